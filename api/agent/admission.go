@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// Admitter is implemented by agents that can tell, without waiting, whether they have the capacity to take a call
+// of a fn now, so that servers may turn the call away before its client uploads a large body.
+type Admitter interface {
+	// Admit returns ErrCallTimeoutServerBusy if the agent has no capacity for a call of the fn with a body of size
+	// bytes, -1 if the size is not known, or the error the call would be rejected with on its body. An admitted call
+	// may still wait for capacity, as other calls may take it first.
+	Admit(ctx context.Context, fn *models.Fn, size int64) error
+}
+
+var _ Admitter = new(agent)
+var _ Admitter = new(lbAgent)
+
+// Admit implements Admitter, admitting calls while the agent has the resources to start a container of the fn, or
+// idle containers to run the call in or to evict for it
+func (a *agent) Admit(ctx context.Context, fn *models.Fn, size int64) error {
+	memory := (uint64(fn.Memory) + uint64(fn.TmpFsSize)) * Mem1MB
+	if util := a.resources.GetUtilization(); util.MemAvail >= memory && util.CpuAvail >= fn.CPUs {
+		return nil
+	}
+	for _, slots := range a.slotMgr.getSlotQueues() {
+		stats := slots.getStats()
+		if stats.containerStates[ContainerStateIdle]+stats.containerStates[ContainerStatePaused] > 0 {
+			return nil
+		}
+	}
+	statsTooBusy(ctx)
+	return models.ErrCallTimeoutServerBusy
+}
+
+// Admit implements Admitter. Lbs only learn the capacity of their runners by placing calls, so they turn away the
+// calls Submit would before placing them: calls while shutting down, and calls whose body the request spool has
+// no room for.
+func (a *lbAgent) Admit(ctx context.Context, fn *models.Fn, size int64) error {
+	select {
+	case <-a.shutWg.Closer():
+		statsTooBusy(ctx)
+		return models.ErrCallTimeoutServerBusy
+	default:
+	}
+	err := a.spool.admit(size)
+	if err == models.ErrCallTimeoutServerBusy {
+		statsTooBusy(ctx)
+	}
+	return err
+}
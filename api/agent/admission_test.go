@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestAdmit(t *testing.T) {
+	ctx := context.Background()
+	a := &agent{
+		resources: NewResourceTracker(&Config{MaxTotalMemory: 256 * Mem1MB}),
+		slotMgr:   NewSlotQueueMgr(),
+	}
+	small := &models.Fn{ID: "small", ResourceConfig: models.ResourceConfig{Memory: 128}}
+	large := &models.Fn{ID: "large", ResourceConfig: models.ResourceConfig{Memory: 512}}
+
+	if err := a.Admit(ctx, small, -1); err != nil {
+		t.Errorf("expected a fn the agent has the resources for to be admitted, got %v", err)
+	}
+	if err := a.Admit(ctx, large, -1); err != models.ErrCallTimeoutServerBusy {
+		t.Errorf("expected a fn the agent has no resources for to be turned away, got %v", err)
+	}
+
+	// an idle container may be evicted for the call
+	slots, _ := a.slotMgr.getSlotQueue(&call{Call: &models.Call{FnID: "other"}, slotHashId: "other"})
+	slots.enterContainerState(ContainerStateIdle)
+	if err := a.Admit(ctx, large, -1); err != nil {
+		t.Errorf("expected a fn to be admitted while there are idle containers, got %v", err)
+	}
+}
+
+func TestLBAdmit(t *testing.T) {
+	ctx := context.Background()
+	a := &lbAgent{
+		shutWg: common.NewWaitGroup(),
+		spool:  &requestSpool{threshold: 1024, maxCall: 64 * 1024, maxTotal: 128 * 1024, used: 96 * 1024},
+	}
+	fn := &models.Fn{ID: "fn", ResourceConfig: models.ResourceConfig{Memory: 128}}
+
+	for i, test := range []struct {
+		size     int64
+		expected error
+	}{
+		{-1, nil},
+		// bodies kept in memory are not spooled
+		{1024, nil},
+		{16 * 1024, nil},
+		{48 * 1024, models.ErrCallTimeoutServerBusy},
+		{96 * 1024, models.ErrRequestContentTooBig},
+	} {
+		if err := a.Admit(ctx, fn, test.size); err != test.expected {
+			t.Errorf("Test %d: expected a body of %d bytes to be admitted with %v, got %v", i, test.size, test.expected, err)
+		}
+	}
+
+	// lbs without a spool keep every body in memory
+	if err := (&lbAgent{shutWg: common.NewWaitGroup()}).Admit(ctx, fn, 96*1024); err != nil {
+		t.Errorf("expected calls to be admitted without a spool, got %v", err)
+	}
+
+	a.shutWg.CloseGroup()
+	if err := a.Admit(ctx, fn, -1); err != models.ErrCallTimeoutServerBusy {
+		t.Errorf("expected calls to be turned away while shutting down, got %v", err)
+	}
+}
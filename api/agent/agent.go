@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -21,6 +22,7 @@ import (
 	"github.com/fnproject/fn/api/common"
 	"github.com/fnproject/fn/api/id"
 	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/api/scratch"
 	"github.com/fnproject/fn/fnext"
 	"github.com/fsnotify/fsnotify"
 	docker "github.com/fsouza/go-dockerclient"
@@ -88,6 +90,7 @@ type agent struct {
 	evictor Evictor
 	// track usage
 	resources ResourceTracker
+	gpus      *gpuTracker
 
 	// used to track running calls / safe shutdown
 	shutWg   *common.WaitGroup
@@ -99,6 +102,50 @@ type agent struct {
 	// additional options to configure each call
 	callOpts []CallOpt
 
+	// invokes chained fns, if enabled
+	chainer *fnChainer
+
+	// mirrors calls to shadow fns, if enabled
+	mirrorer *fnMirrorer
+
+	// persistent scratch volumes of fns, if enabled
+	volumes *volumeManager
+
+	// read-only datasets of fns, if enabled
+	datasetSource DatasetSource
+	datasets      *datasetManager
+
+	// credentials to the scratch space of apps, if enabled
+	scratchStore scratch.Store
+	scratch      *scratchGrants
+
+	// hot containers that died unexpectedly
+	crashes crashLog
+
+	// the logs of the calls running, streamed while they run
+	callLogs callLogStreams
+
+	// where the logs of calls are appended to while they run, if enabled
+	callLogStore CallLogStore
+
+	// CA bundles of private registries, if enabled
+	registryCAs *registryCAManager
+
+	// long running calls running, see models.FnLongRunningAnnotation
+	longRunning int64
+
+	// detached calls that succeeded, see models.FnResultCacheAnnotation
+	results *resultCache
+
+	// faults injected into pulls and creates, if enabled
+	faults *faultInjector
+
+	// hot containers kept warm across restarts, if enabled
+	recovery *containerRecovery
+
+	// containers created ahead of cold starts, if enabled
+	blanks *blankPool
+
 	// deferred actions to call at end of initialisation
 	onStartup []func()
 }
@@ -136,15 +183,56 @@ func New(options ...Option) Agent {
 
 	logrus.Infof("agent starting cfg=%+v", a.cfg)
 
+	a.recovery = newContainerRecovery(&a.cfg)
 	if a.driver == nil {
-		d, err := NewDockerDriver(&a.cfg)
+		conf := driverConfig(&a.cfg)
+		conf.RecoverableContainers = a.recovery.containerIDs()
+		d, err := drivers.New(a.cfg.Driver, conf)
 		if err != nil {
-			logrus.WithError(err).Fatal("failed to create docker driver ")
+			logrus.WithError(err).Fatalf("failed to create %s driver", a.cfg.Driver)
 		}
 		a.driver = d
 	}
+	a.recovery.start(a.driver, a.shutWg.Closer())
+	a.addImageCacheTunables()
 
 	a.resources = NewResourceTracker(&a.cfg)
+	a.gpus = newGPUTracker(&a.cfg)
+	a.results = newResultCache(a.cfg.ResultCacheSize)
+	a.faults = newFaultInjector(&a.cfg)
+	a.blanks = newBlankPool(&a.cfg)
+	go a.blanks.expire(a.shutWg.Closer())
+
+	a.volumes, err = newVolumeManager(&a.cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("error in agent volumes")
+	}
+	go a.volumes.gc(a.shutWg.Closer())
+
+	if a.datasetSource == nil && a.cfg.DatasetSourceURL != "" {
+		a.datasetSource = NewHTTPDatasetSource(a.cfg.DatasetSourceURL)
+	}
+	a.datasets, err = newDatasetManager(&a.cfg, a.datasetSource)
+	if err != nil {
+		logrus.WithError(err).Fatal("error in agent datasets")
+	}
+	go a.datasets.sync(a.shutWg.Closer())
+
+	if a.scratchStore == nil && a.cfg.ScratchStoreURL != "" {
+		a.scratchStore, err = scratch.New(context.Background(), a.cfg.ScratchStoreURL)
+		if err != nil {
+			logrus.WithError(err).Fatal("error in agent scratch store")
+		}
+	}
+	a.scratch = newScratchGrants(&a.cfg, a.scratchStore)
+
+	a.registryCAs, err = newRegistryCAManager(&a.cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("error in agent registry CAs")
+	}
+	go a.registryCAs.watch(a.shutWg.Closer())
+
+	a.loadStagedImages()
 
 	for _, sup := range a.onStartup {
 		sup()
@@ -165,6 +253,22 @@ func WithConfig(cfg *Config) Option {
 	}
 }
 
+// WithDatasetSource sets the source fn datasets are synced from, rather than FN_DATASET_SOURCE_URL
+func WithDatasetSource(source DatasetSource) Option {
+	return func(a *agent) error {
+		a.datasetSource = source
+		return nil
+	}
+}
+
+// WithScratchStore sets the object store the scratch space of apps is kept in, rather than FN_SCRATCH_STORE_URL
+func WithScratchStore(store scratch.Store) Option {
+	return func(a *agent) error {
+		a.scratchStore = store
+		return nil
+	}
+}
+
 // WithDockerDriver Provides a customer driver to agent
 func WithDockerDriver(drv drivers.Driver) Option {
 	return func(a *agent) error {
@@ -199,9 +303,19 @@ func WithCallOptions(opts ...CallOpt) Option {
 
 // NewDockerDriver creates a default docker driver from agent config
 func NewDockerDriver(cfg *Config) (drivers.Driver, error) {
-	return drivers.New("docker", drivers.Config{
+	return drivers.New("docker", driverConfig(cfg))
+}
+
+// NewDriver creates the driver named in agent config, see EnvDriver
+func NewDriver(cfg *Config) (drivers.Driver, error) {
+	return drivers.New(cfg.Driver, driverConfig(cfg))
+}
+
+func driverConfig(cfg *Config) drivers.Config {
+	return drivers.Config{
 		DockerNetworks:                cfg.DockerNetworks,
 		DockerLoadFile:                cfg.DockerLoadFile,
+		DockerDaemons:                 cfg.DockerDaemons,
 		ServerVersion:                 cfg.MinDockerVersion,
 		PreForkPoolSize:               cfg.PreForkPoolSize,
 		PreForkImage:                  cfg.PreForkImage,
@@ -215,7 +329,26 @@ func NewDockerDriver(cfg *Config) (drivers.Driver, error) {
 		ImageCleanExemptTags:          cfg.ImageCleanExemptTags,
 		ImageEnableVolume:             cfg.ImageEnableVolume,
 		DisableUnprivilegedContainers: cfg.DisableUnprivilegedContainers,
-	})
+		FreezeMemoryPercent:           cfg.FreezeMemoryPercent,
+		CgroupRoot:                    cfg.CgroupRoot,
+		DevMode:                       cfg.DevMode,
+		DisableImagePulls:             cfg.DisableImagePulls,
+		ImageTrustPolicy:              cfg.ImageTrustPolicy,
+		RequireSignedImages:           cfg.RequireSignedImages,
+		DockerCertsDir:                cfg.DockerCertsDir,
+		FsStatsIntervalMsecs:          uint64(cfg.FsStatsInterval / time.Millisecond),
+		MockScript:                    cfg.DriverScript,
+		GPURuntime:                    cfg.GPURuntime,
+		SeccompProfilesDir:            cfg.SeccompProfilesDir,
+		SeccompProfile:                cfg.SeccompProfile,
+		AppArmorProfiles:              cfg.AppArmorProfiles,
+		AppArmorProfile:               cfg.AppArmorProfile,
+		Containerd:                    cfg.ContainerdAddress,
+		ContainerdNamespace:           cfg.ContainerdNamespace,
+		ContainerdSnapshotter:         cfg.ContainerdSnapshotter,
+		ContainerdNetNS:               cfg.ContainerdNetNS,
+		ContainerdWarmSnapshots:       cfg.ContainerdWarmSnapshots,
+	}
 }
 
 func (a *agent) Close() error {
@@ -224,6 +357,12 @@ func (a *agent) Close() error {
 	// wait for ongoing sessions
 	a.shutWg.CloseGroup()
 
+	a.blanks.drain()
+
+	if rerr := a.recovery.save(); rerr != nil {
+		logrus.WithError(rerr).Error("Failed to save the agent state, detached containers are not recovered")
+	}
+
 	a.shutonce.Do(func() {
 		// now close docker layer
 		if a.driver != nil {
@@ -263,7 +402,13 @@ func (a *agent) Submit(callI Call) error {
 		)
 	}
 
-	return a.submit(ctx, call)
+	ctx = withFnTags(ctx, call)
+	statsFnCall(ctx)
+	err = a.submit(ctx, call)
+	statsFnCallEnd(ctx, call, err)
+	a.chainer.next(ctx, a, a.cfg.MaxChainDepth, call, err)
+	a.mirrorer.next(ctx, a, call)
+	return err
 }
 
 func (a *agent) startStateTrackers(ctx context.Context, call *call) {
@@ -275,6 +420,13 @@ func (a *agent) endStateTrackers(ctx context.Context, call *call) {
 }
 
 func (a *agent) submit(ctx context.Context, call *call) error {
+	// calls placed by an lb arrive with the deadline of their gRPC stream
+	if deadline, ok := ctx.Deadline(); ok && (call.deadline.IsZero() || deadline.Before(call.deadline)) {
+		call.deadline = deadline
+	}
+	ctx, cancelDeadline := call.withDeadline(ctx)
+	defer cancelDeadline()
+
 	statsCalls(ctx)
 
 	if !a.shutWg.AddSession(1) {
@@ -283,12 +435,25 @@ func (a *agent) submit(ctx context.Context, call *call) error {
 	}
 	defer a.shutWg.DoneSession()
 
+	release, err := a.admitLongRunning(call)
+	if err != nil {
+		statsTooBusy(ctx)
+		return err
+	}
+	defer release()
+
 	statsEnqueue(ctx)
 
 	a.startStateTrackers(ctx, call)
 	defer a.endStateTrackers(ctx, call)
 
+	if a.results.hit(ctx, call) {
+		return a.endCachedCall(ctx, call)
+	}
+
+	slotStart := time.Now()
 	slot, err := a.getSlot(ctx, call)
+	statsFnSlotWait(ctx, time.Since(slotStart))
 	if err != nil {
 		return a.handleCallEnd(ctx, call, slot, err, false)
 	}
@@ -301,12 +466,19 @@ func (a *agent) submit(ctx context.Context, call *call) error {
 	statsDequeue(ctx)
 	statsStartRun(ctx)
 
-	// We are about to execute the function, set container Exec Deadline (call.Timeout)
+	// We are about to execute the function, set container Exec Deadline (call.Timeout), or what is left of it
 	slotCtx, cancel := context.WithTimeout(ctx, time.Duration(call.Timeout)*time.Second)
 	defer cancel()
+	slotCtx, stopLiveness := call.watchLiveness(slotCtx)
 
 	// Pass this error (nil or otherwise) to end directly, to store status, etc.
 	err = slot.exec(slotCtx, call)
+	if stopLiveness() && err != nil {
+		err = models.ErrCallNotLive
+	}
+	if err == nil {
+		a.results.remember(call)
+	}
 	return a.handleCallEnd(ctx, call, slot, err, true)
 }
 
@@ -357,7 +529,7 @@ func (a *agent) getSlot(ctx context.Context, call *call) (Slot, error) {
 		call.slotHashId = getSlotQueueKey(call, slotExtns)
 	}
 
-	call.slots, isNew = a.slotMgr.getSlotQueue(call.slotHashId)
+	call.slots, isNew = a.slotMgr.getSlotQueue(call)
 	call.requestState.UpdateState(ctx, RequestStateWait, call.slots)
 
 	// setup slot caller with a ctx that gets cancelled once waitHot() is completed.
@@ -477,6 +649,7 @@ func (a *agent) checkLaunch(ctx context.Context, call *call, caller slotCaller)
 	if tok == nil {
 		tok = a.resources.GetResourceTokenNB(ctx, mem, call.CPUs)
 	}
+	tok = a.gpus.reserve(ctx, tok, call.gpus)
 
 	if tok != nil {
 		if tok.Error() != nil {
@@ -607,6 +780,11 @@ func (s *hotSlot) exec(ctx context.Context, call *call) error {
 		return err
 	}
 	err = s.dispatch(ctx, call)
+	if s.cfg.EnforceFsSize && s.container.checkFsFull(ctx, err) {
+		// the container cannot take any more calls either
+		err = models.ErrContainerFsFull
+		s.SetError(err)
+	}
 	err2 := s.container.AfterCall(ctx, call.Model(), call.Extensions())
 	if err == nil {
 		err = err2
@@ -618,7 +796,7 @@ func (s *hotSlot) exec(ctx context.Context, call *call) error {
 	return err
 }
 
-func createUDSRequest(ctx context.Context, call *call) *http.Request {
+func createUDSRequest(ctx context.Context, call *call, cfg *Config) *http.Request {
 	req, err := http.NewRequest("POST", "http://localhost/call", call.req.Body)
 	if err != nil {
 		common.Logger(ctx).WithError(err).Error("somebody put a bad url in the call http request. 10 lashes.")
@@ -638,13 +816,18 @@ func createUDSRequest(ctx context.Context, call *call) *http.Request {
 		}
 	}
 
-	req.Header.Set("Fn-Call-Id", call.ID)
-	deadline, ok := ctx.Deadline()
-	if ok {
-		deadlineStr := deadline.Format(time.RFC3339)
-		req.Header.Set("Fn-Deadline", deadlineStr)
+	// fns of calls carrying a W3C trace context continue it from the dispatch, if it is recorded. Our traces are
+	// otherwise kept from fns, see noopOCHTTPFormat. The trace context is a header of each call rather than in the
+	// container env, as the env of a hot container is set when it starts, and its calls are of different traces.
+	if req.Header.Get(common.TraceParentHeader) != "" {
+		if sc := trace.FromContext(ctx).SpanContext(); sc.IsSampled() {
+			req.Header.Set(common.TraceParentHeader, common.TraceParent(sc))
+		}
 	}
 
+	deadline, _ := ctx.Deadline()
+	setPlatformHeaders(req.Header, call, deadline, cfg)
+
 	return req
 }
 
@@ -654,10 +837,17 @@ func (s *hotSlot) dispatch(ctx context.Context, call *call) error {
 
 	// TODO it's possible we can get rid of this (after getting rid of logs API) - may need for call id/debug mode still
 	// TODO there's a timeout race for swapping this back if the container doesn't get killed for timing out, and don't you forget it
-	swapBack := s.container.swap(call.stderr, &call.Stats)
+	swapBack := s.container.swap(call.ID, call.stderr, &call.Stats)
 	defer swapBack()
+	cold := atomic.AddUint64(&s.container.calls, 1) == 1
+	if cold {
+		statsFnColdStart(ctx)
+	}
 
-	req := createUDSRequest(ctx, call)
+	req := createUDSRequest(ctx, call, s.cfg)
+	if s.container.contractVersion != 0 {
+		req.Header.Set(FnHeaderContractVersion, strconv.Itoa(s.container.contractVersion))
+	}
 
 	var resp *http.Response
 	var err error
@@ -678,12 +868,18 @@ func (s *hotSlot) dispatch(ctx context.Context, call *call) error {
 		if strings.Contains(err.Error(), "server response headers exceeded ") {
 			return models.ErrFunctionResponseHdrTooBig
 		}
+		// the container most likely died, tell the caller what it said last
+		call.ErrorDetails = s.container.crashOutput(ctx)
 		return models.ErrFunctionResponse
 	}
 	defer resp.Body.Close()
 
 	common.Logger(ctx).WithField("resp", resp).Debug("Got resp from UDS socket")
 
+	if rw, ok := call.respWriter.(http.ResponseWriter); ok && (s.cfg.EnableTimingHeaders || call.timingHeaders) {
+		setTimingHeaders(rw.Header(), call, cold)
+	}
+
 	ioErrChan := make(chan error, 1)
 	go func() {
 		ioErrChan <- s.writeResp(ctx, s.cfg.MaxResponseSize, resp, call.respWriter)
@@ -784,11 +980,27 @@ func (a *agent) runHot(ctx context.Context, caller slotCaller, call *call, tok R
 	ctrCreatePrepStart := time.Now()
 
 	id := id.New().String()
+	// adopt a container left running by the previous agent, or start a blank container created ahead, if any.
+	// Containers given gpus are created for the gpus of tok, the gpus of others are not tracked.
+	var recovered *recoveredContainer
+	if call.gpus == 0 {
+		recovered = a.recovery.claim(ctx, call)
+	}
+	var blank *blankContainer
+	if recovered != nil {
+		id = recovered.ID
+	} else if blank = a.blanks.claim(call); blank != nil {
+		id = blank.container.id
+		defer blank.cancel()
+	}
 	logger := logrus.WithFields(logrus.Fields{"container_id": id, "app_id": call.AppID, "fn_id": call.FnID, "image": call.Image, "memory": call.Memory, "cpus": call.CPUs, "idle_timeout": call.IdleTimeout})
 	ctx, cancel := context.WithCancel(common.WithLogger(ctx, logger))
 
 	initialized := make(chan struct{}) // when closed, container is ready to handle requests
 	udsWait := make(chan error, 1)     // track UDS state and errors
+	if blank != nil {
+		udsWait = blank.udsWait
+	}
 
 	statsUtilization(ctx, a.resources.GetUtilization())
 	state.UpdateState(ctx, ContainerStateStart, call)
@@ -806,7 +1018,13 @@ func (a *agent) runHot(ctx context.Context, caller slotCaller, call *call, tok R
 
 		// IMPORTANT: for release cookie (remove container), make sure ctx below has no timeout.
 		if cookie != nil {
-			cookie.Close(common.BackgroundContext(ctx))
+			if call.slots != nil {
+				call.slots.enterDraining()
+				defer call.slots.exitDraining()
+			}
+			if !a.detachContainer(common.BackgroundContext(ctx), call, container, cookie, childDone) {
+				cookie.Close(common.BackgroundContext(ctx))
+			}
 		}
 
 		if container != nil {
@@ -852,55 +1070,33 @@ func (a *agent) runHot(ctx context.Context, caller slotCaller, call *call, tok R
 		authToken = call.slots.getAuthToken()
 	}
 
-	container = newHotContainer(ctx, a.evictor, &caller, call, &a.cfg, id, authToken, udsWait)
-	if container == nil {
-		return
-	}
-
-	cookie, err = a.driver.CreateCookie(ctx, container)
-	if err != nil {
-		runHotFailure(ctx, err, caller)
-		return
-	}
+	if blank != nil {
+		container, cookie = blank.container, blank.cookie
+		atomic.StoreInt64(&call.ctrPrepTime, int64(time.Since(ctrCreatePrepStart)))
+	} else {
+		container = newHotContainer(ctx, a.evictor, &caller, call, &a.cfg, id, authToken, recovered, udsWait)
+		if container == nil {
+			return
+		}
+		container.gpus = tokenGPUs(tok)
 
-	needsPull, err := cookie.ValidateImage(ctx)
-	atomic.StoreInt64(&call.ctrPrepTime, int64(time.Since(ctrCreatePrepStart)))
-	if needsPull {
-		waitStart := time.Now()
-		pullCtx, pullCancel := context.WithTimeout(ctx, a.cfg.HotPullTimeout)
-		err = cookie.PullImage(pullCtx)
-		pullCancel()
+		cookie, err = a.createHotContainer(ctx, call, container, recovered, &ctrCreatePrepStart)
 		if err != nil {
-			if pullCtx.Err() == context.DeadlineExceeded {
-				err = models.ErrDockerPullTimeout
-			}
-		} else {
-			needsPull, err = cookie.ValidateImage(ctx) // uses original ctx timeout
-			if needsPull {
-				// Image must have removed by image cleaner, manual intervention, etc.
-				err = models.ErrCallTimeoutServerBusy
-			}
+			runHotFailure(ctx, err, caller)
+			return
 		}
-		atomic.StoreInt64(&call.imagePullWaitTime, int64(time.Since(waitStart)))
-	}
-	if err != nil {
-		runHotFailure(ctx, err, caller)
-		return
-	}
-
-	ctrCreateStart := time.Now()
-	err = cookie.CreateContainer(ctx)
-	if err != nil {
-		runHotFailure(ctx, err, caller)
-		return
 	}
 
+	runStart := time.Now()
 	waiter, err := cookie.Run(ctx)
 	if err != nil {
 		runHotFailure(ctx, err, caller)
 		return
 	}
-	atomic.StoreInt64(&call.ctrCreateTime, int64(time.Since(ctrCreateStart)))
+	atomic.AddInt64(&call.ctrCreateTime, int64(time.Since(runStart)))
+	if recovered == nil {
+		a.fillBlanks(ctx, call)
+	}
 
 	childDone = make(chan struct{})
 
@@ -909,7 +1105,7 @@ func (a *agent) runHot(ctx context.Context, caller slotCaller, call *call, tok R
 		defer close(childDone)
 		defer cancel() // also close if we get an agent shutdown / idle timeout
 
-		// We record init wait for three basic states below: "initialized", "canceled", "timedout"
+		// We record init wait for four basic states below: "initialized", "notready", "canceled", "timedout"
 		// Notice how we do not distinguish between agent-shutdown, eviction, ctx.Done, etc. This is
 		// because monitoring go-routine may pick these events earlier and cancel the ctx.
 		initStart := time.Now()
@@ -920,6 +1116,13 @@ func (a *agent) runHot(ctx context.Context, caller slotCaller, call *call, tok R
 		// INIT BARRIER HERE. Wait for the initialization go-routine signal
 		select {
 		case <-initialized:
+			if err := probeContainer(ctx, &a.cfg, container); err != nil {
+				notReadyTime := time.Now()
+				statsContainerUDSInitLatency(ctx, initStart, notReadyTime, "notready")
+				atomic.StoreInt64(&call.initStartTime, int64(notReadyTime.Sub(initStart)))
+				runHotFailure(ctx, err, caller)
+				return
+			}
 			initTime := time.Now() // Declaring this prior to keep the stats in sync
 			statsContainerUDSInitLatency(ctx, initStart, initTime, "initialized")
 			atomic.StoreInt64(&call.initStartTime, int64(initTime.Sub(initStart)))
@@ -965,11 +1168,33 @@ func (a *agent) runHot(ctx context.Context, caller slotCaller, call *call, tok R
 	}()
 
 	runRes := waiter.Wait(ctx)
+	close(container.exited)
 	if runRes != nil && runRes.Error() != context.Canceled {
 		logger.WithError(runRes.Error()).Info("hot function terminated")
+		if status := runRes.Status(); status == drivers.StatusError || status == drivers.StatusKilled {
+			a.recordCrash(call, container, runRes.Error())
+		}
 	}
 }
 
+// recordCrash remembers a hot container that died unexpectedly, with the tail of its output
+func (a *agent) recordCrash(call *call, c *container, err error) {
+	c.swapMu.Lock()
+	lastCallID := c.lastCallID
+	c.swapMu.Unlock()
+
+	a.crashes.add(ContainerCrash{
+		ContainerID: c.id,
+		AppID:       call.AppID,
+		FnID:        call.FnID,
+		Image:       c.image,
+		LastCallID:  lastCallID,
+		Error:       err.Error(),
+		Output:      c.tail.String(),
+		Time:        time.Now(),
+	})
+}
+
 //checkSocketDestination verifies that the socket file created by the FDK is valid and permitted - notably verifying that any symlinks are relative to the socket dir
 func checkSocketDestination(filename string) error {
 	finfo, err := os.Lstat(filename)
@@ -1063,6 +1288,92 @@ func inotifyAwait(ctx context.Context, iofsDir string, udsWait chan error) {
 	}()
 }
 
+// createHotContainer creates the hot container with the driver, pulling its image if need be, or adopts the container
+// recovered for it, without starting it. The cookie returned is to be closed even if an error is. The times it took
+// are recorded in call, from prepStart, unless prepStart is nil.
+func (a *agent) createHotContainer(ctx context.Context, call *call, container *container, recovered *recoveredContainer, prepStart *time.Time) (drivers.Cookie, error) {
+	volumes, releaseVolumes, err := a.volumes.acquire(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+	datasets, releaseDatasets, err := a.datasets.acquire(ctx, call)
+	if err != nil {
+		releaseVolumes()
+		return nil, err
+	}
+	container.volumes = append(volumes, datasets...)
+	closeContainer := container.close
+	container.close = func() {
+		closeContainer()
+		releaseVolumes()
+		releaseDatasets()
+	}
+
+	var cookie drivers.Cookie
+	if recovered != nil {
+		cookie, err = a.recovery.adopt(ctx, container)
+	} else if err = a.scratch.grant(ctx, call, container); err == nil {
+		cookie, err = a.driver.CreateCookie(ctx, container)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	needsPull, err := cookie.ValidateImage(ctx)
+	if prepStart != nil {
+		atomic.StoreInt64(&call.ctrPrepTime, int64(time.Since(*prepStart)))
+	}
+	if needsPull {
+		waitStart := time.Now()
+		pullCtx, pullCancel := context.WithDeadline(ctx, call.deadlineWithin(a.cfg.HotPullTimeout))
+		err = a.faults.inject(pullCtx, FaultPull, call)
+		if err == nil {
+			statsFnImagePull(ctx, call)
+			err = cookie.PullImage(pullCtx)
+		}
+		pullCancel()
+		if err != nil {
+			if pullCtx.Err() == context.DeadlineExceeded {
+				err = models.ErrDockerPullTimeout
+			}
+		} else {
+			needsPull, err = cookie.ValidateImage(ctx) // uses original ctx timeout
+			if needsPull {
+				// Image must have removed by image cleaner, manual intervention, etc.
+				err = models.ErrCallTimeoutServerBusy
+			}
+		}
+		if prepStart != nil {
+			atomic.StoreInt64(&call.imagePullWaitTime, int64(time.Since(waitStart)))
+		}
+	}
+	if err != nil {
+		return cookie, err
+	}
+
+	ctrCreateStart := time.Now()
+	createCtx, createCancel := call.withDeadline(ctx)
+	err = a.faults.inject(createCtx, FaultCreate, call)
+	if err == nil {
+		err = cookie.CreateContainer(createCtx)
+	}
+	createCancel()
+	if err != nil {
+		return cookie, err
+	}
+	if prepStart != nil {
+		atomic.StoreInt64(&call.ctrCreateTime, int64(time.Since(ctrCreateStart)))
+	}
+	if cc, ok := cookie.(drivers.ContractCookie); ok {
+		container.contractVersion = cc.ContractVersion()
+	}
+	if fc, ok := cookie.(drivers.FsUsageCookie); ok {
+		container.fsUsage = fc.FsUsage
+	}
+
+	return cookie, nil
+}
+
 // runHotReq enqueues a free slot to slot queue manager and watches various timers and the consumer until
 // the slot is consumed. A return value of false means, the container should shutdown and no subsequent
 // calls should be made to this function.
@@ -1070,6 +1381,10 @@ func (a *agent) runHotReq(ctx context.Context, call *call, state ContainerState,
 
 	var err error
 	isFrozen := false
+	// true once the container is unpaused ahead of a call, until it takes one
+	isEager := false
+	// set while the container is paused, if it may be unpaused ahead of calls
+	var unfreezer chan struct{}
 
 	freezeTimer := common.NewTimer(a.cfg.FreezeIdle)
 	idleTimer := common.NewTimer(time.Duration(call.IdleTimeout) * time.Second)
@@ -1083,6 +1398,17 @@ func (a *agent) runHotReq(ctx context.Context, call *call, state ContainerState,
 		}
 	}()
 
+	// containers stop taking calls before their scratch credentials expire
+	var retire <-chan time.Time
+	if !c.retireAt.IsZero() {
+		if !time.Now().Before(c.retireAt) {
+			return false
+		}
+		retireTimer := time.NewTimer(time.Until(c.retireAt))
+		defer retireTimer.Stop()
+		retire = retireTimer.C
+	}
+
 	state.UpdateState(ctx, ContainerStateIdle, call)
 	c.EnableEviction(call)
 
@@ -1097,8 +1423,13 @@ func (a *agent) runHotReq(ctx context.Context, call *call, state ContainerState,
 		case <-ctx.Done(): // container shutdown
 		case <-a.shutWg.Closer(): // agent shutdown
 		case <-idleTimer.C:
+		case <-retire:
 		case <-freezeTimer.C:
 			if !isFrozen {
+				if isEager {
+					statsWastedUnfreeze(ctx)
+					isEager = false
+				}
 				ctx, cancel := context.WithTimeout(ctx, pauseTimeout)
 				err = cookie.Freeze(ctx)
 				cancel()
@@ -1107,7 +1438,24 @@ func (a *agent) runHotReq(ctx context.Context, call *call, state ContainerState,
 				}
 				isFrozen = true
 				state.UpdateState(ctx, ContainerStatePaused, call)
+				if a.cfg.EagerUnfreeze {
+					unfreezer = call.slots.unfreezer
+				}
+			}
+			continue
+		case <-unfreezer:
+			unfreezer = nil
+			ctx, cancel := context.WithTimeout(ctx, pauseTimeout)
+			err = cookie.Unfreeze(ctx)
+			cancel()
+			if err != nil {
+				return false
 			}
+			isFrozen = false
+			isEager = true
+			statsEagerUnfreeze(ctx)
+			state.UpdateState(ctx, ContainerStateIdle, call)
+			freezeTimer.Reset(a.cfg.FreezeIdle)
 			continue
 		case <-evicted:
 		}
@@ -1123,6 +1471,14 @@ func (a *agent) runHotReq(ctx context.Context, call *call, state ContainerState,
 			statsContainerEvicted(ctx, state.GetState())
 		default:
 		}
+		if isEager {
+			statsWastedUnfreeze(ctx)
+		}
+		select {
+		case <-a.shutWg.Closer():
+			c.idleAtShutdown = true
+		default:
+		}
 		return false
 	}
 
@@ -1143,6 +1499,9 @@ func (a *agent) runHotReq(ctx context.Context, call *call, state ContainerState,
 	}
 
 	state.UpdateState(ctx, ContainerStateBusy, call)
+	if a.cfg.EagerUnfreeze {
+		call.slots.unfreezeAhead()
+	}
 	return true
 }
 
@@ -1153,6 +1512,7 @@ func (a *agent) runHotReq(ctx context.Context, call *call, state ContainerState,
 type container struct {
 	id             string // contrived
 	image          string
+	pullPolicy     string
 	env            map[string]string
 	extensions     map[string]string
 	memory         uint64
@@ -1164,7 +1524,16 @@ type container struct {
 	pendingSignals *uint64
 	messageQueue   *uint64
 	tmpFsSize      uint64
+	tmpFsInodes    uint64
 	disableNet     bool
+	stopSignal     string
+	stopTimeout    time.Duration
+	daemonLabels   map[string]string
+	gpus           []string
+	networks       []string
+	seccomp        string
+	appArmor       string
+	volumes        [][2]string
 	iofs           iofs
 	logCfg         drivers.LoggerConfig
 	close          func()
@@ -1174,28 +1543,67 @@ type container struct {
 	authToken      string
 
 	stderr io.Writer
+	// tail of the output of the container, reported if it dies unexpectedly
+	tail *outputTail
+	// closed once the container has exited
+	exited chan struct{}
+	// number of calls dispatched to the container
+	calls uint64
+	// fdk contract version negotiated with the image of the container, 0 if the driver does not negotiate one
+	contractVersion int
+	// samples the fs usage of the container, nil if the driver does not report it
+	fsUsage func(context.Context) (drivers.FsUsage, error)
+	// why the container filesystem is full, if a stat found it so, see fsFullReason
+	fsFull atomic.Value
 
 	udsClient http.Client
 
 	// swapMu protects the stats swapping
-	swapMu sync.Mutex
-	stats  *driver_stats.Stats
+	swapMu     sync.Mutex
+	stats      *driver_stats.Stats
+	lastCallID string
 
 	evictor    Evictor
 	evictToken *EvictToken
+
+	// set if the container was idle when the agent shut down, see containerRecovery
+	idleAtShutdown bool
+	// set if the container is left running for the agent to recover, its iofs is kept
+	detached bool
+	// when the container stops taking calls, for its scratch credentials not to expire during one, if it has any
+	retireAt time.Time
 }
 
 var _ drivers.ContainerTask = &container{}
 
-// newHotContainer creates a container that can be used for multiple sequential events
-func newHotContainer(ctx context.Context, evictor Evictor, caller *slotCaller, call *call, cfg *Config, id, authToken string, udsWait chan error) *container {
+// newHotContainer creates a container that can be used for multiple sequential events. Containers recovered from
+// a previous agent keep their iofs, and are listening already.
+func newHotContainer(ctx context.Context, evictor Evictor, caller *slotCaller, call *call, cfg *Config, id, authToken string, recovered *recoveredContainer, udsWait chan error) *container {
 
 	var iofs iofs
 	var err error
 
 	logger := common.Logger(ctx)
 
-	if cfg.IOFSEnableTmpfs {
+	env := cloneStrMap(call.Config) // clone to avoid data race
+	setPlatformEnv(env, call, cfg)
+
+	// templated config is resolved per container, so that secrets are only
+	// fetched when they are needed and never stored alongside the config
+	if err := resolveConfigTemplates(ctx, call, env); err != nil {
+		udsWait <- err
+		return nil
+	}
+
+	logCfg, err := loggerConfig(ctx, call, cfg)
+	if err != nil {
+		udsWait <- err
+		return nil
+	}
+
+	if recovered != nil {
+		iofs = &directoryIOFS{recovered.IOFSAgentPath, recovered.IOFSDockerPath}
+	} else if cfg.IOFSEnableTmpfs {
 		iofs, err = newTmpfsIOFS(ctx, cfg)
 	} else {
 		iofs, err = newDirectoryIOFS(ctx, cfg)
@@ -1205,7 +1613,15 @@ func newHotContainer(ctx context.Context, evictor Evictor, caller *slotCaller, c
 		return nil
 	}
 
-	inotifyAwait(ctx, iofs.AgentPath(), udsWait)
+	if recovered != nil {
+		if err := checkSocketDestination(filepath.Join(iofs.AgentPath(), udsFilename)); err != nil {
+			udsWait <- err
+		} else {
+			close(udsWait)
+		}
+	} else {
+		inotifyAwait(ctx, iofs.AgentPath(), udsWait)
+	}
 
 	// IMPORTANT: we are not operating on a TTY allocated container. This means, stderr and stdout are multiplexed
 	// from the same stream internally via docker using a multiplexing protocol. Therefore, stderr/stdout *BOTH*
@@ -1223,14 +1639,13 @@ func newHotContainer(ctx context.Context, evictor Evictor, caller *slotCaller, c
 	var bufs []*bytes.Buffer
 	var stderr io.WriteCloser = call.stderr
 	if _, ok := stderr.(common.NoopReadWriteCloser); !ok {
-		gw := common.NewGhostWriter()
 		buf1 := bufPool.Get().(*bytes.Buffer)
 		sec := &nopCloser{&logWriter{
 			logrus.WithFields(logrus.Fields{"tag": "stderr", "app_id": call.AppID, "fn_id": call.FnID, "image": call.Image, "container_id": id}),
 		}}
-		gw.Swap(newLineWriterWithBuffer(buf1, sec))
-		stderr = gw
+		stderr = newCallLogMux(newLineWriterWithBuffer(buf1, sec))
 		bufs = append(bufs, buf1)
+		env[EnvLogFraming] = "1"
 	}
 
 	baseTransport := &http.Transport{
@@ -1245,7 +1660,36 @@ func newHotContainer(ctx context.Context, evictor Evictor, caller *slotCaller, c
 		},
 	}
 
-	env := cloneStrMap(call.Config) // clone to avoid data race
+	var stopSignal string
+	stopTimeout := cfg.ContainerStopTimeout
+	if stop, err := call.Annotations.Stop(); err != nil {
+		logger.WithError(err).Warn("ignoring invalid fn stop annotation")
+	} else if stop != nil {
+		stopSignal = stop.Signal
+		if stop.Timeout != nil {
+			stopTimeout = time.Duration(*stop.Timeout) * time.Second
+		}
+	}
+
+	daemonLabels, err := call.Annotations.DockerDaemonLabels()
+	if err != nil {
+		logger.WithError(err).Warn("ignoring invalid fn docker daemon annotation")
+	}
+
+	pullPolicy, err := call.Annotations.ImagePullPolicy()
+	if err != nil {
+		logger.WithError(err).Warn("ignoring invalid fn image pull policy annotation")
+	}
+
+	policy, err := call.Annotations.Policy()
+	if err != nil {
+		logger.WithError(err).Warn("ignoring invalid app policy annotation")
+	}
+	dockerAuth := call.dockerAuth
+	if dockerAuth == nil {
+		dockerAuth = policyDockerAuth(call, policy)
+	}
+	seccompProfile, appArmorProfile := policyProfiles(policy)
 
 	// Debug info exposed to FDK/Container
 	if cfg.EnableFDKDebugInfo {
@@ -1254,32 +1698,36 @@ func newHotContainer(ctx context.Context, evictor Evictor, caller *slotCaller, c
 		}
 	}
 
-	return &container{
+	c := &container{
 		id:             id, // XXX we could just let docker generate ids...
 		image:          call.Image,
+		pullPolicy:     pullPolicy,
 		env:            env,
 		extensions:     cloneStrMap(call.extensions), // avoid date race
 		memory:         call.Memory,
 		cpus:           uint64(call.CPUs),
 		fsSize:         cfg.MaxFsSize,
 		pids:           uint64(cfg.MaxPIDs),
-		openFiles:      cfg.MaxOpenFiles,
-		lockedMemory:   cfg.MaxLockedMemory,
-		pendingSignals: cfg.MaxPendingSignals,
-		messageQueue:   cfg.MaxMessageQueue,
+		openFiles:      policyULimit(policy, "nofile", cfg.MaxOpenFiles),
+		lockedMemory:   policyULimit(policy, "memlock", cfg.MaxLockedMemory),
+		pendingSignals: policyULimit(policy, "sigpending", cfg.MaxPendingSignals),
+		messageQueue:   policyULimit(policy, "msgqueue", cfg.MaxMessageQueue),
 		tmpFsSize:      uint64(call.TmpFsSize),
+		tmpFsInodes:    tmpFsInodeLimit(call, cfg),
 		disableNet:     call.disableNet,
+		stopSignal:     stopSignal,
+		stopTimeout:    stopTimeout,
+		daemonLabels:   daemonLabels,
+		networks:       policyNetworks(policy),
+		seccomp:        seccompProfile,
+		appArmor:       appArmorProfile,
 		iofs:           iofs,
-		dockerAuth:     call.dockerAuth,
+		dockerAuth:     dockerAuth,
 		authToken:      authToken,
-		logCfg: drivers.LoggerConfig{
-			URL: strings.TrimSpace(call.SyslogURL),
-			Tags: []drivers.LoggerTag{
-				{Name: "app_id", Value: call.AppID},
-				{Name: "fn_id", Value: call.FnID},
-			},
-		},
-		stderr: stderr,
+		logCfg:         logCfg,
+		stderr:         stderr,
+		tail:           newOutputTail(cfg.ContainerOutputTailSize),
+		exited:         make(chan struct{}),
 		udsClient: http.Client{
 			// use this transport so we can trace the requests to container, handy for debugging...
 			Transport: &ochttp.Transport{
@@ -1292,17 +1740,20 @@ func newHotContainer(ctx context.Context, evictor Evictor, caller *slotCaller, c
 		evictor:    evictor,
 		beforeCall: func(context.Context, *models.Call, drivers.CallExtensions) error { return nil },
 		afterCall:  func(context.Context, *models.Call, drivers.CallExtensions) error { return nil },
-		close: func() {
-			stderr.Close()
-			for _, b := range bufs {
-				bufPool.Put(b)
-			}
+	}
+	c.close = func() {
+		stderr.Close()
+		for _, b := range bufs {
+			bufPool.Put(b)
+		}
+		if !c.detached {
 			if err := iofs.Close(); err != nil {
 				logger.WithError(err).Error("Error closing IOFS")
 			}
-			baseTransport.CloseIdleConnections()
-		},
+		}
+		baseTransport.CloseIdleConnections()
 	}
+	return c
 }
 
 var _ propagation.HTTPFormat = noopOCHTTPFormat{}
@@ -1317,21 +1768,21 @@ func (noopOCHTTPFormat) SpanContextFromRequest(req *http.Request) (sc trace.Span
 }
 func (noopOCHTTPFormat) SpanContextToRequest(sc trace.SpanContext, req *http.Request) {}
 
-func (c *container) swap(stderr io.Writer, cs *driver_stats.Stats) func() {
-	// if they aren't using a ghost writer, the logs are disabled, we can skip swapping
-	gw, ok := c.stderr.(common.GhostWriter)
-	var ostderr io.Writer
+func (c *container) swap(callID string, stderr io.Writer, cs *driver_stats.Stats) func() {
+	// if they aren't using a log mux, the logs are disabled, we can skip swapping
+	mux, ok := c.stderr.(*callLogMux)
 	if ok {
-		ostderr = gw.Swap(stderr)
+		mux.swap(callID, stderr)
 	}
 	c.swapMu.Lock()
 	ocs := c.stats
 	c.stats = cs
+	c.lastCallID = callID
 	c.swapMu.Unlock()
 
 	return func() {
-		if ostderr != nil {
-			c.stderr.(common.GhostWriter).Swap(ostderr)
+		if ok {
+			mux.swap("", nil)
 		}
 		c.swapMu.Lock()
 		c.stats = ocs
@@ -1342,10 +1793,11 @@ func (c *container) swap(stderr io.Writer, cs *driver_stats.Stats) func() {
 func (c *container) Id() string                         { return c.id }
 func (c *container) Command() string                    { return "" }
 func (c *container) Input() io.Reader                   { return common.NoopReadWriteCloser{} }
-func (c *container) Logger() (io.Writer, io.Writer)     { return c.stderr, c.stderr }
-func (c *container) Volumes() [][2]string               { return nil }
+func (c *container) Logger() (io.Writer, io.Writer)     { return c.output(), c.output() }
+func (c *container) Volumes() [][2]string               { return c.volumes }
 func (c *container) WorkDir() string                    { return "" }
 func (c *container) Image() string                      { return c.image }
+func (c *container) ImagePullPolicy() string            { return c.pullPolicy }
 func (c *container) EnvVars() map[string]string         { return c.env }
 func (c *container) Memory() uint64                     { return c.memory * 1024 * 1024 } // convert MB
 func (c *container) CPUs() uint64                       { return c.cpus }
@@ -1362,6 +1814,38 @@ func (c *container) UDSAgentPath() string               { return c.iofs.AgentPat
 func (c *container) UDSDockerPath() string              { return c.iofs.DockerPath() }
 func (c *container) UDSDockerDest() string              { return iofsDockerMountDest }
 func (c *container) DisableNet() bool                   { return c.disableNet }
+func (c *container) StopSignal() string                 { return c.stopSignal }
+func (c *container) StopTimeout() time.Duration         { return c.stopTimeout }
+
+func (c *container) DaemonLabels() map[string]string { return c.daemonLabels }
+func (c *container) Networks() []string              { return c.networks }
+func (c *container) GPUs() []string                  { return c.gpus }
+func (c *container) SeccompProfile() string          { return c.seccomp }
+func (c *container) AppArmorProfile() string         { return c.appArmor }
+
+// output is where the output of the container goes, it is kept in its tail as well as logged
+func (c *container) output() io.Writer {
+	if c.tail == nil {
+		return c.stderr
+	}
+	return io.MultiWriter(c.tail, c.stderr)
+}
+
+// crashOutput returns the tail of the output of the container once it has exited, or whatever it has so far if it
+// does not exit in time
+func (c *container) crashOutput(ctx context.Context) string {
+	if c.tail == nil {
+		return ""
+	}
+	timer := time.NewTimer(crashOutputWait)
+	defer timer.Stop()
+	select {
+	case <-c.exited:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return c.tail.String()
+}
 
 // WriteStat publishes each metric in the specified Stats structure as a histogram metric
 func (c *container) WriteStat(ctx context.Context, stat driver_stats.Stat) {
@@ -1370,6 +1854,7 @@ func (c *container) WriteStat(ctx context.Context, stat driver_stats.Stat) {
 			stats.Record(ctx, m.M(int64(value)))
 		}
 	}
+	c.recordFsUsage(stat)
 
 	c.swapMu.Lock()
 	if c.stats != nil {
@@ -29,6 +29,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"go.opencensus.io/trace"
 )
 
 func init() {
@@ -282,7 +283,7 @@ func TestLoggerIsStringerAndWorks(t *testing.T) {
 	// TODO test limit writer, logrus writer, etc etc
 
 	var call models.Call
-	logger := setupLogger(context.Background(), 1*1024*1024, true, &call)
+	logger := setupLogger(context.Background(), 1*1024*1024, true, &call, nil, 0)
 
 	if _, ok := logger.(fmt.Stringer); !ok {
 		// NOTE: if you are reading, maybe what you've done is ok, but be aware we were relying on this for optimization...
@@ -306,7 +307,7 @@ func TestLoggerIsStringerAndWorks(t *testing.T) {
 func TestLoggerTooBig(t *testing.T) {
 
 	var call models.Call
-	logger := setupLogger(context.Background(), 10, true, &call)
+	logger := setupLogger(context.Background(), 10, true, &call, nil, 0)
 
 	str := fmt.Sprintf("0 line\n1 l\n-----max log size 10 bytes exceeded, truncating log-----\n")
 
@@ -961,7 +962,7 @@ func TestPipesAreClear(t *testing.T) {
 		ResourceConfig: models.ResourceConfig{
 			Timeout:     ca.Timeout,
 			IdleTimeout: ca.IdleTimeout,
-			Memory:      ca.Memory,
+			Memory:      models.Megabytes(ca.Memory),
 		},
 	}
 
@@ -1104,7 +1105,7 @@ func TestCallsDontInterlace(t *testing.T) {
 		ResourceConfig: models.ResourceConfig{
 			Timeout:     call.Timeout,
 			IdleTimeout: call.IdleTimeout,
-			Memory:      call.Memory,
+			Memory:      models.Megabytes(call.Memory),
 		},
 	}
 
@@ -1195,7 +1196,7 @@ func TestNBIOResourceTracker(t *testing.T) {
 		ResourceConfig: models.ResourceConfig{
 			Timeout:     call.Timeout,
 			IdleTimeout: call.IdleTimeout,
-			Memory:      call.Memory,
+			Memory:      models.Megabytes(call.Memory),
 		},
 	}
 
@@ -1287,7 +1288,7 @@ func TestDockerAuthExtn(t *testing.T) {
 
 	errC := make(chan error, 10)
 
-	c := newHotContainer(ctx, nil, nil, call, cfg, id.New().String(), "", errC)
+	c := newHotContainer(ctx, nil, nil, call, cfg, id.New().String(), "", nil, errC)
 	if c == nil {
 		err := <-errC
 		t.Fatal("got unexpected err: ", err)
@@ -1300,7 +1301,7 @@ func TestDockerAuthExtn(t *testing.T) {
 		t.Fatal("got unexpected err: ", err)
 	}
 
-	c = newHotContainer(ctx, nil, nil, call, cfg, id.New().String(), "TestRegistryToken", errC)
+	c = newHotContainer(ctx, nil, nil, call, cfg, id.New().String(), "TestRegistryToken", nil, errC)
 	if c == nil {
 		err := <-errC
 		t.Fatal("got unexpected err: ", err)
@@ -1408,7 +1409,7 @@ func TestContainerDisableIO(t *testing.T) {
 
 	errC := make(chan error, 10)
 
-	c := newHotContainer(ctx, nil, nil, call, cfg, id.New().String(), "", errC)
+	c := newHotContainer(ctx, nil, nil, call, cfg, id.New().String(), "", nil, errC)
 	if c == nil {
 		err := <-errC
 		t.Fatal("got unexpected err: ", err)
@@ -1811,3 +1812,26 @@ func TestContainerAfterWrapNotOK(t *testing.T) {
 	assert.Equal(t, cust.isBefore, true)
 	assert.Equal(t, cust.isAfter, true)
 }
+
+func TestUDSRequestTraceParent(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest("POST", "/invoke/fn", nil)
+	c := &call{Call: &models.Call{ID: "call"}, req: req}
+
+	ctx, span := trace.StartSpan(context.Background(), "dispatch", trace.WithSampler(trace.NeverSample()))
+	defer span.End()
+	if h := createUDSRequest(ctx, c, &Config{}).Header; h.Get(common.TraceParentHeader) != "" {
+		t.Fatalf("expected no trace context for a call without one, got %q", h.Get(common.TraceParentHeader))
+	}
+
+	req.Header.Set(common.TraceParentHeader, parent)
+	if h := createUDSRequest(ctx, c, &Config{}).Header; h.Get(common.TraceParentHeader) != parent {
+		t.Fatalf("expected the trace context of the call to pass through an unsampled dispatch, got %q", h.Get(common.TraceParentHeader))
+	}
+
+	ctx, sampled := trace.StartSpan(context.Background(), "dispatch", trace.WithSampler(trace.AlwaysSample()))
+	defer sampled.End()
+	if h := createUDSRequest(ctx, c, &Config{}).Header; h.Get(common.TraceParentHeader) != common.TraceParent(sampled.SpanContext()) {
+		t.Fatalf("expected the fn to continue the trace from the dispatch, got %q", h.Get(common.TraceParentHeader))
+	}
+}
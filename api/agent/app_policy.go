@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	dockerdriver "github.com/fnproject/fn/api/agent/drivers/docker"
+	"github.com/fnproject/fn/api/models"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// policyULimit returns the ulimit name of the app policy, capped at max, the limit of the agent
+func policyULimit(policy *models.AppPolicy, name string, max *uint64) *uint64 {
+	if policy == nil {
+		return max
+	}
+	v, ok := policy.ULimits[name]
+	if !ok || (max != nil && *max < v) {
+		return max
+	}
+	return &v
+}
+
+func policyNetworks(policy *models.AppPolicy) []string {
+	if policy == nil {
+		return nil
+	}
+	return policy.Networks
+}
+
+// policyProfiles returns the seccomp and AppArmor profiles of the app policy, empty for the defaults of the driver
+func policyProfiles(policy *models.AppPolicy) (seccomp, apparmor string) {
+	if policy == nil {
+		return "", ""
+	}
+	return policy.Seccomp, policy.AppArmor
+}
+
+// policyDockerAuth returns an Auther for the registry secret of the app policy, or nil if it has none
+func policyDockerAuth(c *call, policy *models.AppPolicy) dockerdriver.Auther {
+	if policy == nil || policy.RegistrySecret == "" {
+		return nil
+	}
+	return &registrySecretAuth{secrets: c.secrets, appID: c.AppID, name: policy.RegistrySecret}
+}
+
+// registrySecretAuth pulls images with the registry credentials held in a secret of an app
+type registrySecretAuth struct {
+	secrets SecretResolver
+	appID   string
+	name    string
+}
+
+// DockerAuth implements dockerdriver.Auther
+func (r *registrySecretAuth) DockerAuth(ctx context.Context, image string) (*docker.AuthConfiguration, error) {
+	if r.secrets == nil {
+		return nil, registrySecretError(r.name, errNoSecretResolver)
+	}
+	raw, err := r.secrets.Secret(ctx, r.appID, r.name)
+	if err != nil {
+		return nil, registrySecretError(r.name, err)
+	}
+	var auth docker.AuthConfiguration
+	if err := json.Unmarshal([]byte(raw), &auth); err != nil {
+		return nil, registrySecretError(r.name, err)
+	}
+	return &auth, nil
+}
+
+// registrySecretError reports a bad registry secret as the function's fault, as the secret is the app's
+func registrySecretError(name string, err error) error {
+	return models.NewFuncError(models.NewAPIError(http.StatusBadGateway,
+		fmt.Errorf("error reading registry secret %s: %v", name, err)))
+}
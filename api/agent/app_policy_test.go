@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestPolicyULimit(t *testing.T) {
+	max := uint64(350)
+	policy := &models.AppPolicy{ULimits: map[string]uint64{"nofile": 100, "memlock": 1024}}
+
+	if v := policyULimit(nil, "nofile", &max); v != &max {
+		t.Fatalf("expected the limit of the agent without a policy, got %v", v)
+	}
+	if v := policyULimit(policy, "nofile", &max); v == nil || *v != 100 {
+		t.Fatalf("expected the limit of the policy, got %v", v)
+	}
+	if v := policyULimit(policy, "sigpending", &max); v != &max {
+		t.Fatalf("expected the limit of the agent for limits the policy does not set, got %v", v)
+	}
+	small := uint64(10)
+	if v := policyULimit(policy, "memlock", &small); v != &small {
+		t.Fatalf("expected the policy to be capped at the limit of the agent, got %v", v)
+	}
+	if v := policyULimit(policy, "memlock", nil); v == nil || *v != 1024 {
+		t.Fatalf("expected the limit of the policy without a limit of the agent, got %v", v)
+	}
+}
+
+func TestRegistrySecretAuth(t *testing.T) {
+	ctx := context.Background()
+	secrets := SecretResolverFunc(func(ctx context.Context, appID, name string) (string, error) {
+		if appID != "app" || name != "pull" {
+			t.Fatalf("unexpected secret %s of %s", name, appID)
+		}
+		return `{"username":"acme","password":"hunter2"}`, nil
+	})
+
+	c := &call{Call: &models.Call{AppID: "app"}, secrets: secrets}
+	if policyDockerAuth(c, &models.AppPolicy{}) != nil {
+		t.Fatal("expected no auth without a registry secret")
+	}
+
+	auth, err := policyDockerAuth(c, &models.AppPolicy{RegistrySecret: "pull"}).DockerAuth(ctx, "acme/fn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.Username != "acme" || auth.Password != "hunter2" {
+		t.Fatalf("unexpected auth %+v", auth)
+	}
+
+	c.secrets = nil
+	if _, err := policyDockerAuth(c, &models.AppPolicy{RegistrySecret: "pull"}).DockerAuth(ctx, "acme/fn"); err == nil {
+		t.Fatal("expected an error without a secret resolver")
+	}
+}
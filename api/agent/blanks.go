@@ -0,0 +1,224 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/id"
+	"github.com/sirupsen/logrus"
+)
+
+// blankExpiryInterval is how often blank containers that were not started are checked for expiry
+const blankExpiryInterval = 5 * time.Second
+
+// blankContainer is a hot container created but not started, see EnvBlankContainers
+type blankContainer struct {
+	container *container
+	cookie    drivers.Cookie
+	// udsWait is told once the container, when started, listens on its socket
+	udsWait chan error
+	// cancel stops awaiting the socket of the container
+	cancel  func()
+	expires time.Time
+}
+
+// discard removes a blank container that is not to be started
+func (b *blankContainer) discard(ctx context.Context) {
+	if b.cookie != nil {
+		b.cookie.Close(ctx)
+	}
+	b.cancel()
+	b.container.Close()
+}
+
+// blankPool keeps containers created but not started for the fns that start containers cold, for the next cold start
+// of a fn to only start a container rather than create one too. Containers are kept by slot queue, as those of a slot
+// queue are created alike, and removed once not started within the idle timeout of their fn. Unlike the containers of
+// the docker prefork pool, which only hold network namespaces, they hold no memory until started. It is nil unless
+// EnvBlankContainers is set.
+type blankPool struct {
+	size int
+
+	lock sync.Mutex
+	// blanks are the containers created ahead, by slot key
+	blanks map[string][]*blankContainer
+	// filling are the slot keys containers are being created for
+	filling map[string]bool
+}
+
+func newBlankPool(cfg *Config) *blankPool {
+	if cfg.BlankContainers == 0 {
+		return nil
+	}
+	logrus.WithField("containers", cfg.BlankContainers).Info("Blank containers are enabled, this is experimental")
+	return &blankPool{
+		size:    int(cfg.BlankContainers),
+		blanks:  make(map[string][]*blankContainer),
+		filling: make(map[string]bool),
+	}
+}
+
+// claim returns a blank container for the slot queue of call to start, if any
+func (p *blankPool) claim(call *call) *blankContainer {
+	if p == nil {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	list := p.blanks[call.slotHashId]
+	if len(list) == 0 {
+		return nil
+	}
+	b := list[len(list)-1]
+	if len(list) == 1 {
+		delete(p.blanks, call.slotHashId)
+	} else {
+		p.blanks[call.slotHashId] = list[:len(list)-1]
+	}
+	return b
+}
+
+// count returns how many blank containers there are for the slot queue of key
+func (p *blankPool) count(key string) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.blanks[key])
+}
+
+// expire removes the blank containers that are not started within the idle timeout of their fn, until closer is
+// closed
+func (p *blankPool) expire(closer <-chan struct{}) {
+	if p == nil {
+		return
+	}
+	ticker := time.NewTicker(blankExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			p.remove(func(b *blankContainer) bool { return !now.Before(b.expires) })
+		case <-closer:
+			return
+		}
+	}
+}
+
+// drain removes all the blank containers, as the agent shuts down
+func (p *blankPool) drain() {
+	if p == nil {
+		return
+	}
+	p.remove(func(*blankContainer) bool { return true })
+}
+
+// remove removes the blank containers for which expired returns true
+func (p *blankPool) remove(expired func(*blankContainer) bool) {
+	var removed []*blankContainer
+	p.lock.Lock()
+	for key, list := range p.blanks {
+		kept := list[:0]
+		for _, b := range list {
+			if expired(b) {
+				removed = append(removed, b)
+			} else {
+				kept = append(kept, b)
+			}
+		}
+		if len(kept) == 0 {
+			delete(p.blanks, key)
+		} else {
+			p.blanks[key] = kept
+		}
+	}
+	p.lock.Unlock()
+
+	for _, b := range removed {
+		b.discard(context.Background())
+	}
+}
+
+// fillBlanks creates blank containers for the slot queue of call in the background, up to the size of the pool, as
+// a container of the slot queue started cold
+func (a *agent) fillBlanks(ctx context.Context, call *call) {
+	p := a.blanks
+	// containers given gpus are only created once their gpus are free
+	if p == nil || call.gpus > 0 {
+		return
+	}
+	p.lock.Lock()
+	if p.filling[call.slotHashId] || len(p.blanks[call.slotHashId]) >= p.size {
+		p.lock.Unlock()
+		return
+	}
+	p.filling[call.slotHashId] = true
+	p.lock.Unlock()
+
+	if !a.shutWg.AddSession(1) {
+		p.lock.Lock()
+		delete(p.filling, call.slotHashId)
+		p.lock.Unlock()
+		return
+	}
+
+	ctx = common.BackgroundContext(ctx)
+	go func() {
+		defer a.shutWg.DoneSession()
+		defer func() {
+			p.lock.Lock()
+			delete(p.filling, call.slotHashId)
+			p.lock.Unlock()
+		}()
+
+		for p.count(call.slotHashId) < p.size {
+			select {
+			case <-a.shutWg.Closer():
+				return
+			default:
+			}
+			b, err := a.createBlank(ctx, call)
+			if err != nil {
+				common.Logger(ctx).WithError(err).Error("Failed to create blank container")
+				return
+			}
+			p.lock.Lock()
+			p.blanks[call.slotHashId] = append(p.blanks[call.slotHashId], b)
+			p.lock.Unlock()
+		}
+	}()
+}
+
+// createBlank creates a blank container for the slot queue of call
+func (a *agent) createBlank(ctx context.Context, call *call) (*blankContainer, error) {
+	id := id.New().String()
+	logger := logrus.WithFields(logrus.Fields{"container_id": id, "app_id": call.AppID, "fn_id": call.FnID, "image": call.Image})
+	ctx, cancel := context.WithCancel(common.WithLogger(ctx, logger))
+
+	var authToken string
+	if call.slots != nil {
+		authToken = call.slots.getAuthToken()
+	}
+	udsWait := make(chan error, 1)
+	c := newHotContainer(ctx, a.evictor, nil, call, &a.cfg, id, authToken, nil, udsWait)
+	if c == nil {
+		cancel()
+		return nil, <-udsWait
+	}
+
+	b := &blankContainer{
+		container: c,
+		udsWait:   udsWait,
+		cancel:    cancel,
+		expires:   time.Now().Add(time.Duration(call.IdleTimeout) * time.Second),
+	}
+	var err error
+	b.cookie, err = a.createHotContainer(ctx, call, c, nil, nil)
+	if err != nil {
+		b.discard(ctx)
+		return nil, err
+	}
+	logger.Debug("Created blank container")
+	return b, nil
+}
@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+)
+
+func TestBlankContainers(t *testing.T) {
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+	cfg.BlankContainers = 1
+
+	script := &mock.Script{Images: map[string]*mock.ImageScript{
+		mock.ScriptDefault: {LatencyMsecs: 1000},
+	}}
+	a := New(WithConfig(cfg), WithDockerDriver(mock.NewScripted(script)))
+	pool := a.(*agent).blanks
+
+	run := func() chan error {
+		done := make(chan error, 1)
+		go func() {
+			cm := createModelCall("TestBlankContainers")
+			callI, err := a.GetCall(FromModelAndInput(cm, ioutil.NopCloser(strings.NewReader("hello"))), WithWriter(httptest.NewRecorder()))
+			if err == nil {
+				err = a.Submit(callI)
+			}
+			done <- err
+		}()
+		return done
+	}
+	blankIDs := func() []string {
+		pool.lock.Lock()
+		defer pool.lock.Unlock()
+		var ids []string
+		for _, list := range pool.blanks {
+			for _, b := range list {
+				ids = append(ids, b.container.id)
+			}
+		}
+		return ids
+	}
+	awaitBlank := func(not string) string {
+		for i := 0; i < 100; i++ {
+			if ids := blankIDs(); len(ids) == 1 && ids[0] != not {
+				return ids[0]
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("expected a blank container to be created, got %v", blankIDs())
+		return ""
+	}
+
+	// the first container starts cold, and a blank container is created for the fn as it does
+	first := run()
+	blank := awaitBlank("")
+
+	// the container being busy, the second call starts the blank container, which is created again
+	second := run()
+	awaitBlank(blank)
+	for _, done := range []chan error{first, second} {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkClose(t, a)
+	if ids := blankIDs(); len(ids) != 0 {
+		t.Fatalf("expected the blank containers to be removed, got %v", ids)
+	}
+}
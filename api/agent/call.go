@@ -49,7 +49,12 @@ type CallOpt func(c *call) error
 // FromHTTPFnRequest Sets up a call from an http trigger request
 func FromHTTPFnRequest(app *models.App, fn *models.Fn, req *http.Request) CallOpt {
 	return func(c *call) error {
-		id := id.New().String()
+		// disabled fns are not run, however they are invoked
+		if fn.IsDisabled() {
+			return models.ErrFnDisabled
+		}
+
+		id := id.NewString(id.KindCall)
 
 		var syslogURL string
 		if app.SyslogURL != nil {
@@ -63,9 +68,9 @@ func FromHTTPFnRequest(app *models.App, fn *models.Fn, req *http.Request) CallOp
 			Type:        models.TypeSync,
 			Timeout:     fn.Timeout,
 			IdleTimeout: fn.IdleTimeout,
-			TmpFsSize:   0, // TODO clean up this
-			Memory:      fn.Memory,
-			CPUs:        0, // TODO clean up this
+			TmpFsSize:   uint32(fn.TmpFsSize),
+			Memory:      uint64(fn.Memory),
+			CPUs:        fn.CPUs,
 			Config:      buildConfig(app, fn),
 			// TODO - this wasn't really the intention here (that annotations would naturally cascade
 			// but seems to be necessary for some runner behaviour
@@ -80,11 +85,18 @@ func FromHTTPFnRequest(app *models.App, fn *models.Fn, req *http.Request) CallOp
 			SyslogURL:   syslogURL,
 		}
 
+		c.chain = fn.Chain
 		c.req = req
+		c.deadline = time.Time(c.CreatedAt).Add(time.Duration(fn.Timeout) * time.Second)
 		return nil
 	}
 }
 
+// buildConfig assembles the container environment for a fn. Precedence, from
+// lowest to highest, is app config, fn config, trigger config (applied by
+// WithTrigger) and finally the FN_ variables set by fn itself, which users
+// cannot override. Values may be templates, these are resolved when the
+// container is created (see resolveConfigTemplates).
 func buildConfig(app *models.App, fn *models.Fn) models.Config {
 	conf := make(models.Config, 8+len(app.Config)+len(fn.Config))
 	for k, v := range app.Config {
@@ -96,10 +108,13 @@ func buildConfig(app *models.App, fn *models.Fn) models.Config {
 
 	// XXX(reed): add trigger id to request headers on call?
 
-	conf["FN_MEMORY"] = fmt.Sprintf("%d", fn.Memory)
-	conf["FN_TYPE"] = "sync"
-	conf["FN_FN_ID"] = fn.ID
-	conf["FN_APP_ID"] = app.ID
+	conf[FnEnvMemory] = fmt.Sprintf("%d", fn.Memory)
+	if fn.CPUs != 0 {
+		conf[FnEnvCPUs] = fn.CPUs.String()
+	}
+	conf[FnEnvType] = "sync"
+	conf[FnEnvFnID] = fn.ID
+	conf[FnEnvAppID] = app.ID
 
 	return conf
 }
@@ -152,12 +167,25 @@ func FromModelAndInput(mCall *models.Call, in io.ReadCloser) CallOpt {
 	}
 }
 
-// WithTrigger adds trigger specific bits to a call.
-// TODO consider removal, this is from a shuffle
+// WithTrigger adds trigger specific bits to a call. Any trigger config is
+// layered over the app and fn config, see buildConfig for precedence.
 func WithTrigger(t *models.Trigger) CallOpt {
 	return func(c *call) error {
-		// right now just set the trigger id
 		c.TriggerID = t.ID
+
+		conf, err := t.Config()
+		if err != nil {
+			return err
+		}
+		if len(conf) > 0 && c.Call.Config == nil {
+			c.Call.Config = make(models.Config, len(conf))
+		}
+		for k, v := range conf {
+			if isReservedConfigKey(k) {
+				continue
+			}
+			c.Call.Config[k] = v
+		}
 		return nil
 	}
 }
@@ -187,6 +215,15 @@ func InvokeDetached() CallOpt {
 	}
 }
 
+// WithCallID sets the id of the call, for calls recorded before they are made, it must follow the option setting up
+// the call
+func WithCallID(id string) CallOpt {
+	return func(c *call) error {
+		c.ID = id
+		return nil
+	}
+}
+
 // WithContext overrides the context on the call
 func WithContext(ctx context.Context) CallOpt {
 	return func(c *call) error {
@@ -247,12 +284,20 @@ func (a *agent) GetCall(opts ...CallOpt) (Call, error) {
 	if !a.resources.IsResourcePossible(mem, c.CPUs) {
 		return nil, models.ErrCallResourceTooBig
 	}
+	gpus, err := callGPUs(c.Call)
+	if err != nil {
+		return nil, err
+	}
+	if !a.gpus.isPossible(gpus) {
+		return nil, models.ErrCallResourceTooBig
+	}
+	c.gpus = gpus
 
 	if c.Call.Config == nil {
 		c.Call.Config = make(models.Config)
 	}
-	c.Call.Config["FN_LISTENER"] = "unix:" + filepath.Join(iofsDockerMountDest, udsFilename)
-	c.Call.Config["FN_FORMAT"] = "http-stream" // TODO: remove this after fdk's forget what it means
+	c.Call.Config[FnEnvListener] = "unix:" + filepath.Join(iofsDockerMountDest, udsFilename)
+	c.Call.Config[FnEnvFormat] = "http-stream" // TODO: remove this after fdk's forget what it means
 	// TODO we could set type here too, for now, or anything else not based in fn/app/trigger config
 
 	setupCtx(&c)
@@ -261,13 +306,20 @@ func (a *agent) GetCall(opts ...CallOpt) (Call, error) {
 	if c.stderr == nil {
 		// TODO(reed): is line writer is vulnerable to attack?
 		// XXX(reed): forcing this as default is not great / configuring it isn't great either. reconsider.
-		c.stderr = setupLogger(c.req.Context(), a.cfg.MaxLogSize, !a.cfg.DisableDebugUserLogs, c.Call)
+		c.stderr = setupLogger(c.req.Context(), a.cfg.MaxLogSize, !a.cfg.DisableDebugUserLogs, c.Call, a.callLogStore, a.cfg.CallLogFlushInterval)
+		c.logStream = a.callLogs.wrap(c.FnID, c.ID, c.stderr)
+		c.stderr = c.logStream
+	}
+	if err := setupLongRunning(&c); err != nil {
+		return nil, err
 	}
 	if c.respWriter == nil {
 		// send function output to logs if no writer given (TODO no longer need w/o async?)
 		// TODO we could/should probably make this explicit to GetCall, ala 'WithLogger', but it's dupe code (who cares?)
 		c.respWriter = c.stderr
 	}
+	a.chainer.setup(&c)
+	a.mirrorer.setup(&c)
 
 	return &c, nil
 }
@@ -284,12 +336,14 @@ type call struct {
 	respWriter   io.Writer
 	req          *http.Request
 	stderr       io.ReadWriteCloser
+	logStream    *callLogStream
 	ct           callTrigger
 	slots        *slotQueue
 	requestState RequestState
 	slotHashId   string
 	disableNet   bool
-	dockerAuth   docker.Auther // pull config function
+	dockerAuth   docker.Auther  // pull config function
+	secrets      SecretResolver // config template secret lookups
 
 	// amount of time attributed to user-code execution
 	userExecTime *time.Duration
@@ -308,6 +362,50 @@ type call struct {
 
 	// LB & Pure Runner Extra Config
 	extensions map[string]string
+
+	// fns to invoke with the result of the call, if any
+	chain *models.FnChain
+	// gpus is how many GPUs each container of the call is given
+	gpus int
+
+	// mirroring policy and payload of the call, if it was sampled for mirroring
+	mirror        *models.FnMirror
+	mirrorPayload []byte
+
+	// tracks the log writes of long running calls, which fail once they go without any for livenessInterval
+	liveness         *livenessWriter
+	livenessInterval time.Duration
+
+	// the result cache key of a detached call whose fn opted in, see models.FnResultCacheAnnotation
+	resultKey string
+	resultTTL time.Duration
+	// set on calls skipped as an identical call succeeded within the ttl
+	cacheHit bool
+
+	// set on calls whose response gets the timing headers, see WithTimingHeaders
+	timingHeaders bool
+
+	// when a sync call must be done by, computed where the call enters fn, see withDeadline
+	deadline time.Time
+}
+
+// withDeadline bounds ctx by the deadline of a sync call, if it has one, so that placing it, waiting for a slot,
+// launching a container and executing it all spend the one timeout of its fn rather than each getting its own.
+// The deadline travels to runners as the deadline of the gRPC stream of the call, and to the fn as Fn-Deadline.
+func (c *call) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.deadline.IsZero() || c.Type == models.TypeDetached {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, c.deadline)
+}
+
+// deadlineWithin returns the earlier of timeout from now and the deadline of a sync call
+func (c *call) deadlineWithin(timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if !c.deadline.IsZero() && c.Type != models.TypeDetached && c.deadline.Before(deadline) {
+		return c.deadline
+	}
+	return deadline
 }
 
 // SlotHashId returns a string identity for this call that can be used to uniquely place the call in a given container
@@ -366,6 +464,9 @@ func (c *call) Start(ctx context.Context) error {
 
 	c.StartedAt = common.DateTime(time.Now())
 	c.Status = "running"
+	if c.logStream != nil {
+		c.logStream.start()
+	}
 
 	return c.ct.fireBeforeCall(ctx, c.Model())
 }
@@ -376,10 +477,12 @@ func (c *call) End(ctx context.Context, errIn error) error {
 
 	c.CompletedAt = common.DateTime(time.Now())
 
-	switch errIn {
-	case nil:
+	switch {
+	case errIn == nil && c.cacheHit:
+		c.Status = "cached"
+	case errIn == nil:
 		c.Status = "success"
-	case context.DeadlineExceeded:
+	case errIn == context.DeadlineExceeded:
 		c.Status = "timeout"
 	default:
 		c.Status = "error"
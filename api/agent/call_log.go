@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+const (
+	// EnvLogFraming is set in the environment of hot containers to tell FDKs that lines of output may be framed with
+	// the id of the call they belong to. A framed line starts with LogFrameDelimiter, the call id and
+	// LogFrameDelimiter again, eg. "\x1e01D8...\x1e hello world\n".
+	EnvLogFraming = "FN_LOG_FRAMING"
+	// LogFrameDelimiter delimits the call id at the start of a framed line of output
+	LogFrameDelimiter = '\x1e'
+
+	// maxCallLogLine is the longest line of container output buffered, longer lines are split
+	maxCallLogLine = 64 * 1024
+)
+
+// callLogMux splits the output of a hot container into lines, and writes each line to the log of the call it
+// belongs to. The output of a container is streamed asynchronously, so the tail of the output of a call may arrive
+// after it has ended, or even while the next call runs. Lines framed with a call id only go to the log of that call
+// while it runs, or else to the container's log. Unframed lines go to the log of the call running when they arrive.
+type callLogMux struct {
+	lock      sync.Mutex
+	line      []byte
+	container io.WriteCloser
+	call      io.Writer
+	callID    string
+	closed    bool
+}
+
+func newCallLogMux(container io.WriteCloser) *callLogMux {
+	return &callLogMux{container: container}
+}
+
+// swap starts writing the output of the call id to w, or stops writing to the log of the current call if w is nil
+func (m *callLogMux) swap(id string, w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.callID, m.call = id, w
+}
+
+func (m *callLogMux) Write(p []byte) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.closed {
+		return 0, io.EOF
+	}
+
+	m.line = append(m.line, p...)
+	for {
+		i := bytes.IndexByte(m.line, '\n')
+		if i < 0 {
+			if len(m.line) < maxCallLogLine {
+				break
+			}
+			i = maxCallLogLine - 1
+		}
+		m.writeLine(m.line[:i+1])
+		m.line = m.line[i+1:]
+	}
+	// reuse the buffer rather than letting it creep forward
+	m.line = append(m.line[:0], m.line...)
+	return len(p), nil
+}
+
+// writeLine writes a line to the log it belongs to, m.lock must be held
+func (m *callLogMux) writeLine(line []byte) {
+	w := m.call
+	if id, rest, ok := parseLogFrame(line); ok {
+		line = rest
+		if id != m.callID {
+			w = nil
+		}
+	}
+	if w == nil {
+		w = m.container
+	}
+	// call logs are limited and may be closed, errors must not reach docker or it shuts the container down
+	w.Write(line)
+}
+
+// parseLogFrame returns the call id a line is framed with, and the rest of the line
+func parseLogFrame(line []byte) (string, []byte, bool) {
+	if len(line) == 0 || line[0] != LogFrameDelimiter {
+		return "", line, false
+	}
+	end := bytes.IndexByte(line[1:], LogFrameDelimiter)
+	if end < 0 {
+		return "", line, false
+	}
+	return string(line[1 : end+1]), line[end+2:], true
+}
+
+// Close writes what is left of the last line to the container's log, and closes it
+func (m *callLogMux) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	if len(m.line) > 0 {
+		m.writeLine(m.line)
+		m.line = nil
+	}
+	return m.container.Close()
+}
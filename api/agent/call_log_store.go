@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// callLogPartSize is how much output of a call is buffered before it is appended to the log store, whatever the
+	// flush interval
+	callLogPartSize = 64 * 1024
+	// callLogFlushTimeout is how long the last part of the log of a call has to be appended once the call ends
+	callLogFlushTimeout = 10 * time.Second
+)
+
+// CallLogStore is where the logs of calls are streamed to while they run, such as models.Datastore
+type CallLogStore interface {
+	// AppendCallLog records part seq of the log of the call, replacing any part already recorded under seq
+	AppendCallLog(ctx context.Context, fnID, callID string, seq int, data []byte) error
+}
+
+// WithCallLogStore streams the logs of the calls of the agent to store while they run, a part every
+// FN_CALL_LOG_FLUSH_INTERVAL_MSECS, so that the logs of long running calls are not lost with the agent
+func WithCallLogStore(store CallLogStore) Option {
+	return func(a *agent) error {
+		a.callLogStore = store
+		return nil
+	}
+}
+
+// callLogAppender appends the output of a call to its log store in parts, as the call runs. A part that fails to
+// be appended is appended again with the output that follows it, under the same seq.
+type callLogAppender struct {
+	ctx          context.Context
+	store        CallLogStore
+	fnID, callID string
+	interval     time.Duration
+
+	lock sync.Mutex
+	buf  bytes.Buffer
+
+	once    sync.Once
+	full    chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+
+	// owned by the flushing goroutine, then by Close
+	seq     int
+	pending []byte
+}
+
+func newCallLogAppender(ctx context.Context, store CallLogStore, fnID, callID string, interval time.Duration) *callLogAppender {
+	return &callLogAppender{
+		ctx:      common.BackgroundContext(ctx),
+		store:    store,
+		fnID:     fnID,
+		callID:   callID,
+		interval: interval,
+		full:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+func (l *callLogAppender) Write(p []byte) (int, error) {
+	// parts are only flushed once the call writes output, calls that never run leave nothing behind
+	l.once.Do(func() { go l.run() })
+
+	l.lock.Lock()
+	l.buf.Write(p)
+	full := l.buf.Len() >= callLogPartSize
+	l.lock.Unlock()
+
+	if full {
+		select {
+		case l.full <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+func (l *callLogAppender) run() {
+	defer close(l.stopped)
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		case <-l.full:
+		}
+		l.flush(l.ctx)
+	}
+}
+
+func (l *callLogAppender) flush(ctx context.Context) {
+	l.lock.Lock()
+	l.pending = append(l.pending, l.buf.Bytes()...)
+	l.buf.Reset()
+	l.lock.Unlock()
+	if len(l.pending) == 0 {
+		return
+	}
+
+	err := l.store.AppendCallLog(ctx, l.fnID, l.callID, l.seq, l.pending)
+	if err != nil {
+		common.Logger(ctx).WithError(err).WithFields(logrus.Fields{"fn_id": l.fnID, "call_id": l.callID}).Warn("failed to append call log")
+		return
+	}
+	l.seq++
+	l.pending = nil
+}
+
+// Close appends what is left of the log
+func (l *callLogAppender) Close() error {
+	started := true
+	l.once.Do(func() { started = false })
+	if started {
+		close(l.stop)
+		<-l.stopped
+	}
+
+	ctx, cancel := context.WithTimeout(l.ctx, callLogFlushTimeout)
+	defer cancel()
+	l.flush(ctx)
+	return nil
+}
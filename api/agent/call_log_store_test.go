@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+type memCallLogStore struct {
+	lock  sync.Mutex
+	parts map[int]string
+	fail  bool
+}
+
+func (s *memCallLogStore) AppendCallLog(ctx context.Context, fnID, callID string, seq int, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.fail {
+		return errors.New("unavailable")
+	}
+	if s.parts == nil {
+		s.parts = make(map[int]string)
+	}
+	s.parts[seq] = string(data)
+	return nil
+}
+
+func (s *memCallLogStore) log() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var log string
+	for seq := 0; seq < len(s.parts); seq++ {
+		log += s.parts[seq]
+	}
+	return log
+}
+
+func TestCallLogAppender(t *testing.T) {
+	store := &memCallLogStore{}
+	l := newCallLogAppender(context.Background(), store, "fn_id", "call_id", 10*time.Millisecond)
+
+	l.Write([]byte("first\n"))
+	deadline := time.Now().Add(5 * time.Second)
+	for store.log() != "first\n" {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the output to be appended while the call runs, got %q", store.log())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// output that failed to be appended is appended again with what follows it
+	store.lock.Lock()
+	store.fail = true
+	store.lock.Unlock()
+	l.Write([]byte("second\n"))
+	time.Sleep(50 * time.Millisecond)
+	store.lock.Lock()
+	store.fail = false
+	store.lock.Unlock()
+	l.Write([]byte("third\n"))
+	l.Close()
+
+	if log := store.log(); log != "first\nsecond\nthird\n" {
+		t.Errorf("expected the whole output appended once closed, got %q", log)
+	}
+}
+
+func TestCallLogAppenderFullPart(t *testing.T) {
+	store := &memCallLogStore{}
+	l := newCallLogAppender(context.Background(), store, "fn_id", "call_id", time.Hour)
+	defer l.Close()
+
+	part := strings.Repeat("x", callLogPartSize)
+	l.Write([]byte(part))
+	deadline := time.Now().Add(5 * time.Second)
+	for store.log() != part {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a full part to be appended before the flush interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSetupLoggerCallLogStore(t *testing.T) {
+	store := &memCallLogStore{}
+	call := &models.Call{ID: "call_id", FnID: "fn_id"}
+	logger := setupLogger(context.Background(), 10, false, call, store, time.Hour)
+
+	logger.Write([]byte("0123456789abcdef"))
+	logger.Close()
+	if log := store.log(); !strings.HasPrefix(log, "0123456789\n") {
+		t.Errorf("expected the appended log to be limited to the max log size, got %q", log)
+	}
+
+	// calls that write nothing leave nothing behind
+	store = &memCallLogStore{}
+	setupLogger(context.Background(), 10, false, call, store, time.Hour).Close()
+	if len(store.parts) != 0 {
+		t.Errorf("expected nothing to be appended, got %v", store.parts)
+	}
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"io"
+	"sync"
+)
+
+// callLogStreamBuffer is how many writes of output a subscriber may fall behind by, after which its output is
+// dropped rather than holding up the container
+const callLogStreamBuffer = 256
+
+// CallLogStreamer is implemented by agents that stream the output of the calls they run, while they run.
+type CallLogStreamer interface {
+	// StreamCallLog returns the output the call of the fn writes from now on, a write at a time, and a func to stop
+	// streaming it. The channel is closed once the call ends, or it is stopped. ok is false if the call is not
+	// running on the agent.
+	StreamCallLog(fnID, callID string) (output <-chan []byte, stop func(), ok bool)
+}
+
+var _ CallLogStreamer = new(agent)
+
+// StreamCallLog implements CallLogStreamer
+func (a *agent) StreamCallLog(fnID, callID string) (<-chan []byte, func(), bool) {
+	return a.callLogs.subscribe(fnID, callID)
+}
+
+// callLogStreams are the logs of the calls running on an agent, by call id
+type callLogStreams struct {
+	lock  sync.Mutex
+	calls map[string]*callLogStream
+}
+
+// wrap returns the log of call, teeing its output to the subscribers of the call once it starts
+func (s *callLogStreams) wrap(fnID, callID string, log io.ReadWriteCloser) *callLogStream {
+	return &callLogStream{ReadWriteCloser: log, streams: s, fnID: fnID, callID: callID, subs: make(map[chan []byte]struct{})}
+}
+
+func (s *callLogStreams) subscribe(fnID, callID string) (<-chan []byte, func(), bool) {
+	s.lock.Lock()
+	l := s.calls[callID]
+	s.lock.Unlock()
+	if l == nil || l.fnID != fnID {
+		return nil, nil, false
+	}
+	return l.subscribe()
+}
+
+// callLogStream is the log of a call, whose output is also sent to its subscribers while the call runs
+type callLogStream struct {
+	io.ReadWriteCloser
+	streams      *callLogStreams
+	fnID, callID string
+
+	lock   sync.Mutex
+	subs   map[chan []byte]struct{}
+	closed bool
+}
+
+// start lets the log of the call be subscribed to
+func (l *callLogStream) start() {
+	l.streams.lock.Lock()
+	defer l.streams.lock.Unlock()
+	if l.streams.calls == nil {
+		l.streams.calls = make(map[string]*callLogStream)
+	}
+	l.streams.calls[l.callID] = l
+}
+
+func (l *callLogStream) subscribe() (<-chan []byte, func(), bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return nil, nil, false
+	}
+	ch := make(chan []byte, callLogStreamBuffer)
+	l.subs[ch] = struct{}{}
+	stop := func() {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		if _, ok := l.subs[ch]; ok {
+			delete(l.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, stop, true
+}
+
+func (l *callLogStream) Write(p []byte) (int, error) {
+	n, err := l.ReadWriteCloser.Write(p)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.subs) > 0 {
+		b := append([]byte(nil), p...)
+		for ch := range l.subs {
+			select {
+			case ch <- b:
+			default:
+			}
+		}
+	}
+	return n, err
+}
+
+// Close ends the streams of the subscribers, and closes the log
+func (l *callLogStream) Close() error {
+	l.streams.lock.Lock()
+	if l.streams.calls[l.callID] == l {
+		delete(l.streams.calls, l.callID)
+	}
+	l.streams.lock.Unlock()
+
+	l.lock.Lock()
+	l.closed = true
+	for ch := range l.subs {
+		delete(l.subs, ch)
+		close(ch)
+	}
+	l.lock.Unlock()
+	return l.ReadWriteCloser.Close()
+}
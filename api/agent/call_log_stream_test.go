@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"testing"
+)
+
+func TestCallLogStream(t *testing.T) {
+	a := &agent{}
+	var log bufferCloser
+	l := a.callLogs.wrap("fn_id", "call_id", &log)
+
+	if _, _, ok := a.StreamCallLog("fn_id", "call_id"); ok {
+		t.Fatal("expected the log of a call not started not to be streamed")
+	}
+	l.Write([]byte("before start\n"))
+	l.start()
+	if _, _, ok := a.StreamCallLog("other_fn", "call_id"); ok {
+		t.Fatal("expected the log of a call not to be streamed for another fn")
+	}
+	output, stop, ok := a.StreamCallLog("fn_id", "call_id")
+	if !ok {
+		t.Fatal("expected the log of a running call to be streamed")
+	}
+	other, stopOther, _ := a.StreamCallLog("fn_id", "call_id")
+	stopOther()
+	stopOther()
+
+	l.Write([]byte("first\n"))
+	l.Write([]byte("second\n"))
+	l.Close()
+	var streamed string
+	for b := range output {
+		streamed += string(b)
+	}
+	if streamed != "first\nsecond\n" {
+		t.Errorf("expected the output written while subscribed, got %q", streamed)
+	}
+	if _, ok := <-other; ok {
+		t.Error("expected a stopped stream to be closed")
+	}
+	stop()
+
+	if log.String() != "before start\nfirst\nsecond\n" || !log.closed {
+		t.Errorf("expected all output to reach the log of the call, got %q", log.String())
+	}
+	if _, _, ok := a.StreamCallLog("fn_id", "call_id"); ok {
+		t.Error("expected the log of an ended call not to be streamed")
+	}
+}
@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestCallLogMux(t *testing.T) {
+	var container bufferCloser
+	var call1, call2 bytes.Buffer
+	mux := newCallLogMux(&container)
+
+	frame := func(id, line string) string {
+		return string(LogFrameDelimiter) + id + string(LogFrameDelimiter) + line
+	}
+
+	mux.Write([]byte("starting up\n"))
+	mux.swap("call1", &call1)
+	mux.Write([]byte("unframed "))
+	mux.Write([]byte("line\n" + frame("call1", "framed line\n")))
+	mux.swap("", nil)
+
+	mux.swap("call2", &call2)
+	// the tail of the first call arrives late
+	mux.Write([]byte(frame("call1", "late line\n") + frame("call2", "second call\n") + "partial"))
+	mux.swap("", nil)
+	mux.Write([]byte(" line\n" + strings.Repeat("x", maxCallLogLine) + "\ntrailing"))
+
+	if call1.String() != "unframed line\nframed line\n" {
+		t.Errorf("unexpected first call log %q", call1.String())
+	}
+	if call2.String() != "second call\n" {
+		t.Errorf("unexpected second call log %q", call2.String())
+	}
+
+	mux.Close()
+	expected := "starting up\nlate line\npartial line\n" + strings.Repeat("x", maxCallLogLine) + "\ntrailing"
+	if container.String() != expected || !container.closed {
+		t.Errorf("unexpected container log %q", container.String())
+	}
+	if _, err := mux.Write([]byte("after close\n")); err == nil {
+		t.Error("expected writes after close to fail")
+	}
+}
@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// headers added to the responses of calls if EnvTimingHeaders is set, or the call asks for them with WithTimingHeaders
+const (
+	// ColdStartHeader tells whether a call was the first to run in its container
+	ColdStartHeader = "Fn-Cold-Start"
+	// QueueTimeHeader is how long a call waited for a container, in milliseconds
+	QueueTimeHeader = "Fn-Queue-Ms"
+	// ExecTimeHeader is how long the fn took to respond to a call, in milliseconds
+	ExecTimeHeader = "Fn-Exec-Ms"
+)
+
+// setTimingHeaders adds the timing headers of a call that has just been responded to by its container
+func setTimingHeaders(h http.Header, c *call, cold bool) {
+	created, started := time.Time(c.CreatedAt), time.Time(c.StartedAt)
+	h.Set(ColdStartHeader, strconv.FormatBool(cold))
+	if !created.IsZero() && !started.Before(created) {
+		h.Set(QueueTimeHeader, strconv.FormatInt(int64(started.Sub(created)/time.Millisecond), 10))
+	}
+	if !started.IsZero() {
+		h.Set(ExecTimeHeader, strconv.FormatInt(int64(time.Since(started)/time.Millisecond), 10))
+	}
+}
+
+// WithTimingHeaders adds the timing headers to the response of the call, whether or not EnvTimingHeaders is set
+func WithTimingHeaders() CallOpt {
+	return func(c *call) error {
+		c.timingHeaders = true
+		return nil
+	}
+}
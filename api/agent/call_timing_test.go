@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestSetTimingHeaders(t *testing.T) {
+	now := time.Now()
+	c := &call{Call: &models.Call{
+		CreatedAt: common.DateTime(now.Add(-300 * time.Millisecond)),
+		StartedAt: common.DateTime(now.Add(-100 * time.Millisecond)),
+	}}
+
+	h := make(http.Header)
+	setTimingHeaders(h, c, true)
+	if h.Get(ColdStartHeader) != "true" {
+		t.Errorf("expected a cold start, got %q", h.Get(ColdStartHeader))
+	}
+	if h.Get(QueueTimeHeader) != "200" {
+		t.Errorf("expected 200ms queued, got %q", h.Get(QueueTimeHeader))
+	}
+	if exec, err := strconv.Atoi(h.Get(ExecTimeHeader)); err != nil || exec < 100 {
+		t.Errorf("expected at least 100ms of execution, got %q", h.Get(ExecTimeHeader))
+	}
+
+	h = make(http.Header)
+	setTimingHeaders(h, &call{Call: &models.Call{}}, false)
+	if h.Get(ColdStartHeader) != "false" || h.Get(QueueTimeHeader) != "" || h.Get(ExecTimeHeader) != "" {
+		t.Errorf("expected only the cold start header without timestamps, got %v", h)
+	}
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+)
+
+// Capabilities describes what an agent runs fns with, and the optional features it was configured with
+type Capabilities struct {
+	// Driver is the type of the container driver, eg. *docker.DockerDriver
+	Driver string `json:"driver"`
+	// ContractVersions are the fdk contract versions the agent speaks, oldest first
+	ContractVersions []int `json:"contract_versions"`
+	// PlatformEnvVersion is the version of the env vars the agent sets on containers
+	PlatformEnvVersion uint64 `json:"platform_env_version"`
+	// Features are the optional features enabled, eg. volumes or result_cache
+	Features []string `json:"features"`
+}
+
+// CapabilityReporter is implemented by agents reporting their capabilities
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+var _ CapabilityReporter = new(agent)
+var _ CapabilityReporter = new(pureRunner)
+
+// Capabilities implements CapabilityReporter
+func (a *agent) Capabilities() Capabilities {
+	caps := Capabilities{
+		Driver:             fmt.Sprintf("%T", a.driver),
+		ContractVersions:   drivers.ContractVersions,
+		PlatformEnvVersion: a.cfg.PlatformEnvVersion,
+		Features:           []string{},
+	}
+
+	for _, f := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"chaining", a.chainer != nil},
+		{"mirroring", a.mirrorer != nil},
+		{"volumes", a.volumes != nil},
+		{"datasets", a.datasets != nil},
+		{"registry_cas", a.registryCAs != nil},
+		{"image_load", isImageLoader(a.driver)},
+		{"image_cache", isImageCache(a.driver)},
+		{"offline", a.cfg.DisableImagePulls},
+		{"signed_images", a.cfg.ImageTrustPolicy != "" || a.cfg.RequireSignedImages},
+		{"result_cache", a.results != nil},
+		{"fs_stats", a.cfg.FsStatsInterval > 0},
+		{"enforce_fs_size", a.cfg.EnforceFsSize},
+		{"eager_unfreeze", a.cfg.EagerUnfreeze},
+		{"syslog", a.cfg.SyslogURL != ""},
+		{"iofs_tmpfs", a.cfg.IOFSEnableTmpfs},
+		{"fault_injection", a.faults != nil},
+		{"warm_recovery", a.recovery != nil && a.recovery.driver != nil},
+		{"blank_containers", a.blanks != nil},
+		{"scratch", a.scratch != nil},
+		{"gpus", a.gpus != nil},
+		{"seccomp_profiles", a.cfg.SeccompProfilesDir != ""},
+		{"apparmor_profiles", a.cfg.AppArmorProfiles != ""},
+	} {
+		if f.enabled {
+			caps.Features = append(caps.Features, f.name)
+		}
+	}
+	return caps
+}
+
+func isImageLoader(d drivers.Driver) bool {
+	_, ok := d.(drivers.ImageLoader)
+	return ok
+}
+
+func isImageCache(d drivers.Driver) bool {
+	_, ok := d.(drivers.ImageCache)
+	return ok
+}
+
+// Capabilities implements CapabilityReporter, adding the features of the runner itself
+func (pr *pureRunner) Capabilities() Capabilities {
+	var caps Capabilities
+	if r, ok := pr.a.(CapabilityReporter); ok {
+		caps = r.Capabilities()
+	}
+	if pr.enableDetach {
+		caps.Features = append(caps.Features, "detached")
+	}
+	if pr.tokens.Enabled() {
+		caps.Features = append(caps.Features, "runner_tokens")
+	}
+	if pr.payloadKeys != nil {
+		caps.Features = append(caps.Features, "payload_encryption")
+	}
+	return caps
+}
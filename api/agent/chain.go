@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/sirupsen/logrus"
+)
+
+// FnChainHeader is set on chained calls to the comma separated IDs of the fns
+// that led to them, oldest first. It is used to detect loops and to limit the
+// depth of chains.
+const FnChainHeader = "Fn-Chain"
+
+// fnChainer invokes the fns that calls are chained to (see models.FnChain)
+// once they end, looking them up in da.
+type fnChainer struct {
+	da ReadDataAccess
+}
+
+// WithFnChaining enables fn chaining on the agent, chained fns are looked up in da
+func WithFnChaining(da ReadDataAccess) Option {
+	return func(a *agent) error {
+		a.chainer = &fnChainer{da: da}
+		return nil
+	}
+}
+
+// WithLBFnChaining enables fn chaining on the lb agent, chained fns are looked up in da
+func WithLBFnChaining(da ReadDataAccess) LBAgentOption {
+	return func(a *lbAgent) error {
+		a.chainer = &fnChainer{da: da}
+		return nil
+	}
+}
+
+// chainResponseWriter keeps a copy of a call's output, to pass on to the next fn
+type chainResponseWriter struct {
+	http.ResponseWriter
+	out bytes.Buffer
+}
+
+func (w *chainResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.out.Write(b[:n])
+	return n, err
+}
+
+// chainWriter is chainResponseWriter for writers that are not http.ResponseWriters
+type chainWriter struct {
+	io.Writer
+	out bytes.Buffer
+}
+
+func (w *chainWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	w.out.Write(b[:n])
+	return n, err
+}
+
+// setup captures the output of call, if its fn has a chain. Detached calls
+// do not return their output, so there is nothing to pass on.
+func (c *fnChainer) setup(call *call) {
+	if c == nil || call.chain.IsEmpty() || call.Type == models.TypeDetached {
+		return
+	}
+	if rw, ok := call.respWriter.(http.ResponseWriter); ok {
+		call.respWriter = &chainResponseWriter{ResponseWriter: rw}
+	} else {
+		call.respWriter = &chainWriter{Writer: call.respWriter}
+	}
+}
+
+// next invokes the fn that call is chained to given the error it ended with,
+// if any. The next call runs in the background on a, and is dropped if it
+// would loop or exceed maxDepth fns, or if call is a mirrored one, whose
+// output is discarded. Only errors that are the fn's fault count
+// as failures, platform errors are returned to the caller to retry instead.
+func (c *fnChainer) next(ctx context.Context, a Agent, maxDepth uint64, call *call, err error) {
+	if c == nil || call.chain.IsEmpty() || isMirrored(call) {
+		return
+	}
+
+	var nextFnID string
+	var body []byte
+	contentType := ""
+	switch {
+	case err == nil:
+		nextFnID = call.chain.OnSuccess
+		switch w := call.respWriter.(type) {
+		case *chainResponseWriter:
+			body, contentType = w.out.Bytes(), w.Header().Get("Content-Type")
+		case *chainWriter:
+			body = w.out.Bytes()
+		}
+	case models.IsFuncError(err):
+		nextFnID = call.chain.OnFailure
+		body, _ = json.Marshal(models.Error{Message: err.Error()})
+		contentType = "application/json"
+	}
+	if nextFnID == "" {
+		return
+	}
+
+	log := common.Logger(ctx).WithFields(logrus.Fields{"chain_fn_id": nextFnID})
+
+	var path []string
+	if h := call.req.Header.Get(FnChainHeader); h != "" {
+		path = strings.Split(h, ",")
+	}
+	path = append(path, call.FnID)
+	for _, id := range path {
+		if id == nextFnID {
+			log.WithField("chain", path).Error("dropping chained call, it would loop")
+			return
+		}
+	}
+	if uint64(len(path)) >= maxDepth {
+		log.WithField("chain", path).Error("dropping chained call, the chain is too deep")
+		return
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/invoke/"+nextFnID, bytes.NewReader(body))
+	req.Host = call.req.Host
+	req.Header.Set(FnChainHeader, strings.Join(path, ","))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	go func() {
+		ctx := common.BackgroundContext(ctx)
+		err := c.submit(ctx, a, req, call.AppID, nextFnID)
+		if err != nil {
+			log.WithError(err).Error("chained call failed")
+		}
+	}()
+}
+
+func (c *fnChainer) submit(ctx context.Context, a Agent, req *http.Request, appID, fnID string) error {
+	fn, err := c.da.GetFnByID(ctx, fnID)
+	if err != nil {
+		return err
+	}
+	// fns only chain to the fns of their own app
+	if fn.AppID != appID {
+		return models.ErrFnChainTargetNotFound
+	}
+	app, err := c.da.GetAppByID(ctx, fn.AppID)
+	if err != nil {
+		return err
+	}
+
+	// give the call as long to find a slot as it has to run
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Duration(fn.Timeout)*time.Second)
+	defer cancel()
+
+	callI, err := a.GetCall(FromHTTPFnRequest(app, fn, req.WithContext(ctx)),
+		WithWriter(&discardResponseWriter{headers: make(http.Header)}))
+	if err != nil {
+		return err
+	}
+	return a.Submit(callI)
+}
@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+)
+
+// chainRecorder is an Agent that records the calls submitted to it
+type chainRecorder struct {
+	calls chan *call
+}
+
+func (r *chainRecorder) GetCall(opts ...CallOpt) (Call, error) {
+	var c call
+	for _, o := range opts {
+		if err := o(&c); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+func (r *chainRecorder) Submit(c Call) error {
+	r.calls <- c.(*call)
+	return nil
+}
+
+func (r *chainRecorder) Close() error                       { return nil }
+func (r *chainRecorder) AddCallListener(fnext.CallListener) {}
+
+func TestFnChainNext(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "app"}
+	first := &models.Fn{ID: "first", AppID: app.ID, Chain: &models.FnChain{OnSuccess: "ok", OnFailure: "failed"}}
+	ok := &models.Fn{ID: "ok", AppID: app.ID, Chain: &models.FnChain{OnSuccess: "first"}}
+	failed := &models.Fn{ID: "failed", AppID: app.ID}
+	disabled := true
+	off := &models.Fn{ID: "off", AppID: app.ID, Disabled: &disabled}
+	toOff := &models.Fn{ID: "to_off", AppID: app.ID, Chain: &models.FnChain{OnSuccess: "off"}}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{first, ok, failed, off, toOff})
+
+	chainer := &fnChainer{da: ds}
+
+	newCall := func(fn *models.Fn, chain string) *call {
+		req := httptest.NewRequest(http.MethodPost, "/invoke/"+fn.ID, nil)
+		if chain != "" {
+			req.Header.Set(FnChainHeader, chain)
+		}
+		c := &call{respWriter: httptest.NewRecorder()}
+		if err := FromHTTPFnRequest(app, fn, req)(c); err != nil {
+			t.Fatal(err)
+		}
+		chainer.setup(c)
+		return c
+	}
+
+	for i, test := range []struct {
+		fn          *models.Fn
+		chain       string
+		output      string
+		err         error
+		expectedFn  string
+		expectedIn  string
+		expectedHdr string
+	}{
+		{first, "", "hello", nil, "ok", "hello", "first"},
+		{first, "", "", models.ErrFunctionFailed, "failed", `{"message":"` + models.ErrFunctionFailed.Error() + `"}`, "first"},
+		{ok, "a,b", "hello", nil, "first", "hello", "a,b,ok"},
+		// platform errors are not the fn's fault, the caller gets to retry those
+		{first, "", "", models.ErrCallTimeoutServerBusy, "", "", ""},
+		// ok would chain back to first
+		{ok, "first", "hello", nil, "", "", ""},
+		// and this would be one too many
+		{first, "a,b,c", "hello", nil, "", "", ""},
+		// nothing to chain to
+		{failed, "", "hello", nil, "", "", ""},
+		// disabled fns are not chained to
+		{toOff, "", "hello", nil, "", "", ""},
+	} {
+		c := newCall(test.fn, test.chain)
+		c.respWriter.Write([]byte(test.output))
+
+		rec := &chainRecorder{calls: make(chan *call, 1)}
+		chainer.next(context.Background(), rec, 4, c, test.err)
+
+		select {
+		case next := <-rec.calls:
+			if next.FnID != test.expectedFn {
+				t.Fatalf("Test %d: expected chained call to %q, got %q", i, test.expectedFn, next.FnID)
+			}
+			body, _ := ioutil.ReadAll(next.req.Body)
+			if !bytes.Equal(body, []byte(test.expectedIn)) {
+				t.Errorf("Test %d: expected chained call input %q, got %q", i, test.expectedIn, body)
+			}
+			if hdr := next.req.Header.Get(FnChainHeader); hdr != test.expectedHdr {
+				t.Errorf("Test %d: expected chain header %q, got %q", i, test.expectedHdr, hdr)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if test.expectedFn != "" {
+				t.Fatalf("Test %d: expected chained call to %q, got none", i, test.expectedFn)
+			}
+		}
+	}
+}
+
+func TestFnChainSetup(t *testing.T) {
+	chainer := &fnChainer{}
+	chain := &models.FnChain{OnSuccess: "next"}
+
+	// detached calls have no output to pass on
+	c := &call{Call: &models.Call{Type: models.TypeDetached}, chain: chain, respWriter: httptest.NewRecorder()}
+	chainer.setup(c)
+	if _, ok := c.respWriter.(*chainResponseWriter); ok {
+		t.Error("expected detached call output not to be captured")
+	}
+
+	// and the writer must still be an http.ResponseWriter for the agent to write headers
+	c = &call{Call: &models.Call{Type: models.TypeSync}, chain: chain, respWriter: httptest.NewRecorder()}
+	chainer.setup(c)
+	if _, ok := c.respWriter.(http.ResponseWriter); !ok {
+		t.Error("expected call writer to remain an http.ResponseWriter")
+	}
+
+	var nilChainer *fnChainer
+	c = &call{Call: &models.Call{Type: models.TypeSync}, chain: chain, respWriter: new(bytes.Buffer)}
+	nilChainer.setup(c)
+	nilChainer.next(context.Background(), nil, 1, c, errors.New("boom"))
+	if _, ok := c.respWriter.(*bytes.Buffer); !ok {
+		t.Error("expected call writer to be left alone without chaining")
+	}
+}
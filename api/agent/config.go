@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/fnproject/fn/api/config"
 )
 
 // Config specifies various settings for an agent
@@ -15,8 +18,13 @@ type Config struct {
 	ContainerLabelTag             string        `json:"container_label_tag"`
 	DockerNetworks                string        `json:"docker_networks"`
 	DockerLoadFile                string        `json:"docker_load_file"`
+	DockerDaemons                 string        `json:"docker_daemons"`
 	DisableUnprivilegedContainers bool          `json:"disable_unprivileged_containers"`
 	FreezeIdle                    time.Duration `json:"freeze_idle_msecs"`
+	FreezeMemoryPercent           uint64        `json:"freeze_memory_percent"`
+	EagerUnfreeze                 bool          `json:"eager_unfreeze"`
+	CgroupRoot                    string        `json:"cgroup_root"`
+	DevMode                       bool          `json:"dev_mode"`
 	HotPoll                       time.Duration `json:"hot_poll_msecs"`
 	HotLauncherTimeout            time.Duration `json:"hot_launcher_timeout_msecs"`
 	HotPullTimeout                time.Duration `json:"hot_pull_timeout_msecs"`
@@ -27,6 +35,16 @@ type Config struct {
 	MaxLogSize                    uint64        `json:"max_log_size_bytes"`
 	MaxTotalCPU                   uint64        `json:"max_total_cpu_mcpus"`
 	MaxTotalMemory                uint64        `json:"max_total_memory_bytes"`
+	ReservedCPU                   uint64        `json:"reserved_cpu_mcpus"`
+	ReservedMemory                uint64        `json:"reserved_memory_bytes"`
+	ReservedDisk                  uint64        `json:"reserved_disk_bytes"`
+	ReservedDiskPath              string        `json:"reserved_disk_path"`
+	GPUs                          string        `json:"gpus"`
+	GPURuntime                    string        `json:"gpu_runtime"`
+	SeccompProfilesDir            string        `json:"seccomp_profiles_dir"`
+	SeccompProfile                string        `json:"seccomp_profile"`
+	AppArmorProfiles              string        `json:"apparmor_profiles"`
+	AppArmorProfile               string        `json:"apparmor_profile"`
 	MaxFsSize                     uint64        `json:"max_fs_size_mb"`
 	MaxPIDs                       uint64        `json:"max_pids"`
 	MaxOpenFiles                  *uint64       `json:"max_open_files"`
@@ -50,6 +68,55 @@ type Config struct {
 	ImageCleanMaxSize             uint64        `json:"image_clean_max_size"`
 	ImageCleanExemptTags          string        `json:"image_clean_exempt_tags"`
 	ImageEnableVolume             bool          `json:"image_enable_volume"`
+	MaxChainDepth                 uint64        `json:"max_chain_depth"`
+	RequestSpoolThreshold         uint64        `json:"request_spool_threshold_bytes"`
+	RequestSpoolDir               string        `json:"request_spool_dir"`
+	MaxRequestSpoolSize           uint64        `json:"max_request_spool_size_bytes"`
+	MaxTotalRequestSpoolSize      uint64        `json:"max_total_request_spool_size_bytes"`
+	VolumesPath                   string        `json:"volumes_path"`
+	VolumesDockerPath             string        `json:"volumes_docker_path"`
+	MaxVolumeSizeMB               uint64        `json:"max_volume_size_mb"`
+	VolumeIdleTimeout             time.Duration `json:"volume_idle_timeout_msecs"`
+	DatasetsPath                  string        `json:"datasets_path"`
+	DatasetsDockerPath            string        `json:"datasets_docker_path"`
+	DatasetSourceURL              string        `json:"dataset_source_url"`
+	DatasetSyncInterval           time.Duration `json:"dataset_sync_msecs"`
+	DatasetIdleTimeout            time.Duration `json:"dataset_idle_timeout_msecs"`
+	SyslogURL                     string        `json:"syslog_url"`
+	SyslogTags                    string        `json:"syslog_tags"`
+	ContainerOutputTailSize       uint64        `json:"container_output_tail_size_bytes"`
+	EnableTimingHeaders           bool          `json:"enable_timing_headers"`
+	ReadinessProbePath            string        `json:"readiness_probe_path"`
+	ReadinessProbeTimeout         time.Duration `json:"readiness_probe_timeout_msecs"`
+	ReadinessProbeRetries         uint64        `json:"readiness_probe_retries"`
+	ReadinessProbeInterval        time.Duration `json:"readiness_probe_interval_msecs"`
+	ContainerStopTimeout          time.Duration `json:"container_stop_timeout_msecs"`
+	MaxLongRunningCalls           uint64        `json:"max_long_running_calls"`
+	PlatformEnvVersion            uint64        `json:"platform_env_version"`
+	Region                        string        `json:"region"`
+	RunnerID                      string        `json:"runner_id"`
+	RegistryCADir                 string        `json:"registry_ca_dir"`
+	DockerCertsDir                string        `json:"docker_certs_dir"`
+	DisableImagePulls             bool          `json:"disable_image_pulls"`
+	ImageLoadDir                  string        `json:"image_load_dir"`
+	ImageTrustPolicy              string        `json:"image_trust_policy"`
+	RequireSignedImages           bool          `json:"require_signed_images"`
+	FsStatsInterval               time.Duration `json:"fs_stats_interval_msecs"`
+	EnforceFsSize                 bool          `json:"enforce_fs_size"`
+	ResultCacheSize               uint64        `json:"result_cache_size"`
+	EnableFaultInjection          bool          `json:"enable_fault_injection"`
+	Driver                        string        `json:"driver"`
+	DriverScript                  string        `json:"driver_script"`
+	ContainerdAddress             string        `json:"containerd_address"`
+	ContainerdNamespace           string        `json:"containerd_namespace"`
+	ContainerdSnapshotter         string        `json:"containerd_snapshotter"`
+	ContainerdNetNS               string        `json:"containerd_netns"`
+	ContainerdWarmSnapshots       uint64        `json:"containerd_warm_snapshots"`
+	EnableWarmRecovery            bool          `json:"enable_warm_recovery"`
+	BlankContainers               uint64        `json:"blank_containers"`
+	ScratchStoreURL               string        `json:"scratch_store_url"`
+	ScratchTTL                    time.Duration `json:"scratch_ttl_msecs"`
+	CallLogFlushInterval          time.Duration `json:"call_log_flush_interval_msecs"`
 }
 
 const (
@@ -65,10 +132,26 @@ const (
 	EnvDockerNetworks = "FN_DOCKER_NETWORKS"
 	// EnvDockerLoadFile is a file location for a file that contains a tarball of a docker image to load on startup
 	EnvDockerLoadFile = "FN_DOCKER_LOAD_FILE"
+	// EnvDockerDaemons is a JSON list of extra docker daemons to run containers on, besides the one of DOCKER_HOST,
+	// eg. [{"name": "acme", "endpoint": "unix:///var/run/docker-acme.sock", "labels": {"tenant": "acme"}, "max_containers": 50}]
+	EnvDockerDaemons = "FN_DOCKER_DAEMONS"
 	// EnvDisableUnprivilegedContainers disables docker security features like user name, cap drop etc.
 	EnvDisableUnprivilegedContainers = "FN_DISABLE_UNPRIVILEGED_CONTAINERS"
 	// EnvFreezeIdle is the delay between a container being last used and being frozen
 	EnvFreezeIdle = "FN_FREEZE_IDLE_MSECS"
+	// EnvFreezeMemoryPercent squeezes the memory soft limit of frozen containers to this percent of their memory,
+	// so the kernel reclaims their page cache first under memory pressure. 0, the default, leaves it alone.
+	EnvFreezeMemoryPercent = "FN_FREEZE_MEMORY_PERCENT"
+	// EnvEagerUnfreeze unpauses a paused container of a fn when its last idle container takes a call, ahead of the
+	// next call, which then does not wait for the unpause. The container is paused again after EnvFreezeIdle if unused.
+	EnvEagerUnfreeze = "FN_EAGER_UNFREEZE"
+	// EnvCgroupRoot is where cgroups are mounted, containers are frozen by writing to their cgroup freezer there
+	// when the agent may, skipping docker pause. Empty always uses docker pause.
+	EnvCgroupRoot = "FN_CGROUP_ROOT"
+	// EnvDevMode runs containers without the docker features missing on Docker Desktop, for development on laptops:
+	// containers are not paused when frozen, and neither get a sized tmpfs, a disk quota nor a read only root fs.
+	// Each missing feature is logged once. It is on by default when the agent does not run on linux.
+	EnvDevMode = "FN_DEV_MODE"
 	// EnvHotPoll is the interval to ping for a slot manager thread to check if a container should be
 	// launched for a given function
 	EnvHotPoll = "FN_HOT_POLL_MSECS"
@@ -88,6 +171,31 @@ const (
 	EnvMaxTotalCPU = "FN_MAX_TOTAL_CPU_MCPUS"
 	// EnvMaxTotalMemory is the maximum memory that will be reserved across all containers
 	EnvMaxTotalMemory = "FN_MAX_TOTAL_MEMORY_BYTES"
+	// EnvReservedCPU is the CPU reserved for the agent, docker and the OS, that containers may not be given
+	EnvReservedCPU = "FN_RESERVED_CPU_MCPUS"
+	// EnvReservedMemory is the memory reserved for the agent, docker and the OS, that containers may not be given.
+	// On linux, it replaces the head room of 10% of memory, between 256MB and 5GB, that is reserved otherwise.
+	EnvReservedMemory = "FN_RESERVED_MEMORY_BYTES"
+	// EnvReservedDisk is the disk space kept free on EnvReservedDiskPath, no containers are started with less free
+	EnvReservedDisk = "FN_RESERVED_DISK_BYTES"
+	// EnvReservedDiskPath is where EnvReservedDisk is kept free, docker's data root by default
+	EnvReservedDiskPath = "FN_RESERVED_DISK_PATH"
+	// EnvGPUs is a comma separated list of the IDs of the GPUs of the node that containers may be given, indexes or
+	// UUIDs as nvidia-smi lists them. Each GPU is given to one hot container at a time.
+	EnvGPUs = "FN_GPUS"
+	// EnvGPURuntime is the docker runtime containers given GPUs run with, which exposes the GPUs to them
+	EnvGPURuntime = "FN_GPU_RUNTIME"
+	// EnvSeccompProfilesDir is a directory of seccomp profiles, <name>.json files, that containers may run with. Apps
+	// pick one by name in their policy.
+	EnvSeccompProfilesDir = "FN_SECCOMP_PROFILES_DIR"
+	// EnvSeccompProfile is the name of the profile of EnvSeccompProfilesDir containers run with by default, docker's
+	// default profile if unset
+	EnvSeccompProfile = "FN_SECCOMP_PROFILE"
+	// EnvAppArmorProfiles is a comma separated list of the AppArmor profiles loaded on the host that apps may pick in
+	// their policy
+	EnvAppArmorProfiles = "FN_APPARMOR_PROFILES"
+	// EnvAppArmorProfile is the AppArmor profile containers run with by default, docker's default profile if unset
+	EnvAppArmorProfile = "FN_APPARMOR_PROFILE"
 	// EnvMaxFsSize is the maximum filesystem size that a function may use
 	EnvMaxFsSize = "FN_MAX_FS_SIZE_MB"
 	// EnvMaxPIDs is the maximum number of PIDs that a function is allowed to create
@@ -135,14 +243,199 @@ const (
 	// EnvDetachedHeadroom is the extra room we want to give to a detached function to run.
 	EnvDetachedHeadroom = "FN_EXECUTION_HEADROOM"
 
+	// EnvMaxChainDepth is the maximum number of fns that may be chained together, including the first
+	EnvMaxChainDepth = "FN_MAX_CHAIN_DEPTH"
+
+	// EnvRequestSpoolThreshold is the size in bytes above which lb agents keep request bodies in temp files rather than
+	// in memory, 0 keeps them all in memory
+	EnvRequestSpoolThreshold = "FN_REQUEST_SPOOL_THRESHOLD"
+	// EnvRequestSpoolDir is the directory request bodies are spooled to, the system temp dir by default
+	EnvRequestSpoolDir = "FN_REQUEST_SPOOL_DIR"
+	// EnvMaxRequestSpoolSize is the most disk in bytes a single request body may be spooled to
+	EnvMaxRequestSpoolSize = "FN_MAX_REQUEST_SPOOL_SIZE"
+	// EnvMaxTotalRequestSpoolSize is the most disk in bytes all spooled request bodies may use together
+	EnvMaxTotalRequestSpoolSize = "FN_MAX_TOTAL_REQUEST_SPOOL_SIZE"
+
+	// EnvVolumesPath is the path within fn server container of a directory to keep fn volumes in, volumes are
+	// disabled if it is not set
+	EnvVolumesPath = "FN_VOLUMES_PATH"
+	// EnvVolumesDockerPath is the location of the volumes directory on the docker host, if different
+	EnvVolumesDockerPath = "FN_VOLUMES_DOCKER_PATH"
+	// EnvMaxVolumeSizeMB is the largest volume a fn may ask for, 0 is unlimited
+	EnvMaxVolumeSizeMB = "FN_MAX_VOLUME_SIZE_MB"
+	// EnvVolumeIdleTimeout is how long a volume may go unused before it is removed
+	EnvVolumeIdleTimeout = "FN_VOLUME_IDLE_TIMEOUT_MSECS"
+
+	// EnvDatasetsPath is the path within fn server container of a directory to keep fn datasets in, datasets are
+	// disabled if it is not set
+	EnvDatasetsPath = "FN_DATASETS_PATH"
+	// EnvDatasetsDockerPath is the location of the datasets directory on the docker host, if different
+	EnvDatasetsDockerPath = "FN_DATASETS_DOCKER_PATH"
+	// EnvDatasetSourceURL is the base URL datasets are fetched from, laid out as name/latest and name/version.tar.gz
+	EnvDatasetSourceURL = "FN_DATASET_SOURCE_URL"
+	// EnvDatasetSync is the interval to check for new versions of datasets
+	EnvDatasetSync = "FN_DATASET_SYNC_MSECS"
+	// EnvDatasetIdleTimeout is how long a dataset may go unused before it is removed
+	EnvDatasetIdleTimeout = "FN_DATASET_IDLE_TIMEOUT_MSECS"
+
+	// EnvSyslogURL is the syslog url of containers of apps without one, it may be a template such as
+	// tcp://{{.AppName}}.logs.example.com:514
+	EnvSyslogURL = "FN_SYSLOG_URL"
+	// EnvSyslogTags is a comma separated list of name=value syslog tags of containers, values may be templates
+	EnvSyslogTags = "FN_SYSLOG_TAGS"
+
+	// EnvContainerOutputTailSize is how much of the latest output of each hot container is kept, to report when it
+	// dies unexpectedly. 0 disables it.
+	EnvContainerOutputTailSize = "FN_CONTAINER_OUTPUT_TAIL_SIZE_BYTES"
+
+	// EnvTimingHeaders adds headers to responses telling whether the call was a cold start and how long it queued
+	// and ran for
+	EnvTimingHeaders = "FN_TIMING_HEADERS"
+
+	// EnvReadinessProbePath is the path of an http GET made to hot containers over their UDS socket once it
+	// appears, a container is ready once it answers with a 2xx. If it is not set, the socket only has to accept
+	// connections.
+	EnvReadinessProbePath = "FN_READINESS_PROBE_PATH"
+	// EnvReadinessProbeTimeout is the timeout of each readiness probe
+	EnvReadinessProbeTimeout = "FN_READINESS_PROBE_TIMEOUT_MSECS"
+	// EnvReadinessProbeRetries is how many times a failed readiness probe is retried before the container is
+	// recycled
+	EnvReadinessProbeRetries = "FN_READINESS_PROBE_RETRIES"
+	// EnvReadinessProbeInterval is the delay between readiness probes
+	EnvReadinessProbeInterval = "FN_READINESS_PROBE_INTERVAL_MSECS"
+
+	// EnvContainerStopTimeout is how long hot containers are given to exit after SIGTERM when they are recycled,
+	// evicted or drained, before they are killed, unless their fn sets its own. 0 kills them straight away.
+	EnvContainerStopTimeout = "FN_CONTAINER_STOP_TIMEOUT_MSECS"
+
+	// EnvMaxLongRunningCalls is how many calls of long running fns the agent runs at once, 0 for no limit
+	EnvMaxLongRunningCalls = "FN_MAX_LONG_RUNNING_CALLS"
+
+	// EnvPlatformEnvVersion is the version of the env vars and headers the agent sets for fns, 0 for the original
+	// set or up to PlatformEnvVersion
+	EnvPlatformEnvVersion = "FN_PLATFORM_ENV_VERSION"
+	// EnvRegion is the region of the runner, passed to fns from platform env version 1
+	EnvRegion = "FN_REGION"
+	// EnvRunnerID is the id of the runner, passed to fns from platform env version 1, its hostname by default
+	EnvRunnerID = "FN_RUNNER_ID"
+
+	// EnvRegistryCADir is a directory of PEM encoded CA bundles of private registries, named after their registry
+	// such as registry.example.com:5000.crt, which the docker daemon trusts for pulls as they are added, replaced or
+	// removed, without restarting the runner. It is watched, and managed through the admin server as well.
+	EnvRegistryCADir = "FN_REGISTRY_CA_DIR"
+	// EnvDockerCertsDir is the certs.d directory of the docker daemon, the registry CAs are copied to, which must be
+	// mounted in the fn server container when it runs in one
+	EnvDockerCertsDir = "FN_DOCKER_CERTS_DIR"
+
+	// EnvDisableImagePulls runs the agent offline, for air-gapped installs, where images are never pulled and only
+	// images loaded on the docker daemons run. The image cleaner is disabled, as images removed could not come back.
+	EnvDisableImagePulls = "FN_DISABLE_IMAGE_PULLS"
+	// EnvImageLoadDir is a directory of docker save tarballs staged on the runner, loaded at startup and by name
+	// through the admin server
+	EnvImageLoadDir = "FN_IMAGE_LOAD_DIR"
+	// EnvImageTrustPolicy is the path of a JSON policy of the public keys the images of each registry are signed
+	// with, eg. {"default": {"keys": ["/etc/fn/cosign.pub"]}, "registries": {"docker.io": {"keys": [...], "mode": "warn"}}}.
+	// The cosign signatures of images are verified before they are pulled, images signed by none of the keys of their
+	// registry are rejected, or only logged for registries in warn mode. Images already on the runner are verified
+	// too, once, and must be the signed image, loaded images without a digest of their registry are pulled again.
+	// Registries are reached trusting their CAs in EnvDockerCertsDir.
+	EnvImageTrustPolicy = "FN_IMAGE_TRUST_POLICY"
+	// EnvDockerRequireSignedImages only runs signed images, the images of registries without keys in the
+	// EnvImageTrustPolicy are rejected, and registries in warn mode are enforced
+	EnvDockerRequireSignedImages = "FN_DOCKER_REQUIRE_SIGNED_IMAGES"
+
+	// EnvFsStatsInterval is how often the size of the writable layer and the usage of the /tmp tmpfs of running
+	// containers are sampled, recorded in the stats of their calls and as metrics. Sampling the tmpfs needs the
+	// agent to share the pid namespace of the host. 0 disables it.
+	EnvFsStatsInterval = "FN_FS_STATS_INTERVAL_MSECS"
+	// EnvEnforceFsSize fails calls whose container reached its fs size, its tmpfs size or its tmpfs inodes with
+	// models.ErrContainerFsFull, rather than whatever the fn makes of ENOSPC, and recycles the container. The fs size
+	// is enforced even on storage drivers without quotas, from the samples of FN_FS_STATS_INTERVAL_MSECS.
+	EnvEnforceFsSize = "FN_ENFORCE_FS_SIZE"
+
+	// EnvResultCacheSize is how many successful detached calls of fns opting in with
+	// models.FnResultCacheAnnotation the agent remembers, to skip identical calls. 0 disables it.
+	EnvResultCacheSize = "FN_RESULT_CACHE_SIZE"
+
+	// EnvEnableFaultInjection lets admins inject faults into the pulls, creates and placements of the node through
+	// the admin server, for game days against staging clusters. Never enable it in production.
+	EnvEnableFaultInjection = "FN_ENABLE_FAULT_INJECTION"
+
+	// EnvDriver is the name of the container driver of the agent, docker by default. The mock driver runs fns
+	// without docker as scripted in EnvDriverScript, for load tests.
+	EnvDriver = "FN_DRIVER"
+	// EnvDriverScript is the JSON script of the mock driver, see mock.Script
+	EnvDriverScript = "FN_DRIVER_SCRIPT"
+	// EnvRuntime is the container runtime of the agent, docker or containerd. It names the driver as EnvDriver does,
+	// and takes precedence over it.
+	EnvRuntime = "FN_RUNTIME"
+
+	// EnvContainerdAddress is the containerd socket of the containerd driver
+	EnvContainerdAddress = "FN_CONTAINERD_ADDRESS"
+	// EnvContainerdNamespace is the containerd namespace the containerd driver keeps its images and containers in
+	EnvContainerdNamespace = "FN_CONTAINERD_NAMESPACE"
+	// EnvContainerdSnapshotter is the snapshotter the containerd driver unpacks images and creates containers with
+	EnvContainerdSnapshotter = "FN_CONTAINERD_SNAPSHOTTER"
+	// EnvContainerdNetNS is a whitespace separated list of the network namespaces, as `ip netns` names them, the
+	// containers of the containerd driver join. Fns restricted to networks join the first of them they may. Containers
+	// share the network of the host if unset.
+	EnvContainerdNetNS = "FN_CONTAINERD_NETNS"
+	// EnvContainerdWarmSnapshots is the number of rootfs snapshots the containerd driver prepares ahead on top of
+	// each image it created containers from, so that creating the next containers of the image does not wait on
+	// their rootfs. Each snapshot is used by one container. None are prepared if unset.
+	EnvContainerdWarmSnapshots = "FN_CONTAINERD_WARM_SNAPSHOTS"
+
+	// EnvEnableWarmRecovery leaves the idle hot containers of the agent running when it shuts down, recording them
+	// in the state file of FN_IOFS_PATH, for the agent to adopt once it restarts rather than start them cold again.
+	// Containers not adopted within their idle timeout are removed. It needs a driver that can adopt containers.
+	EnvEnableWarmRecovery = "FN_ENABLE_WARM_RECOVERY"
+
+	// EnvBlankContainers is how many containers the agent creates ahead, without starting them, for each fn that
+	// starts a container cold, for the next cold starts of the fn to skip creating a container. Containers not
+	// started within the idle timeout of their fn are removed. Experimental, 0 (default) disables it.
+	EnvBlankContainers = "FN_EXPERIMENTAL_BLANK_CONTAINERS"
+
+	// EnvScratchStoreURL is the object store the scratch space of apps is kept in, eg. s3://bucket/prefix, see
+	// models.FnScratchAnnotation and the providers of the api/scratch packages
+	EnvScratchStoreURL = "FN_SCRATCH_STORE_URL"
+	// EnvScratchTTL is how long the credentials to scratch space are requested for, containers stop taking calls
+	// before they expire
+	EnvScratchTTL = "FN_SCRATCH_TTL_MSECS"
+
+	// EnvCallLogFlushInterval is how often the output of calls is appended to the log store of the agent while they
+	// run, see WithCallLogStore
+	EnvCallLogFlushInterval = "FN_CALL_LOG_FLUSH_INTERVAL_MSECS"
+
 	// MaxMsDisabled is used to determine whether mr freeze is lying in wait. TODO remove this manuever
 	MaxMsDisabled = time.Duration(math.MaxInt64)
 
 	// defaults
 
+	// DefaultReservedDiskPath is the default value for EnvReservedDiskPath
+	DefaultReservedDiskPath = "/var/lib/docker"
+
+	// DefaultGPURuntime is the default value for EnvGPURuntime
+	DefaultGPURuntime = "nvidia"
+
+	// DefaultCgroupRoot is the default value for EnvCgroupRoot
+	DefaultCgroupRoot = "/sys/fs/cgroup"
+
 	// DefaultHotPoll is the default value for EnvHotPoll
 	DefaultHotPoll = 200 * time.Millisecond
 
+	// DefaultDockerCertsDir is the default value for EnvDockerCertsDir
+	DefaultDockerCertsDir = "/etc/docker/certs.d"
+
+	// DefaultDriver is the default value for EnvDriver
+	DefaultDriver = "docker"
+
+	// DefaultContainerdAddress is the default value for EnvContainerdAddress
+	DefaultContainerdAddress = "/run/containerd/containerd.sock"
+	// DefaultContainerdNamespace is the default value for EnvContainerdNamespace
+	DefaultContainerdNamespace = "fn"
+	// DefaultContainerdSnapshotter is the default value for EnvContainerdSnapshotter
+	DefaultContainerdSnapshotter = "overlayfs"
+
 	// TODO(reed): none of these consts above or below should be exported yo
 
 	// iofsDockerMountDest is the mount path for inside of the container to use for the iofs path
@@ -166,9 +459,20 @@ func NewConfig() (*Config, error) {
 	defaultMaxLockedMemory := uint64(64 * 1024)
 	defaultMaxPendingSignals := uint64(5000)
 	defaultMaxMessageQueue := uint64(819200)
+	defaultMaxChainDepth := uint64(8)
+	defaultRequestSpoolThreshold := uint64(1024 * 1024)
+	defaultContainerOutputTailSize := uint64(8 * 1024)
+	defaultReadinessProbeRetries := uint64(3)
+	defaultResultCacheSize := uint64(10000)
 
 	var err error
 	err = setEnvMsecs(err, EnvFreezeIdle, &cfg.FreezeIdle, 50*time.Millisecond)
+	err = setEnvUint(err, EnvFreezeMemoryPercent, &cfg.FreezeMemoryPercent, nil)
+	err = setEnvBool(err, EnvEagerUnfreeze, &cfg.EagerUnfreeze)
+	cfg.CgroupRoot = DefaultCgroupRoot
+	err = setEnvStr(err, EnvCgroupRoot, &cfg.CgroupRoot)
+	cfg.DevMode = runtime.GOOS != "linux"
+	err = setEnvBool(err, EnvDevMode, &cfg.DevMode)
 	err = setEnvMsecs(err, EnvHotPoll, &cfg.HotPoll, DefaultHotPoll)
 	err = setEnvMsecs(err, EnvHotLauncherTimeout, &cfg.HotLauncherTimeout, time.Duration(60)*time.Minute)
 	err = setEnvMsecs(err, EnvHotPullTimeout, &cfg.HotPullTimeout, time.Duration(10)*time.Minute)
@@ -179,6 +483,18 @@ func NewConfig() (*Config, error) {
 	err = setEnvUint(err, EnvMaxLogSize, &cfg.MaxLogSize, nil)
 	err = setEnvUint(err, EnvMaxTotalCPU, &cfg.MaxTotalCPU, nil)
 	err = setEnvUint(err, EnvMaxTotalMemory, &cfg.MaxTotalMemory, nil)
+	err = setEnvUint(err, EnvReservedCPU, &cfg.ReservedCPU, nil)
+	err = setEnvUint(err, EnvReservedMemory, &cfg.ReservedMemory, nil)
+	err = setEnvUint(err, EnvReservedDisk, &cfg.ReservedDisk, nil)
+	cfg.ReservedDiskPath = DefaultReservedDiskPath
+	err = setEnvStr(err, EnvReservedDiskPath, &cfg.ReservedDiskPath)
+	err = setEnvStr(err, EnvGPUs, &cfg.GPUs)
+	cfg.GPURuntime = DefaultGPURuntime
+	err = setEnvStr(err, EnvGPURuntime, &cfg.GPURuntime)
+	err = setEnvStr(err, EnvSeccompProfilesDir, &cfg.SeccompProfilesDir)
+	err = setEnvStr(err, EnvSeccompProfile, &cfg.SeccompProfile)
+	err = setEnvStr(err, EnvAppArmorProfiles, &cfg.AppArmorProfiles)
+	err = setEnvStr(err, EnvAppArmorProfile, &cfg.AppArmorProfile)
 	err = setEnvUint(err, EnvMaxFsSize, &cfg.MaxFsSize, nil)
 	err = setEnvUint(err, EnvMaxPIDs, &cfg.MaxPIDs, &defaultMaxPIDs)
 	err = setEnvUintPointer(err, EnvMaxOpenFiles, &cfg.MaxOpenFiles, &defaultMaxOpenFiles)
@@ -193,6 +509,7 @@ func NewConfig() (*Config, error) {
 	err = setEnvStr(err, EnvContainerLabelTag, &cfg.ContainerLabelTag)
 	err = setEnvStr(err, EnvDockerNetworks, &cfg.DockerNetworks)
 	err = setEnvStr(err, EnvDockerLoadFile, &cfg.DockerLoadFile)
+	err = setEnvStr(err, EnvDockerDaemons, &cfg.DockerDaemons)
 	err = setEnvBool(err, EnvDisableUnprivilegedContainers, &cfg.DisableUnprivilegedContainers)
 	err = setEnvUint(err, EnvMaxTmpFsInodes, &cfg.MaxTmpFsInodes, nil)
 	err = setEnvStr(err, EnvIOFSPath, &cfg.IOFSAgentPath)
@@ -205,16 +522,97 @@ func NewConfig() (*Config, error) {
 	err = setEnvUint(err, EnvImageCleanMaxSize, &cfg.ImageCleanMaxSize, nil)
 	err = setEnvStr(err, EnvImageCleanExemptTags, &cfg.ImageCleanExemptTags)
 	err = setEnvBool(err, EnvImageEnableVolume, &cfg.ImageEnableVolume)
+	err = setEnvUint(err, EnvMaxChainDepth, &cfg.MaxChainDepth, &defaultMaxChainDepth)
+	err = setEnvUint(err, EnvRequestSpoolThreshold, &cfg.RequestSpoolThreshold, &defaultRequestSpoolThreshold)
+	err = setEnvStr(err, EnvRequestSpoolDir, &cfg.RequestSpoolDir)
+	err = setEnvUint(err, EnvMaxRequestSpoolSize, &cfg.MaxRequestSpoolSize, nil)
+	err = setEnvUint(err, EnvMaxTotalRequestSpoolSize, &cfg.MaxTotalRequestSpoolSize, nil)
+	err = setEnvStr(err, EnvVolumesPath, &cfg.VolumesPath)
+	err = setEnvStr(err, EnvVolumesDockerPath, &cfg.VolumesDockerPath)
+	err = setEnvUint(err, EnvMaxVolumeSizeMB, &cfg.MaxVolumeSizeMB, nil)
+	err = setEnvMsecs(err, EnvVolumeIdleTimeout, &cfg.VolumeIdleTimeout, time.Duration(24)*time.Hour)
+	err = setEnvStr(err, EnvDatasetsPath, &cfg.DatasetsPath)
+	err = setEnvStr(err, EnvDatasetsDockerPath, &cfg.DatasetsDockerPath)
+	err = setEnvStr(err, EnvDatasetSourceURL, &cfg.DatasetSourceURL)
+	err = setEnvMsecs(err, EnvDatasetSync, &cfg.DatasetSyncInterval, time.Duration(5)*time.Minute)
+	err = setEnvMsecs(err, EnvDatasetIdleTimeout, &cfg.DatasetIdleTimeout, time.Duration(24)*time.Hour)
+	err = setEnvStr(err, EnvSyslogURL, &cfg.SyslogURL)
+	cfg.SyslogTags = DefaultSyslogTags
+	err = setEnvStr(err, EnvSyslogTags, &cfg.SyslogTags)
+	err = setEnvUint(err, EnvContainerOutputTailSize, &cfg.ContainerOutputTailSize, &defaultContainerOutputTailSize)
+	err = setEnvBool(err, EnvTimingHeaders, &cfg.EnableTimingHeaders)
+	err = setEnvStr(err, EnvReadinessProbePath, &cfg.ReadinessProbePath)
+	err = setEnvMsecs(err, EnvReadinessProbeTimeout, &cfg.ReadinessProbeTimeout, time.Duration(1)*time.Second)
+	err = setEnvUint(err, EnvReadinessProbeRetries, &cfg.ReadinessProbeRetries, &defaultReadinessProbeRetries)
+	err = setEnvMsecs(err, EnvReadinessProbeInterval, &cfg.ReadinessProbeInterval, time.Duration(100)*time.Millisecond)
+	err = setEnvMsecs(err, EnvContainerStopTimeout, &cfg.ContainerStopTimeout, time.Duration(2)*time.Second)
+	err = setEnvUint(err, EnvMaxLongRunningCalls, &cfg.MaxLongRunningCalls, nil)
+	err = setEnvUint(err, EnvPlatformEnvVersion, &cfg.PlatformEnvVersion, nil)
+	err = setEnvStr(err, EnvRegion, &cfg.Region)
+	cfg.RunnerID, _ = os.Hostname()
+	err = setEnvStr(err, EnvRunnerID, &cfg.RunnerID)
+	err = setEnvStr(err, EnvRegistryCADir, &cfg.RegistryCADir)
+	cfg.DockerCertsDir = DefaultDockerCertsDir
+	err = setEnvStr(err, EnvDockerCertsDir, &cfg.DockerCertsDir)
+	err = setEnvBool(err, EnvDisableImagePulls, &cfg.DisableImagePulls)
+	err = setEnvStr(err, EnvImageLoadDir, &cfg.ImageLoadDir)
+	err = setEnvStr(err, EnvImageTrustPolicy, &cfg.ImageTrustPolicy)
+	err = setEnvBool(err, EnvDockerRequireSignedImages, &cfg.RequireSignedImages)
+	err = setEnvMsecs(err, EnvFsStatsInterval, &cfg.FsStatsInterval, 0)
+	err = setEnvBool(err, EnvEnforceFsSize, &cfg.EnforceFsSize)
+	err = setEnvUint(err, EnvResultCacheSize, &cfg.ResultCacheSize, &defaultResultCacheSize)
+	err = setEnvBool(err, EnvEnableFaultInjection, &cfg.EnableFaultInjection)
+	cfg.Driver = DefaultDriver
+	err = setEnvStr(err, EnvDriver, &cfg.Driver)
+	err = setEnvStr(err, EnvRuntime, &cfg.Driver)
+	err = setEnvStr(err, EnvDriverScript, &cfg.DriverScript)
+	cfg.ContainerdAddress = DefaultContainerdAddress
+	err = setEnvStr(err, EnvContainerdAddress, &cfg.ContainerdAddress)
+	cfg.ContainerdNamespace = DefaultContainerdNamespace
+	err = setEnvStr(err, EnvContainerdNamespace, &cfg.ContainerdNamespace)
+	cfg.ContainerdSnapshotter = DefaultContainerdSnapshotter
+	err = setEnvStr(err, EnvContainerdSnapshotter, &cfg.ContainerdSnapshotter)
+	err = setEnvStr(err, EnvContainerdNetNS, &cfg.ContainerdNetNS)
+	err = setEnvUint(err, EnvContainerdWarmSnapshots, &cfg.ContainerdWarmSnapshots, nil)
+	err = setEnvBool(err, EnvEnableWarmRecovery, &cfg.EnableWarmRecovery)
+	err = setEnvUint(err, EnvBlankContainers, &cfg.BlankContainers, nil)
+	err = setEnvStr(err, EnvScratchStoreURL, &cfg.ScratchStoreURL)
+	err = setEnvMsecs(err, EnvScratchTTL, &cfg.ScratchTTL, time.Hour)
+	err = setEnvMsecs(err, EnvCallLogFlushInterval, &cfg.CallLogFlushInterval, time.Duration(5)*time.Second)
 
 	if err != nil {
 		return cfg, err
 	}
 
+	if cfg.PlatformEnvVersion > PlatformEnvVersion {
+		return cfg, fmt.Errorf("error invalid %s %v > %v", EnvPlatformEnvVersion, cfg.PlatformEnvVersion, PlatformEnvVersion)
+	}
+
 	if cfg.MaxLogSize > math.MaxInt64 {
 		// for safety during uint64 to int conversions in Write()/Read(), etc.
 		return cfg, fmt.Errorf("error invalid %s %v > %v", EnvMaxLogSize, cfg.MaxLogSize, math.MaxInt64)
 	}
 
+	if cfg.ContainerOutputTailSize > cfg.MaxLogSize {
+		return cfg, fmt.Errorf("error invalid %s %v > %s %v", EnvContainerOutputTailSize, cfg.ContainerOutputTailSize, EnvMaxLogSize, cfg.MaxLogSize)
+	}
+
+	if cfg.CallLogFlushInterval <= 0 {
+		return cfg, fmt.Errorf("error invalid %s %v, it must be positive", EnvCallLogFlushInterval, cfg.CallLogFlushInterval)
+	}
+
+	if cfg.FreezeMemoryPercent > 100 {
+		return cfg, fmt.Errorf("error invalid %s %v > 100", EnvFreezeMemoryPercent, cfg.FreezeMemoryPercent)
+	}
+
+	if cfg.ReadinessProbePath != "" && !strings.HasPrefix(cfg.ReadinessProbePath, "/") {
+		return cfg, fmt.Errorf("error invalid %s=%s, it must start with /", EnvReadinessProbePath, cfg.ReadinessProbePath)
+	}
+
+	if _, err := parseSyslogTags(cfg.SyslogTags); err != nil {
+		return cfg, fmt.Errorf("error invalid %s: %v", EnvSyslogTags, err)
+	}
+
 	return cfg, nil
 }
 
@@ -222,9 +620,11 @@ func setEnvStr(err error, name string, dst *string) error {
 	if err != nil {
 		return err
 	}
-	if tmp, ok := os.LookupEnv(name); ok {
+	tmp, ok := config.Lookup(name)
+	if ok {
 		*dst = strings.TrimSpace(tmp)
 	}
+	config.Record(name, *dst, ok)
 	return nil
 }
 
@@ -232,13 +632,15 @@ func setEnvBool(err error, name string, dst *bool) error {
 	if err != nil {
 		return err
 	}
-	if tmp, ok := os.LookupEnv(name); ok {
+	tmp, ok := config.Lookup(name)
+	if ok {
 		val, err := strconv.ParseBool(tmp)
 		if err != nil {
 			return err
 		}
 		*dst = val
 	}
+	config.Record(name, *dst, ok)
 	return nil
 }
 
@@ -247,7 +649,8 @@ func setEnvUint(err error, name string, dst *uint64, defaultValue *uint64) error
 		return err
 	}
 
-	if tmp := os.Getenv(name); tmp != "" {
+	tmp, _ := config.Lookup(name)
+	if tmp != "" {
 		val, err := strconv.ParseUint(tmp, 10, 64)
 		if err != nil {
 			return fmt.Errorf("error invalid %s=%s", name, tmp)
@@ -256,6 +659,7 @@ func setEnvUint(err error, name string, dst *uint64, defaultValue *uint64) error
 	} else if defaultValue != nil {
 		*dst = *defaultValue
 	}
+	config.Record(name, *dst, tmp != "")
 
 	return nil
 }
@@ -264,7 +668,8 @@ func setEnvUintPointer(err error, name string, dst **uint64, defaultVal *uint64)
 	if err != nil {
 		return err
 	}
-	if tmp, ok := os.LookupEnv(name); ok {
+	tmp, ok := config.Lookup(name)
+	if ok {
 		val, err := strconv.ParseUint(tmp, 10, 64)
 		if err != nil {
 			return fmt.Errorf("error invalid %s=%s", name, tmp)
@@ -274,6 +679,11 @@ func setEnvUintPointer(err error, name string, dst **uint64, defaultVal *uint64)
 		// No value found in the environment but a default value is supplied
 		*dst = defaultVal
 	}
+	if *dst != nil {
+		config.Record(name, **dst, ok)
+	} else {
+		config.Record(name, "", ok)
+	}
 
 	return nil
 }
@@ -285,7 +695,8 @@ func setEnvMsecs(err error, name string, dst *time.Duration, defaultVal time.Dur
 
 	*dst = defaultVal
 
-	if dur := os.Getenv(name); dur != "" {
+	dur, _ := config.Lookup(name)
+	if dur != "" {
 		durInt, err := strconv.ParseInt(dur, 10, 64)
 		if err != nil {
 			return fmt.Errorf("error invalid %s=%s err=%s", name, dur, err)
@@ -297,6 +708,7 @@ func setEnvMsecs(err error, name string, dst *time.Duration, defaultVal time.Dur
 			*dst = time.Duration(durInt) * time.Millisecond
 		}
 	}
+	config.Record(name, *dst, dur != "")
 
 	return nil
 }
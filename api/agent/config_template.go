@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// SecretResolver looks up named secrets for use in function config templates,
+// e.g. a config value of `{{secret "db_password"}}` is replaced with the value
+// returned for "db_password" when the container is created.
+type SecretResolver interface {
+	Secret(ctx context.Context, appID, name string) (string, error)
+}
+
+// SecretResolverFunc adapts a function to a SecretResolver
+type SecretResolverFunc func(ctx context.Context, appID, name string) (string, error)
+
+// Secret implements SecretResolver
+func (f SecretResolverFunc) Secret(ctx context.Context, appID, name string) (string, error) {
+	return f(ctx, appID, name)
+}
+
+// errNoSecretResolver is returned when a config template references a secret
+// but no SecretResolver was provided for the call
+var errNoSecretResolver = errors.New("no secret resolver configured")
+
+// secretName matches the names of secrets, which name files of the secrets directory of an app
+var secretName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// NewDirSecretResolver returns a SecretResolver reading the secrets of each
+// app from the files of a directory of its own, dir/<app id>/<name>, such as
+// a mounted Kubernetes secret. Apps only read their own secrets.
+func NewDirSecretResolver(dir string) SecretResolver {
+	return SecretResolverFunc(func(ctx context.Context, appID, name string) (string, error) {
+		if !secretName.MatchString(name) || !secretName.MatchString(appID) {
+			return "", fmt.Errorf("invalid secret name %q", name)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, appID, name))
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("secret %q not found", name)
+		} else if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	})
+}
+
+// configTemplateData is the set of fields that may be referenced from a
+// config template, e.g. `{{.AppName}}`. They are the same for every call of
+// a hot container, which the containers are shared by, so the trigger of a
+// call is not one of them.
+type configTemplateData struct {
+	AppID   string
+	AppName string
+	FnID    string
+	Image   string
+	Memory  uint64
+	CPUs    string
+}
+
+// WithSecretResolver configures the resolver used for `{{secret "name"}}`
+// lookups in config templates, and for the registry secrets of app policies.
+// Servers set it on every call with WithCallOptions.
+func WithSecretResolver(r SecretResolver) CallOpt {
+	return func(c *call) error {
+		c.secrets = r
+		return nil
+	}
+}
+
+// callTemplates renders templates against the fields of a call, e.g. `{{.AppName}}`, and its secrets
+type callTemplates struct {
+	data  configTemplateData
+	funcs template.FuncMap
+	buf   bytes.Buffer
+}
+
+func newCallTemplates(ctx context.Context, c *call) *callTemplates {
+	return &callTemplates{
+		data: configTemplateData{
+			AppID:   c.AppID,
+			AppName: c.AppName,
+			FnID:    c.FnID,
+			Image:   c.Image,
+			Memory:  c.Memory,
+			CPUs:    c.CPUs.String(),
+		},
+		funcs: template.FuncMap{
+			"secret": func(name string) (string, error) {
+				if c.secrets == nil {
+					return "", errNoSecretResolver
+				}
+				return c.secrets.Secret(ctx, c.AppID, name)
+			},
+		},
+	}
+}
+
+// render renders text as a template. Text without template delimiters is
+// returned as is, so that plain values pay nothing for this.
+func (t *callTemplates) render(name, text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New(name).Funcs(t.funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	t.buf.Reset()
+	if err := tmpl.Execute(&t.buf, t.data); err != nil {
+		return "", err
+	}
+	return t.buf.String(), nil
+}
+
+// resolveConfigTemplates renders any templated values in env in place.
+func resolveConfigTemplates(ctx context.Context, c *call, env map[string]string) error {
+	templates := newCallTemplates(ctx, c)
+	for k, v := range env {
+		rendered, err := templates.render(k, v)
+		if err != nil {
+			return configTemplateError(k, err)
+		}
+		env[k] = rendered
+	}
+	return nil
+}
+
+// configTemplateError reports a bad template as the function's fault, since
+// it comes from user supplied config
+func configTemplateError(key string, err error) error {
+	return models.NewFuncError(models.NewAPIError(http.StatusBadGateway,
+		fmt.Errorf("error resolving config template for %s: %v", key, err)))
+}
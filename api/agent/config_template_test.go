@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestResolveConfigTemplates(t *testing.T) {
+	secrets := SecretResolverFunc(func(ctx context.Context, appID, name string) (string, error) {
+		if name == "db_password" {
+			return "s3cret-" + appID, nil
+		}
+		return "", errors.New("secret not found")
+	})
+
+	c := &call{
+		Call: &models.Call{
+			AppID:   "app_id",
+			AppName: "myapp",
+			FnID:    "fn_id",
+			Image:   "fnproject/hello",
+		},
+	}
+
+	for i, test := range []struct {
+		value   string
+		secrets SecretResolver
+		want    string
+		wantErr bool
+	}{
+		{"plain", nil, "plain", false},
+		{"{{.AppName}}/{{.FnID}}", nil, "myapp/fn_id", false},
+		{`{{secret "db_password"}}`, secrets, "s3cret-app_id", false},
+		{`{{secret "db_password"}}`, nil, "", true},
+		{`{{secret "nope"}}`, secrets, "", true},
+		{"{{.NoSuchField}}", nil, "", true},
+		// hot containers are shared by the triggers of a fn
+		{"{{.TriggerID}}", nil, "", true},
+		{"{{.AppName", nil, "", true},
+	} {
+		c.secrets = test.secrets
+		env := map[string]string{"KEY": test.value}
+
+		err := resolveConfigTemplates(context.Background(), c, env)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error for %q, got %q", i, test.value, env["KEY"])
+			} else if !models.IsFuncError(err) {
+				t.Errorf("Test %d: expected a func error, got %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: unexpected error for %q: %v", i, test.value, err)
+			continue
+		}
+		if env["KEY"] != test.want {
+			t.Errorf("Test %d: expected %q, got %q", i, test.want, env["KEY"])
+		}
+	}
+}
+
+func TestDirSecretResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, app := range []string{"app_id", "other_id"} {
+		if err := os.MkdirAll(filepath.Join(dir, app), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, app, "db_password"), []byte("s3cret-"+app+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	secrets := NewDirSecretResolver(dir)
+
+	if v, err := secrets.Secret(ctx, "app_id", "db_password"); err != nil || v != "s3cret-app_id" {
+		t.Fatalf("expected the secret of the app, got %q %v", v, err)
+	}
+	for _, name := range []string{"nope", "../other_id/db_password", ".", ""} {
+		if v, err := secrets.Secret(ctx, "app_id", name); err == nil {
+			t.Errorf("expected secret %q not to resolve, got %q", name, v)
+		}
+	}
+	if v, err := secrets.Secret(ctx, "..", "db_password"); err == nil {
+		t.Errorf("expected an invalid app id not to resolve, got %q", v)
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	app := &models.App{ID: "app_id", Config: models.Config{"A": "app", "B": "app", "C": "app", "FN_MEMORY": "1"}}
+	fn := &models.Fn{ID: "fn_id", Config: models.Config{"B": "fn", "C": "fn"}}
+	fn.Memory = 128
+
+	trig := &models.Trigger{ID: "trigger_id"}
+	trig.Annotations, _ = trig.Annotations.With(models.TriggerConfigAnnotation, map[string]string{"C": "trigger", "FN_APP_ID": "evil"})
+
+	c := &call{Call: &models.Call{Config: buildConfig(app, fn)}}
+	if err := WithTrigger(trig)(c); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, want := range map[string]string{"A": "app", "B": "fn", "C": "trigger", "FN_MEMORY": "128", "FN_APP_ID": "app_id"} {
+		if got := c.Config[k]; got != want {
+			t.Errorf("expected %s=%q, got %q", k, want, got)
+		}
+	}
+	if c.TriggerID != "trigger_id" {
+		t.Errorf("expected trigger id to be set, got %q", c.TriggerID)
+	}
+}
+
+func TestLoggerConfig(t *testing.T) {
+	c := &call{
+		Call: &models.Call{
+			AppID:   "app_id",
+			AppName: "myapp",
+			FnID:    "fn_id",
+		},
+	}
+	cfg := &Config{SyslogTags: DefaultSyslogTags}
+
+	if conf, err := loggerConfig(context.Background(), c, cfg); err != nil || conf.URL != "" || len(conf.Tags) != 0 {
+		t.Errorf("expected no logger without a syslog url, got %+v %v", conf, err)
+	}
+
+	// the runner's url applies to apps without one
+	cfg.SyslogURL = "tcp://{{.AppName}}.logs:514"
+	conf, err := loggerConfig(context.Background(), c, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.URL != "tcp://myapp.logs:514" {
+		t.Errorf("expected templated url, got %q", conf.URL)
+	}
+	if len(conf.Tags) != 2 || conf.Tags[0] != (drivers.LoggerTag{Name: "app_id", Value: "app_id"}) || conf.Tags[1] != (drivers.LoggerTag{Name: "fn_id", Value: "fn_id"}) {
+		t.Errorf("expected default tags, got %+v", conf.Tags)
+	}
+
+	c.SyslogURL = "udp://tenant.logs:514"
+	cfg.SyslogTags = "app={{.AppName}}, fn={{.FnID}}"
+	conf, err = loggerConfig(context.Background(), c, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.URL != "udp://tenant.logs:514" || len(conf.Tags) != 2 || conf.Tags[0].Value != "myapp" || conf.Tags[1].Name != "fn" {
+		t.Errorf("unexpected logger config %+v", conf)
+	}
+
+	c.SyslogURL = "tcp://{{.NoSuchField}}:514"
+	if _, err := loggerConfig(context.Background(), c, cfg); !models.IsFuncError(err) {
+		t.Errorf("expected a func error for a bad template, got %v", err)
+	}
+
+	if _, err := parseSyslogTags("app_id"); err == nil {
+		t.Error("expected an error for a tag without a value")
+	}
+}
@@ -139,3 +139,27 @@ func TestSetEnvUintError(t *testing.T) {
 		})
 	}
 }
+
+// TestRuntimeSelectsDriver tests that FN_RUNTIME names the driver, over FN_DRIVER
+func TestRuntimeSelectsDriver(t *testing.T) {
+	defer os.Unsetenv(EnvDriver)
+	defer os.Unsetenv(EnvRuntime)
+
+	os.Setenv(EnvDriver, "mock")
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Driver != "mock" {
+		t.Fatalf("expected the driver of %s, got %s", EnvDriver, cfg.Driver)
+	}
+
+	os.Setenv(EnvRuntime, "containerd")
+	cfg, err = NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Driver != "containerd" || cfg.ContainerdAddress != DefaultContainerdAddress || cfg.ContainerdNamespace != DefaultContainerdNamespace {
+		t.Fatalf("expected the containerd driver with its defaults, got %s %s %s", cfg.Driver, cfg.ContainerdAddress, cfg.ContainerdNamespace)
+	}
+}
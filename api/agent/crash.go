@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// maxContainerCrashes is how many crashed containers an agent remembers
+	maxContainerCrashes = 32
+	// crashOutputWait is how long a call that lost its container waits for the container to exit, so that all of
+	// its output is in its tail
+	crashOutputWait = time.Second
+)
+
+// ContainerCrash describes a hot container that died unexpectedly
+type ContainerCrash struct {
+	ContainerID string    `json:"container_id"`
+	AppID       string    `json:"app_id"`
+	FnID        string    `json:"fn_id"`
+	Image       string    `json:"image"`
+	LastCallID  string    `json:"last_call_id,omitempty"`
+	Error       string    `json:"error"`
+	Output      string    `json:"output,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// CrashReporter is implemented by agents that remember the last hot containers that died unexpectedly, with the
+// tail of their output.
+type CrashReporter interface {
+	// ContainerCrashes returns the crashed containers, most recent first
+	ContainerCrashes() []ContainerCrash
+}
+
+var _ CrashReporter = new(agent)
+var _ CrashReporter = new(pureRunner)
+
+// ContainerCrashes implements CrashReporter
+func (a *agent) ContainerCrashes() []ContainerCrash {
+	return a.crashes.list()
+}
+
+// ContainerCrashes implements CrashReporter
+func (pr *pureRunner) ContainerCrashes() []ContainerCrash {
+	if r, ok := pr.a.(CrashReporter); ok {
+		return r.ContainerCrashes()
+	}
+	return nil
+}
+
+// crashLog keeps the last maxContainerCrashes crashes
+type crashLog struct {
+	lock    sync.Mutex
+	crashes []ContainerCrash
+}
+
+func (l *crashLog) add(c ContainerCrash) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.crashes = append(l.crashes, c)
+	if len(l.crashes) > maxContainerCrashes {
+		l.crashes = append(l.crashes[:0], l.crashes[len(l.crashes)-maxContainerCrashes:]...)
+	}
+}
+
+func (l *crashLog) list() []ContainerCrash {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	crashes := make([]ContainerCrash, len(l.crashes))
+	for i, c := range l.crashes {
+		crashes[len(crashes)-1-i] = c
+	}
+	return crashes
+}
+
+// outputTail keeps the last bytes of a container's output written to it. A nil outputTail keeps nothing.
+type outputTail struct {
+	lock sync.Mutex
+	buf  []byte
+	pos  int
+	full bool
+}
+
+func newOutputTail(size uint64) *outputTail {
+	if size == 0 {
+		return nil
+	}
+	return &outputTail{buf: make([]byte, size)}
+}
+
+func (t *outputTail) Write(p []byte) (int, error) {
+	n := len(p)
+	if t == nil {
+		return n, nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if len(p) >= len(t.buf) {
+		p = p[len(p)-len(t.buf):]
+		t.pos, t.full = 0, true
+	}
+	for len(p) > 0 {
+		c := copy(t.buf[t.pos:], p)
+		p = p[c:]
+		t.pos += c
+		if t.pos == len(t.buf) {
+			t.pos, t.full = 0, true
+		}
+	}
+	return n, nil
+}
+
+func (t *outputTail) String() string {
+	if t == nil {
+		return ""
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.full {
+		return string(t.buf[:t.pos])
+	}
+	return string(t.buf[t.pos:]) + string(t.buf[:t.pos])
+}
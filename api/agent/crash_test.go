@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestOutputTail(t *testing.T) {
+	tail := newOutputTail(8)
+	tail.Write([]byte("abc"))
+	if tail.String() != "abc" {
+		t.Errorf("unexpected tail %q", tail.String())
+	}
+	tail.Write([]byte("defgh"))
+	tail.Write([]byte("ij"))
+	if tail.String() != "cdefghij" {
+		t.Errorf("unexpected tail after wrapping %q", tail.String())
+	}
+	if n, _ := tail.Write([]byte(strings.Repeat("x", 10) + "12345678")); n != 18 {
+		t.Errorf("expected the whole write to be reported, got %d", n)
+	}
+	if tail.String() != "12345678" {
+		t.Errorf("unexpected tail after a long write %q", tail.String())
+	}
+
+	disabled := newOutputTail(0)
+	if n, err := disabled.Write([]byte("abc")); n != 3 || err != nil || disabled.String() != "" {
+		t.Errorf("expected a disabled tail to keep nothing, got %d %v %q", n, err, disabled.String())
+	}
+}
+
+func TestCrashLog(t *testing.T) {
+	var log crashLog
+	for i := 0; i < maxContainerCrashes+5; i++ {
+		log.add(ContainerCrash{ContainerID: fmt.Sprint(i)})
+	}
+	crashes := log.list()
+	if len(crashes) != maxContainerCrashes {
+		t.Fatalf("expected %d crashes, got %d", maxContainerCrashes, len(crashes))
+	}
+	if crashes[0].ContainerID != fmt.Sprint(maxContainerCrashes+4) || crashes[len(crashes)-1].ContainerID != "5" {
+		t.Errorf("expected the most recent crashes first, got %s..%s", crashes[0].ContainerID, crashes[len(crashes)-1].ContainerID)
+	}
+}
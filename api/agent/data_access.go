@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/fnproject/fn/api/common"
 	"github.com/fnproject/fn/api/models"
 	"github.com/golang/groupcache/singleflight"
 	"github.com/patrickmn/go-cache"
@@ -35,27 +36,27 @@ type metricda struct {
 	rda ReadDataAccess
 }
 
-func (m *metricda) GetTriggerBySource(ctx context.Context, appID string, triggerType, source string) (*models.Trigger, error) {
+func (m *metricda) GetTriggerBySource(ctx context.Context, appID string, triggerType, source string) (_ *models.Trigger, err error) {
 	ctx, span := trace.StartSpan(ctx, "rda_get_trigger_by_source")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.rda.GetTriggerBySource(ctx, appID, triggerType, source)
 }
 
-func (m *metricda) GetAppID(ctx context.Context, appName string) (string, error) {
+func (m *metricda) GetAppID(ctx context.Context, appName string) (_ string, err error) {
 	ctx, span := trace.StartSpan(ctx, "rda_get_app_id")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.rda.GetAppID(ctx, appName)
 }
 
-func (m *metricda) GetAppByID(ctx context.Context, appID string) (*models.App, error) {
+func (m *metricda) GetAppByID(ctx context.Context, appID string) (_ *models.App, err error) {
 	ctx, span := trace.StartSpan(ctx, "rda_get_app_by_id")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.rda.GetAppByID(ctx, appID)
 }
 
-func (m *metricda) GetFnByID(ctx context.Context, fnID string) (*models.Fn, error) {
+func (m *metricda) GetFnByID(ctx context.Context, fnID string) (_ *models.Fn, err error) {
 	ctx, span := trace.StartSpan(ctx, "rda_get_fn_by_id")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.rda.GetFnByID(ctx, fnID)
 }
 
@@ -162,3 +163,48 @@ func (da *cachedDataAccess) GetFnByID(ctx context.Context, fnID string) (*models
 	da.cache.Set(key, fn, cache.DefaultExpiration)
 	return fn.(*models.Fn), nil
 }
+
+// CacheInvalidator is implemented by the data accesses caching the apps, fns and triggers of the datastore,
+// Invalidate drops the entries a change to the datastore made stale, rather than letting them expire
+type CacheInvalidator interface {
+	Invalidate(change models.DatastoreChange)
+}
+
+var _ CacheInvalidator = new(cachedDataAccess)
+
+// Invalidate implements CacheInvalidator, changes without a kind flush the whole cache
+func (da *cachedDataAccess) Invalidate(change models.DatastoreChange) {
+	switch change.Kind {
+	case models.ChangeKindApp:
+		da.cache.Delete(appIDCacheKey(change.ID))
+	case models.ChangeKindFn:
+		da.cache.Delete(fnCacheKey(change.ID))
+	case models.ChangeKindTrigger:
+	default:
+		da.cache.Flush()
+		return
+	}
+
+	// removing apps and fns removes their fns and triggers, and names map to app ids
+	for key, item := range da.cache.Items() {
+		var stale bool
+		switch v := item.Object.(type) {
+		case string:
+			stale = change.Kind == models.ChangeKindApp && v == change.ID
+		case *models.Fn:
+			stale = change.Kind == models.ChangeKindApp && v.AppID == change.ID
+		case *models.Trigger:
+			switch change.Kind {
+			case models.ChangeKindApp:
+				stale = v.AppID == change.ID
+			case models.ChangeKindFn:
+				stale = v.FnID == change.ID
+			case models.ChangeKindTrigger:
+				stale = v.ID == change.ID
+			}
+		}
+		if stale {
+			da.cache.Delete(key)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestCachedDataAccessInvalidate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app := &models.App{ID: "app_id", Name: "app", Config: models.Config{"version": "1"}}
+	fn := &models.Fn{ID: "fn_id", AppID: app.ID, Name: "fn", Image: "fnproject/hello"}
+	trigger := &models.Trigger{ID: "trigger_id", AppID: app.ID, FnID: fn.ID, Name: "trigger", Type: "http", Source: "/hello"}
+	other := &models.App{ID: "other_id", Name: "other"}
+	ds := datastore.NewMockInit([]*models.App{app, other}, []*models.Fn{fn}, []*models.Trigger{trigger})
+
+	da := NewCachedDataAccess(ds)
+	ds.WatchChanges(ctx, da.(CacheInvalidator).Invalidate)
+
+	// fill the cache
+	if _, err := da.GetAppID(ctx, app.Name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := da.GetAppID(ctx, other.Name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := da.GetAppByID(ctx, app.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := da.GetFnByID(ctx, fn.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := da.GetTriggerBySource(ctx, app.ID, trigger.Type, trigger.Source); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ds.UpdateApp(ctx, &models.App{ID: app.ID, Config: models.Config{"version": "2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := da.GetAppByID(ctx, app.ID); err != nil || a.Config["version"] != "2" {
+		t.Fatalf("expected the updated app, got %v %v", a, err)
+	}
+
+	if err := ds.RemoveApp(ctx, app.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := da.GetAppID(ctx, app.Name); err != models.ErrAppsNotFound {
+		t.Errorf("expected the removed app not to be found by name, got %v", err)
+	}
+	if _, err := da.GetFnByID(ctx, fn.ID); err != models.ErrFnsNotFound {
+		t.Errorf("expected the fn of the removed app not to be found, got %v", err)
+	}
+	if _, err := da.GetTriggerBySource(ctx, app.ID, trigger.Type, trigger.Source); err != models.ErrTriggerNotFound {
+		t.Errorf("expected the trigger of the removed app not to be found, got %v", err)
+	}
+
+	// the entries of other apps are kept, until a change without a kind flushes the cache
+	da.(CacheInvalidator).Invalidate(models.DatastoreChange{Kind: models.ChangeKindApp, ID: app.ID})
+	if _, ok := da.(*cachedDataAccess).cache.Get(appNameCacheKey(other.Name)); !ok {
+		t.Errorf("expected the id of the other app to still be cached")
+	}
+	da.(CacheInvalidator).Invalidate(models.DatastoreChange{})
+	if n := da.(*cachedDataAccess).cache.ItemCount(); n != 0 {
+		t.Errorf("expected the cache to be flushed, got %d entries", n)
+	}
+}
@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DatasetSource is where runners sync the read-only datasets declared by fns from, typically an object store.
+// Versions of a dataset must never change once published.
+type DatasetSource interface {
+	// Latest returns the latest version of the dataset name
+	Latest(ctx context.Context, name string) (string, error)
+	// Fetch writes the files of version of the dataset name into dir, which is empty
+	Fetch(ctx context.Context, name, version, dir string) error
+}
+
+// maxDatasetVersionSize is the most read from a dataset's latest version, which is meant to be a short string
+const maxDatasetVersionSize = 1024
+
+// httpDatasetSource fetches datasets laid out under a base URL as name/latest, holding the latest version, and
+// name/version.tar.gz archives of each version. Any object store that serves objects over http will do.
+type httpDatasetSource struct {
+	base   string
+	client *http.Client
+}
+
+// NewHTTPDatasetSource returns a DatasetSource for the datasets under baseURL
+func NewHTTPDatasetSource(baseURL string) DatasetSource {
+	return &httpDatasetSource{base: strings.TrimSuffix(baseURL, "/"), client: http.DefaultClient}
+}
+
+func (s *httpDatasetSource) get(ctx context.Context, path string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, s.base+"/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error fetching dataset %s: %s", path, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (s *httpDatasetSource) Latest(ctx context.Context, name string) (string, error) {
+	body, err := s.get(ctx, name+"/latest")
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(body, maxDatasetVersionSize))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func (s *httpDatasetSource) Fetch(ctx context.Context, name, version, dir string) error {
+	body, err := s.get(ctx, name+"/"+version+".tar.gz")
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		return err
+	}
+	return untar(tar.NewReader(zr), dir)
+}
+
+// untar extracts the directories and regular files of an archive into dir, other entries are skipped
+func untar(tr *tar.Reader, dir string) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in dataset archive: %q", hdr.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			mode := os.FileMode(0644)
+			if hdr.FileInfo().Mode()&0111 != 0 {
+				mode = 0755
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// datasetCurrentFile holds the version of a dataset that containers get unless they pin one
+	datasetCurrentFile = ".current"
+	// datasetFetchPrefix marks the dirs of versions still being fetched, which are renamed once complete
+	datasetFetchPrefix = ".fetch-"
+)
+
+// datasetManager syncs the read-only datasets declared by fns with the FnDatasetsAnnotation from a DatasetSource,
+// keeping each version in a directory under root/name. Versions are fetched into a temp dir and renamed into
+// place, so containers never see part of a version. Each dataset has a current version, which is switched to the
+// latest version of the source once it has been fetched, and which containers that do not pin a version get when
+// they start. Versions other than the current one are removed once no container uses them, and datasets are
+// removed altogether once unused for the idle timeout.
+type datasetManager struct {
+	root         string
+	dockerRoot   string
+	source       DatasetSource
+	syncInterval time.Duration
+	idleTimeout  time.Duration
+	fetchTimeout time.Duration
+
+	lock     sync.Mutex
+	datasets map[string]*dataset
+}
+
+// dataset is a dataset on disk, and the containers using its versions
+type dataset struct {
+	name     string
+	current  string
+	versions map[string]int // containers using each version
+	fetches  map[string]*datasetFetch
+	lastUsed time.Time
+}
+
+// datasetFetch is a version being fetched, that any number of containers may wait for
+type datasetFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// newDatasetManager returns nil if datasets are not configured. Datasets left on disk by a previous run are
+// picked up, and versions that were being fetched are removed.
+func newDatasetManager(cfg *Config, source DatasetSource) (*datasetManager, error) {
+	if cfg.DatasetsPath == "" || source == nil {
+		return nil, nil
+	}
+	m := &datasetManager{
+		root:         cfg.DatasetsPath,
+		dockerRoot:   cfg.DatasetsDockerPath,
+		source:       source,
+		syncInterval: cfg.DatasetSyncInterval,
+		idleTimeout:  cfg.DatasetIdleTimeout,
+		fetchTimeout: cfg.HotPullTimeout,
+		datasets:     make(map[string]*dataset),
+	}
+	if m.dockerRoot == "" {
+		m.dockerRoot = m.root
+	}
+	if err := os.MkdirAll(m.root, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create datasets dir: %v", err)
+	}
+
+	names, err := ioutil.ReadDir(m.root)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read datasets dir: %v", err)
+	}
+	for _, name := range names {
+		if !name.IsDir() {
+			continue
+		}
+		ds := m.dataset(name.Name())
+		ds.lastUsed = name.ModTime()
+		dir := filepath.Join(m.root, ds.name)
+		versions, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read datasets dir: %v", err)
+		}
+		for _, version := range versions {
+			switch {
+			case strings.HasPrefix(version.Name(), datasetFetchPrefix):
+				if err := os.RemoveAll(filepath.Join(dir, version.Name())); err != nil {
+					return nil, fmt.Errorf("cannot remove partial dataset: %v", err)
+				}
+			case version.IsDir():
+				ds.versions[version.Name()] = 0
+			}
+		}
+		current, err := ioutil.ReadFile(filepath.Join(dir, datasetCurrentFile))
+		if err == nil {
+			if _, ok := ds.versions[string(current)]; ok {
+				ds.current = string(current)
+			}
+		}
+	}
+	return m, nil
+}
+
+// dataset returns the dataset name, adding it if needed. m.lock must be held.
+func (m *datasetManager) dataset(name string) *dataset {
+	ds, ok := m.datasets[name]
+	if !ok {
+		ds = &dataset{name: name, versions: make(map[string]int), fetches: make(map[string]*datasetFetch), lastUsed: time.Now()}
+		m.datasets[name] = ds
+	}
+	return ds
+}
+
+// acquire returns the read-only mounts of the datasets the call depends on, fetching them if needed. release must
+// be called once the container using the datasets is gone.
+func (m *datasetManager) acquire(ctx context.Context, call *call) (mounts [][2]string, release func(), err error) {
+	release = func() {}
+	datasets, err := call.Annotations.Datasets()
+	if err != nil || len(datasets) == 0 {
+		return nil, release, err
+	}
+	if m == nil {
+		common.Logger(ctx).Error("fn depends on datasets, but datasets are disabled on this runner")
+		return nil, release, models.ErrDatasetUnavailable
+	}
+
+	var releases []func()
+	release = func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	for _, d := range datasets {
+		version, err := m.use(ctx, d)
+		if err != nil {
+			common.Logger(ctx).WithError(err).WithField("dataset", d.Name).Error("failed to fetch dataset")
+			release()
+			return nil, func() {}, models.ErrDatasetUnavailable
+		}
+		name := d.Name
+		releases = append(releases, func() { m.unuse(name, version) })
+		mounts = append(mounts, [2]string{filepath.Join(m.dockerRoot, name, version), d.Path + ":ro"})
+	}
+	return mounts, release, nil
+}
+
+// latest returns the latest version of the dataset name in the source
+func (m *datasetManager) latest(ctx context.Context, name string) (string, error) {
+	version, err := m.source.Latest(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if !models.ValidFnDatasetVersion(version) {
+		return "", fmt.Errorf("invalid latest version of dataset %s: %q", name, version)
+	}
+	return version, nil
+}
+
+// use marks the version of d a container gets as in use, fetching it first if needed
+func (m *datasetManager) use(ctx context.Context, d models.FnDataset) (string, error) {
+	for {
+		m.lock.Lock()
+		ds := m.dataset(d.Name)
+		version := d.Version
+		if version == "" {
+			version = ds.current
+		}
+		if _, ok := ds.versions[version]; ok && version != "" {
+			ds.versions[version]++
+			ds.lastUsed = time.Now()
+			m.lock.Unlock()
+			return version, nil
+		}
+		m.lock.Unlock()
+
+		if version == "" {
+			latest, err := m.latest(ctx, d.Name)
+			if err != nil {
+				return "", err
+			}
+			version = latest
+		}
+		if err := m.fetch(ctx, d.Name, version); err != nil {
+			return "", err
+		}
+		if d.Version == "" {
+			m.lock.Lock()
+			if ds := m.dataset(d.Name); ds.current == "" {
+				m.setCurrent(ds, version)
+			}
+			m.lock.Unlock()
+		}
+	}
+}
+
+// unuse marks a version of a dataset as no longer in use by a container
+func (m *datasetManager) unuse(name, version string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	ds := m.datasets[name]
+	ds.versions[version]--
+	ds.lastUsed = time.Now()
+}
+
+// setCurrent switches the current version of ds, m.lock must be held
+func (m *datasetManager) setCurrent(ds *dataset, version string) {
+	ds.current = version
+	dir := filepath.Join(m.root, ds.name)
+	f, err := ioutil.TempFile(dir, datasetFetchPrefix)
+	if err == nil {
+		_, err = f.WriteString(version)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), filepath.Join(dir, datasetCurrentFile))
+		}
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("dataset", ds.name).Error("failed to record current dataset version")
+	}
+}
+
+// fetch downloads version of the dataset name, unless it is on disk already. Concurrent fetches of a version share
+// a download, which carries on if ctx is done.
+func (m *datasetManager) fetch(ctx context.Context, name, version string) error {
+	m.lock.Lock()
+	ds := m.dataset(name)
+	if _, ok := ds.versions[version]; ok {
+		m.lock.Unlock()
+		return nil
+	}
+	f, ok := ds.fetches[version]
+	if !ok {
+		f = &datasetFetch{done: make(chan struct{})}
+		ds.fetches[version] = f
+		go m.download(ds, version, f)
+	}
+	m.lock.Unlock()
+
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *datasetManager) download(ds *dataset, version string, f *datasetFetch) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.fetchTimeout)
+	defer cancel()
+
+	dir := filepath.Join(m.root, ds.name)
+	err := os.MkdirAll(dir, 0755)
+	var tmp string
+	if err == nil {
+		tmp, err = ioutil.TempDir(dir, datasetFetchPrefix)
+	}
+	if err == nil {
+		err = m.source.Fetch(ctx, ds.name, version, tmp)
+	}
+	if err == nil {
+		// containers may not run as the user that owns the dataset
+		err = os.Chmod(tmp, 0755)
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(dir, version))
+	}
+	if err != nil && tmp != "" {
+		os.RemoveAll(tmp)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err == nil {
+		ds.versions[version] = 0
+		logrus.WithFields(logrus.Fields{"dataset": ds.name, "version": version}).Info("fetched dataset")
+	}
+	delete(ds.fetches, version)
+	f.err = err
+	close(f.done)
+}
+
+// sync keeps datasets up to date with the source, until done is closed. A zero interval disables syncing.
+func (m *datasetManager) sync(done <-chan struct{}) {
+	if m == nil || m.syncInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(m.syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.syncOnce(done)
+		case <-done:
+			return
+		}
+	}
+}
+
+// syncOnce fetches the latest version of each dataset and makes it current, then removes the versions that are
+// no longer used
+func (m *datasetManager) syncOnce(done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	m.lock.Lock()
+	var names []string
+	for name, ds := range m.datasets {
+		if m.idle(ds) {
+			if err := os.RemoveAll(filepath.Join(m.root, name)); err != nil {
+				logrus.WithError(err).WithField("dataset", name).Error("failed to remove idle dataset")
+				continue
+			}
+			delete(m.datasets, name)
+			logrus.WithField("dataset", name).Debug("removed idle dataset")
+			continue
+		}
+		names = append(names, name)
+	}
+	m.lock.Unlock()
+
+	for _, name := range names {
+		log := logrus.WithField("dataset", name)
+		latest, err := m.latest(ctx, name)
+		if err != nil {
+			log.WithError(err).Error("failed to check latest dataset version")
+			continue
+		}
+		if err := m.fetch(ctx, name, latest); err != nil {
+			log.WithError(err).WithField("version", latest).Error("failed to fetch dataset")
+			continue
+		}
+		m.lock.Lock()
+		if ds := m.dataset(name); ds.current != latest {
+			m.setCurrent(ds, latest)
+			log.WithField("version", latest).Info("switched dataset version")
+		}
+		m.lock.Unlock()
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, ds := range m.datasets {
+		for version, refs := range ds.versions {
+			if refs > 0 || version == ds.current {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(m.root, ds.name, version)); err != nil {
+				logrus.WithError(err).WithFields(logrus.Fields{"dataset": ds.name, "version": version}).Error("failed to remove dataset")
+				continue
+			}
+			delete(ds.versions, version)
+		}
+	}
+}
+
+// idle is whether no container has used ds for the idle timeout, m.lock must be held
+func (m *datasetManager) idle(ds *dataset) bool {
+	if len(ds.fetches) > 0 || time.Since(ds.lastUsed) < m.idleTimeout {
+		return false
+	}
+	for _, refs := range ds.versions {
+		if refs > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// memDatasetSource is a DatasetSource whose versions each hold a single file, named after the dataset
+type memDatasetSource struct {
+	lock    sync.Mutex
+	latest  map[string]string
+	fetches int
+}
+
+func (s *memDatasetSource) Latest(ctx context.Context, name string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.latest[name], nil
+}
+
+func (s *memDatasetSource) Fetch(ctx context.Context, name, version, dir string) error {
+	s.lock.Lock()
+	s.fetches++
+	s.lock.Unlock()
+	return ioutil.WriteFile(filepath.Join(dir, name), []byte(version), 0644)
+}
+
+func TestDatasetManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "datasets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := &memDatasetSource{latest: map[string]string{"bert": "v1"}}
+	cfg := &Config{DatasetsPath: dir, DatasetsDockerPath: "/host/datasets", DatasetIdleTimeout: time.Hour, HotPullTimeout: time.Minute}
+	m, err := newDatasetManager(cfg, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newCall := func(datasets ...models.FnDataset) *call {
+		annotations, err := models.Annotations{}.With(models.FnDatasetsAnnotation, datasets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &call{Call: &models.Call{AppID: "app", Annotations: annotations}}
+	}
+	ctx := context.Background()
+	bert := models.FnDataset{Name: "bert", Path: "/models"}
+
+	// concurrent containers share a fetch
+	var wg sync.WaitGroup
+	releases := make([]func(), 3)
+	for i := range releases {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			mounts, release, err := m.acquire(ctx, newCall(bert))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(mounts) != 1 || mounts[0] != [2]string{"/host/datasets/bert/v1", "/models:ro"} {
+				t.Errorf("unexpected mounts %v", mounts)
+			}
+			releases[i] = release
+		}(i)
+	}
+	wg.Wait()
+	if source.fetches != 1 {
+		t.Fatalf("expected a single fetch, got %d", source.fetches)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "bert", "v1", "bert")); string(b) != "v1" {
+		t.Fatalf("expected dataset to be fetched, got %q", b)
+	}
+
+	// new containers switch to the latest version once it is synced, running containers keep theirs
+	source.latest["bert"] = "v2"
+	mounts, release, err := m.acquire(ctx, newCall(bert))
+	if err != nil || mounts[0][0] != "/host/datasets/bert/v1" {
+		t.Fatalf("expected current version before sync, got %v %v", mounts, err)
+	}
+	m.syncOnce(nil)
+	if _, err := os.Stat(filepath.Join(dir, "bert", "v1")); err != nil {
+		t.Fatalf("expected version in use to be kept, got %v", err)
+	}
+	mounts, release2, err := m.acquire(ctx, newCall(bert))
+	if err != nil || mounts[0][0] != "/host/datasets/bert/v2" {
+		t.Fatalf("expected latest version after sync, got %v %v", mounts, err)
+	}
+
+	// pinned versions are fetched as needed
+	pinned := models.FnDataset{Name: "bert", Path: "/models", Version: "v1"}
+	mounts, release3, err := m.acquire(ctx, newCall(pinned))
+	if err != nil || mounts[0][0] != "/host/datasets/bert/v1" {
+		t.Fatalf("expected pinned version, got %v %v", mounts, err)
+	}
+
+	for _, r := range append(releases, release, release2, release3) {
+		r()
+	}
+	m.syncOnce(nil)
+	if _, err := os.Stat(filepath.Join(dir, "bert", "v1")); !os.IsNotExist(err) {
+		t.Errorf("expected unused version to be removed, got %v", err)
+	}
+
+	// the current version is picked up after a restart
+	m, err = newDatasetManager(cfg, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.datasets["bert"].current != "v2" {
+		t.Errorf("expected current version to be picked up, got %q", m.datasets["bert"].current)
+	}
+
+	m.datasets["bert"].lastUsed = time.Now().Add(-2 * time.Hour)
+	m.syncOnce(nil)
+	if _, err := os.Stat(filepath.Join(dir, "bert")); !os.IsNotExist(err) {
+		t.Errorf("expected idle dataset to be removed, got %v", err)
+	}
+
+	source.latest["gpt"] = "../../etc"
+	if _, _, err := m.acquire(ctx, newCall(models.FnDataset{Name: "gpt", Path: "/models"})); err != models.ErrDatasetUnavailable {
+		t.Errorf("expected invalid version to be rejected, got %v", err)
+	}
+
+	var disabled *datasetManager
+	if _, _, err := disabled.acquire(ctx, newCall(bert)); err != models.ErrDatasetUnavailable {
+		t.Errorf("expected datasets to be unavailable when disabled, got %v", err)
+	}
+}
+
+func TestHTTPDatasetSource(t *testing.T) {
+	archive := func(files map[string]string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(zw)
+		for name, content := range files {
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg})
+			tw.Write([]byte(content))
+		}
+		tw.Close()
+		zw.Close()
+		return buf.Bytes()
+	}
+	objects := map[string][]byte{
+		"/bert/latest":      []byte("v1\n"),
+		"/bert/v1.tar.gz":   archive(map[string]string{"weights/layer0": "0.5"}),
+		"/escape/v1.tar.gz": archive(map[string]string{"../../outside": "x"}),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	source := NewHTTPDatasetSource(srv.URL + "/")
+	if version, err := source.Latest(ctx, "bert"); err != nil || version != "v1" {
+		t.Fatalf("expected latest version v1, got %q %v", version, err)
+	}
+	if _, err := source.Latest(ctx, "missing"); err == nil {
+		t.Error("expected an error for a missing dataset")
+	}
+	if err := source.Fetch(ctx, "bert", "v1", dir); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "weights", "layer0")); string(b) != "0.5" {
+		t.Errorf("expected dataset to be extracted, got %q", b)
+	}
+	if err := source.Fetch(ctx, "escape", "v1", dir); err == nil {
+		t.Error("expected paths outside the dataset to be rejected")
+	}
+}
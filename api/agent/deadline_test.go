@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestCallDeadline(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", AppID: app.ID, Image: "fnproject/fn-test-utils", ResourceConfig: models.ResourceConfig{Timeout: 30}}
+	req := httptest.NewRequest(http.MethodPost, "/invoke/fn_id", nil)
+
+	c := &call{}
+	if err := FromHTTPFnRequest(app, fn, req)(c); err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Time(c.CreatedAt).Add(30 * time.Second); !c.deadline.Equal(expected) {
+		t.Fatalf("expected the deadline of the call to be its timeout from its creation, %v, got %v", expected, c.deadline)
+	}
+
+	ctx, cancel := c.withDeadline(context.Background())
+	deadline, ok := ctx.Deadline()
+	cancel()
+	if !ok || !deadline.Equal(c.deadline) {
+		t.Fatalf("expected the context of a sync call to have its deadline, got %v %v", deadline, ok)
+	}
+	if d := c.deadlineWithin(time.Hour); !d.Equal(c.deadline) {
+		t.Fatalf("expected the deadline of the call, earlier than an hour, got %v", d)
+	}
+	if d := c.deadlineWithin(time.Second); !d.Before(c.deadline) {
+		t.Fatalf("expected a second from now, earlier than the deadline of the call, got %v", d)
+	}
+
+	// detached calls are acked before they run, their budget is that of their placement
+	c.Type = models.TypeDetached
+	ctx, cancel = c.withDeadline(context.Background())
+	_, ok = ctx.Deadline()
+	cancel()
+	if ok {
+		t.Fatal("expected no deadline on the context of a detached call")
+	}
+}
+
+func TestCallDeadlineFromContext(t *testing.T) {
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	script := &mock.Script{Images: map[string]*mock.ImageScript{
+		"fnproject/fn-test-utils": {Status: http.StatusOK, LatencyMsecs: 2000},
+	}}
+	a := New(WithConfig(cfg), WithDockerDriver(mock.NewScripted(script)))
+	defer checkClose(t, a)
+
+	// the deadline of the context of a call, as runners get it from lbs, is shorter than the timeout of its fn
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	cm := createModelCall("TestCallDeadlineFromContext")
+	callI, err := a.GetCall(FromModelAndInput(cm, ioutil.NopCloser(strings.NewReader("hello"))),
+		WithWriter(httptest.NewRecorder()), WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	err = a.Submit(callI)
+	if err != models.ErrCallTimeout && err != models.ErrCallTimeoutServerBusy {
+		t.Fatalf("expected the call to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Fatalf("expected the call to time out at the deadline of its context, took %v", elapsed)
+	}
+	if c := callI.(*call); !c.deadline.Equal(deadline) {
+		t.Fatalf("expected the call to take the deadline of its context, %v, got %v", deadline, c.deadline)
+	}
+}
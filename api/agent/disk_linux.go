@@ -0,0 +1,14 @@
+package agent
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// diskFree returns the space available to unprivileged users on the filesystem of path, in bytes
+func diskFree(path string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package agent
+
+import (
+	"errors"
+)
+
+func diskFree(path string) (uint64, error) {
+	return 0, errors.New("disk space checks not supported on this OS")
+}
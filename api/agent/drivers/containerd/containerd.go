@@ -0,0 +1,378 @@
+package containerd
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/fnproject/fn/api/agent/drivers"
+	fndocker "github.com/fnproject/fn/api/agent/drivers/docker"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// FnAgentClassifierLabel and FnAgentInstanceLabel label containers as those of the docker driver do
+	FnAgentClassifierLabel = fndocker.FnAgentClassifierLabel
+	FnAgentInstanceLabel   = fndocker.FnAgentInstanceLabel
+)
+
+// ContainerdDriver implements drivers.Driver via the containerd API
+type ContainerdDriver struct {
+	cancel      func()
+	conf        drivers.Config
+	client      *containerd.Client
+	namespace   string
+	snapshotter string
+	hostname    string
+	auths       fndocker.RegistryAuths
+	netns       []string
+	warm        *warmSnapshots
+
+	instanceId string
+
+	// backoff/retry settings of pulls
+	retryLock   sync.Mutex
+	isRetriable drivers.RetryErrorChecker
+	backOffCfg  common.BackOffConfig
+
+	// gaps are the features of the docker driver missing here that were logged
+	gaps sync.Map
+}
+
+// NewContainerd implements drivers.Driver, connecting to the containerd of conf.Containerd
+func NewContainerd(conf drivers.Config) (*ContainerdDriver, error) {
+	if conf.ImageTrustPolicy != "" || conf.RequireSignedImages {
+		return nil, errors.New("the containerd driver does not verify the signatures of images, unset the image trust policy")
+	}
+	if conf.PreForkPoolSize != 0 {
+		return nil, errors.New("the containerd driver has no prefork pool, unset its size")
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't resolve hostname: %v", err)
+	}
+
+	// This is for testing purposes. Tests override with custom id
+	instanceId := conf.InstanceId
+	if instanceId == "" {
+		instanceId, err = generateRandUUID()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't initialize instanceId: %v", err)
+		}
+	}
+
+	auths, err := fndocker.RegistryAuthsFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't initialize registry: %v", err)
+	}
+
+	client, err := containerd.New(conf.Containerd, containerd.WithDefaultNamespace(conf.ContainerdNamespace))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't connect to containerd at %s: %v", conf.Containerd, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	driver := &ContainerdDriver{
+		cancel:      cancel,
+		conf:        conf,
+		client:      client,
+		namespace:   conf.ContainerdNamespace,
+		snapshotter: conf.ContainerdSnapshotter,
+		hostname:    hostname,
+		auths:       auths,
+		netns:       strings.Fields(conf.ContainerdNetNS),
+		instanceId:  instanceId,
+		isRetriable: func(error) (bool, string) { return false, "" },
+	}
+
+	version, err := client.Version(driver.ctx(ctx))
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("couldn't reach containerd at %s: %v", conf.Containerd, err)
+	}
+	logrus.WithFields(logrus.Fields{"version": version.Version, "namespace": driver.namespace,
+		"snapshotter": driver.snapshotter}).Info("containerd driver connected")
+
+	if conf.DisableImagePulls {
+		logrus.Info("containerd driver in offline mode, only images loaded in its namespace run")
+	}
+	if conf.ImageCleanMaxSize != 0 {
+		driver.gap("image cleaner", "images are not evicted, prune the namespace of the driver")
+	}
+
+	go killLeakedContainers(ctx, driver)
+
+	if conf.ContainerdWarmSnapshots != 0 {
+		driver.warm = newWarmSnapshots(driver.ctx(ctx), client.SnapshotService(driver.snapshotter),
+			conf.ContainerdWarmSnapshots, conf.ContainerLabelTag, instanceId)
+		go driver.warm.removeLeaked(driver.ctx(ctx))
+	}
+
+	// before we do anything else, let's pre-load requested images
+	if conf.DockerLoadFile != "" {
+		logrus.Infof("Loading images from %v", conf.DockerLoadFile)
+		if err := driver.LoadImages(ctx, conf.DockerLoadFile); err != nil {
+			driver.Close()
+			return nil, fmt.Errorf("cannot load images in %s: %v", conf.DockerLoadFile, err)
+		}
+	}
+
+	return driver, nil
+}
+
+// ctx scopes ctx to the containerd namespace of the driver
+func (drv *ContainerdDriver) ctx(ctx context.Context) context.Context {
+	return namespaces.WithNamespace(ctx, drv.namespace)
+}
+
+// gap logs, once per feature, that a feature of the docker driver is missing here
+func (drv *ContainerdDriver) gap(feature, effect string) {
+	if _, logged := drv.gaps.LoadOrStore(feature, true); !logged {
+		logrus.WithFields(logrus.Fields{"feature": feature}).Warnf("containerd driver, %s", effect)
+	}
+}
+
+// killLeakedContainers removes the containers labeled as those of the agent that an earlier agent left behind. It is
+// executed once, and if it fails it does not retry.
+func killLeakedContainers(ctx context.Context, driver *ContainerdDriver) {
+	// Label Tag is used to isolate this cleanup, as for the docker driver
+	if driver.conf.ContainerLabelTag == "" {
+		return
+	}
+
+	ctx, log := common.LoggerWithFields(driver.ctx(ctx), logrus.Fields{"stack": "killLeakedContainers"})
+	limiter := rate.NewLimiter(2.0, 1)
+
+	filter := fmt.Sprintf("labels.%q==%s", FnAgentClassifierLabel, driver.conf.ContainerLabelTag)
+	var containers []containerd.Container
+
+	for limiter.Wait(ctx) == nil {
+		var err error
+		containers, err = driver.client.Containers(ctx, filter)
+		if err == nil {
+			break
+		}
+
+		log.WithError(err).Error("Containers error, will retry...")
+	}
+
+	for _, item := range containers {
+		labels, err := item.Labels(ctx)
+		// skip containers that belong to our current running agent
+		if err != nil || labels[FnAgentInstanceLabel] == driver.instanceId {
+			continue
+		}
+
+		logger := logrus.WithFields(logrus.Fields{"container_id": item.ID()})
+		logger.Info("Terminating dangling containerd container")
+
+		// If this fails, we log and continue.
+		if err := removeContainer(ctx, item); err != nil {
+			logger.WithError(err).Error("cannot remove container")
+		}
+	}
+}
+
+// removeContainer kills the task of the container, if it has one, and removes the container and its snapshot
+func removeContainer(ctx context.Context, container containerd.Container) error {
+	if task, err := container.Task(ctx, nil); err == nil {
+		if _, err := task.Delete(ctx, containerd.WithProcessKill); err != nil {
+			return err
+		}
+	}
+	return container.Delete(ctx, containerd.WithSnapshotCleanup)
+}
+
+// LoadImages implements drivers.ImageLoader, importing the images of a docker save or OCI tarball and unpacking them
+func (drv *ContainerdDriver) LoadImages(ctx context.Context, archive string) error {
+	ctx = drv.ctx(ctx)
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	imgs, err := drv.client.Import(ctx, f)
+	if err != nil {
+		return err
+	}
+	for _, img := range imgs {
+		if err := containerd.NewImage(drv.client, img).Unpack(ctx, drv.snapshotter); err != nil {
+			return fmt.Errorf("cannot unpack image %s: %v", img.Name, err)
+		}
+	}
+	return nil
+}
+
+// PrePullImage implements drivers.ImagePrePuller, pulling image with the credentials of its registry
+func (drv *ContainerdDriver) PrePullImage(ctx context.Context, image string) error {
+	if drv.conf.DisableImagePulls {
+		return nil
+	}
+	ref, err := normalizeImage(image)
+	if err != nil {
+		return err
+	}
+	reg, _, _ := drivers.ParseImage(image)
+	return drv.pullImage(ctx, image, ref, drv.auths.Find(reg))
+}
+
+var _ drivers.ImageLoader = &ContainerdDriver{}
+var _ drivers.ImagePrePuller = &ContainerdDriver{}
+
+func (drv *ContainerdDriver) Close() error {
+	if drv.warm != nil {
+		ctx, cancel := context.WithTimeout(drv.ctx(context.Background()), 10*time.Second)
+		drv.warm.close(ctx)
+		cancel()
+	}
+	if drv.cancel != nil {
+		drv.cancel()
+	}
+	return drv.client.Close()
+}
+
+func (drv *ContainerdDriver) SetPullImageRetryPolicy(policy common.BackOffConfig, checker drivers.RetryErrorChecker) error {
+	drv.retryLock.Lock()
+	defer drv.retryLock.Unlock()
+	drv.isRetriable = checker
+	drv.backOffCfg = policy
+	return nil
+}
+
+func (drv *ContainerdDriver) GetSlotKeyExtensions(extn map[string]string) string {
+	return ""
+}
+
+// pullImage pulls and unpacks the image ref, named image by its fn, retrying as the retry policy of the driver allows
+func (drv *ContainerdDriver) pullImage(ctx context.Context, image, ref string, auth *dockerclient.AuthConfiguration) error {
+	ctx = drv.ctx(ctx)
+	log := common.Logger(ctx).WithFields(logrus.Fields{"image": image, "username": auth.Username})
+	log.Debug("containerd pull")
+
+	drv.retryLock.Lock()
+	isRetriable, backOffCfg := drv.isRetriable, drv.backOffCfg
+	drv.retryLock.Unlock()
+
+	resolver := docker.NewResolver(docker.ResolverOptions{
+		Credentials: func(host string) (string, string, error) {
+			if auth.IdentityToken != "" {
+				return "", auth.IdentityToken, nil
+			}
+			return auth.Username, auth.Password, nil
+		},
+	})
+
+	backoff := common.NewBackOff(backOffCfg)
+	timer := common.NewTimer(time.Duration(backOffCfg.MinDelay) * time.Millisecond)
+	defer timer.Stop()
+
+	for {
+		_, err := drv.client.Pull(ctx, ref, containerd.WithPullUnpack, containerd.WithPullSnapshotter(drv.snapshotter),
+			containerd.WithResolver(resolver))
+		if err == nil {
+			return nil
+		}
+
+		ok, _ := isRetriable(err)
+		var delay time.Duration
+		if ok {
+			delay, ok = backoff.NextBackOff()
+		}
+		if !ok {
+			log.WithError(err).Info("Failed to pull image")
+			return models.NewFuncError(models.NewAPIError(http.StatusBadGateway,
+				fmt.Errorf("Failed to pull image '%s': %v", image, err)))
+		}
+
+		timer.Reset(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (drv *ContainerdDriver) CreateCookie(ctx context.Context, task drivers.ContainerTask) (drivers.Cookie, error) {
+	ctx, log := common.LoggerWithFields(ctx, logrus.Fields{"stack": "CreateCookie"})
+
+	if len(task.DaemonLabels()) != 0 {
+		return nil, fndocker.ErrNoDockerDaemon
+	}
+
+	ref, err := normalizeImage(task.Image())
+	if err != nil {
+		return nil, models.NewAPIError(http.StatusBadRequest, fmt.Errorf("invalid image '%s': %v", task.Image(), err))
+	}
+
+	cookie := &cookie{
+		task: task,
+		drv:  drv,
+		ref:  ref,
+	}
+
+	cookie.configureLabels(log)
+	cookie.configureLogger(log)
+	cookie.configureMem(log)
+	cookie.configureStop(log)
+	cookie.configureCmd(log)
+	cookie.configureEnv(log)
+	cookie.configureCPU(log)
+	cookie.configureGPUs(log)
+	cookie.configureFsSize(log)
+	cookie.configurePIDs(log)
+	cookie.configureULimits(log)
+	cookie.configureRootFs(log)
+	cookie.configureTmpFs(log)
+	cookie.configureVolumes(log)
+	cookie.configureWorkDir(log)
+	cookie.configureIOFS(log)
+	if err := cookie.configureNetwork(log); err != nil {
+		return nil, err
+	}
+	cookie.configureHostname(log)
+	cookie.configureImage(log)
+	if err := cookie.configureSecurity(log); err != nil {
+		return nil, err
+	}
+
+	return cookie, nil
+}
+
+var _ drivers.Driver = &ContainerdDriver{}
+
+func init() {
+	drivers.Register("containerd", func(config drivers.Config) (drivers.Driver, error) {
+		driver, err := NewContainerd(config)
+		if err != nil {
+			return nil, err
+		}
+		return driver, nil
+	})
+}
+
+func generateRandUUID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	uuid := fmt.Sprintf("%x%x%x%x%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+
+	return uuid, nil
+}
@@ -0,0 +1,730 @@
+package containerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/contrib/nvidia"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/oci"
+	"github.com/docker/distribution/reference"
+	"github.com/fnproject/fn/api/agent/drivers"
+	fndocker "github.com/fnproject/fn/api/agent/drivers/docker"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	dockerclient "github.com/fsouza/go-dockerclient"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+	"go.opencensus.io/trace"
+)
+
+const (
+	// netnsDir is where `ip netns` keeps the network namespaces containers may join
+	netnsDir = "/var/run/netns"
+)
+
+// ErrNoNetwork is returned for containers allowed on networks the driver does not have
+var ErrNoNetwork = models.NewAPIError(http.StatusBadRequest, errors.New("none of the networks allowed for the fn are network namespaces of the runner"))
+
+// A cookie identifies a unique request to run a task.
+type cookie struct {
+	// task associated with this cookie
+	task drivers.ContainerTask
+	// pointer to containerd driver
+	drv *ContainerdDriver
+
+	// ref is the fully qualified reference of the image of the task, as containerd names images
+	ref    string
+	imgReg string
+
+	// opts are the spec options of the container created by Driver.CreateCookie, applied over the config of the image
+	opts   []oci.SpecOpts
+	labels map[string]string
+	// cmd replaces the cmd of the image if set
+	cmd []string
+	// stopSignal asks the container to exit when it is closed, it is killed once stopTimeout passes
+	stopSignal syscall.Signal
+
+	// contains the image if ValidateImage() found it
+	image containerd.Image
+	// true once PullImage() pulled the image, images of fns that always pull are pulled once for each container
+	pulled bool
+	// contract version negotiated with the image by ValidateImage()
+	contractVersion int
+
+	// contains created container if CreateContainer() is called
+	container containerd.Container
+	// contains the task of the container once Run() started it
+	ctrTask containerd.Task
+	// true while the container is paused by Freeze()
+	frozen bool
+	// true while the memory soft limit of the container is squeezed by Freeze()
+	squeezed bool
+}
+
+// normalizeImage returns the fully qualified reference of image, eg. docker.io/library/busybox:latest for busybox
+func normalizeImage(image string) (string, error) {
+	named, err := reference.ParseDockerRef(image)
+	if err != nil {
+		return "", err
+	}
+	return named.String(), nil
+}
+
+func (c *cookie) configureImage(log logrus.FieldLogger) {
+	c.imgReg, _, _ = drivers.ParseImage(c.task.Image())
+}
+
+func (c *cookie) configureLabels(log logrus.FieldLogger) {
+	if c.drv.conf.ContainerLabelTag == "" {
+		return
+	}
+
+	c.labels = map[string]string{
+		FnAgentClassifierLabel: c.drv.conf.ContainerLabelTag,
+		FnAgentInstanceLabel:   c.drv.instanceId,
+	}
+}
+
+func (c *cookie) configureLogger(log logrus.FieldLogger) {
+	if c.task.LoggerConfig().URL != "" {
+		c.drv.gap("syslog", "the output of containers is not sent to syslog")
+	}
+}
+
+func (c *cookie) configureMem(log logrus.FieldLogger) {
+	if c.task.Memory() == 0 {
+		return
+	}
+
+	c.opts = append(c.opts, withMemory(c.task.Memory()))
+}
+
+func (c *cookie) configureStop(log logrus.FieldLogger) {
+	c.stopSignal = syscall.SIGTERM
+	if c.task.StopTimeout() <= 0 || c.task.StopSignal() == "" {
+		return
+	}
+
+	sig, err := containerd.ParseSignal(c.task.StopSignal())
+	if err != nil {
+		log.WithError(err).WithFields(logrus.Fields{"stop_signal": c.task.StopSignal(), "call_id": c.task.Id()}).Warn("invalid stop signal, using SIGTERM")
+		return
+	}
+	c.stopSignal = sig
+}
+
+func (c *cookie) configureFsSize(log logrus.FieldLogger) {
+	if c.task.FsSize() == 0 {
+		return
+	}
+	c.drv.gap("fs size", "the disk size of containers is not limited")
+}
+
+func (c *cookie) configurePIDs(log logrus.FieldLogger) {
+	pids := c.task.PIDs()
+	if pids == 0 {
+		return
+	}
+
+	log.WithFields(logrus.Fields{"pids": pids, "call_id": c.task.Id()}).Debug("setting PIDs")
+	c.opts = append(c.opts, withPIDs(int64(pids)))
+}
+
+func (c *cookie) configureULimits(log logrus.FieldLogger) {
+	c.configureULimit("RLIMIT_NOFILE", c.task.OpenFiles(), log)
+	c.configureULimit("RLIMIT_MEMLOCK", c.task.LockedMemory(), log)
+	c.configureULimit("RLIMIT_SIGPENDING", c.task.PendingSignals(), log)
+	c.configureULimit("RLIMIT_MSGQUEUE", c.task.MessageQueue(), log)
+}
+
+func (c *cookie) configureULimit(name string, value *uint64, log logrus.FieldLogger) {
+	if value == nil {
+		return
+	}
+
+	log.WithFields(logrus.Fields{"call_id": c.task.Id(), "ulimitName": name, "ulimitValue": *value}).Debugf("setting ulimit %s", name)
+	c.opts = append(c.opts, withRlimit(name, *value))
+}
+
+func (c *cookie) configureRootFs(log logrus.FieldLogger) {
+	if c.drv.conf.EnableReadOnlyRootFs {
+		c.opts = append(c.opts, oci.WithRootFSReadonly())
+	}
+}
+
+func (c *cookie) configureTmpFs(log logrus.FieldLogger) {
+	// if RO Root is NOT enabled and TmpFsSize does not have any limit, then we do not need
+	// any tmpfs in the container since function can freely write whereever it wants.
+	if c.task.TmpFsSize() == 0 && !c.drv.conf.EnableReadOnlyRootFs {
+		return
+	}
+
+	var options []string
+	if c.task.TmpFsSize() != 0 {
+		options = append(options, fmt.Sprintf("size=%dm", c.task.TmpFsSize()))
+		if c.drv.conf.MaxTmpFsInodes != 0 {
+			options = append(options, fmt.Sprintf("nr_inodes=%d", c.drv.conf.MaxTmpFsInodes))
+		}
+	}
+
+	log.WithFields(logrus.Fields{"target": "/tmp", "options": options, "call_id": c.task.Id()}).Debug("setting tmpfs")
+	c.opts = append(c.opts, withTmpfs("/tmp", options...))
+}
+
+func (c *cookie) configureIOFS(log logrus.FieldLogger) {
+	path := c.task.UDSDockerPath()
+	if path == "" {
+		return
+	}
+
+	log.WithFields(logrus.Fields{"bind": path, "dest": c.task.UDSDockerDest(), "call_id": c.task.Id()}).Debug("setting bind")
+	c.opts = append(c.opts, withBind(path, c.task.UDSDockerDest()))
+}
+
+func (c *cookie) configureVolumes(log logrus.FieldLogger) {
+	for _, mapping := range c.task.Volumes() {
+		log.WithFields(logrus.Fields{"volumes": mapping, "call_id": c.task.Id()}).Debug("setting volumes")
+		c.opts = append(c.opts, withBind(mapping[0], mapping[1]))
+	}
+}
+
+func (c *cookie) configureCPU(log logrus.FieldLogger) {
+	// Translate milli cpus into a CFS quota and period, as the docker driver does
+	if c.task.CPUs() == 0 {
+		return
+	}
+
+	quota := int64(c.task.CPUs() * 100)
+	period := uint64(100000)
+
+	log.WithFields(logrus.Fields{"quota": quota, "period": period, "call_id": c.task.Id()}).Debug("setting CPU")
+	c.opts = append(c.opts, withCPUQuota(quota, period))
+}
+
+// configureGPUs gives the container its GPUs through the prestart hook of the nvidia container toolkit
+func (c *cookie) configureGPUs(log logrus.FieldLogger) {
+	gpus := c.task.GPUs()
+	if len(gpus) == 0 {
+		return
+	}
+
+	log.WithFields(logrus.Fields{"gpus": gpus, "call_id": c.task.Id()}).Debug("setting GPUs")
+	c.opts = append(c.opts, nvidia.WithGPUs(nvidia.WithDeviceUUIDs(gpus...), nvidia.WithCapabilities(nvidia.Compute, nvidia.Utility)))
+}
+
+func (c *cookie) configureWorkDir(log logrus.FieldLogger) {
+	wd := c.task.WorkDir()
+	if wd == "" {
+		return
+	}
+
+	log.WithFields(logrus.Fields{"wd": wd, "call_id": c.task.Id()}).Debug("setting work dir")
+	c.opts = append(c.opts, oci.WithProcessCwd(wd))
+}
+
+// configureNetwork gives containers without network a namespace of their own, with only a loopback interface. Other
+// containers join a network namespace of the driver, or that of the host if the driver has none.
+func (c *cookie) configureNetwork(log logrus.FieldLogger) error {
+	if c.task.DisableNet() {
+		return nil
+	}
+
+	allowed := c.task.Networks()
+	if len(c.drv.netns) == 0 && len(allowed) == 0 {
+		c.opts = append(c.opts, oci.WithHostNamespace(specs.NetworkNamespace), oci.WithHostHostsFile, oci.WithHostResolvconf)
+		return nil
+	}
+
+	for _, name := range c.drv.netns {
+		if isAllowedNetwork(allowed, name) {
+			log.WithFields(logrus.Fields{"netns": name, "call_id": c.task.Id()}).Debug("setting network")
+			c.opts = append(c.opts, oci.WithLinuxNamespace(specs.LinuxNamespace{Type: specs.NetworkNamespace, Path: filepath.Join(netnsDir, name)}),
+				oci.WithHostHostsFile, oci.WithHostResolvconf)
+			return nil
+		}
+	}
+	return ErrNoNetwork
+}
+
+func isAllowedNetwork(allowed []string, name string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cookie) configureHostname(log logrus.FieldLogger) {
+	log.WithFields(logrus.Fields{"hostname": c.drv.hostname, "call_id": c.task.Id()}).Debug("setting hostname")
+	c.opts = append(c.opts, oci.WithHostname(c.drv.hostname))
+}
+
+func (c *cookie) configureCmd(log logrus.FieldLogger) {
+	if c.task.Command() == "" {
+		return
+	}
+
+	c.cmd = strings.Fields(c.task.Command())
+	log.WithFields(logrus.Fields{"call_id": c.task.Id(), "cmd": c.cmd, "len": len(c.cmd)}).Debug("containerd command")
+}
+
+func (c *cookie) configureEnv(log logrus.FieldLogger) {
+	if len(c.task.EnvVars()) == 0 {
+		return
+	}
+
+	env := make([]string, 0, len(c.task.EnvVars()))
+	for name, val := range c.task.EnvVars() {
+		env = append(env, name+"="+val)
+	}
+	c.opts = append(c.opts, oci.WithEnv(env))
+}
+
+// configureSecurity runs the container unprivileged, unless the driver allows privileged containers, and with the
+// seccomp and AppArmor profiles of its task or the defaults of the driver
+func (c *cookie) configureSecurity(log logrus.FieldLogger) error {
+	if !c.drv.conf.DisableUnprivilegedContainers {
+		c.opts = append(c.opts, oci.WithUIDGID(fndocker.FnUserId, fndocker.FnGroupId), oci.WithCapabilities(nil), oci.WithNoNewPrivileges)
+	} else {
+		// the default spec of containerd sets no-new-privileges, docker does not
+		c.opts = append(c.opts, oci.WithNewPrivileges)
+	}
+
+	seccomp, err := c.drv.seccompProfile(c.task.SeccompProfile())
+	if err != nil {
+		return err
+	}
+	c.opts = append(c.opts, seccomp)
+	apparmor, err := c.drv.appArmorProfile(c.task.AppArmorProfile())
+	if err != nil {
+		return err
+	}
+	if apparmor != "" {
+		c.opts = append(c.opts, oci.WithApparmorProfile(apparmor))
+	}
+
+	log.WithFields(logrus.Fields{"unprivileged": !c.drv.conf.DisableUnprivilegedContainers, "seccomp": c.task.SeccompProfile(),
+		"apparmor": apparmor, "call_id": c.task.Id()}).Debug("setting security")
+	return nil
+}
+
+// implements Cookie
+func (c *cookie) Close(ctx context.Context) error {
+	ctx = c.drv.ctx(ctx)
+	log := common.Logger(ctx).WithFields(logrus.Fields{"stack": "Close", "call_id": c.task.Id()})
+
+	if c.ctrTask != nil {
+		// a paused container cannot handle signals
+		if c.frozen {
+			c.unpause(ctx)
+		}
+		c.stop(ctx)
+		if _, err := c.ctrTask.Delete(ctx, containerd.WithProcessKill); err != nil && !errdefs.IsNotFound(err) {
+			log.WithError(err).Error("error removing task")
+		}
+	}
+
+	var err error
+	if c.container != nil {
+		err = c.container.Delete(ctx, containerd.WithSnapshotCleanup)
+		if err != nil {
+			log.WithError(err).Error("error removing container")
+		}
+	}
+	return err
+}
+
+// stop asks the container to exit with its stop signal and waits up to its stop timeout for it to, so that it may
+// shut down cleanly before it is killed
+func (c *cookie) stop(ctx context.Context) {
+	timeout := c.task.StopTimeout()
+	if timeout <= 0 {
+		return
+	}
+	log := common.Logger(ctx).WithFields(logrus.Fields{"stack": "Stop", "call_id": c.task.Id()})
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	exited, err := c.ctrTask.Wait(ctx)
+	if err == nil {
+		err = c.ctrTask.Kill(ctx, c.stopSignal)
+	}
+	if err != nil {
+		// most likely it has exited already
+		log.WithError(err).Debug("error stopping container")
+		return
+	}
+	select {
+	case <-exited:
+	case <-ctx.Done():
+	}
+}
+
+// implements Cookie
+func (c *cookie) Run(ctx context.Context) (drivers.WaitResult, error) {
+	ctx = c.drv.ctx(ctx)
+	log := common.Logger(ctx)
+
+	stdout, stderr := c.task.Logger()
+	var stdin io.Reader
+	if _, stdinOff := c.task.Input().(common.NoopReadWriteCloser); !stdinOff {
+		stdin = c.task.Input()
+	}
+	if _, stdoutOff := stdout.(common.NoopReadWriteCloser); stdoutOff {
+		stdout = nil
+	}
+	if _, stderrOff := stderr.(common.NoopReadWriteCloser); stderrOff {
+		stderr = nil
+	}
+
+	task, err := c.container.NewTask(ctx, cio.NewCreator(cio.WithStreams(stdin, stdout, stderr)))
+	if err != nil {
+		log.WithError(err).WithFields(logrus.Fields{"call_id": c.task.Id()}).Error("error creating task")
+		return nil, err
+	}
+	c.ctrTask = task
+
+	// wait before starting, so that the exit of a container exiting right away is not missed
+	exited, err := task.Wait(ctx)
+	if err == nil {
+		err = task.Start(ctx)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.WithError(err).WithFields(logrus.Fields{"call_id": c.task.Id()}).Error("error starting container")
+		return nil, err
+	}
+
+	return &waitResult{task: task, exited: exited}, nil
+}
+
+// implements Cookie
+func (c *cookie) ContainerOptions() interface{} {
+	return c.opts
+}
+
+// implements Cookie
+func (c *cookie) Freeze(ctx context.Context) error {
+	ctx, log := common.LoggerWithFields(c.drv.ctx(ctx), logrus.Fields{"stack": "Freeze"})
+	log.WithFields(logrus.Fields{"call_id": c.task.Id()}).Debug("containerd pause")
+
+	err := c.ctrTask.Pause(ctx)
+	if err != nil {
+		log.WithError(err).WithFields(logrus.Fields{"call_id": c.task.Id()}).Error("error pausing container")
+		return err
+	}
+	c.frozen = true
+	c.squeezeMem(ctx, log)
+	return nil
+}
+
+// implements Cookie
+func (c *cookie) Unfreeze(ctx context.Context) error {
+	ctx, log := common.LoggerWithFields(c.drv.ctx(ctx), logrus.Fields{"stack": "Unfreeze"})
+	log.WithFields(logrus.Fields{"call_id": c.task.Id()}).Debug("containerd resume")
+
+	c.restoreMem(ctx, log)
+
+	err := c.unpause(ctx)
+	if err != nil {
+		log.WithError(err).WithFields(logrus.Fields{"call_id": c.task.Id()}).Error("error resuming container")
+	}
+	return err
+}
+
+func (c *cookie) unpause(ctx context.Context) error {
+	err := c.ctrTask.Resume(ctx)
+	if err == nil {
+		c.frozen = false
+	}
+	return err
+}
+
+// squeezeMem lowers the memory soft limit of a frozen container to FreezeMemoryPercent of its memory, as the docker
+// driver does. Failing to is not fatal, the container just keeps its memory.
+func (c *cookie) squeezeMem(ctx context.Context, log logrus.FieldLogger) {
+	pct := c.drv.conf.FreezeMemoryPercent
+	if pct == 0 || pct >= 100 || c.task.Memory() == 0 {
+		return
+	}
+
+	if err := c.reserveMem(ctx, c.task.Memory()*pct/100); err != nil {
+		log.WithError(err).WithFields(logrus.Fields{"call_id": c.task.Id()}).Error("error squeezing memory of container")
+		return
+	}
+	c.squeezed = true
+}
+
+// restoreMem undoes squeezeMem, a soft limit of all the memory of the container being no limit
+func (c *cookie) restoreMem(ctx context.Context, log logrus.FieldLogger) {
+	if !c.squeezed {
+		return
+	}
+
+	if err := c.reserveMem(ctx, c.task.Memory()); err != nil {
+		log.WithError(err).WithFields(logrus.Fields{"call_id": c.task.Id()}).Error("error restoring memory of container")
+		return
+	}
+	c.squeezed = false
+}
+
+func (c *cookie) reserveMem(ctx context.Context, reservation uint64) error {
+	r := int64(reservation)
+	return c.ctrTask.Update(ctx, containerd.WithResources(&specs.LinuxResources{Memory: &specs.LinuxMemory{Reservation: &r}}))
+}
+
+// auth returns the credentials to pull the image of the task with, those the task gives if it is a docker.Auther
+func (c *cookie) auth(ctx context.Context) (*dockerclient.AuthConfiguration, error) {
+	config := c.drv.auths.Find(c.imgReg)
+
+	if task, ok := c.task.(fndocker.Auther); ok {
+		_, span := trace.StartSpan(ctx, "containerd_auth")
+		authConfig, err := task.DockerAuth(ctx, c.task.Image())
+		span.End()
+		if err != nil {
+			return nil, err
+		}
+		if authConfig != nil {
+			config = authConfig
+		}
+	}
+	return config, nil
+}
+
+// implements Cookie
+func (c *cookie) ValidateImage(ctx context.Context) (bool, error) {
+	ctx, log := common.LoggerWithFields(c.drv.ctx(ctx), logrus.Fields{"stack": "ValidateImage"})
+	log.WithFields(logrus.Fields{"call_id": c.task.Id(), "image": c.task.Image()}).Debug("containerd get image")
+
+	if c.image != nil {
+		return false, nil
+	}
+
+	policy := c.task.ImagePullPolicy()
+	if policy == models.ImagePullAlways && !c.pulled && !c.drv.conf.DisableImagePulls {
+		return true, nil
+	}
+
+	img, err := c.drv.client.GetImage(ctx, c.ref)
+	if errdefs.IsNotFound(err) {
+		if policy == models.ImagePullNever {
+			return false, errImageNeverPulled(c.task.Image())
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// images imported without being unpacked, eg. by ctr, are unpacked here rather than pulled again
+	unpacked, err := img.IsUnpacked(ctx, c.drv.snapshotter)
+	if err == nil && !unpacked {
+		err = img.Unpack(ctx, c.drv.snapshotter)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	config, err := imageConfig(ctx, img)
+	if err != nil {
+		return false, err
+	}
+
+	// check image doesn't have Volumes
+	if !c.drv.conf.ImageEnableVolume && len(config.Volumes) > 0 {
+		return false, fndocker.ErrImageWithVolume
+	}
+
+	version, err := drivers.NegotiateContract(config.Labels)
+	if err != nil {
+		log.WithError(err).WithField("image", c.task.Image()).Info("image speaks no supported contract version")
+		return false, err
+	}
+	c.contractVersion = version
+	c.image = img
+	return false, nil
+}
+
+// imageConfig reads the config of img from the content store
+func imageConfig(ctx context.Context, img containerd.Image) (*ocispec.ImageConfig, error) {
+	desc, err := img.Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := content.ReadBlob(ctx, img.ContentStore(), desc)
+	if err != nil {
+		return nil, err
+	}
+	var image ocispec.Image
+	if err := json.Unmarshal(raw, &image); err != nil {
+		return nil, err
+	}
+	return &image.Config, nil
+}
+
+// implements Cookie
+func (c *cookie) PullImage(ctx context.Context) error {
+	ctx, log := common.LoggerWithFields(ctx, logrus.Fields{"stack": "PullImage"})
+	if c.image != nil {
+		return nil
+	}
+	if c.drv.conf.DisableImagePulls {
+		log.WithFields(logrus.Fields{"call_id": c.task.Id(), "image": c.task.Image()}).Error("image not loaded, and image pulls are disabled")
+		return models.NewCodedError(http.StatusBadGateway, "image_pulls_disabled", map[string]string{"image": c.task.Image()},
+			fmt.Errorf("Image '%s' is not loaded on the runner, and image pulls are disabled", c.task.Image()))
+	}
+	if c.task.ImagePullPolicy() == models.ImagePullNever {
+		return errImageNeverPulled(c.task.Image())
+	}
+
+	cfg, err := c.auth(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.drv.pullImage(ctx, c.task.Image(), c.ref, cfg)
+	c.pulled = err == nil
+	return err
+}
+
+// errImageNeverPulled is returned for running a fn whose pull policy is models.ImagePullNever on a runner without its
+// image, the image must be pre-pulled or loaded on the runner first
+func errImageNeverPulled(image string) error {
+	return models.NewCodedError(http.StatusBadGateway, "image_never_pulled", map[string]string{"image": image},
+		fmt.Errorf("Image '%s' is not present on the runner, and the pull policy of the fn is %s", image, models.ImagePullNever))
+}
+
+// implements Cookie
+func (c *cookie) CreateContainer(ctx context.Context) error {
+	ctx, log := common.LoggerWithFields(c.drv.ctx(ctx), logrus.Fields{"stack": "CreateContainer"})
+	log.WithFields(logrus.Fields{"call_id": c.task.Id(), "image": c.task.Image()}).Debug("containerd create container")
+
+	if c.image == nil {
+		log.Fatal("invalid usage: image not validated")
+	}
+	if c.container != nil {
+		return nil
+	}
+
+	// the options of the task apply over the config of the image
+	opts := make([]oci.SpecOpts, 0, len(c.opts)+2)
+	opts = append(opts, oci.WithImageConfigArgs(c.image, c.cmd))
+	opts = append(opts, c.opts...)
+	if c.contractVersion != 0 {
+		opts = append(opts, oci.WithEnv([]string{drivers.EnvContractVersion + "=" + strconv.Itoa(c.contractVersion)}))
+	}
+
+	// take a rootfs prepared ahead on top of the image, if one is ready, rather than prepare it now
+	snapshot := containerd.WithNewSnapshot(c.task.Id(), c.image)
+	var warm string
+	if c.drv.warm != nil {
+		if warm = c.drv.warm.take(ctx, c.image); warm != "" {
+			log.WithFields(logrus.Fields{"snapshot": warm}).Debug("containerd create container with warm snapshot")
+			snapshot = containerd.WithSnapshot(warm)
+		}
+	}
+
+	var err error
+	c.container, err = c.drv.client.NewContainer(ctx, c.task.Id(),
+		containerd.WithImage(c.image),
+		containerd.WithContainerLabels(c.labels),
+		containerd.WithSnapshotter(c.drv.snapshotter),
+		snapshot,
+		containerd.WithNewSpec(opts...),
+	)
+	if err != nil && warm != "" {
+		c.drv.warm.release(ctx, warm)
+	}
+
+	// the image was removed since it was validated, as the docker driver does, let the call land on another runner
+	if errdefs.IsNotFound(err) {
+		log.WithError(err).Error("Cannot CreateContainer image likely removed")
+		return models.ErrCallTimeoutServerBusy
+	}
+	if err != nil {
+		log.WithError(err).Error("Could not create container")
+		return err
+	}
+	return nil
+}
+
+// implements drivers.ContractCookie
+func (c *cookie) ContractVersion() int {
+	return c.contractVersion
+}
+
+var _ drivers.ContractCookie = &cookie{}
+
+// runResult implements drivers.RunResult
+type runResult struct {
+	err    error
+	status string
+}
+
+func (r *runResult) Error() error   { return r.err }
+func (r *runResult) Status() string { return r.status }
+
+// waitResult implements drivers.WaitResult
+type waitResult struct {
+	task   containerd.Task
+	exited <-chan containerd.ExitStatus
+}
+
+// waitResult implements drivers.WaitResult
+func (w *waitResult) Wait(ctx context.Context) drivers.RunResult {
+	status, err := w.wait(ctx)
+	return &runResult{
+		status: status,
+		err:    err,
+	}
+}
+
+func (w *waitResult) wait(ctx context.Context) (status string, err error) {
+	var exit containerd.ExitStatus
+	select {
+	case exit = <-w.exited:
+	case <-ctx.Done(): // check if task was canceled or timed out
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			return drivers.StatusTimeout, context.DeadlineExceeded
+		default:
+			return drivers.StatusCancelled, context.Canceled
+		}
+	}
+
+	exitCode, _, err := exit.Result()
+	if err != nil {
+		common.Logger(ctx).WithError(err).WithFields(logrus.Fields{"container": w.task.ID()}).Error("error waiting container")
+		return drivers.StatusError, err
+	}
+	// the output of the container is all copied once it exited
+	w.task.IO().Wait()
+
+	switch exitCode {
+	default:
+		return drivers.StatusError, models.NewAPIError(http.StatusBadGateway, fmt.Errorf("container exit code %d", exitCode))
+	case 0:
+		return drivers.StatusSuccess, nil
+	case 137: // OOM
+		common.Logger(ctx).Error("containerd oom")
+		err := errors.New("container out of memory, you may want to raise fn.memory for this function (default: 128MB)")
+		return drivers.StatusKilled, models.NewAPIError(http.StatusBadGateway, err)
+	}
+}
@@ -0,0 +1,230 @@
+package containerd
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/oci"
+	"github.com/fnproject/fn/api/agent/drivers"
+	fndocker "github.com/fnproject/fn/api/agent/drivers/docker"
+	"github.com/fnproject/fn/api/agent/drivers/stats"
+	"github.com/fnproject/fn/api/models"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+type taskContainerdTest struct {
+	id         string
+	cmd        string
+	env        map[string]string
+	disableNet bool
+	openFiles  *uint64
+	tmpFsSize  uint64
+	volumes    [][2]string
+
+	daemonLabels map[string]string
+	networks     []string
+	seccomp      string
+	apparmor     string
+}
+
+func (f *taskContainerdTest) Command() string                                            { return f.cmd }
+func (f *taskContainerdTest) EnvVars() map[string]string                                 { return f.env }
+func (f *taskContainerdTest) Id() string                                                 { return f.id }
+func (f *taskContainerdTest) Image() string                                              { return "busybox" }
+func (f *taskContainerdTest) ImagePullPolicy() string                                    { return "" }
+func (f *taskContainerdTest) Logger() (stdout, stderr io.Writer)                         { return nil, nil }
+func (f *taskContainerdTest) WriteStat(context.Context, stats.Stat)                      {}
+func (f *taskContainerdTest) Volumes() [][2]string                                       { return f.volumes }
+func (f *taskContainerdTest) Memory() uint64                                             { return 256 * 1024 * 1024 }
+func (f *taskContainerdTest) CPUs() uint64                                               { return 500 }
+func (f *taskContainerdTest) FsSize() uint64                                             { return 0 }
+func (f *taskContainerdTest) PIDs() uint64                                               { return 64 }
+func (f *taskContainerdTest) OpenFiles() *uint64                                         { return f.openFiles }
+func (f *taskContainerdTest) LockedMemory() *uint64                                      { return nil }
+func (f *taskContainerdTest) PendingSignals() *uint64                                    { return nil }
+func (f *taskContainerdTest) MessageQueue() *uint64                                      { return nil }
+func (f *taskContainerdTest) TmpFsSize() uint64                                          { return f.tmpFsSize }
+func (f *taskContainerdTest) WorkDir() string                                            { return "" }
+func (f *taskContainerdTest) Close()                                                     {}
+func (f *taskContainerdTest) WrapClose(func(func()) func())                              {}
+func (f *taskContainerdTest) WrapBeforeCall(func(drivers.BeforeCall) drivers.BeforeCall) {}
+func (f *taskContainerdTest) WrapAfterCall(func(drivers.AfterCall) drivers.AfterCall)    {}
+func (f *taskContainerdTest) Input() io.Reader                                           { return nil }
+func (f *taskContainerdTest) Extensions() map[string]string                              { return nil }
+func (f *taskContainerdTest) LoggerConfig() drivers.LoggerConfig                         { return drivers.LoggerConfig{} }
+func (f *taskContainerdTest) UDSAgentPath() string                                       { return "" }
+func (f *taskContainerdTest) UDSDockerPath() string                                      { return "/iofs/" + f.id }
+func (f *taskContainerdTest) UDSDockerDest() string                                      { return "/tmp/iofs" }
+func (f *taskContainerdTest) DisableNet() bool                                           { return f.disableNet }
+func (f *taskContainerdTest) StopSignal() string                                         { return "" }
+func (f *taskContainerdTest) StopTimeout() time.Duration                                 { return 0 }
+func (f *taskContainerdTest) DaemonLabels() map[string]string                            { return f.daemonLabels }
+func (f *taskContainerdTest) Networks() []string                                         { return f.networks }
+func (f *taskContainerdTest) GPUs() []string                                             { return nil }
+func (f *taskContainerdTest) SeccompProfile() string                                     { return f.seccomp }
+func (f *taskContainerdTest) AppArmorProfile() string                                    { return f.apparmor }
+
+func (f *taskContainerdTest) BeforeCall(context.Context, *models.Call, drivers.CallExtensions) error {
+	return nil
+}
+func (f *taskContainerdTest) AfterCall(context.Context, *models.Call, drivers.CallExtensions) error {
+	return nil
+}
+
+// spec generates the spec of the container of c, without the config of its image
+func spec(t *testing.T, c drivers.Cookie) *oci.Spec {
+	ctx := namespaces.WithNamespace(context.Background(), "fn")
+	s, err := oci.GenerateSpec(ctx, nil, &containers.Container{ID: "test"}, c.ContainerOptions().([]oci.SpecOpts)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func hasNamespace(s *oci.Spec, typ specs.LinuxNamespaceType) (specs.LinuxNamespace, bool) {
+	for _, ns := range s.Linux.Namespaces {
+		if ns.Type == typ {
+			return ns, true
+		}
+	}
+	return specs.LinuxNamespace{}, false
+}
+
+func TestCookieSpec(t *testing.T) {
+	ctx := context.Background()
+	drv := &ContainerdDriver{conf: drivers.Config{EnableReadOnlyRootFs: true, MaxTmpFsInodes: 1024}, hostname: "runner"}
+
+	files := uint64(512)
+	task := &taskContainerdTest{id: "test-containerd-spec", cmd: "serve --port 80", env: map[string]string{"FN_FORMAT": "http-stream"},
+		openFiles: &files, tmpFsSize: 16, volumes: [][2]string{{"/volumes/data", "/data:ro"}}}
+	c, err := drv.CreateCookie(ctx, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := spec(t, c)
+
+	if res := s.Linux.Resources; *res.Memory.Limit != int64(task.Memory()) || *res.Memory.Swap != int64(task.Memory()) ||
+		*res.CPU.Quota != 50000 || *res.CPU.Period != 100000 || res.Pids.Limit != 64 {
+		t.Fatalf("expected the memory, cpu and pids of the task to be limited, got %+v %+v %+v", res.Memory, res.CPU, res.Pids)
+	}
+	if expected := []string{"serve", "--port", "80"}; !reflect.DeepEqual(c.(*cookie).cmd, expected) {
+		t.Fatalf("expected the cmd %v, got %v", expected, c.(*cookie).cmd)
+	}
+	var env bool
+	for _, e := range s.Process.Env {
+		env = env || e == "FN_FORMAT=http-stream"
+	}
+	if !env {
+		t.Fatalf("expected the env of the task, got %v", s.Process.Env)
+	}
+	var nofile bool
+	for _, l := range s.Process.Rlimits {
+		nofile = nofile || (l.Type == "RLIMIT_NOFILE" && l.Hard == files && l.Soft == files)
+	}
+	if !nofile {
+		t.Fatalf("expected the open files to be limited, got %+v", s.Process.Rlimits)
+	}
+	if !s.Root.Readonly || s.Hostname != "runner" {
+		t.Fatalf("expected a read only root fs and the hostname of the runner, got %v %s", s.Root.Readonly, s.Hostname)
+	}
+
+	mounts := make(map[string]specs.Mount)
+	for _, m := range s.Mounts {
+		mounts[m.Destination] = m
+	}
+	if tmp := mounts["/tmp"]; tmp.Type != "tmpfs" || !reflect.DeepEqual(tmp.Options, []string{"nosuid", "nodev", "noexec", "size=16m", "nr_inodes=1024"}) {
+		t.Fatalf("expected a sized /tmp tmpfs, got %+v", tmp)
+	}
+	if iofs := mounts["/tmp/iofs"]; iofs.Source != "/iofs/test-containerd-spec" || !reflect.DeepEqual(iofs.Options, []string{"rbind", "rw"}) {
+		t.Fatalf("expected the iofs to be bound, got %+v", iofs)
+	}
+	if data := mounts["/data"]; data.Source != "/volumes/data" || !reflect.DeepEqual(data.Options, []string{"rbind", "ro"}) {
+		t.Fatalf("expected the volume to be bound read only, got %+v", data)
+	}
+
+	// unprivileged, with the default seccomp profile
+	if s.Process.User.UID != fndocker.FnUserId || s.Process.User.GID != fndocker.FnGroupId || !s.Process.NoNewPrivileges ||
+		len(s.Process.Capabilities.Bounding) != 0 || s.Linux.Seccomp == nil {
+		t.Fatalf("expected the container to run unprivileged, got %+v %+v", s.Process.User, s.Process.Capabilities)
+	}
+	if _, ok := hasNamespace(s, specs.NetworkNamespace); ok {
+		t.Fatal("expected the container to share the network of the host")
+	}
+}
+
+func TestCookieNetwork(t *testing.T) {
+	ctx := context.Background()
+
+	drv := &ContainerdDriver{}
+	c, err := drv.CreateCookie(ctx, &taskContainerdTest{id: "test-containerd-nonet", disableNet: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns, ok := hasNamespace(spec(t, c), specs.NetworkNamespace); !ok || ns.Path != "" {
+		t.Fatalf("expected a network namespace of its own, got %+v", ns)
+	}
+
+	drv = &ContainerdDriver{netns: []string{"fn0", "fn1"}}
+	c, err = drv.CreateCookie(ctx, &taskContainerdTest{id: "test-containerd-netns", networks: []string{"fn1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns, _ := hasNamespace(spec(t, c), specs.NetworkNamespace); ns.Path != "/var/run/netns/fn1" {
+		t.Fatalf("expected the allowed network namespace to be joined, got %+v", ns)
+	}
+
+	if _, err = drv.CreateCookie(ctx, &taskContainerdTest{id: "test-containerd-nonetns", networks: []string{"other"}}); err != ErrNoNetwork {
+		t.Fatalf("expected containers allowed on no network of the driver to be rejected, got %v", err)
+	}
+	if _, err = drv.CreateCookie(ctx, &taskContainerdTest{id: "test-containerd-daemon", daemonLabels: map[string]string{"gpu": "true"}}); err != fndocker.ErrNoDockerDaemon {
+		t.Fatalf("expected containers asking for docker daemons to be rejected, got %v", err)
+	}
+}
+
+func TestCookieSecurity(t *testing.T) {
+	ctx := context.Background()
+
+	drv := &ContainerdDriver{conf: drivers.Config{DisableUnprivilegedContainers: true, AppArmorProfiles: "fn-strict, fn-loose",
+		AppArmorProfile: "fn-loose"}}
+	c, err := drv.CreateCookie(ctx, &taskContainerdTest{id: "test-containerd-apparmor", apparmor: "fn-strict"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := spec(t, c); s.Process.ApparmorProfile != "fn-strict" || s.Process.NoNewPrivileges {
+		t.Fatalf("expected the AppArmor profile of the task in a privileged container, got %s %v", s.Process.ApparmorProfile, s.Process.NoNewPrivileges)
+	}
+	c, err = drv.CreateCookie(ctx, &taskContainerdTest{id: "test-containerd-apparmor-default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := spec(t, c); s.Process.ApparmorProfile != "fn-loose" {
+		t.Fatalf("expected the default AppArmor profile, got %s", s.Process.ApparmorProfile)
+	}
+
+	for _, task := range []*taskContainerdTest{
+		{id: "test-containerd-apparmor-unknown", apparmor: "unconfined"},
+		{id: "test-containerd-seccomp-unknown", seccomp: "../../etc/profile"},
+	} {
+		if _, err := drv.CreateCookie(ctx, task); err != fndocker.ErrNoSecurityProfile {
+			t.Fatalf("%s: expected profiles the driver does not have to be rejected, got %v", task.id, err)
+		}
+	}
+}
+
+func TestNormalizeImage(t *testing.T) {
+	for image, expected := range map[string]string{
+		"busybox":                       "docker.io/library/busybox:latest",
+		"fnproject/hello:0.0.1":         "docker.io/fnproject/hello:0.0.1",
+		"registry.example.com:5000/a/b": "registry.example.com:5000/a/b:latest",
+	} {
+		ref, err := normalizeImage(image)
+		if err != nil || ref != expected {
+			t.Errorf("%s: expected %s, got %s %v", image, expected, ref, err)
+		}
+	}
+}
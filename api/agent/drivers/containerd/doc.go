@@ -0,0 +1,5 @@
+// Package containerd provides a containerd driver for Fn. Provides an
+// implementation of
+//	github.com/fnproject/fn/api/agent/drivers.Driver
+// that runs images through the containerd API, without a docker daemon.
+package containerd
@@ -0,0 +1,46 @@
+package containerd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containerd/containerd/contrib/seccomp"
+	"github.com/containerd/containerd/oci"
+	fndocker "github.com/fnproject/fn/api/agent/drivers/docker"
+)
+
+// seccompProfile returns the spec option of the seccomp profile name of the SeccompProfilesDir, or of the default
+// profile if name is empty. It returns the default profile of containerd, which is docker's, if the driver has none.
+// Profiles are read as containerd reads them, in the seccomp format of the OCI runtime spec.
+func (drv *ContainerdDriver) seccompProfile(name string) (oci.SpecOpts, error) {
+	if name == "" {
+		name = drv.conf.SeccompProfile
+		if name == "" {
+			return seccomp.WithDefaultProfile(), nil
+		}
+	}
+	if drv.conf.SeccompProfilesDir == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fndocker.ErrNoSecurityProfile
+	}
+
+	path := filepath.Join(drv.conf.SeccompProfilesDir, name+".json")
+	if _, err := os.Stat(path); err != nil {
+		return nil, fndocker.ErrNoSecurityProfile
+	}
+	return seccomp.WithProfile(path), nil
+}
+
+// appArmorProfile returns the AppArmor profile name, or the default profile if name is empty. It returns an empty
+// profile, running the container without one, if the driver has no default.
+func (drv *ContainerdDriver) appArmorProfile(name string) (string, error) {
+	if name == "" || name == drv.conf.AppArmorProfile {
+		return drv.conf.AppArmorProfile, nil
+	}
+	for _, profile := range strings.Split(drv.conf.AppArmorProfiles, ",") {
+		if strings.TrimSpace(profile) == name {
+			return name, nil
+		}
+	}
+	return "", fndocker.ErrNoSecurityProfile
+}
@@ -0,0 +1,196 @@
+package containerd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/snapshots"
+	"github.com/fnproject/fn/api/common"
+	"github.com/opencontainers/image-spec/identity"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// FnWarmSnapshotLabel labels the snapshots prepared ahead by an agent with its instance id
+	FnWarmSnapshotLabel = "fn-warm-snapshot"
+
+	// gcRootLabel keeps containerd from collecting snapshots no container uses yet
+	gcRootLabel = "containerd.io/gc.root"
+)
+
+// warmSnapshots keeps up to size rootfs snapshots prepared ahead on top of each image that containers were created
+// from, so that creating a container takes one rather than preparing its rootfs while the call waits. Each snapshot is
+// used by one container only, which removes it with its own snapshot.
+type warmSnapshots struct {
+	ctx         context.Context
+	snapshotter snapshots.Snapshotter
+	size        int
+	labels      map[string]string
+
+	// chains caches the chain ids of the unpacked layers of the images, by the digest of the image
+	chains sync.Map
+
+	lock    sync.Mutex
+	ready   map[string][]string // keys of the snapshots prepared, by the chain id of their parent
+	filling map[string]bool
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// newWarmSnapshots prepares snapshots with snapshotter within ctx, until it is done
+func newWarmSnapshots(ctx context.Context, snapshotter snapshots.Snapshotter, size uint64, labelTag, instanceId string) *warmSnapshots {
+	return &warmSnapshots{
+		ctx:         ctx,
+		snapshotter: snapshotter,
+		size:        int(size),
+		labels: map[string]string{
+			FnAgentClassifierLabel: labelTag,
+			FnWarmSnapshotLabel:    instanceId,
+		},
+		ready:   make(map[string][]string),
+		filling: make(map[string]bool),
+	}
+}
+
+// parent returns the chain id of the unpacked layers of img, that the rootfs of its containers are prepared on
+func (w *warmSnapshots) parent(ctx context.Context, img containerd.Image) (string, error) {
+	digest := img.Target().Digest
+	if parent, ok := w.chains.Load(digest); ok {
+		return parent.(string), nil
+	}
+	diffIDs, err := img.RootFS(ctx)
+	if err != nil {
+		return "", err
+	}
+	parent := identity.ChainID(diffIDs).String()
+	w.chains.Store(digest, parent)
+	return parent, nil
+}
+
+// take returns the key of a snapshot prepared on top of img, or an empty key if none is ready, and has another one
+// prepared in its place.
+func (w *warmSnapshots) take(ctx context.Context, img containerd.Image) string {
+	parent, err := w.parent(ctx, img)
+	if err != nil {
+		common.Logger(ctx).WithError(err).WithFields(logrus.Fields{"image": img.Name()}).Error("cannot resolve the rootfs of image")
+		return ""
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return ""
+	}
+
+	var key string
+	if keys := w.ready[parent]; len(keys) > 0 {
+		key = keys[len(keys)-1]
+		w.ready[parent] = keys[:len(keys)-1]
+	}
+	if !w.filling[parent] {
+		w.filling[parent] = true
+		w.wg.Add(1)
+		go w.fill(parent)
+	}
+	return key
+}
+
+// fill prepares snapshots on top of parent until size of them are ready
+func (w *warmSnapshots) fill(parent string) {
+	defer w.wg.Done()
+	ctx := w.ctx
+	log := common.Logger(ctx).WithFields(logrus.Fields{"stack": "fill", "parent": parent})
+
+	for {
+		w.lock.Lock()
+		if w.closed || len(w.ready[parent]) >= w.size || ctx.Err() != nil {
+			w.filling[parent] = false
+			w.lock.Unlock()
+			return
+		}
+		w.lock.Unlock()
+
+		key, err := w.prepare(ctx, parent)
+		if err != nil {
+			// the image was likely removed, the next container created from it, if any, retries
+			log.WithError(err).Error("cannot prepare warm snapshot")
+			w.lock.Lock()
+			w.filling[parent] = false
+			w.lock.Unlock()
+			return
+		}
+
+		w.lock.Lock()
+		w.ready[parent] = append(w.ready[parent], key)
+		w.lock.Unlock()
+	}
+}
+
+func (w *warmSnapshots) prepare(ctx context.Context, parent string) (string, error) {
+	id, err := generateRandUUID()
+	if err != nil {
+		return "", err
+	}
+	key := "fn-warm-" + id
+
+	labels := make(map[string]string, len(w.labels)+1)
+	for k, v := range w.labels {
+		labels[k] = v
+	}
+	labels[gcRootLabel] = time.Now().UTC().Format(time.RFC3339)
+
+	_, err = w.snapshotter.Prepare(ctx, key, parent, snapshots.WithLabels(labels))
+	return key, err
+}
+
+// release removes the snapshot key taken, that no container was created with
+func (w *warmSnapshots) release(ctx context.Context, key string) {
+	if err := w.snapshotter.Remove(ctx, key); err != nil {
+		common.Logger(ctx).WithError(err).WithFields(logrus.Fields{"key": key}).Error("cannot remove warm snapshot")
+	}
+}
+
+// removeLeaked removes the snapshots prepared ahead by earlier agents with the same label tag, that no container
+// took. It is executed once, and if it fails it does not retry.
+func (w *warmSnapshots) removeLeaked(ctx context.Context) {
+	if w.labels[FnAgentClassifierLabel] == "" {
+		return
+	}
+	ctx, log := common.LoggerWithFields(ctx, logrus.Fields{"stack": "removeLeaked"})
+
+	var leaked []string
+	err := w.snapshotter.Walk(ctx, func(ctx context.Context, info snapshots.Info) error {
+		instance, ok := info.Labels[FnWarmSnapshotLabel]
+		if ok && instance != w.labels[FnWarmSnapshotLabel] &&
+			info.Labels[FnAgentClassifierLabel] == w.labels[FnAgentClassifierLabel] {
+			leaked = append(leaked, info.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithError(err).Error("cannot list warm snapshots")
+		return
+	}
+
+	for _, key := range leaked {
+		log.WithFields(logrus.Fields{"key": key}).Info("Removing dangling warm snapshot")
+		w.release(ctx, key)
+	}
+}
+
+// close stops preparing snapshots and removes the snapshots ready, within ctx
+func (w *warmSnapshots) close(ctx context.Context) {
+	w.lock.Lock()
+	w.closed = true
+	w.lock.Unlock()
+	w.wg.Wait()
+
+	for _, keys := range w.ready {
+		for _, key := range keys {
+			w.release(ctx, key)
+		}
+	}
+	w.ready = make(map[string][]string)
+}
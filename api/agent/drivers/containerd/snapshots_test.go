@@ -0,0 +1,150 @@
+package containerd
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/containerd/snapshots"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/identity"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// snapshotterTest keeps the snapshots of a snapshotter in memory
+type snapshotterTest struct {
+	snapshots.Snapshotter
+
+	lock      sync.Mutex
+	snapshots map[string]snapshots.Info
+}
+
+func (s *snapshotterTest) Prepare(ctx context.Context, key, parent string, opts ...snapshots.Opt) ([]mount.Mount, error) {
+	info := snapshots.Info{Kind: snapshots.KindActive, Name: key, Parent: parent}
+	for _, opt := range opts {
+		if err := opt(&info); err != nil {
+			return nil, err
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.snapshots[key] = info
+	return nil, nil
+}
+
+func (s *snapshotterTest) Remove(ctx context.Context, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.snapshots, key)
+	return nil
+}
+
+func (s *snapshotterTest) Walk(ctx context.Context, fn func(context.Context, snapshots.Info) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, info := range s.snapshots {
+		if err := fn(ctx, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *snapshotterTest) keys() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var keys []string
+	for key := range s.snapshots {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// imageTest is an image of one layer
+type imageTest struct {
+	containerd.Image
+	layer digest.Digest
+}
+
+func (i *imageTest) Name() string { return "busybox" }
+func (i *imageTest) Target() ocispec.Descriptor {
+	return ocispec.Descriptor{Digest: digest.FromString("manifest of " + i.layer.String())}
+}
+func (i *imageTest) RootFS(context.Context) ([]digest.Digest, error) {
+	return []digest.Digest{i.layer}, nil
+}
+
+// ready waits for n snapshots to be prepared on top of parent
+func ready(t *testing.T, w *warmSnapshots, parent string, n int) []string {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		w.lock.Lock()
+		keys, filling := append([]string(nil), w.ready[parent]...), w.filling[parent]
+		w.lock.Unlock()
+		if len(keys) == n && !filling {
+			return keys
+		}
+	}
+	t.Fatalf("expected %d warm snapshots of %s", n, parent)
+	return nil
+}
+
+func TestWarmSnapshots(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	snapshotter := &snapshotterTest{snapshots: make(map[string]snapshots.Info)}
+	w := newWarmSnapshots(ctx, snapshotter, 2, "test", "agent")
+	img := &imageTest{layer: digest.FromString("layer")}
+	parent := identity.ChainID([]digest.Digest{img.layer}).String()
+
+	if key := w.take(ctx, img); key != "" {
+		t.Fatalf("expected no snapshot ready before the first container of the image, got %s", key)
+	}
+	keys := ready(t, w, parent, 2)
+	for _, key := range keys {
+		info := snapshotter.snapshots[key]
+		if info.Parent != parent || info.Labels[FnWarmSnapshotLabel] != "agent" || info.Labels[gcRootLabel] == "" {
+			t.Fatalf("expected a snapshot on top of the image, labeled as the agent's, got %+v", info)
+		}
+	}
+
+	key := w.take(ctx, img)
+	if key != keys[1] {
+		t.Fatalf("expected a snapshot prepared ahead, got %q", key)
+	}
+	// the snapshot taken is the container's now, and another one is prepared in its place
+	if again := ready(t, w, parent, 2); again[0] != keys[0] || again[1] == key {
+		t.Fatalf("expected the snapshot taken to be replaced, got %v", again)
+	}
+
+	w.close(ctx)
+	if left := snapshotter.keys(); len(left) != 1 || left[0] != key {
+		t.Fatalf("expected only the snapshot taken to be left once closed, got %v", left)
+	}
+	if key := w.take(ctx, img); key != "" {
+		t.Fatalf("expected no snapshot once closed, got %s", key)
+	}
+}
+
+func TestWarmSnapshotsRemoveLeaked(t *testing.T) {
+	ctx := context.Background()
+	snapshotter := &snapshotterTest{snapshots: map[string]snapshots.Info{
+		"fn-warm-ours":    {Name: "fn-warm-ours", Labels: map[string]string{FnAgentClassifierLabel: "test", FnWarmSnapshotLabel: "agent"}},
+		"fn-warm-leaked":  {Name: "fn-warm-leaked", Labels: map[string]string{FnAgentClassifierLabel: "test", FnWarmSnapshotLabel: "earlier"}},
+		"fn-warm-other":   {Name: "fn-warm-other", Labels: map[string]string{FnAgentClassifierLabel: "other", FnWarmSnapshotLabel: "earlier"}},
+		"some-container":  {Name: "some-container", Labels: map[string]string{FnAgentClassifierLabel: "test"}},
+		"sha256:abcdef01": {Name: "sha256:abcdef01"},
+	}}
+
+	newWarmSnapshots(ctx, snapshotter, 2, "test", "agent").removeLeaked(ctx)
+	expected := []string{"fn-warm-other", "fn-warm-ours", "sha256:abcdef01", "some-container"}
+	if left := snapshotter.keys(); !reflect.DeepEqual(left, expected) {
+		t.Fatalf("expected only the warm snapshots of earlier agents with the label tag to be removed, got %v", left)
+	}
+}
@@ -0,0 +1,98 @@
+package containerd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/oci"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// resources returns the resources of the linux section of s, adding them if s has none
+func resources(s *oci.Spec) *specs.LinuxResources {
+	if s.Linux == nil {
+		s.Linux = &specs.Linux{}
+	}
+	if s.Linux.Resources == nil {
+		s.Linux.Resources = &specs.LinuxResources{}
+	}
+	return s.Linux.Resources
+}
+
+// withMemory limits the memory of the container to mem bytes, without swap
+func withMemory(mem uint64) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		limit := int64(mem)
+		var zero uint64
+		resources(s).Memory = &specs.LinuxMemory{
+			Limit:      &limit,
+			Swap:       &limit, // disables swap
+			Swappiness: &zero,  // disables host swap
+		}
+		return nil
+	}
+}
+
+// withCPUQuota gives the container quota usecs of cpu time in each period usecs
+func withCPUQuota(quota int64, period uint64) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		resources(s).CPU = &specs.LinuxCPU{Quota: &quota, Period: &period}
+		return nil
+	}
+}
+
+// withPIDs limits the number of processes of the container
+func withPIDs(pids int64) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		resources(s).Pids = &specs.LinuxPids{Limit: pids}
+		return nil
+	}
+}
+
+// withRlimit sets the soft and hard limits of the resource typ, eg. RLIMIT_NOFILE, replacing the default of the spec
+func withRlimit(typ string, value uint64) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		if s.Process == nil {
+			s.Process = &specs.Process{}
+		}
+		limit := specs.POSIXRlimit{Type: typ, Hard: value, Soft: value}
+		for i, l := range s.Process.Rlimits {
+			if l.Type == typ {
+				s.Process.Rlimits[i] = limit
+				return nil
+			}
+		}
+		s.Process.Rlimits = append(s.Process.Rlimits, limit)
+		return nil
+	}
+}
+
+// withTmpfs mounts a tmpfs with options at dest, as docker does for the tmpfs of containers
+func withTmpfs(dest string, options ...string) oci.SpecOpts {
+	return oci.WithMounts([]specs.Mount{{
+		Destination: dest,
+		Type:        "tmpfs",
+		Source:      "tmpfs",
+		Options:     append([]string{"nosuid", "nodev", "noexec"}, options...),
+	}})
+}
+
+// withBind binds the host path src at dest, dest may carry bind options, eg. /data:ro
+func withBind(src, dest string) oci.SpecOpts {
+	mode := "rw"
+	parts := strings.SplitN(dest, ":", 2)
+	if len(parts) == 2 {
+		for _, opt := range strings.Split(parts[1], ",") {
+			if opt == "ro" {
+				mode = "ro"
+			}
+		}
+	}
+	return oci.WithMounts([]specs.Mount{{
+		Destination: parts[0],
+		Type:        "bind",
+		Source:      src,
+		Options:     []string{"rbind", mode},
+	}})
+}
@@ -0,0 +1,56 @@
+package drivers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+const (
+	// ContractVersionsLabel is the image label listing the versions of the contract between the agent and fdks the
+	// image speaks, a comma separated list, eg. "1,2". Images without it speak version 1.
+	ContractVersionsLabel = "fnproject.io/fdk/contract-versions"
+	// EnvContractVersion is the env var telling containers the contract version the agent chose for them
+	EnvContractVersion = "FN_CONTRACT_VERSION"
+)
+
+// ContractVersions are the contract versions the agent speaks, oldest first
+var ContractVersions = []int{1}
+
+// ErrContractUnsupported is returned for images that speak none of the contract versions of the agent
+var ErrContractUnsupported = models.NewAPIError(http.StatusBadRequest,
+	fmt.Errorf("image speaks none of the fdk contract versions supported by the runner, see the %s label", ContractVersionsLabel))
+
+// ContractCookie is a Cookie that negotiated the contract version of its container with its image, once its image
+// is validated
+type ContractCookie interface {
+	Cookie
+	ContractVersion() int
+}
+
+// NegotiateContract returns the newest of ContractVersions that an image with the given labels speaks
+func NegotiateContract(labels map[string]string) (int, error) {
+	list, ok := labels[ContractVersionsLabel]
+	if !ok {
+		return 1, nil
+	}
+
+	supported := make(map[int]bool)
+	for _, v := range strings.Split(list, ",") {
+		version, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return 0, models.NewAPIError(http.StatusBadRequest, errors.New("invalid "+ContractVersionsLabel+" image label "+strconv.Quote(list)))
+		}
+		supported[version] = true
+	}
+	for i := len(ContractVersions) - 1; i >= 0; i-- {
+		if supported[ContractVersions[i]] {
+			return ContractVersions[i], nil
+		}
+	}
+	return 0, ErrContractUnsupported
+}
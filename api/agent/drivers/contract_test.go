@@ -0,0 +1,26 @@
+package drivers
+
+import "testing"
+
+func TestNegotiateContract(t *testing.T) {
+	defer func(versions []int) { ContractVersions = versions }(ContractVersions)
+	ContractVersions = []int{1, 2}
+
+	for i, test := range []struct {
+		labels  map[string]string
+		version int
+		err     bool
+	}{
+		{nil, 1, false},
+		{map[string]string{"other": "2"}, 1, false},
+		{map[string]string{ContractVersionsLabel: "1"}, 1, false},
+		{map[string]string{ContractVersionsLabel: "1, 2, 3"}, 2, false},
+		{map[string]string{ContractVersionsLabel: "3"}, 0, true},
+		{map[string]string{ContractVersionsLabel: "v1"}, 0, true},
+	} {
+		version, err := NegotiateContract(test.labels)
+		if version != test.version || (err != nil) != test.err {
+			t.Errorf("Test %d: expected version %d and error %v, got %d and %v", i, test.version, test.err, version, err)
+		}
+	}
+}
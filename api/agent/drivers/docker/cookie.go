@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fnproject/fn/api/agent/drivers"
 	"github.com/fnproject/fn/api/common"
@@ -41,6 +43,8 @@ type cookie struct {
 	task drivers.ContainerTask
 	// pointer to docker driver
 	drv *DockerDriver
+	// docker daemon the container is run on
+	daemon *dockerDaemon
 
 	imgReg  string
 	imgRepo string
@@ -48,9 +52,24 @@ type cookie struct {
 
 	// contains inspected image if ValidateImage() is called
 	image *CachedImage
+	// true once PullImage() pulled the image, images of fns that always pull are pulled once for each container
+	pulled bool
+	// contract version negotiated with the image by ValidateImage()
+	contractVersion int
 
 	// contains created container if CreateContainer() is called
 	container *docker.Container
+	// true if the container was left running by a previous agent, see DockerDriver.AdoptCookie
+	adopted bool
+	// true while the container is paused by Freeze()
+	frozen bool
+	// freezer of the cgroup of the container if the agent may write to it, looked up by the first Freeze()
+	freezer       *cgroupFreezer
+	freezerLookup bool
+	// true while the container is frozen through its cgroup rather than docker
+	cgroupFrozen bool
+	// true while the memory soft limit of the container is squeezed by Freeze()
+	squeezed bool
 }
 
 func (c *cookie) configureImage(log logrus.FieldLogger) {
@@ -114,10 +133,27 @@ func (c *cookie) configureMem(log logrus.FieldLogger) {
 	c.opts.HostConfig.MemorySwappiness = &zero // disables host swap
 }
 
+func (c *cookie) configureStop(log logrus.FieldLogger) {
+	timeout := c.task.StopTimeout()
+	if timeout <= 0 {
+		return
+	}
+
+	// docker stop timeouts are in seconds, round up
+	c.opts.Config.StopTimeout = int((timeout + time.Second - 1) / time.Second)
+	c.opts.Config.StopSignal = c.task.StopSignal()
+	log.WithFields(logrus.Fields{"stop_signal": c.opts.Config.StopSignal, "stop_timeout": c.opts.Config.StopTimeout,
+		"call_id": c.task.Id()}).Debug("setting stop signal and timeout")
+}
+
 func (c *cookie) configureFsSize(log logrus.FieldLogger) {
 	if c.task.FsSize() == 0 {
 		return
 	}
+	if c.drv.conf.DevMode {
+		c.drv.devGap("storage-opt", "the disk size of containers is not limited")
+		return
+	}
 
 	// If defined, impose file system size limit. In MB units.
 	if c.opts.HostConfig.StorageOpt == nil {
@@ -170,6 +206,12 @@ func (c *cookie) configureTmpFs(log logrus.FieldLogger) {
 	if c.task.TmpFsSize() == 0 && !c.drv.conf.EnableReadOnlyRootFs {
 		return
 	}
+	if c.drv.conf.DevMode {
+		// without a tmpfs, /tmp is only writable on a writable root fs
+		c.drv.devGap("tmpfs", "containers get a writable root fs rather than a read only one with a sized /tmp tmpfs")
+		c.opts.HostConfig.ReadonlyRootfs = false
+		return
+	}
 
 	if c.opts.HostConfig.Tmpfs == nil {
 		c.opts.HostConfig.Tmpfs = make(map[string]string)
@@ -211,9 +253,10 @@ func (c *cookie) configureVolumes(log logrus.FieldLogger) {
 
 	for _, mapping := range c.task.Volumes() {
 		hostDir := mapping[0]
-		containerDir := mapping[1]
+		// the container path may carry bind options, eg. /data:ro
+		containerDir := strings.SplitN(mapping[1], ":", 2)[0]
 		c.opts.Config.Volumes[containerDir] = struct{}{}
-		mapn := fmt.Sprintf("%s:%s", hostDir, containerDir)
+		mapn := fmt.Sprintf("%s:%s", hostDir, mapping[1])
 		c.opts.HostConfig.Binds = append(c.opts.HostConfig.Binds, mapn)
 		log.WithFields(logrus.Fields{"volumes": mapn, "call_id": c.task.Id()}).Debug("setting volumes")
 	}
@@ -236,6 +279,20 @@ func (c *cookie) configureCPU(log logrus.FieldLogger) {
 	c.opts.HostConfig.CPUPeriod = period
 }
 
+// configureGPUs gives the container its GPUs through the GPU runtime of the driver, which exposes the devices named
+// in NVIDIA_VISIBLE_DEVICES to it. The docker API version the driver speaks predates device requests.
+func (c *cookie) configureGPUs(log logrus.FieldLogger) {
+	gpus := c.task.GPUs()
+	if len(gpus) == 0 {
+		return
+	}
+
+	devices := strings.Join(gpus, ",")
+	log.WithFields(logrus.Fields{"gpus": devices, "runtime": c.drv.conf.GPURuntime, "call_id": c.task.Id()}).Debug("setting GPUs")
+	c.opts.HostConfig.Runtime = c.drv.conf.GPURuntime
+	c.opts.Config.Env = append(c.opts.Config.Env, "NVIDIA_VISIBLE_DEVICES="+devices, "NVIDIA_DRIVER_CAPABILITIES=compute,utility")
+}
+
 func (c *cookie) configureWorkDir(log logrus.FieldLogger) {
 	wd := c.task.WorkDir()
 	if wd == "" {
@@ -246,18 +303,20 @@ func (c *cookie) configureWorkDir(log logrus.FieldLogger) {
 	c.opts.Config.WorkingDir = wd
 }
 
-func (c *cookie) configureNetwork(log logrus.FieldLogger) {
+func (c *cookie) configureNetwork(log logrus.FieldLogger) error {
 	if c.opts.HostConfig.NetworkMode != "" {
-		return
+		return nil
 	}
 
 	if c.task.DisableNet() {
 		c.opts.HostConfig.NetworkMode = "none"
-		return
+		return nil
 	}
 
-	// If pool is enabled, we try to pick network from pool
-	if c.drv.pool != nil {
+	// If pool is enabled, we try to pick network from pool, which only runs on the primary daemon. The pool
+	// containers are on networks of their own, containers restricted to some networks cannot share them.
+	allowed := c.task.Networks()
+	if c.drv.pool != nil && c.daemon.primary && len(allowed) == 0 {
 		id, err := c.drv.pool.AllocPoolId()
 		if id != "" {
 			// We are able to fetch a container from pool. Now, use its
@@ -266,7 +325,7 @@ func (c *cookie) configureNetwork(log logrus.FieldLogger) {
 			//c.opts.HostConfig.IpcMode = linker
 			//c.opts.HostConfig.PidMode = linker
 			c.poolId = id
-			return
+			return nil
 		}
 		if err != nil {
 			log.WithError(err).Error("Could not fetch pre fork pool container")
@@ -274,11 +333,15 @@ func (c *cookie) configureNetwork(log logrus.FieldLogger) {
 	}
 
 	// if pool is not enabled or fails, then pick from defined networks if any
-	id := c.drv.network.AllocNetwork()
+	id, err := c.drv.network.AllocNetwork(allowed)
+	if err != nil {
+		return err
+	}
 	if id != "" {
 		c.opts.HostConfig.NetworkMode = id
 		c.netId = id
 	}
+	return nil
 }
 
 func (c *cookie) configureHostname(log logrus.FieldLogger) {
@@ -316,22 +379,47 @@ func (c *cookie) configureEnv(log logrus.FieldLogger) {
 	}
 }
 
-func (c *cookie) configureSecurity(log logrus.FieldLogger) {
-	if c.drv.conf.DisableUnprivilegedContainers {
-		return
+// configureSecurity runs the container unprivileged, unless the driver allows privileged containers, and with the
+// seccomp and AppArmor profiles of its task or the defaults of the driver
+func (c *cookie) configureSecurity(log logrus.FieldLogger) error {
+	if !c.drv.conf.DisableUnprivilegedContainers {
+		c.opts.Config.User = FnDockerUser
+		c.opts.HostConfig.CapDrop = []string{"all"}
+		c.opts.HostConfig.SecurityOpt = []string{"no-new-privileges"}
+	}
+
+	seccomp, err := c.drv.security.seccompProfile(c.task.SeccompProfile())
+	if err != nil {
+		return err
+	}
+	if seccomp != "" {
+		c.opts.HostConfig.SecurityOpt = append(c.opts.HostConfig.SecurityOpt, "seccomp="+seccomp)
+	}
+	apparmor, err := c.drv.security.appArmorProfile(c.task.AppArmorProfile())
+	if err != nil {
+		return err
 	}
-	c.opts.Config.User = FnDockerUser
-	c.opts.HostConfig.CapDrop = []string{"all"}
-	c.opts.HostConfig.SecurityOpt = []string{"no-new-privileges"}
-	log.WithFields(logrus.Fields{"user": c.opts.Config.User,
-		"CapDrop": c.opts.HostConfig.CapDrop, "SecurityOpt": c.opts.HostConfig.SecurityOpt, "call_id": c.task.Id()}).Debug("setting security")
+	if apparmor != "" && c.drv.conf.DevMode {
+		c.drv.devGap("apparmor", "containers run without AppArmor profiles")
+	} else if apparmor != "" {
+		c.opts.HostConfig.SecurityOpt = append(c.opts.HostConfig.SecurityOpt, "apparmor="+apparmor)
+	}
+
+	log.WithFields(logrus.Fields{"user": c.opts.Config.User, "CapDrop": c.opts.HostConfig.CapDrop,
+		"seccomp": c.task.SeccompProfile(), "apparmor": apparmor, "call_id": c.task.Id()}).Debug("setting security")
+	return nil
 }
 
 // implements Cookie
 func (c *cookie) Close(ctx context.Context) error {
 	var err error
 	if c.container != nil {
-		err = c.drv.docker.RemoveContainer(docker.RemoveContainerOptions{
+		// docker cannot kill a container frozen behind its back
+		if c.cgroupFrozen {
+			c.unpause(ctx)
+		}
+		c.stop(ctx)
+		err = c.daemon.docker.RemoveContainer(docker.RemoveContainerOptions{
 			ID: c.task.Id(), Force: true, RemoveVolumes: true, Context: ctx})
 		if err != nil {
 			common.Logger(ctx).WithError(err).WithFields(logrus.Fields{"call_id": c.task.Id()}).Error("error removing container")
@@ -345,15 +433,62 @@ func (c *cookie) Close(ctx context.Context) error {
 		c.drv.network.FreeNetwork(c.netId)
 	}
 
-	if c.image != nil && c.drv.imgCache != nil {
-		c.drv.imgCache.MarkFree(c.image)
+	if c.image != nil && c.daemon.imgCache != nil {
+		c.daemon.imgCache.MarkFree(c.image)
 	}
+	c.drv.releaseDaemon(c.daemon)
 	return err
 }
 
+// stop asks the container to exit with its stop signal and waits up to its stop timeout for it to, so that it may
+// shut down cleanly before it is removed
+func (c *cookie) stop(ctx context.Context) {
+	timeout := c.opts.Config.StopTimeout
+	if timeout <= 0 {
+		return
+	}
+	log := common.Logger(ctx).WithFields(logrus.Fields{"stack": "Stop", "call_id": c.task.Id()})
+
+	// a paused container cannot handle signals
+	if c.frozen {
+		if err := c.unpause(ctx); err != nil {
+			log.WithError(err).Debug("error unpausing container to stop it")
+			return
+		}
+	}
+
+	// docker kills the container if it has not exited once the timeout passes
+	err := c.daemon.docker.StopContainerWithContext(c.task.Id(), uint(timeout), ctx)
+	if err != nil {
+		// most likely it has exited already
+		log.WithError(err).Debug("error stopping container")
+	}
+}
+
 // implements Cookie
 func (c *cookie) Run(ctx context.Context) (drivers.WaitResult, error) {
-	return c.drv.run(ctx, c.task.Id(), c.task)
+	return c.drv.run(ctx, c.daemon, c.task.Id(), c.task, c.adopted)
+}
+
+// implements drivers.DetachableCookie
+func (c *cookie) Detach(ctx context.Context) error {
+	if c.container == nil || c.poolId != "" {
+		return errors.New("container cannot be detached")
+	}
+	if c.frozen {
+		if err := c.Unfreeze(ctx); err != nil {
+			return err
+		}
+	}
+
+	if c.netId != "" {
+		c.drv.network.FreeNetwork(c.netId)
+	}
+	if c.image != nil && c.daemon.imgCache != nil {
+		c.daemon.imgCache.MarkFree(c.image)
+	}
+	c.drv.releaseDaemon(c.daemon)
+	return nil
 }
 
 // implements Cookie
@@ -366,9 +501,16 @@ func (c *cookie) Freeze(ctx context.Context) error {
 	ctx, log := common.LoggerWithFields(ctx, logrus.Fields{"stack": "Freeze"})
 	log.WithFields(logrus.Fields{"call_id": c.task.Id()}).Debug("docker pause")
 
-	err := c.drv.docker.PauseContainer(c.task.Id(), ctx)
+	if c.drv.conf.DevMode {
+		c.drv.devGap("pause", "frozen containers are not paused and keep using cpu")
+		return nil
+	}
+
+	err := c.pause(ctx, log)
 	if err != nil {
 		log.WithError(err).WithFields(logrus.Fields{"call_id": c.task.Id()}).Error("error pausing container")
+	} else {
+		c.squeezeMem(ctx, log)
 	}
 	return err
 }
@@ -378,13 +520,91 @@ func (c *cookie) Unfreeze(ctx context.Context) error {
 	ctx, log := common.LoggerWithFields(ctx, logrus.Fields{"stack": "Unfreeze"})
 	log.WithFields(logrus.Fields{"call_id": c.task.Id()}).Debug("docker unpause")
 
-	err := c.drv.docker.UnpauseContainer(c.task.Id(), ctx)
+	if c.drv.conf.DevMode {
+		return nil
+	}
+
+	c.restoreMem(ctx, log)
+
+	err := c.unpause(ctx)
 	if err != nil {
 		log.WithError(err).WithFields(logrus.Fields{"call_id": c.task.Id()}).Error("error unpausing container")
 	}
 	return err
 }
 
+// pause freezes the container through its cgroup if the agent may, falling back to docker pause
+func (c *cookie) pause(ctx context.Context, log logrus.FieldLogger) error {
+	if !c.freezerLookup && c.container != nil && c.drv.conf.CgroupRoot != "" {
+		c.freezer = findCgroupFreezer(c.drv.conf.CgroupRoot, c.container.ID)
+		c.freezerLookup = true
+	}
+
+	if c.freezer != nil {
+		err := c.freezer.freeze()
+		if err == nil {
+			c.frozen = true
+			c.cgroupFrozen = true
+			return nil
+		}
+		log.WithError(err).WithFields(logrus.Fields{"call_id": c.task.Id()}).Debug("error freezing cgroup of container, using docker")
+		c.freezer = nil
+	}
+
+	err := c.daemon.docker.PauseContainer(c.task.Id(), ctx)
+	if err == nil {
+		c.frozen = true
+	}
+	return err
+}
+
+// unpause undoes pause, the way the container was frozen
+func (c *cookie) unpause(ctx context.Context) error {
+	var err error
+	if c.cgroupFrozen {
+		err = c.freezer.thaw()
+	} else {
+		err = c.daemon.docker.UnpauseContainer(c.task.Id(), ctx)
+	}
+	if err == nil {
+		c.frozen = false
+		c.cgroupFrozen = false
+	}
+	return err
+}
+
+// squeezeMem lowers the memory soft limit of a frozen container to FreezeMemoryPercent of its memory, so that the
+// kernel reclaims its page cache before that of running containers when memory runs low. Failing to is not fatal,
+// the container just keeps its memory.
+func (c *cookie) squeezeMem(ctx context.Context, log logrus.FieldLogger) {
+	pct := c.drv.conf.FreezeMemoryPercent
+	if pct == 0 || pct >= 100 || c.task.Memory() == 0 {
+		return
+	}
+
+	reservation := c.task.Memory() * pct / 100
+	err := c.daemon.docker.UpdateContainer(c.task.Id(), docker.UpdateContainerOptions{MemoryReservation: int(reservation), Context: ctx})
+	if err != nil {
+		log.WithError(err).WithFields(logrus.Fields{"call_id": c.task.Id()}).Error("error squeezing memory of container")
+		return
+	}
+	c.squeezed = true
+}
+
+// restoreMem undoes squeezeMem, a soft limit of all the memory of the container being no limit
+func (c *cookie) restoreMem(ctx context.Context, log logrus.FieldLogger) {
+	if !c.squeezed {
+		return
+	}
+
+	err := c.daemon.docker.UpdateContainer(c.task.Id(), docker.UpdateContainerOptions{MemoryReservation: int(c.task.Memory()), Context: ctx})
+	if err != nil {
+		log.WithError(err).WithFields(logrus.Fields{"call_id": c.task.Id()}).Error("error restoring memory of container")
+		return
+	}
+	c.squeezed = false
+}
+
 func (c *cookie) authImage(ctx context.Context) (*docker.AuthConfiguration, error) {
 	ctx, log := common.LoggerWithFields(ctx, logrus.Fields{"stack": "AuthImage"})
 	log.WithFields(logrus.Fields{"call_id": c.task.Id()}).Debug("docker auth image")
@@ -417,33 +637,60 @@ func (c *cookie) ValidateImage(ctx context.Context) (bool, error) {
 		return false, nil
 	}
 
+	policy := c.task.ImagePullPolicy()
+	if policy == models.ImagePullAlways && !c.pulled && !c.drv.conf.DisableImagePulls {
+		return true, nil
+	}
+
 	// see if we already have it
 	// TODO this should use the image cache instead of making a docker call
-	img, err := c.drv.docker.InspectImage(ctx, c.task.Image())
+	img, err := c.daemon.docker.InspectImage(ctx, c.task.Image())
 	if err == docker.ErrNoSuchImage {
+		if policy == models.ImagePullNever {
+			return false, errImageNeverPulled(c.task.Image())
+		}
 		return true, nil
 	}
 	if err != nil {
 		return false, err
 	}
 
+	// images already on the daemon may have been loaded, or pulled before their signatures were verified
+	if needsPull, err := c.verifyLocalImage(ctx, img); needsPull || err != nil {
+		return needsPull, err
+	}
+
 	// check image doesn't have Volumes
 	if !c.drv.conf.ImageEnableVolume && img.Config != nil && len(img.Config.Volumes) > 0 {
 		err = ErrImageWithVolume
 	}
 
+	var labels map[string]string
+	if img.Config != nil {
+		labels = img.Config.Labels
+	}
+	if version, cerr := drivers.NegotiateContract(labels); cerr != nil {
+		log.WithError(cerr).WithField("image", c.task.Image()).Info("image speaks no supported contract version")
+		if err == nil {
+			err = cerr
+		}
+	} else {
+		c.contractVersion = version
+	}
+
 	c.image = &CachedImage{
-		ID:       img.ID,
-		ParentID: img.Parent,
-		RepoTags: img.RepoTags,
-		Size:     uint64(img.Size),
+		ID:          img.ID,
+		ParentID:    img.Parent,
+		RepoTags:    img.RepoTags,
+		RepoDigests: img.RepoDigests,
+		Size:        uint64(img.Size),
 	}
 
-	if c.drv.imgCache != nil {
+	if c.daemon.imgCache != nil {
 		if err == ErrImageWithVolume {
-			c.drv.imgCache.Update(c.image)
+			c.daemon.imgCache.Update(c.image)
 		} else {
-			c.drv.imgCache.MarkBusy(c.image)
+			c.daemon.imgCache.MarkBusy(c.image)
 		}
 	}
 	return false, err
@@ -455,6 +702,14 @@ func (c *cookie) PullImage(ctx context.Context) error {
 	if c.image != nil {
 		return nil
 	}
+	if c.drv.conf.DisableImagePulls {
+		log.WithFields(logrus.Fields{"call_id": c.task.Id(), "image": c.task.Image()}).Error("image not loaded, and image pulls are disabled")
+		return models.NewCodedError(http.StatusBadGateway, "image_pulls_disabled", map[string]string{"image": c.task.Image()},
+			fmt.Errorf("Image '%s' is not loaded on the runner, and image pulls are disabled", c.task.Image()))
+	}
+	if c.task.ImagePullPolicy() == models.ImagePullNever {
+		return errImageNeverPulled(c.task.Image())
+	}
 
 	cfg, err := c.authImage(ctx)
 	if err != nil {
@@ -467,8 +722,25 @@ func (c *cookie) PullImage(ctx context.Context) error {
 	log.WithFields(logrus.Fields{"call_id": c.task.Id(), "image": c.task.Image()}).Debug("docker pull")
 	ctx = common.WithLogger(ctx, log)
 
-	errC := c.drv.imgPuller.PullImage(ctx, cfg, c.task.Image(), repo, c.imgTag)
-	return <-errC
+	digest, err := c.drv.verifyImage(ctx, cfg, c.task.Image())
+	if err != nil {
+		return err
+	}
+
+	errC := c.daemon.imgPuller.PullImage(ctx, cfg, c.task.Image(), repo, c.imgTag)
+	err = <-errC
+	if err == nil {
+		err = c.drv.checkImageDigest(ctx, c.daemon, c.task.Image(), digest)
+	}
+	c.pulled = err == nil
+	return err
+}
+
+// errImageNeverPulled is returned for running a fn whose pull policy is models.ImagePullNever on a runner without its
+// image, the image must be pre-pulled or loaded on the runner first
+func errImageNeverPulled(image string) error {
+	return models.NewCodedError(http.StatusBadGateway, "image_never_pulled", map[string]string{"image": image},
+		fmt.Errorf("Image '%s' is not present on the runner, and the pull policy of the fn is %s", image, models.ImagePullNever))
 }
 
 // implements Cookie
@@ -487,8 +759,16 @@ func (c *cookie) CreateContainer(ctx context.Context) error {
 
 	createOptions := c.opts
 	createOptions.Context = ctx
+	if c.contractVersion != 0 {
+		// copy the env, the options are reused if the container has to be created again
+		env := make([]string, 0, len(c.opts.Config.Env)+1)
+		env = append(env, c.opts.Config.Env...)
+		config := *c.opts.Config
+		config.Env = append(env, drivers.EnvContractVersion+"="+strconv.Itoa(c.contractVersion))
+		createOptions.Config = &config
+	}
 
-	c.container, err = c.drv.docker.CreateContainer(createOptions)
+	c.container, err = c.daemon.docker.CreateContainer(createOptions)
 
 	// IMPORTANT: The return code 503 here is controversial. Here we treat disk pressure as a temporary
 	// service too busy event that will likely to correct itself. Here with 503 we allow this request
@@ -508,4 +788,10 @@ func (c *cookie) CreateContainer(ctx context.Context) error {
 	return nil
 }
 
-var _ drivers.Cookie = &cookie{}
+// implements drivers.ContractCookie
+func (c *cookie) ContractVersion() int {
+	return c.contractVersion
+}
+
+var _ drivers.ContractCookie = &cookie{}
+var _ drivers.DetachableCookie = &cookie{}
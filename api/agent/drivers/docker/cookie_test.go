@@ -0,0 +1,330 @@
+package docker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/models"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+func TestFreezeSqueezesMemory(t *testing.T) {
+	ctx := context.Background()
+
+	dkr := &DockerDriver{
+		conf:    drivers.Config{FreezeMemoryPercent: 25},
+		docker:  &mockClient{},
+		network: NewDockerNetworks(drivers.Config{}),
+	}
+	mock := dkr.docker.(*mockClient)
+
+	task := createTask("test-docker-freeze")
+	cookie, err := dkr.CreateCookie(ctx, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cookie.Close(ctx)
+
+	if err := cookie.Freeze(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := cookie.Unfreeze(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mem := int(task.Memory())
+	if expected := []int{mem / 4, mem}; !reflect.DeepEqual(mock.memoryReservations, expected) {
+		t.Fatalf("expected the memory soft limit to be squeezed then restored %v, got %v", expected, mock.memoryReservations)
+	}
+
+	// unfreezing a container that was not squeezed leaves its memory alone
+	if err := cookie.Unfreeze(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.memoryReservations) != 2 {
+		t.Fatalf("expected no more memory updates, got %v", mock.memoryReservations)
+	}
+}
+
+func TestFreezeThroughCgroup(t *testing.T) {
+	ctx := context.Background()
+
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// a cgroup v2 hierarchy of the cgroupfs driver of docker
+	freezePath := filepath.Join(root, "docker", "c0ffee", "cgroup.freeze")
+	if err := os.MkdirAll(filepath.Dir(freezePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(freezePath, []byte("0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dkr := &DockerDriver{
+		conf:    drivers.Config{CgroupRoot: root},
+		docker:  &mockClient{},
+		network: NewDockerNetworks(drivers.Config{}),
+	}
+	mock := dkr.docker.(*mockClient)
+
+	c, err := dkr.CreateCookie(ctx, createTask("test-docker-cgroup-freeze"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.(*cookie).container = &docker.Container{ID: "c0ffee"}
+
+	state := func() string {
+		b, err := ioutil.ReadFile(freezePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	if err := c.Freeze(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if state() != "1" {
+		t.Fatalf("expected the cgroup to be frozen, got %s", state())
+	}
+	if err := c.Unfreeze(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if state() != "0" {
+		t.Fatalf("expected the cgroup to be thawed, got %s", state())
+	}
+	if mock.pauses != 0 {
+		t.Fatalf("expected docker not to be asked to pause, but it was %d times", mock.pauses)
+	}
+
+	// without access to the cgroup, docker pauses the container
+	if err := os.Remove(freezePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Dir(freezePath)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Freeze(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Unfreeze(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if mock.pauses != 2 {
+		t.Fatalf("expected docker to pause and unpause the container, got %d calls", mock.pauses)
+	}
+}
+
+func TestDevMode(t *testing.T) {
+	ctx := context.Background()
+
+	dkr := &DockerDriver{
+		conf:    drivers.Config{DevMode: true, EnableReadOnlyRootFs: true, FreezeMemoryPercent: 25},
+		docker:  &mockClient{},
+		network: NewDockerNetworks(drivers.Config{}),
+	}
+	mock := dkr.docker.(*mockClient)
+
+	c, err := dkr.CreateCookie(ctx, createTask("test-docker-dev-mode"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close(ctx)
+
+	opts := c.ContainerOptions().(docker.CreateContainerOptions)
+	if opts.HostConfig.ReadonlyRootfs || opts.HostConfig.Tmpfs != nil {
+		t.Fatalf("expected a writable root fs without tmpfs, got %+v", opts.HostConfig)
+	}
+
+	if err := c.Freeze(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Unfreeze(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if mock.pauses != 0 || len(mock.memoryReservations) != 0 {
+		t.Fatalf("expected freezing to leave the container alone, got %d pauses and memory updates %v", mock.pauses, mock.memoryReservations)
+	}
+}
+
+func TestGPUs(t *testing.T) {
+	ctx := context.Background()
+
+	dkr := &DockerDriver{
+		conf:    drivers.Config{GPURuntime: "nvidia"},
+		docker:  &mockClient{},
+		network: NewDockerNetworks(drivers.Config{}),
+	}
+
+	task := createTask("test-docker-gpus")
+	task.gpus = []string{"0", "2"}
+	c, err := dkr.CreateCookie(ctx, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close(ctx)
+
+	opts := c.ContainerOptions().(docker.CreateContainerOptions)
+	if opts.HostConfig.Runtime != "nvidia" {
+		t.Errorf("expected the gpu runtime, got %q", opts.HostConfig.Runtime)
+	}
+	visible := false
+	for _, env := range opts.Config.Env {
+		visible = visible || env == "NVIDIA_VISIBLE_DEVICES=0,2"
+	}
+	if !visible {
+		t.Errorf("expected the gpus to be visible to the container, got env %v", opts.Config.Env)
+	}
+
+	c, err = dkr.CreateCookie(ctx, createTask("test-docker-no-gpus"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close(ctx)
+	if opts := c.ContainerOptions().(docker.CreateContainerOptions); opts.HostConfig.Runtime != "" {
+		t.Errorf("expected containers without gpus to run with the default runtime, got %q", opts.HostConfig.Runtime)
+	}
+}
+
+func TestSecurityProfiles(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "seccomp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, profile := range map[string]string{
+		"strict":   `{"defaultAction": "SCMP_ACT_ERRNO"}`,
+		"io_uring": `{"defaultAction": "SCMP_ACT_ERRNO", "syscalls": [{"names": ["io_uring_setup"], "action": "SCMP_ACT_ALLOW"}]}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".json"), []byte(profile), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := drivers.Config{SeccompProfilesDir: dir, SeccompProfile: "strict", AppArmorProfiles: "fn-io", AppArmorProfile: "fn-default"}
+	security, err := loadSecurityProfiles(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dkr := &DockerDriver{
+		conf:     conf,
+		docker:   &mockClient{},
+		network:  NewDockerNetworks(drivers.Config{}),
+		security: security,
+	}
+
+	securityOpt := func(task *taskDockerTest) ([]string, error) {
+		c, err := dkr.CreateCookie(ctx, task)
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close(ctx)
+		return c.ContainerOptions().(docker.CreateContainerOptions).HostConfig.SecurityOpt, nil
+	}
+
+	opts, err := securityOpt(createTask("test-docker-default-profiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"no-new-privileges", `seccomp={"defaultAction":"SCMP_ACT_ERRNO"}`, "apparmor=fn-default"}
+	if !reflect.DeepEqual(opts, expected) {
+		t.Errorf("expected the default profiles %v, got %v", expected, opts)
+	}
+
+	task := createTask("test-docker-profiles")
+	task.seccomp, task.apparmor = "io_uring", "fn-io"
+	opts, err = securityOpt(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts) != 3 || !strings.Contains(opts[1], "io_uring_setup") || opts[2] != "apparmor=fn-io" {
+		t.Errorf("expected the profiles of the task, got %v", opts)
+	}
+
+	for _, profiles := range [][2]string{{"unconfined", ""}, {"", "unconfined"}} {
+		task := createTask("test-docker-unknown-profiles")
+		task.seccomp, task.apparmor = profiles[0], profiles[1]
+		if _, err := securityOpt(task); err != ErrNoSecurityProfile {
+			t.Errorf("expected profiles %v the driver does not have to be rejected, got %v", profiles, err)
+		}
+	}
+
+	if _, err := loadSecurityProfiles(drivers.Config{SeccompProfilesDir: dir, SeccompProfile: "nope"}); err == nil {
+		t.Error("expected a default seccomp profile missing from the profiles to be rejected")
+	}
+}
+
+func TestImagePullPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	mock := &mockClient{inspectImageErr: docker.ErrNoSuchImage}
+	puller := &mockClientPuller{}
+	dkr := &DockerDriver{
+		docker:    mock,
+		imgPuller: NewImagePuller(puller),
+		imgCache:  NewImageCache(nil, 1024),
+		network:   NewDockerNetworks(drivers.Config{}),
+	}
+
+	validate := func(policy string) (bool, error) {
+		task := createTask("test-docker-pull-policy")
+		task.pullPolicy = policy
+		c, err := dkr.CreateCookie(ctx, task)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close(ctx)
+		return c.ValidateImage(ctx)
+	}
+
+	if pull, err := validate(""); !pull || err != nil {
+		t.Fatalf("expected a missing image to be pulled, got %v %v", pull, err)
+	}
+	if pull, err := validate(models.ImagePullNever); pull || err == nil || !strings.Contains(err.Error(), "pull policy") {
+		t.Fatalf("expected a missing image never to be pulled, got %v %v", pull, err)
+	}
+
+	mock.inspectImage, mock.inspectImageErr = &docker.Image{ID: "busybox1", RepoTags: []string{"busybox:latest"}}, nil
+	if pull, err := validate(models.ImagePullIfNotPresent); pull || err != nil {
+		t.Fatalf("expected a present image not to be pulled, got %v %v", pull, err)
+	}
+
+	// images of fns that always pull are pulled for each container, once
+	task := createTask("test-docker-pull-always")
+	task.pullPolicy = models.ImagePullAlways
+	c, err := dkr.CreateCookie(ctx, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := commonCookiePull(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	if pull, err := c.ValidateImage(ctx); pull || err != nil || puller.numCalls != 1 {
+		t.Fatalf("expected the image to be pulled once, got %d pulls %v %v", puller.numCalls, pull, err)
+	}
+	c.Close(ctx)
+
+	if err := dkr.PrePullImage(ctx, "busybox"); err != nil {
+		t.Fatal(err)
+	}
+	if img := dkr.imgCache.Pop(); puller.numCalls != 2 || img == nil || img.ID != "busybox1" {
+		t.Fatalf("expected the pre-pulled image to be pulled and cached, got %d pulls %+v", puller.numCalls, img)
+	}
+
+	dkr.conf.DisableImagePulls = true
+	if err := dkr.PrePullImage(ctx, "busybox"); err != ErrImagePullsDisabled {
+		t.Fatalf("expected pre-pulls to need image pulls, got %v", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fnproject/fn/api/agent/drivers/stats"
@@ -64,11 +65,27 @@ type DockerDriver struct {
 	auths    map[string]driverAuthConfig
 	pool     DockerPool
 	network  *DockerNetworks
+	security *securityProfiles
 
 	instanceId string
 
 	imgCache  ImageCacher
 	imgPuller ImagePuller
+	// verifier verifies the signatures of images before they are pulled, if set
+	verifier ImageVerifier
+	// verified are the IDs of the images on the daemons the verifier admitted
+	verified sync.Map
+
+	// daemons are the docker daemons containers are run on, the first one is the daemon of docker
+	daemons     []*dockerDaemon
+	daemonsOnce sync.Once
+	daemonsLock sync.Mutex
+
+	// devGaps are the features missing in dev mode that were logged
+	devGaps sync.Map
+
+	// recoverable are the containers left running by a previous agent, which are not leaked
+	recoverable map[string]bool
 }
 
 // NewDocker implements drivers.Driver
@@ -92,6 +109,21 @@ func NewDocker(conf drivers.Config) *DockerDriver {
 		logrus.WithError(err).Fatal("couldn't initialize registry")
 	}
 
+	daemonConfigs, err := ParseDaemonConfigs(conf.DockerDaemons)
+	if err != nil {
+		logrus.WithError(err).Fatal("couldn't initialize docker daemons")
+	}
+
+	security, err := loadSecurityProfiles(conf)
+	if err != nil {
+		logrus.WithError(err).Fatal("couldn't load security profiles")
+	}
+
+	verifier, err := newCosignVerifier(conf)
+	if err != nil {
+		logrus.WithError(err).Fatal("couldn't load image trust policy")
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	driver := &DockerDriver{
 		cancel:     cancel,
@@ -100,9 +132,37 @@ func NewDocker(conf drivers.Config) *DockerDriver {
 		hostname:   hostname,
 		auths:      auths,
 		network:    NewDockerNetworks(conf),
+		security:   security,
 		instanceId: instanceId,
 		imgCache:   createImageCache(conf),
 	}
+	driver.recoverable = make(map[string]bool, len(conf.RecoverableContainers))
+	for _, id := range conf.RecoverableContainers {
+		driver.recoverable[id] = true
+	}
+	driver.imgPuller = NewImagePuller(driver.docker)
+	if verifier != nil {
+		driver.verifier = verifier
+	}
+
+	driver.getDaemons()
+	for _, c := range daemonConfigs {
+		d, err := newDockerDaemon(ctx, c, createImageCache(conf))
+		if err != nil {
+			logrus.WithError(err).Fatal("couldn't initialize docker daemons")
+		}
+		driver.daemons = append(driver.daemons, d)
+	}
+
+	if conf.DevMode {
+		logrus.Warn("docker driver in dev mode, containers are not isolated or limited as in production")
+	}
+	if conf.DisableImagePulls {
+		logrus.Info("docker driver in offline mode, only images loaded on the daemons run")
+	}
+	if conf.RequireSignedImages {
+		logrus.Info("docker driver only pulls images signed by the keys of the image trust policy")
+	}
 
 	err = checkDockerVersion(ctx, driver)
 	if err != nil {
@@ -110,12 +170,9 @@ func NewDocker(conf drivers.Config) *DockerDriver {
 	}
 
 	// start the cleanup jobs as early as possible
-	go func() {
-		killLeakedContainers(ctx, driver)
-		runImageStats(ctx, driver)
-		syncImageCleaner(ctx, driver)
-		runImageCleaner(ctx, driver)
-	}()
+	for _, d := range driver.daemons {
+		go startDaemon(ctx, driver, d)
+	}
 
 	// before we do anything else, let's pre-load requested images
 	err = loadDockerImages(ctx, driver)
@@ -123,8 +180,6 @@ func NewDocker(conf drivers.Config) *DockerDriver {
 		logrus.WithError(err).Fatalf("cannot load docker images in %s", conf.DockerLoadFile)
 	}
 
-	driver.imgPuller = NewImagePuller(driver.docker)
-
 	// finally spawn pool if enabled
 	if conf.PreForkPoolSize != 0 {
 		driver.pool = NewDockerPool(conf, driver)
@@ -141,6 +196,11 @@ func createImageCache(conf drivers.Config) ImageCacher {
 	if conf.ImageCleanMaxSize == 0 {
 		return nil
 	}
+	// images removed could not be pulled back
+	if conf.DisableImagePulls {
+		logrus.Warn("image cleaner disabled, image pulls are disabled")
+		return nil
+	}
 
 	exemptImages := strings.Fields(conf.ImageCleanExemptTags)
 	// we never want to remove prefork image
@@ -155,7 +215,7 @@ func createImageCache(conf drivers.Config) ImageCacher {
 // killLeakedContainers scans and destroys previously left over containers that were managed
 // by this docker driver. This operation is executed once and if it fails, it will not
 // retry the procedure.
-func killLeakedContainers(ctx context.Context, driver *DockerDriver) {
+func killLeakedContainers(ctx context.Context, driver *DockerDriver, daemon *dockerDaemon) {
 
 	// Label Tag is used to isolate this cleanup. If docker has other containers
 	// that are not managed by fn-agent, then this tag can make sure those containers
@@ -175,7 +235,7 @@ func killLeakedContainers(ctx context.Context, driver *DockerDriver) {
 	for limiter.Wait(ctx) == nil {
 		var err error
 		ctx, cancel := context.WithTimeout(ctx, containerListTimeout)
-		containers, err = driver.docker.ListContainers(docker.ListContainersOptions{
+		containers, err = daemon.docker.ListContainers(docker.ListContainersOptions{
 			All: true, // let's include containers that are not running, but not destroyed
 			Filters: map[string][]string{
 				"label": []string{filter},
@@ -197,6 +257,10 @@ func killLeakedContainers(ctx context.Context, driver *DockerDriver) {
 		if item.Labels[FnAgentInstanceLabel] == driver.instanceId {
 			continue
 		}
+		// and those left running for this agent to adopt
+		if driver.isRecoverable(item) {
+			continue
+		}
 
 		logger := logrus.WithFields(logrus.Fields{"container_id": item.ID, "image": item.Image, "state": item.State})
 		logger.Info("Terminating dangling docker container")
@@ -209,7 +273,7 @@ func killLeakedContainers(ctx context.Context, driver *DockerDriver) {
 		}
 
 		// If this fails, we log and continue.
-		err := driver.docker.RemoveContainer(opts)
+		err := daemon.docker.RemoveContainer(opts)
 		if err != nil {
 			logger.WithError(err).Error("cannot remove container")
 		}
@@ -219,8 +283,8 @@ func killLeakedContainers(ctx context.Context, driver *DockerDriver) {
 // syncImageCleaner lists the current images on the system and adds them to the
 // image cache. The operation is performed once during startup to ensure a
 // restart of the fn-agent keeps track of previous state.
-func syncImageCleaner(ctx context.Context, driver *DockerDriver) {
-	if driver.imgCache == nil {
+func syncImageCleaner(ctx context.Context, daemon *dockerDaemon) {
+	if daemon.imgCache == nil {
 		return
 	}
 
@@ -231,17 +295,12 @@ func syncImageCleaner(ctx context.Context, driver *DockerDriver) {
 
 	for limiter.Wait(ctx) == nil {
 		ctx, cancel := context.WithTimeout(ctx, imageListTimeout)
-		images, err := driver.docker.ListImages(docker.ListImagesOptions{Context: ctx})
+		images, err := daemon.docker.ListImages(docker.ListImagesOptions{Context: ctx})
 		cancel()
 
 		if err == nil {
 			for _, img := range images {
-				driver.imgCache.Update(&CachedImage{
-					ID:       img.ID,
-					ParentID: img.ParentID,
-					RepoTags: img.RepoTags,
-					Size:     uint64(img.Size),
-				})
+				daemon.imgCache.Update(cachedImage(img))
 			}
 			return
 		}
@@ -251,8 +310,8 @@ func syncImageCleaner(ctx context.Context, driver *DockerDriver) {
 }
 
 // runImageStats runs continuously in background to periodically sample image cleaner statistics
-func runImageStats(ctx context.Context, driver *DockerDriver) {
-	if driver.imgCache == nil {
+func runImageStats(ctx context.Context, daemon *dockerDaemon) {
+	if daemon.imgCache == nil {
 		return
 	}
 
@@ -264,7 +323,7 @@ func runImageStats(ctx context.Context, driver *DockerDriver) {
 		defer ticker.Stop()
 
 		for ctx.Err() == nil {
-			RecordImageCleanerStats(ctx, driver.imgCache.GetStats())
+			RecordImageCleanerStats(ctx, daemon.imgCache.GetStats())
 			select {
 			case <-ctx.Done(): // driver shutdown
 			case <-ticker.C:
@@ -276,8 +335,8 @@ func runImageStats(ctx context.Context, driver *DockerDriver) {
 // runImageCleaner runs continuously and monitors image cache state. If the
 // cache is over the high water mark limit, then it tries to remove least recently
 // used image.
-func runImageCleaner(ctx context.Context, driver *DockerDriver) {
-	if driver.imgCache == nil {
+func runImageCleaner(ctx context.Context, daemon *dockerDaemon) {
+	if daemon.imgCache == nil {
 		return
 	}
 
@@ -285,10 +344,10 @@ func runImageCleaner(ctx context.Context, driver *DockerDriver) {
 
 	ctx, log := common.LoggerWithFields(ctx, logrus.Fields{"stack": "runImageCleaner"})
 	limiter := rate.NewLimiter(2.0, 1)
-	notifier := driver.imgCache.GetNotifier()
+	notifier := daemon.imgCache.GetNotifier()
 
 	for limiter.Wait(ctx) == nil {
-		for !driver.imgCache.IsMaxCapacity() {
+		for !daemon.imgCache.IsMaxCapacity() {
 			select {
 			case <-ctx.Done(): // driver shutdown
 				return
@@ -296,17 +355,17 @@ func runImageCleaner(ctx context.Context, driver *DockerDriver) {
 			}
 		}
 
-		img := driver.imgCache.Pop()
+		img := daemon.imgCache.Pop()
 		if img != nil {
 			log.WithField("removedImage", img).Info("Removing image")
 
 			ctx, cancel := context.WithTimeout(ctx, removeImgTimeout)
-			err := driver.docker.RemoveImage(img.ID, docker.RemoveImageOptions{Context: ctx})
+			err := daemon.docker.RemoveImage(img.ID, docker.RemoveImageOptions{Context: ctx})
 			cancel()
 			if err != nil && err != docker.ErrNoSuchImage {
 				log.WithError(err).WithField("removedImage", img).Error("Removing image failed")
 				// in-use or can't be removed or docker just timed out, try to add it back to the cache
-				driver.imgCache.Update(img)
+				daemon.imgCache.Update(img)
 			}
 		}
 	}
@@ -317,23 +376,25 @@ func checkDockerVersion(ctx context.Context, driver *DockerDriver) error {
 		return nil
 	}
 
-	info, err := driver.docker.Info(ctx)
+	wanted, err := semver.NewVersion(driver.conf.ServerVersion)
 	if err != nil {
 		return err
 	}
 
-	actual, err := semver.NewVersion(info.ServerVersion)
-	if err != nil {
-		return err
-	}
+	for _, d := range driver.getDaemons() {
+		info, err := d.docker.Info(ctx)
+		if err != nil {
+			return err
+		}
 
-	wanted, err := semver.NewVersion(driver.conf.ServerVersion)
-	if err != nil {
-		return err
-	}
+		actual, err := semver.NewVersion(info.ServerVersion)
+		if err != nil {
+			return err
+		}
 
-	if actual.Compare(*wanted) < 0 {
-		return fmt.Errorf("docker version is too old. Required: %s Found: %s", driver.conf.ServerVersion, info.ServerVersion)
+		if actual.Compare(*wanted) < 0 {
+			return fmt.Errorf("docker version of %s daemon is too old. Required: %s Found: %s", d.name, driver.conf.ServerVersion, info.ServerVersion)
+		}
 	}
 
 	return nil
@@ -347,9 +408,21 @@ func loadDockerImages(ctx context.Context, driver *DockerDriver) error {
 	var log logrus.FieldLogger
 	ctx, log = common.LoggerWithFields(ctx, logrus.Fields{"stack": "loadDockerImages"})
 	log.Infof("Loading docker images from %v", driver.conf.DockerLoadFile)
-	return driver.docker.LoadImages(ctx, driver.conf.DockerLoadFile)
+	return driver.LoadImages(ctx, driver.conf.DockerLoadFile)
+}
+
+// LoadImages implements drivers.ImageLoader, loading the images of a docker save tarball on every daemon
+func (drv *DockerDriver) LoadImages(ctx context.Context, archive string) error {
+	for _, d := range drv.getDaemons() {
+		if err := d.docker.LoadImages(ctx, archive); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
+var _ drivers.ImageLoader = &DockerDriver{}
+
 func (drv *DockerDriver) Close() error {
 	var err error
 	if drv.pool != nil {
@@ -362,13 +435,33 @@ func (drv *DockerDriver) Close() error {
 }
 
 func (drv *DockerDriver) SetPullImageRetryPolicy(policy common.BackOffConfig, checker drivers.RetryErrorChecker) error {
-	return drv.imgPuller.SetRetryPolicy(policy, checker)
+	for _, d := range drv.getDaemons() {
+		if err := d.imgPuller.SetRetryPolicy(policy, checker); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// devGap logs, once per feature, that a docker feature missing on Docker Desktop is not used in dev mode
+func (drv *DockerDriver) devGap(feature, effect string) {
+	if _, logged := drv.devGaps.LoadOrStore(feature, true); !logged {
+		logrus.WithFields(logrus.Fields{"feature": feature}).Warnf("dev mode, %s", effect)
+	}
 }
 
 func (drv *DockerDriver) CreateCookie(ctx context.Context, task drivers.ContainerTask) (drivers.Cookie, error) {
 
 	ctx, log := common.LoggerWithFields(ctx, logrus.Fields{"stack": "CreateCookie"})
 
+	daemon, err := drv.pickDaemon(task.DaemonLabels())
+	if err != nil {
+		return nil, err
+	}
+	if !daemon.primary {
+		log = log.WithField("docker_daemon", daemon.name)
+	}
+
 	_, stdinOff := task.Input().(common.NoopReadWriteCloser)
 	stdout, stderr := task.Logger()
 	_, stdoutOff := stdout.(common.NoopReadWriteCloser)
@@ -392,18 +485,21 @@ func (drv *DockerDriver) CreateCookie(ctx context.Context, task drivers.Containe
 	}
 
 	cookie := &cookie{
-		opts: opts,
-		task: task,
-		drv:  drv,
+		opts:   opts,
+		task:   task,
+		drv:    drv,
+		daemon: daemon,
 	}
 
 	// Order is important, eg. Hostname doesn't play well with Network config
 	cookie.configureLabels(log)
 	cookie.configureLogger(log)
 	cookie.configureMem(log)
+	cookie.configureStop(log)
 	cookie.configureCmd(log)
 	cookie.configureEnv(log)
 	cookie.configureCPU(log)
+	cookie.configureGPUs(log)
 	cookie.configureFsSize(log)
 	cookie.configurePIDs(log)
 	cookie.configureULimits(log)
@@ -411,21 +507,85 @@ func (drv *DockerDriver) CreateCookie(ctx context.Context, task drivers.Containe
 	cookie.configureVolumes(log)
 	cookie.configureWorkDir(log)
 	cookie.configureIOFS(log)
-	cookie.configureNetwork(log)
+	if err := cookie.configureNetwork(log); err != nil {
+		drv.releaseDaemon(daemon)
+		return nil, err
+	}
 	cookie.configureHostname(log)
 	cookie.configureImage(log)
-	cookie.configureSecurity(log)
+	if err := cookie.configureSecurity(log); err != nil {
+		cookie.Close(ctx)
+		return nil, err
+	}
 
 	return cookie, nil
 }
 
+// isRecoverable returns whether a container was left running for the agent to adopt
+func (drv *DockerDriver) isRecoverable(item docker.APIContainers) bool {
+	for _, name := range item.Names {
+		if drv.recoverable[strings.TrimPrefix(name, "/")] {
+			return true
+		}
+	}
+	return false
+}
+
+// AdoptCookie implements drivers.Recoverer. The container must still be running, and be labeled as the containers
+// of the agent are.
+func (drv *DockerDriver) AdoptCookie(ctx context.Context, task drivers.ContainerTask) (drivers.Cookie, error) {
+	ctx, log := common.LoggerWithFields(ctx, logrus.Fields{"stack": "AdoptCookie", "call_id": task.Id()})
+
+	if drv.pool != nil {
+		// the network namespaces of the pool did not survive the agent
+		return nil, errors.New("containers of a prefork pool cannot be adopted")
+	}
+
+	c, err := drv.CreateCookie(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	cookie := c.(*cookie)
+
+	container, err := cookie.daemon.docker.InspectContainerWithContext(task.Id(), ctx)
+	if err == nil && (!container.State.Running || container.Config == nil ||
+		container.Config.Labels[FnAgentClassifierLabel] != drv.conf.ContainerLabelTag) {
+		err = fmt.Errorf("container %s is not running or not labeled as a container of the agent", task.Id())
+	}
+	if err == nil && container.State.Paused {
+		err = cookie.daemon.docker.UnpauseContainer(task.Id(), ctx)
+	}
+	if err != nil {
+		cookie.Close(ctx)
+		return nil, err
+	}
+
+	log.Debug("docker adopt container")
+	cookie.container = container
+	cookie.adopted = true
+	return cookie, nil
+}
+
+// RemoveContainer implements drivers.Recoverer, removing the container from whichever daemon runs it
+func (drv *DockerDriver) RemoveContainer(ctx context.Context, id string) error {
+	var err error
+	for _, d := range drv.getDaemons() {
+		err = d.docker.RemoveContainer(docker.RemoveContainerOptions{ID: id, Force: true, RemoveVolumes: true, Context: ctx})
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
 func (drv *DockerDriver) GetSlotKeyExtensions(extn map[string]string) string {
 	return ""
 }
 
 // Run executes the docker container. If task runs, drivers.RunResult will be returned. If something fails outside the task (ie: Docker), it will return error.
 // The docker driver will attempt to cast the task to a Auther. If that succeeds, private image support is available. See the Auther interface for how to implement this.
-func (drv *DockerDriver) run(ctx context.Context, container string, task drivers.ContainerTask) (drivers.WaitResult, error) {
+// Containers that were adopted are only attached to, they are running already.
+func (drv *DockerDriver) run(ctx context.Context, daemon *dockerDaemon, container string, task drivers.ContainerTask, adopted bool) (drivers.WaitResult, error) {
 
 	log := common.Logger(ctx)
 	stdout, stderr := task.Logger()
@@ -435,7 +595,7 @@ func (drv *DockerDriver) run(ctx context.Context, container string, task drivers
 	_, stdoutOff := stdout.(common.NoopReadWriteCloser)
 	_, stderrOff := stderr.(common.NoopReadWriteCloser)
 
-	waiter, err := drv.docker.AttachToContainerNonBlocking(ctx, docker.AttachToContainerOptions{
+	waiter, err := daemon.docker.AttachToContainerNonBlocking(ctx, docker.AttachToContainerOptions{
 		Container:    container,
 		InputStream:  task.Input(),
 		OutputStream: stdout,
@@ -480,9 +640,11 @@ func (drv *DockerDriver) run(ctx context.Context, container string, task drivers
 	// we want to stop trying to collect stats when the container exits
 	// collectStats will stop when stopSignal is closed or ctx is cancelled
 	stopSignal := make(chan struct{})
-	go drv.collectStats(ctx, stopSignal, container, task)
+	go drv.collectStats(ctx, daemon, stopSignal, container, task)
 
-	err = drv.docker.StartContainerWithContext(container, nil, ctx)
+	if !adopted {
+		err = daemon.docker.StartContainerWithContext(container, nil, ctx)
+	}
 	if err != nil && ctx.Err() == nil {
 		if isSyslogError(err) {
 			// syslog error is a func error
@@ -496,7 +658,7 @@ func (drv *DockerDriver) run(ctx context.Context, container string, task drivers
 	return &waitResult{
 		container: container,
 		waiter:    waiter,
-		drv:       drv,
+		docker:    daemon.docker,
 		done:      stopSignal,
 	}, nil
 }
@@ -512,7 +674,7 @@ func isSyslogError(err error) bool {
 type waitResult struct {
 	container string
 	waiter    docker.CloseWaiter
-	drv       *DockerDriver
+	docker    dockerClient
 	done      chan struct{}
 }
 
@@ -529,7 +691,7 @@ func (w *waitResult) Wait(ctx context.Context) drivers.RunResult {
 }
 
 // Repeatedly collect stats from the specified docker container until the stopSignal is closed or the context is cancelled
-func (drv *DockerDriver) collectStats(ctx context.Context, stopSignal <-chan struct{}, container string, task drivers.ContainerTask) {
+func (drv *DockerDriver) collectStats(ctx context.Context, daemon *dockerDaemon, stopSignal <-chan struct{}, container string, task drivers.ContainerTask) {
 	ctx, span := trace.StartSpan(ctx, "docker_collect_stats")
 	defer span.End()
 
@@ -545,7 +707,7 @@ func (drv *DockerDriver) collectStats(ctx context.Context, stopSignal <-chan str
 		// the memory overhead is < 1MB for 3600 stat points so this seems fine, seems better to stream
 		// (internal docker api streams) than open/close stream for 1 sample over and over.
 		// must be called in goroutine, docker.Stats() blocks
-		err := drv.docker.Stats(docker.StatsOptions{
+		err := daemon.docker.Stats(docker.StatsOptions{
 			ID:      container,
 			Stats:   dstats,
 			Stream:  true,
@@ -558,6 +720,15 @@ func (drv *DockerDriver) collectStats(ctx context.Context, stopSignal <-chan str
 		}
 	}()
 
+	// fs usage is not part of docker stats, it is sampled less often as it is costly to compute
+	var fsTick <-chan time.Time
+	if drv.conf.FsStatsIntervalMsecs > 0 {
+		ticker := time.NewTicker(time.Duration(drv.conf.FsStatsIntervalMsecs) * time.Millisecond)
+		defer ticker.Stop()
+		fsTick = ticker.C
+	}
+	tmpFs := drv.hasTmpFs(task)
+
 	// collect stats until context is done (i.e. until the container is terminated)
 	for {
 		select {
@@ -573,6 +744,13 @@ func (drv *DockerDriver) collectStats(ctx context.Context, stopSignal <-chan str
 			if !time.Time(stats.Timestamp).IsZero() {
 				task.WriteStat(ctx, stats)
 			}
+		case <-fsTick:
+			usage, err := drv.fsUsage(ctx, daemon, container, tmpFs)
+			if err != nil {
+				log.WithError(err).WithFields(logrus.Fields{"container": container, "call_id": task.Id()}).Debug("error sampling fs usage for task")
+				continue
+			}
+			task.WriteStat(ctx, fsUsageStat(usage, tmpFs))
 		}
 	}
 }
@@ -632,7 +810,7 @@ func cherryPick(ds *docker.Stats) stats.Stat {
 }
 
 func (w *waitResult) wait(ctx context.Context) (status string, err error) {
-	exitCode, waitErr := w.drv.docker.WaitContainerWithContext(w.container, ctx)
+	exitCode, waitErr := w.docker.WaitContainerWithContext(w.container, ctx)
 	if waitErr != nil {
 		log := common.Logger(ctx)
 		log.WithError(waitErr).WithFields(logrus.Fields{"container": w.container}).Error("error waiting container with context")
@@ -676,6 +854,7 @@ func (w *waitResult) wait(ctx context.Context) (status string, err error) {
 }
 
 var _ drivers.Driver = &DockerDriver{}
+var _ drivers.Recoverer = &DockerDriver{}
 
 func init() {
 	drivers.Register("docker", func(config drivers.Config) (drivers.Driver, error) {
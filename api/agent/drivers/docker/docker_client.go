@@ -30,12 +30,15 @@ type dockerClient interface {
 	WaitContainerWithContext(id string, ctx context.Context) (int, error)
 	StartContainerWithContext(id string, hostConfig *docker.HostConfig, ctx context.Context) error
 	KillContainer(opts docker.KillContainerOptions) error
+	StopContainerWithContext(id string, timeout uint, ctx context.Context) error
 	CreateContainer(opts docker.CreateContainerOptions) (*docker.Container, error)
 	RemoveContainer(opts docker.RemoveContainerOptions) error
 	PauseContainer(id string, ctx context.Context) error
 	UnpauseContainer(id string, ctx context.Context) error
+	UpdateContainer(id string, opts docker.UpdateContainerOptions) error
 	PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error
 	InspectImage(ctx context.Context, name string) (*docker.Image, error)
+	InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error)
 	ListImages(opts docker.ListImagesOptions) ([]docker.APIImages, error)
 	RemoveImage(id string, opts docker.RemoveImageOptions) error
 	Stats(opts docker.StatsOptions) error
@@ -89,6 +92,9 @@ var (
 	imageCleanerIdleImgSize  = common.MakeMeasure("image_cleaner_idle_img_size", "image cleaner idle image total size", "By")
 	imageCleanerMaxImgSize   = common.MakeMeasure("image_cleaner_max_img_size", "image cleaner image max size", "By")
 
+	imageCleanerPinnedImgCount = common.MakeMeasure("image_cleaner_pinned_img_count", "image cleaner pinned idle image count", "")
+	imageCleanerPinnedImgSize  = common.MakeMeasure("image_cleaner_pinned_img_size", "image cleaner pinned idle image total size", "By")
+
 	dockerInstanceId = common.MakeMeasure("docker_instance_id", "docker instance id", "")
 )
 
@@ -105,6 +111,8 @@ func RecordImageCleanerStats(ctx context.Context, sample *ImageCacherStats) {
 	stats.Record(ctx, imageCleanerIdleImgCount.M(int64(sample.IdleImgCount)))
 	stats.Record(ctx, imageCleanerIdleImgSize.M(int64(sample.IdleImgTotalSize)))
 	stats.Record(ctx, imageCleanerMaxImgSize.M(int64(sample.MaxImgTotalSize)))
+	stats.Record(ctx, imageCleanerPinnedImgCount.M(int64(sample.PinnedImgCount)))
+	stats.Record(ctx, imageCleanerPinnedImgSize.M(int64(sample.PinnedImgTotalSize)))
 }
 
 // listenEventLoop listens for docker events and reconnects if necessary
@@ -258,6 +266,8 @@ func RegisterViews(tagKeys []string, latencyDist []float64) {
 		common.CreateViewWithTags(imageCleanerIdleImgCount, view.LastValue(), emptyTags),
 		common.CreateViewWithTags(imageCleanerIdleImgSize, view.LastValue(), emptyTags),
 		common.CreateViewWithTags(imageCleanerMaxImgSize, view.LastValue(), emptyTags),
+		common.CreateViewWithTags(imageCleanerPinnedImgCount, view.LastValue(), emptyTags),
+		common.CreateViewWithTags(imageCleanerPinnedImgSize, view.LastValue(), emptyTags),
 		common.CreateViewWithTags(dockerInstanceId, view.LastValue(), emptyTags),
 	)
 	if err != nil {
@@ -362,6 +372,13 @@ func (d *dockerWrap) KillContainer(opts docker.KillContainerOptions) (err error)
 	return err
 }
 
+func (d *dockerWrap) StopContainerWithContext(id string, timeout uint, ctx context.Context) (err error) {
+	ctx, closer := makeTracker(ctx, "docker_stop_container")
+	defer func() { closer(err) }()
+	err = d.docker.StopContainerWithContext(id, timeout, ctx)
+	return err
+}
+
 func (d *dockerWrap) PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) (err error) {
 	_, closer := makeTracker(opts.Context, "docker_pull_image")
 	defer func() { closer(err) }()
@@ -397,6 +414,13 @@ func (d *dockerWrap) UnpauseContainer(id string, ctx context.Context) (err error
 	return err
 }
 
+func (d *dockerWrap) UpdateContainer(id string, opts docker.UpdateContainerOptions) (err error) {
+	_, closer := makeTracker(opts.Context, "docker_update_container")
+	defer func() { closer(err) }()
+	err = d.docker.UpdateContainer(id, opts)
+	return err
+}
+
 func (d *dockerWrap) InspectImage(ctx context.Context, name string) (img *docker.Image, err error) {
 	_, closer := makeTracker(ctx, "docker_inspect_image")
 	defer func() { closer(err) }()
@@ -404,6 +428,13 @@ func (d *dockerWrap) InspectImage(ctx context.Context, name string) (img *docker
 	return img, err
 }
 
+func (d *dockerWrap) InspectContainerWithContext(id string, ctx context.Context) (c *docker.Container, err error) {
+	_, closer := makeTracker(ctx, "docker_inspect_container")
+	defer func() { closer(err) }()
+	c, err = d.docker.InspectContainerWithContext(id, ctx)
+	return c, err
+}
+
 func (d *dockerWrap) Stats(opts docker.StatsOptions) (err error) {
 	_, closer := makeTracker(opts.Context, "docker_stats")
 	defer func() { closer(err) }()
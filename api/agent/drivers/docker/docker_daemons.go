@@ -0,0 +1,185 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultDaemonName is the name of the docker daemon of DOCKER_HOST
+const DefaultDaemonName = "default"
+
+// ErrNoDockerDaemon is returned when no docker daemon has the labels a fn asks for
+var ErrNoDockerDaemon = models.NewAPIError(http.StatusBadRequest, errors.New("no docker daemon of this runner has the labels the fn asks for"))
+
+// DaemonConfig is an extra docker daemon containers may be run on, as listed in the JSON of
+// drivers.Config.DockerDaemons
+type DaemonConfig struct {
+	// Name identifies the daemon in logs and metrics
+	Name string `json:"name"`
+	// Endpoint of the daemon, eg. unix:///var/run/docker-acme.sock or tcp://127.0.0.1:2376
+	Endpoint string `json:"endpoint"`
+	// Labels are what fns must ask for to be run on the daemon, see models.FnDockerDaemonAnnotation. A daemon
+	// without labels runs the fns that ask for none.
+	Labels map[string]string `json:"labels,omitempty"`
+	// MaxContainers is how many containers the daemon may run at once, 0 for no limit
+	MaxContainers uint64 `json:"max_containers,omitempty"`
+}
+
+// ParseDaemonConfigs parses the JSON list of extra docker daemons
+func ParseDaemonConfigs(daemons string) ([]DaemonConfig, error) {
+	if daemons == "" {
+		return nil, nil
+	}
+	var configs []DaemonConfig
+	if err := json.Unmarshal([]byte(daemons), &configs); err != nil {
+		return nil, fmt.Errorf("invalid docker daemons: %v", err)
+	}
+	names := map[string]bool{DefaultDaemonName: true}
+	for _, c := range configs {
+		if c.Name == "" || c.Endpoint == "" {
+			return nil, errors.New("invalid docker daemons: each needs a name and an endpoint")
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("invalid docker daemons: %s is used twice", c.Name)
+		}
+		names[c.Name] = true
+	}
+	return configs, nil
+}
+
+// dockerDaemon is a docker daemon containers are run on. Each daemon has its own images, so its own image cache
+// and puller, and counts the containers it runs against its capacity.
+type dockerDaemon struct {
+	name          string
+	labels        map[string]string
+	maxContainers uint64
+	// primary is the daemon of DOCKER_HOST, the pre-fork pool only runs on it
+	primary bool
+
+	docker    dockerClient
+	imgCache  ImageCacher
+	imgPuller ImagePuller
+
+	// containers is the number of cookies on the daemon, guarded by the daemons lock of the driver
+	containers uint64
+}
+
+// newDockerDaemon connects to the daemon of c
+func newDockerDaemon(ctx context.Context, c DaemonConfig, imgCache ImageCacher) (*dockerDaemon, error) {
+	client, err := docker.NewClient(c.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint of docker daemon %s: %v", c.Name, err)
+	}
+	if err := client.Ping(); err != nil {
+		return nil, fmt.Errorf("couldn't connect to docker daemon %s: %v", c.Name, err)
+	}
+	wrap := &dockerWrap{docker: client}
+	go wrap.listenEventLoop(ctx)
+
+	return &dockerDaemon{
+		name:          c.Name,
+		labels:        c.Labels,
+		maxContainers: c.MaxContainers,
+		docker:        wrap,
+		imgCache:      imgCache,
+		imgPuller:     NewImagePuller(wrap),
+	}, nil
+}
+
+// matches reports whether the daemon runs containers that ask for labels
+func (d *dockerDaemon) matches(labels map[string]string) bool {
+	if len(labels) == 0 {
+		return len(d.labels) == 0
+	}
+	for k, v := range labels {
+		if l, ok := d.labels[k]; !ok || l != v {
+			return false
+		}
+	}
+	return true
+}
+
+// roomier reports whether d has more room for containers than other, daemons without a limit have the most
+func (d *dockerDaemon) roomier(other *dockerDaemon) bool {
+	if d.maxContainers == 0 || other.maxContainers == 0 {
+		if d.maxContainers != other.maxContainers {
+			return d.maxContainers == 0
+		}
+		return d.containers < other.containers
+	}
+	return d.maxContainers-d.containers > other.maxContainers-other.containers
+}
+
+// getDaemons returns the daemons of the driver, the daemon of its docker client if it was not given any
+func (drv *DockerDriver) getDaemons() []*dockerDaemon {
+	drv.daemonsOnce.Do(func() {
+		if len(drv.daemons) == 0 {
+			drv.daemons = []*dockerDaemon{{
+				name:      DefaultDaemonName,
+				primary:   true,
+				docker:    drv.docker,
+				imgCache:  drv.imgCache,
+				imgPuller: drv.imgPuller,
+			}}
+		}
+	})
+	return drv.daemons
+}
+
+// pickDaemon reserves room for a container on the daemon with the most room of those that have labels
+func (drv *DockerDriver) pickDaemon(labels map[string]string) (*dockerDaemon, error) {
+	daemons := drv.getDaemons()
+
+	drv.daemonsLock.Lock()
+	defer drv.daemonsLock.Unlock()
+
+	var picked *dockerDaemon
+	matched := false
+	for _, d := range daemons {
+		if !d.matches(labels) {
+			continue
+		}
+		matched = true
+		if d.maxContainers != 0 && d.containers >= d.maxContainers {
+			continue
+		}
+		if picked == nil || d.roomier(picked) {
+			picked = d
+		}
+	}
+	if !matched {
+		return nil, ErrNoDockerDaemon
+	}
+	if picked == nil {
+		// try another runner, or later once containers exit
+		return nil, models.ErrCallTimeoutServerBusy
+	}
+	picked.containers++
+	return picked, nil
+}
+
+// releaseDaemon frees the room of a container on d
+func (drv *DockerDriver) releaseDaemon(d *dockerDaemon) {
+	drv.daemonsLock.Lock()
+	d.containers--
+	drv.daemonsLock.Unlock()
+}
+
+// startDaemon runs the cleanup jobs of a daemon
+func startDaemon(ctx context.Context, driver *DockerDriver, d *dockerDaemon) {
+	if !d.primary {
+		ctx, _ = common.LoggerWithFields(ctx, logrus.Fields{"docker_daemon": d.name})
+	}
+	killLeakedContainers(ctx, driver, d)
+	runImageStats(ctx, d)
+	syncImageCleaner(ctx, d)
+	runImageCleaner(ctx, d)
+}
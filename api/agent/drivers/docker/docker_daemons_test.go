@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestParseDaemonConfigs(t *testing.T) {
+	configs, err := ParseDaemonConfigs(`[{"name": "acme", "endpoint": "unix:///var/run/docker-acme.sock", "labels": {"tenant": "acme"}, "max_containers": 10}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 || configs[0].Labels["tenant"] != "acme" || configs[0].MaxContainers != 10 {
+		t.Fatalf("unexpected configs %+v", configs)
+	}
+
+	for _, daemons := range []string{
+		`{}`,
+		`[{"name": "acme"}]`,
+		`[{"name": "default", "endpoint": "unix:///var/run/docker-acme.sock"}]`,
+		`[{"name": "a", "endpoint": "tcp://a:2376"}, {"name": "a", "endpoint": "tcp://b:2376"}]`,
+	} {
+		if _, err := ParseDaemonConfigs(daemons); err == nil {
+			t.Errorf("expected %s to be invalid", daemons)
+		}
+	}
+}
+
+func TestPickDaemon(t *testing.T) {
+	drv := &DockerDriver{docker: &mockClient{}}
+	drv.getDaemons()
+	drv.daemons = append(drv.daemons,
+		&dockerDaemon{name: "acme-1", labels: map[string]string{"tenant": "acme", "storage": "ssd"}, maxContainers: 1},
+		&dockerDaemon{name: "acme-2", labels: map[string]string{"tenant": "acme"}, maxContainers: 2},
+	)
+
+	pick := func(labels map[string]string) (string, error) {
+		d, err := drv.pickDaemon(labels)
+		if err != nil {
+			return "", err
+		}
+		return d.name, nil
+	}
+
+	// fns that ask for no labels only run on daemons without labels
+	if name, err := pick(nil); err != nil || name != DefaultDaemonName {
+		t.Fatalf("expected the default daemon, got %s %v", name, err)
+	}
+	// the daemon with the most room is picked
+	if name, err := pick(map[string]string{"tenant": "acme"}); err != nil || name != "acme-2" {
+		t.Fatalf("expected acme-2, got %s %v", name, err)
+	}
+	if name, err := pick(map[string]string{"tenant": "acme"}); err != nil || name != "acme-1" {
+		t.Fatalf("expected acme-1, got %s %v", name, err)
+	}
+	if name, err := pick(map[string]string{"tenant": "acme"}); err != nil || name != "acme-2" {
+		t.Fatalf("expected acme-2, got %s %v", name, err)
+	}
+	if _, err := pick(map[string]string{"tenant": "acme"}); err != models.ErrCallTimeoutServerBusy {
+		t.Fatalf("expected full daemons to be busy, got %v", err)
+	}
+	if _, err := pick(map[string]string{"tenant": "other"}); err != ErrNoDockerDaemon {
+		t.Fatalf("expected no daemon to have the labels, got %v", err)
+	}
+
+	drv.releaseDaemon(drv.daemons[1])
+	if name, err := pick(map[string]string{"storage": "ssd"}); err != nil || name != "acme-1" {
+		t.Fatalf("expected the released acme-1, got %s %v", name, err)
+	}
+}
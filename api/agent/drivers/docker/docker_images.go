@@ -0,0 +1,214 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrImageCleanerDisabled is returned for pinning images on a driver that never evicts them
+	ErrImageCleanerDisabled = models.NewAPIError(http.StatusNotFound, errors.New("The image cleaner is not enabled on this runner, images are never evicted"))
+	// ErrImageNotPinned is returned for unpinning a reference that is not pinned
+	ErrImageNotPinned = models.NewAPIError(http.StatusNotFound, errors.New("Image reference not pinned"))
+	// ErrImageNotFound is returned for evicting an image the daemons do not have
+	ErrImageNotFound = models.NewAPIError(http.StatusNotFound, errors.New("Image not found"))
+	// ErrImageInUse is returned for evicting an image containers are using
+	ErrImageInUse = models.NewAPIError(http.StatusConflict, errors.New("Image in use, it cannot be evicted"))
+	// ErrImagePinned is returned for evicting a pinned image
+	ErrImagePinned = models.NewAPIError(http.StatusConflict, errors.New("Image pinned, it must be unpinned to be evicted"))
+	// ErrImagePullsDisabled is returned for pre-pulling images on a driver that never pulls them
+	ErrImagePullsDisabled = models.NewAPIError(http.StatusNotFound, errors.New("Image pulls are disabled on this runner, images must be loaded"))
+)
+
+var _ drivers.ImageCache = &DockerDriver{}
+var _ drivers.ImagePrePuller = &DockerDriver{}
+
+// cachedImage returns the image cache entry of an image listed by docker
+func cachedImage(img docker.APIImages) *CachedImage {
+	return &CachedImage{
+		ID:          img.ID,
+		ParentID:    img.ParentID,
+		RepoTags:    img.RepoTags,
+		RepoDigests: img.RepoDigests,
+		Size:        uint64(img.Size),
+	}
+}
+
+// imageCaches returns the distinct image caches of the daemons, daemons may share the cache of the driver
+func (drv *DockerDriver) imageCaches() []ImageCacher {
+	var caches []ImageCacher
+	seen := make(map[ImageCacher]bool)
+	for _, d := range drv.getDaemons() {
+		if d.imgCache != nil && !seen[d.imgCache] {
+			seen[d.imgCache] = true
+			caches = append(caches, d.imgCache)
+		}
+	}
+	return caches
+}
+
+// ListCachedImages implements drivers.ImageCache, listing the images of every daemon with their state in its cache
+func (drv *DockerDriver) ListCachedImages(ctx context.Context) (*drivers.CachedImages, error) {
+	images := &drivers.CachedImages{Items: []drivers.CachedImage{}, Pins: []string{}}
+	daemons := drv.getDaemons()
+	for _, d := range daemons {
+		list, err := d.docker.ListImages(docker.ListImagesOptions{Context: ctx})
+		if err != nil {
+			return nil, err
+		}
+		for _, img := range list {
+			item := drivers.CachedImage{ID: img.ID, Tags: img.RepoTags, Digests: img.RepoDigests, Size: uint64(img.Size)}
+			if len(daemons) > 1 {
+				item.Daemon = d.name
+			}
+			if d.imgCache != nil {
+				state := d.imgCache.State(cachedImage(img))
+				item.InUse, item.Pinned, item.Exempt = state.InUse, state.Pinned, state.Exempt
+			}
+			images.Items = append(images.Items, item)
+		}
+	}
+	if caches := drv.imageCaches(); len(caches) > 0 {
+		images.Pins = caches[0].Pins()
+	}
+	return images, nil
+}
+
+// PinImage implements drivers.ImageCache, pinning ref in the caches of all daemons
+func (drv *DockerDriver) PinImage(ctx context.Context, ref string) error {
+	caches := drv.imageCaches()
+	if len(caches) == 0 {
+		return ErrImageCleanerDisabled
+	}
+	for _, c := range caches {
+		c.Pin(ref)
+	}
+	return nil
+}
+
+// SetImageCacheMaxSize implements drivers.ImageCache, changing the size the caches of all daemons evict images past
+func (drv *DockerDriver) SetImageCacheMaxSize(size uint64) error {
+	caches := drv.imageCaches()
+	if len(caches) == 0 {
+		return ErrImageCleanerDisabled
+	}
+	for _, c := range caches {
+		c.SetMaxSize(size)
+	}
+	return nil
+}
+
+// UnpinImage implements drivers.ImageCache
+func (drv *DockerDriver) UnpinImage(ctx context.Context, ref string) error {
+	caches := drv.imageCaches()
+	if len(caches) == 0 {
+		return ErrImageCleanerDisabled
+	}
+	pinned := false
+	for _, c := range caches {
+		pinned = c.Unpin(ref) || pinned
+	}
+	if !pinned {
+		return ErrImageNotPinned
+	}
+	return nil
+}
+
+// EvictImage implements drivers.ImageCache, removing the images matching ref from every daemon. Nothing is removed if
+// any of them is in use or pinned.
+func (drv *DockerDriver) EvictImage(ctx context.Context, ref string) error {
+	type eviction struct {
+		daemon *dockerDaemon
+		img    *CachedImage
+	}
+	var evictions []eviction
+	for _, d := range drv.getDaemons() {
+		list, err := d.docker.ListImages(docker.ListImagesOptions{Context: ctx})
+		if err != nil {
+			return err
+		}
+		for _, item := range list {
+			img := cachedImage(item)
+			if !img.matches(ref) {
+				continue
+			}
+			if d.imgCache != nil {
+				if state := d.imgCache.State(img); state.InUse > 0 {
+					return ErrImageInUse
+				} else if state.Pinned {
+					return ErrImagePinned
+				}
+			}
+			evictions = append(evictions, eviction{d, img})
+		}
+	}
+	if len(evictions) == 0 {
+		return ErrImageNotFound
+	}
+
+	log := common.Logger(ctx).WithFields(logrus.Fields{"stack": "EvictImage", "image": ref})
+	for _, e := range evictions {
+		// the image may have been taken by a container since it was listed
+		if e.daemon.imgCache != nil && !e.daemon.imgCache.Remove(e.img) {
+			return ErrImageInUse
+		}
+		log.WithFields(logrus.Fields{"daemon": e.daemon.name, "image_id": e.img.ID}).Info("Evicting image")
+		err := e.daemon.docker.RemoveImage(e.img.ID, docker.RemoveImageOptions{Context: ctx})
+		if err != nil && err != docker.ErrNoSuchImage {
+			if e.daemon.imgCache != nil {
+				e.daemon.imgCache.Update(e.img)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// PrePullImage implements drivers.ImagePrePuller, pulling image on every daemon with the registry credentials of the
+// driver. Pre-pulled images are candidates for eviction as any other, unless they are pinned.
+func (drv *DockerDriver) PrePullImage(ctx context.Context, image string) error {
+	if drv.conf.DisableImagePulls {
+		return ErrImagePullsDisabled
+	}
+	reg, repo, tag := drivers.ParseImage(image)
+	cfg := findRegistryConfig(reg, drv.auths)
+
+	digest, err := drv.verifyImage(ctx, cfg, image)
+	if err != nil {
+		return err
+	}
+
+	log := common.Logger(ctx).WithFields(logrus.Fields{"stack": "PrePullImage", "image": image})
+	for _, d := range drv.getDaemons() {
+		log.WithField("daemon", d.name).Info("Pre-pulling image")
+		if err := <-d.imgPuller.PullImage(ctx, cfg, image, path.Join(reg, repo), tag); err != nil {
+			return err
+		}
+		if err := drv.checkImageDigest(ctx, d, image, digest); err != nil {
+			return err
+		}
+		if d.imgCache == nil {
+			continue
+		}
+		img, err := d.docker.InspectImage(ctx, image)
+		if err != nil {
+			return err
+		}
+		d.imgCache.Update(&CachedImage{
+			ID:          img.ID,
+			ParentID:    img.Parent,
+			RepoTags:    img.RepoTags,
+			RepoDigests: img.RepoDigests,
+			Size:        uint64(img.Size),
+		})
+	}
+	return nil
+}
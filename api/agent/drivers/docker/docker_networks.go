@@ -1,13 +1,19 @@
 package docker
 
 import (
+	"errors"
 	"math"
+	"net/http"
 	"strings"
 	"sync"
 
 	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/models"
 )
 
+// ErrNoDockerNetwork is returned for containers allowed on networks the driver does not have
+var ErrNoDockerNetwork = models.NewAPIError(http.StatusBadRequest, errors.New("none of the docker networks allowed for the fn are available"))
+
 type DockerNetworks struct {
 	// protects networks map
 	networksLock sync.Mutex
@@ -27,26 +33,41 @@ func NewDockerNetworks(conf drivers.Config) *DockerNetworks {
 	return obj
 }
 
-// pick least used network
-func (n *DockerNetworks) AllocNetwork() string {
-	if len(n.networks) == 0 {
-		return ""
+// pick least used network, among allowed unless it is empty
+func (n *DockerNetworks) AllocNetwork(allowed []string) (string, error) {
+	if len(n.networks) == 0 && len(allowed) == 0 {
+		return "", nil
 	}
 
 	var id string
 	min := uint64(math.MaxUint64)
 
 	n.networksLock.Lock()
+	defer n.networksLock.Unlock()
 	for key, val := range n.networks {
-		if val < min {
+		if val < min && isAllowedNetwork(allowed, key) {
 			id = key
 			min = val
 		}
 	}
+	if id == "" {
+		return "", ErrNoDockerNetwork
+	}
 	n.networks[id]++
-	n.networksLock.Unlock()
 
-	return id
+	return id, nil
+}
+
+func isAllowedNetwork(allowed []string, id string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == id {
+			return true
+		}
+	}
+	return false
 }
 
 // unregister network
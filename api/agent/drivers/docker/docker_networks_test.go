@@ -0,0 +1,27 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+)
+
+func TestAllocNetwork(t *testing.T) {
+	n := NewDockerNetworks(drivers.Config{DockerNetworks: "tenant-a tenant-b shared"})
+
+	id, err := n.AllocNetwork([]string{"tenant-a", "unknown"})
+	if err != nil || id != "tenant-a" {
+		t.Fatalf("expected the allowed network, got %s %v", id, err)
+	}
+	if _, err := n.AllocNetwork([]string{"unknown"}); err != ErrNoDockerNetwork {
+		t.Fatalf("expected no allowed network to be available, got %v", err)
+	}
+	// the least used network is picked
+	if id, err := n.AllocNetwork([]string{"tenant-a", "tenant-b"}); err != nil || id != "tenant-b" {
+		t.Fatalf("expected tenant-b, got %s %v", id, err)
+	}
+
+	if id, err := NewDockerNetworks(drivers.Config{}).AllocNetwork(nil); err != nil || id != "" {
+		t.Fatalf("expected no network without networks, got %s %v", id, err)
+	}
+}
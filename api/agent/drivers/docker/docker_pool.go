@@ -291,6 +291,10 @@ func (pool *dockerPool) prepareImage(ctx context.Context, driver *DockerDriver,
 		if err != docker.ErrNoSuchImage {
 			log.WithError(err).Fatal("prefork pool image inspect failed")
 		}
+		if driver.conf.DisableImagePulls {
+			log.WithField("image", img).Error("prefork pool image not loaded, and image pulls are disabled")
+			return
+		}
 
 		err = driver.docker.PullImage(opts, *config)
 		if err == nil {
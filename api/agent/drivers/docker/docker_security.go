@@ -0,0 +1,114 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/models"
+)
+
+// ErrNoSecurityProfile is returned for containers asking for a seccomp or AppArmor profile the driver does not have
+var ErrNoSecurityProfile = models.NewAPIError(http.StatusBadRequest, errors.New("the security profile the app policy names is not a profile of this runner"))
+
+// securityProfiles are the seccomp and AppArmor profiles containers may run with, and the ones they run with by
+// default. A nil securityProfiles has none, containers run with the defaults of docker.
+type securityProfiles struct {
+	// seccomp are the seccomp profiles by name, as the JSON docker takes in the seccomp security option
+	seccomp        map[string]string
+	defaultSeccomp string
+	// apparmor are the names of the AppArmor profiles loaded on the host
+	apparmor        map[string]bool
+	defaultAppArmor string
+}
+
+// loadSecurityProfiles reads the seccomp profiles of the SeccompProfilesDir of conf and checks that its default
+// profiles are among them, it returns nil if conf has no profiles
+func loadSecurityProfiles(conf drivers.Config) (*securityProfiles, error) {
+	if conf.SeccompProfilesDir == "" && conf.SeccompProfile == "" && conf.AppArmorProfiles == "" && conf.AppArmorProfile == "" {
+		return nil, nil
+	}
+
+	p := &securityProfiles{
+		seccomp:         make(map[string]string),
+		defaultSeccomp:  conf.SeccompProfile,
+		apparmor:        make(map[string]bool),
+		defaultAppArmor: conf.AppArmorProfile,
+	}
+	if conf.SeccompProfilesDir != "" {
+		files, err := filepath.Glob(filepath.Join(conf.SeccompProfilesDir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			raw, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, raw); err != nil {
+				return nil, fmt.Errorf("invalid seccomp profile %s: %v", file, err)
+			}
+			p.seccomp[strings.TrimSuffix(filepath.Base(file), ".json")] = buf.String()
+		}
+	}
+	if _, ok := p.seccomp[p.defaultSeccomp]; p.defaultSeccomp != "" && !ok {
+		return nil, fmt.Errorf("no seccomp profile %s in %q", p.defaultSeccomp, conf.SeccompProfilesDir)
+	}
+
+	for _, name := range strings.Split(conf.AppArmorProfiles, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			p.apparmor[name] = true
+		}
+	}
+	if p.defaultAppArmor != "" {
+		p.apparmor[p.defaultAppArmor] = true
+	}
+	return p, nil
+}
+
+// seccompProfile returns the JSON of the seccomp profile name, or of the default profile if name is empty. It
+// returns an empty profile for the default of docker.
+func (p *securityProfiles) seccompProfile(name string) (string, error) {
+	if p == nil {
+		if name != "" {
+			return "", ErrNoSecurityProfile
+		}
+		return "", nil
+	}
+	if name == "" {
+		name = p.defaultSeccomp
+		if name == "" {
+			return "", nil
+		}
+	}
+	profile, ok := p.seccomp[name]
+	if !ok {
+		return "", ErrNoSecurityProfile
+	}
+	return profile, nil
+}
+
+// appArmorProfile returns the AppArmor profile name, or the default profile if name is empty. It returns an empty
+// profile for the default of docker.
+func (p *securityProfiles) appArmorProfile(name string) (string, error) {
+	if p == nil {
+		if name != "" {
+			return "", ErrNoSecurityProfile
+		}
+		return "", nil
+	}
+	if name == "" {
+		return p.defaultAppArmor, nil
+	}
+	if !p.apparmor[name] {
+		return "", ErrNoSecurityProfile
+	}
+	return name, nil
+}
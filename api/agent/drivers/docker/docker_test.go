@@ -25,6 +25,13 @@ type taskDockerTest struct {
 	output     io.Writer
 	errors     io.Writer
 	logURL     string
+
+	daemonLabels map[string]string
+	networks     []string
+	gpus         []string
+	seccomp      string
+	apparmor     string
+	pullPolicy   string
 }
 
 func (f *taskDockerTest) Command() string                                            { return f.cmd }
@@ -32,6 +39,7 @@ func (f *taskDockerTest) EnvVars() map[string]string
 func (f *taskDockerTest) Id() string                                                 { return f.id }
 func (f *taskDockerTest) Group() string                                              { return "" }
 func (f *taskDockerTest) Image() string                                              { return "busybox" }
+func (f *taskDockerTest) ImagePullPolicy() string                                    { return f.pullPolicy }
 func (f *taskDockerTest) Logger() (stdout, stderr io.Writer)                         { return f.output, f.errors }
 func (f *taskDockerTest) WriteStat(context.Context, stats.Stat)                      { /* TODO */ }
 func (f *taskDockerTest) Volumes() [][2]string                                       { return [][2]string{} }
@@ -55,10 +63,18 @@ func (f *taskDockerTest) Extensions() map[string]string
 func (f *taskDockerTest) LoggerConfig() drivers.LoggerConfig {
 	return drivers.LoggerConfig{URL: f.logURL}
 }
-func (f *taskDockerTest) UDSAgentPath() string  { return "" }
-func (f *taskDockerTest) UDSDockerPath() string { return "" }
-func (f *taskDockerTest) UDSDockerDest() string { return "" }
-func (f *taskDockerTest) DisableNet() bool      { return f.disableNet }
+func (f *taskDockerTest) UDSAgentPath() string       { return "" }
+func (f *taskDockerTest) UDSDockerPath() string      { return "" }
+func (f *taskDockerTest) UDSDockerDest() string      { return "" }
+func (f *taskDockerTest) DisableNet() bool           { return f.disableNet }
+func (f *taskDockerTest) StopSignal() string         { return "" }
+func (f *taskDockerTest) StopTimeout() time.Duration { return 0 }
+
+func (f *taskDockerTest) DaemonLabels() map[string]string { return f.daemonLabels }
+func (f *taskDockerTest) Networks() []string              { return f.networks }
+func (f *taskDockerTest) GPUs() []string                  { return f.gpus }
+func (f *taskDockerTest) SeccompProfile() string          { return f.seccomp }
+func (f *taskDockerTest) AppArmorProfile() string         { return f.apparmor }
 
 func (f *taskDockerTest) BeforeCall(context.Context, *models.Call, drivers.CallExtensions) error {
 	return nil
@@ -0,0 +1,79 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/agent/drivers/stats"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fsouza/go-dockerclient"
+)
+
+var errContainerNotRunning = errors.New("container not running")
+
+// hasTmpFs returns whether the containers of task get a /tmp tmpfs, see cookie.configureTmpFs
+func (drv *DockerDriver) hasTmpFs(task drivers.ContainerTask) bool {
+	return task.TmpFsSize() != 0 || drv.conf.EnableReadOnlyRootFs
+}
+
+// fsUsage samples the fs usage of the running container id. The size of its writable layer is computed by the
+// daemon, which takes a walk of the layer, and the usage of its /tmp tmpfs is read through the root of its init
+// process, which needs the agent to share the pid namespace of the host.
+func (drv *DockerDriver) fsUsage(ctx context.Context, daemon *dockerDaemon, id string, tmpFs bool) (drivers.FsUsage, error) {
+	var usage drivers.FsUsage
+
+	containers, err := daemon.docker.ListContainers(docker.ListContainersOptions{
+		Size:    true,
+		Filters: map[string][]string{"id": {id}},
+		Context: ctx,
+	})
+	if err != nil {
+		return usage, err
+	}
+	if len(containers) == 0 {
+		return usage, errContainerNotRunning
+	}
+	if containers[0].SizeRw > 0 {
+		usage.Writable = uint64(containers[0].SizeRw)
+	}
+
+	if tmpFs {
+		c, err := daemon.docker.InspectContainerWithContext(id, ctx)
+		if err != nil {
+			return usage, err
+		}
+		if c.State.Pid == 0 {
+			return usage, errContainerNotRunning
+		}
+		usage.TmpFs, usage.TmpFsInodes, err = tmpFsUsage(c.State.Pid)
+		if err != nil {
+			return usage, err
+		}
+	}
+	return usage, nil
+}
+
+// fsUsageStat returns the stat of a fs usage sample
+func fsUsageStat(usage drivers.FsUsage, tmpFs bool) stats.Stat {
+	stat := stats.Stat{
+		Timestamp: common.DateTime(time.Now()),
+		Metrics:   map[string]uint64{drivers.StatFsUsage: usage.Writable},
+	}
+	if tmpFs {
+		stat.Metrics[drivers.StatTmpFsUsage] = usage.TmpFs
+		stat.Metrics[drivers.StatTmpFsInodes] = usage.TmpFsInodes
+	}
+	return stat
+}
+
+// implements drivers.FsUsageCookie
+func (c *cookie) FsUsage(ctx context.Context) (drivers.FsUsage, error) {
+	if c.container == nil {
+		return drivers.FsUsage{}, errContainerNotRunning
+	}
+	return c.drv.fsUsage(ctx, c.daemon, c.container.ID, c.drv.hasTmpFs(c.task))
+}
+
+var _ drivers.FsUsageCookie = &cookie{}
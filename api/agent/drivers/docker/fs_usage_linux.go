@@ -0,0 +1,16 @@
+package docker
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// tmpFsUsage returns the bytes and inodes used in the /tmp tmpfs of the process pid
+func tmpFsUsage(pid int) (uint64, uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(fmt.Sprintf("/proc/%d/root/tmp", pid), &st); err != nil {
+		return 0, 0, err
+	}
+	return (st.Blocks - st.Bfree) * uint64(st.Bsize), st.Files - st.Ffree, nil
+}
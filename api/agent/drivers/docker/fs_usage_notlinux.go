@@ -0,0 +1,11 @@
+// +build !linux
+
+package docker
+
+import (
+	"errors"
+)
+
+func tmpFsUsage(pid int) (uint64, uint64, error) {
+	return 0, 0, errors.New("tmpfs usage not supported on this OS")
+}
@@ -2,6 +2,7 @@ package docker
 
 import (
 	"container/list"
+	"sort"
 	"sync"
 )
 
@@ -14,21 +15,48 @@ import (
 // least recently used image from the cache. ImageCacher provides
 // Update() to add/update the LRU cache and MarkBusy()/MarkFree()
 // function pair to mark/unmark a specific image (reference count)
-// in use.
+// in use. Images may be pinned by operators, which keeps them out
+// of the LRU until they are unpinned.
 
 type CachedImage struct {
 	ID       string // Image Cache key
 	ParentID string
 	RepoTags []string // RepoTags are used to match special/status images that are exempt from image cache
-	Size     uint64
+	// RepoDigests are the repo@digest references of the image, which it may be pinned by
+	RepoDigests []string
+	Size        uint64
+}
+
+// matches returns whether ref is the ID, a tag or a digest reference of the image
+func (img *CachedImage) matches(ref string) bool {
+	if img.ID == ref {
+		return true
+	}
+	for _, r := range append(img.RepoTags, img.RepoDigests...) {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// CachedImageState is what the cache knows of an image
+type CachedImageState struct {
+	// InUse is the number of containers using the image
+	InUse uint64
+	// Pinned is whether the image is pinned, Exempt whether it is exempt from the cache by tag
+	Pinned bool
+	Exempt bool
 }
 
 type ImageCacherStats struct {
-	BusyImgTotalSize uint64
-	BusyImgCount     uint64
-	IdleImgTotalSize uint64
-	IdleImgCount     uint64
-	MaxImgTotalSize  uint64
+	BusyImgTotalSize   uint64
+	BusyImgCount       uint64
+	IdleImgTotalSize   uint64
+	IdleImgCount       uint64
+	PinnedImgTotalSize uint64
+	PinnedImgCount     uint64
+	MaxImgTotalSize    uint64
 }
 
 type ImageCacher interface {
@@ -53,6 +81,24 @@ type ImageCacher interface {
 	MarkBusy(img *CachedImage)
 	MarkFree(img *CachedImage)
 
+	// Pin keeps the images matching ref, by ID, tag or digest reference,
+	// out of the LRU until it is unpinned. Unpin returns false if ref was
+	// not pinned, Pins returns the pinned references.
+	Pin(ref string)
+	Unpin(ref string) bool
+	Pins() []string
+
+	// Remove drops an image that is neither in-use nor pinned from the
+	// cache, to remove it from the system. It returns false if the
+	// image is in-use or pinned.
+	Remove(img *CachedImage) bool
+
+	// State returns whether an image is in-use, pinned or exempt
+	State(img *CachedImage) CachedImageState
+
+	// SetMaxSize changes the limit, images are evicted past it from then on
+	SetMaxSize(size uint64)
+
 	// Stats Monitoring
 	GetStats() *ImageCacherStats
 }
@@ -79,6 +125,11 @@ type imageCacher struct {
 	// reference count of images that are in-use
 	busySize uint64
 	busyRef  map[string]uint64
+
+	// pinned references, and the images not in-use they keep out of LRU
+	pins       map[string]struct{}
+	pinnedSize uint64
+	pinnedMap  map[string]*CachedImage
 }
 
 func NewImageCache(exemptTags []string, maxSize uint64) ImageCacher {
@@ -89,6 +140,8 @@ func NewImageCache(exemptTags []string, maxSize uint64) ImageCacher {
 		lruList:       list.New(),
 		lruMap:        make(map[string]*list.Element),
 		busyRef:       make(map[string]uint64),
+		pins:          make(map[string]struct{}),
+		pinnedMap:     make(map[string]*CachedImage),
 	}
 
 	for _, tag := range exemptTags {
@@ -109,6 +162,37 @@ func (c *imageCacher) isEligible(img *CachedImage) bool {
 	return true
 }
 
+// isPinnedLocked returns true if a pinned reference matches the image
+func (c *imageCacher) isPinnedLocked(img *CachedImage) bool {
+	for ref := range c.pins {
+		if img.matches(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// addIdleLocked adds an image not in-use to LRU, or holds it out of LRU if it is pinned
+func (c *imageCacher) addIdleLocked(img *CachedImage) {
+	if !c.isPinnedLocked(img) {
+		c.addLRULocked(img)
+		return
+	}
+	if _, ok := c.pinnedMap[img.ID]; !ok {
+		c.pinnedSize += img.Size
+		c.pinnedMap[img.ID] = img
+	}
+}
+
+// rmIdleLocked removes an image from LRU or from the pinned images
+func (c *imageCacher) rmIdleLocked(img *CachedImage) {
+	c.rmLRULocked(img)
+	if pinned, ok := c.pinnedMap[img.ID]; ok {
+		delete(c.pinnedMap, img.ID)
+		c.pinnedSize -= pinned.Size
+	}
+}
+
 // addLRULocked performs a classic LRU add operation
 func (c *imageCacher) addLRULocked(img *CachedImage) {
 	ee, ok := c.lruMap[img.ID]
@@ -169,22 +253,35 @@ func (c *imageCacher) sendNotify() {
 // We compare both busy + lru size against max. However, we also check if lru is not empty.
 // This is because there's no point to show over capacity if Pop() is going to return nil.
 func (c *imageCacher) isMaxCapacityLocked() bool {
-	return (c.lruSize > 0) && ((c.lruSize + c.busySize) >= c.maxSize)
+	return (c.lruSize > 0) && ((c.lruSize + c.busySize + c.pinnedSize) >= c.maxSize)
 }
 
-func (c *imageCacher) GetStats() *ImageCacherStats {
-	stats := &ImageCacherStats{
-		MaxImgTotalSize: c.maxSize,
+// SetMaxSize changes the limit, notifying the listener if the cache is now over capacity
+func (c *imageCacher) SetMaxSize(size uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.maxSize = size
+	if c.isMaxCapacityLocked() {
+		c.sendNotify()
 	}
+}
+
+func (c *imageCacher) GetStats() *ImageCacherStats {
+	stats := &ImageCacherStats{}
 
 	c.lock.Lock()
 
+	stats.MaxImgTotalSize = c.maxSize
+
 	stats.BusyImgTotalSize = c.busySize
 	stats.BusyImgCount = uint64(len(c.busyRef))
 
 	stats.IdleImgTotalSize = c.lruSize
 	stats.IdleImgCount = uint64(len(c.lruMap))
 
+	stats.PinnedImgTotalSize = c.pinnedSize
+	stats.PinnedImgCount = uint64(len(c.pinnedMap))
+
 	c.lock.Unlock()
 
 	return stats
@@ -209,7 +306,7 @@ func (c *imageCacher) Update(img *CachedImage) {
 	c.lock.Lock()
 
 	if _, ok := c.busyRef[img.ID]; !ok {
-		c.addLRULocked(img)
+		c.addIdleLocked(img)
 		if c.isMaxCapacityLocked() {
 			defer c.sendNotify()
 		}
@@ -241,7 +338,7 @@ func (c *imageCacher) MarkBusy(img *CachedImage) {
 	c.lock.Lock()
 
 	if c.addBusyLocked(img) {
-		c.rmLRULocked(img)
+		c.rmIdleLocked(img)
 		if c.isMaxCapacityLocked() {
 			defer c.sendNotify()
 		}
@@ -260,7 +357,7 @@ func (c *imageCacher) MarkFree(img *CachedImage) {
 	c.lock.Lock()
 
 	if c.rmBusyLocked(img) {
-		c.addLRULocked(img)
+		c.addIdleLocked(img)
 		if c.isMaxCapacityLocked() {
 			defer c.sendNotify()
 		}
@@ -268,3 +365,78 @@ func (c *imageCacher) MarkFree(img *CachedImage) {
 
 	c.lock.Unlock()
 }
+
+// Pin keeps the images matching ref out of LRU until it is unpinned
+func (c *imageCacher) Pin(ref string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.pins[ref] = struct{}{}
+	for _, ee := range c.lruMap {
+		if img := ee.Value.(*CachedImage); img.matches(ref) {
+			c.rmLRULocked(img)
+			c.addIdleLocked(img)
+		}
+	}
+}
+
+// Unpin adds the images ref kept out of LRU back to it, unless other
+// pinned references match them
+func (c *imageCacher) Unpin(ref string) bool {
+	c.lock.Lock()
+
+	if _, ok := c.pins[ref]; !ok {
+		c.lock.Unlock()
+		return false
+	}
+	delete(c.pins, ref)
+	for _, img := range c.pinnedMap {
+		if !c.isPinnedLocked(img) {
+			c.rmIdleLocked(img)
+			c.addLRULocked(img)
+		}
+	}
+	if c.isMaxCapacityLocked() {
+		defer c.sendNotify()
+	}
+
+	c.lock.Unlock()
+	return true
+}
+
+// Pins returns the pinned references, sorted
+func (c *imageCacher) Pins() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	pins := make([]string, 0, len(c.pins))
+	for ref := range c.pins {
+		pins = append(pins, ref)
+	}
+	sort.Strings(pins)
+	return pins
+}
+
+// Remove drops an image from LRU if it is neither in-use nor pinned
+func (c *imageCacher) Remove(img *CachedImage) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.busyRef[img.ID]; ok || c.isPinnedLocked(img) {
+		return false
+	}
+	c.rmLRULocked(img)
+	return true
+}
+
+// State returns whether an image is in-use, pinned or exempt
+func (c *imageCacher) State(img *CachedImage) CachedImageState {
+	if !c.isEligible(img) {
+		return CachedImageState{Exempt: true}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return CachedImageState{InUse: c.busyRef[img.ID], Pinned: c.isPinnedLocked(img)}
+}
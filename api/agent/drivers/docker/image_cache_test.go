@@ -250,3 +250,79 @@ func TestImageCacherBasic4(t *testing.T) {
 		t.Fatalf("cache %+v should Pop()?", inner)
 	}
 }
+
+func TestImageCacherPins(t *testing.T) {
+	obj := NewImageCache([]string{}, 20)
+	inner := obj.(*imageCacher)
+
+	img1 := &CachedImage{ID: "salsa1", RepoDigests: []string{"salsa@sha256:1"}, Size: uint64(10)}
+	img2 := &CachedImage{ID: "salsa2", RepoTags: []string{"salsa:2"}, Size: uint64(15)}
+
+	obj.Update(img1)
+	obj.Pin("salsa@sha256:1")
+	obj.Update(img2)
+
+	if state := obj.State(img1); !state.Pinned || state.InUse != 0 {
+		t.Fatalf("cache %+v should report %+v pinned, got %+v", inner, img1, state)
+	}
+	// the pinned image counts towards the capacity, but only the other may be popped
+	if !obj.IsMaxCapacity() {
+		t.Fatalf("cache %+v should be at max capacity", inner)
+	}
+	if item := obj.Pop(); item != img2 {
+		t.Fatalf("cache %+v should Pop(%+v), got %+v", inner, img2, item)
+	}
+	if item := obj.Pop(); item != nil {
+		t.Fatalf("cache %+v should not Pop(%+v)?", inner, item)
+	}
+	if obj.Remove(img1) {
+		t.Fatalf("cache %+v should not remove pinned %+v", inner, img1)
+	}
+
+	// pinned images in-use stay out of LRU when freed
+	obj.MarkBusy(img1)
+	if state := obj.State(img1); !state.Pinned || state.InUse != 1 {
+		t.Fatalf("cache %+v should report %+v pinned and in-use, got %+v", inner, img1, state)
+	}
+	obj.MarkFree(img1)
+	if item := obj.Pop(); item != nil {
+		t.Fatalf("cache %+v should not Pop(%+v)?", inner, item)
+	}
+
+	if obj.Unpin("salsa:2") {
+		t.Fatalf("cache %+v should not unpin a reference that is not pinned", inner)
+	}
+	if pins := obj.Pins(); len(pins) != 1 || pins[0] != "salsa@sha256:1" {
+		t.Fatalf("cache %+v should have pins [salsa@sha256:1], got %v", inner, pins)
+	}
+	if !obj.Unpin("salsa@sha256:1") {
+		t.Fatalf("cache %+v should unpin salsa@sha256:1", inner)
+	}
+	if item := obj.Pop(); item != img1 {
+		t.Fatalf("cache %+v should Pop(%+v) once unpinned, got %+v", inner, img1, item)
+	}
+	if stats := obj.GetStats(); stats.PinnedImgCount != 0 || stats.PinnedImgTotalSize != 0 {
+		t.Fatalf("cache %+v should have no pinned images, got %+v", inner, stats)
+	}
+}
+
+func TestImageCacherSetMaxSize(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(10*time.Second))
+	defer cancel()
+
+	obj := NewImageCache([]string{}, 20)
+	rec := obj.GetNotifier()
+	obj.Update(&CachedImage{ID: "salsa1", Size: uint64(10)})
+	if obj.IsMaxCapacity() || isNotifySet(ctx, rec) {
+		t.Fatalf("cache under its size should not be over capacity")
+	}
+
+	// lowering the size past the images cached notifies the cleaner
+	obj.SetMaxSize(5)
+	if !obj.IsMaxCapacity() || !isNotifySet(ctx, rec) {
+		t.Fatalf("cache should be over capacity once its size is lowered")
+	}
+	if stats := obj.GetStats(); stats.MaxImgTotalSize != 5 {
+		t.Fatalf("expected the new size in stats, got %d", stats.MaxImgTotalSize)
+	}
+}
@@ -3,6 +3,7 @@ package docker
 import (
 	"bytes"
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,6 +19,11 @@ type mockClient struct {
 
 	inspectImage    *docker.Image
 	inspectImageErr error
+
+	// memoryReservations are the memory soft limits containers were updated to
+	memoryReservations []int
+	// pauses counts docker pauses and unpauses
+	pauses int
 }
 
 func (c *mockClient) ListImages(opts docker.ListImagesOptions) ([]docker.APIImages, error) {
@@ -30,6 +36,18 @@ func (c *mockClient) RemoveImage(id string, opts docker.RemoveImageOptions) erro
 func (c *mockClient) InspectImage(ctx context.Context, name string) (i *docker.Image, err error) {
 	return c.inspectImage, c.inspectImageErr
 }
+func (c *mockClient) PauseContainer(id string, ctx context.Context) error {
+	c.pauses++
+	return nil
+}
+func (c *mockClient) UnpauseContainer(id string, ctx context.Context) error {
+	c.pauses++
+	return nil
+}
+func (c *mockClient) UpdateContainer(id string, opts docker.UpdateContainerOptions) error {
+	c.memoryReservations = append(c.memoryReservations, opts.MemoryReservation)
+	return nil
+}
 
 // Basic startup scenario. ListImages() with exempt as well as exceeding capacity images
 // should result in image removals.
@@ -69,8 +87,8 @@ func TestImageCleaner1(t *testing.T) {
 	})
 
 	go func() {
-		syncImageCleaner(ctx, dkr)
-		runImageCleaner(ctx, dkr)
+		syncImageCleaner(ctx, dkr.getDaemons()[0])
+		runImageCleaner(ctx, dkr.getDaemons()[0])
 	}()
 
 	select {
@@ -144,3 +162,69 @@ func TestImageCleaner2(t *testing.T) {
 		t.Fatalf("should pop item cache=%+v", inner)
 	}
 }
+
+func TestImageEviction(t *testing.T) {
+	ctx := context.Background()
+
+	dkr := &DockerDriver{
+		conf:     drivers.Config{},
+		docker:   &mockClient{},
+		network:  NewDockerNetworks(drivers.Config{}),
+		imgCache: NewImageCache([]string{"exempt"}, uint64(1024*1024)),
+	}
+	mock := dkr.docker.(*mockClient)
+	mock.listImages = []docker.APIImages{
+		{ID: "zoo0", RepoTags: []string{"exempt"}, Size: 1024},
+		{ID: "zoo1", RepoTags: []string{"zoo:1"}, RepoDigests: []string{"zoo@sha256:1"}, Size: 1024},
+		{ID: "zoo2", RepoTags: []string{"zoo:2"}, Size: 1024},
+	}
+	syncImageCleaner(ctx, dkr.getDaemons()[0])
+
+	if err := dkr.PinImage(ctx, "zoo@sha256:1"); err != nil {
+		t.Fatal(err)
+	}
+	dkr.imgCache.MarkBusy(cachedImage(mock.listImages[2]))
+
+	images, err := dkr.ListCachedImages(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images.Items) != 3 || len(images.Pins) != 1 || images.Pins[0] != "zoo@sha256:1" {
+		t.Fatalf("expected 3 images and the pin, got %+v", images)
+	}
+	if !images.Items[0].Exempt || !images.Items[1].Pinned || images.Items[2].InUse != 1 {
+		t.Fatalf("expected the states of the images, got %+v", images.Items)
+	}
+
+	for ref, expected := range map[string]error{"zoo:1": ErrImagePinned, "zoo2": ErrImageInUse, "zoo:3": ErrImageNotFound} {
+		if err := dkr.EvictImage(ctx, ref); err != expected {
+			t.Errorf("expected evicting %s to fail with %v, got %v", ref, expected, err)
+		}
+	}
+	if len(mock.removedImages) != 0 {
+		t.Fatalf("expected no image to be removed, got %v", mock.removedImages)
+	}
+
+	if err := dkr.UnpinImage(ctx, "zoo@sha256:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dkr.UnpinImage(ctx, "zoo@sha256:1"); err != ErrImageNotPinned {
+		t.Fatalf("expected unpinning twice to fail, got %v", err)
+	}
+	if err := dkr.EvictImage(ctx, "zoo:1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.removedImages) != 1 || mock.removedImages[0] != "zoo1" {
+		t.Fatalf("expected the image to be removed, got %v", mock.removedImages)
+	}
+	if item := dkr.imgCache.Pop(); item != nil {
+		t.Fatalf("expected the evicted image to be dropped from the cache, got %+v", item)
+	}
+
+	dkr.imgCache = nil
+	dkr.daemons = nil
+	dkr.daemonsOnce = sync.Once{}
+	if err := dkr.PinImage(ctx, "zoo:1"); err != ErrImageCleanerDisabled {
+		t.Fatalf("expected pins to need the image cleaner, got %v", err)
+	}
+}
@@ -0,0 +1,568 @@
+package docker
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/sirupsen/logrus"
+)
+
+// ImageVerifier is an admission hook for the images the driver pulls, verifying their signatures before they are
+// pulled. VerifyImage returns the digest of the manifest of the image whose signatures it verified, which the
+// image pulled must have, or "" if it admits the image without verifying it.
+type ImageVerifier interface {
+	VerifyImage(ctx context.Context, auth *docker.AuthConfiguration, image string) (digest string, err error)
+}
+
+// SetImageVerifier replaces the verifier of the images the driver pulls, such as the cosign verifier of the
+// ImageTrustPolicy of its config, nil pulls images without verifying them
+func (drv *DockerDriver) SetImageVerifier(v ImageVerifier) {
+	drv.verifier = v
+}
+
+// verifyImage verifies the signatures of image with the verifier of the driver, if it has one
+func (drv *DockerDriver) verifyImage(ctx context.Context, auth *docker.AuthConfiguration, image string) (string, error) {
+	if drv.verifier == nil {
+		return "", nil
+	}
+	return drv.verifier.VerifyImage(ctx, auth, image)
+}
+
+// checkImageDigest makes sure that the image pulled on d is the one whose signatures were verified for digest, the
+// tag of the image may have been pushed again in between. The image is removed from d if it is not.
+func (drv *DockerDriver) checkImageDigest(ctx context.Context, d *dockerDaemon, image, digest string) error {
+	if digest == "" {
+		return nil
+	}
+	img, err := d.docker.InspectImage(ctx, image)
+	if err != nil {
+		return err
+	}
+	if hasRepoDigest(img.RepoDigests, digest) {
+		drv.verified.Store(img.ID, true)
+		return nil
+	}
+	common.Logger(ctx).WithFields(logrus.Fields{"image": image, "digest": digest}).Error("image pulled is not the one verified, removing it")
+	if err := d.docker.RemoveImage(image, docker.RemoveImageOptions{Context: ctx}); err != nil {
+		common.Logger(ctx).WithError(err).WithField("image", image).Error("error removing unverified image")
+	}
+	return models.NewCodedError(http.StatusServiceUnavailable, "image_changed", map[string]string{"image": image},
+		fmt.Errorf("Image '%s' changed while it was pulled, try again", image))
+}
+
+// verifyLocalImage verifies the signatures of img, the image of the cookie already on its daemon, which may have
+// been loaded or pulled before the driver verified images. The image must be the one signed, otherwise it is
+// pulled again if it may be. Images are verified once, by ID, until the driver restarts.
+func (c *cookie) verifyLocalImage(ctx context.Context, img *docker.Image) (needsPull bool, err error) {
+	if c.drv.verifier == nil {
+		return false, nil
+	}
+	if _, ok := c.drv.verified.Load(img.ID); ok {
+		return false, nil
+	}
+
+	auth, err := c.authImage(ctx)
+	if err != nil {
+		return false, err
+	}
+	digest, err := c.drv.verifyImage(ctx, auth, c.task.Image())
+	if err != nil {
+		return false, err
+	}
+	if digest != "" && !hasRepoDigest(img.RepoDigests, digest) {
+		log := common.Logger(ctx).WithFields(logrus.Fields{"image": c.task.Image(), "digest": digest, "image_id": img.ID})
+		if c.drv.conf.DisableImagePulls || c.task.ImagePullPolicy() == models.ImagePullNever {
+			log.Error("image on the daemon is not the one signed, and it cannot be pulled")
+			return false, errImageUntrusted(c.task.Image(), fmt.Errorf("the image on the runner is not the signed %s", digest))
+		}
+		log.Info("image on the daemon is not the one signed, pulling it")
+		return true, nil
+	}
+	c.drv.verified.Store(img.ID, true)
+	return false, nil
+}
+
+// hasRepoDigest returns whether an image with the repo digests of an inspected image has the manifest digest
+func hasRepoDigest(repoDigests []string, digest string) bool {
+	for _, rd := range repoDigests {
+		if strings.HasSuffix(rd, "@"+digest) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// cosignSignatureAnnotation holds the base64 signature of the layers of cosign signature manifests
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// trustModeEnforce rejects images whose signatures are not verified by the trust roots of their registry
+	trustModeEnforce = "enforce"
+	// trustModeWarn logs images whose signatures are not verified by the trust roots of their registry, and runs them
+	trustModeWarn = "warn"
+
+	// maxRegistryResponse is the largest manifest or signature payload fetched from registries
+	maxRegistryResponse = 4 << 20
+)
+
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// imageTrustPolicy is the JSON trust policy of the ImageTrustPolicy of the config of the driver, eg.
+//
+//	{
+//	  "default": {"keys": ["/etc/fn/trust/cosign.pub"]},
+//	  "registries": {
+//	    "registry.example.com": {"keys": ["/etc/fn/trust/example.pub"]},
+//	    "docker.io": {"keys": ["/etc/fn/trust/hub.pub"], "mode": "warn"},
+//	    "localhost:5000": {"keys": ["/etc/fn/trust/dev.pub"], "insecure": true}
+//	  }
+//	}
+type imageTrustPolicy struct {
+	// Default applies to the registries without a policy of their own
+	Default    *registryTrustPolicy            `json:"default"`
+	Registries map[string]*registryTrustPolicy `json:"registries"`
+}
+
+type registryTrustPolicy struct {
+	// Keys are the paths of the PEM public keys, the trust roots, the images of the registry are signed with
+	Keys []string `json:"keys"`
+	// Mode is trustModeEnforce, the default, or trustModeWarn
+	Mode string `json:"mode"`
+	// Insecure reaches the registry over plain HTTP
+	Insecure bool `json:"insecure"`
+}
+
+// registryTrust are the trust roots of a registry
+type registryTrust struct {
+	keys     []crypto.PublicKey
+	warn     bool
+	insecure bool
+}
+
+// cosignVerifier is the ImageVerifier of the ImageTrustPolicy of the config of the driver, it verifies the cosign
+// signatures of images, which registries hold as the sha256-<digest>.sig tag of their repository, with the public
+// keys of the registry of the image.
+type cosignVerifier struct {
+	registries    map[string]*registryTrust
+	defaultTrust  *registryTrust
+	requireSigned bool
+	client        *http.Client
+	// certsDir is the certs.d dir of the docker daemon, holding the CAs it trusts for each registry
+	certsDir string
+}
+
+var _ ImageVerifier = new(cosignVerifier)
+
+// newCosignVerifier returns the verifier of the ImageTrustPolicy of conf, or nil if conf neither has one nor requires
+// signed images
+func newCosignVerifier(conf drivers.Config) (*cosignVerifier, error) {
+	if conf.ImageTrustPolicy == "" && !conf.RequireSignedImages {
+		return nil, nil
+	}
+	v := &cosignVerifier{
+		registries:    make(map[string]*registryTrust),
+		requireSigned: conf.RequireSignedImages,
+		client:        http.DefaultClient,
+		certsDir:      conf.DockerCertsDir,
+	}
+	if conf.ImageTrustPolicy == "" {
+		return v, nil
+	}
+
+	raw, err := ioutil.ReadFile(conf.ImageTrustPolicy)
+	if err != nil {
+		return nil, err
+	}
+	var policy imageTrustPolicy
+	if err := json.Unmarshal(raw, &policy); err != nil {
+		return nil, fmt.Errorf("invalid image trust policy %s: %v", conf.ImageTrustPolicy, err)
+	}
+	if policy.Default != nil {
+		if v.defaultTrust, err = loadRegistryTrust(policy.Default); err != nil {
+			return nil, fmt.Errorf("invalid default image trust policy: %v", err)
+		}
+	}
+	for reg, p := range policy.Registries {
+		trust, err := loadRegistryTrust(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image trust policy of registry %s: %v", reg, err)
+		}
+		v.registries[normalizeRegistry(reg)] = trust
+	}
+	return v, nil
+}
+
+func loadRegistryTrust(p *registryTrustPolicy) (*registryTrust, error) {
+	switch p.Mode {
+	case "", trustModeEnforce, trustModeWarn:
+	default:
+		return nil, fmt.Errorf("invalid mode %q, it must be %s or %s", p.Mode, trustModeEnforce, trustModeWarn)
+	}
+	if len(p.Keys) == 0 {
+		return nil, errors.New("no keys")
+	}
+	trust := &registryTrust{warn: p.Mode == trustModeWarn, insecure: p.Insecure}
+	for _, file := range p.Keys {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := parsePublicKeys(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %v", file, err)
+		}
+		trust.keys = append(trust.keys, keys...)
+	}
+	return trust, nil
+}
+
+// parsePublicKeys parses the PEM PKIX public keys of raw, ECDSA, RSA and Ed25519 keys as cosign generates
+func parsePublicKeys(raw []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, raw = pem.Decode(raw)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", key)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM public keys")
+	}
+	return keys, nil
+}
+
+// normalizeRegistry names docker hub docker.io, however images name it
+func normalizeRegistry(reg string) string {
+	switch reg {
+	case "", "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return "docker.io"
+	}
+	return reg
+}
+
+// errUntrusted are the errors of images whose signatures are not verified, rather than of reaching their registry
+type errUntrusted struct{ error }
+
+// VerifyImage implements ImageVerifier
+func (v *cosignVerifier) VerifyImage(ctx context.Context, auth *docker.AuthConfiguration, image string) (string, error) {
+	reg, repo, tag := drivers.ParseImage(image)
+	reg = normalizeRegistry(reg)
+	log := common.Logger(ctx).WithFields(logrus.Fields{"image": image, "registry": reg})
+
+	trust, ok := v.registries[reg]
+	if !ok {
+		trust = v.defaultTrust
+	}
+	if trust == nil {
+		if v.requireSigned {
+			log.Error("image of a registry without trust roots, and signed images are required")
+			return "", errImageUntrusted(image, fmt.Errorf("no trust roots for registry %s", reg))
+		}
+		return "", nil
+	}
+
+	digest, err := v.verify(ctx, auth, trust, reg, repo, tag)
+	if err == nil {
+		log.WithField("digest", digest).Debug("image signature verified")
+		return digest, nil
+	}
+	if _, ok := err.(errUntrusted); !ok {
+		log.WithError(err).Error("error verifying image signature")
+		return "", models.NewCodedError(http.StatusBadGateway, "image_verification_failed", map[string]string{"image": image},
+			fmt.Errorf("Failed to verify the signature of image '%s': %v", image, err))
+	}
+	if trust.warn && !v.requireSigned {
+		log.WithError(err).Warn("image signature not verified, running it as the registry policy only warns")
+		return "", nil
+	}
+	log.WithError(err).Error("image signature not verified")
+	return "", errImageUntrusted(image, err)
+}
+
+func errImageUntrusted(image string, err error) error {
+	return models.NewCodedError(http.StatusForbidden, "image_untrusted", map[string]string{"image": image},
+		fmt.Errorf("Image '%s' is not signed by a trusted key: %v", image, err))
+}
+
+// verify returns the digest of the manifest tag of repo points to, once a cosign signature of it is verified by
+// the keys of trust
+func (v *cosignVerifier) verify(ctx context.Context, auth *docker.AuthConfiguration, trust *registryTrust, reg, repo, tag string) (string, error) {
+	client, err := v.clientFor(reg)
+	if err != nil {
+		return "", err
+	}
+	if client != v.client {
+		defer client.CloseIdleConnections()
+	}
+	rc := &registryClient{client: client, auth: auth, repo: repo, base: "https://" + reg}
+	if reg == "docker.io" {
+		rc.base = "https://registry-1.docker.io"
+	} else if trust.insecure {
+		rc.base = "http://" + reg
+	}
+
+	manifest, err := rc.get(ctx, "manifests/"+tag, manifestMediaTypes...)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(manifest)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if strings.HasPrefix(tag, "sha256:") && tag != digest {
+		return "", fmt.Errorf("registry returned manifest %s for %s", digest, tag)
+	}
+
+	raw, err := rc.get(ctx, "manifests/sha256-"+hex.EncodeToString(sum[:])+".sig", manifestMediaTypes...)
+	if err == errRegistryNotFound {
+		return "", errUntrusted{errors.New("image is not signed")}
+	} else if err != nil {
+		return "", err
+	}
+	var sigs struct {
+		Layers []struct {
+			Digest      string            `json:"digest"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(raw, &sigs); err != nil {
+		return "", fmt.Errorf("invalid signature manifest: %v", err)
+	}
+
+	for _, layer := range sigs.Layers {
+		sig, err := base64.StdEncoding.DecodeString(layer.Annotations[cosignSignatureAnnotation])
+		if err != nil || len(sig) == 0 {
+			continue
+		}
+		payload, err := rc.get(ctx, "blobs/"+layer.Digest)
+		if err != nil {
+			return "", err
+		}
+		if sum := sha256.Sum256(payload); "sha256:"+hex.EncodeToString(sum[:]) != layer.Digest {
+			return "", fmt.Errorf("registry returned the wrong blob for %s", layer.Digest)
+		}
+		if !verifySignature(trust.keys, payload, sig) {
+			continue
+		}
+
+		var simpleSigning struct {
+			Critical struct {
+				Image struct {
+					DockerManifestDigest string `json:"docker-manifest-digest"`
+				} `json:"image"`
+			} `json:"critical"`
+		}
+		if err := json.Unmarshal(payload, &simpleSigning); err == nil && simpleSigning.Critical.Image.DockerManifestDigest == digest {
+			return digest, nil
+		}
+	}
+	return "", errUntrusted{errors.New("no signature verified by the trust roots of the registry")}
+}
+
+// clientFor returns the client reaching reg, trusting the CAs the docker daemon trusts for pulls from reg, such as
+// the registry CAs the agent manages, on top of the system roots
+func (v *cosignVerifier) clientFor(reg string) (*http.Client, error) {
+	if v.certsDir == "" {
+		return v.client, nil
+	}
+	files, err := filepath.Glob(filepath.Join(v.certsDir, reg, "*.crt"))
+	if err != nil || len(files) == 0 {
+		return v.client, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, file := range files {
+		bundle, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates in registry CA %s", file)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &http.Client{Transport: transport}, nil
+}
+
+// verifySignature returns whether sig is the signature of payload by one of keys
+func verifySignature(keys []crypto.PublicKey, payload, sig []byte) bool {
+	hash := sha256.Sum256(payload)
+	for _, key := range keys {
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, hash[:], sig) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], sig) == nil {
+				return true
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, payload, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var errRegistryNotFound = errors.New("not found in registry")
+
+// registryClient fetches the manifests and blobs of a repository through the registry HTTP API, authenticating as
+// registries challenge it to with the credentials of the driver for the registry
+type registryClient struct {
+	client *http.Client
+	auth   *docker.AuthConfiguration
+	base   string
+	repo   string
+	// authorization is the Authorization header registries accepted
+	authorization string
+}
+
+func (rc *registryClient) get(ctx context.Context, p string, accept ...string) ([]byte, error) {
+	resp, err := rc.do(ctx, rc.base+path.Join("/v2", rc.repo, p), accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && rc.authorization == "" {
+		challenge := resp.Header.Get("Www-Authenticate")
+		resp.Body.Close()
+		if rc.authorization, err = rc.authorize(ctx, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = rc.do(ctx, rc.base+path.Join("/v2", rc.repo, p), accept); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errRegistryNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("registry returned %s for %s", resp.Status, p)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRegistryResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxRegistryResponse {
+		return nil, fmt.Errorf("registry response for %s is too large", p)
+	}
+	return b, nil
+}
+
+func (rc *registryClient) do(ctx context.Context, u string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if rc.authorization != "" {
+		req.Header.Set("Authorization", rc.authorization)
+	}
+	return rc.client.Do(req)
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authorize returns the Authorization header answering the WWW-Authenticate challenge of a registry, fetching a
+// pull token for the repository from the realm of bearer challenges
+func (rc *registryClient) authorize(ctx context.Context, challenge string) (string, error) {
+	scheme := strings.ToLower(strings.SplitN(challenge, " ", 2)[0])
+	if scheme == "basic" {
+		if rc.auth == nil || rc.auth.Username == "" {
+			return "", errors.New("registry requires credentials")
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(rc.auth.Username+":"+rc.auth.Password)), nil
+	}
+	if scheme != "bearer" {
+		return "", fmt.Errorf("unsupported registry authentication %q", challenge)
+	}
+
+	params := make(map[string]string)
+	for _, m := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	if params["realm"] == "" {
+		return "", fmt.Errorf("registry challenge without realm %q", challenge)
+	}
+	q := url.Values{"scope": {"repository:" + rc.repo + ":pull"}}
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	req, err := http.NewRequest(http.MethodGet, params["realm"]+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	if rc.auth != nil && rc.auth.Username != "" {
+		req.SetBasicAuth(rc.auth.Username, rc.auth.Password)
+	}
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token service returned %s", resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRegistryResponse)).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
@@ -0,0 +1,319 @@
+package docker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/models"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// testRegistry serves the manifest of the signed and unsigned tags of the fn repository, and the cosign signature
+// of the signed one, behind token auth
+type testRegistry struct {
+	manifests map[string][]byte
+	blobs     map[string][]byte
+}
+
+func newTestRegistry(t *testing.T, key *ecdsa.PrivateKey) *testRegistry {
+	r := &testRegistry{manifests: make(map[string][]byte), blobs: make(map[string][]byte)}
+	signed := []byte(`{"schemaVersion": 2, "config": {"digest": "sha256:signed"}}`)
+	r.manifests["signed"] = signed
+	r.manifests["unsigned"] = []byte(`{"schemaVersion": 2, "config": {"digest": "sha256:unsigned"}}`)
+
+	payload := []byte(fmt.Sprintf(`{"critical": {"identity": {"docker-reference": "fn"}, "image": {"docker-manifest-digest": %q}, "type": "cosign container image signature"}}`, sha256Digest(signed)))
+	hash := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.blobs[sha256Digest(payload)] = payload
+	r.manifests["sha256-"+strings.TrimPrefix(sha256Digest(signed), "sha256:")+".sig"] = []byte(fmt.Sprintf(
+		`{"schemaVersion": 2, "layers": [{"digest": %q, "annotations": {%q: %q}}]}`,
+		sha256Digest(payload), cosignSignatureAnnotation, base64.StdEncoding.EncodeToString(sig)))
+	return r
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if req.URL.Query().Get("scope") != "repository:fn:pull" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"token": "secret"}`))
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		scheme := "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s://%s/token",service="test"`, scheme, req.Host))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var b []byte
+	if ref := strings.TrimPrefix(req.URL.Path, "/v2/fn/manifests/"); ref != req.URL.Path {
+		b = r.manifests[ref]
+		for _, m := range r.manifests {
+			if sha256Digest(m) == ref {
+				b = m
+			}
+		}
+	} else if digest := strings.TrimPrefix(req.URL.Path, "/v2/fn/blobs/"); digest != req.URL.Path {
+		b = r.blobs[digest]
+	}
+	if b == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write(b)
+}
+
+func writePublicKey(t *testing.T, dir, name string, key *ecdsa.PrivateKey) string {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestCosignVerifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image_trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := writePublicKey(t, dir, "trusted.pub", key)
+	untrusted := writePublicKey(t, dir, "untrusted.pub", otherKey)
+
+	reg := newTestRegistry(t, key)
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	signedDigest := sha256Digest(reg.manifests["signed"])
+
+	newVerifier := func(policy map[string]interface{}, requireSigned bool) *cosignVerifier {
+		raw, err := json.Marshal(policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(dir, "policy.json")
+		if err := ioutil.WriteFile(file, raw, 0644); err != nil {
+			t.Fatal(err)
+		}
+		v, err := newCosignVerifier(drivers.Config{ImageTrustPolicy: file, RequireSignedImages: requireSigned})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	registryPolicy := func(key, mode string) map[string]interface{} {
+		return map[string]interface{}{"registries": map[string]interface{}{
+			host: map[string]interface{}{"keys": []string{key}, "mode": mode, "insecure": true},
+		}}
+	}
+
+	for i, test := range []struct {
+		verifier       *cosignVerifier
+		image          string
+		expectedDigest string
+		expectedCode   string
+	}{
+		{newVerifier(registryPolicy(trusted, ""), false), host + "/fn:signed", signedDigest, ""},
+		{newVerifier(registryPolicy(trusted, ""), false), host + "/fn@" + signedDigest, signedDigest, ""},
+		{newVerifier(registryPolicy(trusted, ""), false), host + "/fn:unsigned", "", "image_untrusted"},
+		{newVerifier(registryPolicy(untrusted, ""), false), host + "/fn:signed", "", "image_untrusted"},
+		{newVerifier(registryPolicy(trusted, ""), false), host + "/fn:missing", "", "image_verification_failed"},
+		// warn mode runs images it cannot verify, unless signed images are required
+		{newVerifier(registryPolicy(trusted, trustModeWarn), false), host + "/fn:unsigned", "", ""},
+		{newVerifier(registryPolicy(trusted, trustModeWarn), true), host + "/fn:unsigned", "", "image_untrusted"},
+		// images of registries without a policy
+		{newVerifier(registryPolicy(trusted, ""), false), "fnproject/hello", "", ""},
+		{newVerifier(registryPolicy(trusted, ""), true), "fnproject/hello", "", "image_untrusted"},
+	} {
+		digest, err := test.verifier.VerifyImage(context.Background(), nil, test.image)
+		if code, _ := models.GetErrorCode(err); code != test.expectedCode || (test.expectedCode == "" && err != nil) {
+			t.Errorf("Test %d: expected error code %q, got %v", i, test.expectedCode, err)
+		}
+		if digest != test.expectedDigest {
+			t.Errorf("Test %d: expected digest %q, got %q", i, test.expectedDigest, digest)
+		}
+	}
+}
+
+func TestCosignVerifierRegistryCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image_trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := newTestRegistry(t, key)
+	srv := httptest.NewTLSServer(reg)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	raw, err := json.Marshal(map[string]interface{}{"registries": map[string]interface{}{
+		host: map[string]interface{}{"keys": []string{writePublicKey(t, dir, "trusted.pub", key)}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := filepath.Join(dir, "policy.json")
+	if err := ioutil.WriteFile(policy, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	certsDir := filepath.Join(dir, "certs.d")
+	v, err := newCosignVerifier(drivers.Config{ImageTrustPolicy: policy, DockerCertsDir: certsDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the registry is not trusted until the docker daemon trusts its CA
+	if _, err := v.VerifyImage(context.Background(), nil, host+"/fn:signed"); err == nil {
+		t.Fatal("expected the registry not to be trusted without its CA")
+	}
+	if err := os.MkdirAll(filepath.Join(certsDir, host), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(filepath.Join(certsDir, host, "ca.crt"), ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := v.VerifyImage(context.Background(), nil, host+"/fn:signed")
+	if err != nil || digest != sha256Digest(reg.manifests["signed"]) {
+		t.Fatalf("expected the signature to be verified through the registry CA, got %q %v", digest, err)
+	}
+}
+
+// testVerifier admits images with the digest it returns, counting how many it verified
+type testVerifier struct {
+	digest   string
+	err      error
+	verified int
+}
+
+func (v *testVerifier) VerifyImage(ctx context.Context, auth *docker.AuthConfiguration, image string) (string, error) {
+	v.verified++
+	return v.digest, v.err
+}
+
+func TestVerifyLocalImage(t *testing.T) {
+	ctx := context.Background()
+	const signed = "sha256:066978f9d271cfde1586ee5c6a3904a683a228252d6bc831e9c64a6fb823bc10"
+
+	for i, test := range []struct {
+		repoDigests       []string
+		disablePulls      bool
+		expectedNeedsPull bool
+		expectedCode      string
+	}{
+		{[]string{"busybox@" + signed}, false, false, ""},
+		// images loaded or pulled before their signatures were required are pulled again, if they may be
+		{nil, false, true, ""},
+		{[]string{"busybox@sha256:unsigned"}, false, true, ""},
+		{nil, true, false, "image_untrusted"},
+	} {
+		verifier := &testVerifier{digest: signed}
+		dkr := &DockerDriver{
+			conf:     drivers.Config{DisableImagePulls: test.disablePulls},
+			docker:   &mockClient{inspectImage: &docker.Image{ID: fmt.Sprintf("image%d", i), RepoDigests: test.repoDigests, Config: &docker.Config{}}},
+			network:  NewDockerNetworks(drivers.Config{}),
+			verifier: verifier,
+		}
+		c, err := dkr.CreateCookie(ctx, createTask(fmt.Sprintf("test-verify-local-image-%d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		needsPull, err := c.ValidateImage(ctx)
+		if code, _ := models.GetErrorCode(err); code != test.expectedCode || (test.expectedCode == "" && err != nil) {
+			t.Errorf("Test %d: expected error code %q, got %v", i, test.expectedCode, err)
+		}
+		if needsPull != test.expectedNeedsPull {
+			t.Errorf("Test %d: expected needs pull %v, got %v", i, test.expectedNeedsPull, needsPull)
+		}
+		c.Close(ctx)
+
+		if test.expectedNeedsPull || test.expectedCode != "" {
+			continue
+		}
+		// verified images are not verified again
+		c, err = dkr.CreateCookie(ctx, createTask(fmt.Sprintf("test-verify-local-image-%d-again", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.ValidateImage(ctx); err != nil || verifier.verified != 1 {
+			t.Errorf("Test %d: expected the image to be verified once, verified %d times, got %v", i, verifier.verified, err)
+		}
+		c.Close(ctx)
+	}
+}
+
+func TestCosignVerifierPolicy(t *testing.T) {
+	if v, err := newCosignVerifier(drivers.Config{}); v != nil || err != nil {
+		t.Fatalf("expected no verifier without a policy, got %v %v", v, err)
+	}
+	if v, err := newCosignVerifier(drivers.Config{RequireSignedImages: true}); v == nil || err != nil {
+		t.Fatalf("expected a verifier rejecting every image, got %v %v", v, err)
+	}
+
+	dir, err := ioutil.TempDir("", "image_trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i, policy := range []string{
+		`{"default": {"keys": []}}`,
+		`{"registries": {"docker.io": {"keys": ["/does/not/exist.pub"]}}}`,
+		`{"registries": {"docker.io": {"keys": ["` + filepath.Join(dir, "policy.json") + `"]}}}`,
+		`{"default": {"keys": ["` + filepath.Join(dir, "policy.json") + `"], "mode": "audit"}}`,
+	} {
+		file := filepath.Join(dir, "policy.json")
+		if err := ioutil.WriteFile(file, []byte(policy), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newCosignVerifier(drivers.Config{ImageTrustPolicy: file}); err == nil {
+			t.Errorf("Test %d: expected policy %s to be invalid", i, policy)
+		}
+	}
+}
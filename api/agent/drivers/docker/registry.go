@@ -2,9 +2,9 @@ package docker
 
 import (
 	"net/url"
-	"os"
 	"strings"
 
+	"github.com/fnproject/fn/api/config"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/sirupsen/logrus"
 )
@@ -13,10 +13,25 @@ var (
 	defaultPrivateRegistries = []string{"hub.docker.com", "index.docker.io"}
 )
 
+// RegistryAuths are the credentials of the registries images are pulled from, for drivers that pull images without
+// docker to authenticate as the docker driver does
+type RegistryAuths map[string]driverAuthConfig
+
+// RegistryAuthsFromEnv returns the credentials of FN_DOCKER_AUTH, or of the docker config of the agent
+func RegistryAuthsFromEnv() (RegistryAuths, error) {
+	return registryFromEnv()
+}
+
+// Find returns the credentials of the registry reg, those of docker hub if reg is empty, and empty credentials if
+// there are none
+func (a RegistryAuths) Find(reg string) *docker.AuthConfiguration {
+	return findRegistryConfig(reg, a)
+}
+
 func registryFromEnv() (map[string]driverAuthConfig, error) {
 	var auths *docker.AuthConfigurations
 	var err error
-	if reg := os.Getenv("FN_DOCKER_AUTH"); reg != "" {
+	if reg := config.String("FN_DOCKER_AUTH", ""); reg != "" {
 		auths, err = docker.NewAuthConfigurations(strings.NewReader(reg))
 	} else {
 		auths, err = docker.NewAuthConfigurationsFromDockerCfg()
@@ -6,6 +6,7 @@ import (
 	"context"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/fnproject/fn/api/agent/drivers/stats"
 	"github.com/fnproject/fn/api/common"
@@ -83,6 +84,54 @@ type Driver interface {
 	Close() error
 }
 
+// ImageLoader is a Driver that loads images from archives rather than pulling them, such as docker save tarballs,
+// for runners that cannot reach a registry
+type ImageLoader interface {
+	LoadImages(ctx context.Context, archive string) error
+}
+
+// ImagePrePuller is a Driver that pulls images ahead of the containers that run them, so that the first calls of fns
+// after a deploy do not wait on the pull
+type ImagePrePuller interface {
+	PrePullImage(ctx context.Context, image string) error
+}
+
+// ImageCache is a Driver that caches the images of fns, evicting the least recently used ones, and lets operators see
+// the images it has, pin those it must never evict and evict others at once
+type ImageCache interface {
+	// ListCachedImages returns the images of the driver and the references pinned
+	ListCachedImages(ctx context.Context) (*CachedImages, error)
+	// PinImage keeps the images matching ref, by ID, tag or digest reference, from being evicted until unpinned
+	PinImage(ctx context.Context, ref string) error
+	UnpinImage(ctx context.Context, ref string) error
+	// EvictImage removes the images matching ref that are neither in use nor pinned
+	EvictImage(ctx context.Context, ref string) error
+	// SetImageCacheMaxSize changes the total size of images, in bytes, past which the least recently used are evicted
+	SetImageCacheMaxSize(size uint64) error
+}
+
+// CachedImages are the images of an ImageCache
+type CachedImages struct {
+	Items []CachedImage `json:"items"`
+	// Pins are the references pinned, whether or not images match them
+	Pins []string `json:"pins"`
+}
+
+// CachedImage is an image of an ImageCache
+type CachedImage struct {
+	// Daemon is the name of the docker daemon the image is on, on drivers with several
+	Daemon  string   `json:"daemon,omitempty"`
+	ID      string   `json:"id"`
+	Tags    []string `json:"tags,omitempty"`
+	Digests []string `json:"digests,omitempty"`
+	Size    uint64   `json:"size"`
+	// InUse is the number of containers using the image
+	InUse  uint64 `json:"in_use"`
+	Pinned bool   `json:"pinned"`
+	// Exempt images are never evicted by the configuration of the driver
+	Exempt bool `json:"exempt"`
+}
+
 // RunResult indicates only the final state of the task.
 type RunResult interface {
 	// Error is an actionable/checkable error from the container, nil if
@@ -127,6 +176,10 @@ type ContainerTask interface {
 	// Image returns the runtime specific image to run.
 	Image() string
 
+	// ImagePullPolicy is when the image is pulled, one of models.ImagePullAlways,
+	// ImagePullIfNotPresent or ImagePullNever. Empty is ImagePullIfNotPresent.
+	ImagePullPolicy() string
+
 	// Driver will write output log from task execution to these writers. Must be
 	// non-nil. Use io.Discard if log is irrelevant.
 	Logger() (stdout, stderr io.Writer)
@@ -136,7 +189,7 @@ type ContainerTask interface {
 
 	// Volumes returns an array of 2-element tuples indicating storage volume mounts.
 	// The first element is the path on the host, and the second element is the
-	// path in the container.
+	// path in the container, optionally followed by bind options such as :ro.
 	Volumes() [][2]string
 
 	// Memory determines the max amount of RAM given to the container to use.
@@ -209,6 +262,32 @@ type ContainerTask interface {
 	// Returns true if network is disabled.
 	DisableNet() bool
 
+	// StopSignal is the signal sent to the container to ask it to exit when it
+	// is closed, empty for SIGTERM.
+	StopSignal() string
+
+	// StopTimeout is how long the container is given to exit after its stop
+	// signal when it is closed, before it is killed. 0 kills it straight away.
+	StopTimeout() time.Duration
+
+	// DaemonLabels are the labels the docker daemon the container is run on
+	// must have, on drivers with several daemons. Empty runs the container on
+	// a daemon without labels.
+	DaemonLabels() map[string]string
+
+	// Networks are the docker networks the container may join, one of them
+	// is picked. Empty allows any of the networks of the driver.
+	Networks() []string
+
+	// GPUs are the IDs of the GPU devices given to the container, empty for
+	// none. They are the indexes or UUIDs the GPU runtime of the driver takes.
+	GPUs() []string
+
+	// SeccompProfile and AppArmorProfile are the names of the security
+	// profiles of the driver the container runs with, empty for its defaults.
+	SeccompProfile() string
+	AppArmorProfile() string
+
 	// BeforeCall is invoked just prior to running an invocation.
 	// The Task is definitely going to be used for this invocation.
 	// Invocation extensions are passed to the Before and After calls
@@ -252,6 +331,7 @@ type Config struct {
 	Docker                        string `json:"docker"`
 	DockerNetworks                string `json:"docker_networks"`
 	DockerLoadFile                string `json:"docker_load_file"`
+	DockerDaemons                 string `json:"docker_daemons"`
 	ServerVersion                 string `json:"server_version"`
 	PreForkPoolSize               uint64 `json:"pre_fork_pool_size"`
 	PreForkImage                  string `json:"pre_fork_image"`
@@ -266,6 +346,46 @@ type Config struct {
 	ImageCleanExemptTags          string `json:"image_clean_exempt_tags"`
 	ImageEnableVolume             bool   `json:"image_enable_volume"`
 	DisableUnprivilegedContainers bool   `json:"disable_unprivileged_containers"`
+	FreezeMemoryPercent           uint64 `json:"freeze_memory_percent"`
+	CgroupRoot                    string `json:"cgroup_root"`
+	DevMode                       bool   `json:"dev_mode"`
+	DisableImagePulls             bool   `json:"disable_image_pulls"`
+	FsStatsIntervalMsecs          uint64 `json:"fs_stats_interval_msecs"`
+	MockScript                    string `json:"mock_script"`
+	// GPURuntime is the container runtime that gives containers their GPUs, eg. nvidia
+	GPURuntime string `json:"gpu_runtime"`
+	// SeccompProfilesDir holds the seccomp profiles containers may run with, as <name>.json files
+	SeccompProfilesDir string `json:"seccomp_profiles_dir"`
+	// SeccompProfile is the name of the seccomp profile containers run with by default, docker's if empty
+	SeccompProfile string `json:"seccomp_profile"`
+	// AppArmorProfiles is a comma separated list of the AppArmor profiles loaded on the hosts containers may run with
+	AppArmorProfiles string `json:"apparmor_profiles"`
+	// AppArmorProfile is the AppArmor profile containers run with by default, docker's if empty
+	AppArmorProfile string `json:"apparmor_profile"`
+	// ImageTrustPolicy is the path of a JSON policy of the keys the images of each registry are signed with, whose
+	// cosign signatures are verified before images are pulled
+	ImageTrustPolicy string `json:"image_trust_policy"`
+	// RequireSignedImages rejects the images of registries without keys in the ImageTrustPolicy, and the images the
+	// policy would only warn about
+	RequireSignedImages bool `json:"require_signed_images"`
+	// DockerCertsDir is the certs.d dir of the docker daemon, whose registry CAs are trusted when verifying the
+	// signatures of images too
+	DockerCertsDir string `json:"docker_certs_dir"`
+	// RecoverableContainers are the containers left running by an agent that shut down, for a Recoverer to adopt
+	// rather than remove as leaked
+	RecoverableContainers []string `json:"recoverable_containers"`
+	// Containerd is the address of the containerd socket of the containerd driver
+	Containerd string `json:"containerd"`
+	// ContainerdNamespace is the containerd namespace the containerd driver keeps its images and containers in
+	ContainerdNamespace string `json:"containerd_namespace"`
+	// ContainerdSnapshotter is the snapshotter the containerd driver unpacks images and creates containers with
+	ContainerdSnapshotter string `json:"containerd_snapshotter"`
+	// ContainerdNetNS is a whitespace separated list of the network namespaces in /var/run/netns the containers of
+	// the containerd driver join, the containers share the network of the host if empty
+	ContainerdNetNS string `json:"containerd_netns"`
+	// ContainerdWarmSnapshots is the number of rootfs snapshots the containerd driver keeps prepared ahead for each
+	// image it created containers from, none if 0
+	ContainerdWarmSnapshots uint64 `json:"containerd_warm_snapshots"`
 }
 
 // https://github.com/fsouza/go-dockerclient/blob/master/misc.go#L166
@@ -280,10 +400,10 @@ func parseRepositoryTag(repoTag string) (repository, tag string) {
 	if n < 0 {
 		return repoTag, digest
 	}
-	if digest != "" {
-		return repoTag[:n], digest
-	}
 	if tag := repoTag[n+1:]; !strings.Contains(tag, "/") {
+		if digest != "" {
+			return repoTag[:n], digest
+		}
 		return repoTag[:n], tag
 	}
 	return repoTag, digest
@@ -24,6 +24,7 @@ func TestParseImage(t *testing.T) {
 		"quay.com/fnproject/fn-test-utils@sha256:066978f9d271cfde1586ee5c6a3904a683a228252d6bc831e9c64a6fb823bc10":                                    {"quay.com", "fnproject/fn-test-utils", "sha256:066978f9d271cfde1586ee5c6a3904a683a228252d6bc831e9c64a6fb823bc10"},
 		"quay.com:8080/fnproject/fn-test-utils:v2@sha256:066978f9d271cfde1586ee5c6a3904a683a228252d6bc831e9c64a6fb823bc10":                            {"quay.com:8080", "fnproject/fn-test-utils", "sha256:066978f9d271cfde1586ee5c6a3904a683a228252d6bc831e9c64a6fb823bc10"},
 		"localhost.localdomain:5000/samalba/hipache:latest@sha256:066978f9d271cfde1586ee5c6a3904a683a228252d6bc831e9c64a6fb823bc10":                   {"localhost.localdomain:5000", "samalba/hipache", "sha256:066978f9d271cfde1586ee5c6a3904a683a228252d6bc831e9c64a6fb823bc10"},
+		"localhost.localdomain:5000/samalba/hipache@sha256:066978f9d271cfde1586ee5c6a3904a683a228252d6bc831e9c64a6fb823bc10":                          {"localhost.localdomain:5000", "samalba/hipache", "sha256:066978f9d271cfde1586ee5c6a3904a683a228252d6bc831e9c64a6fb823bc10"},
 		"localhost.localdomain:5000/samalba/hipache/isthisallowedeven:latest@sha256:066978f9d271cfde1586ee5c6a3904a683a228252d6bc831e9c64a6fb823bc10": {"localhost.localdomain:5000", "samalba/hipache/isthisallowedeven", "sha256:066978f9d271cfde1586ee5c6a3904a683a228252d6bc831e9c64a6fb823bc10"},
 	}
 
@@ -0,0 +1,29 @@
+package drivers
+
+import (
+	"context"
+)
+
+const (
+	// StatFsUsage is the stat metric of the size of the writable layer of a container, in bytes
+	StatFsUsage = "fs_usage"
+	// StatTmpFsUsage is the stat metric of the usage of the /tmp tmpfs of a container, in bytes
+	StatTmpFsUsage = "tmpfs_usage"
+	// StatTmpFsInodes is the stat metric of the inodes used in the /tmp tmpfs of a container
+	StatTmpFsInodes = "tmpfs_inodes"
+)
+
+// FsUsage is the usage of the filesystems of a running container
+type FsUsage struct {
+	// Writable is the size of the writable layer of the container, in bytes
+	Writable uint64
+	// TmpFs is the usage of the /tmp tmpfs of the container, in bytes, 0 if it has none
+	TmpFs uint64
+	// TmpFsInodes is the number of inodes used in the /tmp tmpfs of the container
+	TmpFsInodes uint64
+}
+
+// FsUsageCookie is a Cookie reporting the filesystem usage of its container, once it runs
+type FsUsageCookie interface {
+	FsUsage(ctx context.Context) (FsUsage, error)
+}
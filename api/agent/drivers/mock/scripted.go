@@ -0,0 +1,418 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Outcomes of the steps of the sequences of an ImageScript
+const (
+	// StepOK succeeds the step
+	StepOK = "ok"
+	// StepFail fails a call as the fdk of a failed fn does, or the create of a container
+	StepFail = "fail"
+	// StepTimeout hangs a call until it times out
+	StepTimeout = "timeout"
+	// StepCrash exits the container in the middle of a call, with the exit code of the script
+	StepCrash = "crash"
+)
+
+// ScriptDefault is the image key of the script of images not listed in a Script
+const ScriptDefault = "*"
+
+// Script scripts how the containers of a Scripted driver behave, by image
+type Script struct {
+	Images map[string]*ImageScript `json:"images"`
+}
+
+// ImageScript scripts how the containers of an image behave. Containers serve calls as an fdk does, the output
+// of calls being their input unless the script says. Sequences are cycled through, and are counted across all the
+// containers of the image, so a script plays out the same way for the same order of calls.
+type ImageScript struct {
+	// PullDelayMsecs is how long the image takes to pull, it is pulled once per driver
+	PullDelayMsecs uint64 `json:"pull_delay_msecs,omitempty"`
+	// CreateDelayMsecs is how long containers take to create
+	CreateDelayMsecs uint64 `json:"create_delay_msecs,omitempty"`
+	// StartDelayMsecs is how long containers take to start listening once run
+	StartDelayMsecs uint64 `json:"start_delay_msecs,omitempty"`
+	// LatencyMsecs is how long calls take
+	LatencyMsecs uint64 `json:"latency_msecs,omitempty"`
+	// Status is the http status of the response of calls, 200 by default
+	Status int `json:"status,omitempty"`
+	// Headers are added to the response of calls
+	Headers map[string]string `json:"headers,omitempty"`
+	// Output is the output of calls, their input if unset
+	Output *string `json:"output,omitempty"`
+	// MaxCalls is how many calls a container serves before it exits, 0 for no limit
+	MaxCalls uint64 `json:"max_calls,omitempty"`
+	// ExitCode is the exit code of containers exiting on their own, after a crash or after MaxCalls
+	ExitCode int `json:"exit_code,omitempty"`
+	// CreateSequence are the outcomes of creating containers, StepOK or StepFail
+	CreateSequence []string `json:"create_sequence,omitempty"`
+	// CallSequence are the outcomes of calls, StepOK, StepFail, StepTimeout or StepCrash
+	CallSequence []string `json:"call_sequence,omitempty"`
+}
+
+// LoadScript reads a JSON Script from a file
+func LoadScript(path string) (*Script, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Script
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid driver script %s: %v", path, err)
+	}
+	for image, is := range s.Images {
+		for _, step := range is.CreateSequence {
+			if step != StepOK && step != StepFail {
+				return nil, fmt.Errorf("invalid driver script %s: invalid create step %q of %s", path, step, image)
+			}
+		}
+		for _, step := range is.CallSequence {
+			if step != StepOK && step != StepFail && step != StepTimeout && step != StepCrash {
+				return nil, fmt.Errorf("invalid driver script %s: invalid call step %q of %s", path, step, image)
+			}
+		}
+	}
+	return &s, nil
+}
+
+func init() {
+	drivers.Register("mock", func(config drivers.Config) (drivers.Driver, error) {
+		if config.MockScript == "" {
+			return NewScripted(nil), nil
+		}
+		script, err := LoadScript(config.MockScript)
+		if err != nil {
+			return nil, err
+		}
+		return NewScripted(script), nil
+	})
+}
+
+// Scripted is a Driver running fns without docker, as scripted by image, so that extensions and placers may be
+// load tested on any machine. Its containers listen on the unix socket of their task, as fdks do. Containers that
+// are detached keep running for as long as the process does, for an agent of the process to adopt.
+type Scripted struct {
+	script *Script
+
+	lock     sync.Mutex
+	pulled   map[string]bool
+	creates  map[string]uint64
+	calls    map[string]uint64
+	detached map[string]*scriptedCookie
+}
+
+var _ drivers.Driver = new(Scripted)
+var _ drivers.Recoverer = new(Scripted)
+
+// NewScripted returns a driver playing out script, nil scripts every image as an echo fn
+func NewScripted(script *Script) *Scripted {
+	if script == nil {
+		script = &Script{}
+	}
+	return &Scripted{
+		script:   script,
+		pulled:   make(map[string]bool),
+		creates:  make(map[string]uint64),
+		calls:    make(map[string]uint64),
+		detached: make(map[string]*scriptedCookie),
+	}
+}
+
+func (s *Scripted) imageScript(image string) *ImageScript {
+	if is, ok := s.script.Images[image]; ok {
+		return is
+	}
+	if is, ok := s.script.Images[ScriptDefault]; ok {
+		return is
+	}
+	return &ImageScript{}
+}
+
+// step returns the next step of a sequence of image, counted in counts
+func (s *Scripted) step(counts map[string]uint64, image string, sequence []string) string {
+	if len(sequence) == 0 {
+		return StepOK
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	n := counts[image]
+	counts[image]++
+	return sequence[n%uint64(len(sequence))]
+}
+
+func (s *Scripted) CreateCookie(ctx context.Context, task drivers.ContainerTask) (drivers.Cookie, error) {
+	return &scriptedCookie{d: s, task: task, script: s.imageScript(task.Image())}, nil
+}
+
+// AdoptCookie implements drivers.Recoverer, for containers detached by an agent of the process
+func (s *Scripted) AdoptCookie(ctx context.Context, task drivers.ContainerTask) (drivers.Cookie, error) {
+	s.lock.Lock()
+	c, ok := s.detached[task.Id()]
+	delete(s.detached, task.Id())
+	s.lock.Unlock()
+	if !ok || c.hasExited() {
+		return nil, fmt.Errorf("container %s is not running", task.Id())
+	}
+
+	c.lock.Lock()
+	c.task = task
+	c.adopted = true
+	c.lock.Unlock()
+	return c, nil
+}
+
+// RemoveContainer implements drivers.Recoverer
+func (s *Scripted) RemoveContainer(ctx context.Context, id string) error {
+	s.lock.Lock()
+	c, ok := s.detached[id]
+	delete(s.detached, id)
+	s.lock.Unlock()
+	if !ok {
+		return fmt.Errorf("container %s not found", id)
+	}
+	return c.Close(ctx)
+}
+
+func (s *Scripted) SetPullImageRetryPolicy(policy common.BackOffConfig, checker drivers.RetryErrorChecker) error {
+	return nil
+}
+
+func (s *Scripted) GetSlotKeyExtensions(extn map[string]string) string {
+	return ""
+}
+
+func (s *Scripted) Close() error {
+	return nil
+}
+
+// sleep waits for msecs, or until ctx is done
+func sleep(ctx context.Context, msecs uint64) error {
+	if msecs == 0 {
+		return nil
+	}
+	timer := time.NewTimer(time.Duration(msecs) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type scriptedCookie struct {
+	d      *Scripted
+	task   drivers.ContainerTask
+	script *ImageScript
+
+	lock     sync.Mutex
+	server   *http.Server
+	socket   string
+	calls    uint64
+	exitCode int
+	exited   chan struct{}
+	// set once the container is adopted, it is running already
+	adopted bool
+}
+
+var _ drivers.Cookie = new(scriptedCookie)
+var _ drivers.DetachableCookie = new(scriptedCookie)
+
+func (c *scriptedCookie) ValidateImage(ctx context.Context) (bool, error) {
+	c.d.lock.Lock()
+	defer c.d.lock.Unlock()
+	return c.script.PullDelayMsecs > 0 && !c.d.pulled[c.task.Image()], nil
+}
+
+func (c *scriptedCookie) PullImage(ctx context.Context) error {
+	if err := sleep(ctx, c.script.PullDelayMsecs); err != nil {
+		return err
+	}
+	c.d.lock.Lock()
+	c.d.pulled[c.task.Image()] = true
+	c.d.lock.Unlock()
+	return nil
+}
+
+func (c *scriptedCookie) CreateContainer(ctx context.Context) error {
+	if c.adopted {
+		return nil
+	}
+	if err := sleep(ctx, c.script.CreateDelayMsecs); err != nil {
+		return err
+	}
+	if c.d.step(c.d.creates, c.task.Image(), c.script.CreateSequence) == StepFail {
+		return models.NewAPIError(http.StatusInternalServerError, errors.New("scripted container create failure"))
+	}
+	return nil
+}
+
+func (c *scriptedCookie) Freeze(context.Context) error   { return nil }
+func (c *scriptedCookie) Unfreeze(context.Context) error { return nil }
+func (c *scriptedCookie) ContainerOptions() interface{}  { return nil }
+
+// Run listens on the unix socket of the task, after the start delay of the script, until the container exits
+func (c *scriptedCookie) Run(ctx context.Context) (drivers.WaitResult, error) {
+	if c.adopted {
+		return c, nil
+	}
+	listener := c.task.EnvVars()["FN_LISTENER"]
+	if !strings.HasPrefix(listener, "unix:") || c.task.UDSAgentPath() == "" {
+		return nil, errors.New("scripted containers need a unix socket to listen on")
+	}
+	c.socket = filepath.Join(c.task.UDSAgentPath(), filepath.Base(strings.TrimPrefix(listener, "unix:")))
+	c.exited = make(chan struct{})
+	c.server = &http.Server{Handler: http.HandlerFunc(c.serve)}
+
+	go func() {
+		if err := sleep(ctx, c.script.StartDelayMsecs); err != nil {
+			c.exit(0)
+			return
+		}
+		l, err := net.Listen("unix", c.socket)
+		if err != nil {
+			logrus.WithError(err).WithField("container_id", c.task.Id()).Error("scripted container failed to listen")
+			c.exit(1)
+			return
+		}
+		c.server.Serve(l)
+	}()
+	return c, nil
+}
+
+// Wait implements drivers.WaitResult, containers keep running once ctx is done until they are closed
+func (c *scriptedCookie) Wait(ctx context.Context) drivers.RunResult {
+	select {
+	case <-c.exited:
+	case <-ctx.Done():
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			return &runResult{err: context.DeadlineExceeded, status: drivers.StatusTimeout}
+		default:
+			return &runResult{err: context.Canceled, status: drivers.StatusCancelled}
+		}
+	}
+
+	c.lock.Lock()
+	code := c.exitCode
+	c.lock.Unlock()
+	switch code {
+	case 0:
+		return &runResult{status: drivers.StatusSuccess}
+	case 137:
+		return &runResult{err: models.NewAPIError(http.StatusBadGateway, errors.New("container out of memory")), status: drivers.StatusKilled}
+	default:
+		return &runResult{err: models.NewAPIError(http.StatusBadGateway, fmt.Errorf("container exit code %d", code)), status: drivers.StatusError}
+	}
+}
+
+func (c *scriptedCookie) hasExited() bool {
+	select {
+	case <-c.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// exit stops the container with code, once
+func (c *scriptedCookie) exit(code int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	select {
+	case <-c.exited:
+		return
+	default:
+	}
+	c.exitCode = code
+	close(c.exited)
+	go c.server.Close()
+}
+
+func (c *scriptedCookie) serve(w http.ResponseWriter, r *http.Request) {
+	c.lock.Lock()
+	c.calls++
+	last := c.script.MaxCalls > 0 && c.calls >= c.script.MaxCalls
+	c.lock.Unlock()
+
+	step := c.d.step(c.d.calls, c.task.Image(), c.script.CallSequence)
+	if err := sleep(r.Context(), c.script.LatencyMsecs); err != nil {
+		return
+	}
+
+	switch step {
+	case StepTimeout:
+		<-r.Context().Done()
+		return
+	case StepCrash:
+		c.exit(c.script.ExitCode)
+		panic(http.ErrAbortHandler)
+	case StepFail:
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, `{"message":"scripted fn failure"}`)
+	default:
+		for k, v := range c.script.Headers {
+			w.Header().Set(k, v)
+		}
+		if c.script.Status != 0 {
+			w.Header().Set("Fn-Http-Status", strconv.Itoa(c.script.Status))
+		}
+		w.WriteHeader(http.StatusOK)
+		if c.script.Output != nil {
+			io.WriteString(w, *c.script.Output)
+		} else {
+			io.Copy(w, r.Body)
+		}
+	}
+
+	if last {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		go c.exit(c.script.ExitCode)
+	}
+}
+
+// Detach implements drivers.DetachableCookie
+func (c *scriptedCookie) Detach(ctx context.Context) error {
+	if c.exited == nil || c.hasExited() {
+		return errors.New("container is not running")
+	}
+	c.d.lock.Lock()
+	c.d.detached[c.task.Id()] = c
+	c.d.lock.Unlock()
+	return nil
+}
+
+func (c *scriptedCookie) Close(context.Context) error {
+	if c.exited != nil {
+		c.exit(0)
+	}
+	if c.socket != "" {
+		os.Remove(c.socket)
+	}
+	return nil
+}
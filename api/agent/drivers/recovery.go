@@ -0,0 +1,22 @@
+package drivers
+
+import (
+	"context"
+)
+
+// Recoverer is a Driver whose containers may be left running by an agent that shuts down, for the agent that
+// restarts to adopt them rather than start its hot containers cold again. Containers are named after the ids of
+// their tasks, and the ids of the containers left running are passed in Config.RecoverableContainers.
+type Recoverer interface {
+	// AdoptCookie returns a cookie for the container left running for task, in place of CreateCookie. The
+	// container is neither created nor started again, Run attaches to it.
+	AdoptCookie(ctx context.Context, task ContainerTask) (Cookie, error)
+	// RemoveContainer removes a container left running that is not adopted
+	RemoveContainer(ctx context.Context, id string) error
+}
+
+// DetachableCookie is a Cookie of a Recoverer, which may be released leaving its container running
+type DetachableCookie interface {
+	// Detach releases the cookie in place of Close, leaving its container running and unfrozen for an agent to adopt
+	Detach(ctx context.Context) error
+}
@@ -2,6 +2,7 @@ package drivers
 
 import (
 	"fmt"
+	"sort"
 )
 
 type DriverFunc func(config Config) (Driver, error)
@@ -22,3 +23,13 @@ func New(driverName string, config Config) (Driver, error) {
 	}
 	return driverFunc(config)
 }
+
+// Registered returns the names of the drivers registered in this process
+func Registered() []string {
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,256 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/id"
+	"github.com/fnproject/fn/api/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Operations faults are injected into
+const (
+	// FaultPull faults are injected before images are pulled, on runners
+	FaultPull = "pull"
+	// FaultCreate faults are injected before containers are created, on runners
+	FaultCreate = "create"
+	// FaultPlace faults are injected before calls are placed on runners, on lbs
+	FaultPlace = "place"
+)
+
+const (
+	// maxFaultDelay is the longest delay a fault may inject
+	maxFaultDelay = 10 * time.Minute
+	// defaultFaultTTL is how long faults are injected for unless they say, in seconds, so forgotten faults go away
+	defaultFaultTTL = 3600
+	// maxFaultTTL is the longest faults may be injected for, in seconds
+	maxFaultTTL = 24 * 3600
+)
+
+var (
+	// ErrFaultInjectionDisabled is returned for managing faults on nodes without fault injection enabled
+	ErrFaultInjectionDisabled = models.NewAPIError(http.StatusNotFound, errors.New("Fault injection is not enabled on this node"))
+	// ErrFaultNotFound is returned for removing a fault that is not injected
+	ErrFaultNotFound = models.NewAPIError(http.StatusNotFound, errors.New("Fault not found"))
+	// ErrInvalidFault is returned for faults that are not valid
+	ErrInvalidFault = models.NewAPIError(http.StatusBadRequest, fmt.Errorf("Invalid fault, it must have an op of %s, %s "+
+		"or %s, a percent of between 0 and 100, a delay_msecs of up to %v and or a status between 400 and 599, and a ttl "+
+		"of up to %d seconds", FaultPull, FaultCreate, FaultPlace, maxFaultDelay, maxFaultTTL))
+)
+
+// Fault delays or fails a share of the operations of a kind, for calls of an app or fn or any call
+type Fault struct {
+	ID string `json:"id"`
+	// Op is the operation the fault is injected into, FaultPull, FaultCreate or FaultPlace
+	Op string `json:"op"`
+	// AppID scopes the fault to the calls of an app
+	AppID string `json:"app_id,omitempty"`
+	// FnID scopes the fault to the calls of a fn
+	FnID string `json:"fn_id,omitempty"`
+	// Percent is the share of operations the fault is injected into
+	Percent float64 `json:"percent"`
+	// DelayMsecs delays the operations
+	DelayMsecs uint64 `json:"delay_msecs,omitempty"`
+	// Status fails the operations with an error of this status, after any delay
+	Status int `json:"status,omitempty"`
+	// TTL is how long the fault is injected for, in seconds
+	TTL int `json:"ttl,omitempty"`
+	// ExpiresAt is when the fault stops being injected
+	ExpiresAt common.DateTime `json:"expires_at"`
+	// Injected is how many operations the fault was injected into
+	Injected uint64 `json:"injected"`
+}
+
+func (f *Fault) matches(op string, call *call) bool {
+	return f.Op == op && (f.AppID == "" || f.AppID == call.AppID) && (f.FnID == "" || f.FnID == call.FnID)
+}
+
+// FaultInjector is implemented by agents injecting faults into their operations, so that the retries and backoffs
+// of a cluster may be tested end to end. It is only ever enabled on staging clusters, see EnvEnableFaultInjection.
+type FaultInjector interface {
+	// Faults returns the faults injected
+	Faults() ([]Fault, error)
+	// AddFault starts injecting a fault
+	AddFault(f Fault) (*Fault, error)
+	// RemoveFault stops injecting a fault
+	RemoveFault(id string) error
+}
+
+var _ FaultInjector = new(agent)
+var _ FaultInjector = new(lbAgent)
+var _ FaultInjector = new(pureRunner)
+
+// faultInjector holds the faults of a node, it is nil on nodes without fault injection enabled
+type faultInjector struct {
+	lock   sync.Mutex
+	faults []*Fault
+}
+
+func newFaultInjector(cfg *Config) *faultInjector {
+	if !cfg.EnableFaultInjection {
+		return nil
+	}
+	logrus.Warn("Fault injection is enabled, this node may be told to fail calls")
+	return &faultInjector{}
+}
+
+// expire drops the faults that expired, with the lock held
+func (fi *faultInjector) expire() {
+	now := time.Now()
+	faults := fi.faults[:0]
+	for _, f := range fi.faults {
+		if now.Before(time.Time(f.ExpiresAt)) {
+			faults = append(faults, f)
+		}
+	}
+	fi.faults = faults
+}
+
+func (fi *faultInjector) list() ([]Fault, error) {
+	if fi == nil {
+		return nil, ErrFaultInjectionDisabled
+	}
+	fi.lock.Lock()
+	defer fi.lock.Unlock()
+	fi.expire()
+	faults := make([]Fault, 0, len(fi.faults))
+	for _, f := range fi.faults {
+		faults = append(faults, *f)
+	}
+	return faults, nil
+}
+
+func (fi *faultInjector) add(f Fault) (*Fault, error) {
+	if fi == nil {
+		return nil, ErrFaultInjectionDisabled
+	}
+	if f.Op != FaultPull && f.Op != FaultCreate && f.Op != FaultPlace {
+		return nil, ErrInvalidFault
+	}
+	if f.Percent <= 0 || f.Percent > 100 || time.Duration(f.DelayMsecs)*time.Millisecond > maxFaultDelay {
+		return nil, ErrInvalidFault
+	}
+	if (f.DelayMsecs == 0 && f.Status == 0) || (f.Status != 0 && (f.Status < 400 || f.Status > 599)) {
+		return nil, ErrInvalidFault
+	}
+	if f.TTL == 0 {
+		f.TTL = defaultFaultTTL
+	}
+	if f.TTL < 0 || f.TTL > maxFaultTTL {
+		return nil, ErrInvalidFault
+	}
+
+	f.ID = id.New().String()
+	f.ExpiresAt = common.DateTime(time.Now().Add(time.Duration(f.TTL) * time.Second))
+	f.Injected = 0
+
+	fi.lock.Lock()
+	defer fi.lock.Unlock()
+	fi.expire()
+	fi.faults = append(fi.faults, &f)
+	return &f, nil
+}
+
+func (fi *faultInjector) remove(id string) error {
+	if fi == nil {
+		return ErrFaultInjectionDisabled
+	}
+	fi.lock.Lock()
+	defer fi.lock.Unlock()
+	for i, f := range fi.faults {
+		if f.ID == id {
+			fi.faults = append(fi.faults[:i], fi.faults[i+1:]...)
+			return nil
+		}
+	}
+	return ErrFaultNotFound
+}
+
+// inject delays or fails op for call, if a fault matching it is drawn. The first fault matching op and call is
+// drawn, so narrower faults should be added before broader ones.
+func (fi *faultInjector) inject(ctx context.Context, op string, call *call) error {
+	if fi == nil {
+		return nil
+	}
+
+	fi.lock.Lock()
+	fi.expire()
+	var fault Fault
+	for _, f := range fi.faults {
+		if f.matches(op, call) {
+			if rand.Float64()*100 < f.Percent {
+				f.Injected++
+				fault = *f
+			}
+			break
+		}
+	}
+	fi.lock.Unlock()
+	if fault.ID == "" {
+		return nil
+	}
+
+	common.Logger(ctx).WithFields(logrus.Fields{"fault_id": fault.ID, "op": op}).Warn("Injecting fault")
+	if fault.DelayMsecs > 0 {
+		timer := time.NewTimer(time.Duration(fault.DelayMsecs) * time.Millisecond)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if fault.Status != 0 {
+		return models.NewAPIError(fault.Status, fmt.Errorf("Fault injected into %s", op))
+	}
+	return nil
+}
+
+// Faults implements FaultInjector
+func (a *agent) Faults() ([]Fault, error) { return a.faults.list() }
+
+// AddFault implements FaultInjector
+func (a *agent) AddFault(f Fault) (*Fault, error) { return a.faults.add(f) }
+
+// RemoveFault implements FaultInjector
+func (a *agent) RemoveFault(id string) error { return a.faults.remove(id) }
+
+// Faults implements FaultInjector
+func (a *lbAgent) Faults() ([]Fault, error) { return a.faults.list() }
+
+// AddFault implements FaultInjector
+func (a *lbAgent) AddFault(f Fault) (*Fault, error) { return a.faults.add(f) }
+
+// RemoveFault implements FaultInjector
+func (a *lbAgent) RemoveFault(id string) error { return a.faults.remove(id) }
+
+// Faults implements FaultInjector
+func (pr *pureRunner) Faults() ([]Fault, error) {
+	if fi, ok := pr.a.(FaultInjector); ok {
+		return fi.Faults()
+	}
+	return nil, ErrFaultInjectionDisabled
+}
+
+// AddFault implements FaultInjector
+func (pr *pureRunner) AddFault(f Fault) (*Fault, error) {
+	if fi, ok := pr.a.(FaultInjector); ok {
+		return fi.AddFault(f)
+	}
+	return nil, ErrFaultInjectionDisabled
+}
+
+// RemoveFault implements FaultInjector
+func (pr *pureRunner) RemoveFault(id string) error {
+	if fi, ok := pr.a.(FaultInjector); ok {
+		return fi.RemoveFault(id)
+	}
+	return ErrFaultInjectionDisabled
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestFaultValidation(t *testing.T) {
+	fi := newFaultInjector(&Config{EnableFaultInjection: true})
+
+	for i, f := range []Fault{
+		{Op: "start", Percent: 10, Status: 503},
+		{Op: FaultPull, Percent: 0, Status: 503},
+		{Op: FaultPull, Percent: 101, Status: 503},
+		{Op: FaultPull, Percent: 10},
+		{Op: FaultPull, Percent: 10, Status: 200},
+		{Op: FaultPull, Percent: 10, DelayMsecs: uint64(time.Hour / time.Millisecond)},
+		{Op: FaultPull, Percent: 10, Status: 503, TTL: maxFaultTTL + 1},
+	} {
+		if _, err := fi.add(f); err != ErrInvalidFault {
+			t.Errorf("Test %d: expected %v, got %v", i, ErrInvalidFault, err)
+		}
+	}
+
+	f, err := fi.add(Fault{Op: FaultPlace, Percent: 10, DelayMsecs: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ID == "" || time.Until(time.Time(f.ExpiresAt)) <= 0 {
+		t.Fatalf("expected the fault to get an id and expiry, got %+v", f)
+	}
+
+	var disabled *faultInjector
+	if _, err := disabled.add(Fault{Op: FaultPull, Percent: 10, Status: 503}); err != ErrFaultInjectionDisabled {
+		t.Fatalf("expected %v, got %v", ErrFaultInjectionDisabled, err)
+	}
+	if err := disabled.inject(context.Background(), FaultPull, &call{}); err != nil {
+		t.Fatalf("expected no fault without fault injection, got %v", err)
+	}
+}
+
+func TestFaultInject(t *testing.T) {
+	fi := newFaultInjector(&Config{EnableFaultInjection: true})
+	ctx := context.Background()
+
+	f, err := fi.add(Fault{Op: FaultCreate, FnID: "fn", Percent: 100, Status: http.StatusServiceUnavailable})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matching := &call{Call: &models.Call{AppID: "app", FnID: "fn"}}
+	other := &call{Call: &models.Call{AppID: "app", FnID: "other"}}
+
+	if err := fi.inject(ctx, FaultCreate, matching); models.GetAPIErrorCode(err) != http.StatusServiceUnavailable {
+		t.Fatalf("expected the fault to be injected, got %v", err)
+	}
+	if err := fi.inject(ctx, FaultPull, matching); err != nil {
+		t.Fatalf("expected no fault injected into pulls, got %v", err)
+	}
+	if err := fi.inject(ctx, FaultCreate, other); err != nil {
+		t.Fatalf("expected no fault injected into the calls of other fns, got %v", err)
+	}
+
+	faults, _ := fi.list()
+	if len(faults) != 1 || faults[0].Injected != 1 {
+		t.Fatalf("expected the fault to be injected once, got %+v", faults)
+	}
+
+	if err := fi.remove(f.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := fi.remove(f.ID); err != ErrFaultNotFound {
+		t.Fatalf("expected %v, got %v", ErrFaultNotFound, err)
+	}
+
+	// delays give up with the operation
+	if _, err := fi.add(Fault{Op: FaultPull, Percent: 100, DelayMsecs: 60000}); err != nil {
+		t.Fatal(err)
+	}
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := fi.inject(cctx, FaultPull, matching); err != context.DeadlineExceeded {
+		t.Fatalf("expected the delay to end with the operation, got %v", err)
+	}
+
+	// and expired faults are dropped
+	fi.faults[0].ExpiresAt = common.DateTime(time.Now().Add(-time.Second))
+	if faults, _ := fi.list(); len(faults) != 0 {
+		t.Fatalf("expected expired faults to be dropped, got %+v", faults)
+	}
+}
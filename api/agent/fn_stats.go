@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/sirupsen/logrus"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+var (
+	// TriggerIDMetricKey is a tag for metrics, empty for calls not invoked through a trigger
+	TriggerIDMetricKey = common.MakeKey("trigger_id")
+
+	fnCallsMeasure        = common.MakeMeasure("fn_calls", "calls of the fn", "")
+	fnCallDurationMeasure = common.MakeMeasure("fn_call_duration", "time the calls of the fn spent executing", stats.UnitMilliseconds)
+	fnQueueWaitMeasure    = common.MakeMeasure("fn_queue_wait", "time the calls of the fn spent from their creation to their start", stats.UnitMilliseconds)
+	fnSlotWaitMeasure     = common.MakeMeasure("fn_slot_wait", "time the calls of the fn spent waiting for a container", stats.UnitMilliseconds)
+	fnColdStartsMeasure   = common.MakeMeasure("fn_cold_starts", "calls of the fn that were the first of their container", "")
+	fnImagePullsMeasure   = common.MakeMeasure("fn_image_pulls", "pulls of the image of the fn to start its containers", "")
+	fnServerErrorsMeasure = common.MakeMeasure("fn_server_errors", "calls of the fn that failed with a 5xx", "")
+)
+
+// RegisterFnViews creates and registers the views of the calls of each fn, tagged by app, fn and trigger. These
+// views have a series per fn, mind their cardinality with many fns.
+func RegisterFnViews(tagKeys []string, latencyDist []float64) {
+	keys := []tag.Key{AppIDMetricKey, FnIDMetricKey, TriggerIDMetricKey}
+	for _, key := range tagKeys {
+		switch key {
+		case AppIDMetricKey.Name(), FnIDMetricKey.Name(), TriggerIDMetricKey.Name():
+		default:
+			keys = append(keys, common.MakeKey(key))
+		}
+	}
+
+	err := view.Register(
+		common.CreateViewWithTags(fnCallsMeasure, view.Count(), keys),
+		common.CreateViewWithTags(fnCallDurationMeasure, view.Distribution(latencyDist...), keys),
+		common.CreateViewWithTags(fnQueueWaitMeasure, view.Distribution(latencyDist...), keys),
+		common.CreateViewWithTags(fnSlotWaitMeasure, view.Distribution(latencyDist...), keys),
+		common.CreateViewWithTags(fnColdStartsMeasure, view.Count(), keys),
+		common.CreateViewWithTags(fnImagePullsMeasure, view.Count(), keys),
+		common.CreateViewWithTags(fnServerErrorsMeasure, view.Count(), keys),
+	)
+	if err != nil {
+		logrus.WithError(err).Fatal("cannot register view")
+	}
+}
+
+// withFnTags tags ctx with the app, fn and trigger of call, for the fn metrics recorded with it
+func withFnTags(ctx context.Context, call *call) context.Context {
+	ctx, err := tag.New(ctx,
+		tag.Upsert(AppIDMetricKey, call.AppID),
+		tag.Upsert(FnIDMetricKey, call.FnID),
+		tag.Upsert(TriggerIDMetricKey, call.TriggerID),
+	)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	return ctx
+}
+
+func statsFnCall(ctx context.Context) {
+	stats.Record(ctx, fnCallsMeasure.M(1))
+}
+
+func statsFnSlotWait(ctx context.Context, dur time.Duration) {
+	common.RecordWithExemplar(ctx, fnSlotWaitMeasure.M(int64(dur/time.Millisecond)))
+}
+
+func statsFnColdStart(ctx context.Context) {
+	stats.Record(ctx, fnColdStartsMeasure.M(1))
+}
+
+func statsFnImagePull(ctx context.Context, call *call) {
+	stats.Record(withFnTags(ctx, call), fnImagePullsMeasure.M(1))
+}
+
+// statsFnCallEnd records how long call waited and executed, if it started, and whether it failed with a 5xx
+func statsFnCallEnd(ctx context.Context, call *call, err error) {
+	queued, exec := GetCallLatencies(call)
+	if queued > 0 || exec > 0 {
+		common.RecordWithExemplar(ctx, fnQueueWaitMeasure.M(int64(queued/time.Millisecond)))
+		common.RecordWithExemplar(ctx, fnCallDurationMeasure.M(int64(exec/time.Millisecond)))
+	}
+	if err != nil && err != context.Canceled && fnErrorStatus(err) >= http.StatusInternalServerError {
+		stats.Record(ctx, fnServerErrorsMeasure.M(1))
+	}
+}
+
+// fnErrorStatus is the status a call failing with err is answered with
+func fnErrorStatus(err error) int {
+	if code := models.GetAPIErrorCode(err); code != 0 {
+		return code
+	}
+	return http.StatusInternalServerError
+}
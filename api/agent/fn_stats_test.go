@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"go.opencensus.io/stats/view"
+)
+
+func TestFnStats(t *testing.T) {
+	RegisterFnViews([]string{"fn_id", "call_status"}, []float64{1, 10, 100, 1000})
+	defer view.Unregister(view.Find("fn_calls"), view.Find("fn_call_duration"), view.Find("fn_queue_wait"),
+		view.Find("fn_slot_wait"), view.Find("fn_cold_starts"), view.Find("fn_image_pulls"), view.Find("fn_server_errors"))
+
+	now := time.Now()
+	c := &call{Call: &models.Call{
+		AppID:       "app",
+		FnID:        "fn",
+		TriggerID:   "trigger",
+		CreatedAt:   common.DateTime(now.Add(-300 * time.Millisecond)),
+		StartedAt:   common.DateTime(now.Add(-200 * time.Millisecond)),
+		CompletedAt: common.DateTime(now),
+	}}
+	ctx := withFnTags(context.Background(), c)
+
+	statsFnCall(ctx)
+	statsFnCallEnd(ctx, c, models.ErrFunctionFailed)
+	statsFnCallEnd(ctx, c, models.ErrInvalidPayload)
+	statsFnCallEnd(ctx, c, context.Canceled)
+	statsFnCallEnd(ctx, c, errors.New("boom"))
+
+	for name, count := range map[string]int64{"fn_calls": 1, "fn_server_errors": 2, "fn_call_duration": 4} {
+		rows, err := view.RetrieveData(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("expected a row of %s for the fn, got %v", name, rows)
+		}
+		tags := map[string]string{}
+		for _, tag := range rows[0].Tags {
+			tags[tag.Key.Name()] = tag.Value
+		}
+		if tags["app_id"] != "app" || tags["fn_id"] != "fn" || tags["trigger_id"] != "trigger" {
+			t.Errorf("expected %s to be tagged with the app, fn and trigger, got %v", name, tags)
+		}
+		var got int64
+		switch data := rows[0].Data.(type) {
+		case *view.CountData:
+			got = data.Value
+		case *view.DistributionData:
+			got = data.Count
+			if data.Min != 200 {
+				t.Errorf("expected an execution time of 200ms, got %v", data.Min)
+			}
+		}
+		if got != count {
+			t.Errorf("expected %d of %s, got %d", count, name, got)
+		}
+	}
+}
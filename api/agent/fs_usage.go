@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	driver_stats "github.com/fnproject/fn/api/agent/drivers/stats"
+	"github.com/fnproject/fn/api/common"
+	"github.com/sirupsen/logrus"
+)
+
+// fsUsageTimeout is how long sampling the fs usage of a container may take when a call fails
+const fsUsageTimeout = 10 * time.Second
+
+// tmpFsInodeLimit returns the inodes the /tmp tmpfs of the container of call is limited to, 0 for no limit
+func tmpFsInodeLimit(call *call, cfg *Config) uint64 {
+	if call.TmpFsSize == 0 {
+		return 0
+	}
+	return cfg.MaxTmpFsInodes
+}
+
+// fsFullReason returns which limit of a container usage reached, of its fs size and tmpfs size in MB and its tmpfs
+// inodes, or "" if none. A filesystem within a percent of its size is full, as its last blocks are seldom usable.
+func fsFullReason(usage drivers.FsUsage, fsSize, tmpFsSize, tmpFsInodes uint64) string {
+	nearlyFull := func(used, sizeMB uint64) bool {
+		size := sizeMB * 1024 * 1024
+		return used+size/100 >= size
+	}
+	switch {
+	case fsSize != 0 && nearlyFull(usage.Writable, fsSize):
+		return "fs size"
+	case tmpFsSize != 0 && nearlyFull(usage.TmpFs, tmpFsSize):
+		return "tmpfs size"
+	case tmpFsInodes != 0 && usage.TmpFsInodes >= tmpFsInodes:
+		return "tmpfs inodes"
+	}
+	return ""
+}
+
+// recordFsUsage marks the container full if a fs usage stat says so
+func (c *container) recordFsUsage(stat driver_stats.Stat) {
+	writable, ok := stat.Metrics[drivers.StatFsUsage]
+	if !ok {
+		return
+	}
+	usage := drivers.FsUsage{
+		Writable:    writable,
+		TmpFs:       stat.Metrics[drivers.StatTmpFsUsage],
+		TmpFsInodes: stat.Metrics[drivers.StatTmpFsInodes],
+	}
+	if reason := fsFullReason(usage, c.fsSize, c.tmpFsSize, c.tmpFsInodes); reason != "" {
+		c.fsFull.Store(reason)
+	}
+}
+
+// checkFsFull returns whether the container is full, from its last fs usage stat or, for calls that failed with
+// callErr, sampling its fs usage right away, as the fn likely failed on ENOSPC
+func (c *container) checkFsFull(ctx context.Context, callErr error) bool {
+	reason, _ := c.fsFull.Load().(string)
+	if reason == "" && callErr != nil && c.fsUsage != nil {
+		ctx, cancel := context.WithTimeout(common.BackgroundContext(ctx), fsUsageTimeout)
+		usage, err := c.fsUsage(ctx)
+		cancel()
+		if err != nil {
+			common.Logger(ctx).WithError(err).WithField("container_id", c.id).Debug("error sampling container fs usage")
+			return false
+		}
+		reason = fsFullReason(usage, c.fsSize, c.tmpFsSize, c.tmpFsInodes)
+	}
+	if reason == "" {
+		return false
+	}
+	common.Logger(ctx).WithFields(logrus.Fields{"container_id": c.id, "limit": reason}).Info("container filesystem full")
+	return true
+}
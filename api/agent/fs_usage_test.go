@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	driver_stats "github.com/fnproject/fn/api/agent/drivers/stats"
+)
+
+func TestFsFullReason(t *testing.T) {
+	const mb = 1024 * 1024
+	for i, test := range []struct {
+		usage  drivers.FsUsage
+		reason string
+	}{
+		{drivers.FsUsage{Writable: 10 * mb, TmpFs: 1 * mb, TmpFsInodes: 10}, ""},
+		{drivers.FsUsage{Writable: 100 * mb}, "fs size"},
+		{drivers.FsUsage{Writable: 99*mb + mb/2}, "fs size"},
+		{drivers.FsUsage{TmpFs: 10 * mb}, "tmpfs size"},
+		{drivers.FsUsage{TmpFsInodes: 1000}, "tmpfs inodes"},
+	} {
+		if reason := fsFullReason(test.usage, 100, 10, 1000); reason != test.reason {
+			t.Errorf("test %d: expected %q, got %q", i, test.reason, reason)
+		}
+	}
+	if reason := fsFullReason(drivers.FsUsage{Writable: 1 << 40}, 0, 0, 0); reason != "" {
+		t.Errorf("expected no limits, got %q", reason)
+	}
+}
+
+func TestContainerFsFull(t *testing.T) {
+	ctx := context.Background()
+	var usage drivers.FsUsage
+	c := &container{fsSize: 1, fsUsage: func(context.Context) (drivers.FsUsage, error) { return usage, nil }}
+
+	if c.checkFsFull(ctx, errors.New("ENOSPC")) {
+		t.Fatal("expected container not full")
+	}
+
+	// a failed call samples the usage right away
+	usage.Writable = 1024 * 1024
+	if c.checkFsFull(ctx, nil) {
+		t.Fatal("expected container not full without sampling for calls that succeeded")
+	}
+	if !c.checkFsFull(ctx, errors.New("ENOSPC")) {
+		t.Fatal("expected container full")
+	}
+
+	// stats mark the container full for calls that succeeded
+	c = &container{fsSize: 1}
+	c.recordFsUsage(driver_stats.Stat{Metrics: map[string]uint64{drivers.StatFsUsage: 1024 * 1024}})
+	if !c.checkFsFull(ctx, nil) {
+		t.Fatal("expected container full from its stats")
+	}
+}
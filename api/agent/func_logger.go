@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/fnproject/fn/api/common"
 	"github.com/fnproject/fn/api/models"
@@ -20,12 +21,13 @@ var (
 // setupLogger returns a ReadWriteCloser that may have:
 // * [always] writes bytes to a size limited buffer, that can be read from using io.Reader
 // * [always] writes bytes per line to stderr as DEBUG
+// * [store != nil] appends size limited bytes to the log store every flushInterval, see callLogAppender
 //
 // To prevent write failures from failing the call or any other writes,
 // multiWriteCloser ignores errors. Close will flush the line writers
 // appropriately.  The returned io.ReadWriteCloser is not safe for use after
 // calling Close.
-func setupLogger(ctx context.Context, maxSize uint64, debug bool, c *models.Call) io.ReadWriteCloser {
+func setupLogger(ctx context.Context, maxSize uint64, debug bool, c *models.Call, store CallLogStore, flushInterval time.Duration) io.ReadWriteCloser {
 	lbuf := bufPool.Get().(*bytes.Buffer)
 	dbuf := logPool.Get().(*bytes.Buffer)
 
@@ -42,7 +44,7 @@ func setupLogger(ctx context.Context, maxSize uint64, debug bool, c *models.Call
 	limitw := &nopCloser{newLimitWriter(int(maxSize), dbuf)}
 
 	// order matters, in that closer should be last and limit should be next to last
-	mw := make(multiWriteCloser, 0, 3)
+	mw := make(multiWriteCloser, 0, 4)
 
 	if debug {
 		// accumulate all line writers, wrap in same line writer (to re-use buffer)
@@ -54,6 +56,11 @@ func setupLogger(ctx context.Context, maxSize uint64, debug bool, c *models.Call
 		mw = append(mw, linew)
 	}
 
+	if store != nil {
+		appender := newCallLogAppender(ctx, store, c.FnID, c.ID, flushInterval)
+		mw = append(mw, &limitCloser{newLimitWriter(int(maxSize), appender), appender})
+	}
+
 	mw = append(mw, limitw, &fCloser{close})
 	return &rwc{mw, dbuf}
 }
@@ -81,6 +88,12 @@ type fCloser struct {
 func (f *fCloser) Write(b []byte) (int, error) { return len(b), nil }
 func (f *fCloser) Close() error                { return f.close() }
 
+// limitCloser closes the writer a limit writer wraps
+type limitCloser struct {
+	io.Writer
+	io.Closer
+}
+
 type nopCloser struct {
 	io.Writer
 }
@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// gpuTracker hands out the GPUs of the node to hot containers, each GPU to one container at a time. GPUs are held
+// for the life of a container, idle or not, like its memory and cpu.
+type gpuTracker struct {
+	lock  sync.Mutex
+	total int
+	// free are the IDs of the GPUs no container holds
+	free []string
+}
+
+// newGPUTracker returns a tracker of the GPUs listed in EnvGPUs, or nil if there are none
+func newGPUTracker(cfg *Config) *gpuTracker {
+	var free []string
+	for _, id := range strings.Split(cfg.GPUs, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			free = append(free, id)
+		}
+	}
+	if len(free) == 0 {
+		return nil
+	}
+	return &gpuTracker{total: len(free), free: free}
+}
+
+// callGPUs returns how many GPUs each container of call is given
+func callGPUs(call *models.Call) (int, error) {
+	gpus, err := call.Annotations.GPUs()
+	if err != nil {
+		return 0, err
+	}
+	return int(gpus[models.GPUResourceNVIDIA]), nil
+}
+
+// isPossible returns whether the node has count GPUs to give a container
+func (g *gpuTracker) isPossible(count int) bool {
+	if count == 0 {
+		return true
+	}
+	return g != nil && count <= g.total
+}
+
+// reserve returns a token holding the resources of tok and count GPUs, which are freed along with them when it is
+// closed. If count GPUs are not free, tok is closed and a token with a CapacityFull error is returned.
+func (g *gpuTracker) reserve(ctx context.Context, tok ResourceToken, count int) ResourceToken {
+	if count == 0 || tok == nil || tok.Error() != nil {
+		return tok
+	}
+
+	g.lock.Lock()
+	if count > len(g.free) {
+		g.lock.Unlock()
+		tok.Close()
+		return &resourceToken{err: CapacityFull}
+	}
+	n := len(g.free) - count
+	gpus := g.free[n:]
+	g.free = g.free[:n:n] // appends to free must not overwrite gpus
+	used, avail := g.total-len(g.free), len(g.free)
+	g.lock.Unlock()
+	statsGPUUtilization(ctx, used, avail)
+
+	return &gpuToken{ResourceToken: tok, gpus: gpus, release: func() {
+		g.lock.Lock()
+		g.free = append(g.free, gpus...)
+		used, avail := g.total-len(g.free), len(g.free)
+		g.lock.Unlock()
+		statsGPUUtilization(ctx, used, avail)
+	}}
+}
+
+// gpuToken is a resource token that also holds GPUs
+type gpuToken struct {
+	ResourceToken
+	once    sync.Once
+	gpus    []string
+	release func()
+}
+
+func (t *gpuToken) Close() error {
+	t.once.Do(t.release)
+	return t.ResourceToken.Close()
+}
+
+// tokenGPUs returns the IDs of the GPUs tok holds
+func tokenGPUs(tok ResourceToken) []string {
+	if t, ok := tok.(*gpuToken); ok {
+		return t.gpus
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestGPUTracker(t *testing.T) {
+	if g := newGPUTracker(&Config{}); g != nil || !g.isPossible(0) || g.isPossible(1) {
+		t.Fatal("expected no gpus to be tracked without any configured")
+	}
+
+	ctx := context.Background()
+	g := newGPUTracker(&Config{GPUs: "0, 1,GPU-8a3f"})
+	if !g.isPossible(3) || g.isPossible(4) {
+		t.Fatal("expected 3 gpus to be tracked")
+	}
+
+	tr := NewResourceTracker(nil)
+	tok1 := g.reserve(ctx, tr.GetResourceTokenNB(ctx, 1, 0), 2)
+	if tok1.Error() != nil || len(tokenGPUs(tok1)) != 2 {
+		t.Fatalf("expected a token holding 2 gpus, got %v %v", tok1.Error(), tokenGPUs(tok1))
+	}
+	tok2 := g.reserve(ctx, tr.GetResourceTokenNB(ctx, 1, 0), 2)
+	if tok2.Error() != CapacityFull {
+		t.Fatalf("expected the gpus to be full, got %v", tok2.Error())
+	}
+	if util := tr.GetUtilization(); util.MemUsed != Mem1MB {
+		t.Fatalf("expected the memory of the token that got no gpus to be released, %d in use", util.MemUsed)
+	}
+
+	tok3 := g.reserve(ctx, tr.GetResourceTokenNB(ctx, 1, 0), 1)
+	if gpus := tokenGPUs(tok3); len(gpus) != 1 || gpus[0] == tokenGPUs(tok1)[0] || gpus[0] == tokenGPUs(tok1)[1] {
+		t.Fatalf("expected a gpu that is not held, got %v and %v", tokenGPUs(tok1), gpus)
+	}
+
+	held := append([]string(nil), tokenGPUs(tok3)...)
+	tok1.Close()
+	tok1.Close()
+	tok2 = g.reserve(ctx, tr.GetResourceTokenNB(ctx, 1, 0), 2)
+	if tok2.Error() != nil {
+		t.Fatalf("expected the gpus of the closed token to be free, got %v", tok2.Error())
+	}
+	if gpus := tokenGPUs(tok3); len(gpus) != 1 || gpus[0] != held[0] {
+		t.Fatalf("expected the gpus of a token to be left alone by others, got %v, had %v", gpus, held)
+	}
+	tok2.Close()
+	tok3.Close()
+	if util := tr.GetUtilization(); util.MemUsed != 0 || len(g.free) != 3 {
+		t.Fatalf("expected all resources to be released, %d memory in use and %d gpus free", util.MemUsed, len(g.free))
+	}
+}
+
+func TestGPUCalls(t *testing.T) {
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.GPUs = "0"
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	script := &mock.Script{Images: map[string]*mock.ImageScript{
+		"fnproject/fn-test-utils": {Status: http.StatusOK},
+	}}
+	a := New(WithConfig(cfg), WithDockerDriver(mock.NewScripted(script)))
+	defer checkClose(t, a)
+
+	call := func(gpus uint64) (Call, error) {
+		cm := createModelCall("TestGPUCalls")
+		cm.Annotations, err = models.Annotations{}.With(models.FnGPUsAnnotation, models.FnGPUs{models.GPUResourceNVIDIA: gpus})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a.GetCall(FromModelAndInput(cm, ioutil.NopCloser(strings.NewReader("hello"))), WithWriter(httptest.NewRecorder()))
+	}
+
+	if _, err := call(2); err != models.ErrCallResourceTooBig {
+		t.Fatalf("expected a call asking for more gpus than the node has to be rejected, got %v", err)
+	}
+	callI, err := call(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Submit(callI); err != nil {
+		t.Fatalf("expected a call given a gpu to run, got %v", err)
+	}
+	if caps := a.(CapabilityReporter).Capabilities(); !hasFeature(caps, "gpus") {
+		t.Fatalf("expected the gpus feature, got %v", caps.Features)
+	}
+}
+
+func hasFeature(caps Capabilities, name string) bool {
+	for _, f := range caps.Features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
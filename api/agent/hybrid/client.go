@@ -26,11 +26,22 @@ import (
 
 // client implements agent.DataAccess
 type client struct {
-	base string
-	http *http.Client
+	base   string
+	http   *http.Client
+	tokens *agent.RunnerTokens
 }
 
-func NewClient(u string) (agent.DataAccess, error) {
+// ClientOption configures a client
+type ClientOption func(*client)
+
+// WithRunnerTokens makes the client present the current runner token to the api server
+func WithRunnerTokens(tokens *agent.RunnerTokens) ClientOption {
+	return func(cl *client) {
+		cl.tokens = tokens
+	}
+}
+
+func NewClient(u string, opts ...ClientOption) (agent.DataAccess, error) {
 	uri, err := url.Parse(u)
 	if err != nil {
 		return nil, err
@@ -63,10 +74,14 @@ func NewClient(u string) (agent.DataAccess, error) {
 		},
 	}
 
-	return &client{
+	cl := &client{
 		base: host,
 		http: httpClient,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(cl)
+	}
+	return cl, nil
 }
 
 var noQuery = map[string]string{}
@@ -185,6 +200,9 @@ func (cl *client) once(ctx context.Context, request, result interface{}, method
 	// shove the span headers in so that the server will continue this span
 	var xxx b3.HTTPFormat
 	xxx.SpanContextToRequest(span.SpanContext(), req)
+	if token := cl.tokens.Current(); token != "" {
+		req.Header.Set(agent.RunnerTokenKey, token)
+	}
 
 	resp, err := cl.http.Do(req)
 	if err != nil {
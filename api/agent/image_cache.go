@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/config"
+	"github.com/fnproject/fn/api/models"
+)
+
+var (
+	// ErrImageCacheUnsupported is returned for managing the image cache of runners whose driver has none
+	ErrImageCacheUnsupported = models.NewAPIError(http.StatusNotFound, errors.New("Image cache management is not supported on this runner"))
+	// ErrImageRefMissing is returned for pinning, unpinning or evicting images without a reference
+	ErrImageRefMissing = models.NewAPIError(http.StatusBadRequest, errors.New("Missing image reference, an image ID, tag or digest reference"))
+)
+
+// ImageCacheManager is implemented by agents whose driver caches images, so operators see the images of a runner,
+// pin those that must never be evicted, such as the images of latency sensitive fns, and evict others at once
+type ImageCacheManager interface {
+	// CachedImages returns the images of the runner and the references pinned
+	CachedImages(ctx context.Context) (*drivers.CachedImages, error)
+	// PinImage keeps the images matching ref, an image ID, tag or digest reference, from being evicted. Pins last
+	// until they are unpinned or the runner restarts.
+	PinImage(ctx context.Context, ref string) error
+	UnpinImage(ctx context.Context, ref string) error
+	// EvictImage removes the images matching ref from the runner, unless they are in use or pinned
+	EvictImage(ctx context.Context, ref string) error
+}
+
+var _ ImageCacheManager = new(agent)
+var _ ImageCacheManager = new(pureRunner)
+
+// imageCache returns the image cache of the driver
+func (a *agent) imageCache(ref *string) (drivers.ImageCache, error) {
+	cache, ok := a.driver.(drivers.ImageCache)
+	if !ok {
+		return nil, ErrImageCacheUnsupported
+	}
+	if ref != nil && *ref == "" {
+		return nil, ErrImageRefMissing
+	}
+	return cache, nil
+}
+
+// addImageCacheTunables makes the size images are evicted past tunable at runtime, if the image cleaner is enabled
+func (a *agent) addImageCacheTunables() {
+	cache, ok := a.driver.(drivers.ImageCache)
+	if !ok || a.cfg.ImageCleanMaxSize == 0 {
+		return
+	}
+	config.Tunable(EnvImageCleanMaxSize, func(value string) error {
+		size, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil || size == 0 {
+			return errors.New("it must be a number of bytes greater than 0, the image cleaner cannot be disabled at runtime")
+		}
+		return cache.SetImageCacheMaxSize(size)
+	})
+}
+
+// CachedImages implements ImageCacheManager
+func (a *agent) CachedImages(ctx context.Context) (*drivers.CachedImages, error) {
+	cache, err := a.imageCache(nil)
+	if err != nil {
+		return nil, err
+	}
+	return cache.ListCachedImages(ctx)
+}
+
+// PinImage implements ImageCacheManager
+func (a *agent) PinImage(ctx context.Context, ref string) error {
+	cache, err := a.imageCache(&ref)
+	if err != nil {
+		return err
+	}
+	return cache.PinImage(ctx, ref)
+}
+
+// UnpinImage implements ImageCacheManager
+func (a *agent) UnpinImage(ctx context.Context, ref string) error {
+	cache, err := a.imageCache(&ref)
+	if err != nil {
+		return err
+	}
+	return cache.UnpinImage(ctx, ref)
+}
+
+// EvictImage implements ImageCacheManager
+func (a *agent) EvictImage(ctx context.Context, ref string) error {
+	cache, err := a.imageCache(&ref)
+	if err != nil {
+		return err
+	}
+	return cache.EvictImage(ctx, ref)
+}
+
+// CachedImages implements ImageCacheManager
+func (pr *pureRunner) CachedImages(ctx context.Context) (*drivers.CachedImages, error) {
+	if m, ok := pr.a.(ImageCacheManager); ok {
+		return m.CachedImages(ctx)
+	}
+	return nil, ErrImageCacheUnsupported
+}
+
+// PinImage implements ImageCacheManager
+func (pr *pureRunner) PinImage(ctx context.Context, ref string) error {
+	if m, ok := pr.a.(ImageCacheManager); ok {
+		return m.PinImage(ctx, ref)
+	}
+	return ErrImageCacheUnsupported
+}
+
+// UnpinImage implements ImageCacheManager
+func (pr *pureRunner) UnpinImage(ctx context.Context, ref string) error {
+	if m, ok := pr.a.(ImageCacheManager); ok {
+		return m.UnpinImage(ctx, ref)
+	}
+	return ErrImageCacheUnsupported
+}
+
+// EvictImage implements ImageCacheManager
+func (pr *pureRunner) EvictImage(ctx context.Context, ref string) error {
+	if m, ok := pr.a.(ImageCacheManager); ok {
+		return m.EvictImage(ctx, ref)
+	}
+	return ErrImageCacheUnsupported
+}
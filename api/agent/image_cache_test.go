@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/config"
+)
+
+type cachingDriver struct {
+	drivers.Driver
+	pins    []string
+	maxSize uint64
+}
+
+func (d *cachingDriver) ListCachedImages(ctx context.Context) (*drivers.CachedImages, error) {
+	return &drivers.CachedImages{Items: []drivers.CachedImage{{ID: "sha256:1", Pinned: len(d.pins) > 0}}, Pins: d.pins}, nil
+}
+
+func (d *cachingDriver) PinImage(ctx context.Context, ref string) error {
+	d.pins = append(d.pins, ref)
+	return nil
+}
+
+func (d *cachingDriver) UnpinImage(ctx context.Context, ref string) error {
+	d.pins = nil
+	return nil
+}
+
+func (d *cachingDriver) EvictImage(ctx context.Context, ref string) error {
+	return nil
+}
+
+func (d *cachingDriver) SetImageCacheMaxSize(size uint64) error {
+	d.maxSize = size
+	return nil
+}
+
+func TestImageCache(t *testing.T) {
+	ctx := context.Background()
+	drv := &cachingDriver{Driver: mock.New()}
+	pr := &pureRunner{a: &agent{driver: drv}}
+
+	if err := pr.PinImage(ctx, "fnproject/hello:0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	images, err := pr.CachedImages(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images.Items) != 1 || !images.Items[0].Pinned || len(images.Pins) != 1 || images.Pins[0] != "fnproject/hello:0.0.1" {
+		t.Fatalf("expected the pinned image, got %+v", images)
+	}
+
+	for _, op := range []func(context.Context, string) error{pr.PinImage, pr.UnpinImage, pr.EvictImage} {
+		if err := op(ctx, ""); err != ErrImageRefMissing {
+			t.Fatalf("expected an image reference to be required, got %v", err)
+		}
+	}
+
+	pr.a = &agent{driver: mock.New()}
+	if _, err := pr.CachedImages(ctx); err != ErrImageCacheUnsupported {
+		t.Fatalf("expected the image cache to be unsupported, got %v", err)
+	}
+}
+
+func TestImageCacheTunable(t *testing.T) {
+	drv := &cachingDriver{Driver: mock.New()}
+	a := &agent{driver: drv, cfg: Config{ImageCleanMaxSize: 1024}}
+	a.addImageCacheTunables()
+
+	if _, err := config.Tune(EnvImageCleanMaxSize, "2048", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if drv.maxSize != 2048 {
+		t.Fatalf("expected the image cache size to be changed, got %d", drv.maxSize)
+	}
+	if _, err := config.Tune(EnvImageCleanMaxSize, "0", "test"); err == nil {
+		t.Fatalf("expected the image cleaner not to be disabled at runtime")
+	}
+}
@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrImageLoadUnsupported is returned for loading images on runners whose driver cannot load them
+	ErrImageLoadUnsupported = models.NewAPIError(http.StatusNotFound, errors.New("Image loading is not supported on this runner"))
+	// ErrImageArchiveNotFound is returned for loading a staged image archive that is not in the image load dir
+	ErrImageArchiveNotFound = models.NewAPIError(http.StatusNotFound, errors.New("Image archive not found"))
+)
+
+// ImageLoader is implemented by agents loading images from docker save tarballs into their drivers, so runners of
+// air-gapped installs, which cannot pull images, run fns all the same
+type ImageLoader interface {
+	// LoadImages loads the images of an uploaded archive
+	LoadImages(ctx context.Context, archive io.Reader) error
+	// LoadStagedImages loads the images of an archive staged in the image load dir of the runner, by file name
+	LoadStagedImages(ctx context.Context, name string) error
+}
+
+var _ ImageLoader = new(agent)
+var _ ImageLoader = new(pureRunner)
+
+// LoadImages implements ImageLoader, spooling the archive to disk as the driver may load it on several daemons
+func (a *agent) LoadImages(ctx context.Context, archive io.Reader) error {
+	loader, ok := a.driver.(drivers.ImageLoader)
+	if !ok {
+		return ErrImageLoadUnsupported
+	}
+
+	f, err := ioutil.TempFile(a.cfg.RequestSpoolDir, "fn-images-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, archive)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return loader.LoadImages(ctx, f.Name())
+}
+
+// LoadStagedImages implements ImageLoader
+func (a *agent) LoadStagedImages(ctx context.Context, name string) error {
+	loader, ok := a.driver.(drivers.ImageLoader)
+	if !ok {
+		return ErrImageLoadUnsupported
+	}
+	// archives are only ever read from the image load dir
+	if a.cfg.ImageLoadDir == "" || name == "" || filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		return ErrImageArchiveNotFound
+	}
+
+	path := filepath.Join(a.cfg.ImageLoadDir, name)
+	if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+		return ErrImageArchiveNotFound
+	}
+	return loader.LoadImages(ctx, path)
+}
+
+// loadStagedImages loads the archives staged in the image load dir, at startup. Archives that fail to load are
+// logged, and may be loaded again through the admin server.
+func (a *agent) loadStagedImages() {
+	if a.cfg.ImageLoadDir == "" {
+		return
+	}
+	files, err := ioutil.ReadDir(a.cfg.ImageLoadDir)
+	if err != nil {
+		logrus.WithError(err).Error("cannot read the image load dir")
+		return
+	}
+
+	ctx, log := common.LoggerWithFields(context.Background(), logrus.Fields{"stack": "loadStagedImages"})
+	for _, f := range files {
+		if !f.Mode().IsRegular() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		log.WithField("archive", f.Name()).Info("Loading staged images")
+		if err := a.LoadStagedImages(ctx, f.Name()); err != nil {
+			log.WithError(err).WithField("archive", f.Name()).Error("Failed to load staged images")
+		}
+	}
+}
+
+// LoadImages implements ImageLoader
+func (pr *pureRunner) LoadImages(ctx context.Context, archive io.Reader) error {
+	if l, ok := pr.a.(ImageLoader); ok {
+		return l.LoadImages(ctx, archive)
+	}
+	return ErrImageLoadUnsupported
+}
+
+// LoadStagedImages implements ImageLoader
+func (pr *pureRunner) LoadStagedImages(ctx context.Context, name string) error {
+	if l, ok := pr.a.(ImageLoader); ok {
+		return l.LoadStagedImages(ctx, name)
+	}
+	return ErrImageLoadUnsupported
+}
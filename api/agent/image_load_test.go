@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+)
+
+type loadingDriver struct {
+	drivers.Driver
+	loaded []string
+}
+
+func (d *loadingDriver) LoadImages(ctx context.Context, archive string) error {
+	b, err := ioutil.ReadFile(archive)
+	if err != nil {
+		return err
+	}
+	d.loaded = append(d.loaded, string(b))
+	return nil
+}
+
+func TestImageLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image-load")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "staged.tar"), []byte("staged"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, ".hidden.tar"), []byte("hidden"), 0644)
+
+	drv := &loadingDriver{Driver: mock.New()}
+	a := &agent{cfg: Config{ImageLoadDir: dir}, driver: drv}
+
+	a.loadStagedImages()
+	if len(drv.loaded) != 1 || drv.loaded[0] != "staged" {
+		t.Fatalf("expected staged archive loaded at startup, got %v", drv.loaded)
+	}
+
+	if err := a.LoadImages(context.Background(), strings.NewReader("uploaded")); err != nil {
+		t.Fatal(err)
+	}
+	if len(drv.loaded) != 2 || drv.loaded[1] != "uploaded" {
+		t.Fatalf("expected uploaded archive loaded, got %v", drv.loaded)
+	}
+
+	for _, name := range []string{"missing.tar", "../staged.tar", ".hidden.tar", ""} {
+		if err := a.LoadStagedImages(context.Background(), name); err != ErrImageArchiveNotFound {
+			t.Fatalf("expected archive %q not found, got %v", name, err)
+		}
+	}
+
+	a.driver = mock.New()
+	if err := a.LoadStagedImages(context.Background(), "staged.tar"); err != ErrImageLoadUnsupported {
+		t.Fatalf("expected image loading unsupported, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/id"
+	"github.com/fnproject/fn/api/models"
+	pool "github.com/fnproject/fn/api/runnerpool"
+	"go.opencensus.io/trace"
+)
+
+// PrePullExtension is a reserved call extensions key, set on calls sent from an LB to a pure runner to have it
+// pull the image of the call instead of executing it.
+const PrePullExtension = "fn_prepull"
+
+var (
+	// ErrImagePrePullUnsupported is returned for pre-pulling images on runners whose driver cannot pull them ahead
+	ErrImagePrePullUnsupported = models.NewAPIError(http.StatusNotFound, errors.New("Image pre-pulls are not supported on this runner"))
+	// ErrNoRunners is returned for pre-pulling images on an LB without runners to pull them on
+	ErrNoRunners = models.NewAPIError(http.StatusServiceUnavailable, errors.New("No runners to pull the image on"))
+)
+
+// ImagePrePuller is implemented by agents that pull images ahead of the calls that run them, so that the first
+// calls after a deploy do not wait on the pull
+type ImagePrePuller interface {
+	// PrePullImage pulls image and returns how many of how many runners pulled it. Runners pull it themselves, LBs
+	// pull it on every runner of their pool, or only on those of the tenant pool runnerPool if it is set. The error
+	// of a runner that failed to pull it is returned.
+	PrePullImage(ctx context.Context, image, runnerPool string) (pulled, runners int, err error)
+}
+
+var _ ImagePrePuller = new(agent)
+var _ ImagePrePuller = new(pureRunner)
+var _ ImagePrePuller = new(lbAgent)
+
+// PrePullImage implements ImagePrePuller
+func (a *agent) PrePullImage(ctx context.Context, image, _ string) (int, int, error) {
+	puller, ok := a.driver.(drivers.ImagePrePuller)
+	if !ok {
+		return 0, 1, ErrImagePrePullUnsupported
+	}
+	if image == "" {
+		return 0, 1, ErrImageRefMissing
+	}
+	if err := puller.PrePullImage(ctx, image); err != nil {
+		return 0, 1, err
+	}
+	return 1, 1, nil
+}
+
+// PrePullImage implements ImagePrePuller
+func (pr *pureRunner) PrePullImage(ctx context.Context, image, runnerPool string) (int, int, error) {
+	if p, ok := pr.a.(ImagePrePuller); ok {
+		return p.PrePullImage(ctx, image, runnerPool)
+	}
+	return 0, 1, ErrImagePrePullUnsupported
+}
+
+// spawnPrePull pulls the image of the call instead of running it
+func (pr *pureRunner) spawnPrePull(state *callHandle, image string) {
+	go func() {
+		_, _, err := pr.PrePullImage(state.sctx, image, "")
+		state.enqueueCallResponse(err)
+	}()
+}
+
+// PrePullImage implements ImagePrePuller, pulling image on the runners of the pool at once. Runners that are
+// unreachable count as failing to pull it.
+func (a *lbAgent) PrePullImage(ctx context.Context, image, runnerPool string) (int, int, error) {
+	ctx, span := trace.StartSpan(ctx, "lb_agent_prepull")
+	defer span.End()
+
+	if image == "" {
+		return 0, 0, ErrImageRefMissing
+	}
+	if !a.shutWg.AddSession(1) {
+		return 0, 0, models.ErrCallTimeoutServerBusy
+	}
+	defer a.shutWg.DoneSession()
+
+	annotations := models.Annotations{}
+	if runnerPool != "" {
+		if !models.ValidRunnerPool(runnerPool) {
+			return 0, 0, models.ErrAppInvalidRunnerPool
+		}
+		var err error
+		if annotations, err = annotations.With(models.AppRunnerPoolAnnotation, runnerPool); err != nil {
+			return 0, 0, err
+		}
+	}
+	prePullCall := func() (*call, error) {
+		c, err := a.GetCall(
+			FromModel(&models.Call{ID: id.NewString(id.KindCall), Image: image, Annotations: annotations}),
+			WithWriter(&discardResponseWriter{headers: make(http.Header)}),
+			WithExtensions(map[string]string{PrePullExtension: "1"}),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return c.(*call), nil
+	}
+
+	c, err := prePullCall()
+	if err != nil {
+		return 0, 0, err
+	}
+	runners, err := a.rp.Runners(ctx, c)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(runners) == 0 {
+		return 0, 0, ErrNoRunners
+	}
+
+	results := make(chan error, len(runners))
+	for _, r := range runners {
+		c, err := prePullCall()
+		if err != nil {
+			return 0, len(runners), err
+		}
+		go func(r pool.Runner, c *call) {
+			placed, err := r.TryExec(ctx, c)
+			if err == nil && !placed {
+				err = models.ErrCallTimeoutServerBusy
+			}
+			results <- err
+		}(r, c)
+	}
+
+	pulled := 0
+	for range runners {
+		if rerr := <-results; rerr != nil {
+			common.Logger(ctx).WithError(rerr).Info("runner failed to pull image")
+			err = rerr
+		} else {
+			pulled++
+		}
+	}
+	return pulled, len(runners), err
+}
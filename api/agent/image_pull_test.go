@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/models"
+	pool "github.com/fnproject/fn/api/runnerpool"
+)
+
+type pullingDriver struct {
+	drivers.Driver
+	pulled []string
+}
+
+func (d *pullingDriver) PrePullImage(ctx context.Context, image string) error {
+	d.pulled = append(d.pulled, image)
+	return nil
+}
+
+func TestPrePullImage(t *testing.T) {
+	ctx := context.Background()
+	drv := &pullingDriver{Driver: mock.New()}
+	pr := &pureRunner{a: &agent{driver: drv}}
+
+	if pulled, runners, err := pr.PrePullImage(ctx, "fnproject/hello:0.0.1", ""); pulled != 1 || runners != 1 || err != nil {
+		t.Fatalf("expected the image to be pulled, got %d of %d %v", pulled, runners, err)
+	}
+	if len(drv.pulled) != 1 || drv.pulled[0] != "fnproject/hello:0.0.1" {
+		t.Fatalf("expected the driver to pull the image, got %v", drv.pulled)
+	}
+	if _, _, err := pr.PrePullImage(ctx, "", ""); err != ErrImageRefMissing {
+		t.Fatalf("expected an image reference to be required, got %v", err)
+	}
+
+	pr.a = &agent{driver: mock.New()}
+	if _, _, err := pr.PrePullImage(ctx, "fnproject/hello:0.0.1", ""); err != ErrImagePrePullUnsupported {
+		t.Fatalf("expected pre-pulls to be unsupported, got %v", err)
+	}
+}
+
+func TestLBPrePullImage(t *testing.T) {
+	ctx := context.Background()
+	rp := setupMockRunnerPool([]string{"192.0.2.0", "192.0.2.1"}, 0, 1)
+	a, err := NewLBAgent(rp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	if pulled, runners, err := a.(ImagePrePuller).PrePullImage(ctx, "fnproject/hello:0.0.1", ""); pulled != 2 || runners != 2 || err != nil {
+		t.Fatalf("expected the image to be pulled on every runner, got %d of %d %v", pulled, runners, err)
+	}
+	for _, r := range rp.runners {
+		if n := r.(*mockRunner).procCalls; n != 1 {
+			t.Fatalf("expected runner %s to pull the image once, got %d", r.Address(), n)
+		}
+	}
+
+	if _, _, err := a.(ImagePrePuller).PrePullImage(ctx, "fnproject/hello:0.0.1", "not a pool"); err != models.ErrAppInvalidRunnerPool {
+		t.Fatalf("expected the runner pool to be validated, got %v", err)
+	}
+
+	// busy runners fail to pull the image
+	rp.runners = append(rp.runners, &mockRunner{addr: "192.0.2.2", sleep: time.Millisecond})
+	if pulled, runners, err := a.(ImagePrePuller).PrePullImage(ctx, "fnproject/hello:0.0.1", ""); pulled != 2 || runners != 3 || err != models.ErrCallTimeoutServerBusy {
+		t.Fatalf("expected a runner to fail to pull the image, got %d of %d %v", pulled, runners, err)
+	}
+
+	rp.runners = []pool.Runner{}
+	if _, _, err := a.(ImagePrePuller).PrePullImage(ctx, "fnproject/hello:0.0.1", ""); err != ErrNoRunners {
+		t.Fatalf("expected runners to be required, got %v", err)
+	}
+}
@@ -26,6 +26,10 @@ type lbAgent struct {
 	callOverrider CallOverrider
 	shutWg        *common.WaitGroup
 	callOpts      []CallOpt
+	chainer       *fnChainer
+	mirrorer      *fnMirrorer
+	faults        *faultInjector
+	spool         *requestSpool
 }
 
 type DetachedResponseWriter struct {
@@ -117,6 +121,8 @@ func NewLBAgent(rp pool.RunnerPool, p pool.Placer, options ...LBAgentOption) (Ag
 			logrus.WithError(err).Fatalf("error in lb-agent options")
 		}
 	}
+	a.spool = newRequestSpool(&a.cfg)
+	a.faults = newFaultInjector(&a.cfg)
 
 	logrus.Infof("lb-agent starting cfg=%+v", a.cfg)
 	return a, nil
@@ -169,6 +175,8 @@ func (a *lbAgent) GetCall(opts ...CallOpt) (Call, error) {
 
 	c.ct = a
 	c.stderr = common.NoopReadWriteCloser{}
+	a.chainer.setup(&c)
+	a.mirrorer.setup(&c)
 	return &c, nil
 }
 
@@ -215,12 +223,14 @@ func (a *lbAgent) Submit(callI Call) error {
 
 	statsEnqueue(ctx)
 
+	// reading the body and placing a sync call spend its timeout, which runners get as the deadline of its stream
+	ctx, cancel := call.withDeadline(ctx)
+	defer cancel()
+
 	// pre-read and buffer request body if already not done based
 	// on GetBody presence.
-	buf, err := a.setRequestBody(ctx, call)
-	if buf != nil {
-		defer bufPool.Put(buf)
-	}
+	release, err := a.setRequestBody(ctx, call)
+	defer release()
 	if err != nil {
 		return a.handleCallEnd(ctx, call, err, false)
 	}
@@ -236,7 +246,10 @@ func (a *lbAgent) Submit(callI Call) error {
 	if call.Type == models.TypeDetached {
 		return a.placeDetachCall(ctx, call)
 	}
-	return a.placeCall(ctx, call)
+	err = a.placeCall(ctx, call)
+	a.chainer.next(ctx, a, a.cfg.MaxChainDepth, call, err)
+	a.mirrorer.next(ctx, a, call)
+	return err
 }
 
 func (a *lbAgent) placeDetachCall(ctx context.Context, call *call) error {
@@ -252,7 +265,10 @@ func (a *lbAgent) placeDetachCall(ctx context.Context, call *call) error {
 }
 
 func (a *lbAgent) placeCall(ctx context.Context, call *call) error {
-	err := a.placer.PlaceCall(ctx, a.rp, call)
+	err := a.faults.inject(ctx, FaultPlace, call)
+	if err == nil {
+		err = a.placer.PlaceCall(ctx, a.rp, call)
+	}
 	return a.handleCallEnd(ctx, call, err, true)
 }
 
@@ -266,49 +282,89 @@ func (a *lbAgent) spawnPlaceCall(ctx context.Context, call *call, errCh chan err
 	ctx, cancel = context.WithTimeout(ctx, newCtxTimeout)
 	defer cancel()
 
-	err := a.placer.PlaceCall(ctx, a.rp, call)
-	errCh <- a.handleCallEnd(ctx, call, err, true)
+	err := a.faults.inject(ctx, FaultPlace, call)
+	if err == nil {
+		err = a.placer.PlaceCall(ctx, a.rp, call)
+	}
+	err = a.handleCallEnd(ctx, call, err, true)
+	a.chainer.next(ctx, a, a.cfg.MaxChainDepth, call, err)
+	a.mirrorer.next(ctx, a, call)
+	errCh <- err
 }
 
 // setRequestGetBody sets GetBody function on the given http.Request if it is missing.  GetBody allows
-// reading from the request body without mutating the state of the request.
-func (a *lbAgent) setRequestBody(ctx context.Context, call *call) (*bytes.Buffer, error) {
+// reading from the request body without mutating the state of the request. Bodies larger than the spool
+// threshold are kept in a temp file rather than in memory. The returned func releases the body once the
+// call is done with it.
+func (a *lbAgent) setRequestBody(ctx context.Context, call *call) (func(), error) {
 
 	r := call.req
 	if r.Body == nil || r.GetBody != nil {
-		return nil, nil
+		return func() {}, nil
 	}
 
 	buf := bufPool.Get().(*bytes.Buffer)
 	buf.Reset()
 
+	type result struct {
+		spooled *spooledBody
+		err     error
+	}
+
 	// WARNING: we need to handle IO in a separate go-routine below
 	// to be able to detect a ctx timeout. When we timeout, we
 	// let gin/http-server to unblock the go-routine below.
-	errApp := make(chan error, 1)
+	errApp := make(chan result, 1)
 	go func() {
-
-		_, err := buf.ReadFrom(r.Body)
+		var err error
+		var spooled *spooledBody
+		if a.spool == nil {
+			_, err = buf.ReadFrom(r.Body)
+		} else {
+			_, err = buf.ReadFrom(io.LimitReader(r.Body, int64(a.spool.threshold)+1))
+			if err == nil && uint64(buf.Len()) > a.spool.threshold {
+				spooled, err = a.spool.spool(buf, r.Body)
+			}
+		}
 		if err != nil && err != io.EOF {
-			errApp <- err
+			errApp <- result{err: err}
 			return
 		}
 
-		r.Body = ioutil.NopCloser(bytes.NewReader(buf.Bytes()))
+		if spooled != nil {
+			r.Body = spooled.reader()
+			r.GetBody = func() (io.ReadCloser, error) {
+				return spooled.reader(), nil
+			}
+		} else {
+			r.Body = ioutil.NopCloser(bytes.NewReader(buf.Bytes()))
 
-		// GetBody does not mutate the state of the request body
-		r.GetBody = func() (io.ReadCloser, error) {
-			return ioutil.NopCloser(bytes.NewReader(buf.Bytes())), nil
+			// GetBody does not mutate the state of the request body
+			r.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(buf.Bytes())), nil
+			}
 		}
 
-		close(errApp)
+		errApp <- result{spooled: spooled}
 	}()
 
 	select {
-	case err := <-errApp:
-		return buf, err
+	case res := <-errApp:
+		return func() {
+			bufPool.Put(buf)
+			if res.spooled != nil {
+				res.spooled.Close()
+			}
+		}, res.err
 	case <-ctx.Done():
-		return buf, ctx.Err()
+		// the read may still be going, remove any temp file once it is done
+		return func() {
+			go func() {
+				if res := <-errApp; res.spooled != nil {
+					res.spooled.Close()
+				}
+			}()
+		}, ctx.Err()
 	}
 }
 
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -295,3 +298,74 @@ func TestWithLBCallOptions(t *testing.T) {
 		t.Fatalf("Expected %s got %s", expected, actualType)
 	}
 }
+
+func TestLBRequestBodySpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := &lbAgent{spool: &requestSpool{dir: dir, threshold: 8, maxCall: 64, maxTotal: 100}}
+	spooled := func() int {
+		files, _ := ioutil.ReadDir(dir)
+		return len(files)
+	}
+	newCall := func(body string) *call {
+		// like a server request, which has no GetBody
+		req, _ := http.NewRequest(http.MethodPost, "http://www.example.com", ioutil.NopCloser(strings.NewReader(body)))
+		return &call{req: req}
+	}
+	readBody := func(c *call) string {
+		r, err := c.req.GetBody()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(r)
+		return string(b)
+	}
+
+	// small bodies stay in memory
+	c := newCall("small")
+	release, err := a.setRequestBody(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spooled() != 0 || readBody(c) != "small" {
+		t.Fatalf("expected small body in memory, got %d files", spooled())
+	}
+	release()
+
+	large := strings.Repeat("x", 60)
+	c = newCall(large)
+	release, err = a.setRequestBody(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spooled() != 1 {
+		t.Fatalf("expected large body to be spooled, got %d files", spooled())
+	}
+	// it can be read any number of times, eg. to retry on another runner
+	if readBody(c) != large || readBody(c) != large {
+		t.Fatal("unexpected spooled body")
+	}
+
+	// over the budget of the call
+	if _, err := a.setRequestBody(context.Background(), newCall(strings.Repeat("x", 65))); err != models.ErrRequestContentTooBig {
+		t.Fatalf("expected error `%v`, got `%v`", models.ErrRequestContentTooBig, err)
+	}
+	// over the total budget, with the first body still spooled
+	if _, err := a.setRequestBody(context.Background(), newCall(large)); err != models.ErrCallTimeoutServerBusy {
+		t.Fatalf("expected error `%v`, got `%v`", models.ErrCallTimeoutServerBusy, err)
+	}
+
+	release()
+	if spooled() != 0 || a.spool.used != 0 {
+		t.Fatalf("expected spool to be empty, got %d files using %d bytes", spooled(), a.spool.used)
+	}
+	release, err = a.setRequestBody(context.Background(), newCall(large))
+	if err != nil {
+		t.Fatalf("expected budget to be returned, got `%v`", err)
+	}
+	release()
+}
@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// livenessWriter records when a long running call last wrote to its log. Wrapping the log of a call also makes its
+// container's output reach it on runners that otherwise discard call logs.
+type livenessWriter struct {
+	io.ReadWriteCloser
+	last int64 // unix nanos
+}
+
+func newLivenessWriter(w io.ReadWriteCloser) *livenessWriter {
+	return &livenessWriter{ReadWriteCloser: w, last: time.Now().UnixNano()}
+}
+
+func (w *livenessWriter) Write(b []byte) (int, error) {
+	atomic.StoreInt64(&w.last, time.Now().UnixNano())
+	return w.ReadWriteCloser.Write(b)
+}
+
+func (w *livenessWriter) lastWrite() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&w.last))
+}
+
+// setupLongRunning sets up the liveness checks of c if its fn is long running
+func setupLongRunning(c *call) error {
+	longRunning, err := c.Annotations.LongRunning()
+	if err != nil || longRunning == nil {
+		return err
+	}
+	c.liveness = newLivenessWriter(c.stderr)
+	c.livenessInterval = time.Duration(longRunning.Liveness()) * time.Second
+	c.stderr = c.liveness
+	return nil
+}
+
+// admitLongRunning counts c against the long running calls the agent runs at once, returning a func to release it,
+// or ErrTooManyLongRunningCalls
+func (a *agent) admitLongRunning(c *call) (func(), error) {
+	if c.liveness == nil {
+		return func() {}, nil
+	}
+	n := atomic.AddInt64(&a.longRunning, 1)
+	release := func() { atomic.AddInt64(&a.longRunning, -1) }
+	if a.cfg.MaxLongRunningCalls > 0 && uint64(n) > a.cfg.MaxLongRunningCalls {
+		release()
+		return nil, models.ErrTooManyLongRunningCalls
+	}
+	return release, nil
+}
+
+// watchLiveness returns a context canceled once c goes without writing to its log for its liveness interval, and a
+// func to stop watching returning whether it was
+func (c *call) watchLiveness(ctx context.Context) (context.Context, func() bool) {
+	if c.liveness == nil {
+		return ctx, func() bool { return false }
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	expired := make(chan bool, 1)
+	go func() {
+		timer := time.NewTimer(c.livenessInterval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				expired <- false
+				return
+			case <-timer.C:
+			}
+			if silent := time.Since(c.liveness.lastWrite()); silent < c.livenessInterval {
+				timer.Reset(c.livenessInterval - silent)
+				continue
+			}
+			cancel()
+			<-done
+			expired <- true
+			return
+		}
+	}()
+
+	return ctx, func() bool {
+		close(done)
+		cancel()
+		return <-expired
+	}
+}
@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestLongRunningLiveness(t *testing.T) {
+	c := &call{Call: &models.Call{Annotations: models.Annotations{}}, stderr: common.NoopReadWriteCloser{}}
+	var err error
+	c.Annotations, err = c.Annotations.With(models.FnLongRunningAnnotation, map[string]int{"liveness_interval": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := setupLongRunning(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.liveness == nil || c.livenessInterval != time.Second {
+		t.Fatalf("expected the call to be long running, got %v", c.livenessInterval)
+	}
+	if _, ok := c.stderr.(common.NoopReadWriteCloser); ok {
+		t.Fatal("expected the log of the call to be watched")
+	}
+	c.livenessInterval = 200 * time.Millisecond
+
+	// a call writing to its log stays live
+	ctx, stop := c.watchLiveness(context.Background())
+	for i := 0; i < 5; i++ {
+		time.Sleep(100 * time.Millisecond)
+		c.stderr.Write([]byte("working\n"))
+	}
+	if ctx.Err() != nil || stop() {
+		t.Fatal("expected a call writing to its log to stay live")
+	}
+
+	// a silent one does not
+	ctx, stop = c.watchLiveness(context.Background())
+	select {
+	case <-ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a silent call to be canceled")
+	}
+	if !stop() {
+		t.Fatal("expected a silent call to have gone without liveness")
+	}
+
+	// calls of other fns are not watched
+	other := &call{Call: &models.Call{}}
+	if err := setupLongRunning(other); err != nil || other.liveness != nil {
+		t.Fatalf("expected a call not to be long running, got %v", err)
+	}
+	ctx, stop = other.watchLiveness(context.Background())
+	if ctx != context.Background() || stop() {
+		t.Fatal("expected a call not long running not to be watched")
+	}
+}
+
+func TestLongRunningAdmission(t *testing.T) {
+	a := &agent{cfg: Config{MaxLongRunningCalls: 1}}
+	c := &call{liveness: newLivenessWriter(common.NoopReadWriteCloser{})}
+
+	release, err := a.admitLongRunning(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.admitLongRunning(c); err != models.ErrTooManyLongRunningCalls {
+		t.Fatalf("expected the second long running call to be refused, got %v", err)
+	}
+	if _, err := a.admitLongRunning(&call{}); err != nil {
+		t.Fatalf("expected other calls to be admitted, got %v", err)
+	}
+	release()
+	if _, err := a.admitLongRunning(c); err != nil {
+		t.Fatalf("expected a long running call to be admitted once another ended, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/sirupsen/logrus"
+)
+
+// FnMirrorHeader is set on mirrored calls to the ID of the fn whose call they
+// mirror. Mirrored calls are neither mirrored nor chained further.
+const FnMirrorHeader = "Fn-Mirror"
+
+// maxMirrorPayload is the largest payload of calls that are mirrored, larger
+// ones are not mirrored rather than kept in memory
+const maxMirrorPayload = 1024 * 1024
+
+// fnMirrorer mirrors a share of the calls of fns to their shadow fns (see
+// models.FnMirror) once they end, looking them up in da.
+type fnMirrorer struct {
+	da ReadDataAccess
+}
+
+// WithFnMirroring enables fn mirroring on the agent, shadow fns are looked up in da
+func WithFnMirroring(da ReadDataAccess) Option {
+	return func(a *agent) error {
+		a.mirrorer = &fnMirrorer{da: da}
+		return nil
+	}
+}
+
+// WithLBFnMirroring enables fn mirroring on the lb agent, shadow fns are looked up in da
+func WithLBFnMirroring(da ReadDataAccess) LBAgentOption {
+	return func(a *lbAgent) error {
+		a.mirrorer = &fnMirrorer{da: da}
+		return nil
+	}
+}
+
+// setup samples call for mirroring, keeping a copy of its payload if it is.
+// The payload is read to do so, and put back.
+func (m *fnMirrorer) setup(call *call) {
+	if m == nil || call.req == nil || isMirrored(call) {
+		return
+	}
+	mirror, err := call.Annotations.Mirror()
+	if err != nil || mirror == nil || mirror.FnID == call.FnID {
+		return
+	}
+	if rand.Float64()*100 >= mirror.Percent {
+		return
+	}
+
+	var payload []byte
+	if body := call.req.Body; body != nil {
+		payload, err = ioutil.ReadAll(io.LimitReader(body, maxMirrorPayload+1))
+		if err != nil || len(payload) > maxMirrorPayload {
+			// put back what was read, the fn gets the error reading its payload too
+			call.req.Body = readCloser{io.MultiReader(bytes.NewReader(payload), body), body}
+			return
+		}
+		call.req.Body = readCloser{bytes.NewReader(payload), body}
+	}
+	call.mirror = mirror
+	call.mirrorPayload = payload
+}
+
+// next invokes the shadow fn of call with its payload, if call was sampled for
+// mirroring. The mirrored call runs in the background on a, its response is
+// discarded and its error recorded.
+func (m *fnMirrorer) next(ctx context.Context, a Agent, call *call) {
+	if m == nil || call.mirror == nil {
+		return
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/invoke/"+call.mirror.FnID, bytes.NewReader(call.mirrorPayload))
+	req.Host = call.req.Host
+	req.Header.Set(FnMirrorHeader, call.FnID)
+	if ct := call.req.Header.Get("Content-Type"); ct != "" {
+		req.Header.Set("Content-Type", ct)
+	}
+	log := common.Logger(ctx).WithFields(logrus.Fields{"mirror_fn_id": call.mirror.FnID})
+
+	go func() {
+		ctx := common.BackgroundContext(ctx)
+		statsMirrored(ctx)
+		err := m.submit(ctx, a, req, call.AppID, call.mirror.FnID)
+		if err != nil {
+			statsMirrorErrors(ctx)
+			log.WithError(err).Error("mirrored call failed")
+		}
+	}()
+}
+
+func (m *fnMirrorer) submit(ctx context.Context, a Agent, req *http.Request, appID, fnID string) error {
+	fn, err := m.da.GetFnByID(ctx, fnID)
+	if err != nil {
+		return err
+	}
+	// fns only mirror to the fns of their own app
+	if fn.AppID != appID {
+		return models.ErrFnMirrorTargetNotFound
+	}
+	app, err := m.da.GetAppByID(ctx, fn.AppID)
+	if err != nil {
+		return err
+	}
+
+	// give the call as long to find a slot as it has to run
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Duration(fn.Timeout)*time.Second)
+	defer cancel()
+
+	w := &mirrorResponseWriter{discardResponseWriter: discardResponseWriter{headers: make(http.Header)}}
+	callI, err := a.GetCall(FromHTTPFnRequest(app, fn, req.WithContext(ctx)), WithWriter(w))
+	if err != nil {
+		return err
+	}
+	if err := a.Submit(callI); err != nil {
+		return err
+	}
+	if w.status >= http.StatusInternalServerError {
+		return fmt.Errorf("mirrored fn responded with status %d", w.status)
+	}
+	return nil
+}
+
+// mirrorResponseWriter discards the response of mirrored calls, but for their status
+type mirrorResponseWriter struct {
+	discardResponseWriter
+	status int
+}
+
+func (w *mirrorResponseWriter) WriteHeader(status int) { w.status = status }
+
+// isMirrored returns whether call mirrors the call of another fn
+func isMirrored(call *call) bool {
+	return call.req != nil && call.req.Header.Get(FnMirrorHeader) != ""
+}
@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestFnMirror(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "app"}
+	primary := &models.Fn{ID: "primary", AppID: app.ID, ResourceConfig: models.ResourceConfig{Timeout: 30}, Annotations: models.Annotations{}}
+	primary.Annotations, _ = primary.Annotations.With(models.FnMirrorAnnotation, map[string]interface{}{"fn_id": "shadow", "percent": 100})
+	shadow := &models.Fn{ID: "shadow", AppID: app.ID, ResourceConfig: models.ResourceConfig{Timeout: 30}}
+	disabled := true
+	off := &models.Fn{ID: "off", AppID: app.ID, ResourceConfig: models.ResourceConfig{Timeout: 30}, Disabled: &disabled}
+	toOff := &models.Fn{ID: "to_off", AppID: app.ID, ResourceConfig: models.ResourceConfig{Timeout: 30}, Annotations: models.Annotations{}}
+	toOff.Annotations, _ = toOff.Annotations.With(models.FnMirrorAnnotation, map[string]interface{}{"fn_id": "off", "percent": 100})
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{primary, shadow, off, toOff})
+
+	mirrorer := &fnMirrorer{da: ds}
+
+	for i, test := range []struct {
+		fn         *models.Fn
+		mirrored   bool
+		expectedFn string
+	}{
+		{primary, false, "shadow"},
+		// mirrored calls are not mirrored again
+		{primary, true, ""},
+		// nothing to mirror to
+		{shadow, false, ""},
+		// disabled fns are not mirrored to
+		{toOff, false, ""},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/invoke/"+test.fn.ID, strings.NewReader("hello"))
+		req.Header.Set("Content-Type", "text/plain")
+		if test.mirrored {
+			req.Header.Set(FnMirrorHeader, "other")
+		}
+		c := &call{respWriter: httptest.NewRecorder()}
+		if err := FromHTTPFnRequest(app, test.fn, req)(c); err != nil {
+			t.Fatal(err)
+		}
+		mirrorer.setup(c)
+
+		// the payload is still there for the call mirrored
+		if body, _ := ioutil.ReadAll(c.req.Body); string(body) != "hello" {
+			t.Fatalf("Test %d: expected the payload to be put back, got %q", i, body)
+		}
+
+		rec := &chainRecorder{calls: make(chan *call, 1)}
+		mirrorer.next(context.Background(), rec, c)
+
+		select {
+		case next := <-rec.calls:
+			if next.FnID != test.expectedFn {
+				t.Fatalf("Test %d: expected mirrored call to %q, got %q", i, test.expectedFn, next.FnID)
+			}
+			body, _ := ioutil.ReadAll(next.req.Body)
+			if !bytes.Equal(body, []byte("hello")) {
+				t.Errorf("Test %d: expected mirrored call input %q, got %q", i, "hello", body)
+			}
+			if hdr := next.req.Header.Get(FnMirrorHeader); hdr != test.fn.ID {
+				t.Errorf("Test %d: expected mirror header %q, got %q", i, test.fn.ID, hdr)
+			}
+			if ct := next.req.Header.Get("Content-Type"); ct != "text/plain" {
+				t.Errorf("Test %d: expected content type to be mirrored, got %q", i, ct)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if test.expectedFn != "" {
+				t.Fatalf("Test %d: expected mirrored call to %q, got none", i, test.expectedFn)
+			}
+		}
+	}
+}
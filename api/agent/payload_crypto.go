@@ -0,0 +1,315 @@
+package agent
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	pb "github.com/fnproject/fn/api/agent/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// PayloadKeyIDExtension is the TryCall extension naming the key the data key of a call's payloads is wrapped with
+	PayloadKeyIDExtension = "fn-payload-key-id"
+	// PayloadDataKeyExtension is the TryCall extension holding the wrapped data key of a call's payloads, base64
+	PayloadDataKeyExtension = "fn-payload-data-key"
+
+	// the directions of payloads, part of their nonces so the two directions never reuse one
+	toRunner   = 0
+	fromRunner = 1
+)
+
+var (
+	// ErrPayloadDecrypt is returned for payloads that do not decrypt with the data key of their call
+	ErrPayloadDecrypt = status.Error(codes.DataLoss, "cannot decrypt call payload")
+	// ErrPayloadUnencrypted is returned by runners with payload keys for calls whose payload is not encrypted
+	ErrPayloadUnencrypted = status.Error(codes.Unauthenticated, "call payload is not encrypted")
+)
+
+// KeyProvider wraps and unwraps the data keys the payloads of calls between lbs and runners are encrypted with,
+// typically with the keys of a KMS. Runners must be able to unwrap the data keys wrapped by any of their lbs.
+type KeyProvider interface {
+	// WrapKey encrypts a data key, returning the id of the key it was encrypted with
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key encrypted with the key keyID
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// staticKeyProvider wraps data keys with AES-GCM keys configured on every node
+type staticKeyProvider struct {
+	keys    map[string]cipher.AEAD
+	current string
+}
+
+// NewStaticKeyProvider returns a KeyProvider wrapping data keys with the AES key current of keys, 16, 24 or 32
+// bytes long by id, and unwrapping them with any of keys, so keys are rotated by adding the new key to the runners
+// before making it current on the lbs
+func NewStaticKeyProvider(keys map[string][]byte, current string) (KeyProvider, error) {
+	kp := &staticKeyProvider{keys: make(map[string]cipher.AEAD, len(keys)), current: current}
+	for id, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload key %s: %v", id, err)
+		}
+		kp.keys[id] = aead
+	}
+	if _, ok := kp.keys[current]; !ok {
+		return nil, fmt.Errorf("unknown current payload key %s", current)
+	}
+	return kp, nil
+}
+
+// ParseStaticKeys parses a comma separated list of id=base64 keys, returning the keys and the id of the last one
+func ParseStaticKeys(list string) (keys map[string][]byte, current string, err error) {
+	keys = make(map[string][]byte)
+	for _, kv := range strings.Split(list, ",") {
+		parts := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, "", fmt.Errorf("invalid payload key %q, it must be id=base64", kv)
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid payload key %s: %v", parts[0], err)
+		}
+		keys[parts[0]], current = key, parts[0]
+	}
+	return keys, current, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (kp *staticKeyProvider) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	aead := kp.keys[kp.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return kp.current, aead.Seal(nonce, nonce, dataKey, []byte(kp.current)), nil
+}
+
+func (kp *staticKeyProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := kp.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown payload key %s", keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped data key too short")
+	}
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+}
+
+// payloadCipher encrypts the payloads of one call in one direction and decrypts them in the other, numbering them
+// so payloads cannot be dropped, replayed or reordered unnoticed
+type payloadCipher struct {
+	aead cipher.AEAD
+
+	sealLock sync.Mutex
+	sealDir  uint32
+	sealSeq  uint64
+
+	openLock sync.Mutex
+	openDir  uint32
+	openSeq  uint64
+}
+
+func newPayloadCipher(dataKey []byte, sealDir uint32) (*payloadCipher, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &payloadCipher{aead: aead, sealDir: sealDir, openDir: sealDir ^ 1}, nil
+}
+
+func (p *payloadCipher) nonce(dir uint32, seq uint64) []byte {
+	nonce := make([]byte, p.aead.NonceSize())
+	binary.BigEndian.PutUint32(nonce, dir)
+	binary.BigEndian.PutUint64(nonce[4:], seq)
+	return nonce
+}
+
+func (p *payloadCipher) seal(plain []byte) []byte {
+	p.sealLock.Lock()
+	defer p.sealLock.Unlock()
+	p.sealSeq++
+	return p.aead.Seal(nil, p.nonce(p.sealDir, p.sealSeq), plain, nil)
+}
+
+func (p *payloadCipher) open(sealed []byte) ([]byte, error) {
+	p.openLock.Lock()
+	defer p.openLock.Unlock()
+	p.openSeq++
+	plain, err := p.aead.Open(nil, p.nonce(p.openDir, p.openSeq), sealed, nil)
+	if err != nil {
+		return nil, ErrPayloadDecrypt
+	}
+	return plain, nil
+}
+
+// payloadClientInterceptor encrypts the call and request body an lb sends to runners, and decrypts the response
+// body they send back, with a new data key per call wrapped by keys
+func payloadClientInterceptor(keys KeyProvider) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return stream, err
+		}
+		return &payloadClientStream{ClientStream: stream, ctx: ctx, keys: keys}, nil
+	}
+}
+
+type payloadClientStream struct {
+	grpc.ClientStream
+	ctx    context.Context
+	keys   KeyProvider
+	cipher *payloadCipher
+}
+
+func (s *payloadClientStream) SendMsg(m interface{}) error {
+	msg, ok := m.(*pb.ClientMsg)
+	if !ok {
+		return s.ClientStream.SendMsg(m)
+	}
+
+	switch body := msg.Body.(type) {
+	case *pb.ClientMsg_Try:
+		dataKey := make([]byte, 32)
+		if _, err := rand.Read(dataKey); err != nil {
+			return err
+		}
+		keyID, wrapped, err := s.keys.WrapKey(s.ctx, dataKey)
+		if err != nil {
+			return status.Errorf(codes.Unavailable, "cannot wrap payload key: %v", err)
+		}
+		s.cipher, err = newPayloadCipher(dataKey, toRunner)
+		if err != nil {
+			return err
+		}
+
+		try := *body.Try
+		try.Extensions = make(map[string]string, len(body.Try.Extensions)+2)
+		for k, v := range body.Try.Extensions {
+			try.Extensions[k] = v
+		}
+		try.Extensions[PayloadKeyIDExtension] = keyID
+		try.Extensions[PayloadDataKeyExtension] = base64.StdEncoding.EncodeToString(wrapped)
+		try.ModelsCallJson = base64.StdEncoding.EncodeToString(s.cipher.seal([]byte(try.ModelsCallJson)))
+		m = &pb.ClientMsg{Body: &pb.ClientMsg_Try{Try: &try}}
+	case *pb.ClientMsg_Data:
+		if s.cipher != nil && len(body.Data.Data) > 0 {
+			m = &pb.ClientMsg{Body: &pb.ClientMsg_Data{Data: &pb.DataFrame{Data: s.cipher.seal(body.Data.Data), Eof: body.Data.Eof}}}
+		}
+	}
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *payloadClientStream) RecvMsg(m interface{}) error {
+	if err := s.ClientStream.RecvMsg(m); err != nil {
+		return err
+	}
+	msg, ok := m.(*pb.RunnerMsg)
+	if !ok || s.cipher == nil {
+		return nil
+	}
+	if data := msg.GetData(); data != nil && len(data.Data) > 0 {
+		plain, err := s.cipher.open(data.Data)
+		if err != nil {
+			return err
+		}
+		data.Data = plain
+	}
+	return nil
+}
+
+// PureRunnerWithPayloadKeys makes a pure runner decrypt the payloads of calls lbs encrypted with a data key wrapped
+// by keys, encrypting the payloads it sends back with it. Calls that are not encrypted are rejected, so the lbs
+// must have payload keys before their runners do.
+func PureRunnerWithPayloadKeys(keys KeyProvider) PureRunnerOption {
+	return func(pr *pureRunner) error {
+		if pr.payloadKeys != nil {
+			return errors.New("Failed to create pure runner: payload keys already set")
+		}
+		pr.payloadKeys = keys
+		return nil
+	}
+}
+
+func (pr *pureRunner) payloadStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &payloadServerStream{ServerStream: stream, keys: pr.payloadKeys})
+}
+
+type payloadServerStream struct {
+	grpc.ServerStream
+	keys   KeyProvider
+	cipher *payloadCipher
+}
+
+func (s *payloadServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	msg, ok := m.(*pb.ClientMsg)
+	if !ok {
+		return nil
+	}
+
+	if try := msg.GetTry(); try != nil {
+		keyID, ok := try.Extensions[PayloadKeyIDExtension]
+		if !ok {
+			return ErrPayloadUnencrypted
+		}
+		wrapped, err := base64.StdEncoding.DecodeString(try.Extensions[PayloadDataKeyExtension])
+		if err != nil {
+			return ErrPayloadDecrypt
+		}
+		dataKey, err := s.keys.UnwrapKey(s.Context(), keyID, wrapped)
+		if err != nil {
+			return status.Errorf(codes.Unauthenticated, "cannot unwrap payload key: %v", err)
+		}
+		if s.cipher, err = newPayloadCipher(dataKey, fromRunner); err != nil {
+			return ErrPayloadDecrypt
+		}
+		sealed, err := base64.StdEncoding.DecodeString(try.ModelsCallJson)
+		if err != nil {
+			return ErrPayloadDecrypt
+		}
+		plain, err := s.cipher.open(sealed)
+		if err != nil {
+			return err
+		}
+		try.ModelsCallJson = string(plain)
+		delete(try.Extensions, PayloadKeyIDExtension)
+		delete(try.Extensions, PayloadDataKeyExtension)
+	} else if data := msg.GetData(); data != nil && s.cipher != nil && len(data.Data) > 0 {
+		plain, err := s.cipher.open(data.Data)
+		if err != nil {
+			return err
+		}
+		data.Data = plain
+	}
+	return nil
+}
+
+func (s *payloadServerStream) SendMsg(m interface{}) error {
+	if msg, ok := m.(*pb.RunnerMsg); ok && s.cipher != nil {
+		if data := msg.GetData(); data != nil && len(data.Data) > 0 {
+			m = &pb.RunnerMsg{Body: &pb.RunnerMsg_Data{Data: &pb.DataFrame{Data: s.cipher.seal(data.Data), Eof: data.Eof}}}
+		}
+	}
+	return s.ServerStream.SendMsg(m)
+}
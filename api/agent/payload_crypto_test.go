@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pb "github.com/fnproject/fn/api/agent/grpc"
+	"google.golang.org/grpc"
+)
+
+type fakeClientStream struct {
+	grpc.ClientStream
+	sent []*pb.ClientMsg
+	recv []*pb.RunnerMsg
+}
+
+func (s *fakeClientStream) SendMsg(m interface{}) error {
+	s.sent = append(s.sent, m.(*pb.ClientMsg))
+	return nil
+}
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	*m.(*pb.RunnerMsg), s.recv = *s.recv[0], s.recv[1:]
+	return nil
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	sent []*pb.RunnerMsg
+	recv []*pb.ClientMsg
+}
+
+func (s *fakeServerStream) Context() context.Context { return context.Background() }
+
+func (s *fakeServerStream) SendMsg(m interface{}) error {
+	s.sent = append(s.sent, m.(*pb.RunnerMsg))
+	return nil
+}
+
+func (s *fakeServerStream) RecvMsg(m interface{}) error {
+	*m.(*pb.ClientMsg), s.recv = *s.recv[0], s.recv[1:]
+	return nil
+}
+
+func testKeyProvider(t *testing.T, list string) KeyProvider {
+	keys, current, err := ParseStaticKeys(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kp, err := NewStaticKeyProvider(keys, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kp
+}
+
+func TestPayloadEncryption(t *testing.T) {
+	lbKeys := testKeyProvider(t, "old=MDEyMzQ1Njc4OWFiY2RlZg==,new=MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	runnerKeys := testKeyProvider(t, "new=MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+
+	client := &fakeClientStream{}
+	lb := &payloadClientStream{ClientStream: client, ctx: context.Background(), keys: lbKeys}
+	extensions := map[string]string{"ext": "value"}
+	lb.SendMsg(&pb.ClientMsg{Body: &pb.ClientMsg_Try{Try: &pb.TryCall{ModelsCallJson: `{"id":"call"}`, Extensions: extensions}}})
+	lb.SendMsg(&pb.ClientMsg{Body: &pb.ClientMsg_Data{Data: &pb.DataFrame{Data: []byte("request")}}})
+	lb.SendMsg(&pb.ClientMsg{Body: &pb.ClientMsg_Data{Data: &pb.DataFrame{Eof: true}}})
+
+	if len(extensions) != 1 {
+		t.Fatal("expected the extensions of the call to be left alone")
+	}
+	if sent := client.sent[0].GetTry().ModelsCallJson; sent == `{"id":"call"}` {
+		t.Fatal("expected the call to be encrypted")
+	}
+	if bytes.Equal(client.sent[1].GetData().Data, []byte("request")) {
+		t.Fatal("expected the request body to be encrypted")
+	}
+
+	server := &fakeServerStream{recv: client.sent}
+	runner := &payloadServerStream{ServerStream: server, keys: runnerKeys}
+	var msg pb.ClientMsg
+	if err := runner.RecvMsg(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if try := msg.GetTry(); try.ModelsCallJson != `{"id":"call"}` || len(try.Extensions) != 1 || try.Extensions["ext"] != "value" {
+		t.Fatalf("unexpected decrypted call %+v", try)
+	}
+	if err := runner.RecvMsg(&msg); err != nil || string(msg.GetData().Data) != "request" {
+		t.Fatalf("unexpected decrypted request body %v %q", err, msg.GetData().Data)
+	}
+	if err := runner.RecvMsg(&msg); err != nil || !msg.GetData().Eof {
+		t.Fatalf("unexpected eof %v", err)
+	}
+
+	runner.SendMsg(&pb.RunnerMsg{Body: &pb.RunnerMsg_Data{Data: &pb.DataFrame{Data: []byte("response 1")}}})
+	runner.SendMsg(&pb.RunnerMsg{Body: &pb.RunnerMsg_Data{Data: &pb.DataFrame{Data: []byte("response 2")}}})
+	if bytes.Equal(server.sent[0].GetData().Data, []byte("response 1")) {
+		t.Fatal("expected the response body to be encrypted")
+	}
+
+	// frames may not be reordered
+	client.recv = []*pb.RunnerMsg{server.sent[1], server.sent[0]}
+	var resp pb.RunnerMsg
+	if err := lb.RecvMsg(&resp); err != ErrPayloadDecrypt {
+		t.Fatalf("expected a reordered frame to not decrypt, got %v", err)
+	}
+}
+
+func TestPayloadUnknownKey(t *testing.T) {
+	client := &fakeClientStream{}
+	lb := &payloadClientStream{ClientStream: client, ctx: context.Background(), keys: testKeyProvider(t, "a=MDEyMzQ1Njc4OWFiY2RlZg==")}
+	lb.SendMsg(&pb.ClientMsg{Body: &pb.ClientMsg_Try{Try: &pb.TryCall{ModelsCallJson: "{}"}}})
+
+	runner := &payloadServerStream{ServerStream: &fakeServerStream{recv: client.sent}, keys: testKeyProvider(t, "b=MDEyMzQ1Njc4OWFiY2RlZg==")}
+	if err := runner.RecvMsg(&pb.ClientMsg{}); err == nil {
+		t.Fatal("expected a data key wrapped with an unknown key to be rejected")
+	}
+}
+
+func TestPayloadUnencrypted(t *testing.T) {
+	try := &pb.ClientMsg{Body: &pb.ClientMsg_Try{Try: &pb.TryCall{ModelsCallJson: `{"id":"call"}`}}}
+	runner := &payloadServerStream{ServerStream: &fakeServerStream{recv: []*pb.ClientMsg{try}}, keys: testKeyProvider(t, "a=MDEyMzQ1Njc4OWFiY2RlZg==")}
+	if err := runner.RecvMsg(&pb.ClientMsg{}); err != ErrPayloadUnencrypted {
+		t.Fatalf("expected error `%v`, got `%v`", ErrPayloadUnencrypted, err)
+	}
+}
@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+)
+
+// The env vars the agent sets in the containers of fns. Fns cannot override them through their config.
+const (
+	// FnEnvAppID is the id of the app of the fn
+	FnEnvAppID = "FN_APP_ID"
+	// FnEnvFnID is the id of the fn
+	FnEnvFnID = "FN_FN_ID"
+	// FnEnvMemory is the memory limit of the container, in MB
+	FnEnvMemory = "FN_MEMORY"
+	// FnEnvCPUs is the cpu quota of the container, eg. "500m", if the fn has one
+	FnEnvCPUs = "FN_CPUS"
+	// FnEnvType is the type of the calls of the container, always "sync"
+	FnEnvType = "FN_TYPE"
+	// FnEnvListener is the unix socket the container must listen on, eg. "unix:/tmp/iofs/lsnr.sock"
+	FnEnvListener = "FN_LISTENER"
+	// FnEnvFormat is the format of the calls of the container, always "http-stream"
+	FnEnvFormat = "FN_FORMAT"
+
+	// FnEnvPlatformVersion is the version of the platform env vars and headers, from version 1
+	FnEnvPlatformVersion = "FN_PLATFORM_VERSION"
+	// FnEnvAppName is the name of the app of the fn, from version 1
+	FnEnvAppName = "FN_APP_NAME"
+	// FnEnvRegion is the region of the runner, from version 1, if the runner has one
+	FnEnvRegion = "FN_REGION"
+	// FnEnvRunnerID is the id of the runner the container runs on, from version 1
+	FnEnvRunnerID = "FN_RUNNER_ID"
+)
+
+// The headers the agent sets on each call made to a container
+const (
+	// FnHeaderCallID is the id of the call
+	FnHeaderCallID = "Fn-Call-Id"
+	// FnHeaderDeadline is when the call times out, in RFC3339 to the second
+	FnHeaderDeadline = "Fn-Deadline"
+	// FnHeaderDeadlineMs is when the call times out, in milliseconds since the epoch, from version 1
+	FnHeaderDeadlineMs = "Fn-Deadline-Ms"
+	// FnHeaderContractVersion is the fdk contract version the agent chose for the container, see
+	// drivers.ContractVersionsLabel
+	FnHeaderContractVersion = "Fn-Contract-Version"
+)
+
+// PlatformEnvVersion is the latest version of the platform env vars and headers. Runners set version 0 unless told
+// otherwise, so fns relying on the old set keep working until they are upgraded.
+const PlatformEnvVersion = 1
+
+// isReservedConfigKey returns whether a config key is set by fn itself
+func isReservedConfigKey(k string) bool {
+	switch k {
+	case FnEnvMemory, FnEnvCPUs, FnEnvType, FnEnvFnID, FnEnvAppID, FnEnvListener, FnEnvFormat,
+		FnEnvPlatformVersion, FnEnvAppName, FnEnvRegion, FnEnvRunnerID, drivers.EnvContractVersion:
+		return true
+	}
+	return isScratchEnv(k)
+}
+
+// setPlatformEnv sets the env vars of the runner in env, the env of a container of call, for the platform env version
+// of cfg. The env vars of the fn are set in its config as it is called.
+func setPlatformEnv(env map[string]string, call *call, cfg *Config) {
+	if cfg.PlatformEnvVersion < 1 {
+		return
+	}
+	env[FnEnvPlatformVersion] = strconv.FormatUint(cfg.PlatformEnvVersion, 10)
+	env[FnEnvAppName] = call.AppName
+	env[FnEnvRunnerID] = cfg.RunnerID
+	if cfg.Region != "" {
+		env[FnEnvRegion] = cfg.Region
+	} else {
+		delete(env, FnEnvRegion)
+	}
+}
+
+// setPlatformHeaders sets the headers of a call made to a container with the given deadline, if it has one, for the
+// platform env version of cfg
+func setPlatformHeaders(h http.Header, call *call, deadline time.Time, cfg *Config) {
+	h.Set(FnHeaderCallID, call.ID)
+	if deadline.IsZero() {
+		return
+	}
+	h.Set(FnHeaderDeadline, deadline.Format(time.RFC3339))
+	if cfg.PlatformEnvVersion >= 1 {
+		h.Set(FnHeaderDeadlineMs, strconv.FormatInt(deadline.UnixNano()/int64(time.Millisecond), 10))
+	}
+}
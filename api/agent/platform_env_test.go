@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestPlatformEnv(t *testing.T) {
+	c := &call{Call: &models.Call{ID: "call_id", AppName: "myapp"}}
+	deadline := time.Unix(1500000000, 250*int64(time.Millisecond))
+
+	// version 0 keeps the original set
+	cfg := &Config{Region: "us-ashburn-1", RunnerID: "runner-1"}
+	env := map[string]string{FnEnvAppID: "app_id"}
+	setPlatformEnv(env, c, cfg)
+	if len(env) != 1 {
+		t.Fatalf("expected no platform env vars at version 0, got %v", env)
+	}
+	h := make(http.Header)
+	setPlatformHeaders(h, c, deadline, cfg)
+	if h.Get(FnHeaderCallID) != "call_id" || h.Get(FnHeaderDeadline) == "" || h.Get(FnHeaderDeadlineMs) != "" {
+		t.Fatalf("unexpected headers at version 0 %v", h)
+	}
+
+	cfg.PlatformEnvVersion = 1
+	setPlatformEnv(env, c, cfg)
+	for k, v := range map[string]string{
+		FnEnvAppID:           "app_id",
+		FnEnvPlatformVersion: "1",
+		FnEnvAppName:         "myapp",
+		FnEnvRegion:          "us-ashburn-1",
+		FnEnvRunnerID:        "runner-1",
+	} {
+		if env[k] != v {
+			t.Errorf("expected %s to be %q, got %q", k, v, env[k])
+		}
+	}
+	h = make(http.Header)
+	setPlatformHeaders(h, c, deadline, cfg)
+	if h.Get(FnHeaderDeadlineMs) != "1500000000250" {
+		t.Fatalf("expected the deadline in milliseconds, got %v", h)
+	}
+
+	// fns cannot fake the region of runners without one
+	cfg.Region = ""
+	setPlatformEnv(env, c, cfg)
+	if _, ok := env[FnEnvRegion]; ok {
+		t.Fatalf("expected no region, got %v", env)
+	}
+	if !isReservedConfigKey(FnEnvRunnerID) {
+		t.Fatal("expected the platform env vars to be reserved")
+	}
+}
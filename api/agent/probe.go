@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+)
+
+// probeContainer waits for a hot container whose UDS socket appeared to be ready to take calls, see
+// EnvReadinessProbePath. A container that never becomes ready fails with models.ErrContainerNotReady.
+func probeContainer(ctx context.Context, cfg *Config, c *container) error {
+	socket := filepath.Join(c.iofs.AgentPath(), udsFilename)
+	err := probeUDS(ctx, cfg, &c.udsClient, socket)
+	if err != nil && ctx.Err() == nil {
+		common.Logger(ctx).WithError(err).Info("container failed its readiness probe")
+		return models.ErrContainerNotReady
+	}
+	return err
+}
+
+// probeUDS probes socket up to 1+cfg.ReadinessProbeRetries times, returning the error of the last probe
+func probeUDS(ctx context.Context, cfg *Config, client *http.Client, socket string) error {
+	var err error
+	for attempt := uint64(0); attempt <= cfg.ReadinessProbeRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(cfg.ReadinessProbeInterval):
+			}
+		}
+
+		err = probeOnce(ctx, cfg, client, socket)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func probeOnce(ctx context.Context, cfg *Config, client *http.Client, socket string) error {
+	ctx, cancel := context.WithTimeout(ctx, cfg.ReadinessProbeTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", socket)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+
+	if cfg.ReadinessProbePath == "" {
+		return nil
+	}
+
+	req, err := http.NewRequest("GET", "http://localhost"+cfg.ReadinessProbePath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("readiness probe %s returned %d", cfg.ReadinessProbePath, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadinessProbe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, udsFilename)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+	cfg := &Config{ReadinessProbeTimeout: time.Second, ReadinessProbeRetries: 2, ReadinessProbeInterval: time.Millisecond}
+	ctx := context.Background()
+
+	if err := probeUDS(ctx, cfg, client, socket); err == nil {
+		t.Fatal("expected the probe of a socket nobody listens on to fail")
+	}
+
+	lsnr, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var probes int32
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/probe" || atomic.AddInt32(&probes, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})}
+	go srv.Serve(lsnr)
+	defer srv.Close()
+
+	if err := probeUDS(ctx, cfg, client, socket); err != nil {
+		t.Fatalf("expected the socket to be ready without an http probe, got %v", err)
+	}
+
+	cfg.ReadinessProbePath = "/probe"
+	cfg.ReadinessProbeRetries = 1
+	if err := probeUDS(ctx, cfg, client, socket); err == nil {
+		t.Fatal("expected the http probe to fail before the container is ready")
+	}
+	if err := probeUDS(ctx, cfg, client, socket); err != nil {
+		t.Fatalf("expected the http probe to pass once retried, got %v", err)
+	}
+	if atomic.LoadInt32(&probes) != 3 {
+		t.Fatalf("expected 3 http probes, got %d", probes)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -22,8 +23,8 @@ import (
 	"github.com/fnproject/fn/grpcutil"
 	"github.com/golang/protobuf/ptypes/empty"
 	pbst "github.com/golang/protobuf/ptypes/struct"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/sirupsen/logrus"
-	"go.opencensus.io/plugin/ocgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -631,6 +632,8 @@ type pureRunner struct {
 	callHandleLock sync.Mutex
 	enableDetach   bool
 	configFunc     func(context.Context, *runner.ConfigMsg) (*runner.ConfigStatus, error)
+	tokens         *RunnerTokens
+	payloadKeys    KeyProvider
 }
 
 // implements Agent
@@ -689,6 +692,33 @@ func (pr *pureRunner) spawnDetachSubmit(state *callHandle) {
 	}()
 }
 
+// spawnWarmup warms count containers for the call instead of running it, the
+// containers share the LB assigned slot hash so that later calls find them.
+func (pr *pureRunner) spawnWarmup(state *callHandle, slotHashID, count string, opts []CallOpt) {
+	go func() {
+		err := func() error {
+			w, ok := pr.a.(Warmer)
+			if !ok {
+				return models.ErrWarmupUnsupported
+			}
+			n, err := strconv.Atoi(count)
+			if err != nil || n <= 0 {
+				return models.ErrInvalidWarmupCount
+			}
+			hashID, err := hex.DecodeString(slotHashID)
+			if err != nil {
+				return err
+			}
+			warmed, err := w.Warmup(state.sctx, n, append(opts, withSlotHashID(string(hashID)))...)
+			if err == nil && warmed < n {
+				err = models.ErrCallTimeoutServerBusy
+			}
+			return err
+		}()
+		state.enqueueCallResponse(err)
+	}()
+}
+
 // handleTryCall based on the TryCall message, tries to place the call on NBIO Agent
 func (pr *pureRunner) handleTryCall(tc *runner.TryCall, state *callHandle) error {
 
@@ -714,12 +744,24 @@ func (pr *pureRunner) handleTryCall(tc *runner.TryCall, state *callHandle) error
 	c.StartedAt = common.DateTime(time.Time{})
 	c.CompletedAt = common.DateTime(time.Time{})
 
-	agentCall, err := pr.a.GetCall(FromModelAndInput(&c, state.pipeToFnR),
+	opts := []CallOpt{FromModelAndInput(&c, state.pipeToFnR),
 		WithLogger(common.NoopReadWriteCloser{}),
 		WithWriter(state),
 		WithContext(state.sctx),
 		WithExtensions(tc.GetExtensions()),
-	)
+	}
+
+	if count, ok := tc.GetExtensions()[WarmupExtension]; ok {
+		pr.spawnWarmup(state, tc.SlotHashId, count, opts)
+		return nil
+	}
+
+	if _, ok := tc.GetExtensions()[PrePullExtension]; ok {
+		pr.spawnPrePull(state, c.Image)
+		return nil
+	}
+
+	agentCall, err := pr.a.GetCall(opts...)
 	if err != nil {
 		state.enqueueCallResponse(err)
 		return err
@@ -816,6 +858,10 @@ func (pr *pureRunner) Status(ctx context.Context, e *empty.Empty) (*runner.Runne
 
 // implements RunnerProtocolServer
 func (pr *pureRunner) Status2(ctx context.Context, r *pbst.Struct) (*runner.RunnerStatus, error) {
+	if isHeartbeat(r) {
+		// answering is the heartbeat, the runner is serving, without running the status image
+		return &runner.RunnerStatus{Active: atomic.LoadInt32(&pr.status.inflight)}, nil
+	}
 	return pr.status.Status2(ctx, r)
 }
 
@@ -859,14 +905,20 @@ func (pr *pureRunner) AfterCall(ctx context.Context, call *models.Call) error {
 	return nil
 }
 
-func DefaultPureRunner(cancel context.CancelFunc, addr string, tlsCfg *tls.Config) (Agent, error) {
-	agent := New()
+// DefaultPureRunner creates a pure runner of a default agent, unless options provide one with PureRunnerWithAgent
+func DefaultPureRunner(cancel context.CancelFunc, addr string, tlsCfg *tls.Config, options ...PureRunnerOption) (Agent, error) {
+	options = append(options, func(pr *pureRunner) error {
+		if pr.a == nil {
+			pr.a = New()
+		}
+		return nil
+	})
 
 	// WARNING: SSL creds are optional.
 	if tlsCfg == nil {
-		return NewPureRunner(cancel, addr, PureRunnerWithAgent(agent))
+		return NewPureRunner(cancel, addr, options...)
 	}
-	return NewPureRunner(cancel, addr, PureRunnerWithAgent(agent), PureRunnerWithSSL(tlsCfg))
+	return NewPureRunner(cancel, addr, append(options, PureRunnerWithSSL(tlsCfg))...)
 }
 
 type PureRunnerOption func(*pureRunner) error
@@ -991,9 +1043,18 @@ func NewPureRunner(cancel context.CancelFunc, addr string, options ...PureRunner
 	}
 	pr.status.setAgent(pr.a)
 
-	pr.gRPCOptions = append(pr.gRPCOptions, grpc.StreamInterceptor(grpcutil.RIDStreamServerInterceptor))
-	pr.gRPCOptions = append(pr.gRPCOptions, grpc.UnaryInterceptor(grpcutil.RIDUnaryServerInterceptor))
-	pr.gRPCOptions = append(pr.gRPCOptions, grpc.StatsHandler(&ocgrpc.ServerHandler{}))
+	streamInterceptors := []grpc.StreamServerInterceptor{grpcutil.RIDStreamServerInterceptor}
+	unaryInterceptors := []grpc.UnaryServerInterceptor{grpcutil.RIDUnaryServerInterceptor}
+	if pr.tokens != nil {
+		streamInterceptors = append([]grpc.StreamServerInterceptor{pr.runnerTokenStreamInterceptor}, streamInterceptors...)
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{pr.runnerTokenUnaryInterceptor}, unaryInterceptors...)
+	}
+	if pr.payloadKeys != nil {
+		streamInterceptors = append(streamInterceptors, pr.payloadStreamInterceptor)
+	}
+	pr.gRPCOptions = append(pr.gRPCOptions, grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(streamInterceptors...)))
+	pr.gRPCOptions = append(pr.gRPCOptions, grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unaryInterceptors...)))
+	pr.gRPCOptions = append(pr.gRPCOptions, grpc.StatsHandler(&common.TraceParentServerHandler{}))
 
 	if pr.creds != nil {
 		pr.gRPCOptions = append(pr.gRPCOptions, grpc.Creds(pr.creds))
@@ -0,0 +1,309 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// recoveryStateFile is the file in FN_IOFS_PATH the agent records the containers it left running in, see
+	// EnvEnableWarmRecovery
+	recoveryStateFile = "agent-state.json"
+	// recoveryExpiryInterval is how often recovered containers that were not adopted are checked for expiry
+	recoveryExpiryInterval = 5 * time.Second
+)
+
+// recoveredContainer is a hot container left running by an agent that shut down
+type recoveredContainer struct {
+	// ID is the id of the container, which drivers name containers after
+	ID string `json:"id"`
+	// SlotKey is the key of the slot queue of the container, see getSlotQueueKey
+	SlotKey        []byte `json:"slot_key"`
+	AppID          string `json:"app_id"`
+	FnID           string `json:"fn_id"`
+	Image          string `json:"image"`
+	IOFSAgentPath  string `json:"iofs_agent_path"`
+	IOFSDockerPath string `json:"iofs_docker_path"`
+	// IdleTimeout is how long the container is kept running once detached, in seconds
+	IdleTimeout int32           `json:"idle_timeout"`
+	DetachedAt  common.DateTime `json:"detached_at"`
+}
+
+func (rc *recoveredContainer) expired(now time.Time) bool {
+	return !now.Before(time.Time(rc.DetachedAt).Add(time.Duration(rc.IdleTimeout) * time.Second))
+}
+
+// agentState is the state the agent persists across restarts
+type agentState struct {
+	Containers []*recoveredContainer `json:"containers"`
+}
+
+// containerRecovery keeps the hot containers of the agent warm across restarts: the idle ones are detached rather
+// than removed as the agent shuts down, and adopted into the slot queues of the agent that restarts as calls need
+// them. It is nil unless EnvEnableWarmRecovery is set.
+type containerRecovery struct {
+	path   string
+	driver drivers.Recoverer
+
+	lock sync.Mutex
+	// recovered are the containers left running by the previous agent that were not adopted yet, by slot key
+	recovered map[string][]*recoveredContainer
+	// detached are the containers left running by this agent as it shuts down
+	detached []*recoveredContainer
+}
+
+// newContainerRecovery loads the containers left running by the previous agent. The state file is removed once
+// loaded, so that the containers are not adopted twice should the agent crash.
+func newContainerRecovery(cfg *Config) *containerRecovery {
+	if !cfg.EnableWarmRecovery {
+		return nil
+	}
+	if cfg.IOFSAgentPath == "" || cfg.IOFSEnableTmpfs {
+		logrus.Warnf("Warm recovery is disabled, it needs %s and no %s", EnvIOFSPath, EnvIOFSEnableTmpfs)
+		return nil
+	}
+
+	r := &containerRecovery{
+		path:      filepath.Join(cfg.IOFSAgentPath, recoveryStateFile),
+		recovered: make(map[string][]*recoveredContainer),
+	}
+
+	var state agentState
+	b, err := ioutil.ReadFile(r.path)
+	if err == nil {
+		os.Remove(r.path)
+		err = json.Unmarshal(b, &state)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		logrus.WithError(err).Error("Failed to load the agent state, no containers are recovered")
+	}
+
+	now := time.Now()
+	for _, rc := range state.Containers {
+		if rc.expired(now) {
+			// the driver removes the container as leaked
+			os.RemoveAll(rc.IOFSAgentPath)
+			continue
+		}
+		key := string(rc.SlotKey)
+		r.recovered[key] = append(r.recovered[key], rc)
+	}
+	return r
+}
+
+// containerIDs returns the ids of the containers that may be adopted, for the driver not to remove them as leaked
+func (r *containerRecovery) containerIDs() []string {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var ids []string
+	for _, list := range r.recovered {
+		for _, rc := range list {
+			ids = append(ids, rc.ID)
+		}
+	}
+	return ids
+}
+
+// start removes the recovered containers that are not adopted within their idle timeout, until closer is closed
+func (r *containerRecovery) start(drv drivers.Driver, closer <-chan struct{}) {
+	if r == nil {
+		return
+	}
+	recoverer, ok := drv.(drivers.Recoverer)
+	if !ok {
+		logrus.Warnf("Warm recovery is disabled, the %T driver cannot adopt containers", drv)
+		r.lock.Lock()
+		for key, list := range r.recovered {
+			for _, rc := range list {
+				os.RemoveAll(rc.IOFSAgentPath)
+			}
+			delete(r.recovered, key)
+		}
+		r.lock.Unlock()
+		return
+	}
+	r.driver = recoverer
+	logrus.WithField("containers", len(r.containerIDs())).Info("Warm recovery is enabled")
+
+	go func() {
+		ticker := time.NewTicker(recoveryExpiryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.expire(time.Now())
+			case <-closer:
+				return
+			}
+		}
+	}()
+}
+
+// expire removes the recovered containers that were not adopted within their idle timeout
+func (r *containerRecovery) expire(now time.Time) {
+	var expired []*recoveredContainer
+	r.lock.Lock()
+	for key, list := range r.recovered {
+		kept := list[:0]
+		for _, rc := range list {
+			if rc.expired(now) {
+				expired = append(expired, rc)
+			} else {
+				kept = append(kept, rc)
+			}
+		}
+		if len(kept) == 0 {
+			delete(r.recovered, key)
+		} else {
+			r.recovered[key] = kept
+		}
+	}
+	r.lock.Unlock()
+
+	for _, rc := range expired {
+		r.remove(context.Background(), rc)
+	}
+}
+
+// remove removes a recovered container that is not to be adopted
+func (r *containerRecovery) remove(ctx context.Context, rc *recoveredContainer) {
+	log := common.Logger(ctx).WithFields(logrus.Fields{"container_id": rc.ID, "app_id": rc.AppID, "fn_id": rc.FnID})
+	log.Info("Removing recovered container")
+	if err := r.driver.RemoveContainer(ctx, rc.ID); err != nil {
+		log.WithError(err).Error("Failed to remove recovered container")
+	}
+	os.RemoveAll(rc.IOFSAgentPath)
+}
+
+// claim returns a container left running for the slot queue of call, if any, for the agent to adopt rather than
+// start a container
+func (r *containerRecovery) claim(ctx context.Context, call *call) *recoveredContainer {
+	if r == nil || r.driver == nil {
+		return nil
+	}
+	now := time.Now()
+	for {
+		r.lock.Lock()
+		list := r.recovered[call.slotHashId]
+		if len(list) == 0 {
+			r.lock.Unlock()
+			return nil
+		}
+		rc := list[len(list)-1]
+		if len(list) == 1 {
+			delete(r.recovered, call.slotHashId)
+		} else {
+			r.recovered[call.slotHashId] = list[:len(list)-1]
+		}
+		r.lock.Unlock()
+
+		// containers that stopped listening are not worth adopting
+		if !rc.expired(now) && checkSocketDestination(filepath.Join(rc.IOFSAgentPath, udsFilename)) == nil {
+			return rc
+		}
+		r.remove(ctx, rc)
+	}
+}
+
+// adopt returns a cookie for the recovered container of c, removing the container if it cannot be adopted
+func (r *containerRecovery) adopt(ctx context.Context, c *container) (drivers.Cookie, error) {
+	cookie, err := r.driver.AdoptCookie(ctx, c)
+	if err != nil {
+		common.Logger(ctx).WithError(err).Error("Failed to adopt recovered container")
+		if err := r.driver.RemoveContainer(ctx, c.id); err != nil {
+			common.Logger(ctx).WithError(err).Error("Failed to remove recovered container")
+		}
+		return nil, err
+	}
+	common.Logger(ctx).Info("Adopted recovered container")
+	return cookie, nil
+}
+
+// detachContainer detaches the container of cookie rather than close it, if the agent is shutting down and the
+// container went idle, recording it for the agent that restarts to adopt.
+func (a *agent) detachContainer(ctx context.Context, call *call, c *container, cookie drivers.Cookie, childDone chan struct{}) bool {
+	r := a.recovery
+	if r == nil || r.driver == nil || childDone == nil {
+		return false
+	}
+	select {
+	case <-a.shutWg.Closer():
+	default:
+		return false
+	}
+	// the container goes idle once done with its call, if it is running one
+	<-childDone
+	// the scratch credentials of a container are not renewed for the agent that restarts
+	if !c.idleAtShutdown || !c.retireAt.IsZero() {
+		return false
+	}
+	dc, ok := cookie.(drivers.DetachableCookie)
+	iofs, isDir := c.iofs.(*directoryIOFS)
+	if !ok || !isDir {
+		return false
+	}
+	if err := dc.Detach(ctx); err != nil {
+		common.Logger(ctx).WithError(err).Error("Failed to detach container, removing it")
+		return false
+	}
+
+	c.detached = true
+	r.lock.Lock()
+	r.detached = append(r.detached, &recoveredContainer{
+		ID:             c.id,
+		SlotKey:        []byte(call.slotHashId),
+		AppID:          call.AppID,
+		FnID:           call.FnID,
+		Image:          call.Image,
+		IOFSAgentPath:  iofs.agentPath,
+		IOFSDockerPath: iofs.dockerPath,
+		IdleTimeout:    call.IdleTimeout,
+		DetachedAt:     common.DateTime(time.Now()),
+	})
+	r.lock.Unlock()
+	common.Logger(ctx).Info("Detached container for the agent to recover")
+	return true
+}
+
+// save records the containers left running in the state file, those detached and those recovered but not adopted
+func (r *containerRecovery) save() error {
+	if r == nil || r.driver == nil {
+		return nil
+	}
+	r.lock.Lock()
+	state := agentState{Containers: append([]*recoveredContainer(nil), r.detached...)}
+	now := time.Now()
+	for _, list := range r.recovered {
+		for _, rc := range list {
+			if !rc.expired(now) {
+				state.Containers = append(state.Containers, rc)
+			}
+		}
+	}
+	r.lock.Unlock()
+
+	if len(state.Containers) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
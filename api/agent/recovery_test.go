@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+)
+
+func TestWarmRecovery(t *testing.T) {
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+	cfg.EnableWarmRecovery = true
+
+	// the scripted driver keeps detached containers running for as long as the test does
+	drv := mock.NewScripted(nil)
+
+	run := func(a Agent) {
+		cm := createModelCall("TestWarmRecovery")
+		w := httptest.NewRecorder()
+		callI, err := a.GetCall(FromModelAndInput(cm, ioutil.NopCloser(strings.NewReader("hello"))), WithWriter(w))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Submit(callI); err != nil {
+			t.Fatal(err)
+		}
+		if w.Body.String() != "hello" {
+			t.Fatalf("expected the input echoed, got %q", w.Body.String())
+		}
+	}
+	savedIDs := func() []string {
+		b, err := ioutil.ReadFile(filepath.Join(cfg.IOFSAgentPath, recoveryStateFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var state agentState
+		if err := json.Unmarshal(b, &state); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, rc := range state.Containers {
+			ids = append(ids, rc.ID)
+		}
+		return ids
+	}
+
+	a := New(WithConfig(cfg), WithDockerDriver(drv))
+	run(a)
+	checkClose(t, a)
+	ids := savedIDs()
+	if len(ids) != 1 {
+		t.Fatalf("expected the idle container to be detached, got %v", ids)
+	}
+
+	a = New(WithConfig(cfg), WithDockerDriver(drv))
+	if recovered := a.(*agent).recovery.containerIDs(); len(recovered) != 1 || recovered[0] != ids[0] {
+		t.Fatalf("expected container %s to be recovered, got %v", ids[0], recovered)
+	}
+	run(a)
+	if recovered := a.(*agent).recovery.containerIDs(); len(recovered) != 0 {
+		t.Fatalf("expected the recovered container to be adopted, got %v", recovered)
+	}
+	checkClose(t, a)
+	if again := savedIDs(); len(again) != 1 || again[0] != ids[0] {
+		t.Fatalf("expected the adopted container %s to be detached again, got %v", ids[0], again)
+	}
+
+	// containers that are not adopted within their idle timeout are removed
+	a = New(WithConfig(cfg), WithDockerDriver(drv))
+	a.(*agent).recovery.expire(time.Now().Add(time.Hour))
+	if recovered := a.(*agent).recovery.containerIDs(); len(recovered) != 0 {
+		t.Fatalf("expected the recovered container to expire, got %v", recovered)
+	}
+	if err := drv.RemoveContainer(context.Background(), ids[0]); err == nil {
+		t.Fatalf("expected container %s to be removed", ids[0])
+	}
+	checkClose(t, a)
+	if _, err := os.Stat(filepath.Join(cfg.IOFSAgentPath, recoveryStateFile)); !os.IsNotExist(err) {
+		t.Fatalf("expected no containers left to recover, got %v", err)
+	}
+}
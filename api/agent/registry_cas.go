@@ -0,0 +1,351 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// registryCASuffix is the suffix of the CA bundles in the registry CA dir, named after their registry
+	registryCASuffix = ".crt"
+	// registryCAManagedFile marks the registry dirs of the docker certs dir whose ca.crt the agent manages
+	registryCAManagedFile = ".fn-managed"
+	// registryCAResync is how often the registry CA dir is synced even without any change seen
+	registryCAResync = time.Minute
+)
+
+var (
+	// ErrRegistryCAsDisabled is returned for managing registry CAs on runners without a registry CA dir
+	ErrRegistryCAsDisabled = models.NewAPIError(http.StatusNotFound, errors.New("Registry CAs are not enabled on this runner"))
+	// ErrRegistryCANotFound is returned for removing the CA of a registry that has none
+	ErrRegistryCANotFound = models.NewAPIError(http.StatusNotFound, errors.New("Registry CA not found"))
+	// ErrInvalidRegistry is returned for registries that are not a host and optional port
+	ErrInvalidRegistry = models.NewAPIError(http.StatusBadRequest, errors.New("Invalid registry, it must be a host and optional port"))
+	// ErrInvalidRegistryCA is returned for CA bundles without any PEM encoded certificate
+	ErrInvalidRegistryCA = models.NewAPIError(http.StatusBadRequest, errors.New("Invalid registry CA, it must hold PEM encoded certificates"))
+
+	validRegistry = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?(:[0-9]+)?$`)
+)
+
+// RegistryCA describes the CA bundle trusted for pulls from a registry
+type RegistryCA struct {
+	Registry string          `json:"registry"`
+	Subjects []string        `json:"subjects"`
+	NotAfter common.DateTime `json:"not_after"`
+}
+
+// RegistryCAManager is implemented by agents managing the CA certificates their docker daemons trust for private
+// registries, which are picked up by pulls without restarting the runner
+type RegistryCAManager interface {
+	// RegistryCAs returns the CA bundles of registries, by registry
+	RegistryCAs() ([]RegistryCA, error)
+	// PutRegistryCA adds or replaces the CA bundle of a registry, eg. registry.example.com:5000
+	PutRegistryCA(registry string, bundle []byte) (*RegistryCA, error)
+	// DeleteRegistryCA removes the CA bundle of a registry
+	DeleteRegistryCA(registry string) error
+}
+
+var _ RegistryCAManager = new(agent)
+var _ RegistryCAManager = new(pureRunner)
+
+// RegistryCAs implements RegistryCAManager
+func (a *agent) RegistryCAs() ([]RegistryCA, error) {
+	if a.registryCAs == nil {
+		return nil, ErrRegistryCAsDisabled
+	}
+	return a.registryCAs.list()
+}
+
+// PutRegistryCA implements RegistryCAManager
+func (a *agent) PutRegistryCA(registry string, bundle []byte) (*RegistryCA, error) {
+	if a.registryCAs == nil {
+		return nil, ErrRegistryCAsDisabled
+	}
+	return a.registryCAs.put(registry, bundle)
+}
+
+// DeleteRegistryCA implements RegistryCAManager
+func (a *agent) DeleteRegistryCA(registry string) error {
+	if a.registryCAs == nil {
+		return ErrRegistryCAsDisabled
+	}
+	return a.registryCAs.delete(registry)
+}
+
+// RegistryCAs implements RegistryCAManager
+func (pr *pureRunner) RegistryCAs() ([]RegistryCA, error) {
+	if m, ok := pr.a.(RegistryCAManager); ok {
+		return m.RegistryCAs()
+	}
+	return nil, ErrRegistryCAsDisabled
+}
+
+// PutRegistryCA implements RegistryCAManager
+func (pr *pureRunner) PutRegistryCA(registry string, bundle []byte) (*RegistryCA, error) {
+	if m, ok := pr.a.(RegistryCAManager); ok {
+		return m.PutRegistryCA(registry, bundle)
+	}
+	return nil, ErrRegistryCAsDisabled
+}
+
+// DeleteRegistryCA implements RegistryCAManager
+func (pr *pureRunner) DeleteRegistryCA(registry string) error {
+	if m, ok := pr.a.(RegistryCAManager); ok {
+		return m.DeleteRegistryCA(registry)
+	}
+	return ErrRegistryCAsDisabled
+}
+
+// registryCAManager keeps the registry CA dir, holding a registry.example.com:5000.crt bundle per registry, in
+// sync with the certs dir of the docker daemon, holding them as registry.example.com:5000/ca.crt, which docker
+// reads on every pull. Bundles are added to the dir by hand, by config management or through the admin api, and
+// the agent only ever removes the ca.crt files it wrote.
+type registryCAManager struct {
+	dir      string
+	certsDir string
+
+	lock    sync.Mutex
+	managed map[string]bool
+}
+
+// newRegistryCAManager returns nil if registry CAs are not configured. The registries managed by a previous run are
+// picked up from the docker certs dir.
+func newRegistryCAManager(cfg *Config) (*registryCAManager, error) {
+	if cfg.RegistryCADir == "" {
+		return nil, nil
+	}
+	m := &registryCAManager{dir: cfg.RegistryCADir, certsDir: cfg.DockerCertsDir, managed: make(map[string]bool)}
+	for _, dir := range []string{m.dir, m.certsDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("cannot create registry CA dir: %v", err)
+		}
+	}
+
+	registries, err := ioutil.ReadDir(m.certsDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range registries {
+		if _, err := os.Stat(filepath.Join(m.certsDir, r.Name(), registryCAManagedFile)); err == nil {
+			m.managed[r.Name()] = true
+		}
+	}
+	return m, m.sync()
+}
+
+// parseRegistryCA returns the certificates of a PEM bundle
+func parseRegistryCA(bundle []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, bundle = pem.Decode(bundle)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, ErrInvalidRegistryCA
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, ErrInvalidRegistryCA
+	}
+	return certs, nil
+}
+
+func describeRegistryCA(registry string, certs []*x509.Certificate) RegistryCA {
+	ca := RegistryCA{Registry: registry}
+	for _, cert := range certs {
+		ca.Subjects = append(ca.Subjects, cert.Subject.String())
+		if notAfter := common.DateTime(cert.NotAfter); time.Time(ca.NotAfter).IsZero() || cert.NotAfter.Before(time.Time(ca.NotAfter)) {
+			ca.NotAfter = notAfter
+		}
+	}
+	return ca
+}
+
+// bundles reads the valid bundles of the registry CA dir by registry, skipping the rest
+func (m *registryCAManager) bundles() (map[string][]byte, error) {
+	files, err := ioutil.ReadDir(m.dir)
+	if err != nil {
+		return nil, err
+	}
+	bundles := make(map[string][]byte)
+	for _, f := range files {
+		registry := strings.TrimSuffix(f.Name(), registryCASuffix)
+		if f.IsDir() || registry == f.Name() {
+			continue
+		}
+		log := logrus.WithFields(logrus.Fields{"registry": registry, "file": f.Name()})
+		if !validRegistry.MatchString(registry) {
+			log.Warn("Ignoring registry CA not named after a registry")
+			continue
+		}
+		bundle, err := ioutil.ReadFile(filepath.Join(m.dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := parseRegistryCA(bundle); err != nil {
+			log.Warn("Ignoring registry CA without any valid certificate")
+			continue
+		}
+		bundles[registry] = bundle
+	}
+	return bundles, nil
+}
+
+// sync writes the bundles of the registry CA dir to the docker certs dir, and removes those no longer in it
+func (m *registryCAManager) sync() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	bundles, err := m.bundles()
+	if err != nil {
+		return err
+	}
+
+	for registry, bundle := range bundles {
+		dir := filepath.Join(m.certsDir, registry)
+		path := filepath.Join(dir, "ca.crt")
+		if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, bundle) {
+			m.managed[registry] = true
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, registryCAManagedFile), nil, 0644); err != nil {
+			return err
+		}
+		// written aside and renamed, so pulls never read half a bundle
+		if err := ioutil.WriteFile(path+".tmp", bundle, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return err
+		}
+		m.managed[registry] = true
+		logrus.WithField("registry", registry).Info("Registry CA updated")
+	}
+
+	for registry := range m.managed {
+		if _, ok := bundles[registry]; ok {
+			continue
+		}
+		dir := filepath.Join(m.certsDir, registry)
+		os.Remove(filepath.Join(dir, "ca.crt"))
+		os.Remove(filepath.Join(dir, registryCAManagedFile))
+		os.Remove(dir) // only if docker has nothing else for the registry
+		delete(m.managed, registry)
+		logrus.WithField("registry", registry).Info("Registry CA removed")
+	}
+	return nil
+}
+
+func (m *registryCAManager) list() ([]RegistryCA, error) {
+	m.lock.Lock()
+	bundles, err := m.bundles()
+	m.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	cas := make([]RegistryCA, 0, len(bundles))
+	for registry, bundle := range bundles {
+		certs, _ := parseRegistryCA(bundle)
+		cas = append(cas, describeRegistryCA(registry, certs))
+	}
+	sort.Slice(cas, func(i, j int) bool { return cas[i].Registry < cas[j].Registry })
+	return cas, nil
+}
+
+func (m *registryCAManager) put(registry string, bundle []byte) (*RegistryCA, error) {
+	if !validRegistry.MatchString(registry) {
+		return nil, ErrInvalidRegistry
+	}
+	certs, err := parseRegistryCA(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(m.dir, registry+registryCASuffix)
+	if err := ioutil.WriteFile(path+".tmp", bundle, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, err
+	}
+	ca := describeRegistryCA(registry, certs)
+	return &ca, m.sync()
+}
+
+func (m *registryCAManager) delete(registry string) error {
+	if !validRegistry.MatchString(registry) {
+		return ErrInvalidRegistry
+	}
+	err := os.Remove(filepath.Join(m.dir, registry+registryCASuffix))
+	if os.IsNotExist(err) {
+		return ErrRegistryCANotFound
+	} else if err != nil {
+		return err
+	}
+	return m.sync()
+}
+
+// watch syncs the registry CA dir as it changes, and every registryCAResync in case a change was missed, until
+// closer is closed
+func (m *registryCAManager) watch(closer <-chan struct{}) {
+	if m == nil {
+		return
+	}
+
+	var events chan fsnotify.Event
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = watcher.Add(m.dir)
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Cannot watch the registry CA dir, syncing it periodically only")
+	} else {
+		defer watcher.Close()
+		events = watcher.Events
+	}
+
+	ticker := time.NewTicker(registryCAResync)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closer:
+			return
+		case event := <-events:
+			if !strings.HasSuffix(event.Name, registryCASuffix) {
+				continue
+			}
+		case <-ticker.C:
+		}
+		if err := m.sync(); err != nil {
+			logrus.WithError(err).Error("Failed to sync registry CAs")
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testRegistryCA(t *testing.T, cn string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestRegistryCAs(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "registry-cas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	cfg := &Config{RegistryCADir: filepath.Join(tmp, "cas"), DockerCertsDir: filepath.Join(tmp, "certs.d")}
+	os.MkdirAll(filepath.Join(cfg.DockerCertsDir, "other.example.com"), 0755)
+	ioutil.WriteFile(filepath.Join(cfg.DockerCertsDir, "other.example.com", "ca.crt"), []byte("not ours"), 0644)
+
+	m, err := newRegistryCAManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.put("registry.example.com:5000", []byte("not a cert")); err != ErrInvalidRegistryCA {
+		t.Fatalf("expected invalid CA, got %v", err)
+	}
+	if _, err := m.put("../etc", testRegistryCA(t, "x")); err != ErrInvalidRegistry {
+		t.Fatalf("expected invalid registry, got %v", err)
+	}
+
+	bundle := testRegistryCA(t, "Registry CA")
+	ca, err := m.put("registry.example.com:5000", bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ca.Subjects) != 1 || ca.Subjects[0] != "CN=Registry CA" {
+		t.Fatalf("unexpected subjects %v", ca.Subjects)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(cfg.DockerCertsDir, "registry.example.com:5000", "ca.crt"))
+	if err != nil || string(b) != string(bundle) {
+		t.Fatalf("expected CA in docker certs dir, got %q %v", b, err)
+	}
+
+	// bundles dropped in the dir by hand are picked up on sync
+	ioutil.WriteFile(filepath.Join(cfg.RegistryCADir, "internal.example.com.crt"), testRegistryCA(t, "Internal CA"), 0644)
+	if err := m.sync(); err != nil {
+		t.Fatal(err)
+	}
+	cas, err := m.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cas) != 2 || cas[0].Registry != "internal.example.com" || cas[1].Registry != "registry.example.com:5000" {
+		t.Fatalf("unexpected CAs %+v", cas)
+	}
+
+	if err := m.delete("registry.example.com:5000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.delete("registry.example.com:5000"); err != ErrRegistryCANotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.DockerCertsDir, "registry.example.com:5000")); !os.IsNotExist(err) {
+		t.Fatalf("expected removed CA, got %v", err)
+	}
+
+	// a restarted runner removes the bundles it wrote that are gone, and never those it did not write
+	os.Remove(filepath.Join(cfg.RegistryCADir, "internal.example.com.crt"))
+	if _, err := newRegistryCAManager(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.DockerCertsDir, "internal.example.com")); !os.IsNotExist(err) {
+		t.Fatalf("expected removed CA, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.DockerCertsDir, "other.example.com", "ca.crt")); err != nil {
+		t.Fatalf("expected CA not managed by the runner kept, got %v", err)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/fnproject/fn/api/models"
 	"github.com/sirupsen/logrus"
@@ -72,8 +73,18 @@ type resourceTracker struct {
 	cpuTotal uint64
 	// cpuUsed is cpu reserved for running containers including hot/idle
 	cpuUsed uint64
+
+	// diskReserved is the space to keep free on diskPath, 0 if disk is not checked
+	diskReserved uint64
+	diskPath     string
+	// diskAvailable caches whether diskReserved is free, it is checked at most every diskCheckInterval
+	diskAvailable bool
+	diskCheckedAt time.Time
 }
 
+// diskCheckInterval is how often the free space of the reserved disk is checked
+const diskCheckInterval = time.Second
+
 func NewResourceTracker(cfg *Config) ResourceTracker {
 
 	obj := &resourceTracker{
@@ -82,6 +93,7 @@ func NewResourceTracker(cfg *Config) ResourceTracker {
 
 	obj.initializeMemory(cfg)
 	obj.initializeCPU(cfg)
+	obj.initializeDisk(cfg)
 	return obj
 }
 
@@ -122,7 +134,24 @@ func (a *resourceTracker) isResourceAvailableLocked(memory uint64, cpuQuota mode
 	availMem := a.ramTotal - a.ramUsed
 	availCPU := a.cpuTotal - a.cpuUsed
 
-	return availMem >= memory && availCPU >= uint64(cpuQuota)
+	return availMem >= memory && availCPU >= uint64(cpuQuota) && a.isDiskAvailableLocked()
+}
+
+// isDiskAvailableLocked returns whether the reserved disk space is free
+func (a *resourceTracker) isDiskAvailableLocked() bool {
+	if a.diskReserved == 0 {
+		return true
+	}
+	if time.Since(a.diskCheckedAt) < diskCheckInterval {
+		return a.diskAvailable
+	}
+	free, err := diskFree(a.diskPath)
+	if err != nil {
+		logrus.WithError(err).WithField("path", a.diskPath).Error("Error checking for free disk space")
+	}
+	a.diskAvailable = err != nil || free >= a.diskReserved
+	a.diskCheckedAt = time.Now()
+	return a.diskAvailable
 }
 
 func (a *resourceTracker) GetUtilization() ResourceUtilization {
@@ -185,7 +214,7 @@ func (a *resourceTracker) GetResourceTokenNB(ctx context.Context, memory uint64,
 	availMem := a.ramTotal - a.ramUsed
 	availCPU := a.cpuTotal - a.cpuUsed
 
-	if availMem >= memory && availCPU >= uint64(cpuQuota) {
+	if availMem >= memory && availCPU >= uint64(cpuQuota) && a.isDiskAvailableLocked() {
 		t = a.allocResourcesLocked(memory, cpuQuota)
 	} else {
 		if availMem < memory {
@@ -298,7 +327,14 @@ func (a *resourceTracker) initializeCPU(cfg *Config) {
 		// TODO: check cgroup cpuset to clamp this further. We might be restricted into
 		// a subset of CPUs. (eg. /sys/fs/cgroup/cpuset/cpuset.effective_cpus)
 
-		// TODO: skip CPU headroom for ourselves for now
+	}
+
+	// keep the reserved CPU for ourselves, docker and the OS
+	if cfg != nil && cfg.ReservedCPU != 0 {
+		if cfg.ReservedCPU >= availCPU {
+			logrus.WithFields(logrus.Fields{"avail_cpu": availCPU, "reserved_cpu": cfg.ReservedCPU}).Fatal("Reserved CPU leaves no CPU for functions")
+		}
+		availCPU -= cfg.ReservedCPU
 	}
 
 	// now based on cfg, further clamp on calculated values
@@ -383,9 +419,38 @@ func (a *resourceTracker) initializeMemory(cfg *Config) {
 	}
 }
 
+func (a *resourceTracker) initializeDisk(cfg *Config) {
+	if cfg == nil || cfg.ReservedDisk == 0 {
+		return
+	}
+
+	free, err := diskFree(cfg.ReservedDiskPath)
+	if err != nil {
+		logrus.WithError(err).WithField("path", cfg.ReservedDiskPath).Warn("Cannot check free disk space, disk will not be reserved")
+		return
+	}
+
+	a.diskReserved = cfg.ReservedDisk
+	a.diskPath = cfg.ReservedDiskPath
+
+	logrus.WithFields(logrus.Fields{
+		"path":          a.diskPath,
+		"free_disk":     free,
+		"reserved_disk": a.diskReserved,
+	}).Info("disk reservations")
+}
+
 // headroom estimation in order not to consume entire RAM if possible
 func getMemoryHeadRoom(usableMemory uint64, cfg *Config) (uint64, error) {
 
+	// an explicit reservation replaces the estimate
+	if cfg != nil && cfg.ReservedMemory != 0 {
+		if cfg.ReservedMemory >= usableMemory {
+			return 0, fmt.Errorf("Not enough memory: %v, reserved: %v", usableMemory, cfg.ReservedMemory)
+		}
+		return cfg.ReservedMemory, nil
+	}
+
 	// get %10 of the RAM
 	headRoom := uint64(usableMemory / 10)
 
@@ -2,6 +2,7 @@ package agent
 
 import (
 	"context"
+	"os"
 	"testing"
 	"time"
 )
@@ -179,3 +180,33 @@ func TestResourceGetSimpleNB(t *testing.T) {
 		t.Fatalf("faulty state CPU %#v", vals)
 	}
 }
+
+func TestResourceReservations(t *testing.T) {
+	free, err := diskFree(os.TempDir())
+	if err != nil {
+		t.Skip(err)
+	}
+
+	unreserved := NewResourceTracker(&Config{ReservedDiskPath: os.TempDir()}).(*resourceTracker)
+	reserved := NewResourceTracker(&Config{ReservedCPU: 100, ReservedDisk: free * 2, ReservedDiskPath: os.TempDir()}).(*resourceTracker)
+	if reserved.cpuTotal != unreserved.cpuTotal-100 {
+		t.Errorf("expected reserved cpu to be subtracted, got %d of %d", reserved.cpuTotal, unreserved.cpuTotal)
+	}
+
+	if tok := unreserved.GetResourceTokenNB(context.Background(), 1, 1); tok.Error() != nil {
+		t.Errorf("expected a token without disk reserved, got %v", tok.Error())
+	} else {
+		tok.Close()
+	}
+	if tok := reserved.GetResourceTokenNB(context.Background(), 1, 1); tok.Error() != CapacityFull {
+		t.Errorf("expected capacity to be full with more disk reserved than free, got %v", tok.Error())
+	}
+
+	headRoom, err := getMemoryHeadRoom(8*Mem1GB, &Config{ReservedMemory: Mem1GB})
+	if err != nil || headRoom != Mem1GB {
+		t.Errorf("expected reserved memory as head room, got %d %v", headRoom, err)
+	}
+	if _, err := getMemoryHeadRoom(Mem1GB, &Config{ReservedMemory: Mem1GB}); err == nil {
+		t.Error("expected an error when reserving all memory")
+	}
+}
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/patrickmn/go-cache"
+)
+
+// maxResultCachePayload is the largest payload of calls that are cached, in bytes, larger calls always run
+const maxResultCachePayload = 1024 * 1024
+
+// resultCache remembers the detached calls that succeeded on the runner, of fns opting in with
+// models.FnResultCacheAnnotation, so identical calls within the ttl are skipped. The outputs of detached calls are
+// discarded, only their success is remembered.
+type resultCache struct {
+	cache      *cache.Cache
+	maxEntries int
+}
+
+// newResultCache returns a resultCache remembering up to maxEntries calls, or nil if maxEntries is 0
+func newResultCache(maxEntries uint64) *resultCache {
+	if maxEntries == 0 {
+		return nil
+	}
+	return &resultCache{
+		cache:      cache.New(cache.NoExpiration, time.Minute),
+		maxEntries: int(maxEntries),
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// fnRevision identifies what the container of c runs, which a change to its fn or app would change
+func fnRevision(c *call) []byte {
+	b, _ := json.Marshal(struct {
+		FnID        string             `json:"fn_id"`
+		Image       string             `json:"image"`
+		Memory      uint64             `json:"memory"`
+		CPUs        models.MilliCPUs   `json:"cpus"`
+		Timeout     int32              `json:"timeout"`
+		Config      models.Config      `json:"config"`
+		Annotations models.Annotations `json:"annotations"`
+	}{c.FnID, c.Image, c.Memory, c.CPUs, c.Timeout, c.Config, c.Annotations})
+	return b
+}
+
+// requestKey identifies what the fn sees of req but its payload: its method, url and content type, and the headers
+// of the http request of a trigger
+func requestKey(req *http.Request) []byte {
+	var b bytes.Buffer
+	b.WriteString(req.Method)
+	b.WriteByte(0)
+	b.WriteString(req.URL.String())
+	b.WriteByte(0)
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		if k == "Content-Type" || strings.HasPrefix(k, "Fn-Http-") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(k + ":" + strings.Join(req.Header[k], ","))
+		b.WriteByte(0)
+	}
+	return b.Bytes()
+}
+
+// hit sets up the result cache key of c, if it is a detached call of a fn opting in, and returns whether an
+// identical call succeeded within the ttl. The payload of c is read to do so, and put back.
+func (r *resultCache) hit(ctx context.Context, c *call) bool {
+	if r == nil || c.Type != models.TypeDetached || c.req == nil || c.req.Body == nil {
+		return false
+	}
+	policy, err := c.Annotations.ResultCache()
+	if err != nil || policy == nil {
+		return false
+	}
+
+	body := c.req.Body
+	payload, err := ioutil.ReadAll(io.LimitReader(body, maxResultCachePayload+1))
+	if err != nil || len(payload) > maxResultCachePayload {
+		// put back what was read, the fn gets the error reading its payload too
+		c.req.Body = readCloser{io.MultiReader(bytes.NewReader(payload), body), body}
+		return false
+	}
+	c.req.Body = readCloser{bytes.NewReader(payload), body}
+
+	h := sha256.New()
+	h.Write(fnRevision(c))
+	h.Write([]byte{0})
+	h.Write(requestKey(c.req))
+	h.Write(payload)
+	c.resultKey = hex.EncodeToString(h.Sum(nil))
+	c.resultTTL = policy.Duration()
+
+	if _, ok := r.cache.Get(c.resultKey); ok {
+		common.Logger(ctx).WithField("call_id", c.ID).Debug("identical call succeeded recently, skipping call")
+		return true
+	}
+	return false
+}
+
+// remember records that c succeeded, if its fn opted in
+func (r *resultCache) remember(c *call) {
+	if r == nil || c.resultKey == "" {
+		return
+	}
+	// expired entries linger until they are cleaned up, evict them first when full
+	if r.cache.ItemCount() >= r.maxEntries {
+		r.cache.DeleteExpired()
+		if r.cache.ItemCount() >= r.maxEntries {
+			return
+		}
+	}
+	r.cache.Set(c.resultKey, struct{}{}, c.resultTTL)
+}
+
+// endCachedCall ends c, which an identical call that succeeded within the ttl stands in for, without running it
+func (a *agent) endCachedCall(ctx context.Context, c *call) error {
+	c.cacheHit = true
+	if err := c.Start(ctx); err != nil {
+		return a.handleCallEnd(ctx, c, nil, err, false)
+	}
+	statsDequeue(ctx)
+	statsStartRun(ctx)
+	statsResultCacheHit(ctx)
+	return a.handleCallEnd(ctx, c, nil, nil, true)
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestResultCache(t *testing.T) {
+	ctx := context.Background()
+	r := newResultCache(1)
+
+	newCall := func(typ, payload string, annotations models.Annotations) *call {
+		req, _ := http.NewRequest("POST", "http://localhost/invoke/fn", strings.NewReader(payload))
+		return &call{Call: &models.Call{FnID: "fn", Image: "fnproject/hello", Type: typ, Annotations: annotations}, req: req}
+	}
+	cached := models.Annotations{}
+	cached, _ = cached.With(models.FnResultCacheAnnotation, &models.FnResultCache{TTL: 60})
+
+	c := newCall(models.TypeDetached, "work", cached)
+	if r.hit(ctx, c) {
+		t.Fatal("expected miss")
+	}
+	if b, _ := ioutil.ReadAll(c.req.Body); string(b) != "work" {
+		t.Fatalf("expected payload put back, got %q", b)
+	}
+	r.remember(c)
+
+	c = newCall(models.TypeDetached, "work", cached)
+	if !r.hit(ctx, c) {
+		t.Fatal("expected hit for an identical call")
+	}
+
+	// the request is part of the key, not just its payload
+	withRequest := func(change func(req *http.Request)) *call {
+		c := newCall(models.TypeDetached, "work", cached)
+		change(c.req)
+		return c
+	}
+	for i, c := range []*call{
+		newCall(models.TypeDetached, "other work", cached),
+		newCall(models.TypeSync, "work", cached),
+		newCall(models.TypeDetached, "work", models.Annotations{}),
+		withRequest(func(req *http.Request) { req.Method = "PUT" }),
+		withRequest(func(req *http.Request) { req.URL.RawQuery = "page=2" }),
+		withRequest(func(req *http.Request) { req.Header.Set("Content-Type", "application/json") }),
+		withRequest(func(req *http.Request) { req.Header.Set("Fn-Http-Request-Url", "http://example.com/t/app/hook") }),
+		withRequest(func(req *http.Request) { req.Header.Set("Fn-Http-H-Authorization", "Bearer other") }),
+	} {
+		if r.hit(ctx, c) {
+			t.Errorf("test %d: expected miss", i)
+		}
+	}
+
+	// a change to the fn is a new revision
+	c = newCall(models.TypeDetached, "work", cached)
+	c.Image = "fnproject/hello:0.0.2"
+	if r.hit(ctx, c) {
+		t.Fatal("expected miss for a new revision")
+	}
+
+	// payloads too large are not cached, and put back whole
+	large := strings.Repeat("x", maxResultCachePayload+1)
+	c = newCall(models.TypeDetached, large, cached)
+	if r.hit(ctx, c) || c.resultKey != "" {
+		t.Fatal("expected large payload not cached")
+	}
+	if b, _ := ioutil.ReadAll(c.req.Body); len(b) != len(large) {
+		t.Fatalf("expected payload put back, got %d bytes", len(b))
+	}
+}
@@ -175,6 +175,12 @@ func (r *gRPCRunner) Status(ctx context.Context) (*pool.RunnerStatus, error) {
 	return TranslateGRPCStatusToRunnerStatus(status), err
 }
 
+// implements runnerpool.HeartbeatRunner
+func (r *gRPCRunner) Heartbeat(ctx context.Context) error {
+	_, err := r.client.Status2(ctx, heartbeatRequest)
+	return err
+}
+
 // implements Runner
 func (r *gRPCRunner) TryExec(ctx context.Context, call pool.RunnerCall) (bool, error) {
 	log := common.Logger(ctx).WithField("runner_addr", r.address)
@@ -200,6 +206,11 @@ func (r *gRPCRunner) TryExec(ctx context.Context, call pool.RunnerCall) (bool, e
 		mp := metadata.Pairs(common.RequestIDContextKey, rid)
 		ctx = metadata.NewOutgoingContext(ctx, mp)
 	}
+	// runners get the span of the placement in the W3C format as well as the opencensus binary one, and continue
+	// the trace from either, see common.TraceParentServerHandler
+	if sc := trace.FromContext(ctx).SpanContext(); sc.TraceID != (trace.TraceID{}) {
+		ctx = metadata.AppendToOutgoingContext(ctx, common.TraceParentHeader, common.TraceParent(sc))
+	}
 	runnerConnection, err := r.client.Engage(ctx)
 	if err != nil {
 		// We are going to retry on a different runner, it is ok to log this error as Info
@@ -315,7 +315,7 @@ func (st *statusTracker) newStatusCall(payload json.RawMessage) models.Call {
 
 	// Most of these arguments are baked in. We might want to make this
 	// more configurable.
-	c.ID = id.New().String()
+	c.ID = id.NewString(id.KindCall)
 	c.Image = st.imageName
 	c.Type = models.TypeSync
 	c.TmpFsSize = 0
@@ -332,3 +332,16 @@ func (st *statusTracker) newStatusCall(payload json.RawMessage) models.Call {
 
 	return c
 }
+
+// heartbeatRequest is the Status2 request lbs send runners to renew their lease
+var heartbeatRequest = &pbst.Struct{Fields: map[string]*pbst.Value{
+	"heartbeat": {Kind: &pbst.Value_BoolValue{BoolValue: true}},
+}}
+
+func isHeartbeat(r *pbst.Struct) bool {
+	if r == nil {
+		return false
+	}
+	v, ok := r.Fields["heartbeat"]
+	return ok && v.GetBoolValue()
+}
@@ -0,0 +1,374 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RunnerTokenKey is the grpc metadata key, and http header, carrying the runner token of a node
+const RunnerTokenKey = "fn-runner-token"
+
+var (
+	// ErrRunnerUnauthorized is returned when a node of a hybrid deployment presents a missing or unknown runner token
+	ErrRunnerUnauthorized = status.Error(codes.Unauthenticated, "missing or invalid runner token")
+	// ErrRunnerTokenUnknown is returned for revoking a token the node does not have
+	ErrRunnerTokenUnknown = errors.New("unknown runner token")
+	// ErrRunnerTokenLast is returned for revoking the last token of a node, which would leave its peers unauthenticated
+	ErrRunnerTokenLast = errors.New("the last runner token cannot be revoked")
+)
+
+// RunnerTokens are the tokens authenticating pure runners and the lb and api nodes they work with. A node presents
+// its current token to its peers, and accepts peers presenting any of its tokens. Accepting a token is separate from
+// presenting it, so tokens are rotated by adding the new token to every node, then promoting it on every node, before
+// revoking the old one. A node that never had tokens does not authenticate its peers, one that had does from then on,
+// keeping at least one token. Tokens only outlive the node if they are saved, see Save and Load.
+type RunnerTokens struct {
+	lock    sync.RWMutex
+	tokens  map[string]*runnerToken
+	current string
+	// enabled is set once the node has a token
+	enabled bool
+	// saveLock orders the writes of Save, so that the last change is the one kept
+	saveLock sync.Mutex
+}
+
+type runnerToken struct {
+	token     string
+	createdAt common.DateTime
+}
+
+// RunnerToken describes a runner token, the token itself is only set when it is issued
+type RunnerToken struct {
+	ID        string          `json:"id"`
+	Token     string          `json:"token,omitempty"`
+	CreatedAt common.DateTime `json:"created_at"`
+	Current   bool            `json:"current"`
+}
+
+// NewRunnerTokens returns the runner tokens of a node accepting tokens, the last of which it presents
+func NewRunnerTokens(tokens ...string) *RunnerTokens {
+	t := &RunnerTokens{tokens: make(map[string]*runnerToken)}
+	for _, token := range tokens {
+		t.Promote(t.Add(token).ID)
+	}
+	return t
+}
+
+func runnerTokenID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Issue generates a new token, which the node accepts, see Add
+func (t *RunnerTokens) Issue() (RunnerToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return RunnerToken{}, err
+	}
+	return t.Add(hex.EncodeToString(b)), nil
+}
+
+// Add adds a token, which the node accepts from now on. The node only presents it once it is promoted, unless the
+// node has no other token to present.
+func (t *RunnerTokens) Add(token string) RunnerToken {
+	id := runnerTokenID(token)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	rt, ok := t.tokens[id]
+	if !ok {
+		rt = &runnerToken{token: token, createdAt: common.DateTime(time.Now())}
+		t.tokens[id] = rt
+	}
+	t.enabled = true
+	if t.current == "" {
+		t.current = id
+	}
+	return RunnerToken{ID: id, Token: token, CreatedAt: rt.createdAt, Current: t.current == id}
+}
+
+// Promote makes the node present the token id from now on, returning whether it has it. Tokens are promoted once
+// every peer of the node accepts them.
+func (t *RunnerTokens) Promote(id string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, ok := t.tokens[id]; !ok {
+		return false
+	}
+	t.current = id
+	return true
+}
+
+// Revoke stops the node accepting the token id. Revoking the current token leaves the node presenting the newest of
+// the rest, the last token is not revoked, see ErrRunnerTokenLast.
+func (t *RunnerTokens) Revoke(id string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, ok := t.tokens[id]; !ok {
+		return ErrRunnerTokenUnknown
+	}
+	if len(t.tokens) == 1 {
+		return ErrRunnerTokenLast
+	}
+	delete(t.tokens, id)
+
+	if t.current == id {
+		t.current = t.newest()
+	}
+	return nil
+}
+
+// newest returns the id of the newest token, or "" if there are none
+func (t *RunnerTokens) newest() string {
+	newest := ""
+	for id, rt := range t.tokens {
+		if newest == "" || time.Time(rt.createdAt).After(time.Time(t.tokens[newest].createdAt)) {
+			newest = id
+		}
+	}
+	return newest
+}
+
+// runnerTokensFile is how the runner tokens of a node are saved
+type runnerTokensFile struct {
+	Current string                  `json:"current,omitempty"`
+	Tokens  []runnerTokensFileEntry `json:"tokens"`
+}
+
+type runnerTokensFileEntry struct {
+	Token     string          `json:"token"`
+	CreatedAt common.DateTime `json:"created_at"`
+}
+
+// Load adds the tokens saved to the file at path by Save, presenting the token that was presented when they were
+// saved. Nothing is loaded if the file does not exist.
+func (t *RunnerTokens) Load(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var f runnerTokensFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return fmt.Errorf("invalid runner tokens file %s: %v", path, err)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, e := range f.Tokens {
+		if e.Token != "" {
+			t.tokens[runnerTokenID(e.Token)] = &runnerToken{token: e.Token, createdAt: e.CreatedAt}
+			t.enabled = true
+		}
+	}
+	if _, ok := t.tokens[f.Current]; ok {
+		t.current = f.Current
+	} else if _, ok := t.tokens[t.current]; !ok {
+		t.current = t.newest()
+	}
+	return nil
+}
+
+// Save writes the tokens of the node to the file at path, readable by its owner only, replacing the file at once
+// so that it is never left half written
+func (t *RunnerTokens) Save(path string) error {
+	t.saveLock.Lock()
+	defer t.saveLock.Unlock()
+
+	t.lock.RLock()
+	f := runnerTokensFile{Current: t.current, Tokens: make([]runnerTokensFileEntry, 0, len(t.tokens))}
+	for _, rt := range t.tokens {
+		f.Tokens = append(f.Tokens, runnerTokensFileEntry{Token: rt.token, CreatedAt: rt.createdAt})
+	}
+	t.lock.RUnlock()
+	sort.Slice(f.Tokens, func(i, j int) bool {
+		return time.Time(f.Tokens[i].CreatedAt).Before(time.Time(f.Tokens[j].CreatedAt))
+	})
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	// temp files are created readable by their owner only
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// List returns the tokens of the node, without the tokens themselves, oldest first
+func (t *RunnerTokens) List() []RunnerToken {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	list := make([]RunnerToken, 0, len(t.tokens))
+	for id, rt := range t.tokens {
+		list = append(list, RunnerToken{ID: id, CreatedAt: rt.createdAt, Current: id == t.current})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return time.Time(list[i].CreatedAt).Before(time.Time(list[j].CreatedAt))
+	})
+	return list
+}
+
+// Current returns the token the node presents, or "" if it has none
+func (t *RunnerTokens) Current() string {
+	if t == nil {
+		return ""
+	}
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if rt, ok := t.tokens[t.current]; ok {
+		return rt.token
+	}
+	return ""
+}
+
+// Enabled returns whether the node authenticates its peers, once it has had tokens
+func (t *RunnerTokens) Enabled() bool {
+	if t == nil {
+		return false
+	}
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.enabled
+}
+
+// Valid returns whether token is one of the tokens of the node, or the node does not authenticate its peers
+func (t *RunnerTokens) Valid(token string) bool {
+	if !t.Enabled() {
+		return true
+	}
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	rt, ok := t.tokens[runnerTokenID(token)]
+	return ok && subtle.ConstantTimeCompare([]byte(rt.token), []byte(token)) == 1
+}
+
+func (t *RunnerTokens) validMetadata(md metadata.MD) bool {
+	var token string
+	if v := md.Get(RunnerTokenKey); len(v) > 0 {
+		token = v[0]
+	}
+	return t.Valid(token)
+}
+
+// RunnerDialOptions make an lb present the runner tokens to its runners, and only engage runners presenting one of
+// them back, before any call is sent to them. With keys, the payloads of calls are encrypted as well. Either may be
+// nil.
+func RunnerDialOptions(tokens *RunnerTokens, keys KeyProvider) []grpc.DialOption {
+	var opts []grpc.DialOption
+	var interceptors []grpc.StreamClientInterceptor
+	if tokens != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(&runnerTokenCredentials{tokens: tokens}))
+		interceptors = append(interceptors, tokens.streamClientInterceptor)
+	}
+	if keys != nil {
+		interceptors = append(interceptors, payloadClientInterceptor(keys))
+	}
+	if len(interceptors) > 0 {
+		opts = append(opts, grpc.WithStreamInterceptor(grpc_middleware.ChainStreamClient(interceptors...)))
+	}
+	return opts
+}
+
+// streamClientInterceptor checks the runner token a runner sends back in the header of a stream
+func (t *RunnerTokens) streamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil || !t.Enabled() {
+		return stream, err
+	}
+	md, err := stream.Header()
+	if err != nil {
+		return nil, err
+	}
+	if !t.validMetadata(md) {
+		common.Logger(ctx).WithField("runner_addr", cc.Target()).Warn("Runner presented a missing or invalid runner token")
+		return nil, ErrRunnerUnauthorized
+	}
+	return stream, nil
+}
+
+// runnerTokenCredentials implements credentials.PerRPCCredentials
+type runnerTokenCredentials struct {
+	tokens *RunnerTokens
+}
+
+func (c *runnerTokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	if token := c.tokens.Current(); token != "" {
+		return map[string]string{RunnerTokenKey: token}, nil
+	}
+	return nil, nil
+}
+
+func (c *runnerTokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// PureRunnerWithRunnerTokens makes a pure runner only serve lbs presenting one of the runner tokens, presenting its
+// current token back to them
+func PureRunnerWithRunnerTokens(tokens *RunnerTokens) PureRunnerOption {
+	return func(pr *pureRunner) error {
+		if pr.tokens != nil {
+			return errors.New("Failed to create pure runner: runner tokens already set")
+		}
+		pr.tokens = tokens
+		return nil
+	}
+}
+
+func (pr *pureRunner) runnerTokenStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if !pr.tokens.validMetadata(md) {
+		logrus.WithField("method", info.FullMethod).Warn("Rejected stream with a missing or invalid runner token")
+		return ErrRunnerUnauthorized
+	}
+	if token := pr.tokens.Current(); token != "" {
+		if err := stream.SendHeader(metadata.Pairs(RunnerTokenKey, token)); err != nil {
+			return err
+		}
+	}
+	return handler(srv, stream)
+}
+
+func (pr *pureRunner) runnerTokenUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if !pr.tokens.validMetadata(md) {
+		logrus.WithField("method", info.FullMethod).Warn("Rejected call with a missing or invalid runner token")
+		return nil, ErrRunnerUnauthorized
+	}
+	return handler(ctx, req)
+}
@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRunnerTokens(t *testing.T) {
+	var disabled *RunnerTokens
+	if !disabled.Valid("") || disabled.Current() != "" {
+		t.Fatal("expected a node without tokens to accept any peer")
+	}
+
+	if NewRunnerTokens("a", "b").Current() != "b" {
+		t.Fatal("expected the last token to be presented")
+	}
+	tokens := NewRunnerTokens("old")
+	if tokens.Valid("") || tokens.Valid("rogue") || !tokens.Valid("old") {
+		t.Fatal("expected only the old token to be valid")
+	}
+
+	issued, err := tokens.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.Current() != "old" || !tokens.Valid(issued.Token) || issued.Current {
+		t.Fatal("expected the issued token to be accepted, but not presented until it is promoted")
+	}
+	if !tokens.Promote(issued.ID) || tokens.Promote("missing") {
+		t.Fatal("expected only the issued token to be promoted")
+	}
+	if tokens.Current() != issued.Token || !tokens.Valid("old") {
+		t.Fatal("expected the issued token to be presented, and the old one still accepted while rotating")
+	}
+	for _, token := range tokens.List() {
+		if token.Token != "" {
+			t.Fatal("expected listed tokens to not reveal the tokens")
+		}
+		if token.Current != (token.ID == issued.ID) {
+			t.Fatalf("unexpected current token %+v", token)
+		}
+	}
+
+	if tokens.Revoke(runnerTokenID("old")) != nil || tokens.Valid("old") || tokens.Revoke(runnerTokenID("old")) != ErrRunnerTokenUnknown {
+		t.Fatal("expected the old token to be revoked once")
+	}
+	for _, token := range tokens.List() {
+		tokens.Revoke(token.ID)
+	}
+	if tokens.Revoke(issued.ID) != ErrRunnerTokenLast || tokens.Current() != issued.Token || !tokens.Enabled() {
+		t.Fatal("expected the last token to be kept")
+	}
+	if tokens.Valid("") || tokens.Valid("old") {
+		t.Fatal("expected peers to be authenticated once all tokens but the last are revoked")
+	}
+
+	// nodes that had tokens keep authenticating their peers, whatever happens to their tokens
+	tokens.tokens = make(map[string]*runnerToken)
+	if tokens.Valid("") || !tokens.Enabled() {
+		t.Fatal("expected a node that had tokens to keep authenticating its peers")
+	}
+}
+
+func TestRunnerTokensSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runner_tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tokens.json")
+
+	if err := NewRunnerTokens().Load(path); err != nil {
+		t.Fatalf("expected a missing file to load no tokens, got %v", err)
+	}
+
+	tokens := NewRunnerTokens("old")
+	issued, err := tokens.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens.Promote(issued.ID)
+	tokens.Add("peer")
+	if err := tokens.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected the tokens to be saved readable by their owner only, got %v %v", fi.Mode(), err)
+	}
+
+	// the presented token is restored over the ones the node starts with
+	loaded := NewRunnerTokens("boot")
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Current() != issued.Token {
+		t.Fatalf("expected the issued token to be presented again, got %q", loaded.Current())
+	}
+	for _, token := range []string{"old", "peer", "boot", issued.Token} {
+		if !loaded.Valid(token) {
+			t.Fatalf("expected %s to be accepted once loaded", token)
+		}
+	}
+	if len(loaded.List()) != 4 {
+		t.Fatalf("expected 4 tokens, got %+v", loaded.List())
+	}
+
+	if err := ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewRunnerTokens().Load(path); err == nil {
+		t.Fatal("expected a corrupt file to fail to load")
+	}
+}
+
+func TestRunnerTokenCredentials(t *testing.T) {
+	tokens := NewRunnerTokens("secret")
+	md, err := (&runnerTokenCredentials{tokens: tokens}).GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tokens.validMetadata(metadata.New(md)) {
+		t.Fatalf("expected the presented metadata %v to be valid", md)
+	}
+	if tokens.validMetadata(metadata.Pairs(RunnerTokenKey, "rogue")) || tokens.validMetadata(nil) {
+		t.Fatal("expected rogue metadata to be invalid")
+	}
+}
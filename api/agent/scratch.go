@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/api/scratch"
+)
+
+// The env vars the agent sets in the containers of fns with scratch space, see models.FnScratchAnnotation, along with
+// the credentials to it, as the clients of the object store read them, eg. AWS_ACCESS_KEY_ID
+const (
+	// FnEnvScratchURL locates the scratch space of the app, eg. s3://bucket/prefix/app_id/
+	FnEnvScratchURL = "FN_SCRATCH_URL"
+	// FnEnvScratchEndpoint is the API endpoint of the object store
+	FnEnvScratchEndpoint = "FN_SCRATCH_ENDPOINT"
+	// FnEnvScratchRegion is the region of the object store, if it has regions
+	FnEnvScratchRegion = "FN_SCRATCH_REGION"
+	// FnEnvScratchBucket is the bucket of the scratch space
+	FnEnvScratchBucket = "FN_SCRATCH_BUCKET"
+	// FnEnvScratchPrefix is the prefix of the objects of the scratch space, ending in /
+	FnEnvScratchPrefix = "FN_SCRATCH_PREFIX"
+	// FnEnvScratchExpires is when the credentials expire, in RFC3339
+	FnEnvScratchExpires = "FN_SCRATCH_EXPIRES"
+)
+
+// scratchRetireMargin is how long before its credentials expire, on top of the timeout of its calls, a container stops
+// taking calls
+const scratchRetireMargin = time.Minute
+
+// isScratchEnv returns whether k is an env var of scratch space
+func isScratchEnv(k string) bool {
+	switch k {
+	case FnEnvScratchURL, FnEnvScratchEndpoint, FnEnvScratchRegion, FnEnvScratchBucket, FnEnvScratchPrefix, FnEnvScratchExpires:
+		return true
+	}
+	return false
+}
+
+// scratchGrants issues the credentials to the scratch space of apps for their containers. Credentials are shared by the
+// containers of an app while they are valid long enough, so that the store is not asked for each container.
+type scratchGrants struct {
+	store scratch.Store
+	ttl   time.Duration
+
+	lock   sync.Mutex
+	grants map[string]*scratch.Grant
+}
+
+// newScratchGrants returns the scratchGrants of store, or nil if there is none
+func newScratchGrants(cfg *Config, store scratch.Store) *scratchGrants {
+	if store == nil {
+		return nil
+	}
+	return &scratchGrants{store: store, ttl: cfg.ScratchTTL, grants: make(map[string]*scratch.Grant)}
+}
+
+// get returns credentials of app appID valid for at least minValid, renewing them once past half their ttl
+func (g *scratchGrants) get(ctx context.Context, appID string, minValid time.Duration) (*scratch.Grant, error) {
+	reuse := minValid
+	if reuse < g.ttl/2 {
+		reuse = g.ttl / 2
+	}
+	g.lock.Lock()
+	grant, ok := g.grants[appID]
+	g.lock.Unlock()
+	if ok && time.Until(grant.Expires) >= reuse {
+		return grant, nil
+	}
+
+	ttl := g.ttl
+	if ttl < 2*minValid {
+		ttl = 2 * minValid
+	}
+	grant, err := g.store.Grant(ctx, appID, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if time.Until(grant.Expires) < minValid {
+		common.Logger(ctx).WithField("expires", grant.Expires).Warn("Scratch credentials expire before the calls of the fn may time out")
+	}
+	g.lock.Lock()
+	g.grants[appID] = grant
+	g.lock.Unlock()
+	return grant, nil
+}
+
+// grant sets the scratch space env vars of the container of call, if its fn has scratch space, and when the
+// container is to stop taking calls for its credentials not to expire during one
+func (g *scratchGrants) grant(ctx context.Context, call *call, container *container) error {
+	enabled, err := call.Annotations.Scratch()
+	if err != nil || !enabled {
+		return err
+	}
+	if g == nil {
+		common.Logger(ctx).Error("fn has scratch space, but no scratch store is configured on this runner")
+		return models.ErrScratchUnavailable
+	}
+
+	timeout := time.Duration(call.Timeout) * time.Second
+	grant, err := g.get(ctx, call.AppID, timeout+2*scratchRetireMargin)
+	if err != nil {
+		common.Logger(ctx).WithError(err).Error("failed to issue scratch credentials")
+		return models.ErrScratchUnavailable
+	}
+
+	env := container.env
+	env[FnEnvScratchURL] = grant.URL
+	env[FnEnvScratchEndpoint] = grant.Endpoint
+	env[FnEnvScratchBucket] = grant.Bucket
+	env[FnEnvScratchPrefix] = grant.Prefix
+	env[FnEnvScratchExpires] = grant.Expires.UTC().Format(time.RFC3339)
+	if grant.Region != "" {
+		env[FnEnvScratchRegion] = grant.Region
+	}
+	for k, v := range grant.Env {
+		env[k] = v
+	}
+	container.retireAt = grant.Expires.Add(-timeout - scratchRetireMargin)
+	return nil
+}
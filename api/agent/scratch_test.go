@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/api/scratch"
+)
+
+type countingScratchStore struct {
+	grants  int
+	expires time.Duration
+}
+
+func (s *countingScratchStore) Grant(ctx context.Context, appID string, ttl time.Duration) (*scratch.Grant, error) {
+	s.grants++
+	return &scratch.Grant{
+		URL:     "s3://scratch/" + appID + "/",
+		Bucket:  "scratch",
+		Prefix:  appID + "/",
+		Env:     map[string]string{"AWS_ACCESS_KEY_ID": "AKID"},
+		Expires: time.Now().Add(s.expires),
+	}, nil
+}
+
+func TestScratchGrants(t *testing.T) {
+	ctx := context.Background()
+	scratchCall := func(appID string, annotations models.Annotations) *call {
+		return &call{Call: &models.Call{AppID: appID, Timeout: 30, Annotations: annotations}}
+	}
+	enabled, _ := models.Annotations{}.With(models.FnScratchAnnotation, true)
+
+	// fns without scratch space need no store
+	var none *scratchGrants
+	c := &container{env: map[string]string{}}
+	if err := none.grant(ctx, scratchCall("app", nil), c); err != nil || len(c.env) != 0 || !c.retireAt.IsZero() {
+		t.Fatalf("expected nothing granted without the annotation, got %v %v", err, c.env)
+	}
+	if err := none.grant(ctx, scratchCall("app", enabled), c); err != models.ErrScratchUnavailable {
+		t.Fatalf("expected scratch to be unavailable without a store, got %v", err)
+	}
+
+	store := &countingScratchStore{expires: time.Hour}
+	g := newScratchGrants(&Config{ScratchTTL: time.Hour}, store)
+	for i := 0; i < 2; i++ {
+		c := &container{env: map[string]string{}}
+		if err := g.grant(ctx, scratchCall("app", enabled), c); err != nil {
+			t.Fatal(err)
+		}
+		if c.env[FnEnvScratchURL] != "s3://scratch/app/" || c.env[FnEnvScratchPrefix] != "app/" || c.env["AWS_ACCESS_KEY_ID"] != "AKID" {
+			t.Fatalf("unexpected scratch env %v", c.env)
+		}
+		if d := time.Until(c.retireAt); d < 57*time.Minute || d > time.Hour-30*time.Second-scratchRetireMargin {
+			t.Fatalf("expected the container to retire ahead of the expiry by its timeout, in %v", d)
+		}
+	}
+	if store.grants != 1 {
+		t.Fatalf("expected the credentials of the app to be reused, got %d grants", store.grants)
+	}
+	g.grant(ctx, scratchCall("other", enabled), &container{env: map[string]string{}})
+	if store.grants != 2 {
+		t.Fatalf("expected credentials per app, got %d grants", store.grants)
+	}
+
+	// credentials past half their ttl are renewed
+	store = &countingScratchStore{expires: 20 * time.Minute}
+	g = newScratchGrants(&Config{ScratchTTL: time.Hour}, store)
+	g.grant(ctx, scratchCall("app", enabled), &container{env: map[string]string{}})
+	g.grant(ctx, scratchCall("app", enabled), &container{env: map[string]string{}})
+	if store.grants != 2 {
+		t.Fatalf("expected short lived credentials to be renewed, got %d grants", store.grants)
+	}
+}
+
+func TestScratchEnvReserved(t *testing.T) {
+	for _, k := range []string{FnEnvScratchURL, FnEnvScratchPrefix, FnEnvScratchExpires} {
+		if !isReservedConfigKey(k) {
+			t.Errorf("expected %s to be reserved", k)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestScriptedDriver(t *testing.T) {
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	script := &mock.Script{Images: map[string]*mock.ImageScript{
+		"fnproject/fn-test-utils": {
+			Status:       http.StatusCreated,
+			Headers:      map[string]string{"X-Scripted": "yes"},
+			LatencyMsecs: 10,
+			CallSequence: []string{mock.StepOK, mock.StepFail},
+		},
+	}}
+	a := New(WithConfig(cfg), WithDockerDriver(mock.NewScripted(script)))
+	defer checkClose(t, a)
+
+	run := func() (*httptest.ResponseRecorder, error) {
+		cm := createModelCall("TestScriptedDriver")
+		w := httptest.NewRecorder()
+		callI, err := a.GetCall(FromModelAndInput(cm, ioutil.NopCloser(strings.NewReader("hello"))), WithWriter(w))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w, a.Submit(callI)
+	}
+
+	w, err := run()
+	if err != nil {
+		t.Fatalf("expected the first call to succeed, got %v", err)
+	}
+	if w.Body.String() != "hello" || w.Header().Get("X-Scripted") != "yes" || w.Header().Get("Fn-Http-Status") != "201" {
+		t.Fatalf("expected the input echoed with the scripted headers, got %q %v", w.Body.String(), w.Header())
+	}
+
+	// the second call of the sequence fails, as a fn does
+	if _, err := run(); err != models.ErrFunctionFailed {
+		t.Fatalf("expected the second call to fail with %v, got %v", models.ErrFunctionFailed, err)
+	}
+}
+
+func TestScriptedDriverCrash(t *testing.T) {
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	script := &mock.Script{Images: map[string]*mock.ImageScript{
+		mock.ScriptDefault: {CallSequence: []string{mock.StepCrash}, ExitCode: 3},
+	}}
+	a := New(WithConfig(cfg), WithDockerDriver(mock.NewScripted(script)))
+	defer checkClose(t, a)
+
+	cm := createModelCall("TestScriptedDriverCrash")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	callI, err := a.GetCall(FromModel(cm), WithContext(ctx), WithWriter(httptest.NewRecorder()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Submit(callI); err != models.ErrFunctionResponse {
+		t.Fatalf("expected the crash to fail the call with %v, got %v", models.ErrFunctionResponse, err)
+	}
+}
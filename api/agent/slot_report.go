@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"sort"
+	"time"
+)
+
+// maxSlotWaits is how many of the most recent slot wait times a slot queue keeps for its percentiles
+const maxSlotWaits = 256
+
+// SlotQueueReport describes the hot containers of a fn on an agent, and how long its calls recently waited for one
+type SlotQueueReport struct {
+	AppID string `json:"app_id"`
+	FnID  string `json:"fn_id"`
+	Image string `json:"image"`
+	// Starting counts the containers waiting for resources or starting
+	Starting uint64 `json:"starting"`
+	Idle     uint64 `json:"idle"`
+	Paused   uint64 `json:"paused"`
+	Busy     uint64 `json:"busy"`
+	// Draining counts the containers shutting down
+	Draining uint64 `json:"draining"`
+	// Waiting counts the calls waiting for a container
+	Waiting uint64 `json:"waiting"`
+	// WaitP50, WaitP90 and WaitP99 are percentiles of the recent times calls waited for a container, in
+	// milliseconds
+	WaitP50 int64 `json:"wait_p50_msecs"`
+	WaitP90 int64 `json:"wait_p90_msecs"`
+	WaitP99 int64 `json:"wait_p99_msecs"`
+}
+
+// SlotReporter is implemented by agents that can report the state of their hot containers.
+type SlotReporter interface {
+	// SlotQueues returns a report per fn with hot containers or calls waiting for one
+	SlotQueues() []SlotQueueReport
+}
+
+var _ SlotReporter = new(agent)
+var _ SlotReporter = new(pureRunner)
+
+// SlotQueues implements SlotReporter
+func (a *agent) SlotQueues() []SlotQueueReport {
+	queues := a.slotMgr.getSlotQueues()
+	reports := make([]SlotQueueReport, 0, len(queues))
+	for _, slots := range queues {
+		reports = append(reports, slots.report())
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].FnID != reports[j].FnID {
+			return reports[i].FnID < reports[j].FnID
+		}
+		return reports[i].Image < reports[j].Image
+	})
+	return reports
+}
+
+// SlotQueues implements SlotReporter
+func (pr *pureRunner) SlotQueues() []SlotQueueReport {
+	if r, ok := pr.a.(SlotReporter); ok {
+		return r.SlotQueues()
+	}
+	return nil
+}
+
+func (a *slotQueue) report() SlotQueueReport {
+	a.statsLock.Lock()
+	defer a.statsLock.Unlock()
+
+	r := SlotQueueReport{
+		AppID:    a.appID,
+		FnID:     a.fnID,
+		Image:    a.image,
+		Starting: a.stats.containerStates[ContainerStateWait] + a.stats.containerStates[ContainerStateStart],
+		Idle:     a.stats.containerStates[ContainerStateIdle],
+		Paused:   a.stats.containerStates[ContainerStatePaused],
+		Busy:     a.stats.containerStates[ContainerStateBusy],
+		Draining: a.draining,
+		Waiting:  a.stats.requestStates[RequestStateWait],
+	}
+	r.WaitP50, r.WaitP90, r.WaitP99 = a.waits.percentiles()
+	return r
+}
+
+func (a *slotQueue) recordWait(d time.Duration) {
+	a.statsLock.Lock()
+	a.waits.add(d)
+	a.statsLock.Unlock()
+}
+
+func (a *slotQueue) enterDraining() {
+	a.statsLock.Lock()
+	a.draining++
+	a.statsLock.Unlock()
+}
+
+func (a *slotQueue) exitDraining() {
+	a.statsLock.Lock()
+	a.draining--
+	a.statsLock.Unlock()
+}
+
+// waitTimes is a ring of the last maxSlotWaits wait times
+type waitTimes struct {
+	waits []time.Duration
+	pos   int
+}
+
+func (w *waitTimes) add(d time.Duration) {
+	if len(w.waits) < maxSlotWaits {
+		w.waits = append(w.waits, d)
+		return
+	}
+	w.waits[w.pos] = d
+	w.pos = (w.pos + 1) % maxSlotWaits
+}
+
+// percentiles returns the 50th, 90th and 99th percentiles of the wait times in milliseconds
+func (w *waitTimes) percentiles() (p50, p90, p99 int64) {
+	if len(w.waits) == 0 {
+		return 0, 0, 0
+	}
+	sorted := make([]time.Duration, len(w.waits))
+	copy(sorted, w.waits)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	at := func(p int) int64 {
+		return int64(sorted[(len(sorted)-1)*p/100] / time.Millisecond)
+	}
+	return at(50), at(90), at(99)
+}
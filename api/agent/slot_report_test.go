@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestSlotQueueReport(t *testing.T) {
+	mgr := NewSlotQueueMgr()
+	call := &call{Call: &models.Call{AppID: "app", FnID: "fn", Image: "fnproject/hello"}, slotHashId: "hash"}
+
+	slots, isNew := mgr.getSlotQueue(call)
+	if !isNew {
+		t.Fatal("expected a new slot queue")
+	}
+
+	slots.enterContainerState(ContainerStateStart)
+	slots.enterContainerState(ContainerStatePaused)
+	slots.enterContainerState(ContainerStatePaused)
+	slots.enterContainerState(ContainerStateBusy)
+	slots.enterRequestState(RequestStateWait)
+	slots.enterDraining()
+	for i := 1; i <= 100; i++ {
+		slots.recordWait(time.Duration(i) * time.Millisecond)
+	}
+
+	a := &agent{slotMgr: mgr}
+	reports := a.SlotQueues()
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %+v", reports)
+	}
+	r := reports[0]
+	if r.AppID != "app" || r.FnID != "fn" || r.Image != "fnproject/hello" {
+		t.Errorf("unexpected fn in report %+v", r)
+	}
+	if r.Starting != 1 || r.Idle != 0 || r.Paused != 2 || r.Busy != 1 || r.Draining != 1 || r.Waiting != 1 {
+		t.Errorf("unexpected container counts in report %+v", r)
+	}
+	if r.WaitP50 != 50 || r.WaitP90 != 90 || r.WaitP99 != 99 {
+		t.Errorf("unexpected wait percentiles in report %+v", r)
+	}
+}
+
+func TestWaitTimesWrap(t *testing.T) {
+	var w waitTimes
+	for i := 0; i < maxSlotWaits; i++ {
+		w.add(time.Second)
+	}
+	for i := 0; i < maxSlotWaits; i++ {
+		w.add(time.Millisecond)
+	}
+	if p50, p90, p99 := w.percentiles(); p50 != 1 || p90 != 1 || p99 != 1 {
+		t.Errorf("expected only the latest wait times to be kept, got %d %d %d", p50, p90, p99)
+	}
+}
@@ -57,8 +57,18 @@ type slotQueue struct {
 	slots     []*slotToken
 	nextId    uint64
 	signaller chan *slotCaller
+	// unfreezer wakes a paused container ahead of the calls that will need it
+	unfreezer chan struct{}
 	statsLock sync.Mutex // protects stats below
 	stats     slotQueueStats
+	// fn the queue is for, set once
+	appID string
+	fnID  string
+	image string
+	// containers shutting down
+	draining uint64
+	// recent times requests waited for a slot
+	waits waitTimes
 
 	authLock  sync.Mutex
 	authToken string
@@ -77,6 +87,7 @@ func NewSlotQueue(key string) *slotQueue {
 		cond:      sync.NewCond(new(sync.Mutex)),
 		slots:     make([]*slotToken, 0),
 		signaller: make(chan *slotCaller, 1),
+		unfreezer: make(chan struct{}),
 	}
 
 	return obj
@@ -160,6 +171,25 @@ func (a *slotQueue) queueSlot(slot Slot) *slotToken {
 	return token
 }
 
+// unfreezeAhead wakes one paused container if there is no idle container left to pick up the next call, so that
+// the next call does not have to wait for it to be unpaused. It returns false if no paused container took it.
+func (a *slotQueue) unfreezeAhead() bool {
+	a.statsLock.Lock()
+	needed := a.stats.containerStates[ContainerStateIdle] == 0 && a.stats.containerStates[ContainerStatePaused] > 0
+	a.statsLock.Unlock()
+
+	if !needed {
+		return false
+	}
+
+	select {
+	case a.unfreezer <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
 // isIdle() returns true is there's no activity for this slot queue. This
 // means no one is waiting, running or starting.
 func (a *slotQueue) isIdle() bool {
@@ -267,19 +297,31 @@ func (a *slotQueue) getAuthToken() string {
 
 // getSlot must ensure that if it receives a slot, it will be returned, otherwise
 // a container will be locked up forever waiting for slot to free.
-func (a *slotQueueMgr) getSlotQueue(key string) (*slotQueue, bool) {
+func (a *slotQueueMgr) getSlotQueue(call *call) (*slotQueue, bool) {
 
 	a.hMu.Lock()
-	slots, ok := a.hot[key]
+	slots, ok := a.hot[call.slotHashId]
 	if !ok {
-		slots = NewSlotQueue(key)
-		a.hot[key] = slots
+		slots = NewSlotQueue(call.slotHashId)
+		slots.appID, slots.fnID, slots.image = call.AppID, call.FnID, call.Image
+		a.hot[call.slotHashId] = slots
 	}
 	a.hMu.Unlock()
 
 	return slots, !ok
 }
 
+// getSlotQueues returns all the slot queues
+func (a *slotQueueMgr) getSlotQueues() []*slotQueue {
+	a.hMu.Lock()
+	queues := make([]*slotQueue, 0, len(a.hot))
+	for _, slots := range a.hot {
+		queues = append(queues, slots)
+	}
+	a.hMu.Unlock()
+	return queues
+}
+
 // currently unused. But at some point, we need to age/delete old
 // slotQueues.
 func (a *slotQueueMgr) deleteSlotQueue(slots *slotQueue) bool {
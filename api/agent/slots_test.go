@@ -308,3 +308,41 @@ func BenchmarkSlotKey(b *testing.B) {
 		_ = getSlotQueueKey(call, "")
 	}
 }
+
+func TestSlotQueueUnfreezeAhead(t *testing.T) {
+	obj := NewSlotQueue("test-unfreeze")
+
+	// nobody paused
+	if obj.unfreezeAhead() {
+		t.Fatalf("should not unfreeze without paused containers")
+	}
+
+	obj.enterContainerState(ContainerStatePaused)
+
+	woken := make(chan struct{})
+	go func() {
+		<-obj.unfreezer
+		close(woken)
+	}()
+
+	// an idle container picks up the next call itself
+	obj.enterContainerState(ContainerStateIdle)
+	if obj.unfreezeAhead() {
+		t.Fatalf("should not unfreeze with an idle container")
+	}
+	obj.exitContainerState(ContainerStateIdle)
+
+	deadline := time.Now().Add(time.Second)
+	for !obj.unfreezeAhead() {
+		if time.Now().After(deadline) {
+			t.Fatalf("paused container was not unfrozen")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case <-woken:
+	case <-time.After(time.Second):
+		t.Fatalf("paused container did not get the unfreeze")
+	}
+}
@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// requestSpool keeps request bodies that are too large to buffer in memory in temp files, within a disk budget
+// for each call and for all calls together. A zero budget is unlimited.
+type requestSpool struct {
+	dir       string
+	threshold uint64
+	maxCall   uint64
+	maxTotal  uint64
+	used      uint64 // atomic
+}
+
+func newRequestSpool(cfg *Config) *requestSpool {
+	if cfg.RequestSpoolThreshold == 0 {
+		return nil
+	}
+	return &requestSpool{
+		dir:       cfg.RequestSpoolDir,
+		threshold: cfg.RequestSpoolThreshold,
+		maxCall:   cfg.MaxRequestSpoolSize,
+		maxTotal:  cfg.MaxTotalRequestSpoolSize,
+	}
+}
+
+// admit returns the error spooling a body of size bytes would fail with now, if it would. Bodies of unknown size,
+// -1, and bodies small enough to be kept in memory are admitted, as is everything by a nil spool.
+func (s *requestSpool) admit(size int64) error {
+	if s == nil || size < 0 || uint64(size) <= s.threshold {
+		return nil
+	}
+	if s.maxCall > 0 && uint64(size) > s.maxCall {
+		return models.ErrRequestContentTooBig
+	}
+	if s.maxTotal > 0 && atomic.LoadUint64(&s.used)+uint64(size) > s.maxTotal {
+		return models.ErrCallTimeoutServerBusy
+	}
+	return nil
+}
+
+// spooledBody is a request body in a temp file, it can be read concurrently from the start any number of times
+type spooledBody struct {
+	spool *requestSpool
+	f     *os.File
+	size  int64
+}
+
+// reader returns a new reader of the whole body
+func (b *spooledBody) reader() io.ReadCloser {
+	return ioutil.NopCloser(io.NewSectionReader(b.f, 0, b.size))
+}
+
+// Close removes the temp file and returns its space to the budget
+func (b *spooledBody) Close() error {
+	b.f.Close()
+	atomic.AddUint64(&b.spool.used, ^uint64(b.size-1))
+	return os.Remove(b.f.Name())
+}
+
+// spool writes head, the part of the body already read into memory, and the rest of body to a temp file
+func (s *requestSpool) spool(head *bytes.Buffer, body io.Reader) (*spooledBody, error) {
+	f, err := ioutil.TempFile(s.dir, "fn-request-")
+	if err != nil {
+		return nil, err
+	}
+	b := &spooledBody{spool: s, f: f}
+	_, err = io.Copy(&spoolWriter{b: b}, io.MultiReader(head, body))
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// spoolWriter writes to a spooledBody, reserving budget before each write
+type spoolWriter struct {
+	b *spooledBody
+}
+
+func (w *spoolWriter) Write(p []byte) (int, error) {
+	s, n := w.b.spool, uint64(len(p))
+	if s.maxCall > 0 && uint64(w.b.size)+n > s.maxCall {
+		return 0, models.ErrRequestContentTooBig
+	}
+	if used := atomic.AddUint64(&s.used, n); s.maxTotal > 0 && used > s.maxTotal {
+		atomic.AddUint64(&s.used, ^uint64(n-1))
+		return 0, models.ErrCallTimeoutServerBusy
+	}
+	m, err := w.b.f.Write(p)
+	// give back the reservation for what was not written, Close gives back the rest
+	atomic.AddUint64(&s.used, ^uint64(n-uint64(m)-1))
+	w.b.size += int64(m)
+	return m, err
+}
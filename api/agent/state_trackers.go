@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/fnproject/fn/api/common"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
 )
@@ -91,6 +92,7 @@ func (c *requestState) UpdateState(ctx context.Context, newState RequestStateTyp
 
 	var now time.Time
 	var oldState RequestStateType
+	var before time.Time
 
 	c.lock.Lock()
 
@@ -99,6 +101,7 @@ func (c *requestState) UpdateState(ctx context.Context, newState RequestStateTyp
 
 		now = time.Now()
 		oldState = c.state
+		before = c.start
 		c.state = newState
 		c.start = now
 	}
@@ -113,6 +116,9 @@ func (c *requestState) UpdateState(ctx context.Context, newState RequestStateTyp
 	if slots != nil {
 		slots.enterRequestState(newState)
 		slots.exitRequestState(oldState)
+		if oldState == RequestStateWait {
+			slots.recordWait(now.Sub(before))
+		}
 	}
 }
 
@@ -180,7 +186,7 @@ func (c *containerState) UpdateState(ctx context.Context, newState ContainerStat
 
 	timeKey := containerTimeKeys[oldState]
 	if timeKey != "" {
-		stats.Record(ctx, containerTimeMeasures[oldState].M(int64(now.Sub(before)/time.Millisecond)))
+		common.RecordWithExemplar(ctx, containerTimeMeasures[oldState].M(int64(now.Sub(before)/time.Millisecond)))
 	}
 
 	// update new state stats
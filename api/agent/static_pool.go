@@ -3,6 +3,7 @@ package agent
 import (
 	"context"
 	"crypto/tls"
+	"strings"
 
 	pool "github.com/fnproject/fn/api/runnerpool"
 
@@ -25,13 +26,18 @@ func NewStaticRunnerPool(runnerAddresses []string, tlsConf *tls.Config, dialOpts
 	var runners []pool.Runner
 	dialOpts = append(dialOpts, grpc.WithStatsHandler(new(ocgrpc.ClientHandler)))
 	for _, addr := range runnerAddresses {
+		// an address may be labeled with the tenant pool of the runner, as pool@host:port
+		var tenantPool string
+		if i := strings.Index(addr, "@"); i >= 0 {
+			tenantPool, addr = addr[:i], addr[i+1:]
+		}
 		r, err := NewgRPCRunner(addr, tlsConf, dialOpts...)
 		if err != nil {
 			logrus.WithError(err).WithField("runner_addr", addr).Warn("Invalid runner")
 			continue
 		}
-		logrus.WithField("runner_addr", addr).Debug("Adding runner to pool")
-		runners = append(runners, r)
+		logrus.WithFields(logrus.Fields{"runner_addr": addr, "runner_pool": tenantPool}).Debug("Adding runner to pool")
+		runners = append(runners, pool.WithPool(r, tenantPool))
 	}
 	return &staticRunnerPool{
 		runners: runners,
@@ -46,3 +46,20 @@ func TestEmptyPool(t *testing.T) {
 		t.Fatalf("Unexpected error from shutdown %v", err)
 	}
 }
+
+func TestStaticPoolRunnerPools(t *testing.T) {
+	// TEST-NET-1 unreachable
+	np := setupStaticPool([]string{"192.0.2.255:8080", "acme@192.0.2.255:8081"})
+	defer np.Shutdown(context.Background())
+
+	runners, err := np.Runners(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Failed to list runners %v", err)
+	}
+	if len(runners) != 2 || pool.RunnerPoolOf(runners[0]) != "" || pool.RunnerPoolOf(runners[1]) != "acme" {
+		t.Fatalf("Expected the second runner to be in the acme pool %v", runners)
+	}
+	if runners[1].Address() != "192.0.2.255:8081" {
+		t.Fatalf("Expected the pool label to be stripped from the address, got %s", runners[1].Address())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/fnproject/fn/api/agent/drivers"
 	"github.com/fnproject/fn/api/common"
 
 	"github.com/sirupsen/logrus"
@@ -67,16 +68,28 @@ func statsErrors(ctx context.Context) {
 	stats.Record(ctx, errorsMeasure.M(1))
 }
 
+func statsResultCacheHit(ctx context.Context) {
+	stats.Record(ctx, resultCacheHitsMeasure.M(1))
+}
+
+func statsMirrored(ctx context.Context) {
+	stats.Record(ctx, mirroredMeasure.M(1))
+}
+
+func statsMirrorErrors(ctx context.Context) {
+	stats.Record(ctx, mirrorErrorsMeasure.M(1))
+}
+
 func statsTooBusy(ctx context.Context) {
 	stats.Record(ctx, serverBusyMeasure.M(1))
 }
 
 func statsLBAgentRunnerSchedLatency(ctx context.Context, dur time.Duration) {
-	stats.Record(ctx, runnerSchedLatencyMeasure.M(int64(dur/time.Millisecond)))
+	common.RecordWithExemplar(ctx, runnerSchedLatencyMeasure.M(int64(dur/time.Millisecond)))
 }
 
 func statsLBAgentRunnerExecLatency(ctx context.Context, dur time.Duration) {
-	stats.Record(ctx, runnerExecLatencyMeasure.M(int64(dur/time.Millisecond)))
+	common.RecordWithExemplar(ctx, runnerExecLatencyMeasure.M(int64(dur/time.Millisecond)))
 }
 
 func statsContainerUDSInitLatency(ctx context.Context, start time.Time, end time.Time, containerUDSState string) {
@@ -92,7 +105,7 @@ func statsContainerUDSInitLatency(ctx context.Context, start time.Time, end time
 	}
 
 	dur := end.Sub(start)
-	stats.Record(ctx, containerUDSInitLatencyMeasure.M(int64(dur/time.Millisecond)))
+	common.RecordWithExemplar(ctx, containerUDSInitLatencyMeasure.M(int64(dur/time.Millisecond)))
 }
 
 func statsContainerEvicted(ctx context.Context, containerState string) {
@@ -106,6 +119,14 @@ func statsContainerEvicted(ctx context.Context, containerState string) {
 	stats.Record(ctx, containerEvictedMeasure.M(0))
 }
 
+func statsEagerUnfreeze(ctx context.Context) {
+	stats.Record(ctx, containerEagerUnfreezeMeasure.M(0))
+}
+
+func statsWastedUnfreeze(ctx context.Context) {
+	stats.Record(ctx, containerWastedUnfreezeMeasure.M(0))
+}
+
 func statsUtilization(ctx context.Context, util ResourceUtilization) {
 	stats.Record(ctx, utilCpuUsedMeasure.M(int64(util.CpuUsed)))
 	stats.Record(ctx, utilCpuAvailMeasure.M(int64(util.CpuAvail)))
@@ -113,6 +134,11 @@ func statsUtilization(ctx context.Context, util ResourceUtilization) {
 	stats.Record(ctx, utilMemAvailMeasure.M(int64(util.MemAvail)))
 }
 
+func statsGPUUtilization(ctx context.Context, used, avail int) {
+	stats.Record(ctx, utilGPUUsedMeasure.M(int64(used)))
+	stats.Record(ctx, utilGPUAvailMeasure.M(int64(avail)))
+}
+
 func statsCallLatency(ctx context.Context, dur time.Duration, callStatus string) {
 	ctx, err := tag.New(ctx,
 		tag.Upsert(callStatusKey, callStatus),
@@ -120,7 +146,7 @@ func statsCallLatency(ctx context.Context, dur time.Duration, callStatus string)
 	if err != nil {
 		logrus.Fatal(err)
 	}
-	stats.Record(ctx, callLatencyMeasure.M(int64(dur/time.Millisecond)))
+	common.RecordWithExemplar(ctx, callLatencyMeasure.M(int64(dur/time.Millisecond)))
 }
 
 func statsStatusCall(ctx context.Context, cached, success, network string) {
@@ -160,6 +186,9 @@ const (
 	// timeouts - call timed out
 	// errors - call failed
 	// server_busy - server busy responses (retriable)
+	// result_cache_hits - call skipped, an identical call succeeded within the ttl of its fn result cache
+	// mirrored_calls - calls mirrored to the shadow fn of their fn
+	// mirror_errors - calls mirrored to the shadow fn of their fn that failed
 	//
 	queuedMetricName     = "queued"
 	callsMetricName      = "calls"
@@ -170,13 +199,21 @@ const (
 	errorsMetricName     = "errors"
 	serverBusyMetricName = "server_busy"
 
+	resultCacheHitsMetricName = "result_cache_hits"
+	mirroredMetricName        = "mirrored_calls"
+	mirrorErrorsMetricName    = "mirror_errors"
+
 	containerEvictedMetricName        = "container_evictions"
+	containerEagerUnfreezeMetricName  = "container_eager_unfreezes"
+	containerWastedUnfreezeMetricName = "container_wasted_unfreezes"
 	containerUDSInitLatencyMetricName = "container_uds_init_latency"
 
 	utilCpuUsedMetricName  = "util_cpu_used"
 	utilCpuAvailMetricName = "util_cpu_avail"
 	utilMemUsedMetricName  = "util_mem_used"
 	utilMemAvailMetricName = "util_mem_avail"
+	utilGPUUsedMetricName  = "util_gpu_used"
+	utilGPUAvailMetricName = "util_gpu_avail"
 
 	// Reported By LB
 	runnerSchedLatencyMetricName = "lb_runner_sched_latency"
@@ -196,6 +233,9 @@ var (
 	timedoutMeasure                = common.MakeMeasure(timedoutMetricName, "calls timed out in agent", "")
 	errorsMeasure                  = common.MakeMeasure(errorsMetricName, "calls errored in agent", "")
 	serverBusyMeasure              = common.MakeMeasure(serverBusyMetricName, "calls where server was too busy in agent", "")
+	resultCacheHitsMeasure         = common.MakeMeasure(resultCacheHitsMetricName, "calls skipped as an identical call succeeded in agent", "")
+	mirroredMeasure                = common.MakeMeasure(mirroredMetricName, "calls mirrored to shadow fns in agent", "")
+	mirrorErrorsMeasure            = common.MakeMeasure(mirrorErrorsMetricName, "calls mirrored to shadow fns that failed in agent", "")
 	dockerMeasures                 = initDockerMeasures()
 	containerGaugeMeasures         = initContainerGaugeMeasures()
 	containerTimeMeasures          = initContainerTimeMeasures()
@@ -203,7 +243,11 @@ var (
 	utilCpuAvailMeasure            = common.MakeMeasure(utilCpuAvailMetricName, "agent cpu available", "")
 	utilMemUsedMeasure             = common.MakeMeasure(utilMemUsedMetricName, "agent memory in use", "By")
 	utilMemAvailMeasure            = common.MakeMeasure(utilMemAvailMetricName, "agent memory available", "By")
+	utilGPUUsedMeasure             = common.MakeMeasure(utilGPUUsedMetricName, "agent gpus in use", "")
+	utilGPUAvailMeasure            = common.MakeMeasure(utilGPUAvailMetricName, "agent gpus available", "")
 	containerEvictedMeasure        = common.MakeMeasure(containerEvictedMetricName, "containers evicted", "")
+	containerEagerUnfreezeMeasure  = common.MakeMeasure(containerEagerUnfreezeMetricName, "containers unpaused ahead of calls", "")
+	containerWastedUnfreezeMeasure = common.MakeMeasure(containerWastedUnfreezeMetricName, "containers unpaused ahead of calls that got none", "")
 	containerUDSInitLatencyMeasure = common.MakeMeasure(containerUDSInitLatencyMetricName, "container UDS Init-Wait Latency", "msecs")
 
 	// Reported By LB: How long does a runner scheduler wait for a committed call? eg. wait/launch/pull containers
@@ -247,10 +291,15 @@ func RegisterAgentViews(tagKeys []string, latencyDist []float64) {
 		common.CreateView(timedoutMeasure, view.Sum(), tagKeys),
 		common.CreateView(errorsMeasure, view.Sum(), tagKeys),
 		common.CreateView(serverBusyMeasure, view.Sum(), tagKeys),
+		common.CreateView(resultCacheHitsMeasure, view.Sum(), tagKeys),
+		common.CreateView(mirroredMeasure, view.Sum(), tagKeys),
+		common.CreateView(mirrorErrorsMeasure, view.Sum(), tagKeys),
 		common.CreateView(utilCpuUsedMeasure, view.LastValue(), tagKeys),
 		common.CreateView(utilCpuAvailMeasure, view.LastValue(), tagKeys),
 		common.CreateView(utilMemUsedMeasure, view.LastValue(), tagKeys),
 		common.CreateView(utilMemAvailMeasure, view.LastValue(), tagKeys),
+		common.CreateView(utilGPUUsedMeasure, view.LastValue(), tagKeys),
+		common.CreateView(utilGPUAvailMeasure, view.LastValue(), tagKeys),
 	)
 
 	if err != nil {
@@ -292,7 +341,8 @@ func RegisterDockerViews(tagKeys []string, latencyDist, ioNetDist, ioDiskDist, m
 		// Remember these are sampled by docker in short intervals (approx 1 sec)
 		if m.Name() == "docker_stats_net_rx" || m.Name() == "docker_stats_net_tx" {
 			dist = view.Distribution(ioNetDist...)
-		} else if m.Name() == "docker_stats_disk_read" || m.Name() == "docker_stats_disk_write" {
+		} else if m.Name() == "docker_stats_disk_read" || m.Name() == "docker_stats_disk_write" ||
+			m.Name() == "docker_stats_fs_usage" || m.Name() == "docker_stats_tmpfs_usage" || m.Name() == "docker_stats_tmpfs_inodes" {
 			dist = view.Distribution(ioDiskDist...)
 		} else if m.Name() == "docker_stats_mem_limit" || m.Name() == "docker_stats_mem_usage" {
 			dist = view.Distribution(memoryDist...)
@@ -355,6 +405,8 @@ func RegisterContainerViews(tagKeys []string, latencyDist []float64) {
 	err := view.Register(
 		common.CreateView(containerEvictedMeasure, view.Count(), evictTags),
 		common.CreateView(containerUDSInitLatencyMeasure, view.Distribution(latencyDist...), udsInitTags),
+		common.CreateView(containerEagerUnfreezeMeasure, view.Count(), tagKeys),
+		common.CreateView(containerWastedUnfreezeMeasure, view.Count(), tagKeys),
 	)
 	if err != nil {
 		logrus.WithError(err).Fatal("cannot register view")
@@ -364,12 +416,15 @@ func RegisterContainerViews(tagKeys []string, latencyDist []float64) {
 // initDockerMeasures initializes Docker related measures
 func initDockerMeasures() map[string]*stats.Int64Measure {
 	// TODO this is nasty figure out how to use opencensus to not have to declare these
-	keys := []string{"net_rx", "net_tx", "mem_limit", "mem_usage", "disk_read", "disk_write", "cpu_user", "cpu_total", "cpu_kernel"}
+	keys := []string{"net_rx", "net_tx", "mem_limit", "mem_usage", "disk_read", "disk_write", "cpu_user", "cpu_total", "cpu_kernel",
+		drivers.StatFsUsage, drivers.StatTmpFsUsage, drivers.StatTmpFsInodes}
 	measures := make(map[string]*stats.Int64Measure, len(keys))
 	for _, key := range keys {
 		units := "bytes"
 		if strings.Contains(key, "cpu") {
 			units = "cpu"
+		} else if strings.Contains(key, "inodes") {
+			units = "inodes"
 		}
 		measures[key] = common.MakeMeasure("docker_stats_"+key, "docker container stats for "+key, units)
 	}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/models"
+)
+
+// DefaultSyslogTags are the syslog tags of containers unless EnvSyslogTags is set
+const DefaultSyslogTags = "app_id={{.AppID}},fn_id={{.FnID}}"
+
+// parseSyslogTags parses a comma separated list of name=value syslog tags, where values may be templates
+func parseSyslogTags(tags string) ([]drivers.LoggerTag, error) {
+	var parsed []drivers.LoggerTag
+	for _, pair := range strings.Split(tags, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid syslog tag %q, expected name=value", pair)
+		}
+		parsed = append(parsed, drivers.LoggerTag{Name: strings.TrimSpace(kv[0]), Value: strings.TrimSpace(kv[1])})
+	}
+	return parsed, nil
+}
+
+// loggerConfig returns where the containers of a call send their logs. The syslog url of the app, or else the
+// runner's, and the runner's syslog tags are rendered as templates of the call, e.g. `{{.AppName}}`.
+func loggerConfig(ctx context.Context, c *call, cfg *Config) (drivers.LoggerConfig, error) {
+	url := strings.TrimSpace(c.SyslogURL)
+	if url == "" {
+		url = cfg.SyslogURL
+	}
+	if url == "" {
+		return drivers.LoggerConfig{}, nil
+	}
+
+	templates := newCallTemplates(ctx, c)
+	url, err := templates.render("syslog_url", url)
+	if err != nil {
+		return drivers.LoggerConfig{}, syslogTemplateError("url", err)
+	}
+
+	tags, err := parseSyslogTags(cfg.SyslogTags)
+	if err != nil {
+		return drivers.LoggerConfig{}, err
+	}
+	for i := range tags {
+		tags[i].Value, err = templates.render(tags[i].Name, tags[i].Value)
+		if err != nil {
+			return drivers.LoggerConfig{}, syslogTemplateError("tag "+tags[i].Name, err)
+		}
+	}
+	return drivers.LoggerConfig{URL: strings.TrimSpace(url), Tags: tags}, nil
+}
+
+// syslogTemplateError reports a bad syslog template as the function's fault, as config templates are
+func syslogTemplateError(what string, err error) error {
+	return models.NewFuncError(models.NewAPIError(http.StatusBadGateway,
+		fmt.Errorf("error resolving syslog %s template: %v", what, err)))
+}
@@ -0,0 +1,217 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/sirupsen/logrus"
+)
+
+// volumeGCInterval is how often volumes are checked for inactivity and size
+const volumeGCInterval = time.Minute
+
+// volumeManager keeps the persistent scratch volumes requested by fns with the FnVolumeAnnotation, in a directory
+// for each app and volume name under root. Volumes are created when a container first needs them, and removed
+// once no container has used them for the idle timeout. Volumes are bind mounts of plain directories, so their size is
+// a cleanup threshold rather than a limit: containers may write past it, and volumes found over it are emptied once
+// no container uses them.
+type volumeManager struct {
+	root         string
+	dockerRoot   string
+	maxSize      uint64
+	idleTimeout  time.Duration
+	unprivileged bool
+
+	lock    sync.Mutex
+	volumes map[string]*scratchVolume
+}
+
+// scratchVolume is a volume on disk, and the containers using it
+type scratchVolume struct {
+	path     string
+	size     uint64 // bytes, 0 if unknown
+	refs     int
+	lastUsed time.Time
+	over     bool // found over its size while in use, to be emptied once released
+}
+
+// newVolumeManager returns nil if volumes are not configured. Volumes left on disk by a previous run are picked up,
+// as if last used when they were last modified.
+func newVolumeManager(cfg *Config) (*volumeManager, error) {
+	if cfg.VolumesPath == "" {
+		return nil, nil
+	}
+	m := &volumeManager{
+		root:         cfg.VolumesPath,
+		dockerRoot:   cfg.VolumesDockerPath,
+		maxSize:      cfg.MaxVolumeSizeMB * 1024 * 1024,
+		idleTimeout:  cfg.VolumeIdleTimeout,
+		unprivileged: !cfg.DisableUnprivilegedContainers,
+		volumes:      make(map[string]*scratchVolume),
+	}
+	if m.dockerRoot == "" {
+		m.dockerRoot = m.root
+	}
+	if err := os.MkdirAll(m.root, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create volumes dir: %v", err)
+	}
+
+	apps, err := ioutil.ReadDir(m.root)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read volumes dir: %v", err)
+	}
+	for _, app := range apps {
+		if !app.IsDir() {
+			continue
+		}
+		names, err := ioutil.ReadDir(filepath.Join(m.root, app.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read volumes dir: %v", err)
+		}
+		for _, name := range names {
+			key := filepath.Join(app.Name(), name.Name())
+			m.volumes[key] = &scratchVolume{path: filepath.Join(m.root, key), lastUsed: name.ModTime()}
+		}
+	}
+	return m, nil
+}
+
+// acquire returns the mount of the volume the call asks for, if any, creating it if needed. release must be called
+// once the container using the volume is gone.
+func (m *volumeManager) acquire(ctx context.Context, call *call) (mounts [][2]string, release func(), err error) {
+	release = func() {}
+	vol, err := call.Annotations.Volume()
+	if err != nil || vol == nil {
+		return nil, release, err
+	}
+	if m == nil {
+		common.Logger(ctx).WithField("volume", vol.Name).Warn("fn requested a volume, but volumes are disabled on this runner")
+		return nil, release, nil
+	}
+	size := vol.SizeMB * 1024 * 1024
+	if m.maxSize > 0 && size > m.maxSize {
+		return nil, release, models.ErrFnVolumeTooLarge
+	}
+
+	key := filepath.Join(call.AppID, vol.Name)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	v, ok := m.volumes[key]
+	if !ok {
+		v = &scratchVolume{path: filepath.Join(m.root, key)}
+		if err := os.MkdirAll(v.path, 0755); err != nil {
+			return nil, release, fmt.Errorf("cannot create volume dir: %v", err)
+		}
+		if m.unprivileged {
+			if err := os.Chmod(v.path, 0777); err != nil { // #nosec G302
+				return nil, release, fmt.Errorf("cannot change volume mod: %v", err)
+			}
+		}
+		m.volumes[key] = v
+	}
+	v.size = size
+	v.refs++
+	v.lastUsed = time.Now()
+
+	release = func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		v.refs--
+		v.lastUsed = time.Now()
+		if v.refs == 0 && v.over {
+			m.empty(key, v, logrus.WithField("volume", key))
+		}
+	}
+	return [][2]string{{filepath.Join(m.dockerRoot, key), vol.Path}}, release, nil
+}
+
+// gc removes idle volumes and empties volumes over their size, until done is closed
+func (m *volumeManager) gc(done <-chan struct{}) {
+	if m == nil {
+		return
+	}
+	ticker := time.NewTicker(volumeGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.collect(time.Now())
+		case <-done:
+			return
+		}
+	}
+}
+
+// collect removes the volumes not in use that have been idle for too long, or are over their size. Volumes in use
+// that are over their size are emptied as soon as they are released instead.
+func (m *volumeManager) collect(now time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for key, v := range m.volumes {
+		log := logrus.WithField("volume", key)
+		if v.refs == 0 && now.Sub(v.lastUsed) > m.idleTimeout {
+			if err := os.RemoveAll(v.path); err != nil {
+				log.WithError(err).Error("failed to remove idle volume")
+				continue
+			}
+			delete(m.volumes, key)
+			log.Debug("removed idle volume")
+			continue
+		}
+		if v.size == 0 {
+			continue
+		}
+		used, err := dirSize(v.path)
+		if err != nil {
+			log.WithError(err).Error("failed to size volume")
+			continue
+		}
+		if used <= v.size {
+			continue
+		}
+		log = log.WithFields(logrus.Fields{"used": used, "size": v.size})
+		if v.refs > 0 {
+			if !v.over {
+				log.Warn("volume in use is over its size, it will be emptied once released")
+			}
+			v.over = true
+			continue
+		}
+		m.empty(key, v, log)
+	}
+}
+
+// empty removes volume key over its size, the next container to use it will create it again. m.lock must be held.
+func (m *volumeManager) empty(key string, v *scratchVolume, log logrus.FieldLogger) {
+	if err := os.RemoveAll(v.path); err != nil {
+		log.WithError(err).Error("failed to empty volume")
+		return
+	}
+	delete(m.volumes, key)
+	log.Info("emptied volume over its size")
+}
+
+// dirSize adds up the size of the files under dir
+func dirSize(dir string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size, err
+}
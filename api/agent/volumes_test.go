@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestVolumeManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a volume left behind by a previous run
+	if err := os.MkdirAll(filepath.Join(dir, "old_app", "cache"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := newVolumeManager(&Config{VolumesPath: dir, VolumesDockerPath: "/host/volumes", MaxVolumeSizeMB: 10, VolumeIdleTimeout: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.volumes[filepath.Join("old_app", "cache")]; !ok {
+		t.Fatal("expected existing volume to be picked up")
+	}
+
+	newCall := func(sizeMB int) *call {
+		annotations, err := models.Annotations{}.With(models.FnVolumeAnnotation, map[string]interface{}{"name": "cache", "path": "/cache", "size_mb": sizeMB})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &call{Call: &models.Call{AppID: "app", Annotations: annotations}}
+	}
+	ctx := context.Background()
+
+	if mounts, _, err := m.acquire(ctx, &call{Call: &models.Call{AppID: "app"}}); mounts != nil || err != nil {
+		t.Errorf("expected no volume, got %v %v", mounts, err)
+	}
+
+	if _, _, err := m.acquire(ctx, newCall(11)); err != models.ErrFnVolumeTooLarge {
+		t.Errorf("expected volume to be too large, got %v", err)
+	}
+
+	mounts, release, err := m.acquire(ctx, newCall(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 1 || mounts[0] != [2]string{"/host/volumes/app/cache", "/cache"} {
+		t.Fatalf("unexpected mounts %v", mounts)
+	}
+	volumeDir := filepath.Join(dir, "app", "cache")
+	if err := ioutil.WriteFile(filepath.Join(volumeDir, "weights"), []byte(strings.Repeat("x", 1024*1024+1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// volumes in use are left alone, those over their size are emptied once released
+	m.collect(time.Now().Add(2 * time.Hour))
+	if _, err := os.Stat(volumeDir); err != nil {
+		t.Fatalf("expected volume in use to be kept, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old_app", "cache")); !os.IsNotExist(err) {
+		t.Errorf("expected idle volume to be removed, got %v", err)
+	}
+	release()
+	if _, err := os.Stat(volumeDir); !os.IsNotExist(err) {
+		t.Errorf("expected volume over its size to be emptied once released, got %v", err)
+	}
+
+	// and those found over their size while not in use are emptied right away
+	_, release, err = m.acquire(ctx, newCall(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(volumeDir, "weights"), []byte(strings.Repeat("x", 1024*1024+1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	release()
+	m.collect(time.Now())
+	if _, err := os.Stat(volumeDir); !os.IsNotExist(err) {
+		t.Errorf("expected volume over its size to be emptied, got %v", err)
+	}
+
+	_, release, err = m.acquire(ctx, newCall(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(volumeDir); err != nil {
+		t.Fatalf("expected volume to be created again, got %v", err)
+	}
+	release()
+
+	m.collect(time.Now().Add(2 * time.Hour))
+	if files, _ := ioutil.ReadDir(filepath.Join(dir, "app")); len(files) != 0 {
+		t.Errorf("expected idle volumes to be removed, got %d", len(files))
+	}
+
+	var disabled *volumeManager
+	if mounts, _, err := disabled.acquire(ctx, newCall(1)); mounts != nil || err != nil {
+		t.Errorf("expected no volume when disabled, got %v %v", mounts, err)
+	}
+}
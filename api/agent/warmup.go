@@ -0,0 +1,219 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	pool "github.com/fnproject/fn/api/runnerpool"
+	"github.com/sirupsen/logrus"
+	"go.opencensus.io/trace"
+)
+
+// WarmupExtension is a reserved call extensions key, set on calls sent from
+// an LB to a pure runner to have it warm that many containers instead of
+// executing the call.
+const WarmupExtension = "fn_warmup"
+
+// Warmer is implemented by agents that can start hot containers for a fn
+// ahead of any calls to it.
+type Warmer interface {
+	// Warmup pulls the image for the call built from opts and starts up to
+	// count hot containers for it. The containers are left idle (and paused)
+	// in the fn's slot queue until their idle timeout, as if they had just
+	// completed a call. Warmup returns how many containers were started, which
+	// is less than count if the agent runs out of capacity or a container
+	// fails to start, in which case the error is returned.
+	Warmup(ctx context.Context, count int, opts ...CallOpt) (int, error)
+}
+
+var _ Warmer = new(agent)
+var _ Warmer = new(lbAgent)
+
+func withSlotHashID(id string) CallOpt {
+	return func(c *call) error {
+		c.slotHashId = id
+		return nil
+	}
+}
+
+// discardResponseWriter swallows responses to warmup calls, which never run
+type discardResponseWriter struct {
+	headers http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.headers }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+// warmState tracks a warming container until it is first idle, or fails
+type warmState struct {
+	ContainerState
+	once  sync.Once
+	ready chan struct{}
+	idle  bool
+}
+
+func (s *warmState) UpdateState(ctx context.Context, newState ContainerStateType, call *call) {
+	s.ContainerState.UpdateState(ctx, newState, call)
+	if newState == ContainerStateIdle || newState == ContainerStateDone {
+		s.once.Do(func() {
+			s.idle = newState == ContainerStateIdle
+			close(s.ready)
+		})
+	}
+}
+
+// Warmup implements Warmer
+func (a *agent) Warmup(ctx context.Context, count int, opts ...CallOpt) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "agent_warmup")
+	defer span.End()
+
+	callI, err := a.GetCall(append(opts, WithWriter(&discardResponseWriter{headers: make(http.Header)}))...)
+	if err != nil {
+		return 0, err
+	}
+	call := callI.(*call)
+
+	if !a.shutWg.AddSession(1) {
+		return 0, models.ErrCallTimeoutServerBusy
+	}
+	defer a.shutWg.DoneSession()
+
+	if call.slotHashId == "" {
+		slotExtns := a.driver.GetSlotKeyExtensions(call.Extensions())
+		call.slotHashId = getSlotQueueKey(call, slotExtns)
+	}
+
+	var isNew bool
+	call.slots, isNew = a.slotMgr.getSlotQueue(call)
+
+	// nobody waits on a warm container, launch errors are collected here instead
+	notify := make(chan error, count)
+	caller := slotCaller{id: call.ID, notify: notify}
+
+	if isNew {
+		// every slot queue needs a launcher, which also tears the queue down once idle
+		go a.hotLauncher(ctx, call, &caller)
+	}
+
+	mem := call.Memory + uint64(call.TmpFsSize)
+	var states []*warmState
+	for i := 0; i < count; i++ {
+		tok := a.gpus.reserve(ctx, a.resources.GetResourceTokenNB(ctx, mem, call.CPUs), call.gpus)
+		if tok == nil || tok.Error() != nil {
+			if tok != nil {
+				tok.Close()
+			}
+			err = models.ErrCallTimeoutServerBusy
+			break
+		}
+		if !a.shutWg.AddSession(1) {
+			tok.Close()
+			err = models.ErrCallTimeoutServerBusy
+			break
+		}
+
+		state := &warmState{ContainerState: NewContainerState(), ready: make(chan struct{})}
+		state.UpdateState(ctx, ContainerStateWait, call)
+		states = append(states, state)
+		go func() {
+			a.runHot(ctx, caller, call, tok, state)
+			a.shutWg.DoneSession()
+		}()
+	}
+
+	warmed := 0
+	for _, state := range states {
+		select {
+		case <-state.ready:
+			if state.idle {
+				warmed++
+			}
+		case <-ctx.Done():
+			return warmed, ctx.Err()
+		}
+	}
+
+	if err == nil && warmed < len(states) {
+		err = models.ErrContainerInitFail
+		select {
+		case err = <-notify:
+		default:
+		}
+	}
+	common.Logger(ctx).WithFields(logrus.Fields{"requested": count, "warmed": warmed}).Debug("warmup complete")
+	return warmed, err
+}
+
+// Warmup implements Warmer, spreading count containers evenly across the
+// runners in the pool. Runners that are busy or unreachable are skipped.
+func (a *lbAgent) Warmup(ctx context.Context, count int, opts ...CallOpt) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "lb_agent_warmup")
+	defer span.End()
+
+	if !a.shutWg.AddSession(1) {
+		return 0, models.ErrCallTimeoutServerBusy
+	}
+	defer a.shutWg.DoneSession()
+
+	callI, err := a.GetCall(opts...)
+	if err != nil {
+		return 0, err
+	}
+	runners, err := a.rp.Runners(ctx, callI.(*call))
+	if err != nil {
+		return 0, err
+	}
+	if len(runners) == 0 {
+		return 0, models.ErrCallTimeoutServerBusy
+	}
+
+	type result struct {
+		n   int
+		err error
+	}
+	results := make(chan result, len(runners))
+	started := 0
+	for i, r := range runners {
+		share := count / len(runners)
+		if i < count%len(runners) {
+			share++
+		}
+		if share == 0 {
+			break
+		}
+
+		callI, err := a.GetCall(append(opts,
+			WithWriter(&discardResponseWriter{headers: make(http.Header)}),
+			WithExtensions(map[string]string{WarmupExtension: strconv.Itoa(share)}),
+		)...)
+		if err != nil {
+			return 0, err
+		}
+
+		started++
+		go func(r pool.Runner, c *call, share int) {
+			placed, err := r.TryExec(ctx, c)
+			if !placed || err != nil {
+				results <- result{0, err}
+				return
+			}
+			results <- result{share, nil}
+		}(r, callI.(*call), share)
+	}
+
+	warmed := 0
+	for i := 0; i < started; i++ {
+		res := <-results
+		warmed += res.n
+		if res.err != nil {
+			common.Logger(ctx).WithError(res.err).Info("runner failed to warm containers")
+			err = res.err
+		}
+	}
+	return warmed, err
+}
@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestWarmup(t *testing.T) {
+	a, err := getAgent()
+	if err != nil {
+		t.Fatal("cannot create agent")
+	}
+	defer checkClose(t, a)
+
+	app, fn := getApp(), getFn(0)
+	req, err := http.NewRequest("POST", "http://127.0.0.1:8080/invoke/"+fn.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// only one container fits in the agent's memory
+	warmed, err := a.(Warmer).Warmup(ctx, 2, FromHTTPFnRequest(app, fn, req))
+	if warmed != 1 || err != models.ErrCallTimeoutServerBusy {
+		t.Fatalf("expected 1 container to be warmed with error `%v`, got %d `%v`", models.ErrCallTimeoutServerBusy, warmed, err)
+	}
+
+	// which the next call must use, there is no room to start another
+	err = execFn(`{"sleepTime": 0}`, fn, app, a, 5000)
+	if err != nil {
+		t.Fatalf("call to warmed fn failed: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+// Package build builds the images of fns from their source on builder nodes, so that developers do not need
+// to run docker locally to deploy fns.
+package build
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// Request is a build of an image from source
+type Request struct {
+	// Image is the name and tag the built image is pushed as, eg. registry.example.com/app-fn:01D4Z
+	Image string
+	// Context is a tar archive of the source, optionally gzipped, holding the Dockerfile
+	Context io.Reader
+	// Dockerfile is the path of the Dockerfile in Context, Dockerfile if it is empty
+	Dockerfile string
+	// Output receives the output of the build and push
+	Output io.Writer
+}
+
+// Result is a built image that was pushed to its registry
+type Result struct {
+	// Image is the pushed image pinned to its digest, eg. registry.example.com/app-fn@sha256:4f53...
+	Image string
+	// Digest of the image in its registry
+	Digest string
+}
+
+// Builder builds images and pushes them to their registry
+type Builder interface {
+	Build(ctx context.Context, req *Request) (*Result, error)
+}
+
+// repository returns image without its tag or digest
+func repository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// pinnedImage returns the image of the repository of image among repoDigests, the repository@digest names of
+// an image once pushed
+func pinnedImage(image string, repoDigests []string) (*Result, bool) {
+	repo := repository(image)
+	for _, d := range repoDigests {
+		if strings.HasPrefix(d, repo+"@") {
+			return &Result{Image: d, Digest: d[len(repo)+1:]}, true
+		}
+	}
+	return nil, false
+}
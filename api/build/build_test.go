@@ -0,0 +1,25 @@
+package build
+
+import "testing"
+
+func TestPinnedImage(t *testing.T) {
+	for _, test := range []struct {
+		image       string
+		repoDigests []string
+		pinned      string
+		digest      string
+	}{
+		{"registry.example.com/app-fn:01D4Z", []string{"registry.example.com/app-fn@sha256:4f53"}, "registry.example.com/app-fn@sha256:4f53", "sha256:4f53"},
+		{"localhost:5000/app-fn:v1", []string{"other/app-fn@sha256:1111", "localhost:5000/app-fn@sha256:2222"}, "localhost:5000/app-fn@sha256:2222", "sha256:2222"},
+		{"localhost:5000/app-fn", []string{"localhost:5000/app-fn@sha256:3333"}, "localhost:5000/app-fn@sha256:3333", "sha256:3333"},
+		{"registry.example.com/app-fn:01D4Z", []string{"registry.example.com/app-fn-other@sha256:4f53"}, "", ""},
+	} {
+		res, ok := pinnedImage(test.image, test.repoDigests)
+		if ok != (test.pinned != "") {
+			t.Fatalf("%s: expected a digest %v, got %+v", test.image, test.pinned != "", res)
+		}
+		if ok && (res.Image != test.pinned || res.Digest != test.digest) {
+			t.Errorf("%s: expected %s, got %+v", test.image, test.pinned, res)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package build
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Buildpack builds the image of a fn written with one of the FDKs from its source, without a Dockerfile, the
+// same way the fn CLI does
+type Buildpack struct {
+	// Name of the buildpack, as selected in builds
+	Name string
+	// files, any of which in the root of the source marks it as using this buildpack
+	files []string
+	// dockerfile returns the Dockerfile of the fn, cmd is what runs it if the buildpack needs to be told
+	dockerfile func(cmd string) (string, error)
+}
+
+// Buildpacks are the buildpacks builds may use, in the order they are detected in
+var Buildpacks = []*Buildpack{
+	{
+		Name:  "java",
+		files: []string{"pom.xml"},
+		dockerfile: func(cmd string) (string, error) {
+			if cmd == "" {
+				return "", errors.New("the java buildpack needs the cmd of the fn, eg. com.example.fn.HelloFunction::handleRequest")
+			}
+			return fmt.Sprintf(javaDockerfile, cmd), nil
+		},
+	},
+	{
+		Name:       "go",
+		files:      []string{"go.mod", "func.go"},
+		dockerfile: staticDockerfile(goDockerfile),
+	},
+	{
+		Name:       "node",
+		files:      []string{"package.json", "func.js"},
+		dockerfile: staticDockerfile(nodeDockerfile),
+	},
+	{
+		Name:       "python",
+		files:      []string{"requirements.txt", "func.py"},
+		dockerfile: staticDockerfile(pythonDockerfile),
+	},
+}
+
+// FindBuildpack returns the buildpack called name, or nil if there is none
+func FindBuildpack(name string) *Buildpack {
+	for _, bp := range Buildpacks {
+		if bp.Name == name {
+			return bp
+		}
+	}
+	return nil
+}
+
+// DetectBuildpack returns the buildpack of the source that holds files, or nil if there is none
+func DetectBuildpack(files map[string]bool) *Buildpack {
+	for _, bp := range Buildpacks {
+		for _, f := range bp.files {
+			if files[f] {
+				return bp
+			}
+		}
+	}
+	return nil
+}
+
+// BuildpackNames lists the names of the buildpacks
+func BuildpackNames() string {
+	names := make([]string, 0, len(Buildpacks))
+	for _, bp := range Buildpacks {
+		names = append(names, bp.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func staticDockerfile(dockerfile string) func(string) (string, error) {
+	return func(string) (string, error) { return dockerfile, nil }
+}
+
+const goDockerfile = `FROM fnproject/go:dev as build-stage
+WORKDIR /go/src/func/
+ENV GO111MODULE=on
+COPY . .
+RUN go build -o func
+FROM fnproject/go
+WORKDIR /function
+COPY --from=build-stage /go/src/func/func /function/
+ENTRYPOINT ["./func"]
+`
+
+const nodeDockerfile = `FROM fnproject/node:dev as build-stage
+WORKDIR /function
+ADD package.json /function/
+RUN npm install
+FROM fnproject/node
+WORKDIR /function
+ADD . /function/
+COPY --from=build-stage /function/node_modules/ /function/node_modules/
+ENTRYPOINT ["node", "func.js"]
+`
+
+const pythonDockerfile = `FROM fnproject/python:3.6-dev as build-stage
+WORKDIR /function
+ADD requirements.txt /function/
+RUN pip3 install --target /python/ --no-cache --no-cache-dir -r requirements.txt && rm -fr ~/.cache/pip /tmp* requirements.txt
+ADD . /function/
+FROM fnproject/python:3.6
+WORKDIR /function
+COPY --from=build-stage /python /python
+COPY --from=build-stage /function /function
+ENV PYTHONPATH=/function:/python
+ENTRYPOINT ["/python/bin/fdk", "/function/func.py", "handler"]
+`
+
+const javaDockerfile = `FROM fnproject/fn-java-fdk-build:latest as build-stage
+WORKDIR /function
+ENV MAVEN_OPTS -Dhttp.proxyHost= -Dhttp.proxyPort= -Dhttps.proxyHost= -Dhttps.proxyPort= -Dhttp.nonProxyHosts= -Dmaven.repo.local=/usr/share/maven/ref/repository
+ADD pom.xml /function/pom.xml
+RUN ["mvn", "package", "dependency:copy-dependencies", "-DincludeScope=runtime", "-DskipTests=true", "-Dmdep.prependGroupId=true", "-DoutputDirectory=target", "--fail-never"]
+ADD src /function/src
+RUN ["mvn", "package"]
+FROM fnproject/fn-java-fdk:latest
+WORKDIR /function
+COPY --from=build-stage /function/target/*.jar /function/app/
+CMD [%q]
+`
@@ -0,0 +1,101 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fnproject/fn/api/common"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+type dockerBuilder struct {
+	builders []*docker.Client
+	next     uint64
+	auth     docker.AuthConfiguration
+}
+
+// NewDockerBuilder returns a Builder that builds images on the docker daemons of builder nodes, taking turns, and
+// pushes them with auth. Without hosts, images are built on the docker daemon of the environment, as set by
+// DOCKER_HOST.
+func NewDockerBuilder(hosts []string, auth docker.AuthConfiguration) (Builder, error) {
+	b := &dockerBuilder{auth: auth}
+	for _, host := range hosts {
+		client, err := docker.NewClient(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid builder docker host %s: %v", host, err)
+		}
+		b.builders = append(b.builders, client)
+	}
+	if len(b.builders) == 0 {
+		client, err := docker.NewClientFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		b.builders = append(b.builders, client)
+	}
+	return b, nil
+}
+
+func (b *dockerBuilder) Build(ctx context.Context, req *Request) (*Result, error) {
+	client := b.builders[atomic.AddUint64(&b.next, 1)%uint64(len(b.builders))]
+	log := common.Logger(ctx).WithField("image", req.Image)
+
+	output := req.Output
+	if output == nil {
+		output = ioutil.Discard
+	}
+	dockerfile := req.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+
+	log.Info("building image")
+	err := client.BuildImage(docker.BuildImageOptions{
+		Context:             ctx,
+		Name:                req.Image,
+		Dockerfile:          dockerfile,
+		InputStream:         req.Context,
+		OutputStream:        output,
+		Pull:                true,
+		RmTmpContainer:      true,
+		ForceRmTmpContainer: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("build failed: %v", err)
+	}
+
+	repo := repository(req.Image)
+	err = client.PushImage(docker.PushImageOptions{
+		Context:      ctx,
+		Name:         repo,
+		Tag:          strings.TrimPrefix(req.Image[len(repo):], ":"),
+		OutputStream: output,
+	}, b.auth)
+	if err != nil {
+		return nil, fmt.Errorf("push failed: %v", err)
+	}
+
+	img, err := client.InspectImage(req.Image)
+	if err != nil {
+		return nil, err
+	}
+	res, ok := pinnedImage(req.Image, img.RepoDigests)
+	if !ok {
+		return nil, fmt.Errorf("no digest of %s found once pushed", req.Image)
+	}
+	log.WithField("digest", res.Digest).Info("built and pushed image")
+	return res, nil
+}
+
+// RegistryAuthFromDockerCfg returns the auth of the host of registry in the docker config, eg.
+// ~/.docker/config.json, or no auth if there is none
+func RegistryAuthFromDockerCfg(registry string) docker.AuthConfiguration {
+	auths, err := docker.NewAuthConfigurationsFromDockerCfg()
+	if err != nil {
+		return docker.AuthConfiguration{}
+	}
+	return auths.Configs[strings.SplitN(registry, "/", 2)[0]]
+}
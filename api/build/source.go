@@ -0,0 +1,138 @@
+package build
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+// generatedDockerfile is the path in the source of the Dockerfile generated by a buildpack
+const generatedDockerfile = ".fn-buildpack.Dockerfile"
+
+// SourceOptions select how the image of a source is built
+type SourceOptions struct {
+	// Dockerfile is the path of the Dockerfile in the source, Dockerfile if it is empty
+	Dockerfile string
+	// Buildpack builds the source without its Dockerfile, "auto" detects it. If it is empty a buildpack is only
+	// detected if the source has no Dockerfile and none was asked for.
+	Buildpack string
+	// Cmd is what runs the fn, for buildpacks that need it
+	Cmd string
+}
+
+// Source is a build context spooled to a temporary file, with a Dockerfile generated by its buildpack if it did
+// not have one. It must be closed to remove the file.
+type Source struct {
+	file *os.File
+	// Dockerfile is the path of the Dockerfile in the source
+	Dockerfile string
+	// Buildpack is the name of the buildpack that generated the Dockerfile, if one did
+	Buildpack string
+}
+
+// NewSource reads a tar archive of the source of a fn, optionally gzipped, from r
+func NewSource(r io.Reader, opts SourceOptions) (*Source, error) {
+	f, err := ioutil.TempFile("", "fn-build")
+	if err != nil {
+		return nil, err
+	}
+	s := &Source{file: f}
+	if err := s.spool(r, opts); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Source) spool(r io.Reader, opts SourceOptions) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	files := make(map[string]bool)
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(s.file)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid source archive: %v", err)
+		}
+		files[path.Clean(hdr.Name)] = true
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return fmt.Errorf("invalid source archive: %v", err)
+		}
+	}
+
+	dockerfile := opts.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	if opts.Buildpack == "" {
+		if files[path.Clean(dockerfile)] {
+			s.Dockerfile = dockerfile
+			return tw.Close()
+		}
+		if opts.Dockerfile != "" {
+			return fmt.Errorf("%s not found in the source archive", opts.Dockerfile)
+		}
+	}
+
+	var bp *Buildpack
+	if opts.Buildpack == "" || opts.Buildpack == "auto" {
+		bp = DetectBuildpack(files)
+		if bp == nil {
+			return fmt.Errorf("the source archive has no Dockerfile and no buildpack detects it, buildpacks are %s", BuildpackNames())
+		}
+	} else if bp = FindBuildpack(opts.Buildpack); bp == nil {
+		return fmt.Errorf("unknown buildpack %s, buildpacks are %s", opts.Buildpack, BuildpackNames())
+	}
+	content, err := bp.dockerfile(opts.Cmd)
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{Name: generatedDockerfile, Mode: 0644, Size: int64(len(content)), ModTime: time.Now()})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(tw, content); err != nil {
+		return err
+	}
+	s.Dockerfile = generatedDockerfile
+	s.Buildpack = bp.Name
+	return tw.Close()
+}
+
+func (s *Source) Read(p []byte) (int, error) {
+	return s.file.Read(p)
+}
+
+// Close removes the spooled source
+func (s *Source) Close() error {
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
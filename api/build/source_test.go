@@ -0,0 +1,100 @@
+package build
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func testArchive(t *testing.T, gzipped bool, files ...string) io.Reader {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if gzipped {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, name := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(name))}); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, name)
+	}
+	tw.Close()
+	if gz != nil {
+		gz.Close()
+	}
+	return &buf
+}
+
+func readSource(t *testing.T, s *Source) map[string]string {
+	files := make(map[string]string)
+	tr := tar.NewReader(s)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(tr)
+		files[hdr.Name] = string(content)
+	}
+}
+
+func TestSource(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		archive    io.Reader
+		opts       SourceOptions
+		dockerfile string
+		buildpack  string
+		contains   string
+		err        string
+	}{
+		{"dockerfile", testArchive(t, false, "Dockerfile", "func.go"), SourceOptions{}, "Dockerfile", "", "Dockerfile", ""},
+		{"gzipped", testArchive(t, true, "./Dockerfile"), SourceOptions{}, "Dockerfile", "", "Dockerfile", ""},
+		{"other dockerfile", testArchive(t, false, "build/Dockerfile"), SourceOptions{Dockerfile: "build/Dockerfile"}, "build/Dockerfile", "", "build/Dockerfile", ""},
+		{"missing dockerfile", testArchive(t, false, "func.go"), SourceOptions{Dockerfile: "build/Dockerfile"}, "", "", "", "not found"},
+		{"detected", testArchive(t, false, "./package.json", "./func.js"), SourceOptions{}, generatedDockerfile, "node", "fnproject/node", ""},
+		{"auto", testArchive(t, false, "Dockerfile", "requirements.txt"), SourceOptions{Buildpack: "auto"}, generatedDockerfile, "python", "fnproject/python", ""},
+		{"selected", testArchive(t, false, "func.go", "package.json"), SourceOptions{Buildpack: "go"}, generatedDockerfile, "go", "fnproject/go", ""},
+		{"java", testArchive(t, false, "pom.xml"), SourceOptions{Cmd: "com.example.fn.HelloFunction::handleRequest"}, generatedDockerfile, "java", `CMD ["com.example.fn.HelloFunction::handleRequest"]`, ""},
+		{"java without cmd", testArchive(t, false, "pom.xml"), SourceOptions{}, "", "", "", "needs the cmd"},
+		{"unknown buildpack", testArchive(t, false, "func.go"), SourceOptions{Buildpack: "cobol"}, "", "", "", "unknown buildpack"},
+		{"undetected", testArchive(t, false, "README.md"), SourceOptions{}, "", "", "", "no buildpack detects it"},
+		{"not an archive", strings.NewReader(strings.Repeat("source", 100)), SourceOptions{}, "", "", "", "invalid source archive"},
+	} {
+		s, err := NewSource(test.archive, test.opts)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected an error with %q, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		files := readSource(t, s)
+		s.Close()
+		if s.Dockerfile != test.dockerfile || s.Buildpack != test.buildpack {
+			t.Errorf("%s: expected dockerfile %s of buildpack %q, got %s of %q", test.name, test.dockerfile, test.buildpack, s.Dockerfile, s.Buildpack)
+		}
+		found := false
+		for name, content := range files {
+			if strings.TrimPrefix(name, "./") == s.Dockerfile && strings.Contains(content, test.contains) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: expected %s to hold %q, got %v", test.name, s.Dockerfile, test.contains, files)
+		}
+	}
+}
@@ -72,3 +72,15 @@ func BackgroundContext(ctx context.Context) context.Context {
 		original: ctx,
 	}
 }
+
+// WithPrincipal stores who made a request, as authenticated by an extension.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, contextKey("principal"), principal)
+}
+
+// PrincipalFromContext returns who made a request, or "" if no extension
+// authenticated it.
+func PrincipalFromContext(ctx context.Context) string {
+	p, _ := ctx.Value(contextKey("principal")).(string)
+	return p
+}
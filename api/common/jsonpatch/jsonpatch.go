@@ -0,0 +1,350 @@
+// Package jsonpatch applies JSON merge patches, RFC 7386, and JSON patches, RFC 6902, to JSON documents
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MergePatchType is the media type of JSON merge patches
+	MergePatchType = "application/merge-patch+json"
+	// PatchType is the media type of JSON patches
+	PatchType = "application/json-patch+json"
+)
+
+// ErrTestFailed is returned when a test operation of a JSON patch does not hold
+var ErrTestFailed = errors.New("json patch test operation failed")
+
+func decode(b []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, errors.New("invalid json, trailing data")
+	}
+	return v, nil
+}
+
+// MergePatch returns doc with the merge patch applied
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	d, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decode(patch)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(d, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{}, len(p))
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+type operation struct {
+	Op    string           `json:"op"`
+	Path  *string          `json:"path"`
+	From  *string          `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// Patch returns doc with the JSON patch applied. The operations apply in order, and none do if one fails.
+func Patch(doc, patch []byte) ([]byte, error) {
+	d, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	var ops []operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		d, err = op.apply(d)
+		if err != nil {
+			if err == ErrTestFailed {
+				return nil, err
+			}
+			return nil, fmt.Errorf("json patch operation %d: %v", i, err)
+		}
+	}
+	return json.Marshal(d)
+}
+
+// value returns the value of an add, replace or test operation
+func (op *operation) value() (interface{}, error) {
+	if op.Value == nil {
+		return nil, fmt.Errorf("no value for %s", op.Op)
+	}
+	return decode(*op.Value)
+}
+
+func (op *operation) apply(doc interface{}) (interface{}, error) {
+	if op.Path == nil {
+		return nil, errors.New("no path")
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		v, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, v)
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+	case "replace":
+		v, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if doc, _, err = remove(doc, path); err != nil {
+			return nil, err
+		}
+		return add(doc, path, v)
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("no from for %s", op.Op)
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if op.Op == "move" {
+			if len(path) > len(from) && reflect.DeepEqual(path[:len(from)], from) {
+				return nil, errors.New("cannot move a value into itself")
+			}
+			doc, v, err = remove(doc, from)
+		} else {
+			v, err = get(doc, from)
+			if err == nil {
+				v, err = clone(v)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, v)
+	case "test":
+		v, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		actual, err := get(doc, path)
+		if err != nil || !equal(actual, v) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// parsePointer returns the reference tokens of a JSON pointer, RFC 6901
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("invalid json pointer %q", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// index returns the index a token refers to in an array of n values, n for "-" if end is allowed
+func index(token string, n int, end bool) (int, error) {
+	if token == "-" && end {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > n || (i == n && !end) {
+		return 0, fmt.Errorf("array index %d out of bounds", i)
+	}
+	return i, nil
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, t := range path {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			v, ok := d[t]
+			if !ok {
+				return nil, fmt.Errorf("no member %q", t)
+			}
+			doc = v
+		case []interface{}:
+			i, err := index(t, len(d), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = d[i]
+		default:
+			return nil, fmt.Errorf("cannot traverse %q of a scalar", t)
+		}
+	}
+	return doc, nil
+}
+
+// add returns doc with v added at path, which replaces the member of an object, and is inserted in an array
+func add(doc interface{}, path []string, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = v
+		return doc, nil
+	case []interface{}:
+		i, err := index(last, len(p), true)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, nil)
+		copy(p[i+1:], p[i:])
+		p[i] = v
+		return set(doc, path[:len(path)-1], p)
+	}
+	return nil, fmt.Errorf("cannot add %q to a scalar", last)
+}
+
+// remove returns doc without the value at path, and the value removed
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		v, ok := p[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("no member %q", last)
+		}
+		delete(p, last)
+		return doc, v, nil
+	case []interface{}:
+		i, err := index(last, len(p), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		v := p[i]
+		p = append(p[:i:i], p[i+1:]...)
+		doc, err = set(doc, path[:len(path)-1], p)
+		return doc, v, err
+	}
+	return nil, nil, fmt.Errorf("cannot remove %q of a scalar", last)
+}
+
+// set returns doc with the value at path, which exists, replaced with v, as arrays are replaced when they grow or shrink
+func set(doc interface{}, path []string, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = v
+	case []interface{}:
+		i, err := index(last, len(p), false)
+		if err != nil {
+			return nil, err
+		}
+		p[i] = v
+	}
+	return doc, nil
+}
+
+func clone(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return decode(b)
+}
+
+// equal compares JSON values, numbers by value
+func equal(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		n, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, errA := a.Float64()
+		y, errB := n.Float64()
+		return errA == nil && errB == nil && x == y
+	case map[string]interface{}:
+		m, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(m) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := m[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		s, ok := b.([]interface{})
+		if !ok || len(a) != len(s) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], s[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,82 @@
+package jsonpatch
+
+import (
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// from appendix A of RFC 7386
+	for _, tc := range []struct{ doc, patch, expected string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	} {
+		b, err := MergePatch([]byte(tc.doc), []byte(tc.patch))
+		if err != nil {
+			t.Fatalf("merging %s into %s: %v", tc.patch, tc.doc, err)
+		}
+		if string(b) != tc.expected {
+			t.Errorf("merging %s into %s: expected %s, got %s", tc.patch, tc.doc, tc.expected, b)
+		}
+	}
+}
+
+func TestPatch(t *testing.T) {
+	// from appendix A of RFC 6902
+	for _, tc := range []struct{ doc, patch, expected string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"child":{"grandchild":{}},"foo":"bar"}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+		{`{"foo":{"bar":1}}`, `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`,
+			`{"baz":{"bar":2},"foo":{"bar":1}}`},
+	} {
+		b, err := Patch([]byte(tc.doc), []byte(tc.patch))
+		if err != nil {
+			t.Fatalf("patching %s with %s: %v", tc.doc, tc.patch, err)
+		}
+		if string(b) != tc.expected {
+			t.Errorf("patching %s with %s: expected %s, got %s", tc.doc, tc.patch, tc.expected, b)
+		}
+	}
+
+	for _, tc := range []struct{ doc, patch string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"qux"}]`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":"qux"}]`},
+		{`{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/01"}]`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz"}]`},
+		{`{"foo":"bar"}`, `[{"op":"frob","path":"/foo"}]`},
+		{`{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`},
+		{`{"foo":"bar"}`, `{"op":"add","path":"/baz","value":"qux"}`},
+	} {
+		if _, err := Patch([]byte(tc.doc), []byte(tc.patch)); err == nil || err == ErrTestFailed {
+			t.Errorf("expected patching %s with %s to be invalid, got %v", tc.doc, tc.patch, err)
+		}
+	}
+
+	if _, err := Patch([]byte(`{"baz":"qux"}`), []byte(`[{"op":"test","path":"/baz","value":"bar"}]`)); err != ErrTestFailed {
+		t.Errorf("expected the test to fail, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/id"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrLockHeld is returned by LockStores when another holder holds a lock
+var ErrLockHeld = errors.New("Lock is held by another holder")
+
+// LockStore stores the distributed locks shared by the servers of a deployment, such as the leases of the datastore
+// or keys in redis. Built-in subsystems and extensions elect the server doing a job with the Locks of a store,
+// rather than each rolling their own.
+type LockStore interface {
+	// AcquireLock takes lock name for holder until expires, if no other holder holds it, or renews it if holder
+	// already does. It returns ErrLockHeld if another holder holds the lock and it has not expired.
+	AcquireLock(ctx context.Context, name, holder string, expires time.Time) error
+	// ReleaseLock gives up lock name, if holder holds it, so that another holder can take it without waiting for
+	// it to expire
+	ReleaseLock(ctx context.Context, name, holder string) error
+}
+
+// NewLockHolder returns a unique name of a holder of locks, of the host it runs on
+func NewLockHolder() string {
+	holder := id.New().String()
+	if host, err := os.Hostname(); err == nil {
+		holder = fmt.Sprintf("%s/%s", host, holder)
+	}
+	return holder
+}
+
+// Lock is a named lock of a LockStore, held for ttl at a time by one of the servers sharing the store. Holders
+// renew it before it expires to keep holding it, and stop the work it guards once it does, so that a single
+// server does it at a time.
+type Lock struct {
+	store  LockStore
+	name   string
+	holder string
+	ttl    time.Duration
+
+	lock    sync.Mutex
+	held    bool
+	expires time.Time
+}
+
+// NewLock returns lock name of store, acquired by holder for ttl at a time
+func NewLock(store LockStore, name, holder string, ttl time.Duration) *Lock {
+	return &Lock{store: store, name: name, holder: holder, ttl: ttl}
+}
+
+// Name is the name of the lock
+func (l *Lock) Name() string { return l.name }
+
+// Holder is the holder the lock is acquired for
+func (l *Lock) Holder() string { return l.holder }
+
+// TryAcquire acquires the lock, or renews it if it is held, and returns whether it is held. If the store fails,
+// the lock is held until it would have expired, as another holder cannot take it before then either.
+func (l *Lock) TryAcquire(ctx context.Context) (bool, error) {
+	now := time.Now()
+	expires := now.Add(l.ttl)
+	err := l.store.AcquireLock(ctx, l.name, l.holder, expires)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	switch {
+	case err == nil:
+		l.held, l.expires = true, expires
+	case err == ErrLockHeld:
+		l.held = false
+		err = nil
+	default:
+		l.held = l.held && now.Before(l.expires)
+	}
+	return l.held, err
+}
+
+// Held returns whether the lock is held, and has not expired since it was last acquired
+func (l *Lock) Held() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.held && time.Now().Before(l.expires)
+}
+
+// Release gives up the lock if it is held
+func (l *Lock) Release(ctx context.Context) error {
+	l.lock.Lock()
+	held := l.held
+	l.held = false
+	l.lock.Unlock()
+	if !held {
+		return nil
+	}
+	return l.store.ReleaseLock(ctx, l.name, l.holder)
+}
+
+// Elect runs fn while lock is held, until ctx is done. The lock is acquired, then renewed, every third of its ttl,
+// and the context of fn is canceled once it is lost. fn is run again whenever the lock is held and fn is not
+// running, so it may return once its work is done. The lock is released once ctx is done, so that another server
+// takes over without waiting for it to expire.
+func Elect(ctx context.Context, lock *Lock, fn func(ctx context.Context)) {
+	log := Logger(ctx).WithFields(logrus.Fields{"lock": lock.name, "holder": lock.holder})
+	ticker := time.NewTicker(lock.ttl / 3)
+	defer ticker.Stop()
+
+	var run *electedRun
+	defer func() {
+		run.stop()
+		if err := lock.Release(BackgroundContext(ctx)); err != nil {
+			log.WithError(err).Error("failed to release lock")
+		}
+	}()
+
+	// ticks racing ctx being done must not run fn again
+	for ctx.Err() == nil {
+		held, err := lock.TryAcquire(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.WithError(err).Error("failed to acquire lock")
+		}
+		switch {
+		case !held && run != nil:
+			log.Info("lock lost")
+			run.stop()
+			run = nil
+		case held && !run.running():
+			run.stop()
+			run = startElectedRun(ctx, fn)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// electedRun is a run of the fn of Elect, while the lock is held
+type electedRun struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func startElectedRun(ctx context.Context, fn func(ctx context.Context)) *electedRun {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &electedRun{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		fn(ctx)
+	}()
+	return r
+}
+
+// running returns whether fn has not returned yet
+func (r *electedRun) running() bool {
+	if r == nil {
+		return false
+	}
+	select {
+	case <-r.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop cancels fn, and waits for it to return
+func (r *electedRun) stop() {
+	if r != nil {
+		r.cancel()
+		<-r.done
+	}
+}
+
+// memoryLockStore keeps the locks of a single server in memory
+type memoryLockStore struct {
+	lock  sync.Mutex
+	locks map[string]memoryLock
+}
+
+type memoryLock struct {
+	holder  string
+	expires time.Time
+}
+
+// NewMemoryLockStore returns a LockStore keeping locks in memory, for the subsystems of a single server, or tests
+func NewMemoryLockStore() LockStore {
+	return &memoryLockStore{locks: make(map[string]memoryLock)}
+}
+
+// AcquireLock implements LockStore
+func (m *memoryLockStore) AcquireLock(ctx context.Context, name, holder string, expires time.Time) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if l, ok := m.locks[name]; ok && l.holder != holder && time.Now().Before(l.expires) {
+		return ErrLockHeld
+	}
+	m.locks[name] = memoryLock{holder: holder, expires: expires}
+	return nil
+}
+
+// ReleaseLock implements LockStore
+func (m *memoryLockStore) ReleaseLock(ctx context.Context, name, holder string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if l, ok := m.locks[name]; ok && l.holder == holder {
+		delete(m.locks, name)
+	}
+	return nil
+}
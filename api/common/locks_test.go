@@ -0,0 +1,241 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryLockStore()
+	a := NewLock(store, "gc", "a", time.Minute)
+	b := NewLock(store, "gc", "b", time.Minute)
+
+	if held, err := a.TryAcquire(ctx); !held || err != nil {
+		t.Fatalf("expected the first holder to acquire the lock, got %v %v", held, err)
+	}
+	if held, err := b.TryAcquire(ctx); held || err != nil {
+		t.Fatalf("expected the second holder not to acquire the lock, got %v %v", held, err)
+	}
+	// the holder renews it
+	if held, err := a.TryAcquire(ctx); !held || err != nil {
+		t.Fatalf("expected the holder to renew the lock, got %v %v", held, err)
+	}
+	if !a.Held() || b.Held() {
+		t.Fatalf("expected only the first holder to hold the lock")
+	}
+
+	if err := a.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if a.Held() {
+		t.Fatalf("expected the lock not to be held once released")
+	}
+	if held, err := b.TryAcquire(ctx); !held || err != nil {
+		t.Fatalf("expected the second holder to acquire the released lock, got %v %v", held, err)
+	}
+}
+
+// failingLockStore fails once broken
+type failingLockStore struct {
+	LockStore
+	broken bool
+}
+
+func (f *failingLockStore) AcquireLock(ctx context.Context, name, holder string, expires time.Time) error {
+	if f.broken {
+		return errors.New("unreachable")
+	}
+	return f.LockStore.AcquireLock(ctx, name, holder, expires)
+}
+
+func TestLockStoreFailure(t *testing.T) {
+	ctx := context.Background()
+	store := &failingLockStore{LockStore: NewMemoryLockStore()}
+	l := NewLock(store, "gc", "a", 50*time.Millisecond)
+	if held, err := l.TryAcquire(ctx); !held || err != nil {
+		t.Fatalf("expected the lock to be acquired, got %v %v", held, err)
+	}
+
+	// held until it expires, as no one else can take it before then
+	store.broken = true
+	if held, err := l.TryAcquire(ctx); !held || err == nil {
+		t.Fatalf("expected the lock to still be held with an error, got %v %v", held, err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if held, err := l.TryAcquire(ctx); held || err == nil {
+		t.Fatalf("expected the lock to expire, got %v %v", held, err)
+	}
+}
+
+func TestElect(t *testing.T) {
+	store := NewMemoryLockStore()
+	ttl := 30 * time.Millisecond
+
+	var lock sync.Mutex
+	running := make(map[string]bool)
+	elected := make(chan string, 10)
+	campaign := func(ctx context.Context, holder string) {
+		Elect(ctx, NewLock(store, "gc", holder, ttl), func(ctx context.Context) {
+			lock.Lock()
+			for h, ok := range running {
+				if ok {
+					t.Errorf("%s elected while %s runs", holder, h)
+				}
+			}
+			running[holder] = true
+			lock.Unlock()
+			elected <- holder
+
+			<-ctx.Done()
+			lock.Lock()
+			running[holder] = false
+			lock.Unlock()
+		})
+	}
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	doneA := make(chan struct{})
+	go func() {
+		defer close(doneA)
+		campaign(ctxA, "a")
+	}()
+	if h := <-elected; h != "a" {
+		t.Fatalf("expected a to be elected, got %s", h)
+	}
+
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+	go campaign(ctxB, "b")
+	select {
+	case h := <-elected:
+		t.Fatalf("expected no election while a holds the lock, got %s", h)
+	case <-time.After(2 * ttl):
+	}
+
+	// a releases the lock as it stops, b takes over
+	cancelA()
+	<-doneA
+	select {
+	case h := <-elected:
+		if h != "b" {
+			t.Fatalf("expected b to be elected, got %s", h)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected b to take over")
+	}
+}
+
+// fakeRedis serves AUTH and the lock scripts, running the commands of its connections one at a time as redis does
+type fakeRedis struct {
+	lock     sync.Mutex
+	password string
+	keys     map[string]string
+}
+
+func (f *fakeRedis) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		reply, err := readRedisReply(rd)
+		if err != nil {
+			return
+		}
+		args := make([]string, 0)
+		for _, a := range reply.([]interface{}) {
+			args = append(args, a.(string))
+		}
+
+		f.lock.Lock()
+		var resp string
+		switch {
+		case args[0] == "AUTH":
+			authed = args[1] == f.password
+			resp = "+OK\r\n"
+			if !authed {
+				resp = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			resp = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "EVAL" && args[1] == redisAcquireScript:
+			if v, ok := f.keys[args[3]]; ok && v != args[4] {
+				resp = ":0\r\n"
+			} else {
+				f.keys[args[3]] = args[4]
+				resp = ":1\r\n"
+			}
+		case args[0] == "EVAL" && args[1] == redisReleaseScript:
+			resp = ":0\r\n"
+			if f.keys[args[3]] == args[4] {
+				delete(f.keys, args[3])
+				resp = ":1\r\n"
+			}
+		default:
+			resp = "-ERR unknown command\r\n"
+		}
+		f.lock.Unlock()
+		conn.Write([]byte(resp))
+	}
+}
+
+func TestRedisLockStore(t *testing.T) {
+	ctx := context.Background()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	srv := &fakeRedis{password: "secret", keys: make(map[string]string)}
+	go srv.serve(l)
+
+	if _, err := NewRedisLockStore("http://" + l.Addr().String()); err == nil {
+		t.Fatalf("expected urls of schemes other than redis to be invalid")
+	}
+	store, err := NewRedisLockStore("redis://:secret@" + l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(time.Minute)
+	if err := store.AcquireLock(ctx, "gc", "a", expires); err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+	if srv.keys[redisLockPrefix+"gc"] != "a" {
+		t.Fatalf("expected the lock key to be held by a, got %v", srv.keys)
+	}
+	if err := store.AcquireLock(ctx, "gc", "a", expires); err != nil {
+		t.Fatalf("failed to renew lock: %v", err)
+	}
+	if err := store.AcquireLock(ctx, "gc", "b", expires); err != ErrLockHeld {
+		t.Fatalf("expected error `%v`, but it was `%v`", ErrLockHeld, err)
+	}
+	if err := store.ReleaseLock(ctx, "gc", "a"); err != nil {
+		t.Fatalf("failed to release lock: %v", err)
+	}
+	if err := store.AcquireLock(ctx, "gc", "b", expires); err != nil {
+		t.Fatalf("failed to acquire released lock: %v", err)
+	}
+
+	bad, err := NewRedisLockStore("redis://:wrong@" + l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bad.AcquireLock(ctx, "gc", "c", expires); err == nil {
+		t.Fatalf("expected a wrong password to be rejected")
+	}
+}
@@ -0,0 +1,338 @@
+// Package otlp exports the spans and views of opencensus to OpenTelemetry collectors, with the OpenTelemetry
+// protocol over gRPC or HTTP
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// Protocols of OTLP exporters
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+const (
+	instrumentationScope = "github.com/fnproject/fn"
+
+	tracesPath  = "/v1/traces"
+	metricsPath = "/v1/metrics"
+
+	tracesMethod  = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+	metricsMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+
+	// maxBatch is how many spans are exported at once, a batch is exported as soon as it is full
+	maxBatch = 512
+	// maxQueued is how many spans wait to be exported, spans past it are dropped
+	maxQueued = 4 * maxBatch
+
+	exportTimeout = 10 * time.Second
+)
+
+// Options configure an Exporter
+type Options struct {
+	// Endpoint is the url of the collector, as http://collector:4318 or https://collector:4317. Endpoints without a
+	// scheme are connected to over TLS. Spans are posted to the /v1/traces path of http endpoints.
+	Endpoint string
+	// Protocol is ProtocolGRPC or ProtocolHTTP, ProtocolHTTP if unset
+	Protocol string
+	// Headers are sent with each export, to authenticate to the collector
+	Headers map[string]string
+	// ServiceName and ServiceVersion are the service all telemetry is reported from
+	ServiceName    string
+	ServiceVersion string
+	// Interval is how often queued spans and the data of views are exported, 5s if unset
+	Interval time.Duration
+}
+
+// Exporter exports spans and views to an OpenTelemetry collector. Spans are queued and exported in batches, and
+// the latest data of each view is exported each interval.
+type Exporter struct {
+	opts          Options
+	resourceAttrs map[string]interface{}
+	send          func(ctx context.Context, signal int, req message) error
+
+	lock    sync.Mutex
+	spans   []*trace.SpanData
+	views   map[string]*view.Data
+	dropped int
+
+	full    chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// signals of telemetry, exported to their own path or method
+const (
+	signalTraces = iota
+	signalMetrics
+)
+
+var (
+	_ trace.Exporter = new(Exporter)
+	_ view.Exporter  = new(Exporter)
+)
+
+// ParseHeaders parses headers given as comma separated key=value pairs, as the OTEL_EXPORTER_OTLP_HEADERS
+// environment variable of OpenTelemetry SDKs
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid otlp header %q, expected key=value", pair)
+		}
+		value, err := url.QueryUnescape(strings.TrimSpace(kv[1]))
+		if err != nil {
+			// the value is left out, as it is most likely a credential
+			return nil, fmt.Errorf("invalid otlp header %q, its value is not url encoded", strings.TrimSpace(kv[0]))
+		}
+		headers[strings.TrimSpace(kv[0])] = value
+	}
+	return headers, nil
+}
+
+// NewExporter returns an exporter to the collector at opts.Endpoint, exporting in the background until it is
+// closed
+func NewExporter(opts Options) (*Exporter, error) {
+	if opts.Endpoint == "" {
+		return nil, errors.New("otlp endpoint is required")
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 5 * time.Second
+	}
+	endpoint := opts.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid otlp endpoint %q", opts.Endpoint)
+	}
+
+	e := &Exporter{
+		opts:          opts,
+		resourceAttrs: map[string]interface{}{"service.name": opts.ServiceName},
+		views:         make(map[string]*view.Data),
+		full:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	if opts.ServiceVersion != "" {
+		e.resourceAttrs["service.version"] = opts.ServiceVersion
+	}
+	if host, err := os.Hostname(); err == nil {
+		e.resourceAttrs["host.name"] = host
+	}
+
+	switch opts.Protocol {
+	case "", ProtocolHTTP:
+		e.send = httpSender(strings.TrimSuffix(u.String(), "/"), opts.Headers)
+	case ProtocolGRPC:
+		e.send, err = grpcSender(u, opts.Headers)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid otlp protocol %q, expected %s or %s", opts.Protocol, ProtocolGRPC, ProtocolHTTP)
+	}
+
+	go e.loop()
+	return e, nil
+}
+
+// ExportSpan implements trace.Exporter, queueing s
+func (e *Exporter) ExportSpan(s *trace.SpanData) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if len(e.spans) >= maxQueued {
+		e.dropped++
+		return
+	}
+	e.spans = append(e.spans, s)
+	if len(e.spans) >= maxBatch {
+		select {
+		case e.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ExportView implements view.Exporter, keeping the latest data of the view until the next export
+func (e *Exporter) ExportView(vd *view.Data) {
+	if vd.View == nil {
+		return
+	}
+	e.lock.Lock()
+	e.views[vd.View.Name] = vd
+	e.lock.Unlock()
+}
+
+// Flush exports the queued spans and the latest data of views
+func (e *Exporter) Flush() {
+	for e.export() {
+	}
+}
+
+// Close stops exporting in the background, once the queued spans and data are exported
+func (e *Exporter) Close() {
+	e.once.Do(func() { close(e.stop) })
+	<-e.stopped
+}
+
+func (e *Exporter) loop() {
+	defer close(e.stopped)
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			e.Flush()
+			return
+		case <-ticker.C:
+			e.Flush()
+		case <-e.full:
+			e.export()
+		}
+	}
+}
+
+// export exports a batch of spans and the pending data of views, returning whether spans remain queued
+func (e *Exporter) export() bool {
+	e.lock.Lock()
+	n := len(e.spans)
+	if n > maxBatch {
+		n = maxBatch
+	}
+	spans := e.spans[:n:n]
+	e.spans = e.spans[n:]
+	views := make([]*view.Data, 0, len(e.views))
+	for name, vd := range e.views {
+		views = append(views, vd)
+		delete(e.views, name)
+	}
+	dropped := e.dropped
+	e.dropped = 0
+	more := len(e.spans) > 0
+	e.lock.Unlock()
+
+	if dropped > 0 {
+		logrus.WithField("spans", dropped).Warn("otlp exporter dropped spans, the collector is not keeping up")
+	}
+	if len(spans) > 0 {
+		e.sendSignal(signalTraces, e.encodeSpans(spans))
+	}
+	if len(views) > 0 {
+		e.sendSignal(signalMetrics, e.encodeViews(views))
+	}
+	return more
+}
+
+func (e *Exporter) sendSignal(signal int, req message) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	if err := e.send(ctx, signal, req); err != nil {
+		logrus.WithError(err).WithField("endpoint", e.opts.Endpoint).Error("error exporting to otlp collector")
+	}
+}
+
+// httpSender posts exports to the paths of their signals under endpoint
+func httpSender(endpoint string, headers map[string]string) func(context.Context, int, message) error {
+	client := &http.Client{}
+	return func(ctx context.Context, signal int, body message) error {
+		path := tracesPath
+		if signal == signalMetrics {
+			path = metricsPath
+		}
+		req, err := http.NewRequest(http.MethodPost, endpoint+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(ctx)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+			return fmt.Errorf("otlp collector returned %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+}
+
+// grpcSender calls the export methods of the services of signals at u, over TLS unless its scheme is http
+func grpcSender(u *url.URL, headers map[string]string) (func(context.Context, int, message) error, error) {
+	creds := grpc.WithInsecure()
+	if u.Scheme == "https" {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	}
+	conn, err := grpc.Dial(u.Host, creds)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to otlp collector: %v", err)
+	}
+	md := make(metadata.MD, len(headers))
+	for k, v := range headers {
+		md.Set(k, v)
+	}
+	return func(ctx context.Context, signal int, req message) error {
+		method := tracesMethod
+		if signal == signalMetrics {
+			method = metricsMethod
+		}
+		var resp message
+		return conn.Invoke(metadata.NewOutgoingContext(ctx, md), method, &req, &resp, grpc.ForceCodec(rawCodec{}))
+	}, nil
+}
+
+// rawCodec passes messages encoded ahead of time through to gRPC, as protobuf
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(*message)
+	if !ok {
+		return nil, fmt.Errorf("unexpected otlp message %T", v)
+	}
+	return *m, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(*message)
+	if !ok {
+		return fmt.Errorf("unexpected otlp message %T", v)
+	}
+	*m = append((*m)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
@@ -0,0 +1,257 @@
+package otlp
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fields returns the values of the fields at path in m, the raw bytes of length delimited fields and the little
+// endian bytes of numbers
+func fields(m []byte, path ...int) [][]byte {
+	var found [][]byte
+	for len(m) > 0 {
+		key, n := binary.Uvarint(m)
+		m = m[n:]
+		var value []byte
+		switch key & 7 {
+		case wireVarint:
+			v, n := binary.Uvarint(m)
+			value = make([]byte, 8)
+			binary.LittleEndian.PutUint64(value, v)
+			m = m[n:]
+		case wireFixed64:
+			value, m = m[:8], m[8:]
+		case wireBytes:
+			l, n := binary.Uvarint(m)
+			value, m = m[n:n+int(l)], m[n+int(l):]
+		}
+		if int(key>>3) != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			found = append(found, value)
+		} else {
+			found = append(found, fields(value, path[1:]...)...)
+		}
+	}
+	return found
+}
+
+// collector records the exports it receives
+type collector struct {
+	lock    sync.Mutex
+	exports map[string][][]byte
+	headers []http.Header
+}
+
+func (c *collector) record(path string, body []byte, h http.Header) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.exports == nil {
+		c.exports = make(map[string][][]byte)
+	}
+	c.exports[path] = append(c.exports[path], body)
+	c.headers = append(c.headers, h)
+}
+
+func (c *collector) get(path string) [][]byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.exports[path]
+}
+
+func testSpan() *trace.SpanData {
+	return &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			SpanID:  trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		},
+		ParentSpanID: trace.SpanID{8, 7, 6, 5, 4, 3, 2, 1},
+		Name:         "agent_submit",
+		SpanKind:     trace.SpanKindServer,
+		StartTime:    time.Unix(1, 0),
+		EndTime:      time.Unix(2, 0),
+		Attributes:   map[string]interface{}{"fn.fn_id": "fn", "error": true},
+		Status:       trace.Status{Code: trace.StatusCodeUnavailable, Message: "busy"},
+	}
+}
+
+func TestExportHTTP(t *testing.T) {
+	var c collector
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		c.record(r.URL.Path, body, r.Header)
+	}))
+	defer srv.Close()
+
+	e, err := NewExporter(Options{Endpoint: srv.URL + "/", Headers: map[string]string{"Authorization": "Bearer token"}, ServiceName: "fnserver", Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.ExportSpan(testSpan())
+	e.ExportSpan(&trace.SpanData{Name: "ok", Status: trace.Status{Code: trace.StatusCodeNotFound}})
+
+	key, _ := tag.NewKey("app_id")
+	measure := stats.Int64("calls", "calls", stats.UnitDimensionless)
+	e.ExportView(&view.Data{
+		View:  &view.View{Name: "latency", Measure: measure, Aggregation: view.Distribution(10, 100)},
+		Start: time.Unix(1, 0),
+		End:   time.Unix(2, 0),
+		Rows: []*view.Row{{
+			Tags: []tag.Tag{{Key: key, Value: "app"}},
+			Data: &view.DistributionData{Count: 3, Min: 1, Max: 200, Mean: 70, CountPerBucket: []int64{1, 1, 1}},
+		}},
+	})
+	e.Close()
+
+	traces := c.get(tracesPath)
+	if len(traces) != 1 {
+		t.Fatalf("expected an export of the spans, got %d", len(traces))
+	}
+	if h := c.headers[0]; h.Get("Content-Type") != "application/x-protobuf" || h.Get("Authorization") != "Bearer token" {
+		t.Errorf("expected a protobuf export with the headers set, got %v", h)
+	}
+	spans := fields(traces[0], 1, 2, 2)
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if name := string(fields(spans[0], 5)[0]); name != "agent_submit" {
+		t.Errorf("expected the name of the span, got %s", name)
+	}
+	if id := fields(spans[0], 1)[0]; id[0] != 1 || id[15] != 16 || len(id) != 16 {
+		t.Errorf("expected the trace id of the span, got %x", id)
+	}
+	if code := fields(spans[0], 15, 3); len(code) != 1 || code[0][0] != statusCodeError {
+		t.Errorf("expected the failed span to have an error status, got %v", code)
+	}
+	if code := fields(spans[1], 15, 3); len(code) != 0 {
+		t.Errorf("expected the status of a span answering not found to be unset, got %v", code)
+	}
+	if service := fields(traces[0], 1, 1, 1); len(service) == 0 {
+		t.Error("expected the resource to be set")
+	}
+
+	metrics := c.get(metricsPath)
+	if len(metrics) != 1 {
+		t.Fatalf("expected an export of the views, got %d", len(metrics))
+	}
+	metric := fields(metrics[0], 1, 2, 2)
+	if len(metric) != 1 || string(fields(metric[0], 1)[0]) != "latency" {
+		t.Fatalf("expected the metric of the view, got %v", metric)
+	}
+	point := fields(metric[0], 9, 1)
+	if len(point) != 1 {
+		t.Fatalf("expected a histogram point, got %v", point)
+	}
+	if count := binary.LittleEndian.Uint64(fields(point[0], 4)[0]); count != 3 {
+		t.Errorf("expected a count of 3, got %d", count)
+	}
+	if bounds := fields(point[0], 7)[0]; len(bounds) != 16 {
+		t.Errorf("expected 2 bounds, got %d bytes", len(bounds))
+	}
+}
+
+// serverCodec lets collectors of tests receive raw messages
+type serverCodec struct{ rawCodec }
+
+func (serverCodec) String() string { return "proto" }
+
+func TestExportGRPC(t *testing.T) {
+	var c collector
+	srv := grpc.NewServer(grpc.CustomCodec(serverCodec{}), grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		var req message
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		method, _ := grpc.MethodFromServerStream(stream)
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		c.record(method, req, http.Header{"Authorization": md.Get("authorization")})
+		return stream.SendMsg(&message{})
+	}))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	e, err := NewExporter(Options{Endpoint: "http://" + l.Addr().String(), Protocol: ProtocolGRPC, Headers: map[string]string{"Authorization": "Bearer token"}, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.ExportSpan(testSpan())
+	e.Close()
+
+	traces := c.get(tracesMethod)
+	if len(traces) != 1 || len(fields(traces[0], 1, 2, 2)) != 1 {
+		t.Fatalf("expected an export of the span to the trace service, got %v", c.exports)
+	}
+	if auth := c.headers[0].Get("Authorization"); auth != "Bearer token" {
+		t.Errorf("expected the headers to be sent as metadata, got %q", auth)
+	}
+}
+
+func TestExporterConfig(t *testing.T) {
+	for _, opts := range []Options{
+		{},
+		{Endpoint: "ftp://collector"},
+		{Endpoint: "http://collector", Protocol: "thrift"},
+	} {
+		if _, err := NewExporter(opts); err == nil {
+			t.Errorf("expected %+v to be invalid", opts)
+		}
+	}
+
+	headers, err := ParseHeaders("api-key=secret, x-tenant=a%20b,")
+	if err != nil || headers["api-key"] != "secret" || headers["x-tenant"] != "a b" {
+		t.Errorf("expected the headers to be parsed, got %v %v", headers, err)
+	}
+	if _, err := ParseHeaders("api-key"); err == nil || !strings.Contains(err.Error(), "key=value") {
+		t.Errorf("expected a header without a value to be invalid, got %v", err)
+	}
+}
+
+// the export of spans queued past a batch starts before the interval
+func TestExportFullBatch(t *testing.T) {
+	exported := make(chan int, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		exported <- len(fields(body, 1, 2, 2))
+	}))
+	defer srv.Close()
+
+	e, err := NewExporter(Options{Endpoint: srv.URL, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	for i := 0; i < maxBatch; i++ {
+		e.ExportSpan(testSpan())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	select {
+	case n := <-exported:
+		if n != maxBatch {
+			t.Errorf("expected a full batch, got %d spans", n)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected a full batch to be exported")
+	}
+}
@@ -0,0 +1,317 @@
+package otlp
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
+)
+
+// message encodes a protobuf message, a field at a time. Only the few wire types OTLP uses are supported.
+type message []byte
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+func (m *message) varint(v uint64) {
+	for v >= 0x80 {
+		*m = append(*m, byte(v)|0x80)
+		v >>= 7
+	}
+	*m = append(*m, byte(v))
+}
+
+func (m *message) tag(field, wire int) {
+	m.varint(uint64(field<<3 | wire))
+}
+
+func (m *message) fixed64(field int, v uint64) {
+	m.tag(field, wireFixed64)
+	m.appendFixed64(v)
+}
+
+// appendFixed64 appends v little-endian, as fixed64 fields and the elements of packed repeated ones are
+func (m *message) appendFixed64(v uint64) {
+	for i := 0; i < 8; i++ {
+		*m = append(*m, byte(v>>(8*uint(i))))
+	}
+}
+
+func (m *message) double(field int, f float64) {
+	m.fixed64(field, math.Float64bits(f))
+}
+
+func (m *message) uint(field int, v uint64) {
+	if v != 0 {
+		m.tag(field, wireVarint)
+		m.varint(v)
+	}
+}
+
+func (m *message) bytes(field int, b []byte) {
+	m.tag(field, wireBytes)
+	m.varint(uint64(len(b)))
+	*m = append(*m, b...)
+}
+
+func (m *message) string(field int, s string) {
+	if s != "" {
+		m.bytes(field, []byte(s))
+	}
+}
+
+func (m *message) message(field int, sub message) {
+	m.bytes(field, sub)
+}
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+// keyValue encodes an attribute, skipping values of types OTLP attributes cannot hold
+func keyValue(key string, value interface{}) (message, bool) {
+	var v message
+	switch value := value.(type) {
+	case string:
+		v.bytes(1, []byte(value))
+	case bool:
+		v.tag(2, wireVarint)
+		if value {
+			v.varint(1)
+		} else {
+			v.varint(0)
+		}
+	case int64:
+		v.tag(3, wireVarint)
+		v.varint(uint64(value))
+	case float64:
+		v.double(4, value)
+	default:
+		return nil, false
+	}
+	var kv message
+	kv.string(1, key)
+	kv.message(2, v)
+	return kv, true
+}
+
+// attributes adds attrs to m as field, in a stable order
+func (m *message) attributes(field int, attrs map[string]interface{}) {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if kv, ok := keyValue(k, attrs[k]); ok {
+			m.message(field, kv)
+		}
+	}
+}
+
+// resource encodes the resource and instrumentation scope all telemetry of the exporter is reported under
+func (e *Exporter) resource() (resource, scope message) {
+	resource.attributes(1, e.resourceAttrs)
+	scope.string(1, instrumentationScope)
+	scope.string(2, e.opts.ServiceVersion)
+	return resource, scope
+}
+
+// Span kinds and status codes of OTLP
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+
+	statusCodeError = 2
+)
+
+// encodeSpans encodes spans as an ExportTraceServiceRequest
+func (e *Exporter) encodeSpans(spans []*trace.SpanData) message {
+	resource, scope := e.resource()
+	var scopeSpans message
+	scopeSpans.message(1, scope)
+	for _, s := range spans {
+		scopeSpans.message(2, encodeSpan(s))
+	}
+
+	var resourceSpans message
+	resourceSpans.message(1, resource)
+	resourceSpans.message(2, scopeSpans)
+	var req message
+	req.message(1, resourceSpans)
+	return req
+}
+
+func encodeSpan(s *trace.SpanData) message {
+	var span message
+	span.bytes(1, s.TraceID[:])
+	span.bytes(2, s.SpanID[:])
+	if s.Tracestate != nil {
+		var state string
+		for i, entry := range s.Tracestate.Entries() {
+			if i > 0 {
+				state += ","
+			}
+			state += entry.Key + "=" + entry.Value
+		}
+		span.string(3, state)
+	}
+	if s.ParentSpanID != (trace.SpanID{}) {
+		span.bytes(4, s.ParentSpanID[:])
+	}
+	span.string(5, s.Name)
+	switch s.SpanKind {
+	case trace.SpanKindServer:
+		span.uint(6, spanKindServer)
+	case trace.SpanKindClient:
+		span.uint(6, spanKindClient)
+	default:
+		span.uint(6, spanKindInternal)
+	}
+	span.fixed64(7, unixNano(s.StartTime))
+	span.fixed64(8, unixNano(s.EndTime))
+	span.attributes(9, s.Attributes)
+	span.uint(10, uint64(s.DroppedAttributeCount))
+	for _, a := range s.Annotations {
+		var event message
+		event.fixed64(1, unixNano(a.Time))
+		event.string(2, a.Message)
+		event.attributes(3, a.Attributes)
+		span.message(11, event)
+	}
+	span.uint(12, uint64(s.DroppedAnnotationCount))
+
+	var status message
+	if failed(s) {
+		status.string(2, s.Message)
+		status.uint(3, statusCodeError)
+	}
+	span.message(15, status)
+	return span
+}
+
+// failed returns whether the status of s is a failure rather than an answer to the caller, such as a missing
+// resource, see common.EndSpan
+func failed(s *trace.SpanData) bool {
+	if s.Attributes["error"] == true {
+		return true
+	}
+	switch s.Code {
+	case trace.StatusCodeOK, trace.StatusCodeCancelled, trace.StatusCodeInvalidArgument, trace.StatusCodeNotFound,
+		trace.StatusCodeAlreadyExists, trace.StatusCodePermissionDenied, trace.StatusCodeResourceExhausted,
+		trace.StatusCodeFailedPrecondition, trace.StatusCodeOutOfRange, trace.StatusCodeUnauthenticated:
+		return false
+	}
+	return true
+}
+
+// Aggregation temporality of OTLP metrics, views are cumulative
+const temporalityCumulative = 2
+
+// encodeViews encodes the data of views as an ExportMetricsServiceRequest
+func (e *Exporter) encodeViews(views []*view.Data) message {
+	resource, scope := e.resource()
+	var scopeMetrics message
+	scopeMetrics.message(1, scope)
+	for _, vd := range views {
+		if metric, ok := encodeView(vd); ok {
+			scopeMetrics.message(2, metric)
+		}
+	}
+
+	var resourceMetrics message
+	resourceMetrics.message(1, resource)
+	resourceMetrics.message(2, scopeMetrics)
+	var req message
+	req.message(1, resourceMetrics)
+	return req
+}
+
+func encodeView(vd *view.Data) (message, bool) {
+	if vd.View == nil || vd.View.Aggregation == nil {
+		return nil, false
+	}
+	start, end := unixNano(vd.Start), unixNano(vd.End)
+
+	var points message
+	for _, row := range vd.Rows {
+		attrs := make(map[string]interface{}, len(row.Tags))
+		for _, t := range row.Tags {
+			attrs[t.Key.Name()] = t.Value
+		}
+
+		var point message
+		switch data := row.Data.(type) {
+		case *view.CountData:
+			point.fixed64(2, start)
+			point.fixed64(3, end)
+			point.fixed64(6, uint64(data.Value))
+			point.attributes(7, attrs)
+		case *view.SumData:
+			point.fixed64(2, start)
+			point.fixed64(3, end)
+			point.double(4, data.Value)
+			point.attributes(7, attrs)
+		case *view.LastValueData:
+			point.fixed64(3, end)
+			point.double(4, data.Value)
+			point.attributes(7, attrs)
+		case *view.DistributionData:
+			point.fixed64(2, start)
+			point.fixed64(3, end)
+			point.fixed64(4, uint64(data.Count))
+			point.double(5, data.Mean*float64(data.Count))
+			var counts, bounds message
+			for _, c := range data.CountPerBucket {
+				counts.appendFixed64(uint64(c))
+			}
+			for _, b := range vd.View.Aggregation.Buckets {
+				bounds.appendFixed64(math.Float64bits(b))
+			}
+			point.bytes(6, counts)
+			point.bytes(7, bounds)
+			point.attributes(9, attrs)
+			if data.Count > 0 {
+				point.double(11, data.Min)
+				point.double(12, data.Max)
+			}
+		default:
+			continue
+		}
+		points.message(1, point)
+	}
+
+	var metric message
+	metric.string(1, vd.View.Name)
+	metric.string(2, vd.View.Description)
+	if vd.View.Measure != nil {
+		metric.string(3, vd.View.Measure.Unit())
+	}
+	switch vd.View.Aggregation.Type {
+	case view.AggTypeCount:
+		points.uint(2, temporalityCumulative)
+		points.uint(3, 1) // monotonic
+		metric.message(7, points)
+	case view.AggTypeSum:
+		// sums of views such as running calls go up and down
+		points.uint(2, temporalityCumulative)
+		metric.message(7, points)
+	case view.AggTypeLastValue:
+		metric.message(5, points)
+	case view.AggTypeDistribution:
+		points.uint(2, temporalityCumulative)
+		metric.message(9, points)
+	default:
+		return nil, false
+	}
+	return metric, true
+}
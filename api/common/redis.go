@@ -0,0 +1,199 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// redisTimeout bounds the round trips to redis of contexts without a deadline
+	redisTimeout = 5 * time.Second
+	// redisMaxIdleConns is how many connections to redis a client keeps open between commands
+	redisMaxIdleConns = 16
+)
+
+// RedisClient runs commands on a redis, such as the one keeping the distributed locks, over connections it keeps
+// open between commands. It is safe for concurrent use.
+type RedisClient struct {
+	addr     string
+	password string
+	db       int
+	idle     chan *redisConn
+}
+
+// redisConn is a connection to redis, authenticated and on the db of its client
+type redisConn struct {
+	net.Conn
+	rd *bufio.Reader
+}
+
+// NewRedisClient returns a client of the redis at redisURL, such as redis://:password@localhost:6379/0
+func NewRedisClient(redisURL string) (*RedisClient, error) {
+	u, err := url.Parse(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid redis url scheme %q, it must be redis", u.Scheme)
+	}
+	r := &RedisClient{addr: u.Host, idle: make(chan *redisConn, redisMaxIdleConns)}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis db %q", db)
+		}
+	}
+	return r, nil
+}
+
+// Do runs a command, returning its reply as a string, int64, nil, or []interface{} of them. Error replies are
+// returned as errors.
+func (r *RedisClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	conn, err := r.conn(ctx, deadline)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := redisCommand(conn, conn.rd, args...)
+	if _, ok := err.(redisError); err != nil && !ok {
+		// the connection may be left mid reply
+		conn.Close()
+		return nil, err
+	}
+	r.put(conn)
+	return reply, err
+}
+
+// conn returns an idle connection, or a new one, with deadline set
+func (r *RedisClient) conn(ctx context.Context, deadline time.Time) (*redisConn, error) {
+	select {
+	case conn := <-r.idle:
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	default:
+	}
+
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: c, rd: bufio.NewReader(c)}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if r.password != "" {
+		if _, err := redisCommand(conn, conn.rd, "AUTH", r.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.db != 0 {
+		if _, err := redisCommand(conn, conn.rd, "SELECT", strconv.Itoa(r.db)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (r *RedisClient) put(conn *redisConn) {
+	select {
+	case r.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// Close closes the idle connections of the client
+func (r *RedisClient) Close() error {
+	for {
+		select {
+		case conn := <-r.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// redisCommand writes a command as a RESP array of bulk strings, and reads its reply
+func redisCommand(w io.Writer, rd *bufio.Reader, args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(rd)
+}
+
+// redisError is an error reply of redis
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// readRedisReply reads a RESP reply, as a string, int64, nil, or []interface{} of them
+func readRedisReply(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRedisReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: invalid reply %q", line)
+}
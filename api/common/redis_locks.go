@@ -0,0 +1,63 @@
+package common
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+const (
+	// redisLockPrefix prefixes the keys of the locks in redis
+	redisLockPrefix = "fn:lock:"
+
+	// redisAcquireScript sets the key of a lock to its holder, unless another holder holds it, expiring after ARGV[2]
+	// milliseconds
+	redisAcquireScript = `local v = redis.call('GET', KEYS[1])
+if v == false or v == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0`
+	// redisReleaseScript deletes the key of a lock, if its holder holds it
+	redisReleaseScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`
+)
+
+// redisLockStore keeps locks as keys of redis expiring with them, set and deleted by scripts checking their holder
+type redisLockStore struct {
+	client *RedisClient
+}
+
+// NewRedisLockStore returns a LockStore keeping the locks in the redis at redisURL, such as
+// redis://:password@localhost:6379/0
+func NewRedisLockStore(redisURL string) (LockStore, error) {
+	client, err := NewRedisClient(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &redisLockStore{client: client}, nil
+}
+
+// AcquireLock implements LockStore
+func (r *redisLockStore) AcquireLock(ctx context.Context, name, holder string, expires time.Time) error {
+	ms := time.Until(expires).Nanoseconds() / int64(time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	reply, err := r.client.Do(ctx, "EVAL", redisAcquireScript, "1", redisLockPrefix+name, holder, strconv.FormatInt(ms, 10))
+	if err != nil {
+		return err
+	}
+	if reply != int64(1) {
+		return ErrLockHeld
+	}
+	return nil
+}
+
+// ReleaseLock implements LockStore
+func (r *redisLockStore) ReleaseLock(ctx context.Context, name, holder string) error {
+	_, err := r.client.Do(ctx, "EVAL", redisReleaseScript, "1", redisLockPrefix+name, holder)
+	return err
+}
@@ -0,0 +1,31 @@
+package sigv4
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestSign checks the signatures of cases of the AWS signature version 4 test suite
+func TestSign(t *testing.T) {
+	now, _ := time.Parse("20060102T150405Z", "20150830T123600Z")
+	creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+	for url, expected := range map[string]string{
+		// get-vanilla
+		"https://example.amazonaws.com/": "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		// get-vanilla-query-order-key-case
+		"https://example.amazonaws.com/?Param2=value2&Param1=value1": "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+	} {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		Sign(req, nil, creds, "us-east-1", "service", now)
+		if auth := req.Header.Get("Authorization"); auth != expected {
+			t.Errorf("%s: expected %s, got %s", url, expected, auth)
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package common
 
 import (
+	"context"
 	"math"
 
 	"github.com/sirupsen/logrus"
+	"go.opencensus.io/metric/metricdata"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
 )
 
 func CreateView(measure stats.Measure, agg *view.Aggregation, tagKeys []string) *view.View {
@@ -24,6 +27,19 @@ func CreateViewWithTags(measure stats.Measure, agg *view.Aggregation, tags []tag
 	}
 }
 
+// RecordWithExemplar records measurements as stats.Record does, attaching the span of ctx to them if it is sampled.
+// Distributions keep the last span attached to each of their buckets as an exemplar, so that exporters supporting
+// exemplars can link a bucket of latencies to the trace of a call that fell into it.
+func RecordWithExemplar(ctx context.Context, ms ...stats.Measurement) {
+	opts := []stats.Options{stats.WithMeasurements(ms...)}
+	if span := trace.FromContext(ctx); span != nil && span.SpanContext().IsSampled() {
+		opts = append(opts, stats.WithAttachments(metricdata.Attachments{
+			metricdata.AttachmentKeySpanContext: span.SpanContext(),
+		}))
+	}
+	stats.RecordWithOptions(ctx, opts...)
+}
+
 func MakeMeasure(name string, desc string, unit string) *stats.Int64Measure {
 	return stats.Int64(name, desc, unit)
 }
@@ -1,9 +1,13 @@
 package common
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
 )
 
 func TestGenerateLogScaleHistogramBucketsWithRange(t *testing.T) {
@@ -17,3 +21,36 @@ func TestGenerateLinearHistogramBuckets(t *testing.T) {
 func TestGenerateLogScaleHistogramBuckets(t *testing.T) {
 	assert.Equal(t, []float64{0, 0.1953125, 0.390625, 0.78125, 1.5625, 3.125, 6.25, 12.5, 25, 50, 100}, GenerateLogScaleHistogramBuckets(100, 10))
 }
+
+func TestRecordWithExemplar(t *testing.T) {
+	measure := MakeMeasure("test_exemplar_latency", "test latency", "msecs")
+	v := CreateView(measure, view.Distribution(10, 100), nil)
+	if err := view.Register(v); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(v)
+
+	exemplar := func() *metricdata.Exemplar {
+		rows, err := view.RetrieveData(v.Name)
+		if err != nil || len(rows) != 1 {
+			t.Fatalf("expected a row, got %v %v", rows, err)
+		}
+		return rows[0].Data.(*view.DistributionData).ExemplarsPerBucket[1]
+	}
+
+	// unsampled spans are not worth linking to
+	ctx, span := trace.StartSpan(context.Background(), "unsampled", trace.WithSampler(trace.NeverSample()))
+	RecordWithExemplar(ctx, measure.M(50))
+	span.End()
+	if e := exemplar(); e != nil {
+		t.Fatalf("expected no exemplar for an unsampled span, got %+v", e)
+	}
+
+	ctx, span = trace.StartSpan(context.Background(), "sampled", trace.WithSampler(trace.AlwaysSample()))
+	RecordWithExemplar(ctx, measure.M(50))
+	span.End()
+	e := exemplar()
+	if e == nil || e.Value != 50 || e.Attachments[metricdata.AttachmentKeySpanContext] != span.SpanContext() {
+		t.Fatalf("expected an exemplar of the sampled span, got %+v", e)
+	}
+}
@@ -0,0 +1,155 @@
+package common
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opencensus.io/plugin/ocgrpc"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+)
+
+const (
+	// TraceParentHeader is the header the W3C trace context of a request is propagated in, and the gRPC metadata key
+	// of that of a call
+	TraceParentHeader = "traceparent"
+	// grpcTraceBinKey is the gRPC metadata key ocgrpc propagates the binary trace context of a call in
+	grpcTraceBinKey = "grpc-trace-bin"
+)
+
+// coder is implemented by errors with an http status code, such as models.APIError
+type coder interface {
+	Code() int
+}
+
+// traceStatusCode returns the trace status code of err
+func traceStatusCode(err error) int32 {
+	switch err {
+	case context.Canceled:
+		return trace.StatusCodeCancelled
+	case context.DeadlineExceeded:
+		return trace.StatusCodeDeadlineExceeded
+	}
+	c, ok := err.(coder)
+	if !ok {
+		return trace.StatusCodeUnknown
+	}
+	switch c.Code() {
+	case http.StatusBadRequest:
+		return trace.StatusCodeInvalidArgument
+	case http.StatusUnauthorized:
+		return trace.StatusCodeUnauthenticated
+	case http.StatusForbidden:
+		return trace.StatusCodePermissionDenied
+	case http.StatusNotFound:
+		return trace.StatusCodeNotFound
+	case http.StatusConflict:
+		return trace.StatusCodeAlreadyExists
+	case http.StatusTooManyRequests:
+		return trace.StatusCodeResourceExhausted
+	case http.StatusServiceUnavailable:
+		return trace.StatusCodeUnavailable
+	case http.StatusGatewayTimeout:
+		return trace.StatusCodeDeadlineExceeded
+	}
+	return trace.StatusCodeUnknown
+}
+
+// EndSpan ends span, recording the status of err if it is set. Errors are tagged as such, unless they are the
+// caller's, with a 4xx code, as a missing app or a conflicting name are answers rather than failures.
+func EndSpan(span *trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(trace.Status{Code: traceStatusCode(err), Message: err.Error()})
+		if c, ok := err.(coder); !ok || c.Code() >= 500 {
+			span.AddAttributes(trace.BoolAttribute("error", true))
+		}
+	}
+	span.End()
+}
+
+// TraceParent formats sc as the value of a W3C traceparent header
+func TraceParent(sc trace.SpanContext) string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), uint32(sc.TraceOptions)&1)
+}
+
+// ParseTraceParent parses the value of a W3C traceparent header, returning false if it is invalid
+func ParseTraceParent(h string) (trace.SpanContext, bool) {
+	var sc trace.SpanContext
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	// versions past 00 may add fields, which are ignored
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 {
+		return sc, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil || sc.TraceID == (trace.TraceID{}) {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil || sc.SpanID == (trace.SpanID{}) {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.TraceOptions = trace.TraceOptions(flags[0] & 1)
+	return sc, true
+}
+
+// TraceContextFormat propagates spans over http in W3C traceparent headers, falling back to another format, if
+// set, for requests without one
+type TraceContextFormat struct {
+	Fallback propagation.HTTPFormat
+}
+
+var _ propagation.HTTPFormat = TraceContextFormat{}
+
+// SpanContextFromRequest implements propagation.HTTPFormat
+func (f TraceContextFormat) SpanContextFromRequest(req *http.Request) (trace.SpanContext, bool) {
+	if h := req.Header.Get(TraceParentHeader); h != "" {
+		return ParseTraceParent(h)
+	}
+	if f.Fallback != nil {
+		return f.Fallback.SpanContextFromRequest(req)
+	}
+	return trace.SpanContext{}, false
+}
+
+// SpanContextToRequest implements propagation.HTTPFormat
+func (f TraceContextFormat) SpanContextToRequest(sc trace.SpanContext, req *http.Request) {
+	req.Header.Set(TraceParentHeader, TraceParent(sc))
+	if f.Fallback != nil {
+		f.Fallback.SpanContextToRequest(sc, req)
+	}
+}
+
+// TraceParentServerHandler is a gRPC stats handler tracing calls as ocgrpc.ServerHandler does, continuing the trace
+// of clients that send a W3C traceparent in their metadata rather than the binary trace context of ocgrpc
+type TraceParentServerHandler struct {
+	ocgrpc.ServerHandler
+}
+
+var _ stats.Handler = new(TraceParentServerHandler)
+
+// TagRPC implements stats.Handler
+func (h *TraceParentServerHandler) TagRPC(ctx context.Context, rti *stats.RPCTagInfo) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(TraceParentHeader); len(values) > 0 && len(md.Get(grpcTraceBinKey)) == 0 {
+		if sc, ok := ParseTraceParent(values[0]); ok {
+			md = md.Copy()
+			md.Set(grpcTraceBinKey, string(propagation.Binary(sc)))
+			ctx = metadata.NewIncomingContext(ctx, md)
+		}
+	}
+	return h.ServerHandler.TagRPC(ctx, rti)
+}
@@ -0,0 +1,118 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+)
+
+type spanRecorder struct {
+	spans []*trace.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *trace.SpanData) { r.spans = append(r.spans, s) }
+
+type codedError int
+
+func (e codedError) Error() string { return http.StatusText(int(e)) }
+func (e codedError) Code() int     { return int(e) }
+
+func TestEndSpan(t *testing.T) {
+	rec := new(spanRecorder)
+	trace.RegisterExporter(rec)
+	defer trace.UnregisterExporter(rec)
+
+	for _, test := range []struct {
+		err    error
+		status int32
+		tagged bool
+	}{
+		{nil, trace.StatusCodeOK, false},
+		{codedError(http.StatusNotFound), trace.StatusCodeNotFound, false},
+		{codedError(http.StatusServiceUnavailable), trace.StatusCodeUnavailable, true},
+		{context.DeadlineExceeded, trace.StatusCodeDeadlineExceeded, true},
+		{errors.New("connection refused"), trace.StatusCodeUnknown, true},
+	} {
+		rec.spans = nil
+		_, span := trace.StartSpan(context.Background(), "test", trace.WithSampler(trace.AlwaysSample()))
+		EndSpan(span, test.err)
+		if len(rec.spans) != 1 {
+			t.Fatalf("expected a span to be exported, got %d", len(rec.spans))
+		}
+		s := rec.spans[0]
+		if s.Status.Code != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.err, test.status, s.Status.Code)
+		}
+		if tagged, _ := s.Attributes["error"].(bool); tagged != test.tagged {
+			t.Errorf("%v: expected error tag %v, got %v", test.err, test.tagged, tagged)
+		}
+	}
+}
+
+func TestTraceParent(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceParent(header)
+	if !ok || !sc.IsSampled() {
+		t.Fatalf("expected a sampled span context, got %v %v", sc, ok)
+	}
+	if got := TraceParent(sc); got != header {
+		t.Errorf("expected the header to round trip, got %s", got)
+	}
+	if _, ok := ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future"); !ok {
+		t.Error("expected fields of later versions to be ignored")
+	}
+
+	for _, invalid := range []string{
+		"",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		"00-xbf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, ok := ParseTraceParent(invalid); ok {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if _, ok := (TraceContextFormat{}).SpanContextFromRequest(req); ok {
+		t.Error("expected no span context without a header")
+	}
+	TraceContextFormat{}.SpanContextToRequest(sc, req)
+	if got, ok := (TraceContextFormat{}).SpanContextFromRequest(req); !ok || got.TraceID != sc.TraceID || got.SpanID != sc.SpanID {
+		t.Errorf("expected the span context to be propagated, got %v", got)
+	}
+}
+
+func TestTraceParentServerHandler(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	parent, _ := ParseTraceParent(header)
+	h := &TraceParentServerHandler{}
+	rti := &stats.RPCTagInfo{FullMethodName: "/runner.RunnerProtocol/Engage"}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(TraceParentHeader, header))
+	sc := trace.FromContext(h.TagRPC(ctx, rti)).SpanContext()
+	if sc.TraceID != parent.TraceID || !sc.IsSampled() {
+		t.Fatalf("expected the call to continue the trace of its traceparent, got %v", sc)
+	}
+
+	// the binary trace context of ocgrpc clients wins
+	other := trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, TraceOptions: 1}
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(TraceParentHeader, header, grpcTraceBinKey, string(propagation.Binary(other))))
+	if sc := trace.FromContext(h.TagRPC(ctx, rti)).SpanContext(); sc.TraceID != other.TraceID {
+		t.Fatalf("expected the call to continue the trace of its binary trace context, got %v", sc)
+	}
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(TraceParentHeader, "invalid"))
+	if sc := trace.FromContext(h.TagRPC(ctx, rti)).SpanContext(); sc.TraceID == parent.TraceID {
+		t.Fatalf("expected an invalid traceparent to start a new trace, got %v", sc)
+	}
+}
@@ -0,0 +1,293 @@
+// Package config reads the settings of fn from the environment. Every setting read through it is recorded, with
+// the value in effect, so that the effective configuration can be reported, and so that settings nobody read, most
+// likely misspelled, can be warned about at startup.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix is the prefix of the environment variables of fn
+const Prefix = "FN_"
+
+// Setting is a setting of fn and the value in effect
+type Setting struct {
+	Name string `json:"name"`
+	// Value in effect, redacted for secrets
+	Value string `json:"value"`
+	// Set is whether the value came from the environment rather than the default
+	Set bool `json:"set"`
+}
+
+var (
+	lock     sync.Mutex
+	settings = make(map[string]Setting)
+	checks   []func() error
+)
+
+// Lookup looks up a setting under its name in the environment, or name+_FILE to read the value from a file
+func Lookup(name string) (string, bool) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	if path, ok := os.LookupEnv(name + "_FILE"); ok {
+		dat, err := ioutil.ReadFile(filepath.Clean(path))
+		if err == nil {
+			return strings.TrimSpace(string(dat)), true
+		}
+	}
+	return "", false
+}
+
+// Record records the value in effect of a setting read without this package
+func Record(name string, value interface{}, set bool) {
+	lock.Lock()
+	settings[name] = Setting{Name: name, Value: fmt.Sprint(value), Set: set}
+	lock.Unlock()
+}
+
+// Known declares settings read by extensions that are not worth recording, so they are not reported as unknown
+func Known(names ...string) {
+	lock.Lock()
+	for _, name := range names {
+		if _, ok := settings[name]; !ok {
+			settings[name] = Setting{Name: name}
+		}
+	}
+	lock.Unlock()
+}
+
+// String returns the setting name, or fallback if it is not set
+func String(name, fallback string) string {
+	value, ok := Lookup(name)
+	if !ok {
+		value = fallback
+	}
+	Record(name, value, ok)
+	return value
+}
+
+// Int returns the setting name, or fallback if it is not set
+func Int(name string, fallback int) (int, error) {
+	value, ok := Lookup(name)
+	i := fallback
+	if ok {
+		var err error
+		if i, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+			return fallback, fmt.Errorf("invalid %s=%s, it must be an integer", name, value)
+		}
+	}
+	Record(name, i, ok)
+	return i, nil
+}
+
+// Uint returns the setting name, or fallback if it is not set
+func Uint(name string, fallback uint64) (uint64, error) {
+	value, ok := Lookup(name)
+	u := fallback
+	if ok {
+		var err error
+		if u, err = strconv.ParseUint(strings.TrimSpace(value), 10, 64); err != nil {
+			return fallback, fmt.Errorf("invalid %s=%s, it must be a positive integer", name, value)
+		}
+	}
+	Record(name, u, ok)
+	return u, nil
+}
+
+// Bool returns the setting name, or fallback if it is not set or empty
+func Bool(name string, fallback bool) (bool, error) {
+	value, ok := Lookup(name)
+	b := fallback
+	if ok && value != "" {
+		var err error
+		if b, err = strconv.ParseBool(strings.TrimSpace(value)); err != nil {
+			return fallback, fmt.Errorf("invalid %s=%s, it must be a boolean", name, value)
+		}
+	}
+	Record(name, b, ok)
+	return b, nil
+}
+
+// Duration returns the setting name, a duration like 1m30s or a number of seconds, or fallback if it is not set or
+// empty
+func Duration(name string, fallback time.Duration) (time.Duration, error) {
+	value, ok := Lookup(name)
+	d := fallback
+	if ok && value != "" {
+		var err error
+		if d, err = ParseDuration(value); err != nil {
+			return fallback, fmt.Errorf("invalid %s=%s, %v", name, value, err)
+		}
+	}
+	Record(name, d, ok)
+	return d, nil
+}
+
+// ParseDuration parses a duration like 1m30s or a number of seconds
+func ParseDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		s, serr := strconv.Atoi(value)
+		if serr != nil {
+			return 0, errors.New("it must be a duration or a number of seconds")
+		}
+		d = time.Duration(s) * time.Second
+	}
+	return d, nil
+}
+
+// Check adds a check of the settings together, eg. for settings that conflict, run by Report
+func Check(check func() error) {
+	lock.Lock()
+	checks = append(checks, check)
+	lock.Unlock()
+}
+
+// IsSet returns whether the setting name was read from the environment
+func IsSet(name string) bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return settings[name].Set
+}
+
+// Settings returns the settings read so far with the values in effect, sorted by name. The values of settings
+// that look like secrets, and the passwords in URLs, are redacted.
+func Settings() []Setting {
+	lock.Lock()
+	list := make([]Setting, 0, len(settings))
+	for _, s := range settings {
+		if s.Value == "" && !s.Set {
+			continue // declared Known only
+		}
+		s.Value = redact(s.Name, s.Value)
+		list = append(list, s)
+	}
+	lock.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Report returns a warning for each setting of the environment that was not read, with the known setting it most
+// likely misspells, and for each failed Check
+func Report() []string {
+	lock.Lock()
+	known := make([]string, 0, len(settings))
+	for name := range settings {
+		known = append(known, name)
+	}
+	checks := checks
+	lock.Unlock()
+	sort.Strings(known)
+
+	var warnings []string
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if !strings.HasPrefix(name, Prefix) {
+			continue
+		}
+		if isKnown(known, name) || isKnown(known, strings.TrimSuffix(name, "_FILE")) {
+			continue
+		}
+		warning := fmt.Sprintf("unknown setting %s", name)
+		if guess := closest(known, name); guess != "" {
+			warning += fmt.Sprintf(", did you mean %s?", guess)
+		}
+		warnings = append(warnings, warning)
+	}
+	sort.Strings(warnings)
+
+	for _, check := range checks {
+		if err := check(); err != nil {
+			warnings = append(warnings, err.Error())
+		}
+	}
+	return warnings
+}
+
+func isKnown(known []string, name string) bool {
+	i := sort.SearchStrings(known, name)
+	return i < len(known) && known[i] == name
+}
+
+// closest returns the known setting closest to name, if it is close enough to be a typo
+func closest(known []string, name string) string {
+	best, bestDist := "", 4
+	for _, k := range known {
+		if d := distance(k, name); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance of a and b
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+var (
+	// exporter headers carry the credentials of collectors, eg. Authorization=Bearer ..., unlike the header lists
+	// of the other *_HEADERS settings
+	secretName  = regexp.MustCompile(`PASSWORD|SECRET|TOKEN|AUTH|_KEYS?$|CREDENTIALS|OTLP_HEADERS$`)
+	urlPassword = regexp.MustCompile(`(://[^:/@\s]*):[^@\s]*@`)
+)
+
+const redacted = "REDACTED"
+
+func redact(name, value string) string {
+	if value == "" {
+		return value
+	}
+	if secretName.MatchString(name) {
+		return redacted
+	}
+	return urlPassword.ReplaceAllString(value, "$1:"+redacted+"@")
+}
+
+// reset forgets the settings read so far, for tests
+func reset() {
+	lock.Lock()
+	settings = make(map[string]Setting)
+	checks = nil
+	tunables = make(map[string]func(string) error)
+	changes = nil
+	lock.Unlock()
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxChanges is how many of the most recent runtime changes of settings are kept
+const maxChanges = 100
+
+// ErrNotTunable is returned for settings that cannot be changed at runtime
+var ErrNotTunable = errors.New("setting cannot be changed at runtime")
+
+// Change is a change of a setting at runtime
+type Change struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+	// By is who made the change
+	By   string    `json:"by"`
+	Time time.Time `json:"time"`
+}
+
+var (
+	tunables = make(map[string]func(string) error)
+	changes  []Change
+
+	// tuneLock serializes changes, so that the value recorded is the one last applied
+	tuneLock sync.Mutex
+)
+
+// Tunable declares that the setting name may be changed while fn runs. apply applies a new value, or returns an
+// error if it rejects it. Only settings that are safe to change at any time, without a restart, should be tunable.
+func Tunable(name string, apply func(value string) error) {
+	lock.Lock()
+	tunables[name] = apply
+	lock.Unlock()
+}
+
+// Tune changes the tunable setting name to value and records the change, made by by, for auditing
+func Tune(name, value, by string) (Change, error) {
+	tuneLock.Lock()
+	defer tuneLock.Unlock()
+
+	lock.Lock()
+	apply, ok := tunables[name]
+	old := settings[name].Value
+	lock.Unlock()
+	if !ok {
+		return Change{}, ErrNotTunable
+	}
+	if err := apply(value); err != nil {
+		return Change{}, err
+	}
+
+	change := Change{Name: name, Old: redact(name, old), New: redact(name, value), By: by, Time: time.Now()}
+	lock.Lock()
+	settings[name] = Setting{Name: name, Value: value, Set: true}
+	changes = append(changes, change)
+	if len(changes) > maxChanges {
+		changes = changes[len(changes)-maxChanges:]
+	}
+	lock.Unlock()
+	return change, nil
+}
+
+// Tunables returns the tunable settings with the values in effect, sorted by name
+func Tunables() []Setting {
+	lock.Lock()
+	list := make([]Setting, 0, len(tunables))
+	for name := range tunables {
+		s := settings[name]
+		s.Name = name
+		s.Value = redact(name, s.Value)
+		list = append(list, s)
+	}
+	lock.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Changes returns the most recent runtime changes of settings, oldest first
+func Changes() []Change {
+	lock.Lock()
+	defer lock.Unlock()
+	return append([]Change(nil), changes...)
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTune(t *testing.T) {
+	reset()
+	defer reset()
+
+	level := String("FN_TEST_LEVEL", "info")
+	Tunable("FN_TEST_LEVEL", func(value string) error {
+		if value != "debug" && value != "info" {
+			return errors.New("unknown level")
+		}
+		level = value
+		return nil
+	})
+
+	if _, err := Tune("FN_TEST_PORT", "80", "tester"); err != ErrNotTunable {
+		t.Fatalf("expected settings that are not tunable to be rejected, got %v", err)
+	}
+	if _, err := Tune("FN_TEST_LEVEL", "loud", "tester"); err == nil {
+		t.Fatal("expected the rejected value to fail")
+	}
+	if len(Changes()) != 0 {
+		t.Fatalf("expected no changes, got %+v", Changes())
+	}
+
+	change, err := Tune("FN_TEST_LEVEL", "debug", "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level != "debug" || change.Old != "info" || change.New != "debug" || change.By != "tester" {
+		t.Fatalf("unexpected change %+v, level %s", change, level)
+	}
+	if changes := Changes(); len(changes) != 1 || changes[0] != change {
+		t.Fatalf("expected the change to be recorded, got %+v", changes)
+	}
+	if tunables := Tunables(); len(tunables) != 1 || tunables[0].Value != "debug" || !tunables[0].Set {
+		t.Fatalf("expected the value in effect to be debug, got %+v", tunables)
+	}
+}
@@ -9,10 +9,18 @@ const (
 
 	// TriggerID is the url path parameter for trigger id
 	TriggerID string = "trigger_id"
+	// TriggerRunID is the url path parameter for trigger run id
+	TriggerRunID string = "run_id"
 	// CallID is the url path parameter for call id
 	CallID string = "call_id"
 	// FnID is the url path parameter for fn id
 	FnID string = "fn_id"
+	// WorkflowID is the url path parameter for workflow id
+	WorkflowID string = "workflow_id"
+	// WorkflowRunID is the url path parameter for workflow run id
+	WorkflowRunID string = "run_id"
+	// DeploymentID is the url path parameter for fn deployment id
+	DeploymentID string = "deployment_id"
 	// TriggerSource is the triggers source parameter
 	TriggerSource string = "trigger_source"
 
@@ -42,6 +42,33 @@ type Provider interface {
 	New(ctx context.Context, url *url.URL) (models.Datastore, error)
 }
 
+// ChangeWatcher is implemented by the providers of data stores which publish their changes to the servers sharing
+// them, such as postgres
+type ChangeWatcher interface {
+	// WatchChanges calls fn with the changes published to the data store at url, until ctx is done
+	WatchChanges(ctx context.Context, url *url.URL, fn func(models.DatastoreChange)) error
+}
+
+// WatchChanges calls fn with the changes to the apps, fns and triggers the servers sharing the data store at dbURL
+// publish, until ctx is done. Servers without a data store of their own, such as LB nodes, use it to invalidate
+// their caches. It fails if the data store does not publish its changes.
+func WatchChanges(ctx context.Context, dbURL string, fn func(models.DatastoreChange)) error {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return fmt.Errorf("bad DB URL: %v", err)
+	}
+	for _, provider := range providers {
+		if provider.Supports(u) {
+			w, ok := provider.(ChangeWatcher)
+			if !ok {
+				return fmt.Errorf("data store provider %s does not publish changes", provider)
+			}
+			return w.WatchChanges(ctx, u, fn)
+		}
+	}
+	return fmt.Errorf("no data store provider found for storage url %s", common.MaskPassword(u))
+}
+
 var providers []Provider
 
 // Register globally registers a data store provider
@@ -49,3 +76,12 @@ func Register(provider Provider) {
 	logrus.Infof("Registering data store provider '%s'", provider)
 	providers = append(providers, provider)
 }
+
+// Providers returns the names of the registered data store providers
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		names = append(names, provider.String())
+	}
+	return names
+}
@@ -10,10 +10,13 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"reflect"
 	"sort"
 	"testing"
 	"time"
 
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/id"
 	"github.com/fnproject/fn/api/models"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -99,7 +102,7 @@ func (brp *BasicResourceProvider) ValidFn(appId string) *models.Fn {
 		ResourceConfig: models.ResourceConfig{
 			Timeout:     models.DefaultTimeout,
 			IdleTimeout: models.DefaultIdleTimeout,
-			Memory:      models.DefaultMemory,
+			Memory:      models.Megabytes(models.DefaultMemory),
 		},
 	}
 }
@@ -668,6 +671,64 @@ func RunFnsTest(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
 			}
 		})
 
+		t.Run("Update function chain", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			chain := &models.FnChain{OnSuccess: "next_fn", OnFailure: "error_fn"}
+			_, err := ds.UpdateFn(ctx, &models.Fn{ID: testFn.ID, Chain: chain})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := ds.GetFnByID(ctx, testFn.ID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Chain.Equals(chain) {
+				t.Fatalf("expected chain `%v` but got `%v`", chain, got.Chain)
+			}
+
+			// an empty chain removes it
+			_, err = ds.UpdateFn(ctx, &models.Fn{ID: testFn.ID, Chain: &models.FnChain{}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err = ds.GetFnByID(ctx, testFn.ID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Chain != nil {
+				t.Fatalf("expected chain to be removed but got `%v`", got.Chain)
+			}
+		})
+
+		t.Run("Disable and enable function", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+			if testFn.IsDisabled() {
+				t.Fatalf("expected a new function to be enabled")
+			}
+
+			for _, disabled := range []bool{true, false} {
+				disabled := disabled
+				_, err := ds.UpdateFn(ctx, &models.Fn{ID: testFn.ID, Disabled: &disabled})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				got, err := ds.GetFnByID(ctx, testFn.ID)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.IsDisabled() != disabled {
+					t.Fatalf("expected disabled `%v` but got `%v`", disabled, got.IsDisabled())
+				}
+			}
+		})
+
 		t.Run("basic pagination no functions", func(t *testing.T) {
 			h := NewHarness(t, ctx, ds)
 			defer h.Cleanup()
@@ -941,7 +1002,7 @@ func RunTriggersTest(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
 
 			for i := 1; i < 10; i++ {
 				if triggers.Items[i-1].Name > triggers.Items[i].Name {
-					t.Fatalf("Test GetTriggers(page triggers), names out of order, %s, %s", triggers.Items[i-1], triggers.Items[i])
+					t.Fatalf("Test GetTriggers(page triggers), names out of order, %v, %v", triggers.Items[i-1], triggers.Items[i])
 				}
 			}
 
@@ -957,7 +1018,7 @@ func RunTriggersTest(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
 
 			for i := 0; i < 5; i++ {
 				if !triggers.Items[i].EqualsWithAnnotationSubset(storedTriggers[i]) {
-					t.Fatalf("Test GetTriggers(first five page triggers), expect equal, %s, %s", triggers.Items[i], storedTriggers[i])
+					t.Fatalf("Test GetTriggers(first five page triggers), expect equal, %v, %v", triggers.Items[i], storedTriggers[i])
 				}
 			}
 
@@ -977,7 +1038,7 @@ func RunTriggersTest(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
 
 			for i := 0; i < 5; i++ {
 				if !triggers.Items[i].EqualsWithAnnotationSubset(storedTriggers[i+5]) {
-					t.Fatalf("Test GetTriggers(second five page triggers), expect equal, %s, %s", triggers.Items[i], storedTriggers[i+5])
+					t.Fatalf("Test GetTriggers(second five page triggers), expect equal, %v, %v", triggers.Items[i], storedTriggers[i+5])
 				}
 			}
 
@@ -1125,6 +1186,29 @@ func RunTriggersTest(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
 
 		})
 
+		t.Run("disable and enable trigger", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+			testTrigger := h.GivenTriggerInDb(rp.ValidTrigger(testApp.ID, testFn.ID))
+
+			for _, disabled := range []bool{true, false} {
+				disabled := disabled
+				_, err := ds.UpdateTrigger(ctx, &models.Trigger{ID: testTrigger.ID, Disabled: &disabled})
+				if err != nil {
+					t.Fatalf("error when updating trigger: %s", err)
+				}
+				gotTrigger, err := ds.GetTriggerByID(ctx, testTrigger.ID)
+				if err != nil {
+					t.Fatalf("wasn't expecting an error : %s", err)
+				}
+				if gotTrigger.IsDisabled() != disabled {
+					t.Fatalf("expected disabled %v, got %v", disabled, gotTrigger.IsDisabled())
+				}
+			}
+		})
+
 		t.Run("remove non-existant", func(t *testing.T) {
 			err := ds.RemoveTrigger(ctx, "nonexistant")
 
@@ -1221,6 +1305,1075 @@ func RunTriggerBySourceTests(t *testing.T, dsf DataStoreFunc, rp ResourceProvide
 
 }
 
+func queuedCall(app *models.App, fn *models.Fn) *models.Call {
+	return &models.Call{
+		ID:        id.New().String(),
+		AppID:     app.ID,
+		FnID:      fn.ID,
+		Status:    models.CallStateQueued,
+		Timeout:   fn.Timeout,
+		CreatedAt: common.DateTime(time.Now().Truncate(time.Millisecond)),
+	}
+}
+
+func RunCallsTest(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
+	ds := dsf(t)
+	ctx := rp.DefaultCtx()
+
+	t.Run("calls", func(t *testing.T) {
+
+		t.Run("insert call must be queued", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			call := queuedCall(testApp, testFn)
+			call.Status = models.CallStateRunning
+			err := ds.InsertCall(ctx, call)
+			if err != models.ErrCallInvalidState {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallInvalidState, err)
+			}
+		})
+
+		t.Run("insert and get call", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			call := queuedCall(testApp, testFn)
+			err := ds.InsertCall(ctx, call)
+			if err != nil {
+				t.Fatalf("failed to insert call: %v", err)
+			}
+
+			err = ds.InsertCall(ctx, call)
+			if err != models.ErrCallExists {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallExists, err)
+			}
+
+			got, err := ds.GetCall(ctx, testFn.ID, call.ID)
+			if err != nil {
+				t.Fatalf("failed to get call: %v", err)
+			}
+			if got.ID != call.ID || got.AppID != call.AppID || got.Status != models.CallStateQueued || got.Timeout != call.Timeout {
+				t.Fatalf("expected call %+v, but got %+v", call, got)
+			}
+			if !time.Time(got.CreatedAt).Equal(time.Time(call.CreatedAt)) {
+				t.Fatalf("expected created_at %v, but got %v", call.CreatedAt, got.CreatedAt)
+			}
+
+			_, err = ds.GetCall(ctx, "otherfn", call.ID)
+			if err != models.ErrCallNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallNotFound, err)
+			}
+		})
+
+		t.Run("call state transitions", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			call := queuedCall(testApp, testFn)
+			err := ds.InsertCall(ctx, call)
+			if err != nil {
+				t.Fatalf("failed to insert call: %v", err)
+			}
+
+			call.Status = models.CallStateSucceeded
+			err = ds.UpdateCallState(ctx, call, models.CallStateQueued)
+			if err != models.ErrCallInvalidTransition {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallInvalidTransition, err)
+			}
+
+			call.Status = models.CallStateRunning
+			call.StartedAt = common.DateTime(time.Now())
+			err = ds.UpdateCallState(ctx, call, models.CallStateQueued)
+			if err != nil {
+				t.Fatalf("failed to start call: %v", err)
+			}
+
+			// a second writer that still thinks the call is queued loses
+			call.Status = models.CallStateExpired
+			err = ds.UpdateCallState(ctx, call, models.CallStateQueued)
+			if err != models.ErrCallInvalidTransition {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallInvalidTransition, err)
+			}
+
+			call.Status = models.CallStateFailed
+			call.Error = "boom"
+			call.ErrorDetails = "panic: boom"
+			call.CompletedAt = common.DateTime(time.Now())
+			err = ds.UpdateCallState(ctx, call, models.CallStateRunning)
+			if err != nil {
+				t.Fatalf("failed to complete call: %v", err)
+			}
+
+			got, err := ds.GetCall(ctx, testFn.ID, call.ID)
+			if err != nil {
+				t.Fatalf("failed to get call: %v", err)
+			}
+			if got.Status != models.CallStateFailed || got.Error != "boom" || got.ErrorDetails != "panic: boom" {
+				t.Fatalf("expected failed call with error, but got %+v", got)
+			}
+
+			missing := queuedCall(testApp, testFn)
+			missing.Status = models.CallStateRunning
+			err = ds.UpdateCallState(ctx, missing, models.CallStateQueued)
+			if err != models.ErrCallNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallNotFound, err)
+			}
+		})
+
+		t.Run("list calls", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			var calls []*models.Call
+			for i := 0; i < 3; i++ {
+				call := queuedCall(testApp, testFn)
+				err := ds.InsertCall(ctx, call)
+				if err != nil {
+					t.Fatalf("failed to insert call: %v", err)
+				}
+				calls = append(calls, call)
+			}
+			calls[0].Status = models.CallStateCancelled
+			err := ds.UpdateCallState(ctx, calls[0], models.CallStateQueued)
+			if err != nil {
+				t.Fatalf("failed to cancel call: %v", err)
+			}
+
+			res, err := ds.GetCalls(ctx, &models.CallFilter{FnID: testFn.ID, PerPage: 2})
+			if err != nil {
+				t.Fatalf("failed to list calls: %v", err)
+			}
+			if len(res.Items) != 2 || res.Items[0].ID != calls[2].ID || res.NextCursor == "" {
+				t.Fatalf("expected newest two calls and a cursor, but got %+v", res)
+			}
+
+			res, err = ds.GetCalls(ctx, &models.CallFilter{FnID: testFn.ID, PerPage: 2, Cursor: res.NextCursor})
+			if err != nil {
+				t.Fatalf("failed to list calls: %v", err)
+			}
+			if len(res.Items) != 1 || res.Items[0].ID != calls[0].ID {
+				t.Fatalf("expected oldest call, but got %+v", res)
+			}
+
+			res, err = ds.GetCalls(ctx, &models.CallFilter{FnID: testFn.ID, Status: models.CallStateQueued})
+			if err != nil {
+				t.Fatalf("failed to list calls: %v", err)
+			}
+			if len(res.Items) != 2 {
+				t.Fatalf("expected 2 queued calls, but got %d", len(res.Items))
+			}
+
+			_, err = ds.GetCalls(ctx, &models.CallFilter{FnID: testFn.ID, Status: "bogus"})
+			if err != models.ErrCallInvalidState {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallInvalidState, err)
+			}
+		})
+
+		t.Run("replayed calls", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			original := queuedCall(testApp, testFn)
+			original.ContentType = "application/octet-stream"
+			original.Input = models.CallInput{0, 1, 2}
+			original.Replayable = true
+			err := ds.InsertCall(ctx, original)
+			if err != nil {
+				t.Fatalf("failed to insert call: %v", err)
+			}
+			replay := queuedCall(testApp, testFn)
+			replay.ReplayOf = original.ID
+			replay.DeploymentID = "deployment_id"
+			err = ds.InsertCall(ctx, replay)
+			if err != nil {
+				t.Fatalf("failed to insert call: %v", err)
+			}
+
+			got, err := ds.GetCall(ctx, testFn.ID, original.ID)
+			if err != nil {
+				t.Fatalf("failed to get call: %v", err)
+			}
+			if !got.Replayable || got.ContentType != original.ContentType || !bytes.Equal(got.Input, original.Input) || got.ReplayOf != "" {
+				t.Fatalf("expected call with its input, but got %+v", got)
+			}
+
+			res, err := ds.GetCalls(ctx, &models.CallFilter{FnID: testFn.ID, ReplayOf: original.ID})
+			if err != nil {
+				t.Fatalf("failed to list calls: %v", err)
+			}
+			if len(res.Items) != 1 || res.Items[0].ID != replay.ID || res.Items[0].DeploymentID != "deployment_id" || res.Items[0].Replayable {
+				t.Fatalf("expected the replay of the call, but got %+v", res.Items)
+			}
+
+			// inputs are only read for a single call
+			res, err = ds.GetCalls(ctx, &models.CallFilter{FnID: testFn.ID})
+			if err != nil {
+				t.Fatalf("failed to list calls: %v", err)
+			}
+			for _, c := range res.Items {
+				if len(c.Input) != 0 || (c.ID == original.ID && !c.Replayable) {
+					t.Fatalf("expected calls to be listed without their inputs, but got %+v", c)
+				}
+			}
+		})
+
+		t.Run("remove call input", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			call := queuedCall(testApp, testFn)
+			call.Input = models.CallInput("sealed")
+			call.InputKey = "key:d3JhcHBlZA=="
+			call.InputExpiresAt = common.DateTime(time.Now().Add(time.Hour).Truncate(time.Millisecond))
+			call.Replayable = true
+			err := ds.InsertCall(ctx, call)
+			if err != nil {
+				t.Fatalf("failed to insert call: %v", err)
+			}
+
+			got, err := ds.GetCall(ctx, testFn.ID, call.ID)
+			if err != nil {
+				t.Fatalf("failed to get call: %v", err)
+			}
+			if got.InputKey != call.InputKey || !time.Time(got.InputExpiresAt).Equal(time.Time(call.InputExpiresAt)) {
+				t.Fatalf("expected call with its input key and expiry, but got %+v", got)
+			}
+
+			err = ds.RemoveCallInput(ctx, call.ID)
+			if err != nil {
+				t.Fatalf("failed to remove call input: %v", err)
+			}
+			got, err = ds.GetCall(ctx, testFn.ID, call.ID)
+			if err != nil {
+				t.Fatalf("failed to get call: %v", err)
+			}
+			if got.Replayable || len(got.Input) != 0 || got.InputKey != "" || got.Status != models.CallStateQueued {
+				t.Fatalf("expected call without its input, but got %+v", got)
+			}
+
+			err = ds.RemoveCallInput(ctx, "missing")
+			if err != models.ErrCallNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallNotFound, err)
+			}
+		})
+
+		t.Run("remove expired call inputs", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			now := time.Now()
+			var calls []*models.Call
+			// the input of the last call has no ttl
+			for _, expiresAt := range []time.Time{now.Add(-time.Minute), now.Add(time.Hour), {}} {
+				call := queuedCall(testApp, testFn)
+				call.Input = models.CallInput("sealed")
+				call.InputExpiresAt = common.DateTime(expiresAt)
+				call.Replayable = true
+				if err := ds.InsertCall(ctx, call); err != nil {
+					t.Fatalf("failed to insert call: %v", err)
+				}
+				calls = append(calls, call)
+			}
+
+			n, err := ds.RemoveExpiredCallInputs(ctx, now)
+			if err != nil || n != 1 {
+				t.Fatalf("expected one call input to be removed, but got %d %v", n, err)
+			}
+			expired, err := ds.GetCall(ctx, testFn.ID, calls[0].ID)
+			if err != nil {
+				t.Fatalf("failed to get call: %v", err)
+			}
+			if expired.Replayable || len(expired.Input) != 0 {
+				t.Fatalf("expected the expired call input to be removed, but got %+v", expired)
+			}
+			for _, call := range calls[1:] {
+				kept, err := ds.GetCall(ctx, testFn.ID, call.ID)
+				if err != nil {
+					t.Fatalf("failed to get call: %v", err)
+				}
+				if !kept.Replayable || string(kept.Input) != "sealed" {
+					t.Fatalf("expected the call input that has not expired to be kept, but got %+v", kept)
+				}
+			}
+		})
+
+		t.Run("append and get call log", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+			callID := id.New().String()
+
+			_, err := ds.GetCallLog(ctx, testFn.ID, callID)
+			if err != models.ErrCallLogNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallLogNotFound, err)
+			}
+
+			// parts are joined in order, whatever order they were appended in
+			for seq, part := range map[int]string{1: "world\n", 0: "hello ", 2: "\xff\x00"} {
+				if err := ds.AppendCallLog(ctx, testFn.ID, callID, seq, []byte(part)); err != nil {
+					t.Fatalf("failed to append call log: %v", err)
+				}
+			}
+			err = ds.AppendCallLog(ctx, testFn.ID, callID, 2, []byte("bye\n"))
+			if err != nil {
+				t.Fatalf("failed to append call log: %v", err)
+			}
+
+			log, err := ds.GetCallLog(ctx, testFn.ID, callID)
+			if err != nil {
+				t.Fatalf("failed to get call log: %v", err)
+			}
+			if string(log) != "hello world\nbye\n" {
+				t.Fatalf("expected the parts of the call log joined, but got %q", log)
+			}
+
+			_, err = ds.GetCallLog(ctx, "otherfn", callID)
+			if err != models.ErrCallLogNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallLogNotFound, err)
+			}
+		})
+
+		t.Run("remove expired call logs", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+			callID := id.New().String()
+
+			err := ds.AppendCallLog(ctx, testFn.ID, callID, 0, []byte("hello\n"))
+			if err != nil {
+				t.Fatalf("failed to append call log: %v", err)
+			}
+
+			n, err := ds.RemoveExpiredCallLogs(ctx, time.Now().Add(-time.Hour))
+			if err != nil || n != 0 {
+				t.Fatalf("expected no call log to be removed, but got %d %v", n, err)
+			}
+			n, err = ds.RemoveExpiredCallLogs(ctx, time.Now().Add(time.Hour))
+			if err != nil || n != 1 {
+				t.Fatalf("expected one call log to be removed, but got %d %v", n, err)
+			}
+			_, err = ds.GetCallLog(ctx, testFn.ID, callID)
+			if err != models.ErrCallLogNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallLogNotFound, err)
+			}
+		})
+
+		t.Run("remove fn removes its calls", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			call := queuedCall(testApp, testFn)
+			err := ds.InsertCall(ctx, call)
+			if err != nil {
+				t.Fatalf("failed to insert call: %v", err)
+			}
+			err = ds.AppendCallLog(ctx, testFn.ID, call.ID, 0, []byte("hello\n"))
+			if err != nil {
+				t.Fatalf("failed to append call log: %v", err)
+			}
+			err = ds.RemoveFn(ctx, testFn.ID)
+			if err != nil {
+				t.Fatalf("failed to remove fn: %v", err)
+			}
+			_, err = ds.GetCall(ctx, testFn.ID, call.ID)
+			if err != models.ErrCallNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallNotFound, err)
+			}
+			_, err = ds.GetCallLog(ctx, testFn.ID, call.ID)
+			if err != models.ErrCallLogNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallLogNotFound, err)
+			}
+		})
+	})
+}
+
+func RunTriggerRunsTest(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
+	ds := dsf(t)
+	ctx := rp.DefaultCtx()
+
+	newRun := func(trigger *models.Trigger, payload []byte) *models.TriggerRun {
+		return &models.TriggerRun{
+			ID:          id.New().String(),
+			TriggerID:   trigger.ID,
+			AppID:       trigger.AppID,
+			FnID:        trigger.FnID,
+			Status:      models.CallStateRunning,
+			Method:      "POST",
+			URL:         "http://localhost:8080/t/app" + trigger.Source,
+			ContentType: "application/octet-stream",
+			Payload:     payload,
+			Replayable:  true,
+			CreatedAt:   common.DateTime(time.Now()),
+			ExpiresAt:   common.DateTime(time.Now().Add(time.Hour)),
+		}
+	}
+
+	t.Run("trigger runs", func(t *testing.T) {
+
+		t.Run("insert, update and get trigger run", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+			testTrigger := h.GivenTriggerInDb(rp.ValidTrigger(testApp.ID, testFn.ID))
+
+			// payloads need not be text
+			run := newRun(testTrigger, []byte{0, 1, 0xfe, 0xff})
+			run.PayloadKey = "key:wrapped"
+			err := ds.InsertTriggerRun(ctx, run)
+			if err != nil {
+				t.Fatalf("failed to insert trigger run: %v", err)
+			}
+
+			run.Status = models.CallStateFailed
+			run.Error = "boom"
+			run.CompletedAt = common.DateTime(time.Now())
+			err = ds.UpdateTriggerRun(ctx, run)
+			if err != nil {
+				t.Fatalf("failed to update trigger run: %v", err)
+			}
+
+			got, err := ds.GetTriggerRun(ctx, testTrigger.ID, run.ID)
+			if err != nil {
+				t.Fatalf("failed to get trigger run: %v", err)
+			}
+			if got.Status != models.CallStateFailed || got.Error != "boom" || got.FnID != testFn.ID || got.URL != run.URL || !got.Replayable {
+				t.Fatalf("expected trigger run %+v, but got %+v", run, got)
+			}
+			if !bytes.Equal(got.Payload, run.Payload) || got.PayloadKey != run.PayloadKey {
+				t.Fatalf("expected payload %v with key %q, but got %v %q", run.Payload, run.PayloadKey, got.Payload, got.PayloadKey)
+			}
+			if time.Time(got.ExpiresAt).IsZero() {
+				t.Fatalf("expected the run to expire, but got %+v", got)
+			}
+
+			_, err = ds.GetTriggerRun(ctx, "othertrigger", run.ID)
+			if err != models.ErrTriggerRunNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrTriggerRunNotFound, err)
+			}
+			missing := newRun(testTrigger, nil)
+			err = ds.UpdateTriggerRun(ctx, missing)
+			if err != models.ErrTriggerRunNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrTriggerRunNotFound, err)
+			}
+		})
+
+		t.Run("list trigger runs", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+			testTrigger := h.GivenTriggerInDb(rp.ValidTrigger(testApp.ID, testFn.ID))
+
+			var runs []*models.TriggerRun
+			for i := 0; i < 3; i++ {
+				run := newRun(testTrigger, []byte("payload"))
+				if err := ds.InsertTriggerRun(ctx, run); err != nil {
+					t.Fatalf("failed to insert trigger run: %v", err)
+				}
+				runs = append(runs, run)
+			}
+			runs[0].Status = models.CallStateSucceeded
+			if err := ds.UpdateTriggerRun(ctx, runs[0]); err != nil {
+				t.Fatalf("failed to update trigger run: %v", err)
+			}
+
+			res, err := ds.GetTriggerRuns(ctx, &models.TriggerRunFilter{TriggerID: testTrigger.ID, PerPage: 2})
+			if err != nil {
+				t.Fatalf("failed to list trigger runs: %v", err)
+			}
+			if len(res.Items) != 2 || res.Items[0].ID != runs[2].ID || res.NextCursor == "" {
+				t.Fatalf("expected newest two runs and a cursor, but got %+v", res)
+			}
+			if len(res.Items[0].Payload) != 0 {
+				t.Fatalf("expected the payloads of runs to be left out of lists, but got %q", res.Items[0].Payload)
+			}
+
+			res, err = ds.GetTriggerRuns(ctx, &models.TriggerRunFilter{TriggerID: testTrigger.ID, PerPage: 2, Cursor: res.NextCursor})
+			if err != nil {
+				t.Fatalf("failed to list trigger runs: %v", err)
+			}
+			if len(res.Items) != 1 || res.Items[0].ID != runs[0].ID {
+				t.Fatalf("expected oldest run, but got %+v", res)
+			}
+
+			res, err = ds.GetTriggerRuns(ctx, &models.TriggerRunFilter{TriggerID: testTrigger.ID, Status: models.CallStateSucceeded})
+			if err != nil {
+				t.Fatalf("failed to list trigger runs: %v", err)
+			}
+			if len(res.Items) != 1 {
+				t.Fatalf("expected 1 succeeded run, but got %d", len(res.Items))
+			}
+
+			_, err = ds.GetTriggerRuns(ctx, &models.TriggerRunFilter{TriggerID: testTrigger.ID, Status: "bogus"})
+			if err != models.ErrCallInvalidState {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrCallInvalidState, err)
+			}
+		})
+
+		t.Run("remove expired trigger runs", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+			testTrigger := h.GivenTriggerInDb(rp.ValidTrigger(testApp.ID, testFn.ID))
+
+			now := time.Now()
+			expired := newRun(testTrigger, nil)
+			expired.ExpiresAt = common.DateTime(now.Add(-time.Minute))
+			// runs without an expiry are kept for the default ttl
+			old := newRun(testTrigger, nil)
+			old.ExpiresAt = common.DateTime{}
+			old.CreatedAt = common.DateTime(now.Add(-(models.DefaultTriggerRunTTL + 60) * time.Second))
+			recent := newRun(testTrigger, nil)
+			recent.ExpiresAt = common.DateTime{}
+			kept := newRun(testTrigger, nil)
+			for _, run := range []*models.TriggerRun{expired, old, recent, kept} {
+				if err := ds.InsertTriggerRun(ctx, run); err != nil {
+					t.Fatalf("failed to insert trigger run: %v", err)
+				}
+			}
+
+			n, err := ds.RemoveExpiredTriggerRuns(ctx, now)
+			if err != nil || n != 2 {
+				t.Fatalf("expected two trigger runs to be removed, but got %d %v", n, err)
+			}
+			for _, run := range []*models.TriggerRun{expired, old} {
+				if _, err := ds.GetTriggerRun(ctx, testTrigger.ID, run.ID); err != models.ErrTriggerRunNotFound {
+					t.Fatalf("expected error `%v`, but it was `%v`", models.ErrTriggerRunNotFound, err)
+				}
+			}
+			for _, run := range []*models.TriggerRun{recent, kept} {
+				if _, err := ds.GetTriggerRun(ctx, testTrigger.ID, run.ID); err != nil {
+					t.Fatalf("expected the trigger run to be kept, but got %v", err)
+				}
+			}
+		})
+
+		t.Run("remove trigger removes its runs", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+			testTrigger := h.GivenTriggerInDb(rp.ValidTrigger(testApp.ID, testFn.ID))
+
+			run := newRun(testTrigger, nil)
+			if err := ds.InsertTriggerRun(ctx, run); err != nil {
+				t.Fatalf("failed to insert trigger run: %v", err)
+			}
+			if err := ds.RemoveTrigger(ctx, testTrigger.ID); err != nil {
+				t.Fatalf("failed to remove trigger: %v", err)
+			}
+			_, err := ds.GetTriggerRun(ctx, testTrigger.ID, run.ID)
+			if err != models.ErrTriggerRunNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrTriggerRunNotFound, err)
+			}
+		})
+	})
+}
+
+func RunImageScansTest(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
+	ds := dsf(t)
+	ctx := rp.DefaultCtx()
+
+	t.Run("image scans", func(t *testing.T) {
+
+		t.Run("put and get image scan", func(t *testing.T) {
+			digest := fmt.Sprintf("sha256:%016x", rand.Uint64())
+			_, err := ds.GetImageScan(ctx, digest)
+			if err != models.ErrImageScanNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrImageScanNotFound, err)
+			}
+
+			scan := &models.ImageScan{
+				Digest:          digest,
+				Image:           "fnproject/hello@" + digest,
+				Vulnerabilities: models.ImageVulnerabilities{models.SeverityHigh: 2, models.SeverityLow: 5},
+				ScannedAt:       common.DateTime(time.Now()),
+			}
+			if err := ds.PutImageScan(ctx, scan); err != nil {
+				t.Fatalf("failed to put image scan: %v", err)
+			}
+			got, err := ds.GetImageScan(ctx, digest)
+			if err != nil {
+				t.Fatalf("failed to get image scan: %v", err)
+			}
+			if got.Image != scan.Image || !reflect.DeepEqual(got.Vulnerabilities, scan.Vulnerabilities) {
+				t.Fatalf("expected image scan %+v, but got %+v", scan, got)
+			}
+
+			// a rescan replaces the scan of the digest
+			scan.Vulnerabilities = nil
+			if err := ds.PutImageScan(ctx, scan); err != nil {
+				t.Fatalf("failed to put image scan: %v", err)
+			}
+			got, err = ds.GetImageScan(ctx, digest)
+			if err != nil {
+				t.Fatalf("failed to get image scan: %v", err)
+			}
+			if len(got.Vulnerabilities) != 0 {
+				t.Fatalf("expected a scan without vulnerabilities, but got %+v", got)
+			}
+		})
+
+		t.Run("missing digest", func(t *testing.T) {
+			err := ds.PutImageScan(ctx, &models.ImageScan{Image: "fnproject/hello"})
+			if err != models.ErrDatastoreEmptyImageDigest {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrDatastoreEmptyImageDigest, err)
+			}
+		})
+	})
+}
+
+func RunFnDeploymentsTest(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
+	ds := dsf(t)
+	ctx := rp.DefaultCtx()
+
+	newDeployment := func(fn *models.Fn) *models.FnDeployment {
+		return &models.FnDeployment{
+			ID:              id.New().String(),
+			FnID:            fn.ID,
+			AppID:           fn.AppID,
+			Image:           "fnproject/hello@sha256:4f53",
+			Digest:          "sha256:4f53",
+			DeployedBy:      "alice@example.com",
+			SourceCommit:    "9fceb02",
+			Scanned:         true,
+			Vulnerabilities: models.ImageVulnerabilities{models.SeverityLow: 1},
+			SignatureStatus: models.SignatureUnverified,
+			CreatedAt:       common.DateTime(time.Now()),
+		}
+	}
+
+	t.Run("fn deployments", func(t *testing.T) {
+
+		t.Run("insert, get and list fn deployments", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			var deployments []*models.FnDeployment
+			for i := 0; i < 3; i++ {
+				deployment := newDeployment(testFn)
+				if err := ds.InsertFnDeployment(ctx, deployment); err != nil {
+					t.Fatalf("failed to insert fn deployment: %v", err)
+				}
+				deployments = append(deployments, deployment)
+			}
+
+			got, err := ds.GetFnDeployment(ctx, testFn.ID, deployments[0].ID)
+			if err != nil {
+				t.Fatalf("failed to get fn deployment: %v", err)
+			}
+			if got.Digest != "sha256:4f53" || got.DeployedBy != "alice@example.com" || got.SourceCommit != "9fceb02" ||
+				!got.Scanned || got.Vulnerabilities[models.SeverityLow] != 1 || got.SignatureStatus != models.SignatureUnverified {
+				t.Fatalf("expected fn deployment %+v, but got %+v", deployments[0], got)
+			}
+
+			_, err = ds.GetFnDeployment(ctx, "otherfn", deployments[0].ID)
+			if err != models.ErrFnDeploymentNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrFnDeploymentNotFound, err)
+			}
+
+			res, err := ds.GetFnDeployments(ctx, &models.FnDeploymentFilter{FnID: testFn.ID, PerPage: 2})
+			if err != nil {
+				t.Fatalf("failed to list fn deployments: %v", err)
+			}
+			if len(res.Items) != 2 || res.Items[0].ID != deployments[2].ID || res.NextCursor == "" {
+				t.Fatalf("expected newest two deployments and a cursor, but got %+v", res)
+			}
+
+			res, err = ds.GetFnDeployments(ctx, &models.FnDeploymentFilter{FnID: testFn.ID, PerPage: 2, Cursor: res.NextCursor})
+			if err != nil {
+				t.Fatalf("failed to list fn deployments: %v", err)
+			}
+			if len(res.Items) != 1 || res.Items[0].ID != deployments[0].ID {
+				t.Fatalf("expected oldest deployment, but got %+v", res)
+			}
+		})
+
+		t.Run("remove fn keeps its deployments", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			deployment := newDeployment(testFn)
+			if err := ds.InsertFnDeployment(ctx, deployment); err != nil {
+				t.Fatalf("failed to insert fn deployment: %v", err)
+			}
+			if err := ds.RemoveFn(ctx, testFn.ID); err != nil {
+				t.Fatalf("failed to remove fn: %v", err)
+			}
+			if _, err := ds.GetFnDeployment(ctx, testFn.ID, deployment.ID); err != nil {
+				t.Fatalf("expected the deployment to be kept, but got `%v`", err)
+			}
+		})
+	})
+}
+
+func RunWorkflowsTest(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
+	ds := dsf(t)
+	ctx := rp.DefaultCtx()
+
+	validWorkflow := func(appID, fnID string) *models.Workflow {
+		return &models.Workflow{
+			Name:  fmt.Sprintf("workflow_%09d", rand.Uint32()),
+			AppID: appID,
+			Steps: models.WorkflowSteps{{FnID: fnID, Retries: 1}, {FnID: fnID, CompensateFnID: fnID}},
+		}
+	}
+
+	t.Run("workflows", func(t *testing.T) {
+
+		t.Run("insert invalid workflow", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			for i, test := range []struct {
+				workflow *models.Workflow
+				expected error
+			}{
+				{&models.Workflow{ID: "id", Name: "w", AppID: testApp.ID, Steps: models.WorkflowSteps{{FnID: testFn.ID}}}, models.ErrWorkflowsIDProvided},
+				{&models.Workflow{AppID: testApp.ID, Steps: models.WorkflowSteps{{FnID: testFn.ID}}}, models.ErrWorkflowsMissingName},
+				{&models.Workflow{Name: "w", Steps: models.WorkflowSteps{{FnID: testFn.ID}}}, models.ErrWorkflowsMissingAppID},
+				{&models.Workflow{Name: "w", AppID: testApp.ID}, models.ErrWorkflowsInvalidSteps},
+				{&models.Workflow{Name: "w", AppID: testApp.ID, Steps: models.WorkflowSteps{{}}}, models.ErrWorkflowsInvalidStep},
+				{&models.Workflow{Name: "w", AppID: testApp.ID, Steps: models.WorkflowSteps{{FnID: testFn.ID, Retries: -1}}}, models.ErrWorkflowsInvalidStep},
+				{&models.Workflow{Name: "w", AppID: "notreal", Steps: models.WorkflowSteps{{FnID: testFn.ID}}}, models.ErrAppsNotFound},
+			} {
+				_, err := ds.InsertWorkflow(ctx, test.workflow)
+				if err != test.expected {
+					t.Errorf("Test %d: expected error `%v`, but it was `%v`", i, test.expected, err)
+				}
+			}
+		})
+
+		t.Run("insert, get and remove workflow", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			workflow, err := ds.InsertWorkflow(ctx, validWorkflow(testApp.ID, testFn.ID))
+			if err != nil {
+				t.Fatalf("failed to insert workflow: %v", err)
+			}
+			if workflow.ID == "" || time.Time(workflow.CreatedAt).IsZero() {
+				t.Fatalf("expected workflow to have an id and created_at, but got %+v", workflow)
+			}
+
+			dup := validWorkflow(testApp.ID, testFn.ID)
+			dup.Name = workflow.Name
+			_, err = ds.InsertWorkflow(ctx, dup)
+			if err != models.ErrWorkflowsExists {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrWorkflowsExists, err)
+			}
+
+			got, err := ds.GetWorkflowByID(ctx, workflow.ID)
+			if err != nil {
+				t.Fatalf("failed to get workflow: %v", err)
+			}
+			if got.Name != workflow.Name || len(got.Steps) != 2 || got.Steps[0] != workflow.Steps[0] || got.Steps[1] != workflow.Steps[1] {
+				t.Fatalf("expected workflow %+v, but got %+v", workflow, got)
+			}
+
+			err = ds.RemoveWorkflow(ctx, workflow.ID)
+			if err != nil {
+				t.Fatalf("failed to remove workflow: %v", err)
+			}
+			_, err = ds.GetWorkflowByID(ctx, workflow.ID)
+			if err != models.ErrWorkflowsNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrWorkflowsNotFound, err)
+			}
+			err = ds.RemoveWorkflow(ctx, workflow.ID)
+			if err != models.ErrWorkflowsNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrWorkflowsNotFound, err)
+			}
+		})
+
+		t.Run("list workflows", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			otherApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			var names []string
+			for i := 0; i < 3; i++ {
+				workflow, err := ds.InsertWorkflow(ctx, validWorkflow(testApp.ID, testFn.ID))
+				if err != nil {
+					t.Fatalf("failed to insert workflow: %v", err)
+				}
+				names = append(names, workflow.Name)
+			}
+			_, err := ds.InsertWorkflow(ctx, validWorkflow(otherApp.ID, testFn.ID))
+			if err != nil {
+				t.Fatalf("failed to insert workflow: %v", err)
+			}
+			sort.Strings(names)
+
+			res, err := ds.GetWorkflows(ctx, &models.WorkflowFilter{AppID: testApp.ID, PerPage: 2})
+			if err != nil {
+				t.Fatalf("failed to list workflows: %v", err)
+			}
+			if len(res.Items) != 2 || res.Items[0].Name != names[0] || res.NextCursor == "" {
+				t.Fatalf("expected first two workflows and a cursor, but got %+v", res)
+			}
+
+			res, err = ds.GetWorkflows(ctx, &models.WorkflowFilter{AppID: testApp.ID, PerPage: 2, Cursor: res.NextCursor})
+			if err != nil {
+				t.Fatalf("failed to list workflows: %v", err)
+			}
+			if len(res.Items) != 1 || res.Items[0].Name != names[2] {
+				t.Fatalf("expected last workflow, but got %+v", res)
+			}
+		})
+
+		t.Run("workflow runs", func(t *testing.T) {
+			h := NewHarness(t, ctx, ds)
+			defer h.Cleanup()
+			testApp := h.GivenAppInDb(rp.ValidApp())
+			testFn := h.GivenFnInDb(rp.ValidFn(testApp.ID))
+
+			workflow, err := ds.InsertWorkflow(ctx, validWorkflow(testApp.ID, testFn.ID))
+			if err != nil {
+				t.Fatalf("failed to insert workflow: %v", err)
+			}
+
+			var runs []*models.WorkflowRun
+			for i := 0; i < 3; i++ {
+				run := &models.WorkflowRun{
+					ID:         id.New().String(),
+					WorkflowID: workflow.ID,
+					Status:     models.WorkflowRunStateRunning,
+					Input:      "input",
+					CreatedAt:  common.DateTime(time.Now()),
+				}
+				if err := ds.InsertWorkflowRun(ctx, run); err != nil {
+					t.Fatalf("failed to insert workflow run: %v", err)
+				}
+				runs = append(runs, run)
+			}
+
+			run := runs[0]
+			run.Status = models.WorkflowRunStateSucceeded
+			run.Step = 1
+			run.Outputs = models.WorkflowOutputs{"first", "second"}
+			run.CompletedAt = common.DateTime(time.Now())
+			if err := ds.UpdateWorkflowRun(ctx, run); err != nil {
+				t.Fatalf("failed to update workflow run: %v", err)
+			}
+
+			got, err := ds.GetWorkflowRun(ctx, workflow.ID, run.ID)
+			if err != nil {
+				t.Fatalf("failed to get workflow run: %v", err)
+			}
+			if got.Status != run.Status || got.Step != 1 || got.Input != "input" || len(got.Outputs) != 2 || got.Outputs[1] != "second" {
+				t.Fatalf("expected workflow run %+v, but got %+v", run, got)
+			}
+			_, err = ds.GetWorkflowRun(ctx, "otherworkflow", run.ID)
+			if err != models.ErrWorkflowRunNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrWorkflowRunNotFound, err)
+			}
+
+			res, err := ds.GetWorkflowRuns(ctx, &models.WorkflowRunFilter{WorkflowID: workflow.ID, PerPage: 2})
+			if err != nil {
+				t.Fatalf("failed to list workflow runs: %v", err)
+			}
+			if len(res.Items) != 2 || res.Items[0].ID != runs[2].ID || res.NextCursor == "" {
+				t.Fatalf("expected newest two runs and a cursor, but got %+v", res)
+			}
+			res, err = ds.GetWorkflowRuns(ctx, &models.WorkflowRunFilter{WorkflowID: workflow.ID, Status: models.WorkflowRunStateRunning})
+			if err != nil {
+				t.Fatalf("failed to list workflow runs: %v", err)
+			}
+			if len(res.Items) != 2 {
+				t.Fatalf("expected 2 running runs, but got %d", len(res.Items))
+			}
+
+			missing := &models.WorkflowRun{ID: id.New().String(), WorkflowID: workflow.ID}
+			if err := ds.UpdateWorkflowRun(ctx, missing); err != models.ErrWorkflowRunNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrWorkflowRunNotFound, err)
+			}
+
+			// runs that stopped being updated were lost, finished runs are left alone
+			runs[2].UpdatedAt = common.DateTime(time.Now())
+			if err := ds.UpdateWorkflowRun(ctx, runs[2]); err != nil {
+				t.Fatalf("failed to update workflow run: %v", err)
+			}
+			n, err := ds.FailLostWorkflowRuns(ctx, time.Now().Add(-time.Minute))
+			if err != nil || n != 1 {
+				t.Fatalf("expected one workflow run to be failed, but got %d %v", n, err)
+			}
+			for i, expected := range []string{models.WorkflowRunStateSucceeded, models.WorkflowRunStateFailed, models.WorkflowRunStateRunning} {
+				got, err := ds.GetWorkflowRun(ctx, workflow.ID, runs[i].ID)
+				if err != nil {
+					t.Fatalf("failed to get workflow run: %v", err)
+				}
+				if got.Status != expected {
+					t.Fatalf("expected run %d to be %s, but got %+v", i, expected, got)
+				}
+			}
+			if got, _ := ds.GetWorkflowRun(ctx, workflow.ID, runs[1].ID); got.Error != models.WorkflowRunLostError || time.Time(got.CompletedAt).IsZero() {
+				t.Fatalf("expected the lost run to fail as lost, but got %+v", got)
+			}
+
+			// removing the app takes its workflows and their runs with it
+			err = ds.RemoveApp(ctx, testApp.ID)
+			if err != nil {
+				t.Fatalf("failed to remove app: %v", err)
+			}
+			_, err = ds.GetWorkflowByID(ctx, workflow.ID)
+			if err != models.ErrWorkflowsNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrWorkflowsNotFound, err)
+			}
+			_, err = ds.GetWorkflowRun(ctx, workflow.ID, run.ID)
+			if err != models.ErrWorkflowRunNotFound {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrWorkflowRunNotFound, err)
+			}
+		})
+	})
+}
+
+func RunLeasesTest(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
+	ds := dsf(t)
+	ctx := rp.DefaultCtx()
+
+	t.Run("leases", func(t *testing.T) {
+
+		t.Run("acquire, renew and release lease", func(t *testing.T) {
+			name := fmt.Sprintf("lease-%016x", rand.Uint64())
+			lease := &models.Lease{Name: name, Holder: "a", ExpiresAt: common.DateTime(time.Now().Add(time.Minute))}
+			if err := ds.AcquireLease(ctx, lease); err != nil {
+				t.Fatalf("failed to acquire lease: %v", err)
+			}
+			// the holder renews it
+			lease.ExpiresAt = common.DateTime(time.Now().Add(2 * time.Minute))
+			if err := ds.AcquireLease(ctx, lease); err != nil {
+				t.Fatalf("failed to renew lease: %v", err)
+			}
+
+			other := &models.Lease{Name: name, Holder: "b", ExpiresAt: common.DateTime(time.Now().Add(time.Minute))}
+			if err := ds.AcquireLease(ctx, other); err != models.ErrLeaseHeld {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrLeaseHeld, err)
+			}
+			// only the holder releases it
+			if err := ds.ReleaseLease(ctx, name, "b"); err != nil {
+				t.Fatalf("failed to release lease: %v", err)
+			}
+			if err := ds.AcquireLease(ctx, other); err != models.ErrLeaseHeld {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrLeaseHeld, err)
+			}
+			if err := ds.ReleaseLease(ctx, name, "a"); err != nil {
+				t.Fatalf("failed to release lease: %v", err)
+			}
+			if err := ds.AcquireLease(ctx, other); err != nil {
+				t.Fatalf("failed to acquire released lease: %v", err)
+			}
+		})
+
+		t.Run("acquire expired lease", func(t *testing.T) {
+			name := fmt.Sprintf("lease-%016x", rand.Uint64())
+			lease := &models.Lease{Name: name, Holder: "a", ExpiresAt: common.DateTime(time.Now().Add(-time.Second))}
+			if err := ds.AcquireLease(ctx, lease); err != nil {
+				t.Fatalf("failed to acquire lease: %v", err)
+			}
+			other := &models.Lease{Name: name, Holder: "b", ExpiresAt: common.DateTime(time.Now().Add(time.Minute))}
+			if err := ds.AcquireLease(ctx, other); err != nil {
+				t.Fatalf("failed to acquire expired lease: %v", err)
+			}
+		})
+
+		t.Run("missing name", func(t *testing.T) {
+			err := ds.AcquireLease(ctx, &models.Lease{Holder: "a"})
+			if err != models.ErrDatastoreEmptyLeaseName {
+				t.Fatalf("expected error `%v`, but it was `%v`", models.ErrDatastoreEmptyLeaseName, err)
+			}
+		})
+	})
+}
+
+func RunChangesTest(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
+	ds := dsf(t)
+	ctx := rp.DefaultCtx()
+
+	t.Run("changes", func(t *testing.T) {
+		wctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var changes []models.DatastoreChange
+		ds.WatchChanges(wctx, func(change models.DatastoreChange) {
+			changes = append(changes, change)
+		})
+
+		h := NewHarness(t, ctx, ds)
+		defer h.Cleanup()
+		app := h.GivenAppInDb(rp.ValidApp())
+		fn := h.GivenFnInDb(rp.ValidFn(app.ID))
+		trigger := h.GivenTriggerInDb(rp.ValidTrigger(app.ID, fn.ID))
+
+		if _, err := ds.UpdateApp(ctx, &models.App{ID: app.ID, Config: models.Config{"a": "b"}}); err != nil {
+			t.Fatalf("failed to update app: %v", err)
+		}
+		if _, err := ds.UpdateFn(ctx, &models.Fn{ID: fn.ID, Image: "fnproject/fn-test-utils:latest"}); err != nil {
+			t.Fatalf("failed to update fn: %v", err)
+		}
+		if _, err := ds.UpdateTrigger(ctx, &models.Trigger{ID: trigger.ID, Source: "/changed"}); err != nil {
+			t.Fatalf("failed to update trigger: %v", err)
+		}
+		if err := ds.RemoveTrigger(ctx, trigger.ID); err != nil {
+			t.Fatalf("failed to remove trigger: %v", err)
+		}
+		if err := ds.RemoveFn(ctx, fn.ID); err != nil {
+			t.Fatalf("failed to remove fn: %v", err)
+		}
+		if err := ds.RemoveApp(ctx, app.ID); err != nil {
+			t.Fatalf("failed to remove app: %v", err)
+		}
+		// failed changes are not notified
+		if err := ds.RemoveApp(ctx, app.ID); err != models.ErrAppsNotFound {
+			t.Fatalf("expected error `%v`, but it was `%v`", models.ErrAppsNotFound, err)
+		}
+
+		expected := []models.DatastoreChange{
+			{Kind: models.ChangeKindApp, ID: app.ID},
+			{Kind: models.ChangeKindFn, ID: fn.ID},
+			{Kind: models.ChangeKindTrigger, ID: trigger.ID},
+			{Kind: models.ChangeKindApp, ID: app.ID},
+			{Kind: models.ChangeKindFn, ID: fn.ID},
+			{Kind: models.ChangeKindTrigger, ID: trigger.ID},
+			{Kind: models.ChangeKindTrigger, ID: trigger.ID},
+			{Kind: models.ChangeKindFn, ID: fn.ID},
+			{Kind: models.ChangeKindApp, ID: app.ID},
+		}
+		if !reflect.DeepEqual(changes, expected) {
+			t.Fatalf("expected changes %v, got %v", expected, changes)
+		}
+	})
+}
+
 func RunAllTests(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
 	buf := setLogBuffer()
 	defer func() {
@@ -1233,5 +2386,12 @@ func RunAllTests(t *testing.T, dsf DataStoreFunc, rp ResourceProvider) {
 	RunFnsTest(t, dsf, rp)
 	RunTriggersTest(t, dsf, rp)
 	RunTriggerBySourceTests(t, dsf, rp)
+	RunCallsTest(t, dsf, rp)
+	RunTriggerRunsTest(t, dsf, rp)
+	RunImageScansTest(t, dsf, rp)
+	RunFnDeploymentsTest(t, dsf, rp)
+	RunWorkflowsTest(t, dsf, rp)
+	RunLeasesTest(t, dsf, rp)
+	RunChangesTest(t, dsf, rp)
 
 }
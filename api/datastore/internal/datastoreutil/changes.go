@@ -0,0 +1,50 @@
+package datastoreutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// ChangeFeed hands the changes datastores notify it of to their watchers, its zero value is ready to use
+type ChangeFeed struct {
+	lock     sync.Mutex
+	watchers map[*watcher]struct{}
+}
+
+type watcher struct {
+	fn func(models.DatastoreChange)
+}
+
+// Watch calls fn with each change notified until ctx is done
+func (f *ChangeFeed) Watch(ctx context.Context, fn func(models.DatastoreChange)) {
+	w := &watcher{fn}
+	f.lock.Lock()
+	if f.watchers == nil {
+		f.watchers = make(map[*watcher]struct{})
+	}
+	f.watchers[w] = struct{}{}
+	f.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		f.lock.Lock()
+		delete(f.watchers, w)
+		f.lock.Unlock()
+	}()
+}
+
+// Notify calls the watchers with change
+func (f *ChangeFeed) Notify(change models.DatastoreChange) {
+	f.lock.Lock()
+	watchers := make([]*watcher, 0, len(f.watchers))
+	for w := range f.watchers {
+		watchers = append(watchers, w)
+	}
+	f.lock.Unlock()
+
+	for _, w := range watchers {
+		w.fn(change)
+	}
+}
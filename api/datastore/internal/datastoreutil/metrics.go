@@ -2,7 +2,9 @@ package datastoreutil
 
 import (
 	"context"
+	"time"
 
+	"github.com/fnproject/fn/api/common"
 	"github.com/fnproject/fn/api/models"
 	"go.opencensus.io/trace"
 )
@@ -15,109 +17,293 @@ type metricds struct {
 	ds models.Datastore
 }
 
-func (m *metricds) GetTriggerBySource(ctx context.Context, appId string, triggerType, source string) (*models.Trigger, error) {
+func (m *metricds) GetTriggerBySource(ctx context.Context, appId string, triggerType, source string) (_ *models.Trigger, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_get_trigger_by_source")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.GetTriggerBySource(ctx, appId, triggerType, source)
 }
 
-func (m *metricds) GetAppID(ctx context.Context, appName string) (string, error) {
+func (m *metricds) GetAppID(ctx context.Context, appName string) (_ string, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_get_app_id")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.GetAppID(ctx, appName)
 }
 
-func (m *metricds) GetAppByID(ctx context.Context, appID string) (*models.App, error) {
+func (m *metricds) GetAppByID(ctx context.Context, appID string) (_ *models.App, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_get_app_by_id")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.GetAppByID(ctx, appID)
 }
 
-func (m *metricds) GetApps(ctx context.Context, filter *models.AppFilter) (*models.AppList, error) {
+func (m *metricds) GetApps(ctx context.Context, filter *models.AppFilter) (_ *models.AppList, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_get_apps")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.GetApps(ctx, filter)
 }
 
-func (m *metricds) InsertApp(ctx context.Context, app *models.App) (*models.App, error) {
+func (m *metricds) InsertApp(ctx context.Context, app *models.App) (_ *models.App, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_insert_app")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.InsertApp(ctx, app)
 }
 
-func (m *metricds) UpdateApp(ctx context.Context, app *models.App) (*models.App, error) {
+func (m *metricds) UpdateApp(ctx context.Context, app *models.App) (_ *models.App, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_update_app")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.UpdateApp(ctx, app)
 }
 
-func (m *metricds) RemoveApp(ctx context.Context, appID string) error {
+func (m *metricds) RemoveApp(ctx context.Context, appID string) (err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_remove_app")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.RemoveApp(ctx, appID)
 }
 
-func (m *metricds) InsertTrigger(ctx context.Context, trigger *models.Trigger) (*models.Trigger, error) {
+func (m *metricds) InsertTrigger(ctx context.Context, trigger *models.Trigger) (_ *models.Trigger, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_insert_trigger")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.InsertTrigger(ctx, trigger)
 
 }
 
-func (m *metricds) UpdateTrigger(ctx context.Context, trigger *models.Trigger) (*models.Trigger, error) {
+func (m *metricds) UpdateTrigger(ctx context.Context, trigger *models.Trigger) (_ *models.Trigger, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_update_trigger")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.UpdateTrigger(ctx, trigger)
 }
 
-func (m *metricds) RemoveTrigger(ctx context.Context, triggerID string) error {
+func (m *metricds) RemoveTrigger(ctx context.Context, triggerID string) (err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_remove_trigger")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.RemoveTrigger(ctx, triggerID)
 }
 
-func (m *metricds) GetTriggerByID(ctx context.Context, triggerID string) (*models.Trigger, error) {
+func (m *metricds) GetTriggerByID(ctx context.Context, triggerID string) (_ *models.Trigger, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_get_trigger_by_id")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.GetTriggerByID(ctx, triggerID)
 }
 
-func (m *metricds) GetTriggers(ctx context.Context, filter *models.TriggerFilter) (*models.TriggerList, error) {
+func (m *metricds) GetTriggers(ctx context.Context, filter *models.TriggerFilter) (_ *models.TriggerList, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_get_triggers")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.GetTriggers(ctx, filter)
 }
 
-func (m *metricds) InsertFn(ctx context.Context, fn *models.Fn) (*models.Fn, error) {
+func (m *metricds) InsertFn(ctx context.Context, fn *models.Fn) (_ *models.Fn, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_insert_func")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.InsertFn(ctx, fn)
 }
 
-func (m *metricds) UpdateFn(ctx context.Context, fn *models.Fn) (*models.Fn, error) {
+func (m *metricds) UpdateFn(ctx context.Context, fn *models.Fn) (_ *models.Fn, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_insert_func")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.UpdateFn(ctx, fn)
 }
 
-func (m *metricds) GetFns(ctx context.Context, filter *models.FnFilter) (*models.FnList, error) {
+func (m *metricds) GetFns(ctx context.Context, filter *models.FnFilter) (_ *models.FnList, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_get_funcs")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.GetFns(ctx, filter)
 }
 
-func (m *metricds) GetFnByID(ctx context.Context, fnID string) (*models.Fn, error) {
+func (m *metricds) GetFnByID(ctx context.Context, fnID string) (_ *models.Fn, err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_get_func")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.GetFnByID(ctx, fnID)
 }
 
-func (m *metricds) RemoveFn(ctx context.Context, fnID string) error {
+func (m *metricds) RemoveFn(ctx context.Context, fnID string) (err error) {
 	ctx, span := trace.StartSpan(ctx, "ds_remove_func")
-	defer span.End()
+	defer func() { common.EndSpan(span, err) }()
 	return m.ds.RemoveFn(ctx, fnID)
 }
 
+func (m *metricds) InsertCall(ctx context.Context, call *models.Call) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_insert_call")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.InsertCall(ctx, call)
+}
+
+func (m *metricds) GetCall(ctx context.Context, fnID, callID string) (_ *models.Call, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_get_call")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.GetCall(ctx, fnID, callID)
+}
+
+func (m *metricds) GetCalls(ctx context.Context, filter *models.CallFilter) (_ *models.CallList, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_get_calls")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.GetCalls(ctx, filter)
+}
+
+func (m *metricds) UpdateCallState(ctx context.Context, call *models.Call, from string) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_update_call_state")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.UpdateCallState(ctx, call, from)
+}
+
+func (m *metricds) RemoveCallInput(ctx context.Context, callID string) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_remove_call_input")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.RemoveCallInput(ctx, callID)
+}
+
+func (m *metricds) RemoveExpiredCallInputs(ctx context.Context, now time.Time) (_ int, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_remove_expired_call_inputs")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.RemoveExpiredCallInputs(ctx, now)
+}
+
+func (m *metricds) AppendCallLog(ctx context.Context, fnID, callID string, seq int, data []byte) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_append_call_log")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.AppendCallLog(ctx, fnID, callID, seq, data)
+}
+
+func (m *metricds) GetCallLog(ctx context.Context, fnID, callID string) (_ []byte, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_get_call_log")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.GetCallLog(ctx, fnID, callID)
+}
+
+func (m *metricds) RemoveExpiredCallLogs(ctx context.Context, before time.Time) (_ int, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_remove_expired_call_logs")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.RemoveExpiredCallLogs(ctx, before)
+}
+
+func (m *metricds) InsertWorkflow(ctx context.Context, workflow *models.Workflow) (_ *models.Workflow, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_insert_workflow")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.InsertWorkflow(ctx, workflow)
+}
+
+func (m *metricds) GetWorkflowByID(ctx context.Context, workflowID string) (_ *models.Workflow, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_get_workflow_by_id")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.GetWorkflowByID(ctx, workflowID)
+}
+
+func (m *metricds) GetWorkflows(ctx context.Context, filter *models.WorkflowFilter) (_ *models.WorkflowList, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_get_workflows")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.GetWorkflows(ctx, filter)
+}
+
+func (m *metricds) RemoveWorkflow(ctx context.Context, workflowID string) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_remove_workflow")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.RemoveWorkflow(ctx, workflowID)
+}
+
+func (m *metricds) InsertWorkflowRun(ctx context.Context, run *models.WorkflowRun) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_insert_workflow_run")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.InsertWorkflowRun(ctx, run)
+}
+
+func (m *metricds) FailLostWorkflowRuns(ctx context.Context, updatedBefore time.Time) (_ int, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_fail_lost_workflow_runs")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.FailLostWorkflowRuns(ctx, updatedBefore)
+}
+
+func (m *metricds) UpdateWorkflowRun(ctx context.Context, run *models.WorkflowRun) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_update_workflow_run")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.UpdateWorkflowRun(ctx, run)
+}
+
+func (m *metricds) GetWorkflowRun(ctx context.Context, workflowID, runID string) (_ *models.WorkflowRun, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_get_workflow_run")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.GetWorkflowRun(ctx, workflowID, runID)
+}
+
+func (m *metricds) GetWorkflowRuns(ctx context.Context, filter *models.WorkflowRunFilter) (_ *models.WorkflowRunList, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_get_workflow_runs")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.GetWorkflowRuns(ctx, filter)
+}
+
+func (m *metricds) InsertTriggerRun(ctx context.Context, run *models.TriggerRun) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_insert_trigger_run")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.InsertTriggerRun(ctx, run)
+}
+
+func (m *metricds) UpdateTriggerRun(ctx context.Context, run *models.TriggerRun) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_update_trigger_run")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.UpdateTriggerRun(ctx, run)
+}
+
+func (m *metricds) GetTriggerRun(ctx context.Context, triggerID, runID string) (_ *models.TriggerRun, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_get_trigger_run")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.GetTriggerRun(ctx, triggerID, runID)
+}
+
+func (m *metricds) GetTriggerRuns(ctx context.Context, filter *models.TriggerRunFilter) (_ *models.TriggerRunList, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_get_trigger_runs")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.GetTriggerRuns(ctx, filter)
+}
+
+func (m *metricds) RemoveExpiredTriggerRuns(ctx context.Context, now time.Time) (_ int, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_remove_expired_trigger_runs")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.RemoveExpiredTriggerRuns(ctx, now)
+}
+
+func (m *metricds) GetImageScan(ctx context.Context, digest string) (_ *models.ImageScan, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_get_image_scan")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.GetImageScan(ctx, digest)
+}
+
+func (m *metricds) PutImageScan(ctx context.Context, scan *models.ImageScan) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_put_image_scan")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.PutImageScan(ctx, scan)
+}
+
+func (m *metricds) InsertFnDeployment(ctx context.Context, deployment *models.FnDeployment) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_insert_fn_deployment")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.InsertFnDeployment(ctx, deployment)
+}
+
+func (m *metricds) GetFnDeployment(ctx context.Context, fnID, deploymentID string) (_ *models.FnDeployment, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_get_fn_deployment")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.GetFnDeployment(ctx, fnID, deploymentID)
+}
+
+func (m *metricds) GetFnDeployments(ctx context.Context, filter *models.FnDeploymentFilter) (_ *models.FnDeploymentList, err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_get_fn_deployments")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.GetFnDeployments(ctx, filter)
+}
+
+func (m *metricds) AcquireLease(ctx context.Context, lease *models.Lease) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_acquire_lease")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.AcquireLease(ctx, lease)
+}
+
+func (m *metricds) ReleaseLease(ctx context.Context, name, holder string) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ds_release_lease")
+	defer func() { common.EndSpan(span, err) }()
+	return m.ds.ReleaseLease(ctx, name, holder)
+}
+
+func (m *metricds) WatchChanges(ctx context.Context, fn func(models.DatastoreChange)) {
+	m.ds.WatchChanges(ctx, fn)
+}
+
 // Close calls Close on the underlying Datastore
 func (m *metricds) Close() error {
 	return m.ds.Close()
@@ -138,3 +138,229 @@ func (v *validator) RemoveFn(ctx context.Context, fnID string) error {
 	}
 	return v.Datastore.RemoveFn(ctx, fnID)
 }
+
+func (v *validator) InsertCall(ctx context.Context, call *models.Call) error {
+	if call.ID == "" {
+		return models.ErrDatastoreEmptyCallID
+	}
+	if call.FnID == "" {
+		return models.ErrDatastoreEmptyFnID
+	}
+	if call.Status != models.CallStateQueued {
+		return models.ErrCallInvalidState
+	}
+	return v.Datastore.InsertCall(ctx, call)
+}
+
+func (v *validator) GetCall(ctx context.Context, fnID, callID string) (*models.Call, error) {
+	if fnID == "" {
+		return nil, models.ErrDatastoreEmptyFnID
+	}
+	if callID == "" {
+		return nil, models.ErrDatastoreEmptyCallID
+	}
+	return v.Datastore.GetCall(ctx, fnID, callID)
+}
+
+func (v *validator) GetCalls(ctx context.Context, filter *models.CallFilter) (*models.CallList, error) {
+	if filter.FnID == "" {
+		return nil, models.ErrDatastoreEmptyFnID
+	}
+	if filter.Status != "" && !models.ValidCallState(filter.Status) {
+		return nil, models.ErrCallInvalidState
+	}
+	return v.Datastore.GetCalls(ctx, filter)
+}
+
+func (v *validator) UpdateCallState(ctx context.Context, call *models.Call, from string) error {
+	if call.ID == "" {
+		return models.ErrDatastoreEmptyCallID
+	}
+	if !models.ValidCallTransition(from, call.Status) {
+		return models.ErrCallInvalidTransition
+	}
+	return v.Datastore.UpdateCallState(ctx, call, from)
+}
+
+func (v *validator) RemoveCallInput(ctx context.Context, callID string) error {
+	if callID == "" {
+		return models.ErrDatastoreEmptyCallID
+	}
+	return v.Datastore.RemoveCallInput(ctx, callID)
+}
+
+func (v *validator) AppendCallLog(ctx context.Context, fnID, callID string, seq int, data []byte) error {
+	if fnID == "" {
+		return models.ErrDatastoreEmptyFnID
+	}
+	if callID == "" {
+		return models.ErrDatastoreEmptyCallID
+	}
+	return v.Datastore.AppendCallLog(ctx, fnID, callID, seq, data)
+}
+
+func (v *validator) GetCallLog(ctx context.Context, fnID, callID string) ([]byte, error) {
+	if fnID == "" {
+		return nil, models.ErrDatastoreEmptyFnID
+	}
+	if callID == "" {
+		return nil, models.ErrDatastoreEmptyCallID
+	}
+	return v.Datastore.GetCallLog(ctx, fnID, callID)
+}
+
+func (v *validator) InsertWorkflow(ctx context.Context, workflow *models.Workflow) (*models.Workflow, error) {
+	if workflow.ID != "" {
+		return nil, models.ErrWorkflowsIDProvided
+	}
+	if err := workflow.Validate(); err != nil {
+		return nil, err
+	}
+	return v.Datastore.InsertWorkflow(ctx, workflow)
+}
+
+func (v *validator) GetWorkflowByID(ctx context.Context, workflowID string) (*models.Workflow, error) {
+	if workflowID == "" {
+		return nil, models.ErrDatastoreEmptyWorkflowID
+	}
+	return v.Datastore.GetWorkflowByID(ctx, workflowID)
+}
+
+func (v *validator) RemoveWorkflow(ctx context.Context, workflowID string) error {
+	if workflowID == "" {
+		return models.ErrDatastoreEmptyWorkflowID
+	}
+	return v.Datastore.RemoveWorkflow(ctx, workflowID)
+}
+
+func (v *validator) InsertWorkflowRun(ctx context.Context, run *models.WorkflowRun) error {
+	if run.ID == "" {
+		return models.ErrDatastoreEmptyWorkflowRunID
+	}
+	if run.WorkflowID == "" {
+		return models.ErrDatastoreEmptyWorkflowID
+	}
+	return v.Datastore.InsertWorkflowRun(ctx, run)
+}
+
+func (v *validator) UpdateWorkflowRun(ctx context.Context, run *models.WorkflowRun) error {
+	if run.ID == "" {
+		return models.ErrDatastoreEmptyWorkflowRunID
+	}
+	return v.Datastore.UpdateWorkflowRun(ctx, run)
+}
+
+func (v *validator) GetWorkflowRun(ctx context.Context, workflowID, runID string) (*models.WorkflowRun, error) {
+	if workflowID == "" {
+		return nil, models.ErrDatastoreEmptyWorkflowID
+	}
+	if runID == "" {
+		return nil, models.ErrDatastoreEmptyWorkflowRunID
+	}
+	return v.Datastore.GetWorkflowRun(ctx, workflowID, runID)
+}
+
+func (v *validator) GetWorkflowRuns(ctx context.Context, filter *models.WorkflowRunFilter) (*models.WorkflowRunList, error) {
+	if filter.WorkflowID == "" {
+		return nil, models.ErrDatastoreEmptyWorkflowID
+	}
+	return v.Datastore.GetWorkflowRuns(ctx, filter)
+}
+
+func (v *validator) InsertTriggerRun(ctx context.Context, run *models.TriggerRun) error {
+	if run.ID == "" {
+		return models.ErrDatastoreEmptyTriggerRunID
+	}
+	if run.TriggerID == "" {
+		return models.ErrMissingID
+	}
+	return v.Datastore.InsertTriggerRun(ctx, run)
+}
+
+func (v *validator) UpdateTriggerRun(ctx context.Context, run *models.TriggerRun) error {
+	if run.ID == "" {
+		return models.ErrDatastoreEmptyTriggerRunID
+	}
+	return v.Datastore.UpdateTriggerRun(ctx, run)
+}
+
+func (v *validator) GetTriggerRun(ctx context.Context, triggerID, runID string) (*models.TriggerRun, error) {
+	if triggerID == "" {
+		return nil, models.ErrMissingID
+	}
+	if runID == "" {
+		return nil, models.ErrDatastoreEmptyTriggerRunID
+	}
+	return v.Datastore.GetTriggerRun(ctx, triggerID, runID)
+}
+
+func (v *validator) GetTriggerRuns(ctx context.Context, filter *models.TriggerRunFilter) (*models.TriggerRunList, error) {
+	if filter.TriggerID == "" {
+		return nil, models.ErrMissingID
+	}
+	if filter.Status != "" && !models.ValidCallState(filter.Status) {
+		return nil, models.ErrCallInvalidState
+	}
+	return v.Datastore.GetTriggerRuns(ctx, filter)
+}
+
+func (v *validator) GetImageScan(ctx context.Context, digest string) (*models.ImageScan, error) {
+	if digest == "" {
+		return nil, models.ErrDatastoreEmptyImageDigest
+	}
+	return v.Datastore.GetImageScan(ctx, digest)
+}
+
+func (v *validator) PutImageScan(ctx context.Context, scan *models.ImageScan) error {
+	if scan.Digest == "" {
+		return models.ErrDatastoreEmptyImageDigest
+	}
+	return v.Datastore.PutImageScan(ctx, scan)
+}
+
+func (v *validator) InsertFnDeployment(ctx context.Context, deployment *models.FnDeployment) error {
+	if deployment.ID == "" {
+		return models.ErrDatastoreEmptyFnDeploymentID
+	}
+	if deployment.FnID == "" {
+		return models.ErrDatastoreEmptyFnID
+	}
+	return v.Datastore.InsertFnDeployment(ctx, deployment)
+}
+
+func (v *validator) GetFnDeployment(ctx context.Context, fnID, deploymentID string) (*models.FnDeployment, error) {
+	if fnID == "" {
+		return nil, models.ErrDatastoreEmptyFnID
+	}
+	if deploymentID == "" {
+		return nil, models.ErrDatastoreEmptyFnDeploymentID
+	}
+	return v.Datastore.GetFnDeployment(ctx, fnID, deploymentID)
+}
+
+func (v *validator) GetFnDeployments(ctx context.Context, filter *models.FnDeploymentFilter) (*models.FnDeploymentList, error) {
+	if filter.FnID == "" {
+		return nil, models.ErrDatastoreEmptyFnID
+	}
+	return v.Datastore.GetFnDeployments(ctx, filter)
+}
+
+func (v *validator) AcquireLease(ctx context.Context, lease *models.Lease) error {
+	if lease.Name == "" {
+		return models.ErrDatastoreEmptyLeaseName
+	}
+	if lease.Holder == "" {
+		return models.ErrDatastoreEmptyLeaseHolder
+	}
+	return v.Datastore.AcquireLease(ctx, lease)
+}
+
+func (v *validator) ReleaseLease(ctx context.Context, name, holder string) error {
+	if name == "" {
+		return models.ErrDatastoreEmptyLeaseName
+	}
+	if holder == "" {
+		return models.ErrDatastoreEmptyLeaseHolder
+	}
+	return v.Datastore.ReleaseLease(ctx, name, holder)
+}
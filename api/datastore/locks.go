@@ -0,0 +1,33 @@
+package datastore
+
+import (
+	"context"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+)
+
+// leaseLockStore keeps locks as the leases of a datastore
+type leaseLockStore struct {
+	ds models.Datastore
+}
+
+// NewLockStore returns a common.LockStore keeping locks as the leases of ds, shared by the servers sharing ds
+func NewLockStore(ds models.Datastore) common.LockStore {
+	return &leaseLockStore{ds: ds}
+}
+
+// AcquireLock implements common.LockStore
+func (l *leaseLockStore) AcquireLock(ctx context.Context, name, holder string, expires time.Time) error {
+	err := l.ds.AcquireLease(ctx, &models.Lease{Name: name, Holder: holder, ExpiresAt: common.DateTime(expires)})
+	if err == models.ErrLeaseHeld {
+		return common.ErrLockHeld
+	}
+	return err
+}
+
+// ReleaseLock implements common.LockStore
+func (l *leaseLockStore) ReleaseLock(ctx context.Context, name, holder string) error {
+	return l.ds.ReleaseLease(ctx, name, holder)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fnproject/fn/api/common"
@@ -18,6 +19,28 @@ type mock struct {
 	Apps     []*models.App
 	Fns      []*models.Fn
 	Triggers []*models.Trigger
+
+	// calls are updated by the agent concurrently with api requests
+	callsLock   sync.Mutex
+	Calls       []*models.Call
+	TriggerRuns []*models.TriggerRun
+	CallLogs    []*mockCallLog
+
+	// as are workflow runs, by the workflow executor
+	workflowsLock sync.Mutex
+	Workflows     []*models.Workflow
+	WorkflowRuns  []*models.WorkflowRun
+
+	// image scans are cached and deployments recorded concurrently with fn updates
+	scansLock     sync.Mutex
+	ImageScans    []*models.ImageScan
+	FnDeployments []*models.FnDeployment
+
+	// leases are acquired and renewed by the servers sharing the datastore
+	leasesLock sync.Mutex
+	Leases     []*models.Lease
+
+	changes datastoreutil.ChangeFeed
 }
 
 // NewMock creates a new mock datastore
@@ -48,6 +71,18 @@ func NewMockInit(args ...interface{}) models.Datastore {
 			mocker.Fns = x
 		case []*models.Trigger:
 			mocker.Triggers = x
+		case []*models.Call:
+			mocker.Calls = x
+		case []*models.Workflow:
+			mocker.Workflows = x
+		case []*models.TriggerRun:
+			mocker.TriggerRuns = x
+		case []*models.ImageScan:
+			mocker.ImageScans = x
+		case []*models.FnDeployment:
+			mocker.FnDeployments = x
+		case []*models.Lease:
+			mocker.Leases = x
 
 		default:
 			panic("not accounted for data type sent to mock init. add it")
@@ -131,9 +166,10 @@ func (m *mock) InsertApp(ctx context.Context, newApp *models.App) (*models.App,
 	app := newApp.Clone()
 	app.CreatedAt = common.DateTime(time.Now())
 	app.UpdatedAt = app.CreatedAt
-	app.ID = id.New().String()
+	app.ID = id.NewString(id.KindApp)
 
 	m.Apps = append(m.Apps, app)
+	m.changes.Notify(models.DatastoreChange{Kind: models.ChangeKindApp, ID: app.ID})
 	return app.Clone(), nil
 }
 
@@ -152,6 +188,7 @@ func (m *mock) UpdateApp(ctx context.Context, app *models.App) (*models.App, err
 				return nil, err
 			}
 			m.Apps[idx] = c
+			m.changes.Notify(models.DatastoreChange{Kind: models.ChangeKindApp, ID: c.ID})
 			return c.Clone(), nil
 		}
 	}
@@ -167,9 +204,12 @@ func (m *mock) RemoveApp(ctx context.Context, appID string) error {
 			var newTriggers []*models.Trigger
 			newApps := append(m.Apps[0:i], m.Apps[i+1:]...)
 
+			removedFns := make(map[string]bool)
 			for _, fn := range m.Fns {
 				if fn.AppID != appID {
 					newFns = append(newFns, fn)
+				} else {
+					removedFns[fn.ID] = true
 				}
 			}
 
@@ -182,6 +222,11 @@ func (m *mock) RemoveApp(ctx context.Context, appID string) error {
 			m.Apps = newApps
 			m.Triggers = newTriggers
 			m.Fns = newFns
+			m.removeCalls(func(c *models.Call) bool { return c.AppID == appID })
+			m.removeTriggerRuns(func(r *models.TriggerRun) bool { return r.AppID == appID })
+			m.removeCallLogs(func(fnID string) bool { return removedFns[fnID] })
+			m.removeWorkflows(func(w *models.Workflow) bool { return w.AppID == appID })
+			m.changes.Notify(models.DatastoreChange{Kind: models.ChangeKindApp, ID: appID})
 			return nil
 
 		}
@@ -204,7 +249,7 @@ func (m *mock) InsertFn(ctx context.Context, fn *models.Fn) (*models.Fn, error)
 		}
 	}
 	cl := fn.Clone()
-	cl.ID = id.New().String()
+	cl.ID = id.NewString(id.KindFn)
 	cl.CreatedAt = common.DateTime(time.Now())
 	cl.UpdatedAt = cl.CreatedAt
 	err = fn.Validate()
@@ -213,6 +258,7 @@ func (m *mock) InsertFn(ctx context.Context, fn *models.Fn) (*models.Fn, error)
 	}
 
 	m.Fns = append(m.Fns, cl)
+	m.changes.Notify(models.DatastoreChange{Kind: models.ChangeKindFn, ID: cl.ID})
 
 	return cl.Clone(), nil
 }
@@ -228,6 +274,7 @@ func (m *mock) UpdateFn(ctx context.Context, fn *models.Fn) (*models.Fn, error)
 				return nil, err
 			}
 			*f = *clone
+			m.changes.Notify(models.DatastoreChange{Kind: models.ChangeKindFn, ID: f.ID})
 			return f, nil
 		}
 	}
@@ -302,6 +349,10 @@ func (m *mock) RemoveFn(ctx context.Context, fnID string) error {
 			}
 
 			m.Triggers = newTriggers
+			m.removeCalls(func(c *models.Call) bool { return c.FnID == fnID })
+			m.removeTriggerRuns(func(r *models.TriggerRun) bool { return r.FnID == fnID })
+			m.removeCallLogs(func(id string) bool { return id == fnID })
+			m.changes.Notify(models.DatastoreChange{Kind: models.ChangeKindFn, ID: fnID})
 			return nil
 		}
 	}
@@ -341,13 +392,14 @@ func (m *mock) InsertTrigger(ctx context.Context, trigger *models.Trigger) (*mod
 	cl := trigger.Clone()
 	cl.CreatedAt = common.DateTime(time.Now())
 	cl.UpdatedAt = cl.CreatedAt
-	cl.ID = id.New().String()
+	cl.ID = id.NewString(id.KindTrigger)
 
 	err = trigger.Validate()
 	if err != nil {
 		return nil, err
 	}
 	m.Triggers = append(m.Triggers, cl)
+	m.changes.Notify(models.DatastoreChange{Kind: models.ChangeKindTrigger, ID: cl.ID})
 	return cl.Clone(), nil
 }
 
@@ -361,6 +413,7 @@ func (m *mock) UpdateTrigger(ctx context.Context, trigger *models.Trigger) (*mod
 				return nil, err
 			}
 			*t = *cl
+			m.changes.Notify(models.DatastoreChange{Kind: models.ChangeKindTrigger, ID: cl.ID})
 			return cl.Clone(), nil
 		}
 	}
@@ -446,12 +499,662 @@ func (m *mock) RemoveTrigger(ctx context.Context, triggerID string) error {
 	for i, t := range m.Triggers {
 		if t.ID == triggerID {
 			m.Triggers = append(m.Triggers[:i], m.Triggers[i+1:]...)
+			m.removeTriggerRuns(func(r *models.TriggerRun) bool { return r.TriggerID == triggerID })
+			m.changes.Notify(models.DatastoreChange{Kind: models.ChangeKindTrigger, ID: triggerID})
 			return nil
 		}
 	}
 	return models.ErrTriggerNotFound
 }
 
+func (m *mock) removeCalls(match func(*models.Call) bool) {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	var newCalls []*models.Call
+	for _, c := range m.Calls {
+		if !match(c) {
+			newCalls = append(newCalls, c)
+		}
+	}
+	m.Calls = newCalls
+}
+
+func (m *mock) InsertCall(ctx context.Context, call *models.Call) error {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	for _, c := range m.Calls {
+		if c.ID == call.ID {
+			return models.ErrCallExists
+		}
+	}
+	cl := *call
+	m.Calls = append(m.Calls, &cl)
+	return nil
+}
+
+func (m *mock) GetCall(ctx context.Context, fnID, callID string) (*models.Call, error) {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	for _, c := range m.Calls {
+		if c.ID == callID && c.FnID == fnID {
+			cl := *c
+			return &cl, nil
+		}
+	}
+	return nil, models.ErrCallNotFound
+}
+
+type sortC []*models.Call
+
+func (s sortC) Len() int           { return len(s) }
+func (s sortC) Less(i, j int) bool { return strings.Compare(s[i].ID, s[j].ID) > 0 }
+func (s sortC) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (m *mock) GetCalls(ctx context.Context, filter *models.CallFilter) (*models.CallList, error) {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+
+	// sort them all first for cursoring (this is for testing, n is small & mock is not concurrent..)
+	sort.Sort(sortC(m.Calls))
+
+	var cursor string
+	if filter.Cursor != "" {
+		s, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = string(s)
+	}
+
+	res := []*models.Call{}
+	for _, c := range m.Calls {
+		if filter.PerPage > 0 && len(res) == filter.PerPage {
+			break
+		}
+
+		if (cursor == "" || strings.Compare(cursor, c.ID) > 0) &&
+			(filter.FnID == "" || c.FnID == filter.FnID) &&
+			(filter.Status == "" || c.Status == filter.Status) &&
+			(filter.ReplayOf == "" || c.ReplayOf == filter.ReplayOf) &&
+			(time.Time(filter.FromTime).IsZero() || time.Time(filter.FromTime).Before(time.Time(c.CreatedAt))) &&
+			(time.Time(filter.ToTime).IsZero() || time.Time(c.CreatedAt).Before(time.Time(filter.ToTime))) {
+			cl := *c
+			cl.Input = nil
+			res = append(res, &cl)
+		}
+	}
+
+	var nextCursor string
+	if len(res) > 0 && len(res) == filter.PerPage {
+		last := []byte(res[len(res)-1].ID)
+		nextCursor = base64.RawURLEncoding.EncodeToString(last)
+	}
+
+	return &models.CallList{
+		NextCursor: nextCursor,
+		Items:      res,
+	}, nil
+}
+
+func (m *mock) UpdateCallState(ctx context.Context, call *models.Call, from string) error {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	for _, c := range m.Calls {
+		if c.ID == call.ID {
+			if c.Status != from {
+				return models.ErrCallInvalidTransition
+			}
+			c.Status = call.Status
+			c.Error = call.Error
+			c.ErrorDetails = call.ErrorDetails
+			c.StartedAt = call.StartedAt
+			c.CompletedAt = call.CompletedAt
+			return nil
+		}
+	}
+	return models.ErrCallNotFound
+}
+
+func (m *mock) RemoveCallInput(ctx context.Context, callID string) error {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	for _, c := range m.Calls {
+		if c.ID == callID {
+			c.Input = nil
+			c.InputKey = ""
+			c.Replayable = false
+			return nil
+		}
+	}
+	return models.ErrCallNotFound
+}
+
+func (m *mock) RemoveExpiredCallInputs(ctx context.Context, now time.Time) (int, error) {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	var n int
+	for _, c := range m.Calls {
+		if c.Replayable && !time.Time(c.InputExpiresAt).IsZero() && time.Time(c.InputExpiresAt).Before(now) {
+			c.Input = nil
+			c.InputKey = ""
+			c.Replayable = false
+			n++
+		}
+	}
+	return n, nil
+}
+
+// mockCallLog is a part of the log of a call
+type mockCallLog struct {
+	fnID      string
+	callID    string
+	seq       int
+	data      []byte
+	createdAt time.Time
+}
+
+func (m *mock) AppendCallLog(ctx context.Context, fnID, callID string, seq int, data []byte) error {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	part := &mockCallLog{fnID: fnID, callID: callID, seq: seq, data: append([]byte(nil), data...), createdAt: time.Now()}
+	for i, l := range m.CallLogs {
+		if l.callID == callID && l.seq == seq {
+			m.CallLogs[i] = part
+			return nil
+		}
+	}
+	m.CallLogs = append(m.CallLogs, part)
+	return nil
+}
+
+func (m *mock) GetCallLog(ctx context.Context, fnID, callID string) ([]byte, error) {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	var parts []*mockCallLog
+	for _, l := range m.CallLogs {
+		if l.fnID == fnID && l.callID == callID {
+			parts = append(parts, l)
+		}
+	}
+	if len(parts) == 0 {
+		return nil, models.ErrCallLogNotFound
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].seq < parts[j].seq })
+	var log []byte
+	for _, l := range parts {
+		log = append(log, l.data...)
+	}
+	return log, nil
+}
+
+func (m *mock) removeCallLogs(matchFn func(fnID string) bool) {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	var kept []*mockCallLog
+	for _, l := range m.CallLogs {
+		if !matchFn(l.fnID) {
+			kept = append(kept, l)
+		}
+	}
+	m.CallLogs = kept
+}
+
+func (m *mock) RemoveExpiredCallLogs(ctx context.Context, before time.Time) (int, error) {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	var kept []*mockCallLog
+	for _, l := range m.CallLogs {
+		if !l.createdAt.Before(before) {
+			kept = append(kept, l)
+		}
+	}
+	n := len(m.CallLogs) - len(kept)
+	m.CallLogs = kept
+	return n, nil
+}
+
+func (m *mock) removeTriggerRuns(match func(*models.TriggerRun) bool) {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	var newRuns []*models.TriggerRun
+	for _, r := range m.TriggerRuns {
+		if !match(r) {
+			newRuns = append(newRuns, r)
+		}
+	}
+	m.TriggerRuns = newRuns
+}
+
+func (m *mock) InsertTriggerRun(ctx context.Context, run *models.TriggerRun) error {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	cl := *run
+	m.TriggerRuns = append(m.TriggerRuns, &cl)
+	return nil
+}
+
+func (m *mock) UpdateTriggerRun(ctx context.Context, run *models.TriggerRun) error {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	for _, r := range m.TriggerRuns {
+		if r.ID == run.ID {
+			r.Status = run.Status
+			r.Error = run.Error
+			r.CompletedAt = run.CompletedAt
+			return nil
+		}
+	}
+	return models.ErrTriggerRunNotFound
+}
+
+func (m *mock) GetTriggerRun(ctx context.Context, triggerID, runID string) (*models.TriggerRun, error) {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+	for _, r := range m.TriggerRuns {
+		if r.ID == runID && r.TriggerID == triggerID {
+			cl := *r
+			return &cl, nil
+		}
+	}
+	return nil, models.ErrTriggerRunNotFound
+}
+
+func (m *mock) RemoveExpiredTriggerRuns(ctx context.Context, now time.Time) (int, error) {
+	legacy := now.Add(-models.DefaultTriggerRunTTL * time.Second)
+	var n int
+	m.removeTriggerRuns(func(r *models.TriggerRun) bool {
+		expiresAt := time.Time(r.ExpiresAt)
+		if expiresAt.IsZero() && time.Time(r.CreatedAt).Before(legacy) || !expiresAt.IsZero() && expiresAt.Before(now) {
+			n++
+			return true
+		}
+		return false
+	})
+	return n, nil
+}
+
+type sortTR []*models.TriggerRun
+
+func (s sortTR) Len() int           { return len(s) }
+func (s sortTR) Less(i, j int) bool { return strings.Compare(s[i].ID, s[j].ID) > 0 }
+func (s sortTR) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (m *mock) GetTriggerRuns(ctx context.Context, filter *models.TriggerRunFilter) (*models.TriggerRunList, error) {
+	m.callsLock.Lock()
+	defer m.callsLock.Unlock()
+
+	sort.Sort(sortTR(m.TriggerRuns))
+
+	var cursor string
+	if filter.Cursor != "" {
+		s, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = string(s)
+	}
+
+	res := []*models.TriggerRun{}
+	for _, r := range m.TriggerRuns {
+		if filter.PerPage > 0 && len(res) == filter.PerPage {
+			break
+		}
+		if (cursor == "" || strings.Compare(cursor, r.ID) > 0) &&
+			r.TriggerID == filter.TriggerID &&
+			(filter.Status == "" || r.Status == filter.Status) {
+			cl := *r
+			cl.Payload = nil
+			res = append(res, &cl)
+		}
+	}
+
+	var nextCursor string
+	if len(res) > 0 && len(res) == filter.PerPage {
+		last := []byte(res[len(res)-1].ID)
+		nextCursor = base64.RawURLEncoding.EncodeToString(last)
+	}
+
+	return &models.TriggerRunList{
+		NextCursor: nextCursor,
+		Items:      res,
+	}, nil
+}
+
+func (m *mock) removeWorkflows(match func(*models.Workflow) bool) {
+	m.workflowsLock.Lock()
+	defer m.workflowsLock.Unlock()
+	var newWorkflows []*models.Workflow
+	removed := make(map[string]bool)
+	for _, w := range m.Workflows {
+		if match(w) {
+			removed[w.ID] = true
+		} else {
+			newWorkflows = append(newWorkflows, w)
+		}
+	}
+	var newRuns []*models.WorkflowRun
+	for _, r := range m.WorkflowRuns {
+		if !removed[r.WorkflowID] {
+			newRuns = append(newRuns, r)
+		}
+	}
+	m.Workflows = newWorkflows
+	m.WorkflowRuns = newRuns
+}
+
+func (m *mock) InsertWorkflow(ctx context.Context, workflow *models.Workflow) (*models.Workflow, error) {
+	_, err := m.GetAppByID(ctx, workflow.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.workflowsLock.Lock()
+	defer m.workflowsLock.Unlock()
+	for _, w := range m.Workflows {
+		if w.AppID == workflow.AppID && w.Name == workflow.Name {
+			return nil, models.ErrWorkflowsExists
+		}
+	}
+	cl := *workflow
+	cl.ID = id.New().String()
+	cl.CreatedAt = common.DateTime(time.Now())
+	cl.UpdatedAt = cl.CreatedAt
+	m.Workflows = append(m.Workflows, &cl)
+	ret := cl
+	return &ret, nil
+}
+
+func (m *mock) GetWorkflowByID(ctx context.Context, workflowID string) (*models.Workflow, error) {
+	m.workflowsLock.Lock()
+	defer m.workflowsLock.Unlock()
+	for _, w := range m.Workflows {
+		if w.ID == workflowID {
+			cl := *w
+			return &cl, nil
+		}
+	}
+	return nil, models.ErrWorkflowsNotFound
+}
+
+type sortW []*models.Workflow
+
+func (s sortW) Len() int           { return len(s) }
+func (s sortW) Less(i, j int) bool { return strings.Compare(s[i].Name, s[j].Name) < 0 }
+func (s sortW) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (m *mock) GetWorkflows(ctx context.Context, filter *models.WorkflowFilter) (*models.WorkflowList, error) {
+	m.workflowsLock.Lock()
+	defer m.workflowsLock.Unlock()
+
+	sort.Sort(sortW(m.Workflows))
+
+	var cursor string
+	if filter.Cursor != "" {
+		s, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = string(s)
+	}
+
+	res := []*models.Workflow{}
+	for _, w := range m.Workflows {
+		if filter.PerPage > 0 && len(res) == filter.PerPage {
+			break
+		}
+		if strings.Compare(cursor, w.Name) < 0 &&
+			(filter.AppID == "" || filter.AppID == w.AppID) {
+			cl := *w
+			res = append(res, &cl)
+		}
+	}
+
+	var nextCursor string
+	if len(res) > 0 && len(res) == filter.PerPage {
+		last := []byte(res[len(res)-1].Name)
+		nextCursor = base64.RawURLEncoding.EncodeToString(last)
+	}
+
+	return &models.WorkflowList{
+		NextCursor: nextCursor,
+		Items:      res,
+	}, nil
+}
+
+func (m *mock) RemoveWorkflow(ctx context.Context, workflowID string) error {
+	if _, err := m.GetWorkflowByID(ctx, workflowID); err != nil {
+		return err
+	}
+	m.removeWorkflows(func(w *models.Workflow) bool { return w.ID == workflowID })
+	return nil
+}
+
+func (m *mock) InsertWorkflowRun(ctx context.Context, run *models.WorkflowRun) error {
+	m.workflowsLock.Lock()
+	defer m.workflowsLock.Unlock()
+	cl := *run
+	cl.Outputs = append(models.WorkflowOutputs{}, run.Outputs...)
+	m.WorkflowRuns = append(m.WorkflowRuns, &cl)
+	return nil
+}
+
+func (m *mock) UpdateWorkflowRun(ctx context.Context, run *models.WorkflowRun) error {
+	m.workflowsLock.Lock()
+	defer m.workflowsLock.Unlock()
+	for _, r := range m.WorkflowRuns {
+		if r.ID == run.ID {
+			r.Status = run.Status
+			r.Step = run.Step
+			r.Outputs = append(models.WorkflowOutputs{}, run.Outputs...)
+			r.Error = run.Error
+			r.UpdatedAt = run.UpdatedAt
+			r.CompletedAt = run.CompletedAt
+			return nil
+		}
+	}
+	return models.ErrWorkflowRunNotFound
+}
+
+func (m *mock) FailLostWorkflowRuns(ctx context.Context, updatedBefore time.Time) (int, error) {
+	m.workflowsLock.Lock()
+	defer m.workflowsLock.Unlock()
+	now := common.DateTime(time.Now())
+	var n int
+	for _, r := range m.WorkflowRuns {
+		if (r.Status == models.WorkflowRunStateRunning || r.Status == models.WorkflowRunStateCompensating) &&
+			time.Time(r.UpdatedAt).Before(updatedBefore) {
+			r.Status = models.WorkflowRunStateFailed
+			r.Error = models.WorkflowRunLostError
+			r.UpdatedAt, r.CompletedAt = now, now
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *mock) GetWorkflowRun(ctx context.Context, workflowID, runID string) (*models.WorkflowRun, error) {
+	m.workflowsLock.Lock()
+	defer m.workflowsLock.Unlock()
+	for _, r := range m.WorkflowRuns {
+		if r.ID == runID && r.WorkflowID == workflowID {
+			cl := *r
+			cl.Outputs = append(models.WorkflowOutputs{}, r.Outputs...)
+			return &cl, nil
+		}
+	}
+	return nil, models.ErrWorkflowRunNotFound
+}
+
+type sortR []*models.WorkflowRun
+
+func (s sortR) Len() int           { return len(s) }
+func (s sortR) Less(i, j int) bool { return strings.Compare(s[i].ID, s[j].ID) > 0 }
+func (s sortR) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (m *mock) GetWorkflowRuns(ctx context.Context, filter *models.WorkflowRunFilter) (*models.WorkflowRunList, error) {
+	m.workflowsLock.Lock()
+	defer m.workflowsLock.Unlock()
+
+	sort.Sort(sortR(m.WorkflowRuns))
+
+	var cursor string
+	if filter.Cursor != "" {
+		s, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = string(s)
+	}
+
+	res := []*models.WorkflowRun{}
+	for _, r := range m.WorkflowRuns {
+		if filter.PerPage > 0 && len(res) == filter.PerPage {
+			break
+		}
+		if (cursor == "" || strings.Compare(cursor, r.ID) > 0) &&
+			r.WorkflowID == filter.WorkflowID &&
+			(filter.Status == "" || r.Status == filter.Status) {
+			cl := *r
+			cl.Outputs = append(models.WorkflowOutputs{}, r.Outputs...)
+			res = append(res, &cl)
+		}
+	}
+
+	var nextCursor string
+	if len(res) > 0 && len(res) == filter.PerPage {
+		last := []byte(res[len(res)-1].ID)
+		nextCursor = base64.RawURLEncoding.EncodeToString(last)
+	}
+
+	return &models.WorkflowRunList{
+		NextCursor: nextCursor,
+		Items:      res,
+	}, nil
+}
+
+func (m *mock) GetImageScan(ctx context.Context, digest string) (*models.ImageScan, error) {
+	m.scansLock.Lock()
+	defer m.scansLock.Unlock()
+	for _, s := range m.ImageScans {
+		if s.Digest == digest {
+			cl := *s
+			return &cl, nil
+		}
+	}
+	return nil, models.ErrImageScanNotFound
+}
+
+func (m *mock) PutImageScan(ctx context.Context, scan *models.ImageScan) error {
+	m.scansLock.Lock()
+	defer m.scansLock.Unlock()
+	cl := *scan
+	for i, s := range m.ImageScans {
+		if s.Digest == scan.Digest {
+			m.ImageScans[i] = &cl
+			return nil
+		}
+	}
+	m.ImageScans = append(m.ImageScans, &cl)
+	return nil
+}
+
+func (m *mock) InsertFnDeployment(ctx context.Context, deployment *models.FnDeployment) error {
+	m.scansLock.Lock()
+	defer m.scansLock.Unlock()
+	cl := *deployment
+	m.FnDeployments = append(m.FnDeployments, &cl)
+	return nil
+}
+
+func (m *mock) GetFnDeployment(ctx context.Context, fnID, deploymentID string) (*models.FnDeployment, error) {
+	m.scansLock.Lock()
+	defer m.scansLock.Unlock()
+	for _, d := range m.FnDeployments {
+		if d.ID == deploymentID && d.FnID == fnID {
+			cl := *d
+			return &cl, nil
+		}
+	}
+	return nil, models.ErrFnDeploymentNotFound
+}
+
+type sortFD []*models.FnDeployment
+
+func (s sortFD) Len() int           { return len(s) }
+func (s sortFD) Less(i, j int) bool { return strings.Compare(s[i].ID, s[j].ID) > 0 }
+func (s sortFD) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (m *mock) GetFnDeployments(ctx context.Context, filter *models.FnDeploymentFilter) (*models.FnDeploymentList, error) {
+	m.scansLock.Lock()
+	defer m.scansLock.Unlock()
+
+	sort.Sort(sortFD(m.FnDeployments))
+
+	var cursor string
+	if filter.Cursor != "" {
+		s, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = string(s)
+	}
+
+	res := []*models.FnDeployment{}
+	for _, d := range m.FnDeployments {
+		if filter.PerPage > 0 && len(res) == filter.PerPage {
+			break
+		}
+		if (cursor == "" || strings.Compare(cursor, d.ID) > 0) && d.FnID == filter.FnID {
+			cl := *d
+			res = append(res, &cl)
+		}
+	}
+
+	var nextCursor string
+	if len(res) > 0 && len(res) == filter.PerPage {
+		last := []byte(res[len(res)-1].ID)
+		nextCursor = base64.RawURLEncoding.EncodeToString(last)
+	}
+
+	return &models.FnDeploymentList{
+		NextCursor: nextCursor,
+		Items:      res,
+	}, nil
+}
+
 func (m *mock) Close() error {
 	return nil
 }
+
+func (m *mock) AcquireLease(ctx context.Context, lease *models.Lease) error {
+	m.leasesLock.Lock()
+	defer m.leasesLock.Unlock()
+	cl := *lease
+	for i, l := range m.Leases {
+		if l.Name == lease.Name {
+			if l.Holder != lease.Holder && time.Now().Before(time.Time(l.ExpiresAt)) {
+				return models.ErrLeaseHeld
+			}
+			m.Leases[i] = &cl
+			return nil
+		}
+	}
+	m.Leases = append(m.Leases, &cl)
+	return nil
+}
+
+func (m *mock) ReleaseLease(ctx context.Context, name, holder string) error {
+	m.leasesLock.Lock()
+	defer m.leasesLock.Unlock()
+	for i, l := range m.Leases {
+		if l.Name == name && l.Holder == holder {
+			m.Leases = append(m.Leases[:i], m.Leases[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *mock) WatchChanges(ctx context.Context, fn func(models.DatastoreChange)) {
+	m.changes.Watch(ctx, fn)
+}
@@ -2,6 +2,7 @@
 package dbhelper
 
 import (
+	"context"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
@@ -30,6 +31,16 @@ type Helper interface {
 	IsDuplicateKeyError(err error) bool
 }
 
+// Notifier is implemented by the helpers of dbs that publish notifications to the other clients of the db, such as
+// postgres LISTEN/NOTIFY
+type Notifier interface {
+	// Notify publishes payload on channel to the listeners of the db
+	Notify(ctx context.Context, db *sqlx.DB, channel, payload string) error
+	// Listen calls fn with the payloads published on channel, until ctx is done. It calls fn with "" when
+	// notifications may have been missed, such as after reconnecting to the db.
+	Listen(ctx context.Context, uri, channel string, fn func(payload string))
+}
+
 // GetHelper returns a helper for a specific driver
 func GetHelper(driverName string) (Helper, bool) {
 	for _, helper := range sqlHelpers {
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/datastore/sql/migratex"
+	"github.com/jmoiron/sqlx"
+)
+
+func up26(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, "ALTER TABLE fns ADD tmpfs_size int NOT NULL DEFAULT 0;")
+	return err
+}
+
+func down26(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, "ALTER TABLE fns DROP COLUMN tmpfs_size;")
+	return err
+}
+
+func init() {
+	Migrations = append(Migrations, &migratex.MigFields{
+		VersionFunc: vfunc(26),
+		UpFunc:      up26,
+		DownFunc:    down26,
+	})
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/datastore/sql/migratex"
+	"github.com/jmoiron/sqlx"
+)
+
+func up27(ctx context.Context, tx *sqlx.Tx) error {
+	createQuery := `CREATE TABLE IF NOT EXISTS calls (
+	id varchar(256) NOT NULL PRIMARY KEY,
+	fn_id varchar(256) NOT NULL,
+	app_id varchar(256) NOT NULL,
+	trigger_id varchar(256) NOT NULL,
+	status varchar(256) NOT NULL,
+	timeout int NOT NULL,
+	error text NOT NULL,
+	created_at varchar(256) NOT NULL,
+	started_at varchar(256) NOT NULL,
+	completed_at varchar(256) NOT NULL
+);`
+	_, err := tx.ExecContext(ctx, createQuery)
+	return err
+}
+
+func down27(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, "DROP TABLE calls;")
+	return err
+}
+
+func init() {
+	Migrations = append(Migrations, &migratex.MigFields{
+		VersionFunc: vfunc(27),
+		UpFunc:      up27,
+		DownFunc:    down27,
+	})
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/datastore/sql/migratex"
+	"github.com/jmoiron/sqlx"
+)
+
+func up29(ctx context.Context, tx *sqlx.Tx) error {
+	createQuery := `CREATE TABLE IF NOT EXISTS workflows (
+	id varchar(256) NOT NULL PRIMARY KEY,
+	name varchar(256) NOT NULL,
+	app_id varchar(256) NOT NULL,
+	steps text NOT NULL,
+	created_at varchar(256) NOT NULL,
+	updated_at varchar(256) NOT NULL,
+	CONSTRAINT workflow_name_app_id_unique UNIQUE (app_id, name)
+);`
+	_, err := tx.ExecContext(ctx, createQuery)
+	if err != nil {
+		return err
+	}
+
+	createQuery = `CREATE TABLE IF NOT EXISTS workflow_runs (
+	id varchar(256) NOT NULL PRIMARY KEY,
+	workflow_id varchar(256) NOT NULL,
+	status varchar(256) NOT NULL,
+	step int NOT NULL,
+	input text NOT NULL,
+	outputs text NOT NULL,
+	error text NOT NULL,
+	created_at varchar(256) NOT NULL,
+	updated_at varchar(256) NOT NULL,
+	completed_at varchar(256) NOT NULL
+);`
+	_, err = tx.ExecContext(ctx, createQuery)
+	return err
+}
+
+func down29(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, "DROP TABLE workflow_runs;")
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DROP TABLE workflows;")
+	return err
+}
+
+func init() {
+	Migrations = append(Migrations, &migratex.MigFields{
+		VersionFunc: vfunc(29),
+		UpFunc:      up29,
+		DownFunc:    down29,
+	})
+}
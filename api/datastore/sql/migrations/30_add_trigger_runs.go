@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/datastore/sql/migratex"
+	"github.com/jmoiron/sqlx"
+)
+
+func up30(ctx context.Context, tx *sqlx.Tx) error {
+	createQuery := `CREATE TABLE IF NOT EXISTS trigger_runs (
+	id varchar(256) NOT NULL PRIMARY KEY,
+	trigger_id varchar(256) NOT NULL,
+	app_id varchar(256) NOT NULL,
+	fn_id varchar(256) NOT NULL,
+	status varchar(256) NOT NULL,
+	error text NOT NULL,
+	method varchar(256) NOT NULL,
+	url text NOT NULL,
+	content_type varchar(256) NOT NULL,
+	payload text NOT NULL,
+	replayable boolean NOT NULL,
+	created_at varchar(256) NOT NULL,
+	completed_at varchar(256) NOT NULL
+);`
+	_, err := tx.ExecContext(ctx, createQuery)
+	return err
+}
+
+func down30(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, "DROP TABLE trigger_runs;")
+	return err
+}
+
+func init() {
+	Migrations = append(Migrations, &migratex.MigFields{
+		VersionFunc: vfunc(30),
+		UpFunc:      up30,
+		DownFunc:    down30,
+	})
+}
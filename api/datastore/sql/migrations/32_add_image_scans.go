@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/datastore/sql/migratex"
+	"github.com/jmoiron/sqlx"
+)
+
+func up32(ctx context.Context, tx *sqlx.Tx) error {
+	createQuery := `CREATE TABLE IF NOT EXISTS image_scans (
+	digest varchar(256) NOT NULL PRIMARY KEY,
+	image text NOT NULL,
+	vulnerabilities text NOT NULL,
+	scanned_at varchar(256) NOT NULL
+);`
+	_, err := tx.ExecContext(ctx, createQuery)
+	return err
+}
+
+func down32(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, "DROP TABLE image_scans;")
+	return err
+}
+
+func init() {
+	Migrations = append(Migrations, &migratex.MigFields{
+		VersionFunc: vfunc(32),
+		UpFunc:      up32,
+		DownFunc:    down32,
+	})
+}
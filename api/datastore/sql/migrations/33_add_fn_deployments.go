@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/datastore/sql/migratex"
+	"github.com/jmoiron/sqlx"
+)
+
+func up33(ctx context.Context, tx *sqlx.Tx) error {
+	createQuery := `CREATE TABLE IF NOT EXISTS fn_deployments (
+	id varchar(256) NOT NULL PRIMARY KEY,
+	fn_id varchar(256) NOT NULL,
+	app_id varchar(256) NOT NULL,
+	image text NOT NULL,
+	digest varchar(256) NOT NULL,
+	deployed_by varchar(256) NOT NULL,
+	source_commit varchar(256) NOT NULL,
+	scanned boolean NOT NULL,
+	vulnerabilities text NOT NULL,
+	signature_status varchar(256) NOT NULL,
+	created_at varchar(256) NOT NULL
+);`
+	_, err := tx.ExecContext(ctx, createQuery)
+	return err
+}
+
+func down33(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, "DROP TABLE fn_deployments;")
+	return err
+}
+
+func init() {
+	Migrations = append(Migrations, &migratex.MigFields{
+		VersionFunc: vfunc(33),
+		UpFunc:      up33,
+		DownFunc:    down33,
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/datastore/sql/migratex"
+	"github.com/jmoiron/sqlx"
+)
+
+func up34(ctx context.Context, tx *sqlx.Tx) error {
+	if _, err := tx.ExecContext(ctx, "ALTER TABLE fns ADD disabled boolean;"); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "ALTER TABLE triggers ADD disabled boolean;")
+	return err
+}
+
+func down34(ctx context.Context, tx *sqlx.Tx) error {
+	if _, err := tx.ExecContext(ctx, "ALTER TABLE fns DROP COLUMN disabled;"); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "ALTER TABLE triggers DROP COLUMN disabled;")
+	return err
+}
+
+func init() {
+	Migrations = append(Migrations, &migratex.MigFields{
+		VersionFunc: vfunc(34),
+		UpFunc:      up34,
+		DownFunc:    down34,
+	})
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/datastore/sql/migratex"
+	"github.com/jmoiron/sqlx"
+)
+
+var callReplayColumns = []string{
+	"content_type varchar(256)",
+	"input text",
+	"replayable boolean NOT NULL DEFAULT false",
+	"replay_of varchar(256)",
+	"deployment_id varchar(256)",
+}
+
+func up35(ctx context.Context, tx *sqlx.Tx) error {
+	for _, column := range callReplayColumns {
+		if _, err := tx.ExecContext(ctx, "ALTER TABLE calls ADD "+column+";"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func down35(ctx context.Context, tx *sqlx.Tx) error {
+	for _, column := range []string{"content_type", "input", "replayable", "replay_of", "deployment_id"} {
+		if _, err := tx.ExecContext(ctx, "ALTER TABLE calls DROP COLUMN "+column+";"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	Migrations = append(Migrations, &migratex.MigFields{
+		VersionFunc: vfunc(35),
+		UpFunc:      up35,
+		DownFunc:    down35,
+	})
+}
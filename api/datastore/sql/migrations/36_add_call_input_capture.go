@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/datastore/sql/migratex"
+	"github.com/jmoiron/sqlx"
+)
+
+func up36(ctx context.Context, tx *sqlx.Tx) error {
+	if _, err := tx.ExecContext(ctx, "ALTER TABLE calls ADD input_key text;"); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "ALTER TABLE calls ADD input_expires_at varchar(256);")
+	return err
+}
+
+func down36(ctx context.Context, tx *sqlx.Tx) error {
+	if _, err := tx.ExecContext(ctx, "ALTER TABLE calls DROP COLUMN input_key;"); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "ALTER TABLE calls DROP COLUMN input_expires_at;")
+	return err
+}
+
+func init() {
+	Migrations = append(Migrations, &migratex.MigFields{
+		VersionFunc: vfunc(36),
+		UpFunc:      up36,
+		DownFunc:    down36,
+	})
+}
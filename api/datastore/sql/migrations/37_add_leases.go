@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/datastore/sql/migratex"
+	"github.com/jmoiron/sqlx"
+)
+
+func up37(ctx context.Context, tx *sqlx.Tx) error {
+	createQuery := `CREATE TABLE IF NOT EXISTS leases (
+	name varchar(256) NOT NULL PRIMARY KEY,
+	holder varchar(256) NOT NULL,
+	expires_at varchar(256) NOT NULL
+);`
+	_, err := tx.ExecContext(ctx, createQuery)
+	return err
+}
+
+func down37(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, "DROP TABLE leases;")
+	return err
+}
+
+func init() {
+	Migrations = append(Migrations, &migratex.MigFields{
+		VersionFunc: vfunc(37),
+		UpFunc:      up37,
+		DownFunc:    down37,
+	})
+}
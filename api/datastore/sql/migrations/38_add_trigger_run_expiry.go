@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/datastore/sql/migratex"
+	"github.com/jmoiron/sqlx"
+)
+
+func up38(ctx context.Context, tx *sqlx.Tx) error {
+	if _, err := tx.ExecContext(ctx, "ALTER TABLE trigger_runs ADD payload_key text;"); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "ALTER TABLE trigger_runs ADD expires_at varchar(256);")
+	return err
+}
+
+func down38(ctx context.Context, tx *sqlx.Tx) error {
+	if _, err := tx.ExecContext(ctx, "ALTER TABLE trigger_runs DROP COLUMN payload_key;"); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "ALTER TABLE trigger_runs DROP COLUMN expires_at;")
+	return err
+}
+
+func init() {
+	Migrations = append(Migrations, &migratex.MigFields{
+		VersionFunc: vfunc(38),
+		UpFunc:      up38,
+		DownFunc:    down38,
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/datastore/sql/migratex"
+	"github.com/jmoiron/sqlx"
+)
+
+func up39(ctx context.Context, tx *sqlx.Tx) error {
+	createQuery := `CREATE TABLE IF NOT EXISTS call_logs (
+	call_id varchar(256) NOT NULL,
+	seq int NOT NULL,
+	fn_id varchar(256) NOT NULL,
+	log text NOT NULL,
+	created_at varchar(256) NOT NULL,
+	PRIMARY KEY (call_id, seq)
+);`
+	_, err := tx.ExecContext(ctx, createQuery)
+	return err
+}
+
+func down39(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, "DROP TABLE call_logs;")
+	return err
+}
+
+func init() {
+	Migrations = append(Migrations, &migratex.MigFields{
+		VersionFunc: vfunc(39),
+		UpFunc:      up39,
+		DownFunc:    down39,
+	})
+}
@@ -1,15 +1,20 @@
 package postgres
 
 import (
+	"context"
+	"github.com/fnproject/fn/api/common"
 	"github.com/fnproject/fn/api/datastore/sql/dbhelper"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	"net/url"
+	"time"
 )
 
 type postgresHelper int
 
+var _ dbhelper.Notifier = postgresHelper(0)
+
 func (postgresHelper) Supports(scheme string) bool {
 	switch scheme {
 	case "postgres", "pgx":
@@ -58,6 +63,42 @@ func (postgresHelper) IsDuplicateKeyError(err error) bool {
 	return false
 }
 
+// Notify publishes payload with pg_notify, postgres delivers it to the listeners of channel once the transaction
+// of the notification commits
+func (postgresHelper) Notify(ctx context.Context, db *sqlx.DB, channel, payload string) error {
+	_, err := db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, channel, payload)
+	return err
+}
+
+// Listen LISTENs on channel on a connection of its own, reconnecting as pq.Listener does when the connection is lost
+func (postgresHelper) Listen(ctx context.Context, uri, channel string, fn func(payload string)) {
+	log := common.Logger(ctx).WithField("channel", channel)
+	l := pq.NewListener(uri, 100*time.Millisecond, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.WithError(err).Warn("postgres listener connection error")
+		}
+	})
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	go func() {
+		if err := l.Listen(channel); err != nil && ctx.Err() == nil {
+			log.WithError(err).Error("error listening for postgres notifications")
+		}
+	}()
+
+	// Notify is closed once the listener is
+	for n := range l.Notify {
+		if n == nil {
+			// the listener reconnected, notifications in between are lost
+			fn("")
+			continue
+		}
+		fn(n.Extra)
+	}
+}
+
 func init() {
 	dbhelper.Register(postgresHelper(0))
 }
@@ -5,14 +5,16 @@ import (
 	"context"
 	"database/sql"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/url"
-	"os"
-	"strconv"
+	"sync"
 	"time"
 
 	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/config"
 	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/datastore/internal/datastoreutil"
 	"github.com/fnproject/fn/api/datastore/sql/dbhelper"
 	"github.com/fnproject/fn/api/datastore/sql/migratex"
 	"github.com/fnproject/fn/api/datastore/sql/migrations"
@@ -53,6 +55,7 @@ var tables = [...]string{
 	type varchar(256) NOT NULL,
 	source varchar(256) NOT NULL,
     annotations text NOT NULL,
+	disabled boolean,
     CONSTRAINT name_app_id_fn_id_unique UNIQUE (app_id, fn_id, name)
 );`,
 
@@ -62,28 +65,147 @@ var tables = [...]string{
 	app_id varchar(256) NOT NULL,
 	image varchar(256) NOT NULL,
 	memory int NOT NULL,
+	cpus int NOT NULL DEFAULT 0,
+	tmpfs_size int NOT NULL DEFAULT 0,
 	timeout int NOT NULL,
 	idle_timeout int NOT NULL,
 	config text NOT NULL,
 	annotations text NOT NULL,
+	chain text,
+	disabled boolean,
 	created_at varchar(256) NOT NULL,
 	updated_at varchar(256) NOT NULL,
     CONSTRAINT name_app_id_unique UNIQUE (app_id, name)
 );`,
+
+	`CREATE TABLE IF NOT EXISTS calls (
+	id varchar(256) NOT NULL PRIMARY KEY,
+	fn_id varchar(256) NOT NULL,
+	app_id varchar(256) NOT NULL,
+	trigger_id varchar(256) NOT NULL,
+	status varchar(256) NOT NULL,
+	timeout int NOT NULL,
+	error text NOT NULL,
+	error_details text,
+	created_at varchar(256) NOT NULL,
+	started_at varchar(256) NOT NULL,
+	completed_at varchar(256) NOT NULL,
+	content_type varchar(256),
+	input text,
+	replayable boolean NOT NULL DEFAULT false,
+	replay_of varchar(256),
+	deployment_id varchar(256),
+	input_key text,
+	input_expires_at varchar(256)
+);`,
+
+	`CREATE TABLE IF NOT EXISTS workflows (
+	id varchar(256) NOT NULL PRIMARY KEY,
+	name varchar(256) NOT NULL,
+	app_id varchar(256) NOT NULL,
+	steps text NOT NULL,
+	created_at varchar(256) NOT NULL,
+	updated_at varchar(256) NOT NULL,
+	CONSTRAINT workflow_name_app_id_unique UNIQUE (app_id, name)
+);`,
+
+	`CREATE TABLE IF NOT EXISTS workflow_runs (
+	id varchar(256) NOT NULL PRIMARY KEY,
+	workflow_id varchar(256) NOT NULL,
+	status varchar(256) NOT NULL,
+	step int NOT NULL,
+	input text NOT NULL,
+	outputs text NOT NULL,
+	error text NOT NULL,
+	created_at varchar(256) NOT NULL,
+	updated_at varchar(256) NOT NULL,
+	completed_at varchar(256) NOT NULL
+);`,
+
+	`CREATE TABLE IF NOT EXISTS trigger_runs (
+	id varchar(256) NOT NULL PRIMARY KEY,
+	trigger_id varchar(256) NOT NULL,
+	app_id varchar(256) NOT NULL,
+	fn_id varchar(256) NOT NULL,
+	status varchar(256) NOT NULL,
+	error text NOT NULL,
+	method varchar(256) NOT NULL,
+	url text NOT NULL,
+	content_type varchar(256) NOT NULL,
+	payload text NOT NULL,
+	replayable boolean NOT NULL,
+	created_at varchar(256) NOT NULL,
+	completed_at varchar(256) NOT NULL,
+	payload_key text,
+	expires_at varchar(256)
+);`,
+
+	`CREATE TABLE IF NOT EXISTS image_scans (
+	digest varchar(256) NOT NULL PRIMARY KEY,
+	image text NOT NULL,
+	vulnerabilities text NOT NULL,
+	scanned_at varchar(256) NOT NULL
+);`,
+
+	`CREATE TABLE IF NOT EXISTS fn_deployments (
+	id varchar(256) NOT NULL PRIMARY KEY,
+	fn_id varchar(256) NOT NULL,
+	app_id varchar(256) NOT NULL,
+	image text NOT NULL,
+	digest varchar(256) NOT NULL,
+	deployed_by varchar(256) NOT NULL,
+	source_commit varchar(256) NOT NULL,
+	scanned boolean NOT NULL,
+	vulnerabilities text NOT NULL,
+	signature_status varchar(256) NOT NULL,
+	created_at varchar(256) NOT NULL
+);`,
+
+	`CREATE TABLE IF NOT EXISTS leases (
+	name varchar(256) NOT NULL PRIMARY KEY,
+	holder varchar(256) NOT NULL,
+	expires_at varchar(256) NOT NULL
+);`,
+
+	`CREATE TABLE IF NOT EXISTS call_logs (
+	call_id varchar(256) NOT NULL,
+	seq int NOT NULL,
+	fn_id varchar(256) NOT NULL,
+	log text NOT NULL,
+	created_at varchar(256) NOT NULL,
+	PRIMARY KEY (call_id, seq)
+);`,
 }
 
 const (
 	appIDSelector     = `SELECT id, name, config, annotations, syslog_url, created_at, updated_at FROM apps WHERE id=?`
 	ensureAppSelector = `SELECT id FROM apps WHERE name=?`
 
-	fnSelector   = `SELECT id,name,app_id,image,memory,timeout,idle_timeout,config,annotations,created_at,updated_at FROM fns`
+	fnSelector   = `SELECT id,name,app_id,image,memory,cpus,tmpfs_size,timeout,idle_timeout,config,annotations,chain,disabled,created_at,updated_at FROM fns`
 	fnIDSelector = fnSelector + ` WHERE id=?`
 
-	triggerSelector   = `SELECT id,name,app_id,fn_id,type,source,annotations,created_at,updated_at FROM triggers`
+	triggerSelector   = `SELECT id,name,app_id,fn_id,type,source,annotations,disabled,created_at,updated_at FROM triggers`
 	triggerIDSelector = triggerSelector + ` WHERE id=?`
 
 	triggerIDSourceSelector = triggerSelector + ` WHERE app_id=? AND type=? AND source=?`
 
+	// callColumns are the columns of calls but their inputs, which are only selected for a single call
+	callColumns      = `id,fn_id,app_id,trigger_id,status,timeout,error,COALESCE(error_details, '') AS error_details,created_at,started_at,completed_at,COALESCE(content_type, '') AS content_type,replayable,COALESCE(replay_of, '') AS replay_of,COALESCE(deployment_id, '') AS deployment_id,COALESCE(input_key, '') AS input_key,input_expires_at`
+	callSelector     = `SELECT input,` + callColumns + ` FROM calls`
+	callListSelector = `SELECT ` + callColumns + ` FROM calls`
+
+	imageScanSelector = `SELECT digest,image,vulnerabilities,scanned_at FROM image_scans`
+
+	fnDeploymentSelector = `SELECT id,fn_id,app_id,image,digest,deployed_by,source_commit,scanned,vulnerabilities,signature_status,created_at FROM fn_deployments`
+
+	// triggerRunColumns are the columns of trigger runs but their payloads, which are only selected for a single run
+	triggerRunColumns      = `id,trigger_id,app_id,fn_id,status,error,method,url,content_type,replayable,created_at,completed_at,COALESCE(payload_key, '') AS payload_key,expires_at`
+	triggerRunSelector     = `SELECT payload,` + triggerRunColumns + ` FROM trigger_runs`
+	triggerRunListSelector = `SELECT ` + triggerRunColumns + ` FROM trigger_runs`
+
+	workflowSelector    = `SELECT id,name,app_id,steps,created_at,updated_at FROM workflows`
+	workflowRunSelector = `SELECT id,workflow_id,status,step,input,outputs,error,created_at,updated_at,completed_at FROM workflow_runs`
+
 	EnvDBPingMaxRetries = "FN_DS_DB_PING_MAX_RETRIES"
 )
 
@@ -95,6 +217,17 @@ var ( // compiler will yell nice things about our upbringing as a child
 type SQLStore struct {
 	helper dbhelper.Helper
 	db     *sqlx.DB
+
+	// uri is the connect URL of the db, which listeners of notifications connect to
+	uri string
+	// origin tells the changes this store publishes from the ones of the other servers sharing the db
+	origin  string
+	changes datastoreutil.ChangeFeed
+	// listen starts listening for the changes of the other servers, once the changes are watched, until
+	// stopListener is called on Close
+	listen       sync.Once
+	listenCtx    context.Context
+	stopListener context.CancelFunc
 }
 
 type sqlDsProvider int
@@ -162,7 +295,8 @@ func newDS(ctx context.Context, url *url.URL) (*SQLStore, error) {
 		log.WithError(err).Error("couldn't initialize db")
 		return nil, err
 	}
-	sdb := &SQLStore{db: db, helper: helper}
+	sdb := &SQLStore{db: db, helper: helper, uri: uri, origin: id.New().String()}
+	sdb.listenCtx, sdb.stopListener = context.WithCancel(common.BackgroundContext(ctx))
 
 	// NOTE: runMigrations happens before we create all the tables, so that it
 	// can detect whether the db did not exist and insert the latest version of
@@ -201,16 +335,14 @@ func newDS(ctx context.Context, url *url.URL) (*SQLStore, error) {
 
 func pingWithRetry(ctx context.Context, db *sqlx.DB) (err error) {
 
-	attempts := int64(10)
-	if tmp := os.Getenv(EnvDBPingMaxRetries); tmp != "" {
-		attempts, err = strconv.ParseInt(tmp, 10, 64)
-		if err != nil {
-			return fmt.Errorf("cannot parse (%s) invalid %s=%s", err, EnvDBPingMaxRetries, tmp)
-		}
-		if attempts < 0 {
-			return fmt.Errorf("cannot parse invalid %s=%s", EnvDBPingMaxRetries, tmp)
-		}
+	maxRetries, err := config.Int(EnvDBPingMaxRetries, 10)
+	if err != nil {
+		return err
+	}
+	if maxRetries < 0 {
+		return fmt.Errorf("cannot parse invalid %s=%d", EnvDBPingMaxRetries, maxRetries)
 	}
+	attempts := int64(maxRetries)
 
 	if ctx.Err() != nil {
 		return ctx.Err()
@@ -283,6 +415,54 @@ func (ds *SQLStore) clear() error {
 
 		query = tx.Rebind(`DELETE FROM fns`)
 		_, err = tx.Exec(query)
+		if err != nil {
+			return err
+		}
+
+		query = tx.Rebind(`DELETE FROM calls`)
+		_, err = tx.Exec(query)
+		if err != nil {
+			return err
+		}
+
+		query = tx.Rebind(`DELETE FROM trigger_runs`)
+		_, err = tx.Exec(query)
+		if err != nil {
+			return err
+		}
+
+		query = tx.Rebind(`DELETE FROM image_scans`)
+		_, err = tx.Exec(query)
+		if err != nil {
+			return err
+		}
+
+		query = tx.Rebind(`DELETE FROM fn_deployments`)
+		_, err = tx.Exec(query)
+		if err != nil {
+			return err
+		}
+
+		query = tx.Rebind(`DELETE FROM workflows`)
+		_, err = tx.Exec(query)
+		if err != nil {
+			return err
+		}
+
+		query = tx.Rebind(`DELETE FROM workflow_runs`)
+		_, err = tx.Exec(query)
+		if err != nil {
+			return err
+		}
+
+		query = tx.Rebind(`DELETE FROM leases`)
+		_, err = tx.Exec(query)
+		if err != nil {
+			return err
+		}
+
+		query = tx.Rebind(`DELETE FROM call_logs`)
+		_, err = tx.Exec(query)
 		return err
 	})
 }
@@ -307,7 +487,7 @@ func (ds *SQLStore) InsertApp(ctx context.Context, newApp *models.App) (*models.
 	app := newApp.Clone()
 	app.CreatedAt = common.DateTime(time.Now())
 	app.UpdatedAt = app.CreatedAt
-	app.ID = id.New().String()
+	app.ID = id.NewString(id.KindApp)
 
 	if app.Config == nil {
 		// keeps the JSON from being nil
@@ -340,6 +520,7 @@ func (ds *SQLStore) InsertApp(ctx context.Context, newApp *models.App) (*models.
 		return nil, err
 	}
 
+	ds.changed(ctx, models.DatastoreChange{Kind: models.ChangeKindApp, ID: app.ID})
 	return app, nil
 }
 
@@ -389,10 +570,16 @@ func (ds *SQLStore) UpdateApp(ctx context.Context, newapp *models.App) (*models.
 		return nil, err
 	}
 
+	ds.changed(ctx, models.DatastoreChange{Kind: models.ChangeKindApp, ID: app.ID})
 	return &app, nil
 }
 
-func (ds *SQLStore) RemoveApp(ctx context.Context, appID string) error {
+func (ds *SQLStore) RemoveApp(ctx context.Context, appID string) (err error) {
+	defer func() {
+		if err == nil {
+			ds.changed(ctx, models.DatastoreChange{Kind: models.ChangeKindApp, ID: appID})
+		}
+	}()
 	return ds.Tx(func(tx *sqlx.Tx) error {
 		res, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM apps WHERE id=?`), appID)
 		if err != nil {
@@ -407,8 +594,13 @@ func (ds *SQLStore) RemoveApp(ctx context.Context, appID string) error {
 		}
 
 		deletes := []string{
+			`DELETE FROM call_logs WHERE fn_id IN (SELECT id FROM fns WHERE app_id=?)`,
 			`DELETE FROM fns WHERE app_id=?`,
 			`DELETE FROM triggers WHERE app_id=?`,
+			`DELETE FROM calls WHERE app_id=?`,
+			`DELETE FROM trigger_runs WHERE app_id=?`,
+			`DELETE FROM workflow_runs WHERE workflow_id IN (SELECT id FROM workflows WHERE app_id=?)`,
+			`DELETE FROM workflows WHERE app_id=?`,
 		}
 		for _, stmt := range deletes {
 			_, err := tx.ExecContext(ctx, tx.Rebind(stmt), appID)
@@ -477,7 +669,7 @@ func (ds *SQLStore) GetApps(ctx context.Context, filter *models.AppFilter) (*mod
 
 func (ds *SQLStore) InsertFn(ctx context.Context, newFn *models.Fn) (*models.Fn, error) {
 	fn := newFn.Clone()
-	fn.ID = id.New().String()
+	fn.ID = id.NewString(id.KindFn)
 	fn.CreatedAt = common.DateTime(time.Now())
 	fn.UpdatedAt = fn.CreatedAt
 
@@ -502,10 +694,14 @@ func (ds *SQLStore) InsertFn(ctx context.Context, newFn *models.Fn) (*models.Fn,
 				app_id,
 				image,
 				memory,
+				cpus,
+				tmpfs_size,
 				timeout,
 				idle_timeout,
 				config,
 				annotations,
+				chain,
+				disabled,
 				created_at,
 				updated_at
 			)
@@ -515,10 +711,14 @@ func (ds *SQLStore) InsertFn(ctx context.Context, newFn *models.Fn) (*models.Fn,
 				:app_id,
 				:image,
 				:memory,
+				:cpus,
+				:tmpfs_size,
 				:timeout,
 				:idle_timeout,
 				:config,
 				:annotations,
+				:chain,
+				:disabled,
 				:created_at,
 				:updated_at
 			);`)
@@ -533,6 +733,7 @@ func (ds *SQLStore) InsertFn(ctx context.Context, newFn *models.Fn) (*models.Fn,
 		}
 		return nil, err
 	}
+	ds.changed(ctx, models.DatastoreChange{Kind: models.ChangeKindFn, ID: fn.ID})
 	return fn, nil
 }
 
@@ -561,10 +762,14 @@ func (ds *SQLStore) UpdateFn(ctx context.Context, fn *models.Fn) (*models.Fn, er
 				name = :name,
 				image = :image,
 				memory = :memory,
+				cpus = :cpus,
+				tmpfs_size = :tmpfs_size,
 				timeout = :timeout,
 				idle_timeout = :idle_timeout,
 				config = :config,
 				annotations = :annotations,
+				chain = :chain,
+				disabled = :disabled,
 				updated_at = :updated_at
 			    WHERE id=:id;`)
 
@@ -575,6 +780,7 @@ func (ds *SQLStore) UpdateFn(ctx context.Context, fn *models.Fn) (*models.Fn, er
 	if err != nil {
 		return nil, err
 	}
+	ds.changed(ctx, models.DatastoreChange{Kind: models.ChangeKindFn, ID: fn.ID})
 	return fn, nil
 }
 
@@ -638,7 +844,12 @@ func (ds *SQLStore) GetFnByID(ctx context.Context, fnID string) (*models.Fn, err
 	return &fn, nil
 }
 
-func (ds *SQLStore) RemoveFn(ctx context.Context, fnID string) error {
+func (ds *SQLStore) RemoveFn(ctx context.Context, fnID string) (err error) {
+	defer func() {
+		if err == nil {
+			ds.changed(ctx, models.DatastoreChange{Kind: models.ChangeKindFn, ID: fnID})
+		}
+	}()
 	return ds.Tx(func(tx *sqlx.Tx) error {
 		/* #nosec */
 		query := tx.Rebind(fmt.Sprintf("%s WHERE id=?", fnSelector))
@@ -657,6 +868,27 @@ func (ds *SQLStore) RemoveFn(ctx context.Context, fnID string) error {
 			return err
 		}
 
+		query = tx.Rebind(`DELETE FROM calls WHERE fn_id=?`)
+		_, err = tx.ExecContext(ctx, query, fnID)
+
+		if err != nil {
+			return err
+		}
+
+		query = tx.Rebind(`DELETE FROM trigger_runs WHERE fn_id=?`)
+		_, err = tx.ExecContext(ctx, query, fnID)
+
+		if err != nil {
+			return err
+		}
+
+		query = tx.Rebind(`DELETE FROM call_logs WHERE fn_id=?`)
+		_, err = tx.ExecContext(ctx, query, fnID)
+
+		if err != nil {
+			return err
+		}
+
 		query = tx.Rebind(`DELETE FROM fns WHERE id=?`)
 		_, err = tx.ExecContext(ctx, query, fnID)
 
@@ -763,7 +995,7 @@ func (ds *SQLStore) InsertTrigger(ctx context.Context, newTrigger *models.Trigge
 
 	trigger.CreatedAt = common.DateTime(time.Now())
 	trigger.UpdatedAt = trigger.CreatedAt
-	trigger.ID = id.New().String()
+	trigger.ID = id.NewString(id.KindTrigger)
 
 	err := trigger.Validate()
 	if err != nil {
@@ -813,7 +1045,8 @@ func (ds *SQLStore) InsertTrigger(ctx context.Context, newTrigger *models.Trigge
 			updated_at,
 			type,
 		  	source,
-		  	annotations
+		  	annotations,
+			disabled
 		)
 		VALUES (
 			:id,
@@ -824,7 +1057,8 @@ func (ds *SQLStore) InsertTrigger(ctx context.Context, newTrigger *models.Trigge
 			:updated_at,
 			:type,
 			:source,
-			:annotations
+			:annotations,
+			:disabled
 		);`)
 
 		_, err = tx.NamedExecContext(ctx, query, trigger)
@@ -838,6 +1072,7 @@ func (ds *SQLStore) InsertTrigger(ctx context.Context, newTrigger *models.Trigge
 		return nil, err
 	}
 
+	ds.changed(ctx, models.DatastoreChange{Kind: models.ChangeKindTrigger, ID: trigger.ID})
 	return trigger, err
 }
 
@@ -867,7 +1102,8 @@ func (ds *SQLStore) UpdateTrigger(ctx context.Context, trigger *models.Trigger)
 			fn_id = :fn_id,
 			updated_at = :updated_at,
 			source = :source,
-			annotations = :annotations
+			annotations = :annotations,
+			disabled = :disabled
 			WHERE id = :id;`)
 		_, err = tx.NamedExecContext(ctx, query, trigger)
 		return err
@@ -876,6 +1112,7 @@ func (ds *SQLStore) UpdateTrigger(ctx context.Context, trigger *models.Trigger)
 	if err != nil {
 		return nil, err
 	}
+	ds.changed(ctx, models.DatastoreChange{Kind: models.ChangeKindTrigger, ID: trigger.ID})
 	return trigger, nil
 }
 
@@ -896,23 +1133,32 @@ func (ds *SQLStore) GetTrigger(ctx context.Context, appId, fnId, triggerName str
 	return &trigger, nil
 }
 
-func (ds *SQLStore) RemoveTrigger(ctx context.Context, triggerId string) error {
-	query := ds.db.Rebind(`DELETE FROM triggers WHERE id = ?;`)
-	res, err := ds.db.ExecContext(ctx, query, triggerId)
-	if err != nil {
-		return err
-	}
+func (ds *SQLStore) RemoveTrigger(ctx context.Context, triggerId string) (err error) {
+	defer func() {
+		if err == nil {
+			ds.changed(ctx, models.DatastoreChange{Kind: models.ChangeKindTrigger, ID: triggerId})
+		}
+	}()
+	return ds.Tx(func(tx *sqlx.Tx) error {
+		query := tx.Rebind(`DELETE FROM triggers WHERE id = ?;`)
+		res, err := tx.ExecContext(ctx, query, triggerId)
+		if err != nil {
+			return err
+		}
 
-	n, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
 
-	if n == 0 {
-		return models.ErrTriggerNotFound
-	}
+		if n == 0 {
+			return models.ErrTriggerNotFound
+		}
 
-	return nil
+		query = tx.Rebind(`DELETE FROM trigger_runs WHERE trigger_id = ?;`)
+		_, err = tx.ExecContext(ctx, query, triggerId)
+		return err
+	})
 }
 
 func (ds *SQLStore) GetTriggerByID(ctx context.Context, triggerID string) (*models.Trigger, error) {
@@ -1028,8 +1274,918 @@ func (ds *SQLStore) GetTriggerBySource(ctx context.Context, appId string, trigge
 	return &trigger, nil
 }
 
+func (ds *SQLStore) InsertCall(ctx context.Context, call *models.Call) error {
+	query := ds.db.Rebind(`INSERT INTO calls (
+		id,
+		fn_id,
+		app_id,
+		trigger_id,
+		status,
+		timeout,
+		error,
+		error_details,
+		created_at,
+		started_at,
+		completed_at,
+		content_type,
+		input,
+		replayable,
+		replay_of,
+		deployment_id,
+		input_key,
+		input_expires_at
+	)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+
+	_, err := ds.db.ExecContext(ctx, query, call.ID, call.FnID, call.AppID, call.TriggerID, call.Status,
+		call.Timeout, call.Error, call.ErrorDetails, call.CreatedAt, call.StartedAt, call.CompletedAt,
+		call.ContentType, call.Input, call.Replayable, call.ReplayOf, call.DeploymentID, call.InputKey, call.InputExpiresAt)
+	if err != nil && ds.helper.IsDuplicateKeyError(err) {
+		return models.ErrCallExists
+	}
+	return err
+}
+
+func (ds *SQLStore) GetCall(ctx context.Context, fnID, callID string) (*models.Call, error) {
+	query := ds.db.Rebind(callSelector + ` WHERE id=? AND fn_id=?`)
+	row := ds.db.QueryRowxContext(ctx, query, callID, fnID)
+
+	var call models.Call
+	err := row.StructScan(&call)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrCallNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &call, nil
+}
+
+func buildFilterCallQuery(filter *models.CallFilter) (string, []interface{}, error) {
+	var b bytes.Buffer
+	var args []interface{}
+
+	args = where(&b, args, "fn_id=?", filter.FnID)
+	args = where(&b, args, "status=?", filter.Status)
+	args = where(&b, args, "replay_of=?", filter.ReplayOf)
+
+	if filter.Cursor != "" {
+		s, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		args = where(&b, args, "id<?", string(s))
+	}
+	if !time.Time(filter.FromTime).IsZero() {
+		args = where(&b, args, "created_at>?", filter.FromTime.String())
+	}
+	if !time.Time(filter.ToTime).IsZero() {
+		args = where(&b, args, "created_at<?", filter.ToTime.String())
+	}
+
+	fmt.Fprintf(&b, ` ORDER BY id DESC`) // ids are time ordered
+	if filter.PerPage > 0 {
+		fmt.Fprintf(&b, ` LIMIT ?`)
+		args = append(args, filter.PerPage)
+	}
+	return b.String(), args, nil
+}
+
+func (ds *SQLStore) GetCalls(ctx context.Context, filter *models.CallFilter) (*models.CallList, error) {
+	res := &models.CallList{Items: []*models.Call{}}
+
+	filterQuery, args, err := buildFilterCallQuery(filter)
+	if err != nil {
+		return res, err
+	}
+
+	/* #nosec */
+	query := ds.db.Rebind(fmt.Sprintf("%s %s", callListSelector, filterQuery))
+	rows, err := ds.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return res, nil // no error for empty list
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var call models.Call
+		err := rows.StructScan(&call)
+		if err != nil {
+			continue
+		}
+		res.Items = append(res.Items, &call)
+	}
+
+	if len(res.Items) > 0 && len(res.Items) == filter.PerPage {
+		last := []byte(res.Items[len(res.Items)-1].ID)
+		res.NextCursor = base64.RawURLEncoding.EncodeToString(last)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (ds *SQLStore) UpdateCallState(ctx context.Context, call *models.Call, from string) error {
+	// the status guard makes this a compare-and-swap, so that concurrent
+	// transitions (eg. completion racing expiry) cannot both succeed
+	query := ds.db.Rebind(`UPDATE calls SET
+		status=?,
+		error=?,
+		error_details=?,
+		started_at=?,
+		completed_at=?
+	WHERE id=? AND status=?;`)
+
+	res, err := ds.db.ExecContext(ctx, query, call.Status, call.Error, call.ErrorDetails, call.StartedAt, call.CompletedAt, call.ID, from)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// distinguish a missing call from one that has moved on
+		var status string
+		query = ds.db.Rebind(`SELECT status FROM calls WHERE id=?`)
+		err = ds.db.QueryRowxContext(ctx, query, call.ID).Scan(&status)
+		if err == sql.ErrNoRows {
+			return models.ErrCallNotFound
+		} else if err != nil {
+			return err
+		}
+		return models.ErrCallInvalidTransition
+	}
+	return nil
+}
+
+func (ds *SQLStore) RemoveCallInput(ctx context.Context, callID string) error {
+	query := ds.db.Rebind(`UPDATE calls SET input=NULL, input_key=NULL, replayable=? WHERE id=?;`)
+	res, err := ds.db.ExecContext(ctx, query, false, callID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrCallNotFound
+	}
+	return nil
+}
+
+func (ds *SQLStore) RemoveExpiredCallInputs(ctx context.Context, now time.Time) (int, error) {
+	// inputs without a ttl are stored with the zero time, and never expire
+	query := ds.db.Rebind(`UPDATE calls SET input=NULL, input_key=NULL, replayable=? WHERE replayable=? AND input_expires_at>? AND input_expires_at<?;`)
+	res, err := ds.db.ExecContext(ctx, query, false, true, common.DateTime{}.String(), common.DateTime(now).String())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (ds *SQLStore) AppendCallLog(ctx context.Context, fnID, callID string, seq int, data []byte) error {
+	return ds.Tx(func(tx *sqlx.Tx) error {
+		query := tx.Rebind(`DELETE FROM call_logs WHERE call_id=? AND seq=?`)
+		_, err := tx.ExecContext(ctx, query, callID, seq)
+		if err != nil {
+			return err
+		}
+
+		// logs are stored encoded, as fns may write anything to stderr
+		query = tx.Rebind(`INSERT INTO call_logs (
+			call_id,
+			seq,
+			fn_id,
+			log,
+			created_at
+		)
+		VALUES (?, ?, ?, ?, ?);`)
+		_, err = tx.ExecContext(ctx, query, callID, seq, fnID, base64.StdEncoding.EncodeToString(data), common.DateTime(time.Now()).String())
+		return err
+	})
+}
+
+func (ds *SQLStore) GetCallLog(ctx context.Context, fnID, callID string) ([]byte, error) {
+	query := ds.db.Rebind(`SELECT log FROM call_logs WHERE fn_id=? AND call_id=? ORDER BY seq`)
+	rows, err := ds.db.QueryxContext(ctx, query, fnID, callID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	var found bool
+	for rows.Next() {
+		var part string
+		if err := rows.Scan(&part); err != nil {
+			return nil, err
+		}
+		data, err := base64.StdEncoding.DecodeString(part)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		found = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, models.ErrCallLogNotFound
+	}
+	return buf.Bytes(), nil
+}
+
+func (ds *SQLStore) RemoveExpiredCallLogs(ctx context.Context, before time.Time) (int, error) {
+	query := ds.db.Rebind(`DELETE FROM call_logs WHERE created_at<?;`)
+	res, err := ds.db.ExecContext(ctx, query, common.DateTime(before).String())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (ds *SQLStore) InsertTriggerRun(ctx context.Context, run *models.TriggerRun) error {
+	query := ds.db.Rebind(`INSERT INTO trigger_runs (
+		id,
+		trigger_id,
+		app_id,
+		fn_id,
+		status,
+		error,
+		method,
+		url,
+		content_type,
+		payload,
+		replayable,
+		created_at,
+		completed_at,
+		payload_key,
+		expires_at
+	)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+
+	_, err := ds.db.ExecContext(ctx, query, run.ID, run.TriggerID, run.AppID, run.FnID, run.Status, run.Error,
+		run.Method, run.URL, run.ContentType, run.Payload, run.Replayable, run.CreatedAt, run.CompletedAt,
+		run.PayloadKey, run.ExpiresAt)
+	return err
+}
+
+func (ds *SQLStore) UpdateTriggerRun(ctx context.Context, run *models.TriggerRun) error {
+	query := ds.db.Rebind(`UPDATE trigger_runs SET
+		status=?,
+		error=?,
+		completed_at=?
+	WHERE id=?;`)
+
+	res, err := ds.db.ExecContext(ctx, query, run.Status, run.Error, run.CompletedAt, run.ID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrTriggerRunNotFound
+	}
+	return nil
+}
+
+func (ds *SQLStore) GetTriggerRun(ctx context.Context, triggerID, runID string) (*models.TriggerRun, error) {
+	query := ds.db.Rebind(triggerRunSelector + ` WHERE id=? AND trigger_id=?`)
+	row := ds.db.QueryRowxContext(ctx, query, runID, triggerID)
+
+	var run models.TriggerRun
+	err := row.StructScan(&run)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrTriggerRunNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (ds *SQLStore) RemoveExpiredTriggerRuns(ctx context.Context, now time.Time) (int, error) {
+	// runs without an expiry, such as those recorded before runs expired, are kept for the default ttl. The zero
+	// time is stored for runs inserted without one.
+	zero := common.DateTime{}.String()
+	legacy := now.Add(-models.DefaultTriggerRunTTL * time.Second)
+	query := ds.db.Rebind(`DELETE FROM trigger_runs WHERE (expires_at>? AND expires_at<?) OR ((expires_at IS NULL OR expires_at=?) AND created_at<?);`)
+	res, err := ds.db.ExecContext(ctx, query, zero, common.DateTime(now).String(), zero, common.DateTime(legacy).String())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func buildFilterTriggerRunQuery(filter *models.TriggerRunFilter) (string, []interface{}, error) {
+	var b bytes.Buffer
+	var args []interface{}
+
+	args = where(&b, args, "trigger_id=?", filter.TriggerID)
+	args = where(&b, args, "status=?", filter.Status)
+
+	if filter.Cursor != "" {
+		s, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		args = where(&b, args, "id<?", string(s))
+	}
+
+	fmt.Fprintf(&b, ` ORDER BY id DESC`) // ids are time ordered
+	if filter.PerPage > 0 {
+		fmt.Fprintf(&b, ` LIMIT ?`)
+		args = append(args, filter.PerPage)
+	}
+	return b.String(), args, nil
+}
+
+func (ds *SQLStore) GetTriggerRuns(ctx context.Context, filter *models.TriggerRunFilter) (*models.TriggerRunList, error) {
+	res := &models.TriggerRunList{Items: []*models.TriggerRun{}}
+
+	filterQuery, args, err := buildFilterTriggerRunQuery(filter)
+	if err != nil {
+		return res, err
+	}
+
+	/* #nosec */
+	query := ds.db.Rebind(fmt.Sprintf("%s %s", triggerRunListSelector, filterQuery))
+	rows, err := ds.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run models.TriggerRun
+		err := rows.StructScan(&run)
+		if err != nil {
+			return nil, err
+		}
+		res.Items = append(res.Items, &run)
+	}
+
+	if len(res.Items) > 0 && len(res.Items) == filter.PerPage {
+		last := []byte(res.Items[len(res.Items)-1].ID)
+		res.NextCursor = base64.RawURLEncoding.EncodeToString(last)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (ds *SQLStore) GetImageScan(ctx context.Context, digest string) (*models.ImageScan, error) {
+	query := ds.db.Rebind(imageScanSelector + ` WHERE digest=?`)
+	row := ds.db.QueryRowxContext(ctx, query, digest)
+
+	var scan models.ImageScan
+	err := row.StructScan(&scan)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrImageScanNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+func (ds *SQLStore) PutImageScan(ctx context.Context, scan *models.ImageScan) error {
+	return ds.Tx(func(tx *sqlx.Tx) error {
+		query := tx.Rebind(`DELETE FROM image_scans WHERE digest=?`)
+		_, err := tx.ExecContext(ctx, query, scan.Digest)
+		if err != nil {
+			return err
+		}
+
+		query = tx.Rebind(`INSERT INTO image_scans (
+			digest,
+			image,
+			vulnerabilities,
+			scanned_at
+		)
+		VALUES (?, ?, ?, ?);`)
+		_, err = tx.ExecContext(ctx, query, scan.Digest, scan.Image, scan.Vulnerabilities, scan.ScannedAt)
+		return err
+	})
+}
+
+func (ds *SQLStore) AcquireLease(ctx context.Context, lease *models.Lease) error {
+	return ds.Tx(func(tx *sqlx.Tx) error {
+		var holder, expiresAt string
+		query := tx.Rebind(`SELECT holder,expires_at FROM leases WHERE name=?`)
+		err := tx.QueryRowxContext(ctx, query, lease.Name).Scan(&holder, &expiresAt)
+		if err == sql.ErrNoRows {
+			query = tx.Rebind(`INSERT INTO leases (
+				name,
+				holder,
+				expires_at
+			)
+			VALUES (?, ?, ?);`)
+			_, err = tx.ExecContext(ctx, query, lease.Name, lease.Holder, lease.ExpiresAt)
+			if err != nil && ds.helper.IsDuplicateKeyError(err) {
+				return models.ErrLeaseHeld
+			}
+			return err
+		} else if err != nil {
+			return err
+		}
+
+		var expires common.DateTime
+		if err := expires.UnmarshalText([]byte(expiresAt)); err != nil {
+			return err
+		}
+		if holder != lease.Holder && time.Now().Before(time.Time(expires)) {
+			return models.ErrLeaseHeld
+		}
+
+		// the lease is only taken as it was read, another holder may have taken it since
+		query = tx.Rebind(`UPDATE leases SET
+			holder=?,
+			expires_at=?
+		WHERE name=? AND holder=? AND expires_at=?;`)
+		res, err := tx.ExecContext(ctx, query, lease.Holder, lease.ExpiresAt, lease.Name, holder, expiresAt)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return models.ErrLeaseHeld
+		}
+		return nil
+	})
+}
+
+func (ds *SQLStore) ReleaseLease(ctx context.Context, name, holder string) error {
+	query := ds.db.Rebind(`DELETE FROM leases WHERE name=? AND holder=?`)
+	_, err := ds.db.ExecContext(ctx, query, name, holder)
+	return err
+}
+
+func (ds *SQLStore) InsertFnDeployment(ctx context.Context, deployment *models.FnDeployment) error {
+	query := ds.db.Rebind(`INSERT INTO fn_deployments (
+		id,
+		fn_id,
+		app_id,
+		image,
+		digest,
+		deployed_by,
+		source_commit,
+		scanned,
+		vulnerabilities,
+		signature_status,
+		created_at
+	)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+
+	_, err := ds.db.ExecContext(ctx, query, deployment.ID, deployment.FnID, deployment.AppID, deployment.Image,
+		deployment.Digest, deployment.DeployedBy, deployment.SourceCommit, deployment.Scanned, deployment.Vulnerabilities,
+		deployment.SignatureStatus, deployment.CreatedAt)
+	return err
+}
+
+func (ds *SQLStore) GetFnDeployment(ctx context.Context, fnID, deploymentID string) (*models.FnDeployment, error) {
+	query := ds.db.Rebind(fnDeploymentSelector + ` WHERE id=? AND fn_id=?`)
+	row := ds.db.QueryRowxContext(ctx, query, deploymentID, fnID)
+
+	var deployment models.FnDeployment
+	err := row.StructScan(&deployment)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrFnDeploymentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+func buildFilterFnDeploymentQuery(filter *models.FnDeploymentFilter) (string, []interface{}, error) {
+	var b bytes.Buffer
+	var args []interface{}
+
+	args = where(&b, args, "fn_id=?", filter.FnID)
+
+	if filter.Cursor != "" {
+		s, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		args = where(&b, args, "id<?", string(s))
+	}
+
+	fmt.Fprintf(&b, ` ORDER BY id DESC`) // ids are time ordered
+	if filter.PerPage > 0 {
+		fmt.Fprintf(&b, ` LIMIT ?`)
+		args = append(args, filter.PerPage)
+	}
+	return b.String(), args, nil
+}
+
+func (ds *SQLStore) GetFnDeployments(ctx context.Context, filter *models.FnDeploymentFilter) (*models.FnDeploymentList, error) {
+	res := &models.FnDeploymentList{Items: []*models.FnDeployment{}}
+
+	filterQuery, args, err := buildFilterFnDeploymentQuery(filter)
+	if err != nil {
+		return res, err
+	}
+
+	/* #nosec */
+	query := ds.db.Rebind(fmt.Sprintf("%s %s", fnDeploymentSelector, filterQuery))
+	rows, err := ds.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var deployment models.FnDeployment
+		err := rows.StructScan(&deployment)
+		if err != nil {
+			return nil, err
+		}
+		res.Items = append(res.Items, &deployment)
+	}
+
+	if len(res.Items) > 0 && len(res.Items) == filter.PerPage {
+		last := []byte(res.Items[len(res.Items)-1].ID)
+		res.NextCursor = base64.RawURLEncoding.EncodeToString(last)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (ds *SQLStore) InsertWorkflow(ctx context.Context, newWorkflow *models.Workflow) (*models.Workflow, error) {
+	workflow := *newWorkflow
+	workflow.ID = id.New().String()
+	workflow.CreatedAt = common.DateTime(time.Now())
+	workflow.UpdatedAt = workflow.CreatedAt
+
+	err := ds.Tx(func(tx *sqlx.Tx) error {
+		query := tx.Rebind(`SELECT 1 FROM apps WHERE id=?`)
+		r := tx.QueryRowContext(ctx, query, workflow.AppID)
+		if err := r.Scan(new(int)); err != nil {
+			if err == sql.ErrNoRows {
+				return models.ErrAppsNotFound
+			}
+			return err
+		}
+
+		query = tx.Rebind(`INSERT INTO workflows (
+				id,
+				name,
+				app_id,
+				steps,
+				created_at,
+				updated_at
+			)
+			VALUES (
+				:id,
+				:name,
+				:app_id,
+				:steps,
+				:created_at,
+				:updated_at
+			);`)
+
+		_, err := tx.NamedExecContext(ctx, query, &workflow)
+		return err
+	})
+
+	if err != nil {
+		if ds.helper.IsDuplicateKeyError(err) {
+			return nil, models.ErrWorkflowsExists
+		}
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+func (ds *SQLStore) GetWorkflowByID(ctx context.Context, workflowID string) (*models.Workflow, error) {
+	query := ds.db.Rebind(workflowSelector + ` WHERE id=?`)
+	row := ds.db.QueryRowxContext(ctx, query, workflowID)
+
+	var workflow models.Workflow
+	err := row.StructScan(&workflow)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrWorkflowsNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+func buildFilterWorkflowQuery(filter *models.WorkflowFilter) (string, []interface{}, error) {
+	var b bytes.Buffer
+	var args []interface{}
+
+	args = where(&b, args, "app_id=?", filter.AppID)
+
+	if filter.Cursor != "" {
+		s, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		args = where(&b, args, "name>?", string(s))
+	}
+
+	fmt.Fprintf(&b, ` ORDER BY name ASC`)
+	if filter.PerPage > 0 {
+		fmt.Fprintf(&b, ` LIMIT ?`)
+		args = append(args, filter.PerPage)
+	}
+	return b.String(), args, nil
+}
+
+func (ds *SQLStore) GetWorkflows(ctx context.Context, filter *models.WorkflowFilter) (*models.WorkflowList, error) {
+	res := &models.WorkflowList{Items: []*models.Workflow{}}
+
+	filterQuery, args, err := buildFilterWorkflowQuery(filter)
+	if err != nil {
+		return res, err
+	}
+
+	/* #nosec */
+	query := ds.db.Rebind(fmt.Sprintf("%s %s", workflowSelector, filterQuery))
+	rows, err := ds.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var workflow models.Workflow
+		err := rows.StructScan(&workflow)
+		if err != nil {
+			return nil, err
+		}
+		res.Items = append(res.Items, &workflow)
+	}
+
+	if len(res.Items) > 0 && len(res.Items) == filter.PerPage {
+		last := []byte(res.Items[len(res.Items)-1].Name)
+		res.NextCursor = base64.RawURLEncoding.EncodeToString(last)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (ds *SQLStore) RemoveWorkflow(ctx context.Context, workflowID string) error {
+	return ds.Tx(func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM workflows WHERE id=?`), workflowID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return models.ErrWorkflowsNotFound
+		}
+
+		_, err = tx.ExecContext(ctx, tx.Rebind(`DELETE FROM workflow_runs WHERE workflow_id=?`), workflowID)
+		return err
+	})
+}
+
+func (ds *SQLStore) InsertWorkflowRun(ctx context.Context, run *models.WorkflowRun) error {
+	query := ds.db.Rebind(`INSERT INTO workflow_runs (
+		id,
+		workflow_id,
+		status,
+		step,
+		input,
+		outputs,
+		error,
+		created_at,
+		updated_at,
+		completed_at
+	)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+
+	_, err := ds.db.ExecContext(ctx, query, run.ID, run.WorkflowID, run.Status, run.Step, run.Input,
+		run.Outputs, run.Error, run.CreatedAt, run.UpdatedAt, run.CompletedAt)
+	return err
+}
+
+func (ds *SQLStore) UpdateWorkflowRun(ctx context.Context, run *models.WorkflowRun) error {
+	query := ds.db.Rebind(`UPDATE workflow_runs SET
+		status=?,
+		step=?,
+		outputs=?,
+		error=?,
+		updated_at=?,
+		completed_at=?
+	WHERE id=?;`)
+
+	res, err := ds.db.ExecContext(ctx, query, run.Status, run.Step, run.Outputs, run.Error,
+		run.UpdatedAt, run.CompletedAt, run.ID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrWorkflowRunNotFound
+	}
+	return nil
+}
+
+func (ds *SQLStore) FailLostWorkflowRuns(ctx context.Context, updatedBefore time.Time) (int, error) {
+	now := common.DateTime(time.Now())
+	query := ds.db.Rebind(`UPDATE workflow_runs SET
+		status=?,
+		error=?,
+		updated_at=?,
+		completed_at=?
+	WHERE status IN (?, ?) AND updated_at<?;`)
+
+	res, err := ds.db.ExecContext(ctx, query, models.WorkflowRunStateFailed, models.WorkflowRunLostError, now, now,
+		models.WorkflowRunStateRunning, models.WorkflowRunStateCompensating, common.DateTime(updatedBefore).String())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (ds *SQLStore) GetWorkflowRun(ctx context.Context, workflowID, runID string) (*models.WorkflowRun, error) {
+	query := ds.db.Rebind(workflowRunSelector + ` WHERE id=? AND workflow_id=?`)
+	row := ds.db.QueryRowxContext(ctx, query, runID, workflowID)
+
+	var run models.WorkflowRun
+	err := row.StructScan(&run)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrWorkflowRunNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func buildFilterWorkflowRunQuery(filter *models.WorkflowRunFilter) (string, []interface{}, error) {
+	var b bytes.Buffer
+	var args []interface{}
+
+	args = where(&b, args, "workflow_id=?", filter.WorkflowID)
+	args = where(&b, args, "status=?", filter.Status)
+
+	if filter.Cursor != "" {
+		s, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		args = where(&b, args, "id<?", string(s))
+	}
+
+	fmt.Fprintf(&b, ` ORDER BY id DESC`) // ids are time ordered
+	if filter.PerPage > 0 {
+		fmt.Fprintf(&b, ` LIMIT ?`)
+		args = append(args, filter.PerPage)
+	}
+	return b.String(), args, nil
+}
+
+func (ds *SQLStore) GetWorkflowRuns(ctx context.Context, filter *models.WorkflowRunFilter) (*models.WorkflowRunList, error) {
+	res := &models.WorkflowRunList{Items: []*models.WorkflowRun{}}
+
+	filterQuery, args, err := buildFilterWorkflowRunQuery(filter)
+	if err != nil {
+		return res, err
+	}
+
+	/* #nosec */
+	query := ds.db.Rebind(fmt.Sprintf("%s %s", workflowRunSelector, filterQuery))
+	rows, err := ds.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run models.WorkflowRun
+		err := rows.StructScan(&run)
+		if err != nil {
+			return nil, err
+		}
+		res.Items = append(res.Items, &run)
+	}
+
+	if len(res.Items) > 0 && len(res.Items) == filter.PerPage {
+		last := []byte(res.Items[len(res.Items)-1].ID)
+		res.NextCursor = base64.RawURLEncoding.EncodeToString(last)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// changesChannel is the channel the changes to the apps, fns and triggers of the db are published on, to the other
+// servers sharing it
+const changesChannel = "fn_changes"
+
+// publishedChange is the payload of the notifications of changesChannel
+type publishedChange struct {
+	Origin string `json:"origin"`
+	models.DatastoreChange
+}
+
+// changed notifies the watchers of the store of change, and publishes it to the other servers sharing the db if
+// the db notifies them, a change that could not be published only takes the cache TTL to reach them
+func (ds *SQLStore) changed(ctx context.Context, change models.DatastoreChange) {
+	ds.changes.Notify(change)
+
+	notifier, ok := ds.helper.(dbhelper.Notifier)
+	if !ok {
+		return
+	}
+	payload, err := json.Marshal(publishedChange{Origin: ds.origin, DatastoreChange: change})
+	if err == nil {
+		err = notifier.Notify(ctx, ds.db, changesChannel, string(payload))
+	}
+	if err != nil {
+		common.Logger(ctx).WithError(err).WithFields(logrus.Fields{"kind": change.Kind, "id": change.ID}).Error("error publishing datastore change")
+	}
+}
+
+// WatchChanges implements models.Datastore, watching the changes of this store, and those published by the other
+// servers sharing the db if it notifies them
+func (ds *SQLStore) WatchChanges(ctx context.Context, fn func(models.DatastoreChange)) {
+	ds.changes.Watch(ctx, fn)
+
+	notifier, ok := ds.helper.(dbhelper.Notifier)
+	if !ok {
+		return
+	}
+	ds.listen.Do(func() {
+		go listenChanges(ds.listenCtx, notifier, ds.uri, ds.origin, ds.changes.Notify)
+	})
+}
+
+// listenChanges calls fn with the changes published on changesChannel by the servers other than origin, until ctx
+// is done
+func listenChanges(ctx context.Context, notifier dbhelper.Notifier, uri, origin string, fn func(models.DatastoreChange)) {
+	notifier.Listen(ctx, uri, changesChannel, func(payload string) {
+		var change publishedChange
+		if payload != "" {
+			if err := json.Unmarshal([]byte(payload), &change); err != nil {
+				common.Logger(ctx).WithError(err).Error("invalid datastore change published")
+			}
+			if change.Origin != "" && change.Origin == origin {
+				return
+			}
+		}
+		// changes that could not be read, or were missed, invalidate everything
+		fn(change.DatastoreChange)
+	})
+}
+
+// WatchChanges implements datastore.ChangeWatcher, listening for the changes published by the servers sharing the
+// db at u without connecting a store to it
+func (sqlDsProvider) WatchChanges(ctx context.Context, u *url.URL, fn func(models.DatastoreChange)) error {
+	helper, ok := dbhelper.GetHelper(u.Scheme)
+	if !ok {
+		return fmt.Errorf("DB helper '%s' is not supported", u.Scheme)
+	}
+	notifier, ok := helper.(dbhelper.Notifier)
+	if !ok {
+		return fmt.Errorf("%s does not publish datastore changes", helper)
+	}
+	uri, err := helper.PreConnect(u)
+	if err != nil {
+		return fmt.Errorf("failed to initialise db helper %s : %s", u.Scheme, err)
+	}
+	go listenChanges(ctx, notifier, uri, "", fn)
+	return nil
+}
+
 // Close closes the database, releasing any open resources.
 func (ds *SQLStore) Close() error {
+	ds.stopListener()
 	return ds.db.Close()
 }
 
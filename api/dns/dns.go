@@ -0,0 +1,131 @@
+// Package dns publishes the HTTP triggers of fns as DNS records, for internal consumers to discover fns by stable
+// names rather than by the URLs of the load balancer.
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/sirupsen/logrus"
+)
+
+// TTL is the time to live of the records published, in seconds
+const TTL = 60
+
+// Record publishes a trigger under Name, a fully qualified domain name without its final dot. Name resolves to
+// Host, the host of the load balancer, with an A or AAAA record if Host is an IP address and a CNAME record otherwise.
+// The TXT record of TXTName(Name) maps it to URL, the URL of the trigger on the load balancer, as "url=" + URL.
+type Record struct {
+	Name string
+	Host string
+	URL  string
+}
+
+// AddressType returns the type of the record Name resolves to Host with, A, AAAA or CNAME
+func (r *Record) AddressType() string {
+	ip := net.ParseIP(r.Host)
+	switch {
+	case ip == nil:
+		return "CNAME"
+	case ip.To4() != nil:
+		return "A"
+	default:
+		return "AAAA"
+	}
+}
+
+// TXT returns the text of the TXT record of the trigger
+func (r *Record) TXT() string {
+	return "url=" + r.URL
+}
+
+// TXTName returns the name of the TXT record that maps name to the URL of its trigger
+func TXTName(name string) string {
+	return "_fn." + name
+}
+
+// Publisher publishes records with a DNS provider
+type Publisher interface {
+	// Publish creates or replaces the records of r
+	Publish(ctx context.Context, r *Record) error
+	// Unpublish removes the records of r
+	Unpublish(ctx context.Context, r *Record) error
+}
+
+// Provider is a DNS provider
+type Provider interface {
+	fmt.Stringer
+	// Supports indicates if this provider can handle a given DNS url
+	Supports(url *url.URL) bool
+	// New creates a Publisher of the records of zone from the specified url
+	New(ctx context.Context, url *url.URL, zone string) (Publisher, error)
+}
+
+var providers []Provider
+
+// Register globally registers a DNS provider
+func Register(provider Provider) {
+	logrus.Infof("Registering DNS provider '%s'", provider)
+	providers = append(providers, provider)
+}
+
+// Providers returns the names of the registered DNS providers
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		names = append(names, provider.String())
+	}
+	return names
+}
+
+// New creates a Publisher of the records of zone from the specified url
+func New(ctx context.Context, dnsURL, zone string) (Publisher, error) {
+	u, err := url.Parse(dnsURL)
+	if err != nil {
+		return nil, fmt.Errorf("bad DNS url %s: %v", dnsURL, err)
+	}
+	common.Logger(ctx).WithFields(logrus.Fields{"dns": u.Scheme, "zone": zone}).Debug("creating new DNS publisher")
+
+	for _, provider := range providers {
+		if provider.Supports(u) {
+			return provider.New(ctx, u, strings.TrimSuffix(zone, "."))
+		}
+	}
+	return nil, fmt.Errorf("no DNS provider found for url %s", u)
+}
+
+// maxLabel is the longest a DNS label may be
+const maxLabel = 63
+
+// label turns a trigger or app name into a DNS label, lower cased with underscores turned into hyphens. It returns
+// false for names that do not make a valid label.
+func label(name string) (string, bool) {
+	l := strings.ToLower(strings.Replace(name, "_", "-", -1))
+	if l == "" || len(l) > maxLabel || l[0] == '-' || l[len(l)-1] == '-' {
+		return "", false
+	}
+	for _, c := range l {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return "", false
+		}
+	}
+	return l, true
+}
+
+// TriggerName returns the name trigger of app is published under in zone, <trigger>.<app>.<zone>. It returns false
+// if either name does not make a valid DNS label.
+func TriggerName(trigger, app, zone string) (string, bool) {
+	t, ok := label(trigger)
+	if !ok {
+		return "", false
+	}
+	a, ok := label(app)
+	if !ok {
+		return "", false
+	}
+	return t + "." + a + "." + strings.TrimSuffix(zone, "."), true
+}
@@ -0,0 +1,31 @@
+package dns
+
+import "testing"
+
+func TestTriggerName(t *testing.T) {
+	for _, c := range []struct {
+		trigger, app, zone string
+		name               string
+		ok                 bool
+	}{
+		{"hello", "myapp", "fns.example.com", "hello.myapp.fns.example.com", true},
+		{"Hello_World", "my-app", "fns.example.com.", "hello-world.my-app.fns.example.com", true},
+		{"_hello", "myapp", "fns.example.com", "", false},
+		{"héllo", "myapp", "fns.example.com", "", false},
+		{"hello", "myapp" + string(make([]byte, 64)), "fns.example.com", "", false},
+	} {
+		name, ok := TriggerName(c.trigger, c.app, c.zone)
+		if name != c.name || ok != c.ok {
+			t.Errorf("expected %q, %v for %s.%s, got %q, %v", c.name, c.ok, c.trigger, c.app, name, ok)
+		}
+	}
+}
+
+func TestAddressType(t *testing.T) {
+	for host, typ := range map[string]string{"lb.example.com": "CNAME", "10.0.0.1": "A", "fd00::1": "AAAA"} {
+		r := Record{Host: host}
+		if r.AddressType() != typ {
+			t.Errorf("expected %s for %s, got %s", typ, host, r.AddressType())
+		}
+	}
+}
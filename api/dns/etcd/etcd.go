@@ -0,0 +1,125 @@
+// Package etcd publishes trigger records in etcd, for CoreDNS to serve with its etcd plugin, eg.
+// etcd://etcd:2379/skydns, or etcds:// for an etcd served over TLS. Records are put through the JSON gateway of the
+// etcd v3 API, in the SkyDNS layout the plugin reads, under the path of the url, /skydns by default.
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fnproject/fn/api/dns"
+)
+
+// requestTimeout is how long a request to etcd may take
+const requestTimeout = 10 * time.Second
+
+type provider int
+
+func (provider) String() string {
+	return "etcd"
+}
+
+func (provider) Supports(u *url.URL) bool {
+	return u.Scheme == "etcd" || u.Scheme == "etcds"
+}
+
+func (provider) New(ctx context.Context, u *url.URL, zone string) (dns.Publisher, error) {
+	scheme := "http"
+	if u.Scheme == "etcds" {
+		scheme = "https"
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("no etcd host in DNS url %s", u)
+	}
+	return New(scheme+"://"+u.Host, u.Path), nil
+}
+
+func init() {
+	dns.Register(provider(0))
+}
+
+type etcd struct {
+	endpoint string
+	prefix   string
+	client   *http.Client
+}
+
+// New returns a Publisher that puts records in the etcd at endpoint, under prefix, /skydns if it is empty
+func New(endpoint, prefix string) dns.Publisher {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		prefix = "/skydns"
+	}
+	return &etcd{endpoint: strings.TrimSuffix(endpoint, "/"), prefix: prefix, client: &http.Client{Timeout: requestTimeout}}
+}
+
+// service is a SkyDNS record, as read by the CoreDNS etcd plugin
+type service struct {
+	Host string `json:"host,omitempty"`
+	Text string `json:"text,omitempty"`
+	TTL  uint32 `json:"ttl"`
+}
+
+// key returns the SkyDNS key of name, its labels reversed, eg. /skydns/com/example/app/trigger
+func (e *etcd) key(name string) string {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return e.prefix + "/" + strings.Join(labels, "/")
+}
+
+func (e *etcd) Publish(ctx context.Context, r *dns.Record) error {
+	if err := e.put(ctx, e.key(r.Name), service{Host: r.Host, TTL: dns.TTL}); err != nil {
+		return err
+	}
+	return e.put(ctx, e.key(dns.TXTName(r.Name)), service{Text: r.TXT(), TTL: dns.TTL})
+}
+
+func (e *etcd) Unpublish(ctx context.Context, r *dns.Record) error {
+	if err := e.call(ctx, "/v3/kv/deleterange", e.key(r.Name), nil); err != nil {
+		return err
+	}
+	return e.call(ctx, "/v3/kv/deleterange", e.key(dns.TXTName(r.Name)), nil)
+}
+
+func (e *etcd) put(ctx context.Context, key string, s service) error {
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return e.call(ctx, "/v3/kv/put", key, value)
+}
+
+// call makes a request of the JSON gateway, which takes keys and values base64 encoded
+func (e *etcd) call(ctx context.Context, path, key string, value []byte) error {
+	body := map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(key))}
+	if value != nil {
+		body["value"] = base64.StdEncoding.EncodeToString(value)
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("etcd returned %s for %s", resp.Status, key)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package etcd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fnproject/fn/api/dns"
+)
+
+func TestPublish(t *testing.T) {
+	kv := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		key, _ := base64.StdEncoding.DecodeString(body["key"])
+		value, _ := base64.StdEncoding.DecodeString(body["value"])
+		switch r.URL.Path {
+		case "/v3/kv/put":
+			kv[string(key)] = string(value)
+		case "/v3/kv/deleterange":
+			delete(kv, string(key))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p := New(srv.URL, "")
+	r := &dns.Record{Name: "hello.myapp.fns.example.com", Host: "lb.example.com", URL: "https://lb.example.com/t/myapp/hello"}
+	if err := p.Publish(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"/skydns/com/example/fns/myapp/hello":     `{"host":"lb.example.com","ttl":60}`,
+		"/skydns/com/example/fns/myapp/hello/_fn": `{"text":"url=https://lb.example.com/t/myapp/hello","ttl":60}`,
+	}
+	for k, v := range expected {
+		if kv[k] != v {
+			t.Errorf("expected %s to be %s, got %q", k, v, kv[k])
+		}
+	}
+
+	if err := p.Unpublish(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	if len(kv) != 0 {
+		t.Fatalf("expected the records to be removed, got %v", kv)
+	}
+}
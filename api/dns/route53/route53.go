@@ -0,0 +1,149 @@
+// Package route53 publishes trigger records in an AWS Route 53 hosted zone, eg. route53://Z1D633PJN98FT9. Requests are
+// signed with the credentials of the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment
+// variables. The endpoint query parameter overrides the Route 53 API endpoint, https://route53.amazonaws.com.
+package route53
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fnproject/fn/api/common/sigv4"
+	"github.com/fnproject/fn/api/dns"
+)
+
+const (
+	// DefaultEndpoint is the endpoint of the Route 53 API
+	DefaultEndpoint = "https://route53.amazonaws.com"
+	// signingRegion is the region Route 53 requests are signed for, Route 53 being a global service
+	signingRegion  = "us-east-1"
+	signingService = "route53"
+	// requestTimeout is how long a request to Route 53 may take
+	requestTimeout = 30 * time.Second
+)
+
+type provider int
+
+func (provider) String() string {
+	return "route53"
+}
+
+func (provider) Supports(u *url.URL) bool {
+	return u.Scheme == "route53"
+}
+
+func (provider) New(ctx context.Context, u *url.URL, zone string) (dns.Publisher, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("no hosted zone id in DNS url %s", u)
+	}
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, errors.New("route53 needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	endpoint := u.Query().Get("endpoint")
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	return New(endpoint, u.Host, creds), nil
+}
+
+func init() {
+	dns.Register(provider(0))
+}
+
+// Credentials are the AWS credentials requests are signed with
+type Credentials = sigv4.Credentials
+
+type route53 struct {
+	endpoint     string
+	hostedZoneID string
+	creds        Credentials
+	client       *http.Client
+}
+
+// New returns a Publisher that changes the record sets of the hosted zone hostedZoneID through the Route 53 API at
+// endpoint
+func New(endpoint, hostedZoneID string, creds Credentials) dns.Publisher {
+	return &route53{
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+		hostedZoneID: hostedZoneID,
+		creds:        creds,
+		client:       &http.Client{Timeout: requestTimeout},
+	}
+}
+
+type changeRequest struct {
+	XMLName xml.Name `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ChangeResourceRecordSetsRequest"`
+	Changes []change `xml:"ChangeBatch>Changes>Change"`
+}
+
+type change struct {
+	Action string        `xml:"Action"`
+	Set    recordSetSpec `xml:"ResourceRecordSet"`
+}
+
+type recordSetSpec struct {
+	Name   string   `xml:"Name"`
+	Type   string   `xml:"Type"`
+	TTL    int      `xml:"TTL"`
+	Values []string `xml:"ResourceRecords>ResourceRecord>Value"`
+}
+
+// changes returns the changes that apply action to the record sets of r
+func changes(action string, r *dns.Record) []change {
+	return []change{
+		{action, recordSetSpec{r.Name + ".", r.AddressType(), dns.TTL, []string{r.Host}}},
+		{action, recordSetSpec{dns.TXTName(r.Name) + ".", "TXT", dns.TTL, []string{strconv.Quote(r.TXT())}}},
+	}
+}
+
+func (r *route53) Publish(ctx context.Context, rec *dns.Record) error {
+	return r.change(ctx, changes("UPSERT", rec))
+}
+
+func (r *route53) Unpublish(ctx context.Context, rec *dns.Record) error {
+	err := r.change(ctx, changes("DELETE", rec))
+	// deletes fail as a whole if any record set is missing, which is what they are after
+	if err != nil && strings.Contains(err.Error(), "but it was not found") {
+		return nil
+	}
+	return err
+}
+
+func (r *route53) change(ctx context.Context, changes []change) error {
+	body, err := xml.Marshal(changeRequest{Changes: changes})
+	if err != nil {
+		return err
+	}
+	body = append([]byte(xml.Header), body...)
+	req, err := http.NewRequest(http.MethodPost, r.endpoint+"/2013-04-01/hostedzone/"+r.hostedZoneID+"/rrset/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	sigv4.Sign(req, body, r.creds, signingRegion, signingService, time.Now())
+
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("route53 returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
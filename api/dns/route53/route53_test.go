@@ -0,0 +1,56 @@
+package route53
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/dns"
+)
+
+func TestPublish(t *testing.T) {
+	var got []changeRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2013-04-01/hostedzone/Z123/rrset/" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		var req changeRequest
+		if err := xml.Unmarshal(b, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		got = append(got, req)
+		if req.Changes[0].Action == "DELETE" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Tried to delete resource record set but it was not found"))
+		}
+	}))
+	defer srv.Close()
+
+	p := New(srv.URL, "Z123", Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	r := &dns.Record{Name: "hello.myapp.fns.example.com", Host: "10.0.0.1", URL: "http://10.0.0.1/t/myapp/hello"}
+	if err := p.Publish(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Unpublish(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 change requests, got %d", len(got))
+	}
+	expected := []recordSetSpec{
+		{"hello.myapp.fns.example.com.", "A", dns.TTL, []string{"10.0.0.1"}},
+		{"_fn.hello.myapp.fns.example.com.", "TXT", dns.TTL, []string{`"url=http://10.0.0.1/t/myapp/hello"`}},
+	}
+	for i, c := range got[0].Changes {
+		if c.Action != "UPSERT" || c.Set.Name != expected[i].Name || c.Set.Type != expected[i].Type || c.Set.Values[0] != expected[i].Values[0] {
+			t.Errorf("expected an upsert of %+v, got %+v", expected[i], c)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Package zonefile publishes trigger records in a zone file, for CoreDNS to serve with its file plugin, eg.
+// coredns-file:///etc/coredns/db.fns.example.com
+package zonefile
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/dns"
+)
+
+type provider int
+
+func (provider) String() string {
+	return "coredns-file"
+}
+
+func (provider) Supports(u *url.URL) bool {
+	return u.Scheme == "coredns-file"
+}
+
+func (provider) New(ctx context.Context, u *url.URL, zone string) (dns.Publisher, error) {
+	if u.Path == "" {
+		return nil, fmt.Errorf("no zone file in DNS url %s", u)
+	}
+	if zone == "" {
+		return nil, fmt.Errorf("a zone is needed to publish to %s", u)
+	}
+	return New(u.Path, zone)
+}
+
+func init() {
+	dns.Register(provider(0))
+}
+
+// zoneFile keeps the records of the zone in memory, and writes the whole zone file on every change. The SOA serial is
+// the time of the change, for CoreDNS to reload the file.
+type zoneFile struct {
+	path string
+	zone string
+
+	lock    sync.Mutex
+	records map[string]*dns.Record
+	serial  uint32
+}
+
+// New returns a Publisher that writes the records of zone to the zone file at path, loading the records already in it
+func New(path, zone string) (dns.Publisher, error) {
+	z := &zoneFile{path: path, zone: zone, records: make(map[string]*dns.Record)}
+	if err := z.load(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("cannot load zone file %s: %v", path, err)
+	}
+	return z, nil
+}
+
+// fqdn returns the absolute name of a relative name of the zone file
+func (z *zoneFile) fqdn(rel string) string {
+	if rel == "@" {
+		return z.zone
+	}
+	return rel + "." + z.zone
+}
+
+// relative returns the name of the zone file for name
+func (z *zoneFile) relative(name string) string {
+	return strings.TrimSuffix(name, "."+z.zone)
+}
+
+// load reads back the records of a zone file written by z
+func (z *zoneFile) load() error {
+	f, err := os.Open(z.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	txtPrefix := dns.TXTName("")
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[2] != "IN" {
+			continue
+		}
+		name, typ, data := z.fqdn(fields[0]), fields[3], strings.Join(fields[4:], " ")
+		switch typ {
+		case "A", "AAAA", "CNAME":
+			z.record(name).Host = strings.TrimSuffix(data, ".")
+		case "TXT":
+			text, err := strconv.Unquote(data)
+			if err != nil || !strings.HasPrefix(name, txtPrefix) {
+				continue
+			}
+			z.record(strings.TrimPrefix(name, txtPrefix)).URL = strings.TrimPrefix(text, "url=")
+		}
+	}
+	return scanner.Err()
+}
+
+// record returns the record of name, adding it if need be
+func (z *zoneFile) record(name string) *dns.Record {
+	r, ok := z.records[name]
+	if !ok {
+		r = &dns.Record{Name: name}
+		z.records[name] = r
+	}
+	return r
+}
+
+func (z *zoneFile) Publish(ctx context.Context, r *dns.Record) error {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	cp := *r
+	z.records[r.Name] = &cp
+	return z.write()
+}
+
+func (z *zoneFile) Unpublish(ctx context.Context, r *dns.Record) error {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	delete(z.records, r.Name)
+	return z.write()
+}
+
+// write replaces the zone file with one holding the records of z
+func (z *zoneFile) write() error {
+	serial := uint32(time.Now().Unix())
+	if serial <= z.serial {
+		serial = z.serial + 1
+	}
+	z.serial = serial
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "$ORIGIN %s.\n$TTL %d\n", z.zone, dns.TTL)
+	fmt.Fprintf(&b, "@ %d IN SOA ns.%s. hostmaster.%s. %d 3600 600 86400 %d\n", dns.TTL, z.zone, z.zone, serial, dns.TTL)
+
+	names := make([]string, 0, len(z.records))
+	for name := range z.records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r := z.records[name]
+		host := r.Host
+		if r.AddressType() == "CNAME" {
+			host += "."
+		}
+		fmt.Fprintf(&b, "%s %d IN %s %s\n", z.relative(r.Name), dns.TTL, r.AddressType(), host)
+		fmt.Fprintf(&b, "%s %d IN TXT %s\n", z.relative(dns.TXTName(r.Name)), dns.TTL, strconv.Quote(r.TXT()))
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(z.path), filepath.Base(z.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), z.path)
+}
@@ -0,0 +1,61 @@
+package zonefile
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/dns"
+)
+
+func TestZoneFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zonefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "db.fns.example.com")
+	ctx := context.Background()
+
+	p, err := New(path, "fns.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello := &dns.Record{Name: "hello.myapp.fns.example.com", Host: "lb.example.com", URL: "https://lb.example.com/t/myapp/hello"}
+	bye := &dns.Record{Name: "bye.myapp.fns.example.com", Host: "10.0.0.1", URL: "http://10.0.0.1/t/myapp/bye"}
+	for _, r := range []*dns.Record{hello, bye} {
+		if err := p.Publish(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"$ORIGIN fns.example.com.",
+		"hello.myapp 60 IN CNAME lb.example.com.",
+		`_fn.hello.myapp 60 IN TXT "url=https://lb.example.com/t/myapp/hello"`,
+		"bye.myapp 60 IN A 10.0.0.1",
+	} {
+		if !strings.Contains(string(b), line+"\n") {
+			t.Errorf("expected the zone file to hold %q, got\n%s", line, b)
+		}
+	}
+
+	// the records already in the zone file are kept once it is loaded again
+	p, err = New(path, "fns.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Unpublish(ctx, bye); err != nil {
+		t.Fatal(err)
+	}
+	z := p.(*zoneFile)
+	if len(z.records) != 1 || *z.records[hello.Name] != *hello {
+		t.Fatalf("expected only %+v to be left, got %+v", hello, z.records)
+	}
+}
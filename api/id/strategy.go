@@ -0,0 +1,203 @@
+package id
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of the resources whose ids are generated by the Strategy
+const (
+	KindApp     = "app"
+	KindFn      = "fn"
+	KindTrigger = "trigger"
+	KindCall    = "call"
+)
+
+// Strategy generates the ids of resources of a kind. Ids must be unique, and sort as strings in the order they are
+// generated in, at least to the millisecond, as resources are listed by id, newest first.
+type Strategy interface {
+	NewID(kind string) string
+}
+
+// StrategyFunc is a Strategy of a func
+type StrategyFunc func(kind string) string
+
+// NewID implements Strategy
+func (f StrategyFunc) NewID(kind string) string { return f(kind) }
+
+var (
+	// Default generates ids as New, 26 character sortable base 32 strings seeded by the machine id
+	Default Strategy = StrategyFunc(func(string) string { return New().String() })
+	// ULID generates ULIDs, 26 character sortable base 32 strings of the time and 80 random bits
+	ULID Strategy = StrategyFunc(func(string) string { return newULID(time.Now()) })
+	// UUIDv7 generates version 7 UUIDs, of the time and 74 random bits
+	UUIDv7 Strategy = StrategyFunc(func(string) string { return newUUIDv7(time.Now()) })
+)
+
+// Prefixed prefixes the ids s generates with their kind, such as fn_01AN4Z07BY79KA1307SR9X4MV3
+func Prefixed(s Strategy) Strategy {
+	return StrategyFunc(func(kind string) string { return kind + "_" + s.NewID(kind) })
+}
+
+var strategy = Default
+
+// SetStrategy sets the Strategy of NewString. Like SetMachineId, it may only be called by one thread before any id
+// generation is done.
+func SetStrategy(s Strategy) {
+	strategy = s
+}
+
+// NewString returns a new id of a resource of kind, as the Strategy set with SetStrategy generates it, Default if
+// none is set
+func NewString(kind string) string {
+	return strategy.NewID(kind)
+}
+
+// ParseStrategy returns the strategy of name, one of default, ulid or uuidv7, each optionally prefixed:, such as
+// prefixed:ulid, to prefix the ids with their kind. "" is the default strategy.
+func ParseStrategy(name string) (Strategy, error) {
+	if base := strings.TrimPrefix(name, "prefixed:"); base != name {
+		s, err := ParseStrategy(base)
+		if err != nil {
+			return nil, err
+		}
+		return Prefixed(s), nil
+	}
+	switch name {
+	case "", "default":
+		return Default, nil
+	case "prefixed":
+		return Prefixed(Default), nil
+	case "ulid":
+		return ULID, nil
+	case "uuidv7":
+		return UUIDv7, nil
+	}
+	return nil, fmt.Errorf("unknown id strategy %q, it must be default, ulid or uuidv7, optionally prefixed:", name)
+}
+
+// entropy hands out the random bits of ULIDs, incremented within a millisecond rather than drawn again so that the
+// ids of a millisecond sort in the order they were generated in
+var entropy struct {
+	sync.Mutex
+	ms   uint64
+	bits [10]byte
+}
+
+func randomBits(ms uint64) [10]byte {
+	entropy.Lock()
+	defer entropy.Unlock()
+	if ms == entropy.ms {
+		for i := len(entropy.bits) - 1; i >= 0; i-- {
+			entropy.bits[i]++
+			if entropy.bits[i] != 0 {
+				break
+			}
+		}
+		return entropy.bits
+	}
+	entropy.ms = ms
+	if _, err := rand.Read(entropy.bits[:]); err != nil {
+		panic(err)
+	}
+	// leave room to increment the bits within the millisecond
+	entropy.bits[0] &= 0x7f
+	return entropy.bits
+}
+
+func unixMillis(t time.Time) uint64 {
+	return uint64(t.Unix())*1000 + uint64(t.Nanosecond()/int(time.Millisecond))
+}
+
+func newULID(t time.Time) string {
+	ms := unixMillis(t)
+	bits := randomBits(ms)
+
+	var id Id
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+	copy(id[6:], bits[:])
+	return id.String()
+}
+
+// uuidEntropy hands out bytes 6 to 15 of version 7 UUIDs, their version and variant around the 74 random bits of
+// rand_a and rand_b. As for ULIDs, the random bits are incremented within a millisecond, as one number whose high
+// bits are rand_a, so that increments carry around the version and variant rather than into them.
+var uuidEntropy struct {
+	sync.Mutex
+	ms   uint64
+	bits [10]byte
+}
+
+func uuidv7Bits(ms uint64) [10]byte {
+	uuidEntropy.Lock()
+	defer uuidEntropy.Unlock()
+	if ms == uuidEntropy.ms {
+		incrementUUIDv7Bits(&uuidEntropy.bits)
+		return uuidEntropy.bits
+	}
+	uuidEntropy.ms = ms
+	if _, err := rand.Read(uuidEntropy.bits[:]); err != nil {
+		panic(err)
+	}
+	// version 7, leaving room to increment rand_a within the millisecond
+	uuidEntropy.bits[0] = 0x70 | uuidEntropy.bits[0]&0x07
+	// variant 10
+	uuidEntropy.bits[2] = 0x80 | uuidEntropy.bits[2]&0x3f
+	return uuidEntropy.bits
+}
+
+// incrementUUIDv7Bits adds one to the rand_a and rand_b bits of b, bytes 6 to 15 of a version 7 UUID
+func incrementUUIDv7Bits(b *[10]byte) {
+	for i := len(b) - 1; i > 2; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return
+		}
+	}
+	// the 6 bits of rand_b after the variant
+	if b[2]&0x3f != 0x3f {
+		b[2]++
+		return
+	}
+	b[2] &^= 0x3f
+	// the 12 bits of rand_a after the version
+	b[1]++
+	if b[1] == 0 {
+		b[0] = b[0]&0xf0 | (b[0]+1)&0x0f
+	}
+}
+
+func newUUIDv7(t time.Time) string {
+	ms := unixMillis(t)
+	bits := uuidv7Bits(ms)
+
+	var u [16]byte
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	u[2] = byte(ms >> 24)
+	u[3] = byte(ms >> 16)
+	u[4] = byte(ms >> 8)
+	u[5] = byte(ms)
+	copy(u[6:], bits[:])
+
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
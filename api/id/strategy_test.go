@@ -0,0 +1,86 @@
+package id
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStrategies(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for i, test := range []struct {
+		name  string
+		valid func(string) bool
+	}{
+		{"", func(id string) bool { return ValidateText([]byte(id)) }},
+		{"default", func(id string) bool { return ValidateText([]byte(id)) }},
+		{"ulid", func(id string) bool { return ValidateText([]byte(id)) }},
+		{"uuidv7", uuid.MatchString},
+		{"prefixed", func(id string) bool {
+			return strings.HasPrefix(id, "fn_") && ValidateText([]byte(strings.TrimPrefix(id, "fn_")))
+		}},
+		{"prefixed:uuidv7", func(id string) bool { return strings.HasPrefix(id, "fn_") && uuid.MatchString(id[3:]) }},
+	} {
+		s, err := ParseStrategy(test.name)
+		if err != nil {
+			t.Fatalf("Test %d: %v", i, err)
+		}
+		ids := make([]string, 1000)
+		seen := make(map[string]bool, len(ids))
+		for j := range ids {
+			ids[j] = s.NewID(KindFn)
+			if !test.valid(ids[j]) {
+				t.Fatalf("Test %d: invalid id %q", i, ids[j])
+			}
+			if seen[ids[j]] {
+				t.Fatalf("Test %d: duplicate id %q", i, ids[j])
+			}
+			seen[ids[j]] = true
+		}
+		if !sort.StringsAreSorted(ids) {
+			t.Errorf("Test %d: expected ids to sort in the order they were generated in", i)
+		}
+	}
+
+	if _, err := ParseStrategy("uuidv4"); err == nil {
+		t.Error("expected an unknown strategy to be invalid")
+	}
+}
+
+func TestUUIDv7Carry(t *testing.T) {
+	now := time.Now()
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	for i, bits := range [][10]byte{
+		// rand_b carries into its bits in the variant byte
+		{0x70, 0x00, 0x80, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe},
+		// and on past the variant into rand_a
+		{0x70, 0x00, 0xbf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe},
+		// and across the bytes of rand_a, up to the version
+		{0x70, 0xff, 0xbf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe},
+	} {
+		uuidEntropy.Lock()
+		uuidEntropy.ms, uuidEntropy.bits = unixMillis(now), bits
+		uuidEntropy.Unlock()
+
+		ids := []string{newUUIDv7(now), newUUIDv7(now), newUUIDv7(now)}
+		for _, id := range ids {
+			if !uuid.MatchString(id) {
+				t.Fatalf("Test %d: expected the version and variant to be kept, got %q", i, id)
+			}
+		}
+		if !sort.StringsAreSorted(ids) || ids[0] == ids[1] || ids[1] == ids[2] {
+			t.Errorf("Test %d: expected ids to sort in the order they were generated in, got %v", i, ids)
+		}
+	}
+}
+
+func TestSetStrategy(t *testing.T) {
+	defer SetStrategy(Default)
+	SetStrategy(Prefixed(ULID))
+	if id := NewString(KindApp); !strings.HasPrefix(id, "app_") || !ValidateText([]byte(id[4:])) {
+		t.Errorf("expected a prefixed ULID, got %q", id)
+	}
+}
@@ -0,0 +1,423 @@
+// Package jsonschema implements validation of JSON values against the commonly
+// used subset of JSON Schema (draft 7): type, enum, const, properties,
+// required, additionalProperties, items, minimum, maximum, exclusiveMinimum,
+// exclusiveMaximum, minLength, maxLength, pattern, minItems and maxItems.
+// Unknown keywords are ignored, as the spec requires.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a compiled JSON Schema
+type Schema struct {
+	// boolean schemas, true accepts anything and false accepts nothing
+	always *bool
+
+	Types                []string
+	Enum                 []interface{}
+	Const                *interface{}
+	Properties           map[string]*Schema
+	Required             []string
+	AdditionalProperties *Schema
+	Items                *Schema
+	Minimum              *float64
+	Maximum              *float64
+	ExclusiveMinimum     *float64
+	ExclusiveMaximum     *float64
+	MinLength            *int
+	MaxLength            *int
+	Pattern              *regexp.Regexp
+	MinItems             *int
+	MaxItems             *int
+}
+
+// FieldError describes a single validation failure, Path is a dotted path to
+// the offending value relative to the validated document, empty for the root.
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+func (e FieldError) String() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+var validTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "string": true, "integer": true,
+}
+
+// Parse compiles a JSON Schema document
+func Parse(b []byte) (*Schema, error) {
+	var raw interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid schema JSON: %v", err)
+	}
+	return compile(raw, "")
+}
+
+func compile(raw interface{}, path string) (*Schema, error) {
+	if b, ok := raw.(bool); ok {
+		return &Schema{always: &b}, nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, schemaError(path, "schema must be an object or boolean")
+	}
+
+	s := &Schema{}
+	var err error
+
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		s.Types = []string{t}
+	case []interface{}:
+		for _, v := range t {
+			ts, ok := v.(string)
+			if !ok {
+				return nil, schemaError(path, "type must be a string or array of strings")
+			}
+			s.Types = append(s.Types, ts)
+		}
+	default:
+		return nil, schemaError(path, "type must be a string or array of strings")
+	}
+	for _, t := range s.Types {
+		if !validTypes[t] {
+			return nil, schemaError(path, fmt.Sprintf("unknown type %q", t))
+		}
+	}
+
+	if e, ok := m["enum"]; ok {
+		s.Enum, ok = e.([]interface{})
+		if !ok {
+			return nil, schemaError(path, "enum must be an array")
+		}
+	}
+	if c, ok := m["const"]; ok {
+		s.Const = &c
+	}
+
+	if p, ok := m["properties"]; ok {
+		props, ok := p.(map[string]interface{})
+		if !ok {
+			return nil, schemaError(path, "properties must be an object")
+		}
+		s.Properties = make(map[string]*Schema, len(props))
+		for k, v := range props {
+			s.Properties[k], err = compile(v, joinPath(path, k))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if r, ok := m["required"]; ok {
+		req, ok := r.([]interface{})
+		if !ok {
+			return nil, schemaError(path, "required must be an array of strings")
+		}
+		for _, v := range req {
+			rs, ok := v.(string)
+			if !ok {
+				return nil, schemaError(path, "required must be an array of strings")
+			}
+			s.Required = append(s.Required, rs)
+		}
+	}
+	if a, ok := m["additionalProperties"]; ok {
+		s.AdditionalProperties, err = compile(a, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if i, ok := m["items"]; ok {
+		s.Items, err = compile(i, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for kw, dst := range map[string]**float64{
+		"minimum":          &s.Minimum,
+		"maximum":          &s.Maximum,
+		"exclusiveMinimum": &s.ExclusiveMinimum,
+		"exclusiveMaximum": &s.ExclusiveMaximum,
+	} {
+		if v, ok := m[kw]; ok {
+			f, ok := toFloat(v)
+			if !ok {
+				return nil, schemaError(path, kw+" must be a number")
+			}
+			*dst = &f
+		}
+	}
+	for kw, dst := range map[string]**int{
+		"minLength": &s.MinLength,
+		"maxLength": &s.MaxLength,
+		"minItems":  &s.MinItems,
+		"maxItems":  &s.MaxItems,
+	} {
+		if v, ok := m[kw]; ok {
+			f, ok := toFloat(v)
+			if !ok || f < 0 || f != float64(int(f)) {
+				return nil, schemaError(path, kw+" must be a non-negative integer")
+			}
+			n := int(f)
+			*dst = &n
+		}
+	}
+
+	if p, ok := m["pattern"]; ok {
+		ps, ok := p.(string)
+		if !ok {
+			return nil, schemaError(path, "pattern must be a string")
+		}
+		s.Pattern, err = regexp.Compile(ps)
+		if err != nil {
+			return nil, schemaError(path, fmt.Sprintf("invalid pattern: %v", err))
+		}
+	}
+
+	return s, nil
+}
+
+func schemaError(path, msg string) error {
+	if path == "" {
+		return errors.New(msg)
+	}
+	return fmt.Errorf("%s: %s", path, msg)
+}
+
+// ValidateJSON validates a raw JSON document against the schema
+func (s *Schema) ValidateJSON(b []byte) []FieldError {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return []FieldError{{Message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+	return s.Validate(v)
+}
+
+// Validate validates a decoded JSON value against the schema, numbers may be
+// either float64 or json.Number. Errors are returned in a stable order.
+func (s *Schema) Validate(v interface{}) []FieldError {
+	var errs []FieldError
+	s.validate(v, "", &errs)
+	return errs
+}
+
+func (s *Schema) validate(v interface{}, path string, errs *[]FieldError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.always != nil {
+		if !*s.always {
+			fail("no value is allowed here")
+		}
+		return
+	}
+
+	if len(s.Types) > 0 && !matchesType(v, s.Types) {
+		fail("expected %s, got %s", strings.Join(s.Types, " or "), typeOf(v))
+		return
+	}
+
+	if s.Enum != nil {
+		found := false
+		for _, e := range s.Enum {
+			if equal(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value must be one of %s", encode(s.Enum))
+		}
+	}
+	if s.Const != nil && !equal(*s.Const, v) {
+		fail("value must be %s", encode(*s.Const))
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, r := range s.Required {
+			if _, ok := t[r]; !ok {
+				*errs = append(*errs, FieldError{Path: joinPath(path, r), Message: "is required"})
+			}
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if ps, ok := s.Properties[k]; ok {
+				ps.validate(t[k], joinPath(path, k), errs)
+			} else if s.AdditionalProperties != nil {
+				if s.AdditionalProperties.always != nil && !*s.AdditionalProperties.always {
+					*errs = append(*errs, FieldError{Path: joinPath(path, k), Message: "unknown property"})
+				} else {
+					s.AdditionalProperties.validate(t[k], joinPath(path, k), errs)
+				}
+			}
+		}
+
+	case []interface{}:
+		if s.MinItems != nil && len(t) < *s.MinItems {
+			fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(t) > *s.MaxItems {
+			fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range t {
+				s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+
+	case string:
+		n := utf8.RuneCountInString(t)
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.Pattern != nil && !s.Pattern.MatchString(t) {
+			fail("must match pattern %q", s.Pattern.String())
+		}
+
+	default:
+		f, ok := toFloat(v)
+		if !ok {
+			break
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("must be <= %v", *s.Maximum)
+		}
+		if s.ExclusiveMinimum != nil && f <= *s.ExclusiveMinimum {
+			fail("must be > %v", *s.ExclusiveMinimum)
+		}
+		if s.ExclusiveMaximum != nil && f >= *s.ExclusiveMaximum {
+			fail("must be < %v", *s.ExclusiveMaximum)
+		}
+	}
+}
+
+func joinPath(path, k string) string {
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
+
+func matchesType(v interface{}, types []string) bool {
+	actual := typeOf(v)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		if f, ok := toFloat(t); ok {
+			if f == float64(int64(f)) {
+				return "integer"
+			}
+			return "number"
+		}
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// equal compares two decoded JSON values, treating numbers by value
+func equal(a, b interface{}) bool {
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if aok || bok {
+		return aok && bok && af == bf
+	}
+	switch at := a.(type) {
+	case []interface{}:
+		bt, ok := b.([]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for i := range at {
+			if !equal(at[i], bt[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bt, ok := b.(map[string]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for k, av := range at {
+			bv, ok := bt[k]
+			if !ok || !equal(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func encode(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
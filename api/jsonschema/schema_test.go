@@ -0,0 +1,63 @@
+package jsonschema
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"properties": {
+		"tier": {"type": "string", "enum": ["gold", "silver"]},
+		"owner": {"type": "string", "minLength": 1, "pattern": "^[a-z]+$"},
+		"replicas": {"type": "integer", "minimum": 1, "maximum": 10},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+	},
+	"required": ["owner"],
+	"additionalProperties": false
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, test := range []struct {
+		doc  string
+		want []FieldError
+	}{
+		{`{"owner": "ops"}`, nil},
+		{`{"owner": "ops", "tier": "gold", "replicas": 3, "tags": ["a", "b"]}`, nil},
+		{`{}`, []FieldError{{"owner", "is required"}}},
+		{`{"owner": "ops", "teir": "gold"}`, []FieldError{{"teir", "unknown property"}}},
+		{`{"owner": "ops", "tier": "bronze"}`, []FieldError{{"tier", `value must be one of ["gold","silver"]`}}},
+		{`{"owner": "Ops"}`, []FieldError{{"owner", `must match pattern "^[a-z]+$"`}}},
+		{`{"owner": "ops", "replicas": 1.5}`, []FieldError{{"replicas", "expected integer, got number"}}},
+		{`{"owner": "ops", "replicas": 11}`, []FieldError{{"replicas", "must be <= 10"}}},
+		{`{"owner": "ops", "tags": ["a", 1]}`, []FieldError{{"tags[1]", "expected string, got integer"}}},
+		{`{"owner": "ops", "tags": ["a", "b", "c"]}`, []FieldError{{"tags", "must have at most 2 items"}}},
+		{`[]`, []FieldError{{"", "expected object, got array"}}},
+	} {
+		got := s.ValidateJSON([]byte(test.doc))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Test %d: for %s expected %v, got %v", i, test.doc, test.want, got)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for i, doc := range []string{
+		`not json`,
+		`"string"`,
+		`{"type": "widget"}`,
+		`{"type": 1}`,
+		`{"properties": {"a": {"minLength": -1}}}`,
+		`{"pattern": "("}`,
+		`{"required": [1]}`,
+	} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("Test %d: expected error parsing %s", i, doc)
+		}
+	}
+}
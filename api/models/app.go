@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 	"time"
 	"unicode"
@@ -66,6 +67,9 @@ type App struct {
 	UpdatedAt   common.DateTime `json:"updated_at,omitempty" db:"updated_at"`
 }
 
+// syslogTemplateActions matches the template actions of a syslog url, e.g. `{{.AppName}}`
+var syslogTemplateActions = regexp.MustCompile(`\{\{.*?\}\}`)
+
 func (a *App) Validate() error {
 
 	if err := a.ValidateName(); err != nil {
@@ -76,8 +80,67 @@ func (a *App) Validate() error {
 		return err
 	}
 
+	if _, err := a.Annotations.Volume(); err != nil {
+		return err
+	}
+
+	if _, err := a.Annotations.Datasets(); err != nil {
+		return err
+	}
+
+	if _, err := a.Annotations.Stop(); err != nil {
+		return err
+	}
+
+	if _, err := a.Annotations.Scratch(); err != nil {
+		return err
+	}
+
+	if _, err := a.Annotations.GPUs(); err != nil {
+		return err
+	}
+
+	if _, err := a.Annotations.DockerDaemonLabels(); err != nil {
+		return err
+	}
+
+	if _, err := a.Annotations.ImagePullPolicy(); err != nil {
+		return err
+	}
+
+	if _, err := a.Annotations.Quota(); err != nil {
+		return err
+	}
+
+	if _, err := a.Annotations.Policy(); err != nil {
+		return err
+	}
+
+	if _, err := a.Annotations.RunnerPool(); err != nil {
+		return err
+	}
+
+	if _, err := a.Annotations.ResultCache(); err != nil {
+		return err
+	}
+
+	if _, err := a.Annotations.Maintenance(); err != nil {
+		return err
+	}
+
+	// the timeouts of fns are validated against their class, which apps cannot change under them
+	if _, ok := a.Annotations.Get(FnLongRunningAnnotation); ok {
+		return ErrAppLongRunning
+	}
+
+	// mirrors are to a shadow of one fn
+	if _, ok := a.Annotations.Get(FnMirrorAnnotation); ok {
+		return ErrAppMirror
+	}
+
 	if a.SyslogURL != nil && *a.SyslogURL != "" {
-		url, err := url.Parse(strings.TrimSpace(*a.SyslogURL))
+		// templates are rendered per container, check the rest of the url
+		url, err := url.Parse(syslogTemplateActions.ReplaceAllString(strings.TrimSpace(*a.SyslogURL), "template"))
 		if err == nil {
 			// See: https://docs.docker.com/config/containers/logging/syslog/#options
 			switch url.Scheme {
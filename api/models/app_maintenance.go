@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// AppMaintenanceAnnotation holds a JSON AppMaintenance object, putting an app in maintenance mode. Its triggers
+// respond with the static response of the maintenance, without their fns being invoked.
+const AppMaintenanceAnnotation = "fnproject.io/app/maintenance"
+
+var (
+	ErrAppInvalidMaintenance = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with an optional status between 200 and 599, "+
+			"body, content_type and retry_after in seconds", AppMaintenanceAnnotation),
+	}
+	ErrFnAppMaintenance = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("The %s annotation may only be set on apps", AppMaintenanceAnnotation),
+	}
+)
+
+// AppMaintenance is the static response the triggers of an app in maintenance mode respond with
+type AppMaintenance struct {
+	// Status is the status of the response, http.StatusServiceUnavailable if it is not set
+	Status int `json:"status,omitempty"`
+	// Body is the body of the response
+	Body string `json:"body,omitempty"`
+	// ContentType is the content type of the body, text/plain if it is not set
+	ContentType string `json:"content_type,omitempty"`
+	// RetryAfter sets the Retry-After header of the response, in seconds, if it is greater than 0
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// Validate checks the status and retry after of the maintenance
+func (m *AppMaintenance) Validate() error {
+	if m.Status != 0 && (m.Status < 200 || m.Status > 599) {
+		return ErrAppInvalidMaintenance
+	}
+	if m.RetryAfter < 0 {
+		return ErrAppInvalidMaintenance
+	}
+	return nil
+}
+
+// StatusCode returns the status of the response, with its default
+func (m *AppMaintenance) StatusCode() int {
+	if m.Status == 0 {
+		return http.StatusServiceUnavailable
+	}
+	return m.Status
+}
+
+// Maintenance returns the maintenance held in the AppMaintenanceAnnotation of annotations, or nil if there is none
+func (a Annotations) Maintenance() (*AppMaintenance, error) {
+	raw, ok := a.Get(AppMaintenanceAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var m AppMaintenance
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, ErrAppInvalidMaintenance
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
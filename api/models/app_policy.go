@@ -0,0 +1,98 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// AppPolicyAnnotation holds a JSON AppPolicy object, the policy the containers of all fns of an app run under. The
+// default logger of the fns of an app is its syslog_url.
+const AppPolicyAnnotation = "fnproject.io/app/policy"
+
+var (
+	ErrAppInvalidPolicy = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with optional networks, a list of docker "+
+			"network names, a registry_secret name, ulimits, an object of nofile, memlock, sigpending or msgqueue "+
+			"to limits, and seccomp and apparmor profile names", AppPolicyAnnotation),
+	}
+	ErrAppPolicyNoSecrets = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("The registry_secret of an app policy cannot be set, as this server has no secrets configured"),
+	}
+	ErrFnAppPolicy = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("The %s annotation may only be set on apps", AppPolicyAnnotation),
+	}
+)
+
+// AppPolicy is the policy the containers of all fns of an app run under
+type AppPolicy struct {
+	// Networks are the docker networks of the runner the containers may join, one of them is picked for each
+	// container. Containers of apps without networks join any network of the runner.
+	Networks []string `json:"networks,omitempty"`
+	// RegistrySecret is the name of the secret holding the credentials to pull the images of the fns, a JSON
+	// object with a username and password, or an identitytoken
+	RegistrySecret string `json:"registry_secret,omitempty"`
+	// ULimits are the default ulimits of the containers, by the names nofile, memlock, sigpending and msgqueue.
+	// They are capped at the limits of the runner.
+	ULimits map[string]uint64 `json:"ulimits,omitempty"`
+	// Seccomp is the name of the seccomp profile of the runner the containers run with instead of its default
+	Seccomp string `json:"seccomp,omitempty"`
+	// AppArmor is the name of the AppArmor profile of the runner the containers run with instead of its default
+	AppArmor string `json:"apparmor,omitempty"`
+}
+
+// ULimitNames are the ulimits an AppPolicy may set
+var ULimitNames = []string{"nofile", "memlock", "sigpending", "msgqueue"}
+
+// securityProfileName matches the names of seccomp and AppArmor profiles
+var securityProfileName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Validate checks the networks, ulimit names and security profile names of the policy
+func (p *AppPolicy) Validate() error {
+	for _, n := range p.Networks {
+		if n == "" {
+			return ErrAppInvalidPolicy
+		}
+	}
+	for name := range p.ULimits {
+		if !validULimitName(name) {
+			return ErrAppInvalidPolicy
+		}
+	}
+	for _, profile := range []string{p.Seccomp, p.AppArmor} {
+		if profile != "" && !securityProfileName.MatchString(profile) {
+			return ErrAppInvalidPolicy
+		}
+	}
+	return nil
+}
+
+func validULimitName(name string) bool {
+	for _, n := range ULimitNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy returns the policy held in the AppPolicyAnnotation of annotations, or nil if there is none
+func (a Annotations) Policy() (*AppPolicy, error) {
+	raw, ok := a.Get(AppPolicyAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var p AppPolicy
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, ErrAppInvalidPolicy
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// AppQuotaAnnotation holds a JSON AppQuota object, the usage an app is allotted over a rolling period
+	AppQuotaAnnotation = "fnproject.io/app/quota"
+	// MaxAppQuotaPeriod is the longest period of a quota, servers keep the usage metered over it in memory
+	MaxAppQuotaPeriod = 24 * time.Hour
+)
+
+var ErrAppInvalidQuota = err{
+	code: http.StatusBadRequest,
+	error: fmt.Errorf("Invalid %s annotation, it must be an object with a period such as \"24h\", of at most %s, "+
+		"and at least one of invocations, gb_seconds or cpu_seconds greater than 0", AppQuotaAnnotation, MaxAppQuotaPeriod),
+}
+
+// AppQuota is the usage an app is allotted over a rolling period, see Usage. Consumption of the quota is reported
+// by the usage API for platform owners to act on.
+type AppQuota struct {
+	// Period is the rolling period the quota applies to, as a duration such as "24h"
+	Period      string  `json:"period"`
+	Invocations uint64  `json:"invocations,omitempty"`
+	GBSeconds   float64 `json:"gb_seconds,omitempty"`
+	CPUSeconds  float64 `json:"cpu_seconds,omitempty"`
+}
+
+// PeriodDuration returns the period of the quota, which must be valid
+func (q *AppQuota) PeriodDuration() time.Duration {
+	d, _ := time.ParseDuration(q.Period)
+	return d
+}
+
+// Validate checks the period and limits of the quota
+func (q *AppQuota) Validate() error {
+	if d, err := time.ParseDuration(q.Period); err != nil || d <= 0 || d > MaxAppQuotaPeriod {
+		return ErrAppInvalidQuota
+	}
+	if q.Invocations == 0 && q.GBSeconds <= 0 && q.CPUSeconds <= 0 {
+		return ErrAppInvalidQuota
+	}
+	if q.GBSeconds < 0 || q.CPUSeconds < 0 {
+		return ErrAppInvalidQuota
+	}
+	return nil
+}
+
+// Quota returns the quota held in the AppQuotaAnnotation of annotations, or nil if there is none
+func (a Annotations) Quota() (*AppQuota, error) {
+	raw, ok := a.Get(AppQuotaAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var q AppQuota
+	if err := json.Unmarshal(raw, &q); err != nil {
+		return nil, ErrAppInvalidQuota
+	}
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
@@ -81,6 +81,14 @@ func novelValue(t *testing.T, originalInstance reflect.Value, fieldName string,
 			if !newValue.(Config).Equals(currentValue.(Config)) {
 				break
 			}
+		} else if fieldName == "Chain" {
+			if !newValue.(*FnChain).Equals(currentValue.(*FnChain)) {
+				break
+			}
+		} else if fieldName == "Disabled" {
+			if *newValue.(*bool) != *currentValue.(*bool) {
+				break
+			}
 		} else {
 			if newValue != currentValue {
 				break
@@ -179,13 +187,32 @@ func TestValidateAppName(t *testing.T) {
 func TestValidateApp(t *testing.T) {
 	valid_name := "valid_name"
 	valid_syslog := "tcp://localhost:13371"
+	templated_syslog := "tcp://{{.AppName}}.logs:13371"
 
 	testCases := []struct {
 		App  App
 		Want error
 	}{
 		{App{Name: valid_name, SyslogURL: &valid_syslog}, nil},
+		{App{Name: valid_name, SyslogURL: &templated_syslog}, nil},
 		{App{Name: ""}, ErrMissingName},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppQuotaAnnotation, `{"period":"24h","invocations":1000}`)}, nil},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppQuotaAnnotation, `{"period":"24h"}`)}, ErrAppInvalidQuota},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppQuotaAnnotation, `{"period":"daily","cpu_seconds":60}`)}, ErrAppInvalidQuota},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppQuotaAnnotation, `{"period":"168h","cpu_seconds":60}`)}, ErrAppInvalidQuota},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppPolicyAnnotation, `{"networks":["tenant-a"],"registry_secret":"pull","ulimits":{"nofile":1024}}`)}, nil},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppPolicyAnnotation, `{"ulimits":{"stack":1024}}`)}, ErrAppInvalidPolicy},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppPolicyAnnotation, `{"networks":[""]}`)}, ErrAppInvalidPolicy},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppPolicyAnnotation, `{"seccomp":"allow-io_uring","apparmor":"fn-strict"}`)}, nil},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppPolicyAnnotation, `{"seccomp":"../unconfined"}`)}, ErrAppInvalidPolicy},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppRunnerPoolAnnotation, `"regulated-1"`)}, nil},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppRunnerPoolAnnotation, `"bad pool"`)}, ErrAppInvalidRunnerPool},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppRunnerPoolAnnotation, `{"pool":"acme"}`)}, ErrAppInvalidRunnerPool},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(FnLongRunningAnnotation, `{}`)}, ErrAppLongRunning},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(FnMirrorAnnotation, `{"fn_id":"shadow","percent":10}`)}, ErrAppMirror},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppMaintenanceAnnotation, `{"status":503,"body":"back soon","retry_after":600}`)}, nil},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppMaintenanceAnnotation, `{"status":99}`)}, ErrAppInvalidMaintenance},
+		{App{Name: valid_name, Annotations: Annotations{}.withRawKey(AppMaintenanceAnnotation, `"on"`)}, ErrAppInvalidMaintenance},
 	}
 
 	for _, testCase := range testCases {
@@ -24,41 +24,9 @@ type Call struct {
 	// Unique identifier representing a specific call.
 	ID string `json:"id" db:"id"`
 
-	// NOTE: this is stale, retries are not implemented atm, but this is nice, so leaving
-	//  States and valid transitions.
-	//
-	//                  +---------+
-	//        +---------> delayed <----------------+
-	//                  +----+----+                |
-	//                       |                     |
-	//                       |                     |
-	//                  +----v----+                |
-	//        +---------> queued  <----------------+
-	//                  +----+----+                *
-	//                       |                     *
-	//                       |               retry * creates new call
-	//                  +----v----+                *
-	//                  | running |                *
-	//                  +--+-+-+--+                |
-	//           +---------|-|-|-----+-------------+
-	//       +---|---------+ | +-----|---------+   |
-	//       |   |           |       |         |   |
-	// +-----v---^-+      +--v-------^+     +--v---^-+
-	// | success   |      | cancelled |     |  error |
-	// +-----------+      +-----------+     +--------+
-	//
-	// * delayed - has a delay.
-	// * queued - Ready to be consumed when it's turn comes.
-	// * running - Currently consumed by a runner which will attempt to process it.
-	// * success - (or complete? success/error is common javascript terminology)
-	// * error - Something went wrong. In this case more information can be obtained
-	//   by inspecting the "reason" field.
-	//   - timeout
-	//   - killed - forcibly killed by worker due to resource restrictions or access
-	//     violations.
-	//   - bad_exit - exited with non-zero status due to program termination/crash.
-	// * cancelled - cancelled via API. More information in the reason field.
-	//   - client_request - Request was cancelled by a client.
+	// Status of the call. The agent moves calls to "running" and then to one of
+	// "success", "error" or "timeout". Detached calls persisted in the datastore
+	// follow the CallState state machine instead, see call_state.go.
 	Status string `json:"status" db:"status"`
 
 	// Name of Docker image to use.
@@ -83,7 +51,7 @@ type Call struct {
 	Method string `json:"method,omitempty" db:"-"`
 
 	// Maximum runtime in seconds.
-	Timeout int32 `json:"timeout,omitempty" db:"-"`
+	Timeout int32 `json:"timeout,omitempty" db:"timeout"`
 
 	// Hot function idle timeout in seconds before termination.
 	IdleTimeout int32 `json:"idle_timeout,omitempty" db:"-"`
@@ -130,6 +98,10 @@ type Call struct {
 	// status is equal to "error".
 	Error string `json:"error,omitempty" db:"error"`
 
+	// ErrorDetails is the tail of the output of the container, if it died
+	// unexpectedly while running the call.
+	ErrorDetails string `json:"error_details,omitempty" db:"error_details"`
+
 	// App this call belongs to.
 	AppID string `json:"app_id" db:"app_id"`
 
@@ -141,10 +113,39 @@ type Call struct {
 
 	// Fn this call belongs to.
 	FnID string `json:"fn_id" db:"fn_id"`
+
+	// ContentType is the content type of the input of a persisted call. Other
+	// request headers are not recorded, as they may carry credentials.
+	ContentType string `json:"content_type,omitempty" db:"content_type"`
+
+	// Input is the recorded input of a persisted call, if it was no larger
+	// than MaxCallInput.
+	Input CallInput `json:"-" db:"input"`
+
+	// Replayable is whether the input of a persisted call was recorded, so
+	// that it can be replayed.
+	Replayable bool `json:"replayable,omitempty" db:"replayable"`
+
+	// ReplayOf is the call this call replayed, if it is a replay.
+	ReplayOf string `json:"replay_of,omitempty" db:"replay_of"`
+
+	// DeploymentID is the fn deployment whose image a replay ran against, if
+	// it was not the image of the fn at the time.
+	DeploymentID string `json:"deployment_id,omitempty" db:"deployment_id"`
+
+	// InputKey is the id and wrapped data key of the key the recorded input
+	// is encrypted with, if it is.
+	InputKey string `json:"-" db:"input_key"`
+
+	// InputExpiresAt is when the recorded input is removed, if it is before
+	// the call record is.
+	InputExpiresAt common.DateTime `json:"input_expires_at,omitempty" db:"input_expires_at"`
 }
 
 type CallFilter struct {
 	FnID     string //match
+	Status   string //match
+	ReplayOf string //match
 	FromTime common.DateTime
 	ToTime   common.DateTime
 	Cursor   string
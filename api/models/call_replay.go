@@ -0,0 +1,52 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// MaxCallInput is the largest input recorded with a detached call, calls with
+// larger inputs cannot be replayed.
+const MaxCallInput = 32 * 1024
+
+var (
+	ErrCallNotReplayable = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("Call cannot be replayed, its input was not recorded or was larger than %d bytes", MaxCallInput),
+	}
+	ErrCallReplayUnsupported = err{
+		code:  http.StatusNotImplemented,
+		error: errors.New("Replaying calls is not supported on this server"),
+	}
+	ErrCallInputNotFound = err{
+		code:  http.StatusNotFound,
+		error: errors.New("Call input was not recorded or has expired"),
+	}
+	ErrCallInputUnavailable = err{
+		code:  http.StatusServiceUnavailable,
+		error: errors.New("Call input could not be decrypted"),
+	}
+)
+
+// CallReplay is a request to run a call again with its recorded input
+type CallReplay struct {
+	// DeploymentID is the deployment of the fn whose image the call is
+	// replayed against, the current image of the fn if it is empty.
+	DeploymentID string `json:"deployment_id,omitempty"`
+}
+
+// CallInput is the recorded input of a call, stored base64 encoded like
+// trigger run payloads
+type CallInput []byte
+
+// implements sql.Valuer, returning a string
+func (i CallInput) Value() (driver.Value, error) {
+	return TriggerRunPayload(i).Value()
+}
+
+// implements sql.Scanner
+func (i *CallInput) Scan(value interface{}) error {
+	return (*TriggerRunPayload)(i).Scan(value)
+}
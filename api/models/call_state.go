@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+)
+
+// States of a persisted async (detached) call. A call is inserted as queued,
+// moves to running when the agent starts it and finishes in exactly one of
+// the terminal states:
+//
+//	queued ----> running ----> succeeded
+//	  |            |
+//	  |            +---------> cached
+//	  |            |
+//	  +------------+---------> failed
+//	  |            |
+//	  +------------+---------> cancelled
+//	  |            |
+//	  +------------+---------> expired
+//
+// * succeeded - the function returned a response.
+// * cached - the function was not run, an identical call succeeded moments ago, see FnResultCacheAnnotation.
+// * failed - the function or the platform returned an error, see Call.Error.
+// * cancelled - the call was cancelled before completing, eg. agent shutdown.
+// * expired - the call did not complete before its deadline, eg. its server died.
+const (
+	CallStateQueued    = "queued"
+	CallStateRunning   = "running"
+	CallStateSucceeded = "succeeded"
+	CallStateCached    = "cached"
+	CallStateFailed    = "failed"
+	CallStateCancelled = "cancelled"
+	CallStateExpired   = "expired"
+)
+
+var callTransitions = map[string][]string{
+	CallStateQueued:  {CallStateRunning, CallStateFailed, CallStateCancelled, CallStateExpired},
+	CallStateRunning: {CallStateSucceeded, CallStateCached, CallStateFailed, CallStateCancelled, CallStateExpired},
+}
+
+var (
+	// ErrCallInvalidTransition is returned when a call state change is not allowed from its current state
+	ErrCallInvalidTransition = err{
+		code:  http.StatusConflict,
+		error: errors.New("Call state transition is not allowed from the current call state"),
+	}
+	// ErrCallExists is returned when inserting a call whose ID is already taken
+	ErrCallExists = err{
+		code:  http.StatusConflict,
+		error: errors.New("Call with specified ID already exists"),
+	}
+	// ErrCallInvalidState is returned when filtering on an unknown call state
+	ErrCallInvalidState = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Invalid call state"),
+	}
+)
+
+// ValidCallTransition returns whether a call may move from state from to state to
+func ValidCallTransition(from, to string) bool {
+	for _, s := range callTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminalCallState returns whether no further transitions are possible from state
+func IsTerminalCallState(state string) bool {
+	return ValidCallState(state) && len(callTransitions[state]) == 0
+}
+
+// ValidCallState returns whether state is one of the call states
+func ValidCallState(state string) bool {
+	switch state {
+	case CallStateQueued, CallStateRunning, CallStateSucceeded, CallStateCached, CallStateFailed, CallStateCancelled, CallStateExpired:
+		return true
+	}
+	return false
+}
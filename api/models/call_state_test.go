@@ -0,0 +1,38 @@
+package models
+
+import "testing"
+
+func TestCallTransitions(t *testing.T) {
+	for i, test := range []struct {
+		from, to string
+		valid    bool
+	}{
+		{CallStateQueued, CallStateRunning, true},
+		{CallStateQueued, CallStateFailed, true},
+		{CallStateQueued, CallStateExpired, true},
+		{CallStateQueued, CallStateSucceeded, false},
+		{CallStateRunning, CallStateSucceeded, true},
+		{CallStateRunning, CallStateCached, true},
+		{CallStateQueued, CallStateCached, false},
+		{CallStateRunning, CallStateCancelled, true},
+		{CallStateRunning, CallStateQueued, false},
+		{CallStateSucceeded, CallStateFailed, false},
+		{CallStateExpired, CallStateSucceeded, false},
+		{"bogus", CallStateRunning, false},
+	} {
+		if ValidCallTransition(test.from, test.to) != test.valid {
+			t.Errorf("Test %d: expected transition %s -> %s valid to be %v", i, test.from, test.to, test.valid)
+		}
+	}
+
+	for _, s := range []string{CallStateSucceeded, CallStateCached, CallStateFailed, CallStateCancelled, CallStateExpired} {
+		if !IsTerminalCallState(s) {
+			t.Errorf("expected %s to be terminal", s)
+		}
+	}
+	for _, s := range []string{CallStateQueued, CallStateRunning, "bogus"} {
+		if IsTerminalCallState(s) {
+			t.Errorf("expected %s to not be terminal", s)
+		}
+	}
+}
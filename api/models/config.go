@@ -5,8 +5,10 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 type Config map[string]string
@@ -153,7 +155,16 @@ func (c *MilliCPUs) UnmarshalJSON(data []byte) error {
 	}
 
 	if !bytes.HasSuffix(outer, []byte("\"")) || !bytes.HasPrefix(outer, []byte("\"")) {
-		return ErrInvalidJSON
+		// Support bare numbers as CPU units, eg. 1.5
+		fCPU, err := strconv.ParseFloat(string(outer), 64)
+		if err != nil {
+			return ErrInvalidJSON
+		}
+		if fCPU < MinMilliCPUs/1000 || fCPU > MaxMilliCPUs/1000 {
+			return ErrInvalidCPUs
+		}
+		*c = MilliCPUs(fCPU * 1000)
+		return nil
 	}
 
 	outer = bytes.TrimPrefix(outer, []byte("\""))
@@ -195,3 +206,96 @@ func (c *MilliCPUs) MarshalJSON() ([]byte, error) {
 	// always use milli cpus "1000m" format
 	return []byte(fmt.Sprintf("\"%s\"", c.String())), nil
 }
+
+// Megabytes is a size in MB (2^20 bytes) units, used for memory and tmpfs sizes.
+type Megabytes uint64
+
+// size suffixes accepted by ParseMegabytes, binary units follow the
+// kubernetes convention (Ki, Mi, Gi, Ti), decimal units are powers of 1000.
+var sizeSuffixes = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"Ki", 1 << 10},
+	{"Mi", 1 << 20},
+	{"Gi", 1 << 30},
+	{"Ti", 1 << 40},
+	{"k", 1e3},
+	{"K", 1e3},
+	{"M", 1e6},
+	{"G", 1e9},
+	{"T", 1e12},
+}
+
+// ParseMegabytes parses a size either as a plain number of megabytes, eg. "128",
+// or as a number with a unit suffix, eg. "512Mi", "1.5Gi" or "2G". Sizes which
+// are not a whole number of megabytes are rounded up to the next megabyte.
+func ParseMegabytes(s string) (Megabytes, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	for _, u := range sizeSuffixes {
+		if !strings.HasSuffix(s, u.suffix) {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), 64)
+		if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+			return 0, ErrInvalidSize
+		}
+		mb := math.Ceil(f * u.bytes / (1 << 20))
+		if mb >= math.MaxUint64 {
+			return 0, ErrInvalidSize
+		}
+		return Megabytes(mb), nil
+	}
+
+	mb, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidSize
+	}
+	return Megabytes(mb), nil
+}
+
+// implements fmt.Stringer
+func (m Megabytes) String() string {
+	return fmt.Sprintf("%dMi", uint64(m))
+}
+
+// implements json.Unmarshaler, accepting either a number of megabytes or a string
+// with a unit suffix, see ParseMegabytes.
+func (m *Megabytes) UnmarshalJSON(data []byte) error {
+	outer := bytes.TrimSpace(data)
+
+	if bytes.Equal(outer, []byte("null")) {
+		*m = 0
+		return nil
+	}
+
+	if bytes.HasPrefix(outer, []byte("\"")) {
+		var s string
+		if err := json.Unmarshal(outer, &s); err != nil {
+			return ErrInvalidJSON
+		}
+		mb, err := ParseMegabytes(s)
+		if err != nil {
+			return err
+		}
+		*m = mb
+		return nil
+	}
+
+	mb, err := strconv.ParseUint(string(outer), 10, 64)
+	if err != nil {
+		return ErrInvalidSize
+	}
+	*m = Megabytes(mb)
+	return nil
+}
+
+// implements json.Marshaler, sizes are always written as a number of megabytes
+// so that existing clients reading integer sizes keep working.
+func (m Megabytes) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatUint(uint64(m), 10)), nil
+}
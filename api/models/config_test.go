@@ -109,4 +109,61 @@ func TestMilliCPUsUnmarshal(t *testing.T) {
 	if err == nil {
 		t.Fatal("failed, should get error got: ", tmp)
 	}
+
+	err = checkStr("1.5", 1500)
+	if err != nil {
+		t.Fatal("failed: ", err)
+	}
+
+	tmp, err = checkErr("-1")
+	if err == nil {
+		t.Fatal("failed, should get error got: ", tmp)
+	}
+}
+
+func TestMegabytesUnmarshal(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected Megabytes
+	}{
+		{`128`, 128},
+		{`"128"`, 128},
+		{`"512Mi"`, 512},
+		{`"2Gi"`, 2048},
+		{`"1.5Gi"`, 1536},
+		{`"1024Ki"`, 1},
+		{`"1Ki"`, 1}, // rounds up
+		{`"2G"`, 1908},
+		{`"500M"`, 477},
+		{`""`, 0},
+		{`null`, 0},
+	} {
+		var res Megabytes
+		err := json.Unmarshal([]byte(tc.input), &res)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", tc.input, err)
+		}
+		if res != tc.expected {
+			t.Fatalf("mismatch parsing %s, %d != %d", tc.input, res, tc.expected)
+		}
+
+		// round trip
+		out, err := json.Marshal(res)
+		if err != nil {
+			t.Fatal("failed: ", err)
+		}
+		var res2 Megabytes
+		err = json.Unmarshal(out, &res2)
+		if err != nil || res2 != res {
+			t.Fatalf("round trip mismatch %s -> %s -> %d", tc.input, out, res2)
+		}
+	}
+
+	for _, input := range []string{`"-1Mi"`, `"12Xi"`, `"Mi"`, `-5`, `1.5`, `"abc"`, `true`} {
+		var res Megabytes
+		err := json.Unmarshal([]byte(input), &res)
+		if err == nil {
+			t.Fatalf("expected error parsing %s, got %d", input, res)
+		}
+	}
 }
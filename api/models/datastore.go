@@ -3,6 +3,7 @@ package models
 import (
 	"context"
 	"io"
+	"time"
 )
 
 type Datastore interface {
@@ -73,6 +74,134 @@ type Datastore interface {
 	// GetTriggerBySource loads a trigger by type and source ID - this is only needed when the data store is also used for agent read access
 	GetTriggerBySource(ctx context.Context, appId string, triggerType, source string) (*Trigger, error)
 
+	// InsertCall persists an async call, which must be in the CallStateQueued state.
+	// Returns ErrDatastoreEmptyCallID if call.ID is empty.
+	InsertCall(ctx context.Context, call *Call) error
+
+	// GetCall returns the persisted call callID belonging to fn fnID.
+	// Returns ErrCallNotFound if no call is found.
+	GetCall(ctx context.Context, fnID, callID string) (*Call, error)
+
+	// GetCalls returns a list of persisted calls for filter.FnID, most recent first, and a cursor.
+	// The recorded inputs of the calls are left out, GetCall returns them.
+	// Returns ErrDatastoreEmptyFnID if no FnID is set in the filter.
+	GetCalls(ctx context.Context, filter *CallFilter) (*CallList, error)
+
+	// UpdateCallState moves a persisted call from state `from` to call.Status, recording its
+	// started_at, completed_at and error. Returns ErrCallInvalidTransition if the transition is not
+	// allowed or the call is no longer in state `from`, and ErrCallNotFound if no call is found.
+	UpdateCallState(ctx context.Context, call *Call, from string) error
+
+	// RemoveCallInput removes the recorded input of the persisted call callID, so that it can no
+	// longer be replayed. Returns ErrCallNotFound if no call is found.
+	RemoveCallInput(ctx context.Context, callID string) error
+
+	// RemoveExpiredCallInputs removes the recorded inputs of the persisted calls that expired
+	// before now, returning how many were removed.
+	RemoveExpiredCallInputs(ctx context.Context, now time.Time) (int, error)
+
+	// AppendCallLog records part seq of the log of call callID belonging to fn fnID, replacing any
+	// part already recorded under seq.
+	AppendCallLog(ctx context.Context, fnID, callID string, seq int, data []byte) error
+
+	// GetCallLog returns the log of call callID belonging to fn fnID, its parts joined in order.
+	// Returns ErrCallLogNotFound if no part of the log is recorded.
+	GetCallLog(ctx context.Context, fnID, callID string) ([]byte, error)
+
+	// RemoveExpiredCallLogs removes the parts of call logs recorded before before, returning how
+	// many were removed.
+	RemoveExpiredCallLogs(ctx context.Context, before time.Time) (int, error)
+
+	// InsertWorkflow inserts a new workflow, applying any defaults necessary.
+	// Returns ErrAppsNotFound if its app does not exist, and ErrWorkflowsExists
+	// if the app already has a workflow by the same name.
+	InsertWorkflow(ctx context.Context, workflow *Workflow) (*Workflow, error)
+
+	// GetWorkflowByID returns a workflow by ID. Returns ErrDatastoreEmptyWorkflowID if workflowID is empty.
+	// Returns ErrWorkflowsNotFound if no workflow is found.
+	GetWorkflowByID(ctx context.Context, workflowID string) (*Workflow, error)
+
+	// GetWorkflows returns a list of workflows ordered by name, and a cursor, applying any filters provided.
+	GetWorkflows(ctx context.Context, filter *WorkflowFilter) (*WorkflowList, error)
+
+	// RemoveWorkflow removes a workflow and its runs. Returns ErrDatastoreEmptyWorkflowID if workflowID is empty.
+	// Returns ErrWorkflowsNotFound if no workflow is found.
+	RemoveWorkflow(ctx context.Context, workflowID string) error
+
+	// InsertWorkflowRun persists a new run of a workflow.
+	// Returns ErrDatastoreEmptyWorkflowRunID if run.ID is empty.
+	InsertWorkflowRun(ctx context.Context, run *WorkflowRun) error
+
+	// UpdateWorkflowRun records the progress of a run: its status, step, outputs and error.
+	// Returns ErrWorkflowRunNotFound if no run is found.
+	UpdateWorkflowRun(ctx context.Context, run *WorkflowRun) error
+
+	// GetWorkflowRun returns the run runID of workflow workflowID.
+	// Returns ErrWorkflowRunNotFound if no run is found.
+	GetWorkflowRun(ctx context.Context, workflowID, runID string) (*WorkflowRun, error)
+
+	// GetWorkflowRuns returns a list of runs of filter.WorkflowID, most recent first, and a cursor.
+	// Returns ErrDatastoreEmptyWorkflowID if no WorkflowID is set in the filter.
+	GetWorkflowRuns(ctx context.Context, filter *WorkflowRunFilter) (*WorkflowRunList, error)
+
+	// FailLostWorkflowRuns fails the runs still running or compensating that were last updated before
+	// updatedBefore, as the server running them stopped, with WorkflowRunLostError. It returns how many were failed.
+	FailLostWorkflowRuns(ctx context.Context, updatedBefore time.Time) (int, error)
+
+	// InsertTriggerRun records a trigger firing.
+	// Returns ErrDatastoreEmptyTriggerRunID if run.ID is empty.
+	InsertTriggerRun(ctx context.Context, run *TriggerRun) error
+
+	// UpdateTriggerRun records the end of a trigger run: its status, error and completed_at.
+	// Returns ErrTriggerRunNotFound if no run is found.
+	UpdateTriggerRun(ctx context.Context, run *TriggerRun) error
+
+	// GetTriggerRun returns the run runID of trigger triggerID.
+	// Returns ErrTriggerRunNotFound if no run is found.
+	GetTriggerRun(ctx context.Context, triggerID, runID string) (*TriggerRun, error)
+
+	// GetTriggerRuns returns a list of runs of filter.TriggerID, most recent first, and a cursor. The payloads of
+	// the runs are left out, GetTriggerRun returns them.
+	// Returns ErrMissingID if no TriggerID is set in the filter.
+	GetTriggerRuns(ctx context.Context, filter *TriggerRunFilter) (*TriggerRunList, error)
+
+	// RemoveExpiredTriggerRuns removes the trigger runs that expired before now, returning how many were removed.
+	// Runs recorded without an expiry are removed once they are older than DefaultTriggerRunTTL.
+	RemoveExpiredTriggerRuns(ctx context.Context, now time.Time) (int, error)
+
+	// GetImageScan returns the last scan of the image with digest.
+	// Returns ErrImageScanNotFound if the image has not been scanned.
+	GetImageScan(ctx context.Context, digest string) (*ImageScan, error)
+
+	// PutImageScan records the scan of an image, replacing any earlier scan of its digest.
+	// Returns ErrDatastoreEmptyImageDigest if scan.Digest is empty.
+	PutImageScan(ctx context.Context, scan *ImageScan) error
+
+	// InsertFnDeployment records the provenance of an image deployed to a fn.
+	// Returns ErrDatastoreEmptyFnDeploymentID if deployment.ID is empty.
+	InsertFnDeployment(ctx context.Context, deployment *FnDeployment) error
+
+	// GetFnDeployment returns the deployment deploymentID of fn fnID.
+	// Returns ErrFnDeploymentNotFound if no deployment is found.
+	GetFnDeployment(ctx context.Context, fnID, deploymentID string) (*FnDeployment, error)
+
+	// GetFnDeployments returns a list of deployments of filter.FnID, most recent first, and a cursor.
+	// Returns ErrDatastoreEmptyFnID if no FnID is set in the filter.
+	GetFnDeployments(ctx context.Context, filter *FnDeploymentFilter) (*FnDeploymentList, error)
+
+	// AcquireLease takes lease.Name for lease.Holder until lease.ExpiresAt, if
+	// it is not held or has expired, or renews it if lease.Holder holds it.
+	// Returns ErrLeaseHeld if another holder holds the lease.
+	AcquireLease(ctx context.Context, lease *Lease) error
+
+	// ReleaseLease gives up lease name, if holder holds it, so that another
+	// holder may acquire it without waiting for it to expire.
+	ReleaseLease(ctx context.Context, name, holder string) error
+
+	// WatchChanges calls fn with each change to the apps, fns and triggers of the datastore until ctx is done, the
+	// changes of other servers sharing its database as well, if the database notifies them.
+	WatchChanges(ctx context.Context, fn func(DatastoreChange))
+
 	// implements io.Closer to shutdown
 	io.Closer
 }
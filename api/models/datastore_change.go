@@ -0,0 +1,18 @@
+package models
+
+// Kinds of the resources of DatastoreChanges
+const (
+	ChangeKindApp     = "app"
+	ChangeKindFn      = "fn"
+	ChangeKindTrigger = "trigger"
+)
+
+// DatastoreChange is the creation, update or removal of an app, fn or trigger of a datastore. Servers caching them
+// drop what a change invalidates, rather than wait for it to expire. A change without a kind invalidates everything,
+// it is sent when changes may have been missed, such as when the connection to the database was lost.
+type DatastoreChange struct {
+	// Kind is one of ChangeKindApp, ChangeKindFn or ChangeKindTrigger
+	Kind string `json:"kind,omitempty"`
+	// ID is the id of the app, fn or trigger changed
+	ID string `json:"id,omitempty"`
+}
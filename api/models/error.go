@@ -13,6 +13,10 @@ const (
 	MaxLengthFnName = 255
 	// MaxLengthTriggerName is the max length for a trigger name
 	MaxLengthTriggerName = 255
+	// MaxWarmupCount is the max number of containers a single warmup may start
+	MaxWarmupCount = 100
+	// MaxInvokeBatchItems is the max number of payloads a single batch invoke may carry
+	MaxInvokeBatchItems = 1000
 )
 
 var (
@@ -37,6 +41,9 @@ var (
 	ErrUnsupportedMediaType = err{
 		code:  http.StatusUnsupportedMediaType,
 		error: errors.New("Content Type not supported")}
+	ErrPatchTestFailed = err{
+		code:  http.StatusConflict,
+		error: errors.New("Patch test operation failed, the object has changed")}
 
 	ErrMissingID = err{
 		code:  http.StatusBadRequest,
@@ -79,6 +86,18 @@ var (
 		code:  http.StatusBadRequest,
 		error: errors.New("Invalid payload"),
 	}
+	ErrPayloadStoreUnavailable = err{
+		code:  http.StatusServiceUnavailable,
+		error: errors.New("Call payload could not be exchanged with the payload store"),
+	}
+	ErrCallQueueUnavailable = err{
+		code:  http.StatusServiceUnavailable,
+		error: errors.New("Call could not be queued"),
+	}
+	ErrInvalidContentEncoding = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Request body could not be decoded with its Content-Encoding"),
+	}
 	ErrFoundDynamicURL = err{
 		code:  http.StatusBadRequest,
 		error: errors.New("Dynamic URL is not allowed"),
@@ -99,6 +118,14 @@ var (
 		code:  http.StatusBadRequest,
 		error: fmt.Errorf("memory value is out of range. It should be between 0 and %d", MaxMemory),
 	}
+	ErrInvalidTmpFsSize = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("tmpfs_size value is out of range. It should be between 0 and %d", MaxMemory),
+	}
+	ErrInvalidSize = err{
+		code:  http.StatusBadRequest,
+		error: errors.New(`Invalid size, sizes must be a number of megabytes or a number with a unit suffix such as "512Mi" or "2G"`),
+	}
 	ErrCallResourceTooBig = err{
 		code:  http.StatusBadRequest,
 		error: fmt.Errorf("Requested CPU/Memory cannot be allocated"),
@@ -154,6 +181,26 @@ var (
 		error: errors.New("Detach call functions are not supported on this server"),
 	}
 
+	ErrWarmupUnsupported = err{
+		code:  http.StatusNotImplemented,
+		error: errors.New("Warming up functions is not supported on this server"),
+	}
+
+	ErrInvalidWarmupCount = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("Warmup count must be between 1 and %d", MaxWarmupCount),
+	}
+
+	ErrInvalidInvokeBatch = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("Invalid batch, it must be a JSON array of between 1 and %d payloads", MaxInvokeBatchItems),
+	}
+
+	ErrInvalidInvokeBatchParallelism = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Batch parallelism must be a positive number"),
+	}
+
 	ErrCallHandlerNotFound = err{
 		code:  http.StatusInternalServerError,
 		error: errors.New("Unable to find the call handle"),
@@ -212,6 +259,14 @@ var (
 		code:  http.StatusGatewayTimeout,
 		error: errors.New("Container initialization timed out, please ensure you are using the latest fdk and check the logs"),
 	}
+	ErrContainerNotReady = ferr{
+		code:  http.StatusBadGateway,
+		error: errors.New("Container did not become ready, please ensure you are using the latest fdk and check the logs"),
+	}
+	ErrContainerFsFull = ferr{
+		code:  http.StatusBadGateway,
+		error: errors.New("Container filesystem full, the fn wrote more than its fs size or tmpfs size allow"),
+	}
 
 	ErrSyslogUnavailable = ferr{
 		code:  http.StatusInternalServerError,
@@ -242,6 +297,25 @@ func (e err) Code() int { return e.code }
 // NewAPIError returns an APIError given a code and error
 func NewAPIError(code int, e error) APIError { return err{code, e} }
 
+// FieldsError is an APIError that also describes which fields of a request
+// were invalid
+type FieldsError interface {
+	APIError
+	Fields() string
+}
+
+type fieldsErr struct {
+	err
+	fields string
+}
+
+func (e fieldsErr) Fields() string { return e.fields }
+
+// NewFieldsError returns a FieldsError given a code, error and description of the invalid fields
+func NewFieldsError(code int, e error, fields string) FieldsError {
+	return fieldsErr{err{code, e}, fields}
+}
+
 // IsAPIError returns whether err implements APIError
 func IsAPIError(e error) bool {
 	_, ok := e.(APIError)
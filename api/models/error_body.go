@@ -2,7 +2,11 @@ package models
 
 type Error struct {
 	Message string `json:"message,omitempty"`
-	Fields  string `json:"fields,omitempty"`
+	// Code identifies the error, such as apps_not_found, as its message may be reworded or localized
+	Code   string `json:"code,omitempty"`
+	Fields string `json:"fields,omitempty"`
+	// Errors are the fields of the request that failed validation, with their paths and codes
+	Errors []FieldError `json:"errors,omitempty"`
 }
 
 // Validate validates this error body
@@ -0,0 +1,286 @@
+package models
+
+// CodedError is an APIError with a stable code, so that error catalogs may replace its message, and the parameters
+// of its message, which catalog templates may use
+type CodedError interface {
+	APIError
+	ErrorCode() string
+	ErrorParams() map[string]string
+}
+
+type codedErr struct {
+	err
+	errorCode string
+	params    map[string]string
+}
+
+var _ CodedError = codedErr{}
+
+func (e codedErr) ErrorCode() string              { return e.errorCode }
+func (e codedErr) ErrorParams() map[string]string { return e.params }
+
+// NewCodedError returns a CodedError given a status code, error code, the parameters of its message and its default
+// message e
+func NewCodedError(status int, code string, params map[string]string, e error) CodedError {
+	return codedErr{err{status, e}, code, params}
+}
+
+// GetErrorCode returns the code and message parameters of e, the code of CodedErrors or of the errors of this package,
+// or "" if e has none
+func GetErrorCode(e error) (string, map[string]string) {
+	switch t := e.(type) {
+	case CodedError:
+		return t.ErrorCode(), t.ErrorParams()
+	case *apiErrorWrapper:
+		return GetErrorCode(t.APIError)
+	case err:
+		return errorCodes[t], nil
+	case ferr:
+		if code, ok := errorCodes[t]; ok {
+			return code, nil
+		}
+		// fn errors made with NewFuncError have the code of the error they wrap
+		return GetErrorCode(t.error)
+	}
+	return "", nil
+}
+
+// errorCodes are the codes of the errors of this package, by the file they are declared in
+var errorCodes = map[error]string{
+	// app.go
+	ErrAppsMissingID:     "apps_missing_id",
+	ErrAppIDProvided:     "app_id_provided",
+	ErrAppsIDMismatch:    "apps_id_mismatch",
+	ErrAppsMissingName:   "apps_missing_name",
+	ErrAppsTooLongName:   "apps_too_long_name",
+	ErrAppsInvalidName:   "apps_invalid_name",
+	ErrAppsAlreadyExists: "apps_already_exists",
+	ErrAppsMissingNew:    "apps_missing_new",
+	ErrAppsNameImmutable: "apps_name_immutable",
+	ErrAppsNotFound:      "apps_not_found",
+
+	// app_maintenance.go
+	ErrAppInvalidMaintenance: "app_invalid_maintenance",
+	ErrFnAppMaintenance:      "fn_app_maintenance",
+
+	// app_policy.go
+	ErrAppInvalidPolicy:   "app_invalid_policy",
+	ErrAppPolicyNoSecrets: "app_policy_no_secrets",
+	ErrFnAppPolicy:        "fn_app_policy",
+
+	// app_quota.go
+	ErrAppInvalidQuota: "app_invalid_quota",
+
+	// call_replay.go
+	ErrCallNotReplayable:     "call_not_replayable",
+	ErrCallReplayUnsupported: "call_replay_unsupported",
+	ErrCallInputNotFound:     "call_input_not_found",
+	ErrCallInputUnavailable:  "call_input_unavailable",
+
+	// call_state.go
+	ErrCallInvalidTransition: "call_invalid_transition",
+	ErrCallExists:            "call_exists",
+	ErrCallInvalidState:      "call_invalid_state",
+
+	// error.go
+	ErrMethodNotAllowed:              "method_not_allowed",
+	ErrInvalidJSON:                   "invalid_json",
+	ErrClientCancel:                  "client_cancel",
+	ErrCallTimeoutServerBusy:         "call_timeout_server_busy",
+	ErrUnsupportedMediaType:          "unsupported_media_type",
+	ErrPatchTestFailed:               "patch_test_failed",
+	ErrMissingID:                     "missing_id",
+	ErrMissingAppID:                  "missing_app_id",
+	ErrMissingFnID:                   "missing_fn_id",
+	ErrMissingName:                   "missing_name",
+	ErrCreatedAtProvided:             "created_at_provided",
+	ErrUpdatedAtProvided:             "updated_at_provided",
+	ErrDatastoreEmptyApp:             "datastore_empty_app",
+	ErrDatastoreEmptyCallID:          "datastore_empty_call_id",
+	ErrDatastoreEmptyFn:              "datastore_empty_fn",
+	ErrDatastoreEmptyFnID:            "datastore_empty_fn_id",
+	ErrInvalidPayload:                "invalid_payload",
+	ErrPayloadStoreUnavailable:       "payload_store_unavailable",
+	ErrInvalidContentEncoding:        "invalid_content_encoding",
+	ErrFoundDynamicURL:               "found_dynamic_url",
+	ErrPathMalformed:                 "path_malformed",
+	ErrInvalidToTime:                 "invalid_to_time",
+	ErrInvalidFromTime:               "invalid_from_time",
+	ErrInvalidMemory:                 "invalid_memory",
+	ErrInvalidTmpFsSize:              "invalid_tmp_fs_size",
+	ErrInvalidSize:                   "invalid_size",
+	ErrCallResourceTooBig:            "call_resource_too_big",
+	ErrCallNotFound:                  "call_not_found",
+	ErrInvalidCPUs:                   "invalid_cpus",
+	ErrCallLogNotFound:               "call_log_not_found",
+	ErrPathNotFound:                  "path_not_found",
+	ErrInvalidAnnotationKey:          "invalid_annotation_key",
+	ErrInvalidAnnotationKeyLength:    "invalid_annotation_key_length",
+	ErrInvalidAnnotationValue:        "invalid_annotation_value",
+	ErrInvalidAnnotationValueLength:  "invalid_annotation_value_length",
+	ErrTooManyAnnotationKeys:         "too_many_annotation_keys",
+	ErrTooManyRequests:               "too_many_requests",
+	ErrAsyncUnsupported:              "async_unsupported",
+	ErrDetachUnsupported:             "detach_unsupported",
+	ErrWarmupUnsupported:             "warmup_unsupported",
+	ErrInvalidWarmupCount:            "invalid_warmup_count",
+	ErrInvalidInvokeBatch:            "invalid_invoke_batch",
+	ErrInvalidInvokeBatchParallelism: "invalid_invoke_batch_parallelism",
+	ErrCallHandlerNotFound:           "call_handler_not_found",
+	ErrServiceReservationFailure:     "service_reservation_failure",
+	ErrDockerPullTimeout:             "docker_pull_timeout",
+	ErrFunctionResponseTooBig:        "function_response_too_big",
+	ErrFunctionResponseHdrTooBig:     "function_response_hdr_too_big",
+	ErrFunctionResponse:              "function_response",
+	ErrFunctionFailed:                "function_failed",
+	ErrFunctionInvalidResponse:       "function_invalid_response",
+	ErrFunctionPrematureWrite:        "function_premature_write",
+	ErrFunctionWriteRequest:          "function_write_request",
+	ErrRequestContentTooBig:          "request_content_too_big",
+	ErrCallTimeout:                   "call_timeout",
+	ErrContainerInitFail:             "container_init_fail",
+	ErrContainerInitTimeout:          "container_init_timeout",
+	ErrContainerNotReady:             "container_not_ready",
+	ErrContainerFsFull:               "container_fs_full",
+	ErrSyslogUnavailable:             "syslog_unavailable",
+	ErrRequestLimitExceeded:          "request_limit_exceeded",
+
+	// fn.go
+	ErrFnsIDMismatch:         "fns_id_mismatch",
+	ErrFnsIDProvided:         "fns_id_provided",
+	ErrFnsMissingID:          "fns_missing_id",
+	ErrFnsMissingName:        "fns_missing_name",
+	ErrFnsInvalidName:        "fns_invalid_name",
+	ErrFnsTooLongName:        "fns_too_long_name",
+	ErrFnsMissingAppID:       "fns_missing_app_id",
+	ErrFnsMissingImage:       "fns_missing_image",
+	ErrFnsInvalidImage:       "fns_invalid_image",
+	ErrFnsInvalidTimeout:     "fns_invalid_timeout",
+	ErrFnsInvalidIdleTimeout: "fns_invalid_idle_timeout",
+	ErrFnsNotFound:           "fns_not_found",
+	ErrFnsExists:             "fns_exists",
+	ErrFnDisabled:            "fn_disabled",
+
+	// fn_capture.go
+	ErrFnInvalidCapture: "fn_invalid_capture",
+
+	// fn_chain.go
+	ErrFnChainTargetNotFound: "fn_chain_target_not_found",
+
+	// fn_dataset.go
+	ErrFnInvalidDatasets:  "fn_invalid_datasets",
+	ErrDatasetUnavailable: "dataset_unavailable",
+
+	// fn_deployment.go
+	ErrFnDeploymentNotFound:         "fn_deployment_not_found",
+	ErrDatastoreEmptyFnDeploymentID: "datastore_empty_fn_deployment_id",
+
+	// fn_docker_daemon.go
+	ErrFnInvalidDockerDaemon: "fn_invalid_docker_daemon",
+
+	// fn_gpus.go
+	ErrFnInvalidGPUs: "fn_invalid_gpus",
+
+	// fn_image_pull_policy.go
+	ErrFnInvalidImagePullPolicy: "fn_invalid_image_pull_policy",
+
+	// fn_long_running.go
+	ErrFnInvalidLongRunning:         "fn_invalid_long_running",
+	ErrAppLongRunning:               "app_long_running",
+	ErrFnsInvalidLongRunningTimeout: "fns_invalid_long_running_timeout",
+	ErrCallLongRunningSync:          "call_long_running_sync",
+	ErrTooManyLongRunningCalls:      "too_many_long_running_calls",
+	ErrCallNotLive:                  "call_not_live",
+
+	// fn_mirror.go
+	ErrFnInvalidMirror:        "fn_invalid_mirror",
+	ErrAppMirror:              "app_mirror",
+	ErrFnMirrorTargetNotFound: "fn_mirror_target_not_found",
+
+	// fn_result_cache.go
+	ErrFnInvalidResultCache: "fn_invalid_result_cache",
+
+	// fn_scratch.go
+	ErrFnInvalidScratch:   "fn_invalid_scratch",
+	ErrScratchUnavailable: "scratch_unavailable",
+
+	// fn_stop.go
+	ErrFnInvalidStop: "fn_invalid_stop",
+
+	// fn_volume.go
+	ErrFnInvalidVolume:  "fn_invalid_volume",
+	ErrFnVolumeTooLarge: "fn_volume_too_large",
+
+	// image_scan.go
+	ErrImageScanNotFound:         "image_scan_not_found",
+	ErrDatastoreEmptyImageDigest: "datastore_empty_image_digest",
+
+	// lease.go
+	ErrLeaseHeld:                 "lease_held",
+	ErrDatastoreEmptyLeaseName:   "datastore_empty_lease_name",
+	ErrDatastoreEmptyLeaseHolder: "datastore_empty_lease_holder",
+
+	// runner_pool.go
+	ErrAppInvalidRunnerPool: "app_invalid_runner_pool",
+	ErrFnAppRunnerPool:      "fn_app_runner_pool",
+
+	// template.go
+	ErrTemplateNotFound: "template_not_found",
+	ErrTemplateNoApp:    "template_no_app",
+	ErrTemplateNoFn:     "template_no_fn",
+
+	// trigger.go
+	ErrTriggerIDProvided:          "trigger_id_provided",
+	ErrTriggerIDMismatch:          "trigger_id_mismatch",
+	ErrTriggerMissingName:         "trigger_missing_name",
+	ErrTriggerTooLongName:         "trigger_too_long_name",
+	ErrTriggerInvalidName:         "trigger_invalid_name",
+	ErrTriggerMissingAppID:        "trigger_missing_app_id",
+	ErrTriggerMissingFnID:         "trigger_missing_fn_id",
+	ErrTriggerFnIDNotSameApp:      "trigger_fn_id_not_same_app",
+	ErrTriggerTypeUnknown:         "trigger_type_unknown",
+	ErrTriggerMissingSource:       "trigger_missing_source",
+	ErrTriggerMissingSourcePrefix: "trigger_missing_source_prefix",
+	ErrTriggerNotFound:            "trigger_not_found",
+	ErrTriggerExists:              "trigger_exists",
+	ErrTriggerSourceExists:        "trigger_source_exists",
+	ErrTriggerInvalidConfig:       "trigger_invalid_config",
+	ErrTriggerDisabled:            "trigger_disabled",
+
+	// trigger_cache.go
+	ErrTriggerInvalidCache: "trigger_invalid_cache",
+
+	// trigger_cron.go
+	ErrTriggerInvalidCronSchedule: "trigger_invalid_cron_schedule",
+	ErrTriggerInvalidCron:         "trigger_invalid_cron",
+
+	// trigger_headers.go
+	ErrTriggerInvalidHeaders: "trigger_invalid_headers",
+
+	// trigger_run.go
+	ErrTriggerRunNotFound:           "trigger_run_not_found",
+	ErrTriggerRunNotReplayable:      "trigger_run_not_replayable",
+	ErrTriggerRunsUnsupported:       "trigger_runs_unsupported",
+	ErrTriggerRunsNotRecorded:       "trigger_runs_not_recorded",
+	ErrTriggerRunPayloadUnavailable: "trigger_run_payload_unavailable",
+	ErrTriggerInvalidRuns:           "trigger_invalid_runs",
+	ErrDatastoreEmptyTriggerRunID:   "datastore_empty_trigger_run_id",
+
+	// trigger_transform.go
+	ErrTriggerInvalidTransform: "trigger_invalid_transform",
+
+	// workflow.go
+	ErrWorkflowsNotFound:           "workflows_not_found",
+	ErrWorkflowsExists:             "workflows_exists",
+	ErrWorkflowsIDProvided:         "workflows_id_provided",
+	ErrWorkflowsMissingName:        "workflows_missing_name",
+	ErrWorkflowsInvalidName:        "workflows_invalid_name",
+	ErrWorkflowsMissingAppID:       "workflows_missing_app_id",
+	ErrWorkflowsInvalidSteps:       "workflows_invalid_steps",
+	ErrWorkflowsInvalidStep:        "workflows_invalid_step",
+	ErrWorkflowsStepFnNotFound:     "workflows_step_fn_not_found",
+	ErrWorkflowsUnsupported:        "workflows_unsupported",
+	ErrWorkflowRunNotFound:         "workflow_run_not_found",
+	ErrDatastoreEmptyWorkflowID:    "datastore_empty_workflow_id",
+	ErrDatastoreEmptyWorkflowRunID: "datastore_empty_workflow_run_id",
+}
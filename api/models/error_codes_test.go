@@ -0,0 +1,31 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestGetErrorCode(t *testing.T) {
+	if code, _ := GetErrorCode(ErrAppsNotFound); code != "apps_not_found" {
+		t.Errorf("expected the code of a registered error, got %q", code)
+	}
+	if code, _ := GetErrorCode(ErrInvalidCPUs); code != "invalid_cpus" {
+		t.Errorf("expected the code of a registered error, got %q", code)
+	}
+
+	err := NewCodedError(http.StatusBadGateway, "image_never_pulled", map[string]string{"image": "fnproject/hello"}, errors.New("not pulled"))
+	if code, params := GetErrorCode(err); code != "image_never_pulled" || params["image"] != "fnproject/hello" {
+		t.Errorf("expected the code and parameters of a coded error, got %q %v", code, params)
+	}
+	if err.Code() != http.StatusBadGateway || err.Error() != "not pulled" {
+		t.Errorf("expected the status and message of a coded error, got %d %q", err.Code(), err.Error())
+	}
+
+	if code, _ := GetErrorCode(NewAPIError(http.StatusNotFound, errors.New("not found"))); code != "" {
+		t.Errorf("expected no code for an uncoded error, got %q", code)
+	}
+	if code, _ := GetErrorCode(unhashableError{"a"}); code != "" {
+		t.Errorf("expected no code for an unhashable error, got %q", code)
+	}
+}
@@ -0,0 +1,158 @@
+package models
+
+import "strings"
+
+// Codes of the fields of requests that failed validation
+const (
+	FieldRequired    = "required"
+	FieldInvalid     = "invalid"
+	FieldTooLong     = "too_long"
+	FieldOutOfRange  = "out_of_range"
+	FieldNotAllowed  = "not_allowed"
+	FieldImmutable   = "immutable"
+	FieldMismatch    = "mismatch"
+	FieldNotFound    = "not_found"
+	FieldUnsupported = "unsupported"
+)
+
+// FieldError is a field of a request that failed validation
+type FieldError struct {
+	// Field is the path of the field in the request body, as name, config.KEY or annotations.fnproject.io/fn/stop
+	Field string `json:"field"`
+	// Code is what is wrong with the field, one of the Field codes such as FieldRequired
+	Code string `json:"code"`
+	// Message describes what is wrong with the field to people
+	Message string `json:"message"`
+}
+
+// ValidationError is a FieldsError describing each invalid field with its path and code
+type ValidationError interface {
+	FieldsError
+	FieldErrors() []FieldError
+}
+
+type validationErr struct {
+	err
+	fields []FieldError
+}
+
+var _ ValidationError = validationErr{}
+
+func (e validationErr) FieldErrors() []FieldError { return e.fields }
+
+// Fields describes the fields as "field: message" pairs, separated by "; "
+func (e validationErr) Fields() string {
+	fields := make([]string, len(e.fields))
+	for i, f := range e.fields {
+		fields[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(fields, "; ")
+}
+
+// NewValidationError returns a ValidationError given a code, error and the invalid fields
+func NewValidationError(code int, e error, fields ...FieldError) ValidationError {
+	return validationErr{err{code, e}, fields}
+}
+
+// GetFieldErrors returns the fields e reports failed validation, or nil if it reports none
+func GetFieldErrors(e error) []FieldError {
+	if ve, ok := e.(ValidationError); ok {
+		return ve.FieldErrors()
+	}
+	if e, ok := e.(err); ok {
+		if f, ok := errorFields[e]; ok {
+			return []FieldError{{Field: f.field, Code: f.code, Message: e.Error()}}
+		}
+	}
+	return nil
+}
+
+type errorField struct {
+	field, code string
+}
+
+func annotationField(key string) string { return "annotations." + key }
+
+// errorFields are the fields the validation errors of apps, fns and triggers are about
+var errorFields = map[err]errorField{
+	ErrMissingID:         {"id", FieldRequired},
+	ErrMissingAppID:      {"app_id", FieldRequired},
+	ErrMissingFnID:       {"fn_id", FieldRequired},
+	ErrMissingName:       {"name", FieldRequired},
+	ErrCreatedAtProvided: {"created_at", FieldNotAllowed},
+	ErrUpdatedAtProvided: {"updated_at", FieldNotAllowed},
+	ErrInvalidMemory:     {"memory", FieldOutOfRange},
+	ErrInvalidTmpFsSize:  {"tmpfs_size", FieldOutOfRange},
+	ErrInvalidCPUs:       {"cpus", FieldInvalid},
+
+	ErrInvalidAnnotationKey:         {"annotations", FieldInvalid},
+	ErrInvalidAnnotationKeyLength:   {"annotations", FieldTooLong},
+	ErrInvalidAnnotationValue:       {"annotations", FieldInvalid},
+	ErrInvalidAnnotationValueLength: {"annotations", FieldTooLong},
+	ErrTooManyAnnotationKeys:        {"annotations", FieldOutOfRange},
+
+	ErrAppsMissingID:     {"id", FieldRequired},
+	ErrAppIDProvided:     {"id", FieldNotAllowed},
+	ErrAppsIDMismatch:    {"id", FieldMismatch},
+	ErrAppsMissingName:   {"name", FieldRequired},
+	ErrAppsTooLongName:   {"name", FieldTooLong},
+	ErrAppsInvalidName:   {"name", FieldInvalid},
+	ErrAppsNameImmutable: {"name", FieldImmutable},
+
+	ErrAppInvalidMaintenance: {annotationField(AppMaintenanceAnnotation), FieldInvalid},
+	ErrFnAppMaintenance:      {annotationField(AppMaintenanceAnnotation), FieldNotAllowed},
+	ErrAppInvalidPolicy:      {annotationField(AppPolicyAnnotation), FieldInvalid},
+	ErrAppPolicyNoSecrets:    {annotationField(AppPolicyAnnotation), FieldUnsupported},
+	ErrFnAppPolicy:           {annotationField(AppPolicyAnnotation), FieldNotAllowed},
+	ErrAppInvalidQuota:       {annotationField(AppQuotaAnnotation), FieldInvalid},
+	ErrAppInvalidRunnerPool:  {annotationField(AppRunnerPoolAnnotation), FieldInvalid},
+	ErrFnAppRunnerPool:       {annotationField(AppRunnerPoolAnnotation), FieldNotAllowed},
+
+	ErrFnsIDMismatch:                {"id", FieldMismatch},
+	ErrFnsIDProvided:                {"id", FieldNotAllowed},
+	ErrFnsMissingID:                 {"id", FieldRequired},
+	ErrFnsMissingName:               {"name", FieldRequired},
+	ErrFnsInvalidName:               {"name", FieldInvalid},
+	ErrFnsTooLongName:               {"name", FieldTooLong},
+	ErrFnsMissingAppID:              {"app_id", FieldRequired},
+	ErrFnsMissingImage:              {"image", FieldRequired},
+	ErrFnsInvalidImage:              {"image", FieldInvalid},
+	ErrFnsInvalidTimeout:            {"timeout", FieldOutOfRange},
+	ErrFnsInvalidIdleTimeout:        {"idle_timeout", FieldOutOfRange},
+	ErrFnsInvalidLongRunningTimeout: {"timeout", FieldOutOfRange},
+	ErrFnChainTargetNotFound:        {"chain", FieldNotFound},
+
+	ErrFnInvalidCapture:         {annotationField(FnCaptureAnnotation), FieldInvalid},
+	ErrFnInvalidDatasets:        {annotationField(FnDatasetsAnnotation), FieldInvalid},
+	ErrFnInvalidDockerDaemon:    {annotationField(FnDockerDaemonAnnotation), FieldInvalid},
+	ErrFnInvalidGPUs:            {annotationField(FnGPUsAnnotation), FieldInvalid},
+	ErrFnInvalidImagePullPolicy: {annotationField(FnImagePullPolicyAnnotation), FieldInvalid},
+	ErrFnInvalidLongRunning:     {annotationField(FnLongRunningAnnotation), FieldInvalid},
+	ErrAppLongRunning:           {annotationField(FnLongRunningAnnotation), FieldNotAllowed},
+	ErrFnInvalidMirror:          {annotationField(FnMirrorAnnotation), FieldInvalid},
+	ErrAppMirror:                {annotationField(FnMirrorAnnotation), FieldNotAllowed},
+	ErrFnMirrorTargetNotFound:   {annotationField(FnMirrorAnnotation), FieldNotFound},
+	ErrFnInvalidResultCache:     {annotationField(FnResultCacheAnnotation), FieldInvalid},
+	ErrFnInvalidScratch:         {annotationField(FnScratchAnnotation), FieldInvalid},
+	ErrFnInvalidStop:            {annotationField(FnStopAnnotation), FieldInvalid},
+	ErrFnInvalidVolume:          {annotationField(FnVolumeAnnotation), FieldInvalid},
+
+	ErrTriggerIDProvided:          {"id", FieldNotAllowed},
+	ErrTriggerIDMismatch:          {"id", FieldMismatch},
+	ErrTriggerMissingName:         {"name", FieldRequired},
+	ErrTriggerTooLongName:         {"name", FieldTooLong},
+	ErrTriggerInvalidName:         {"name", FieldInvalid},
+	ErrTriggerMissingAppID:        {"app_id", FieldRequired},
+	ErrTriggerMissingFnID:         {"fn_id", FieldRequired},
+	ErrTriggerFnIDNotSameApp:      {"fn_id", FieldInvalid},
+	ErrTriggerTypeUnknown:         {"type", FieldUnsupported},
+	ErrTriggerMissingSource:       {"source", FieldRequired},
+	ErrTriggerMissingSourcePrefix: {"source", FieldInvalid},
+	ErrTriggerInvalidCronSchedule: {"source", FieldInvalid},
+	ErrTriggerInvalidConfig:       {annotationField(TriggerConfigAnnotation), FieldInvalid},
+	ErrTriggerInvalidCache:        {annotationField(TriggerCacheAnnotation), FieldInvalid},
+	ErrTriggerInvalidCron:         {annotationField(TriggerCronAnnotation), FieldInvalid},
+	ErrTriggerInvalidHeaders:      {annotationField(TriggerHeadersAnnotation), FieldInvalid},
+	ErrTriggerInvalidRuns:         {annotationField(TriggerRunsAnnotation), FieldInvalid},
+	ErrTriggerInvalidTransform:    {annotationField(TriggerTransformAnnotation), FieldInvalid},
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// unhashableError is an error that cannot be a map key
+type unhashableError []string
+
+func (e unhashableError) Error() string { return "unhashable" }
+
+func TestGetFieldErrors(t *testing.T) {
+	fields := GetFieldErrors((&Fn{Name: "fn", AppID: "app_id"}).Validate())
+	if len(fields) != 1 || fields[0].Field != "image" || fields[0].Code != FieldRequired || fields[0].Message != ErrFnsMissingImage.Error() {
+		t.Errorf("expected the image of the fn to be required, got %+v", fields)
+	}
+
+	fields = GetFieldErrors((&Trigger{Name: "t", AppID: "app_id", FnID: "fn_id", Type: "http", Source: "src"}).Validate())
+	if len(fields) != 1 || fields[0].Field != "source" || fields[0].Code != FieldInvalid {
+		t.Errorf("expected the source of the trigger to be invalid, got %+v", fields)
+	}
+
+	err := NewValidationError(http.StatusBadRequest, errors.New("invalid"),
+		FieldError{Field: "name", Code: FieldInvalid, Message: "must be lower case"},
+		FieldError{Field: "config.KEY", Code: FieldRequired, Message: "must be set"})
+	if fields := GetFieldErrors(err); len(fields) != 2 || fields[1].Field != "config.KEY" {
+		t.Errorf("expected the fields of the validation error, got %+v", fields)
+	}
+	if err.Fields() != "name: must be lower case; config.KEY: must be set" {
+		t.Errorf("expected the fields to be described, got %q", err.Fields())
+	}
+
+	for _, err := range []error{ErrAppsNotFound, errors.New("boom"), unhashableError{"a"}, nil} {
+		if fields := GetFieldErrors(err); fields != nil {
+			t.Errorf("expected no fields for %v, got %+v", err, fields)
+		}
+	}
+}
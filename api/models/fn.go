@@ -74,6 +74,10 @@ var (
 		code:  http.StatusConflict,
 		error: errors.New("Fn with specified name already exists"),
 	}
+	ErrFnDisabled = err{
+		code:  http.StatusServiceUnavailable,
+		error: errors.New("Fn is disabled"),
+	}
 )
 
 // FnInvokeEndpointAnnotation is the annotation that exposes the fn invoke endpoint For want of a better place to put this it's here
@@ -96,6 +100,10 @@ type Fn struct {
 	Config Config `json:"config" db:"config"`
 	// Annotations allow additional configuration of a function, these are not passed to the function.
 	Annotations Annotations `json:"annotations,omitempty" db:"annotations"`
+	// Chain optionally routes the output of this function to other functions.
+	Chain *FnChain `json:"chain,omitempty" db:"chain"`
+	// Disabled fns reject invokes, until they are enabled again by setting it to false.
+	Disabled *bool `json:"disabled,omitempty" db:"disabled"`
 	// CreatedAt is the UTC timestamp when this function was created.
 	CreatedAt common.DateTime `json:"created_at,omitempty" db:"created_at"`
 	// UpdatedAt is the UTC timestamp of the last time this func was modified.
@@ -104,8 +112,15 @@ type Fn struct {
 
 // ResourceConfig specified resource constraints imposed on a function execution.
 type ResourceConfig struct {
-	// Memory is the amount of memory allotted, in MB.
-	Memory uint64 `json:"memory,omitempty" db:"memory"`
+	// Memory is the amount of memory allotted, in MB. It may be specified as
+	// a number of MB or with a unit suffix, eg. "512Mi" or "2G".
+	Memory Megabytes `json:"memory,omitempty" db:"memory"`
+	// CPUs is the CPU quota in MilliCPUs, specified either as "100m" or "0.1".
+	// 0 is unlimited.
+	CPUs MilliCPUs `json:"cpus,omitempty" db:"cpus"`
+	// TmpFsSize is the size of the writable /tmp mount, in MB. It accepts the
+	// same units as Memory, 0 disables the tmpfs mount.
+	TmpFsSize Megabytes `json:"tmpfs_size,omitempty" db:"tmpfs_size"`
 	// Timeout is the max execution time for a function, in seconds.
 	// TODO this should probably be milliseconds?
 	Timeout int32 `json:"timeout,omitempty" db:"timeout"`
@@ -118,7 +133,7 @@ type ResourceConfig struct {
 func (f *Fn) SetDefaults() {
 
 	if f.Memory == 0 {
-		f.Memory = DefaultMemory
+		f.Memory = Megabytes(DefaultMemory)
 	}
 
 	if f.Config == nil {
@@ -158,7 +173,24 @@ func (f *Fn) Validate() error {
 		return ErrFnsMissingImage
 	}
 
-	if f.Timeout <= 0 || f.Timeout > MaxTimeout {
+	longRunning, err := f.Annotations.LongRunning()
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Annotations.ResultCache(); err != nil {
+		return err
+	}
+
+	if _, err := f.Annotations.Mirror(); err != nil {
+		return err
+	}
+
+	if longRunning != nil {
+		if f.Timeout <= 0 || f.Timeout > MaxLongRunningTimeout {
+			return ErrFnsInvalidLongRunningTimeout
+		}
+	} else if f.Timeout <= 0 || f.Timeout > MaxTimeout {
 		return ErrFnsInvalidTimeout
 	}
 
@@ -166,10 +198,65 @@ func (f *Fn) Validate() error {
 		return ErrFnsInvalidIdleTimeout
 	}
 
-	if f.Memory < 1 || f.Memory > MaxMemory {
+	if f.Memory < 1 || uint64(f.Memory) > MaxMemory {
 		return ErrInvalidMemory
 	}
 
+	if uint64(f.TmpFsSize) > MaxMemory {
+		return ErrInvalidTmpFsSize
+	}
+
+	if f.CPUs > MaxMilliCPUs {
+		return ErrInvalidCPUs
+	}
+
+	if _, err := f.Annotations.Volume(); err != nil {
+		return err
+	}
+
+	if _, err := f.Annotations.Datasets(); err != nil {
+		return err
+	}
+
+	if _, err := f.Annotations.Stop(); err != nil {
+		return err
+	}
+
+	if _, err := f.Annotations.Scratch(); err != nil {
+		return err
+	}
+
+	if _, err := f.Annotations.GPUs(); err != nil {
+		return err
+	}
+
+	if _, err := f.Annotations.Capture(); err != nil {
+		return err
+	}
+
+	if _, err := f.Annotations.DockerDaemonLabels(); err != nil {
+		return err
+	}
+
+	if _, err := f.Annotations.ImagePullPolicy(); err != nil {
+		return err
+	}
+
+	// the policy of an app is not for its fns to loosen
+	if _, ok := f.Annotations.Get(AppPolicyAnnotation); ok {
+		return ErrFnAppPolicy
+	}
+
+	// nor is its runner pool for its fns to leave
+	if _, ok := f.Annotations.Get(AppRunnerPoolAnnotation); ok {
+		return ErrFnAppRunnerPool
+	}
+
+	// nor its maintenance for its fns to opt out of
+	if _, ok := f.Annotations.Get(AppMaintenanceAnnotation); ok {
+		return ErrFnAppMaintenance
+	}
+
 	return f.Annotations.Validate()
 }
 
@@ -207,6 +294,14 @@ func (f *Fn) Clone() *Fn {
 			clone.Annotations[k] = v
 		}
 	}
+	if f.Chain != nil {
+		chain := *f.Chain
+		clone.Chain = &chain
+	}
+	if f.Disabled != nil {
+		disabled := *f.Disabled
+		clone.Disabled = &disabled
+	}
 	return clone
 }
 
@@ -220,10 +315,14 @@ func (f1 *Fn) Equals(f2 *Fn) bool {
 	eq = eq && f1.AppID == f2.AppID
 	eq = eq && f1.Image == f2.Image
 	eq = eq && f1.Memory == f2.Memory
+	eq = eq && f1.CPUs == f2.CPUs
+	eq = eq && f1.TmpFsSize == f2.TmpFsSize
 	eq = eq && f1.Timeout == f2.Timeout
 	eq = eq && f1.IdleTimeout == f2.IdleTimeout
 	eq = eq && f1.Config.Equals(f2.Config)
 	eq = eq && f1.Annotations.Equals(f2.Annotations)
+	eq = eq && f1.Chain.Equals(f2.Chain)
+	eq = eq && f1.IsDisabled() == f2.IsDisabled()
 	// NOTE: datastore tests are not very fun to write with timestamp checks,
 	// and these are not values the user may set so we kind of don't care.
 	//eq = eq && time.Time(f1.CreatedAt).Equal(time.Time(f2.CreatedAt))
@@ -241,10 +340,14 @@ func (f1 *Fn) EqualsWithAnnotationSubset(f2 *Fn) bool {
 	eq = eq && f1.AppID == f2.AppID
 	eq = eq && f1.Image == f2.Image
 	eq = eq && f1.Memory == f2.Memory
+	eq = eq && f1.CPUs == f2.CPUs
+	eq = eq && f1.TmpFsSize == f2.TmpFsSize
 	eq = eq && f1.Timeout == f2.Timeout
 	eq = eq && f1.IdleTimeout == f2.IdleTimeout
 	eq = eq && f1.Config.Equals(f2.Config)
 	eq = eq && f1.Annotations.Subset(f2.Annotations)
+	eq = eq && f1.Chain.Equals(f2.Chain)
+	eq = eq && f1.IsDisabled() == f2.IsDisabled()
 	// NOTE: datastore tests are not very fun to write with timestamp checks,
 	// and these are not values the user may set so we kind of don't care.
 	//eq = eq && time.Time(f1.CreatedAt).Equal(time.Time(f2.CreatedAt))
@@ -264,6 +367,12 @@ func (f *Fn) Update(patch *Fn) {
 	if patch.Memory != 0 {
 		f.Memory = patch.Memory
 	}
+	if patch.CPUs != 0 {
+		f.CPUs = patch.CPUs
+	}
+	if patch.TmpFsSize != 0 {
+		f.TmpFsSize = patch.TmpFsSize
+	}
 
 	if patch.Timeout != 0 {
 		f.Timeout = patch.Timeout
@@ -286,11 +395,30 @@ func (f *Fn) Update(patch *Fn) {
 
 	f.Annotations = f.Annotations.MergeChange(patch.Annotations)
 
+	// an empty chain removes it
+	if patch.Chain != nil {
+		f.Chain = nil
+		if !patch.Chain.IsEmpty() {
+			chain := *patch.Chain
+			f.Chain = &chain
+		}
+	}
+
+	if patch.Disabled != nil {
+		disabled := *patch.Disabled
+		f.Disabled = &disabled
+	}
+
 	if !f.Equals(original) {
 		f.UpdatedAt = common.DateTime(time.Now())
 	}
 }
 
+// IsDisabled returns whether f is disabled
+func (f *Fn) IsDisabled() bool {
+	return f.Disabled != nil && *f.Disabled
+}
+
 type FnFilter struct {
 	AppID   string // this is exact match
 	Name    string //exact match
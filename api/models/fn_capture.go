@@ -0,0 +1,85 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// FnCaptureAnnotation holds a JSON FnCapture object, opting a fn in to having the inputs of a sample of its sync
+// calls recorded with call records, as those of detached calls are, to replay them or download them for debugging.
+const FnCaptureAnnotation = "fnproject.io/fn/capture"
+
+// DefaultFnCaptureTTL is how long recorded inputs are kept for, in seconds, if a fn does not say
+const DefaultFnCaptureTTL = 24 * 60 * 60
+
+// MaxFnCaptureTTL is the longest a fn may have its recorded inputs kept for, in seconds
+const MaxFnCaptureTTL = 30 * 24 * 60 * 60
+
+var (
+	ErrFnInvalidCapture = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with a percent of between 0 and 100, a "+
+			"max_size of at most %d bytes and a ttl of at most %d seconds", FnCaptureAnnotation, MaxCallInput, MaxFnCaptureTTL),
+	}
+)
+
+// FnCapture is the input capture policy of a fn. It also caps the size and sets the ttl of the recorded inputs of
+// the detached calls of the fn, which are always recorded.
+type FnCapture struct {
+	// Percent is the share of sync calls whose input is recorded
+	Percent float64 `json:"percent"`
+	// MaxSize is the largest input recorded, in bytes, MaxCallInput if 0
+	MaxSize int `json:"max_size,omitempty"`
+	// TTL is how long, in seconds, recorded inputs are kept for, DefaultFnCaptureTTL if 0
+	TTL int `json:"ttl,omitempty"`
+	// Redact are the top level fields of JSON inputs whose values are replaced before the inputs are recorded
+	Redact []string `json:"redact,omitempty"`
+}
+
+// Validate checks the percent, size and ttl of the policy
+func (c *FnCapture) Validate() error {
+	if c.Percent < 0 || c.Percent > 100 || c.MaxSize < 0 || c.MaxSize > MaxCallInput || c.TTL < 0 || c.TTL > MaxFnCaptureTTL {
+		return ErrFnInvalidCapture
+	}
+	return nil
+}
+
+// Size returns the largest input recorded, in bytes
+func (c *FnCapture) Size() int {
+	if c == nil || c.MaxSize == 0 {
+		return MaxCallInput
+	}
+	return c.MaxSize
+}
+
+// Duration returns how long recorded inputs are kept for, 0 if they are kept as long as their call records
+func (c *FnCapture) Duration() time.Duration {
+	switch {
+	case c == nil:
+		return 0
+	case c.TTL == 0:
+		return DefaultFnCaptureTTL * time.Second
+	}
+	return time.Duration(c.TTL) * time.Second
+}
+
+// Capture returns the input capture policy held in the FnCaptureAnnotation of annotations, or nil if there is none
+func (a Annotations) Capture() (*FnCapture, error) {
+	raw, ok := a.Get(FnCaptureAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var c FnCapture
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, ErrFnInvalidCapture
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
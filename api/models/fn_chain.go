@@ -0,0 +1,55 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrFnChainTargetNotFound is returned when a fn chains to a fn that does not exist
+	ErrFnChainTargetNotFound = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Chained fn not found"),
+	}
+)
+
+// FnChain routes the output of a fn's calls to other fns. The fn to chain to
+// is invoked asynchronously, with the output of a successful call or the
+// error of a failed one as its input. Chains may be nested, up to a depth set
+// on the agent, and calls that would loop back into a fn already in the chain
+// are dropped.
+type FnChain struct {
+	// OnSuccess is the ID of the fn to invoke with the output of successful calls.
+	OnSuccess string `json:"on_success,omitempty"`
+	// OnFailure is the ID of the fn to invoke with the error of failed calls.
+	OnFailure string `json:"on_failure,omitempty"`
+}
+
+// IsEmpty returns whether c routes calls nowhere
+func (c *FnChain) IsEmpty() bool {
+	return c == nil || (c.OnSuccess == "" && c.OnFailure == "")
+}
+
+// Equals returns whether c1 and c2 route calls to the same fns
+func (c1 *FnChain) Equals(c2 *FnChain) bool {
+	if c1.IsEmpty() || c2.IsEmpty() {
+		return c1.IsEmpty() == c2.IsEmpty()
+	}
+	return *c1 == *c2
+}
+
+// Value implements sql.Valuer, storing an empty chain as NULL
+func (c *FnChain) Value() (driver.Value, error) {
+	if c.IsEmpty() {
+		return nil, nil
+	}
+	b, err := json.Marshal(c)
+	return driver.Value(string(b)), err
+}
+
+// Scan implements sql.Scanner
+func (c *FnChain) Scan(value interface{}) error {
+	return scanJSON("chain", value, c)
+}
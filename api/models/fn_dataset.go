@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// FnDatasetsAnnotation holds a JSON list of FnDataset objects, naming the read-only datasets the fn's containers
+// depend on. As annotations cascade, an app may declare datasets for all of its fns.
+const FnDatasetsAnnotation = "fnproject.io/fn/datasets"
+
+// MaxFnDatasetVersionLength is the longest a dataset version may be
+const MaxFnDatasetVersionLength = 64
+
+var validFnDatasetVersion = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+var (
+	ErrFnInvalidDatasets = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be a list of objects with a name of up to %d letters, digits, "+
+			"'-' or '_', a distinct absolute path other than / and an optional version", FnDatasetsAnnotation, MaxFnVolumeNameLength),
+	}
+	ErrDatasetUnavailable = err{
+		code:  http.StatusServiceUnavailable,
+		error: errors.New("A dataset the fn depends on is not available on this runner"),
+	}
+)
+
+// FnDataset is a read-only dataset, such as model weights, that runners sync from a dataset source and mount into
+// the fn's containers. Each dataset has versions, which are never changed once published. Without a version, a
+// container gets the latest version the runner has synced when it starts, and keeps it for its lifetime.
+type FnDataset struct {
+	// Name identifies the dataset in the dataset source
+	Name string `json:"name"`
+	// Path is where the dataset is mounted in the fn's containers
+	Path string `json:"path"`
+	// Version pins the version of the dataset, optional
+	Version string `json:"version,omitempty"`
+}
+
+// Validate checks the name, path and version of the dataset
+func (d *FnDataset) Validate() error {
+	if len(d.Name) > MaxFnVolumeNameLength || !validFnVolumeName.MatchString(d.Name) {
+		return ErrFnInvalidDatasets
+	}
+	if !validMountPath(d.Path) {
+		return ErrFnInvalidDatasets
+	}
+	if d.Version != "" && !ValidFnDatasetVersion(d.Version) {
+		return ErrFnInvalidDatasets
+	}
+	return nil
+}
+
+// ValidFnDatasetVersion checks that version is up to MaxFnDatasetVersionLength letters, digits, '-', '_' or '.',
+// starting with a letter or digit
+func ValidFnDatasetVersion(version string) bool {
+	return len(version) <= MaxFnDatasetVersionLength && validFnDatasetVersion.MatchString(version)
+}
+
+// Datasets returns the datasets held in the FnDatasetsAnnotation of annotations
+func (a Annotations) Datasets() ([]FnDataset, error) {
+	raw, ok := a.Get(FnDatasetsAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var datasets []FnDataset
+	if err := json.Unmarshal(raw, &datasets); err != nil {
+		return nil, ErrFnInvalidDatasets
+	}
+	paths := make(map[string]bool, len(datasets))
+	for i := range datasets {
+		if err := datasets[i].Validate(); err != nil {
+			return nil, err
+		}
+		if paths[datasets[i].Path] {
+			return nil, ErrFnInvalidDatasets
+		}
+		paths[datasets[i].Path] = true
+	}
+	return datasets, nil
+}
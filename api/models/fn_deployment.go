@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fnproject/fn/api/common"
+)
+
+// FnSourceCommitAnnotation is the fn annotation deploy tooling sets to the commit of the source an image was
+// built from, it is recorded in the provenance of deployments.
+const FnSourceCommitAnnotation = "fnproject.io/fn/source-commit"
+
+// Signature statuses of deployed images
+const (
+	// SignatureUnverified images were not checked for a signature
+	SignatureUnverified = "unverified"
+)
+
+var (
+	ErrFnDeploymentNotFound = err{
+		code:  http.StatusNotFound,
+		error: errors.New("Fn deployment not found"),
+	}
+	ErrDatastoreEmptyFnDeploymentID = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Missing Fn deployment ID"),
+	}
+)
+
+// FnDeployment is the provenance of an image deployed to a fn, recorded
+// whenever the image of a fn changes. Deployments are never updated, and are
+// kept once their fn is deleted, for audits.
+type FnDeployment struct {
+	// ID is time ordered, later deployments have greater ids.
+	ID string `json:"id" db:"id"`
+	// FnID is the fn the image was deployed to.
+	FnID string `json:"fn_id" db:"fn_id"`
+	// AppID is the app of the fn.
+	AppID string `json:"app_id" db:"app_id"`
+	// Image is the image deployed.
+	Image string `json:"image" db:"image"`
+	// Digest is the digest the image is pinned to, if it is.
+	Digest string `json:"digest,omitempty" db:"digest"`
+	// DeployedBy is who deployed the image, as authenticated by an extension.
+	DeployedBy string `json:"deployed_by,omitempty" db:"deployed_by"`
+	// SourceCommit is the commit of the source the image was built from, from
+	// the fnproject.io/fn/source-commit annotation of the fn.
+	SourceCommit string `json:"source_commit,omitempty" db:"source_commit"`
+	// Scanned is whether the digest had been scanned for vulnerabilities.
+	Scanned bool `json:"scanned" db:"scanned"`
+	// Vulnerabilities are those the scan of the digest found.
+	Vulnerabilities ImageVulnerabilities `json:"vulnerabilities,omitempty" db:"vulnerabilities"`
+	// SignatureStatus is whether the signature of the image was verified.
+	SignatureStatus string `json:"signature_status" db:"signature_status"`
+	// CreatedAt is the UTC timestamp when the image was deployed.
+	CreatedAt common.DateTime `json:"created_at" db:"created_at"`
+}
+
+type FnDeploymentFilter struct {
+	FnID    string // this is exact match
+	Cursor  string
+	PerPage int
+}
+
+type FnDeploymentList struct {
+	NextCursor string          `json:"next_cursor,omitempty"`
+	Items      []*FnDeployment `json:"items"`
+}
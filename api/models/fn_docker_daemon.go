@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// FnDockerDaemonAnnotation holds a JSON object of the labels a docker daemon must have to run the fn's containers,
+// on runners with several docker daemons, eg. {"tenant": "acme"}. As annotations cascade, an app may set it for all
+// of its fns.
+const FnDockerDaemonAnnotation = "fnproject.io/fn/docker-daemon"
+
+var ErrFnInvalidDockerDaemon = err{
+	code:  http.StatusBadRequest,
+	error: fmt.Errorf("Invalid %s annotation, it must be an object of non-empty label names to string values", FnDockerDaemonAnnotation),
+}
+
+// DockerDaemonLabels returns the labels held in the FnDockerDaemonAnnotation of annotations, or nil if there are none
+func (a Annotations) DockerDaemonLabels() (map[string]string, error) {
+	raw, ok := a.Get(FnDockerDaemonAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var labels map[string]string
+	if err := json.Unmarshal(raw, &labels); err != nil {
+		return nil, ErrFnInvalidDockerDaemon
+	}
+	for k := range labels {
+		if k == "" {
+			return nil, ErrFnInvalidDockerDaemon
+		}
+	}
+	return labels, nil
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// FnGPUsAnnotation holds a JSON FnGPUs object, the GPUs each container of the fn is given, eg. {"nvidia.com/gpu": 1}.
+// As annotations cascade, an app may set it for all of its fns.
+const FnGPUsAnnotation = "fnproject.io/fn/gpus"
+
+// GPUResourceNVIDIA is the resource name of NVIDIA GPUs, as in kubernetes device plugins
+const GPUResourceNVIDIA = "nvidia.com/gpu"
+
+// MaxFnGPUs is the most GPUs of a resource a container may be given
+const MaxFnGPUs uint64 = 16
+
+// FnGPUResources are the GPU resources fns may ask for
+var FnGPUResources = []string{GPUResourceNVIDIA}
+
+var ErrFnInvalidGPUs = err{
+	code: http.StatusBadRequest,
+	error: fmt.Errorf("Invalid %s annotation, it must be an object of GPU counts between 1 and %d by resource, "+
+		"one of %s", FnGPUsAnnotation, MaxFnGPUs, strings.Join(FnGPUResources, ", ")),
+}
+
+// FnGPUs are the number of GPUs of each resource a container of a fn is given
+type FnGPUs map[string]uint64
+
+// Validate checks the resources and counts of the GPUs
+func (g FnGPUs) Validate() error {
+	for resource, count := range g {
+		if count == 0 || count > MaxFnGPUs {
+			return ErrFnInvalidGPUs
+		}
+		valid := false
+		for _, r := range FnGPUResources {
+			valid = valid || r == resource
+		}
+		if !valid {
+			return ErrFnInvalidGPUs
+		}
+	}
+	return nil
+}
+
+// GPUs returns the GPUs held in the FnGPUsAnnotation of annotations, or nil if there are none
+func (a Annotations) GPUs() (FnGPUs, error) {
+	raw, ok := a.Get(FnGPUsAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var g FnGPUs
+	if err := json.Unmarshal(raw, &g); err != nil {
+		return nil, ErrFnInvalidGPUs
+	}
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
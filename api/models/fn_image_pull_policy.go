@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// FnImagePullPolicyAnnotation holds the JSON string pull policy of the fn's image, one of ImagePullAlways,
+// ImagePullIfNotPresent or ImagePullNever. As annotations cascade, an app may set it for all of its fns.
+const FnImagePullPolicyAnnotation = "fnproject.io/fn/image_pull_policy"
+
+// Pull policies of the images of fns
+const (
+	// ImagePullAlways pulls the image each time a container is started for the fn, so that containers of a fn
+	// whose tag is pushed again run the image it names now
+	ImagePullAlways = "Always"
+	// ImagePullIfNotPresent pulls the image only if the runner does not have it, the default
+	ImagePullIfNotPresent = "IfNotPresent"
+	// ImagePullNever never pulls the image, the fn only runs on runners the image was pre-pulled or loaded on
+	ImagePullNever = "Never"
+)
+
+var ErrFnInvalidImagePullPolicy = err{
+	code: http.StatusBadRequest,
+	error: fmt.Errorf("Invalid %s annotation, it must be one of \"%s\", \"%s\" or \"%s\"", FnImagePullPolicyAnnotation,
+		ImagePullAlways, ImagePullIfNotPresent, ImagePullNever),
+}
+
+// ImagePullPolicy returns the pull policy held in the FnImagePullPolicyAnnotation of annotations, or
+// ImagePullIfNotPresent if there is none
+func (a Annotations) ImagePullPolicy() (string, error) {
+	raw, ok := a.Get(FnImagePullPolicyAnnotation)
+	if !ok {
+		return ImagePullIfNotPresent, nil
+	}
+	var policy string
+	if err := json.Unmarshal(raw, &policy); err != nil {
+		return "", ErrFnInvalidImagePullPolicy
+	}
+	switch policy {
+	case ImagePullAlways, ImagePullIfNotPresent, ImagePullNever:
+		return policy, nil
+	}
+	return "", ErrFnInvalidImagePullPolicy
+}
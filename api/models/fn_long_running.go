@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// FnLongRunningAnnotation holds a JSON FnLongRunning object, putting the fn in the long running class of calls. Long
+// running fns may have timeouts of up to MaxLongRunningTimeout, but are only invoked detached and must write to
+// their log regularly, so batch jobs do not tie up the sync path.
+const FnLongRunningAnnotation = "fnproject.io/fn/long_running"
+
+var (
+	// MaxLongRunningTimeout is the longest timeout of long running fns, in seconds
+	MaxLongRunningTimeout int32 = 4 * 3600 // 4h
+
+	// MaxLivenessInterval is the longest a long running fn may go without writing to its log, in seconds
+	MaxLivenessInterval int32 = 3600 // 1h
+
+	// DefaultLivenessInterval is how long a long running fn may go without writing to its log unless it says, in
+	// seconds
+	DefaultLivenessInterval int32 = 300 // 5m
+
+	ErrFnInvalidLongRunning = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with an optional liveness_interval between 0 "+
+			"and %d seconds", FnLongRunningAnnotation, MaxLivenessInterval),
+	}
+	ErrAppLongRunning = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("The %s annotation may only be set on fns", FnLongRunningAnnotation),
+	}
+	ErrFnsInvalidLongRunningTimeout = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("timeout value is out of range, long running fns must have a timeout between 0 and %d", MaxLongRunningTimeout),
+	}
+	ErrCallLongRunningSync = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Long running fns must be invoked detached, with the Fn-Invoke-Type: detached header"),
+	}
+	ErrTooManyLongRunningCalls = err{
+		code:  http.StatusServiceUnavailable,
+		error: errors.New("Too many long running calls, try later"),
+	}
+	ErrCallNotLive = ferr{
+		code:  http.StatusGatewayTimeout,
+		error: errors.New("Timed out - long running fn stopped writing to its log"),
+	}
+)
+
+// FnLongRunning are the settings of a long running fn
+type FnLongRunning struct {
+	// LivenessInterval is how long the fn may go without writing to its log before its call is failed, in seconds,
+	// DefaultLivenessInterval if it is not set
+	LivenessInterval int32 `json:"liveness_interval,omitempty"`
+}
+
+// Liveness returns the liveness interval of the fn, in seconds
+func (l *FnLongRunning) Liveness() int32 {
+	if l.LivenessInterval == 0 {
+		return DefaultLivenessInterval
+	}
+	return l.LivenessInterval
+}
+
+// LongRunning returns the settings held in the FnLongRunningAnnotation of annotations, or nil if the fn is not long
+// running
+func (a Annotations) LongRunning() (*FnLongRunning, error) {
+	raw, ok := a.Get(FnLongRunningAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var l FnLongRunning
+	if err := json.Unmarshal(raw, &l); err != nil {
+		return nil, ErrFnInvalidLongRunning
+	}
+	if l.LivenessInterval < 0 || l.LivenessInterval > MaxLivenessInterval {
+		return nil, ErrFnInvalidLongRunning
+	}
+	return &l, nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// FnMirrorAnnotation holds a JSON FnMirror object, mirroring a share of the calls of the fn to a shadow fn, such as
+// a new revision of it, to validate it under real traffic before shifting traffic to it. Mirrored calls are made
+// once the call they mirror ends, their responses are discarded and their errors are recorded.
+const FnMirrorAnnotation = "fnproject.io/fn/mirror"
+
+var (
+	ErrFnInvalidMirror = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with a fn_id and a percent of calls "+
+			"between 0 and 100", FnMirrorAnnotation),
+	}
+	ErrAppMirror = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("The %s annotation may only be set on fns", FnMirrorAnnotation),
+	}
+	// ErrFnMirrorTargetNotFound is returned when a fn mirrors its calls to a fn that does not exist
+	ErrFnMirrorTargetNotFound = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Mirrored fn not found"),
+	}
+)
+
+// FnMirror is the mirroring policy of a fn
+type FnMirror struct {
+	// FnID is the ID of the shadow fn to mirror calls to
+	FnID string `json:"fn_id"`
+	// Percent is the share of calls mirrored, eg. 0.5 mirrors one call in 200
+	Percent float64 `json:"percent"`
+}
+
+// Mirror returns the mirroring policy held in the FnMirrorAnnotation of annotations, or nil if there is none
+func (a Annotations) Mirror() (*FnMirror, error) {
+	raw, ok := a.Get(FnMirrorAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var m FnMirror
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, ErrFnInvalidMirror
+	}
+	if m.FnID == "" || m.Percent <= 0 || m.Percent > 100 {
+		return nil, ErrFnInvalidMirror
+	}
+	return &m, nil
+}
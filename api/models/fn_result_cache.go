@@ -0,0 +1,56 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// FnResultCacheAnnotation holds a JSON FnResultCache object, opting the detached calls of a fn in to being skipped
+// by runners that ran an identical call successfully within the ttl. Identical calls are to the same revision of
+// the fn, with the same method, url, content type, trigger request headers and payload, so this suits batch
+// workloads re-submitting idempotent work. Set on an app, it applies to all its fns.
+const FnResultCacheAnnotation = "fnproject.io/fn/result_cache"
+
+// MaxFnResultCacheTTL is the longest the successful calls of a fn may be remembered for, in seconds
+const MaxFnResultCacheTTL = 24 * 60 * 60
+
+var (
+	ErrFnInvalidResultCache = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with a ttl of between 1 and %d seconds",
+			FnResultCacheAnnotation, MaxFnResultCacheTTL),
+	}
+)
+
+// FnResultCache is the result caching policy of a fn
+type FnResultCache struct {
+	// TTL is how long, in seconds, a successful call is remembered for
+	TTL int `json:"ttl"`
+}
+
+// Duration returns the ttl as a time.Duration
+func (c *FnResultCache) Duration() time.Duration {
+	return time.Duration(c.TTL) * time.Second
+}
+
+// ResultCache returns the result caching policy held in the FnResultCacheAnnotation of annotations, or nil if there
+// is none
+func (a Annotations) ResultCache() (*FnResultCache, error) {
+	raw, ok := a.Get(FnResultCacheAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var c FnResultCache
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, ErrFnInvalidResultCache
+	}
+	if c.TTL <= 0 || c.TTL > MaxFnResultCacheTTL {
+		return nil, ErrFnInvalidResultCache
+	}
+	return &c, nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// FnScratchAnnotation holds a JSON boolean, giving the fn's containers credentials to the scratch space of its app
+// in the object store of the runner, where it may write outputs larger than the response limit. As annotations
+// cascade, an app may set it for all of its fns.
+const FnScratchAnnotation = "fnproject.io/fn/scratch"
+
+var (
+	ErrFnInvalidScratch = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be true or false", FnScratchAnnotation),
+	}
+	ErrScratchUnavailable = err{
+		code:  http.StatusServiceUnavailable,
+		error: errors.New("The scratch space of the app is not available on this runner"),
+	}
+)
+
+// Scratch returns whether the FnScratchAnnotation of annotations gives containers credentials to scratch space
+func (a Annotations) Scratch() (bool, error) {
+	raw, ok := a.Get(FnScratchAnnotation)
+	if !ok {
+		return false, nil
+	}
+	var scratch bool
+	if err := json.Unmarshal(raw, &scratch); err != nil {
+		return false, ErrFnInvalidScratch
+	}
+	return scratch, nil
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// FnStopAnnotation holds a JSON FnStop object, setting how the fn's hot containers are stopped. As annotations
+// cascade, an app may set it for all of its fns.
+const FnStopAnnotation = "fnproject.io/fn/stop"
+
+// MaxFnStopTimeout is the longest a container may be given to exit once asked to, in seconds
+const MaxFnStopTimeout int32 = 120
+
+// FnStopSignals are the signals a fn may ask to be stopped with
+var FnStopSignals = []string{"SIGTERM", "SIGINT", "SIGQUIT", "SIGHUP", "SIGUSR1", "SIGUSR2", "SIGWINCH"}
+
+var ErrFnInvalidStop = err{
+	code: http.StatusBadRequest,
+	error: fmt.Errorf("Invalid %s annotation, it must be an object with a timeout between 0 and %d seconds and "+
+		"optionally a signal, one of %s", FnStopAnnotation, MaxFnStopTimeout, strings.Join(FnStopSignals, ", ")),
+}
+
+// FnStop is how a hot container of a fn is stopped when it is recycled, evicted or drained. The container is
+// sent its Signal, so that it may flush buffers and close connections, and is killed if it has not exited once its
+// Timeout passes.
+type FnStop struct {
+	// Signal asks the container to exit, SIGTERM if it is empty. Runtimes that shut down gracefully on another
+	// signal, eg. SIGQUIT for nginx or SIGWINCH for apache, may set it.
+	Signal string `json:"signal,omitempty"`
+	// Timeout is how long the container is given to exit, in seconds, the runner's default if it is not set.
+	// 0 kills it straight away.
+	Timeout *int32 `json:"timeout,omitempty"`
+}
+
+// Validate checks the signal and timeout of the stop
+func (s *FnStop) Validate() error {
+	if s.Timeout != nil && (*s.Timeout < 0 || *s.Timeout > MaxFnStopTimeout) {
+		return ErrFnInvalidStop
+	}
+	if s.Signal == "" {
+		return nil
+	}
+	for _, signal := range FnStopSignals {
+		if s.Signal == signal {
+			return nil
+		}
+	}
+	return ErrFnInvalidStop
+}
+
+// Stop returns the stop held in the FnStopAnnotation of annotations, or nil if there is none
+func (a Annotations) Stop() (*FnStop, error) {
+	raw, ok := a.Get(FnStopAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var s FnStop
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, ErrFnInvalidStop
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
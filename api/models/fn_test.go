@@ -17,7 +17,9 @@ func fnReflectType() reflect.Type {
 func resourceConfigGenerator(t *testing.T) gopter.Gen {
 	fieldGens := make(map[string]gopter.Gen)
 
-	fieldGens["Memory"] = gen.UInt64()
+	fieldGens["Memory"] = gen.UInt64().Map(func(v uint64) Megabytes { return Megabytes(v) })
+	fieldGens["CPUs"] = gen.UInt64Range(0, MaxMilliCPUs).Map(func(v uint64) MilliCPUs { return MilliCPUs(v) })
+	fieldGens["TmpFsSize"] = gen.UInt64().Map(func(v uint64) Megabytes { return Megabytes(v) })
 	fieldGens["Timeout"] = gen.Int32()
 	fieldGens["IdleTimeout"] = gen.Int32()
 
@@ -31,6 +33,17 @@ func resourceConfigGenerator(t *testing.T) gopter.Gen {
 	return gen.Struct(reflect.TypeOf(resourceConfig), fieldGens)
 }
 
+func chainGenerator() gopter.Gen {
+	return gen.Struct(reflect.TypeOf(FnChain{}), map[string]gopter.Gen{
+		"OnSuccess": gen.Identifier(),
+		"OnFailure": gen.Identifier(),
+	}).Map(func(c FnChain) *FnChain { return &c })
+}
+
+func disabledGenerator() gopter.Gen {
+	return gen.Bool().Map(func(b bool) *bool { return &b })
+}
+
 func fnFieldGenerators(t *testing.T) map[string]gopter.Gen {
 	fieldGens := make(map[string]gopter.Gen)
 
@@ -41,6 +54,8 @@ func fnFieldGenerators(t *testing.T) map[string]gopter.Gen {
 	fieldGens["Config"] = configGenerator()
 	fieldGens["ResourceConfig"] = resourceConfigGenerator(t)
 	fieldGens["Annotations"] = annotationGenerator()
+	fieldGens["Chain"] = chainGenerator()
+	fieldGens["Disabled"] = disabledGenerator()
 	fieldGens["CreatedAt"] = datetimeGenerator()
 	fieldGens["UpdatedAt"] = datetimeGenerator()
 
@@ -173,6 +188,163 @@ func TestValidateFn(t *testing.T) {
 	testFn.Memory = 0
 	testCases = append(testCases, test{testFn, ErrInvalidMemory})
 
+	testFn = generateValidFn()
+	testFn.TmpFsSize = Megabytes(MaxMemory + 1)
+	testCases = append(testCases, test{testFn, ErrInvalidTmpFsSize})
+
+	testFn = generateValidFn()
+	testFn.CPUs = MaxMilliCPUs + 1
+	testCases = append(testCases, test{testFn, ErrInvalidCPUs})
+
+	for _, volume := range []string{
+		`"not an object"`,
+		`{"name":"cache/../x","path":"/cache","size_mb":10}`,
+		`{"name":"cache","path":"cache","size_mb":10}`,
+		`{"name":"cache","path":"/","size_mb":10}`,
+		`{"name":"cache","path":"/cache/","size_mb":10}`,
+		`{"name":"cache","path":"/cache"}`,
+	} {
+		testFn = generateValidFn()
+		testFn.Annotations = Annotations{}.withRawKey(FnVolumeAnnotation, volume)
+		testCases = append(testCases, test{testFn, ErrFnInvalidVolume})
+	}
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(FnVolumeAnnotation, `{"name":"model-cache","path":"/cache","size_mb":512}`)
+	testCases = append(testCases, test{testFn, nil})
+
+	for _, datasets := range []string{
+		`{"name":"bert","path":"/models"}`,
+		`[{"name":"bert/large","path":"/models"}]`,
+		`[{"name":"bert","path":"models"}]`,
+		`[{"name":"bert","path":"/models","version":".."}]`,
+		`[{"name":"bert","path":"/models"},{"name":"gpt","path":"/models"}]`,
+	} {
+		testFn = generateValidFn()
+		testFn.Annotations = Annotations{}.withRawKey(FnDatasetsAnnotation, datasets)
+		testCases = append(testCases, test{testFn, ErrFnInvalidDatasets})
+	}
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(FnDatasetsAnnotation, `[{"name":"bert","path":"/models","version":"v1.2"},{"name":"vocab","path":"/vocab"}]`)
+	testCases = append(testCases, test{testFn, nil})
+
+	for _, stop := range []string{`"10s"`, `{"timeout":-1}`, `{"timeout":121}`, `{"signal":"SIGKILL","timeout":10}`, `{"signal":"TERM"}`} {
+		testFn = generateValidFn()
+		testFn.Annotations = Annotations{}.withRawKey(FnStopAnnotation, stop)
+		testCases = append(testCases, test{testFn, ErrFnInvalidStop})
+	}
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(FnStopAnnotation, `{"signal":"SIGQUIT","timeout":10}`)
+	testCases = append(testCases, test{testFn, nil})
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(FnStopAnnotation, `{"signal":"SIGWINCH"}`)
+	testCases = append(testCases, test{testFn, nil})
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(FnScratchAnnotation, `{"enabled":true}`)
+	testCases = append(testCases, test{testFn, ErrFnInvalidScratch})
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(FnScratchAnnotation, `true`)
+	testCases = append(testCases, test{testFn, nil})
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(FnGPUsAnnotation, `{"nvidia.com/gpu":2}`)
+	testCases = append(testCases, test{testFn, nil})
+
+	for _, gpus := range []string{`2`, `{"nvidia.com/gpu":0}`, `{"nvidia.com/gpu":17}`, `{"amd.com/gpu":1}`} {
+		testFn = generateValidFn()
+		testFn.Annotations = Annotations{}.withRawKey(FnGPUsAnnotation, gpus)
+		testCases = append(testCases, test{testFn, ErrFnInvalidGPUs})
+	}
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(FnCaptureAnnotation, `{"percent":2.5,"max_size":1024,"ttl":3600,"redact":["password"]}`)
+	testCases = append(testCases, test{testFn, nil})
+
+	for _, capture := range []string{`10`, `{"percent":101}`, `{"percent":10,"max_size":32769}`, `{"percent":10,"ttl":-1}`, `{"percent":10,"sample":true}`} {
+		testFn = generateValidFn()
+		testFn.Annotations = Annotations{}.withRawKey(FnCaptureAnnotation, capture)
+		testCases = append(testCases, test{testFn, ErrFnInvalidCapture})
+	}
+
+	for _, labels := range []string{`"acme"`, `{"tenant":1}`, `{"":"acme"}`} {
+		testFn = generateValidFn()
+		testFn.Annotations = Annotations{}.withRawKey(FnDockerDaemonAnnotation, labels)
+		testCases = append(testCases, test{testFn, ErrFnInvalidDockerDaemon})
+	}
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(FnDockerDaemonAnnotation, `{"tenant":"acme","storage":"ssd"}`)
+	testCases = append(testCases, test{testFn, nil})
+
+	for _, policy := range []string{`"IfNotPresent"`, `"Always"`, `"Never"`} {
+		testFn = generateValidFn()
+		testFn.Annotations = Annotations{}.withRawKey(FnImagePullPolicyAnnotation, policy)
+		testCases = append(testCases, test{testFn, nil})
+	}
+
+	for _, policy := range []string{`"always"`, `""`, `{"policy":"Always"}`} {
+		testFn = generateValidFn()
+		testFn.Annotations = Annotations{}.withRawKey(FnImagePullPolicyAnnotation, policy)
+		testCases = append(testCases, test{testFn, ErrFnInvalidImagePullPolicy})
+	}
+
+	for _, longRunning := range []string{`true`, `{"liveness_interval":-1}`, `{"liveness_interval":3601}`} {
+		testFn = generateValidFn()
+		testFn.Annotations = Annotations{}.withRawKey(FnLongRunningAnnotation, longRunning)
+		testCases = append(testCases, test{testFn, ErrFnInvalidLongRunning})
+	}
+
+	testFn = generateValidFn()
+	testFn.Timeout = MaxTimeout + 1
+	testCases = append(testCases, test{testFn, ErrFnsInvalidTimeout})
+
+	testFn = generateValidFn()
+	testFn.Timeout = MaxLongRunningTimeout
+	testFn.Annotations = Annotations{}.withRawKey(FnLongRunningAnnotation, `{"liveness_interval":60}`)
+	testCases = append(testCases, test{testFn, nil})
+
+	testFn = generateValidFn()
+	testFn.Timeout = MaxLongRunningTimeout + 1
+	testFn.Annotations = Annotations{}.withRawKey(FnLongRunningAnnotation, `{}`)
+	testCases = append(testCases, test{testFn, ErrFnsInvalidLongRunningTimeout})
+
+	for _, resultCache := range []string{`{}`, `{"ttl":0}`, `{"ttl":86401}`, `{"ttl":60,"size":1}`} {
+		testFn = generateValidFn()
+		testFn.Annotations = Annotations{}.withRawKey(FnResultCacheAnnotation, resultCache)
+		testCases = append(testCases, test{testFn, ErrFnInvalidResultCache})
+	}
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(FnResultCacheAnnotation, `{"ttl":3600}`)
+	testCases = append(testCases, test{testFn, nil})
+
+	for _, mirror := range []string{`{"fn_id":"shadow"}`, `{"percent":10}`, `{"fn_id":"shadow","percent":101}`, `"shadow"`} {
+		testFn = generateValidFn()
+		testFn.Annotations = Annotations{}.withRawKey(FnMirrorAnnotation, mirror)
+		testCases = append(testCases, test{testFn, ErrFnInvalidMirror})
+	}
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(FnMirrorAnnotation, `{"fn_id":"shadow","percent":0.5}`)
+	testCases = append(testCases, test{testFn, nil})
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(AppPolicyAnnotation, `{"networks":["host"]}`)
+	testCases = append(testCases, test{testFn, ErrFnAppPolicy})
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(AppRunnerPoolAnnotation, `"acme"`)
+	testCases = append(testCases, test{testFn, ErrFnAppRunnerPool})
+
+	testFn = generateValidFn()
+	testFn.Annotations = Annotations{}.withRawKey(AppMaintenanceAnnotation, `{}`)
+	testCases = append(testCases, test{testFn, ErrFnAppMaintenance})
+
 	for _, testCase := range testCases {
 		got := testCase.Fn.Validate()
 
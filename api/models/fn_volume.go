@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+)
+
+// FnVolumeAnnotation holds a JSON FnVolume object, requesting a persistent scratch volume for the fn's containers
+// on each runner that supports them. As annotations cascade, an app may request a volume for all of its fns.
+const FnVolumeAnnotation = "fnproject.io/fn/volume"
+
+// MaxFnVolumeNameLength is the longest a volume name may be
+const MaxFnVolumeNameLength = 64
+
+var validFnVolumeName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+var (
+	ErrFnInvalidVolume = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with a name of up to %d letters, digits, "+
+			"'-' or '_', an absolute path other than / and a size_mb greater than 0", FnVolumeAnnotation, MaxFnVolumeNameLength),
+	}
+	ErrFnVolumeTooLarge = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Fn volume is larger than this runner allows"),
+	}
+)
+
+// FnVolume is a named directory that persists across the calls of a fn on a runner, for caches such as model
+// weights or packages. Volumes are created when first used, shared by the fns of an app that use the same name,
+// emptied when found over their size, and removed once unused for a while. Their contents may be lost at any
+// time, fns must be able to recreate them.
+type FnVolume struct {
+	// Name identifies the volume within the app
+	Name string `json:"name"`
+	// Path is where the volume is mounted in the fn's containers
+	Path string `json:"path"`
+	// SizeMB is the most the volume should hold, in megabytes. It is not enforced while containers use the volume,
+	// volumes found over it are emptied once they are not in use.
+	SizeMB uint64 `json:"size_mb"`
+}
+
+// Validate checks the name, path and size of the volume
+func (v *FnVolume) Validate() error {
+	if len(v.Name) > MaxFnVolumeNameLength || !validFnVolumeName.MatchString(v.Name) {
+		return ErrFnInvalidVolume
+	}
+	if !validMountPath(v.Path) {
+		return ErrFnInvalidVolume
+	}
+	if v.SizeMB == 0 {
+		return ErrFnInvalidVolume
+	}
+	return nil
+}
+
+// validMountPath checks that p is a clean absolute path in a container, other than /
+func validMountPath(p string) bool {
+	return path.IsAbs(p) && path.Clean(p) == p && p != "/"
+}
+
+// Volume returns the volume held in the FnVolumeAnnotation of annotations, or nil if there is none
+func (a Annotations) Volume() (*FnVolume, error) {
+	raw, ok := a.Get(FnVolumeAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var v FnVolume
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, ErrFnInvalidVolume
+	}
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fnproject/fn/api/common"
+)
+
+// Severities of image vulnerabilities, from least to most severe.
+const (
+	SeverityUnknown  = "UNKNOWN"
+	SeverityLow      = "LOW"
+	SeverityMedium   = "MEDIUM"
+	SeverityHigh     = "HIGH"
+	SeverityCritical = "CRITICAL"
+)
+
+// Severities are the severities of image vulnerabilities, from least to most
+// severe.
+var Severities = []string{SeverityUnknown, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+var (
+	ErrImageScanNotFound = err{
+		code:  http.StatusNotFound,
+		error: errors.New("Image scan not found"),
+	}
+	ErrDatastoreEmptyImageDigest = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Missing image digest"),
+	}
+)
+
+// ErrImageVulnerable is returned when the image of a fn has vulnerabilities
+// at or above the severity images are blocked at.
+func ErrImageVulnerable(image string, count int, severity string) error {
+	return err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("Image %s has %d vulnerabilities of severity %s or higher", image, count, severity),
+	}
+}
+
+// ValidSeverity reports whether severity is one of Severities.
+func ValidSeverity(severity string) bool {
+	return severityRank(severity) >= 0
+}
+
+func severityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// ImageVulnerabilities counts the vulnerabilities of an image by severity.
+type ImageVulnerabilities map[string]int
+
+// AtLeast returns the number of vulnerabilities of severity or higher.
+// Vulnerabilities of severities not in Severities count as UNKNOWN.
+func (v ImageVulnerabilities) AtLeast(severity string) int {
+	rank := severityRank(severity)
+	n := 0
+	for s, count := range v {
+		r := severityRank(strings.ToUpper(s))
+		if r < 0 {
+			r = 0
+		}
+		if r >= rank {
+			n += count
+		}
+	}
+	return n
+}
+
+// implements sql.Valuer, returning a string
+func (v ImageVulnerabilities) Value() (driver.Value, error) {
+	if len(v) < 1 {
+		return driver.Value(string("")), nil
+	}
+	var b bytes.Buffer
+	err := json.NewEncoder(&b).Encode(v)
+	return driver.Value(b.String()), err
+}
+
+// implements sql.Scanner
+func (v *ImageVulnerabilities) Scan(value interface{}) error {
+	if value == nil {
+		*v = nil
+		return nil
+	}
+	bv, err := driver.String.ConvertValue(value)
+	if err != nil {
+		return err
+	}
+	var b []byte
+	switch x := bv.(type) {
+	case []byte:
+		b = x
+	case string:
+		b = []byte(x)
+	}
+	if len(b) > 0 {
+		return json.Unmarshal(b, v)
+	}
+	*v = nil
+	return nil
+}
+
+// ImageScan is the result of scanning an image for vulnerabilities. Scans
+// are kept per digest, as the image a tag names may change.
+type ImageScan struct {
+	// Digest is the digest of the image that was scanned, eg. sha256:4f53...
+	Digest string `json:"digest" db:"digest"`
+	// Image is the image reference that was scanned.
+	Image string `json:"image" db:"image"`
+	// Vulnerabilities counts the vulnerabilities found by severity.
+	Vulnerabilities ImageVulnerabilities `json:"vulnerabilities,omitempty" db:"vulnerabilities"`
+	// ScannedAt is when the image was scanned.
+	ScannedAt common.DateTime `json:"scanned_at" db:"scanned_at"`
+}
+
+// ImageDigest returns the digest an image reference is pinned to, eg.
+// sha256:4f53... of repo@sha256:4f53..., or "" if it is not pinned.
+func ImageDigest(image string) string {
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	return ""
+}
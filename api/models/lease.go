@@ -0,0 +1,37 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fnproject/fn/api/common"
+)
+
+var (
+	ErrLeaseHeld = err{
+		code:  http.StatusConflict,
+		error: errors.New("Lease held by another holder"),
+	}
+	ErrDatastoreEmptyLeaseName = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Missing lease name"),
+	}
+	ErrDatastoreEmptyLeaseHolder = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Missing lease holder"),
+	}
+)
+
+// Lease is held by one of the servers sharing a datastore at a time, until it
+// expires unless its holder renews it. Servers elect one of them to do work
+// that only one of them may do, such as firing cron triggers, by acquiring a
+// lease. Expiry is compared to the clocks of the servers, which must not
+// drift apart by a large part of the time leases are held for.
+type Lease struct {
+	// Name is the work the lease is for.
+	Name string `json:"name" db:"name"`
+	// Holder identifies the server holding the lease.
+	Holder string `json:"holder" db:"holder"`
+	// ExpiresAt is the UTC timestamp the lease is held until.
+	ExpiresAt common.DateTime `json:"expires_at" db:"expires_at"`
+}
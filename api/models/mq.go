@@ -0,0 +1,43 @@
+package models
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/fnproject/fn/api/common"
+)
+
+// MessageQueue delivers the detached calls servers take to the servers that run them, so that detached calls outlive
+// the server taking them, and are spread over all the servers consuming the queue.
+type MessageQueue interface {
+	// Push publishes msg, keyed by the app of its call. Messages of an app are consumed in the order pushed.
+	Push(ctx context.Context, msg *CallMessage) error
+
+	// Consume hands the messages of the queue to handle, one at a time per consumer, until ctx is done. A message is
+	// acknowledged once handle returns nil. Messages handle fails, or that are handed to a consumer that dies before
+	// it returns, are delivered again.
+	Consume(ctx context.Context, handle func(ctx context.Context, msg *CallMessage) error) error
+
+	// Close releases the connections of the queue.
+	Close() error
+}
+
+// CallMessage is a detached call in a MessageQueue, as the request that made it
+type CallMessage struct {
+	// CallID is the id of the call, recorded queued when it was pushed
+	CallID    string `json:"call_id"`
+	AppID     string `json:"app_id"`
+	FnID      string `json:"fn_id"`
+	TriggerID string `json:"trigger_id,omitempty"`
+	// DeploymentID is the deployment of the fn whose image replays of calls run against
+	DeploymentID string `json:"deployment_id,omitempty"`
+
+	// Method, URL, Headers and Body are the request of the call. Headers are those servers publish, Body is the
+	// body of the request as it was made, unencrypted.
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    []byte      `json:"body,omitempty"`
+
+	CreatedAt common.DateTime `json:"created_at"`
+}
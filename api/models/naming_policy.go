@@ -0,0 +1,274 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The kinds of objects naming policies apply to
+const (
+	KindApp     = "app"
+	KindFn      = "fn"
+	KindTrigger = "trigger"
+)
+
+var (
+	errNameNotAllowed = errors.New("Name is not allowed by the naming policy")
+	errFieldImmutable = errors.New("Fields may not change outside of their change windows")
+
+	weekdays = map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+)
+
+// NamingPolicy are operator rules on the names of apps, fns and triggers, and on fields of them that may not change
+// once set, or only within change windows. It must be validated before use.
+type NamingPolicy struct {
+	Apps     *NameRule `json:"apps,omitempty"`
+	Fns      *NameRule `json:"fns,omitempty"`
+	Triggers *NameRule `json:"triggers,omitempty"`
+
+	Immutable []ImmutableField `json:"immutable,omitempty"`
+}
+
+// NameRule restricts names
+type NameRule struct {
+	// Pattern is a regular expression names must match in full, eg. "[a-z][a-z0-9-]*"
+	Pattern string `json:"pattern,omitempty"`
+	// Message describes Pattern in the errors of names that do not match it, eg. "lower case letters, digits and -"
+	Message string `json:"message,omitempty"`
+	// ReservedPrefixes are prefixes names may not start with, eg. "fn-"
+	ReservedPrefixes []string `json:"reserved_prefixes,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// ImmutableField is a field that may not change once set, or only within its change windows
+type ImmutableField struct {
+	// Kind is the kind of object of the field, app, fn or trigger
+	Kind string `json:"kind"`
+	// Field is the JSON name of the field, or config.<key> or annotations.<key> for a key, eg. "image"
+	Field string `json:"field"`
+	// Windows are when the field may change, it never may if there are none
+	Windows []ChangeWindow `json:"windows,omitempty"`
+}
+
+// ChangeWindow is a time of day, on some days of the week, changes are allowed in
+type ChangeWindow struct {
+	// Days are the days of the window, eg. ["mon", "tue"], every day if there are none
+	Days []string `json:"days,omitempty"`
+	// Start and End are the time of day the window opens and closes, eg. "09:00", the window closes the next day if
+	// End is before Start
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Location is the time zone of the window, eg. "Europe/London", UTC if empty
+	Location string `json:"location,omitempty"`
+
+	days       map[time.Weekday]bool
+	start, end time.Duration
+	location   *time.Location
+}
+
+// Validate checks the policy and prepares it for use
+func (p *NamingPolicy) Validate() error {
+	for kind, r := range map[string]*NameRule{KindApp: p.Apps, KindFn: p.Fns, KindTrigger: p.Triggers} {
+		if r == nil || r.Pattern == "" {
+			continue
+		}
+		pattern, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid %s name pattern: %v", kind, err)
+		}
+		r.pattern = pattern
+	}
+	for i := range p.Immutable {
+		f := &p.Immutable[i]
+		switch f.Kind {
+		case KindApp, KindFn, KindTrigger:
+		default:
+			return fmt.Errorf("invalid kind %q of immutable field %s, expected app, fn or trigger", f.Kind, f.Field)
+		}
+		if f.Field == "" {
+			return fmt.Errorf("no field for immutable %s field", f.Kind)
+		}
+		for j := range f.Windows {
+			if err := f.Windows[j].validate(); err != nil {
+				return fmt.Errorf("invalid change window of %s %s: %v", f.Kind, f.Field, err)
+			}
+		}
+	}
+	return nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected hh:mm", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w *ChangeWindow) validate() error {
+	var err error
+	if w.start, err = parseTimeOfDay(w.Start); err != nil {
+		return err
+	}
+	if w.end, err = parseTimeOfDay(w.End); err != nil {
+		return err
+	}
+	if w.location, err = time.LoadLocation(w.Location); err != nil {
+		return err
+	}
+	w.days = make(map[time.Weekday]bool, len(w.Days))
+	for _, d := range w.Days {
+		day, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return fmt.Errorf("invalid day %q, expected mon, tue, wed, thu, fri, sat or sun", d)
+		}
+		w.days[day] = true
+	}
+	return nil
+}
+
+// contains returns whether t is within the window
+func (w *ChangeWindow) contains(t time.Time) bool {
+	t = t.In(w.location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, w.location)
+	tod := t.Sub(midnight)
+	day := t.Weekday()
+	if w.end <= w.start && tod < w.end {
+		// the window of the day before, still open
+		day = (day + 6) % 7
+	} else if tod < w.start || (w.end > w.start && tod >= w.end) {
+		return false
+	}
+	return len(w.days) == 0 || w.days[day]
+}
+
+func (w *ChangeWindow) String() string {
+	days := "every day"
+	if len(w.Days) > 0 {
+		days = strings.Join(w.Days, ",")
+	}
+	location := w.Location
+	if location == "" {
+		location = "UTC"
+	}
+	return fmt.Sprintf("%s %s-%s %s", days, w.Start, w.End, location)
+}
+
+func (p *NamingPolicy) rule(kind string) *NameRule {
+	switch kind {
+	case KindApp:
+		return p.Apps
+	case KindFn:
+		return p.Fns
+	case KindTrigger:
+		return p.Triggers
+	}
+	return nil
+}
+
+// CheckName returns an error describing why the policy does not allow the name of an object of a kind, or nil
+func (p *NamingPolicy) CheckName(kind, name string) error {
+	r := p.rule(kind)
+	if r == nil {
+		return nil
+	}
+	for _, prefix := range r.ReservedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return NewValidationError(http.StatusBadRequest, errNameNotAllowed, FieldError{Field: "name", Code: FieldNotAllowed,
+				Message: fmt.Sprintf("%s names may not start with the reserved prefix %q", kind, prefix)})
+		}
+	}
+	if r.pattern != nil && !r.pattern.MatchString(name) {
+		expected := r.Message
+		if expected == "" {
+			expected = "the pattern " + r.Pattern
+		}
+		return NewValidationError(http.StatusBadRequest, errNameNotAllowed, FieldError{Field: "name", Code: FieldInvalid,
+			Message: fmt.Sprintf("%s names must be %s", kind, expected)})
+	}
+	return nil
+}
+
+// jsonFields returns the fields of the JSON of an object
+func jsonFields(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// fieldValue returns the value of a field of the JSON of an object, or nil if it is not set
+func fieldValue(doc map[string]interface{}, field string) interface{} {
+	if v, ok := doc[field]; ok {
+		return v
+	}
+	parts := strings.SplitN(field, ".", 2)
+	if len(parts) == 2 {
+		if m, ok := doc[parts[0]].(map[string]interface{}); ok {
+			return m[parts[1]]
+		}
+	}
+	return nil
+}
+
+// CheckChanges returns an error listing the immutable fields of objects of a kind that the change from old to
+// updated makes, at now, or nil. Fields that were not set may be set at any time.
+func (p *NamingPolicy) CheckChanges(kind string, old, updated interface{}, now time.Time) error {
+	before, err := jsonFields(old)
+	if err != nil {
+		return err
+	}
+	after, err := jsonFields(updated)
+	if err != nil {
+		return err
+	}
+
+	var fields []FieldError
+	for i := range p.Immutable {
+		f := &p.Immutable[i]
+		if f.Kind != kind {
+			continue
+		}
+		was := fieldValue(before, f.Field)
+		if was == nil || reflect.DeepEqual(was, fieldValue(after, f.Field)) {
+			continue
+		}
+
+		open := false
+		windows := make([]string, 0, len(f.Windows))
+		for j := range f.Windows {
+			if f.Windows[j].contains(now) {
+				open = true
+				break
+			}
+			windows = append(windows, f.Windows[j].String())
+		}
+		if open {
+			continue
+		}
+		msg := "may not change once set"
+		if len(windows) > 0 {
+			msg = "may only change within " + strings.Join(windows, "; ")
+		}
+		fields = append(fields, FieldError{Field: f.Field, Code: FieldImmutable, Message: msg})
+	}
+	if len(fields) > 0 {
+		return NewValidationError(http.StatusConflict, errFieldImmutable, fields...)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNamingPolicyCheckName(t *testing.T) {
+	p := &NamingPolicy{
+		Fns:  &NameRule{Pattern: "[a-z][a-z0-9-]*", Message: "lower case letters, digits and -", ReservedPrefixes: []string{"fn-"}},
+		Apps: &NameRule{ReservedPrefixes: []string{"system"}},
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, test := range []struct {
+		kind, name string
+		fields     string
+	}{
+		{KindFn, "hello-world", ""},
+		{KindFn, "Hello", "name: fn names must be lower case letters, digits and -"},
+		{KindFn, "hello world", "name: fn names must be lower case letters, digits and -"},
+		{KindFn, "fn-hello", `name: fn names may not start with the reserved prefix "fn-"`},
+		{KindApp, "Anything_Goes", ""},
+		{KindApp, "system-apps", `name: app names may not start with the reserved prefix "system"`},
+		{KindTrigger, "fn-trigger", ""},
+	} {
+		err := p.CheckName(test.kind, test.name)
+		if test.fields == "" {
+			if err != nil {
+				t.Errorf("Test %d: expected %s name %q to be allowed, got %v", i, test.kind, test.name, err)
+			}
+			continue
+		}
+		fe, ok := err.(FieldsError)
+		if !ok || fe.Code() != http.StatusBadRequest || fe.Fields() != test.fields {
+			t.Errorf("Test %d: expected %s name %q to fail with %q, got %v", i, test.kind, test.name, test.fields, err)
+		}
+	}
+
+	if err := (&NamingPolicy{Fns: &NameRule{Pattern: "("}}).Validate(); err == nil {
+		t.Error("expected an invalid pattern to be rejected")
+	}
+}
+
+func TestNamingPolicyCheckChanges(t *testing.T) {
+	p := &NamingPolicy{Immutable: []ImmutableField{
+		{Kind: KindFn, Field: "image", Windows: []ChangeWindow{{Days: []string{"mon", "tue"}, Start: "09:00", End: "17:00"}}},
+		{Kind: KindFn, Field: "config.DB_URL"},
+		{Kind: KindApp, Field: "syslog_url", Windows: []ChangeWindow{{Start: "22:00", End: "02:00", Location: "America/New_York"}}},
+	}}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	monday := time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC)
+	sunday := time.Date(2026, 10, 11, 10, 0, 0, 0, time.UTC)
+	old := &Fn{ID: "fn_id", Image: "a:1", Config: Config{"DB_URL": "db", "OTHER": "x"}}
+
+	for i, test := range []struct {
+		fn      *Fn
+		now     time.Time
+		allowed bool
+	}{
+		{&Fn{ID: "fn_id", Image: "a:2", Config: Config{"DB_URL": "db"}}, monday, true},
+		{&Fn{ID: "fn_id", Image: "a:2", Config: Config{"DB_URL": "db"}}, sunday, false},
+		{&Fn{ID: "fn_id", Image: "a:1", Config: Config{"DB_URL": "other"}}, monday, false},
+		{&Fn{ID: "fn_id", Image: "a:1", Config: Config{"DB_URL": "db", "OTHER": "y"}}, sunday, true},
+	} {
+		err := p.CheckChanges(KindFn, old, test.fn, test.now)
+		if test.allowed && err != nil {
+			t.Errorf("Test %d: expected the change to be allowed, got %v", i, err)
+		}
+		if !test.allowed && GetAPIErrorCode(err) != http.StatusConflict {
+			t.Errorf("Test %d: expected the change to be rejected, got %v", i, err)
+		}
+	}
+
+	err := p.CheckChanges(KindFn, old, &Fn{ID: "fn_id", Image: "a:2"}, sunday)
+	fe, ok := err.(FieldsError)
+	if !ok || !strings.Contains(fe.Fields(), "image: may only change within mon,tue 09:00-17:00 UTC") ||
+		!strings.Contains(fe.Fields(), "config.DB_URL: may not change once set") {
+		t.Errorf("expected both fields to be listed with their windows, got %v", err)
+	}
+
+	// fields that were not set may be set
+	if err := p.CheckChanges(KindFn, &Fn{ID: "fn_id"}, &Fn{ID: "fn_id", Config: Config{"DB_URL": "db"}}, sunday); err != nil {
+		t.Errorf("expected setting a field to be allowed, got %v", err)
+	}
+
+	// windows past midnight are open after midnight, the day after they open
+	url, other := "tcp://a:514", "tcp://b:514"
+	for now, allowed := range map[time.Time]bool{
+		time.Date(2026, 10, 12, 3, 30, 0, 0, time.UTC): true,  // 23:30 in New York
+		time.Date(2026, 10, 12, 5, 30, 0, 0, time.UTC): true,  // 01:30
+		time.Date(2026, 10, 12, 7, 0, 0, 0, time.UTC):  false, // 03:00
+		time.Date(2026, 10, 12, 20, 0, 0, 0, time.UTC): false, // 16:00
+	} {
+		err := p.CheckChanges(KindApp, &App{SyslogURL: &url}, &App{SyslogURL: &other}, now)
+		if allowed != (err == nil) {
+			t.Errorf("%v: expected the change to be allowed %v, got %v", now, allowed, err)
+		}
+	}
+}
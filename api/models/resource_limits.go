@@ -0,0 +1,78 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ResourceLimits are operator configured ceilings on the resources that a fn
+// may request, on top of the hard maximums (MaxMemory, MaxTimeout, etc.).
+// Zero values impose no additional ceiling. Fns cannot request a disk size,
+// every container is capped by the agent's FN_MAX_FS_SIZE_MB instead.
+type ResourceLimits struct {
+	Memory      Megabytes `json:"memory,omitempty"`
+	CPUs        MilliCPUs `json:"cpus,omitempty"`
+	TmpFsSize   Megabytes `json:"tmpfs_size,omitempty"`
+	Timeout     int32     `json:"timeout,omitempty"`
+	IdleTimeout int32     `json:"idle_timeout,omitempty"`
+}
+
+// IsZero returns whether no limits are set
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
+// Override returns a copy of l with any non-zero limits in o replacing those in l
+func (l ResourceLimits) Override(o ResourceLimits) ResourceLimits {
+	if o.Memory != 0 {
+		l.Memory = o.Memory
+	}
+	if o.CPUs != 0 {
+		l.CPUs = o.CPUs
+	}
+	if o.TmpFsSize != 0 {
+		l.TmpFsSize = o.TmpFsSize
+	}
+	if o.Timeout != 0 {
+		l.Timeout = o.Timeout
+	}
+	if o.IdleTimeout != 0 {
+		l.IdleTimeout = o.IdleTimeout
+	}
+	return l
+}
+
+// Check returns an APIError describing the first resource in rc that exceeds
+// the limits, or nil. Fns with no CPU quota set are within a CPU limit, they
+// are run with the limit as their quota, see Apply.
+func (l ResourceLimits) Check(rc *ResourceConfig) error {
+	if l.Memory != 0 && rc.Memory > l.Memory {
+		return exceedsLimit("memory", rc.Memory.String(), l.Memory.String())
+	}
+	if l.CPUs != 0 && rc.CPUs > l.CPUs {
+		return exceedsLimit("cpus", rc.CPUs.String(), l.CPUs.String())
+	}
+	if l.TmpFsSize != 0 && rc.TmpFsSize > l.TmpFsSize {
+		return exceedsLimit("tmpfs_size", rc.TmpFsSize.String(), l.TmpFsSize.String())
+	}
+	if l.Timeout != 0 && rc.Timeout > l.Timeout {
+		return exceedsLimit("timeout", fmt.Sprint(rc.Timeout), fmt.Sprint(l.Timeout))
+	}
+	if l.IdleTimeout != 0 && rc.IdleTimeout > l.IdleTimeout {
+		return exceedsLimit("idle_timeout", fmt.Sprint(rc.IdleTimeout), fmt.Sprint(l.IdleTimeout))
+	}
+	return nil
+}
+
+// Apply returns rc with the CPU limit as its quota if it has none, as fns
+// without one are otherwise allowed to use every CPU on the host.
+func (l ResourceLimits) Apply(rc ResourceConfig) ResourceConfig {
+	if l.CPUs != 0 && rc.CPUs == 0 {
+		rc.CPUs = l.CPUs
+	}
+	return rc
+}
+
+func exceedsLimit(field, val, limit string) error {
+	return NewAPIError(http.StatusBadRequest, fmt.Errorf("%s value %s exceeds the limit of %s for this fn", field, val, limit))
+}
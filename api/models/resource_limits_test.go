@@ -0,0 +1,47 @@
+package models
+
+import "testing"
+
+func TestResourceLimitsCheck(t *testing.T) {
+	limits := ResourceLimits{Memory: 512, Timeout: 60}
+	override := limits.Override(ResourceLimits{Memory: 1024, CPUs: 2000})
+
+	for i, test := range []struct {
+		limits ResourceLimits
+		rc     ResourceConfig
+		valid  bool
+	}{
+		{ResourceLimits{}, ResourceConfig{Memory: Megabytes(MaxMemory), Timeout: MaxTimeout}, true},
+		{limits, ResourceConfig{Memory: 512, Timeout: 60}, true},
+		{limits, ResourceConfig{Memory: 513, Timeout: 60}, false},
+		{limits, ResourceConfig{Memory: 128, Timeout: 61}, false},
+		{override, ResourceConfig{Memory: 1024, CPUs: 1000, Timeout: 60}, true},
+		{override, ResourceConfig{Memory: 1024, CPUs: 2001, Timeout: 60}, false},
+		// fns without a cpu quota are run with the cpu limit
+		{override, ResourceConfig{Memory: 128, Timeout: 30}, true},
+		{ResourceLimits{TmpFsSize: 64}, ResourceConfig{TmpFsSize: 65}, false},
+		{ResourceLimits{IdleTimeout: 10}, ResourceConfig{IdleTimeout: 11}, false},
+	} {
+		err := test.limits.Check(&test.rc)
+		if test.valid && err != nil {
+			t.Errorf("Test %d: expected %+v to be within %+v, got %v", i, test.rc, test.limits, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Test %d: expected %+v to exceed %+v", i, test.rc, test.limits)
+		}
+		if err != nil && !IsAPIError(err) {
+			t.Errorf("Test %d: expected an APIError, got %v", i, err)
+		}
+	}
+
+	if rc := override.Apply(ResourceConfig{Memory: 128}); rc.CPUs != 2000 || rc.Memory != 128 {
+		t.Errorf("expected a fn without a cpu quota to get the cpu limit, got %+v", rc)
+	}
+	if rc := override.Apply(ResourceConfig{CPUs: 500}); rc.CPUs != 500 {
+		t.Errorf("expected a fn with a cpu quota to keep it, got %+v", rc)
+	}
+
+	if override.Timeout != 60 {
+		t.Errorf("expected override to keep the timeout limit, got %d", override.Timeout)
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// AppRunnerPoolAnnotation holds the JSON string name of the tenant pool of runners that the calls of all fns of an
+// app are placed on, in hybrid deployments. The calls of apps without a pool are placed on the runners without a
+// pool, or in a pool that is not exclusive.
+const AppRunnerPoolAnnotation = "fnproject.io/app/runner_pool"
+
+var (
+	ErrAppInvalidRunnerPool = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be a pool name of letters, digits, '-' and '_'", AppRunnerPoolAnnotation),
+	}
+	ErrFnAppRunnerPool = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("The %s annotation may only be set on apps", AppRunnerPoolAnnotation),
+	}
+)
+
+var runnerPoolName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidRunnerPool returns whether name is a valid runner pool name
+func ValidRunnerPool(name string) bool {
+	return runnerPoolName.MatchString(name)
+}
+
+// RunnerPool returns the runner pool held in the AppRunnerPoolAnnotation of annotations, or "" if there is none
+func (a Annotations) RunnerPool() (string, error) {
+	raw, ok := a.Get(AppRunnerPoolAnnotation)
+	if !ok {
+		return "", nil
+	}
+	var pool string
+	if err := json.Unmarshal(raw, &pool); err != nil || !ValidRunnerPool(pool) {
+		return "", ErrAppInvalidRunnerPool
+	}
+	return pool, nil
+}
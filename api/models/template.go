@@ -0,0 +1,97 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/fnproject/fn/api/common"
+)
+
+var (
+	ErrTemplateNotFound = err{
+		code:  http.StatusNotFound,
+		error: errors.New("Template not found"),
+	}
+	ErrTemplateNoApp = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Template has no app to create apps from"),
+	}
+	ErrTemplateNoFn = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Template has no fn to create fns from"),
+	}
+)
+
+// Template is an operator configured starting point for apps and fns, eg. the memory, logging and annotations that
+// functions of a team are expected to run with. Apps and fns created from a template start with its values, which the
+// request creating them overrides as an update of them would.
+type Template struct {
+	// Name is the name the template is referred to by.
+	Name string `json:"name"`
+	// Description tells users what the template is for.
+	Description string `json:"description,omitempty"`
+	// App is the app apps created from the template start as, if any. Its name is ignored.
+	App *App `json:"app,omitempty"`
+	// Fn is the fn fns created from the template start as, if any. Its name and app are ignored.
+	Fn *Fn `json:"fn,omitempty"`
+}
+
+// Validate checks a template is named and has an app or fn
+func (t *Template) Validate() error {
+	if t.Name == "" {
+		return errors.New("template has no name")
+	}
+	if t.App == nil && t.Fn == nil {
+		return fmt.Errorf("template %s has neither an app nor a fn", t.Name)
+	}
+	return nil
+}
+
+// NewApp returns the app of the template, overridden by overrides, named by them
+func (t *Template) NewApp(overrides *App) (*App, error) {
+	if t.App == nil {
+		return nil, ErrTemplateNoApp
+	}
+	return CloneApp(t.App, overrides), nil
+}
+
+// NewFn returns the fn of the template, overridden by overrides, named and in the app of them
+func (t *Template) NewFn(overrides *Fn) (*Fn, error) {
+	if t.Fn == nil {
+		return nil, ErrTemplateNoFn
+	}
+	fn := CloneFn(t.Fn, overrides)
+	fn.AppID = overrides.AppID
+	return fn, nil
+}
+
+// TemplateList is the list of the templates of a server
+type TemplateList struct {
+	Items []*Template `json:"items"`
+}
+
+// CloneApp returns a new app with the config, annotations and syslog url of app, overridden by overrides as an update
+// of app would be, and named by them
+func CloneApp(app, overrides *App) *App {
+	clone := app.Clone()
+	clone.Update(overrides)
+	clone.ID = ""
+	clone.Name = overrides.Name
+	clone.CreatedAt, clone.UpdatedAt = common.DateTime{}, common.DateTime{}
+	return clone
+}
+
+// CloneFn returns a new fn with the image, resources, config, annotations and chain of fn, overridden by overrides
+// as an update of fn would be, and named by them. It is in the app of fn, unless overrides name another.
+func CloneFn(fn, overrides *Fn) *Fn {
+	clone := fn.Clone()
+	clone.Update(overrides)
+	clone.ID = ""
+	clone.Name = overrides.Name
+	if overrides.AppID != "" {
+		clone.AppID = overrides.AppID
+	}
+	clone.CreatedAt, clone.UpdatedAt = common.DateTime{}, common.DateTime{}
+	return clone
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -14,6 +15,10 @@ import (
 // TriggerHTTPEndpointAnnotation is the annotation that exposes the HTTP trigger endpoint For want of a better place to put this it's here
 const TriggerHTTPEndpointAnnotation = "fnproject.io/trigger/httpEndpoint"
 
+// TriggerConfigAnnotation holds a JSON object of config values that are applied on top of the app and fn config for
+// calls made via the trigger
+const TriggerConfigAnnotation = "fnproject.io/trigger/config"
+
 // Trigger represents a binding between a Function and an external event source
 type Trigger struct {
 	ID          string          `json:"id" db:"id"`
@@ -25,6 +30,8 @@ type Trigger struct {
 	Type        string          `json:"type" db:"type"`
 	Source      string          `json:"source" db:"source"`
 	Annotations Annotations     `json:"annotations,omitempty" db:"annotations"`
+	// Disabled triggers are not served, until they are enabled again by setting it to false
+	Disabled *bool `json:"disabled,omitempty" db:"disabled"`
 }
 
 // Equals compares two triggers for semantic equality  it ignores timestamp fields but includes annotations
@@ -38,6 +45,7 @@ func (t *Trigger) Equals(t2 *Trigger) bool {
 	eq = eq && t.Type == t2.Type
 	eq = eq && t.Source == t2.Source
 	eq = eq && t.Annotations.Equals(t2.Annotations)
+	eq = eq && t.IsDisabled() == t2.IsDisabled()
 
 	return eq
 }
@@ -53,6 +61,7 @@ func (t *Trigger) EqualsWithAnnotationSubset(t2 *Trigger) bool {
 	eq = eq && t.Type == t2.Type
 	eq = eq && t.Source == t2.Source
 	eq = eq && t.Annotations.Subset(t2.Annotations)
+	eq = eq && t.IsDisabled() == t2.IsDisabled()
 
 	return eq
 }
@@ -60,7 +69,7 @@ func (t *Trigger) EqualsWithAnnotationSubset(t2 *Trigger) bool {
 //TriggerTypeHTTP represents an HTTP trigger
 const TriggerTypeHTTP = "http"
 
-var triggerTypes = []string{TriggerTypeHTTP}
+var triggerTypes = []string{TriggerTypeHTTP, TriggerTypeCron}
 
 //ValidTriggerTypes lists the supported trigger types in this service
 func ValidTriggerTypes() []string {
@@ -136,6 +145,14 @@ var (
 	ErrTriggerSourceExists = err{
 		code:  http.StatusConflict,
 		error: errors.New("Trigger with the same type and source exists on this app")}
+	//ErrTriggerInvalidConfig - the trigger config annotation is not an object of string values
+	ErrTriggerInvalidConfig = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with string values", TriggerConfigAnnotation)}
+	//ErrTriggerDisabled - the trigger is disabled
+	ErrTriggerDisabled = err{
+		code:  http.StatusServiceUnavailable,
+		error: errors.New("Trigger is disabled")}
 )
 
 //Validate checks that trigger has valid data for inserting into a store
@@ -160,7 +177,11 @@ func (t *Trigger) Validate() error {
 		return ErrTriggerMissingSource
 	}
 
-	if !strings.HasPrefix(t.Source, "/") {
+	if t.Type == TriggerTypeCron {
+		if _, err := ParseCronSchedule(t.Source); err != nil {
+			return err
+		}
+	} else if !strings.HasPrefix(t.Source, "/") {
 		return ErrTriggerMissingSourcePrefix
 	}
 
@@ -169,9 +190,46 @@ func (t *Trigger) Validate() error {
 		return err
 	}
 
+	if _, err := t.Config(); err != nil {
+		return err
+	}
+
+	if _, err := t.Transform(); err != nil {
+		return err
+	}
+
+	if _, err := t.Cache(); err != nil {
+		return err
+	}
+
+	if _, err := t.Runs(); err != nil {
+		return err
+	}
+
+	if _, err := t.Headers(); err != nil {
+		return err
+	}
+
+	if _, err := t.Cron(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// Config returns the trigger specific config held in the TriggerConfigAnnotation, or nil if there is none
+func (t *Trigger) Config() (Config, error) {
+	v, ok := t.Annotations.Get(TriggerConfigAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var c Config
+	if err := json.Unmarshal(v, &c); err != nil {
+		return nil, ErrTriggerInvalidConfig
+	}
+	return c, nil
+}
+
 func (t *Trigger) ValidateName() error {
 	if t.Name == "" {
 		return ErrTriggerMissingName
@@ -195,6 +253,10 @@ func (t *Trigger) Clone() *Trigger {
 	clone := new(Trigger)
 	*clone = *t // shallow copy
 	// annotations are immutable via their interface so can be shallow copied
+	if t.Disabled != nil {
+		disabled := *t.Disabled
+		clone.Disabled = &disabled
+	}
 	return clone
 }
 
@@ -220,11 +282,21 @@ func (t *Trigger) Update(patch *Trigger) {
 
 	t.Annotations = t.Annotations.MergeChange(patch.Annotations)
 
+	if patch.Disabled != nil {
+		disabled := *patch.Disabled
+		t.Disabled = &disabled
+	}
+
 	if !t.Equals(original) {
 		t.UpdatedAt = common.DateTime(time.Now())
 	}
 }
 
+// IsDisabled returns whether t is disabled
+func (t *Trigger) IsDisabled() bool {
+	return t.Disabled != nil && *t.Disabled
+}
+
 //TriggerFilter is a search criteria on triggers
 type TriggerFilter struct {
 	//AppID searches for triggers in APP - mandatory
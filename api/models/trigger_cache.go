@@ -0,0 +1,67 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// TriggerCacheAnnotation holds a JSON TriggerCache object, opting an http trigger in to having the responses to its
+// GET and HEAD requests cached by the server
+const TriggerCacheAnnotation = "fnproject.io/trigger/cache"
+
+// MaxTriggerCacheTTL is the longest a trigger may have its responses cached for, in seconds
+const MaxTriggerCacheTTL = 24 * 60 * 60
+
+var (
+	//ErrTriggerInvalidCache - the trigger cache annotation is not a valid TriggerCache
+	ErrTriggerInvalidCache = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with a ttl of between 1 and %d seconds "+
+			"and a list of valid vary header names", TriggerCacheAnnotation, MaxTriggerCacheTTL)}
+)
+
+// TriggerCache is the response caching policy of an http trigger. Responses are cached by method, path and query,
+// and the values of the vary headers.
+type TriggerCache struct {
+	// TTL is how long, in seconds, a response is cached for
+	TTL int `json:"ttl"`
+	// Vary are the request headers that responses depend on, eg. Accept
+	Vary []string `json:"vary,omitempty"`
+}
+
+// Validate checks that the ttl is in range and the vary headers are valid header names
+func (c *TriggerCache) Validate() error {
+	if c.TTL <= 0 || c.TTL > MaxTriggerCacheTTL {
+		return ErrTriggerInvalidCache
+	}
+	if !validHeaders(c.Vary, nil) {
+		return ErrTriggerInvalidCache
+	}
+	return nil
+}
+
+// Duration returns the ttl as a time.Duration
+func (c *TriggerCache) Duration() time.Duration {
+	return time.Duration(c.TTL) * time.Second
+}
+
+// Cache returns the trigger's caching policy held in the TriggerCacheAnnotation, or nil if there is none
+func (t *Trigger) Cache() (*TriggerCache, error) {
+	v, ok := t.Annotations.Get(TriggerCacheAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var c TriggerCache
+	dec := json.NewDecoder(bytes.NewReader(v))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, ErrTriggerInvalidCache
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,248 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TriggerTypeCron represents a trigger fired on a schedule, its source is a cron expression
+const TriggerTypeCron = "cron"
+
+// TriggerCronAnnotation holds a JSON TriggerCron object, the options of a cron trigger
+const TriggerCronAnnotation = "fnproject.io/trigger/cron"
+
+// MaxTriggerCronJitter is the most a cron trigger may delay its firings by, in seconds
+const MaxTriggerCronJitter = 60 * 60
+
+// Misfire policies of cron triggers, for the firings missed while no server was scheduling them
+const (
+	// CronMisfireSkip drops missed firings, the trigger fires at its next scheduled time
+	CronMisfireSkip = "skip"
+	// CronMisfireFireOnce fires once as soon as missed firings are found, however many were missed
+	CronMisfireFireOnce = "fire_once"
+)
+
+var (
+	//ErrTriggerInvalidCronSchedule - the source of a cron trigger is not a cron expression
+	ErrTriggerInvalidCronSchedule = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid cron trigger source, it must be a cron expression of minute, hour, day of month, " +
+			"month and day of week fields, eg. */5 * * * *, or one of @yearly, @monthly, @weekly, @daily or @hourly")}
+	//ErrTriggerInvalidCron - the trigger cron annotation is not a valid TriggerCron
+	ErrTriggerInvalidCron = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with a jitter of between 0 and %d seconds, "+
+			"a misfire policy of %s or %s, a valid timezone and a JSON payload", TriggerCronAnnotation, MaxTriggerCronJitter,
+			CronMisfireSkip, CronMisfireFireOnce)}
+)
+
+// TriggerCron are the options of a cron trigger
+type TriggerCron struct {
+	// Jitter is up to how many seconds each firing is delayed by, at random, so that fns scheduled at the same time
+	// do not all start at once
+	Jitter int `json:"jitter,omitempty"`
+	// Misfire is what is done about firings missed while no server was scheduling, CronMisfireSkip by default
+	Misfire string `json:"misfire,omitempty"`
+	// Timezone is the IANA timezone the schedule is in, eg. Europe/London, UTC by default
+	Timezone string `json:"timezone,omitempty"`
+	// Payload is the JSON body the fn is called with
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Validate checks that the jitter is in range, and the misfire policy and timezone are valid
+func (c *TriggerCron) Validate() error {
+	if c.Jitter < 0 || c.Jitter > MaxTriggerCronJitter {
+		return ErrTriggerInvalidCron
+	}
+	switch c.Misfire {
+	case "", CronMisfireSkip, CronMisfireFireOnce:
+	default:
+		return ErrTriggerInvalidCron
+	}
+	if _, err := c.Location(); err != nil {
+		return ErrTriggerInvalidCron
+	}
+	return nil
+}
+
+// Location returns the timezone of the schedule
+func (c *TriggerCron) Location() (*time.Location, error) {
+	if c == nil || c.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+// Cron returns the options of the trigger held in the TriggerCronAnnotation, or nil if there are none
+func (t *Trigger) Cron() (*TriggerCron, error) {
+	v, ok := t.Annotations.Get(TriggerCronAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var c TriggerCron
+	dec := json.NewDecoder(bytes.NewReader(v))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, ErrTriggerInvalidCron
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CronSchedule is a parsed cron expression
+type CronSchedule struct {
+	// the values of each field that match, as bit sets
+	minute, hour, dom, month, dow uint64
+	// restricted day of month and day of week fields match days either of them match, as in cron
+	domStar, dowStar bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonths = []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronDays   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// ParseCronSchedule parses a cron expression of minute, hour, day of month, month and day of week fields, each a
+// list of values, ranges or *, with optional steps, or one of the @ descriptors. Months and days of the week may be
+// given by their first three letters, and Sunday is either 0 or 7.
+func ParseCronSchedule(spec string) (*CronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, ErrTriggerInvalidCronSchedule
+	}
+
+	var s CronSchedule
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12, cronMonths); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7, cronDays); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	return &s, nil
+}
+
+// parseCronField returns the bit set of the values of field, which are between min and max
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, ErrTriggerInvalidCronSchedule
+			}
+			rng, step = part[:i], n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = cronValue(bounds[0], names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = cronValue(bounds[1], names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// a step from a single value runs to the end of the range, eg. 5/15 in minutes
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, ErrTriggerInvalidCronSchedule
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, names []string) (int, error) {
+	for i, name := range names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, ErrTriggerInvalidCronSchedule
+	}
+	return v, nil
+}
+
+// Next returns the first time after t that the schedule fires at, in the location of t, or the zero time if it
+// never does, eg. for the 30th of February
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// a schedule that does not fire within 5 years, leap days included, never fires
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TriggerHeadersAnnotation holds a JSON HeaderPolicy object, the headers an http trigger passes to its fn and back.
+// It narrows the header policy of the server, headers must pass both.
+const TriggerHeadersAnnotation = "fnproject.io/trigger/headers"
+
+var (
+	//ErrTriggerInvalidHeaders - the trigger headers annotation is not a valid HeaderPolicy
+	ErrTriggerInvalidHeaders = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with an optional request and response, each "+
+			"with lists of allow and deny header names", TriggerHeadersAnnotation)}
+)
+
+// HeaderPolicy is which request headers an http trigger passes to its fn, and which response headers of the fn it
+// passes back
+type HeaderPolicy struct {
+	Request  *HeaderFilter `json:"request,omitempty"`
+	Response *HeaderFilter `json:"response,omitempty"`
+}
+
+// HeaderFilter filters headers by name. A name ending with * matches all the headers it prefixes, eg. Fn-*.
+type HeaderFilter struct {
+	// Allow are the headers passed, all of them if empty
+	Allow []string `json:"allow,omitempty"`
+	// Deny are the headers never passed, even if allowed
+	Deny []string `json:"deny,omitempty"`
+}
+
+// Validate checks that the names of the filters are valid header names
+func (p *HeaderPolicy) Validate() error {
+	for _, f := range []*HeaderFilter{p.Request, p.Response} {
+		if f != nil && (!validHeaders(f.Allow, nil) || !validHeaders(f.Deny, nil)) {
+			return ErrTriggerInvalidHeaders
+		}
+	}
+	return nil
+}
+
+// Passes returns whether the header name passes the filter, a nil filter passes all headers
+func (f *HeaderFilter) Passes(name string) bool {
+	if f == nil {
+		return true
+	}
+	if matchHeader(f.Deny, name) {
+		return false
+	}
+	return len(f.Allow) == 0 || matchHeader(f.Allow, name)
+}
+
+func matchHeader(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "*") {
+			prefix := p[:len(p)-1]
+			if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+				return true
+			}
+		} else if strings.EqualFold(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Headers returns the trigger's header policy held in the TriggerHeadersAnnotation, or nil if there is none
+func (t *Trigger) Headers() (*HeaderPolicy, error) {
+	v, ok := t.Annotations.Get(TriggerHeadersAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var p HeaderPolicy
+	dec := json.NewDecoder(bytes.NewReader(v))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, ErrTriggerInvalidHeaders
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
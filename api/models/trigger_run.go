@@ -0,0 +1,206 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+)
+
+// MaxTriggerRunPayload is the largest request body recorded with a trigger
+// run, runs of larger requests cannot be re-run.
+const MaxTriggerRunPayload = 32 * 1024
+
+// TriggerRunsAnnotation holds a JSON TriggerRuns object, opting a trigger in
+// to having its firings recorded as runs, with their payloads, so that they
+// can be listed and re-run. The automatic firings of cron triggers are
+// recorded whether or not it is set, with the default policy if it is not.
+const TriggerRunsAnnotation = "fnproject.io/trigger/runs"
+
+// DefaultTriggerRunTTL is how long runs are kept for, in seconds, if their
+// trigger does not say
+const DefaultTriggerRunTTL = 7 * 24 * 60 * 60
+
+// MaxTriggerRunTTL is the longest runs may be kept for, in seconds
+const MaxTriggerRunTTL = 30 * 24 * 60 * 60
+
+var (
+	ErrTriggerRunNotFound = err{
+		code:  http.StatusNotFound,
+		error: errors.New("Trigger run not found"),
+	}
+	ErrTriggerRunNotReplayable = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("Trigger run cannot be re-run, its payload was larger than %d bytes", MaxTriggerRunPayload),
+	}
+	ErrTriggerRunsUnsupported = err{
+		code:  http.StatusNotImplemented,
+		error: errors.New("Re-running triggers is not supported on this server"),
+	}
+	ErrTriggerRunsNotRecorded = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("Runs of this trigger are not recorded, it must have a %s annotation to be re-run", TriggerRunsAnnotation),
+	}
+	ErrTriggerRunPayloadUnavailable = err{
+		code:  http.StatusServiceUnavailable,
+		error: errors.New("Trigger run payload could not be decrypted"),
+	}
+	//ErrTriggerInvalidRuns - the trigger runs annotation is not a valid TriggerRuns
+	ErrTriggerInvalidRuns = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with a ttl of at most %d seconds "+
+			"and a list of the JSON fields to redact", TriggerRunsAnnotation, MaxTriggerRunTTL)}
+	ErrDatastoreEmptyTriggerRunID = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Missing Trigger run ID"),
+	}
+)
+
+// TriggerRun is the record of a trigger firing, and the call it made. Runs
+// start running and end in one of the succeeded, failed or cancelled call
+// states.
+type TriggerRun struct {
+	// ID is the id of the call the trigger made, as returned in Fn-Call-Id.
+	ID string `json:"id" db:"id"`
+	// TriggerID is the trigger that fired.
+	TriggerID string `json:"trigger_id" db:"trigger_id"`
+	// AppID is the app of the trigger.
+	AppID string `json:"app_id" db:"app_id"`
+	// FnID is the fn that was called.
+	FnID string `json:"fn_id" db:"fn_id"`
+	// Status is one of the CallState values.
+	Status string `json:"status" db:"status"`
+	// Error is why the call failed, if it did.
+	Error string `json:"error,omitempty" db:"error"`
+	// Method is the method of the request that fired the trigger.
+	Method string `json:"method" db:"method"`
+	// URL is the url of the request that fired the trigger.
+	URL string `json:"url" db:"url"`
+	// ContentType is the content type of the payload. Other request headers
+	// are not recorded, as they may carry credentials.
+	ContentType string `json:"content_type,omitempty" db:"content_type"`
+	// Payload is the body of the request, if it was no larger than
+	// MaxTriggerRunPayload, with the fields its trigger redacts redacted.
+	// It is left out of lists of runs.
+	Payload TriggerRunPayload `json:"payload,omitempty" db:"payload"`
+	// PayloadKey is the id and wrapped data key of the key the payload is
+	// encrypted with, if it is encrypted.
+	PayloadKey string `json:"-" db:"payload_key"`
+	// Replayable is whether the payload was recorded, so that the run can be
+	// fired again.
+	Replayable bool `json:"replayable" db:"replayable"`
+	// CreatedAt is the UTC timestamp when the trigger fired.
+	CreatedAt common.DateTime `json:"created_at,omitempty" db:"created_at"`
+	// CompletedAt is the UTC timestamp when the call ended.
+	CompletedAt common.DateTime `json:"completed_at,omitempty" db:"completed_at"`
+	// ExpiresAt is when the run is removed.
+	ExpiresAt common.DateTime `json:"expires_at,omitempty" db:"expires_at"`
+}
+
+// TriggerRuns is the run recording policy of a trigger
+type TriggerRuns struct {
+	// TTL is how long, in seconds, runs are kept for, DefaultTriggerRunTTL if it is not set
+	TTL int `json:"ttl,omitempty"`
+	// Redact are the fields of JSON payloads whose values are redacted before they are recorded. Payloads that are
+	// not JSON objects are not recorded if there are fields to redact.
+	Redact []string `json:"redact,omitempty"`
+}
+
+// Validate checks that the ttl is in range and the redacted fields are named
+func (r *TriggerRuns) Validate() error {
+	if r.TTL < 0 || r.TTL > MaxTriggerRunTTL {
+		return ErrTriggerInvalidRuns
+	}
+	for _, field := range r.Redact {
+		if field == "" {
+			return ErrTriggerInvalidRuns
+		}
+	}
+	return nil
+}
+
+// Duration returns how long runs are kept for as a time.Duration
+func (r *TriggerRuns) Duration() time.Duration {
+	if r.TTL == 0 {
+		return DefaultTriggerRunTTL * time.Second
+	}
+	return time.Duration(r.TTL) * time.Second
+}
+
+// Runs returns the trigger's run recording policy held in the TriggerRunsAnnotation, the default policy for cron
+// triggers without one, or nil if its runs are not recorded
+func (t *Trigger) Runs() (*TriggerRuns, error) {
+	v, ok := t.Annotations.Get(TriggerRunsAnnotation)
+	if !ok {
+		if t.Type == TriggerTypeCron {
+			return &TriggerRuns{}, nil
+		}
+		return nil, nil
+	}
+	var r TriggerRuns
+	dec := json.NewDecoder(bytes.NewReader(v))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		return nil, ErrTriggerInvalidRuns
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// TriggerRunPayload is stored base64 encoded, as payloads need not be text
+type TriggerRunPayload []byte
+
+// implements sql.Valuer, returning a string
+func (p TriggerRunPayload) Value() (driver.Value, error) {
+	return base64.StdEncoding.EncodeToString(p), nil
+}
+
+// implements sql.Scanner
+func (p *TriggerRunPayload) Scan(value interface{}) error {
+	if value == nil {
+		*p = nil
+		return nil
+	}
+	bv, err := driver.String.ConvertValue(value)
+	if err != nil {
+		return fmt.Errorf("payload invalid db format: %T %T value, err: %v", value, bv, err)
+	}
+	var s string
+	switch x := bv.(type) {
+	case []byte:
+		s = string(x)
+	case string:
+		s = x
+	default:
+		return fmt.Errorf("payload invalid db format: %T %T value", value, bv)
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		b = nil
+	}
+	*p = b
+	return nil
+}
+
+type TriggerRunFilter struct {
+	TriggerID string // this is exact match
+	Status    string // this is exact match
+	Cursor    string
+	PerPage   int
+}
+
+type TriggerRunList struct {
+	NextCursor string        `json:"next_cursor,omitempty"`
+	Items      []*TriggerRun `json:"items"`
+}
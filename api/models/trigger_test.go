@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/leanovate/gopter"
 	"github.com/leanovate/gopter/gen"
@@ -84,6 +85,7 @@ func triggerFieldGenerators(t *testing.T) map[string]gopter.Gen {
 	fieldGens["Type"] = gen.AlphaString()
 	fieldGens["Source"] = gen.AlphaString()
 	fieldGens["Annotations"] = annotationGenerator()
+	fieldGens["Disabled"] = disabledGenerator()
 
 	triggerFieldCount := triggerReflectType().NumField()
 
@@ -215,6 +217,99 @@ func TestValidateTrigger(t *testing.T) {
 	testCases =
 		append(testCases, test{testTrigger, ErrTriggerMissingSourcePrefix})
 
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerConfigAnnotation, []string{"not", "an", "object"})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidConfig})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerConfigAnnotation, map[string]string{"DB_HOST": "db"})
+	testCases = append(testCases, test{testTrigger, nil})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerTransformAnnotation, map[string]interface{}{"request": map[string]interface{}{"base64": true}})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidTransform})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerTransformAnnotation, map[string]interface{}{"request": map[string]interface{}{"set_headers": map[string]string{"Bad Header": "v"}}})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidTransform})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerTransformAnnotation, map[string]interface{}{"response": map[string]interface{}{"content_type": "text/"}})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidTransform})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerTransformAnnotation, map[string]interface{}{
+		"request":  map[string]interface{}{"strip_headers": []string{"Cookie"}, "query": map[string]string{"v": "2"}, "base64_body": true},
+		"response": map[string]interface{}{"set_headers": map[string]string{"Cache-Control": "no-cache"}, "content_type": "image/png", "base64_body": true},
+	})
+	testCases = append(testCases, test{testTrigger, nil})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerCacheAnnotation, map[string]interface{}{"ttl": 0})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidCache})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerCacheAnnotation, map[string]interface{}{"ttl": 60, "vary": []string{"Not A Header"}})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidCache})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerCacheAnnotation, map[string]interface{}{"ttl": 60, "vary": []string{"Accept"}})
+	testCases = append(testCases, test{testTrigger, nil})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerRunsAnnotation, map[string]interface{}{"ttl": MaxTriggerRunTTL + 1})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidRuns})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerRunsAnnotation, map[string]interface{}{"redact": []string{""}})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidRuns})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerRunsAnnotation, map[string]interface{}{"keep": true})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidRuns})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerRunsAnnotation, map[string]interface{}{"ttl": 3600, "redact": []string{"password"}})
+	testCases = append(testCases, test{testTrigger, nil})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerHeadersAnnotation, map[string]interface{}{"request": map[string]interface{}{"deny": []string{"Not A Header"}}})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidHeaders})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerHeadersAnnotation, map[string]interface{}{"request": map[string]interface{}{"strip": []string{"Cookie"}}})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidHeaders})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerHeadersAnnotation, map[string]interface{}{
+		"request":  map[string]interface{}{"allow": []string{"Accept", "X-Acme-*"}, "deny": []string{"Cookie"}},
+		"response": map[string]interface{}{"deny": []string{"Fn-*"}},
+	})
+	testCases = append(testCases, test{testTrigger, nil})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Type, testTrigger.Source = TriggerTypeCron, "*/5 * * * *"
+	testCases = append(testCases, test{testTrigger, nil})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Type, testTrigger.Source = TriggerTypeCron, "/valid-src"
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidCronSchedule})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Type, testTrigger.Source = TriggerTypeCron, "@daily"
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerCronAnnotation, map[string]interface{}{"jitter": 30, "misfire": "fire_once", "timezone": "UTC", "payload": map[string]string{"report": "daily"}})
+	testCases = append(testCases, test{testTrigger, nil})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Type, testTrigger.Source = TriggerTypeCron, "@daily"
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerCronAnnotation, map[string]interface{}{"misfire": "fire_all"})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidCron})
+
+	testTrigger = generateValidTrigger()
+	testTrigger.Type, testTrigger.Source = TriggerTypeCron, "@daily"
+	testTrigger.Annotations, _ = testTrigger.Annotations.With(TriggerCronAnnotation, map[string]interface{}{"jitter": MaxTriggerCronJitter + 1})
+	testCases = append(testCases, test{testTrigger, ErrTriggerInvalidCron})
+
 	for _, testCase := range testCases {
 		got := testCase.Trigger.Validate()
 
@@ -236,3 +331,72 @@ func generateValidTrigger() Trigger {
 		Source: "/valid-src",
 	}
 }
+
+func TestCronSchedule(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	for _, tc := range []struct {
+		spec, from, next string
+	}{
+		{"* * * * *", "2019-01-01T10:00:30Z", "2019-01-01T10:01:00Z"},
+		{"*/15 * * * *", "2019-01-01T10:00:00Z", "2019-01-01T10:15:00Z"},
+		{"5/20 * * * *", "2019-01-01T10:30:00Z", "2019-01-01T10:45:00Z"},
+		{"0 9-17 * * mon-fri", "2019-01-04T17:30:00Z", "2019-01-07T09:00:00Z"},
+		{"0 0 1,15 * *", "2019-01-02T00:00:00Z", "2019-01-15T00:00:00Z"},
+		// restricted days of month and week match either
+		{"0 0 13 * 5", "2019-01-02T00:00:00Z", "2019-01-04T00:00:00Z"},
+		{"0 0 * * 7", "2019-01-01T00:00:00Z", "2019-01-06T00:00:00Z"},
+		{"0 0 29 feb *", "2019-01-01T00:00:00Z", "2020-02-29T00:00:00Z"},
+		{"@hourly", "2019-12-31T23:10:00Z", "2020-01-01T00:00:00Z"},
+		{"0 0 30 2 *", "2019-01-01T00:00:00Z", ""},
+	} {
+		s, err := ParseCronSchedule(tc.spec)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", tc.spec, err)
+		}
+		next := s.Next(at(tc.from))
+		if tc.next == "" {
+			if !next.IsZero() {
+				t.Errorf("expected %q never to fire, but it fires at %v", tc.spec, next)
+			}
+		} else if !next.Equal(at(tc.next)) {
+			t.Errorf("expected %q to fire after %s at %s, but it fires at %v", tc.spec, tc.from, tc.next, next)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *", "@every"} {
+		if _, err := ParseCronSchedule(spec); err != ErrTriggerInvalidCronSchedule {
+			t.Errorf("expected %q to be invalid, but got %v", spec, err)
+		}
+	}
+}
+
+func TestHeaderFilter(t *testing.T) {
+	var all *HeaderFilter
+	if !all.Passes("Cookie") {
+		t.Error("expected a nil filter to pass all headers")
+	}
+
+	f := &HeaderFilter{Allow: []string{"Accept", "X-Acme-*"}, Deny: []string{"X-Acme-Secret"}}
+	for name, passes := range map[string]bool{
+		"Accept":        true,
+		"accept":        true,
+		"X-Acme-Tenant": true,
+		"X-Acme-Secret": false,
+		"Cookie":        false,
+	} {
+		if f.Passes(name) != passes {
+			t.Errorf("expected %s to pass %v", name, passes)
+		}
+	}
+
+	f = &HeaderFilter{Deny: []string{"Fn-*"}}
+	if f.Passes("Fn-Call-Id") || !f.Passes("Content-Type") {
+		t.Error("expected only the Fn- headers to be denied")
+	}
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// TriggerTransformAnnotation holds a JSON TriggerTransform object, the rules applied by the server to requests
+// made via an http trigger before they are passed to the fn, and to the fn's responses before they are returned
+const TriggerTransformAnnotation = "fnproject.io/trigger/transform"
+
+var (
+	//ErrTriggerInvalidTransform - the trigger transform annotation is not a valid TriggerTransform
+	ErrTriggerInvalidTransform = err{
+		code: http.StatusBadRequest,
+		error: fmt.Errorf("Invalid %s annotation, it must be an object with request and response transforms "+
+			"of valid header names, query parameters and content types", TriggerTransformAnnotation)}
+)
+
+// TriggerTransform are the rules applied to the requests and responses of calls made via an http trigger
+type TriggerTransform struct {
+	Request  *TriggerRequestTransform  `json:"request,omitempty"`
+	Response *TriggerResponseTransform `json:"response,omitempty"`
+}
+
+// TriggerRequestTransform is applied to the request made to the trigger, before it is passed to the fn
+type TriggerRequestTransform struct {
+	// StripHeaders are removed from the request, before any are set
+	StripHeaders []string `json:"strip_headers,omitempty"`
+	// SetHeaders are set on the request, replacing any values the caller sent
+	SetHeaders map[string]string `json:"set_headers,omitempty"`
+	// Query parameters are set on the request URL, replacing any values the caller sent
+	Query map[string]string `json:"query,omitempty"`
+	// ContentType overrides the content type of the request
+	ContentType string `json:"content_type,omitempty"`
+	// Base64Body base64 encodes the request body, so that fns that only handle text can accept binary input
+	Base64Body bool `json:"base64_body,omitempty"`
+}
+
+// TriggerResponseTransform is applied to the fn's response, before it is returned to the caller
+type TriggerResponseTransform struct {
+	// StripHeaders are removed from the response, before any are set
+	StripHeaders []string `json:"strip_headers,omitempty"`
+	// SetHeaders are set on the response, replacing any values the fn returned
+	SetHeaders map[string]string `json:"set_headers,omitempty"`
+	// ContentType overrides the content type of the response
+	ContentType string `json:"content_type,omitempty"`
+	// Base64Body base64 decodes the response body, so that fns that only output text can return binary output
+	Base64Body bool `json:"base64_body,omitempty"`
+}
+
+// Validate checks that header names are valid http tokens, that query parameters have names, and that content
+// types can be parsed
+func (t *TriggerTransform) Validate() error {
+	if req := t.Request; req != nil {
+		if !validHeaders(req.StripHeaders, req.SetHeaders) || !validContentType(req.ContentType) {
+			return ErrTriggerInvalidTransform
+		}
+		for k := range req.Query {
+			if k == "" {
+				return ErrTriggerInvalidTransform
+			}
+		}
+	}
+	if resp := t.Response; resp != nil {
+		if !validHeaders(resp.StripHeaders, resp.SetHeaders) || !validContentType(resp.ContentType) {
+			return ErrTriggerInvalidTransform
+		}
+	}
+	return nil
+}
+
+// Transform returns the trigger's transform held in the TriggerTransformAnnotation, or nil if there is none
+func (t *Trigger) Transform() (*TriggerTransform, error) {
+	v, ok := t.Annotations.Get(TriggerTransformAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	var tf TriggerTransform
+	dec := json.NewDecoder(bytes.NewReader(v))
+	// catch misspelt rules, rather than silently ignoring them
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&tf); err != nil {
+		return nil, ErrTriggerInvalidTransform
+	}
+	if err := tf.Validate(); err != nil {
+		return nil, err
+	}
+	return &tf, nil
+}
+
+func validHeaders(strip []string, set map[string]string) bool {
+	for _, k := range strip {
+		if !validHeaderName(k) {
+			return false
+		}
+	}
+	for k := range set {
+		if !validHeaderName(k) {
+			return false
+		}
+	}
+	return true
+}
+
+// validHeaderName checks that name is an RFC 7230 token
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c < 0x7f && bytes.ContainsRune([]byte("!#$%&'*+-.^_`|~"), c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func validContentType(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	_, _, err := mime.ParseMediaType(ct)
+	return err == nil
+}
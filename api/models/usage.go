@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+)
+
+// Usage is what the calls of a fn consumed over a window of time, for chargeback. Resources are counted as they
+// were allocated to calls, rather than as they were used.
+type Usage struct {
+	AppID string `json:"app_id"`
+	FnID  string `json:"fn_id"`
+
+	// Start and End of the window, calls are counted in the window they completed in
+	Start common.DateTime `json:"start"`
+	End   common.DateTime `json:"end"`
+
+	// Invocations is the number of calls, of which Errors failed or timed out
+	Invocations uint64 `json:"invocations"`
+	Errors      uint64 `json:"errors"`
+
+	// GBSeconds is the memory allocated to calls, in gigabytes, times their duration in seconds
+	GBSeconds float64 `json:"gb_seconds"`
+	// CPUSeconds is the CPUs allocated to calls times their duration in seconds. Calls without a CPU limit count
+	// as a single CPU.
+	CPUSeconds float64 `json:"cpu_seconds"`
+}
+
+// CallUsage returns the usage of a single call that has ended
+func CallUsage(call *Call) Usage {
+	duration := call.ExecutionDuration
+	started, completed := time.Time(call.StartedAt), time.Time(call.CompletedAt)
+	if duration == 0 && !started.IsZero() && completed.After(started) {
+		duration = completed.Sub(started)
+	}
+	cpus := float64(call.CPUs) / 1000
+	if cpus == 0 {
+		cpus = 1
+	}
+
+	u := Usage{
+		AppID:       call.AppID,
+		FnID:        call.FnID,
+		Start:       call.StartedAt,
+		End:         call.CompletedAt,
+		Invocations: 1,
+		GBSeconds:   float64(call.Memory) / 1024 * duration.Seconds(),
+		CPUSeconds:  cpus * duration.Seconds(),
+	}
+	if call.Status != "success" {
+		u.Errors = 1
+	}
+	return u
+}
+
+// Add adds the invocations and resources of o to u
+func (u *Usage) Add(o Usage) {
+	u.Invocations += o.Invocations
+	u.Errors += o.Errors
+	u.GBSeconds += o.GBSeconds
+	u.CPUSeconds += o.CPUSeconds
+}
+
+// AppUsageSummary is the usage of an app's fns between two times, and how much of its quota is consumed
+type AppUsageSummary struct {
+	AppID string          `json:"app_id"`
+	From  common.DateTime `json:"from"`
+	To    common.DateTime `json:"to"`
+
+	Invocations uint64 `json:"invocations"`
+	Errors      uint64 `json:"errors"`
+	// ErrorRate is the fraction of invocations that failed
+	ErrorRate  float64 `json:"error_rate"`
+	GBSeconds  float64 `json:"gb_seconds"`
+	CPUSeconds float64 `json:"cpu_seconds"`
+
+	// Fns is the usage of each fn of the app that was called
+	Fns []Usage `json:"fns"`
+
+	Quota *AppQuotaUsage `json:"quota,omitempty"`
+}
+
+// AppQuotaUsage is how much of its quota an app has used over the current quota period
+type AppQuotaUsage struct {
+	Limits AppQuota `json:"limits"`
+	Used   Usage    `json:"used"`
+}
@@ -0,0 +1,251 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+)
+
+const (
+	// MaxWorkflowSteps is the max number of steps in a workflow
+	MaxWorkflowSteps = 32
+	// MaxWorkflowStepRetries is the max number of times a workflow step may be retried
+	MaxWorkflowStepRetries = 10
+	// MaxWorkflowRunInput is the largest input a run may be started with, in bytes
+	MaxWorkflowRunInput = 1024 * 1024
+)
+
+// WorkflowRunLostError is the error of the runs that were lost, as the server running them stopped
+const WorkflowRunLostError = "run lost, the server running it stopped"
+
+// States of a workflow run. A run starts running and either succeeds, or
+// fails once a step has run out of retries. A failed run first compensates
+// the steps that completed, in reverse order:
+//
+//	running ----> succeeded
+//	   |
+//	   +--------> compensating ----> failed
+const (
+	WorkflowRunStateRunning      = "running"
+	WorkflowRunStateSucceeded    = "succeeded"
+	WorkflowRunStateCompensating = "compensating"
+	WorkflowRunStateFailed       = "failed"
+)
+
+var (
+	ErrWorkflowsNotFound = err{
+		code:  http.StatusNotFound,
+		error: errors.New("Workflow not found"),
+	}
+	ErrWorkflowsExists = err{
+		code:  http.StatusConflict,
+		error: errors.New("Workflow with specified name already exists"),
+	}
+	ErrWorkflowsIDProvided = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("ID cannot be provided for Workflow creation"),
+	}
+	ErrWorkflowsMissingName = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Missing Workflow name"),
+	}
+	ErrWorkflowsInvalidName = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("Workflow name must be a valid string of %v characters or less", MaxLengthFnName),
+	}
+	ErrWorkflowsMissingAppID = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Missing AppID on Workflow"),
+	}
+	ErrWorkflowsInvalidSteps = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("Workflow must have between 1 and %d steps", MaxWorkflowSteps),
+	}
+	ErrWorkflowsInvalidStep = err{
+		code:  http.StatusBadRequest,
+		error: fmt.Errorf("Workflow steps must have a fn_id, and at most %d retries", MaxWorkflowStepRetries),
+	}
+	ErrWorkflowsStepFnNotFound = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Workflow step fn not found in the workflow's app"),
+	}
+	ErrWorkflowsUnsupported = err{
+		code:  http.StatusNotImplemented,
+		error: errors.New("Running workflows is not supported on this server"),
+	}
+	ErrWorkflowRunNotFound = err{
+		code:  http.StatusNotFound,
+		error: errors.New("Workflow run not found"),
+	}
+	ErrDatastoreEmptyWorkflowID = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Missing Workflow ID"),
+	}
+	ErrDatastoreEmptyWorkflowRunID = err{
+		code:  http.StatusBadRequest,
+		error: errors.New("Missing Workflow run ID"),
+	}
+)
+
+// Workflow is an ordered list of fns, run one after another with the output
+// of each step as the input to the next.
+type Workflow struct {
+	// ID is the generated resource id.
+	ID string `json:"id" db:"id"`
+	// Name is a user provided name for this workflow, unique within its app.
+	Name string `json:"name" db:"name"`
+	// AppID is the app the workflow and the fns of its steps belong to.
+	AppID string `json:"app_id" db:"app_id"`
+	// Steps are the fns to run, in order.
+	Steps WorkflowSteps `json:"steps" db:"steps"`
+	// CreatedAt is the UTC timestamp when this workflow was created.
+	CreatedAt common.DateTime `json:"created_at,omitempty" db:"created_at"`
+	// UpdatedAt is the UTC timestamp of the last time this workflow was modified.
+	UpdatedAt common.DateTime `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// WorkflowStep is a fn run by a workflow, and its retry and compensation policies.
+type WorkflowStep struct {
+	// FnID is the fn to invoke with the output of the previous step, or the
+	// run's input for the first step.
+	FnID string `json:"fn_id"`
+	// Retries is how many more times the fn is invoked if it fails.
+	Retries int `json:"retries,omitempty"`
+	// CompensateFnID is an optional fn that undoes the step, it is invoked
+	// with the step's output if a later step fails.
+	CompensateFnID string `json:"compensate_fn_id,omitempty"`
+}
+
+// WorkflowSteps is stored as a JSON list
+type WorkflowSteps []WorkflowStep
+
+// implements sql.Valuer, returning a string
+func (s WorkflowSteps) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	return driver.Value(string(b)), err
+}
+
+// implements sql.Scanner
+func (s *WorkflowSteps) Scan(value interface{}) error {
+	return scanJSON("steps", value, s)
+}
+
+// SetDefaults sets zeroed fields to defaults.
+func (w *Workflow) SetDefaults() {
+	if time.Time(w.CreatedAt).IsZero() {
+		w.CreatedAt = common.DateTime(time.Now())
+	}
+	if time.Time(w.UpdatedAt).IsZero() {
+		w.UpdatedAt = w.CreatedAt
+	}
+}
+
+// Validate validates all field values, returning the first error, if any.
+func (w *Workflow) Validate() error {
+	if w.Name == "" {
+		return ErrWorkflowsMissingName
+	}
+	if len(w.Name) > MaxLengthFnName || url.PathEscape(w.Name) != w.Name {
+		return ErrWorkflowsInvalidName
+	}
+	if w.AppID == "" {
+		return ErrWorkflowsMissingAppID
+	}
+	if len(w.Steps) == 0 || len(w.Steps) > MaxWorkflowSteps {
+		return ErrWorkflowsInvalidSteps
+	}
+	for _, step := range w.Steps {
+		if step.FnID == "" || step.Retries < 0 || step.Retries > MaxWorkflowStepRetries {
+			return ErrWorkflowsInvalidStep
+		}
+	}
+	return nil
+}
+
+// WorkflowRun is an execution of a workflow.
+type WorkflowRun struct {
+	// ID is the generated run id.
+	ID string `json:"id" db:"id"`
+	// WorkflowID is the workflow being run.
+	WorkflowID string `json:"workflow_id" db:"workflow_id"`
+	// Status is one of the WorkflowRunState values.
+	Status string `json:"status" db:"status"`
+	// Step is the index of the step being run, or compensated.
+	Step int `json:"step" db:"step"`
+	// Input is the input to the first step.
+	Input string `json:"input" db:"input"`
+	// Outputs are the outputs of each completed step, the last of a
+	// succeeded run is its result.
+	Outputs WorkflowOutputs `json:"outputs" db:"outputs"`
+	// Error is why the run failed, if it did.
+	Error string `json:"error,omitempty" db:"error"`
+	// CreatedAt is the UTC timestamp when the run was started.
+	CreatedAt common.DateTime `json:"created_at,omitempty" db:"created_at"`
+	// UpdatedAt is the UTC timestamp of the last step taken.
+	UpdatedAt common.DateTime `json:"updated_at,omitempty" db:"updated_at"`
+	// CompletedAt is the UTC timestamp when the run succeeded or failed.
+	CompletedAt common.DateTime `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// WorkflowOutputs is stored as a JSON list
+type WorkflowOutputs []string
+
+// implements sql.Valuer, returning a string
+func (o WorkflowOutputs) Value() (driver.Value, error) {
+	if o == nil {
+		o = WorkflowOutputs{}
+	}
+	b, err := json.Marshal(o)
+	return driver.Value(string(b)), err
+}
+
+// implements sql.Scanner
+func (o *WorkflowOutputs) Scan(value interface{}) error {
+	return scanJSON("outputs", value, o)
+}
+
+func scanJSON(name string, value interface{}, dst interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bv, err := driver.String.ConvertValue(value)
+	if err != nil {
+		return fmt.Errorf("%s invalid db format: %T %T value, err: %v", name, value, bv, err)
+	}
+	switch x := bv.(type) {
+	case []byte:
+		return json.Unmarshal(x, dst)
+	case string:
+		return json.Unmarshal([]byte(x), dst)
+	}
+	return fmt.Errorf("%s invalid db format: %T %T value", name, value, bv)
+}
+
+type WorkflowFilter struct {
+	AppID   string // this is exact match
+	Cursor  string
+	PerPage int
+}
+
+type WorkflowList struct {
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Items      []*Workflow `json:"items"`
+}
+
+type WorkflowRunFilter struct {
+	WorkflowID string // this is exact match
+	Status     string // this is exact match
+	Cursor     string
+	PerPage    int
+}
+
+type WorkflowRunList struct {
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Items      []*WorkflowRun `json:"items"`
+}
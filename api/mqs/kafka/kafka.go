@@ -0,0 +1,207 @@
+// Package kafka delivers detached calls through a Kafka topic, partitioned by app id, consumed by the servers as a
+// consumer group.
+//
+// The queue is set up with kafka://<broker>:<port>,<broker>:<port>/<topic>, or kafkas:// over TLS. The group query
+// parameter is the consumer group, fn by default, and consumers how many calls each server takes off the topic at
+// once, 1 by default. Servers sharing a group share the partitions of the topic, which must exist, and has as many
+// partitions as there may be consumers across the group.
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/api/mqs"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultGroup is the consumer group of queues whose url does not set one
+	defaultGroup = "fn"
+	// batchTimeout is how long pushed messages wait for others to be written with, calls are pushed one at a time
+	batchTimeout = 10 * time.Millisecond
+	// retryBackoff is how long consumers wait before fetching again after failing to
+	retryBackoff = time.Second
+	// commitTimeout is how long committing the offset of a message handled may take
+	commitTimeout = 10 * time.Second
+)
+
+type provider int
+
+func (provider) String() string {
+	return "kafka"
+}
+
+func (provider) Supports(u *url.URL) bool {
+	return u.Scheme == "kafka" || u.Scheme == "kafkas"
+}
+
+func (provider) New(ctx context.Context, u *url.URL) (models.MessageQueue, error) {
+	cfg, err := configFromURL(u)
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg), nil
+}
+
+func init() {
+	mqs.Register(new(provider))
+}
+
+// Config is the topic a queue is kept in
+type Config struct {
+	Brokers []string
+	Topic   string
+	// Group is the consumer group the servers consuming the queue share
+	Group string
+	// Consumers is how many messages a server handles at once
+	Consumers int
+	// TLS connects to the brokers over TLS, if set
+	TLS *tls.Config
+}
+
+func configFromURL(u *url.URL) (Config, error) {
+	cfg := Config{
+		Topic:     strings.Trim(u.Path, "/"),
+		Group:     u.Query().Get("group"),
+		Consumers: 1,
+	}
+	for _, b := range strings.Split(u.Host, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			cfg.Brokers = append(cfg.Brokers, b)
+		}
+	}
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" || strings.Contains(cfg.Topic, "/") {
+		return cfg, fmt.Errorf("bad kafka url %s, it must be kafka://<brokers>/<topic>", u.Redacted())
+	}
+	if cfg.Group == "" {
+		cfg.Group = defaultGroup
+	}
+	if c := u.Query().Get("consumers"); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("bad kafka consumers %q, it must be a positive number", c)
+		}
+		cfg.Consumers = n
+	}
+	if u.Scheme == "kafkas" {
+		cfg.TLS = &tls.Config{}
+	}
+	return cfg, nil
+}
+
+// queue is a models.MessageQueue keeping messages in a topic, keyed by app id
+type queue struct {
+	cfg    Config
+	writer *kafka.Writer
+}
+
+// New returns a MessageQueue keeping messages in the topic of cfg
+func New(cfg Config) models.MessageQueue {
+	return &queue{
+		cfg: cfg,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: batchTimeout,
+			Transport:    &kafka.Transport{TLS: cfg.TLS},
+		},
+	}
+}
+
+func (q *queue) Push(ctx context.Context, msg *models.CallMessage) error {
+	value, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return q.writer.WriteMessages(ctx, kafka.Message{Key: []byte(msg.AppID), Value: value})
+}
+
+func (q *queue) Consume(ctx context.Context, handle func(ctx context.Context, msg *models.CallMessage) error) error {
+	var wg sync.WaitGroup
+	for i := 0; i < q.cfg.Consumers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				q.consume(ctx, handle)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(retryBackoff):
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// consume hands the messages of a reader of the group to handle, one at a time, committing each once handled, so
+// that the partitions of the reader are consumed in order. The reader leaves the group once handle fails, for the
+// message to be fetched again from the last offset committed.
+func (q *queue) consume(ctx context.Context, handle func(ctx context.Context, msg *models.CallMessage) error) {
+	var dialer *kafka.Dialer
+	if q.cfg.TLS != nil {
+		dialer = &kafka.Dialer{Timeout: 10 * time.Second, DualStack: true, TLS: q.cfg.TLS}
+	}
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: q.cfg.Brokers,
+		GroupID: q.cfg.Group,
+		Topic:   q.cfg.Topic,
+		Dialer:  dialer,
+	})
+	defer r.Close()
+
+	log := common.Logger(ctx).WithFields(logrus.Fields{"topic": q.cfg.Topic, "group": q.cfg.Group})
+	for {
+		m, err := r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				return
+			}
+			log.WithError(err).Error("failed to fetch call message")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryBackoff):
+			}
+			continue
+		}
+
+		var msg models.CallMessage
+		if err := json.Unmarshal(m.Value, &msg); err != nil {
+			// it would never be handled, it is skipped rather than blocking its partition
+			log.WithError(err).WithFields(logrus.Fields{"partition": m.Partition, "offset": m.Offset}).Error("dropping malformed call message")
+		} else if err := handle(ctx, &msg); err != nil {
+			if ctx.Err() == nil {
+				log.WithError(err).WithField("call_id", msg.CallID).Error("failed to handle call message")
+			}
+			return
+		}
+
+		commitCtx, cancel := context.WithTimeout(common.BackgroundContext(ctx), commitTimeout)
+		err = r.CommitMessages(commitCtx, m)
+		cancel()
+		if err != nil {
+			log.WithError(err).WithField("call_id", msg.CallID).Error("failed to commit call message")
+		}
+	}
+}
+
+func (q *queue) Close() error {
+	return q.writer.Close()
+}
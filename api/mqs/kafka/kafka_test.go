@@ -0,0 +1,47 @@
+package kafka
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestConfigFromURL(t *testing.T) {
+	for _, tc := range []struct {
+		url       string
+		brokers   []string
+		topic     string
+		group     string
+		consumers int
+		tls       bool
+		bad       bool
+	}{
+		{url: "kafka://localhost:9092/calls", brokers: []string{"localhost:9092"}, topic: "calls", group: "fn", consumers: 1},
+		{url: "kafkas://k1:9093,k2:9093/calls?group=runners&consumers=4", brokers: []string{"k1:9093", "k2:9093"}, topic: "calls", group: "runners", consumers: 4, tls: true},
+		{url: "kafka://localhost:9092", bad: true},
+		{url: "kafka:///calls", bad: true},
+		{url: "kafka://localhost:9092/calls/more", bad: true},
+		{url: "kafka://localhost:9092/calls?consumers=0", bad: true},
+		{url: "kafka://localhost:9092/calls?consumers=many", bad: true},
+	} {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := configFromURL(u)
+		if tc.bad {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.url, err)
+			continue
+		}
+		if !reflect.DeepEqual(cfg.Brokers, tc.brokers) || cfg.Topic != tc.topic || cfg.Group != tc.group ||
+			cfg.Consumers != tc.consumers || (cfg.TLS != nil) != tc.tls {
+			t.Errorf("%s: unexpected config %+v", tc.url, cfg)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Package mqs creates the message queues detached calls are delivered through, see models.MessageQueue.
+package mqs
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Provider is a message queue provider
+type Provider interface {
+	fmt.Stringer
+	// Supports indicates if this provider can handle a given message queue url
+	Supports(url *url.URL) bool
+	// New creates a MessageQueue from the specified url
+	New(ctx context.Context, url *url.URL) (models.MessageQueue, error)
+}
+
+var providers []Provider
+
+// Register globally registers a message queue provider
+func Register(provider Provider) {
+	logrus.Infof("Registering message queue provider '%s'", provider)
+	providers = append(providers, provider)
+}
+
+// New creates a MessageQueue from the specified url
+func New(ctx context.Context, mqURL string) (models.MessageQueue, error) {
+	u, err := url.Parse(mqURL)
+	if err != nil {
+		return nil, fmt.Errorf("bad message queue url: %v", err)
+	}
+	common.Logger(ctx).WithFields(logrus.Fields{"mq": u.Scheme}).Debug("creating new message queue")
+
+	for _, provider := range providers {
+		if provider.Supports(u) {
+			return provider.New(ctx, u)
+		}
+	}
+	return nil, fmt.Errorf("no message queue provider found for url scheme %s", u.Scheme)
+}
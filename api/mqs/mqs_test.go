@@ -0,0 +1,12 @@
+package mqs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNewUnknownScheme(t *testing.T) {
+	if _, err := New(context.Background(), "nope://localhost/calls"); err == nil {
+		t.Fatal("expected no provider for an unknown scheme")
+	}
+}
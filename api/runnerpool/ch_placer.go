@@ -13,25 +13,31 @@ import (
 )
 
 type chPlacer struct {
-	cfg PlacerConfig
+	cfg placerConfig
 }
 
 func NewCHPlacer(cfg *PlacerConfig) Placer {
 	logrus.Infof("Creating new CH runnerpool placer with config=%+v", cfg)
 	return &chPlacer{
-		cfg: *cfg,
+		cfg: placerConfig{cfg: *cfg},
 	}
 }
 
 func (p *chPlacer) GetPlacerConfig() PlacerConfig {
-	return p.cfg
+	return p.cfg.get()
+}
+
+// SetPlacerConfig implements TunablePlacer
+func (p *chPlacer) SetPlacerConfig(cfg PlacerConfig) {
+	p.cfg.set(cfg)
 }
 
 // This borrows the CH placement algorithm from the original FNLB.
 // Because we ask a runner to accept load (queuing on the LB rather than on the nodes), we don't use
 // the LB_WAIT to drive placement decisions: runners only accept work if they have the capacity for it.
 func (p *chPlacer) PlaceCall(ctx context.Context, rp RunnerPool, call RunnerCall) error {
-	state := NewPlacerTracker(ctx, &p.cfg, call)
+	cfg := p.cfg.get()
+	state := NewPlacerTracker(ctx, &cfg, call)
 	defer state.HandleDone()
 
 	key := call.Model().FnID
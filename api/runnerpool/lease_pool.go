@@ -0,0 +1,160 @@
+package runnerpool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HeartbeatRunner is a Runner that answers heartbeats, cheaper than its Status, renewing its lease
+type HeartbeatRunner interface {
+	Runner
+	Heartbeat(ctx context.Context) error
+}
+
+// LeaseConfig configures the leases of runners
+type LeaseConfig struct {
+	// Interval is how often runners are sent heartbeats
+	Interval time.Duration
+	// Lease is how long a runner is placed calls on after its last heartbeat
+	Lease time.Duration
+	// Timeout is how long a heartbeat may take, a runner not answering in time is not renewed
+	Timeout time.Duration
+}
+
+// NewLeaseConfig returns the default LeaseConfig, heartbeats every second and leases of 5 seconds
+func NewLeaseConfig() LeaseConfig {
+	return LeaseConfig{
+		Interval: 1 * time.Second,
+		Lease:    5 * time.Second,
+		Timeout:  1 * time.Second,
+	}
+}
+
+// leasedRunnerPool only places calls on runners whose lease is current
+type leasedRunnerPool struct {
+	RunnerPool
+	cfg LeaseConfig
+
+	lock   sync.Mutex
+	leases map[string]*runnerLease
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+type runnerLease struct {
+	expiry time.Time
+	seen   time.Time
+}
+
+// NewLeasedRunnerPool returns a RunnerPool sending heartbeats to the HeartbeatRunners of rp, and placing calls only
+// on those that answered one within the lease, so wedged runners behind half-open connections are not placed calls.
+// Runners that do not answer heartbeats are always placed calls.
+func NewLeasedRunnerPool(rp RunnerPool, cfg LeaseConfig) RunnerPool {
+	return &leasedRunnerPool{
+		RunnerPool: rp,
+		cfg:        cfg,
+		leases:     make(map[string]*runnerLease),
+		done:       make(chan struct{}),
+	}
+}
+
+// Runners implements RunnerPool
+func (p *leasedRunnerPool) Runners(ctx context.Context, call RunnerCall) ([]Runner, error) {
+	runners, err := p.RunnerPool.Runners(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	leased := make([]Runner, 0, len(runners))
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, r := range runners {
+		hr, ok := asHeartbeatRunner(r)
+		if !ok {
+			leased = append(leased, r)
+			continue
+		}
+		lease, ok := p.leases[r.Address()]
+		if !ok {
+			// runners get a first lease when they join the pool
+			lease = &runnerLease{expiry: now.Add(p.cfg.Lease)}
+			p.leases[r.Address()] = lease
+			select {
+			case <-p.done:
+			default:
+				p.wg.Add(1)
+				go p.heartbeat(hr)
+			}
+		}
+		lease.seen = now
+		if now.Before(lease.expiry) {
+			leased = append(leased, r)
+		}
+	}
+	return leased, nil
+}
+
+// asHeartbeatRunner returns r as a HeartbeatRunner, looking through the labels of runners
+func asHeartbeatRunner(r Runner) (HeartbeatRunner, bool) {
+	if tr, ok := r.(*tenantRunner); ok {
+		r = tr.Runner
+	}
+	hr, ok := r.(HeartbeatRunner)
+	return hr, ok
+}
+
+// heartbeat renews the lease of r while it answers heartbeats, until the pool shuts down or r leaves it
+func (p *leasedRunnerPool) heartbeat(r HeartbeatRunner) {
+	defer p.wg.Done()
+	log := logrus.WithField("runner_addr", r.Address())
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.cfg.Timeout)
+		err := r.Heartbeat(ctx)
+		cancel()
+
+		now := time.Now()
+		p.lock.Lock()
+		lease := p.leases[r.Address()]
+		// runners no longer listed by the pool for a while have left it
+		if now.Sub(lease.seen) > 10*p.cfg.Lease {
+			delete(p.leases, r.Address())
+			p.lock.Unlock()
+			return
+		}
+		expired := !now.Before(lease.expiry)
+		expiring := !expired && !now.Add(p.cfg.Interval).Before(lease.expiry)
+		if err == nil {
+			lease.expiry = now.Add(p.cfg.Lease)
+		}
+		p.lock.Unlock()
+
+		if err != nil && expiring {
+			log.WithError(err).Warn("Runner lease expiring, heartbeats failing")
+		} else if err == nil && expired {
+			log.Info("Runner lease renewed")
+		}
+	}
+}
+
+// Shutdown implements RunnerPool
+func (p *leasedRunnerPool) Shutdown(ctx context.Context) error {
+	p.lock.Lock()
+	close(p.done)
+	p.lock.Unlock()
+	p.wg.Wait()
+	return p.RunnerPool.Shutdown(ctx)
+}
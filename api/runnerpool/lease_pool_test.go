@@ -0,0 +1,59 @@
+package runnerpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// implements HeartbeatRunner
+type heartbeatRunner struct {
+	dummyRunner
+	addr   string
+	wedged int32
+}
+
+func (o *heartbeatRunner) Address() string { return o.addr }
+func (o *heartbeatRunner) Heartbeat(ctx context.Context) error {
+	if atomic.LoadInt32(&o.wedged) == 1 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func TestLeasedRunnerPool(t *testing.T) {
+	healthy, wedged, legacy := &heartbeatRunner{addr: "healthy"}, &heartbeatRunner{addr: "wedged"}, &dummyRunner{}
+	all := []Runner{healthy, WithPool(wedged, "acme"), legacy}
+
+	rp := &dummyPool{}
+	rp.On("Runners", mock.Anything, mock.Anything).Return(all, nil)
+	rp.On("Shutdown", mock.Anything).Return(errors.New("closed"))
+	lp := NewLeasedRunnerPool(rp, LeaseConfig{Interval: 10 * time.Millisecond, Lease: 100 * time.Millisecond, Timeout: 10 * time.Millisecond})
+
+	runners, err := lp.Runners(context.Background(), &dummyCall{})
+	assert.NoError(t, err)
+	assert.Len(t, runners, 3, "runners joining the pool get a first lease")
+
+	atomic.StoreInt32(&wedged.wedged, 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(runners) != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		runners, _ = lp.Runners(context.Background(), &dummyCall{})
+	}
+	assert.Equal(t, []Runner{healthy, legacy}, runners, "the lease of the wedged runner expires")
+
+	atomic.StoreInt32(&wedged.wedged, 0)
+	for len(runners) != 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		runners, _ = lp.Runners(context.Background(), &dummyCall{})
+	}
+	assert.Len(t, runners, 3, "the lease of the runner is renewed once it answers heartbeats again")
+
+	assert.EqualError(t, lp.Shutdown(context.Background()), "closed")
+}
@@ -10,22 +10,28 @@ import (
 )
 
 type naivePlacer struct {
-	cfg PlacerConfig
+	cfg placerConfig
 }
 
 func NewNaivePlacer(cfg *PlacerConfig) Placer {
 	logrus.Infof("Creating new naive runnerpool placer with config=%+v", cfg)
 	return &naivePlacer{
-		cfg: *cfg,
+		cfg: placerConfig{cfg: *cfg},
 	}
 }
 
 func (sp *naivePlacer) GetPlacerConfig() PlacerConfig {
-	return sp.cfg
+	return sp.cfg.get()
+}
+
+// SetPlacerConfig implements TunablePlacer
+func (sp *naivePlacer) SetPlacerConfig(cfg PlacerConfig) {
+	sp.cfg.set(cfg)
 }
 
 func (sp *naivePlacer) PlaceCall(ctx context.Context, rp RunnerPool, call RunnerCall) error {
-	state := NewPlacerTracker(ctx, &sp.cfg, call)
+	cfg := sp.cfg.get()
+	state := NewPlacerTracker(ctx, &cfg, call)
 	defer state.HandleDone()
 
 	var runnerPoolErr error
@@ -0,0 +1,75 @@
+package runnerpool
+
+import (
+	"context"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+)
+
+// Outcomes of placement attempts
+const (
+	// PlacementPlaced is a call run by the runner
+	PlacementPlaced = "placed"
+	// PlacementBusy is a call the runner was too busy to take, it is tried on other runners
+	PlacementBusy = "busy"
+	// PlacementRetry is a call that failed to reach the runner, it is tried on other runners
+	PlacementRetry = "retry"
+	// PlacementError is a call the runner took but failed to run
+	PlacementError = "error"
+	// PlacementAborted is a call the client canceled or timed out on while the runner ran it
+	PlacementAborted = "aborted"
+	// PlacementNotPlaced ends the attempts of a call no runner took before the placer gave up
+	PlacementNotPlaced = "not_placed"
+)
+
+// PlacementAttempt records one attempt of a placer to place a call on a runner
+type PlacementAttempt struct {
+	CallID string `json:"call_id"`
+	AppID  string `json:"app_id,omitempty"`
+	FnID   string `json:"fn_id,omitempty"`
+	// Runner is the address of the runner tried, empty for the end of the attempts of a call not placed
+	Runner string `json:"runner,omitempty"`
+	// Attempt numbers the attempts of a call from 1
+	Attempt int    `json:"attempt"`
+	Outcome string `json:"outcome"`
+	// Reason is the error of the attempt, eg. why the runner NACKed the call
+	Reason    string          `json:"reason,omitempty"`
+	StartedAt common.DateTime `json:"started_at"`
+	// Latency is how long the attempt took, including running the call once it is placed
+	Latency time.Duration `json:"latency"`
+}
+
+// PlacementRecorder records the placement attempts of placers, see PlacerConfig.Recorder. It must not block.
+type PlacementRecorder interface {
+	RecordPlacement(ctx context.Context, attempt PlacementAttempt)
+}
+
+func placementAttempt(call RunnerCall, attempt int, start time.Time) PlacementAttempt {
+	model := call.Model()
+	return PlacementAttempt{
+		CallID:    model.ID,
+		AppID:     model.AppID,
+		FnID:      model.FnID,
+		Attempt:   attempt,
+		StartedAt: common.DateTime(start),
+		Latency:   time.Since(start),
+	}
+}
+
+// placementOutcome is the outcome of an attempt that returned placed and err, in the request context ctx
+func placementOutcome(ctx context.Context, placed bool, err error) string {
+	switch {
+	case !placed && err == models.ErrCallTimeoutServerBusy:
+		return PlacementBusy
+	case !placed:
+		return PlacementRetry
+	case err == nil:
+		return PlacementPlaced
+	case ctx.Err() == err:
+		return PlacementAborted
+	default:
+		return PlacementError
+	}
+}
@@ -0,0 +1,73 @@
+package runnerpool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+type memRecorder struct {
+	lock     sync.Mutex
+	attempts []PlacementAttempt
+}
+
+func (r *memRecorder) RecordPlacement(ctx context.Context, attempt PlacementAttempt) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.attempts = append(r.attempts, attempt)
+}
+
+func TestPlacementRecorder(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	rec := &memRecorder{}
+	cfg := NewPlacerConfig()
+	cfg.PlacerTimeout = 300 * time.Millisecond
+	cfg.Recorder = rec
+	placer := NewNaivePlacer(&cfg)
+
+	call := &dummyCall{Call: models.Call{ID: "call1", AppID: "app1", FnID: "fn1"}}
+	busy := &dummyRunner{}
+	busy.On("TryExec", mock.Anything, call).Return(false, models.ErrCallTimeoutServerBusy)
+	pool := &dummyPool{}
+	pool.On("Runners", ctx, call).Return([]Runner{busy}, nil)
+
+	assert.Equal(t, models.ErrCallTimeoutServerBusy, placer.PlaceCall(ctx, pool, call))
+
+	rec.lock.Lock()
+	attempts := rec.attempts
+	rec.lock.Unlock()
+	if !assert.True(t, len(attempts) > 1, "should record the busy attempts and the end of the attempts") {
+		return
+	}
+	for i, a := range attempts[:len(attempts)-1] {
+		assert.Equal(t, PlacementBusy, a.Outcome)
+		assert.Equal(t, i+1, a.Attempt)
+		assert.Equal(t, "call1", a.CallID)
+		assert.Equal(t, "fn1", a.FnID)
+	}
+	last := attempts[len(attempts)-1]
+	assert.Equal(t, PlacementNotPlaced, last.Outcome)
+	assert.Equal(t, "placer timeout", last.Reason)
+	assert.Equal(t, len(attempts), last.Attempt)
+
+	// a placed call ends with its placed attempt
+	rec.attempts = nil
+	placed := &dummyRunner{}
+	placed.On("TryExec", mock.Anything, call).Return(true, nil)
+	pool = &dummyPool{}
+	pool.On("Runners", ctx, call).Return([]Runner{placed}, nil)
+
+	assert.NoError(t, placer.PlaceCall(ctx, pool, call))
+	if assert.Len(t, rec.attempts, 1) {
+		assert.Equal(t, PlacementPlaced, rec.attempts[0].Outcome)
+		assert.Empty(t, rec.attempts[0].Reason)
+	}
+}
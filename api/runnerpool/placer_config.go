@@ -1,6 +1,7 @@
 package runnerpool
 
 import (
+	"sync"
 	"time"
 )
 
@@ -14,6 +15,9 @@ type PlacerConfig struct {
 
 	// Maximum amount of time a placer can hold an ack sync request during runner attempts
 	DetachedPlacerTimeout time.Duration `json:"detached_placer_timeout"`
+
+	// Recorder records the placement attempts of calls, if set
+	Recorder PlacementRecorder `json:"-"`
 }
 
 func NewPlacerConfig() PlacerConfig {
@@ -23,3 +27,26 @@ func NewPlacerConfig() PlacerConfig {
 		DetachedPlacerTimeout: 30 * time.Second,
 	}
 }
+
+// TunablePlacer is implemented by placers whose config can be changed while they place calls
+type TunablePlacer interface {
+	SetPlacerConfig(cfg PlacerConfig)
+}
+
+// placerConfig guards the config of a placer, calls placed after a change use the new config
+type placerConfig struct {
+	lock sync.RWMutex
+	cfg  PlacerConfig
+}
+
+func (c *placerConfig) get() PlacerConfig {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.cfg
+}
+
+func (c *placerConfig) set(cfg PlacerConfig) {
+	c.lock.Lock()
+	c.cfg = cfg
+	c.lock.Unlock()
+}
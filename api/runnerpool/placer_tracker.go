@@ -2,6 +2,7 @@ package runnerpool
 
 import (
 	"context"
+	"time"
 
 	"github.com/fnproject/fn/api/common"
 	"github.com/fnproject/fn/api/models"
@@ -16,6 +17,10 @@ type placerTracker struct {
 	cancel     context.CancelFunc
 	tracker    *attemptTracker
 	isPlaced   bool
+	call       RunnerCall
+	attempts   int
+	lastErr    error
+	start      time.Time
 }
 
 func NewPlacerTracker(requestCtx context.Context, cfg *PlacerConfig, call RunnerCall) *placerTracker {
@@ -32,6 +37,8 @@ func NewPlacerTracker(requestCtx context.Context, cfg *PlacerConfig, call Runner
 		placerCtx:  ctx,
 		cancel:     cancel,
 		tracker:    newAttemptTracker(requestCtx),
+		call:       call,
+		start:      time.Now(),
 	}
 }
 
@@ -55,6 +62,8 @@ func (tr *placerTracker) HandleFindRunnersFailure(err error) {
 // analyze the results.
 func (tr *placerTracker) TryRunner(r Runner, call RunnerCall) (bool, error) {
 	tr.tracker.recordAttempt()
+	tr.attempts++
+	start := time.Now()
 
 	// WARNING: Do not use placerCtx here to let requestCtx take its time
 	// during container execution.
@@ -62,6 +71,17 @@ func (tr *placerTracker) TryRunner(r Runner, call RunnerCall) (bool, error) {
 	isPlaced, err := r.TryExec(ctx, call)
 	cancel()
 
+	if tr.cfg.Recorder != nil {
+		attempt := placementAttempt(call, tr.attempts, start)
+		attempt.Runner = r.Address()
+		attempt.Outcome = placementOutcome(tr.requestCtx, isPlaced, err)
+		if err != nil {
+			attempt.Reason = err.Error()
+		}
+		tr.cfg.Recorder.RecordPlacement(tr.requestCtx, attempt)
+	}
+	tr.lastErr = err
+
 	if !isPlaced {
 
 		// Too Busy is super common case, we track it separately
@@ -107,6 +127,20 @@ func (tr *placerTracker) HandleDone() {
 		stats.Record(tr.requestCtx, placerTimeoutMeasure.M(0))
 	}
 
+	if !tr.isPlaced && tr.cfg.Recorder != nil {
+		attempt := placementAttempt(tr.call, tr.attempts+1, tr.start)
+		attempt.Outcome = PlacementNotPlaced
+		switch {
+		case tr.requestCtx.Err() != nil:
+			attempt.Reason = tr.requestCtx.Err().Error()
+		case tr.placerCtx.Err() != nil:
+			attempt.Reason = "placer timeout"
+		case tr.lastErr != nil:
+			attempt.Reason = tr.lastErr.Error()
+		}
+		tr.cfg.Recorder.RecordPlacement(tr.requestCtx, attempt)
+	}
+
 	tr.tracker.finalizeAttempts(tr.isPlaced)
 	tr.cancel()
 }
@@ -0,0 +1,78 @@
+package runnerpool
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/common"
+)
+
+// TenantRunner is a Runner labeled with the tenant pool it belongs to, see models.AppRunnerPoolAnnotation
+type TenantRunner interface {
+	Runner
+	Pool() string
+}
+
+type tenantRunner struct {
+	Runner
+	pool string
+}
+
+func (r *tenantRunner) Pool() string { return r.pool }
+
+// WithPool labels a runner with the tenant pool it belongs to, "" for none
+func WithPool(r Runner, pool string) Runner {
+	if pool == "" {
+		return r
+	}
+	return &tenantRunner{Runner: r, pool: pool}
+}
+
+// RunnerPoolOf returns the tenant pool a runner belongs to, "" for none
+func RunnerPoolOf(r Runner) string {
+	if tr, ok := r.(TenantRunner); ok {
+		return tr.Pool()
+	}
+	return ""
+}
+
+// tenantRunnerPool places the calls of apps with a runner pool on the runners of their pool only, and the calls of
+// the rest on the runners without a pool or in a pool that is not exclusive
+type tenantRunnerPool struct {
+	RunnerPool
+	exclusive map[string]bool
+}
+
+// NewTenantRunnerPool returns a RunnerPool partitioning the runners of rp by the tenant pools they are labeled with,
+// see WithPool. The runners of exclusive pools only run the calls of the apps of their pool.
+func NewTenantRunnerPool(rp RunnerPool, exclusive []string) RunnerPool {
+	t := &tenantRunnerPool{RunnerPool: rp, exclusive: make(map[string]bool, len(exclusive))}
+	for _, pool := range exclusive {
+		t.exclusive[pool] = true
+	}
+	return t
+}
+
+// Runners implements RunnerPool
+func (t *tenantRunnerPool) Runners(ctx context.Context, call RunnerCall) ([]Runner, error) {
+	runners, err := t.RunnerPool.Runners(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := call.Model().Annotations.RunnerPool()
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]Runner, 0, len(runners))
+	for _, r := range runners {
+		rpool := RunnerPoolOf(r)
+		if rpool == pool || (pool == "" && !t.exclusive[rpool]) {
+			filtered = append(filtered, r)
+		}
+	}
+	if len(filtered) == 0 && len(runners) > 0 {
+		common.Logger(ctx).WithField("runner_pool", pool).Debug("No runners in the runner pool of the call")
+	}
+	return filtered, nil
+}
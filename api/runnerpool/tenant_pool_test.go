@@ -0,0 +1,37 @@
+package runnerpool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestTenantRunnerPool(t *testing.T) {
+	shared, acme, bank := &dummyRunner{}, WithPool(&dummyRunner{}, "acme"), WithPool(&dummyRunner{}, "bank")
+	all := []Runner{shared, acme, bank}
+
+	rp := &dummyPool{}
+	rp.On("Runners", mock.Anything, mock.Anything).Return(all, nil)
+	tp := NewTenantRunnerPool(rp, []string{"bank"})
+
+	runners := func(pool string) []Runner {
+		call := &dummyCall{}
+		if pool != "" {
+			call.Annotations = models.Annotations{}
+			call.Annotations, _ = call.Annotations.With(models.AppRunnerPoolAnnotation, pool)
+		}
+		r, err := tp.Runners(context.Background(), call)
+		assert.NoError(t, err)
+		return r
+	}
+
+	assert.Equal(t, []Runner{shared, acme}, runners(""), "calls without a pool may run on shared pools, not exclusive ones")
+	assert.Equal(t, []Runner{acme}, runners("acme"))
+	assert.Equal(t, []Runner{bank}, runners("bank"))
+	assert.Empty(t, runners("other"))
+	assert.Len(t, all, 3, "the runners of the pool must not be changed")
+}
@@ -0,0 +1,184 @@
+// Package gcs keeps the scratch space of apps in a Google Cloud Storage bucket, under a prefix per app, eg.
+// gs://<bucket>/<prefix>. The bucket must exist. Credentials scoped to the prefix of an app are OAuth access tokens,
+// downscoped with a credential access boundary from the token of the service account of the runner, which is read
+// from the metadata server. They are passed to containers as FN_SCRATCH_ACCESS_TOKEN, and expire along with the token
+// of the runner. The token_url and sts_endpoint query parameters override where the token of the runner is read
+// from and the endpoint of the STS API.
+package gcs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fnproject/fn/api/scratch"
+)
+
+const (
+	// DefaultTokenURL is where the token of the service account of the runner is read from
+	DefaultTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	// DefaultSTSEndpoint is the endpoint of the STS API
+	DefaultSTSEndpoint = "https://sts.googleapis.com"
+	// Endpoint is the endpoint of the Cloud Storage API
+	Endpoint = "https://storage.googleapis.com"
+	// EnvAccessToken is the env var the downscoped access token is passed to containers as
+	EnvAccessToken = "FN_SCRATCH_ACCESS_TOKEN"
+
+	// requestTimeout is how long a request for a token may take
+	requestTimeout = 10 * time.Second
+)
+
+type provider int
+
+func (provider) String() string {
+	return "gcs"
+}
+
+func (provider) Supports(u *url.URL) bool {
+	return u.Scheme == "gs"
+}
+
+func (provider) New(ctx context.Context, u *url.URL) (scratch.Store, error) {
+	if u.Host == "" {
+		return nil, errors.New("no bucket in the gcs scratch store url")
+	}
+	q := u.Query()
+	tokenURL, stsEndpoint := q.Get("token_url"), q.Get("sts_endpoint")
+	if tokenURL == "" {
+		tokenURL = DefaultTokenURL
+	}
+	if stsEndpoint == "" {
+		stsEndpoint = DefaultSTSEndpoint
+	}
+	return New(u.Host, strings.Trim(u.Path, "/"), tokenURL, stsEndpoint), nil
+}
+
+func init() {
+	scratch.Register(provider(0))
+}
+
+type store struct {
+	bucket      string
+	prefix      string
+	tokenURL    string
+	stsEndpoint string
+	client      *http.Client
+}
+
+// New returns a Store keeping the scratch space of apps under prefix in bucket, downscoping the token read from
+// tokenURL with the STS API at stsEndpoint
+func New(bucket, prefix, tokenURL, stsEndpoint string) scratch.Store {
+	return &store{
+		bucket:      bucket,
+		prefix:      prefix,
+		tokenURL:    tokenURL,
+		stsEndpoint: strings.TrimSuffix(stsEndpoint, "/"),
+		client:      &http.Client{Timeout: requestTimeout},
+	}
+}
+
+type token struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// decode reads the token of a response
+func decode(resp *http.Response, what string) (*token, error) {
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s: %s", what, resp.Status, b)
+	}
+	var t token
+	if err := json.Unmarshal(b, &t); err != nil || t.AccessToken == "" {
+		return nil, fmt.Errorf("bad %s response", what)
+	}
+	return &t, nil
+}
+
+// sourceToken reads the token of the service account of the runner
+func (s *store) sourceToken(ctx context.Context) (*token, error) {
+	req, err := http.NewRequest(http.MethodGet, s.tokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return decode(resp, "metadata server")
+}
+
+// accessBoundary returns the credential access boundary limiting tokens to the objects under prefix
+func (s *store) accessBoundary(prefix string) (string, error) {
+	resource := "projects/_/buckets/" + s.bucket
+	b, err := json.Marshal(map[string]interface{}{
+		"accessBoundary": map[string]interface{}{
+			"accessBoundaryRules": []map[string]interface{}{{
+				"availablePermissions": []string{"inRole:roles/storage.objectAdmin"},
+				"availableResource":    "//storage.googleapis.com/" + resource,
+				"availabilityCondition": map[string]string{
+					"expression": fmt.Sprintf("resource.name.startsWith('%s/objects/%s') || "+
+						"api.getAttribute('storage.googleapis.com/objectListPrefix', '').startsWith('%s')", resource, prefix, prefix),
+				},
+			}},
+		},
+	})
+	return string(b), err
+}
+
+func (s *store) Grant(ctx context.Context, appID string, ttl time.Duration) (*scratch.Grant, error) {
+	prefix := scratch.AppPrefix(s.prefix, appID)
+	boundary, err := s.accessBoundary(prefix)
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.sourceToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token_type":   {"urn:ietf:params:oauth:token-type:access_token"},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"subject_token":        {source.AccessToken},
+		"options":              {boundary},
+	}
+	req, err := http.NewRequest(http.MethodPost, s.stsEndpoint+"/v1/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	downscoped, err := decode(resp, "sts")
+	if err != nil {
+		return nil, err
+	}
+	expiresIn := downscoped.ExpiresIn
+	if expiresIn == 0 {
+		expiresIn = source.ExpiresIn
+	}
+
+	return &scratch.Grant{
+		URL:      "gs://" + s.bucket + "/" + prefix,
+		Endpoint: Endpoint,
+		Bucket:   s.bucket,
+		Prefix:   prefix,
+		Env:      map[string]string{EnvAccessToken: downscoped.AccessToken},
+		Expires:  time.Now().Add(time.Duration(expiresIn) * time.Second),
+	}, nil
+}
@@ -0,0 +1,53 @@
+package gcs
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGrant(t *testing.T) {
+	var options string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"access_token":"source","expires_in":1800,"token_type":"Bearer"}`))
+		case "/v1/token":
+			b, _ := ioutil.ReadAll(r.Body)
+			form, _ := url.ParseQuery(string(b))
+			if form.Get("subject_token") != "source" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			options = form.Get("options")
+			w.Write([]byte(`{"access_token":"downscoped","expires_in":1800}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	store := New("scratch", "fns", srv.URL+"/token", srv.URL)
+	grant, err := store.Grant(context.Background(), "app_id", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grant.URL != "gs://scratch/fns/app_id/" || grant.Env[EnvAccessToken] != "downscoped" {
+		t.Fatalf("unexpected grant %+v", grant)
+	}
+	if d := time.Until(grant.Expires); d < 29*time.Minute || d > 30*time.Minute {
+		t.Fatalf("expected the grant to expire along with the token, in %v", d)
+	}
+	if !strings.Contains(options, "projects/_/buckets/scratch/objects/fns/app_id/") {
+		t.Fatalf("expected the access boundary to be scoped to the prefix of the app, got %s", options)
+	}
+}
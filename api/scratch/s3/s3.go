@@ -0,0 +1,333 @@
+// Package s3 keeps the scratch space of apps in an S3 compatible object store, under a prefix per app in one bucket,
+// which is created if it does not exist. Credentials scoped to the prefix of an app are issued with the AssumeRole
+// API of STS, with a session policy, which AWS and MinIO both serve.
+//
+// AWS S3 is set up with s3://<bucket>/<prefix>?region=<region>&role=<arn>, the role being assumed for the
+// credentials, and MinIO with minio://<host>:<port>/<bucket>/<prefix>, or minios:// over TLS. The access key and
+// secret the store itself uses are those of the url, or of the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN environment variables. The endpoint and sts_endpoint query parameters override the endpoints of
+// S3 and STS, for other S3 compatible stores.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/common/sigv4"
+	"github.com/fnproject/fn/api/scratch"
+)
+
+const (
+	// defaultRegion is the region of stores whose url does not set one
+	defaultRegion = "us-east-1"
+	// minDuration and maxDuration bound how long the credentials issued by STS may be valid
+	minDuration = 15 * time.Minute
+	maxDuration = 12 * time.Hour
+	// requestTimeout is how long a request to the store may take
+	requestTimeout = 30 * time.Second
+)
+
+type provider int
+
+func (provider) String() string {
+	return "s3"
+}
+
+func (provider) Supports(u *url.URL) bool {
+	switch u.Scheme {
+	case "s3", "minio", "minios":
+		return true
+	}
+	return false
+}
+
+func (provider) New(ctx context.Context, u *url.URL) (scratch.Store, error) {
+	q := u.Query()
+	cfg := Config{
+		Region:      q.Get("region"),
+		RoleARN:     q.Get("role"),
+		Endpoint:    q.Get("endpoint"),
+		STSEndpoint: q.Get("sts_endpoint"),
+		Credentials: sigv4.Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		},
+	}
+	if u.User != nil {
+		secret, _ := u.User.Password()
+		cfg.Credentials = sigv4.Credentials{AccessKeyID: u.User.Username(), SecretAccessKey: secret}
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+
+	path := strings.Trim(u.Path, "/")
+	if u.Scheme == "s3" {
+		cfg.Bucket, cfg.Prefix = u.Host, path
+		if cfg.RoleARN == "" {
+			return nil, errors.New("no role to assume in the s3 scratch store url")
+		}
+		if cfg.Endpoint == "" {
+			cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+		} else {
+			cfg.PathStyle = true
+		}
+		if cfg.STSEndpoint == "" {
+			cfg.STSEndpoint = "https://sts." + cfg.Region + ".amazonaws.com"
+		}
+	} else {
+		if u.Host == "" {
+			return nil, errors.New("no MinIO host in the s3 scratch store url")
+		}
+		scheme := "http"
+		if u.Scheme == "minios" {
+			scheme = "https"
+		}
+		cfg.Endpoint = scheme + "://" + u.Host
+		cfg.PathStyle = true
+		parts := strings.SplitN(path, "/", 2)
+		cfg.Bucket = parts[0]
+		if len(parts) > 1 {
+			cfg.Prefix = parts[1]
+		}
+		if cfg.STSEndpoint == "" {
+			cfg.STSEndpoint = cfg.Endpoint
+		}
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("no bucket in the s3 scratch store url")
+	}
+	if cfg.Credentials.AccessKeyID == "" || cfg.Credentials.SecretAccessKey == "" {
+		return nil, errors.New("the s3 scratch store needs an access key and secret, in its url or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return New(cfg), nil
+}
+
+func init() {
+	scratch.Register(provider(0))
+}
+
+// Config locates the bucket of a store, and how to reach it
+type Config struct {
+	// Endpoint is the endpoint of the S3 API
+	Endpoint string
+	// PathStyle addresses the bucket in the path of requests, rather than as a subdomain of Endpoint
+	PathStyle bool
+	// STSEndpoint is the endpoint of the STS API
+	STSEndpoint string
+	Region      string
+	Bucket      string
+	// Prefix is the prefix the scratch spaces of apps are under, if any
+	Prefix string
+	// RoleARN is the role assumed for the credentials of apps, which MinIO does not need
+	RoleARN string
+	// Credentials are those of the store, which must be allowed to create the bucket and to assume RoleARN
+	Credentials sigv4.Credentials
+}
+
+type store struct {
+	cfg    Config
+	client *http.Client
+
+	lock sync.Mutex
+	// provisioned is set once the bucket is known to exist
+	provisioned bool
+}
+
+// New returns a Store keeping the scratch space of apps in the bucket of cfg
+func New(cfg Config) scratch.Store {
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	cfg.STSEndpoint = strings.TrimSuffix(cfg.STSEndpoint, "/")
+	return &store{cfg: cfg, client: &http.Client{Timeout: requestTimeout}}
+}
+
+// bucketURL returns the URL of the bucket
+func (s *store) bucketURL() string {
+	if s.cfg.PathStyle {
+		return s.cfg.Endpoint + "/" + s.cfg.Bucket
+	}
+	u, err := url.Parse(s.cfg.Endpoint)
+	if err != nil {
+		return s.cfg.Endpoint + "/" + s.cfg.Bucket
+	}
+	u.Host = s.cfg.Bucket + "." + u.Host
+	return u.String()
+}
+
+func (s *store) do(ctx context.Context, method, url, service string, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", sigv4.SHA256Hex(body))
+	}
+	sigv4.Sign(req, body, s.cfg.Credentials, s.cfg.Region, service, time.Now())
+	return s.client.Do(req.WithContext(ctx))
+}
+
+type createBucketConfiguration struct {
+	XMLName            xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CreateBucketConfiguration"`
+	LocationConstraint string   `xml:"LocationConstraint"`
+}
+
+// provision creates the bucket, unless it exists
+func (s *store) provision(ctx context.Context) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.provisioned {
+		return nil
+	}
+
+	resp, err := s.do(ctx, http.MethodHead, s.bucketURL(), "s3", nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		s.provisioned = true
+		return nil
+	case http.StatusNotFound:
+	default:
+		return fmt.Errorf("s3 returned %s for bucket %s", resp.Status, s.cfg.Bucket)
+	}
+
+	var body []byte
+	// buckets outside of us-east-1 are created with their region
+	if s.cfg.Region != defaultRegion {
+		body, err = xml.Marshal(createBucketConfiguration{LocationConstraint: s.cfg.Region})
+		if err != nil {
+			return err
+		}
+	}
+	resp, err = s.do(ctx, http.MethodPut, s.bucketURL(), "s3", nil, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		if !bytes.Contains(msg, []byte("BucketAlreadyOwnedByYou")) {
+			return fmt.Errorf("s3 returned %s creating bucket %s: %s", resp.Status, s.cfg.Bucket, msg)
+		}
+	}
+	s.provisioned = true
+	return nil
+}
+
+// policy returns the session policy limiting credentials to the objects under prefix
+func (s *store) policy(prefix string) (string, error) {
+	bucket := "arn:aws:s3:::" + s.cfg.Bucket
+	b, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"},
+				"Resource": []string{bucket + "/" + prefix + "*"},
+			},
+			{
+				"Effect":    "Allow",
+				"Action":    []string{"s3:ListBucket"},
+				"Resource":  []string{bucket},
+				"Condition": map[string]interface{}{"StringLike": map[string][]string{"s3:prefix": {prefix + "*"}}},
+			},
+		},
+	})
+	return string(b), err
+}
+
+type assumeRoleResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleResult>Credentials"`
+}
+
+// assumeRole issues credentials limited by policy, valid for duration
+func (s *store) assumeRole(ctx context.Context, appID, policy string, duration time.Duration) (*assumeRoleResponse, error) {
+	form := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"DurationSeconds": {strconv.Itoa(int(duration / time.Second))},
+		"Policy":          {policy},
+		"RoleSessionName": {"fn-scratch-" + appID},
+	}
+	if s.cfg.RoleARN != "" {
+		form.Set("RoleArn", s.cfg.RoleARN)
+	}
+	header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	resp, err := s.do(ctx, http.MethodPost, s.cfg.STSEndpoint+"/", "sts", header, []byte(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sts returned %s: %s", resp.Status, b)
+	}
+	var creds assumeRoleResponse
+	if err := xml.Unmarshal(b, &creds); err != nil {
+		return nil, fmt.Errorf("bad sts response: %v", err)
+	}
+	return &creds, nil
+}
+
+func (s *store) Grant(ctx context.Context, appID string, ttl time.Duration) (*scratch.Grant, error) {
+	if err := s.provision(ctx); err != nil {
+		return nil, err
+	}
+
+	prefix := scratch.AppPrefix(s.cfg.Prefix, appID)
+	policy, err := s.policy(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if ttl < minDuration {
+		ttl = minDuration
+	} else if ttl > maxDuration {
+		ttl = maxDuration
+	}
+	creds, err := s.assumeRole(ctx, appID, policy, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scratch.Grant{
+		URL:      "s3://" + s.cfg.Bucket + "/" + prefix,
+		Endpoint: s.cfg.Endpoint,
+		Region:   s.cfg.Region,
+		Bucket:   s.cfg.Bucket,
+		Prefix:   prefix,
+		Env: map[string]string{
+			"AWS_ACCESS_KEY_ID":     creds.Credentials.AccessKeyID,
+			"AWS_SECRET_ACCESS_KEY": creds.Credentials.SecretAccessKey,
+			"AWS_SESSION_TOKEN":     creds.Credentials.SessionToken,
+			"AWS_REGION":            s.cfg.Region,
+			"AWS_ENDPOINT_URL_S3":   s.cfg.Endpoint,
+		},
+		Expires: creds.Credentials.Expiration,
+	}, nil
+}
@@ -0,0 +1,92 @@
+package s3
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/scratch"
+)
+
+func TestGrant(t *testing.T) {
+	var created int
+	var policy string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.Method == http.MethodHead && r.URL.Path == "/scratch":
+			if created == 0 {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == http.MethodPut && r.URL.Path == "/scratch":
+			if r.Header.Get("X-Amz-Content-Sha256") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			created++
+		case r.Method == http.MethodPost && r.URL.Path == "/":
+			b, _ := ioutil.ReadAll(r.Body)
+			form, _ := url.ParseQuery(string(b))
+			if form.Get("Action") != "AssumeRole" || form.Get("DurationSeconds") != "3600" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			policy = form.Get("Policy")
+			w.Write([]byte(`<AssumeRoleResponse><AssumeRoleResult><Credentials>
+<AccessKeyId>AKID</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken>
+<Expiration>2030-01-01T00:00:00Z</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	u := strings.Replace(srv.URL, "http://", "minio://minio:minio123@", 1) + "/scratch/fns"
+	st, err := scratch.New(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		grant, err := st.Grant(context.Background(), "app_id", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if grant.URL != "s3://scratch/fns/app_id/" || grant.Bucket != "scratch" || grant.Prefix != "fns/app_id/" || grant.Endpoint != srv.URL {
+			t.Fatalf("unexpected grant %+v", grant)
+		}
+		if grant.Env["AWS_ACCESS_KEY_ID"] != "AKID" || grant.Env["AWS_SECRET_ACCESS_KEY"] != "secret" || grant.Env["AWS_SESSION_TOKEN"] != "token" {
+			t.Fatalf("unexpected credentials %v", grant.Env)
+		}
+		if !grant.Expires.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("unexpected expiry %v", grant.Expires)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("expected the bucket to be created once, got %d", created)
+	}
+	if !strings.Contains(policy, `"arn:aws:s3:::scratch/fns/app_id/*"`) || !strings.Contains(policy, `"s3:prefix":["fns/app_id/*"]`) {
+		t.Fatalf("expected the policy to be scoped to the prefix of the app, got %s", policy)
+	}
+}
+
+func TestNewNeedsRole(t *testing.T) {
+	if _, err := scratch.New(context.Background(), "s3://AKID:secret@scratch/fns?region=us-west-2"); err == nil {
+		t.Fatal("expected an error without a role to assume")
+	}
+	st, err := scratch.New(context.Background(), "s3://AKID:secret@scratch/fns?region=us-west-2&role=arn:aws:iam::123456789012:role/scratch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := st.(*store).bucketURL(); u != "https://scratch.s3.us-west-2.amazonaws.com" {
+		t.Fatalf("expected a virtual hosted bucket, got %s", u)
+	}
+}
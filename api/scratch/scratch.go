@@ -0,0 +1,88 @@
+// Package scratch provisions the scratch space of apps in object stores, where their fns write outputs larger than
+// the response limit, and issues credentials scoped to it for the containers of the fns.
+package scratch
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/sirupsen/logrus"
+)
+
+// Grant is the scratch space of an app, and credentials that may only access it
+type Grant struct {
+	// URL locates the scratch space, eg. s3://bucket/prefix/app_id/
+	URL string
+	// Endpoint is the API endpoint of the object store
+	Endpoint string
+	// Region is the region of the object store, if it has regions
+	Region string
+	// Bucket is the bucket of the scratch space
+	Bucket string
+	// Prefix is the prefix of the objects of the scratch space in Bucket, ending in /
+	Prefix string
+	// Env holds the credentials, as the env vars the clients of the object store read them from
+	Env map[string]string
+	// Expires is when the credentials expire
+	Expires time.Time
+}
+
+// Store is an object store holding the scratch space of apps
+type Store interface {
+	// Grant provisions the scratch space of app appID, if it was not yet, and returns credentials to it that are
+	// valid for ttl, or for as long as the store allows
+	Grant(ctx context.Context, appID string, ttl time.Duration) (*Grant, error)
+}
+
+// Provider is a scratch store provider
+type Provider interface {
+	fmt.Stringer
+	// Supports indicates if this provider can handle a given scratch store url
+	Supports(url *url.URL) bool
+	// New creates a Store from the specified url
+	New(ctx context.Context, url *url.URL) (Store, error)
+}
+
+var providers []Provider
+
+// Register globally registers a scratch store provider
+func Register(provider Provider) {
+	logrus.Infof("Registering scratch store provider '%s'", provider)
+	providers = append(providers, provider)
+}
+
+// Providers returns the names of the registered scratch store providers
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		names = append(names, provider.String())
+	}
+	return names
+}
+
+// New creates a Store from the specified url
+func New(ctx context.Context, storeURL string) (Store, error) {
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return nil, fmt.Errorf("bad scratch store url: %v", err)
+	}
+	common.Logger(ctx).WithFields(logrus.Fields{"scratch": u.Scheme}).Debug("creating new scratch store")
+
+	for _, provider := range providers {
+		if provider.Supports(u) {
+			return provider.New(ctx, u)
+		}
+	}
+	return nil, fmt.Errorf("no scratch store provider found for url scheme %s", u.Scheme)
+}
+
+// AppPrefix returns the prefix of the scratch space of app appID under prefix
+func AppPrefix(prefix, appID string) string {
+	if prefix != "" && prefix[len(prefix)-1] != '/' {
+		prefix += "/"
+	}
+	return prefix + appID + "/"
+}
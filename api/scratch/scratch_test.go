@@ -0,0 +1,20 @@
+package scratch
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAppPrefix(t *testing.T) {
+	for prefix, expected := range map[string]string{"": "app_id/", "fn": "fn/app_id/", "fn/": "fn/app_id/", "fn/scratch": "fn/scratch/app_id/"} {
+		if p := AppPrefix(prefix, "app_id"); p != expected {
+			t.Errorf("expected %s under %q, got %s", expected, prefix, p)
+		}
+	}
+}
+
+func TestNewUnknownScheme(t *testing.T) {
+	if _, err := New(context.Background(), "nope://bucket"); err == nil {
+		t.Fatal("expected no provider for an unknown scheme")
+	}
+}
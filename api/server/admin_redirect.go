@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// legacyAdminPaths are the admin endpoints served at the root of the admin server before they were grouped under
+// /v2/admin. Requests to them are redirected to the same endpoints under /v2/admin with a 308, which keeps their
+// method and body, so that scripts and clients of the old paths keep working. New admin endpoints go under
+// /v2/admin only.
+var legacyAdminPaths = []string{
+	"/diagnostics/crashes",
+	"/diagnostics/placements",
+	"/config",
+	"/config/runtime",
+	"/config/runtime/:name",
+	"/runner/tokens",
+	"/runner/tokens/:token_id",
+	"/runner/tokens/:token_id/promote",
+	"/registry/cas",
+	"/registry/cas/:registry",
+	"/faults",
+	"/faults/:fault_id",
+	"/benchmarks",
+}
+
+// redirectToV2Admin redirects a request to one of the legacyAdminPaths to its endpoint under /v2/admin
+func redirectToV2Admin(c *gin.Context) {
+	u := *c.Request.URL
+	u.Path = "/v2/admin" + u.Path
+	if u.RawPath != "" {
+		u.RawPath = "/v2/admin" + u.RawPath
+	}
+	c.Redirect(http.StatusPermanentRedirect, u.RequestURI())
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+)
+
+func TestLegacyAdminPathsRedirect(t *testing.T) {
+	ds := datastore.NewMock()
+	srv := testServer(ds, nil, ServerTypeAPI, WithAdminToken("secret"))
+
+	for i, test := range []struct {
+		method, path, expected string
+	}{
+		{http.MethodGet, "/config", "/v2/admin/config"},
+		{http.MethodPut, "/config/runtime/FN_MAX_QUEUED_REQUESTS", "/v2/admin/config/runtime/FN_MAX_QUEUED_REQUESTS"},
+		{http.MethodPost, "/runner/tokens", "/v2/admin/runner/tokens"},
+		{http.MethodDelete, "/runner/tokens/tok1", "/v2/admin/runner/tokens/tok1"},
+		{http.MethodPost, "/runner/tokens/tok1/promote", "/v2/admin/runner/tokens/tok1/promote"},
+		{http.MethodGet, "/diagnostics/placements?call_id=call0&limit=1", "/v2/admin/diagnostics/placements?call_id=call0&limit=1"},
+		{http.MethodDelete, "/registry/cas/registry.example.com:5000", "/v2/admin/registry/cas/registry.example.com:5000"},
+	} {
+		req := createRequest(t, test.method, test.path, nil)
+		_, rec := routerRequest2(t, srv.AdminRouter, req)
+		if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != test.expected {
+			t.Errorf("Test %d: expected a redirect to %s, got %d %s", i, test.expected, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+
+	// the endpoints themselves are served under /v2/admin only
+	req := createRequest(t, http.MethodGet, "/v2/admin/runner/tokens", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	if _, rec := routerRequest2(t, srv.AdminRouter, req); rec.Code != http.StatusOK {
+		t.Errorf("expected the runner tokens to be listed under /v2/admin, got %d", rec.Code)
+	}
+}
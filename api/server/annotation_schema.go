@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fnproject/fn/api/jsonschema"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+)
+
+// annotationSchema is a JSON Schema that applies to all annotation keys with
+// a given prefix. The annotations in the namespace are validated as a single
+// object whose properties are the keys with the namespace removed, so a
+// schema for "example.com/" with `"additionalProperties": false` will reject
+// a mistyped "example.com/teir" annotation.
+type annotationSchema struct {
+	namespace string
+	schema    *jsonschema.Schema
+}
+
+// annotationSchemas validates app, fn and trigger annotations on create and update
+type annotationSchemas struct {
+	schemas []annotationSchema
+	// datastore lookups are required on update, as updates carry only the changed annotations
+	ds func() models.Datastore
+}
+
+var _ fnext.AppListener = new(annotationSchemas)
+var _ fnext.FnListener = new(annotationSchemas)
+var _ fnext.TriggerListener = new(annotationSchemas)
+
+var errAnnotationSchema = errors.New("Annotations do not match the schema registered for their namespace")
+
+// AddAnnotationSchema registers a JSON Schema for all annotation keys starting with namespace, e.g.
+// "example.com/". Apps, fns and triggers that set any annotation in the namespace must then satisfy
+// the schema, otherwise creates and updates fail with the invalid fields listed in the error.
+func (s *Server) AddAnnotationSchema(namespace string, schema []byte) error {
+	if namespace == "" {
+		return errors.New("annotation schema namespace must not be empty")
+	}
+	compiled, err := jsonschema.Parse(schema)
+	if err != nil {
+		return fmt.Errorf("invalid annotation schema for %s: %v", namespace, err)
+	}
+
+	if s.annotationSchemas == nil {
+		s.annotationSchemas = &annotationSchemas{ds: func() models.Datastore { return s.datastore }}
+		s.AddAppListener(s.annotationSchemas)
+		s.AddFnListener(s.annotationSchemas)
+		s.AddTriggerListener(s.annotationSchemas)
+	}
+	s.annotationSchemas.schemas = append(s.annotationSchemas.schemas, annotationSchema{namespace, compiled})
+	return nil
+}
+
+// WithAnnotationSchema registers a JSON Schema for annotations in a namespace, see AddAnnotationSchema
+func WithAnnotationSchema(namespace string, schema []byte) Option {
+	return func(ctx context.Context, s *Server) error {
+		return s.AddAnnotationSchema(namespace, schema)
+	}
+}
+
+// touches returns whether any of the annotations fall into a registered namespace
+func (a *annotationSchemas) touches(annotations models.Annotations) bool {
+	for k := range annotations {
+		for _, s := range a.schemas {
+			if strings.HasPrefix(k, s.namespace) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a *annotationSchemas) validate(annotations models.Annotations) error {
+	var fields []models.FieldError
+	for _, s := range a.schemas {
+		doc := make(map[string]interface{})
+		for k := range annotations {
+			if !strings.HasPrefix(k, s.namespace) {
+				continue
+			}
+			raw, _ := annotations.Get(k)
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return models.ErrInvalidAnnotationValue
+			}
+			doc[strings.TrimPrefix(k, s.namespace)] = v
+		}
+		if len(doc) == 0 {
+			continue
+		}
+
+		for _, fe := range s.schema.Validate(doc) {
+			fields = append(fields, models.FieldError{Field: "annotations." + s.namespace + fe.Path, Code: models.FieldInvalid, Message: fe.Message})
+		}
+	}
+
+	if len(fields) > 0 {
+		return models.NewValidationError(http.StatusBadRequest, errAnnotationSchema, fields...)
+	}
+	return nil
+}
+
+func (a *annotationSchemas) BeforeAppCreate(ctx context.Context, app *models.App) error {
+	return a.validate(app.Annotations)
+}
+
+func (a *annotationSchemas) BeforeAppUpdate(ctx context.Context, app *models.App) error {
+	if !a.touches(app.Annotations) {
+		return nil
+	}
+	old, err := a.ds().GetAppByID(ctx, app.ID)
+	if err != nil {
+		return err
+	}
+	return a.validate(old.Annotations.MergeChange(app.Annotations))
+}
+
+func (a *annotationSchemas) BeforeFnCreate(ctx context.Context, fn *models.Fn) error {
+	return a.validate(fn.Annotations)
+}
+
+func (a *annotationSchemas) BeforeFnUpdate(ctx context.Context, fn *models.Fn) error {
+	if !a.touches(fn.Annotations) {
+		return nil
+	}
+	old, err := a.ds().GetFnByID(ctx, fn.ID)
+	if err != nil {
+		return err
+	}
+	return a.validate(old.Annotations.MergeChange(fn.Annotations))
+}
+
+func (a *annotationSchemas) BeforeTriggerCreate(ctx context.Context, t *models.Trigger) error {
+	return a.validate(t.Annotations)
+}
+
+func (a *annotationSchemas) BeforeTriggerUpdate(ctx context.Context, t *models.Trigger) error {
+	if !a.touches(t.Annotations) {
+		return nil
+	}
+	old, err := a.ds().GetTriggerByID(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	return a.validate(old.Annotations.MergeChange(t.Annotations))
+}
+
+func (a *annotationSchemas) AfterAppCreate(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (a *annotationSchemas) AfterAppUpdate(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (a *annotationSchemas) BeforeAppDelete(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (a *annotationSchemas) AfterAppDelete(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (a *annotationSchemas) BeforeAppGet(ctx context.Context, appID string) error {
+	return nil
+}
+
+func (a *annotationSchemas) AfterAppGet(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (a *annotationSchemas) BeforeAppsList(ctx context.Context, filter *models.AppFilter) error {
+	return nil
+}
+
+func (a *annotationSchemas) AfterAppsList(ctx context.Context, apps []*models.App) error {
+	return nil
+}
+
+func (a *annotationSchemas) AfterFnCreate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (a *annotationSchemas) AfterFnUpdate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (a *annotationSchemas) BeforeFnDelete(ctx context.Context, fnID string) error {
+	return nil
+}
+
+func (a *annotationSchemas) AfterFnDelete(ctx context.Context, fnID string) error {
+	return nil
+}
+
+func (a *annotationSchemas) AfterTriggerCreate(ctx context.Context, t *models.Trigger) error {
+	return nil
+}
+
+func (a *annotationSchemas) AfterTriggerUpdate(ctx context.Context, t *models.Trigger) error {
+	return nil
+}
+
+func (a *annotationSchemas) BeforeTriggerDelete(ctx context.Context, triggerID string) error {
+	return nil
+}
+
+func (a *annotationSchemas) AfterTriggerDelete(ctx context.Context, triggerID string) error {
+	return nil
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+const testAnnotationSchema = `{
+	"type": "object",
+	"properties": {
+		"tier": {"type": "string", "enum": ["gold", "silver"]},
+		"owner": {"type": "string"}
+	},
+	"required": ["owner"],
+	"additionalProperties": false
+}`
+
+func TestAnnotationSchemaValidation(t *testing.T) {
+	buf := setLogBuffer()
+
+	a := &models.App{Name: "a", ID: "app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID, Image: "fnproject/fn-test-utils"}
+	f.SetDefaults()
+	f.Annotations, _ = f.Annotations.With("example.com/owner", "ops")
+	ds := datastore.NewMockInit([]*models.App{a}, []*models.Fn{f})
+
+	rnr, cancel := testRunner(t)
+	defer cancel()
+	srv := testServer(ds, rnr, ServerTypeFull, WithAnnotationSchema("example.com/", []byte(testAnnotationSchema)))
+
+	for i, test := range []struct {
+		method         string
+		path           string
+		body           string
+		expectedCode   int
+		expectedFields string
+	}{
+		// annotations outside of the namespace are not checked
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "other", "image": "fnproject/fn-test-utils", "annotations": {"k": "v"}}`, http.StatusOK, ""},
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "good", "image": "fnproject/fn-test-utils", "annotations": {"example.com/owner": "ops", "example.com/tier": "gold"}}`, http.StatusOK, ""},
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "typo", "image": "fnproject/fn-test-utils", "annotations": {"example.com/owner": "ops", "example.com/teir": "gold"}}`, http.StatusBadRequest, "annotations.example.com/teir: unknown property"},
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "noowner", "image": "fnproject/fn-test-utils", "annotations": {"example.com/tier": "gold"}}`, http.StatusBadRequest, "annotations.example.com/owner: is required"},
+		{http.MethodPost, "/v2/apps", `{"name": "badapp", "annotations": {"example.com/owner": 1}}`, http.StatusBadRequest, "annotations.example.com/owner: expected string, got integer"},
+
+		// updates are checked against the merged annotations
+		{http.MethodPut, fmt.Sprintf("/v2/fns/%s", f.ID), `{"annotations": {"example.com/tier": "silver"}}`, http.StatusOK, ""},
+		{http.MethodPut, fmt.Sprintf("/v2/fns/%s", f.ID), `{"annotations": {"example.com/tier": "bronze"}}`, http.StatusBadRequest, `annotations.example.com/tier: value must be one of ["gold","silver"]`},
+		{http.MethodPut, fmt.Sprintf("/v2/fns/%s", f.ID), `{"annotations": {"example.com/owner": ""}}`, http.StatusBadRequest, "annotations.example.com/owner: is required"},
+	} {
+		_, rec := routerRequest(t, srv.Router, test.method, test.path, bytes.NewBufferString(test.body))
+
+		if rec.Code != test.expectedCode {
+			t.Log(buf.String())
+			t.Fatalf("Test %d: Expected status code to be %d but was %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+
+		if test.expectedFields != "" {
+			resp := getErrorResponse(t, rec)
+			if resp.Fields != test.expectedFields {
+				t.Errorf("Test %d: Expected error fields to be `%s`, but was `%s`", i, test.expectedFields, resp.Fields)
+			}
+		}
+		buf.Reset()
+	}
+}
+
+func TestAnnotationSchemaInvalid(t *testing.T) {
+	srv := &Server{appListeners: new(appListeners), fnListeners: new(fnListeners), triggerListeners: new(triggerListeners)}
+	if err := srv.AddAnnotationSchema("example.com/", []byte(`{"type": "widget"}`)); err == nil {
+		t.Fatal("expected an error registering an invalid schema")
+	}
+	if err := srv.AddAnnotationSchema("", []byte(`{}`)); err == nil {
+		t.Fatal("expected an error registering a schema with no namespace")
+	}
+}
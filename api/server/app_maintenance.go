@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// serveMaintenance writes the static response of an app in maintenance m to w
+func serveMaintenance(w http.ResponseWriter, m *models.AppMaintenance) {
+	contentType := m.ContentType
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(m.Body)))
+	if m.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(m.RetryAfter))
+	}
+	w.WriteHeader(m.StatusCode())
+	w.Write([]byte(m.Body))
+}
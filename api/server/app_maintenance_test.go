@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAppMaintenance(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "myapp", Annotations: models.Annotations{}}
+	var err error
+	app.Annotations, err = app.Annotations.With(models.AppMaintenanceAnnotation, &models.AppMaintenance{
+		Status: http.StatusOK, Body: `{"message": "back soon"}`, ContentType: "application/json", RetryAfter: 600,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := datastore.NewMockInit([]*models.App{app})
+	s := &Server{datastore: ds, lbReadAccess: ds}
+	engine := gin.New()
+	engine.Any("/t/:app_name/*trigger_source", s.handleHTTPTriggerCall)
+
+	// the trigger is not looked up, so paths without one respond too
+	for _, path := range []string{"/t/myapp/hello", "/t/myapp/nope"} {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != `{"message": "back soon"}` {
+			t.Errorf("%s: expected the maintenance response, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("Retry-After") != "600" {
+			t.Errorf("%s: expected the maintenance headers, got %v", path, rec.Header())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	serveMaintenance(rec, &models.AppMaintenance{})
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Content-Type") != "text/plain" || rec.Header().Get("Retry-After") != "" {
+		t.Errorf("expected the default maintenance response, got %d: %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// handleAppClone creates a copy of an app, with the name and overrides of the request, along with copies of its fns
+// and their triggers. Nothing is created if any copy fails.
+func (s *Server) handleAppClone(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	source, err := s.datastore.GetAppByID(ctx, c.Param(api.AppID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	overrides := &models.App{}
+	if err := c.BindJSON(overrides); err != nil {
+		if models.IsAPIError(err) {
+			handleErrorResponse(c, err)
+		} else {
+			handleErrorResponse(c, models.ErrInvalidJSON)
+		}
+		return
+	}
+
+	app, err := s.datastore.InsertApp(ctx, models.CloneApp(source, overrides))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	if err := s.cloneAppContents(ctx, source, app); err != nil {
+		if rmErr := s.datastore.RemoveApp(ctx, app.ID); rmErr != nil {
+			common.Logger(ctx).WithError(rmErr).WithField("app_id", app.ID).Error("failed to remove partially cloned app")
+		}
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, app)
+}
+
+// cloneAppContents copies the fns of source, and their triggers, to app
+func (s *Server) cloneAppContents(ctx context.Context, source, app *models.App) error {
+	fnIDs := make(map[string]string)
+	var chained []*models.Fn
+	filter := &models.FnFilter{AppID: source.ID}
+	for {
+		fns, err := s.datastore.GetFns(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, fn := range fns.Items {
+			clone := models.CloneFn(fn, &models.Fn{Name: fn.Name, AppID: app.ID})
+			created, err := s.datastore.InsertFn(ctx, clone)
+			if err != nil {
+				return err
+			}
+			fnIDs[fn.ID] = created.ID
+			if !created.Chain.IsEmpty() {
+				chained = append(chained, created)
+			}
+		}
+		if fns.NextCursor == "" {
+			break
+		}
+		filter.Cursor = fns.NextCursor
+	}
+
+	// chains within the app are to the copies of their fns
+	for _, fn := range chained {
+		chain := *fn.Chain
+		if id, ok := fnIDs[chain.OnSuccess]; ok {
+			chain.OnSuccess = id
+		}
+		if id, ok := fnIDs[chain.OnFailure]; ok {
+			chain.OnFailure = id
+		}
+		if chain != *fn.Chain {
+			if _, err := s.datastore.UpdateFn(ctx, &models.Fn{ID: fn.ID, Chain: &chain}); err != nil {
+				return err
+			}
+		}
+	}
+
+	triggerFilter := &models.TriggerFilter{AppID: source.ID}
+	for {
+		triggers, err := s.datastore.GetTriggers(ctx, triggerFilter)
+		if err != nil {
+			return err
+		}
+		for _, t := range triggers.Items {
+			clone := t.Clone()
+			clone.ID = ""
+			clone.AppID = app.ID
+			clone.FnID = fnIDs[t.FnID]
+			clone.CreatedAt, clone.UpdatedAt = common.DateTime{}, common.DateTime{}
+			if _, err := s.datastore.InsertTrigger(ctx, clone); err != nil {
+				return err
+			}
+		}
+		if triggers.NextCursor == "" {
+			break
+		}
+		triggerFilter.Cursor = triggers.NextCursor
+	}
+	return nil
+}
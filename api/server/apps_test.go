@@ -359,3 +359,23 @@ func TestAppUpdate(t *testing.T) {
 
 	}
 }
+
+func TestAppCreateFieldErrors(t *testing.T) {
+	srv := testServer(datastore.NewMock(), nil, ServerTypeAPI)
+
+	for body, expected := range map[string]models.FieldError{
+		`{"name": ""}`:       {Field: "name", Code: models.FieldRequired, Message: models.ErrMissingName.Error()},
+		`{"name": "my app"}`: {Field: "name", Code: models.FieldInvalid, Message: models.ErrAppsInvalidName.Error()},
+		`{"name": "app", "annotations": {"fnproject.io/app/quota": 1}}`: {Field: "annotations.fnproject.io/app/quota", Code: models.FieldInvalid, Message: models.ErrAppInvalidQuota.Error()},
+	} {
+		_, rec := routerRequest(t, srv.Router, http.MethodPost, "/v2/apps", strings.NewReader(body))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected %s to be rejected, got %d", body, rec.Code)
+			continue
+		}
+		resp := getErrorResponse(t, rec)
+		if len(resp.Errors) != 1 || resp.Errors[0] != expected {
+			t.Errorf("expected %s to fail with %+v, got %+v", body, expected, resp.Errors)
+		}
+	}
+}
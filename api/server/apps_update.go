@@ -40,3 +40,33 @@ func (s *Server) handleAppUpdate(c *gin.Context) {
 
 	c.JSON(http.StatusOK, app)
 }
+
+// handleAppPatch updates an app with a JSON merge patch or JSON patch of it, see patchUpdate
+func (s *Server) handleAppPatch(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param(api.AppID)
+
+	app, err := s.datastore.GetAppByID(ctx, id)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	update := &models.App{}
+	if err := patchUpdate(c, app, update); err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	if update.ID != "" && update.ID != id {
+		handleErrorResponse(c, models.ErrAppsIDMismatch)
+		return
+	}
+	update.ID = id
+
+	app, err = s.datastore.UpdateApp(ctx, update)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, app)
+}
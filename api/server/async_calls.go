@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+	"go.opencensus.io/trace"
+)
+
+// asyncCallExpiry is how long past its timeout a detached call may stay queued or
+// running before it is considered lost, this covers placement, image pulls and
+// the agent's detached headroom (FN_EXECUTION_HEADROOM).
+const asyncCallExpiry = 10 * time.Minute
+
+// asyncCalls persists detached calls in the datastore, driving each through the
+// call state machine as the agent starts and ends it. Sync calls sampled by the
+// FnCaptureAnnotation of their fn are persisted the same way.
+//
+// Detached calls run on the node that takes them, unless the server delivers
+// them through a message queue (see WithMessageQueue), in which case they are
+// recorded queued as they are pushed and run by the node consuming them. Calls
+// of a node that restarts are otherwise lost (see asyncCallExpiry).
+type asyncCalls struct {
+	ds        func() models.Datastore
+	keys      func() agent.KeyProvider
+	redactors func() []fnext.CallInputRedactor
+	// sampled are the ids of the sync calls being persisted
+	sampled sync.Map
+}
+
+var _ fnext.CallListener = new(asyncCalls)
+
+// sample returns whether a sync call of a fn with the capture policy is to be
+// persisted with its input
+func (a *asyncCalls) sample(capture *models.FnCapture) bool {
+	return a != nil && capture != nil && rand.Float64()*100 < capture.Percent
+}
+
+// capture reads the start of the body of req, up to size bytes, to record as
+// the input of its call. The body is left for the fn to read as it was.
+func (a *asyncCalls) capture(req *http.Request, size int) (input []byte, replayable bool) {
+	if a == nil {
+		return nil, false
+	}
+	if req.Body == nil {
+		return nil, true
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(req.Body, int64(size)+1))
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), req.Body), req.Body}
+	if err != nil || len(buf) > size {
+		return nil, false
+	}
+	return buf, true
+}
+
+// enqueue records call as queued with its input, before it is submitted to the
+// agent. The input is redacted and encrypted, and expires with the ttl of the
+// capture policy of the fn, if it has one.
+func (a *asyncCalls) enqueue(ctx context.Context, req *http.Request, call *models.Call, input []byte, replayable bool, capture *models.FnCapture) (err error) {
+	if a == nil {
+		return nil
+	}
+	ctx, span := trace.StartSpan(ctx, "async_enqueue")
+	defer func() { common.EndSpan(span, err) }()
+	queued := &models.Call{
+		ID:          call.ID,
+		FnID:        call.FnID,
+		AppID:       call.AppID,
+		TriggerID:   call.TriggerID,
+		Status:      models.CallStateQueued,
+		Timeout:     call.Timeout,
+		CreatedAt:   call.CreatedAt,
+		ContentType: req.Header.Get("Content-Type"),
+	}
+	if replay, ok := ctx.Value(callReplayKey{}).(*callReplay); ok {
+		queued.ReplayOf, queued.DeploymentID = replay.of, replay.deploymentID
+	}
+	if replayable {
+		queued.Input, queued.InputKey, err = a.seal(ctx, queued, input, capture)
+		if err != nil {
+			// the input is for debugging, the call is made without it
+			common.Logger(ctx).WithError(err).WithField("call_id", call.ID).Info("call input not recorded")
+			queued.Input, queued.InputKey = nil, ""
+		} else {
+			queued.Replayable = true
+			if ttl := capture.Duration(); ttl > 0 {
+				queued.InputExpiresAt = common.DateTime(time.Now().Add(ttl))
+			}
+		}
+	}
+
+	err = a.ds().InsertCall(ctx, queued)
+	if err == nil && call.Type != models.TypeDetached {
+		a.sampled.Store(call.ID, struct{}{})
+	}
+	return err
+}
+
+// seal passes the input of call through the redactors and encrypts it, if
+// there are keys to encrypt it with
+func (a *asyncCalls) seal(ctx context.Context, call *models.Call, input []byte, capture *models.FnCapture) (_ []byte, key string, err error) {
+	if capture != nil && len(capture.Redact) > 0 {
+		if input, err = redactJSONFields(call.ContentType, input, capture.Redact); err != nil {
+			return nil, "", err
+		}
+	}
+	if a.redactors != nil {
+		for _, r := range a.redactors() {
+			if input, err = r.RedactCallInput(ctx, call, call.ContentType, input); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if a.keys != nil {
+		if keys := a.keys(); keys != nil {
+			return sealCallInput(ctx, keys, call.ID, input)
+		}
+	}
+	return input, "", nil
+}
+
+// persisted returns whether call is persisted, as detached calls and sampled
+// sync calls are
+func (a *asyncCalls) persisted(call *models.Call) bool {
+	if call.Type == models.TypeDetached {
+		return true
+	}
+	_, ok := a.sampled.Load(call.ID)
+	return ok
+}
+
+// abort ends a call that the agent refused or failed to start. Calls that did
+// start have already been ended by AfterCall, and are left alone.
+func (a *asyncCalls) abort(ctx context.Context, call *models.Call, err error) {
+	if a == nil {
+		return
+	}
+	defer a.sampled.Delete(call.ID)
+	update := &models.Call{
+		ID:          call.ID,
+		Status:      models.CallStateFailed,
+		Error:       err.Error(),
+		CompletedAt: common.DateTime(time.Now()),
+	}
+	if err == context.Canceled {
+		update.Status = models.CallStateCancelled
+	}
+	a.update(ctx, update, models.CallStateQueued)
+}
+
+func (a *asyncCalls) update(ctx context.Context, call *models.Call, from string) (err error) {
+	ctx, span := trace.StartSpan(ctx, "async_update_call_state")
+	span.AddAttributes(trace.StringAttribute("call_id", call.ID), trace.StringAttribute("status", call.Status))
+	defer func() { common.EndSpan(span, err) }()
+
+	err = a.ds().UpdateCallState(ctx, call, from)
+	if err != nil && err != models.ErrCallInvalidTransition {
+		common.Logger(ctx).WithError(err).WithField("call_id", call.ID).Error("failed to update call state")
+	}
+	return err
+}
+
+// BeforeCall moves a persisted call to running. A detached call that has since
+// been cancelled or expired is not started.
+func (a *asyncCalls) BeforeCall(ctx context.Context, call *models.Call) error {
+	if !a.persisted(call) {
+		return nil
+	}
+	err := a.update(ctx, &models.Call{
+		ID:        call.ID,
+		Status:    models.CallStateRunning,
+		StartedAt: call.StartedAt,
+	}, models.CallStateQueued)
+	if err == models.ErrCallInvalidTransition && call.Type == models.TypeDetached {
+		return err
+	}
+	return nil
+}
+
+// AfterCall moves a persisted call to its terminal state
+func (a *asyncCalls) AfterCall(ctx context.Context, call *models.Call) error {
+	if !a.persisted(call) {
+		return nil
+	}
+	defer a.sampled.Delete(call.ID)
+	update := &models.Call{
+		ID:          call.ID,
+		StartedAt:   call.StartedAt,
+		CompletedAt: call.CompletedAt,
+	}
+	update.Status, update.Error = callEndState(call)
+	// the call has already ended, failing here would only replace its result
+	a.update(common.BackgroundContext(ctx), update, models.CallStateRunning)
+	return nil
+}
+
+// callEndState maps the status the agent ended call with to its terminal
+// CallState, and the error to record with it
+func callEndState(call *models.Call) (string, string) {
+	switch {
+	case call.Status == "success":
+		return models.CallStateSucceeded, call.Error
+	case call.Status == "cached":
+		return models.CallStateCached, call.Error
+	case call.Status == "timeout":
+		return models.CallStateFailed, models.ErrCallTimeout.Error()
+	case call.Error == context.Canceled.Error():
+		return models.CallStateCancelled, call.Error
+	default:
+		return models.CallStateFailed, call.Error
+	}
+}
+
+// expireCall moves call to expired if it has outlived its deadline without ending,
+// eg. because the server running it died. This is done as calls are read, so that
+// api nodes can expire calls without an agent. Concurrent completions win the
+// race, in which case the stored call is returned. Recorded inputs past their
+// ttl are removed on the way.
+func expireCall(ctx context.Context, ds models.Datastore, call *models.Call) (*models.Call, error) {
+	call = expireCallInput(ctx, ds, call)
+	if models.IsTerminalCallState(call.Status) {
+		return call, nil
+	}
+	deadline := time.Time(call.CreatedAt).Add(time.Duration(call.Timeout)*time.Second + asyncCallExpiry)
+	if time.Now().Before(deadline) {
+		return call, nil
+	}
+
+	expired := *call
+	expired.Status = models.CallStateExpired
+	expired.CompletedAt = common.DateTime(time.Now())
+	err := ds.UpdateCallState(ctx, &expired, call.Status)
+	if err == models.ErrCallInvalidTransition {
+		return ds.GetCall(ctx, call.FnID, call.ID)
+	} else if err != nil {
+		return nil, err
+	}
+	return &expired, nil
+}
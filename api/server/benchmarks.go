@@ -0,0 +1,262 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxBenchmarkCalls is the most calls a benchmark may make
+	maxBenchmarkCalls = 10000
+	// maxBenchmarkConcurrency is the most calls a benchmark may make at once
+	maxBenchmarkConcurrency = 256
+	// maxBenchmarkPayload is the largest payload a benchmark may send
+	maxBenchmarkPayload = 1024 * 1024
+)
+
+// ErrInvalidBenchmark is returned for benchmarks that are not valid
+var ErrInvalidBenchmark = models.NewAPIError(http.StatusBadRequest, fmt.Errorf("Invalid benchmark, it must have a "+
+	"fn_id, up to %d calls, a concurrency of up to %d and a payload_size of up to %d bytes, or a payload",
+	maxBenchmarkCalls, maxBenchmarkConcurrency, maxBenchmarkPayload))
+
+// Benchmark is a run of synthetic calls to a fn
+type Benchmark struct {
+	FnID string `json:"fn_id"`
+	// Calls is how many calls to make, 100 by default
+	Calls int `json:"calls,omitempty"`
+	// Concurrency is how many calls to make at once, 1 by default
+	Concurrency int `json:"concurrency,omitempty"`
+	// PayloadSize is the size of the payload of the calls, in bytes, if Payload is not set
+	PayloadSize int `json:"payload_size,omitempty"`
+	// Payload is the payload of the calls
+	Payload string `json:"payload,omitempty"`
+}
+
+func (b *Benchmark) validate() error {
+	if b.Calls == 0 {
+		b.Calls = 100
+	}
+	if b.Concurrency == 0 {
+		b.Concurrency = 1
+	}
+	if b.FnID == "" || b.Calls < 0 || b.Calls > maxBenchmarkCalls || b.Concurrency < 0 ||
+		b.Concurrency > maxBenchmarkConcurrency || b.PayloadSize < 0 || b.PayloadSize > maxBenchmarkPayload ||
+		len(b.Payload) > maxBenchmarkPayload || (b.Payload != "" && b.PayloadSize != 0) {
+		return ErrInvalidBenchmark
+	}
+	return nil
+}
+
+func (b *Benchmark) payload() []byte {
+	if b.Payload != "" {
+		return []byte(b.Payload)
+	}
+	return bytes.Repeat([]byte("x"), b.PayloadSize)
+}
+
+// LatencyStats is the distribution of latencies of the calls of a benchmark, in milliseconds
+type LatencyStats struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+func newLatencyStats(latencies []time.Duration) LatencyStats {
+	if len(latencies) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	msecs := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	// nearest rank percentiles
+	percentile := func(p float64) float64 {
+		i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return msecs(sorted[i])
+	}
+	return LatencyStats{
+		Count: len(sorted),
+		Min:   msecs(sorted[0]),
+		P50:   percentile(50),
+		P90:   percentile(90),
+		P99:   percentile(99),
+		Max:   msecs(sorted[len(sorted)-1]),
+	}
+}
+
+// BenchmarkReport is the outcome of a benchmark. Calls are told cold from warm by the agent, calls the agent did
+// not tell, such as those run by runners without timing headers, are in Latency only.
+type BenchmarkReport struct {
+	Benchmark
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// Errors counts the calls that failed, by error
+	Errors map[string]int `json:"errors,omitempty"`
+	// DurationMsecs is how long the benchmark took
+	DurationMsecs float64 `json:"duration_msecs"`
+	// Throughput is how many calls were made per second
+	Throughput float64 `json:"throughput"`
+	// Latency is the distribution of the latencies of the calls that succeeded, as seen by the server
+	Latency LatencyStats `json:"latency"`
+	// Cold is the distribution of the latencies of the calls that started a container
+	Cold LatencyStats `json:"cold"`
+	// Warm is the distribution of the latencies of the calls that ran in a container already started
+	Warm LatencyStats `json:"warm"`
+	// Exec is the distribution of the time fns took to respond, as seen by the agent
+	Exec LatencyStats `json:"exec"`
+}
+
+type benchmarkResult struct {
+	latency time.Duration
+	exec    time.Duration
+	cold    string
+	err     error
+}
+
+// benchmarkCall makes one call of a benchmark, asking the agent for the timing headers of the call
+func (s *Server) benchmarkCall(ctx context.Context, app *models.App, fn *models.Fn, payload []byte) benchmarkResult {
+	req, err := http.NewRequest(http.MethodPost, "/invoke/"+fn.ID, bytes.NewReader(payload))
+	if err != nil {
+		return benchmarkResult{err: err}
+	}
+	req = req.WithContext(ctx)
+	writer := &syncResponseWriter{headers: make(http.Header), status: http.StatusOK, Buffer: new(bytes.Buffer)}
+
+	start := time.Now()
+	opts := append(getCallOptions(req, app, fn, nil, writer), agent.WithTimingHeaders())
+	call, err := s.agent.GetCall(opts...)
+	if err == nil {
+		err = s.agent.Submit(call)
+	}
+	result := benchmarkResult{latency: time.Since(start), err: err}
+	if err == nil && writer.status >= http.StatusInternalServerError {
+		result.err = fmt.Errorf("fn responded with status %d", writer.status)
+	}
+
+	result.cold = writer.headers.Get(agent.ColdStartHeader)
+	if ms, err := strconv.ParseInt(writer.headers.Get(agent.ExecTimeHeader), 10, 64); err == nil {
+		result.exec = time.Duration(ms) * time.Millisecond
+	} else {
+		result.exec = -1
+	}
+	return result
+}
+
+// RunBenchmark makes the calls of b, b.Concurrency at a time, and reports their latencies
+func (s *Server) RunBenchmark(ctx context.Context, b Benchmark) (*BenchmarkReport, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	fn, err := s.lbReadAccess.GetFnByID(ctx, b.FnID)
+	if err != nil {
+		return nil, err
+	}
+	app, err := s.lbReadAccess.GetAppByID(ctx, fn.AppID)
+	if err != nil {
+		return nil, err
+	}
+	fn, err = s.resourceLimits.limit(app, fn)
+	if err != nil {
+		return nil, err
+	}
+	if longRunning, err := fn.Annotations.LongRunning(); err != nil {
+		return nil, err
+	} else if longRunning != nil {
+		return nil, models.ErrCallLongRunningSync
+	}
+
+	payload := b.payload()
+	results := make([]benchmarkResult, b.Calls)
+	calls := make(chan int)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < b.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range calls {
+				results[n] = s.benchmarkCall(ctx, app, fn, payload)
+			}
+		}()
+	}
+	for n := 0; n < b.Calls; n++ {
+		calls <- n
+	}
+	close(calls)
+	wg.Wait()
+	duration := time.Since(start)
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	report := &BenchmarkReport{
+		Benchmark:     b,
+		Errors:        make(map[string]int),
+		DurationMsecs: float64(duration) / float64(time.Millisecond),
+		Throughput:    float64(b.Calls) / duration.Seconds(),
+	}
+	var all, cold, warm, exec []time.Duration
+	for _, r := range results {
+		if r.err != nil {
+			report.Failed++
+			report.Errors[r.err.Error()]++
+			continue
+		}
+		report.Succeeded++
+		all = append(all, r.latency)
+		switch r.cold {
+		case "true":
+			cold = append(cold, r.latency)
+		case "false":
+			warm = append(warm, r.latency)
+		}
+		if r.exec >= 0 {
+			exec = append(exec, r.exec)
+		}
+	}
+	report.Latency = newLatencyStats(all)
+	report.Cold = newLatencyStats(cold)
+	report.Warm = newLatencyStats(warm)
+	report.Exec = newLatencyStats(exec)
+	return report, nil
+}
+
+// handleBenchmark runs a benchmark against a fn, responding with its report once it is done
+func (s *Server) handleBenchmark(c *gin.Context) {
+	var b Benchmark
+	if err := c.BindJSON(&b); err != nil {
+		handleErrorResponse(c, models.ErrInvalidJSON)
+		return
+	}
+
+	log := logrus.WithFields(logrus.Fields{"fn_id": b.FnID, "calls": b.Calls, "concurrency": b.Concurrency,
+		"by": c.ClientIP()})
+	log.Info("Benchmark started")
+	report, err := s.RunBenchmark(c.Request.Context(), b)
+	if err != nil {
+		log.WithError(err).Info("Benchmark failed")
+		handleErrorResponse(c, err)
+		return
+	}
+	log.WithFields(logrus.Fields{"failed": report.Failed, "p50": report.Latency.P50, "p99": report.Latency.P99}).Info("Benchmark finished")
+	c.JSON(http.StatusOK, report)
+}
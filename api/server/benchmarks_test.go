@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestBenchmark(t *testing.T) {
+	cfg, err := agent.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	script := &mock.Script{Images: map[string]*mock.ImageScript{
+		mock.ScriptDefault: {StartDelayMsecs: 50, LatencyMsecs: 5},
+	}}
+	a := agent.New(agent.WithConfig(cfg), agent.WithDockerDriver(mock.NewScripted(script)))
+	defer a.Close()
+
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", Name: "myfn", AppID: app.ID, Image: "fnproject/fn-test-utils",
+		ResourceConfig: models.ResourceConfig{Memory: 128, Timeout: 30, IdleTimeout: 30}}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn})
+	srv := testServer(ds, a, ServerTypeFull, WithAdminToken("secret"))
+
+	benchmark := func(b Benchmark) (*BenchmarkReport, int) {
+		body, _ := json.Marshal(b)
+		req := createRequest(t, http.MethodPost, "/v2/admin/benchmarks", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		_, rec := routerRequest2(t, srv.AdminRouter, req)
+		if rec.Code != http.StatusOK {
+			return nil, rec.Code
+		}
+		var report BenchmarkReport
+		if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		return &report, rec.Code
+	}
+
+	report, code := benchmark(Benchmark{FnID: fn.ID, Calls: 10, PayloadSize: 64})
+	if code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if report.Succeeded != 10 || report.Failed != 0 {
+		t.Fatalf("expected 10 calls to succeed, got %+v", report)
+	}
+	// calls made one at a time run in the one container, which only the first starts
+	if report.Cold.Count != 1 || report.Warm.Count != 9 || report.Exec.Count != 10 {
+		t.Fatalf("expected 1 cold and 9 warm calls, got %+v", report)
+	}
+	if report.Cold.Max < report.Warm.Max {
+		t.Errorf("expected the cold call to be slower than the warm ones, got %+v and %+v", report.Cold, report.Warm)
+	}
+
+	for _, b := range []Benchmark{
+		{},
+		{FnID: fn.ID, Calls: maxBenchmarkCalls + 1},
+		{FnID: fn.ID, Concurrency: maxBenchmarkConcurrency + 1},
+		{FnID: fn.ID, PayloadSize: 10, Payload: "hello"},
+	} {
+		if _, code := benchmark(b); code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %+v, got %d", http.StatusBadRequest, b, code)
+		}
+	}
+	if _, code := benchmark(Benchmark{FnID: "nope"}); code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing fn, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	stats := newLatencyStats(latencies)
+	expected := LatencyStats{Count: 100, Min: 1, P50: 50, P90: 90, P99: 99, Max: 100}
+	if stats != expected {
+		t.Fatalf("expected %+v, got %+v", expected, stats)
+	}
+	if stats := newLatencyStats(nil); stats != (LatencyStats{}) {
+		t.Fatalf("expected no stats, got %+v", stats)
+	}
+}
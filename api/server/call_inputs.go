@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// redactedValue replaces the values of the redacted fields of JSON inputs
+const redactedValue = `"[REDACTED]"`
+
+// WithCallInputKeyProvider encrypts the call inputs recorded with call records, and the payloads of trigger runs,
+// with data keys wrapped by keys, eg. the keys of a KMS
+func WithCallInputKeyProvider(keys agent.KeyProvider) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.callInputKeys = keys
+		return nil
+	}
+}
+
+// WithStaticCallInputKeys encrypts the call inputs recorded with call records, and the payloads of trigger runs,
+// with data keys wrapped by a comma separated list of id=base64 AES keys, see EnvCallInputKeys
+func WithStaticCallInputKeys(list string) Option {
+	return func(ctx context.Context, s *Server) error {
+		keys, current, err := agent.ParseStaticKeys(list)
+		if err != nil {
+			return err
+		}
+		s.callInputKeys, err = agent.NewStaticKeyProvider(keys, current)
+		return err
+	}
+}
+
+// redactJSONFields replaces the values of fields of a JSON object input. Inputs that are not JSON objects cannot
+// be redacted, and are not to be recorded.
+func redactJSONFields(contentType string, input []byte, fields []string) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return nil, errors.New("only JSON inputs can be redacted")
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(input, &obj); err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		if _, ok := obj[field]; ok {
+			obj[field] = json.RawMessage(redactedValue)
+		}
+	}
+	return json.Marshal(obj)
+}
+
+func newInputAEAD(dataKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealCallInput encrypts the input of call callID with a new data key wrapped by keys, returning the sealed input
+// and the id and wrapped data key to store with it
+func sealCallInput(ctx context.Context, keys agent.KeyProvider, callID string, input []byte) ([]byte, string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, "", err
+	}
+	keyID, wrapped, err := keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, "", err
+	}
+	aead, err := newInputAEAD(dataKey)
+	if err != nil {
+		return nil, "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	return aead.Seal(nonce, nonce, input, []byte(callID)), keyID + ":" + base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// openSealed decrypts the input or payload id, sealed by sealCallInput with key
+func openSealed(ctx context.Context, keys agent.KeyProvider, id, key string, sealed []byte) ([]byte, error) {
+	if keys == nil {
+		return nil, errors.New("no call input keys are configured")
+	}
+	parts := strings.SplitN(key, ":", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("invalid call input key")
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := keys.UnwrapKey(ctx, parts[0], wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newInputAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed call input too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+}
+
+// openCallInput returns the recorded input of call, decrypting it with keys if it was encrypted
+func openCallInput(ctx context.Context, keys agent.KeyProvider, call *models.Call) ([]byte, error) {
+	if call.InputKey == "" {
+		return call.Input, nil
+	}
+	input, err := openSealed(ctx, keys, call.ID, call.InputKey, call.Input)
+	if err != nil {
+		common.Logger(ctx).WithError(err).WithField("call_id", call.ID).Error("failed to decrypt call input")
+		return nil, models.ErrCallInputUnavailable
+	}
+	return input, nil
+}
+
+// expireCallInput removes the recorded input of call once it has outlived its ttl, as it is read. The inputs of
+// calls that are not read are removed by the call-inputs sweep.
+func expireCallInput(ctx context.Context, ds models.Datastore, call *models.Call) *models.Call {
+	expiresAt := time.Time(call.InputExpiresAt)
+	if !call.Replayable || expiresAt.IsZero() || time.Now().Before(expiresAt) {
+		return call
+	}
+	if err := ds.RemoveCallInput(ctx, call.ID); err != nil {
+		common.Logger(ctx).WithError(err).WithField("call_id", call.ID).Error("failed to remove expired call input")
+	}
+	expired := *call
+	expired.Input, expired.InputKey, expired.Replayable = nil, "", false
+	return &expired
+}
+
+// handleCallInputGet downloads the recorded input of a call, with its content type
+func (s *Server) handleCallInputGet(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	call, err := s.datastore.GetCall(ctx, c.Param(api.FnID), c.Param(api.CallID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	call = expireCallInput(ctx, s.datastore, call)
+	if !call.Replayable {
+		handleErrorResponse(c, models.ErrCallInputNotFound)
+		return
+	}
+	input, err := openCallInput(ctx, s.callInputKeys, call)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	contentType := call.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Data(http.StatusOK, contentType, input)
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/id"
+	"github.com/fnproject/fn/api/models"
+)
+
+// testInputRedactor drops inputs mentioning secrets, and masks tokens
+type testInputRedactor struct{}
+
+func (testInputRedactor) RedactCallInput(ctx context.Context, call *models.Call, contentType string, input []byte) ([]byte, error) {
+	if strings.Contains(string(input), "secret") {
+		return nil, errors.New("input has a secret")
+	}
+	return []byte(strings.Replace(string(input), "tok_123", "tok_***", -1)), nil
+}
+
+func TestCallInputCapture(t *testing.T) {
+	buf := setLogBuffer()
+
+	cfg, err := agent.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	script := &mock.Script{Images: map[string]*mock.ImageScript{
+		mock.ScriptDefault: {},
+	}}
+	a := agent.New(agent.WithConfig(cfg), agent.WithDockerDriver(mock.NewScripted(script)))
+	defer a.Close()
+
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	sampled := &models.Fn{ID: "sampled_id", Name: "sampled", AppID: app.ID, Image: "fnproject/fn-test-utils",
+		ResourceConfig: models.ResourceConfig{Memory: 128, Timeout: 30, IdleTimeout: 30}}
+	sampled.Annotations, _ = models.Annotations{}.With(models.FnCaptureAnnotation, &models.FnCapture{Percent: 100, TTL: 60, Redact: []string{"password"}})
+	unsampled := &models.Fn{ID: "unsampled_id", Name: "unsampled", AppID: app.ID, Image: "fnproject/fn-test-utils",
+		ResourceConfig: models.ResourceConfig{Memory: 128, Timeout: 30, IdleTimeout: 30}}
+	unsampled.Annotations, _ = models.Annotations{}.With(models.FnCaptureAnnotation, &models.FnCapture{Percent: 0})
+	expired := &models.Call{ID: id.New().String(), FnID: sampled.ID, AppID: app.ID, Status: models.CallStateSucceeded, Timeout: 30,
+		CreatedAt: common.DateTime(time.Now()), Input: models.CallInput("old"), Replayable: true, InputExpiresAt: common.DateTime(time.Now().Add(-time.Minute))}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{sampled, unsampled}, []*models.Call{expired})
+
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	srv := testServer(ds, a, ServerTypeFull, WithStaticCallInputKeys("k1="+key))
+	srv.AddCallInputRedactor(testInputRedactor{})
+
+	invoke := func(fnID, body string) string {
+		req := createRequest(t, http.MethodPost, "/invoke/"+fnID, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		_, rec := routerRequest2(t, srv.Router, req)
+		if rec.Code != http.StatusOK {
+			t.Log(buf.String())
+			t.Fatalf("expected the call to succeed, got %d: %s", rec.Code, rec.Body.String())
+		}
+		return rec.Header().Get("Fn-Call-Id")
+	}
+	ctx := context.Background()
+
+	callID := invoke(sampled.ID, `{"user": "bob", "password": "hunter2", "token": "tok_123"}`)
+	call, err := ds.GetCall(ctx, sampled.ID, callID)
+	if err != nil {
+		t.Fatalf("expected the sampled call to be recorded, got %v", err)
+	}
+	if call.Status != models.CallStateSucceeded || !call.Replayable || call.InputKey == "" || strings.Contains(string(call.Input), "bob") {
+		t.Fatalf("expected a succeeded call with an encrypted input, got %+v", call)
+	}
+	if ttl := time.Time(call.InputExpiresAt).Sub(time.Time(call.CreatedAt)); ttl < 59*time.Second || ttl > 61*time.Second {
+		t.Errorf("expected the input to expire after the ttl of the fn, got %v", ttl)
+	}
+
+	_, rec := routerRequest(t, srv.Router, http.MethodGet, "/v2/fns/sampled_id/calls/"+callID+"/input", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected the input, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if input := rec.Body.String(); input != `{"password":"[REDACTED]","token":"tok_***","user":"bob"}` {
+		t.Errorf("expected the redacted input, got %s", input)
+	}
+
+	// a redactor refusing an input has the call recorded without it
+	callID = invoke(sampled.ID, `{"note": "secret"}`)
+	if call, err := ds.GetCall(ctx, sampled.ID, callID); err != nil || call.Replayable || len(call.Input) != 0 {
+		t.Errorf("expected the call to be recorded without its input, got %+v %v", call, err)
+	}
+
+	callID = invoke(unsampled.ID, `{}`)
+	if _, err := ds.GetCall(ctx, unsampled.ID, callID); err != models.ErrCallNotFound {
+		t.Errorf("expected the call not to be sampled, got %v", err)
+	}
+
+	_, rec = routerRequest(t, srv.Router, http.MethodGet, "/v2/fns/sampled_id/calls/"+expired.ID+"/input", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected the expired input to be gone, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if call, _ := ds.GetCall(ctx, sampled.ID, expired.ID); call.Replayable || len(call.Input) != 0 {
+		t.Errorf("expected the expired input to be removed, got %+v", call)
+	}
+
+	// inputs encrypted with keys a server does not have cannot be read
+	call, _ = ds.GetCall(ctx, sampled.ID, invoke(sampled.ID, `{}`))
+	if _, err := openCallInput(ctx, nil, call); err != models.ErrCallInputUnavailable {
+		t.Errorf("expected error `%v`, got `%v`", models.ErrCallInputUnavailable, err)
+	}
+}
+
+func TestCallInputSweep(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", Name: "myfn", AppID: app.ID, Image: "fnproject/fn-test-utils"}
+	expired := &models.Call{ID: id.New().String(), FnID: fn.ID, AppID: app.ID, Status: models.CallStateSucceeded,
+		CreatedAt: common.DateTime(time.Now()), Input: models.CallInput("old"), Replayable: true, InputExpiresAt: common.DateTime(time.Now().Add(-time.Minute))}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn}, []*models.Call{expired})
+	srv := testServer(ds, nil, ServerTypeAPI, WithLockStore(common.NewMemoryLockStore()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv.runSweeps(ctx)
+
+	// the input is removed without the call being read through the api
+	for i := 0; i < 100; i++ {
+		if call, _ := ds.GetCall(ctx, fn.ID, expired.ID); !call.Replayable && len(call.Input) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected the expired input to be swept")
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// callLogStreamKeepAlive is how often a comment is sent to an idle event stream, so that proxies keep it open
+const callLogStreamKeepAlive = 15 * time.Second
+
+// ErrCallNotRunning is returned for the log stream of a call that is not running on the server
+var ErrCallNotRunning = models.NewAPIError(http.StatusNotFound,
+	errors.New("Call is not running on this server, its log is only streamed by the full node running it, while it runs"))
+
+// handleCallLogStream streams the output of a running call until it ends, as server-sent events, or as WebSocket
+// text messages to clients upgrading to a WebSocket. Only output written after the stream starts is sent, and output
+// is dropped for clients falling far behind rather than holding up the fn.
+func (s *Server) handleCallLogStream(c *gin.Context) {
+	streamer, ok := s.agent.(agent.CallLogStreamer)
+	if !ok {
+		handleErrorResponse(c, ErrCallNotRunning)
+		return
+	}
+	output, stop, ok := streamer.StreamCallLog(c.Param(api.FnID), c.Param(api.CallID))
+	if !ok {
+		handleErrorResponse(c, ErrCallNotRunning)
+		return
+	}
+	defer stop()
+
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		websocket.Server{Handler: func(ws *websocket.Conn) {
+			streamCallLogWebSocket(ws, output, stop)
+		}}.ServeHTTP(c.Writer, c.Request)
+		return
+	}
+	streamCallLogEvents(c, output)
+}
+
+// streamCallLogEvents sends each line of output as the data of a message event, and an end event once the call ends
+func streamCallLogEvents(c *gin.Context, output <-chan []byte) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(callLogStreamKeepAlive)
+	defer keepAlive.Stop()
+	var buf bytes.Buffer
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			io.WriteString(c.Writer, ": keep-alive\n\n")
+		case b, ok := <-output:
+			if !ok {
+				io.WriteString(c.Writer, "event: end\ndata: \n\n")
+				c.Writer.Flush()
+				return
+			}
+			buf.Reset()
+			for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+				buf.WriteString("data: ")
+				buf.WriteString(strings.TrimSuffix(line, "\r"))
+				buf.WriteString("\n")
+			}
+			buf.WriteString("\n")
+			if _, err := c.Writer.Write(buf.Bytes()); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// streamCallLogWebSocket sends output as text messages, closing the WebSocket once the call ends
+func streamCallLogWebSocket(ws *websocket.Conn, output <-chan []byte, stop func()) {
+	defer ws.Close()
+	// the stream stops once the client closes it, messages from the client are ignored
+	go func() {
+		io.Copy(ioutil.Discard, ws)
+		stop()
+	}()
+	for b := range output {
+		if err := websocket.Message.Send(ws, string(b)); err != nil {
+			return
+		}
+	}
+}
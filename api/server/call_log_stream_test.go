@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/datastore"
+	"golang.org/x/net/websocket"
+)
+
+// logStreamAgent streams the output sent to it as the log of its only running call
+type logStreamAgent struct {
+	agent.Agent
+	output chan []byte
+}
+
+func (a *logStreamAgent) StreamCallLog(fnID, callID string) (<-chan []byte, func(), bool) {
+	if fnID != "fn_id" || callID != "call_id" {
+		return nil, nil, false
+	}
+	return a.output, func() {}, true
+}
+
+func TestCallLogStream(t *testing.T) {
+	cfg, err := agent.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+	a := &logStreamAgent{Agent: agent.New(agent.WithConfig(cfg), agent.WithDockerDriver(mock.NewScripted(&mock.Script{})))}
+	defer a.Close()
+
+	srv := testServer(datastore.NewMock(), a, ServerTypeFull)
+	ts := httptest.NewServer(srv.Router)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v2/fns/fn_id/calls/other_id/log/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the log of a call not running to be not found, got %d", resp.StatusCode)
+	}
+
+	// server-sent events
+	a.output = make(chan []byte, 2)
+	a.output <- []byte("hello\nworld\n")
+	a.output <- []byte("bye\n")
+	close(a.output)
+	resp, err = http.Get(ts.URL + "/v2/fns/fn_id/calls/call_id/log/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body, err := ioutil.ReadAll(bufio.NewReader(resp.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "data: hello\ndata: world\n\ndata: bye\n\nevent: end\ndata: \n\n"
+	if string(body) != expected {
+		t.Errorf("expected the events of each write then the end, got %q", body)
+	}
+
+	// websocket
+	a.output = make(chan []byte, 2)
+	a.output <- []byte("hello\n")
+	a.output <- []byte("bye\n")
+	close(a.output)
+	ws, err := websocket.Dial(strings.Replace(ts.URL, "http", "ws", 1)+"/v2/fns/fn_id/calls/call_id/log/stream", "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	var messages []string
+	for {
+		var msg string
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			break
+		}
+		messages = append(messages, msg)
+	}
+	if strings.Join(messages, "|") != "hello\n|bye\n" {
+		t.Errorf("expected a message for each write, got %q", messages)
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/fnproject/fn/api"
+	"github.com/gin-gonic/gin"
+)
+
+// WithCallLogs keeps the logs of calls in the datastore for ttl, the full agent appending them while calls run so
+// that the logs of long running calls survive the agent, see agent.WithCallLogStore. 0 keeps no call logs.
+func WithCallLogs(ttl time.Duration) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.callLogTTL = ttl
+		return nil
+	}
+}
+
+// handleCallLogGet returns the log of a call as appended so far, which is the whole log once the call ended. Servers
+// that keep no call logs answer 410.
+func (s *Server) handleCallLogGet(c *gin.Context) {
+	if s.callLogTTL <= 0 {
+		c.Status(http.StatusGone)
+		return
+	}
+
+	log, err := s.datastore.GetCallLog(c.Request.Context(), c.Param(api.FnID), c.Param(api.CallID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", log)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestCallLogGet(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", Name: "myfn", AppID: app.ID, Image: "fnproject/fn-test-utils"}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn})
+	ctx := context.Background()
+	for seq, part := range []string{"hello ", "world\n"} {
+		if err := ds.AppendCallLog(ctx, fn.ID, "call_id", seq, []byte(part)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := testServer(ds, nil, ServerTypeAPI, WithCallLogs(time.Hour))
+	_, rec := routerRequest(t, srv.Router, http.MethodGet, "/v2/fns/fn_id/calls/call_id/log", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Fatalf("expected the call log, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if log := rec.Body.String(); log != "hello world\n" {
+		t.Errorf("expected the parts of the call log joined, got %q", log)
+	}
+
+	_, rec = routerRequest(t, srv.Router, http.MethodGet, "/v2/fns/fn_id/calls/other_call/log", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected no log for a call without one, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	srv = testServer(ds, nil, ServerTypeAPI)
+	_, rec = routerRequest(t, srv.Router, http.MethodGet, "/v2/fns/fn_id/calls/call_id/log", nil)
+	if rec.Code != http.StatusGone {
+		t.Errorf("expected servers keeping no call logs to answer 410, got %d", rec.Code)
+	}
+}
+
+func TestCallLogSweep(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", Name: "myfn", AppID: app.ID, Image: "fnproject/fn-test-utils"}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := ds.AppendCallLog(ctx, fn.ID, "call_id", 0, []byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := testServer(ds, nil, ServerTypeAPI, WithLockStore(common.NewMemoryLockStore()), WithCallLogs(time.Nanosecond))
+	srv.runSweeps(ctx)
+
+	for i := 0; i < 100; i++ {
+		if _, err := ds.GetCallLog(ctx, fn.ID, "call_id"); err == models.ErrCallLogNotFound {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected the expired call log to be swept")
+}
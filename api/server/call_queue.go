@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/id"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/api/mqs"
+	"github.com/sirupsen/logrus"
+)
+
+// queuedCallKey is the context key of the CallMessage a call is made from
+type queuedCallKey struct{}
+
+// queuedHeaders are the request headers published with queued calls, the others, credentials and hop-by-hop headers
+// among them, are left out of the message queue. The request headers of http triggers, passed to fns as
+// Fn-Http-H-<name>, are published if <name> is.
+var queuedHeaders = map[string]bool{
+	"Accept":                     true,
+	"Accept-Language":            true,
+	"Content-Language":           true,
+	"Content-Type":               true,
+	"Traceparent":                true,
+	"Tracestate":                 true,
+	"User-Agent":                 true,
+	"Fn-Intent":                  true,
+	"Fn-Http-Method":             true,
+	"Fn-Http-Request-Url":        true,
+	"Fn-Http-Client-Ip":          true,
+	"Fn-Http-Tls-Server-Name":    true,
+	"Fn-Http-Tls-Client-Subject": true,
+	agent.FnChainHeader:          true,
+	agent.FnMirrorHeader:         true,
+	PayloadURLHeader:             true,
+}
+
+// queueHeaders returns the headers of hdr in queuedHeaders
+func queueHeaders(hdr http.Header) http.Header {
+	queued := make(http.Header)
+	for k, vs := range hdr {
+		name := k
+		if strings.HasPrefix(k, "Fn-Http-H-") {
+			name = http.CanonicalHeaderKey(strings.TrimPrefix(k, "Fn-Http-H-"))
+			if strings.HasPrefix(name, "Fn-") {
+				continue
+			}
+		}
+		if queuedHeaders[name] {
+			queued[k] = vs
+		}
+	}
+	return queued
+}
+
+// WithMessageQueue delivers the detached calls of the server through mq: they are recorded queued and pushed to mq
+// rather than run on the server taking them, and the server runs the calls it consumes from mq. Calls outlive the
+// server taking them, and are spread over the servers consuming mq. Only the queuedHeaders of calls are published,
+// their bodies are published as they are, in clear, so mq is to be kept as private as the calls made to the server.
+func WithMessageQueue(mq models.MessageQueue) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.callQueue = mq
+		return nil
+	}
+}
+
+// WithMessageQueueURL delivers the detached calls of the server through the message queue at mqURL, such as
+// kafka://localhost:9092/calls, see WithMessageQueue. Detached calls run on the server taking them if it is empty.
+func WithMessageQueueURL(mqURL string) Option {
+	return func(ctx context.Context, s *Server) error {
+		if mqURL == "" {
+			return nil
+		}
+		mq, err := mqs.New(ctx, mqURL)
+		if err != nil {
+			return err
+		}
+		return WithMessageQueue(mq)(ctx, s)
+	}
+}
+
+// queueCall records a detached call queued and pushes it to the message queue, for a server consuming the queue to
+// run it. The call is answered 202 once pushed.
+func (s *Server) queueCall(resp http.ResponseWriter, req *http.Request, app *models.App, fn *models.Fn, trig *models.Trigger, input []byte, inputReplayable bool, capture *models.FnCapture) error {
+	ctx := req.Context()
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return err
+		}
+	}
+
+	call := &models.Call{
+		ID:        id.NewString(id.KindCall),
+		AppID:     app.ID,
+		FnID:      fn.ID,
+		Type:      models.TypeDetached,
+		Timeout:   fn.Timeout,
+		CreatedAt: common.DateTime(time.Now()),
+	}
+	msg := &models.CallMessage{
+		CallID:    call.ID,
+		AppID:     app.ID,
+		FnID:      fn.ID,
+		Method:    req.Method,
+		URL:       reqURL(req),
+		Headers:   queueHeaders(req.Header),
+		Body:      body,
+		CreatedAt: call.CreatedAt,
+	}
+	if trig != nil {
+		call.TriggerID, msg.TriggerID = trig.ID, trig.ID
+	}
+	if replay, ok := ctx.Value(callReplayKey{}).(*callReplay); ok {
+		msg.DeploymentID = replay.deploymentID
+	}
+
+	if err := s.asyncCalls.enqueue(ctx, req, call, input, inputReplayable, capture); err != nil {
+		return err
+	}
+	if err := s.callQueue.Push(ctx, msg); err != nil {
+		common.Logger(ctx).WithError(err).WithField("call_id", call.ID).Error("failed to queue call")
+		s.asyncCalls.abort(common.BackgroundContext(ctx), call, models.ErrCallQueueUnavailable)
+		return models.ErrCallQueueUnavailable
+	}
+
+	resp.Header().Add("Fn-Call-Id", call.ID)
+	resp.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// handleCallMessage runs a call consumed from the message queue, as it was made to the server that queued it. Calls
+// that cannot be made are failed, calls that have since expired are not started (see asyncCalls.BeforeCall). It only
+// fails, for the message to be delivered again, if ctx is done before the call is started.
+func (s *Server) handleCallMessage(ctx context.Context, msg *models.CallMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// the call is drained with the agent rather than cancelled, its message is acknowledged once handed to the agent
+	ctx, log := common.LoggerWithFields(common.BackgroundContext(ctx), logrus.Fields{"call_id": msg.CallID, "fn_id": msg.FnID})
+
+	err := s.runCallMessage(ctx, msg)
+	if err != nil {
+		log.WithError(err).Info("queued call failed")
+		// calls that did start have already been ended, this is left alone for them
+		s.asyncCalls.abort(ctx, &models.Call{ID: msg.CallID, Type: models.TypeDetached}, err)
+	}
+	return nil
+}
+
+func (s *Server) runCallMessage(ctx context.Context, msg *models.CallMessage) error {
+	fn, err := s.datastore.GetFnByID(ctx, msg.FnID)
+	if err != nil {
+		return err
+	}
+	app, err := s.datastore.GetAppByID(ctx, fn.AppID)
+	if err != nil {
+		return err
+	}
+	var trig *models.Trigger
+	if msg.TriggerID != "" {
+		if trig, err = s.datastore.GetTriggerByID(ctx, msg.TriggerID); err != nil {
+			return err
+		}
+	}
+	if msg.DeploymentID != "" {
+		deployment, err := s.datastore.GetFnDeployment(ctx, fn.ID, msg.DeploymentID)
+		if err != nil {
+			return err
+		}
+		fn = fn.Clone()
+		fn.Image = deployment.Image
+	}
+
+	req, err := http.NewRequest(msg.Method, msg.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return err
+	}
+	// messages pushed by servers publishing other headers are held to the same headers
+	req.Header = queueHeaders(msg.Headers)
+	req.Header.Set("Fn-Invoke-Type", models.TypeDetached)
+	req = req.WithContext(context.WithValue(ctx, queuedCallKey{}, msg))
+
+	writer := &syncResponseWriter{
+		headers: make(http.Header),
+		Buffer:  new(bytes.Buffer),
+	}
+	return s.fnInvoke(writer, req, app, fn, trig)
+}
+
+// consumeCalls runs the calls of the message queue until ctx is done
+func (s *Server) consumeCalls(ctx context.Context) {
+	err := s.callQueue.Consume(ctx, s.handleCallMessage)
+	if err != nil && err != context.Canceled {
+		common.Logger(ctx).WithError(err).Error("stopped consuming queued calls")
+	}
+}
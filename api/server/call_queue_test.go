@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+// testCallQueue is a models.MessageQueue keeping its messages in memory, Consume hands them over once
+type testCallQueue struct {
+	lock    sync.Mutex
+	msgs    []*models.CallMessage
+	pushErr error
+}
+
+func (q *testCallQueue) Push(ctx context.Context, msg *models.CallMessage) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.pushErr != nil {
+		return q.pushErr
+	}
+	q.msgs = append(q.msgs, msg)
+	return nil
+}
+
+func (q *testCallQueue) Consume(ctx context.Context, handle func(ctx context.Context, msg *models.CallMessage) error) error {
+	q.lock.Lock()
+	msgs := q.msgs
+	q.msgs = nil
+	q.lock.Unlock()
+	for i, msg := range msgs {
+		if err := handle(ctx, msg); err != nil {
+			q.lock.Lock()
+			q.msgs = append(msgs[i:], q.msgs...)
+			q.lock.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *testCallQueue) Close() error { return nil }
+
+func TestCallQueue(t *testing.T) {
+	buf := setLogBuffer()
+
+	cfg, err := agent.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	script := &mock.Script{Images: map[string]*mock.ImageScript{
+		mock.ScriptDefault: {},
+	}}
+	a := agent.New(agent.WithConfig(cfg), agent.WithDockerDriver(mock.NewScripted(script)))
+	defer a.Close()
+
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", Name: "myfn", AppID: app.ID, Image: "fnproject/fn-test-utils",
+		ResourceConfig: models.ResourceConfig{Memory: 128, Timeout: 30, IdleTimeout: 30}}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn})
+
+	queue := new(testCallQueue)
+	srv := testServer(ds, a, ServerTypeFull, WithMessageQueue(queue))
+	ctx := context.Background()
+
+	invoke := func(expectedCode int) string {
+		req := createRequest(t, http.MethodPost, "/invoke/"+fn.ID, strings.NewReader(`{"name": "bob"}`))
+		req.Header.Set("Fn-Invoke-Type", models.TypeDetached)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Cookie", "session=secret")
+		_, rec := routerRequest2(t, srv.Router, req)
+		if rec.Code != expectedCode {
+			t.Log(buf.String())
+			t.Fatalf("expected %d, got %d: %s", expectedCode, rec.Code, rec.Body.String())
+		}
+		return rec.Header().Get("Fn-Call-Id")
+	}
+
+	callID := invoke(http.StatusAccepted)
+	if len(queue.msgs) != 1 {
+		t.Fatalf("expected the call to be pushed, got %d messages", len(queue.msgs))
+	}
+	msg := queue.msgs[0]
+	if msg.CallID != callID || msg.AppID != app.ID || msg.FnID != fn.ID || string(msg.Body) != `{"name": "bob"}` {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	if msg.Headers.Get("Content-Type") != "application/json" || msg.Headers.Get("Authorization") != "" || msg.Headers.Get("Cookie") != "" {
+		t.Fatalf("expected only the content type of the call to be published, got %v", msg.Headers)
+	}
+	call, err := ds.GetCall(ctx, fn.ID, callID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Status != models.CallStateQueued {
+		t.Fatalf("expected the call to be queued until consumed, got %s", call.Status)
+	}
+
+	// a server stopping leaves its messages to the others
+	stopped, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := queue.Consume(stopped, srv.handleCallMessage); err == nil || len(queue.msgs) != 1 {
+		t.Fatalf("expected the message to be left queued, got %v", err)
+	}
+
+	if err := queue.Consume(ctx, srv.handleCallMessage); err != nil {
+		t.Fatal(err)
+	}
+	call, err = ds.GetCall(ctx, fn.ID, callID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Status != models.CallStateSucceeded {
+		t.Log(buf.String())
+		t.Fatalf("expected the consumed call to succeed, got %s: %s", call.Status, call.Error)
+	}
+
+	// calls of fns gone by the time they are consumed fail
+	invoke(http.StatusAccepted)
+	gone := *queue.msgs[0]
+	gone.FnID = "gone_id"
+	queue.msgs[0] = &gone
+	if err := queue.Consume(ctx, srv.handleCallMessage); err != nil {
+		t.Fatal(err)
+	}
+	call, err = ds.GetCall(ctx, fn.ID, gone.CallID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Status != models.CallStateFailed {
+		t.Fatalf("expected the call of a removed fn to fail, got %s", call.Status)
+	}
+
+	// calls that cannot be pushed are not left queued
+	queue.pushErr = errors.New("broker down")
+	invoke(http.StatusServiceUnavailable)
+	calls, err := ds.GetCalls(ctx, &models.CallFilter{FnID: fn.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range calls.Items {
+		if c.Status == models.CallStateQueued {
+			t.Fatalf("expected the call that could not be pushed to fail, got %s", c.Status)
+		}
+	}
+}
+
+func TestQueueHeaders(t *testing.T) {
+	hdr := http.Header{
+		"Content-Type":               {"text/plain"},
+		"Authorization":              {"Bearer secret"},
+		"Proxy-Authorization":        {"Basic secret"},
+		"Connection":                 {"close"},
+		"Fn-Intent":                  {"httprequest"},
+		"Fn-Http-H-Authorization":    {"Bearer secret"},
+		"Fn-Http-H-Cookie":           {"session=secret"},
+		"Fn-Http-H-Accept":           {"text/plain"},
+		"Fn-Http-H-Fn-Payload-Url":   {"http://example.com"},
+		"Fn-Http-H-X-Forwarded-Host": {"example.com"},
+	}
+	queued := queueHeaders(hdr)
+	for _, k := range []string{"Content-Type", "Fn-Intent", "Fn-Http-H-Accept"} {
+		if queued.Get(k) != hdr.Get(k) {
+			t.Errorf("expected %s to be published", k)
+		}
+	}
+	if len(queued) != 3 {
+		t.Errorf("expected credentials and other headers to be left out, got %v", queued)
+	}
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleCallGet(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	call, err := s.datastore.GetCall(ctx, c.Param(api.FnID), c.Param(api.CallID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	call, err = expireCall(ctx, s.datastore, call)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, call)
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleCallList(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var filter models.CallFilter
+	filter.Cursor, filter.PerPage = pageParams(c)
+	filter.FnID = c.Param(api.FnID)
+	filter.Status = c.Query("status")
+	filter.ReplayOf = c.Query("replay_of")
+
+	var err error
+	if from := c.Query("from_time"); from != "" {
+		if filter.FromTime, err = common.ParseDateTime(from); err != nil {
+			handleErrorResponse(c, models.ErrInvalidFromTime)
+			return
+		}
+	}
+	if to := c.Query("to_time"); to != "" {
+		if filter.ToTime, err = common.ParseDateTime(to); err != nil {
+			handleErrorResponse(c, models.ErrInvalidToTime)
+			return
+		}
+	}
+
+	calls, err := s.datastore.GetCalls(ctx, &filter)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	for idx, call := range calls.Items {
+		calls.Items[idx], err = expireCall(ctx, s.datastore, call)
+		if err != nil {
+			handleErrorResponse(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, calls)
+}
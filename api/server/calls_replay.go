@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// callReplayKey is the context key of the callReplay a call is made for
+type callReplayKey struct{}
+
+// callReplay is the lineage recorded with a replayed call
+type callReplay struct {
+	of           string
+	deploymentID string
+}
+
+// handleCallReplay runs a persisted call again, as a new detached call with
+// the recorded input of the original, against the current image of its fn or
+// the image of one of its deployments. The new call is returned once queued,
+// its replay_of being the original call. Only the content type of the
+// original input is replayed, its other headers were not recorded.
+func (s *Server) handleCallReplay(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if s.asyncCalls == nil {
+		handleErrorResponse(c, models.ErrCallReplayUnsupported)
+		return
+	}
+
+	var replay models.CallReplay
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&replay); err != nil {
+			if !models.IsAPIError(err) {
+				err = models.ErrInvalidJSON
+			}
+			handleErrorResponse(c, err)
+			return
+		}
+	}
+
+	fn, err := s.datastore.GetFnByID(ctx, c.Param(api.FnID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	call, err := s.datastore.GetCall(ctx, fn.ID, c.Param(api.CallID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	call = expireCallInput(ctx, s.datastore, call)
+	if !call.Replayable {
+		handleErrorResponse(c, models.ErrCallNotReplayable)
+		return
+	}
+	input, err := openCallInput(ctx, s.callInputKeys, call)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	app, err := s.datastore.GetAppByID(ctx, fn.AppID)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	if replay.DeploymentID != "" {
+		deployment, err := s.datastore.GetFnDeployment(ctx, fn.ID, replay.DeploymentID)
+		if err != nil {
+			handleErrorResponse(c, err)
+			return
+		}
+		fn = fn.Clone()
+		fn.Image = deployment.Image
+	}
+
+	ctx = context.WithValue(ctx, callReplayKey{}, &callReplay{of: call.ID, deploymentID: replay.DeploymentID})
+	req, err := http.NewRequest(http.MethodPost, "/invoke/"+fn.ID, bytes.NewReader(input))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	req = req.WithContext(ctx)
+	if call.ContentType != "" {
+		req.Header.Set("Content-Type", call.ContentType)
+	}
+	req.Header.Set("Fn-Invoke-Type", models.TypeDetached)
+
+	writer := &syncResponseWriter{
+		headers: make(http.Header),
+		Buffer:  new(bytes.Buffer),
+	}
+	err = s.fnInvoke(writer, req, app, fn, nil)
+
+	replayID := writer.Header().Get("Fn-Call-Id")
+	if replayID == "" {
+		// the call was refused before it was queued
+		handleErrorResponse(c, err)
+		return
+	}
+	replayed, getErr := s.datastore.GetCall(ctx, fn.ID, replayID)
+	if getErr != nil {
+		// the call may not have been queued, in which case why is err
+		if err == nil {
+			err = getErr
+		}
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, replayed)
+}
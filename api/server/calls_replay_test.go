@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/id"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestCallReplay(t *testing.T) {
+	buf := setLogBuffer()
+
+	cfg, err := agent.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	script := &mock.Script{Images: map[string]*mock.ImageScript{
+		mock.ScriptDefault: {},
+	}}
+	a := agent.New(agent.WithConfig(cfg), agent.WithDockerDriver(mock.NewScripted(script)))
+	defer a.Close()
+
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", Name: "myfn", AppID: app.ID, Image: "fnproject/fn-test-utils:v2",
+		ResourceConfig: models.ResourceConfig{Memory: 128, Timeout: 30, IdleTimeout: 30}}
+	deployment := &models.FnDeployment{ID: id.New().String(), FnID: fn.ID, AppID: app.ID, Image: "fnproject/fn-test-utils:v1"}
+	tooLarge := &models.Call{ID: id.New().String(), FnID: fn.ID, AppID: app.ID, Status: models.CallStateSucceeded, Timeout: 30, CreatedAt: common.DateTime(time.Now())}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn}, []*models.FnDeployment{deployment}, []*models.Call{tooLarge})
+	srv := testServer(ds, a, ServerTypeFull)
+	api := testServer(ds, nil, ServerTypeAPI)
+
+	req := createRequest(t, http.MethodPost, "/invoke/fn_id", strings.NewReader(`{"hello": "world"}`))
+	req.Header.Set("Fn-Invoke-Type", models.TypeDetached)
+	req.Header.Set("Content-Type", "application/json")
+	_, rec := routerRequest2(t, srv.Router, req)
+	callID := rec.Header().Get("Fn-Call-Id")
+	if rec.Code >= http.StatusMultipleChoices || callID == "" {
+		t.Log(buf.String())
+		t.Fatalf("expected the detached call to be made, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	original, err := ds.GetCall(req.Context(), fn.ID, callID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !original.Replayable || string(original.Input) != `{"hello": "world"}` || original.ContentType != "application/json" {
+		t.Fatalf("expected the input of the detached call to be recorded, got %+v", original)
+	}
+
+	for i, test := range []struct {
+		srv                  *Server
+		path                 string
+		body                 string
+		expectedCode         int
+		expectedDeploymentID string
+	}{
+		{srv, "/v2/fns/fn_id/calls/" + callID + "/replay", "", http.StatusAccepted, ""},
+		{srv, "/v2/fns/fn_id/calls/" + callID + "/replay", `{"deployment_id": "` + deployment.ID + `"}`, http.StatusAccepted, deployment.ID},
+		{srv, "/v2/fns/fn_id/calls/" + callID + "/replay", `{"deployment_id": "missing"}`, http.StatusNotFound, ""},
+		{srv, "/v2/fns/fn_id/calls/" + tooLarge.ID + "/replay", "", http.StatusBadRequest, ""},
+		{srv, "/v2/fns/fn_id/calls/missing/replay", "", http.StatusNotFound, ""},
+		// api nodes have no agent to replay calls on
+		{api, "/v2/fns/fn_id/calls/" + callID + "/replay", "", http.StatusNotImplemented, ""},
+	} {
+		_, rec := routerRequest(t, test.srv.Router, http.MethodPost, test.path, bytes.NewBufferString(test.body))
+		if rec.Code != test.expectedCode {
+			t.Log(buf.String())
+			t.Fatalf("Test %d: Expected status code to be %d but was %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+		if rec.Code != http.StatusAccepted {
+			continue
+		}
+
+		var replay models.Call
+		if err := json.NewDecoder(rec.Body).Decode(&replay); err != nil {
+			t.Fatalf("Test %d: could not decode call: %v", i, err)
+		}
+		if replay.ID == callID || replay.ReplayOf != callID || replay.DeploymentID != test.expectedDeploymentID || !replay.Replayable {
+			t.Errorf("Test %d: Expected a replay of the call, but got %+v", i, replay)
+		}
+		stored, err := ds.GetCall(req.Context(), fn.ID, replay.ID)
+		if err != nil || string(stored.Input) != string(original.Input) || stored.ContentType != original.ContentType {
+			t.Errorf("Test %d: Expected the replay to have the input of the call, but got %+v %v", i, stored, err)
+		}
+		buf.Reset()
+	}
+
+	_, rec = routerRequest(t, srv.Router, http.MethodGet, "/v2/fns/fn_id/calls?replay_of="+callID, nil)
+	var calls models.CallList
+	if err := json.NewDecoder(rec.Body).Decode(&calls); err != nil {
+		t.Fatalf("could not decode calls: %v", err)
+	}
+	if len(calls.Items) != 2 {
+		t.Fatalf("Expected the two replays of the call, but got %+v", calls.Items)
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/id"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestCallGetAndList(t *testing.T) {
+	buf := setLogBuffer()
+
+	a := &models.App{Name: "a", ID: "app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID}
+	now := time.Now()
+	running := &models.Call{ID: id.New().String(), FnID: f.ID, AppID: a.ID, Status: models.CallStateRunning, Timeout: 30, CreatedAt: common.DateTime(now)}
+	done := &models.Call{ID: id.New().String(), FnID: f.ID, AppID: a.ID, Status: models.CallStateSucceeded, Timeout: 30, CreatedAt: common.DateTime(now)}
+	// its server went away long ago
+	lost := &models.Call{ID: id.New().String(), FnID: f.ID, AppID: a.ID, Status: models.CallStateRunning, Timeout: 30, CreatedAt: common.DateTime(now.Add(-time.Hour))}
+	ds := datastore.NewMockInit([]*models.App{a}, []*models.Fn{f}, []*models.Call{running, done, lost})
+
+	rnr, cancel := testRunner(t, ds)
+	defer cancel()
+	srv := testServer(ds, rnr, ServerTypeFull)
+
+	for i, test := range []struct {
+		path           string
+		expectedCode   int
+		expectedStatus string
+	}{
+		{fmt.Sprintf("/v2/fns/%s/calls/%s", f.ID, running.ID), http.StatusOK, models.CallStateRunning},
+		{fmt.Sprintf("/v2/fns/%s/calls/%s", f.ID, done.ID), http.StatusOK, models.CallStateSucceeded},
+		{fmt.Sprintf("/v2/fns/%s/calls/%s", f.ID, lost.ID), http.StatusOK, models.CallStateExpired},
+		{fmt.Sprintf("/v2/fns/%s/calls/%s", "other_fn", done.ID), http.StatusNotFound, ""},
+		{fmt.Sprintf("/v2/fns/%s/calls/%s/log", f.ID, done.ID), http.StatusGone, ""},
+		{fmt.Sprintf("/v2/fns/%s/calls?status=bogus", f.ID), http.StatusBadRequest, ""},
+	} {
+		_, rec := routerRequest(t, srv.Router, http.MethodGet, test.path, nil)
+
+		if rec.Code != test.expectedCode {
+			t.Log(buf.String())
+			t.Fatalf("Test %d: Expected status code to be %d but was %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+
+		if test.expectedStatus != "" {
+			var call models.Call
+			if err := json.NewDecoder(rec.Body).Decode(&call); err != nil {
+				t.Fatalf("Test %d: could not decode call: %v", i, err)
+			}
+			if call.Status != test.expectedStatus {
+				t.Errorf("Test %d: Expected call status to be %s but was %s", i, test.expectedStatus, call.Status)
+			}
+		}
+		buf.Reset()
+	}
+
+	_, rec := routerRequest(t, srv.Router, http.MethodGet, fmt.Sprintf("/v2/fns/%s/calls?status=running", f.ID), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code to be %d but was %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var calls models.CallList
+	if err := json.NewDecoder(rec.Body).Decode(&calls); err != nil {
+		t.Fatalf("could not decode calls: %v", err)
+	}
+	if len(calls.Items) != 1 || calls.Items[0].ID != running.ID {
+		t.Fatalf("Expected only the running call, but got %+v", calls.Items)
+	}
+}
+
+func TestAsyncCallsListener(t *testing.T) {
+	ctx := context.Background()
+	ds := datastore.NewMock()
+	ac := &asyncCalls{ds: func() models.Datastore { return ds }}
+
+	newCall := func() *models.Call {
+		call := &models.Call{ID: id.New().String(), FnID: "fn_id", Type: models.TypeDetached, CreatedAt: common.DateTime(time.Now())}
+		req := httptest.NewRequest(http.MethodPost, "/invoke/fn_id", nil)
+		if err := ac.enqueue(ctx, req, call, nil, true, nil); err != nil {
+			t.Fatalf("failed to enqueue call: %v", err)
+		}
+		return call
+	}
+
+	for i, test := range []struct {
+		status   string
+		errMsg   string
+		expected string
+	}{
+		{"success", "", models.CallStateSucceeded},
+		{"timeout", "", models.CallStateFailed},
+		{"error", "boom", models.CallStateFailed},
+		{"error", context.Canceled.Error(), models.CallStateCancelled},
+	} {
+		call := newCall()
+		call.Status = "running"
+		if err := ac.BeforeCall(ctx, call); err != nil {
+			t.Fatalf("Test %d: unexpected error starting call: %v", i, err)
+		}
+		call.Status, call.Error = test.status, test.errMsg
+		if err := ac.AfterCall(ctx, call); err != nil {
+			t.Fatalf("Test %d: unexpected error ending call: %v", i, err)
+		}
+
+		got, err := ds.GetCall(ctx, call.FnID, call.ID)
+		if err != nil {
+			t.Fatalf("Test %d: failed to get call: %v", i, err)
+		}
+		if got.Status != test.expected {
+			t.Errorf("Test %d: Expected call status to be %s but was %s", i, test.expected, got.Status)
+		}
+	}
+
+	// a call the agent refused never runs
+	call := newCall()
+	ac.abort(ctx, call, models.ErrCallTimeoutServerBusy)
+	got, _ := ds.GetCall(ctx, call.FnID, call.ID)
+	if got.Status != models.CallStateFailed || got.Error != models.ErrCallTimeoutServerBusy.Error() {
+		t.Errorf("Expected refused call to have failed, but got %+v", got)
+	}
+
+	// and a call that was cancelled while queued must not start
+	call = newCall()
+	ac.abort(ctx, call, context.Canceled)
+	if err := ac.BeforeCall(ctx, call); err != models.ErrCallInvalidTransition {
+		t.Errorf("Expected error `%v` starting a cancelled call, but got `%v`", models.ErrCallInvalidTransition, err)
+	}
+
+	// sync calls are not persisted
+	syncCall := &models.Call{ID: id.New().String(), FnID: "fn_id", Type: models.TypeSync}
+	if err := ac.BeforeCall(ctx, syncCall); err != nil {
+		t.Errorf("unexpected error starting sync call: %v", err)
+	}
+	if _, err := ds.GetCall(ctx, syncCall.FnID, syncCall.ID); err != models.ErrCallNotFound {
+		t.Errorf("Expected sync call to not be persisted, but got `%v`", err)
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/agent/drivers"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/version"
+	"github.com/gin-gonic/gin"
+)
+
+// Capabilities describes what a deployment was compiled and configured with, so that tooling can adapt to it
+// rather than guess. There is no message queue nor log store to report, as calls are queued and their logs kept by
+// the datastore and the agent.
+type Capabilities struct {
+	Version  string `json:"version"`
+	NodeType string `json:"node_type"`
+	// Drivers are the container drivers compiled in
+	Drivers []string `json:"drivers"`
+	// Datastores are the datastore providers compiled in
+	Datastores []string `json:"datastores"`
+	// Datastore is the scheme of the datastore configured, if any
+	Datastore string `json:"datastore,omitempty"`
+	// Auth are the auth modes enabled, eg. admin_token or tls
+	Auth []string `json:"auth"`
+	// Features are the optional server features enabled, eg. async_calls or builds
+	Features []string `json:"features"`
+	// Agent is the capability report of the agent, if it runs fns
+	Agent *agent.Capabilities `json:"agent,omitempty"`
+}
+
+// datastoreScheme returns the scheme of a datastore url, without any credentials in it. The url is not parsed, as
+// mysql ones are not valid urls.
+func datastoreScheme(dbURL string) string {
+	if i := strings.Index(dbURL, "://"); i > 0 {
+		return dbURL[:i]
+	}
+	return ""
+}
+
+type feature struct {
+	name    string
+	enabled bool
+}
+
+func enabledFeatures(features ...feature) []string {
+	names := []string{}
+	for _, f := range features {
+		if f.enabled {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// Capabilities returns the capability report of the server
+func (s *Server) Capabilities() Capabilities {
+	caps := Capabilities{
+		Version:    version.Version,
+		NodeType:   s.nodeType.String(),
+		Drivers:    drivers.Registered(),
+		Datastores: datastore.Providers(),
+		Datastore:  s.datastoreScheme,
+	}
+
+	tls := false
+	if web, ok := s.svcConfigs[WebServer]; ok && web.TLSConfig != nil {
+		tls = true
+	}
+	caps.Auth = enabledFeatures(
+		feature{"admin_token", s.adminToken != ""},
+		feature{"runner_tokens", s.runnerTokens.Enabled()},
+		feature{"payload_encryption", s.payloadKeys != nil},
+		feature{"tls", tls},
+	)
+
+	caps.Features = enabledFeatures(
+		feature{"http_triggers", !s.noHTTTPTriggerEndpoint},
+		feature{"fn_invoke", !s.noFnInvokeEndpoint},
+		feature{"async_calls", s.asyncCalls != nil},
+		feature{"call_queue", s.callQueue != nil && s.asyncCalls != nil},
+		feature{"workflows", s.workflows != nil},
+		feature{"trigger_runs", s.triggerRuns != nil},
+		feature{"cron_triggers", s.cron != nil},
+		feature{"grpc_invoke", s.grpcInvokeServer != nil},
+		feature{"http3", s.http3Port != 0},
+		feature{"response_cache", s.responseCache != nil},
+		feature{"payload_pass_through", s.payloads != nil},
+		feature{"builds", s.builder != nil},
+		feature{"usage_export", len(s.usageExporters) > 0},
+		feature{"placements", s.placements != nil},
+		feature{"request_decompression", s.decompressRequests},
+		feature{"response_compression", s.compressResponses},
+		feature{"profiler", !s.noProfilerEndpoint},
+	)
+
+	if r, ok := s.agent.(agent.CapabilityReporter); ok {
+		agentCaps := r.Capabilities()
+		caps.Agent = &agentCaps
+	}
+	return caps
+}
+
+// handleCapabilities reports the compiled and configured feature set of the server
+func (s *Server) handleCapabilities(c *gin.Context) {
+	c.JSON(http.StatusOK, s.Capabilities())
+}
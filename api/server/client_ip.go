@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// WithTrustedProxies trusts the X-Forwarded-For, X-Real-IP and Forwarded headers of the requests of proxies in
+// cidrs, CIDRs or single addresses, to tell the address of the client of http trigger requests
+func WithTrustedProxies(cidrs []string) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.trustedProxies = nil
+		for _, c := range cidrs {
+			if !strings.Contains(c, "/") {
+				if ip := net.ParseIP(c); ip != nil && ip.To4() != nil {
+					c += "/32"
+				} else {
+					c += "/128"
+				}
+			}
+			_, n, err := net.ParseCIDR(c)
+			if err != nil {
+				return fmt.Errorf("invalid trusted proxy %s: %v", c, err)
+			}
+			s.trustedProxies = append(s.trustedProxies, n)
+		}
+		return nil
+	}
+}
+
+func (s *Server) trustedProxy(ip net.IP) bool {
+	for _, n := range s.trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client of req. Requests of trusted proxies are from the last address their
+// forwarding headers were forwarded for that is not a trusted proxy.
+func (s *Server) clientIP(req *http.Request) string {
+	peer := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	ip := net.ParseIP(peer)
+	if ip == nil || !s.trustedProxy(ip) {
+		return peer
+	}
+
+	chain := forwardedFor(req.Header)
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !s.trustedProxy(chain[i]) || i == 0 {
+			return chain[i].String()
+		}
+	}
+	return peer
+}
+
+// forwardedFor returns the addresses a request was forwarded for, the client first, from the Forwarded header or
+// else the X-Forwarded-For or X-Real-IP headers. Addresses that are not ips, such as obfuscated ones, are skipped.
+func forwardedFor(hdr http.Header) []net.IP {
+	var addrs []string
+	if fwd := hdr["Forwarded"]; len(fwd) > 0 {
+		for _, elem := range strings.Split(strings.Join(fwd, ","), ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					addrs = append(addrs, strings.Trim(kv[1], `"`))
+				}
+			}
+		}
+	} else if xff := hdr["X-Forwarded-For"]; len(xff) > 0 {
+		addrs = strings.Split(strings.Join(xff, ","), ",")
+	} else if realIP := hdr.Get("X-Real-Ip"); realIP != "" {
+		addrs = []string{realIP}
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+	for _, a := range addrs {
+		a = strings.TrimSpace(a)
+		if host, _, err := net.SplitHostPort(a); err == nil {
+			a = host
+		}
+		if ip := net.ParseIP(strings.Trim(a, "[]")); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// setClientHeaders sets the headers telling a fn about the client of an http trigger request, its address and, for
+// requests over TLS, the server name it asked for and the subject of its certificate
+func (s *Server) setClientHeaders(headers http.Header, req *http.Request) {
+	headers.Set("Fn-Http-Client-Ip", s.clientIP(req))
+	if req.TLS == nil {
+		return
+	}
+	if req.TLS.ServerName != "" {
+		headers.Set("Fn-Http-Tls-Server-Name", req.TLS.ServerName)
+	}
+	if len(req.TLS.PeerCertificates) > 0 {
+		headers.Set("Fn-Http-Tls-Client-Subject", req.TLS.PeerCertificates[0].Subject.String())
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	s := &Server{}
+	if err := WithTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, test := range []struct {
+		remote  string
+		headers map[string]string
+		ip      string
+	}{
+		{"203.0.113.7:1234", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.7"},
+		{"10.0.0.1:1234", nil, "10.0.0.1"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 10.1.1.1"}, "1.2.3.4"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 5.6.7.8, 192.168.1.1"}, "5.6.7.8"},
+		{"10.0.0.1:1234", map[string]string{"X-Real-IP": "1.2.3.4"}, "1.2.3.4"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": `for=1.2.3.4;proto=https, for="[2001:db8::17]:4711"`, "X-Forwarded-For": "5.6.7.8"}, "2001:db8::17"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": "for=_hidden, for=10.2.2.2"}, "10.2.2.2"},
+	} {
+		req := &http.Request{RemoteAddr: test.remote, Header: http.Header{}}
+		for k, v := range test.headers {
+			req.Header.Set(k, v)
+		}
+		if ip := s.clientIP(req); ip != test.ip {
+			t.Errorf("test %d: expected client ip %s, got %s", i, test.ip, ip)
+		}
+	}
+
+	if err := WithTrustedProxies([]string{"10.0.0.0/33"})(context.Background(), s); err == nil {
+		t.Fatal("expected an invalid trusted proxy error")
+	}
+}
+
+func TestClientHeaders(t *testing.T) {
+	s := &Server{}
+	req := &http.Request{RemoteAddr: "203.0.113.7:1234", Header: http.Header{}, TLS: &tls.ConnectionState{
+		ServerName:       "fns.example.com",
+		PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "client", Organization: []string{"Acme"}}}},
+	}}
+
+	headers := http.Header{}
+	s.setClientHeaders(headers, req)
+	if ip := headers.Get("Fn-Http-Client-Ip"); ip != "203.0.113.7" {
+		t.Fatalf("unexpected client ip %s", ip)
+	}
+	if sni := headers.Get("Fn-Http-Tls-Server-Name"); sni != "fns.example.com" {
+		t.Fatalf("unexpected server name %s", sni)
+	}
+	if subject := headers.Get("Fn-Http-Tls-Client-Subject"); subject != "CN=client,O=Acme" {
+		t.Fatalf("unexpected client subject %s", subject)
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// The compatibility endpoints let clients written for OpenFaaS or Knative invoke fns unchanged, while they are
+// migrated. Both map the functions they name onto the http triggers of apps, with the function name as the first
+// segment of the trigger source:
+//
+//   OpenFaaS  POST /function/<name>.<app>/<path>        => /t/<app>/<name>/<path>
+//             POST /async-function/<name>.<app>/<path>  => the same, detached, answering 202 with X-Call-Id
+//   Knative   <method> http://<name>.<app>.<domain>/<path> => /t/<app>/<name>/<path>
+//
+// OpenFaaS functions named without a namespace are looked up in the default app of WithOpenFaaSEndpoints.
+
+// WithOpenFaaSEndpoints adds the OpenFaaS gateway invoke endpoints to full and LB nodes. Functions named without a
+// namespace are looked up in defaultApp, if set.
+func WithOpenFaaSEndpoints(defaultApp string) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.openFaaS = true
+		s.openFaaSApp = defaultApp
+		return nil
+	}
+}
+
+// WithKnativeDomain invokes fns on full and LB nodes through the requests for hosts of domain, named as Knative
+// services are, <name>.<app>.<domain>
+func WithKnativeDomain(domain string) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.knativeDomain = strings.TrimSuffix(strings.ToLower(domain), ".")
+		return nil
+	}
+}
+
+// isOpenFaaSRequest returns whether a request invokes a fn through the OpenFaaS endpoints
+func isOpenFaaSRequest(req *http.Request) bool {
+	return strings.HasPrefix(req.URL.Path, "/function/") || strings.HasPrefix(req.URL.Path, "/async-function/")
+}
+
+// knativeService returns the name and app of the fn a request for a Knative host is for, or false if it is not for
+// one
+func (s *Server) knativeService(req *http.Request) (string, string, bool) {
+	if s.knativeDomain == "" {
+		return "", "", false
+	}
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if !strings.HasSuffix(host, "."+s.knativeDomain) {
+		return "", "", false
+	}
+	labels := strings.Split(strings.TrimSuffix(host, "."+s.knativeDomain), ".")
+	if len(labels) != 2 || labels[0] == "" || labels[1] == "" {
+		return "", "", false
+	}
+	return labels[0], labels[1], true
+}
+
+// compatSource returns the source of the trigger of fn name for path
+func compatSource(name, path string) string {
+	return "/" + name + strings.TrimSuffix(path, "/")
+}
+
+// handleKnativeCall invokes the fn of requests for Knative hosts, passing the others on
+func (s *Server) handleKnativeCall(c *gin.Context) {
+	name, app, ok := s.knativeService(c.Request)
+	if !ok {
+		c.Next()
+		return
+	}
+	if err := s.serveHTTPTriggerSource(c, app, compatSource(name, c.Request.URL.Path)); err != nil {
+		handleErrorResponse(c, err)
+	}
+	c.Abort()
+}
+
+// callIDWriter adds the id of the call under the header the clients of a compatible API expect to responses
+type callIDWriter struct {
+	gin.ResponseWriter
+	header string
+}
+
+// callID adds the call id before the headers are written
+func (w *callIDWriter) callID() {
+	if id := w.Header().Get("Fn-Call-Id"); id != "" && !w.Written() {
+		w.Header().Set(w.header, id)
+	}
+}
+
+func (w *callIDWriter) WriteHeader(code int) {
+	w.callID()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *callIDWriter) WriteHeaderNow() {
+	w.callID()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *callIDWriter) Write(b []byte) (int, error) {
+	w.callID()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *callIDWriter) WriteString(str string) (int, error) {
+	w.callID()
+	return w.ResponseWriter.WriteString(str)
+}
+
+// handleOpenFaaSCall invokes the fn of an OpenFaaS function, detached for async functions
+func (s *Server) handleOpenFaaSCall(c *gin.Context) {
+	name, app := c.Param("function_name"), s.openFaaSApp
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name, app = name[:i], name[i+1:]
+	}
+	if name == "" || app == "" {
+		handleErrorResponse(c, models.ErrAppsNotFound)
+		return
+	}
+
+	if strings.HasPrefix(c.Request.URL.Path, "/async-function/") {
+		c.Request.Header.Set("Fn-Invoke-Type", models.TypeDetached)
+	}
+	c.Writer = &callIDWriter{c.Writer, "X-Call-Id"}
+	if err := s.serveHTTPTriggerSource(c, app, compatSource(name, c.Param("path"))); err != nil {
+		handleErrorResponse(c, err)
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/gin-gonic/gin"
+)
+
+func TestKnativeService(t *testing.T) {
+	s := &Server{knativeDomain: "fns.example.com"}
+
+	for _, test := range []struct {
+		host, name, app string
+		ok              bool
+	}{
+		{"hello.myapp.fns.example.com", "hello", "myapp", true},
+		{"Hello.MyApp.fns.example.com:8080", "hello", "myapp", true},
+		{"myapp.fns.example.com", "", "", false},
+		{"a.hello.myapp.fns.example.com", "", "", false},
+		{"hello.myapp.example.com", "", "", false},
+		{"fns.example.com", "", "", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = test.host
+		name, app, ok := s.knativeService(req)
+		if name != test.name || app != test.app || ok != test.ok {
+			t.Errorf("host %s: expected %q %q %v, got %q %q %v", test.host, test.name, test.app, test.ok, name, app, ok)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "hello.myapp.fns.example.com"
+	if _, _, ok := new(Server).knativeService(req); ok {
+		t.Error("expected no Knative services without a domain")
+	}
+}
+
+func TestCompatSource(t *testing.T) {
+	for path, source := range map[string]string{
+		"":       "/hello",
+		"/":      "/hello",
+		"/a/b":   "/hello/a/b",
+		"/a/b/":  "/hello/a/b",
+		"/a%20b": "/hello/a%20b",
+	} {
+		if s := compatSource("hello", path); s != source {
+			t.Errorf("path %q: expected source %s, got %s", path, source, s)
+		}
+	}
+}
+
+func TestOpenFaaSCallNotFound(t *testing.T) {
+	s := &Server{lbReadAccess: datastore.NewMock()}
+	engine := gin.New()
+	engine.Any("/function/:function_name", s.handleOpenFaaSCall)
+	engine.Any("/function/:function_name/*path", s.handleOpenFaaSCall)
+
+	for _, path := range []string{"/function/hello", "/function/hello.myapp", "/function/hello.myapp/a/b"} {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusNotFound, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// MinCompressSize is the smallest response, in bytes, that is compressed for callers that accept it. Responses
+// of unknown length are always compressed.
+const MinCompressSize = 1024
+
+// WithInvokeCompression configures gzip and deflate handling on the invoke and http trigger endpoints. With
+// decompressRequests, request bodies sent with a Content-Encoding are decompressed before they are passed to the
+// fn, and any request size limit applies to the decompressed body. With compressResponses, responses are gzipped
+// for callers that accept it, unless the fn already encoded them.
+func WithInvokeCompression(decompressRequests, compressResponses bool) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.decompressRequests = decompressRequests
+		s.compressResponses = compressResponses
+		return nil
+	}
+}
+
+// invokeCompressionWrap decompresses requests and compresses responses, as configured by WithInvokeCompression
+func (s *Server) invokeCompressionWrap(c *gin.Context) {
+	if s.decompressRequests {
+		if err := decompressRequest(c.Request, s.maxRequestSize); err != nil {
+			handleErrorResponse(c, err)
+			c.Abort()
+			return
+		}
+	}
+
+	if s.compressResponses && acceptsGzip(c.Request) && c.Request.Method != http.MethodHead {
+		gw := &gzipResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = gw
+		defer gw.close()
+	}
+	c.Next()
+}
+
+// decompressRequest replaces the body of req with its decompressed content, if it has a gzip or deflate
+// Content-Encoding. Other encodings are passed to the fn as they are. If max is set, the decompressed body is read
+// up front to check its size, otherwise it is decompressed as the fn reads it, and the body of a client expecting
+// 100 Continue is not read at all until then, so that the call may be turned away before it is uploaded.
+func decompressRequest(req *http.Request, max int64) error {
+	if req.Body == nil {
+		return nil
+	}
+
+	var newReader func(io.Reader) (io.ReadCloser, error)
+	switch strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		newReader = func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+	case "deflate":
+		newReader = zlib.NewReader
+	default:
+		return nil
+	}
+
+	if max <= 0 && expectsContinue(req) {
+		req.Header.Del("Content-Encoding")
+		req.Header.Del("Content-Length")
+		req.ContentLength = -1
+		req.Body = &lazyDecompressor{src: req.Body, newReader: newReader}
+		return nil
+	}
+
+	body, err := newReader(req.Body)
+	if err != nil {
+		return models.ErrInvalidContentEncoding
+	}
+
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+
+	if max <= 0 {
+		req.Body = body
+		return nil
+	}
+
+	defer body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(body, max+1))
+	if err != nil {
+		return models.ErrInvalidContentEncoding
+	}
+	if int64(len(b)) > max {
+		return models.ErrRequestContentTooBig
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	req.ContentLength = int64(len(b))
+	return nil
+}
+
+// lazyDecompressor decompresses src from its first read
+type lazyDecompressor struct {
+	src       io.ReadCloser
+	newReader func(io.Reader) (io.ReadCloser, error)
+	r         io.ReadCloser
+	err       error
+}
+
+func (d *lazyDecompressor) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		if d.r, d.err = d.newReader(d.src); d.err != nil {
+			d.err = models.ErrInvalidContentEncoding
+		}
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.r.Read(p)
+}
+
+func (d *lazyDecompressor) Close() error {
+	if d.r != nil {
+		d.r.Close()
+	}
+	return d.src.Close()
+}
+
+// acceptsGzip checks whether the caller accepts gzipped responses
+func acceptsGzip(req *http.Request) bool {
+	for _, enc := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		enc = strings.TrimSpace(enc)
+		if i := strings.Index(enc, ";"); i >= 0 {
+			if strings.Replace(enc[i:], " ", "", -1) == ";q=0" {
+				continue
+			}
+			enc = enc[:i]
+		}
+		if strings.EqualFold(enc, "gzip") {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter gzips responses that are worth compressing. gin holds the status until the body is first
+// written, so that is when the headers are final and compression is decided.
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *gzipResponseWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	if w.compress(w.Status()) {
+		h := w.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		h.Add("Vary", "Accept-Encoding")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+}
+
+func (w *gzipResponseWriter) compress(status int) bool {
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if cl, err := strconv.Atoi(h.Get("Content-Length")); err == nil && cl < MinCompressSize {
+		return false
+	}
+	return true
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	w.decide()
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gzipResponseWriter) WriteHeaderNow() {
+	w.decide()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *gzipResponseWriter) Flush() {
+	w.decide()
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipResponseWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestInvokeCompression(t *testing.T) {
+	s := &Server{decompressRequests: true, compressResponses: true, maxRequestSize: 2048}
+	engine := gin.New()
+	// echoes the request body, with a content length like fnInvoke sets
+	engine.POST("/invoke/:fn_id", s.invokeCompressionWrap, func(c *gin.Context) {
+		b, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			handleErrorResponse(c, err)
+			return
+		}
+		c.Header("Content-Length", strconv.Itoa(len(b)))
+		c.Header("X-Content-Encoding", c.Request.Header.Get("Content-Encoding"))
+		c.Status(http.StatusOK)
+		c.Writer.Write(b)
+	})
+
+	compress := func(encoding, body string) io.Reader {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		default:
+			return strings.NewReader(body)
+		}
+		w.Write([]byte(body))
+		w.Close()
+		return &buf
+	}
+
+	small := "hello"
+	large := strings.Repeat("a", MinCompressSize)
+	for i, test := range []struct {
+		encoding         string
+		body             io.Reader
+		acceptEncoding   string
+		expectedCode     int
+		expectedBody     string
+		expectedEncoding string
+	}{
+		{"gzip", compress("gzip", small), "", http.StatusOK, small, ""},
+		{"deflate", compress("deflate", small), "", http.StatusOK, small, ""},
+		// other encodings are left to the fn
+		{"br", strings.NewReader(small), "", http.StatusOK, small, ""},
+		{"gzip", strings.NewReader("not gzip"), "", http.StatusBadRequest, "", ""},
+		// the limit applies to the decompressed body
+		{"gzip", compress("gzip", strings.Repeat("a", 4096)), "", http.StatusRequestEntityTooLarge, "", ""},
+		{"", strings.NewReader(large), "gzip, deflate", http.StatusOK, large, "gzip"},
+		{"", strings.NewReader(large), "gzip;q=0, deflate", http.StatusOK, large, ""},
+		// not worth it
+		{"", strings.NewReader(small), "gzip", http.StatusOK, small, ""},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/invoke/fn_id", test.body)
+		if test.encoding != "" {
+			req.Header.Set("Content-Encoding", test.encoding)
+		}
+		if test.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", test.acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+
+		if rec.Code != test.expectedCode {
+			t.Fatalf("Test %d: expected status code %d, got %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+		if test.expectedCode == http.StatusBadRequest && !strings.Contains(rec.Body.String(), models.ErrInvalidContentEncoding.Error()) {
+			t.Errorf("Test %d: expected error %q, got %s", i, models.ErrInvalidContentEncoding, rec.Body.String())
+		}
+		if test.expectedCode != http.StatusOK {
+			continue
+		}
+		if enc := rec.Header().Get("Content-Encoding"); enc != test.expectedEncoding {
+			t.Errorf("Test %d: expected Content-Encoding %q, got %q", i, test.expectedEncoding, enc)
+		}
+		if test.encoding == "gzip" || test.encoding == "deflate" {
+			if enc := rec.Header().Get("X-Content-Encoding"); enc != "" {
+				t.Errorf("Test %d: expected the fn not to see a Content-Encoding, got %q", i, enc)
+			}
+		}
+
+		var body io.Reader = rec.Body
+		if test.expectedEncoding == "gzip" {
+			if rec.Header().Get("Content-Length") != "" {
+				t.Errorf("Test %d: expected no Content-Length on a compressed response", i)
+			}
+			zr, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatalf("Test %d: invalid gzip response: %v", i, err)
+			}
+			body = zr
+		}
+		b, _ := ioutil.ReadAll(body)
+		if string(b) != test.expectedBody {
+			t.Errorf("Test %d: expected body %q, got %q", i, test.expectedBody, b)
+		}
+	}
+}
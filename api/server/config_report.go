@@ -0,0 +1,45 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/fnproject/fn/api/config"
+	"github.com/sirupsen/logrus"
+)
+
+// addConfigChecks checks for settings that conflict with the node type, which are ignored
+func addConfigChecks(nodeType NodeType) {
+	config.Check(func() error {
+		if v := getEnv(EnvNodeType, ""); v != "" && nodeTypeFromString(v).String() != v {
+			return fmt.Errorf("unknown %s=%s, running as a %s node", EnvNodeType, v, nodeType)
+		}
+		return nil
+	})
+
+	ignored := func(name string, nodeTypes ...NodeType) {
+		config.Check(func() error {
+			for _, t := range nodeTypes {
+				if t == nodeType && config.IsSet(name) {
+					return fmt.Errorf("%s is ignored on %s nodes", name, nodeType)
+				}
+			}
+			return nil
+		})
+	}
+	ignored(EnvDBURL, ServerTypeLB, ServerTypePureRunner)
+	ignored(EnvRunnerAddresses, ServerTypeFull, ServerTypeAPI, ServerTypePureRunner)
+	ignored(EnvBuildRegistry, ServerTypeLB, ServerTypePureRunner)
+	ignored(EnvImageScannerURL, ServerTypeLB, ServerTypePureRunner)
+}
+
+// reportConfig logs the settings in effect, and warns about unknown and conflicting ones
+func reportConfig() {
+	for _, s := range config.Settings() {
+		if s.Set {
+			logrus.WithFields(logrus.Fields{"setting": s.Name, "value": s.Value}).Debug("setting")
+		}
+	}
+	for _, warning := range config.Report() {
+		logrus.Warn(warning)
+	}
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/sirupsen/logrus"
+)
+
+// cronLease is the lock held by the server that fires the cron triggers of a deployment
+const cronLease = "cron-triggers"
+
+// WithCronTriggers fires cron triggers on their schedules. The servers sharing the datastore elect the one that
+// fires them by holding a lock of their LockStore, renewed every third of leaseTTL, for leaseTTL. Another server
+// takes over leaseTTL after the one holding it stops. 0 disables cron triggers on the server.
+func WithCronTriggers(leaseTTL time.Duration) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.cronLeaseTTL = leaseTTL
+		return nil
+	}
+}
+
+// cronEntry is the next firing of a cron trigger
+type cronEntry struct {
+	trigger  *models.Trigger
+	schedule *models.CronSchedule
+	opts     *models.TriggerCron
+	loc      *time.Location
+	// next is the time the trigger is scheduled at, and at the time it fires at, after its jitter
+	next time.Time
+	at   time.Time
+}
+
+// cronScheduler fires the cron triggers of all apps while the server holds the cron lock. The triggers are listed
+// each time the lease is renewed, so changes to them take effect within a third of the lease ttl. Firings missed
+// while no server held the lease are found from the last run of each trigger, and handled by its misfire policy.
+type cronScheduler struct {
+	ds   func() models.Datastore
+	fire func(ctx context.Context, trigger *models.Trigger, opts *models.TriggerCron, scheduled time.Time) error
+	lock *common.Lock
+	ttl  time.Duration
+
+	// leader is whether the lock was held at the last sync
+	leader  bool
+	entries map[string]*cronEntry
+}
+
+func newCronScheduler(ds func() models.Datastore, locks common.LockStore, ttl time.Duration, fire func(context.Context, *models.Trigger, *models.TriggerCron, time.Time) error) *cronScheduler {
+	return &cronScheduler{
+		ds:      ds,
+		fire:    fire,
+		lock:    common.NewLock(locks, cronLease, common.NewLockHolder(), ttl),
+		ttl:     ttl,
+		entries: make(map[string]*cronEntry),
+	}
+}
+
+// run schedules triggers until ctx is done, giving up the lock on the way out so that another server takes over
+// without waiting for it to expire
+func (c *cronScheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var synced time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			if err := c.lock.Release(context.Background()); err != nil {
+				logrus.WithError(err).Error("failed to release the cron lock")
+			}
+			return
+		case now := <-ticker.C:
+			if now.Sub(synced) >= c.ttl/3 {
+				c.sync(ctx, now)
+				synced = now
+			}
+			c.tick(ctx, now)
+		}
+	}
+}
+
+// sync acquires or renews the lock and, if it is held, refreshes the triggers to fire
+func (c *cronScheduler) sync(ctx context.Context, now time.Time) {
+	log := common.Logger(ctx).WithField("holder", c.lock.Holder())
+	// the lock may still be held if it cannot be renewed, firing stops once it expires
+	held, err := c.lock.TryAcquire(ctx)
+	if err != nil {
+		log.WithError(err).Error("failed to renew the cron lock")
+	}
+	switch {
+	case held && !c.leader:
+		log.Info("firing cron triggers")
+	case !held && c.leader:
+		log.Info("stopped firing cron triggers, another server takes over")
+	}
+	c.leader = held
+	if !c.leader {
+		c.entries = make(map[string]*cronEntry)
+		return
+	}
+
+	triggers, err := c.triggers(ctx)
+	if err != nil {
+		log.WithError(err).Error("failed to list the cron triggers")
+		return
+	}
+	entries := make(map[string]*cronEntry, len(triggers))
+	for _, t := range triggers {
+		if e, ok := c.entries[t.ID]; ok && time.Time(e.trigger.UpdatedAt).Equal(time.Time(t.UpdatedAt)) {
+			entries[t.ID] = e
+			continue
+		}
+		e, err := c.entry(ctx, t)
+		if err != nil {
+			log.WithError(err).WithField("trigger_id", t.ID).Error("invalid cron trigger")
+			continue
+		}
+		entries[t.ID] = e
+	}
+	c.entries = entries
+}
+
+// triggers lists the enabled cron triggers of all apps
+func (c *cronScheduler) triggers(ctx context.Context) ([]*models.Trigger, error) {
+	var triggers []*models.Trigger
+	appFilter := &models.AppFilter{PerPage: 100}
+	for {
+		apps, err := c.ds().GetApps(ctx, appFilter)
+		if err != nil {
+			return nil, err
+		}
+		for _, app := range apps.Items {
+			filter := &models.TriggerFilter{AppID: app.ID, PerPage: 100}
+			for {
+				list, err := c.ds().GetTriggers(ctx, filter)
+				if err != nil {
+					return nil, err
+				}
+				for _, t := range list.Items {
+					if t.Type == models.TriggerTypeCron && !t.IsDisabled() {
+						triggers = append(triggers, t)
+					}
+				}
+				if list.NextCursor == "" {
+					break
+				}
+				filter.Cursor = list.NextCursor
+			}
+		}
+		if apps.NextCursor == "" {
+			return triggers, nil
+		}
+		appFilter.Cursor = apps.NextCursor
+	}
+}
+
+// entry schedules the next firing of trigger after its last run, or after it was last updated if it has not run
+// since, so that firings missed before the server took the lease are found
+func (c *cronScheduler) entry(ctx context.Context, t *models.Trigger) (*cronEntry, error) {
+	schedule, err := models.ParseCronSchedule(t.Source)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := t.Cron()
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &models.TriggerCron{}
+	}
+	loc, err := opts.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	last := time.Time(t.UpdatedAt)
+	if last.IsZero() {
+		last = time.Time(t.CreatedAt)
+	}
+	runs, err := c.ds().GetTriggerRuns(ctx, &models.TriggerRunFilter{TriggerID: t.ID, PerPage: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(runs.Items) > 0 && time.Time(runs.Items[0].CreatedAt).After(last) {
+		last = time.Time(runs.Items[0].CreatedAt)
+	}
+
+	e := &cronEntry{trigger: t, schedule: schedule, opts: opts, loc: loc}
+	e.scheduleAfter(last)
+	return e, nil
+}
+
+// scheduleAfter sets the next firing of the entry after t
+func (e *cronEntry) scheduleAfter(t time.Time) {
+	e.next = e.schedule.Next(t.In(e.loc))
+	e.at = e.next
+	if e.opts.Jitter > 0 && !e.next.IsZero() {
+		e.at = e.next.Add(time.Duration(rand.Int63n(int64(e.opts.Jitter) * int64(time.Second))))
+	}
+}
+
+// tick fires the triggers due at now. Firings due longer than the lease ttl ago were missed, as no server held the
+// lease, and are dropped or fired once by the misfire policy of their trigger.
+func (c *cronScheduler) tick(ctx context.Context, now time.Time) {
+	if !c.leader {
+		return
+	}
+	for _, e := range c.entries {
+		if e.next.IsZero() || now.Before(e.at) {
+			continue
+		}
+		log := common.Logger(ctx).WithFields(logrus.Fields{"trigger_id": e.trigger.ID, "scheduled": e.next})
+		if now.Sub(e.at) > c.ttl && e.opts.Misfire != models.CronMisfireFireOnce {
+			log.Info("skipping missed cron trigger firing")
+		} else {
+			go func(t *models.Trigger, opts *models.TriggerCron, scheduled time.Time) {
+				if err := c.fire(common.BackgroundContext(ctx), t, opts, scheduled); err != nil {
+					log.WithError(err).Error("failed to fire cron trigger")
+				}
+			}(e.trigger, e.opts, e.next)
+		}
+		e.scheduleAfter(now)
+	}
+}
+
+// fireCronTrigger calls the fn of a cron trigger with the payload of the trigger, as a detached call so that fns
+// running longer than a sync call may be scheduled
+func (s *Server) fireCronTrigger(ctx context.Context, trigger *models.Trigger, opts *models.TriggerCron, scheduled time.Time) error {
+	fn, err := s.datastore.GetFnByID(ctx, trigger.FnID)
+	if err != nil {
+		return err
+	}
+	app, err := s.datastore.GetAppByID(ctx, trigger.AppID)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(opts.Payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Fn-Invoke-Type", models.TypeDetached)
+	req.Header.Set("Fn-Intent", "cron")
+	req.Header.Set("Fn-Cron-Scheduled-At", scheduled.UTC().Format(time.RFC3339))
+
+	// the fn's response is discarded, the run records how its call ended
+	writer := &syncResponseWriter{
+		headers: make(http.Header),
+		Buffer:  new(bytes.Buffer),
+	}
+	return s.fnInvoke(writer, req, app, fn, trigger)
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+type cronFiring struct {
+	trigger   *models.Trigger
+	scheduled time.Time
+}
+
+func newTestCronScheduler(ds models.Datastore, fired chan cronFiring) *cronScheduler {
+	return newCronScheduler(func() models.Datastore { return ds }, datastore.NewLockStore(ds), 30*time.Second, func(ctx context.Context, t *models.Trigger, opts *models.TriggerCron, scheduled time.Time) error {
+		fired <- cronFiring{t, scheduled}
+		return nil
+	})
+}
+
+func cronTrigger(id, schedule string, updated time.Time, opts map[string]interface{}) *models.Trigger {
+	t := &models.Trigger{ID: id, Name: id, AppID: "app_id", FnID: "fn_id", Type: models.TriggerTypeCron, Source: schedule, UpdatedAt: common.DateTime(updated)}
+	if opts != nil {
+		t.Annotations, _ = t.Annotations.With(models.TriggerCronAnnotation, opts)
+	}
+	return t
+}
+
+func expectCronFirings(t *testing.T, fired chan cronFiring, ids ...string) []cronFiring {
+	var firings []cronFiring
+	for range ids {
+		select {
+		case f := <-fired:
+			firings = append(firings, f)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected triggers %v to fire, but got %d firings", ids, len(firings))
+		}
+	}
+	select {
+	case f := <-fired:
+		t.Fatalf("expected triggers %v to fire, but %s fired too", ids, f.trigger.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return firings
+}
+
+func TestCronSchedulerLeader(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	ds := datastore.NewMockInit(
+		[]*models.App{{ID: "app_id", Name: "myapp"}},
+		[]*models.Trigger{cronTrigger("every_minute", "* * * * *", now.Add(-time.Minute), nil)},
+	)
+	fired := make(chan cronFiring, 10)
+	a, b := newTestCronScheduler(ds, fired), newTestCronScheduler(ds, fired)
+
+	a.sync(ctx, now)
+	b.sync(ctx, now)
+	if !a.leader || b.leader {
+		t.Fatalf("expected only the first scheduler to hold the lease, but got %v and %v", a.leader, b.leader)
+	}
+	if len(a.entries) != 1 || len(b.entries) != 0 {
+		t.Fatalf("expected only the leader to schedule the trigger, but got %d and %d entries", len(a.entries), len(b.entries))
+	}
+
+	next := a.entries["every_minute"].next
+	a.tick(ctx, next.Add(-time.Second))
+	expectCronFirings(t, fired)
+	a.tick(ctx, next)
+	b.tick(ctx, next)
+	firings := expectCronFirings(t, fired, "every_minute")
+	if !firings[0].scheduled.Equal(next) {
+		t.Errorf("expected the trigger to fire as scheduled at %v, but it was %v", next, firings[0].scheduled)
+	}
+	if e := a.entries["every_minute"]; !e.next.Equal(next.Add(time.Minute)) {
+		t.Errorf("expected the trigger to be scheduled a minute later, but it is at %v", e.next)
+	}
+
+	// the lease is given up as the leader stops
+	if err := ds.ReleaseLease(ctx, cronLease, a.lock.Holder()); err != nil {
+		t.Fatal(err)
+	}
+	b.sync(ctx, now)
+	a.sync(ctx, now)
+	if a.leader || !b.leader {
+		t.Fatalf("expected the second scheduler to take over the lease, but got %v and %v", a.leader, b.leader)
+	}
+}
+
+func TestCronSchedulerMisfire(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	// triggers whose firings were missed while no server held the lease
+	last := now.Add(-time.Hour)
+	ds := datastore.NewMockInit(
+		[]*models.App{{ID: "app_id", Name: "myapp"}},
+		[]*models.Trigger{
+			cronTrigger("skip", "* * * * *", last, nil),
+			cronTrigger("fire_once", "* * * * *", last, map[string]interface{}{"misfire": models.CronMisfireFireOnce}),
+			cronTrigger("ran", "* * * * *", last, nil),
+		},
+		[]*models.TriggerRun{{ID: "run_id", TriggerID: "ran", CreatedAt: common.DateTime(now)}},
+	)
+	fired := make(chan cronFiring, 10)
+	c := newTestCronScheduler(ds, fired)
+	c.sync(ctx, now)
+	c.tick(ctx, now)
+
+	// the trigger that has just run is not late
+	firings := expectCronFirings(t, fired, "fire_once")
+	if f := firings[0]; f.trigger.ID != "fire_once" || !f.scheduled.Equal(last.Truncate(time.Minute).Add(time.Minute)) {
+		t.Errorf("expected the fire_once trigger to fire once for its missed firings, but got %s scheduled at %v", f.trigger.ID, f.scheduled)
+	}
+	for id, e := range c.entries {
+		if !e.next.After(now) {
+			t.Errorf("expected trigger %s to be scheduled after its missed firings, but it is at %v", id, e.next)
+		}
+	}
+}
+
+func TestCronSchedulerJitter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	ds := datastore.NewMockInit(
+		[]*models.App{{ID: "app_id", Name: "myapp"}},
+		[]*models.Trigger{
+			cronTrigger("jitter", "*/5 * * * *", now, map[string]interface{}{"jitter": 120}),
+			cronTrigger("disabled", "* * * * *", now, nil),
+			{ID: "http", Name: "http", AppID: "app_id", FnID: "fn_id", Type: models.TriggerTypeHTTP, Source: "/src"},
+		},
+	)
+	disabled := true
+	trigger, _ := ds.GetTriggerByID(ctx, "disabled")
+	trigger.Disabled = &disabled
+	if _, err := ds.UpdateTrigger(ctx, trigger); err != nil {
+		t.Fatal(err)
+	}
+
+	fired := make(chan cronFiring, 10)
+	c := newTestCronScheduler(ds, fired)
+	c.sync(ctx, now)
+	if len(c.entries) != 1 {
+		t.Fatalf("expected only the enabled cron trigger to be scheduled, but got %d entries", len(c.entries))
+	}
+	e := c.entries["jitter"]
+	if e.at.Before(e.next) || !e.at.Before(e.next.Add(2*time.Minute)) {
+		t.Fatalf("expected the trigger to fire within 2 minutes of %v, but it fires at %v", e.next, e.at)
+	}
+	next := e.next
+	c.tick(ctx, e.at)
+	if f := expectCronFirings(t, fired, "jitter")[0]; !f.scheduled.Equal(next) {
+		t.Errorf("expected the firing to be of the time it was scheduled at, but got %v", f.scheduled)
+	}
+}
+
+func TestFireCronTrigger(t *testing.T) {
+	cfg, err := agent.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	script := &mock.Script{Images: map[string]*mock.ImageScript{
+		mock.ScriptDefault: {LatencyMsecs: 5},
+	}}
+	a := agent.New(agent.WithConfig(cfg), agent.WithDockerDriver(mock.NewScripted(script)))
+	defer a.Close()
+
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", Name: "myfn", AppID: app.ID, Image: "fnproject/fn-test-utils",
+		ResourceConfig: models.ResourceConfig{Memory: 128, Timeout: 30, IdleTimeout: 30}}
+	trigger := cronTrigger("trigger_id", "@hourly", time.Now(), map[string]interface{}{"payload": map[string]string{"report": "hourly"}})
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn}, []*models.Trigger{trigger})
+	srv := testServer(ds, a, ServerTypeFull, WithCronTriggers(DefaultCronLeaseTTL))
+	if srv.cron == nil {
+		t.Fatal("expected the server to schedule cron triggers")
+	}
+
+	ctx := context.Background()
+	opts, _ := trigger.Cron()
+	if err := srv.fireCronTrigger(ctx, trigger, opts, time.Now().Truncate(time.Hour)); err != nil {
+		t.Fatalf("failed to fire cron trigger: %v", err)
+	}
+	runs, err := ds.GetTriggerRuns(ctx, &models.TriggerRunFilter{TriggerID: trigger.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs.Items) != 1 {
+		t.Fatalf("expected one recorded run, but got %+v", runs.Items)
+	}
+	// firings of cron triggers are recorded without a runs annotation, and lists leave payloads out
+	run, err := ds.GetTriggerRun(ctx, trigger.ID, runs.Items[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(run.Payload) != `{"report":"hourly"}` || run.ContentType != "application/json" || !run.Replayable {
+		t.Fatalf("expected a run of the trigger with its payload, but got %+v", run)
+	}
+	// the fn is called detached, so the call is recorded too
+	if _, err := ds.GetCall(ctx, fn.ID, runs.Items[0].ID); err != nil {
+		t.Errorf("expected the call of the trigger to be recorded, but got %v", err)
+	}
+}
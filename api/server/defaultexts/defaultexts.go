@@ -5,9 +5,17 @@ package defaultexts
 
 import (
 	// import all datastore modules for runtime config
+	_ "github.com/fnproject/fn/api/agent/drivers/containerd"
 	_ "github.com/fnproject/fn/api/agent/drivers/docker"
+	_ "github.com/fnproject/fn/api/agent/drivers/mock"
 	_ "github.com/fnproject/fn/api/datastore/sql"
 	_ "github.com/fnproject/fn/api/datastore/sql/mysql"
 	_ "github.com/fnproject/fn/api/datastore/sql/postgres"
 	_ "github.com/fnproject/fn/api/datastore/sql/sqlite"
+	_ "github.com/fnproject/fn/api/dns/etcd"
+	_ "github.com/fnproject/fn/api/dns/route53"
+	_ "github.com/fnproject/fn/api/dns/zonefile"
+	_ "github.com/fnproject/fn/api/mqs/kafka"
+	_ "github.com/fnproject/fn/api/scratch/gcs"
+	_ "github.com/fnproject/fn/api/scratch/s3"
 )
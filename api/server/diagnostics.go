@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/config"
+	"github.com/gin-gonic/gin"
+)
+
+type crashesResponse struct {
+	Items []agent.ContainerCrash `json:"items"`
+}
+
+type configResponse struct {
+	Items []config.Setting `json:"items"`
+}
+
+type slotQueuesResponse struct {
+	Items []agent.SlotQueueReport `json:"items"`
+}
+
+// handleContainerCrashes lists the hot containers of the runner that died unexpectedly, with the tail of their output
+func (s *Server) handleContainerCrashes(c *gin.Context) {
+	r := s.agent.(agent.CrashReporter)
+	c.JSON(http.StatusOK, crashesResponse{Items: r.ContainerCrashes()})
+}
+
+// handleSlotQueues lists the hot containers of the runner per fn by state, with the recent times calls waited for one
+func (s *Server) handleSlotQueues(c *gin.Context) {
+	r := s.agent.(agent.SlotReporter)
+	c.JSON(http.StatusOK, slotQueuesResponse{Items: r.SlotQueues()})
+}
+
+// handleConfig lists the settings in effect, with secrets redacted
+func (s *Server) handleConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, configResponse{Items: config.Settings()})
+}
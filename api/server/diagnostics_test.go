@@ -0,0 +1,39 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+)
+
+func TestConfigRequiresAdminToken(t *testing.T) {
+	ds := datastore.NewMock()
+
+	get := func(srv *Server, token string) *http.Response {
+		req := createRequest(t, http.MethodGet, "/v2/admin/config", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		_, rec := routerRequest2(t, srv.AdminRouter, req)
+		return rec.Result()
+	}
+
+	// without an admin token, the settings are not served at all
+	if resp := get(testServer(ds, nil, ServerTypeAPI), ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the settings not to be served without an admin token, got %d", resp.StatusCode)
+	}
+
+	srv := testServer(ds, nil, ServerTypeAPI, WithAdminToken("secret"))
+	for _, token := range []string{"", "wrong"} {
+		if resp := get(srv, token); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected the settings to be refused with token %q, got %d", token, resp.StatusCode)
+		}
+	}
+	resp := get(srv, "secret")
+	var config configResponse
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the settings to be served to admins, got %d %v", resp.StatusCode, err)
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// WithDisabledTriggerStatus sets the status disabled triggers respond with, http.StatusServiceUnavailable, or
+// http.StatusNotFound to respond as if they did not exist
+func WithDisabledTriggerStatus(status int) Option {
+	return func(ctx context.Context, s *Server) error {
+		if status != http.StatusServiceUnavailable && status != http.StatusNotFound {
+			return fmt.Errorf("invalid disabled trigger status %d, it must be %d or %d", status, http.StatusServiceUnavailable, http.StatusNotFound)
+		}
+		s.disabledTriggerStatus = status
+		return nil
+	}
+}
+
+// checkEnabled returns the error an invoke of fn, via trigger if it is not nil, fails with if either is disabled.
+// It is checked before the call is made, so that disabled fns and triggers take up no resources of the agent. The
+// agent rejects calls of disabled fns too, for the calls it makes itself, such as chained and mirrored calls.
+func (s *Server) checkEnabled(fn *models.Fn, trigger *models.Trigger) error {
+	if trigger != nil && trigger.IsDisabled() {
+		if s.disabledTriggerStatus == http.StatusNotFound {
+			return models.ErrTriggerNotFound
+		}
+		return models.ErrTriggerDisabled
+	}
+	if fn.IsDisabled() {
+		return models.ErrFnDisabled
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestDisabled(t *testing.T) {
+	disabled := true
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", AppID: app.ID, Name: "myfn", Image: "fnproject/fn-test-utils", Disabled: &disabled}
+	other := &models.Fn{ID: "other_id", AppID: app.ID, Name: "other", Image: "fnproject/fn-test-utils"}
+	triggers := []*models.Trigger{
+		{ID: "t1", AppID: app.ID, FnID: other.ID, Name: "off", Type: "http", Source: "/off", Disabled: &disabled},
+		{ID: "t2", AppID: app.ID, FnID: fn.ID, Name: "on", Type: "http", Source: "/on"},
+	}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn, other}, triggers)
+
+	for i, test := range []struct {
+		triggerStatus int
+		path          string
+		expectedCode  int
+		expectedError error
+	}{
+		{http.StatusServiceUnavailable, "/invoke/fn_id", http.StatusServiceUnavailable, models.ErrFnDisabled},
+		{http.StatusServiceUnavailable, "/t/myapp/on", http.StatusServiceUnavailable, models.ErrFnDisabled},
+		{http.StatusServiceUnavailable, "/t/myapp/off", http.StatusServiceUnavailable, models.ErrTriggerDisabled},
+		{http.StatusNotFound, "/t/myapp/off", http.StatusNotFound, models.ErrTriggerNotFound},
+	} {
+		s := &Server{datastore: ds, lbReadAccess: ds}
+		for _, opt := range []Option{WithDisabledTriggerStatus(test.triggerStatus), WithTriggerMetricsMaxSources(10)} {
+			if err := opt(context.Background(), s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		engine := gin.New()
+		engine.POST("/invoke/:fn_id", s.handleFnInvokeCall)
+		engine.Any("/t/:app_name/*trigger_source", s.handleHTTPTriggerCall)
+
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, test.path, nil))
+		if rec.Code != test.expectedCode {
+			t.Errorf("Test %d: expected status code %d, got %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+		if resp := getErrorResponse(t, rec); resp.Message != test.expectedError.Error() {
+			t.Errorf("Test %d: expected error %q, got %q", i, test.expectedError, resp.Message)
+		}
+	}
+
+	if err := WithDisabledTriggerStatus(http.StatusOK)(context.Background(), &Server{}); err == nil {
+		t.Error("expected statuses other than 503 and 404 to be rejected")
+	}
+}
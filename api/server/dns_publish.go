@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/dns"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+	"github.com/sirupsen/logrus"
+)
+
+// dnsPublisher publishes the HTTP triggers of apps as DNS records as they are created, updated and deleted, along
+// with their fns and apps, see dns.TriggerName. Triggers whose names do not make DNS labels are not published.
+// Failures to publish are logged rather than failing the changes, which are already made by then.
+type dnsPublisher struct {
+	publisher dns.Publisher
+	zone      string
+	lbURL     string
+	lbHost    string
+	ds        func() models.Datastore
+
+	lock sync.Mutex
+	// unpublish are the records to remove once the triggers, fns or apps they are keyed by are updated or deleted
+	unpublish map[string][]*dns.Record
+}
+
+var _ fnext.TriggerListener = new(dnsPublisher)
+var _ fnext.AppListener = new(dnsPublisher)
+var _ fnext.FnListener = new(dnsPublisher)
+
+// WithDNSPublisher publishes the HTTP triggers of apps with publisher, as <trigger>.<app>.<zone> records that resolve
+// to the host of lbURL, the public URL of the load balancer, and map to the URLs of the triggers on it.
+func WithDNSPublisher(publisher dns.Publisher, zone, lbURL string) Option {
+	return func(ctx context.Context, s *Server) error {
+		u, err := url.Parse(lbURL)
+		if err != nil || u.Hostname() == "" {
+			return fmt.Errorf("DNS publication needs the public URL of the load balancer, got %q", lbURL)
+		}
+		p := &dnsPublisher{
+			publisher: publisher,
+			zone:      zone,
+			lbURL:     lbURL,
+			lbHost:    u.Hostname(),
+			ds:        func() models.Datastore { return s.datastore },
+			unpublish: make(map[string][]*dns.Record),
+		}
+		s.AddTriggerListener(p)
+		s.AddFnListener(p)
+		s.AddAppListener(p)
+		return nil
+	}
+}
+
+// record returns the record of trigger t of app, nil if it is not published
+func (p *dnsPublisher) record(app *models.App, t *models.Trigger) *dns.Record {
+	if t.Type != models.TriggerTypeHTTP {
+		return nil
+	}
+	name, ok := dns.TriggerName(t.Name, app.Name, p.zone)
+	if !ok {
+		return nil
+	}
+	annotated, err := annotateTriggerWithBaseURL(p.lbURL, app, t)
+	if err != nil {
+		return nil
+	}
+	endpoint, err := annotated.Annotations.GetString(models.TriggerHTTPEndpointAnnotation)
+	if err != nil {
+		return nil
+	}
+	return &dns.Record{Name: name, Host: p.lbHost, URL: endpoint}
+}
+
+// triggerRecord looks up the app of trigger t for its record
+func (p *dnsPublisher) triggerRecord(ctx context.Context, t *models.Trigger) *dns.Record {
+	if t.Type != models.TriggerTypeHTTP {
+		return nil
+	}
+	app, err := p.ds().GetAppByID(ctx, t.AppID)
+	if err != nil {
+		common.Logger(ctx).WithError(err).WithField("trigger_id", t.ID).Error("Failed to look up the app of trigger to publish")
+		return nil
+	}
+	return p.record(app, t)
+}
+
+func (p *dnsPublisher) publish(ctx context.Context, r *dns.Record) {
+	if r == nil {
+		return
+	}
+	if err := p.publisher.Publish(common.BackgroundContext(ctx), r); err != nil {
+		common.Logger(ctx).WithError(err).WithFields(logrus.Fields{"name": r.Name}).Error("Failed to publish trigger DNS record")
+	}
+}
+
+// later records the records to remove once the change of key is made
+func (p *dnsPublisher) later(key string, records []*dns.Record) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(records) == 0 {
+		delete(p.unpublish, key)
+	} else {
+		p.unpublish[key] = records
+	}
+}
+
+// unpublishLater removes the records recorded for key, except keep
+func (p *dnsPublisher) unpublishLater(ctx context.Context, key string, keep *dns.Record) {
+	p.lock.Lock()
+	records := p.unpublish[key]
+	delete(p.unpublish, key)
+	p.lock.Unlock()
+
+	for _, r := range records {
+		if keep != nil && r.Name == keep.Name {
+			continue
+		}
+		if err := p.publisher.Unpublish(common.BackgroundContext(ctx), r); err != nil {
+			common.Logger(ctx).WithError(err).WithFields(logrus.Fields{"name": r.Name}).Error("Failed to unpublish trigger DNS record")
+		}
+	}
+}
+
+// oldTriggerRecord records the record trigger id is published under before it is updated or deleted
+func (p *dnsPublisher) oldTriggerRecord(ctx context.Context, id string) {
+	old, err := p.ds().GetTriggerByID(ctx, id)
+	if err != nil {
+		return
+	}
+	if r := p.triggerRecord(ctx, old); r != nil {
+		p.later(id, []*dns.Record{r})
+	}
+}
+
+func (p *dnsPublisher) BeforeTriggerCreate(ctx context.Context, t *models.Trigger) error {
+	return nil
+}
+
+func (p *dnsPublisher) AfterTriggerCreate(ctx context.Context, t *models.Trigger) error {
+	p.publish(ctx, p.triggerRecord(ctx, t))
+	return nil
+}
+
+func (p *dnsPublisher) BeforeTriggerUpdate(ctx context.Context, t *models.Trigger) error {
+	p.oldTriggerRecord(ctx, t.ID)
+	return nil
+}
+
+func (p *dnsPublisher) AfterTriggerUpdate(ctx context.Context, t *models.Trigger) error {
+	r := p.triggerRecord(ctx, t)
+	p.publish(ctx, r)
+	// renamed triggers are no longer published under their old names
+	p.unpublishLater(ctx, t.ID, r)
+	return nil
+}
+
+func (p *dnsPublisher) BeforeTriggerDelete(ctx context.Context, triggerID string) error {
+	p.oldTriggerRecord(ctx, triggerID)
+	return nil
+}
+
+func (p *dnsPublisher) AfterTriggerDelete(ctx context.Context, triggerID string) error {
+	p.unpublishLater(ctx, triggerID, nil)
+	return nil
+}
+
+func (p *dnsPublisher) BeforeAppCreate(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (p *dnsPublisher) AfterAppCreate(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (p *dnsPublisher) BeforeAppUpdate(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (p *dnsPublisher) AfterAppUpdate(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+// triggerRecords returns the records of the HTTP triggers of app, those of fn fnID only if it is set
+func (p *dnsPublisher) triggerRecords(ctx context.Context, app *models.App, fnID string) []*dns.Record {
+	var records []*dns.Record
+	filter := &models.TriggerFilter{AppID: app.ID, FnID: fnID, PerPage: 100}
+	for {
+		triggers, err := p.ds().GetTriggers(ctx, filter)
+		if err != nil {
+			common.Logger(ctx).WithError(err).WithField("app_id", app.ID).Error("Failed to list the triggers to unpublish")
+			return records
+		}
+		for _, t := range triggers.Items {
+			if r := p.record(app, t); r != nil {
+				records = append(records, r)
+			}
+		}
+		if triggers.NextCursor == "" {
+			return records
+		}
+		filter.Cursor = triggers.NextCursor
+	}
+}
+
+// BeforeAppDelete records the records of the HTTP triggers of the app, which are deleted along with it
+func (p *dnsPublisher) BeforeAppDelete(ctx context.Context, app *models.App) error {
+	// the app to delete is passed by its id, in place of its name
+	old, err := p.ds().GetAppByID(ctx, app.Name)
+	if err == nil {
+		p.later(old.ID, p.triggerRecords(ctx, old, ""))
+	}
+	return nil
+}
+
+func (p *dnsPublisher) AfterAppDelete(ctx context.Context, app *models.App) error {
+	p.unpublishLater(ctx, app.Name, nil)
+	return nil
+}
+
+func (p *dnsPublisher) BeforeAppGet(ctx context.Context, appID string) error {
+	return nil
+}
+
+func (p *dnsPublisher) AfterAppGet(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (p *dnsPublisher) BeforeAppsList(ctx context.Context, filter *models.AppFilter) error {
+	return nil
+}
+
+func (p *dnsPublisher) AfterAppsList(ctx context.Context, apps []*models.App) error {
+	return nil
+}
+
+func (p *dnsPublisher) BeforeFnCreate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (p *dnsPublisher) AfterFnCreate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (p *dnsPublisher) BeforeFnUpdate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (p *dnsPublisher) AfterFnUpdate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+// BeforeFnDelete records the records of the HTTP triggers of the fn, which are deleted along with it
+func (p *dnsPublisher) BeforeFnDelete(ctx context.Context, fnID string) error {
+	fn, err := p.ds().GetFnByID(ctx, fnID)
+	if err != nil {
+		return nil
+	}
+	app, err := p.ds().GetAppByID(ctx, fn.AppID)
+	if err == nil {
+		p.later(fnID, p.triggerRecords(ctx, app, fnID))
+	}
+	return nil
+}
+
+func (p *dnsPublisher) AfterFnDelete(ctx context.Context, fnID string) error {
+	p.unpublishLater(ctx, fnID, nil)
+	return nil
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/dns"
+	"github.com/fnproject/fn/api/models"
+)
+
+type recordingPublisher struct {
+	lock    sync.Mutex
+	records map[string]dns.Record
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, r *dns.Record) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.records[r.Name] = *r
+	return nil
+}
+
+func (p *recordingPublisher) Unpublish(ctx context.Context, r *dns.Record) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.records, r.Name)
+	return nil
+}
+
+func TestDNSPublisher(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", Name: "myfn", AppID: app.ID, Image: "fnproject/fn-test-utils"}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn})
+	pub := &recordingPublisher{records: make(map[string]dns.Record)}
+	srv := testServer(ds, nil, ServerTypeAPI, WithDNSPublisher(pub, "fns.example.com", "https://lb.example.com"))
+
+	request := func(method, path, body string, expected int) *bytes.Buffer {
+		_, rec := routerRequest(t, srv.Router, method, path, bytes.NewBufferString(body))
+		if rec.Code != expected {
+			t.Fatalf("expected status %d for %s %s, got %d: %s", expected, method, path, rec.Code, rec.Body.String())
+		}
+		return rec.Body
+	}
+	expectRecords := func(names ...string) {
+		pub.lock.Lock()
+		defer pub.lock.Unlock()
+		if len(pub.records) != len(names) {
+			t.Fatalf("expected records %v, got %v", names, pub.records)
+		}
+		for _, name := range names {
+			if _, ok := pub.records[name]; !ok {
+				t.Fatalf("expected records %v, got %v", names, pub.records)
+			}
+		}
+	}
+
+	body := request(http.MethodPost, "/v2/triggers", `{"app_id":"app_id","fn_id":"fn_id","name":"Hello_World","type":"http","source":"/hello"}`, http.StatusOK)
+	trigger := &models.Trigger{}
+	if err := json.NewDecoder(body).Decode(trigger); err != nil {
+		t.Fatal(err)
+	}
+	expectRecords("hello-world.myapp.fns.example.com")
+	expected := dns.Record{Name: "hello-world.myapp.fns.example.com", Host: "lb.example.com", URL: "https://lb.example.com/t/myapp/hello"}
+	if r := pub.records[expected.Name]; r != expected {
+		t.Fatalf("expected %+v, got %+v", expected, r)
+	}
+
+	// renamed triggers are published under their new names only
+	request(http.MethodPut, "/v2/triggers/"+trigger.ID, `{"name":"bye"}`, http.StatusOK)
+	expectRecords("bye.myapp.fns.example.com")
+
+	request(http.MethodDelete, "/v2/triggers/"+trigger.ID, "", http.StatusNoContent)
+	expectRecords()
+
+	request(http.MethodPost, "/v2/triggers", `{"app_id":"app_id","fn_id":"fn_id","name":"again","type":"http","source":"/again"}`, http.StatusOK)
+	expectRecords("again.myapp.fns.example.com")
+	request(http.MethodDelete, "/v2/fns/fn_id", "", http.StatusNoContent)
+	expectRecords()
+
+	request(http.MethodPost, "/v2/fns", `{"app_id":"app_id","name":"myfn","image":"fnproject/fn-test-utils"}`, http.StatusOK)
+	fns := &models.FnList{}
+	if err := json.NewDecoder(request(http.MethodGet, "/v2/fns?app_id=app_id", "", http.StatusOK)).Decode(fns); err != nil {
+		t.Fatal(err)
+	}
+	request(http.MethodPost, "/v2/triggers", `{"app_id":"app_id","fn_id":"`+fns.Items[0].ID+`","name":"again","type":"http","source":"/again"}`, http.StatusOK)
+	expectRecords("again.myapp.fns.example.com")
+	request(http.MethodDelete, "/v2/apps/app_id", "", http.StatusNoContent)
+	expectRecords()
+}
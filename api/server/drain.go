@@ -0,0 +1,202 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrServerDraining is returned to the requests that reach a server shutting down, on connections it accepted
+// before it stopped listening
+var ErrServerDraining = models.NewAPIError(http.StatusServiceUnavailable, errors.New("Server is shutting down, please retry"))
+
+// WithDrainTimeouts sets how long a server shutting down waits for its in-flight management requests, and for its
+// in-flight invoke and http trigger requests, before it cancels them. 0 waits for them to finish.
+func WithDrainTimeouts(api, invoke time.Duration) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.apiDrain.timeout = api
+		s.invokeDrain.timeout = invoke
+		return nil
+	}
+}
+
+// longestFnTimeout is the longest timeout a fn may have under limits and the limits of apps, up to the timeout of
+// long running fns, which is how long an invoke may be in flight for
+func longestFnTimeout(limits models.ResourceLimits, apps map[string]models.ResourceLimits) time.Duration {
+	longest := limits.Timeout
+	if longest == 0 {
+		longest = models.MaxLongRunningTimeout
+	}
+	for _, app := range apps {
+		if app.Timeout > longest {
+			longest = app.Timeout
+		}
+	}
+	if longest > models.MaxLongRunningTimeout {
+		longest = models.MaxLongRunningTimeout
+	}
+	return time.Duration(longest) * time.Second
+}
+
+// defaultInvokeDrainTimeout is the longest timeout a fn may have under limits and the limits of apps, up to
+// MaxDefaultInvokeDrainTimeout, so that a server shutting down is not held for hours by default
+func defaultInvokeDrainTimeout(limits models.ResourceLimits, apps map[string]models.ResourceLimits) time.Duration {
+	timeout := longestFnTimeout(limits, apps)
+	if timeout > MaxDefaultInvokeDrainTimeout {
+		timeout = MaxDefaultInvokeDrainTimeout
+	}
+	return timeout
+}
+
+// drainGroup tracks in-flight requests, to drain them on shutdown
+type drainGroup struct {
+	name    string
+	timeout time.Duration
+	wg      *common.WaitGroup
+
+	lock    sync.Mutex
+	next    uint64
+	cancels map[uint64]context.CancelFunc
+}
+
+func newDrainGroup(name string) *drainGroup {
+	return &drainGroup{name: name, wg: common.NewWaitGroup(), cancels: make(map[uint64]context.CancelFunc)}
+}
+
+// add tracks a request, returning its context, which is canceled if it is still running past the timeout of the
+// drain, and the func to call once it is done. ok is false if the group is draining.
+func (g *drainGroup) add(ctx context.Context) (rctx context.Context, done func(), ok bool) {
+	if !g.wg.AddSession(1) {
+		return nil, nil, false
+	}
+	rctx, cancel := context.WithCancel(ctx)
+
+	g.lock.Lock()
+	id := g.next
+	g.next++
+	g.cancels[id] = cancel
+	g.lock.Unlock()
+
+	return rctx, func() {
+		g.lock.Lock()
+		delete(g.cancels, id)
+		g.lock.Unlock()
+		cancel()
+		g.wg.DoneSession()
+	}, true
+}
+
+// drain stops the group taking requests and returns a channel closed once its in-flight requests are done,
+// canceling those still running past its timeout
+func (g *drainGroup) drain() <-chan struct{} {
+	done := g.wg.CloseGroupNB()
+	if g.timeout <= 0 {
+		return done
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		select {
+		case <-done:
+			return
+		case <-time.After(g.timeout):
+		}
+
+		g.lock.Lock()
+		logrus.WithFields(logrus.Fields{"requests": g.name, "in_flight": len(g.cancels)}).Warn("Drain timed out, canceling in-flight requests")
+		for _, cancel := range g.cancels {
+			cancel()
+		}
+		g.lock.Unlock()
+		<-done
+	}()
+	return drained
+}
+
+// isInvokeRequest returns whether a request invokes a fn, through an http trigger, its invoke endpoint or the
+// compatibility endpoints
+func (s *Server) isInvokeRequest(req *http.Request) bool {
+	if strings.HasPrefix(req.URL.Path, "/t/") || strings.HasPrefix(req.URL.Path, "/invoke/") {
+		return true
+	}
+	if s.openFaaS && isOpenFaaSRequest(req) {
+		return true
+	}
+	if s.lambda && isLambdaRequest(req) {
+		return true
+	}
+	_, _, ok := s.knativeService(req)
+	return ok
+}
+
+// drainMiddleware tracks the in-flight requests of the server, and turns requests away once it is shutting down
+func (s *Server) drainMiddleware(c *gin.Context) {
+	g := s.apiDrain
+	if s.isInvokeRequest(c.Request) {
+		g = s.invokeDrain
+	}
+
+	ctx, done, ok := g.add(c.Request.Context())
+	if !ok {
+		c.Header("Connection", "close")
+		handleErrorResponse(c, ErrServerDraining)
+		c.Abort()
+		return
+	}
+	defer done()
+
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
+
+// shutdown stops server listening and drains its in-flight requests. The agent is closed once the invoke requests
+// are drained, while management requests may still be draining.
+func (s *Server) shutdown(server *http.Server) {
+	logrus.Info("Shutting down, draining in-flight requests")
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		// the drain groups bound how long the handlers run, this returns once they are all done
+		if err := server.Shutdown(context.Background()); err != nil {
+			logrus.WithError(err).Error("server shutdown error")
+		}
+	}()
+
+	apiDrained := s.apiDrain.drain()
+	<-s.invokeDrain.drain()
+	s.stopGRPCInvoke()
+	s.closeAgent()
+	<-apiDrained
+	s.closeHTTP3()
+	<-stopped
+}
+
+// stopGRPCInvoke stops the grpc invoke service, whose calls are drained with the invoke requests
+func (s *Server) stopGRPCInvoke() {
+	if s.grpcInvokeServer != nil {
+		s.grpcInvokeServer.GracefulStop()
+	}
+}
+
+func (s *Server) closeAgent() {
+	if s.agent == nil {
+		return
+	}
+	err := s.agent.Close() // after we stop taking requests, wait for all tasks to finish
+	if err != nil {
+		logrus.WithError(err).Error("Fail to close the agent")
+	}
+	if s.placements != nil {
+		s.placements.close()
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestDrainGroup(t *testing.T) {
+	g := newDrainGroup("test")
+	g.timeout = 50 * time.Millisecond
+
+	ctx, done, ok := g.add(context.Background())
+	if !ok {
+		t.Fatal("expected the request to be tracked")
+	}
+
+	drained := g.drain()
+	if _, _, ok := g.add(context.Background()); ok {
+		t.Fatal("expected a draining group to turn requests away")
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the in-flight request to be canceled past the drain timeout")
+	}
+	select {
+	case <-drained:
+		t.Fatal("expected the drain to wait for the canceled request to be done")
+	default:
+	}
+
+	done()
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the group to be drained")
+	}
+}
+
+func TestLongestFnTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		limits   models.ResourceLimits
+		apps     map[string]models.ResourceLimits
+		expected time.Duration
+	}{
+		{expected: 4 * time.Hour},
+		{limits: models.ResourceLimits{Timeout: 120}, expected: 2 * time.Minute},
+		{limits: models.ResourceLimits{Timeout: 120}, apps: map[string]models.ResourceLimits{"batch": {Timeout: 3600}, "web": {Memory: 128}}, expected: time.Hour},
+		{limits: models.ResourceLimits{Timeout: 120}, apps: map[string]models.ResourceLimits{"batch": {Timeout: 86400}}, expected: 4 * time.Hour},
+	} {
+		if got := longestFnTimeout(tc.limits, tc.apps); got != tc.expected {
+			t.Errorf("expected %v for %+v %+v, got %v", tc.expected, tc.limits, tc.apps, got)
+		}
+	}
+}
+
+func TestDefaultInvokeDrainTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		limits   models.ResourceLimits
+		apps     map[string]models.ResourceLimits
+		expected time.Duration
+	}{
+		{expected: MaxDefaultInvokeDrainTimeout},
+		{limits: models.ResourceLimits{Timeout: 120}, expected: 2 * time.Minute},
+		{limits: models.ResourceLimits{Timeout: 120}, apps: map[string]models.ResourceLimits{"batch": {Timeout: 3600}}, expected: MaxDefaultInvokeDrainTimeout},
+	} {
+		if got := defaultInvokeDrainTimeout(tc.limits, tc.apps); got != tc.expected {
+			t.Errorf("expected %v for %+v %+v, got %v", tc.expected, tc.limits, tc.apps, got)
+		}
+	}
+}
+
+func TestDrainingServer(t *testing.T) {
+	ds := datastore.NewMock()
+	srv := testServer(ds, nil, ServerTypeAPI)
+
+	_, rec := routerRequest(t, srv.Router, http.MethodGet, "/v2/apps", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	<-srv.apiDrain.drain()
+	_, rec = routerRequest(t, srv.Router, http.MethodGet, "/v2/apps", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d once draining, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
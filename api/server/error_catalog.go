@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorCatalog replaces the messages of the errors of responses with templates, by error code, in the languages
+// clients accept. Deployments use it to localize messages, or to word them for their users and hide the internal
+// details of server errors.
+//
+// Catalogs are JSON files of messages by language, then by error code, such as models.GetErrorCode returns, or by
+// status code for the errors of a status without a message of their own:
+//
+//	{
+//	  "default_language": "en",
+//	  "messages": {
+//	    "en": {"image_never_pulled": "{{.image}} is not deployed yet", "500": "Something went wrong, quote {{.request_id}}"},
+//	    "fr": {"apps_not_found": "Application introuvable"}
+//	  }
+//	}
+//
+// Templates are given the parameters of the error, along with its code, status, request_id and default message.
+// Errors without a message in the catalog keep their default one.
+type ErrorCatalog struct {
+	defaultLanguage string
+	messages        map[string]map[string]*template.Template
+}
+
+type errorCatalogFile struct {
+	DefaultLanguage string                       `json:"default_language"`
+	Messages        map[string]map[string]string `json:"messages"`
+}
+
+// NewErrorCatalog parses the JSON catalog b
+func NewErrorCatalog(b []byte) (*ErrorCatalog, error) {
+	var f errorCatalogFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("invalid error catalog: %v", err)
+	}
+	c := &ErrorCatalog{
+		defaultLanguage: strings.ToLower(f.DefaultLanguage),
+		messages:        make(map[string]map[string]*template.Template, len(f.Messages)),
+	}
+	for lang, messages := range f.Messages {
+		lang = strings.ToLower(lang)
+		c.messages[lang] = make(map[string]*template.Template, len(messages))
+		for key, msg := range messages {
+			t, err := template.New(key).Option("missingkey=zero").Parse(msg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid error catalog message %s of language %s: %v", key, lang, err)
+			}
+			c.messages[lang][key] = t
+		}
+	}
+	if _, ok := c.messages[c.defaultLanguage]; c.defaultLanguage != "" && !ok {
+		return nil, fmt.Errorf("invalid error catalog, it has no messages in its default language %s", c.defaultLanguage)
+	}
+	return c, nil
+}
+
+// LoadErrorCatalog reads the JSON catalog at path
+func LoadErrorCatalog(path string) (*ErrorCatalog, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewErrorCatalog(b)
+}
+
+// languages returns the languages of the catalog an Accept-Language header asks for, by preference, then the
+// default language of the catalog
+func (c *ErrorCatalog) languages(acceptLanguage string) []string {
+	type pref struct {
+		lang string
+		q    float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(part, ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" || lang == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			prefs = append(prefs, pref{lang, q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	var langs []string
+	seen := make(map[string]bool)
+	add := func(lang string) {
+		if _, ok := c.messages[lang]; ok && !seen[lang] {
+			seen[lang] = true
+			langs = append(langs, lang)
+		}
+	}
+	for _, p := range prefs {
+		// fr-CA falls back to fr
+		add(p.lang)
+		add(strings.SplitN(p.lang, "-", 2)[0])
+	}
+	add(c.defaultLanguage)
+	return langs
+}
+
+// errorMessages are the messages of a catalog in the languages a client accepts, in the context of its request
+type errorMessages struct {
+	catalog   *ErrorCatalog
+	languages []string
+}
+
+type errorMessagesKey struct{}
+
+// errorCatalogMiddleware has the errors of responses worded by the catalog of the server, in the languages the
+// client accepts
+func (s *Server) errorCatalogMiddleware(c *gin.Context) {
+	m := &errorMessages{catalog: s.errorCatalog, languages: s.errorCatalog.languages(c.GetHeader("Accept-Language"))}
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), errorMessagesKey{}, m))
+	c.Next()
+}
+
+// localize returns the message of err in the catalog of ctx, or its default message if there is none
+func localize(ctx context.Context, status int, err error) string {
+	m, ok := ctx.Value(errorMessagesKey{}).(*errorMessages)
+	if !ok {
+		return err.Error()
+	}
+	code, params := models.GetErrorCode(err)
+	for _, lang := range m.languages {
+		messages := m.catalog.messages[lang]
+		t, ok := messages[code]
+		if !ok {
+			if t, ok = messages[strconv.Itoa(status)]; !ok {
+				continue
+			}
+		}
+
+		data := make(map[string]string, len(params)+4)
+		for k, v := range params {
+			data[k] = v
+		}
+		data["code"] = code
+		data["status"] = strconv.Itoa(status)
+		data["message"] = err.Error()
+		data["request_id"] = common.RequestIDFromContext(ctx)
+
+		var buf bytes.Buffer
+		if terr := t.Execute(&buf, data); terr != nil {
+			common.Logger(ctx).WithError(terr).WithField("message", t.Name()).Error("error executing error catalog message")
+			return err.Error()
+		}
+		return buf.String()
+	}
+	return err.Error()
+}
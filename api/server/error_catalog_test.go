@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+const testErrorCatalog = `{
+  "default_language": "en",
+  "messages": {
+    "en": {"apps_not_found": "No such app", "image_never_pulled": "{{.image}} is not deployed yet", "500": "Something went wrong, quote {{.request_id}}"},
+    "fr": {"apps_not_found": "Application introuvable"}
+  }
+}`
+
+func TestErrorCatalogLanguages(t *testing.T) {
+	c, err := NewErrorCatalog([]byte(testErrorCatalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, test := range []struct {
+		acceptLanguage string
+		expected       []string
+	}{
+		{"", []string{"en"}},
+		{"fr", []string{"fr", "en"}},
+		{"fr-CA, de;q=0.8", []string{"fr", "en"}},
+		{"de, en;q=0.5, fr;q=0.9", []string{"fr", "en"}},
+		{"fr;q=0", []string{"en"}},
+	} {
+		if langs := c.languages(test.acceptLanguage); !reflect.DeepEqual(langs, test.expected) {
+			t.Errorf("Test %d: expected languages %v, got %v", i, test.expected, langs)
+		}
+	}
+
+	if _, err := NewErrorCatalog([]byte(`{"default_language": "de", "messages": {"en": {}}}`)); err == nil {
+		t.Error("expected a catalog without messages in its default language to be invalid")
+	}
+	if _, err := NewErrorCatalog([]byte(`{"messages": {"en": {"500": "{{.oops"}}}`)); err == nil {
+		t.Error("expected a catalog with an invalid template to be invalid")
+	}
+}
+
+func TestErrorCatalogLocalize(t *testing.T) {
+	c, err := NewErrorCatalog([]byte(testErrorCatalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(common.WithRequestID(context.Background(), "rid"), errorMessagesKey{}, &errorMessages{catalog: c, languages: c.languages("fr")})
+
+	neverPulled := models.NewCodedError(http.StatusBadGateway, "image_never_pulled", map[string]string{"image": "fnproject/hello"}, errors.New("not pulled"))
+	for i, test := range []struct {
+		ctx      context.Context
+		status   int
+		err      error
+		expected string
+	}{
+		{ctx, http.StatusNotFound, models.ErrAppsNotFound, "Application introuvable"},
+		{ctx, http.StatusBadGateway, neverPulled, "fnproject/hello is not deployed yet"},
+		{ctx, http.StatusInternalServerError, ErrInternalServerError, "Something went wrong, quote rid"},
+		{ctx, http.StatusBadRequest, models.ErrAppsMissingName, models.ErrAppsMissingName.Error()},
+		{context.Background(), http.StatusNotFound, models.ErrAppsNotFound, models.ErrAppsNotFound.Error()},
+	} {
+		if msg := localize(test.ctx, test.status, test.err); msg != test.expected {
+			t.Errorf("Test %d: expected message %q, got %q", i, test.expected, msg)
+		}
+	}
+}
+
+func TestErrorCatalogResponses(t *testing.T) {
+	f, err := ioutil.TempFile("", "error_catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(testErrorCatalog); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	cfg, err := agent.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	a := agent.New(agent.WithConfig(cfg), agent.WithDockerDriver(mock.NewScripted(&mock.Script{})))
+	defer a.Close()
+	srv := testServer(datastore.NewMock(), a, ServerTypeFull, WithErrorCatalog(f.Name()))
+
+	req := createRequest(t, http.MethodGet, "/v2/apps/nope", nil)
+	req.Header.Set("Accept-Language", "fr-FR")
+	_, rec := routerRequest2(t, srv.Router, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status code %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if resp := getErrorResponse(t, rec); resp.Message != "Application introuvable" || resp.Code != "apps_not_found" {
+		t.Errorf("expected the message of the catalog in french, got %+v", resp)
+	}
+}
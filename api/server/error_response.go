@@ -18,7 +18,12 @@ import (
 var ErrInternalServerError = errors.New("internal server error")
 
 func simpleError(err error) *models.Error {
-	return &models.Error{Message: err.Error()}
+	code, _ := models.GetErrorCode(err)
+	e := &models.Error{Message: err.Error(), Code: code, Errors: models.GetFieldErrors(err)}
+	if fe, ok := err.(models.FieldsError); ok {
+		e.Fields = fe.Fields()
+	}
+	return e
 }
 
 func handleErrorResponse(c *gin.Context, err error) {
@@ -66,7 +71,9 @@ func WriteError(ctx context.Context, w http.ResponseWriter, statuscode int, err
 	log := common.Logger(ctx)
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(statuscode)
-	err = json.NewEncoder(w).Encode(simpleError(err))
+	body := simpleError(err)
+	body.Message = localize(ctx, statuscode, err)
+	err = json.NewEncoder(w).Encode(body)
 	if err != nil {
 		log.WithError(err).Errorln("error encoding error json")
 	}
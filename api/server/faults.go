@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type faultsResponse struct {
+	Items []agent.Fault `json:"items"`
+}
+
+// handleFaultList lists the faults injected into the operations of the node
+func (s *Server) handleFaultList(c *gin.Context) {
+	faults, err := s.agent.(agent.FaultInjector).Faults()
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, faultsResponse{Items: faults})
+}
+
+// handleFaultAdd starts injecting a fault into the operations of the node
+func (s *Server) handleFaultAdd(c *gin.Context) {
+	var f agent.Fault
+	if err := c.BindJSON(&f); err != nil {
+		handleErrorResponse(c, models.ErrInvalidJSON)
+		return
+	}
+	fault, err := s.agent.(agent.FaultInjector).AddFault(f)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{"fault_id": fault.ID, "op": fault.Op, "app_id": fault.AppID, "fn_id": fault.FnID,
+		"percent": fault.Percent, "by": c.ClientIP()}).Warn("Fault added")
+	c.JSON(http.StatusOK, fault)
+}
+
+// handleFaultRemove stops injecting a fault
+func (s *Server) handleFaultRemove(c *gin.Context) {
+	faultID := c.Param("fault_id")
+	if err := s.agent.(agent.FaultInjector).RemoveFault(faultID); err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{"fault_id": faultID, "by": c.ClientIP()}).Info("Fault removed")
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+)
+
+// fnChains checks that fns only chain and mirror to fns that exist, in the same
+// app. Chained and mirrored fns that are deleted later are skipped by the agent
+// when it gets to them.
+type fnChains struct {
+	ds func() models.Datastore
+}
+
+var _ fnext.FnListener = new(fnChains)
+
+// appID returns the app of fn, which updates leave out
+func (f *fnChains) appID(ctx context.Context, fn *models.Fn) (string, error) {
+	if fn.AppID != "" {
+		return fn.AppID, nil
+	}
+	old, err := f.ds().GetFnByID(ctx, fn.ID)
+	if err != nil {
+		return "", err
+	}
+	return old.AppID, nil
+}
+
+func (f *fnChains) check(ctx context.Context, fn *models.Fn) error {
+	chain := fn.Chain
+	if chain.IsEmpty() {
+		return nil
+	}
+	appID, err := f.appID(ctx, fn)
+	if err != nil {
+		return err
+	}
+	for _, id := range []string{chain.OnSuccess, chain.OnFailure} {
+		if id == "" {
+			continue
+		}
+		target, err := f.ds().GetFnByID(ctx, id)
+		if err == models.ErrFnsNotFound {
+			return models.ErrFnChainTargetNotFound
+		} else if err != nil {
+			return err
+		}
+		if target.AppID != appID {
+			return models.ErrFnChainTargetNotFound
+		}
+	}
+	return nil
+}
+
+func (f *fnChains) checkMirror(ctx context.Context, fn *models.Fn) error {
+	mirror, err := fn.Annotations.Mirror()
+	if err != nil || mirror == nil {
+		return err
+	}
+	if mirror.FnID == fn.ID {
+		return models.ErrFnInvalidMirror
+	}
+	appID, err := f.appID(ctx, fn)
+	if err != nil {
+		return err
+	}
+	target, err := f.ds().GetFnByID(ctx, mirror.FnID)
+	if err == models.ErrFnsNotFound {
+		return models.ErrFnMirrorTargetNotFound
+	} else if err != nil {
+		return err
+	}
+	if target.AppID != appID {
+		return models.ErrFnMirrorTargetNotFound
+	}
+	return nil
+}
+
+func (f *fnChains) BeforeFnCreate(ctx context.Context, fn *models.Fn) error {
+	if err := f.check(ctx, fn); err != nil {
+		return err
+	}
+	return f.checkMirror(ctx, fn)
+}
+
+func (f *fnChains) BeforeFnUpdate(ctx context.Context, fn *models.Fn) error {
+	if err := f.check(ctx, fn); err != nil {
+		return err
+	}
+	return f.checkMirror(ctx, fn)
+}
+
+func (f *fnChains) AfterFnCreate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (f *fnChains) AfterFnUpdate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (f *fnChains) BeforeFnDelete(ctx context.Context, fnID string) error {
+	return nil
+}
+
+func (f *fnChains) AfterFnDelete(ctx context.Context, fnID string) error {
+	return nil
+}
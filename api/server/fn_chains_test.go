@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestFnChainTargets(t *testing.T) {
+	buf := setLogBuffer()
+
+	a := &models.App{Name: "a", ID: "app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID, Image: "fnproject/fn-test-utils"}
+	f.SetDefaults()
+	b := &models.App{Name: "b", ID: "other_app_id"}
+	o := &models.Fn{ID: "other_fn_id", Name: "o", AppID: b.ID, Image: "fnproject/fn-test-utils"}
+	o.SetDefaults()
+	ds := datastore.NewMockInit([]*models.App{a, b}, []*models.Fn{f, o})
+
+	rnr, cancel := testRunner(t, ds)
+	defer cancel()
+	srv := testServer(ds, rnr, ServerTypeFull)
+
+	for i, test := range []struct {
+		method        string
+		path          string
+		body          string
+		expectedCode  int
+		expectedError string
+		expectedChain *models.FnChain
+	}{
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "g", "image": "fnproject/fn-test-utils", "chain": {"on_success": "missing"}}`, http.StatusBadRequest, models.ErrFnChainTargetNotFound.Error(), nil},
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "g", "image": "fnproject/fn-test-utils", "chain": {"on_success": "fn_id"}}`, http.StatusOK, "", &models.FnChain{OnSuccess: "fn_id"}},
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "h", "image": "fnproject/fn-test-utils", "chain": {"on_success": "other_fn_id"}}`, http.StatusBadRequest, models.ErrFnChainTargetNotFound.Error(), nil},
+		{http.MethodPut, "/v2/fns/fn_id", `{"chain": {"on_failure": "missing"}}`, http.StatusBadRequest, models.ErrFnChainTargetNotFound.Error(), nil},
+		{http.MethodPut, "/v2/fns/fn_id", `{"chain": {"on_failure": "other_fn_id"}}`, http.StatusBadRequest, models.ErrFnChainTargetNotFound.Error(), nil},
+		{http.MethodPut, "/v2/fns/fn_id", `{"chain": {"on_failure": "fn_id"}}`, http.StatusOK, "", &models.FnChain{OnFailure: "fn_id"}},
+		// an empty chain removes it
+		{http.MethodPut, "/v2/fns/fn_id", `{"chain": {}}`, http.StatusOK, "", nil},
+	} {
+		_, rec := routerRequest(t, srv.Router, test.method, test.path, bytes.NewBufferString(test.body))
+
+		if rec.Code != test.expectedCode {
+			t.Log(buf.String())
+			t.Fatalf("Test %d: Expected status code to be %d but was %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+
+		if test.expectedError != "" {
+			resp := getErrorResponse(t, rec)
+			if !strings.Contains(resp.Message, test.expectedError) {
+				t.Errorf("Test %d: Expected error message to have `%s`, but was `%s`", i, test.expectedError, resp.Message)
+			}
+		} else {
+			var fn models.Fn
+			if err := json.NewDecoder(rec.Body).Decode(&fn); err != nil {
+				t.Fatalf("Test %d: could not decode fn: %v", i, err)
+			}
+			if !fn.Chain.Equals(test.expectedChain) {
+				t.Errorf("Test %d: Expected chain to be %v but was %v", i, test.expectedChain, fn.Chain)
+			}
+		}
+		buf.Reset()
+	}
+}
+
+func TestFnMirrorTargets(t *testing.T) {
+	a := &models.App{Name: "a", ID: "app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID, Image: "fnproject/fn-test-utils"}
+	f.SetDefaults()
+	b := &models.App{Name: "b", ID: "other_app_id"}
+	o := &models.Fn{ID: "other_fn_id", Name: "o", AppID: b.ID, Image: "fnproject/fn-test-utils"}
+	o.SetDefaults()
+	ds := datastore.NewMockInit([]*models.App{a, b}, []*models.Fn{f, o})
+	srv := testServer(ds, nil, ServerTypeAPI)
+
+	for i, test := range []struct {
+		method        string
+		path          string
+		body          string
+		expectedCode  int
+		expectedError string
+	}{
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "g", "image": "fnproject/fn-test-utils", "annotations": {"fnproject.io/fn/mirror": {"fn_id": "missing", "percent": 10}}}`, http.StatusBadRequest, models.ErrFnMirrorTargetNotFound.Error()},
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "g", "image": "fnproject/fn-test-utils", "annotations": {"fnproject.io/fn/mirror": {"fn_id": "fn_id"}}}`, http.StatusBadRequest, models.ErrFnInvalidMirror.Error()},
+		{http.MethodPut, "/v2/fns/fn_id", `{"annotations": {"fnproject.io/fn/mirror": {"fn_id": "fn_id", "percent": 10}}}`, http.StatusBadRequest, models.ErrFnInvalidMirror.Error()},
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "h", "image": "fnproject/fn-test-utils", "annotations": {"fnproject.io/fn/mirror": {"fn_id": "other_fn_id", "percent": 10}}}`, http.StatusBadRequest, models.ErrFnMirrorTargetNotFound.Error()},
+		{http.MethodPut, "/v2/fns/fn_id", `{"annotations": {"fnproject.io/fn/mirror": {"fn_id": "other_fn_id", "percent": 10}}}`, http.StatusBadRequest, models.ErrFnMirrorTargetNotFound.Error()},
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "g", "image": "fnproject/fn-test-utils", "annotations": {"fnproject.io/fn/mirror": {"fn_id": "fn_id", "percent": 10}}}`, http.StatusOK, ""},
+	} {
+		_, rec := routerRequest(t, srv.Router, test.method, test.path, bytes.NewBufferString(test.body))
+
+		if rec.Code != test.expectedCode {
+			t.Fatalf("Test %d: Expected status code to be %d but was %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+		if test.expectedError != "" {
+			resp := getErrorResponse(t, rec)
+			if !strings.Contains(resp.Message, test.expectedError) {
+				t.Errorf("Test %d: Expected error message to have `%s`, but was `%s`", i, test.expectedError, resp.Message)
+			}
+		}
+	}
+}
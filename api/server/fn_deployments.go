@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/id"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+)
+
+// fnDeployments records the provenance of the images deployed to fns, whenever a fn is created or its image changes
+type fnDeployments struct {
+	ds func() models.Datastore
+}
+
+var _ fnext.FnListener = new(fnDeployments)
+
+func (d *fnDeployments) record(ctx context.Context, fn *models.Fn) error {
+	ds := d.ds()
+	last, err := ds.GetFnDeployments(ctx, &models.FnDeploymentFilter{FnID: fn.ID, PerPage: 1})
+	if err != nil {
+		return err
+	}
+	if len(last.Items) > 0 && last.Items[0].Image == fn.Image {
+		return nil
+	}
+
+	deployment := &models.FnDeployment{
+		ID:              id.New().String(),
+		FnID:            fn.ID,
+		AppID:           fn.AppID,
+		Image:           fn.Image,
+		Digest:          models.ImageDigest(fn.Image),
+		DeployedBy:      common.PrincipalFromContext(ctx),
+		SignatureStatus: models.SignatureUnverified,
+		CreatedAt:       common.DateTime(time.Now()),
+	}
+	if commit, err := fn.Annotations.GetString(models.FnSourceCommitAnnotation); err == nil {
+		deployment.SourceCommit = commit
+	}
+	if deployment.Digest != "" {
+		scan, err := ds.GetImageScan(ctx, deployment.Digest)
+		if err == nil {
+			deployment.Scanned = true
+			deployment.Vulnerabilities = scan.Vulnerabilities
+		} else if err != models.ErrImageScanNotFound {
+			return err
+		}
+	}
+	return ds.InsertFnDeployment(ctx, deployment)
+}
+
+// after records the deployment of fn once it is stored, failing to record it does not fail the change to the fn
+func (d *fnDeployments) after(ctx context.Context, fn *models.Fn) error {
+	if err := d.record(ctx, fn); err != nil {
+		common.Logger(ctx).WithError(err).WithField("fn_id", fn.ID).Error("failed to record fn deployment")
+	}
+	return nil
+}
+
+func (d *fnDeployments) BeforeFnCreate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (d *fnDeployments) BeforeFnUpdate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (d *fnDeployments) AfterFnCreate(ctx context.Context, fn *models.Fn) error {
+	return d.after(ctx, fn)
+}
+
+func (d *fnDeployments) AfterFnUpdate(ctx context.Context, fn *models.Fn) error {
+	return d.after(ctx, fn)
+}
+
+func (d *fnDeployments) BeforeFnDelete(ctx context.Context, fnID string) error {
+	return nil
+}
+
+func (d *fnDeployments) AfterFnDelete(ctx context.Context, fnID string) error {
+	return nil
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleFnDeploymentGet(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	deployment, err := s.datastore.GetFnDeployment(ctx, c.Param(api.FnID), c.Param(api.DeploymentID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deployment)
+}
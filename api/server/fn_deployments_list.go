@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleFnDeploymentList(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var filter models.FnDeploymentFilter
+	filter.Cursor, filter.PerPage = pageParams(c)
+	filter.FnID = c.Param(api.FnID)
+
+	deployments, err := s.datastore.GetFnDeployments(ctx, &filter)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deployments)
+}
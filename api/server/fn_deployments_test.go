@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestFnDeployments(t *testing.T) {
+	buf := setLogBuffer()
+
+	a := &models.App{Name: "a", ID: "app_id"}
+	scan := &models.ImageScan{Digest: "sha256:4f53", Vulnerabilities: models.ImageVulnerabilities{models.SeverityMedium: 2}}
+	ds := datastore.NewMockInit([]*models.App{a}, []*models.ImageScan{scan})
+	srv := testServer(ds, nil, ServerTypeAPI)
+	// an auth extension sets who made the request
+	srv.AddAPIMiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(common.WithPrincipal(r.Context(), r.Header.Get("X-Test-User"))))
+		})
+	})
+
+	request := func(method, path, user, body string, v interface{}) {
+		req := createRequest(t, method, path, bytes.NewBufferString(body))
+		req.Header.Set("X-Test-User", user)
+		_, rec := routerRequest2(t, srv.Router, req)
+		if rec.Code != http.StatusOK {
+			t.Log(buf.String())
+			t.Fatalf("expected %s %s to succeed, got %d: %s", method, path, rec.Code, rec.Body.String())
+		}
+		if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var fn models.Fn
+	request(http.MethodPost, "/v2/fns", "alice", `{"app_id": "app_id", "name": "f", "image": "fnproject/hello:0.0.1", "annotations": {"fnproject.io/fn/source-commit": "9fceb02"}}`, &fn)
+	// updates that keep the image are not deployments
+	request(http.MethodPut, "/v2/fns/"+fn.ID, "alice", `{"memory": 256}`, &fn)
+	request(http.MethodPut, "/v2/fns/"+fn.ID, "bob", `{"image": "fnproject/hello@sha256:4f53", "annotations": {"fnproject.io/fn/source-commit": "b0d1e5"}}`, &fn)
+
+	var list models.FnDeploymentList
+	request(http.MethodGet, "/v2/fns/"+fn.ID+"/deployments", "", "", &list)
+	if len(list.Items) != 2 {
+		t.Fatalf("expected 2 deployments, got %+v", list.Items)
+	}
+	latest, first := list.Items[0], list.Items[1]
+	if first.Image != "fnproject/hello:0.0.1" || first.Digest != "" || first.DeployedBy != "alice" || first.SourceCommit != "9fceb02" || first.Scanned {
+		t.Errorf("unexpected first deployment %+v", first)
+	}
+	if latest.Digest != "sha256:4f53" || latest.DeployedBy != "bob" || latest.SourceCommit != "b0d1e5" || !latest.Scanned ||
+		latest.Vulnerabilities[models.SeverityMedium] != 2 || latest.SignatureStatus != models.SignatureUnverified {
+		t.Errorf("unexpected latest deployment %+v", latest)
+	}
+
+	var got models.FnDeployment
+	request(http.MethodGet, "/v2/fns/"+fn.ID+"/deployments/"+first.ID, "", "", &got)
+	if got.ID != first.ID || got.Image != first.Image {
+		t.Errorf("expected to get the first deployment, got %+v", got)
+	}
+	_, rec := routerRequest(t, srv.Router, http.MethodGet, "/v2/fns/"+fn.ID+"/deployments/missing", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected a missing deployment not to be found, got %d", rec.Code)
+	}
+}
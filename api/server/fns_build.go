@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/build"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/id"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// maxBuildLogSize is the most of the output of a build returned to its caller
+const maxBuildLogSize = 64 * 1024
+
+// WithBuilder enables building the images of fns from source with builder, the images are pushed to registry,
+// eg. registry.example.com/fns
+func WithBuilder(builder build.Builder, registry string) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.builder = builder
+		s.buildRegistry = strings.TrimSuffix(registry, "/")
+		return nil
+	}
+}
+
+// WithBuildLimits sets the largest source archive a build accepts, and how long a build may take
+func WithBuildLimits(maxContextSize int64, timeout time.Duration) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.maxBuildContextSize = maxContextSize
+		s.buildTimeout = timeout
+		return nil
+	}
+}
+
+type buildResponse struct {
+	ID        string     `json:"id"`
+	Buildpack string     `json:"buildpack,omitempty"`
+	Image     string     `json:"image,omitempty"`
+	Digest    string     `json:"digest,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Log       string     `json:"log"`
+	Fn        *models.Fn `json:"fn,omitempty"`
+}
+
+// handleFnBuild builds the image of a fn from a tar archive of its source in the request body, with its Dockerfile
+// or a buildpack, pushes it to the build registry and updates the fn to use it
+func (s *Server) handleFnBuild(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	fn, err := s.datastore.GetFnByID(ctx, c.Param(api.FnID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	app, err := s.datastore.GetAppByID(ctx, fn.AppID)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	src, err := build.NewSource(c.Request.Body, build.SourceOptions{
+		Dockerfile: c.Query("dockerfile"),
+		Buildpack:  c.Query("buildpack"),
+		Cmd:        c.Query("cmd"),
+	})
+	if err != nil {
+		handleErrorResponse(c, models.NewAPIError(http.StatusBadRequest, err))
+		return
+	}
+	defer src.Close()
+
+	buildID := id.New().String()
+	output := new(buildLog)
+	req := &build.Request{
+		Image:      s.buildImage(app, fn, buildID),
+		Context:    src,
+		Dockerfile: src.Dockerfile,
+		Output:     output,
+	}
+
+	buildCtx := ctx
+	if s.buildTimeout > 0 {
+		var cancel context.CancelFunc
+		buildCtx, cancel = context.WithTimeout(ctx, s.buildTimeout)
+		defer cancel()
+	}
+	res, err := s.builder.Build(buildCtx, req)
+	if err != nil {
+		common.Logger(ctx).WithError(err).WithField("fn_id", fn.ID).Info("fn build failed")
+		c.JSON(http.StatusBadRequest, buildResponse{ID: buildID, Buildpack: src.Buildpack, Error: err.Error(), Log: output.String()})
+		return
+	}
+
+	fn, err = s.datastore.UpdateFn(ctx, &models.Fn{ID: fn.ID, Image: res.Image})
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, buildResponse{ID: buildID, Buildpack: src.Buildpack, Image: res.Image, Digest: res.Digest, Log: output.String(), Fn: fn})
+}
+
+// buildLog keeps the last maxBuildLogSize bytes of the output of a build, where its errors are
+type buildLog struct {
+	lock sync.Mutex
+	buf  []byte
+}
+
+func (l *buildLog) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.buf = append(l.buf, p...)
+	if len(l.buf) > maxBuildLogSize {
+		l.buf = append(l.buf[:0], l.buf[len(l.buf)-maxBuildLogSize:]...)
+	}
+	return len(p), nil
+}
+
+func (l *buildLog) String() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return string(l.buf)
+}
+
+// buildImage is the name and tag the image of a build of fn is pushed as, eg. registry.example.com/fns/app-fn:01D4Z
+func (s *Server) buildImage(app *models.App, fn *models.Fn, buildID string) string {
+	return s.buildRegistry + "/" + strings.ToLower(app.Name+"-"+fn.Name) + ":" + strings.ToLower(buildID)
+}
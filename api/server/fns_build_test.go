@@ -0,0 +1,119 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/build"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func tarArchive(t *testing.T, files map[string]string) io.Reader {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, content)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+type testBuilder struct {
+	req   *build.Request
+	files map[string]string
+	err   error
+}
+
+func (b *testBuilder) Build(ctx context.Context, req *build.Request) (*build.Result, error) {
+	b.req = req
+	b.files = make(map[string]string)
+	tr := tar.NewReader(req.Context)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		content, _ := ioutil.ReadAll(tr)
+		b.files[hdr.Name] = string(content)
+	}
+	fmt.Fprintf(req.Output, "Step 1/1 : FROM fnproject/go\n")
+	if b.err != nil {
+		return nil, b.err
+	}
+	repo := req.Image[:strings.LastIndex(req.Image, ":")]
+	return &build.Result{Image: repo + "@sha256:4f53", Digest: "sha256:4f53"}, nil
+}
+
+func TestFnBuild(t *testing.T) {
+	buf := setLogBuffer()
+
+	a := &models.App{Name: "myapp", ID: "app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "MyFn", AppID: a.ID, Image: "fnproject/fn-test-utils"}
+	f.SetDefaults()
+	ds := datastore.NewMockInit([]*models.App{a}, []*models.Fn{f})
+	builder := &testBuilder{}
+	srv := testServer(ds, nil, ServerTypeAPI, WithBuilder(builder, "registry.example.com/fns/"))
+
+	src := map[string]string{"build/Dockerfile": "FROM fnproject/go", "func.go": "package main"}
+	_, rec := routerRequest(t, srv.Router, http.MethodPost, "/v2/fns/fn_id/build?dockerfile=build/Dockerfile", tarArchive(t, src))
+	if rec.Code != http.StatusOK {
+		t.Log(buf.String())
+		t.Fatalf("expected the build to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp buildResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(builder.req.Image, "registry.example.com/fns/myapp-myfn:") || builder.req.Dockerfile != "build/Dockerfile" || !reflect.DeepEqual(builder.files, src) {
+		t.Errorf("unexpected build request %+v with source %v", builder.req, builder.files)
+	}
+	if resp.Buildpack != "" || resp.Digest != "sha256:4f53" || resp.Fn == nil || resp.Fn.Image != "registry.example.com/fns/myapp-myfn@sha256:4f53" || !strings.Contains(resp.Log, "FROM") {
+		t.Errorf("unexpected build response %+v", resp)
+	}
+	fn, err := ds.GetFnByID(context.Background(), "fn_id")
+	if err != nil || fn.Image != "registry.example.com/fns/myapp-myfn@sha256:4f53" {
+		t.Errorf("expected the fn to use the built image, got %+v %v", fn, err)
+	}
+
+	// without a Dockerfile the go buildpack is detected
+	_, rec = routerRequest(t, srv.Router, http.MethodPost, "/v2/fns/fn_id/build", tarArchive(t, map[string]string{"func.go": "package main"}))
+	resp = buildResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != http.StatusOK || resp.Buildpack != "go" {
+		t.Errorf("expected the go buildpack to build the fn, got %d: %+v", rec.Code, resp)
+	}
+	if !strings.Contains(builder.files[builder.req.Dockerfile], "fnproject/go:dev") {
+		t.Errorf("expected a go Dockerfile to be generated, got %v", builder.files)
+	}
+
+	builder.err = errors.New("build failed: exit code 1")
+	_, rec = routerRequest(t, srv.Router, http.MethodPost, "/v2/fns/fn_id/build", tarArchive(t, src))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "exit code 1") || !strings.Contains(rec.Body.String(), "FROM") {
+		t.Errorf("expected the build to fail with its log, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	_, rec = routerRequest(t, srv.Router, http.MethodPost, "/v2/fns/fn_id/build", tarArchive(t, map[string]string{"README": "hi"}))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "no buildpack detects it") {
+		t.Errorf("expected a source without Dockerfile or buildpack not to be built, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	_, rec = routerRequest(t, srv.Router, http.MethodPost, "/v2/fns/missing/build", tarArchive(t, src))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected a missing fn not to be built, got %d", rec.Code)
+	}
+}
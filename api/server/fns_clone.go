@@ -0,0 +1,37 @@
+package server
+
+import (
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// handleFnClone creates a copy of a fn, with the name and overrides of the request, in the app of the fn unless the
+// request names another
+func (s *Server) handleFnClone(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	source, err := s.datastore.GetFnByID(ctx, c.Param(api.FnID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	overrides := &models.Fn{}
+	if err := c.BindJSON(overrides); err != nil {
+		if !models.IsAPIError(err) {
+			err = models.ErrInvalidJSON
+		}
+		handleErrorResponse(c, err)
+		return
+	}
+
+	fn := models.CloneFn(source, overrides)
+	fn.SetDefaults()
+	fnCreated, err := s.datastore.InsertFn(ctx, fn)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	s.writeCreatedFn(c, fnCreated)
+}
@@ -10,7 +10,6 @@ import (
 
 func (s *Server) handleFnCreate(c *gin.Context) {
 	ctx := c.Request.Context()
-	log := common.Logger(ctx)
 
 	fn := &models.Fn{}
 	err := c.BindJSON(fn)
@@ -29,6 +28,14 @@ func (s *Server) handleFnCreate(c *gin.Context) {
 		return
 	}
 
+	s.writeCreatedFn(c, fnCreated)
+}
+
+// writeCreatedFn writes a fn that was created, annotated as fns are when they are read
+func (s *Server) writeCreatedFn(c *gin.Context, fnCreated *models.Fn) {
+	ctx := c.Request.Context()
+	log := common.Logger(ctx)
+
 	app, err := s.datastore.GetAppByID(ctx, fnCreated.AppID)
 	if err != nil {
 		log.Debugln("Failed to lookup app.")
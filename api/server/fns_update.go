@@ -39,3 +39,33 @@ func (s *Server) handleFnUpdate(c *gin.Context) {
 
 	c.JSON(http.StatusOK, fnUpdated)
 }
+
+// handleFnPatch updates a fn with a JSON merge patch or JSON patch of it, see patchUpdate
+func (s *Server) handleFnPatch(c *gin.Context) {
+	ctx := c.Request.Context()
+	pathFnID := c.Param(api.FnID)
+
+	fn, err := s.datastore.GetFnByID(ctx, pathFnID)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	update := &models.Fn{}
+	if err := patchUpdate(c, fn, update); err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	if update.ID != "" && update.ID != pathFnID {
+		handleErrorResponse(c, models.ErrFnsIDMismatch)
+		return
+	}
+	update.ID = pathFnID
+
+	fnUpdated, err := s.datastore.UpdateFn(ctx, update)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, fnUpdated)
+}
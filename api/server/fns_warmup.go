@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+type warmupResponse struct {
+	Requested int    `json:"requested"`
+	Warmed    int    `json:"warmed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// handleFnWarmup starts hot containers for a fn ahead of its first calls
+func (s *Server) handleFnWarmup(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	count := 1
+	if v := c.Query("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > models.MaxWarmupCount {
+			handleErrorResponse(c, models.ErrInvalidWarmupCount)
+			return
+		}
+		count = n
+	}
+
+	w, ok := s.agent.(agent.Warmer)
+	if !ok {
+		handleErrorResponse(c, models.ErrWarmupUnsupported)
+		return
+	}
+
+	fn, err := s.lbReadAccess.GetFnByID(ctx, c.Param(api.FnID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	app, err := s.lbReadAccess.GetAppByID(ctx, fn.AppID)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	fn, err = s.resourceLimits.limit(app, fn)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	warmed, err := w.Warmup(ctx, count, agent.FromHTTPFnRequest(app, fn, c.Request))
+	if warmed == 0 && err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	resp := warmupResponse{Requested: count, Warmed: warmed}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestFnWarmupValidation(t *testing.T) {
+	buf := setLogBuffer()
+
+	a := &models.App{Name: "a", ID: "app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID, Image: "fnproject/fn-test-utils"}
+	ds := datastore.NewMockInit([]*models.App{a}, []*models.Fn{f})
+
+	rnr, cancel := testRunner(t, ds)
+	defer cancel()
+	srv := testServer(ds, rnr, ServerTypeFull)
+
+	for i, test := range []struct {
+		path          string
+		expectedCode  int
+		expectedError string
+	}{
+		{"/v2/fns/fn_id/warmup?count=0", http.StatusBadRequest, models.ErrInvalidWarmupCount.Error()},
+		{"/v2/fns/fn_id/warmup?count=101", http.StatusBadRequest, models.ErrInvalidWarmupCount.Error()},
+		{"/v2/fns/fn_id/warmup?count=many", http.StatusBadRequest, models.ErrInvalidWarmupCount.Error()},
+		{"/v2/fns/missing/warmup", http.StatusNotFound, models.ErrFnsNotFound.Error()},
+	} {
+		_, rec := routerRequest(t, srv.Router, http.MethodPost, test.path, nil)
+
+		if rec.Code != test.expectedCode {
+			t.Log(buf.String())
+			t.Fatalf("Test %d: Expected status code to be %d but was %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+
+		resp := getErrorResponse(t, rec)
+		if !strings.Contains(resp.Message, test.expectedError) {
+			t.Errorf("Test %d: Expected error message to have `%s`, but was `%s`", i, test.expectedError, resp.Message)
+		}
+		buf.Reset()
+	}
+}
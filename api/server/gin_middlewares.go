@@ -59,7 +59,7 @@ func optionalCorsWrap(r *gin.Engine) {
 			corsConfig.AllowHeaders = headers
 		}
 
-		corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "HEAD", "DELETE"}
+		corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "HEAD", "DELETE"}
 
 		logrus.Infof("CORS enabled for domains: %s", origins)
 
@@ -170,7 +170,7 @@ func apiMetricsWrap(s *Server) {
 				logrus.Fatal(err)
 			}
 			stats.Record(ctx, apiResponseCountMeasure.M(0))
-			stats.Record(ctx, apiLatencyMeasure.M(int64(time.Since(start)/time.Millisecond)))
+			common.RecordWithExemplar(ctx, apiLatencyMeasure.M(int64(time.Since(start)/time.Millisecond)))
 
 		}
 	}
@@ -0,0 +1,534 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: invoke.proto
+
+package invoke
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Header struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value                string   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Header) Reset()         { *m = Header{} }
+func (m *Header) String() string { return proto.CompactTextString(m) }
+func (*Header) ProtoMessage()    {}
+func (*Header) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2156226f9a4f30f8, []int{0}
+}
+
+func (m *Header) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Header.Unmarshal(m, b)
+}
+func (m *Header) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Header.Marshal(b, m, deterministic)
+}
+func (m *Header) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Header.Merge(m, src)
+}
+func (m *Header) XXX_Size() int {
+	return xxx_messageInfo_Header.Size(m)
+}
+func (m *Header) XXX_DiscardUnknown() {
+	xxx_messageInfo_Header.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Header proto.InternalMessageInfo
+
+func (m *Header) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *Header) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+// Request to call a fn, headers are passed to it as those of an http request
+// to the invoke endpoint, eg. Fn-Invoke-Type
+type InvokeRequest struct {
+	FnId                 string    `protobuf:"bytes,1,opt,name=fn_id,json=fnId,proto3" json:"fn_id,omitempty"`
+	Headers              []*Header `protobuf:"bytes,2,rep,name=headers,proto3" json:"headers,omitempty"`
+	Body                 []byte    `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *InvokeRequest) Reset()         { *m = InvokeRequest{} }
+func (m *InvokeRequest) String() string { return proto.CompactTextString(m) }
+func (*InvokeRequest) ProtoMessage()    {}
+func (*InvokeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2156226f9a4f30f8, []int{1}
+}
+
+func (m *InvokeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InvokeRequest.Unmarshal(m, b)
+}
+func (m *InvokeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InvokeRequest.Marshal(b, m, deterministic)
+}
+func (m *InvokeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InvokeRequest.Merge(m, src)
+}
+func (m *InvokeRequest) XXX_Size() int {
+	return xxx_messageInfo_InvokeRequest.Size(m)
+}
+func (m *InvokeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_InvokeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InvokeRequest proto.InternalMessageInfo
+
+func (m *InvokeRequest) GetFnId() string {
+	if m != nil {
+		return m.FnId
+	}
+	return ""
+}
+
+func (m *InvokeRequest) GetHeaders() []*Header {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
+}
+
+func (m *InvokeRequest) GetBody() []byte {
+	if m != nil {
+		return m.Body
+	}
+	return nil
+}
+
+// Response of a fn, with the http status and headers it responded with
+type InvokeResponse struct {
+	StatusCode           int32     `protobuf:"varint,1,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	CallId               string    `protobuf:"bytes,2,opt,name=call_id,json=callId,proto3" json:"call_id,omitempty"`
+	Headers              []*Header `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty"`
+	Body                 []byte    `protobuf:"bytes,4,opt,name=body,proto3" json:"body,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *InvokeResponse) Reset()         { *m = InvokeResponse{} }
+func (m *InvokeResponse) String() string { return proto.CompactTextString(m) }
+func (*InvokeResponse) ProtoMessage()    {}
+func (*InvokeResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2156226f9a4f30f8, []int{2}
+}
+
+func (m *InvokeResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InvokeResponse.Unmarshal(m, b)
+}
+func (m *InvokeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InvokeResponse.Marshal(b, m, deterministic)
+}
+func (m *InvokeResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InvokeResponse.Merge(m, src)
+}
+func (m *InvokeResponse) XXX_Size() int {
+	return xxx_messageInfo_InvokeResponse.Size(m)
+}
+func (m *InvokeResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_InvokeResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InvokeResponse proto.InternalMessageInfo
+
+func (m *InvokeResponse) GetStatusCode() int32 {
+	if m != nil {
+		return m.StatusCode
+	}
+	return 0
+}
+
+func (m *InvokeResponse) GetCallId() string {
+	if m != nil {
+		return m.CallId
+	}
+	return ""
+}
+
+func (m *InvokeResponse) GetHeaders() []*Header {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
+}
+
+func (m *InvokeResponse) GetBody() []byte {
+	if m != nil {
+		return m.Body
+	}
+	return nil
+}
+
+type InvokeRequestFrame struct {
+	// Types that are valid to be assigned to Frame:
+	//	*InvokeRequestFrame_Request
+	//	*InvokeRequestFrame_Data
+	Frame                isInvokeRequestFrame_Frame `protobuf_oneof:"frame"`
+	XXX_NoUnkeyedLiteral struct{}                   `json:"-"`
+	XXX_unrecognized     []byte                     `json:"-"`
+	XXX_sizecache        int32                      `json:"-"`
+}
+
+func (m *InvokeRequestFrame) Reset()         { *m = InvokeRequestFrame{} }
+func (m *InvokeRequestFrame) String() string { return proto.CompactTextString(m) }
+func (*InvokeRequestFrame) ProtoMessage()    {}
+func (*InvokeRequestFrame) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2156226f9a4f30f8, []int{3}
+}
+
+func (m *InvokeRequestFrame) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InvokeRequestFrame.Unmarshal(m, b)
+}
+func (m *InvokeRequestFrame) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InvokeRequestFrame.Marshal(b, m, deterministic)
+}
+func (m *InvokeRequestFrame) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InvokeRequestFrame.Merge(m, src)
+}
+func (m *InvokeRequestFrame) XXX_Size() int {
+	return xxx_messageInfo_InvokeRequestFrame.Size(m)
+}
+func (m *InvokeRequestFrame) XXX_DiscardUnknown() {
+	xxx_messageInfo_InvokeRequestFrame.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InvokeRequestFrame proto.InternalMessageInfo
+
+type isInvokeRequestFrame_Frame interface {
+	isInvokeRequestFrame_Frame()
+}
+
+type InvokeRequestFrame_Request struct {
+	Request *InvokeRequest `protobuf:"bytes,1,opt,name=request,proto3,oneof"`
+}
+
+type InvokeRequestFrame_Data struct {
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3,oneof"`
+}
+
+func (*InvokeRequestFrame_Request) isInvokeRequestFrame_Frame() {}
+
+func (*InvokeRequestFrame_Data) isInvokeRequestFrame_Frame() {}
+
+func (m *InvokeRequestFrame) GetFrame() isInvokeRequestFrame_Frame {
+	if m != nil {
+		return m.Frame
+	}
+	return nil
+}
+
+func (m *InvokeRequestFrame) GetRequest() *InvokeRequest {
+	if x, ok := m.GetFrame().(*InvokeRequestFrame_Request); ok {
+		return x.Request
+	}
+	return nil
+}
+
+func (m *InvokeRequestFrame) GetData() []byte {
+	if x, ok := m.GetFrame().(*InvokeRequestFrame_Data); ok {
+		return x.Data
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*InvokeRequestFrame) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*InvokeRequestFrame_Request)(nil),
+		(*InvokeRequestFrame_Data)(nil),
+	}
+}
+
+type InvokeResponseFrame struct {
+	// Types that are valid to be assigned to Frame:
+	//	*InvokeResponseFrame_Response
+	//	*InvokeResponseFrame_Data
+	Frame                isInvokeResponseFrame_Frame `protobuf_oneof:"frame"`
+	XXX_NoUnkeyedLiteral struct{}                    `json:"-"`
+	XXX_unrecognized     []byte                      `json:"-"`
+	XXX_sizecache        int32                       `json:"-"`
+}
+
+func (m *InvokeResponseFrame) Reset()         { *m = InvokeResponseFrame{} }
+func (m *InvokeResponseFrame) String() string { return proto.CompactTextString(m) }
+func (*InvokeResponseFrame) ProtoMessage()    {}
+func (*InvokeResponseFrame) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2156226f9a4f30f8, []int{4}
+}
+
+func (m *InvokeResponseFrame) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InvokeResponseFrame.Unmarshal(m, b)
+}
+func (m *InvokeResponseFrame) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InvokeResponseFrame.Marshal(b, m, deterministic)
+}
+func (m *InvokeResponseFrame) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InvokeResponseFrame.Merge(m, src)
+}
+func (m *InvokeResponseFrame) XXX_Size() int {
+	return xxx_messageInfo_InvokeResponseFrame.Size(m)
+}
+func (m *InvokeResponseFrame) XXX_DiscardUnknown() {
+	xxx_messageInfo_InvokeResponseFrame.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InvokeResponseFrame proto.InternalMessageInfo
+
+type isInvokeResponseFrame_Frame interface {
+	isInvokeResponseFrame_Frame()
+}
+
+type InvokeResponseFrame_Response struct {
+	Response *InvokeResponse `protobuf:"bytes,1,opt,name=response,proto3,oneof"`
+}
+
+type InvokeResponseFrame_Data struct {
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3,oneof"`
+}
+
+func (*InvokeResponseFrame_Response) isInvokeResponseFrame_Frame() {}
+
+func (*InvokeResponseFrame_Data) isInvokeResponseFrame_Frame() {}
+
+func (m *InvokeResponseFrame) GetFrame() isInvokeResponseFrame_Frame {
+	if m != nil {
+		return m.Frame
+	}
+	return nil
+}
+
+func (m *InvokeResponseFrame) GetResponse() *InvokeResponse {
+	if x, ok := m.GetFrame().(*InvokeResponseFrame_Response); ok {
+		return x.Response
+	}
+	return nil
+}
+
+func (m *InvokeResponseFrame) GetData() []byte {
+	if x, ok := m.GetFrame().(*InvokeResponseFrame_Data); ok {
+		return x.Data
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*InvokeResponseFrame) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*InvokeResponseFrame_Response)(nil),
+		(*InvokeResponseFrame_Data)(nil),
+	}
+}
+
+func init() {
+	proto.RegisterType((*Header)(nil), "invoke.Header")
+	proto.RegisterType((*InvokeRequest)(nil), "invoke.InvokeRequest")
+	proto.RegisterType((*InvokeResponse)(nil), "invoke.InvokeResponse")
+	proto.RegisterType((*InvokeRequestFrame)(nil), "invoke.InvokeRequestFrame")
+	proto.RegisterType((*InvokeResponseFrame)(nil), "invoke.InvokeResponseFrame")
+}
+
+func init() { proto.RegisterFile("invoke.proto", fileDescriptor_2156226f9a4f30f8) }
+
+var fileDescriptor_2156226f9a4f30f8 = []byte{
+	// 334 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x92, 0xcd, 0x4e, 0xfa, 0x40,
+	0x14, 0xc5, 0x3b, 0xf4, 0xeb, 0xff, 0xbf, 0x54, 0x62, 0x2e, 0xa8, 0x0d, 0x2e, 0x24, 0x5d, 0x75,
+	0x45, 0x10, 0xdd, 0xb8, 0xd5, 0xc4, 0x94, 0x85, 0x9b, 0xf1, 0x01, 0xc8, 0xc0, 0x4c, 0x23, 0x02,
+	0x1d, 0x6c, 0x07, 0x12, 0xde, 0xc0, 0xb5, 0x4f, 0x6c, 0x3a, 0xd3, 0x12, 0x6b, 0x30, 0xba, 0x9b,
+	0x73, 0x3f, 0xce, 0xef, 0xdc, 0x64, 0x20, 0x58, 0x64, 0x3b, 0xb9, 0x14, 0xc3, 0x4d, 0x2e, 0x95,
+	0x44, 0xcf, 0xa8, 0x68, 0x04, 0x5e, 0x22, 0x18, 0x17, 0x39, 0x9e, 0x82, 0xbd, 0x14, 0xfb, 0x90,
+	0x0c, 0x48, 0xfc, 0x9f, 0x96, 0x4f, 0xec, 0x81, 0xbb, 0x63, 0xab, 0xad, 0x08, 0x5b, 0xba, 0x66,
+	0x44, 0x34, 0x83, 0x93, 0x89, 0xde, 0xa5, 0xe2, 0x6d, 0x2b, 0x0a, 0x85, 0x5d, 0x70, 0xd3, 0x6c,
+	0xba, 0xe0, 0xd5, 0xaa, 0x93, 0x66, 0x13, 0x8e, 0x31, 0xf8, 0x2f, 0xda, 0xb7, 0x08, 0x5b, 0x03,
+	0x3b, 0x6e, 0x8f, 0x3b, 0xc3, 0x8a, 0x6f, 0x70, 0xb4, 0x6e, 0x23, 0x82, 0x33, 0x93, 0x7c, 0x1f,
+	0xda, 0x03, 0x12, 0x07, 0x54, 0xbf, 0xa3, 0x77, 0x02, 0x9d, 0x1a, 0x52, 0x6c, 0x64, 0x56, 0x08,
+	0xbc, 0x82, 0x76, 0xa1, 0x98, 0xda, 0x16, 0xd3, 0xb9, 0xe4, 0x42, 0xb3, 0x5c, 0x0a, 0xa6, 0xf4,
+	0x20, 0xb9, 0xc0, 0x0b, 0xf0, 0xe7, 0x6c, 0xb5, 0x2a, 0x83, 0x98, 0xbc, 0x5e, 0x29, 0x9b, 0x51,
+	0xec, 0xbf, 0x45, 0x71, 0xbe, 0x44, 0x49, 0x01, 0x1b, 0xe7, 0x3e, 0xe6, 0x6c, 0x2d, 0xf0, 0x1a,
+	0xfc, 0xdc, 0x68, 0x9d, 0xa4, 0x3d, 0x3e, 0xab, 0x3d, 0x1b, 0xc3, 0x89, 0x45, 0xeb, 0x39, 0xec,
+	0x81, 0xc3, 0x99, 0x62, 0x3a, 0x5c, 0x90, 0x58, 0x54, 0xab, 0x7b, 0x1f, 0xdc, 0xb4, 0x74, 0x8c,
+	0x5e, 0xa1, 0xdb, 0xbc, 0xd8, 0x80, 0x6e, 0xe1, 0x5f, 0x5e, 0x15, 0x2a, 0xd2, 0xf9, 0x77, 0x92,
+	0xe9, 0x26, 0x16, 0x3d, 0x4c, 0xfe, 0xc2, 0x1a, 0x7f, 0x10, 0xf0, 0xcc, 0x36, 0xde, 0x1d, 0x5e,
+	0xc7, 0x2f, 0xe8, 0xff, 0x80, 0x8b, 0x2c, 0x7c, 0x82, 0xc0, 0xd4, 0x9e, 0x55, 0x2e, 0xd8, 0x1a,
+	0xfb, 0x47, 0x0d, 0xf4, 0x19, 0xfd, 0xcb, 0xe3, 0x2e, 0xba, 0x19, 0x59, 0x31, 0x19, 0x91, 0x99,
+	0xa7, 0x3f, 0xe6, 0xcd, 0xe7, 0x00, 0x57, 0x28, 0x97, 0xa5, 0xa8, 0x02, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// InvokeClient is the client API for Invoke service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type InvokeClient interface {
+	// Invoke calls a fn with the body of the request, returning its response
+	Invoke(ctx context.Context, in *InvokeRequest, opts ...grpc.CallOption) (*InvokeResponse, error)
+	// InvokeStream calls a fn with a request, the first frame, and a body
+	// streamed in the frames after it until the client closes its side. The
+	// response, without its body, is the first frame back, and the body is
+	// streamed in the frames after it.
+	InvokeStream(ctx context.Context, opts ...grpc.CallOption) (Invoke_InvokeStreamClient, error)
+}
+
+type invokeClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewInvokeClient(cc *grpc.ClientConn) InvokeClient {
+	return &invokeClient{cc}
+}
+
+func (c *invokeClient) Invoke(ctx context.Context, in *InvokeRequest, opts ...grpc.CallOption) (*InvokeResponse, error) {
+	out := new(InvokeResponse)
+	err := c.cc.Invoke(ctx, "/invoke.Invoke/Invoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *invokeClient) InvokeStream(ctx context.Context, opts ...grpc.CallOption) (Invoke_InvokeStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Invoke_serviceDesc.Streams[0], "/invoke.Invoke/InvokeStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &invokeInvokeStreamClient{stream}
+	return x, nil
+}
+
+type Invoke_InvokeStreamClient interface {
+	Send(*InvokeRequestFrame) error
+	Recv() (*InvokeResponseFrame, error)
+	grpc.ClientStream
+}
+
+type invokeInvokeStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *invokeInvokeStreamClient) Send(m *InvokeRequestFrame) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *invokeInvokeStreamClient) Recv() (*InvokeResponseFrame, error) {
+	m := new(InvokeResponseFrame)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// InvokeServer is the server API for Invoke service.
+type InvokeServer interface {
+	// Invoke calls a fn with the body of the request, returning its response
+	Invoke(context.Context, *InvokeRequest) (*InvokeResponse, error)
+	// InvokeStream calls a fn with a request, the first frame, and a body
+	// streamed in the frames after it until the client closes its side. The
+	// response, without its body, is the first frame back, and the body is
+	// streamed in the frames after it.
+	InvokeStream(Invoke_InvokeStreamServer) error
+}
+
+func RegisterInvokeServer(s *grpc.Server, srv InvokeServer) {
+	s.RegisterService(&_Invoke_serviceDesc, srv)
+}
+
+func _Invoke_Invoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InvokeServer).Invoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/invoke.Invoke/Invoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InvokeServer).Invoke(ctx, req.(*InvokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Invoke_InvokeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(InvokeServer).InvokeStream(&invokeInvokeStreamServer{stream})
+}
+
+type Invoke_InvokeStreamServer interface {
+	Send(*InvokeResponseFrame) error
+	Recv() (*InvokeRequestFrame, error)
+	grpc.ServerStream
+}
+
+type invokeInvokeStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *invokeInvokeStreamServer) Send(m *InvokeResponseFrame) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *invokeInvokeStreamServer) Recv() (*InvokeRequestFrame, error) {
+	m := new(InvokeRequestFrame)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _Invoke_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "invoke.Invoke",
+	HandlerType: (*InvokeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Invoke",
+			Handler:    _Invoke_Invoke_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "InvokeStream",
+			Handler:       _Invoke_InvokeStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "invoke.proto",
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	pb "github.com/fnproject/fn/api/server/grpc"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcInvokeFrameSize is the most body a frame of a streamed response carries
+const grpcInvokeFrameSize = 64 * 1024
+
+// WithGRPCInvoke serves the Invoke gRPC service on the gRPC port of full and lb nodes, for clients calling fns over
+// gRPC rather than http. Extension middlewares, being http handlers, do not apply to it.
+func WithGRPCInvoke(enabled bool) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.grpcInvoke = enabled
+		return nil
+	}
+}
+
+// grpcInvokeServer implements the Invoke gRPC service, calling fns as the invoke endpoint does
+type grpcInvokeServer struct {
+	s *Server
+}
+
+var _ pb.InvokeServer = new(grpcInvokeServer)
+
+// newGRPCInvokeServer returns the gRPC server of the Invoke service, with the TLS config of the gRPC port if set
+func (s *Server) newGRPCInvokeServer() *grpc.Server {
+	opts := []grpc.ServerOption{grpc.StatsHandler(&common.TraceParentServerHandler{})}
+	if cfg := s.svcConfigs[GRPCServer].TLSConfig; cfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg)))
+	}
+	srv := grpc.NewServer(opts...)
+	pb.RegisterInvokeServer(srv, &grpcInvokeServer{s: s})
+	return srv
+}
+
+// serveGRPCInvoke listens on the gRPC port, calling cancel if the server fails
+func (s *Server) serveGRPCInvoke(cancel context.CancelFunc) {
+	addr := s.svcConfigs[GRPCServer].Addr
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		logrus.WithError(err).Fatalf("Could not listen on %s", addr)
+	}
+	logrus.WithField("type", s.nodeType).Infof("Fn gRPC invoke serving on `%v`", addr)
+	go func() {
+		if err := s.grpcInvokeServer.Serve(lis); err != nil {
+			logrus.WithError(err).Error("grpc serve error")
+			cancel()
+		}
+	}()
+}
+
+// Invoke implements pb.InvokeServer
+func (g *grpcInvokeServer) Invoke(ctx context.Context, req *pb.InvokeRequest) (*pb.InvokeResponse, error) {
+	ctx, done, ok := g.s.invokeDrain.add(ctx)
+	if !ok {
+		return nil, grpcError(ctx, ErrServerDraining)
+	}
+	defer done()
+
+	resp, err := g.s.invokeGRPC(ctx, req, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return resp, nil
+}
+
+// InvokeStream implements pb.InvokeServer. The call starts once the request frame is received, reading the body
+// from the frames as they arrive.
+func (g *grpcInvokeServer) InvokeStream(stream pb.Invoke_InvokeStreamServer) error {
+	ctx, done, ok := g.s.invokeDrain.add(stream.Context())
+	if !ok {
+		return grpcError(ctx, ErrServerDraining)
+	}
+	defer done()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	req := first.GetRequest()
+	if req == nil {
+		return status.Error(codes.InvalidArgument, "the first frame of an invoke stream must be the request")
+	}
+
+	body, w := io.Pipe()
+	defer body.Close()
+	go func() {
+		if _, err := w.Write(req.Body); err != nil {
+			return
+		}
+		for {
+			frame, err := stream.Recv()
+			if err == io.EOF {
+				w.Close()
+				return
+			} else if err != nil {
+				w.CloseWithError(err)
+				return
+			}
+			if frame.GetRequest() != nil {
+				w.CloseWithError(status.Error(codes.InvalidArgument, "only the first frame of an invoke stream may be the request"))
+				return
+			}
+			if _, err := w.Write(frame.GetData()); err != nil {
+				return
+			}
+		}
+	}()
+
+	resp, err := g.s.invokeGRPC(ctx, req, body)
+	if err != nil {
+		return grpcError(ctx, err)
+	}
+	out := resp.Body
+	resp.Body = nil
+	if err := stream.Send(&pb.InvokeResponseFrame{Frame: &pb.InvokeResponseFrame_Response{Response: resp}}); err != nil {
+		return err
+	}
+	for len(out) > 0 {
+		n := len(out)
+		if n > grpcInvokeFrameSize {
+			n = grpcInvokeFrameSize
+		}
+		if err := stream.Send(&pb.InvokeResponseFrame{Frame: &pb.InvokeResponseFrame_Data{Data: out[:n]}}); err != nil {
+			return err
+		}
+		out = out[n:]
+	}
+	return nil
+}
+
+// invokeGRPC calls the fn of req with body, as a request to its invoke endpoint, returning the response of the fn
+func (s *Server) invokeGRPC(ctx context.Context, req *pb.InvokeRequest, body io.Reader) (*pb.InvokeResponse, error) {
+	ctx, _ = common.LoggerWithFields(ctx, logrus.Fields{"fn_id": req.FnId})
+	fn, err := s.lbReadAccess.GetFnByID(ctx, req.FnId)
+	if err != nil {
+		return nil, err
+	}
+	app, err := s.lbReadAccess.GetAppByID(ctx, fn.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, "/invoke/"+fn.ID, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	for _, h := range req.Headers {
+		httpReq.Header.Add(h.Key, h.Value)
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		httpReq.RemoteAddr = p.Addr.String()
+	}
+
+	writer := &syncResponseWriter{headers: make(http.Header), Buffer: new(bytes.Buffer)}
+	if err := s.fnInvoke(writer, httpReq, app, fn, nil); err != nil {
+		return nil, err
+	}
+	if writer.status == 0 {
+		writer.status = http.StatusOK
+	}
+
+	resp := &pb.InvokeResponse{
+		StatusCode: int32(writer.status),
+		CallId:     writer.headers.Get("Fn-Call-Id"),
+		Body:       writer.Bytes(),
+	}
+	keys := make([]string, 0, len(writer.headers))
+	for k := range writer.headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range writer.headers[k] {
+			resp.Headers = append(resp.Headers, &pb.Header{Key: k, Value: v})
+		}
+	}
+	return resp, nil
+}
+
+// grpcCodes are the gRPC codes of the http status codes of API errors
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.Aborted,
+	http.StatusGone:                  codes.NotFound,
+	http.StatusPreconditionFailed:    codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge: codes.ResourceExhausted,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	models.ErrClientCancel.Code():    codes.Canceled,
+	http.StatusNotImplemented:        codes.Unimplemented,
+	http.StatusBadGateway:            codes.Unavailable,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// grpcError returns the gRPC status of err, as HandleErrorResponse would respond with it over http
+func grpcError(ctx context.Context, err error) error {
+	log := common.Logger(ctx)
+	switch ctx.Err() {
+	case context.Canceled:
+		log.Info("client context cancelled")
+		return status.Error(codes.Canceled, models.ErrClientCancel.Error())
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+	}
+
+	if e, ok := err.(models.APIError); ok {
+		if e.Code() >= 500 {
+			log.WithFields(logrus.Fields{"code": e.Code()}).WithError(e).Error("api error")
+		}
+		code, ok := grpcCodes[e.Code()]
+		if !ok {
+			code = codes.Unknown
+			if e.Code() >= 500 {
+				code = codes.Internal
+			}
+		}
+		return status.Error(code, e.Error())
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	log.WithError(err).Error("internal server error")
+	return status.Error(codes.Internal, ErrInternalServerError.Error())
+}
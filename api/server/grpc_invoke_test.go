@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+	pb "github.com/fnproject/fn/api/server/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCInvoke(t *testing.T) {
+	cfg, err := agent.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	big := strings.Repeat("x", 3*grpcInvokeFrameSize/2)
+	script := &mock.Script{Images: map[string]*mock.ImageScript{
+		mock.ScriptDefault: {LatencyMsecs: 5, Headers: map[string]string{"X-Echo": "yes"}},
+		"fnproject/big":    {LatencyMsecs: 5, Output: &big},
+	}}
+	a := agent.New(agent.WithConfig(cfg), agent.WithDockerDriver(mock.NewScripted(script)))
+	defer a.Close()
+
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", Name: "myfn", AppID: app.ID, Image: "fnproject/fn-test-utils",
+		ResourceConfig: models.ResourceConfig{Memory: 128, Timeout: 30, IdleTimeout: 30}}
+	bigFn := &models.Fn{ID: "big_id", Name: "big", AppID: app.ID, Image: "fnproject/big",
+		ResourceConfig: models.ResourceConfig{Memory: 128, Timeout: 30, IdleTimeout: 30}}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn, bigFn})
+	srv := testServer(ds, a, ServerTypeFull, WithGRPCInvoke(true))
+	if srv.grpcInvokeServer == nil {
+		t.Fatal("expected the server to serve grpc invoke")
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.grpcInvokeServer.Serve(lis)
+	defer srv.grpcInvokeServer.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, lis.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewInvokeClient(conn)
+
+	headers := []*pb.Header{{Key: "Content-Type", Value: "text/plain"}}
+	resp, err := client.Invoke(ctx, &pb.InvokeRequest{FnId: fn.ID, Headers: headers, Body: []byte("hello")})
+	if err != nil {
+		t.Fatalf("unary invoke failed: %v", err)
+	}
+	if resp.StatusCode != 200 || resp.CallId == "" || string(resp.Body) != "hello" {
+		t.Errorf("expected the echo of the request, got %+v", resp)
+	}
+	echoed := false
+	for _, h := range resp.Headers {
+		echoed = echoed || (h.Key == "X-Echo" && h.Value == "yes")
+	}
+	if !echoed {
+		t.Errorf("expected the headers of the fn response, got %v", resp.Headers)
+	}
+
+	_, err = client.Invoke(ctx, &pb.InvokeRequest{FnId: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected a missing fn to be NotFound, got %v", err)
+	}
+
+	// the body is streamed in frames, the response is streamed back in frames too
+	streamInvoke := func(fnID string, data ...string) (*pb.InvokeResponse, string, int) {
+		stream, err := client.InvokeStream(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := &pb.InvokeRequest{FnId: fnID, Headers: headers}
+		if err := stream.Send(&pb.InvokeRequestFrame{Frame: &pb.InvokeRequestFrame_Request{Request: req}}); err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range data {
+			if err := stream.Send(&pb.InvokeRequestFrame{Frame: &pb.InvokeRequestFrame_Data{Data: []byte(d)}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := stream.CloseSend(); err != nil {
+			t.Fatal(err)
+		}
+		first, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("stream invoke failed: %v", err)
+		}
+		if first.GetResponse() == nil || len(first.GetResponse().Body) != 0 {
+			t.Fatalf("expected the response without its body first, got %+v", first)
+		}
+		var body bytes.Buffer
+		n := 0
+		for {
+			frame, err := stream.Recv()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			body.Write(frame.GetData())
+			n++
+		}
+		return first.GetResponse(), body.String(), n
+	}
+
+	resp, body, _ := streamInvoke(fn.ID, "a", "b", "c")
+	if resp.StatusCode != 200 || body != "abc" {
+		t.Errorf("expected the echo of the streamed body, got %d %q", resp.StatusCode, body)
+	}
+	resp, body, n := streamInvoke(bigFn.ID)
+	if resp.StatusCode != 200 || body != big || n != 2 {
+		t.Errorf("expected the response body in 2 frames, got %d bytes in %d frames", len(body), n)
+	}
+
+	stream, err := client.InvokeStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&pb.InvokeRequestFrame{Frame: &pb.InvokeRequestFrame_Data{Data: []byte("a")}})
+	stream.CloseSend()
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected a stream not starting with the request to be InvalidArgument, got %v", err)
+	}
+}
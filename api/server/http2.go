@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// WithHTTP2 sets how the web server speaks HTTP/2. Unless disabled, h2 is negotiated with clients connecting over
+// TLS. h2c serves HTTP/2 over cleartext too, to clients upgrading to it or knowing the server speaks it, for internal
+// deployments terminating TLS in front of fn. maxStreams is how many requests a client may have in flight on a
+// connection, 0 for the default of 250. See WithHTTP3 for HTTP/3.
+func WithHTTP2(enabled, h2c bool, maxStreams int) Option {
+	return func(ctx context.Context, s *Server) error {
+		if h2c && !enabled {
+			return errors.New("h2c requires http2 to be enabled")
+		}
+		if maxStreams < 0 {
+			return errors.New("http2 max concurrent streams must not be negative")
+		}
+		s.noHTTP2 = !enabled
+		s.h2c = h2c
+		s.http2MaxStreams = uint32(maxStreams)
+		return nil
+	}
+}
+
+// configureHTTP2 sets up server, after its handler is set, to speak HTTP/2 as configured
+func (s *Server) configureHTTP2(server *http.Server) error {
+	if s.noHTTP2 {
+		// a non-nil map of protocols stops h2 being negotiated over TLS
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		return nil
+	}
+
+	h2 := &http2.Server{MaxConcurrentStreams: s.http2MaxStreams}
+	tlsConfig := server.TLSConfig
+	// this also has the connections of h2 and h2c shut down gracefully with the server
+	if err := http2.ConfigureServer(server, h2); err != nil {
+		return err
+	}
+	// the server listens with TLS only if it was configured with it
+	if tlsConfig == nil {
+		server.TLSConfig = nil
+	}
+	if s.h2c {
+		server.Handler = h2c.NewHandler(server.Handler, h2)
+	}
+	return nil
+}
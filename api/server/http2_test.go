@@ -0,0 +1,58 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"golang.org/x/net/http2"
+)
+
+func TestHTTP2(t *testing.T) {
+	ds := datastore.NewMock()
+
+	srv := testServer(ds, nil, ServerTypeAPI, WithHTTP2(true, true, 10))
+	server := &http.Server{Handler: srv.Router}
+	if err := srv.configureHTTP2(server); err != nil {
+		t.Fatal(err)
+	}
+	if server.TLSConfig != nil {
+		t.Error("expected the server to listen without TLS, as it was not configured with it")
+	}
+	ts := httptest.NewServer(server.Handler)
+	defer ts.Close()
+
+	// a client knowing the server speaks h2c
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	resp, err := client.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Errorf("expected an HTTP/2 response over cleartext, got %s %d", resp.Proto, resp.StatusCode)
+	}
+
+	srv = testServer(ds, nil, ServerTypeAPI, WithHTTP2(false, false, 0))
+	server = &http.Server{Handler: srv.Router, TLSConfig: &tls.Config{}}
+	if err := srv.configureHTTP2(server); err != nil {
+		t.Fatal(err)
+	}
+	if server.TLSNextProto == nil || len(server.TLSNextProto) != 0 {
+		t.Errorf("expected h2 not to be negotiated over TLS, got %v", server.TLSNextProto)
+	}
+
+	for _, opt := range []Option{WithHTTP2(false, true, 0), WithHTTP2(true, false, -1)} {
+		if err := opt(nil, srv); err == nil {
+			t.Error("expected an invalid http2 config to be rejected")
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/sirupsen/logrus"
+)
+
+// WithHTTP3 serves HTTP/3 over QUIC on the UDP port port of the web server, 0 for none. It is experimental. The web
+// server must be configured with TLS, whose config HTTP/3 shares, and advertises HTTP/3 to its clients in the Alt-Svc
+// header. maxStreams is how many requests a client may have in flight on a connection, 0 for the default of 100.
+func WithHTTP3(port, maxStreams int) Option {
+	return func(ctx context.Context, s *Server) error {
+		if port < 0 || port > 65535 {
+			return errors.New("http3 port must be between 0 and 65535")
+		}
+		if maxStreams < 0 {
+			return errors.New("http3 max concurrent streams must not be negative")
+		}
+		s.http3Port = port
+		s.http3MaxStreams = maxStreams
+		return nil
+	}
+}
+
+// configureHTTP3 sets up the HTTP/3 server of server, after its handler is set, and has server advertise it
+func (s *Server) configureHTTP3(server *http.Server) error {
+	if s.http3Port == 0 {
+		return nil
+	}
+	if server.TLSConfig == nil {
+		return errors.New("http3 requires the web server to be configured with TLS")
+	}
+	host, _, err := net.SplitHostPort(server.Addr)
+	if err != nil {
+		return err
+	}
+
+	h3 := &http3.Server{
+		Addr:      net.JoinHostPort(host, strconv.Itoa(s.http3Port)),
+		TLSConfig: server.TLSConfig.Clone(),
+		Handler:   server.Handler,
+	}
+	if s.http3MaxStreams != 0 {
+		h3.QuicConfig = &quic.Config{MaxIncomingStreams: int64(s.http3MaxStreams)}
+	}
+	s.http3 = h3
+
+	handler := server.Handler
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// this fails only until the HTTP/3 server is listening
+		h3.SetQuicHeaders(w.Header())
+		handler.ServeHTTP(w, r)
+	})
+	return nil
+}
+
+// serveHTTP3 serves HTTP/3, if configured, calling cancel if it stops serving before it is closed
+func (s *Server) serveHTTP3(cancel context.CancelFunc) error {
+	if s.http3 == nil {
+		return nil
+	}
+	// the socket is bound before serving, so that closing the server does not race with it
+	conn, err := net.ListenPacket("udp", s.http3.Addr)
+	if err != nil {
+		return err
+	}
+	s.http3Conn = conn
+	logrus.Infof("Fn serving HTTP/3 on `%v`", s.http3.Addr)
+
+	go func() {
+		err := s.http3.Serve(conn)
+		if err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Error("http3 server error")
+			cancel()
+		} else {
+			logrus.Info("http3 server stopped")
+		}
+	}()
+	return nil
+}
+
+// closeHTTP3 closes the HTTP/3 server, aborting the requests still in flight on it
+func (s *Server) closeHTTP3() {
+	if s.http3 == nil {
+		return
+	}
+	if err := s.http3.Close(); err != nil {
+		logrus.WithError(err).Error("http3 server shutdown error")
+	}
+	// the server does not close the socket it was handed
+	if s.http3Conn != nil {
+		s.http3Conn.Close()
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestHTTP3(t *testing.T) {
+	ds := datastore.NewMock()
+
+	srv := testServer(ds, nil, ServerTypeAPI, WithHTTP3(0, 0))
+	server := &http.Server{Addr: "127.0.0.1:0", Handler: srv.Router}
+	if err := srv.configureHTTP3(server); err != nil || srv.http3 != nil {
+		t.Fatalf("expected no HTTP/3 unless configured, got %v", err)
+	}
+
+	// a certificate of the loopback address
+	certs := httptest.NewTLSServer(nil)
+	certs.Close()
+
+	srv = testServer(ds, nil, ServerTypeAPI, WithHTTP3(freeUDPPort(t), 10))
+	server = &http.Server{Addr: "127.0.0.1:8080", Handler: srv.Router, TLSConfig: &tls.Config{Certificates: certs.TLS.Certificates}}
+	if err := srv.configureHTTP3(server); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.serveHTTP3(func() { t.Error("expected the HTTP/3 server to keep serving") }); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.closeHTTP3()
+
+	client := &http.Client{Transport: &http3.RoundTripper{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	defer client.Transport.(*http3.RoundTripper).Close()
+	resp, err := client.Get("https://" + srv.http3.Addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 3 {
+		t.Errorf("expected an HTTP/3 response, got %s %d", resp.Proto, resp.StatusCode)
+	}
+
+	// clients of the web server are told it speaks HTTP/3
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if altSvc := rec.Header().Get("Alt-Svc"); !strings.Contains(altSvc, fmt.Sprintf(`h3=":%d"`, srv.http3Port)) {
+		t.Errorf("expected HTTP/3 to be advertised, got %q", altSvc)
+	}
+
+	srv = testServer(ds, nil, ServerTypeAPI, WithHTTP3(8443, 0))
+	if err := srv.configureHTTP3(&http.Server{Addr: ":8080", Handler: srv.Router}); err == nil {
+		t.Error("expected HTTP/3 without TLS to be rejected")
+	}
+	for _, opt := range []Option{WithHTTP3(-1, 0), WithHTTP3(70000, 0), WithHTTP3(8443, -1)} {
+		if err := opt(nil, srv); err == nil {
+			t.Error("expected an invalid http3 config to be rejected")
+		}
+	}
+}
+
+// freeUDPPort returns a UDP port of the loopback address no socket is bound to
+func freeUDPPort(t *testing.T) int {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
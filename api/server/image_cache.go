@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// handleImageList lists the images of the runner, whether they are in use, pinned or exempt from eviction, and
+// the image references pinned
+func (s *Server) handleImageList(c *gin.Context) {
+	images, err := s.agent.(agent.ImageCacheManager).CachedImages(c.Request.Context())
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, images)
+}
+
+// handleImagePin pins the images of the image query parameter, an image ID, tag or digest reference, so the image
+// cleaner never evicts them
+func (s *Server) handleImagePin(c *gin.Context) {
+	ref := c.Query("image")
+	if err := s.agent.(agent.ImageCacheManager).PinImage(c.Request.Context(), ref); err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{"image": ref, "by": c.ClientIP()}).Info("Image pinned")
+	c.Status(http.StatusNoContent)
+}
+
+// handleImageUnpin makes the images of the image query parameter candidates for eviction again
+func (s *Server) handleImageUnpin(c *gin.Context) {
+	ref := c.Query("image")
+	if err := s.agent.(agent.ImageCacheManager).UnpinImage(c.Request.Context(), ref); err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{"image": ref, "by": c.ClientIP()}).Info("Image unpinned")
+	c.Status(http.StatusNoContent)
+}
+
+// handleImageEvict removes the images of the image query parameter from the runner, unless they are in use or pinned
+func (s *Server) handleImageEvict(c *gin.Context) {
+	ref := c.Query("image")
+	if err := s.agent.(agent.ImageCacheManager).EvictImage(c.Request.Context(), ref); err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{"image": ref, "by": c.ClientIP()}).Info("Image evicted")
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/datastore"
+)
+
+func TestImageCacheRoutes(t *testing.T) {
+	cfg, err := agent.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	a := agent.New(agent.WithConfig(cfg), agent.WithDockerDriver(mock.NewScripted(&mock.Script{})))
+	defer a.Close()
+	srv := testServer(datastore.NewMock(), a, ServerTypeFull, WithAdminToken("secret"))
+
+	for i, test := range []struct {
+		method        string
+		path          string
+		token         string
+		expectedCode  int
+		expectedError error
+	}{
+		// the mock driver keeps no images
+		{http.MethodGet, "/v2/admin/images", "", http.StatusNotFound, agent.ErrImageCacheUnsupported},
+		{http.MethodPut, "/v2/admin/images/pins?image=fnproject/hello:0.0.1", "", http.StatusUnauthorized, ErrAdminUnauthorized},
+		{http.MethodDelete, "/v2/admin/images/pins?image=fnproject/hello:0.0.1", "secret", http.StatusNotFound, agent.ErrImageCacheUnsupported},
+		{http.MethodDelete, "/v2/admin/images?image=fnproject/hello:0.0.1", "secret", http.StatusNotFound, agent.ErrImageCacheUnsupported},
+		{http.MethodPost, "/v2/admin/images/pulls?image=fnproject/hello:0.0.1", "", http.StatusUnauthorized, ErrAdminUnauthorized},
+		{http.MethodPost, "/v2/admin/images/pulls?image=fnproject/hello:0.0.1", "secret", http.StatusNotFound, agent.ErrImagePrePullUnsupported},
+		{http.MethodPost, "/v2/admin/images/pulls", "secret", http.StatusNotFound, agent.ErrImagePrePullUnsupported},
+		{http.MethodPost, "/v2/admin/images/load", "", http.StatusUnauthorized, ErrAdminUnauthorized},
+	} {
+		req := createRequest(t, test.method, test.path, nil)
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		_, rec := routerRequest2(t, srv.AdminRouter, req)
+		if rec.Code != test.expectedCode {
+			t.Errorf("Test %d: expected status code %d, got %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+		if resp := getErrorResponse(t, rec); resp.Message != test.expectedError.Error() {
+			t.Errorf("Test %d: expected error %q, got %q", i, test.expectedError, resp.Message)
+		}
+	}
+}
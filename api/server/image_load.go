@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// handleImageLoad loads the images of a docker save tarball on the runner, either uploaded as the body of the
+// request, or staged in the image load dir of the runner and named by the archive query parameter
+func (s *Server) handleImageLoad(c *gin.Context) {
+	ctx := c.Request.Context()
+	loader := s.agent.(agent.ImageLoader)
+	log := logrus.WithField("by", c.ClientIP())
+
+	var err error
+	if name := c.Query("archive"); name != "" {
+		log = log.WithField("archive", name)
+		err = loader.LoadStagedImages(ctx, name)
+	} else {
+		err = loader.LoadImages(ctx, c.Request.Body)
+	}
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	log.Info("Images loaded")
+	c.Status(http.StatusNoContent)
+}
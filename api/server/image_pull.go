@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type imagePrePullResponse struct {
+	Image   string `json:"image"`
+	Runners int    `json:"runners"`
+	Pulled  int    `json:"pulled"`
+	Error   string `json:"error,omitempty"`
+}
+
+// handleImagePrePull pulls the image of the image query parameter on the runner, or on every runner of the pool of
+// an LB, so that the first calls of fns deployed with it do not wait on the pull. LBs pull it only on the runners of
+// the tenant pool of the runner_pool query parameter if it is set.
+func (s *Server) handleImagePrePull(c *gin.Context) {
+	ref := c.Query("image")
+	pulled, runners, err := s.agent.(agent.ImagePrePuller).PrePullImage(c.Request.Context(), ref, c.Query("runner_pool"))
+	if pulled == 0 && err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{"image": ref, "pulled": pulled, "runners": runners, "by": c.ClientIP()}).Info("Image pre-pulled")
+
+	resp := imagePrePullResponse{Image: ref, Runners: runners, Pulled: pulled}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+)
+
+// ImageScanner scans images for vulnerabilities
+type ImageScanner interface {
+	// ScanImage scans image, returning the digest it resolved to and its vulnerabilities
+	ScanImage(ctx context.Context, image string) (*models.ImageScan, error)
+}
+
+type httpImageScanner struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPImageScanner returns an ImageScanner that POSTs {"image": image} to url and expects an ImageScan back, eg.
+// {"digest": "sha256:4f53...", "vulnerabilities": {"HIGH": 2, "LOW": 5}}. It is the hook a Trivy or Clair client
+// is put behind.
+func NewHTTPImageScanner(url string, timeout time.Duration) ImageScanner {
+	return &httpImageScanner{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (h *httpImageScanner) ScanImage(ctx context.Context, image string) (*models.ImageScan, error) {
+	body, err := json.Marshal(struct {
+		Image string `json:"image"`
+	}{image})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image scanner returned %s", resp.Status)
+	}
+	var scan models.ImageScan
+	if err := json.NewDecoder(resp.Body).Decode(&scan); err != nil {
+		return nil, fmt.Errorf("invalid image scan: %v", err)
+	}
+	return &scan, nil
+}
+
+// imageScanGate scans the images of fns when they are created or updated, blocking or warning about those with
+// vulnerabilities. Scans are cached in the datastore per digest, so the scans of images pinned to a digest are reused.
+type imageScanGate struct {
+	scanner ImageScanner
+	// block and warn are the lowest severities images are blocked and warned about at, "" never blocks or warns
+	block  string
+	warn   string
+	maxAge time.Duration
+	ds     func() models.Datastore
+}
+
+var _ fnext.FnListener = new(imageScanGate)
+
+// WithImageScanner scans the images of fns with scanner when they are created or updated. Images with
+// vulnerabilities of blockSeverity or higher are rejected, those with vulnerabilities of warnSeverity or higher are
+// logged. Either severity may be "" to never block or warn. Cached scans older than maxAge are redone, 0 keeps them.
+func WithImageScanner(scanner ImageScanner, blockSeverity, warnSeverity string, maxAge time.Duration) Option {
+	return func(ctx context.Context, s *Server) error {
+		for _, severity := range []string{blockSeverity, warnSeverity} {
+			if severity != "" && !models.ValidSeverity(severity) {
+				return fmt.Errorf("invalid image scan severity %s, severities are %v", severity, models.Severities)
+			}
+		}
+		s.AddFnListener(&imageScanGate{
+			scanner: scanner,
+			block:   blockSeverity,
+			warn:    warnSeverity,
+			maxAge:  maxAge,
+			ds:      func() models.Datastore { return s.datastore },
+		})
+		return nil
+	}
+}
+
+// scan returns the cached scan of image if it is pinned to a digest that was scanned, or scans it
+func (g *imageScanGate) scan(ctx context.Context, image string) (*models.ImageScan, error) {
+	if digest := models.ImageDigest(image); digest != "" {
+		scan, err := g.ds().GetImageScan(ctx, digest)
+		if err == nil && (g.maxAge == 0 || time.Since(time.Time(scan.ScannedAt)) < g.maxAge) {
+			return scan, nil
+		}
+		if err != nil && err != models.ErrImageScanNotFound {
+			return nil, err
+		}
+	}
+
+	scan, err := g.scanner.ScanImage(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	scan.Image = image
+	scan.ScannedAt = common.DateTime(time.Now())
+	if scan.Digest != "" {
+		if err := g.ds().PutImageScan(ctx, scan); err != nil {
+			common.Logger(ctx).WithError(err).WithField("image", image).Error("failed to cache image scan")
+		}
+	}
+	return scan, nil
+}
+
+func (g *imageScanGate) check(ctx context.Context, image string) error {
+	if image == "" {
+		return nil // fails validation, or is not being updated
+	}
+	log := common.Logger(ctx).WithField("image", image)
+
+	scan, err := g.scan(ctx, image)
+	if err != nil {
+		if g.block == "" {
+			log.WithError(err).Warn("failed to scan image")
+			return nil
+		}
+		return models.NewAPIError(http.StatusBadGateway, fmt.Errorf("Image %s could not be scanned: %v", image, err))
+	}
+
+	if g.block != "" {
+		if n := scan.Vulnerabilities.AtLeast(g.block); n > 0 {
+			log.WithField("vulnerabilities", scan.Vulnerabilities).Info("blocked vulnerable image")
+			return models.ErrImageVulnerable(image, n, g.block)
+		}
+	}
+	if g.warn != "" {
+		if n := scan.Vulnerabilities.AtLeast(g.warn); n > 0 {
+			log.WithField("vulnerabilities", scan.Vulnerabilities).Warnf("image has %d vulnerabilities of severity %s or higher", n, g.warn)
+		}
+	}
+	return nil
+}
+
+func (g *imageScanGate) BeforeFnCreate(ctx context.Context, fn *models.Fn) error {
+	return g.check(ctx, fn.Image)
+}
+
+func (g *imageScanGate) BeforeFnUpdate(ctx context.Context, fn *models.Fn) error {
+	return g.check(ctx, fn.Image)
+}
+
+func (g *imageScanGate) AfterFnCreate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (g *imageScanGate) AfterFnUpdate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (g *imageScanGate) BeforeFnDelete(ctx context.Context, fnID string) error {
+	return nil
+}
+
+func (g *imageScanGate) AfterFnDelete(ctx context.Context, fnID string) error {
+	return nil
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+type testImageScanner struct {
+	scans map[string]*models.ImageScan
+	// scanned counts the scans of each image
+	scanned map[string]int
+}
+
+func (s *testImageScanner) ScanImage(ctx context.Context, image string) (*models.ImageScan, error) {
+	s.scanned[image]++
+	scan, ok := s.scans[image]
+	if !ok {
+		return nil, errors.New("registry unavailable")
+	}
+	cl := *scan
+	return &cl, nil
+}
+
+func TestImageScanGate(t *testing.T) {
+	buf := setLogBuffer()
+
+	a := &models.App{Name: "a", ID: "app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID, Image: "fnproject/fn-test-utils"}
+	f.SetDefaults()
+	stale := &models.ImageScan{Digest: "sha256:57a1e", Image: "fnproject/stale@sha256:57a1e", ScannedAt: common.DateTime(time.Now().Add(-48 * time.Hour))}
+	ds := datastore.NewMockInit([]*models.App{a}, []*models.Fn{f}, []*models.ImageScan{stale})
+
+	scanner := &testImageScanner{
+		scans: map[string]*models.ImageScan{
+			"fnproject/clean":              {Digest: "sha256:c1ea4"},
+			"fnproject/clean@sha256:c1ea4": {Digest: "sha256:c1ea4"},
+			"fnproject/old":                {Digest: "sha256:01d", Vulnerabilities: models.ImageVulnerabilities{"HIGH": 3, "LOW": 1}},
+			"fnproject/bad@sha256:bad":     {Digest: "sha256:bad", Vulnerabilities: models.ImageVulnerabilities{"CRITICAL": 1, "HIGH": 2}},
+			"fnproject/stale@sha256:57a1e": {Digest: "sha256:57a1e", Vulnerabilities: models.ImageVulnerabilities{"CRITICAL": 1}},
+		},
+		scanned: make(map[string]int),
+	}
+	srv := testServer(ds, nil, ServerTypeAPI, WithImageScanner(scanner, models.SeverityCritical, models.SeverityHigh, 24*time.Hour))
+
+	for i, test := range []struct {
+		method        string
+		path          string
+		body          string
+		expectedCode  int
+		expectedError string
+	}{
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "clean", "image": "fnproject/clean"}`, http.StatusOK, ""},
+		// vulnerabilities below the block severity are only warned about
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "old", "image": "fnproject/old"}`, http.StatusOK, ""},
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "bad", "image": "fnproject/bad@sha256:bad"}`, http.StatusBadRequest, "has 1 vulnerabilities of severity CRITICAL or higher"},
+		{http.MethodPut, fmt.Sprintf("/v2/fns/%s", f.ID), `{"image": "fnproject/bad@sha256:bad"}`, http.StatusBadRequest, "has 1 vulnerabilities of severity CRITICAL or higher"},
+		// the scan of the digest the clean tag resolved to is reused
+		{http.MethodPut, fmt.Sprintf("/v2/fns/%s", f.ID), `{"image": "fnproject/clean@sha256:c1ea4"}`, http.StatusOK, ""},
+		// scans older than the max age are redone
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "stale", "image": "fnproject/stale@sha256:57a1e"}`, http.StatusBadRequest, "has 1 vulnerabilities"},
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "unscannable", "image": "fnproject/missing"}`, http.StatusBadGateway, "could not be scanned"},
+		// updates that leave the image alone are not scanned
+		{http.MethodPut, fmt.Sprintf("/v2/fns/%s", f.ID), `{"memory": 256}`, http.StatusOK, ""},
+	} {
+		_, rec := routerRequest(t, srv.Router, test.method, test.path, bytes.NewBufferString(test.body))
+
+		if rec.Code != test.expectedCode {
+			t.Log(buf.String())
+			t.Fatalf("Test %d: Expected status code to be %d but was %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+
+		if test.expectedError != "" {
+			resp := getErrorResponse(t, rec)
+			if !strings.Contains(resp.Message, test.expectedError) {
+				t.Errorf("Test %d: Expected error message to have `%s`, but was `%s`", i, test.expectedError, resp.Message)
+			}
+		}
+	}
+
+	if !strings.Contains(buf.String(), "image has 3 vulnerabilities of severity HIGH or higher") {
+		t.Errorf("expected the vulnerabilities of fnproject/old to be logged, got %s", buf.String())
+	}
+	if n := scanner.scanned["fnproject/bad@sha256:bad"]; n != 1 {
+		t.Errorf("expected the scan of fnproject/bad to be cached, but it was scanned %d times", n)
+	}
+	if n := scanner.scanned["fnproject/clean@sha256:c1ea4"]; n != 0 {
+		t.Errorf("expected the scan of fnproject/clean to be reused, but its digest was scanned %d times", n)
+	}
+	if n := scanner.scanned["fnproject/stale@sha256:57a1e"]; n != 1 {
+		t.Errorf("expected the stale scan to be redone, but it was scanned %d times", n)
+	}
+	if scan, err := ds.GetImageScan(context.Background(), "sha256:01d"); err != nil || scan.Image != "fnproject/old" {
+		t.Errorf("expected the scan of fnproject/old to be cached, got %+v %v", scan, err)
+	}
+}
@@ -3,18 +3,15 @@ package server
 import (
 	"bytes"
 	"context"
-	"io/ioutil"
 	"net"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"runtime"
-	"strconv"
-	"strings"
 	"syscall"
 	"time"
 
 	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/config"
 	"github.com/fnproject/fn/api/id"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -24,6 +21,7 @@ func init() {
 	// init logging stuff in init, in case any packages log stuff on startup
 	common.SetLogFormat(getEnv(EnvLogFormat, DefaultLogFormat))
 	common.SetLogLevel(getEnv(EnvLogLevel, DefaultLogLevel))
+	config.Tunable(EnvLogLevel, tuneLogLevel)
 	common.SetLogDest(getEnv(EnvLogDest, DefaultLogDest), getEnv(EnvLogPrefix, ""))
 
 	// gin is not nice by default, this can get set in logging initialization
@@ -32,6 +30,7 @@ func init() {
 	// set machine id in init() before any packages are initialized that may use it
 	// (you may change this to seed the id another way but be wary of package initialization)
 	setMachineID()
+	setIDStrategy()
 
 }
 
@@ -45,6 +44,14 @@ func setMachineID() {
 	id.SetMachineIdHost(addr, port)
 }
 
+func setIDStrategy() {
+	s, err := id.ParseStrategy(getEnv(EnvIDStrategy, ""))
+	if err != nil {
+		logrus.WithError(err).Fatal("invalid id strategy")
+	}
+	id.SetStrategy(s)
+}
+
 // whoAmI searches for a non-local address on any network interface, returning
 // the first one it finds. it could be expanded to search eth0 or en0 only but
 // to date this has been unnecessary.
@@ -69,57 +76,31 @@ func whoAmI() net.IP {
 }
 
 func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
-	} else if value, ok := os.LookupEnv(key + "_FILE"); ok {
-		dat, err := ioutil.ReadFile(filepath.Clean(value))
-		if err == nil {
-			return string(dat)
-		}
-	}
-	return fallback
+	return config.String(key, fallback)
 }
 
 func getEnvInt(key string, fallback int) int {
-	if value, ok := os.LookupEnv(key); ok {
-		// linter liked this better than if/else
-		var err error
-		var i int
-		if i, err = strconv.Atoi(value); err != nil {
-			logrus.WithError(err).WithFields(logrus.Fields{"string": value, "environment_key": key}).Fatal("Failed to convert string to int")
-		}
-		return i
-	} else if value, ok := os.LookupEnv(key + "_FILE"); ok {
-		dat, err := ioutil.ReadFile(filepath.Clean(value))
-		if err == nil {
-			var err error
-			var i int
-			if i, err = strconv.Atoi(strings.TrimSpace(string(dat))); err != nil {
-				logrus.WithError(err).WithFields(logrus.Fields{"string": dat, "environment_key": key}).Fatal("Failed to convert string to int")
-			}
-			return i
-		}
+	i, err := config.Int(key, fallback)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"environment_key": key}).Fatal("Failed to convert string to int")
+	}
+	return i
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	b, err := config.Bool(key, fallback)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"environment_key": key}).Fatal("Failed to convert string to bool")
 	}
-	return fallback
+	return b
 }
 
 func getEnvDuration(key string, fallback time.Duration) time.Duration {
-	var err error
-	res := fallback
-	if tmp := os.Getenv(key); tmp != "" {
-		// if the returned value is not null it needs to be either an integral value in seconds or a parsable duration-format string
-		res, err = time.ParseDuration(tmp)
-		if err != nil {
-			// try to parse an int
-			s, perr := strconv.Atoi(tmp)
-			if perr != nil {
-				logrus.WithError(err).WithFields(logrus.Fields{"duration_string": tmp, "environment_key": key}).Fatal("Failed to parse duration from env")
-			} else {
-				res = time.Duration(s) * time.Second
-			}
-		}
+	d, err := config.Duration(key, fallback)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"environment_key": key}).Fatal("Failed to parse duration from env")
 	}
-	return res
+	return d
 }
 
 func contextWithSignal(ctx context.Context, signals ...os.Signal) (context.Context, context.CancelFunc) {
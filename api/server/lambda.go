@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// The Lambda endpoint lets SDKs and tools written against AWS Lambda invoke fns, with the shape of its Invoke API:
+//
+//   POST /2015-03-31/functions/<function>/invocations?Qualifier=<app>
+//
+// The function is an fn id, or the name of an fn, bare, as an ARN or with a qualifier, which names its app in place
+// of a version or alias. Fns named without one, or with $LATEST, are looked up in the default app of
+// WithLambdaEndpoints. The X-Amz-Invocation-Type header picks how the fn is called:
+//
+//   RequestResponse  the default, answers with the response of the fn
+//   Event            detached, answers 202 as soon as the call is queued
+//   DryRun           answers 204 if the fn exists, without calling it
+//
+// Responses carry the call id as X-Amzn-RequestId. Fn failures answer 200 with X-Amz-Function-Error, as Lambda does,
+// and errors of the service carry the Lambda error type as X-Amzn-ErrorType. X-Amz-Log-Type is ignored, the logs of
+// calls are read from the call log API.
+
+const (
+	lambdaInvokePrefix = "/2015-03-31/functions/"
+	lambdaLatest       = "$LATEST"
+)
+
+// WithLambdaEndpoints adds the Lambda Invoke endpoint to full and LB nodes. Fns named without a qualifier are looked
+// up in defaultApp, if set, fns are otherwise named by id.
+func WithLambdaEndpoints(defaultApp string) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.lambda = true
+		s.lambdaApp = defaultApp
+		return nil
+	}
+}
+
+// isLambdaRequest returns whether a request invokes a fn through the Lambda endpoint
+func isLambdaRequest(req *http.Request) bool {
+	return strings.HasPrefix(req.URL.Path, lambdaInvokePrefix)
+}
+
+// lambdaFunction returns the name and qualifier of a Lambda function name, which may be an ARN,
+// arn:aws:lambda:<region>:<account>:function:<name>[:<qualifier>], or <name>[:<qualifier>]
+func lambdaFunction(function string) (string, string) {
+	if strings.HasPrefix(function, "arn:") {
+		if i := strings.Index(function, ":function:"); i >= 0 {
+			function = function[i+len(":function:"):]
+		}
+	}
+	if i := strings.Index(function, ":"); i >= 0 {
+		return function[:i], function[i+1:]
+	}
+	return function, ""
+}
+
+// lambdaFn looks up the fn a Lambda function name and qualifier are for
+func (s *Server) lambdaFn(ctx context.Context, function, qualifier string) (*models.Fn, error) {
+	name, q := lambdaFunction(function)
+	if qualifier == "" {
+		qualifier = q
+	}
+	if qualifier == lambdaLatest {
+		qualifier = ""
+	}
+	appName := qualifier
+	if appName == "" {
+		appName = s.lambdaApp
+	}
+	if name == "" {
+		return nil, models.ErrFnsNotFound
+	}
+	if appName == "" {
+		return s.lbReadAccess.GetFnByID(ctx, name)
+	}
+
+	appID, err := s.lbReadAccess.GetAppID(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+	if s.datastore == nil {
+		return nil, models.ErrFnsNotFound
+	}
+	fns, err := s.datastore.GetFns(ctx, &models.FnFilter{AppID: appID, Name: name, PerPage: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(fns.Items) == 0 {
+		return nil, models.ErrFnsNotFound
+	}
+	return fns.Items[0], nil
+}
+
+// lambdaErrorType returns the Lambda error type of the status of an error
+func lambdaErrorType(code int) string {
+	switch code {
+	case http.StatusNotFound:
+		return "ResourceNotFoundException"
+	case http.StatusRequestEntityTooLarge:
+		return "RequestTooLargeException"
+	case http.StatusTooManyRequests:
+		return "TooManyRequestsException"
+	case http.StatusServiceUnavailable:
+		return "ResourceNotReadyException"
+	}
+	if code >= 400 && code < 500 {
+		return "InvalidRequestContentException"
+	}
+	return "ServiceException"
+}
+
+// handleLambdaError writes the error of a Lambda invocation, the failures of fns as Lambda reports them
+func handleLambdaError(c *gin.Context, err error) {
+	if models.IsFuncError(err) && !c.Writer.Written() {
+		c.Header("X-Amz-Function-Error", "Unhandled")
+		c.JSON(http.StatusOK, gin.H{"errorType": "FunctionError", "errorMessage": err.Error()})
+		return
+	}
+	code := models.GetAPIErrorCode(err)
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+	c.Header("X-Amzn-ErrorType", lambdaErrorType(code))
+	handleErrorResponse(c, err)
+}
+
+// handleLambdaInvoke invokes the fn of a Lambda function, as its invocation type asks
+func (s *Server) handleLambdaInvoke(c *gin.Context) {
+	ctx := c.Request.Context()
+	function := c.Param("function_name")
+	ctx, _ = common.LoggerWithFields(ctx, logrus.Fields{"lambda_function": function})
+	c.Request = c.Request.WithContext(ctx)
+
+	fn, err := s.lambdaFn(ctx, function, c.Query("Qualifier"))
+	if err != nil {
+		handleLambdaError(c, err)
+		return
+	}
+	app, err := s.lbReadAccess.GetAppByID(ctx, fn.AppID)
+	if err != nil {
+		handleLambdaError(c, err)
+		return
+	}
+
+	c.Header("X-Amz-Executed-Version", lambdaLatest)
+	switch invocationType := c.GetHeader("X-Amz-Invocation-Type"); invocationType {
+	case "", "RequestResponse":
+	case "Event":
+		c.Request.Header.Set("Fn-Invoke-Type", models.TypeDetached)
+	case "DryRun":
+		c.Status(http.StatusNoContent)
+		return
+	default:
+		handleLambdaError(c, models.NewAPIError(http.StatusBadRequest,
+			fmt.Errorf("invalid X-Amz-Invocation-Type %q, expected RequestResponse, Event or DryRun", invocationType)))
+		return
+	}
+
+	c.Writer = &callIDWriter{c.Writer, "X-Amzn-RequestId"}
+	if err := s.ServeFnInvoke(c, app, fn); err != nil {
+		handleLambdaError(c, err)
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestLambdaFunction(t *testing.T) {
+	for function, expected := range map[string][2]string{
+		"hello":         {"hello", ""},
+		"hello:myapp":   {"hello", "myapp"},
+		"hello:$LATEST": {"hello", "$LATEST"},
+		"arn:aws:lambda:us-west-2:123456789012:function:hello":       {"hello", ""},
+		"arn:aws:lambda:us-west-2:123456789012:function:hello:myapp": {"hello", "myapp"},
+	} {
+		name, qualifier := lambdaFunction(function)
+		if name != expected[0] || qualifier != expected[1] {
+			t.Errorf("%s: expected %q %q, got %q %q", function, expected[0], expected[1], name, qualifier)
+		}
+	}
+}
+
+func TestLambdaInvoke(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", Name: "hello", AppID: app.ID, Image: "fnproject/hello"}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn})
+	s := &Server{datastore: ds, lbReadAccess: agent.NewCachedDataAccess(ds), lambdaApp: "myapp"}
+	engine := gin.New()
+	engine.POST(lambdaInvokePrefix+":function_name/invocations", s.handleLambdaInvoke)
+
+	for _, test := range []struct {
+		path           string
+		invocationType string
+		status         int
+		errorType      string
+	}{
+		{"hello/invocations", "DryRun", http.StatusNoContent, ""},
+		{"hello:myapp/invocations", "DryRun", http.StatusNoContent, ""},
+		{"hello/invocations?Qualifier=myapp", "DryRun", http.StatusNoContent, ""},
+		{"arn:aws:lambda:us-west-2:123456789012:function:hello/invocations", "DryRun", http.StatusNoContent, ""},
+		{"goodbye/invocations", "DryRun", http.StatusNotFound, "ResourceNotFoundException"},
+		{"hello/invocations?Qualifier=otherapp", "DryRun", http.StatusNotFound, "ResourceNotFoundException"},
+		{"hello/invocations", "Sometimes", http.StatusBadRequest, "InvalidRequestContentException"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, lambdaInvokePrefix+test.path, nil)
+		req.Header.Set("X-Amz-Invocation-Type", test.invocationType)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.path, test.status, rec.Code)
+		}
+		if errorType := rec.Header().Get("X-Amzn-ErrorType"); errorType != test.errorType {
+			t.Errorf("%s: expected error type %q, got %q", test.path, test.errorType, errorType)
+		}
+	}
+
+	// fns are named by id without a default app
+	s.lambdaApp = ""
+	req := httptest.NewRequest(http.MethodPost, lambdaInvokePrefix+"fn_id/invocations?Qualifier=$LATEST", nil)
+	req.Header.Set("X-Amz-Invocation-Type", "DryRun")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected fn id to be found, got status %d", rec.Code)
+	}
+}
@@ -6,3 +6,9 @@ import "github.com/fnproject/fn/fnext"
 func (s *Server) AddCallListener(listener fnext.CallListener) {
 	s.agent.AddCallListener(listener)
 }
+
+// AddCallInputRedactor adds a redactor that the inputs of calls are passed through, in the order added, before
+// they are recorded with their call records.
+func (s *Server) AddCallInputRedactor(redactor fnext.CallInputRedactor) {
+	s.callInputRedactors = append(s.callInputRedactors, redactor)
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/datastore"
+)
+
+// WithLockStore sets the store of the distributed locks electing the server doing cluster wide work, such as firing
+// cron triggers, shared with extensions. The leases of the datastore are used if it is not set.
+func WithLockStore(ls common.LockStore) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.lockStore = ls
+		return nil
+	}
+}
+
+// WithLockStoreURL keeps the distributed locks in the redis at redisURL, such as redis://:password@localhost:6379/0.
+// The leases of the datastore are used if it is empty.
+func WithLockStoreURL(redisURL string) Option {
+	return func(ctx context.Context, s *Server) error {
+		if redisURL == "" {
+			return nil
+		}
+		ls, err := common.NewRedisLockStore(redisURL)
+		if err != nil {
+			return err
+		}
+		return WithLockStore(ls)(ctx, s)
+	}
+}
+
+// LockStore implements fnext.ExtServer, it returns the store set with WithLockStore, or one keeping locks as the
+// leases of the datastore of the server
+func (s *Server) LockStore() common.LockStore {
+	if s.lockStore == nil && s.datastore != nil {
+		return datastore.NewLockStore(s.datastore)
+	}
+	return s.lockStore
+}
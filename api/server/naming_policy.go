@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+)
+
+// namingPolicy enforces the operator naming policy on apps, fns and triggers when they are created or updated
+type namingPolicy struct {
+	policy *models.NamingPolicy
+	// datastore lookups are required on update, as updates carry only the changed fields
+	ds  func() models.Datastore
+	now func() time.Time
+}
+
+var _ fnext.AppListener = new(namingPolicy)
+var _ fnext.FnListener = new(namingPolicy)
+var _ fnext.TriggerListener = new(namingPolicy)
+
+// WithNamingPolicy rejects creates and updates of apps, fns and triggers whose names the policy does not allow, or
+// which change fields it makes immutable outside of their change windows, see models.NamingPolicy
+func WithNamingPolicy(policy *models.NamingPolicy) Option {
+	return func(ctx context.Context, s *Server) error {
+		if policy == nil {
+			return nil
+		}
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("invalid naming policy: %v", err)
+		}
+		p := &namingPolicy{policy: policy, ds: func() models.Datastore { return s.datastore }, now: time.Now}
+		s.AddAppListener(p)
+		s.AddFnListener(p)
+		s.AddTriggerListener(p)
+		return nil
+	}
+}
+
+// namingPolicyFromEnv reads the policy for WithNamingPolicy from EnvNamingPolicy, nil if it is not set
+func namingPolicyFromEnv() (*models.NamingPolicy, error) {
+	v := getEnv(EnvNamingPolicy, "")
+	if v == "" {
+		return nil, nil
+	}
+	var policy models.NamingPolicy
+	if err := json.Unmarshal([]byte(v), &policy); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", EnvNamingPolicy, err)
+	}
+	return &policy, nil
+}
+
+func (p *namingPolicy) BeforeAppCreate(ctx context.Context, app *models.App) error {
+	return p.policy.CheckName(models.KindApp, app.Name)
+}
+
+func (p *namingPolicy) BeforeAppUpdate(ctx context.Context, app *models.App) error {
+	old, err := p.ds().GetAppByID(ctx, app.ID)
+	if err != nil {
+		return err
+	}
+	updated := old.Clone()
+	updated.Update(app)
+	return p.policy.CheckChanges(models.KindApp, old, updated, p.now())
+}
+
+func (p *namingPolicy) BeforeFnCreate(ctx context.Context, fn *models.Fn) error {
+	return p.policy.CheckName(models.KindFn, fn.Name)
+}
+
+func (p *namingPolicy) BeforeFnUpdate(ctx context.Context, fn *models.Fn) error {
+	old, err := p.ds().GetFnByID(ctx, fn.ID)
+	if err != nil {
+		return err
+	}
+	updated := old.Clone()
+	updated.Update(fn)
+	return p.policy.CheckChanges(models.KindFn, old, updated, p.now())
+}
+
+func (p *namingPolicy) BeforeTriggerCreate(ctx context.Context, t *models.Trigger) error {
+	return p.policy.CheckName(models.KindTrigger, t.Name)
+}
+
+func (p *namingPolicy) BeforeTriggerUpdate(ctx context.Context, t *models.Trigger) error {
+	old, err := p.ds().GetTriggerByID(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	updated := old.Clone()
+	updated.Update(t)
+	if updated.Name != old.Name {
+		if err := p.policy.CheckName(models.KindTrigger, updated.Name); err != nil {
+			return err
+		}
+	}
+	return p.policy.CheckChanges(models.KindTrigger, old, updated, p.now())
+}
+
+func (p *namingPolicy) AfterAppCreate(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (p *namingPolicy) AfterAppUpdate(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (p *namingPolicy) BeforeAppDelete(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (p *namingPolicy) AfterAppDelete(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (p *namingPolicy) BeforeAppGet(ctx context.Context, appID string) error {
+	return nil
+}
+
+func (p *namingPolicy) AfterAppGet(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (p *namingPolicy) BeforeAppsList(ctx context.Context, filter *models.AppFilter) error {
+	return nil
+}
+
+func (p *namingPolicy) AfterAppsList(ctx context.Context, apps []*models.App) error {
+	return nil
+}
+
+func (p *namingPolicy) AfterFnCreate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (p *namingPolicy) AfterFnUpdate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (p *namingPolicy) BeforeFnDelete(ctx context.Context, fnID string) error {
+	return nil
+}
+
+func (p *namingPolicy) AfterFnDelete(ctx context.Context, fnID string) error {
+	return nil
+}
+
+func (p *namingPolicy) AfterTriggerCreate(ctx context.Context, t *models.Trigger) error {
+	return nil
+}
+
+func (p *namingPolicy) AfterTriggerUpdate(ctx context.Context, t *models.Trigger) error {
+	return nil
+}
+
+func (p *namingPolicy) BeforeTriggerDelete(ctx context.Context, triggerID string) error {
+	return nil
+}
+
+func (p *namingPolicy) AfterTriggerDelete(ctx context.Context, triggerID string) error {
+	return nil
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestNamingPolicy(t *testing.T) {
+	defer envTweaker(EnvNamingPolicy, `{"triggers": {"pattern": "[a-z]+"}, "immutable": [{"kind": "fn", "field": "image"}, {"kind": "trigger", "field": "source"}]}`)()
+	policy, err := namingPolicyFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", AppID: app.ID, Name: "myfn", Image: "fnproject/fn-test-utils:1"}
+	trigger := &models.Trigger{ID: "trigger_id", AppID: app.ID, FnID: fn.ID, Name: "mytrigger", Type: "http", Source: "/a"}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn}, []*models.Trigger{trigger})
+	p := &namingPolicy{policy: policy, ds: func() models.Datastore { return ds }, now: time.Now}
+	ctx := context.Background()
+
+	if err := p.BeforeTriggerCreate(ctx, &models.Trigger{Name: "Bad_Name"}); models.GetAPIErrorCode(err) != http.StatusBadRequest {
+		t.Errorf("expected a trigger name not matching the pattern to be rejected, got %v", err)
+	}
+	if err := p.BeforeTriggerUpdate(ctx, &models.Trigger{ID: trigger.ID, Name: "Bad_Name"}); models.GetAPIErrorCode(err) != http.StatusBadRequest {
+		t.Errorf("expected renaming a trigger to a name not matching the pattern to be rejected, got %v", err)
+	}
+	if err := p.BeforeTriggerUpdate(ctx, &models.Trigger{ID: trigger.ID, Source: "/b"}); models.GetAPIErrorCode(err) != http.StatusConflict {
+		t.Errorf("expected changing the source of a trigger to be rejected, got %v", err)
+	}
+	if err := p.BeforeFnUpdate(ctx, &models.Fn{ID: fn.ID, Image: "fnproject/fn-test-utils:2"}); models.GetAPIErrorCode(err) != http.StatusConflict {
+		t.Errorf("expected changing the image of a fn to be rejected, got %v", err)
+	}
+	if err := p.BeforeFnUpdate(ctx, &models.Fn{ID: fn.ID, Config: models.Config{"k": "v"}}); err != nil {
+		t.Errorf("expected changing the config of a fn to be allowed, got %v", err)
+	}
+	if err := p.BeforeFnCreate(ctx, &models.Fn{Name: "Any_Name"}); err != nil {
+		t.Errorf("expected fn names to be unrestricted, got %v", err)
+	}
+}
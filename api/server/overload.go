@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrServerBusy is returned to the requests turned away as the server is handling and queueing as many as it may
+var ErrServerBusy = models.NewAPIError(http.StatusServiceUnavailable, errors.New("Server is busy, please retry"))
+
+// WithRequestConcurrency bounds how many requests the web server handles at once, so that it degrades predictably
+// when overloaded. Requests past maxRequests wait, in a queue of up to maxQueued requests, up to queueTimeout for
+// another to finish, 0 waiting as long as their client does. Requests that find the queue full or time out in it
+// are turned away with a 503. 0 maxRequests leaves requests unbounded. The limits are tunable at runtime.
+func WithRequestConcurrency(maxRequests, maxQueued int, queueTimeout time.Duration) Option {
+	return func(ctx context.Context, s *Server) error {
+		return s.requestLimiter.set(requestLimits{maxRequests: maxRequests, maxQueued: maxQueued, timeout: queueTimeout})
+	}
+}
+
+// WithClientConnectionLimit bounds how many connections each client IP may have open to the web server. Further
+// connections of a client are closed as soon as they are accepted. 0 leaves connections unbounded.
+func WithClientConnectionLimit(maxConns int) Option {
+	return func(ctx context.Context, s *Server) error {
+		if maxConns < 0 {
+			return errors.New("client connection limit must not be negative")
+		}
+		s.maxClientConns = maxConns
+		return nil
+	}
+}
+
+// requestLimits are the limits of a requestLimiter, see WithRequestConcurrency
+type requestLimits struct {
+	maxRequests int
+	maxQueued   int
+	timeout     time.Duration
+}
+
+// requestLimiter admits a bounded number of requests at once, queueing a bounded number more. Its limits may be
+// changed while it admits requests, the requests admitted or queued before a change do not count against the new
+// limits. The zero value admits every request.
+type requestLimiter struct {
+	lock   sync.Mutex
+	limits requestLimits
+	slots  chan struct{}
+	queue  chan struct{}
+}
+
+// set changes the limits of the limiter
+func (l *requestLimiter) set(limits requestLimits) error {
+	if limits.maxRequests < 0 || limits.maxQueued < 0 || limits.timeout < 0 {
+		return errors.New("request concurrency limits must not be negative")
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.limits = limits
+	l.slots, l.queue = nil, nil
+	if limits.maxRequests > 0 {
+		l.slots = make(chan struct{}, limits.maxRequests)
+		l.queue = make(chan struct{}, limits.maxQueued)
+	}
+	return nil
+}
+
+// get returns the limits of the limiter
+func (l *requestLimiter) get() requestLimits {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.limits
+}
+
+// acquire waits for a slot for a request, returning false if the queue is full, or the request times out or is
+// canceled waiting in it. release must be called once a request that got a slot is done.
+func (l *requestLimiter) acquire(ctx context.Context) (release func(), ok bool) {
+	l.lock.Lock()
+	slots, queue, queueTimeout := l.slots, l.queue, l.limits.timeout
+	l.lock.Unlock()
+	if slots == nil {
+		return func() {}, true
+	}
+	release = func() { <-slots }
+
+	select {
+	case slots <- struct{}{}:
+		return release, true
+	default:
+	}
+
+	select {
+	case queue <- struct{}{}:
+	default:
+		return nil, false
+	}
+	defer func() { <-queue }()
+
+	var timeout <-chan time.Time
+	if queueTimeout > 0 {
+		timer := time.NewTimer(queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case slots <- struct{}{}:
+		return release, true
+	case <-timeout:
+	case <-ctx.Done():
+	}
+	return nil, false
+}
+
+// requestLimitMiddleware turns requests away once the server is handling and queueing as many as it may. Pings are
+// not limited, so that a busy server still reports itself up.
+func (s *Server) requestLimitMiddleware(c *gin.Context) {
+	if c.Request.URL.Path == "/" {
+		c.Next()
+		return
+	}
+	release, ok := s.requestLimiter.acquire(c.Request.Context())
+	if !ok {
+		c.Header("Retry-After", "1")
+		handleErrorResponse(c, ErrServerBusy)
+		c.Abort()
+		return
+	}
+	defer release()
+	c.Next()
+}
+
+// listen returns the listener of server, limiting the connections of each client if configured to
+func (s *Server) listen(server *http.Server) (net.Listener, error) {
+	addr := server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if s.maxClientConns > 0 {
+		l = &clientConnLimitListener{Listener: l, max: s.maxClientConns, conns: make(map[string]int)}
+	}
+	return l, nil
+}
+
+// clientConnLimitListener closes the connections of clients that have as many open as they may
+type clientConnLimitListener struct {
+	net.Listener
+	max int
+
+	lock  sync.Mutex
+	conns map[string]int
+}
+
+func (l *clientConnLimitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		client := conn.RemoteAddr().String()
+		if host, _, err := net.SplitHostPort(client); err == nil {
+			client = host
+		}
+
+		l.lock.Lock()
+		if l.conns[client] >= l.max {
+			l.lock.Unlock()
+			conn.Close()
+			continue
+		}
+		l.conns[client]++
+		l.lock.Unlock()
+		return &clientConn{Conn: conn, release: func() { l.release(client) }}, nil
+	}
+}
+
+func (l *clientConnLimitListener) release(client string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.conns[client]--; l.conns[client] <= 0 {
+		delete(l.conns, client)
+	}
+}
+
+// clientConn counts against the connections of its client until it is closed
+type clientConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *clientConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
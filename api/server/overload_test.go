@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/config"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestConcurrency(t *testing.T) {
+	srv := testServer(datastore.NewMock(), nil, ServerTypeAPI, WithRequestConcurrency(1, 1, 100*time.Millisecond))
+	started, unblock := make(chan struct{}, 2), make(chan struct{})
+	engine := gin.New()
+	engine.Use(srv.requestLimitMiddleware)
+	engine.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		<-unblock
+		c.Status(http.StatusOK)
+	})
+	engine.GET("/", handlePing)
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = get("/slow")
+		}(i)
+		if i == 0 {
+			<-started
+		}
+	}
+	// the first request is handled and the second queued, so a third finds the queue full
+	time.Sleep(20 * time.Millisecond)
+	if code := get("/slow"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected a request past the queue to be turned away, got %d", code)
+	}
+	if code := get("/"); code != http.StatusOK {
+		t.Errorf("expected pings not to be limited, got %d", code)
+	}
+	close(unblock)
+	wg.Wait()
+	<-started
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK {
+		t.Errorf("expected the queued request to be handled once the first finished, got %v", codes)
+	}
+
+	// a queued request times out
+	unblock = make(chan struct{})
+	go get("/slow")
+	<-started
+	if code := get("/slow"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected a request to time out in the queue, got %d", code)
+	}
+	close(unblock)
+}
+
+func TestRequestConcurrencyTunable(t *testing.T) {
+	ctx := context.Background()
+	srv := testServer(datastore.NewMock(), nil, ServerTypeAPI)
+	if release, ok := srv.requestLimiter.acquire(ctx); !ok {
+		t.Fatal("expected requests to be unbounded")
+	} else {
+		release()
+	}
+
+	// limits set at runtime apply to the requests arriving after them
+	if _, err := config.Tune(EnvMaxConcurrentRequests, "1", "test"); err != nil {
+		t.Fatal(err)
+	}
+	defer config.Tune(EnvMaxConcurrentRequests, "0", "test")
+	release, ok := srv.requestLimiter.acquire(ctx)
+	if !ok {
+		t.Fatal("expected the first request to be admitted")
+	}
+	if _, ok := srv.requestLimiter.acquire(ctx); ok {
+		t.Fatal("expected a request past the limit to be turned away")
+	}
+	release()
+	if _, ok := srv.requestLimiter.acquire(ctx); !ok {
+		t.Fatal("expected a request to be admitted once the first finished")
+	}
+
+	if _, err := config.Tune(EnvMaxQueuedRequests, "-1", "test"); err == nil {
+		t.Fatal("expected negative limits to be rejected")
+	}
+	if limits := srv.requestLimiter.get(); limits.maxRequests != 1 || limits.maxQueued != 0 {
+		t.Fatalf("expected the limits to be left alone, got %+v", limits)
+	}
+}
+
+func TestClientConnectionLimit(t *testing.T) {
+	srv := testServer(datastore.NewMock(), nil, ServerTypeAPI, WithClientConnectionLimit(1))
+	l, err := srv.listen(&http.Server{Addr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	accepted := make(chan net.Conn, 3)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	first := dial()
+	defer first.Close()
+	conn := <-accepted
+
+	// the client's second connection is closed as it is accepted
+	second := dial()
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err == nil {
+		t.Error("expected a connection past the limit of the client to be closed")
+	}
+	select {
+	case <-accepted:
+		t.Error("expected a connection past the limit of the client not to be served")
+	default:
+	}
+
+	conn.Close()
+	third := dial()
+	defer third.Close()
+	select {
+	case <-accepted:
+	case <-time.After(5 * time.Second):
+		t.Error("expected a connection to be served once the client closed another")
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+
+	"github.com/fnproject/fn/api/common/jsonpatch"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// mergedFields are the fields of apps, fns and triggers whose keys updates merge into the current ones, rather than
+// replacing them
+var mergedFields = map[string]bool{"config": true, "annotations": true}
+
+// patchUpdate applies the patch of a PATCH request to current, as a JSON merge patch, RFC 7386, or a JSON patch,
+// RFC 6902, by its content type, and decodes into update the changes it makes, as an update merging them into the
+// current object takes them. Only what the patch changes is updated, so that concurrent updates of other fields or
+// keys are not overwritten with the values current was read with.
+func patchUpdate(c *gin.Context, current, update interface{}) error {
+	patch, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch c.ContentType() {
+	case jsonpatch.MergePatchType, gin.MIMEJSON, "":
+		patched, err = jsonpatch.MergePatch(doc, patch)
+	case jsonpatch.PatchType:
+		patched, err = jsonpatch.Patch(doc, patch)
+	default:
+		return models.ErrUnsupportedMediaType
+	}
+	if err == jsonpatch.ErrTestFailed {
+		return models.ErrPatchTestFailed
+	}
+	if err != nil {
+		return models.NewAPIError(http.StatusBadRequest, fmt.Errorf("Invalid patch: %v", err))
+	}
+
+	changes, err := patchChanges(doc, patched)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(changes, update); err != nil {
+		if models.IsAPIError(err) {
+			return err
+		}
+		return models.ErrInvalidJSON
+	}
+	return nil
+}
+
+// patchChanges returns the fields of patched that differ from doc. Keys removed from merged fields are null, and
+// other fields removed are empty, which updates take as removing them.
+func patchChanges(doc, patched []byte) ([]byte, error) {
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(doc, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil || after == nil {
+		return nil, models.NewAPIError(http.StatusBadRequest, fmt.Errorf("Invalid patch: the result is not an object"))
+	}
+
+	changes := make(map[string]interface{})
+	for k, v := range after {
+		old, ok := before[k]
+		if ok && reflect.DeepEqual(old, v) {
+			continue
+		}
+		oldKeys, _ := old.(map[string]interface{})
+		keys, isMap := v.(map[string]interface{})
+		if !mergedFields[k] || !isMap {
+			changes[k] = v
+			continue
+		}
+		changed := make(map[string]interface{})
+		for key, value := range keys {
+			if oldValue, ok := oldKeys[key]; !ok || !reflect.DeepEqual(oldValue, value) {
+				changed[key] = value
+			}
+		}
+		for key := range oldKeys {
+			if _, ok := keys[key]; !ok {
+				changed[key] = nil
+			}
+		}
+		changes[k] = changed
+	}
+	for k, old := range before {
+		if _, ok := after[k]; ok {
+			continue
+		}
+		switch old := old.(type) {
+		case string:
+			changes[k] = ""
+		case bool:
+			changes[k] = false
+		case map[string]interface{}:
+			removed := make(map[string]interface{}, len(old))
+			if mergedFields[k] {
+				for key := range old {
+					removed[key] = nil
+				}
+			}
+			changes[k] = removed
+		}
+	}
+	return json.Marshal(changes)
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestPatchChanges(t *testing.T) {
+	for _, test := range []struct{ doc, patched, expected string }{
+		{`{"id":"a","config":{"a":"1","b":"2"}}`, `{"id":"a","config":{"a":"1","c":"3"}}`, `{"config":{"b":null,"c":"3"}}`},
+		{`{"id":"a","config":{"a":"1"}}`, `{"id":"a"}`, `{"config":{"a":null}}`},
+		{`{"id":"a","annotations":{"x":{"y":1,"z":2}}}`, `{"id":"a","annotations":{"x":{"y":1}}}`, `{"annotations":{"x":{"y":1}}}`},
+		{`{"id":"a","syslog_url":"tcp://example.com:514"}`, `{"id":"a"}`, `{"syslog_url":""}`},
+		{`{"id":"a","chain":{"on_success":"f","on_failure":"g"}}`, `{"id":"a","chain":{"on_success":"h","on_failure":"g"}}`,
+			`{"chain":{"on_failure":"g","on_success":"h"}}`},
+		{`{"id":"a","chain":{"on_success":"f"}}`, `{"id":"a"}`, `{"chain":{}}`},
+		{`{"id":"a","timeout":30}`, `{"id":"a","timeout":60}`, `{"timeout":60}`},
+		{`{"id":"a","timeout":30}`, `{"id":"a","timeout":30}`, `{}`},
+	} {
+		changes, err := patchChanges([]byte(test.doc), []byte(test.patched))
+		if err != nil {
+			t.Fatalf("%s to %s: %v", test.doc, test.patched, err)
+		}
+		if string(changes) != test.expected {
+			t.Errorf("%s to %s: expected changes %s, got %s", test.doc, test.patched, test.expected, changes)
+		}
+	}
+
+	if _, err := patchChanges([]byte(`{"id":"a"}`), []byte(`["a"]`)); models.GetAPIErrorCode(err) != http.StatusBadRequest {
+		t.Errorf("expected patching an object into an array to be invalid, got %v", err)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "myapp", Config: models.Config{"a": "1", "b": "2"}}
+	fn := &models.Fn{ID: "fn_id", AppID: app.ID, Name: "myfn", Image: "fnproject/fn-test-utils", Config: models.Config{"a": "1"},
+		ResourceConfig: models.ResourceConfig{Memory: 128, Timeout: 30, IdleTimeout: 30}}
+	trigger := &models.Trigger{ID: "trigger_id", AppID: app.ID, FnID: fn.ID, Name: "mytrigger", Type: "http", Source: "/a"}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn}, []*models.Trigger{trigger})
+
+	s := &Server{datastore: ds}
+	engine := gin.New()
+	engine.PATCH("/v2/apps/:app_id", s.handleAppPatch)
+	engine.PATCH("/v2/fns/:fn_id", s.handleFnPatch)
+	engine.PATCH("/v2/triggers/:trigger_id", s.handleTriggerPatch)
+
+	for i, test := range []struct {
+		path, contentType, body string
+		expectedCode            int
+		expectedError           error
+	}{
+		{"/v2/apps/app_id", "application/merge-patch+json", `{"config":{"b":null,"c":"3"}}`, http.StatusOK, nil},
+		{"/v2/apps/app_id", "application/json-patch+json", `[{"op":"test","path":"/config/a","value":"1"},{"op":"replace","path":"/config/a","value":"x"}]`, http.StatusOK, nil},
+		{"/v2/apps/app_id", "application/json-patch+json", `[{"op":"test","path":"/config/a","value":"1"},{"op":"remove","path":"/config/a"}]`, http.StatusConflict, models.ErrPatchTestFailed},
+		{"/v2/apps/app_id", "application/json-patch+json", `[{"op":"remove","path":"/config/nope"}]`, http.StatusBadRequest, nil},
+		{"/v2/apps/app_id", "application/merge-patch+json", `{"id":"other"}`, http.StatusBadRequest, models.ErrAppsIDMismatch},
+		{"/v2/apps/app_id", "application/merge-patch+json", `{"name":"other"}`, http.StatusConflict, models.ErrAppsNameImmutable},
+		{"/v2/apps/app_id", "text/plain", `{}`, http.StatusUnsupportedMediaType, models.ErrUnsupportedMediaType},
+		{"/v2/apps/not_app", "application/merge-patch+json", `{}`, http.StatusNotFound, models.ErrAppsNotFound},
+		{"/v2/fns/fn_id", "application/json", `{"timeout":60,"config":{"a":null},"annotations":{"fnproject.io/x":"y"}}`, http.StatusOK, nil},
+		{"/v2/fns/fn_id", "application/json-patch+json", `[{"op":"replace","path":"/memory","value":"lots"}]`, http.StatusBadRequest, nil},
+		{"/v2/triggers/trigger_id", "application/json-patch+json", `[{"op":"replace","path":"/source","value":"/b"}]`, http.StatusOK, nil},
+	} {
+		req := httptest.NewRequest(http.MethodPatch, test.path, bytes.NewBufferString(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+
+		if rec.Code != test.expectedCode {
+			t.Fatalf("Test %d: expected status code %d, got %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+		if test.expectedError != nil {
+			resp := getErrorResponse(t, rec)
+			if !strings.Contains(resp.Message, test.expectedError.Error()) {
+				t.Errorf("Test %d: expected error message to have `%s`, got `%s`", i, test.expectedError.Error(), resp.Message)
+			}
+		}
+	}
+
+	ctx := context.Background()
+	app, err := ds.GetAppByID(ctx, "app_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (models.Config{"a": "x", "c": "3"}); !app.Config.Equals(expected) {
+		t.Errorf("expected the app config to be %v, got %v", expected, app.Config)
+	}
+
+	fn, err = ds.GetFnByID(ctx, "fn_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if annotation, err := fn.Annotations.GetString("fnproject.io/x"); err != nil || annotation != "y" {
+		t.Errorf("expected the fn to be annotated, got %v", fn.Annotations)
+	}
+	if fn.Timeout != 60 || len(fn.Config) != 0 || fn.Memory != 128 || fn.Image != "fnproject/fn-test-utils" {
+		t.Errorf("expected only the timeout and config of the fn to change, got %+v", fn)
+	}
+
+	trigger, err = ds.GetTriggerByID(ctx, "trigger_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trigger.Source != "/b" || trigger.Name != "mytrigger" {
+		t.Errorf("expected only the source of the trigger to change, got %+v", trigger)
+	}
+}
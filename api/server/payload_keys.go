@@ -0,0 +1,29 @@
+package server
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/agent"
+)
+
+// WithPayloadKeyProvider encrypts the payloads of calls between lbs and pure runners, in addition to TLS, with data
+// keys wrapped by keys, eg. the keys of a KMS
+func WithPayloadKeyProvider(keys agent.KeyProvider) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.payloadKeys = keys
+		return nil
+	}
+}
+
+// WithStaticPayloadKeys encrypts the payloads of calls between lbs and pure runners with data keys wrapped by a
+// comma separated list of id=base64 AES keys, see EnvPayloadKeys
+func WithStaticPayloadKeys(list string) Option {
+	return func(ctx context.Context, s *Server) error {
+		keys, current, err := agent.ParseStaticKeys(list)
+		if err != nil {
+			return err
+		}
+		s.payloadKeys, err = agent.NewStaticKeyProvider(keys, current)
+		return err
+	}
+}
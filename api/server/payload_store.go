@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/id"
+	"github.com/fnproject/fn/api/models"
+	"go.opencensus.io/trace"
+)
+
+const (
+	// PayloadURLHeader is set on calls whose input was uploaded to the payload store, to a pre-signed URL the fn
+	// downloads its input from. The request body passed to the fn is empty.
+	PayloadURLHeader = "Fn-Payload-Url"
+	// PayloadUploadURLHeader is set on sync calls to a pre-signed URL the fn may upload its output to
+	PayloadUploadURLHeader = "Fn-Payload-Upload-Url"
+	// PayloadUploadedHeader is set by fns on their response once they have uploaded their output, the response body
+	// is then ignored
+	PayloadUploadedHeader = "Fn-Payload-Uploaded"
+)
+
+// PayloadStore is an object store that large call payloads are exchanged with fns through, rather than passing
+// them on the invoke path. Payloads are stored under unique keys, and implementations are expected to expire them.
+type PayloadStore interface {
+	// Put uploads a call's input under key
+	Put(ctx context.Context, key string, body io.Reader) error
+	// Get downloads a fn's output from under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// DownloadURL returns a pre-signed URL to download the payload under key from
+	DownloadURL(ctx context.Context, key string) (string, error)
+	// UploadURL returns a pre-signed URL to upload a payload to under key
+	UploadURL(ctx context.Context, key string) (string, error)
+}
+
+// tracedPayloadStore traces the operations of a PayloadStore, in the traces of the calls they are for
+type tracedPayloadStore struct {
+	store PayloadStore
+}
+
+func (t *tracedPayloadStore) Put(ctx context.Context, key string, body io.Reader) (err error) {
+	ctx, span := trace.StartSpan(ctx, "payload_store_put")
+	defer func() { common.EndSpan(span, err) }()
+	return t.store.Put(ctx, key, body)
+}
+
+func (t *tracedPayloadStore) Get(ctx context.Context, key string) (_ io.ReadCloser, err error) {
+	ctx, span := trace.StartSpan(ctx, "payload_store_get")
+	defer func() { common.EndSpan(span, err) }()
+	return t.store.Get(ctx, key)
+}
+
+func (t *tracedPayloadStore) DownloadURL(ctx context.Context, key string) (_ string, err error) {
+	ctx, span := trace.StartSpan(ctx, "payload_store_download_url")
+	defer func() { common.EndSpan(span, err) }()
+	return t.store.DownloadURL(ctx, key)
+}
+
+func (t *tracedPayloadStore) UploadURL(ctx context.Context, key string) (_ string, err error) {
+	ctx, span := trace.StartSpan(ctx, "payload_store_upload_url")
+	defer func() { common.EndSpan(span, err) }()
+	return t.store.UploadURL(ctx, key)
+}
+
+// payloadPassThrough exchanges the payloads of calls larger than threshold through the store. Fn outputs are
+// returned to the caller as a redirect to the store, or proxied by the server.
+type payloadPassThrough struct {
+	store     PayloadStore
+	threshold int64
+	redirect  bool
+}
+
+// WithPayloadStore has request bodies larger than threshold bytes uploaded to store for fns to download, and
+// offers sync calls a URL in store to upload their output to. With redirect, callers are redirected to the output
+// in the store, otherwise the server proxies it.
+func WithPayloadStore(store PayloadStore, threshold int64, redirect bool) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.payloads = &payloadPassThrough{store: &tracedPayloadStore{store}, threshold: threshold, redirect: redirect}
+		return nil
+	}
+}
+
+// payloadKeys are where the payloads of a call are stored
+type payloadKeys struct {
+	input  string
+	output string
+}
+
+// offload uploads the body of req to the store if it is too large, and sets the headers that tell the fn where its
+// payloads are. It returns nil if req is left alone.
+func (p *payloadPassThrough) offload(ctx context.Context, req *http.Request, detached bool) (*payloadKeys, error) {
+	if p == nil {
+		return nil, nil
+	}
+	prefix := id.New().String()
+	keys := &payloadKeys{input: prefix + "/input", output: prefix + "/output"}
+	offloaded := false
+
+	if req.Body != nil {
+		head, err := ioutil.ReadAll(io.LimitReader(req.Body, p.threshold+1))
+		if err != nil {
+			return nil, err
+		}
+		body := io.MultiReader(bytes.NewReader(head), req.Body)
+		if int64(len(head)) > p.threshold {
+			if err := p.store.Put(ctx, keys.input, body); err != nil {
+				common.Logger(ctx).WithError(err).Error("failed to upload call input")
+				return nil, models.ErrPayloadStoreUnavailable
+			}
+			url, err := p.store.DownloadURL(ctx, keys.input)
+			if err != nil {
+				common.Logger(ctx).WithError(err).Error("failed to sign call input url")
+				return nil, models.ErrPayloadStoreUnavailable
+			}
+			req.Header.Set(PayloadURLHeader, url)
+			req.Header.Del("Content-Length")
+			req.Body = http.NoBody
+			req.ContentLength = 0
+			offloaded = true
+		} else {
+			req.Body = ioutil.NopCloser(body)
+		}
+	}
+
+	// detached calls have no one to return their output to
+	if detached {
+		if !offloaded {
+			return nil, nil
+		}
+		return keys, nil
+	}
+
+	url, err := p.store.UploadURL(ctx, keys.output)
+	if err != nil {
+		common.Logger(ctx).WithError(err).Error("failed to sign call output url")
+		return nil, models.ErrPayloadStoreUnavailable
+	}
+	req.Header.Set(PayloadUploadURLHeader, url)
+	return keys, nil
+}
+
+// respond replaces the response of a fn that uploaded its output with a redirect to it. If the output is
+// proxied instead, it is returned to be written in place of the response body.
+func (p *payloadPassThrough) respond(ctx context.Context, writer ResponseBuffer, buf *bytes.Buffer, keys *payloadKeys, trigger bool) (io.ReadCloser, error) {
+	h := writer.Header()
+	if p == nil || keys == nil || h.Get(PayloadUploadedHeader) == "" {
+		return nil, nil
+	}
+	h.Del(PayloadUploadedHeader)
+	buf.Reset()
+
+	if !p.redirect {
+		output, err := p.store.Get(ctx, keys.output)
+		if err != nil {
+			common.Logger(ctx).WithError(err).Error("failed to download call output")
+			return nil, models.ErrPayloadStoreUnavailable
+		}
+		return output, nil
+	}
+
+	url, err := p.store.DownloadURL(ctx, keys.output)
+	if err != nil {
+		common.Logger(ctx).WithError(err).Error("failed to sign call output url")
+		return nil, models.ErrPayloadStoreUnavailable
+	}
+	// http triggers take their headers and status from the fn's http headers
+	if trigger {
+		h.Set("Fn-Http-H-Location", url)
+		h.Set("Fn-Http-Status", strconv.Itoa(http.StatusSeeOther))
+	} else {
+		h.Set("Location", url)
+		writer.WriteHeader(http.StatusSeeOther)
+	}
+	return nil, nil
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memPayloadStore is a PayloadStore in memory, with URLs made up of its keys
+type memPayloadStore struct {
+	lock     sync.Mutex
+	payloads map[string][]byte
+}
+
+func (m *memPayloadStore) Put(ctx context.Context, key string, body io.Reader) error {
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.payloads[key] = b
+	return nil
+}
+
+func (m *memPayloadStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return ioutil.NopCloser(bytes.NewReader(m.payloads[key])), nil
+}
+
+func (m *memPayloadStore) DownloadURL(ctx context.Context, key string) (string, error) {
+	return "https://store/get/" + key, nil
+}
+
+func (m *memPayloadStore) UploadURL(ctx context.Context, key string) (string, error) {
+	return "https://store/put/" + key, nil
+}
+
+func TestPayloadOffload(t *testing.T) {
+	ctx := context.Background()
+	store := &memPayloadStore{payloads: make(map[string][]byte)}
+	p := &payloadPassThrough{store: store, threshold: 8}
+
+	// small inputs are passed to the fn, which can still upload its output
+	req := httptest.NewRequest(http.MethodPost, "/invoke/fn_id", strings.NewReader("small"))
+	keys, err := p.offload(ctx, req, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(req.Body)
+	if string(b) != "small" || req.Header.Get(PayloadURLHeader) != "" {
+		t.Errorf("expected input to be left alone, got %q %v", b, req.Header)
+	}
+	if url := req.Header.Get(PayloadUploadURLHeader); url != "https://store/put/"+keys.output {
+		t.Errorf("expected output upload url, got %q", url)
+	}
+
+	large := strings.Repeat("x", 9)
+	req = httptest.NewRequest(http.MethodPost, "/invoke/fn_id", strings.NewReader(large))
+	keys, err = p.offload(ctx, req, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = ioutil.ReadAll(req.Body)
+	if len(b) != 0 || req.ContentLength != 0 {
+		t.Errorf("expected no input to be passed to the fn, got %q", b)
+	}
+	if url := req.Header.Get(PayloadURLHeader); url != "https://store/get/"+keys.input {
+		t.Errorf("expected input download url, got %q", url)
+	}
+	if string(store.payloads[keys.input]) != large {
+		t.Errorf("expected input to be uploaded, got %q", store.payloads[keys.input])
+	}
+
+	// detached calls only have their input offloaded
+	req = httptest.NewRequest(http.MethodPost, "/invoke/fn_id", strings.NewReader("small"))
+	if keys, err := p.offload(ctx, req, true); keys != nil || err != nil || req.Header.Get(PayloadUploadURLHeader) != "" {
+		t.Errorf("expected detached call to be left alone, got %v %v", keys, err)
+	}
+
+	var nilPassThrough *payloadPassThrough
+	if keys, err := nilPassThrough.offload(ctx, req, false); keys != nil || err != nil {
+		t.Errorf("expected no offload without a store, got %v %v", keys, err)
+	}
+}
+
+func TestPayloadRespond(t *testing.T) {
+	ctx := context.Background()
+	store := &memPayloadStore{payloads: map[string][]byte{"call/output": []byte("output")}}
+	keys := &payloadKeys{input: "call/input", output: "call/output"}
+
+	newWriter := func(uploaded bool) *syncResponseWriter {
+		w := &syncResponseWriter{headers: make(http.Header), status: http.StatusOK, Buffer: bytes.NewBufferString("ignored")}
+		if uploaded {
+			w.headers.Set(PayloadUploadedHeader, "true")
+		}
+		return w
+	}
+
+	// the fn returned its output as usual
+	p := &payloadPassThrough{store: store, redirect: true}
+	w := newWriter(false)
+	if output, err := p.respond(ctx, w, w.Buffer, keys, false); output != nil || err != nil || w.String() != "ignored" {
+		t.Errorf("expected response to be left alone, got %v %v %q", output, err, w.String())
+	}
+
+	w = newWriter(true)
+	if output, err := p.respond(ctx, w, w.Buffer, keys, false); output != nil || err != nil {
+		t.Fatalf("expected a redirect, got %v %v", output, err)
+	}
+	if w.Status() != http.StatusSeeOther || w.headers.Get("Location") != "https://store/get/call/output" || w.Len() != 0 {
+		t.Errorf("unexpected redirect %d %v %q", w.Status(), w.headers, w.String())
+	}
+	if w.headers.Get(PayloadUploadedHeader) != "" {
+		t.Error("expected the uploaded header to be removed")
+	}
+
+	// triggers redirect through the fn's http headers
+	w = newWriter(true)
+	p.respond(ctx, w, w.Buffer, keys, true)
+	if w.headers.Get("Fn-Http-Status") != "303" || w.headers.Get("Fn-Http-H-Location") != "https://store/get/call/output" {
+		t.Errorf("unexpected trigger redirect %v", w.headers)
+	}
+
+	p.redirect = false
+	w = newWriter(true)
+	output, err := p.respond(ctx, w, w.Buffer, keys, false)
+	if err != nil || output == nil {
+		t.Fatalf("expected proxied output, got %v", err)
+	}
+	b, _ := ioutil.ReadAll(output)
+	if string(b) != "output" || w.Status() != http.StatusOK {
+		t.Errorf("unexpected proxied output %d %q", w.Status(), b)
+	}
+}
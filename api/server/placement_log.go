@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	pool "github.com/fnproject/fn/api/runnerpool"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// placementLogBuffer is how many placement attempts may wait to be written before new ones are dropped
+const placementLogBuffer = 1024
+
+type placementsResponse struct {
+	Items []pool.PlacementAttempt `json:"items"`
+}
+
+// WithPlacementLog makes an lb record the attempts of its placers to place calls on runners in a file at path,
+// keeping about the last size attempts, so slow or failed placements can be looked into after the fact through the
+// admin server. It must be set before WithAgentFromEnv.
+func WithPlacementLog(path string, size int) Option {
+	return func(ctx context.Context, s *Server) error {
+		if path == "" || size <= 0 {
+			return nil
+		}
+		if s.placements == nil {
+			s.placements = newPlacementLog()
+		}
+		return s.placements.open(path, size)
+	}
+}
+
+// WithPlacementProducer makes an lb publish the attempts of its placers to place calls on runners as JSON messages,
+// keyed by the id of their call. It must be set before WithAgentFromEnv.
+func WithPlacementProducer(producer UsageProducer) Option {
+	return func(ctx context.Context, s *Server) error {
+		if s.placements == nil {
+			s.placements = newPlacementLog()
+		}
+		s.placements.producers = append(s.placements.producers, producer)
+		return nil
+	}
+}
+
+// placementLog records placement attempts in a ring of two files, path and path.1, each holding up to half the
+// attempts kept, and to producers. Attempts are written in the background so placers are never held up by them.
+type placementLog struct {
+	producers []UsageProducer
+	attempts  chan pool.PlacementAttempt
+	done      chan struct{}
+
+	lock  sync.Mutex
+	path  string
+	max   int
+	file  *os.File
+	lines int
+}
+
+var _ pool.PlacementRecorder = new(placementLog)
+
+func newPlacementLog() *placementLog {
+	l := &placementLog{
+		attempts: make(chan pool.PlacementAttempt, placementLogBuffer),
+		done:     make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+func (l *placementLog) open(path string, size int) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.path = path
+	l.max = (size + 1) / 2
+	lines, err := countLines(path)
+	if err != nil {
+		return err
+	}
+	l.lines = lines
+	l.file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	return err
+}
+
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines, scanner.Err()
+}
+
+// RecordPlacement implements runnerpool.PlacementRecorder, dropping attempts while the log falls behind
+func (l *placementLog) RecordPlacement(ctx context.Context, attempt pool.PlacementAttempt) {
+	select {
+	case l.attempts <- attempt:
+	default:
+		logrus.WithField("call_id", attempt.CallID).Debug("Placement log falling behind, dropping placement attempt")
+	}
+}
+
+func (l *placementLog) run() {
+	defer close(l.done)
+	for attempt := range l.attempts {
+		b, err := json.Marshal(attempt)
+		if err != nil {
+			continue
+		}
+		if err := l.write(b); err != nil {
+			logrus.WithError(err).Warn("Failed to write placement log")
+		}
+		for _, p := range l.producers {
+			if err := p.Produce(context.Background(), attempt.CallID, b); err != nil {
+				logrus.WithError(err).Warn("Failed to publish placement attempt")
+			}
+		}
+	}
+}
+
+func (l *placementLog) write(b []byte) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file == nil {
+		return nil
+	}
+
+	if l.lines >= l.max {
+		l.file.Close()
+		if err := os.Rename(l.path, l.path+".1"); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(l.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			l.file = nil
+			return err
+		}
+		l.file, l.lines = f, 0
+	}
+
+	l.lines++
+	_, err := l.file.Write(append(b, '\n'))
+	return err
+}
+
+// close writes the attempts recorded so far and closes the log
+func (l *placementLog) close() {
+	close(l.attempts)
+	<-l.done
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// list returns the last limit attempts in the log for the call callID, or any call if "", newest first
+func (l *placementLog) list(callID string, limit int) ([]pool.PlacementAttempt, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var attempts []pool.PlacementAttempt
+	for _, path := range []string{l.path + ".1", l.path} {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var attempt pool.PlacementAttempt
+			// skip lines torn by a crash
+			if json.Unmarshal(scanner.Bytes(), &attempt) != nil {
+				continue
+			}
+			if callID == "" || attempt.CallID == callID {
+				attempts = append(attempts, attempt)
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	newest := make([]pool.PlacementAttempt, 0, limit)
+	for i := len(attempts) - 1; i >= 0 && len(newest) < limit; i-- {
+		newest = append(newest, attempts[i])
+	}
+	return newest, nil
+}
+
+// handlePlacementList lists the placement attempts in the placement log of an lb, newest first, optionally those of
+// one call only
+func (s *Server) handlePlacementList(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	attempts, err := s.placements.list(c.Query("call_id"), limit)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, placementsResponse{Items: attempts})
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	pool "github.com/fnproject/fn/api/runnerpool"
+	"github.com/gin-gonic/gin"
+)
+
+func TestPlacementLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "placements")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "placements.log")
+
+	ctx := context.Background()
+	producer := &memUsageProducer{messages: make(map[string][]string)}
+	s := &Server{}
+	if err := WithPlacementLog(path, 4)(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := WithPlacementProducer(producer)(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 7; i++ {
+		s.placements.RecordPlacement(ctx, pool.PlacementAttempt{CallID: "call" + strconv.Itoa(i%2), Attempt: i, Outcome: pool.PlacementBusy})
+	}
+	s.placements.close()
+
+	if len(producer.messages["call0"]) != 3 || len(producer.messages["call1"]) != 4 {
+		t.Fatalf("expected every attempt published, got %v", producer.messages)
+	}
+
+	// the ring keeps the last 2 to 4 attempts
+	attempts, err := s.placements.list("", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 3 || attempts[0].Attempt != 7 || attempts[2].Attempt != 5 {
+		t.Fatalf("expected the last attempts newest first, got %+v", attempts)
+	}
+
+	attempts, err = s.placements.list("call1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 1 || attempts[0].Attempt != 7 {
+		t.Fatalf("expected the last attempt of call1, got %+v", attempts)
+	}
+
+	// a restarted lb picks up the log where it was left
+	s = &Server{}
+	if err := WithPlacementLog(path, 4)(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	s.placements.RecordPlacement(ctx, pool.PlacementAttempt{CallID: "call0", Attempt: 8})
+	s.placements.close()
+	attempts, _ = s.placements.list("", 100)
+	if len(attempts) != 4 || attempts[0].Attempt != 8 || attempts[1].Attempt != 7 {
+		t.Fatalf("expected the log appended to on restart, got %+v", attempts)
+	}
+
+	// listed through the admin server
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v2/admin/diagnostics/placements?call_id=call0&limit=1", nil)
+	s.handlePlacementList(c)
+	var resp placementsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Items) != 1 || resp.Items[0].Attempt != 8 {
+		t.Fatalf("unexpected placements response %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	ctx := context.Background()
+	s := &Server{}
+	if err := WithPrometheusMetrics("acme", "dockerd=docker_daemon", "cluster=prod, region=us-ashburn-1")(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := WithPrometheus()(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	defer view.UnregisterExporter(s.promExporter)
+
+	if s.promComponents["dockerd"] != "docker_daemon" {
+		t.Errorf("expected the namespace of dockerd, got %v", s.promComponents)
+	}
+
+	rec := httptest.NewRecorder()
+	s.promExporter.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, metric := range []string{
+		`acme_process_open_fds{cluster="prod",region="us-ashburn-1"}`,
+		`go_goroutines{cluster="prod",region="us-ashburn-1"}`,
+	} {
+		if !strings.Contains(body, metric) {
+			t.Errorf("expected metric %s, got %s", metric, body)
+		}
+	}
+	if strings.Contains(body, "fn_process_") {
+		t.Errorf("expected the metrics of fn to be renamed, got %s", body)
+	}
+
+	for i, opts := range [][3]string{
+		{"acme-corp", "", ""},
+		{"acme", "dockerd", ""},
+		{"acme", "dockerd=docker-daemon", ""},
+		{"acme", "", "cluster"},
+		{"acme", "", "__cluster=prod"},
+		{"acme", "", "cluster-name=prod"},
+	} {
+		if err := WithPrometheusMetrics(opts[0], opts[1], opts[2])(ctx, &Server{}); err == nil {
+			t.Errorf("Test %d: expected %q to be invalid", i, opts)
+		}
+	}
+}
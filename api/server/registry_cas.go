@@ -0,0 +1,56 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxRegistryCASize is the largest CA bundle accepted for a registry
+const maxRegistryCASize = 1 << 20
+
+type registryCAsResponse struct {
+	Items []agent.RegistryCA `json:"items"`
+}
+
+// handleRegistryCAList lists the CA bundles the runner trusts for pulls from private registries
+func (s *Server) handleRegistryCAList(c *gin.Context) {
+	cas, err := s.agent.(agent.RegistryCAManager).RegistryCAs()
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, registryCAsResponse{Items: cas})
+}
+
+// handleRegistryCAPut adds or replaces the CA bundle of a registry, given as PEM encoded certificates, which the
+// next pulls from the registry trust
+func (s *Server) handleRegistryCAPut(c *gin.Context) {
+	bundle, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxRegistryCASize))
+	if err != nil {
+		handleErrorResponse(c, models.ErrRequestContentTooBig)
+		return
+	}
+	ca, err := s.agent.(agent.RegistryCAManager).PutRegistryCA(c.Param("registry"), bundle)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{"registry": ca.Registry, "by": c.ClientIP()}).Info("Registry CA put")
+	c.JSON(http.StatusOK, ca)
+}
+
+// handleRegistryCADelete removes the CA bundle of a registry
+func (s *Server) handleRegistryCADelete(c *gin.Context) {
+	registry := c.Param("registry")
+	if err := s.agent.(agent.RegistryCAManager).DeleteRegistryCA(registry); err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{"registry": registry, "by": c.ClientIP()}).Info("Registry CA removed")
+	c.Status(http.StatusNoContent)
+}
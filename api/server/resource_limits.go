@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+)
+
+// resourceLimits enforces operator configured resource ceilings on fns when they are
+// created or updated, and again at invoke time as the limits may have been lowered since.
+type resourceLimits struct {
+	limits models.ResourceLimits
+	// per app overrides, keyed by app name
+	apps map[string]models.ResourceLimits
+	ds   func() models.Datastore
+}
+
+var _ fnext.FnListener = new(resourceLimits)
+
+// WithResourceLimits sets ceilings on the memory, cpus, tmpfs size and timeouts that any fn
+// may request. appLimits, keyed by app name, override individual limits for specific apps.
+func WithResourceLimits(limits models.ResourceLimits, appLimits map[string]models.ResourceLimits) Option {
+	return func(ctx context.Context, s *Server) error {
+		if limits.IsZero() && len(appLimits) == 0 {
+			return nil
+		}
+		if s.resourceLimits == nil {
+			s.resourceLimits = &resourceLimits{ds: func() models.Datastore { return s.datastore }}
+			s.AddFnListener(s.resourceLimits)
+		}
+		s.resourceLimits.limits = limits
+		s.resourceLimits.apps = appLimits
+		return nil
+	}
+}
+
+// resourceLimitsFromEnv reads the limits for WithResourceLimits from EnvMaxFnMemory,
+// EnvMaxFnCPUs, EnvMaxFnTmpFsSize, EnvMaxFnTimeout, EnvMaxFnIdleTimeout and EnvAppResourceLimits
+func resourceLimitsFromEnv() (models.ResourceLimits, map[string]models.ResourceLimits, error) {
+	var l models.ResourceLimits
+	var err error
+
+	if v := getEnv(EnvMaxFnMemory, ""); v != "" {
+		if l.Memory, err = models.ParseMegabytes(v); err != nil {
+			return l, nil, fmt.Errorf("invalid %s: %v", EnvMaxFnMemory, err)
+		}
+	}
+	if v := getEnv(EnvMaxFnTmpFsSize, ""); v != "" {
+		if l.TmpFsSize, err = models.ParseMegabytes(v); err != nil {
+			return l, nil, fmt.Errorf("invalid %s: %v", EnvMaxFnTmpFsSize, err)
+		}
+	}
+	if v := getEnv(EnvMaxFnCPUs, ""); v != "" {
+		if err = l.CPUs.UnmarshalJSON([]byte(strconv.Quote(v))); err != nil {
+			return l, nil, fmt.Errorf("invalid %s: %v", EnvMaxFnCPUs, err)
+		}
+	}
+	l.Timeout = int32(getEnvInt(EnvMaxFnTimeout, 0))
+	l.IdleTimeout = int32(getEnvInt(EnvMaxFnIdleTimeout, 0))
+
+	var apps map[string]models.ResourceLimits
+	if v := getEnv(EnvAppResourceLimits, ""); v != "" {
+		if err = json.Unmarshal([]byte(v), &apps); err != nil {
+			return l, nil, fmt.Errorf("invalid %s: %v", EnvAppResourceLimits, err)
+		}
+	}
+	return l, apps, nil
+}
+
+func (r *resourceLimits) forApp(app *models.App) models.ResourceLimits {
+	if o, ok := r.apps[app.Name]; ok {
+		return r.limits.Override(o)
+	}
+	return r.limits
+}
+
+// check checks fn against the limits of its app when it is created or updated
+func (r *resourceLimits) check(app *models.App, fn *models.Fn) error {
+	return r.forApp(app).Check(&fn.ResourceConfig)
+}
+
+// limit is the admission check for an invoke, returning fn as it is to be run, with the cpu limit as its quota if
+// it has none
+func (r *resourceLimits) limit(app *models.App, fn *models.Fn) (*models.Fn, error) {
+	if r == nil {
+		return fn, nil
+	}
+	limits := r.forApp(app)
+	if err := limits.Check(&fn.ResourceConfig); err != nil {
+		return nil, err
+	}
+	if rc := limits.Apply(fn.ResourceConfig); rc != fn.ResourceConfig {
+		fn = fn.Clone()
+		fn.ResourceConfig = rc
+	}
+	return fn, nil
+}
+
+func (r *resourceLimits) BeforeFnCreate(ctx context.Context, fn *models.Fn) error {
+	if fn.AppID == "" {
+		return nil // fails validation
+	}
+	app, err := r.ds().GetAppByID(ctx, fn.AppID)
+	if err != nil {
+		return err
+	}
+	return r.check(app, fn)
+}
+
+func (r *resourceLimits) BeforeFnUpdate(ctx context.Context, fn *models.Fn) error {
+	old, err := r.ds().GetFnByID(ctx, fn.ID)
+	if err != nil {
+		return err
+	}
+	app, err := r.ds().GetAppByID(ctx, old.AppID)
+	if err != nil {
+		return err
+	}
+	updated := old.Clone()
+	updated.Update(fn)
+	return r.check(app, updated)
+}
+
+func (r *resourceLimits) AfterFnCreate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (r *resourceLimits) AfterFnUpdate(ctx context.Context, fn *models.Fn) error {
+	return nil
+}
+
+func (r *resourceLimits) BeforeFnDelete(ctx context.Context, fnID string) error {
+	return nil
+}
+
+func (r *resourceLimits) AfterFnDelete(ctx context.Context, fnID string) error {
+	return nil
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestResourceLimits(t *testing.T) {
+	buf := setLogBuffer()
+
+	a := &models.App{Name: "a", ID: "app_id"}
+	big := &models.App{Name: "big", ID: "big_app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID, Image: "fnproject/fn-test-utils"}
+	f.SetDefaults()
+	// created before the limits were lowered
+	hog := &models.Fn{ID: "hog_id", Name: "hog", AppID: a.ID, Image: "fnproject/fn-test-utils"}
+	hog.SetDefaults()
+	hog.Memory = 1024
+	ds := datastore.NewMockInit([]*models.App{a, big}, []*models.Fn{f, hog})
+
+	rnr, cancel := testRunner(t, ds)
+	defer cancel()
+	srv := testServer(ds, rnr, ServerTypeFull, WithResourceLimits(
+		models.ResourceLimits{Memory: 512, Timeout: 60},
+		map[string]models.ResourceLimits{"big": {Memory: 2048}},
+	))
+
+	for i, test := range []struct {
+		method        string
+		path          string
+		body          string
+		expectedCode  int
+		expectedError string
+	}{
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "ok", "image": "fnproject/fn-test-utils", "memory": "512Mi"}`, http.StatusOK, ""},
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "toobig", "image": "fnproject/fn-test-utils", "memory": "1Gi"}`, http.StatusBadRequest, "memory value 1024Mi exceeds the limit of 512Mi for this fn"},
+		{http.MethodPost, "/v2/fns", `{"app_id": "app_id", "name": "tooslow", "image": "fnproject/fn-test-utils", "timeout": 120}`, http.StatusBadRequest, "timeout value 120 exceeds the limit of 60 for this fn"},
+		// per app overrides only replace the limits they set
+		{http.MethodPost, "/v2/fns", `{"app_id": "big_app_id", "name": "big", "image": "fnproject/fn-test-utils", "memory": "1Gi"}`, http.StatusOK, ""},
+		{http.MethodPost, "/v2/fns", `{"app_id": "big_app_id", "name": "bigslow", "image": "fnproject/fn-test-utils", "timeout": 120}`, http.StatusBadRequest, "timeout value 120 exceeds the limit of 60 for this fn"},
+
+		{http.MethodPut, fmt.Sprintf("/v2/fns/%s", f.ID), `{"memory": 256}`, http.StatusOK, ""},
+		{http.MethodPut, fmt.Sprintf("/v2/fns/%s", f.ID), `{"memory": 1024}`, http.StatusBadRequest, "memory value 1024Mi exceeds the limit of 512Mi for this fn"},
+
+		// fns over the limits are rejected at admission
+		{http.MethodPost, fmt.Sprintf("/invoke/%s", hog.ID), ``, http.StatusBadRequest, "memory value 1024Mi exceeds the limit of 512Mi for this fn"},
+	} {
+		_, rec := routerRequest(t, srv.Router, test.method, test.path, bytes.NewBufferString(test.body))
+
+		if rec.Code != test.expectedCode {
+			t.Log(buf.String())
+			t.Fatalf("Test %d: Expected status code to be %d but was %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+
+		if test.expectedError != "" {
+			resp := getErrorResponse(t, rec)
+			if !strings.Contains(resp.Message, test.expectedError) {
+				t.Errorf("Test %d: Expected error message to have `%s`, but was `%s`", i, test.expectedError, resp.Message)
+			}
+		}
+		buf.Reset()
+	}
+}
+
+func TestResourceLimitsCPUQuota(t *testing.T) {
+	r := &resourceLimits{limits: models.ResourceLimits{CPUs: 1000}}
+	a := &models.App{Name: "a", ID: "app_id"}
+
+	// fns without a cpu quota are run with the limit, without changing the fn itself
+	f := &models.Fn{ID: "fn_id", AppID: a.ID}
+	limited, err := r.limit(a, f)
+	if err != nil {
+		t.Fatalf("expected a fn without a cpu quota to be admitted, got %v", err)
+	}
+	if limited.CPUs != 1000 || f.CPUs != 0 {
+		t.Errorf("expected the fn to be run with the cpu limit, got %s and %s", limited.CPUs.String(), f.CPUs.String())
+	}
+
+	f.CPUs = 500
+	if limited, err := r.limit(a, f); err != nil || limited.CPUs != 500 {
+		t.Errorf("expected the fn to keep its cpu quota, got %+v %v", limited, err)
+	}
+	f.CPUs = 2000
+	if _, err := r.limit(a, f); err == nil {
+		t.Errorf("expected a fn over the cpu limit to be rejected")
+	}
+
+	var none *resourceLimits
+	if limited, err := none.limit(a, f); err != nil || limited != f {
+		t.Errorf("expected no limits to admit the fn as it is, got %+v %v", limited, err)
+	}
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/patrickmn/go-cache"
+)
+
+// MaxCachedResponseSize is the largest response body that is cached, in bytes
+const MaxCachedResponseSize = 1024 * 1024
+
+// ResponseCacheHeader is set on responses to http triggers that cache them, to hit or miss
+const ResponseCacheHeader = "Fn-Cache"
+
+// CachedResponse is a response to an http trigger, as it was returned to the caller
+type CachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// ResponseCache stores the responses of http triggers that opt in to caching, see models.TriggerCache.
+// Implementations must be safe for concurrent use, and may be shared by servers, eg. to cache in redis.
+type ResponseCache interface {
+	// Get returns the response stored under key, if it has not expired
+	Get(ctx context.Context, key string) (*CachedResponse, bool)
+	// Set stores resp under key for ttl
+	Set(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration)
+}
+
+// WithResponseCache sets the cache used for the responses of http triggers that opt in to caching
+func WithResponseCache(rc ResponseCache) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.responseCache = rc
+		return nil
+	}
+}
+
+type memoryResponseCache struct {
+	cache      *cache.Cache
+	maxEntries int
+}
+
+// NewMemoryResponseCache returns a ResponseCache that keeps up to maxEntries responses in memory
+func NewMemoryResponseCache(maxEntries int) ResponseCache {
+	return &memoryResponseCache{
+		cache:      cache.New(cache.NoExpiration, time.Minute),
+		maxEntries: maxEntries,
+	}
+}
+
+func (m *memoryResponseCache) Get(ctx context.Context, key string) (*CachedResponse, bool) {
+	v, ok := m.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.(*CachedResponse), true
+}
+
+func (m *memoryResponseCache) Set(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) {
+	// expired entries linger until they are cleaned up, evict them first when full
+	if m.cache.ItemCount() >= m.maxEntries {
+		m.cache.DeleteExpired()
+		if m.cache.ItemCount() >= m.maxEntries {
+			return
+		}
+	}
+	m.cache.Set(key, resp, ttl)
+}
+
+// redisResponseCachePrefix prefixes the keys of the responses in redis
+const redisResponseCachePrefix = "fn:response:"
+
+// redisResponseCache keeps responses as keys of redis expiring with them, shared by the servers using the redis
+type redisResponseCache struct {
+	client *common.RedisClient
+}
+
+// NewRedisResponseCache returns a ResponseCache keeping the responses in the redis at redisURL, such as
+// redis://:password@localhost:6379/0, shared by the servers caching in it. Redis evicts responses as configured
+// by its maxmemory policy.
+func NewRedisResponseCache(redisURL string) (ResponseCache, error) {
+	client, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &redisResponseCache{client: client}, nil
+}
+
+// Get implements ResponseCache, responses that cannot be read from redis are misses
+func (r *redisResponseCache) Get(ctx context.Context, key string) (*CachedResponse, bool) {
+	reply, err := r.client.Do(ctx, "GET", redisResponseCachePrefix+key)
+	if err != nil {
+		common.Logger(ctx).WithError(err).Warn("failed to get cached response")
+		return nil, false
+	}
+	v, ok := reply.(string)
+	if !ok {
+		return nil, false
+	}
+	var resp CachedResponse
+	if err := json.Unmarshal([]byte(v), &resp); err != nil {
+		common.Logger(ctx).WithError(err).Warn("failed to decode cached response")
+		return nil, false
+	}
+	return &resp, true
+}
+
+// Set implements ResponseCache, responses that cannot be written to redis are not cached
+func (r *redisResponseCache) Set(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) {
+	v, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	ms := ttl.Nanoseconds() / int64(time.Millisecond)
+	if ms < 1 {
+		return
+	}
+	_, err = r.client.Do(ctx, "SET", redisResponseCachePrefix+key, string(v), "PX", strconv.FormatInt(ms, 10))
+	if err != nil {
+		common.Logger(ctx).WithError(err).Warn("failed to cache response")
+	}
+}
+
+// responseCacheKey identifies the response to req. The trigger and fn update times are part of the key, so that
+// responses are not served from before either was changed.
+func responseCacheKey(trigger *models.Trigger, fn *models.Fn, req *http.Request, vary []string) string {
+	h := sha256.New()
+	for _, s := range []string{
+		trigger.ID,
+		time.Time(trigger.UpdatedAt).String(),
+		time.Time(fn.UpdatedAt).String(),
+		req.Method,
+		req.URL.RequestURI(),
+	} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	for _, k := range vary {
+		h.Write([]byte(http.CanonicalHeaderKey(k) + ":" + strings.Join(req.Header[http.CanonicalHeaderKey(k)], ",")))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheable checks whether the response to req may be served from, or stored in, the cache
+func cacheable(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// sharedCacheable checks whether the Cache-Control header of a response lets shared caches store it
+func sharedCacheable(header http.Header) bool {
+	for _, v := range header["Cache-Control"] {
+		for _, directive := range strings.Split(v, ",") {
+			// directives may have arguments, eg. private="Set-Cookie"
+			name := strings.ToLower(strings.TrimSpace(strings.SplitN(directive, "=", 2)[0]))
+			if name == "no-store" || name == "private" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// writeCachedResponse writes resp to w, as it was returned to the first caller
+func writeCachedResponse(w http.ResponseWriter, req *http.Request, resp *CachedResponse) {
+	for k, vs := range resp.Header {
+		w.Header()[k] = vs
+	}
+	w.Header().Set(ResponseCacheHeader, "hit")
+	w.WriteHeader(resp.Status)
+	if req.Method != http.MethodHead {
+		w.Write(resp.Body)
+	}
+}
+
+// cacheResponseWriter keeps a copy of a trigger's response, as long as it is small enough to cache
+type cacheResponseWriter struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *cacheResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.Header().Set(ResponseCacheHeader, "miss")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	if !w.overflow {
+		if w.body.Len()+n > MaxCachedResponseSize {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(b[:n])
+		}
+	}
+	return n, err
+}
+
+// response returns the response to cache, or nil if it should not be. Only successful responses are cached, and
+// the call ID and timings are dropped as later hits do not make a call. Responses setting cookies, or that the fn
+// marks no-store or private, are meant for their caller alone and are not cached.
+func (w *cacheResponseWriter) response() *CachedResponse {
+	if w.overflow || w.status < 200 || w.status > 299 {
+		return nil
+	}
+	if len(w.Header()["Set-Cookie"]) > 0 || !sharedCacheable(w.Header()) {
+		return nil
+	}
+	header := make(http.Header, len(w.Header()))
+	for k, vs := range w.Header() {
+		switch k {
+		case "Fn-Call-Id", ResponseCacheHeader, agent.ColdStartHeader, agent.QueueTimeHeader, agent.ExecTimeHeader:
+		default:
+			header[k] = vs
+		}
+	}
+	return &CachedResponse{Status: w.status, Header: header, Body: w.body.Bytes()}
+}
+
+// serveCachedHTTPTrigger serves req from the response cache if it can, or runs invoke and caches its response
+func (s *Server) serveCachedHTTPTrigger(w http.ResponseWriter, req *http.Request, trigger *models.Trigger, fn *models.Fn,
+	invoke func(w http.ResponseWriter) error) error {
+
+	policy, err := trigger.Cache()
+	if err != nil {
+		return err
+	}
+	if policy == nil || s.responseCache == nil || !cacheable(req) {
+		return invoke(w)
+	}
+
+	ctx := req.Context()
+	key := responseCacheKey(trigger, fn, req, policy.Vary)
+	if !strings.Contains(req.Header.Get("Cache-Control"), "no-cache") {
+		if resp, ok := s.responseCache.Get(ctx, key); ok {
+			writeCachedResponse(w, req, resp)
+			return nil
+		}
+	}
+
+	cw := &cacheResponseWriter{ResponseWriter: w}
+	err = invoke(cw)
+	if err != nil {
+		return err
+	}
+	if resp := cw.response(); resp != nil {
+		s.responseCache.Set(ctx, key, resp, policy.Duration())
+		common.Logger(ctx).WithField("ttl", policy.TTL).Debug("cached trigger response")
+	}
+	return nil
+}
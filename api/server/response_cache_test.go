@@ -0,0 +1,278 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestServeCachedHTTPTrigger(t *testing.T) {
+	s := &Server{responseCache: NewMemoryResponseCache(10)}
+	fn := &models.Fn{ID: "fn_id"}
+	trigger := &models.Trigger{ID: "trigger_id", FnID: fn.ID}
+	trigger.Annotations, _ = trigger.Annotations.With(models.TriggerCacheAnnotation, models.TriggerCache{TTL: 60, Vary: []string{"accept"}})
+
+	calls := 0
+	status := http.StatusOK
+	invoke := func(w http.ResponseWriter) error {
+		calls++
+		w.Header().Set("Fn-Call-Id", "call_id")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(status)
+		w.Write([]byte("hello"))
+		return nil
+	}
+
+	for i, test := range []struct {
+		method        string
+		path          string
+		accept        string
+		cacheControl  string
+		status        int
+		expectedCalls int
+		expectedCache string
+	}{
+		{http.MethodGet, "/t/app/hook", "text/plain", "", http.StatusOK, 1, "miss"},
+		{http.MethodGet, "/t/app/hook", "text/plain", "", http.StatusOK, 1, "hit"},
+		// vary headers and queries are part of the key
+		{http.MethodGet, "/t/app/hook", "application/json", "", http.StatusOK, 2, "miss"},
+		{http.MethodGet, "/t/app/hook?page=2", "text/plain", "", http.StatusOK, 3, "miss"},
+		// callers can skip the cache
+		{http.MethodGet, "/t/app/hook", "text/plain", "no-cache", http.StatusOK, 4, "miss"},
+		// only idempotent requests are cached
+		{http.MethodPost, "/t/app/hook", "text/plain", "", http.StatusOK, 5, ""},
+		{http.MethodPost, "/t/app/hook", "text/plain", "", http.StatusOK, 6, ""},
+		// and only successful responses
+		{http.MethodGet, "/t/app/error", "text/plain", "", http.StatusBadGateway, 7, "miss"},
+		{http.MethodGet, "/t/app/error", "text/plain", "", http.StatusBadGateway, 8, "miss"},
+	} {
+		status = test.status
+		req := httptest.NewRequest(test.method, test.path, nil)
+		req.Header.Set("Accept", test.accept)
+		if test.cacheControl != "" {
+			req.Header.Set("Cache-Control", test.cacheControl)
+		}
+		rec := httptest.NewRecorder()
+
+		if err := s.serveCachedHTTPTrigger(rec, req, trigger, fn, invoke); err != nil {
+			t.Fatalf("Test %d: unexpected error: %v", i, err)
+		}
+		if calls != test.expectedCalls {
+			t.Errorf("Test %d: expected %d calls, got %d", i, test.expectedCalls, calls)
+		}
+		if got := rec.Header().Get(ResponseCacheHeader); got != test.expectedCache {
+			t.Errorf("Test %d: expected cache header %q, got %q", i, test.expectedCache, got)
+		}
+		if rec.Code != test.status || rec.Body.String() != "hello" || rec.Header().Get("Content-Type") != "text/plain" {
+			t.Errorf("Test %d: unexpected response %d %v %q", i, rec.Code, rec.Header(), rec.Body.String())
+		}
+		if test.expectedCache == "hit" && rec.Header().Get("Fn-Call-Id") != "" {
+			t.Errorf("Test %d: expected no call id on a cached response", i)
+		}
+	}
+
+	// updating the trigger drops its cached responses
+	trigger.UpdatedAt = common.DateTime(time.Now())
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/t/app/hook", nil)
+	req.Header.Set("Accept", "text/plain")
+	if err := s.serveCachedHTTPTrigger(rec, req, trigger, fn, invoke); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Header().Get(ResponseCacheHeader); got != "miss" {
+		t.Errorf("expected a miss after updating the trigger, got %q", got)
+	}
+
+	// responses meant for their caller alone are not cached
+	for i, header := range []http.Header{
+		{"Set-Cookie": {"session=abc"}},
+		{"Cache-Control": {"no-store"}},
+		{"Cache-Control": {"max-age=60, Private"}},
+		{"Cache-Control": {`private="Set-Cookie"`}},
+	} {
+		private := &models.Trigger{ID: "private_id", FnID: fn.ID, Annotations: trigger.Annotations}
+		calls = 0
+		invokePrivate := func(w http.ResponseWriter) error {
+			calls++
+			for k, vs := range header {
+				w.Header()[k] = vs
+			}
+			w.Write([]byte("hello"))
+			return nil
+		}
+		for j := 0; j < 2; j++ {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/t/app/private", nil)
+			if err := s.serveCachedHTTPTrigger(rec, req, private, fn, invokePrivate); err != nil {
+				t.Fatal(err)
+			}
+			if got := rec.Header().Get(ResponseCacheHeader); got != "miss" {
+				t.Errorf("Test %d: expected a miss for a response with %v, got %q", i, header, got)
+			}
+		}
+		if calls != 2 {
+			t.Errorf("Test %d: expected a response with %v not to be cached, got %d calls", i, header, calls)
+		}
+	}
+}
+
+func TestMemoryResponseCache(t *testing.T) {
+	ctx := context.Background()
+	rc := NewMemoryResponseCache(2)
+	resp := &CachedResponse{Status: http.StatusOK}
+
+	rc.Set(ctx, "a", resp, time.Millisecond)
+	rc.Set(ctx, "b", resp, time.Minute)
+	rc.Set(ctx, "c", resp, time.Minute)
+	if _, ok := rc.Get(ctx, "c"); ok {
+		t.Error("expected the cache to be full")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := rc.Get(ctx, "a"); ok {
+		t.Error("expected a to have expired")
+	}
+	rc.Set(ctx, "c", resp, time.Minute)
+	if _, ok := rc.Get(ctx, "c"); !ok {
+		t.Error("expected c to replace the expired entry")
+	}
+}
+
+// fakeResponseRedis serves GET and SET ... PX of redis, expiring keys as redis does
+type fakeResponseRedis struct {
+	lock    sync.Mutex
+	keys    map[string]string
+	expires map[string]time.Time
+	conns   int
+}
+
+func (f *fakeResponseRedis) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		f.lock.Lock()
+		f.conns++
+		f.lock.Unlock()
+		go f.handle(conn)
+	}
+}
+
+// readCommand reads a command sent as a RESP array of bulk strings
+func (f *fakeResponseRedis) readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = rd.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeResponseRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		args, err := f.readCommand(rd)
+		if err != nil {
+			return
+		}
+		f.lock.Lock()
+		resp := "-ERR unknown command\r\n"
+		switch {
+		case args[0] == "GET":
+			resp = "$-1\r\n"
+			if v, ok := f.keys[args[1]]; ok && time.Now().Before(f.expires[args[1]]) {
+				resp = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			}
+		case args[0] == "SET" && len(args) == 5 && args[3] == "PX":
+			ms, _ := strconv.Atoi(args[4])
+			f.keys[args[1]] = args[2]
+			f.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			resp = "+OK\r\n"
+		}
+		f.lock.Unlock()
+		conn.Write([]byte(resp))
+	}
+}
+
+func TestRedisResponseCache(t *testing.T) {
+	ctx := context.Background()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	srv := &fakeResponseRedis{keys: make(map[string]string), expires: make(map[string]time.Time)}
+	go srv.serve(l)
+
+	if _, err := NewRedisResponseCache("http://" + l.Addr().String()); err == nil {
+		t.Fatal("expected urls of schemes other than redis to be invalid")
+	}
+	rc, err := NewRedisResponseCache("redis://" + l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &CachedResponse{Status: http.StatusOK, Header: http.Header{"Content-Type": {"application/octet-stream"}}, Body: []byte{0, 1, '\r', '\n', 0xff}}
+	rc.Set(ctx, "a", resp, time.Millisecond)
+	rc.Set(ctx, "b", resp, time.Minute)
+	if _, ok := srv.keys[redisResponseCachePrefix+"b"]; !ok {
+		t.Fatalf("expected the response to be kept under its prefixed key, got %v", srv.keys)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := rc.Get(ctx, "a"); ok {
+		t.Error("expected a to have expired")
+	}
+	got, ok := rc.Get(ctx, "b")
+	if !ok {
+		t.Fatal("expected b to be cached")
+	}
+	if got.Status != resp.Status || got.Header.Get("Content-Type") != "application/octet-stream" || string(got.Body) != string(resp.Body) {
+		t.Fatalf("expected the cached response back, got %+v", got)
+	}
+	if srv.conns != 1 {
+		t.Errorf("expected the commands to share a connection, got %d", srv.conns)
+	}
+
+	// a cache that cannot be reached misses
+	l.Close()
+	down, err := NewRedisResponseCache("redis://" + l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := down.Get(ctx, "b"); ok {
+		t.Error("expected a cache that is down to miss")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/fnproject/fn/api"
@@ -88,13 +89,69 @@ func (s *Server) ServeFnInvoke(c *gin.Context, app *models.App, fn *models.Fn) e
 }
 
 func (s *Server) fnInvoke(resp http.ResponseWriter, req *http.Request, app *models.App, fn *models.Fn, trig *models.Trigger) error {
+	if err := s.checkEnabled(fn, trig); err != nil {
+		return err
+	}
+
+	// limits may have been lowered since the fn was last updated
+	fn, err := s.resourceLimits.limit(app, fn)
+	if err != nil {
+		return err
+	}
+
+	// clients expecting 100 Continue upload the body once it is first read, so calls the agent has no capacity for
+	// are turned away before then
+	if a, ok := s.agent.(agent.Admitter); ok && expectsContinue(req) {
+		if err := a.Admit(req.Context(), fn, req.ContentLength); err != nil {
+			return err
+		}
+	}
+
+	isDetached := req.Header.Get("Fn-Invoke-Type") == models.TypeDetached
+
+	// triggers opt in to having their runs recorded
+	var runs *models.TriggerRuns
+	if trig != nil && s.triggerRuns != nil {
+		if runs, err = trig.Runs(); err != nil {
+			return err
+		}
+	}
+	payload, replayable := s.triggerRuns.capture(req, runs)
+	// detached calls are always persisted, sync calls if sampled
+	capture, err := fn.Annotations.Capture()
+	if err != nil {
+		return err
+	}
+	persisted := isDetached || s.asyncCalls.sample(capture)
+	input, inputReplayable := []byte(nil), false
+	if persisted {
+		input, inputReplayable = s.asyncCalls.capture(req, capture.Size())
+	}
+
 	// TODO: we should get rid of the buffers, and stream back (saves memory (+splice), faster (splice), allows streaming, don't have to cap resp size)
 	// buffer the response before writing it out to client to prevent partials from trying to stream
 	buf := bufPool.Get().(*bytes.Buffer)
 	buf.Reset()
 	var writer ResponseBuffer
 
-	isDetached := req.Header.Get("Fn-Invoke-Type") == models.TypeDetached
+	// long running calls have their own class, they are not to hold sync requests open for hours
+	if longRunning, err := fn.Annotations.LongRunning(); err != nil {
+		return err
+	} else if longRunning != nil && !isDetached {
+		return models.ErrCallLongRunningSync
+	}
+
+	payloadKeys, err := s.payloads.offload(req.Context(), req, isDetached)
+	if err != nil {
+		return err
+	}
+
+	// detached calls are run by the servers consuming the message queue, if there is one, calls consumed from it here
+	queued, _ := req.Context().Value(queuedCallKey{}).(*models.CallMessage)
+	if isDetached && queued == nil && s.callQueue != nil && s.asyncCalls != nil {
+		return s.queueCall(resp, req, app, fn, trig, input, inputReplayable, capture)
+	}
+
 	if isDetached {
 		writer = agent.NewDetachedResponseWriter(resp.Header(), 202)
 	} else {
@@ -105,6 +162,9 @@ func (s *Server) fnInvoke(resp http.ResponseWriter, req *http.Request, app *mode
 		}
 	}
 	opts := getCallOptions(req, app, fn, trig, writer)
+	if queued != nil {
+		opts = append(opts, agent.WithCallID(queued.CallID))
+	}
 
 	call, err := s.agent.GetCall(opts...)
 	if err != nil {
@@ -114,13 +174,45 @@ func (s *Server) fnInvoke(resp http.ResponseWriter, req *http.Request, app *mode
 	// add this before submit, always tie a call id to the response at this point
 	writer.Header().Add("Fn-Call-Id", call.Model().ID)
 
+	// queued calls were recorded as they were queued
+	if persisted && queued == nil {
+		if err := s.asyncCalls.enqueue(req.Context(), req, call.Model(), input, inputReplayable, capture); err != nil {
+			if isDetached {
+				return err
+			}
+			// sampling is for debugging, it must not fail the call
+			common.Logger(req.Context()).WithError(err).Error("failed to record sampled call")
+			persisted = false
+		}
+	}
+
+	if runs != nil {
+		s.triggerRuns.start(req.Context(), req, trig, runs, call.Model(), payload, replayable)
+	}
+
 	err = s.agent.Submit(call)
 	if err != nil {
+		if persisted {
+			s.asyncCalls.abort(common.BackgroundContext(req.Context()), call.Model(), err)
+		}
+		if runs != nil {
+			s.triggerRuns.abort(common.BackgroundContext(req.Context()), call.Model(), err)
+		}
 		return err
 	}
 
+	var output io.ReadCloser
+	if !isDetached {
+		output, err = s.payloads.respond(req.Context(), writer, buf, payloadKeys, trig != nil)
+		if err != nil {
+			return err
+		}
+	}
+
 	// because we can...
-	writer.Header().Set("Content-Length", strconv.Itoa(int(buf.Len())))
+	if output == nil {
+		writer.Header().Set("Content-Length", strconv.Itoa(int(buf.Len())))
+	}
 
 	// buffered response writer traps status (so we can add headers), we need to write it still
 	if writer.Status() > 0 {
@@ -131,11 +223,22 @@ func (s *Server) fnInvoke(resp http.ResponseWriter, req *http.Request, app *mode
 		return nil
 	}
 
-	io.Copy(resp, buf)
+	if output != nil {
+		io.Copy(resp, output)
+		output.Close()
+	} else {
+		io.Copy(resp, buf)
+	}
 	bufPool.Put(buf) // at this point, submit returned without timing out, so we can re-use this one
 	return nil
 }
 
+// expectsContinue returns whether the client of req waits for 100 Continue before uploading its body, which
+// net/http sends once the body is first read
+func expectsContinue(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Expect"), "100-continue") && req.ContentLength != 0
+}
+
 func getCallOptions(req *http.Request, app *models.App, fn *models.Fn, trig *models.Trigger, rw http.ResponseWriter) []agent.CallOpt {
 	var opts []agent.CallOpt
 	opts = append(opts, agent.WithWriter(rw)) // XXX (reed): order matters [for now]
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// WithInvokeBatchParallelism sets the most calls of a batch invoke that run at once, batches may ask for fewer
+func WithInvokeBatchParallelism(max int) Option {
+	return func(ctx context.Context, s *Server) error {
+		if max < 1 {
+			return models.ErrInvalidInvokeBatchParallelism
+		}
+		s.invokeBatchParallelism = max
+		return nil
+	}
+}
+
+type invokeBatchResponse struct {
+	Items []invokeBatchItem `json:"items"`
+}
+
+// invokeBatchItem is the result of the call of a payload of a batch, the response of the fn or the error the call
+// failed with, as the invoke endpoint would have responded to it
+type invokeBatchItem struct {
+	Status  int         `json:"status"`
+	CallID  string      `json:"call_id,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body"`
+}
+
+// handleFnInvokeBatch calls a fn once for each payload of a JSON array, up to parallelism calls at a time, and
+// responds with the result of each call in the order of the payloads. Payloads are passed to the fn as JSON, with
+// the headers of the batch request. As calls only run in as many containers as run at once, a batch run one at a
+// time reuses a single hot container.
+func (s *Server) handleFnInvokeBatch(c *gin.Context) {
+	fnID := c.Param(api.FnID)
+	ctx, log := common.LoggerWithFields(c.Request.Context(), logrus.Fields{"fn_id": fnID})
+
+	parallelism := s.invokeBatchParallelism
+	if parallelism == 0 {
+		parallelism = DefaultInvokeBatchParallelism
+	}
+	if v := c.Query("parallelism"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			handleErrorResponse(c, models.ErrInvalidInvokeBatchParallelism)
+			return
+		}
+		if n < parallelism {
+			parallelism = n
+		}
+	}
+
+	var payloads []json.RawMessage
+	if err := json.NewDecoder(c.Request.Body).Decode(&payloads); err != nil || len(payloads) == 0 || len(payloads) > models.MaxInvokeBatchItems {
+		handleErrorResponse(c, models.ErrInvalidInvokeBatch)
+		return
+	}
+
+	fn, err := s.lbReadAccess.GetFnByID(ctx, fnID)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	app, err := s.lbReadAccess.GetAppByID(ctx, fn.AppID)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	items := make([]invokeBatchItem, len(payloads))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range payloads {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			items[i] = s.invokeBatchItem(ctx, c.Request, app, fn, payloads[i])
+		}(i)
+	}
+	wg.Wait()
+
+	log.WithFields(logrus.Fields{"items": len(items), "parallelism": parallelism}).Debug("batch invoked")
+	c.JSON(http.StatusOK, invokeBatchResponse{Items: items})
+}
+
+// invokeBatchItem makes the call of a payload of the batch request req
+func (s *Server) invokeBatchItem(ctx context.Context, req *http.Request, app *models.App, fn *models.Fn, payload []byte) invokeBatchItem {
+	itemReq, err := http.NewRequest(http.MethodPost, "/invoke/"+fn.ID, bytes.NewReader(payload))
+	if err != nil {
+		return invokeBatchItem{Status: http.StatusInternalServerError, Body: err.Error()}
+	}
+	itemReq = itemReq.WithContext(ctx)
+	for k, v := range req.Header {
+		itemReq.Header[k] = v
+	}
+	itemReq.Header.Del("Content-Length")
+	itemReq.Header.Del("Content-Encoding")
+	itemReq.Header.Set("Content-Type", "application/json")
+	itemReq.RemoteAddr = req.RemoteAddr
+
+	writer := &syncResponseWriter{headers: make(http.Header), Buffer: new(bytes.Buffer)}
+	if err := s.fnInvoke(writer, itemReq, app, fn, nil); err != nil {
+		// the call may have failed after its response was written, which is dropped for the error
+		writer.Reset()
+		HandleErrorResponse(ctx, writer, err)
+	}
+	if writer.status == 0 {
+		writer.status = http.StatusOK
+	}
+	return invokeBatchItem{
+		Status:  writer.status,
+		CallID:  writer.headers.Get("Fn-Call-Id"),
+		Headers: writer.headers,
+		Body:    writer.String(),
+	}
+}
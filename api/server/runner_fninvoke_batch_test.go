@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/agent/drivers/mock"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestFnInvokeBatch(t *testing.T) {
+	cfg, err := agent.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOFSAgentPath, err = ioutil.TempDir("", "iofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.IOFSAgentPath)
+
+	script := &mock.Script{Images: map[string]*mock.ImageScript{
+		mock.ScriptDefault: {LatencyMsecs: 5},
+	}}
+	a := agent.New(agent.WithConfig(cfg), agent.WithDockerDriver(mock.NewScripted(script)))
+	defer a.Close()
+
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", Name: "myfn", AppID: app.ID, Image: "fnproject/fn-test-utils",
+		ResourceConfig: models.ResourceConfig{Memory: 128, Timeout: 30, IdleTimeout: 30}}
+	disabled := true
+	off := &models.Fn{ID: "off_id", Name: "off", AppID: app.ID, Image: "fnproject/fn-test-utils", Disabled: &disabled,
+		ResourceConfig: models.ResourceConfig{Memory: 128, Timeout: 30, IdleTimeout: 30}}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn, off})
+	srv := testServer(ds, a, ServerTypeFull, WithInvokeBatchParallelism(2))
+
+	batch := func(path, body string) (*invokeBatchResponse, int) {
+		_, rec := routerRequest2(t, srv.Router, createRequest(t, http.MethodPost, path, strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			return nil, rec.Code
+		}
+		var resp invokeBatchResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return &resp, rec.Code
+	}
+
+	resp, code := batch("/invoke/fn_id/batch?parallelism=8", `[{"n": 1}, "two", 3]`)
+	if code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if len(resp.Items) != 3 {
+		t.Fatalf("expected a result for each payload, got %+v", resp.Items)
+	}
+	for i, expected := range []string{`{"n": 1}`, `"two"`, `3`} {
+		item := resp.Items[i]
+		if item.Status != http.StatusOK || item.CallID == "" || item.Body != expected {
+			t.Errorf("item %d: expected the echo of %s, got %+v", i, expected, item)
+		}
+	}
+	if resp.Items[0].CallID == resp.Items[1].CallID {
+		t.Errorf("expected a call for each payload, got %s twice", resp.Items[0].CallID)
+	}
+
+	resp, code = batch("/invoke/off_id/batch", `[1]`)
+	if code != http.StatusOK || len(resp.Items) != 1 || resp.Items[0].Status != http.StatusServiceUnavailable ||
+		!strings.Contains(resp.Items[0].Body, models.ErrFnDisabled.Error()) {
+		t.Errorf("expected the error of the call in its result, got %d %+v", code, resp)
+	}
+
+	for _, test := range []struct {
+		path string
+		body string
+		code int
+	}{
+		{"/invoke/fn_id/batch", `[]`, http.StatusBadRequest},
+		{"/invoke/fn_id/batch", `{"n": 1}`, http.StatusBadRequest},
+		{"/invoke/fn_id/batch?parallelism=0", `[1]`, http.StatusBadRequest},
+		{"/invoke/nope/batch", `[1]`, http.StatusNotFound},
+	} {
+		if _, code := batch(test.path, test.body); code != test.code {
+			t.Errorf("%s %s: expected status %d, got %d", test.path, test.body, test.code, code)
+		}
+	}
+}
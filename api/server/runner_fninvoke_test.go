@@ -1,11 +1,14 @@
 package server
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -141,7 +144,7 @@ func TestFnInvokeRunnerExecution(t *testing.T) {
 	app := &models.App{ID: "app_id", Name: "myapp"}
 
 	models.MaxMemory = uint64(1024 * 1024 * 1024) // 1024 TB
-	hugeMem := uint64(models.MaxMemory - 1)
+	hugeMem := models.Megabytes(models.MaxMemory - 1)
 
 	// quickly exit with exit code 0 without serving http/uds, or sleep 20 secs, then exit.. Two failure scenarios.
 	failQuickCfg := map[string]string{"ENABLE_INIT_EXIT": "0"}
@@ -441,3 +444,96 @@ func TestInvokeRunnerMinimalConcurrentHotSync(t *testing.T) {
 		}
 	}
 }
+
+func TestFnInvokeLongRunning(t *testing.T) {
+	buf := setLogBuffer()
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", AppID: app.ID, Name: "batch", Image: "fnproject/fn-test-utils", ResourceConfig: models.ResourceConfig{Timeout: 3600}}
+	fn.Annotations, _ = models.Annotations{}.With(models.FnLongRunningAnnotation, map[string]int{"liveness_interval": 60})
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn})
+	srv := testServer(ds, &workflowAgent{}, ServerTypeFull)
+
+	for i, test := range []struct {
+		invokeType string
+		refused    bool
+	}{
+		{"", true},
+		{models.TypeSync, true},
+		{models.TypeDetached, false},
+	} {
+		request := createRequest(t, http.MethodPost, "/invoke/fn_id", strings.NewReader("{}"))
+		if test.invokeType != "" {
+			request.Header.Set("Fn-Invoke-Type", test.invokeType)
+		}
+		_, rec := routerRequest2(t, srv.Router, request)
+
+		refused := rec.Code == http.StatusBadRequest && strings.Contains(rec.Body.String(), models.ErrCallLongRunningSync.Error())
+		if refused != test.refused {
+			t.Log(buf.String())
+			t.Fatalf("Test %d: Expected the call refused to be %v but got %d: %s", i, test.refused, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// admitAgent admits calls unless busy
+type admitAgent struct {
+	workflowAgent
+	busy bool
+}
+
+func (a *admitAgent) Admit(ctx context.Context, fn *models.Fn, size int64) error {
+	if a.busy {
+		return models.ErrCallTimeoutServerBusy
+	}
+	return nil
+}
+
+// readFlagger records whether it was read
+type readFlagger struct {
+	io.Reader
+	read bool
+}
+
+func (r *readFlagger) Read(p []byte) (int, error) {
+	r.read = true
+	return r.Reader.Read(p)
+}
+
+func TestFnInvokeExpectContinue(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	fn := &models.Fn{ID: "fn_id", AppID: app.ID, Name: "myfn", Image: "fnproject/fn-test-utils"}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{fn})
+	a := &admitAgent{busy: true}
+	srv := testServer(ds, a, ServerTypeFull)
+
+	for i, test := range []struct {
+		expect       bool
+		encoding     string
+		busy         bool
+		expectedCode int
+	}{
+		{true, "", true, http.StatusServiceUnavailable},
+		{true, "gzip", true, http.StatusServiceUnavailable},
+		{true, "", false, http.StatusOK},
+		// calls are only turned away early for clients waiting to upload their body
+		{false, "", true, http.StatusOK},
+	} {
+		a.busy = test.busy
+		body := &readFlagger{Reader: strings.NewReader(strings.Repeat("a", 1024))}
+		request := httptest.NewRequest(http.MethodPost, "/invoke/fn_id", body)
+		request.ContentLength = 1024
+		if test.expect {
+			request.Header.Set("Expect", "100-continue")
+		}
+		if test.encoding != "" {
+			request.Header.Set("Content-Encoding", test.encoding)
+		}
+		_, rec := routerRequest2(t, srv.Router, request)
+		if rec.Code != test.expectedCode {
+			t.Fatalf("Test %d: expected status %d, got %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+		if test.expectedCode == http.StatusServiceUnavailable && body.read {
+			t.Errorf("Test %d: expected the body of a call turned away not to be read", i)
+		}
+	}
+}
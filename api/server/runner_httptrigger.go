@@ -2,11 +2,14 @@ package server
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/agent"
 	"github.com/fnproject/fn/api/common"
 	"github.com/fnproject/fn/api/models"
 	"github.com/gin-gonic/gin"
@@ -24,14 +27,16 @@ func (s *Server) handleHTTPTriggerCall(c *gin.Context) {
 // handleTriggerHTTPFunctionCall2 executes the function and returns an error
 // Requires the following in the context:
 func (s *Server) handleTriggerHTTPFunctionCall2(c *gin.Context) error {
-	ctx := c.Request.Context()
 	p := c.Param(api.TriggerSource)
 	if p == "" {
 		p = "/"
 	}
+	return s.serveHTTPTriggerSource(c, c.Param(api.AppName), p)
+}
 
-	appName := c.Param(api.AppName)
-
+// serveHTTPTriggerSource executes the function of the http trigger of app appName with source p
+func (s *Server) serveHTTPTriggerSource(c *gin.Context, appName, p string) error {
+	ctx := c.Request.Context()
 	appID, err := s.lbReadAccess.GetAppID(ctx, appName)
 	if err != nil {
 		return err
@@ -42,6 +47,14 @@ func (s *Server) handleTriggerHTTPFunctionCall2(c *gin.Context) error {
 		return err
 	}
 
+	// apps in maintenance respond for all of their triggers, without them being looked up
+	if m, err := app.Annotations.Maintenance(); err != nil {
+		return err
+	} else if m != nil {
+		serveMaintenance(c.Writer, m)
+		return nil
+	}
+
 	routePath := p
 
 	trigger, err := s.lbReadAccess.GetTriggerBySource(ctx, appID, "http", routePath)
@@ -75,6 +88,10 @@ func (s *Server) handleTriggerHTTPFunctionCall2(c *gin.Context) error {
 type triggerResponseWriter struct {
 	inner     http.ResponseWriter
 	committed bool
+	transform *models.TriggerResponseTransform
+	// filters are the header filters of the server and trigger the response headers must pass
+	filters []*models.HeaderFilter
+	body    io.Writer
 }
 
 func (trw *triggerResponseWriter) Header() http.Header {
@@ -85,6 +102,9 @@ func (trw *triggerResponseWriter) Write(b []byte) (int, error) {
 	if !trw.committed {
 		trw.WriteHeader(http.StatusOK)
 	}
+	if trw.body != nil {
+		return trw.body.Write(b)
+	}
 	return trw.inner.Write(b)
 }
 
@@ -111,11 +131,16 @@ func (trw *triggerResponseWriter) WriteHeader(serviceStatus int) {
 					userStatus = statusInt
 				}
 			}
-		case k == "Content-Type", k == "Fn-Call-Id":
+		case k == "Content-Type", k == "Fn-Call-Id",
+			k == agent.ColdStartHeader, k == agent.QueueTimeHeader, k == agent.ExecTimeHeader:
 			gwHeaders[k] = vs
 		}
 	}
 
+	// the fn may not set transport headers, nor the headers filtered out
+	common.StripHopHeaders(gwHeaders)
+	filterHeaders(gwHeaders, trw.filters...)
+
 	// XXX(reed): this is O(3n)... yes sorry for making it work without making it perfect first
 	for k := range realHeaders {
 		realHeaders.Del(k)
@@ -124,6 +149,13 @@ func (trw *triggerResponseWriter) WriteHeader(serviceStatus int) {
 		realHeaders[k] = vs
 	}
 
+	if trw.transform != nil {
+		applyResponseTransform(realHeaders, trw.transform)
+		if trw.transform.Base64Body {
+			trw.body = &base64DecodeWriter{w: trw.inner}
+		}
+	}
+
 	// XXX(reed): simplify / add tests for these behaviors...
 	finalStatus := 200
 	if serviceStatus >= 400 {
@@ -152,12 +184,49 @@ func reqURL(req *http.Request) string {
 // ServeHTTPTrigger serves an HTTP trigger for a given app/fn/trigger based on the current request
 // This is exported to allow extensions to handle their own trigger naming and publishing
 func (s *Server) ServeHTTPTrigger(c *gin.Context, app *models.App, fn *models.Fn, trigger *models.Trigger) error {
+	start := time.Now()
+	body := &countingReader{ReadCloser: c.Request.Body}
+	if c.Request.Body != nil {
+		c.Request.Body = body
+	}
+
+	// disabled triggers do not serve their cached responses either
+	err := s.checkEnabled(fn, trigger)
+	if err == nil {
+		err = s.serveCachedHTTPTrigger(c.Writer, c.Request, trigger, fn, func(w http.ResponseWriter) error {
+			return s.invokeHTTPTrigger(w, c.Request, app, fn, trigger)
+		})
+	}
+	s.recordTrigger(c, app, trigger, body.n, start, err)
+	return err
+}
+
+// invokeHTTPTrigger invokes fn with req, writing its response to w
+func (s *Server) invokeHTTPTrigger(w http.ResponseWriter, req *http.Request, app *models.App, fn *models.Fn, trigger *models.Trigger) error {
 	// transpose trigger headers into the request
-	req := c.Request
 	headers := make(http.Header, len(req.Header))
 
-	// remove transport headers before decorating headers
+	tf, err := trigger.Transform()
+	if err != nil {
+		return err
+	}
+	hp, err := trigger.Headers()
+	if err != nil {
+		return err
+	}
+	if hp == nil {
+		hp = &models.HeaderPolicy{}
+	}
+
+	// remove transport headers, and the headers filtered out, before decorating headers
 	common.StripHopHeaders(req.Header)
+	filterHeaders(req.Header, s.headerPolicy.Request, hp.Request)
+
+	if tf != nil && tf.Request != nil {
+		if body := applyRequestTransform(req, tf.Request); body != nil {
+			defer body.Close()
+		}
+	}
 
 	for k, vs := range req.Header {
 		switch k {
@@ -171,11 +240,15 @@ func (s *Server) ServeHTTPTrigger(c *gin.Context, app *models.App, fn *models.Fn
 
 	headers.Set("Fn-Http-Method", req.Method)
 	headers.Set("Fn-Http-Request-Url", requestURL)
+	s.setClientHeaders(headers, req)
 	headers.Set("Fn-Intent", "httprequest")
 	req.Header = headers
 
 	// trap the headers and rewrite them for http trigger
-	rw := &triggerResponseWriter{inner: c.Writer}
+	rw := &triggerResponseWriter{inner: w, filters: []*models.HeaderFilter{s.headerPolicy.Response, hp.Response}}
+	if tf != nil {
+		rw.transform = tf.Response
+	}
 
 	return s.fnInvoke(rw, req, app, fn, trigger)
 }
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrRunnerTokenInvalid is returned for runner requests without one of the runner tokens of the server
+	ErrRunnerTokenInvalid = models.NewAPIError(http.StatusUnauthorized, errors.New("Missing or invalid runner token"))
+	// ErrRunnerTokenNotFound is returned for revoking a runner token the server does not have
+	ErrRunnerTokenNotFound = models.NewAPIError(http.StatusNotFound, errors.New("Runner token not found"))
+	// ErrRunnerTokenLast is returned for revoking the last runner token of the server
+	ErrRunnerTokenLast = models.NewAPIError(http.StatusConflict, errors.New("The last runner token cannot be revoked, add another one first"))
+	// ErrRunnerTokenMissing is returned for adding an empty runner token
+	ErrRunnerTokenMissing = models.NewAPIError(http.StatusBadRequest, errors.New("Missing runner token"))
+	// ErrRunnerTokensNotSaved is returned for changes to the runner tokens that are in effect, but could not be saved
+	ErrRunnerTokensNotSaved = models.NewAPIError(http.StatusInternalServerError, errors.New("Runner tokens changed, but could not be saved, the change is lost when the server restarts"))
+)
+
+type runnerTokensResponse struct {
+	Items []agent.RunnerToken `json:"items"`
+}
+
+type addRunnerTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// WithRunnerTokens sets the runner tokens authenticating the nodes of a hybrid deployment, the last of which the
+// server presents to its peers. Tokens are issued, added, promoted and revoked at runtime through the admin server,
+// these changes are lost when the server restarts unless they are saved, see WithRunnerTokensFile.
+func WithRunnerTokens(tokens ...string) Option {
+	return func(ctx context.Context, s *Server) error {
+		for _, token := range tokens {
+			s.runnerTokens.Promote(s.runnerTokens.Add(token).ID)
+		}
+		return nil
+	}
+}
+
+// WithRunnerTokensFile saves the runner tokens of the server to the file at path as they are changed through the
+// admin server, and loads them back from it as the server starts. The token the server presented when they were
+// saved is presented again, over those set by WithRunnerTokens, which it keeps accepting.
+func WithRunnerTokensFile(path string) Option {
+	return func(ctx context.Context, s *Server) error {
+		if path == "" {
+			return nil
+		}
+		if err := s.runnerTokens.Load(path); err != nil {
+			return err
+		}
+		s.runnerTokensFile = path
+		return nil
+	}
+}
+
+// saveRunnerTokens saves the runner tokens of the server after they changed, if it keeps them in a file. The change
+// is in effect even if it could not be saved.
+func (s *Server) saveRunnerTokens(c *gin.Context) bool {
+	if s.runnerTokensFile == "" {
+		return true
+	}
+	if err := s.runnerTokens.Save(s.runnerTokensFile); err != nil {
+		logrus.WithError(err).WithField("file", s.runnerTokensFile).Error("Failed to save runner tokens")
+		handleErrorResponse(c, ErrRunnerTokensNotSaved)
+		return false
+	}
+	return true
+}
+
+// requireRunnerToken aborts requests without one of the runner tokens of the server, if it has any
+func (s *Server) requireRunnerToken(c *gin.Context) {
+	if !s.runnerTokens.Valid(c.GetHeader(agent.RunnerTokenKey)) {
+		handleErrorResponse(c, ErrRunnerTokenInvalid)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// handleRunnerTokenList lists the runner tokens of the server, without the tokens themselves
+func (s *Server) handleRunnerTokenList(c *gin.Context) {
+	c.JSON(http.StatusOK, runnerTokensResponse{Items: s.runnerTokens.List()})
+}
+
+// handleRunnerTokenIssue issues a new runner token, which the server accepts from now on. It must be added to the
+// other nodes of the deployment, then promoted on all of them.
+func (s *Server) handleRunnerTokenIssue(c *gin.Context) {
+	token, err := s.runnerTokens.Issue()
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	if !s.saveRunnerTokens(c) {
+		return
+	}
+	logrus.WithFields(logrus.Fields{"token_id": token.ID, "by": c.ClientIP()}).Info("Runner token issued")
+	c.JSON(http.StatusCreated, token)
+}
+
+// handleRunnerTokenAdd adds a runner token issued by another node, which the server accepts from now on
+func (s *Server) handleRunnerTokenAdd(c *gin.Context) {
+	var req addRunnerTokenRequest
+	if err := c.BindJSON(&req); err != nil {
+		handleErrorResponse(c, models.ErrInvalidJSON)
+		return
+	}
+	if req.Token == "" {
+		handleErrorResponse(c, ErrRunnerTokenMissing)
+		return
+	}
+
+	token := s.runnerTokens.Add(req.Token)
+	token.Token = ""
+	if !s.saveRunnerTokens(c) {
+		return
+	}
+	logrus.WithFields(logrus.Fields{"token_id": token.ID, "by": c.ClientIP()}).Info("Runner token added")
+	c.JSON(http.StatusOK, token)
+}
+
+// handleRunnerTokenPromote makes the server present a runner token to its peers, once all of them accept it
+func (s *Server) handleRunnerTokenPromote(c *gin.Context) {
+	id := c.Param("token_id")
+	if !s.runnerTokens.Promote(id) {
+		handleErrorResponse(c, ErrRunnerTokenNotFound)
+		return
+	}
+	if !s.saveRunnerTokens(c) {
+		return
+	}
+	logrus.WithFields(logrus.Fields{"token_id": id, "by": c.ClientIP()}).Info("Runner token promoted")
+	c.Status(http.StatusNoContent)
+}
+
+// handleRunnerTokenRevoke stops the server accepting a runner token
+func (s *Server) handleRunnerTokenRevoke(c *gin.Context) {
+	id := c.Param("token_id")
+	switch err := s.runnerTokens.Revoke(id); err {
+	case nil:
+	case agent.ErrRunnerTokenUnknown:
+		handleErrorResponse(c, ErrRunnerTokenNotFound)
+		return
+	case agent.ErrRunnerTokenLast:
+		handleErrorResponse(c, ErrRunnerTokenLast)
+		return
+	default:
+		handleErrorResponse(c, err)
+		return
+	}
+	if !s.saveRunnerTokens(c) {
+		return
+	}
+	logrus.WithFields(logrus.Fields{"token_id": id, "by": c.ClientIP()}).Info("Runner token revoked")
+	c.Status(http.StatusNoContent)
+}
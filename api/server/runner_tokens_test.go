@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestRunnerTokenRotation(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{{ID: "fn_id", Name: "myfn", AppID: app.ID, Image: "fnproject/fn-test-utils"}},
+		[]*models.Trigger{{ID: "trigger_id", Name: "mytrigger", AppID: app.ID, FnID: "fn_id", Type: "http", Source: "/myt"}})
+	srv := testServer(ds, nil, ServerTypeAPI, WithAdminToken("secret"), WithRunnerTokens("old"))
+
+	admin := func(method, path string, body io.Reader) (int, []byte) {
+		req := createRequest(t, method, path, body)
+		req.Header.Set("Authorization", "Bearer secret")
+		_, rec := routerRequest2(t, srv.AdminRouter, req)
+		return rec.Code, rec.Body.Bytes()
+	}
+	runner := func(token string) int {
+		req := createRequest(t, http.MethodGet, "/v2/runner/apps/app_id/triggerBySource/http//myt", nil)
+		if token != "" {
+			req.Header.Set(agent.RunnerTokenKey, token)
+		}
+		_, rec := routerRequest2(t, srv.Router, req)
+		return rec.Code
+	}
+
+	if code := runner(""); code != http.StatusUnauthorized {
+		t.Fatalf("expected runner requests without a token to be rejected, got %d", code)
+	}
+	if code := runner("old"); code != http.StatusOK {
+		t.Fatalf("expected runner requests with a token to be served, got %d", code)
+	}
+
+	code, body := admin(http.MethodPost, "/v2/admin/runner/tokens", nil)
+	if code != http.StatusCreated {
+		t.Fatalf("expected a token to be issued, got %d %s", code, body)
+	}
+	var issued agent.RunnerToken
+	if err := json.Unmarshal(body, &issued); err != nil {
+		t.Fatal(err)
+	}
+
+	var list runnerTokensResponse
+	code, body = admin(http.MethodGet, "/v2/admin/runner/tokens", nil)
+	if err := json.Unmarshal(body, &list); err != nil || code != http.StatusOK || len(list.Items) != 2 {
+		t.Fatalf("expected both tokens to be listed, got %d %s", code, body)
+	}
+	var oldID string
+	for _, token := range list.Items {
+		if token.ID != issued.ID {
+			oldID = token.ID
+		}
+	}
+
+	if runner(issued.Token) != http.StatusOK {
+		t.Fatal("expected the issued token to be accepted before it is promoted")
+	}
+	if code, _ = admin(http.MethodPost, "/v2/admin/runner/tokens/missing/promote", nil); code != http.StatusNotFound {
+		t.Fatalf("expected promoting an unknown token to not find it, got %d", code)
+	}
+	if code, _ = admin(http.MethodPost, "/v2/admin/runner/tokens/"+issued.ID+"/promote", nil); code != http.StatusNoContent {
+		t.Fatalf("expected the issued token to be promoted, got %d", code)
+	}
+	_, body = admin(http.MethodGet, "/v2/admin/runner/tokens", nil)
+	if err := json.Unmarshal(body, &list); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range list.Items {
+		if token.Current != (token.ID == issued.ID) {
+			t.Fatalf("expected the promoted token to be current, got %s", body)
+		}
+	}
+
+	if code, _ = admin(http.MethodDelete, "/v2/admin/runner/tokens/"+oldID, nil); code != http.StatusNoContent {
+		t.Fatalf("expected the old token to be revoked, got %d", code)
+	}
+	if code, _ = admin(http.MethodDelete, "/v2/admin/runner/tokens/"+oldID, nil); code != http.StatusNotFound {
+		t.Fatalf("expected revoking twice to not find the token, got %d", code)
+	}
+	if runner("old") != http.StatusUnauthorized || runner(issued.Token) != http.StatusOK {
+		t.Fatal("expected only the issued token to be accepted once the old one is revoked")
+	}
+	if code, _ = admin(http.MethodDelete, "/v2/admin/runner/tokens/"+issued.ID, nil); code != http.StatusConflict {
+		t.Fatalf("expected the last token to not be revoked, got %d", code)
+	}
+	if runner("") != http.StatusUnauthorized || runner(issued.Token) != http.StatusOK {
+		t.Fatal("expected runners to be authenticated with the last token")
+	}
+
+	if code, _ = admin(http.MethodPut, "/v2/admin/runner/tokens", strings.NewReader(`{"token": "peer"}`)); code != http.StatusOK {
+		t.Fatalf("expected a token of a peer to be added, got %d", code)
+	}
+	if runner("peer") != http.StatusOK {
+		t.Fatal("expected the added token to be accepted")
+	}
+
+	req := createRequest(t, http.MethodPost, "/v2/admin/runner/tokens", nil)
+	if _, rec := routerRequest2(t, srv.AdminRouter, req); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected issuing tokens without the admin token to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestRunnerTokensFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runner_tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tokens.json")
+	ds := datastore.NewMock()
+
+	newServer := func(path string) *Server {
+		return testServer(ds, nil, ServerTypeAPI, WithAdminToken("secret"), WithRunnerTokens("old"), WithRunnerTokensFile(path))
+	}
+	admin := func(srv *Server, method, path string) (int, []byte) {
+		req := createRequest(t, method, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		_, rec := routerRequest2(t, srv.AdminRouter, req)
+		return rec.Code, rec.Body.Bytes()
+	}
+
+	srv := newServer(path)
+	code, body := admin(srv, http.MethodPost, "/v2/admin/runner/tokens")
+	if code != http.StatusCreated {
+		t.Fatalf("expected a token to be issued, got %d %s", code, body)
+	}
+	var issued agent.RunnerToken
+	if err := json.Unmarshal(body, &issued); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ = admin(srv, http.MethodPost, "/v2/admin/runner/tokens/"+issued.ID+"/promote"); code != http.StatusNoContent {
+		t.Fatalf("expected the issued token to be promoted, got %d", code)
+	}
+
+	// the rotation survives the server restarting
+	restarted := newServer(path)
+	if restarted.runnerTokens.Current() != issued.Token {
+		t.Fatal("expected the promoted token to be presented after a restart")
+	}
+
+	// changes that cannot be saved are reported
+	broken := newServer(filepath.Join(dir, "missing", "tokens.json"))
+	if code, _ = admin(broken, http.MethodPost, "/v2/admin/runner/tokens"); code != http.StatusInternalServerError {
+		t.Fatalf("expected a token that cannot be saved to be reported, got %d", code)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+)
+
+// WithSecretResolver sets the resolver of the secrets of apps, which the `{{secret "name"}}` config templates and
+// the registry_secret of app policies read, on the calls of the agent of the server. Secrets cannot be used if it
+// is not set.
+func WithSecretResolver(r agent.SecretResolver) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.secrets = r
+		return nil
+	}
+}
+
+// WithSecretsDir reads the secrets of each app from the files of dir/<app id>, see agent.NewDirSecretResolver. An
+// empty dir sets no resolver.
+func WithSecretsDir(dir string) Option {
+	return func(ctx context.Context, s *Server) error {
+		if dir == "" {
+			return nil
+		}
+		return WithSecretResolver(agent.NewDirSecretResolver(dir))(ctx, s)
+	}
+}
+
+// agentOptions are the options of the agents running the containers of the server, the full agent or the agent of
+// a pure runner
+func (s *Server) agentOptions() []agent.Option {
+	var opts []agent.Option
+	if s.secrets != nil {
+		opts = append(opts, agent.WithCallOptions(agent.WithSecretResolver(s.secrets)))
+	}
+	if s.callLogTTL > 0 && s.datastore != nil {
+		opts = append(opts, agent.WithCallLogStore(s.datastore))
+	}
+	return opts
+}
+
+// noSecrets rejects app policies with a registry_secret on servers without a secret resolver, as the images of
+// their fns could never be pulled. API servers check it too, so they need the secrets of their runners configured.
+type noSecrets struct{}
+
+var _ fnext.AppListener = new(noSecrets)
+
+func (noSecrets) check(app *models.App) error {
+	policy, err := app.Annotations.Policy()
+	if err != nil || policy == nil {
+		return err
+	}
+	if policy.RegistrySecret != "" {
+		return models.ErrAppPolicyNoSecrets
+	}
+	return nil
+}
+
+func (n noSecrets) BeforeAppCreate(ctx context.Context, app *models.App) error {
+	return n.check(app)
+}
+
+func (n noSecrets) BeforeAppUpdate(ctx context.Context, app *models.App) error {
+	return n.check(app)
+}
+
+func (noSecrets) AfterAppCreate(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (noSecrets) AfterAppUpdate(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (noSecrets) BeforeAppDelete(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (noSecrets) AfterAppDelete(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (noSecrets) BeforeAppGet(ctx context.Context, appID string) error {
+	return nil
+}
+
+func (noSecrets) AfterAppGet(ctx context.Context, app *models.App) error {
+	return nil
+}
+
+func (noSecrets) BeforeAppsList(ctx context.Context, filter *models.AppFilter) error {
+	return nil
+}
+
+func (noSecrets) AfterAppsList(ctx context.Context, apps []*models.App) error {
+	return nil
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/datastore"
+)
+
+func TestRegistrySecretRequiresSecrets(t *testing.T) {
+	app := `{"name": "myapp", "annotations": {"fnproject.io/app/policy": {"registry_secret": "pull"}}}`
+
+	srv := testServer(datastore.NewMock(), nil, ServerTypeAPI)
+	_, rec := routerRequest(t, srv.Router, http.MethodPost, "/v2/apps", bytes.NewBufferString(app))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected an app with a registry secret to be rejected without secrets, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	dir := agent.NewDirSecretResolver("/nonexistent")
+	srv = testServer(datastore.NewMock(), nil, ServerTypeAPI, WithSecretResolver(dir))
+	_, rec = routerRequest(t, srv.Router, http.MethodPost, "/v2/apps", bytes.NewBufferString(app))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected an app with a registry secret to be created, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
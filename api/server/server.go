@@ -4,17 +4,21 @@ import (
 	"bufio"
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 	"unicode"
 
 	"contrib.go.opencensus.io/exporter/jaeger"
@@ -23,15 +27,22 @@ import (
 	"github.com/gin-gonic/gin"
 	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
 	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/sirupsen/logrus"
 	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/trace"
+	"google.golang.org/grpc"
 
 	"github.com/fnproject/fn/api/agent"
 	"github.com/fnproject/fn/api/agent/hybrid"
+	"github.com/fnproject/fn/api/build"
 	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/common/otlp"
+	"github.com/fnproject/fn/api/config"
 	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/dns"
 	"github.com/fnproject/fn/api/models"
 	pool "github.com/fnproject/fn/api/runnerpool"
 	"github.com/fnproject/fn/api/version"
@@ -67,9 +78,33 @@ const (
 	// EnvRunnerURL is a url pointing to an Fn API service.
 	EnvRunnerURL = "FN_RUNNER_API_URL"
 
-	// EnvRunnerAddresses is a list of runner urls for an lb to use.
+	// EnvCacheInvalidationURL is the url of the db of the Fn API service of an lb, such as FN_DB_URL, whose changes
+	// invalidate the apps, fns and triggers the lb caches. Only postgres publishes its changes. Cached entries
+	// expire after a few seconds if it is not set.
+	EnvCacheInvalidationURL = "FN_CACHE_INVALIDATION_URL"
+
+	// EnvRunnerAddresses is a list of runner urls for an lb to use. A url may be labeled with the tenant pool of
+	// its runner as pool@host:port, the runners apps with a runner pool annotation are placed on.
 	EnvRunnerAddresses = "FN_RUNNER_ADDRESSES"
 
+	// EnvExclusiveRunnerPools is a comma separated list of the tenant pools whose runners only run the calls of the
+	// apps of their pool
+	EnvExclusiveRunnerPools = "FN_EXCLUSIVE_RUNNER_POOLS"
+
+	// EnvRunnerLease is how long an lb places calls on a runner after it last answered a heartbeat, eg. "5s". 0
+	// disables heartbeats.
+	EnvRunnerLease = "FN_RUNNER_LEASE"
+
+	// EnvRunnerHeartbeatInterval is how often an lb sends runners heartbeats, a fifth of the lease by default
+	EnvRunnerHeartbeatInterval = "FN_RUNNER_HEARTBEAT_INTERVAL"
+
+	// EnvPlacementLog is the file an lb records the attempts to place calls on runners in, listed by the admin
+	// server at /v2/admin/diagnostics/placements. Attempts are not recorded if it is not set.
+	EnvPlacementLog = "FN_PLACEMENT_LOG"
+
+	// EnvPlacementLogSize is about how many placement attempts the placement log keeps
+	EnvPlacementLogSize = "FN_PLACEMENT_LOG_SIZE"
+
 	// EnvPublicLoadBalancerURL is the url to inject into trigger responses to get a public url.
 	EnvPublicLoadBalancerURL = "FN_PUBLIC_LB_URL"
 
@@ -77,10 +112,16 @@ const (
 	// are one of: { full, api, lb, runner, pure-runner }
 	EnvNodeType = "FN_NODE_TYPE"
 
+	// EnvIDStrategy is how the ids of apps, fns, triggers and calls are generated, default, ulid or uuidv7, each
+	// optionally prefixed: with the kind of resource, such as prefixed:ulid for ids like fn_01AN4Z07BY79KA1307SR9X4MV3.
+	// Every node of a cluster should generate ids the same way, and ids already generated are kept.
+	EnvIDStrategy = "FN_ID_STRATEGY"
+
 	// EnvPort is the port to listen on for fn http server.
 	EnvPort = "FN_PORT" // be careful, Gin expects this variable to be "port"
 
-	// EnvGRPCPort is the port to run the grpc server on for a pure-runner node.
+	// EnvGRPCPort is the port to run the grpc server on for a pure-runner node, and the grpc invoke service on for
+	// full and lb nodes.
 	EnvGRPCPort = "FN_GRPC_PORT"
 
 	// EnvAPICORSOrigins is the list of CORS origins to allow.
@@ -95,18 +136,56 @@ const (
 	// EnvJaegerURL is the url of a jaeger node to send traces to.
 	EnvJaegerURL = "FN_JAEGER_URL"
 
+	// EnvOTLPEndpoint is the url of an OpenTelemetry collector to send traces and metrics to, as
+	// http://collector:4318. Endpoints without a scheme are connected to over TLS.
+	EnvOTLPEndpoint = "FN_OTLP_ENDPOINT"
+
+	// EnvOTLPProtocol is the protocol traces and metrics are sent to the OpenTelemetry collector with, grpc or
+	// http/protobuf.
+	EnvOTLPProtocol = "FN_OTLP_PROTOCOL"
+
+	// EnvOTLPHeaders are the headers sent to the OpenTelemetry collector, as comma separated key=value pairs.
+	EnvOTLPHeaders = "FN_OTLP_HEADERS"
+
+	// EnvErrorCatalog is the path of a JSON catalog of the messages of errors in responses, by language and error
+	// code, see ErrorCatalog
+	EnvErrorCatalog = "FN_ERROR_CATALOG"
+
 	// EnvRIDHeader is the header name of the incoming request which holds the request ID
 	EnvRIDHeader = "FN_RID_HEADER"
 
 	// EnvProcessCollectorList is the list of procid's to collect metrics for.
 	EnvProcessCollectorList = "FN_PROCESS_COLLECTOR_LIST"
 
+	// EnvPrometheusNamespace prefixes the names of the metrics of fn on the /metrics endpoint, fn by default
+	EnvPrometheusNamespace = "FN_PROMETHEUS_NAMESPACE"
+	// EnvPrometheusComponentNamespaces prefixes the names of the process metrics of the commands of
+	// EnvProcessCollectorList, as comma separated command=namespace pairs, eg. dockerd=docker. Commands without one
+	// are prefixed with their name.
+	EnvPrometheusComponentNamespaces = "FN_PROMETHEUS_COMPONENT_NAMESPACES"
+	// EnvPrometheusLabels are static labels set on every metric of the /metrics endpoint, as comma separated
+	// key=value pairs, eg. cluster=prod,region=us-ashburn-1
+	EnvPrometheusLabels = "FN_PROMETHEUS_LABELS"
+
 	// EnvLBPlacementAlg is the algorithm to place fn calls to fn runners in lb.[0w
 	EnvLBPlacementAlg = "FN_PLACER"
 
 	// EnvMaxRequestSize sets the limit in bytes for any API request body's length.
 	EnvMaxRequestSize = "FN_MAX_REQUEST_SIZE"
 
+	// EnvMaxConcurrentRequests is how many requests the web server handles at once, 0 for no limit
+	EnvMaxConcurrentRequests = "FN_MAX_CONCURRENT_REQUESTS"
+
+	// EnvMaxQueuedRequests is how many requests past EnvMaxConcurrentRequests wait for another to finish, further
+	// requests are turned away with a 503
+	EnvMaxQueuedRequests = "FN_MAX_QUEUED_REQUESTS"
+
+	// EnvRequestQueueTimeout is how long a request waits in the queue before it is turned away with a 503, eg. "1s"
+	EnvRequestQueueTimeout = "FN_REQUEST_QUEUE_TIMEOUT"
+
+	// EnvMaxClientConnections is how many connections each client IP may have open to the web server, 0 for no limit
+	EnvMaxClientConnections = "FN_MAX_CLIENT_CONNECTIONS"
+
 	// EnvMaxHeaderSize sets the limit in bytes for any API request body's length.
 	EnvMaxHeaderSize = "FN_MAX_REQUEST_HEADER_SIZE"
 
@@ -127,6 +206,220 @@ const (
 	// EnvHTTPIdleTimeout maximum amount of time to wait for the next request.
 	EnvHTTPIdleTimeout = "FN_HTTP_IDLE_TIMEOUT"
 
+	// EnvAPIDrainTimeout is how long a server shutting down waits for in-flight management requests before it
+	// cancels them, eg. "30s", 0 waits for them to finish
+	EnvAPIDrainTimeout = "FN_API_DRAIN_TIMEOUT"
+
+	// EnvInvokeDrainTimeout is how long a server shutting down waits for in-flight invoke and http trigger
+	// requests before it cancels them, eg. "5m", 0 waits for them to finish. It defaults to the longest timeout a fn
+	// may have under FN_MAX_FN_TIMEOUT and FN_APP_RESOURCE_LIMITS, up to 5 minutes. Deployments running long fns,
+	// whose detached calls are in flight until they end, set it to up to the 4h of long running fns explicitly.
+	EnvInvokeDrainTimeout = "FN_INVOKE_DRAIN_TIMEOUT"
+
+	// EnvMaxFnMemory is the most memory any fn may request, eg. "1Gi"
+	EnvMaxFnMemory = "FN_MAX_FN_MEMORY"
+
+	// EnvMaxFnCPUs is the most cpus any fn may request, eg. "2" or "500m". Fns that do not set cpus are run with
+	// it as their quota.
+	EnvMaxFnCPUs = "FN_MAX_FN_CPUS"
+
+	// EnvMaxFnTmpFsSize is the largest tmpfs any fn may request, eg. "256Mi"
+	EnvMaxFnTmpFsSize = "FN_MAX_FN_TMPFS_SIZE"
+
+	// EnvMaxFnTimeout is the longest timeout in seconds any fn may request
+	EnvMaxFnTimeout = "FN_MAX_FN_TIMEOUT"
+
+	// EnvMaxFnIdleTimeout is the longest idle_timeout in seconds any fn may request
+	EnvMaxFnIdleTimeout = "FN_MAX_FN_IDLE_TIMEOUT"
+
+	// EnvAppResourceLimits is a JSON object of per app overrides of the above limits, keyed by app name, eg.
+	// {"bigapp": {"memory": "4Gi", "timeout": 120}}
+	EnvAppResourceLimits = "FN_APP_RESOURCE_LIMITS"
+
+	// EnvNamingPolicy is a JSON object of rules on the names of apps, fns and triggers, and of their fields that may
+	// not change, eg. {"fns": {"pattern": "[a-z][a-z0-9-]*"}, "immutable": [{"kind": "fn", "field": "image",
+	// "windows": [{"days": ["mon", "tue", "wed", "thu"], "start": "09:00", "end": "16:00"}]}]}, see models.NamingPolicy
+	EnvNamingPolicy = "FN_NAMING_POLICY"
+
+	// EnvTemplates is a JSON array of the templates users may create apps and fns from, eg. [{"name": "standard",
+	// "description": "...", "fn": {"memory": 256, "annotations": {...}}}], see models.Template
+	EnvTemplates = "FN_TEMPLATES"
+
+	// EnvDisabledTriggerStatus is the status disabled triggers respond with, 503 or 404 to hide them
+	EnvDisabledTriggerStatus = "FN_DISABLED_TRIGGER_STATUS"
+
+	// EnvDecompressRequests decompresses gzip and deflate encoded request bodies before they are passed to fns, "true"
+	// by default or "false"
+	EnvDecompressRequests = "FN_DECOMPRESS_REQUESTS"
+
+	// EnvCompressResponses gzips fn responses for callers that accept it, "true" or "false" by default. Off by
+	// default, as it changes the bytes and headers of responses that callers and fns may rely on.
+	EnvCompressResponses = "FN_COMPRESS_RESPONSES"
+
+	// EnvResponseCacheSize is the most http trigger responses kept in memory, for triggers that opt in to caching
+	EnvResponseCacheSize = "FN_RESPONSE_CACHE_SIZE"
+
+	// EnvResponseCacheURL is the url of the redis http trigger responses are cached in, shared by the servers using
+	// it, eg. redis://:password@localhost:6379/0. Responses are kept in memory if it is not set.
+	EnvResponseCacheURL = "FN_RESPONSE_CACHE_URL"
+
+	// EnvInvokeBatchParallelism is the most calls of a batch invoke, /invoke/<fn_id>/batch, that run at once
+	EnvInvokeBatchParallelism = "FN_INVOKE_BATCH_PARALLELISM"
+
+	// EnvTriggerMetricsMaxSources is the most trigger sources the metrics of http triggers are tagged with, the
+	// metrics of the rest are tagged as "other"
+	EnvTriggerMetricsMaxSources = "FN_TRIGGER_METRICS_MAX_SOURCES"
+
+	// EnvRequestHeadersAllow are the request headers http triggers pass to fns, a list of names separated by
+	// commas or spaces, where a name ending with * matches all the headers it prefixes. Empty passes all headers.
+	EnvRequestHeadersAllow = "FN_REQUEST_HEADERS_ALLOW"
+
+	// EnvRequestHeadersDeny are the request headers http triggers never pass to fns, eg. "Cookie Fn-*"
+	EnvRequestHeadersDeny = "FN_REQUEST_HEADERS_DENY"
+
+	// EnvResponseHeadersAllow are the response headers of fns http triggers pass back. Empty passes all headers.
+	EnvResponseHeadersAllow = "FN_RESPONSE_HEADERS_ALLOW"
+
+	// EnvResponseHeadersDeny are the response headers of fns http triggers never pass back
+	EnvResponseHeadersDeny = "FN_RESPONSE_HEADERS_DENY"
+
+	// EnvTrustedProxies are the proxies whose X-Forwarded-For, X-Real-IP and Forwarded headers are trusted to tell
+	// the address of the clients of http triggers, a comma separated list of CIDRs or addresses, eg. 10.0.0.0/8
+	EnvTrustedProxies = "FN_TRUSTED_PROXIES"
+
+	// EnvUsageWindow is the window the usage of fns is metered over for chargeback, eg. "1h", 0 disables metering
+	EnvUsageWindow = "FN_USAGE_WINDOW"
+
+	// EnvCronLeaseTTL is how long the server firing cron triggers holds the lease electing it, another server
+	// takes over this long after it stops, 0 disables cron triggers on the server
+	EnvCronLeaseTTL = "FN_CRON_LEASE_TTL"
+
+	// EnvCallLogTTL is how long the logs of calls are kept in the datastore, which full nodes append them to while
+	// they run, for GET /v2/fns/:fn_id/calls/:call_id/log. 0 (default) keeps no call logs.
+	EnvCallLogTTL = "FN_CALL_LOG_TTL"
+
+	// EnvMQURL is the url of the message queue full nodes deliver detached calls through, eg.
+	// kafka://localhost:9092/calls?group=fn. Detached calls run on the node taking them if it is not set.
+	EnvMQURL = "FN_MQ_URL"
+
+	// EnvSecretsDir is the directory of the secrets of apps, read from dir/<app id>/<name> by the `{{secret "name"}}`
+	// config templates and the registry_secret of app policies. Secrets cannot be used if it is not set, and API
+	// servers reject apps with a registry_secret unless it is set for them as well.
+	EnvSecretsDir = "FN_SECRETS_DIR"
+
+	// EnvLockStoreURL is the url of the redis keeping the distributed locks electing the servers doing cluster wide
+	// work, such as firing cron triggers, eg. redis://:password@localhost:6379/0. The leases of the datastore are
+	// used if it is not set.
+	EnvLockStoreURL = "FN_LOCK_STORE_URL"
+
+	// EnvBuildRegistry enables building the images of fns from source, the images are pushed to this registry,
+	// eg. registry.example.com/fns
+	EnvBuildRegistry = "FN_BUILD_REGISTRY"
+
+	// EnvBuildDockerHosts is a comma separated list of the docker daemons of builder nodes, eg.
+	// tcp://builder1:2375,tcp://builder2:2375. Images are built on the local docker daemon if it is not set.
+	EnvBuildDockerHosts = "FN_BUILD_DOCKER_HOSTS"
+
+	// EnvMaxBuildContextSize is the largest source archive a build accepts, in bytes
+	EnvMaxBuildContextSize = "FN_MAX_BUILD_CONTEXT_SIZE"
+
+	// EnvBuildTimeout is how long a build may take, eg. "10m"
+	EnvBuildTimeout = "FN_BUILD_TIMEOUT"
+
+	// EnvOpenFaaSEndpoints adds the OpenFaaS gateway invoke endpoints, /function/<name>.<app> and
+	// /async-function/<name>.<app>, which invoke the http trigger /t/<app>/<name>, see WithOpenFaaSEndpoints
+	EnvOpenFaaSEndpoints = "FN_OPENFAAS_ENDPOINTS"
+
+	// EnvOpenFaaSDefaultApp is the app of the OpenFaaS functions named without a namespace
+	EnvOpenFaaSDefaultApp = "FN_OPENFAAS_DEFAULT_APP"
+
+	// EnvKnativeDomain invokes the http trigger /t/<app>/<name> for requests to the hosts <name>.<app>.<domain>, as
+	// Knative services are named, see WithKnativeDomain
+	EnvKnativeDomain = "FN_KNATIVE_DOMAIN"
+
+	// EnvLambdaEndpoints adds the AWS Lambda Invoke endpoint, /2015-03-31/functions/<fn>/invocations, see
+	// WithLambdaEndpoints
+	EnvLambdaEndpoints = "FN_LAMBDA_ENDPOINTS"
+
+	// EnvLambdaDefaultApp is the app of the fns invoked through the Lambda endpoint without a qualifier
+	EnvLambdaDefaultApp = "FN_LAMBDA_DEFAULT_APP"
+
+	// EnvGRPCInvoke serves the Invoke grpc service on the grpc port, see WithGRPCInvoke
+	EnvGRPCInvoke = "FN_GRPC_INVOKE"
+
+	// EnvHTTP2 negotiates HTTP/2 with the clients of the web server connecting over TLS, true by default
+	EnvHTTP2 = "FN_HTTP2"
+
+	// EnvH2C serves HTTP/2 over cleartext on the web server, for internal deployments terminating TLS in front of fn
+	EnvH2C = "FN_H2C"
+
+	// EnvHTTP2MaxConcurrentStreams is how many requests a client may have in flight on an HTTP/2 connection
+	EnvHTTP2MaxConcurrentStreams = "FN_HTTP2_MAX_CONCURRENT_STREAMS"
+
+	// EnvHTTP3Port serves experimental HTTP/3 over QUIC on this UDP port of the web server, which must be configured
+	// with TLS. HTTP/3 is not served if unset.
+	EnvHTTP3Port = "FN_HTTP3_PORT"
+
+	// EnvHTTP3MaxConcurrentStreams is how many requests a client may have in flight on an HTTP/3 connection
+	EnvHTTP3MaxConcurrentStreams = "FN_HTTP3_MAX_CONCURRENT_STREAMS"
+
+	// EnvDNSURL publishes the HTTP triggers of apps as DNS records with the DNS provider of this url, eg.
+	// coredns-file:///etc/coredns/db.fns, etcd://etcd:2379/skydns or route53://<hosted zone id>. Records resolve to
+	// the host of FN_PUBLIC_LB_URL, which is required along with FN_DNS_ZONE, see WithDNSPublisher
+	EnvDNSURL = "FN_DNS_URL"
+
+	// EnvDNSZone is the zone the DNS records of triggers are published in, as <trigger>.<app>.<zone>
+	EnvDNSZone = "FN_DNS_ZONE"
+
+	// EnvImageScannerURL enables scanning the images of fns for vulnerabilities when they are created or updated,
+	// images are POSTed to this url, see NewHTTPImageScanner
+	EnvImageScannerURL = "FN_IMAGE_SCANNER_URL"
+
+	// EnvImageScanTimeout is how long a scan may take, eg. "2m"
+	EnvImageScanTimeout = "FN_IMAGE_SCAN_TIMEOUT"
+
+	// EnvImageScanBlockSeverity is the lowest severity of vulnerabilities that images are rejected for, one of
+	// UNKNOWN, LOW, MEDIUM, HIGH or CRITICAL, or NONE
+	EnvImageScanBlockSeverity = "FN_IMAGE_SCAN_BLOCK_SEVERITY"
+
+	// EnvImageScanWarnSeverity is the lowest severity of vulnerabilities that images are logged for, or NONE
+	EnvImageScanWarnSeverity = "FN_IMAGE_SCAN_WARN_SEVERITY"
+
+	// EnvImageScanMaxAge is how long the scan of an image digest is reused for, eg. "24h", 0 reuses it forever
+	EnvImageScanMaxAge = "FN_IMAGE_SCAN_MAX_AGE"
+
+	// EnvAdminToken enables changing the tunable settings and runner tokens at runtime through the admin server, for
+	// requests with this bearer token
+	EnvAdminToken = "FN_ADMIN_TOKEN"
+
+	// EnvRunnerTokens are the tokens authenticating pure runners and the lb and api nodes they work with, a comma
+	// separated list, the last of which a node presents to its peers. Tokens are rotated through the admin server, and
+	// rotated tokens must be set here as well to survive restarts, unless FN_RUNNER_TOKENS_FILE is set.
+	EnvRunnerTokens = "FN_RUNNER_TOKENS"
+
+	// EnvRunnerTokensFile is the file the runner tokens of a node are saved to as they are rotated through the admin
+	// server, and loaded from as it starts, so that rotations survive restarts. The tokens of FN_RUNNER_TOKENS are
+	// accepted again on restart, even if they were revoked.
+	EnvRunnerTokensFile = "FN_RUNNER_TOKENS_FILE"
+
+	// EnvPayloadKeys are the AES keys the data keys of the payloads of calls between lbs and pure runners are
+	// wrapped with, a comma separated list of id=base64 keys. Lbs wrap with the last, runners unwrap with any.
+	EnvPayloadKeys = "FN_PAYLOAD_KEYS"
+
+	// EnvCallInputKeys are the AES keys the data keys of the call inputs recorded with call records, and of the
+	// payloads of trigger runs, are wrapped with, a comma separated list of id=base64 keys. Inputs are encrypted
+	// with the last and decrypted with any.
+	EnvCallInputKeys = "FN_CALL_INPUT_KEYS"
+
+	// EnvPlacerTimeout is how long an lb may try to place a call on runners, eg. "6m"
+	EnvPlacerTimeout = "FN_PLACER_TIMEOUT"
+
+	// EnvDetachedPlacerTimeout is how long an lb may try to place a detached call on runners, eg. "30s"
+	EnvDetachedPlacerTimeout = "FN_DETACHED_PLACER_TIMEOUT"
+
+	// EnvPlacerRetryAllDelay is how long an lb waits before trying all the runners again, eg. "10ms"
+	EnvPlacerRetryAllDelay = "FN_PLACER_RETRY_ALL_DELAY"
+
 	// DefaultLogFormat is text
 	DefaultLogFormat = "text"
 
@@ -136,11 +429,62 @@ const (
 	// DefaultLogDest is stderr
 	DefaultLogDest = "stderr"
 
+	// DefaultAPIDrainTimeout is 30 seconds
+	DefaultAPIDrainTimeout = 30 * time.Second
+
+	// MaxDefaultInvokeDrainTimeout is 5 minutes, the longest EnvInvokeDrainTimeout defaults to
+	MaxDefaultInvokeDrainTimeout = 5 * time.Minute
+
+	// DefaultResponseCacheSize is 1024
+	DefaultResponseCacheSize = 1024
+
+	// DefaultInvokeBatchParallelism is 4
+	DefaultInvokeBatchParallelism = 4
+
+	// DefaultTriggerMetricsMaxSources is 1000
+	DefaultTriggerMetricsMaxSources = 1000
+
+	// DefaultUsageWindow is an hour
+	DefaultUsageWindow = time.Hour
+
+	// DefaultCronLeaseTTL is 30 seconds
+	DefaultCronLeaseTTL = 30 * time.Second
+
+	// DefaultHTTP2MaxConcurrentStreams is 250
+	DefaultHTTP2MaxConcurrentStreams = 250
+
+	// DefaultRequestQueueTimeout is 1 second
+	DefaultRequestQueueTimeout = time.Second
+
+	// DefaultPlacementLogSize is 100000
+	DefaultPlacementLogSize = 100000
+
+	// DefaultMaxBuildContextSize is 100MiB
+	DefaultMaxBuildContextSize = 100 * 1024 * 1024
+
+	// DefaultBuildTimeout is 10 minutes
+	DefaultBuildTimeout = 10 * time.Minute
+
+	// DefaultImageScanTimeout is 2 minutes
+	DefaultImageScanTimeout = 2 * time.Minute
+
+	// DefaultImageScanBlockSeverity is CRITICAL
+	DefaultImageScanBlockSeverity = models.SeverityCritical
+
+	// DefaultImageScanWarnSeverity is HIGH
+	DefaultImageScanWarnSeverity = models.SeverityHigh
+
+	// DefaultImageScanMaxAge is a day
+	DefaultImageScanMaxAge = 24 * time.Hour
+
 	// DefaultPort is 8080
 	DefaultPort = 8080
 
 	// DefaultGRPCPort is 9190
 	DefaultGRPCPort = 9190
+
+	// DefaultPrometheusNamespace is fn
+	DefaultPrometheusNamespace = "fn"
 )
 
 // NodeType is the mode to run fn in.
@@ -197,6 +541,9 @@ type Server struct {
 	datastore models.Datastore
 	nodeType  NodeType
 
+	// scheme of the datastore url, for the capability report
+	datastoreScheme string
+
 	// Service Settings for Admin/Web/gRPC. Note that for gRPC only
 	// TLSConfig and Addr are transferrable from http.Server to GRPC service.
 	// TODO: extend this to cover gRPC options.
@@ -206,6 +553,11 @@ type Server struct {
 	noHTTTPTriggerEndpoint bool
 	noFnInvokeEndpoint     bool
 	noProfilerEndpoint     bool
+	openFaaS               bool
+	openFaaSApp            string
+	knativeDomain          string
+	lambda                 bool
+	lambdaApp              string
 	noWebServer            bool
 	noAdminServer          bool
 	appListeners           *appListeners
@@ -214,8 +566,64 @@ type Server struct {
 	rootMiddlewares        []fnext.Middleware
 	apiMiddlewares         []fnext.Middleware
 	promExporter           *prometheus.Exporter
+	promNamespace          string
+	promComponents         map[string]string
+	promLabels             promclient.Labels
+	otlpExporter           *otlp.Exporter
+	errorCatalog           *ErrorCatalog
 	triggerAnnotator       TriggerAnnotator
 	fnAnnotator            FnAnnotator
+	annotationSchemas      *annotationSchemas
+	templates              map[string]*models.Template
+	templateNames          []string
+	disabledTriggerStatus  int
+	resourceLimits         *resourceLimits
+	asyncCalls             *asyncCalls
+	workflows              *workflowExecutor
+	triggerRuns            *triggerRuns
+	responseCache          ResponseCache
+	headerPolicy           models.HeaderPolicy
+	triggerSources         *triggerSources
+	invokeBatchParallelism int
+	trustedProxies         []*net.IPNet
+	apiDrain               *drainGroup
+	invokeDrain            *drainGroup
+	runnerTokens           *agent.RunnerTokens
+	runnerTokensFile       string
+	payloadKeys            agent.KeyProvider
+	callInputKeys          agent.KeyProvider
+	callInputRedactors     []fnext.CallInputRedactor
+	placements             *placementLog
+	maxRequestSize         int64
+	decompressRequests     bool
+	compressResponses      bool
+	payloads               *payloadPassThrough
+	usageWindow            time.Duration
+	usageExporters         []UsageExporter
+	usage                  *usageMeter
+	cronLeaseTTL           time.Duration
+	callLogTTL             time.Duration
+	callQueue              models.MessageQueue
+	cron                   *cronScheduler
+	sweeps                 []sweep
+	lockStore              common.LockStore
+	secrets                agent.SecretResolver
+	grpcInvoke             bool
+	grpcInvokeServer       *grpc.Server
+	noHTTP2                bool
+	h2c                    bool
+	http2MaxStreams        uint32
+	http3Port              int
+	http3MaxStreams        int
+	http3                  *http3.Server
+	http3Conn              net.PacketConn
+	requestLimiter         requestLimiter
+	maxClientConns         int
+	builder                build.Builder
+	buildRegistry          string
+	maxBuildContextSize    int64
+	buildTimeout           time.Duration
+	adminToken             string
 
 	// Extensions can append to this list of contexts so that cancellations are properly handled.
 	extraCtxs []context.Context
@@ -250,13 +658,128 @@ func NewFromEnv(ctx context.Context, opts ...Option) *Server {
 	opts = append(opts, WithGRPCPort(getEnvInt(EnvGRPCPort, DefaultGRPCPort)))
 	opts = append(opts, WithZipkin(getEnv(EnvZipkinURL, "")))
 	opts = append(opts, WithJaeger(getEnv(EnvJaegerURL, "")))
+	opts = append(opts, WithOpenTelemetry(getEnv(EnvOTLPEndpoint, ""), getEnv(EnvOTLPProtocol, otlp.ProtocolHTTP), getEnv(EnvOTLPHeaders, "")))
+	opts = append(opts, WithErrorCatalog(getEnv(EnvErrorCatalog, "")))
+	opts = append(opts, WithPrometheusMetrics(getEnv(EnvPrometheusNamespace, DefaultPrometheusNamespace),
+		getEnv(EnvPrometheusComponentNamespaces, ""), getEnv(EnvPrometheusLabels, "")))
 	opts = append(opts, WithPrometheus()) // TODO option to turn this off?
 	opts = append(opts, WithDBURL(getEnv(EnvDBURL, defaultDB)))
 	opts = append(opts, WithType(nodeType))
+	opts = append(opts, WithAdminToken(getEnv(EnvAdminToken, "")))
+	opts = append(opts, WithLockStoreURL(getEnv(EnvLockStoreURL, "")))
+	opts = append(opts, WithSecretsDir(getEnv(EnvSecretsDir, "")))
+	opts = append(opts, WithRunnerTokens(strings.FieldsFunc(getEnv(EnvRunnerTokens, ""), func(r rune) bool { return r == ',' })...))
+	opts = append(opts, WithRunnerTokensFile(getEnv(EnvRunnerTokensFile, "")))
+	if keys := getEnv(EnvPayloadKeys, ""); keys != "" {
+		opts = append(opts, WithStaticPayloadKeys(keys))
+	}
+	if keys := getEnv(EnvCallInputKeys, ""); keys != "" {
+		opts = append(opts, WithStaticCallInputKeys(keys))
+	}
 
 	opts = append(opts, LimitRequestBody(int64(getEnvInt(EnvMaxRequestSize, 0))))
+	opts = append(opts, WithRequestConcurrency(getEnvInt(EnvMaxConcurrentRequests, 0), getEnvInt(EnvMaxQueuedRequests, 0), getEnvDuration(EnvRequestQueueTimeout, DefaultRequestQueueTimeout)))
+	opts = append(opts, WithClientConnectionLimit(getEnvInt(EnvMaxClientConnections, 0)))
+
+	limits, appLimits, err := resourceLimitsFromEnv()
+	if err != nil {
+		logrus.WithError(err).Fatal("invalid fn resource limits")
+	}
+	opts = append(opts, WithResourceLimits(limits, appLimits))
+	opts = append(opts, WithDrainTimeouts(getEnvDuration(EnvAPIDrainTimeout, DefaultAPIDrainTimeout), getEnvDuration(EnvInvokeDrainTimeout, defaultInvokeDrainTimeout(limits, appLimits))))
+
+	namingPolicy, err := namingPolicyFromEnv()
+	if err != nil {
+		logrus.WithError(err).Fatal("invalid naming policy")
+	}
+	opts = append(opts, WithNamingPolicy(namingPolicy))
+
+	templates, err := templatesFromEnv()
+	if err != nil {
+		logrus.WithError(err).Fatal("invalid templates")
+	}
+	opts = append(opts, WithTemplates(templates))
+	opts = append(opts, WithDisabledTriggerStatus(getEnvInt(EnvDisabledTriggerStatus, http.StatusServiceUnavailable)))
+
+	if nodeType == ServerTypeFull || nodeType == ServerTypeLB {
+		opts = append(opts, WithInvokeCompression(getEnvBool(EnvDecompressRequests, true), getEnvBool(EnvCompressResponses, false)))
+		responseCache := NewMemoryResponseCache(getEnvInt(EnvResponseCacheSize, DefaultResponseCacheSize))
+		if u := getEnv(EnvResponseCacheURL, ""); u != "" {
+			if responseCache, err = NewRedisResponseCache(u); err != nil {
+				logrus.WithError(err).Fatal("invalid response cache url")
+			}
+		}
+		opts = append(opts, WithResponseCache(responseCache))
+		opts = append(opts, WithHeaderPolicy(headerPolicyFromEnv()))
+		opts = append(opts, WithTrustedProxies(headerList(getEnv(EnvTrustedProxies, ""))))
+		opts = append(opts, WithTriggerMetricsMaxSources(getEnvInt(EnvTriggerMetricsMaxSources, DefaultTriggerMetricsMaxSources)))
+		opts = append(opts, WithInvokeBatchParallelism(getEnvInt(EnvInvokeBatchParallelism, DefaultInvokeBatchParallelism)))
+		opts = append(opts, WithUsageWindow(getEnvDuration(EnvUsageWindow, DefaultUsageWindow)))
+	}
+	if nodeType == ServerTypeFull {
+		opts = append(opts, WithCronTriggers(getEnvDuration(EnvCronLeaseTTL, DefaultCronLeaseTTL)))
+		opts = append(opts, WithMessageQueueURL(getEnv(EnvMQURL, "")))
+	}
+	if nodeType == ServerTypeFull || nodeType == ServerTypeAPI {
+		opts = append(opts, WithCallLogs(getEnvDuration(EnvCallLogTTL, 0)))
+	}
+	if nodeType == ServerTypeLB {
+		opts = append(opts, WithPlacementLog(getEnv(EnvPlacementLog, ""), getEnvInt(EnvPlacementLogSize, DefaultPlacementLogSize)))
+	}
+
+	if registry := getEnv(EnvBuildRegistry, ""); registry != "" && (nodeType == ServerTypeFull || nodeType == ServerTypeAPI) {
+		var hosts []string
+		if h := getEnv(EnvBuildDockerHosts, ""); h != "" {
+			hosts = strings.Split(h, ",")
+		}
+		builder, err := build.NewDockerBuilder(hosts, build.RegistryAuthFromDockerCfg(registry))
+		if err != nil {
+			logrus.WithError(err).Fatal("invalid fn builder")
+		}
+		opts = append(opts, WithBuilder(builder, registry))
+		opts = append(opts, WithBuildLimits(int64(getEnvInt(EnvMaxBuildContextSize, DefaultMaxBuildContextSize)), getEnvDuration(EnvBuildTimeout, DefaultBuildTimeout)))
+	}
+
+	if url := getEnv(EnvImageScannerURL, ""); url != "" && (nodeType == ServerTypeFull || nodeType == ServerTypeAPI) {
+		severity := func(key, def string) string {
+			if v := strings.ToUpper(getEnv(key, def)); v != "NONE" {
+				return v
+			}
+			return ""
+		}
+		opts = append(opts, WithImageScanner(NewHTTPImageScanner(url, getEnvDuration(EnvImageScanTimeout, DefaultImageScanTimeout)),
+			severity(EnvImageScanBlockSeverity, DefaultImageScanBlockSeverity),
+			severity(EnvImageScanWarnSeverity, DefaultImageScanWarnSeverity),
+			getEnvDuration(EnvImageScanMaxAge, DefaultImageScanMaxAge)))
+	}
+
+	if nodeType == ServerTypeFull || nodeType == ServerTypeLB {
+		if getEnvBool(EnvOpenFaaSEndpoints, false) {
+			opts = append(opts, WithOpenFaaSEndpoints(getEnv(EnvOpenFaaSDefaultApp, "")))
+		}
+		if domain := getEnv(EnvKnativeDomain, ""); domain != "" {
+			opts = append(opts, WithKnativeDomain(domain))
+		}
+		if getEnvBool(EnvLambdaEndpoints, false) {
+			opts = append(opts, WithLambdaEndpoints(getEnv(EnvLambdaDefaultApp, "")))
+		}
+		opts = append(opts, WithGRPCInvoke(getEnvBool(EnvGRPCInvoke, false)))
+		opts = append(opts, WithHTTP2(getEnvBool(EnvHTTP2, true), getEnvBool(EnvH2C, false), getEnvInt(EnvHTTP2MaxConcurrentStreams, DefaultHTTP2MaxConcurrentStreams)))
+		opts = append(opts, WithHTTP3(getEnvInt(EnvHTTP3Port, 0), getEnvInt(EnvHTTP3MaxConcurrentStreams, 0)))
+	}
 
 	publicLBURL := getEnv(EnvPublicLoadBalancerURL, "")
+	if dnsURL := getEnv(EnvDNSURL, ""); dnsURL != "" && (nodeType == ServerTypeFull || nodeType == ServerTypeAPI) {
+		zone := getEnv(EnvDNSZone, "")
+		if publicLBURL == "" || zone == "" {
+			logrus.Fatalf("%s needs %s, the URL DNS records point at, and %s", EnvDNSURL, EnvPublicLoadBalancerURL, EnvDNSZone)
+		}
+		publisher, err := dns.New(ctx, dnsURL, zone)
+		if err != nil {
+			logrus.WithError(err).Fatal("invalid DNS publisher")
+		}
+		opts = append(opts, WithDNSPublisher(publisher, zone, publicLBURL))
+	}
 	if publicLBURL != "" {
 		logrus.Infof("using LB Base URL: '%s'", publicLBURL)
 		opts = append(opts, WithTriggerAnnotator(NewStaticURLTriggerAnnotator(publicLBURL)))
@@ -272,7 +795,10 @@ func NewFromEnv(ctx context.Context, opts ...Option) *Server {
 		opts = append(opts, WithAgentFromEnv())
 	}
 
-	return New(ctx, opts...)
+	addConfigChecks(nodeType)
+	s := New(ctx, opts...)
+	reportConfig()
+	return s
 }
 
 func pwd() string {
@@ -338,6 +864,7 @@ func WithDBURL(dbURL string) Option {
 			if err != nil {
 				return err
 			}
+			s.datastoreScheme = datastoreScheme(dbURL)
 			return WithDatastore(ds)(ctx, s)
 		}
 		return nil
@@ -375,7 +902,10 @@ func WithDatastore(ds models.Datastore) Option {
 		s.datastore = datastore.Wrap(s.datastore)
 		s.datastore = fnext.NewDatastore(s.datastore, s.appListeners, s.fnListeners, s.triggerListeners)
 		if s.lbReadAccess == nil {
-			return WithReadDataAccess(agent.NewCachedDataAccess(s.datastore))(ctx, s)
+			cda := agent.NewCachedDataAccess(s.datastore)
+			// changes to the datastore, of this server and those sharing it, invalidate the cache
+			s.datastore.WatchChanges(ctx, cda.(agent.CacheInvalidator).Invalidate)
+			return WithReadDataAccess(cda)(ctx, s)
 		}
 		return nil
 	}
@@ -394,14 +924,29 @@ func (s *Server) defaultRunnerPool() (pool.RunnerPool, error) {
 	if runnerAddresses == "" {
 		return nil, errors.New("must provide FN_RUNNER_ADDRESSES  when running in default load-balanced mode")
 	}
-	return agent.DefaultStaticRunnerPool(strings.Split(runnerAddresses, ",")), nil
+	rp := agent.NewStaticRunnerPool(strings.Split(runnerAddresses, ","), nil, agent.RunnerDialOptions(s.runnerTokens, s.payloadKeys)...)
+	exclusive := strings.FieldsFunc(getEnv(EnvExclusiveRunnerPools, ""), func(r rune) bool { return r == ',' })
+	rp = pool.NewTenantRunnerPool(rp, exclusive)
+
+	if lease := getEnvDuration(EnvRunnerLease, 0); lease > 0 {
+		cfg := pool.NewLeaseConfig()
+		cfg.Lease = lease
+		cfg.Interval = getEnvDuration(EnvRunnerHeartbeatInterval, lease/5)
+		cfg.Timeout = cfg.Interval
+		rp = pool.NewLeasedRunnerPool(rp, cfg)
+	}
+	return rp, nil
 }
 
 // WithFullAgent is a shorthand for WithAgent(... create a full agent here ...)
 func WithFullAgent() Option {
 	return func(ctx context.Context, s *Server) error {
 		s.nodeType = ServerTypeFull
-		s.agent = agent.New()
+		opts := s.agentOptions()
+		if s.lbReadAccess != nil {
+			opts = append(opts, agent.WithFnChaining(s.lbReadAccess), agent.WithFnMirroring(s.lbReadAccess))
+		}
+		s.agent = agent.New(opts...)
 		return nil
 	}
 }
@@ -415,7 +960,14 @@ func WithAgentFromEnv() Option {
 			return errors.New("should not initialize an agent for an Fn API node")
 		case ServerTypePureRunner:
 			cancelCtx, cancel := context.WithCancel(ctx)
-			prAgent, err := agent.DefaultPureRunner(cancel, s.svcConfigs[GRPCServer].Addr, s.svcConfigs[GRPCServer].TLSConfig)
+			prOpts := []agent.PureRunnerOption{agent.PureRunnerWithRunnerTokens(s.runnerTokens)}
+			if s.payloadKeys != nil {
+				prOpts = append(prOpts, agent.PureRunnerWithPayloadKeys(s.payloadKeys))
+			}
+			if opts := s.agentOptions(); len(opts) > 0 {
+				prOpts = append(prOpts, agent.PureRunnerWithAgent(agent.New(opts...)))
+			}
+			prAgent, err := agent.DefaultPureRunner(cancel, s.svcConfigs[GRPCServer].Addr, s.svcConfigs[GRPCServer].TLSConfig, prOpts...)
 			if err != nil {
 				return err
 			}
@@ -428,7 +980,7 @@ func WithAgentFromEnv() Option {
 				return errors.New("no FN_RUNNER_API_URL provided for an Fn NuLB node")
 			}
 
-			cl, err := hybrid.NewClient(runnerURL)
+			cl, err := hybrid.NewClient(runnerURL, hybrid.WithRunnerTokens(s.runnerTokens))
 			if err != nil {
 				return err
 			}
@@ -440,6 +992,12 @@ func WithAgentFromEnv() Option {
 
 			// Select the placement algorithm
 			placerCfg := pool.NewPlacerConfig()
+			placerCfg.PlacerTimeout = getEnvDuration(EnvPlacerTimeout, placerCfg.PlacerTimeout)
+			placerCfg.DetachedPlacerTimeout = getEnvDuration(EnvDetachedPlacerTimeout, placerCfg.DetachedPlacerTimeout)
+			placerCfg.RetryAllDelay = getEnvDuration(EnvPlacerRetryAllDelay, placerCfg.RetryAllDelay)
+			if s.placements != nil {
+				placerCfg.Recorder = s.placements
+			}
 			var placer pool.Placer
 			switch getEnv(EnvLBPlacementAlg, "") {
 			case "ch":
@@ -447,12 +1005,19 @@ func WithAgentFromEnv() Option {
 			default:
 				placer = pool.NewNaivePlacer(&placerCfg)
 			}
+			addPlacerTunables(placer)
 
-			err = WithReadDataAccess(agent.NewCachedDataAccess(cl))(ctx, s)
+			cda := agent.NewCachedDataAccess(cl)
+			if dbURL := getEnv(EnvCacheInvalidationURL, ""); dbURL != "" {
+				if err := datastore.WatchChanges(ctx, dbURL, cda.(agent.CacheInvalidator).Invalidate); err != nil {
+					return fmt.Errorf("cannot watch the changes of %s: %v", EnvCacheInvalidationURL, err)
+				}
+			}
+			err = WithReadDataAccess(cda)(ctx, s)
 			if err != nil {
 				return errors.New("LBAgent creation failed")
 			}
-			s.agent, err = agent.NewLBAgent(runnerPool, placer)
+			s.agent, err = agent.NewLBAgent(runnerPool, placer, agent.WithLBFnChaining(s.lbReadAccess), agent.WithLBFnMirroring(s.lbReadAccess))
 			if err != nil {
 				return errors.New("LBAgent creation failed")
 			}
@@ -530,6 +1095,10 @@ func New(ctx context.Context, opts ...Option) *Server {
 		appListeners:     new(appListeners),
 		fnListeners:      new(fnListeners),
 		triggerListeners: new(triggerListeners),
+		triggerSources:   newTriggerSources(DefaultTriggerMetricsMaxSources),
+		apiDrain:         newDrainGroup("api"),
+		invokeDrain:      newDrainGroup("invoke"),
+		runnerTokens:     agent.NewRunnerTokens(),
 
 		// Almost everything else is configured through opts (see NewFromEnv for ex.) or below
 	}
@@ -543,6 +1112,7 @@ func New(ctx context.Context, opts ...Option) *Server {
 			log.WithError(err).Fatal("Error during server opt initialization.")
 		}
 	}
+	s.addRequestLimitTunables()
 
 	if s.svcConfigs[WebServer].Addr == "" {
 		s.svcConfigs[WebServer].Addr = fmt.Sprintf(":%d", DefaultPort)
@@ -591,6 +1161,66 @@ func New(ctx context.Context, opts ...Option) *Server {
 
 	}
 
+	if s.datastore != nil {
+		s.AddFnListener(&fnChains{ds: func() models.Datastore { return s.datastore }})
+		s.AddFnListener(&fnDeployments{ds: func() models.Datastore { return s.datastore }})
+		if s.secrets == nil {
+			s.AddAppListener(noSecrets{})
+		}
+		s.addSweep("call-inputs", callInputSweepInterval, func(ctx context.Context, now time.Time) (int, error) {
+			return s.datastore.RemoveExpiredCallInputs(ctx, now)
+		})
+		s.addSweep("trigger-runs", triggerRunSweepInterval, func(ctx context.Context, now time.Time) (int, error) {
+			return s.datastore.RemoveExpiredTriggerRuns(ctx, now)
+		})
+		if s.callLogTTL > 0 {
+			s.addSweep("call-logs", callLogSweepInterval, func(ctx context.Context, now time.Time) (int, error) {
+				return s.datastore.RemoveExpiredCallLogs(ctx, now.Add(-s.callLogTTL))
+			})
+		}
+		s.addSweep("workflow-runs", workflowRunSweepInterval, func(ctx context.Context, now time.Time) (int, error) {
+			return s.datastore.FailLostWorkflowRuns(ctx, now.Add(-workflowRunLostAfter))
+		})
+	}
+
+	// full nodes persist their detached calls, api nodes serve them
+	if s.agent != nil && s.datastore != nil {
+		s.asyncCalls = &asyncCalls{
+			ds:        func() models.Datastore { return s.datastore },
+			keys:      func() agent.KeyProvider { return s.callInputKeys },
+			redactors: func() []fnext.CallInputRedactor { return s.callInputRedactors },
+		}
+		s.AddCallListener(s.asyncCalls)
+
+		s.triggerRuns = &triggerRuns{
+			ds:        func() models.Datastore { return s.datastore },
+			keys:      func() agent.KeyProvider { return s.callInputKeys },
+			redactors: func() []fnext.CallInputRedactor { return s.callInputRedactors },
+		}
+		s.AddCallListener(s.triggerRuns)
+
+		s.workflows = &workflowExecutor{
+			ds:        func() models.Datastore { return s.datastore },
+			agent:     s.agent,
+			limits:    s.resourceLimits,
+			backoff:   workflowRetryBackoff,
+			heartbeat: workflowRunHeartbeat,
+		}
+
+		if s.cronLeaseTTL > 0 {
+			s.cron = newCronScheduler(func() models.Datastore { return s.datastore }, s.LockStore(), s.cronLeaseTTL, s.fireCronTrigger)
+		}
+	}
+
+	if s.agent != nil && s.usageWindow > 0 && (s.nodeType == ServerTypeFull || s.nodeType == ServerTypeLB) {
+		s.usage = newUsageMeter(s.usageWindow, s.usageExporters)
+		s.AddCallListener(s.usage)
+	}
+
+	if s.grpcInvoke && !s.noFnInvokeEndpoint && (s.nodeType == ServerTypeFull || s.nodeType == ServerTypeLB) {
+		s.grpcInvokeServer = s.newGRPCInvokeServer()
+	}
+
 	s.Router.Use(loggerWrap, traceWrap) // TODO should be opts
 	optionalCorsWrap(s.Router)          // TODO should be an opt
 	apiMetricsWrap(s)
@@ -605,18 +1235,28 @@ func New(ctx context.Context, opts ...Option) *Server {
 // WithPrometheus activates the prometheus collection and /metrics endpoint
 func WithPrometheus() Option {
 	return func(ctx context.Context, s *Server) error {
+		namespace := s.promNamespace
+		if namespace == "" {
+			namespace = DefaultPrometheusNamespace
+		}
+
 		reg := promclient.NewRegistry()
-		reg.MustRegister(promclient.NewProcessCollector(promclient.ProcessCollectorOpts{
+		// the labels of the metrics of the exporter are set by the exporter itself
+		labeled := promclient.WrapRegistererWith(s.promLabels, reg)
+		labeled.MustRegister(promclient.NewProcessCollector(promclient.ProcessCollectorOpts{
 			PidFn:     func() (int, error) { return os.Getpid(), nil },
-			Namespace: "fn",
+			Namespace: namespace,
 		}),
 			promclient.NewGoCollector(),
 		)
 
 		for _, exeName := range getMonitoredCmdNames() {
-			san := promSanitizeMetricName(exeName)
+			san, ok := s.promComponents[exeName]
+			if !ok {
+				san = promSanitizeMetricName(exeName)
+			}
 
-			err := reg.Register(promclient.NewProcessCollector(promclient.ProcessCollectorOpts{
+			err := labeled.Register(promclient.NewProcessCollector(promclient.ProcessCollectorOpts{
 				PidFn:     getPidCmd(exeName),
 				Namespace: san,
 			}))
@@ -626,9 +1266,10 @@ func WithPrometheus() Option {
 		}
 
 		exporter, err := prometheus.NewExporter(prometheus.Options{
-			Namespace: "fn",
-			Registry:  reg,
-			OnError:   func(err error) { logrus.WithError(err).Error("opencensus prometheus exporter err") },
+			Namespace:   namespace,
+			Registry:    reg,
+			OnError:     func(err error) { logrus.WithError(err).Error("opencensus prometheus exporter err") },
+			ConstLabels: s.promLabels,
 		})
 		if err != nil {
 			return fmt.Errorf("error starting prometheus exporter: %v", err)
@@ -728,6 +1369,112 @@ func WithZipkin(zipkinURL string) Option {
 	}
 }
 
+// WithOpenTelemetry exports traces and metrics to the OpenTelemetry collector at endpoint, maps EnvOTLPEndpoint,
+// EnvOTLPProtocol and EnvOTLPHeaders
+func WithOpenTelemetry(endpoint, protocol, headers string) Option {
+	return func(ctx context.Context, s *Server) error {
+		if endpoint == "" {
+			return nil
+		}
+		hdrs, err := otlp.ParseHeaders(headers)
+		if err != nil {
+			return err
+		}
+		exporter, err := otlp.NewExporter(otlp.Options{
+			Endpoint:       endpoint,
+			Protocol:       protocol,
+			Headers:        hdrs,
+			ServiceName:    "fnserver",
+			ServiceVersion: version.Version,
+		})
+		if err != nil {
+			return fmt.Errorf("error starting otlp exporter: %v", err)
+		}
+		s.otlpExporter = exporter
+		trace.RegisterExporter(exporter)
+		view.RegisterExporter(exporter)
+		logrus.WithFields(logrus.Fields{"endpoint": endpoint, "protocol": protocol}).Info("exporting spans and metrics to otlp collector")
+
+		// as with jaeger and zipkin, every request is traced
+		trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+		return nil
+	}
+}
+
+// WithErrorCatalog words the errors of responses with the catalog at path, in the languages clients accept, maps
+// EnvErrorCatalog
+func WithErrorCatalog(path string) Option {
+	return func(ctx context.Context, s *Server) error {
+		if path == "" {
+			return nil
+		}
+		catalog, err := LoadErrorCatalog(path)
+		if err != nil {
+			return fmt.Errorf("error loading error catalog: %v", err)
+		}
+		s.errorCatalog = catalog
+		logrus.WithField("path", path).Info("wording errors with error catalog")
+		return nil
+	}
+}
+
+// WithPrometheusMetrics names and labels the metrics of WithPrometheus, which it must come before. namespace prefixes
+// the names of the metrics of fn, components the names of the process metrics of the commands of
+// EnvProcessCollectorList, as comma separated command=namespace pairs, and labels are set on every metric, as
+// comma separated key=value pairs. Maps EnvPrometheusNamespace, EnvPrometheusComponentNamespaces and
+// EnvPrometheusLabels.
+func WithPrometheusMetrics(namespace, components, labels string) Option {
+	return func(ctx context.Context, s *Server) error {
+		if namespace != "" && !promMetricName.MatchString(namespace) {
+			return fmt.Errorf("invalid prometheus namespace %q", namespace)
+		}
+		s.promNamespace = namespace
+
+		pairs, err := parsePromPairs(components)
+		if err != nil {
+			return fmt.Errorf("invalid prometheus component namespaces: %v", err)
+		}
+		for cmd, ns := range pairs {
+			if !promMetricName.MatchString(ns) {
+				return fmt.Errorf("invalid prometheus namespace %q of %s", ns, cmd)
+			}
+		}
+		s.promComponents = pairs
+
+		if pairs, err = parsePromPairs(labels); err != nil {
+			return fmt.Errorf("invalid prometheus labels: %v", err)
+		}
+		for name := range pairs {
+			if !promLabelName.MatchString(name) || strings.HasPrefix(name, "__") {
+				return fmt.Errorf("invalid prometheus label name %q", name)
+			}
+		}
+		s.promLabels = pairs
+		return nil
+	}
+}
+
+var (
+	promMetricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	promLabelName  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// parsePromPairs parses comma separated key=value pairs
+func parsePromPairs(s string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid pair %q, expected key=value", pair)
+		}
+		pairs[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return pairs, nil
+}
+
 // prometheus only allows [a-zA-Z0-9:_] in metrics names.
 func promSanitizeMetricName(name string) string {
 	res := make([]rune, 0, len(name))
@@ -746,7 +1493,8 @@ func promSanitizeMetricName(name string) string {
 func getMonitoredCmdNames() []string {
 
 	// override? empty variable to disable trackers
-	val, ok := os.LookupEnv(EnvProcessCollectorList)
+	val, ok := config.Lookup(EnvProcessCollectorList)
+	config.Record(EnvProcessCollectorList, val, ok)
 	if ok {
 		return strings.Fields(val)
 	}
@@ -842,15 +1590,9 @@ func (s *Server) Start(ctx context.Context) {
 
 func (s *Server) startGears(ctx context.Context, cancel context.CancelFunc) {
 
-	logrus.Info(`
-        ______
-       / ____/___
-      / /_  / __ \
-     / __/ / / / /
-    /_/   /_/ /_/
-`)
-
-	logrus.WithFields(logrus.Fields{"type": s.nodeType, "version": version.Version}).Infof("Fn serving on `%v`", s.svcConfigs[WebServer].Addr)
+	// the capability report rather than a banner, so that tooling reading logs can tell what the deployment runs
+	caps, _ := json.Marshal(s.Capabilities())
+	logrus.WithFields(logrus.Fields{"type": s.nodeType, "version": version.Version, "capabilities": string(caps)}).Infof("Fn serving on `%v`", s.svcConfigs[WebServer].Addr)
 
 	installChildReaper()
 
@@ -858,6 +1600,8 @@ func (s *Server) startGears(ctx context.Context, cancel context.CancelFunc) {
 	if server.Handler == nil {
 		server.Handler = &ochttp.Handler{
 			Handler: s.Router,
+			// spans continue the W3C trace context of requests carrying one, or their b3 headers
+			Propagation: common.TraceContextFormat{Fallback: &b3.HTTPFormat{}},
 			GetStartOptions: func(r *http.Request) trace.StartOptions {
 				startOptions := trace.StartOptions{}
 				// TODO: Add list of url paths to exclude
@@ -869,14 +1613,27 @@ func (s *Server) startGears(ctx context.Context, cancel context.CancelFunc) {
 			// TODO: add FormatSpanName to clean up trace exporter operations dash
 		}
 	}
+	if err := s.configureHTTP2(server); err != nil {
+		logrus.WithError(err).Fatal("invalid http2 config")
+	}
+	if !s.noWebServer {
+		if err := s.configureHTTP3(server); err != nil {
+			logrus.WithError(err).Fatal("invalid http3 config")
+		}
+		if err := s.serveHTTP3(cancel); err != nil {
+			logrus.WithError(err).Fatal("cannot serve http3")
+		}
+	}
 
 	if !s.noWebServer {
 		go func() {
-			var err error
-			if server.TLSConfig != nil {
-				err = server.ListenAndServeTLS("", "")
-			} else {
-				err = server.ListenAndServe()
+			l, err := s.listen(server)
+			if err == nil {
+				if server.TLSConfig != nil {
+					err = server.ServeTLS(l, "", "")
+				} else {
+					err = server.Serve(l)
+				}
 			}
 			if err != nil && err != http.ErrServerClosed {
 				logrus.WithError(err).Error("server error")
@@ -916,6 +1673,26 @@ func (s *Server) startGears(ctx context.Context, cancel context.CancelFunc) {
 		}()
 	}
 
+	if s.usage != nil {
+		go s.usage.run(ctx)
+	}
+
+	if s.cron != nil {
+		go s.cron.run(ctx)
+	}
+
+	if s.callQueue != nil && s.asyncCalls != nil {
+		go s.consumeCalls(ctx)
+	}
+
+	if len(s.sweeps) > 0 {
+		s.runSweeps(ctx)
+	}
+
+	if s.grpcInvokeServer != nil {
+		s.serveGRPCInvoke(cancel)
+	}
+
 	// listening for signals or listener errors or cancellations on all registered contexts.
 	s.extraCtxs = append(s.extraCtxs, ctx)
 	cases := make([]reflect.SelectCase, len(s.extraCtxs))
@@ -935,29 +1712,38 @@ func (s *Server) startGears(ctx context.Context, cancel context.CancelFunc) {
 	}
 
 	if !s.noWebServer {
-		// TODO: do not wait forever during graceful shutdown (add graceful shutdown timeout)
-		if err := server.Shutdown(context.Background()); err != nil {
-			logrus.WithError(err).Error("server shutdown error")
-		}
+		s.shutdown(server)
+	} else {
+		s.stopGRPCInvoke()
+		s.closeAgent()
 	}
 
-	if s.agent != nil {
-		err := s.agent.Close() // after we stop taking requests, wait for all tasks to finish
-		if err != nil {
-			logrus.WithError(err).Error("Fail to close the agent")
-		}
+	if s.usage != nil {
+		s.usage.flush(context.Background())
+	}
+	if s.callQueue != nil {
+		s.callQueue.Close()
+	}
+	if s.otlpExporter != nil {
+		s.otlpExporter.Close()
 	}
-}
-
-func (s *Server) goneResponse(c *gin.Context) {
-	c.Status(http.StatusGone)
 }
 
 func (s *Server) bindHandlers(ctx context.Context) {
 	engine := s.Router
 	admin := s.AdminRouter
+	if s.errorCatalog != nil {
+		engine.Use(s.errorCatalogMiddleware)
+		admin.Use(s.errorCatalogMiddleware)
+	}
+	engine.Use(s.drainMiddleware)
+	// requests are limited even without limits, as they may be set at runtime
+	engine.Use(s.requestLimitMiddleware)
 	// now for extensible middleware
 	engine.Use(s.rootMiddlewareWrapper())
+	if s.knativeDomain != "" && (s.nodeType == ServerTypeFull || s.nodeType == ServerTypeLB) {
+		engine.Use(s.invokeCompressionWrap, s.handleKnativeCall)
+	}
 
 	engine.GET("/", handlePing)
 	admin.GET("/version", handleVersion)
@@ -970,6 +1756,73 @@ func (s *Server) bindHandlers(ctx context.Context) {
 		profilerSetup(admin, "/debug")
 	}
 
+	// the admin endpoints of fn, other than the version, metrics and profiler served before them
+	v2admin := admin.Group("/v2/admin")
+	if _, ok := s.agent.(agent.CrashReporter); ok {
+		v2admin.GET("/diagnostics/crashes", s.handleContainerCrashes)
+	}
+	if s.placements != nil && s.placements.path != "" {
+		v2admin.GET("/diagnostics/placements", s.handlePlacementList)
+	}
+	if _, ok := s.agent.(agent.SlotReporter); ok {
+		v2admin.GET("/slots", s.handleSlotQueues)
+	}
+	v2admin.GET("/capabilities", s.handleCapabilities)
+	if _, ok := s.agent.(agent.ImageCacheManager); ok {
+		v2admin.GET("/images", s.handleImageList)
+	}
+
+	if s.adminToken != "" {
+		// the settings in effect tell how the deployment is set up, they are served to admins only
+		v2admin.GET("/config", s.requireAdminToken, s.handleConfig)
+
+		tuning := v2admin.Group("/config/runtime", s.requireAdminToken)
+		tuning.GET("", s.handleTunables)
+		tuning.PUT("/:name", s.handleTune)
+
+		runnerTokens := v2admin.Group("/runner/tokens", s.requireAdminToken)
+		runnerTokens.GET("", s.handleRunnerTokenList)
+		runnerTokens.POST("", s.handleRunnerTokenIssue)
+		runnerTokens.PUT("", s.handleRunnerTokenAdd)
+		runnerTokens.POST("/:token_id/promote", s.handleRunnerTokenPromote)
+		runnerTokens.DELETE("/:token_id", s.handleRunnerTokenRevoke)
+
+		if _, ok := s.agent.(agent.RegistryCAManager); ok {
+			registryCAs := v2admin.Group("/registry/cas", s.requireAdminToken)
+			registryCAs.GET("", s.handleRegistryCAList)
+			registryCAs.PUT("/:registry", s.handleRegistryCAPut)
+			registryCAs.DELETE("/:registry", s.handleRegistryCADelete)
+		}
+
+		if _, ok := s.agent.(agent.FaultInjector); ok {
+			faults := v2admin.Group("/faults", s.requireAdminToken)
+			faults.GET("", s.handleFaultList)
+			faults.POST("", s.handleFaultAdd)
+			faults.DELETE("/:fault_id", s.handleFaultRemove)
+		}
+
+		if s.nodeType == ServerTypeFull || s.nodeType == ServerTypeLB {
+			v2admin.POST("/benchmarks", s.requireAdminToken, s.handleBenchmark)
+		}
+
+		images := v2admin.Group("/images", s.requireAdminToken)
+		if _, ok := s.agent.(agent.ImageCacheManager); ok {
+			images.PUT("/pins", s.handleImagePin)
+			images.DELETE("/pins", s.handleImageUnpin)
+			images.DELETE("", s.handleImageEvict)
+		}
+		if _, ok := s.agent.(agent.ImageLoader); ok {
+			images.POST("/load", s.handleImageLoad)
+		}
+		if _, ok := s.agent.(agent.ImagePrePuller); ok {
+			images.POST("/pulls", s.handleImagePrePull)
+		}
+	}
+
+	for _, path := range legacyAdminPaths {
+		admin.Any(path, redirectToV2Admin)
+	}
+
 	// Pure runners don't have any route, they have grpc
 	switch s.nodeType {
 
@@ -983,28 +1836,63 @@ func (s *Server) bindHandlers(ctx context.Context) {
 			v2.POST("/apps", s.handleAppCreate)
 			v2.GET("/apps/:app_id", s.handleAppGet)
 			v2.PUT("/apps/:app_id", s.handleAppUpdate)
+			v2.PATCH("/apps/:app_id", s.handleAppPatch)
 			v2.DELETE("/apps/:app_id", s.handleAppDelete)
+			v2.POST("/apps/:app_id/clone", s.handleAppClone)
 
 			v2.GET("/fns", s.handleFnList)
 			v2.POST("/fns", s.handleFnCreate)
 			v2.GET("/fns/:fn_id", s.handleFnGet)
 			v2.PUT("/fns/:fn_id", s.handleFnUpdate)
+			v2.PATCH("/fns/:fn_id", s.handleFnPatch)
 			v2.DELETE("/fns/:fn_id", s.handleFnDelete)
+			v2.POST("/fns/:fn_id/clone", s.handleFnClone)
+			v2.GET("/fns/:fn_id/deployments", s.handleFnDeploymentList)
+			v2.GET("/fns/:fn_id/deployments/:deployment_id", s.handleFnDeploymentGet)
+
+			v2.GET("/templates", s.handleTemplateList)
+			v2.GET("/templates/:template_name", s.handleTemplateGet)
+			v2.POST("/templates/:template_name/apps", s.handleTemplateAppCreate)
+			v2.POST("/templates/:template_name/fns", s.handleTemplateFnCreate)
 
 			v2.GET("/triggers", s.handleTriggerList)
 			v2.POST("/triggers", s.handleTriggerCreate)
 			v2.GET("/triggers/:trigger_id", s.handleTriggerGet)
 			v2.PUT("/triggers/:trigger_id", s.handleTriggerUpdate)
+			v2.PATCH("/triggers/:trigger_id", s.handleTriggerPatch)
 			v2.DELETE("/triggers/:trigger_id", s.handleTriggerDelete)
+
+			v2.GET("/triggers/:trigger_id/runs", s.handleTriggerRunList)
+			v2.GET("/triggers/:trigger_id/runs/:run_id", s.handleTriggerRunGet)
+			v2.POST("/triggers/:trigger_id/runs/:run_id/rerun", s.handleTriggerRunRerun)
 		}
 
-		// TODO remove these in 30 days or something
-		v2.GET("/fns/:fn_id/calls", s.goneResponse)
-		v2.GET("/fns/:fn_id/calls/:call_id", s.goneResponse)
-		v2.GET("/fns/:fn_id/calls/:call_id/log", s.goneResponse)
+		v2.GET("/fns/:fn_id/calls", s.handleCallList)
+		v2.GET("/fns/:fn_id/calls/:call_id", s.handleCallGet)
+		v2.GET("/fns/:fn_id/calls/:call_id/input", s.handleCallInputGet)
+		v2.POST("/fns/:fn_id/calls/:call_id/replay", s.handleCallReplay)
+		v2.GET("/fns/:fn_id/calls/:call_id/log/stream", s.handleCallLogStream)
+		// TODO remove this in 30 days or something
+		v2.GET("/fns/:fn_id/calls/:call_id/log", s.handleCallLogGet)
+
+		v2.GET("/workflows", s.handleWorkflowList)
+		v2.POST("/workflows", s.handleWorkflowCreate)
+		v2.GET("/workflows/:workflow_id", s.handleWorkflowGet)
+		v2.DELETE("/workflows/:workflow_id", s.handleWorkflowDelete)
+		v2.GET("/workflows/:workflow_id/runs", s.handleWorkflowRunList)
+		v2.POST("/workflows/:workflow_id/runs", s.handleWorkflowRunCreate)
+		v2.GET("/workflows/:workflow_id/runs/:run_id", s.handleWorkflowRunGet)
 
 		// TODO figure out how to deprecate
-		runner := cleanv2.Group("/runner")
+		if s.builder != nil {
+			buildHandlers := []gin.HandlerFunc{s.handleFnBuild}
+			if s.maxBuildContextSize > 0 {
+				buildHandlers = append([]gin.HandlerFunc{limitRequestBody(s.maxBuildContextSize)}, buildHandlers...)
+			}
+			v2.POST("/fns/:fn_id/build", buildHandlers...)
+		}
+
+		runner := cleanv2.Group("/runner", s.requireRunnerToken)
 		runnerAppAPI := runner.Group("/apps/:app_id")
 		runnerAppAPI.GET("/triggerBySource/:trigger_type/*trigger_source", s.handleRunnerGetTriggerBySource)
 	}
@@ -1012,14 +1900,39 @@ func (s *Server) bindHandlers(ctx context.Context) {
 	switch s.nodeType {
 	case ServerTypeFull, ServerTypeLB:
 		if !s.noHTTTPTriggerEndpoint {
-			lbTriggerGroup := engine.Group("/t")
+			lbTriggerGroup := engine.Group("/t", s.invokeCompressionWrap)
 			lbTriggerGroup.Any("/:app_name", s.handleHTTPTriggerCall)
 			lbTriggerGroup.Any("/:app_name/*trigger_source", s.handleHTTPTriggerCall)
 		}
 
 		if !s.noFnInvokeEndpoint {
-			lbFnInvokeGroup := engine.Group("/invoke")
+			lbFnInvokeGroup := engine.Group("/invoke", s.invokeCompressionWrap)
 			lbFnInvokeGroup.POST("/:fn_id", s.handleFnInvokeCall)
+			lbFnInvokeGroup.POST("/:fn_id/batch", s.handleFnInvokeBatch)
+		}
+
+		if s.openFaaS {
+			openFaaSGroup := engine.Group("", s.invokeCompressionWrap)
+			openFaaSGroup.Any("/function/:function_name", s.handleOpenFaaSCall)
+			openFaaSGroup.Any("/function/:function_name/*path", s.handleOpenFaaSCall)
+			openFaaSGroup.POST("/async-function/:function_name", s.handleOpenFaaSCall)
+			openFaaSGroup.POST("/async-function/:function_name/*path", s.handleOpenFaaSCall)
+		}
+
+		if s.lambda {
+			lambdaGroup := engine.Group(lambdaInvokePrefix, s.invokeCompressionWrap)
+			lambdaGroup.POST("/:function_name/invocations", s.handleLambdaInvoke)
+		}
+
+		warmup := engine.Group("/v2")
+		warmup.Use(s.apiMiddlewareWrapper())
+		warmup.POST("/fns/:fn_id/warmup", s.handleFnWarmup)
+
+		if s.usage != nil {
+			usage := engine.Group("/v2")
+			usage.Use(s.apiMiddlewareWrapper())
+			usage.GET("/usage", s.handleUsageList)
+			usage.GET("/apps/:app_id/usage", s.handleAppUsage)
 		}
 	}
 
@@ -51,6 +51,7 @@ func EnableShutdownEndpoint(ctx context.Context, halt context.CancelFunc) Option
 // LimitRequestBody wraps every http request to limit its size to the specified max bytes.
 func LimitRequestBody(max int64) Option {
 	return func(ctx context.Context, s *Server) error {
+		s.maxRequestSize = max
 		if max > 0 {
 			s.Router.Use(limitRequestBody(max))
 		}
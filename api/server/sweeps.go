@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// sweepLockTTL is how long the server running a sweep holds the lock electing it
+	sweepLockTTL = time.Minute
+	// callInputSweepInterval is how often the recorded inputs of calls that expired are removed
+	callInputSweepInterval = 10 * time.Minute
+	// callLogSweepInterval is how often the call logs that expired are removed
+	callLogSweepInterval = 10 * time.Minute
+	// triggerRunSweepInterval is how often the trigger runs that expired are removed
+	triggerRunSweepInterval = 10 * time.Minute
+	// workflowRunSweepInterval is how often the workflow runs that were lost are failed
+	workflowRunSweepInterval = time.Minute
+)
+
+// sweep periodically removes the records of the datastore that expired. The servers sharing the datastore elect
+// the one running each sweep with a lock of their LockStore.
+type sweep struct {
+	name     string
+	interval time.Duration
+	remove   func(ctx context.Context, now time.Time) (int, error)
+}
+
+// addSweep runs remove every interval on one of the servers sharing the datastore
+func (s *Server) addSweep(name string, interval time.Duration, remove func(ctx context.Context, now time.Time) (int, error)) {
+	s.sweeps = append(s.sweeps, sweep{name: name, interval: interval, remove: remove})
+}
+
+// runSweeps runs the sweeps the server is elected for until ctx is done
+func (s *Server) runSweeps(ctx context.Context) {
+	for _, sw := range s.sweeps {
+		lock := common.NewLock(s.LockStore(), "sweep-"+sw.name, common.NewLockHolder(), sweepLockTTL)
+		go common.Elect(ctx, lock, sw.run)
+	}
+}
+
+func (sw sweep) run(ctx context.Context) {
+	log := common.Logger(ctx).WithField("sweep", sw.name)
+	ticker := time.NewTicker(sw.interval)
+	defer ticker.Stop()
+	for {
+		n, err := sw.remove(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			log.WithError(err).Error("sweep failed")
+		} else if n > 0 {
+			log.WithFields(logrus.Fields{"removed": n}).Info("swept expired records")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// WithTemplates adds named templates of apps and fns, which users list and create apps and fns from
+func WithTemplates(templates []*models.Template) Option {
+	return func(ctx context.Context, s *Server) error {
+		for _, t := range templates {
+			if err := t.Validate(); err != nil {
+				return err
+			}
+			if _, ok := s.templates[t.Name]; ok {
+				return fmt.Errorf("duplicate template %s", t.Name)
+			}
+			if s.templates == nil {
+				s.templates = make(map[string]*models.Template)
+			}
+			s.templates[t.Name] = t
+			s.templateNames = append(s.templateNames, t.Name)
+		}
+		return nil
+	}
+}
+
+// templatesFromEnv reads the templates for WithTemplates from EnvTemplates
+func templatesFromEnv() ([]*models.Template, error) {
+	v := getEnv(EnvTemplates, "")
+	if v == "" {
+		return nil, nil
+	}
+	var templates []*models.Template
+	if err := json.Unmarshal([]byte(v), &templates); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", EnvTemplates, err)
+	}
+	return templates, nil
+}
+
+func (s *Server) template(c *gin.Context) (*models.Template, error) {
+	t, ok := s.templates[c.Param("template_name")]
+	if !ok {
+		return nil, models.ErrTemplateNotFound
+	}
+	return t, nil
+}
+
+func (s *Server) handleTemplateList(c *gin.Context) {
+	list := &models.TemplateList{Items: make([]*models.Template, 0, len(s.templateNames))}
+	for _, name := range s.templateNames {
+		list.Items = append(list.Items, s.templates[name])
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (s *Server) handleTemplateGet(c *gin.Context) {
+	t, err := s.template(c)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// handleTemplateAppCreate creates an app from the app of a template, with the name and overrides of the request
+func (s *Server) handleTemplateAppCreate(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	t, err := s.template(c)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	overrides := &models.App{}
+	if err := c.BindJSON(overrides); err != nil {
+		if !models.IsAPIError(err) {
+			err = models.ErrInvalidJSON
+		}
+		handleErrorResponse(c, err)
+		return
+	}
+
+	app, err := t.NewApp(overrides)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	app, err = s.datastore.InsertApp(ctx, app)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, app)
+}
+
+// handleTemplateFnCreate creates a fn from the fn of a template, with the name, app and overrides of the request
+func (s *Server) handleTemplateFnCreate(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	t, err := s.template(c)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	overrides := &models.Fn{}
+	if err := c.BindJSON(overrides); err != nil {
+		if !models.IsAPIError(err) {
+			err = models.ErrInvalidJSON
+		}
+		handleErrorResponse(c, err)
+		return
+	}
+
+	fn, err := t.NewFn(overrides)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	fn.SetDefaults()
+	fnCreated, err := s.datastore.InsertFn(ctx, fn)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	s.writeCreatedFn(c, fnCreated)
+}
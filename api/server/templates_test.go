@@ -0,0 +1,137 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func templatesTestServer(t *testing.T, ds models.Datastore) (*Server, *gin.Engine) {
+	defer envTweaker(EnvTemplates, `[
+		{"name": "standard", "description": "the standard fn", "fn": {"image": "fnproject/fn-test-utils", "memory": 256, "config": {"LOG_LEVEL": "info"}, "annotations": {"example.com/team": "platform"}}},
+		{"name": "standard-app", "app": {"config": {"REGION": "eu"}}}
+	]`)()
+	templates, err := templatesFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{datastore: ds, fnAnnotator: NewRequestBasedFnAnnotator()}
+	if err := WithTemplates(templates)(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	engine := gin.New()
+	engine.GET("/v2/templates", s.handleTemplateList)
+	engine.GET("/v2/templates/:template_name", s.handleTemplateGet)
+	engine.POST("/v2/templates/:template_name/apps", s.handleTemplateAppCreate)
+	engine.POST("/v2/templates/:template_name/fns", s.handleTemplateFnCreate)
+	engine.POST("/v2/apps/:app_id/clone", s.handleAppClone)
+	engine.POST("/v2/fns/:fn_id/clone", s.handleFnClone)
+	return s, engine
+}
+
+func templatesRequest(t *testing.T, engine *gin.Engine, method, path, body string, expectedCode int, v interface{}) {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	if rec.Code != expectedCode {
+		t.Fatalf("%s %s: expected status code %d, got %d: %s", method, path, expectedCode, rec.Code, rec.Body.String())
+	}
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "myapp"}
+	ds := datastore.NewMockInit([]*models.App{app})
+	_, engine := templatesTestServer(t, ds)
+
+	var list models.TemplateList
+	templatesRequest(t, engine, http.MethodGet, "/v2/templates", "", http.StatusOK, &list)
+	if len(list.Items) != 2 || list.Items[0].Name != "standard" || list.Items[1].Name != "standard-app" {
+		t.Fatalf("expected the templates in order, got %+v", list.Items)
+	}
+	templatesRequest(t, engine, http.MethodGet, "/v2/templates/nope", "", http.StatusNotFound, nil)
+
+	var fn models.Fn
+	templatesRequest(t, engine, http.MethodPost, "/v2/templates/standard/fns",
+		`{"app_id": "app_id", "name": "hello", "config": {"GREETING": "hi"}}`, http.StatusOK, &fn)
+	if fn.ID == "" || fn.Name != "hello" || fn.AppID != app.ID || fn.Memory != 256 || fn.Image != "fnproject/fn-test-utils" {
+		t.Errorf("expected a fn from the template, got %+v", fn)
+	}
+	if fn.Config["LOG_LEVEL"] != "info" || fn.Config["GREETING"] != "hi" {
+		t.Errorf("expected the config of the template and of the request, got %v", fn.Config)
+	}
+	if team, err := fn.Annotations.GetString("example.com/team"); err != nil || team != "platform" {
+		t.Errorf("expected the annotations of the template, got %v", fn.Annotations)
+	}
+
+	var newApp models.App
+	templatesRequest(t, engine, http.MethodPost, "/v2/templates/standard-app/apps", `{"name": "other"}`, http.StatusOK, &newApp)
+	if newApp.ID == "" || newApp.Name != "other" || newApp.Config["REGION"] != "eu" {
+		t.Errorf("expected an app from the template, got %+v", newApp)
+	}
+	templatesRequest(t, engine, http.MethodPost, "/v2/templates/standard-app/fns", `{"app_id": "app_id", "name": "x"}`, http.StatusBadRequest, nil)
+	templatesRequest(t, engine, http.MethodPost, "/v2/templates/standard/fns", `{"app_id": "app_id"}`, http.StatusBadRequest, nil)
+}
+
+func TestClone(t *testing.T) {
+	app := &models.App{ID: "app_id", Name: "myapp", Config: models.Config{"A": "1"}}
+	first := &models.Fn{ID: "fn1", AppID: app.ID, Name: "first", Image: "fnproject/fn-test-utils", Chain: &models.FnChain{OnSuccess: "fn2"}}
+	second := &models.Fn{ID: "fn2", AppID: app.ID, Name: "second", Image: "fnproject/fn-test-utils"}
+	first.SetDefaults()
+	second.SetDefaults()
+	trigger := &models.Trigger{ID: "trigger_id", AppID: app.ID, FnID: second.ID, Name: "t", Type: "http", Source: "/second"}
+	ds := datastore.NewMockInit([]*models.App{app}, []*models.Fn{first, second}, []*models.Trigger{trigger})
+	_, engine := templatesTestServer(t, ds)
+	ctx := context.Background()
+
+	var fn models.Fn
+	templatesRequest(t, engine, http.MethodPost, "/v2/fns/fn2/clone", `{"name": "third", "memory": 512}`, http.StatusOK, &fn)
+	if fn.ID == "" || fn.ID == second.ID || fn.Name != "third" || fn.AppID != app.ID || fn.Memory != 512 || fn.Image != second.Image {
+		t.Errorf("expected a copy of the fn with the overrides, got %+v", fn)
+	}
+	templatesRequest(t, engine, http.MethodPost, "/v2/fns/fn2/clone", `{"name": "second"}`, http.StatusConflict, nil)
+	templatesRequest(t, engine, http.MethodPost, "/v2/fns/nope/clone", `{"name": "x"}`, http.StatusNotFound, nil)
+
+	var clone models.App
+	templatesRequest(t, engine, http.MethodPost, "/v2/apps/app_id/clone", `{"name": "copy", "config": {"B": "2"}}`, http.StatusOK, &clone)
+	if clone.ID == "" || clone.Name != "copy" || clone.Config["A"] != "1" || clone.Config["B"] != "2" {
+		t.Errorf("expected a copy of the app with the overrides, got %+v", clone)
+	}
+
+	fns, err := ds.GetFns(ctx, &models.FnFilter{AppID: clone.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]*models.Fn)
+	for _, f := range fns.Items {
+		byName[f.Name] = f
+	}
+	if len(byName) != 3 || byName["first"] == nil || byName["second"] == nil {
+		t.Fatalf("expected copies of the fns of the app, got %+v", fns.Items)
+	}
+	if byName["first"].Chain == nil || byName["first"].Chain.OnSuccess != byName["second"].ID {
+		t.Errorf("expected the chain of the copy to be to the copy of its fn, got %+v", byName["first"].Chain)
+	}
+
+	triggers, err := ds.GetTriggers(ctx, &models.TriggerFilter{AppID: clone.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers.Items) != 1 || triggers.Items[0].FnID != byName["second"].ID || triggers.Items[0].Source != "/second" {
+		t.Errorf("expected a copy of the trigger, to the copy of its fn, got %+v", triggers.Items)
+	}
+
+	templatesRequest(t, engine, http.MethodPost, "/v2/apps/app_id/clone", `{"name": "myapp"}`, http.StatusConflict, nil)
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// WithHeaderPolicy sets which request headers http triggers pass to fns, and which response headers of fns they
+// pass back. Triggers may narrow it with their models.TriggerHeadersAnnotation.
+func WithHeaderPolicy(policy models.HeaderPolicy) Option {
+	return func(ctx context.Context, s *Server) error {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+		s.headerPolicy = policy
+		return nil
+	}
+}
+
+func headerPolicyFromEnv() models.HeaderPolicy {
+	filter := func(allowKey, denyKey string) *models.HeaderFilter {
+		allow, deny := headerList(getEnv(allowKey, "")), headerList(getEnv(denyKey, ""))
+		if len(allow) == 0 && len(deny) == 0 {
+			return nil
+		}
+		return &models.HeaderFilter{Allow: allow, Deny: deny}
+	}
+	return models.HeaderPolicy{
+		Request:  filter(EnvRequestHeadersAllow, EnvRequestHeadersDeny),
+		Response: filter(EnvResponseHeadersAllow, EnvResponseHeadersDeny),
+	}
+}
+
+// headerList splits a list of header names separated by commas or spaces
+func headerList(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// filterHeaders removes the headers of hdr that do not pass all of filters
+func filterHeaders(hdr http.Header, filters ...*models.HeaderFilter) {
+	for k := range hdr {
+		for _, f := range filters {
+			if !f.Passes(k) {
+				delete(hdr, k)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestHeaderList(t *testing.T) {
+	if list := headerList("Cookie, Fn-* X-Acme-Secret"); !reflect.DeepEqual(list, []string{"Cookie", "Fn-*", "X-Acme-Secret"}) {
+		t.Fatalf("unexpected list %v", list)
+	}
+}
+
+func TestFilterHeaders(t *testing.T) {
+	hdr := http.Header{}
+	hdr.Set("Accept", "text/plain")
+	hdr.Set("Cookie", "session=1")
+	hdr.Set("Fn-Call-Id", "spoofed")
+	hdr.Set("X-Acme-Tenant", "acme")
+
+	server := &models.HeaderFilter{Deny: []string{"Fn-*"}}
+	trigger := &models.HeaderFilter{Allow: []string{"Accept", "X-Acme-*"}}
+	filterHeaders(hdr, server, trigger, nil)
+
+	if len(hdr) != 2 || hdr.Get("Accept") == "" || hdr.Get("X-Acme-Tenant") == "" {
+		t.Fatalf("expected only the headers passing both filters, got %v", hdr)
+	}
+}
+
+func TestTriggerResponseHeaders(t *testing.T) {
+	rec := httptest.NewRecorder()
+	trw := &triggerResponseWriter{inner: rec, filters: []*models.HeaderFilter{
+		{Deny: []string{"Fn-*"}},
+		{Deny: []string{"X-Internal"}},
+	}}
+	trw.Header().Set("Fn-Http-H-X-Internal", "debug")
+	trw.Header().Set("Fn-Http-H-X-Other", "kept")
+	trw.Header().Set("Fn-Http-H-Connection", "close")
+	trw.Header().Set("Fn-Call-Id", "call")
+	trw.Header().Set("Content-Type", "text/plain")
+	trw.WriteHeader(http.StatusOK)
+
+	expected := http.Header{"X-Other": {"kept"}, "Content-Type": {"text/plain"}}
+	if !reflect.DeepEqual(rec.Header(), expected) {
+		t.Fatalf("expected headers %v, got %v", expected, rec.Header())
+	}
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+)
+
+// triggerRuns records each firing of the triggers with a runs policy as a
+// run in the datastore, with the payload it fired with so that it can be
+// re-run later. Payloads are redacted and encrypted as call inputs are, and
+// runs are removed by the trigger-runs sweep once they expire.
+type triggerRuns struct {
+	ds        func() models.Datastore
+	keys      func() agent.KeyProvider
+	redactors func() []fnext.CallInputRedactor
+
+	// recorded holds the ids of the calls whose runs were recorded, until
+	// they end
+	recorded sync.Map
+}
+
+var _ fnext.CallListener = new(triggerRuns)
+
+// capture reads the start of the body of req, to record with its run if
+// policy records runs. The body is left for the fn to read as it was.
+func (r *triggerRuns) capture(req *http.Request, policy *models.TriggerRuns) (payload []byte, replayable bool) {
+	if r == nil || policy == nil {
+		return nil, false
+	}
+	if req.Body == nil {
+		return nil, true
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(req.Body, models.MaxTriggerRunPayload+1))
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), req.Body), req.Body}
+	if err != nil || len(buf) > models.MaxTriggerRunPayload {
+		return nil, false
+	}
+	return buf, true
+}
+
+// start records the firing of trigger as call, before it is submitted to the
+// agent, if policy records runs
+func (r *triggerRuns) start(ctx context.Context, req *http.Request, trigger *models.Trigger, policy *models.TriggerRuns, call *models.Call, payload []byte, replayable bool) {
+	if r == nil || policy == nil {
+		return
+	}
+	method := req.Header.Get("Fn-Http-Method")
+	if method == "" {
+		method = req.Method
+	}
+	url := req.Header.Get("Fn-Http-Request-Url")
+	if url == "" {
+		url = req.URL.String()
+	}
+	run := &models.TriggerRun{
+		ID:          call.ID,
+		TriggerID:   trigger.ID,
+		AppID:       call.AppID,
+		FnID:        call.FnID,
+		Status:      models.CallStateRunning,
+		Method:      method,
+		URL:         url,
+		ContentType: req.Header.Get("Content-Type"),
+		CreatedAt:   call.CreatedAt,
+		ExpiresAt:   common.DateTime(time.Now().Add(policy.Duration())),
+	}
+	if replayable {
+		var err error
+		run.Payload, run.PayloadKey, err = r.seal(ctx, call, run.ContentType, payload, policy)
+		if err != nil {
+			// the run is recorded without its payload, it cannot be re-run
+			common.Logger(ctx).WithError(err).WithField("trigger_id", trigger.ID).Info("trigger run payload not recorded")
+			run.Payload, run.PayloadKey = nil, ""
+		} else {
+			run.Replayable = true
+		}
+	}
+
+	if err := r.ds().InsertTriggerRun(ctx, run); err != nil {
+		// the history is for operators, it must not fail the call
+		common.Logger(ctx).WithError(err).WithField("trigger_id", trigger.ID).Error("failed to record trigger run")
+		return
+	}
+	r.recorded.Store(call.ID, struct{}{})
+}
+
+// seal redacts the fields of payload that policy and the redactors redact,
+// and encrypts it, if there are keys to encrypt it with
+func (r *triggerRuns) seal(ctx context.Context, call *models.Call, contentType string, payload []byte, policy *models.TriggerRuns) (_ []byte, key string, err error) {
+	if len(payload) == 0 {
+		return nil, "", nil
+	}
+	if len(policy.Redact) > 0 {
+		if payload, err = redactJSONFields(contentType, payload, policy.Redact); err != nil {
+			return nil, "", err
+		}
+	}
+	if r.redactors != nil {
+		for _, redactor := range r.redactors() {
+			if payload, err = redactor.RedactCallInput(ctx, call, contentType, payload); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if r.keys != nil {
+		if keys := r.keys(); keys != nil {
+			return sealCallInput(ctx, keys, call.ID, payload)
+		}
+	}
+	return payload, "", nil
+}
+
+// open returns the recorded payload of run, decrypting it if it was encrypted
+func (r *triggerRuns) open(ctx context.Context, run *models.TriggerRun) ([]byte, error) {
+	if run.PayloadKey == "" {
+		return run.Payload, nil
+	}
+	var keys agent.KeyProvider
+	if r != nil && r.keys != nil {
+		keys = r.keys()
+	}
+	payload, err := openSealed(ctx, keys, run.ID, run.PayloadKey, run.Payload)
+	if err != nil {
+		common.Logger(ctx).WithError(err).WithField("call_id", run.ID).Error("failed to decrypt trigger run payload")
+		return nil, models.ErrTriggerRunPayloadUnavailable
+	}
+	return payload, nil
+}
+
+// abort ends the run of a call that the agent refused or failed to start.
+// Calls that did start are ended by AfterCall.
+func (r *triggerRuns) abort(ctx context.Context, call *models.Call, err error) {
+	if r == nil || !time.Time(call.StartedAt).IsZero() {
+		return
+	}
+	if _, ok := r.recorded.Load(call.ID); !ok {
+		return
+	}
+	r.recorded.Delete(call.ID)
+	r.end(ctx, &models.TriggerRun{
+		ID:          call.ID,
+		Status:      models.CallStateFailed,
+		Error:       err.Error(),
+		CompletedAt: common.DateTime(time.Now()),
+	})
+}
+
+func (r *triggerRuns) end(ctx context.Context, run *models.TriggerRun) {
+	if err := r.ds().UpdateTriggerRun(ctx, run); err != nil {
+		common.Logger(ctx).WithError(err).WithField("call_id", run.ID).Error("failed to update trigger run")
+	}
+}
+
+// BeforeCall implements fnext.CallListener
+func (r *triggerRuns) BeforeCall(ctx context.Context, call *models.Call) error {
+	return nil
+}
+
+// AfterCall records how the call a trigger made ended
+func (r *triggerRuns) AfterCall(ctx context.Context, call *models.Call) error {
+	if _, ok := r.recorded.Load(call.ID); !ok {
+		return nil
+	}
+	r.recorded.Delete(call.ID)
+	run := &models.TriggerRun{ID: call.ID, CompletedAt: call.CompletedAt}
+	run.Status, run.Error = callEndState(call)
+	r.end(common.BackgroundContext(ctx), run)
+	return nil
+}
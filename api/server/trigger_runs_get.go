@@ -0,0 +1,24 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleTriggerRunGet(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	run, err := s.datastore.GetTriggerRun(ctx, c.Param(api.TriggerID), c.Param(api.TriggerRunID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	if run.Payload, err = s.triggerRuns.open(ctx, run); err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleTriggerRunList(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var filter models.TriggerRunFilter
+	filter.Cursor, filter.PerPage = pageParams(c)
+	filter.TriggerID = c.Param(api.TriggerID)
+	filter.Status = c.Query("status")
+
+	runs, err := s.datastore.GetTriggerRuns(ctx, &filter)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// handleTriggerRunRerun fires a trigger again with the payload of a past run,
+// returning the new run once its call has ended. Only the content type of the
+// original request is replayed, its other headers were not recorded. The
+// trigger must still record its runs, for the new run to be recorded.
+func (s *Server) handleTriggerRunRerun(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if s.triggerRuns == nil {
+		handleErrorResponse(c, models.ErrTriggerRunsUnsupported)
+		return
+	}
+
+	trigger, err := s.datastore.GetTriggerByID(ctx, c.Param(api.TriggerID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	if runs, err := trigger.Runs(); err != nil {
+		handleErrorResponse(c, err)
+		return
+	} else if runs == nil {
+		handleErrorResponse(c, models.ErrTriggerRunsNotRecorded)
+		return
+	}
+	run, err := s.datastore.GetTriggerRun(ctx, trigger.ID, c.Param(api.TriggerRunID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	if !run.Replayable {
+		handleErrorResponse(c, models.ErrTriggerRunNotReplayable)
+		return
+	}
+	payload, err := s.triggerRuns.open(ctx, run)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	fn, err := s.datastore.GetFnByID(ctx, trigger.FnID)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	app, err := s.datastore.GetAppByID(ctx, trigger.AppID)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	req, err := http.NewRequest(run.Method, run.URL, bytes.NewReader(payload))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	req = req.WithContext(ctx)
+	if run.ContentType != "" {
+		req.Header.Set("Content-Type", run.ContentType)
+	}
+	req.Header.Set("Fn-Http-Method", run.Method)
+	req.Header.Set("Fn-Http-Request-Url", run.URL)
+	if trigger.Type == models.TriggerTypeCron {
+		req.Header.Set("Fn-Intent", "cron")
+	} else {
+		req.Header.Set("Fn-Intent", "httprequest")
+	}
+
+	// the fn's response is discarded, the run records how its call ended
+	writer := &syncResponseWriter{
+		headers: make(http.Header),
+		Buffer:  new(bytes.Buffer),
+	}
+	err = s.fnInvoke(writer, req, app, fn, trigger)
+
+	rerunID := writer.Header().Get("Fn-Call-Id")
+	if rerunID == "" {
+		// the call was refused before it fired
+		handleErrorResponse(c, err)
+		return
+	}
+	rerun, err := s.datastore.GetTriggerRun(ctx, trigger.ID, rerunID)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rerun)
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/id"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+)
+
+func TestTriggerRunsListener(t *testing.T) {
+	ctx := context.Background()
+	ds := datastore.NewMock()
+	keys, current, err := agent.ParseStaticKeys("k1=" + base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kp, err := agent.NewStaticKeyProvider(keys, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &triggerRuns{
+		ds:        func() models.Datastore { return ds },
+		keys:      func() agent.KeyProvider { return kp },
+		redactors: func() []fnext.CallInputRedactor { return []fnext.CallInputRedactor{testInputRedactor{}} },
+	}
+	trigger := &models.Trigger{ID: "trigger_id", AppID: "app_id", FnID: "fn_id", Source: "/src"}
+	recorded := &models.TriggerRuns{TTL: 60}
+
+	fire := func(policy *models.TriggerRuns, contentType, body string) (*models.Call, *http.Request) {
+		req := httptest.NewRequest(http.MethodPost, "/t/app/src", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Fn-Http-Method", http.MethodPut)
+		req.Header.Set("Fn-Http-Request-Url", "http://example.com/t/app/src?q=1")
+
+		payload, replayable := tr.capture(req, policy)
+		call := &models.Call{ID: id.New().String(), AppID: trigger.AppID, FnID: trigger.FnID, TriggerID: trigger.ID, CreatedAt: common.DateTime(time.Now())}
+		tr.start(ctx, req, trigger, policy, call, payload, replayable)
+		return call, req
+	}
+
+	call, req := fire(recorded, "text/plain", "hello tok_123")
+	// the fn still gets the whole body
+	if body, _ := ioutil.ReadAll(req.Body); string(body) != "hello tok_123" {
+		t.Fatalf("Expected fn to read the body `hello tok_123`, but got `%s`", body)
+	}
+	call.Status = "success"
+	if err := tr.AfterCall(ctx, call); err != nil {
+		t.Fatalf("unexpected error ending call: %v", err)
+	}
+	run, err := ds.GetTriggerRun(ctx, trigger.ID, call.ID)
+	if err != nil {
+		t.Fatalf("failed to get trigger run: %v", err)
+	}
+	if run.Status != models.CallStateSucceeded || run.Method != http.MethodPut || run.URL != "http://example.com/t/app/src?q=1" ||
+		run.ContentType != "text/plain" || !run.Replayable {
+		t.Errorf("Expected a succeeded, replayable run of the request, but got %+v", run)
+	}
+	if run.PayloadKey == "" || strings.Contains(string(run.Payload), "hello") {
+		t.Errorf("Expected the payload to be encrypted, but got %+v", run)
+	}
+	if payload, err := tr.open(ctx, run); err != nil || string(payload) != "hello tok_***" {
+		t.Errorf("Expected the redacted payload, but got %q %v", payload, err)
+	}
+	if ttl := time.Time(run.ExpiresAt).Sub(time.Time(run.CreatedAt)); ttl < 59*time.Second || ttl > 61*time.Second {
+		t.Errorf("Expected the run to expire after the ttl of the trigger, but got %v", ttl)
+	}
+
+	// the fields the trigger redacts are redacted, and payloads that cannot be redacted are not recorded
+	policy := &models.TriggerRuns{Redact: []string{"password"}}
+	call, _ = fire(policy, "application/json", `{"user": "bob", "password": "hunter2"}`)
+	run, _ = ds.GetTriggerRun(ctx, trigger.ID, call.ID)
+	if payload, err := tr.open(ctx, run); err != nil || string(payload) != `{"password":"[REDACTED]","user":"bob"}` {
+		t.Errorf("Expected the redacted payload, but got %q %v", payload, err)
+	}
+	if ttl := time.Time(run.ExpiresAt).Sub(time.Time(run.CreatedAt)); ttl < models.DefaultTriggerRunTTL*time.Second-time.Second {
+		t.Errorf("Expected the run to expire after the default ttl, but got %v", ttl)
+	}
+	call, _ = fire(policy, "text/plain", "password")
+	if run, _ = ds.GetTriggerRun(ctx, trigger.ID, call.ID); run.Replayable || run.Payload != nil {
+		t.Errorf("Expected a run without its payload, but got %+v", run)
+	}
+
+	// too large to replay, but the fn must not notice
+	large := strings.Repeat("a", models.MaxTriggerRunPayload+1)
+	call, req = fire(recorded, "text/plain", large)
+	if body, _ := ioutil.ReadAll(req.Body); string(body) != large {
+		t.Fatalf("Expected fn to read the whole body, but got %d bytes", len(body))
+	}
+	tr.abort(ctx, call, models.ErrCallTimeoutServerBusy)
+	run, _ = ds.GetTriggerRun(ctx, trigger.ID, call.ID)
+	if run.Status != models.CallStateFailed || run.Error != models.ErrCallTimeoutServerBusy.Error() || run.Replayable || run.Payload != nil {
+		t.Errorf("Expected a failed run that cannot be replayed, but got %+v", run)
+	}
+
+	// triggers without a runs policy, and calls that are not made by triggers, have no runs
+	call, _ = fire(nil, "text/plain", "hello")
+	call.Status = "success"
+	other := &models.Call{ID: id.New().String(), FnID: "fn_id", Status: "success"}
+	for _, c := range []*models.Call{call, other} {
+		if err := tr.AfterCall(ctx, c); err != nil {
+			t.Errorf("unexpected error ending call: %v", err)
+		}
+		if _, err := ds.GetTriggerRun(ctx, trigger.ID, c.ID); err != models.ErrTriggerRunNotFound {
+			t.Errorf("Expected error `%v`, but got `%v`", models.ErrTriggerRunNotFound, err)
+		}
+	}
+}
+
+func TestTriggerRunSweep(t *testing.T) {
+	run := &models.TriggerRun{ID: id.New().String(), TriggerID: "trigger_id", Status: models.CallStateSucceeded,
+		CreatedAt: common.DateTime(time.Now()), ExpiresAt: common.DateTime(time.Now().Add(-time.Minute))}
+	ds := datastore.NewMockInit([]*models.TriggerRun{run})
+	srv := testServer(ds, nil, ServerTypeAPI, WithLockStore(common.NewMemoryLockStore()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv.runSweeps(ctx)
+
+	for i := 0; i < 100; i++ {
+		if _, err := ds.GetTriggerRun(ctx, run.TriggerID, run.ID); err == models.ErrTriggerRunNotFound {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected the expired run to be swept")
+}
+
+func TestTriggerRunGetAndList(t *testing.T) {
+	buf := setLogBuffer()
+
+	a := &models.App{Name: "a", ID: "app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID}
+	tr := &models.Trigger{ID: "trigger_id", Name: "t", AppID: a.ID, FnID: f.ID, Type: models.TriggerTypeHTTP, Source: "/src"}
+	tr.Annotations, _ = models.Annotations{}.With(models.TriggerRunsAnnotation, &models.TriggerRuns{})
+	unrecorded := &models.Trigger{ID: "unrecorded_id", Name: "u", AppID: a.ID, FnID: f.ID, Type: models.TriggerTypeHTTP, Source: "/unrecorded"}
+	now := common.DateTime(time.Now())
+	succeeded := &models.TriggerRun{ID: id.New().String(), TriggerID: tr.ID, AppID: a.ID, FnID: f.ID, Status: models.CallStateSucceeded, Method: "POST", URL: "http://localhost/t/a/src", Payload: []byte("hello"), Replayable: true, CreatedAt: now}
+	tooLarge := &models.TriggerRun{ID: id.New().String(), TriggerID: tr.ID, AppID: a.ID, FnID: f.ID, Status: models.CallStateFailed, Method: "POST", URL: "http://localhost/t/a/src", CreatedAt: now}
+	// runs of triggers that stopped recording them are kept until they expire, but cannot be re-run
+	stopped := &models.TriggerRun{ID: id.New().String(), TriggerID: unrecorded.ID, AppID: a.ID, FnID: f.ID, Status: models.CallStateSucceeded, Method: "POST", URL: "http://localhost/t/a/unrecorded", Replayable: true, CreatedAt: now}
+	ds := datastore.NewMockInit([]*models.App{a}, []*models.Fn{f}, []*models.Trigger{tr, unrecorded}, []*models.TriggerRun{succeeded, tooLarge, stopped})
+
+	srv := testServer(ds, &workflowAgent{}, ServerTypeFull)
+	api := testServer(ds, nil, ServerTypeAPI)
+
+	for i, test := range []struct {
+		srv          *Server
+		method       string
+		path         string
+		expectedCode int
+		expectedID   string
+	}{
+		{srv, http.MethodGet, "/v2/triggers/trigger_id/runs/" + succeeded.ID, http.StatusOK, succeeded.ID},
+		{srv, http.MethodGet, "/v2/triggers/other_trigger_id/runs/" + succeeded.ID, http.StatusNotFound, ""},
+		{srv, http.MethodGet, "/v2/triggers/trigger_id/runs?status=bogus", http.StatusBadRequest, ""},
+		{srv, http.MethodPost, "/v2/triggers/trigger_id/runs/" + tooLarge.ID + "/rerun", http.StatusBadRequest, ""},
+		{srv, http.MethodPost, "/v2/triggers/trigger_id/runs/missing/rerun", http.StatusNotFound, ""},
+		{srv, http.MethodPost, "/v2/triggers/unrecorded_id/runs/" + stopped.ID + "/rerun", http.StatusBadRequest, ""},
+		// api nodes have no agent to re-run triggers on
+		{api, http.MethodPost, "/v2/triggers/trigger_id/runs/" + succeeded.ID + "/rerun", http.StatusNotImplemented, ""},
+		{api, http.MethodGet, "/v2/triggers/trigger_id/runs/" + succeeded.ID, http.StatusOK, succeeded.ID},
+	} {
+		_, rec := routerRequest(t, test.srv.Router, test.method, test.path, nil)
+
+		if rec.Code != test.expectedCode {
+			t.Log(buf.String())
+			t.Fatalf("Test %d: Expected status code to be %d but was %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+
+		if test.expectedID != "" {
+			var run models.TriggerRun
+			if err := json.NewDecoder(rec.Body).Decode(&run); err != nil {
+				t.Fatalf("Test %d: could not decode run: %v", i, err)
+			}
+			if run.ID != test.expectedID {
+				t.Errorf("Test %d: Expected run %s but got %s", i, test.expectedID, run.ID)
+			}
+		}
+		buf.Reset()
+	}
+
+	_, rec := routerRequest(t, srv.Router, http.MethodGet, "/v2/triggers/trigger_id/runs?status=failed", nil)
+	var runs models.TriggerRunList
+	if err := json.NewDecoder(bytes.NewReader(rec.Body.Bytes())).Decode(&runs); err != nil {
+		t.Fatalf("could not decode runs: %v", err)
+	}
+	if len(runs.Items) != 1 || runs.Items[0].ID != tooLarge.ID {
+		t.Fatalf("Expected only the failed run, but got %+v", runs.Items)
+	}
+
+	// payloads are only returned with single runs
+	_, rec = routerRequest(t, srv.Router, http.MethodGet, "/v2/triggers/trigger_id/runs?status=succeeded", nil)
+	if err := json.NewDecoder(bytes.NewReader(rec.Body.Bytes())).Decode(&runs); err != nil {
+		t.Fatalf("could not decode runs: %v", err)
+	}
+	if len(runs.Items) != 1 || runs.Items[0].Payload != nil {
+		t.Fatalf("Expected the succeeded run without its payload, but got %+v", runs.Items)
+	}
+	_, rec = routerRequest(t, srv.Router, http.MethodGet, "/v2/triggers/trigger_id/runs/"+succeeded.ID, nil)
+	var run models.TriggerRun
+	if err := json.NewDecoder(rec.Body).Decode(&run); err != nil || string(run.Payload) != "hello" {
+		t.Fatalf("Expected the run with its payload, but got %+v %v", run, err)
+	}
+}
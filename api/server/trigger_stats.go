@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// otherTriggerSource tags the metrics of the trigger sources past the most tracked
+const otherTriggerSource = "other"
+
+var (
+	triggerSourceKey = common.MakeKey("trigger_source")
+
+	triggerRequestSizeMeasure  = common.MakeMeasure("trigger/request_size", "Size of the bodies of http trigger requests", stats.UnitBytes)
+	triggerResponseSizeMeasure = common.MakeMeasure("trigger/response_size", "Size of the bodies of http trigger responses", stats.UnitBytes)
+	triggerLatencyMeasure      = common.MakeMeasure("trigger/latency", "Latency distribution of http trigger requests", stats.UnitMilliseconds)
+)
+
+// RegisterTriggerViews registers the views of http trigger requests, tagged by app, trigger source, method and
+// status. The sources past the most tracked by a server are tagged as "other", see EnvTriggerMetricsMaxSources.
+func RegisterTriggerViews(tagKeys []string, latencyDist, sizeDist []float64) {
+	keys := []tag.Key{agent.AppIDMetricKey, triggerSourceKey, methodKey, statusKey}
+	for _, key := range tagKeys {
+		switch key {
+		case agent.AppIDMetricKey.Name(), triggerSourceKey.Name(), methodKey.Name(), statusKey.Name():
+		default:
+			keys = append(keys, common.MakeKey(key))
+		}
+	}
+
+	err := view.Register(
+		common.CreateViewWithTags(triggerRequestSizeMeasure, view.Distribution(sizeDist...), keys),
+		common.CreateViewWithTags(triggerResponseSizeMeasure, view.Distribution(sizeDist...), keys),
+		common.CreateViewWithTags(triggerLatencyMeasure, view.Distribution(latencyDist...), keys),
+	)
+	if err != nil {
+		logrus.WithError(err).Fatal("cannot register view")
+	}
+}
+
+// WithTriggerMetricsMaxSources sets the most trigger sources the metrics of http triggers are tagged with, to bound
+// their cardinality. The metrics of the rest are tagged as "other". 0 tags them all as "other".
+func WithTriggerMetricsMaxSources(max int) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.triggerSources = newTriggerSources(max)
+		return nil
+	}
+}
+
+// triggerSources tracks the trigger sources metrics are tagged with, up to max of them
+type triggerSources struct {
+	lock sync.Mutex
+	max  int
+	seen map[string]struct{}
+}
+
+func newTriggerSources(max int) *triggerSources {
+	return &triggerSources{max: max, seen: make(map[string]struct{})}
+}
+
+// tag returns the tag of the source of a trigger of app, the source if it is tracked, or otherTriggerSource
+func (t *triggerSources) tag(appID, source string) string {
+	key := appID + "\x00" + source
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, ok := t.seen[key]; ok {
+		return source
+	}
+	if len(t.seen) >= t.max {
+		return otherTriggerSource
+	}
+	t.seen[key] = struct{}{}
+	return source
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// recordTrigger records the sizes and latency of a request to an http trigger, which returned err
+func (s *Server) recordTrigger(c *gin.Context, app *models.App, trigger *models.Trigger, reqSize int64, start time.Time, err error) {
+	ctx, terr := tag.New(c.Request.Context(),
+		tag.Upsert(agent.AppIDMetricKey, app.ID),
+		tag.Upsert(triggerSourceKey, s.triggerSources.tag(app.ID, trigger.Source)),
+		tag.Upsert(methodKey, c.Request.Method),
+		tag.Upsert(statusKey, strconv.Itoa(triggerStatus(c.Writer, err))),
+	)
+	if terr != nil {
+		logrus.Fatal(terr)
+	}
+
+	respSize := c.Writer.Size()
+	if respSize < 0 {
+		respSize = 0
+	}
+	stats.Record(ctx,
+		triggerRequestSizeMeasure.M(reqSize),
+		triggerResponseSizeMeasure.M(int64(respSize)),
+	)
+	common.RecordWithExemplar(ctx, triggerLatencyMeasure.M(int64(time.Since(start)/time.Millisecond)))
+}
+
+// triggerStatus is the status of the response to a trigger request, which is yet to be written for errors the
+// response was not committed for
+func triggerStatus(w gin.ResponseWriter, err error) int {
+	if err == nil || w.Written() {
+		return w.Status()
+	}
+	if e, ok := err.(models.APIError); ok {
+		return e.Code()
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestTriggerSourcesCardinality(t *testing.T) {
+	sources := newTriggerSources(2)
+
+	if tag := sources.tag("app1", "/hello"); tag != "/hello" {
+		t.Fatalf("expected the source, got %s", tag)
+	}
+	if tag := sources.tag("app2", "/hello"); tag != "/hello" {
+		t.Fatalf("expected the source of another app, got %s", tag)
+	}
+	if tag := sources.tag("app1", "/bye"); tag != otherTriggerSource {
+		t.Fatalf("expected sources past the most tracked to be other, got %s", tag)
+	}
+	if tag := sources.tag("app1", "/hello"); tag != "/hello" {
+		t.Fatalf("expected tracked sources to keep their tag, got %s", tag)
+	}
+
+	if tag := newTriggerSources(0).tag("app1", "/hello"); tag != otherTriggerSource {
+		t.Fatalf("expected all sources to be other, got %s", tag)
+	}
+}
+
+func TestTriggerStatus(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if status := triggerStatus(c.Writer, models.ErrFnsNotFound); status != http.StatusNotFound {
+		t.Fatalf("expected the status of the api error, got %d", status)
+	}
+	if status := triggerStatus(c.Writer, errors.New("boom")); status != http.StatusInternalServerError {
+		t.Fatalf("expected an internal error, got %d", status)
+	}
+
+	c.Writer.WriteHeader(http.StatusAccepted)
+	c.Writer.WriteHeaderNow()
+	if status := triggerStatus(c.Writer, errors.New("boom")); status != http.StatusAccepted {
+		t.Fatalf("expected the status written, got %d", status)
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+// applyRequestTransform rewrites req as tf describes, before it is transposed for the fn. If the body is
+// encoded, the returned closer must be closed once the call is done, to stop encoding a body the fn did not read.
+func applyRequestTransform(req *http.Request, tf *models.TriggerRequestTransform) io.Closer {
+	for _, k := range tf.StripHeaders {
+		req.Header.Del(k)
+	}
+	for k, v := range tf.SetHeaders {
+		req.Header.Set(k, v)
+	}
+	if tf.ContentType != "" {
+		req.Header.Set("Content-Type", tf.ContentType)
+	}
+	if len(tf.Query) > 0 {
+		q := req.URL.Query()
+		for k, v := range tf.Query {
+			q.Set(k, v)
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	if !tf.Base64Body || req.Body == nil {
+		return nil
+	}
+
+	// the encoded length is only known up front if the caller sent one
+	req.Header.Del("Content-Length")
+	if req.ContentLength > 0 {
+		req.ContentLength = int64(base64.StdEncoding.EncodedLen(int(req.ContentLength)))
+	}
+
+	body := req.Body
+	pr, pw := io.Pipe()
+	go func() {
+		defer body.Close()
+		enc := base64.NewEncoder(base64.StdEncoding, pw)
+		_, err := io.Copy(enc, body)
+		if err == nil {
+			err = enc.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	req.Body = pr
+	return pr
+}
+
+// applyResponseTransform rewrites the gateway headers of a response as tf describes
+func applyResponseTransform(headers http.Header, tf *models.TriggerResponseTransform) {
+	for _, k := range tf.StripHeaders {
+		headers.Del(k)
+	}
+	for k, v := range tf.SetHeaders {
+		headers.Set(k, v)
+	}
+	if tf.ContentType != "" {
+		headers.Set("Content-Type", tf.ContentType)
+	}
+}
+
+// base64DecodeWriter base64 decodes what is written to it into w. Line breaks are ignored, and any incomplete
+// quantum is held back until the rest of it is written.
+type base64DecodeWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (d *base64DecodeWriter) Write(b []byte) (int, error) {
+	for _, c := range b {
+		if c != '\r' && c != '\n' {
+			d.buf = append(d.buf, c)
+		}
+	}
+	n := len(d.buf) / 4 * 4
+	if n == 0 {
+		return len(b), nil
+	}
+	out := make([]byte, base64.StdEncoding.DecodedLen(n))
+	m, err := base64.StdEncoding.Decode(out, d.buf[:n])
+	if err != nil {
+		return 0, err
+	}
+	d.buf = append(d.buf[:0], d.buf[n:]...)
+	if _, err := d.w.Write(out[:m]); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/models"
+)
+
+func TestTriggerRequestTransform(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/t/app/hook?v=1&q=x", strings.NewReader("\x00binary\xff"))
+	req.Header.Set("Cookie", "secret")
+	req.Header.Set("X-Keep", "yes")
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	body := applyRequestTransform(req, &models.TriggerRequestTransform{
+		StripHeaders: []string{"cookie"},
+		SetHeaders:   map[string]string{"X-Source": "gateway"},
+		Query:        map[string]string{"v": "2"},
+		ContentType:  "text/plain",
+		Base64Body:   true,
+	})
+	if body == nil {
+		t.Fatal("expected a closer for the encoded body")
+	}
+	defer body.Close()
+
+	if req.Header.Get("Cookie") != "" {
+		t.Errorf("expected Cookie to be stripped, got %q", req.Header.Get("Cookie"))
+	}
+	if req.Header.Get("X-Keep") != "yes" || req.Header.Get("X-Source") != "gateway" {
+		t.Errorf("unexpected headers %v", req.Header)
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("expected content type to be overridden, got %q", ct)
+	}
+	if q := req.URL.Query(); q.Get("v") != "2" || q.Get("q") != "x" {
+		t.Errorf("unexpected query %q", req.URL.RawQuery)
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := base64.StdEncoding.EncodeToString([]byte("\x00binary\xff")); string(b) != expected {
+		t.Errorf("expected body %q, got %q", expected, b)
+	}
+	if req.ContentLength != int64(len(b)) {
+		t.Errorf("expected content length %d, got %d", len(b), req.ContentLength)
+	}
+
+	// nothing to clean up without encoding
+	req = httptest.NewRequest(http.MethodGet, "/t/app/hook", nil)
+	if body := applyRequestTransform(req, &models.TriggerRequestTransform{Query: map[string]string{"v": "2"}}); body != nil {
+		t.Error("expected no closer without body encoding")
+	}
+}
+
+func TestTriggerResponseTransform(t *testing.T) {
+	rec := httptest.NewRecorder()
+	trw := &triggerResponseWriter{inner: rec, transform: &models.TriggerResponseTransform{
+		StripHeaders: []string{"X-Internal"},
+		SetHeaders:   map[string]string{"Cache-Control": "no-cache"},
+		ContentType:  "image/png",
+		Base64Body:   true,
+	}}
+	trw.Header().Set("Fn-Http-H-X-Internal", "debug")
+	trw.Header().Set("Fn-Http-H-X-Other", "kept")
+	trw.Header().Set("Content-Type", "text/plain")
+	trw.Header().Set("Fn-Http-Status", "201")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\nimage data"))
+	// split mid quantum, with the trailing newline fns tend to print
+	for _, chunk := range []string{encoded[:5], encoded[5:11], encoded[11:] + "\n"} {
+		if _, err := trw.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+	if rec.Header().Get("X-Internal") != "" || rec.Header().Get("X-Other") != "kept" || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("expected content type to be overridden, got %q", ct)
+	}
+	if body := rec.Body.String(); body != "\x89PNG\r\n\x1a\nimage data" {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	rec = httptest.NewRecorder()
+	trw = &triggerResponseWriter{inner: rec, transform: &models.TriggerResponseTransform{Base64Body: true}}
+	if _, err := trw.Write([]byte("not base64!")); err == nil {
+		t.Error("expected an error decoding an invalid body")
+	}
+}
@@ -40,3 +40,33 @@ func (s *Server) handleTriggerUpdate(c *gin.Context) {
 
 	c.JSON(http.StatusOK, triggerUpdated)
 }
+
+// handleTriggerPatch updates a trigger with a JSON merge patch or JSON patch of it, see patchUpdate
+func (s *Server) handleTriggerPatch(c *gin.Context) {
+	ctx := c.Request.Context()
+	pathTriggerID := c.Param(api.TriggerID)
+
+	trigger, err := s.datastore.GetTriggerByID(ctx, pathTriggerID)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	update := &models.Trigger{}
+	if err := patchUpdate(c, trigger, update); err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	if update.ID != "" && update.ID != pathTriggerID {
+		handleErrorResponse(c, models.ErrTriggerIDMismatch)
+		return
+	}
+	update.ID = pathTriggerID
+
+	triggerUpdated, err := s.datastore.UpdateTrigger(ctx, update)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, triggerUpdated)
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/config"
+	"github.com/fnproject/fn/api/models"
+	pool "github.com/fnproject/fn/api/runnerpool"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrAdminUnauthorized is returned for requests to change settings without the admin token
+	ErrAdminUnauthorized = models.NewAPIError(http.StatusUnauthorized, errors.New("Missing or invalid admin token"))
+	// ErrSettingNotTunable is returned for settings that cannot be changed at runtime
+	ErrSettingNotTunable = models.NewAPIError(http.StatusNotFound, config.ErrNotTunable)
+)
+
+type tunablesResponse struct {
+	Items []config.Setting `json:"items"`
+	// Changes are the most recent changes of settings, oldest first
+	Changes []config.Change `json:"changes"`
+}
+
+type tuneRequest struct {
+	Value string `json:"value"`
+}
+
+// WithAdminToken enables changing the tunable settings and runner tokens at runtime through the admin server, for
+// requests with the bearer token. An empty token disables it.
+func WithAdminToken(token string) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.adminToken = token
+		return nil
+	}
+}
+
+// tuneLogLevel changes the log level, rejecting unknown levels rather than falling back to info
+func tuneLogLevel(level string) error {
+	if _, err := logrus.ParseLevel(level); err != nil {
+		return err
+	}
+	common.SetLogLevel(level)
+	return nil
+}
+
+// addPlacerTunables makes the timeouts of the placer tunable at runtime, calls placed after a change use them
+func addPlacerTunables(placer pool.Placer) {
+	p, ok := placer.(pool.TunablePlacer)
+	if !ok {
+		return
+	}
+	tune := func(set func(cfg *pool.PlacerConfig, value string) error) func(string) error {
+		return func(value string) error {
+			cfg := placer.GetPlacerConfig()
+			if err := set(&cfg, value); err != nil {
+				return err
+			}
+			p.SetPlacerConfig(cfg)
+			return nil
+		}
+	}
+	config.Tunable(EnvPlacerTimeout, tune(func(cfg *pool.PlacerConfig, value string) (err error) {
+		cfg.PlacerTimeout, err = config.ParseDuration(value)
+		return err
+	}))
+	config.Tunable(EnvDetachedPlacerTimeout, tune(func(cfg *pool.PlacerConfig, value string) (err error) {
+		cfg.DetachedPlacerTimeout, err = config.ParseDuration(value)
+		return err
+	}))
+	config.Tunable(EnvPlacerRetryAllDelay, tune(func(cfg *pool.PlacerConfig, value string) (err error) {
+		cfg.RetryAllDelay, err = config.ParseDuration(value)
+		return err
+	}))
+}
+
+// addRequestLimitTunables makes the request concurrency limits tunable at runtime, requests arriving after a change
+// are held to them
+func (s *Server) addRequestLimitTunables() {
+	tune := func(set func(limits *requestLimits, value string) error) func(string) error {
+		return func(value string) error {
+			limits := s.requestLimiter.get()
+			if err := set(&limits, value); err != nil {
+				return err
+			}
+			return s.requestLimiter.set(limits)
+		}
+	}
+	config.Tunable(EnvMaxConcurrentRequests, tune(func(limits *requestLimits, value string) (err error) {
+		limits.maxRequests, err = strconv.Atoi(value)
+		return err
+	}))
+	config.Tunable(EnvMaxQueuedRequests, tune(func(limits *requestLimits, value string) (err error) {
+		limits.maxQueued, err = strconv.Atoi(value)
+		return err
+	}))
+	config.Tunable(EnvRequestQueueTimeout, tune(func(limits *requestLimits, value string) (err error) {
+		limits.timeout, err = config.ParseDuration(value)
+		return err
+	}))
+}
+
+// requireAdminToken aborts requests without the admin token as a bearer token
+func (s *Server) requireAdminToken(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+		handleErrorResponse(c, ErrAdminUnauthorized)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// handleTunables lists the settings that can be changed at runtime, and the recent changes
+func (s *Server) handleTunables(c *gin.Context) {
+	c.JSON(http.StatusOK, tunablesResponse{Items: config.Tunables(), Changes: config.Changes()})
+}
+
+// handleTune changes a tunable setting, logging the change for auditing
+func (s *Server) handleTune(c *gin.Context) {
+	var req tuneRequest
+	if err := c.BindJSON(&req); err != nil {
+		handleErrorResponse(c, models.ErrInvalidJSON)
+		return
+	}
+
+	change, err := config.Tune(c.Param("name"), req.Value, c.ClientIP())
+	if err == config.ErrNotTunable {
+		handleErrorResponse(c, ErrSettingNotTunable)
+		return
+	}
+	if err != nil {
+		handleErrorResponse(c, models.NewAPIError(http.StatusBadRequest, err))
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{"setting": change.Name, "old": change.Old, "new": change.New, "by": change.By}).Info("Setting changed at runtime")
+	c.JSON(http.StatusOK, change)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fnproject/fn/api/config"
+	"github.com/fnproject/fn/api/datastore"
+)
+
+func TestTuneSettings(t *testing.T) {
+	ds := datastore.NewMock()
+	srv := testServer(ds, nil, ServerTypeAPI, WithAdminToken("secret"))
+
+	var value string
+	config.Tunable("FN_TEST_TUNABLE", func(v string) error {
+		if v == "bad" {
+			return errors.New("bad value")
+		}
+		value = v
+		return nil
+	})
+
+	tune := func(token, name, body string) int {
+		req := createRequest(t, http.MethodPut, "/v2/admin/config/runtime/"+name, strings.NewReader(body))
+		req.RemoteAddr = "10.0.0.1:4321"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		_, rec := routerRequest2(t, srv.AdminRouter, req)
+		return rec.Code
+	}
+
+	for i, test := range []struct {
+		token, name, body string
+		expected          int
+	}{
+		{"", "FN_TEST_TUNABLE", `{"value": "on"}`, http.StatusUnauthorized},
+		{"wrong", "FN_TEST_TUNABLE", `{"value": "on"}`, http.StatusUnauthorized},
+		{"secret", "FN_DB_URL", `{"value": "on"}`, http.StatusNotFound},
+		{"secret", "FN_TEST_TUNABLE", `{"value": "bad"}`, http.StatusBadRequest},
+		{"secret", "FN_TEST_TUNABLE", `{"value": "on"}`, http.StatusOK},
+	} {
+		if code := tune(test.token, test.name, test.body); code != test.expected {
+			t.Errorf("Test %d: expected status %d, got %d", i, test.expected, code)
+		}
+	}
+	if value != "on" {
+		t.Fatalf("expected the setting to be changed, got %q", value)
+	}
+
+	req := createRequest(t, http.MethodGet, "/v2/admin/config/runtime", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	_, rec := routerRequest2(t, srv.AdminRouter, req)
+	var resp tunablesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	last := resp.Changes[len(resp.Changes)-1]
+	if last.Name != "FN_TEST_TUNABLE" || last.New != "on" || last.By != "10.0.0.1" {
+		t.Fatalf("expected the change to be audited, got %+v", resp.Changes)
+	}
+}
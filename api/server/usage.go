@@ -0,0 +1,382 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+	"github.com/gin-gonic/gin"
+)
+
+// usageWindowsKept is how many closed usage windows are kept in memory for the usage API, at least, as windows
+// are kept over models.MaxAppQuotaPeriod for the consumption of quotas
+const usageWindowsKept = 24
+
+// UsageExporter receives the usage of fns each time a usage window closes, eg. to bill for it
+type UsageExporter interface {
+	ExportUsage(ctx context.Context, usage []models.Usage) error
+}
+
+// WithUsageWindow meters the usage of fns over windows of the given length, 0 disables metering
+func WithUsageWindow(window time.Duration) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.usageWindow = window
+		return nil
+	}
+}
+
+// WithUsageExporter adds an exporter that the usage of fns is sent to as each usage window closes
+func WithUsageExporter(exporter UsageExporter) Option {
+	return func(ctx context.Context, s *Server) error {
+		s.usageExporters = append(s.usageExporters, exporter)
+		return nil
+	}
+}
+
+type usageKey struct {
+	appID string
+	fnID  string
+}
+
+// usageMeter aggregates the usage of the calls made through the server per fn, over aligned windows of time.
+// Usage is kept in memory, so each server meters the calls made through it.
+type usageMeter struct {
+	window    time.Duration
+	exporters []UsageExporter
+
+	lock    sync.Mutex
+	start   time.Time
+	current map[usageKey]*models.Usage
+	closed  []models.Usage
+	// usage of closed windows yet to be exported
+	pending [][]models.Usage
+}
+
+var _ fnext.CallListener = new(usageMeter)
+
+func newUsageMeter(window time.Duration, exporters []UsageExporter) *usageMeter {
+	return &usageMeter{
+		window:    window,
+		exporters: exporters,
+		start:     time.Now().Truncate(window),
+		current:   make(map[usageKey]*models.Usage),
+	}
+}
+
+// BeforeCall implements fnext.CallListener
+func (m *usageMeter) BeforeCall(ctx context.Context, call *models.Call) error {
+	return nil
+}
+
+// AfterCall adds the usage of a call to the current window
+func (m *usageMeter) AfterCall(ctx context.Context, call *models.Call) error {
+	usage := models.CallUsage(call)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.roll(time.Now())
+	key := usageKey{appID: usage.AppID, fnID: usage.FnID}
+	u, ok := m.current[key]
+	if !ok {
+		u = &models.Usage{AppID: usage.AppID, FnID: usage.FnID, Start: common.DateTime(m.start)}
+		m.current[key] = u
+	}
+	u.Add(usage)
+	return nil
+}
+
+// roll closes the current window if it has ended. m.lock must be held.
+func (m *usageMeter) roll(now time.Time) {
+	end := m.start.Add(m.window)
+	if now.Before(end) {
+		return
+	}
+	m.close(end)
+	m.start = now.Truncate(m.window)
+}
+
+// close ends the current window at end, and queues its usage to be exported. m.lock must be held.
+func (m *usageMeter) close(end time.Time) {
+	closed := make([]models.Usage, 0, len(m.current))
+	for _, u := range m.current {
+		u.End = common.DateTime(end)
+		closed = append(closed, *u)
+	}
+	sortUsage(closed)
+	m.current = make(map[usageKey]*models.Usage)
+
+	if len(closed) > 0 {
+		m.pending = append(m.pending, closed)
+	}
+	m.closed = append(m.closed, closed...)
+	kept := usageWindowsKept * m.window
+	if kept < models.MaxAppQuotaPeriod {
+		kept = models.MaxAppQuotaPeriod
+	}
+	cutoff := end.Add(-kept)
+	for len(m.closed) > 0 && !time.Time(m.closed[0].End).After(cutoff) {
+		m.closed = m.closed[1:]
+	}
+}
+
+// export sends the usage of each closed window to the exporters
+func (m *usageMeter) export(ctx context.Context) {
+	m.lock.Lock()
+	pending := m.pending
+	m.pending = nil
+	m.lock.Unlock()
+
+	for _, usage := range pending {
+		for _, e := range m.exporters {
+			if err := e.ExportUsage(ctx, usage); err != nil {
+				common.Logger(ctx).WithError(err).Error("failed to export fn usage")
+			}
+		}
+	}
+}
+
+// run closes usage windows as they end, until ctx is done
+func (m *usageMeter) run(ctx context.Context) {
+	for {
+		m.lock.Lock()
+		wait := time.Until(m.start.Add(m.window))
+		m.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		m.lock.Lock()
+		m.roll(time.Now())
+		m.lock.Unlock()
+		m.export(ctx)
+	}
+}
+
+// flush closes the current window early and exports its usage, when the server shuts down
+func (m *usageMeter) flush(ctx context.Context) {
+	m.lock.Lock()
+	m.close(time.Now())
+	m.lock.Unlock()
+	m.export(ctx)
+}
+
+// list returns the usage of the current and retained windows, most recent first, optionally of a single app or fn.
+// The current window ends now.
+func (m *usageMeter) list(appID, fnID string) []models.Usage {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var usage []models.Usage
+	match := func(u models.Usage) bool {
+		return (appID == "" || u.AppID == appID) && (fnID == "" || u.FnID == fnID)
+	}
+	current := make([]models.Usage, 0, len(m.current))
+	for _, u := range m.current {
+		if match(*u) {
+			c := *u
+			c.End = common.DateTime(time.Now())
+			current = append(current, c)
+		}
+	}
+	sortUsage(current)
+	usage = append(usage, current...)
+	for i := len(m.closed) - 1; i >= 0; i-- {
+		if match(m.closed[i]) {
+			usage = append(usage, m.closed[i])
+		}
+	}
+	return usage
+}
+
+// summary totals the usage of an app in the windows that overlap from and to, and over the period of its quota.
+// A zero from or to leaves the range open.
+func (m *usageMeter) summary(app *models.App, from, to time.Time) (*models.AppUsageSummary, error) {
+	quota, err := app.Annotations.Quota()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if to.IsZero() || to.After(now) {
+		to = now
+	}
+
+	summary := &models.AppUsageSummary{AppID: app.ID, From: common.DateTime(from), To: common.DateTime(to), Fns: []models.Usage{}}
+	if quota != nil {
+		since := now.Add(-quota.PeriodDuration())
+		summary.Quota = &models.AppQuotaUsage{
+			Limits: *quota,
+			Used:   models.Usage{AppID: app.ID, Start: common.DateTime(since), End: common.DateTime(now)},
+		}
+	}
+
+	fns := make(map[string]*models.Usage)
+	for _, u := range m.list(app.ID, "") {
+		start, end := time.Time(u.Start), time.Time(u.End)
+		if summary.Quota != nil && end.After(time.Time(summary.Quota.Used.Start)) {
+			summary.Quota.Used.Add(u)
+		}
+		if !end.After(from) || !start.Before(to) {
+			continue
+		}
+		f, ok := fns[u.FnID]
+		if !ok {
+			f = &models.Usage{AppID: u.AppID, FnID: u.FnID, Start: u.Start, End: u.End}
+			fns[u.FnID] = f
+		}
+		f.Add(u)
+		if start.Before(time.Time(f.Start)) {
+			f.Start = u.Start
+		}
+		if end.After(time.Time(f.End)) {
+			f.End = u.End
+		}
+	}
+
+	for _, f := range fns {
+		summary.Fns = append(summary.Fns, *f)
+		summary.Invocations += f.Invocations
+		summary.Errors += f.Errors
+		summary.GBSeconds += f.GBSeconds
+		summary.CPUSeconds += f.CPUSeconds
+	}
+	sortUsage(summary.Fns)
+	if summary.Invocations > 0 {
+		summary.ErrorRate = float64(summary.Errors) / float64(summary.Invocations)
+	}
+	return summary, nil
+}
+
+func sortUsage(usage []models.Usage) {
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].AppID != usage[j].AppID {
+			return usage[i].AppID < usage[j].AppID
+		}
+		return usage[i].FnID < usage[j].FnID
+	})
+}
+
+type usageList struct {
+	Items []models.Usage `json:"items"`
+}
+
+// handleUsageList returns the usage of fns metered by this server
+func (s *Server) handleUsageList(c *gin.Context) {
+	c.JSON(http.StatusOK, usageList{Items: s.usage.list(c.Query("app_id"), c.Query("fn_id"))})
+}
+
+// handleAppUsage summarizes the usage and quota consumption of an app, as metered by this server
+func (s *Server) handleAppUsage(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var from, to common.DateTime
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = common.ParseDateTime(v); err != nil {
+			handleErrorResponse(c, models.ErrInvalidFromTime)
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = common.ParseDateTime(v); err != nil {
+			handleErrorResponse(c, models.ErrInvalidToTime)
+			return
+		}
+	}
+
+	app, err := s.lbReadAccess.GetAppByID(ctx, c.Param(api.AppID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	summary, err := s.usage.summary(app, time.Time(from), time.Time(to))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// UsageObjectStore stores usage reports, eg. a PayloadStore
+type UsageObjectStore interface {
+	Put(ctx context.Context, key string, body io.Reader) error
+}
+
+type csvUsageExporter struct {
+	store  UsageObjectStore
+	prefix string
+}
+
+// NewCSVUsageExporter returns an exporter that puts the usage of each window in store as a CSV file, under prefix
+// followed by the start of the window, eg. usage/20190101T100000Z.csv
+func NewCSVUsageExporter(store UsageObjectStore, prefix string) UsageExporter {
+	return &csvUsageExporter{store: store, prefix: prefix}
+}
+
+func (e *csvUsageExporter) ExportUsage(ctx context.Context, usage []models.Usage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"app_id", "fn_id", "start", "end", "invocations", "errors", "gb_seconds", "cpu_seconds"})
+	for _, u := range usage {
+		w.Write([]string{
+			u.AppID,
+			u.FnID,
+			u.Start.String(),
+			u.End.String(),
+			strconv.FormatUint(u.Invocations, 10),
+			strconv.FormatUint(u.Errors, 10),
+			strconv.FormatFloat(u.GBSeconds, 'f', -1, 64),
+			strconv.FormatFloat(u.CPUSeconds, 'f', -1, 64),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	key := e.prefix + time.Time(usage[0].Start).UTC().Format("20060102T150405Z") + ".csv"
+	return e.store.Put(ctx, key, &buf)
+}
+
+// UsageProducer publishes messages to a topic, eg. a kafka producer
+type UsageProducer interface {
+	Produce(ctx context.Context, key string, value []byte) error
+}
+
+type producerUsageExporter struct {
+	producer UsageProducer
+}
+
+// NewProducerUsageExporter returns an exporter that publishes the usage of each fn as a JSON message, keyed by
+// the id of its app
+func NewProducerUsageExporter(producer UsageProducer) UsageExporter {
+	return &producerUsageExporter{producer: producer}
+}
+
+func (e *producerUsageExporter) ExportUsage(ctx context.Context, usage []models.Usage) error {
+	for _, u := range usage {
+		value, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+		if err := e.producer.Produce(ctx, u.AppID, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
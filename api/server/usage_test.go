@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/models"
+)
+
+type memUsageStore struct {
+	lock    sync.Mutex
+	objects map[string]string
+}
+
+func (s *memUsageStore) Put(ctx context.Context, key string, body io.Reader) error {
+	b, err := ioutil.ReadAll(body)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.objects[key] = string(b)
+	return err
+}
+
+type memUsageProducer struct {
+	messages map[string][]string
+}
+
+func (p *memUsageProducer) Produce(ctx context.Context, key string, value []byte) error {
+	p.messages[key] = append(p.messages[key], string(value))
+	return nil
+}
+
+func TestUsageMeter(t *testing.T) {
+	store := &memUsageStore{objects: make(map[string]string)}
+	producer := &memUsageProducer{messages: make(map[string][]string)}
+	m := newUsageMeter(time.Hour, []UsageExporter{NewCSVUsageExporter(store, "usage/"), NewProducerUsageExporter(producer)})
+	ctx := context.Background()
+
+	now := time.Now()
+	newCall := func(appID, fnID, status string, memory uint64, cpus models.MilliCPUs, duration time.Duration) *models.Call {
+		return &models.Call{
+			AppID:       appID,
+			FnID:        fnID,
+			Status:      status,
+			Memory:      memory,
+			CPUs:        cpus,
+			StartedAt:   common.DateTime(now.Add(-duration)),
+			CompletedAt: common.DateTime(now),
+		}
+	}
+	m.AfterCall(ctx, newCall("app1", "fn1", "success", 512, 500, 2*time.Second))
+	m.AfterCall(ctx, newCall("app1", "fn1", "error", 1024, 0, time.Second))
+	m.AfterCall(ctx, newCall("app2", "fn2", "success", 128, 0, 0))
+
+	usage := m.list("app1", "")
+	if len(usage) != 1 {
+		t.Fatalf("expected the usage of a single fn, got %+v", usage)
+	}
+	u := usage[0]
+	if u.FnID != "fn1" || u.Invocations != 2 || u.Errors != 1 || u.GBSeconds != 2 || u.CPUSeconds != 2 {
+		t.Errorf("unexpected usage %+v", u)
+	}
+	if len(m.list("", "")) != 2 {
+		t.Errorf("expected the usage of both fns, got %+v", m.list("", ""))
+	}
+
+	// the window closes once it ends
+	m.lock.Lock()
+	m.roll(time.Now().Add(time.Hour))
+	m.lock.Unlock()
+	m.AfterCall(ctx, newCall("app1", "fn1", "success", 1024, 1000, time.Second))
+	m.flush(ctx)
+
+	usage = m.list("", "fn1")
+	if len(usage) != 2 || usage[0].Invocations != 1 || usage[1].Invocations != 2 {
+		t.Fatalf("expected the usage of both windows, most recent first, got %+v", usage)
+	}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if len(store.objects) != 2 {
+		t.Fatalf("expected a csv per window, got %v", store.objects)
+	}
+	for key, csv := range store.objects {
+		if !strings.HasPrefix(key, "usage/") || !strings.HasSuffix(key, ".csv") {
+			t.Errorf("unexpected usage key %q", key)
+		}
+		if !strings.HasPrefix(csv, "app_id,fn_id,start,end,invocations,errors,gb_seconds,cpu_seconds\n") {
+			t.Errorf("unexpected usage csv %q", csv)
+		}
+	}
+
+	var exported models.Usage
+	if len(producer.messages["app2"]) != 1 || json.Unmarshal([]byte(producer.messages["app2"][0]), &exported) != nil ||
+		exported.FnID != "fn2" || exported.Invocations != 1 {
+		t.Errorf("unexpected usage messages %v", producer.messages["app2"])
+	}
+}
+
+func TestUsageSummary(t *testing.T) {
+	m := newUsageMeter(time.Hour, nil)
+	now := time.Now()
+	window := func(fnID string, start time.Time, invocations, errors uint64, gbSeconds float64) models.Usage {
+		return models.Usage{AppID: "app", FnID: fnID, Start: common.DateTime(start), End: common.DateTime(start.Add(time.Hour)),
+			Invocations: invocations, Errors: errors, GBSeconds: gbSeconds}
+	}
+	m.closed = []models.Usage{
+		window("fn1", now.Add(-3*time.Hour), 10, 0, 1),
+		window("fn1", now.Add(-2*time.Hour), 20, 5, 2),
+		window("fn2", now.Add(-2*time.Hour), 10, 5, 4),
+		window("fn1", now.Add(-90*time.Minute), 10, 0, 8),
+		{AppID: "other", FnID: "fn3", Start: common.DateTime(now.Add(-2 * time.Hour)), End: common.DateTime(now), Invocations: 100},
+	}
+
+	annotations, _ := models.Annotations{}.With(models.AppQuotaAnnotation, models.AppQuota{Period: "2h", Invocations: 100})
+	app := &models.App{ID: "app", Annotations: annotations}
+
+	summary, err := m.summary(app, now.Add(-2*time.Hour), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Invocations != 40 || summary.Errors != 10 || summary.ErrorRate != 0.25 || summary.GBSeconds != 14 {
+		t.Errorf("unexpected totals %+v", summary)
+	}
+	if len(summary.Fns) != 2 || summary.Fns[0].FnID != "fn1" || summary.Fns[0].Invocations != 30 || summary.Fns[1].Invocations != 10 {
+		t.Errorf("unexpected fn usage %+v", summary.Fns)
+	}
+	if summary.Quota == nil || summary.Quota.Limits.Invocations != 100 || summary.Quota.Used.Invocations != 40 {
+		t.Errorf("unexpected quota usage %+v", summary.Quota)
+	}
+
+	summary, err = m.summary(&models.App{ID: "app"}, time.Time{}, now.Add(-150*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Invocations != 10 || summary.Quota != nil {
+		t.Errorf("expected only the oldest window without a quota, got %+v", summary)
+	}
+}
+
+func TestUsageKeptOverQuotaPeriod(t *testing.T) {
+	// 24 windows of a minute cover much less than the longest quota period
+	m := newUsageMeter(time.Minute, nil)
+	now := time.Now()
+	window := func(end time.Time) models.Usage {
+		return models.Usage{AppID: "app", FnID: "fn", Start: common.DateTime(end.Add(-time.Minute)), End: common.DateTime(end), Invocations: 1}
+	}
+	oldest := window(now.Add(-models.MaxAppQuotaPeriod + time.Minute))
+	m.closed = []models.Usage{window(now.Add(-models.MaxAppQuotaPeriod - time.Minute)), oldest, window(now.Add(-time.Hour))}
+
+	m.lock.Lock()
+	m.close(now)
+	m.lock.Unlock()
+	if len(m.closed) != 2 || m.closed[0] != oldest {
+		t.Fatalf("expected the windows over the longest quota period to be kept, got %+v", m.closed)
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// handleWorkflowRunCreate starts a run of a workflow, with the request body as
+// the input to its first step. The run carries on in the background.
+func (s *Server) handleWorkflowRunCreate(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if s.workflows == nil {
+		handleErrorResponse(c, models.ErrWorkflowsUnsupported)
+		return
+	}
+
+	workflow, err := s.datastore.GetWorkflowByID(ctx, c.Param(api.WorkflowID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	input, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, models.MaxWorkflowRunInput))
+	if err != nil {
+		handleErrorResponse(c, models.ErrRequestContentTooBig)
+		return
+	}
+
+	run, err := s.workflows.start(ctx, workflow, string(input))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, run)
+}
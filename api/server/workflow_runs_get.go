@@ -0,0 +1,20 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleWorkflowRunGet(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	run, err := s.datastore.GetWorkflowRun(ctx, c.Param(api.WorkflowID), c.Param(api.WorkflowRunID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleWorkflowRunList(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var filter models.WorkflowRunFilter
+	filter.Cursor, filter.PerPage = pageParams(c)
+	filter.WorkflowID = c.Param(api.WorkflowID)
+	filter.Status = c.Query("status")
+
+	runs, err := s.datastore.GetWorkflowRuns(ctx, &filter)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
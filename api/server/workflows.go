@@ -0,0 +1,244 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/id"
+	"github.com/fnproject/fn/api/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// workflowRetryBackoff is how long to wait before retrying a failed step,
+	// it grows linearly with each attempt.
+	workflowRetryBackoff = time.Second
+	// workflowRunHeartbeat is how often the progress of a run is recorded
+	// again while a step runs
+	workflowRunHeartbeat = time.Minute
+	// workflowRunLostAfter is how long a run may go without its progress
+	// being recorded before it is failed as lost
+	workflowRunLostAfter = 5 * workflowRunHeartbeat
+)
+
+// workflowExecutor runs workflows on the agent, recording the progress of each
+// run in the datastore. Runs execute in the background on the node that
+// started them, and are not resumed if it stops. Their progress is recorded
+// every heartbeat while they run, and the workflow-runs sweep fails the runs
+// that go without it for workflowRunLostAfter as lost.
+type workflowExecutor struct {
+	ds        func() models.Datastore
+	agent     agent.Agent
+	limits    *resourceLimits
+	backoff   time.Duration
+	heartbeat time.Duration
+}
+
+// workflowProgress records the progress of a run. It keeps the last progress
+// recorded, to record it again every heartbeat, as the run goroutine may be
+// changing the run meanwhile.
+type workflowProgress struct {
+	ds   func() models.Datastore
+	lock sync.Mutex
+	last models.WorkflowRun
+}
+
+// record records the progress of run
+func (p *workflowProgress) record(ctx context.Context, run *models.WorkflowRun) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	run.UpdatedAt = common.DateTime(time.Now())
+	p.last = *run
+	p.last.Outputs = append(models.WorkflowOutputs(nil), run.Outputs...)
+	p.write(ctx)
+}
+
+// beat records the last progress again, as the run is still running
+func (p *workflowProgress) beat(ctx context.Context) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.last.UpdatedAt = common.DateTime(time.Now())
+	p.write(ctx)
+}
+
+func (p *workflowProgress) write(ctx context.Context) {
+	if err := p.ds().UpdateWorkflowRun(ctx, &p.last); err != nil {
+		common.Logger(ctx).WithError(err).Error("failed to update workflow run")
+	}
+}
+
+// beat records the progress of a run every heartbeat until the returned func is called
+func (e *workflowExecutor) beat(ctx context.Context, progress *workflowProgress) (stop func()) {
+	if e.heartbeat <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(e.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				progress.beat(ctx)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// start records a new run of workflow with input, and runs it in the background
+func (e *workflowExecutor) start(ctx context.Context, workflow *models.Workflow, input string) (*models.WorkflowRun, error) {
+	now := common.DateTime(time.Now())
+	run := &models.WorkflowRun{
+		ID:         id.New().String(),
+		WorkflowID: workflow.ID,
+		Status:     models.WorkflowRunStateRunning,
+		Input:      input,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := e.ds().InsertWorkflowRun(ctx, run); err != nil {
+		return nil, err
+	}
+
+	started := *run
+	go e.run(common.BackgroundContext(ctx), workflow, run)
+	return &started, nil
+}
+
+// run invokes the steps of workflow in order, passing the output of each step
+// to the next. If a step fails once out of retries, the steps that completed
+// are compensated and the run fails.
+func (e *workflowExecutor) run(ctx context.Context, workflow *models.Workflow, run *models.WorkflowRun) {
+	ctx, log := common.LoggerWithFields(ctx, logrus.Fields{"workflow_id": workflow.ID, "workflow_run_id": run.ID})
+
+	progress := &workflowProgress{ds: e.ds, last: *run}
+	stop := e.beat(ctx, progress)
+	defer stop()
+
+	input := run.Input
+	for i, step := range workflow.Steps {
+		if i > 0 {
+			run.Step = i
+			progress.record(ctx, run)
+		}
+		out, err := e.invoke(ctx, step.FnID, input, step.Retries)
+		if err != nil {
+			log.WithError(err).WithField("step", i).Info("workflow step failed")
+			run.Error = fmt.Sprintf("step %d: %v", i, err)
+			e.compensate(ctx, workflow, run, progress)
+			return
+		}
+		run.Outputs = append(run.Outputs, out)
+		input = out
+	}
+
+	run.Status = models.WorkflowRunStateSucceeded
+	run.CompletedAt = common.DateTime(time.Now())
+	progress.record(ctx, run)
+}
+
+// compensate invokes the compensating fns of the completed steps of run, in
+// reverse order, each with the output of its step. Compensation is best
+// effort, failures are added to the run's error and the rest carry on.
+func (e *workflowExecutor) compensate(ctx context.Context, workflow *models.Workflow, run *models.WorkflowRun, progress *workflowProgress) {
+	run.Status = models.WorkflowRunStateCompensating
+	progress.record(ctx, run)
+
+	for i := len(run.Outputs) - 1; i >= 0; i-- {
+		step := workflow.Steps[i]
+		if step.CompensateFnID == "" {
+			continue
+		}
+		run.Step = i
+		progress.record(ctx, run)
+		if _, err := e.invoke(ctx, step.CompensateFnID, run.Outputs[i], step.Retries); err != nil {
+			common.Logger(ctx).WithError(err).WithField("step", i).Error("workflow step compensation failed")
+			run.Error += fmt.Sprintf("; compensating step %d: %v", i, err)
+		}
+	}
+
+	run.Status = models.WorkflowRunStateFailed
+	run.CompletedAt = common.DateTime(time.Now())
+	progress.record(ctx, run)
+}
+
+// invoke calls fnID with input, trying up to retries more times if it fails
+func (e *workflowExecutor) invoke(ctx context.Context, fnID, input string, retries int) (string, error) {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * e.backoff):
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+
+		var out string
+		out, err = e.invokeOnce(ctx, fnID, input)
+		// disabled fns stay disabled for the retries
+		if err == nil || err == models.ErrFnDisabled {
+			return out, err
+		}
+	}
+	return "", err
+}
+
+func (e *workflowExecutor) invokeOnce(ctx context.Context, fnID, input string) (string, error) {
+	fn, err := e.ds().GetFnByID(ctx, fnID)
+	if err != nil {
+		return "", err
+	}
+	app, err := e.ds().GetAppByID(ctx, fn.AppID)
+	if err != nil {
+		return "", err
+	}
+	fn, err = e.limits.limit(app, fn)
+	if err != nil {
+		return "", err
+	}
+	if fn.IsDisabled() {
+		return "", models.ErrFnDisabled
+	}
+
+	// give the call as long to find a slot as it has to run
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Duration(fn.Timeout)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, "/invoke/"+fn.ID, strings.NewReader(input))
+	if err != nil {
+		return "", err
+	}
+	writer := &syncResponseWriter{
+		headers: make(http.Header),
+		status:  http.StatusOK,
+		Buffer:  new(bytes.Buffer),
+	}
+
+	call, err := e.agent.GetCall(agent.WithWriter(writer), agent.FromHTTPFnRequest(app, fn, req.WithContext(ctx)))
+	if err != nil {
+		return "", err
+	}
+	if err := e.agent.Submit(call); err != nil {
+		return "", err
+	}
+	if writer.Status() >= http.StatusBadRequest {
+		return "", fmt.Errorf("fn %s returned status %d", fn.ID, writer.Status())
+	}
+	return writer.String(), nil
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleWorkflowCreate(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	workflow := &models.Workflow{}
+	err := c.BindJSON(workflow)
+	if err != nil {
+		if !models.IsAPIError(err) {
+			err = models.ErrInvalidJSON
+		}
+		handleErrorResponse(c, err)
+		return
+	}
+
+	if workflow.ID != "" {
+		handleErrorResponse(c, models.ErrWorkflowsIDProvided)
+		return
+	}
+	if err := workflow.Validate(); err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+	if err := s.checkWorkflowSteps(ctx, workflow); err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	workflow.SetDefaults()
+	workflowCreated, err := s.datastore.InsertWorkflow(ctx, workflow)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workflowCreated)
+}
+
+// checkWorkflowSteps checks that the fns of each step exist in the workflow's app
+func (s *Server) checkWorkflowSteps(ctx context.Context, workflow *models.Workflow) error {
+	for _, step := range workflow.Steps {
+		for _, fnID := range []string{step.FnID, step.CompensateFnID} {
+			if fnID == "" {
+				continue
+			}
+			fn, err := s.datastore.GetFnByID(ctx, fnID)
+			if err == models.ErrFnsNotFound {
+				return models.ErrWorkflowsStepFnNotFound
+			} else if err != nil {
+				return err
+			}
+			if fn.AppID != workflow.AppID {
+				return models.ErrWorkflowsStepFnNotFound
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleWorkflowDelete(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	err := s.datastore.RemoveWorkflow(ctx, c.Param(api.WorkflowID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.String(http.StatusNoContent, "")
+}
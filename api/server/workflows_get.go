@@ -0,0 +1,20 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleWorkflowGet(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	workflow, err := s.datastore.GetWorkflowByID(ctx, c.Param(api.WorkflowID))
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workflow)
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fn/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleWorkflowList(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var filter models.WorkflowFilter
+	filter.Cursor, filter.PerPage = pageParams(c)
+	filter.AppID = c.Query("app_id")
+
+	workflows, err := s.datastore.GetWorkflows(ctx, &filter)
+	if err != nil {
+		handleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workflows)
+}
@@ -0,0 +1,320 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fnproject/fn/api/agent"
+	"github.com/fnproject/fn/api/common"
+	"github.com/fnproject/fn/api/datastore"
+	"github.com/fnproject/fn/api/models"
+	"github.com/fnproject/fn/fnext"
+)
+
+type workflowCall struct{ models.Call }
+
+func (c *workflowCall) Model() *models.Call                      { return &c.Call }
+func (c *workflowCall) Start(context.Context) error              { return nil }
+func (c *workflowCall) End(ctx context.Context, err error) error { return err }
+
+// workflowAgent is an Agent that fails the calls submitted to it in turn as
+// told by results, counting them
+type workflowAgent struct {
+	lock    sync.Mutex
+	results []error
+	calls   int
+}
+
+func (a *workflowAgent) GetCall(...agent.CallOpt) (agent.Call, error) {
+	return &workflowCall{}, nil
+}
+
+func (a *workflowAgent) Submit(agent.Call) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	var err error
+	if a.calls < len(a.results) {
+		err = a.results[a.calls]
+	}
+	a.calls++
+	return err
+}
+
+func (a *workflowAgent) Close() error                       { return nil }
+func (a *workflowAgent) AddCallListener(fnext.CallListener) {}
+
+func waitForWorkflowRun(t *testing.T, ds models.Datastore, workflowID, runID string) *models.WorkflowRun {
+	for i := 0; i < 100; i++ {
+		run, err := ds.GetWorkflowRun(context.Background(), workflowID, runID)
+		if err != nil {
+			t.Fatalf("failed to get workflow run: %v", err)
+		}
+		if run.Status == models.WorkflowRunStateSucceeded || run.Status == models.WorkflowRunStateFailed {
+			return run
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("workflow run did not complete")
+	return nil
+}
+
+func TestWorkflowCreate(t *testing.T) {
+	buf := setLogBuffer()
+
+	a := &models.App{Name: "a", ID: "app_id"}
+	other := &models.App{Name: "other", ID: "other_app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID}
+	o := &models.Fn{ID: "other_fn_id", Name: "o", AppID: other.ID}
+	ds := datastore.NewMockInit([]*models.App{a, other}, []*models.Fn{f, o})
+	srv := testServer(ds, &workflowAgent{}, ServerTypeFull)
+
+	for i, test := range []struct {
+		body          string
+		expectedCode  int
+		expectedError error
+	}{
+		{`{"name": "w", "app_id": "app_id", "steps": [{"fn_id": "fn_id", "retries": 2, "compensate_fn_id": "fn_id"}]}`, http.StatusOK, nil},
+		{`{"name": "w", "app_id": "app_id", "steps": [{"fn_id": "fn_id"}]}`, http.StatusConflict, models.ErrWorkflowsExists},
+		{`{"id": "id", "name": "w2", "app_id": "app_id", "steps": [{"fn_id": "fn_id"}]}`, http.StatusBadRequest, models.ErrWorkflowsIDProvided},
+		{`{"app_id": "app_id", "steps": [{"fn_id": "fn_id"}]}`, http.StatusBadRequest, models.ErrWorkflowsMissingName},
+		{`{"name": "w2", "app_id": "app_id", "steps": []}`, http.StatusBadRequest, models.ErrWorkflowsInvalidSteps},
+		{`{"name": "w2", "app_id": "app_id", "steps": [{"fn_id": "fn_id", "retries": 11}]}`, http.StatusBadRequest, models.ErrWorkflowsInvalidStep},
+		{`{"name": "w2", "app_id": "app_id", "steps": [{"fn_id": "missing"}]}`, http.StatusBadRequest, models.ErrWorkflowsStepFnNotFound},
+		{`{"name": "w2", "app_id": "app_id", "steps": [{"fn_id": "fn_id", "compensate_fn_id": "other_fn_id"}]}`, http.StatusBadRequest, models.ErrWorkflowsStepFnNotFound},
+		{`{"name": "w2", "app_id": "missing", "steps": [{"fn_id": "fn_id"}]}`, http.StatusBadRequest, models.ErrWorkflowsStepFnNotFound},
+	} {
+		_, rec := routerRequest(t, srv.Router, http.MethodPost, "/v2/workflows", bytes.NewBufferString(test.body))
+
+		if rec.Code != test.expectedCode {
+			t.Log(buf.String())
+			t.Fatalf("Test %d: Expected status code to be %d but was %d: %s", i, test.expectedCode, rec.Code, rec.Body.String())
+		}
+
+		if test.expectedError != nil {
+			resp := getErrorResponse(t, rec)
+			if !strings.Contains(resp.Message, test.expectedError.Error()) {
+				t.Errorf("Test %d: Expected error message to have `%s`, but was `%s`", i, test.expectedError.Error(), resp.Message)
+			}
+		}
+		buf.Reset()
+	}
+}
+
+func TestWorkflowRuns(t *testing.T) {
+	buf := setLogBuffer()
+
+	a := &models.App{Name: "a", ID: "app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID, ResourceConfig: models.ResourceConfig{Timeout: 30}}
+	w := &models.Workflow{ID: "workflow_id", Name: "w", AppID: a.ID, Steps: models.WorkflowSteps{{FnID: f.ID}, {FnID: f.ID}}}
+	gone := &models.Workflow{ID: "gone_id", Name: "gone", AppID: a.ID, Steps: models.WorkflowSteps{{FnID: "deleted_fn_id"}}}
+	ds := datastore.NewMockInit([]*models.App{a}, []*models.Fn{f}, []*models.Workflow{w, gone})
+	srv := testServer(ds, &workflowAgent{}, ServerTypeFull)
+
+	for i, test := range []struct {
+		workflowID     string
+		expectedStatus string
+	}{
+		{w.ID, models.WorkflowRunStateSucceeded},
+		{gone.ID, models.WorkflowRunStateFailed},
+	} {
+		_, rec := routerRequest(t, srv.Router, http.MethodPost, "/v2/workflows/"+test.workflowID+"/runs", bytes.NewBufferString("input"))
+		if rec.Code != http.StatusAccepted {
+			t.Log(buf.String())
+			t.Fatalf("Test %d: Expected status code to be %d but was %d: %s", i, http.StatusAccepted, rec.Code, rec.Body.String())
+		}
+		var run models.WorkflowRun
+		if err := json.NewDecoder(rec.Body).Decode(&run); err != nil {
+			t.Fatalf("Test %d: could not decode run: %v", i, err)
+		}
+		if run.Status != models.WorkflowRunStateRunning || run.Input != "input" {
+			t.Errorf("Test %d: Expected a running run with its input, but got %+v", i, run)
+		}
+
+		got := waitForWorkflowRun(t, ds, test.workflowID, run.ID)
+		if got.Status != test.expectedStatus {
+			t.Errorf("Test %d: Expected run status to be %s but was %s: %s", i, test.expectedStatus, got.Status, got.Error)
+		}
+
+		_, rec = routerRequest(t, srv.Router, http.MethodGet, "/v2/workflows/"+test.workflowID+"/runs/"+run.ID, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Test %d: Expected status code to be %d but was %d: %s", i, http.StatusOK, rec.Code, rec.Body.String())
+		}
+		buf.Reset()
+	}
+
+	_, rec := routerRequest(t, srv.Router, http.MethodGet, "/v2/workflows/"+w.ID+"/runs?status=succeeded", nil)
+	var runs models.WorkflowRunList
+	if err := json.NewDecoder(rec.Body).Decode(&runs); err != nil {
+		t.Fatalf("could not decode runs: %v", err)
+	}
+	if len(runs.Items) != 1 || len(runs.Items[0].Outputs) != 2 {
+		t.Fatalf("Expected one succeeded run with an output per step, but got %+v", runs.Items)
+	}
+
+	_, rec = routerRequest(t, srv.Router, http.MethodPost, "/v2/workflows/"+w.ID+"/runs", bytes.NewReader(make([]byte, models.MaxWorkflowRunInput+1)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status code to be %d but was %d: %s", http.StatusRequestEntityTooLarge, rec.Code, rec.Body.String())
+	}
+
+	_, rec = routerRequest(t, srv.Router, http.MethodPost, "/v2/workflows/missing/runs", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected status code to be %d but was %d: %s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+
+	// api nodes have no agent to run workflows on
+	api := testServer(ds, nil, ServerTypeAPI)
+	_, rec = routerRequest(t, api.Router, http.MethodPost, "/v2/workflows/"+w.ID+"/runs", nil)
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("Expected status code to be %d but was %d: %s", http.StatusNotImplemented, rec.Code, rec.Body.String())
+	}
+}
+
+func TestWorkflowRetryAndCompensate(t *testing.T) {
+	a := &models.App{Name: "a", ID: "app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID, ResourceConfig: models.ResourceConfig{Timeout: 30}}
+	ds := datastore.NewMockInit([]*models.App{a}, []*models.Fn{f})
+	w := &models.Workflow{ID: "workflow_id", Name: "w", AppID: a.ID, Steps: models.WorkflowSteps{
+		{FnID: f.ID, CompensateFnID: f.ID},
+		{FnID: f.ID},
+		{FnID: f.ID, Retries: 2, CompensateFnID: f.ID},
+	}}
+
+	boom := errors.New("boom")
+	for i, test := range []struct {
+		results        []error
+		expectedStatus string
+		expectedCalls  int
+	}{
+		// the third step succeeds on its last retry
+		{[]error{nil, nil, boom, boom, nil}, models.WorkflowRunStateSucceeded, 5},
+		// it runs out of retries, so the first step is compensated, the second has nothing to undo
+		{[]error{nil, nil, boom, boom, boom, nil}, models.WorkflowRunStateFailed, 6},
+		// and a compensation that fails too leaves the run failed
+		{[]error{nil, boom, boom}, models.WorkflowRunStateFailed, 3},
+	} {
+		rnr := &workflowAgent{results: test.results}
+		e := &workflowExecutor{ds: func() models.Datastore { return ds }, agent: rnr, backoff: time.Millisecond}
+
+		run, err := e.start(context.Background(), w, "input")
+		if err != nil {
+			t.Fatalf("Test %d: failed to start run: %v", i, err)
+		}
+		got := waitForWorkflowRun(t, ds, w.ID, run.ID)
+		if got.Status != test.expectedStatus {
+			t.Errorf("Test %d: Expected run status to be %s but was %s: %s", i, test.expectedStatus, got.Status, got.Error)
+		}
+		rnr.lock.Lock()
+		if rnr.calls != test.expectedCalls {
+			t.Errorf("Test %d: Expected %d calls, but got %d", i, test.expectedCalls, rnr.calls)
+		}
+		rnr.lock.Unlock()
+	}
+}
+
+func TestWorkflowDisabledFn(t *testing.T) {
+	a := &models.App{Name: "a", ID: "app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID, ResourceConfig: models.ResourceConfig{Timeout: 30}}
+	disabled := true
+	off := &models.Fn{ID: "off_id", Name: "off", AppID: a.ID, ResourceConfig: models.ResourceConfig{Timeout: 30}, Disabled: &disabled}
+	ds := datastore.NewMockInit([]*models.App{a}, []*models.Fn{f, off})
+	w := &models.Workflow{ID: "workflow_id", Name: "w", AppID: a.ID, Steps: models.WorkflowSteps{
+		{FnID: f.ID},
+		{FnID: off.ID, Retries: 3},
+	}}
+
+	rnr := &workflowAgent{}
+	e := &workflowExecutor{ds: func() models.Datastore { return ds }, agent: rnr, backoff: time.Millisecond}
+	run, err := e.start(context.Background(), w, "input")
+	if err != nil {
+		t.Fatalf("failed to start run: %v", err)
+	}
+	got := waitForWorkflowRun(t, ds, w.ID, run.ID)
+	if got.Status != models.WorkflowRunStateFailed || !strings.Contains(got.Error, models.ErrFnDisabled.Error()) {
+		t.Errorf("expected the run to fail on the disabled fn, got %s: %s", got.Status, got.Error)
+	}
+	rnr.lock.Lock()
+	defer rnr.lock.Unlock()
+	if rnr.calls != 1 {
+		t.Errorf("expected the disabled fn to not be called or retried, got %d calls", rnr.calls)
+	}
+}
+
+// blockingAgent is an Agent whose calls block until release is closed
+type blockingAgent struct {
+	workflowAgent
+	release chan struct{}
+}
+
+func (a *blockingAgent) Submit(c agent.Call) error {
+	<-a.release
+	return a.workflowAgent.Submit(c)
+}
+
+func TestWorkflowRunHeartbeat(t *testing.T) {
+	a := &models.App{Name: "a", ID: "app_id"}
+	f := &models.Fn{ID: "fn_id", Name: "f", AppID: a.ID, ResourceConfig: models.ResourceConfig{Timeout: 30}}
+	ds := datastore.NewMockInit([]*models.App{a}, []*models.Fn{f})
+	w := &models.Workflow{ID: "workflow_id", Name: "w", AppID: a.ID, Steps: models.WorkflowSteps{{FnID: f.ID}}}
+
+	rnr := &blockingAgent{release: make(chan struct{})}
+	e := &workflowExecutor{ds: func() models.Datastore { return ds }, agent: rnr, backoff: time.Millisecond, heartbeat: 10 * time.Millisecond}
+	run, err := e.start(context.Background(), w, "input")
+	if err != nil {
+		t.Fatalf("failed to start run: %v", err)
+	}
+
+	// the progress of the blocked step is recorded again while it runs
+	started := time.Time(run.UpdatedAt)
+	beat := false
+	for i := 0; i < 100 && !beat; i++ {
+		time.Sleep(10 * time.Millisecond)
+		got, err := ds.GetWorkflowRun(context.Background(), w.ID, run.ID)
+		if err != nil {
+			t.Fatalf("failed to get workflow run: %v", err)
+		}
+		beat = got.Status == models.WorkflowRunStateRunning && time.Time(got.UpdatedAt).After(started)
+	}
+	if !beat {
+		t.Fatal("expected the running run to be updated every heartbeat")
+	}
+
+	close(rnr.release)
+	if got := waitForWorkflowRun(t, ds, w.ID, run.ID); got.Status != models.WorkflowRunStateSucceeded {
+		t.Errorf("expected the run to succeed, got %s: %s", got.Status, got.Error)
+	}
+}
+
+func TestWorkflowRunSweep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ds := datastore.NewMock()
+	stale := common.DateTime(time.Now().Add(-2 * workflowRunLostAfter))
+	run := &models.WorkflowRun{ID: "run_id", WorkflowID: "workflow_id", Status: models.WorkflowRunStateRunning, CreatedAt: stale, UpdatedAt: stale}
+	if err := ds.InsertWorkflowRun(ctx, run); err != nil {
+		t.Fatal(err)
+	}
+	srv := testServer(ds, nil, ServerTypeAPI, WithLockStore(common.NewMemoryLockStore()))
+	srv.runSweeps(ctx)
+
+	for i := 0; i < 100; i++ {
+		got, err := ds.GetWorkflowRun(ctx, run.WorkflowID, run.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status == models.WorkflowRunStateFailed {
+			if got.Error != models.WorkflowRunLostError {
+				t.Fatalf("expected the run to fail as lost, got %q", got.Error)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected the lost run to be failed")
+}
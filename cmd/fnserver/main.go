@@ -49,8 +49,16 @@ func registerViews() {
 	cKeys := append(keys, agent.AppIDMetricKey.Name(), agent.FnIDMetricKey.Name(), agent.ImageNameMetricKey.Name())
 	agent.RegisterContainerViews(cKeys, latencyDist)
 
+	// calls of each fn, tagged by app, fn and trigger
+	agent.RegisterFnViews(keys, latencyDist)
+
 	// Register docker client views
 	docker.RegisterViews(keys, latencyDist)
 
 	server.RegisterAPIViews(keys, latencyDist)
+
+	// Body sizes in bytes
+	kB := float64(1024)
+	sizeDist := []float64{0, kB, 16 * kB, 64 * kB, 256 * kB, 1024 * kB, 4 * 1024 * kB, 16 * 1024 * kB}
+	server.RegisterTriggerViews(keys, latencyDist, sizeDist)
 }
@@ -80,3 +80,9 @@ type CallListener interface {
 	// AfterCall called after a function completes
 	AfterCall(ctx context.Context, call *models.Call) error
 }
+
+// CallInputRedactor removes sensitive data from the inputs of calls before they are recorded with their call records.
+type CallInputRedactor interface {
+	// RedactCallInput returns input without its sensitive data. Returning an error skips recording the input.
+	RedactCallInput(ctx context.Context, call *models.Call, contentType string, input []byte) ([]byte, error)
+}
@@ -3,6 +3,7 @@ package fnext
 import (
 	"net/http"
 
+	"github.com/fnproject/fn/api/common"
 	"github.com/fnproject/fn/api/models"
 )
 
@@ -35,6 +36,13 @@ type ExtServer interface {
 	// AddEndpoint adds an endpoint to /v2/x
 	AddEndpointFunc(method, path string, handler func(w http.ResponseWriter, r *http.Request))
 
+	// AddAnnotationSchema registers a JSON Schema that annotations with keys in namespace must satisfy
+	AddAnnotationSchema(namespace string, schema []byte) error
+
 	// Datastore returns the Datastore Fn is using
 	Datastore() models.Datastore
+
+	// LockStore returns the store of the distributed locks shared by the servers of the deployment, which
+	// extensions elect the server doing cluster wide work with, see common.Lock and common.Elect
+	LockStore() common.LockStore
 }